@@ -62,6 +62,14 @@ func main() {
 	cmd.RegisterMigrateFlags(migrateCmd)
 	rootCmd.AddCommand(migrateCmd)
 
+	migrateStatusCmd := cmd.NewMigrateStatusCommand(rootCmd.Use)
+	cmd.RegisterMigrateFlags(migrateStatusCmd)
+	migrateCmd.AddCommand(migrateStatusCmd)
+
+	migratePlanCmd := cmd.NewMigratePlanCommand(rootCmd.Use)
+	cmd.RegisterMigrateFlags(migratePlanCmd)
+	migrateCmd.AddCommand(migratePlanCmd)
+
 	// Add migration commands
 	datastoreCmd, err := cmd.NewDatastoreCommand(rootCmd.Use)
 	if err != nil {
@@ -84,6 +92,29 @@ func main() {
 	}
 	rootCmd.AddCommand(serveCmd)
 
+	// Add self test command
+	var selfTestConfig cmdutil.Config
+	selfTestCmd := cmd.NewSelfTestCommand(rootCmd.Use, &selfTestConfig)
+	if err := cmd.RegisterSelfTestFlags(selfTestCmd, &selfTestConfig); err != nil {
+		log.Fatal().Err(err).Msg("failed to register self test flags")
+	}
+	rootCmd.AddCommand(selfTestCmd)
+
+	// Add benchmarking command
+	benchCmd := cmd.NewBenchCommand(rootCmd.Use)
+	cmd.RegisterBenchFlags(benchCmd)
+	rootCmd.AddCommand(benchCmd)
+
+	// Add replay command
+	replayCmd := cmd.NewReplayCommand(rootCmd.Use)
+	cmd.RegisterReplayFlags(replayCmd)
+	rootCmd.AddCommand(replayCmd)
+
+	// Add schema formatting command
+	formatSchemaCmd := cmd.NewFormatSchemaCommand(rootCmd.Use)
+	cmd.RegisterFormatSchemaFlags(formatSchemaCmd)
+	rootCmd.AddCommand(formatSchemaCmd)
+
 	devtoolsCmd := cmd.NewDevtoolsCommand(rootCmd.Use)
 	cmd.RegisterDevtoolsFlags(devtoolsCmd)
 	rootCmd.AddCommand(devtoolsCmd)
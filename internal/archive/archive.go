@@ -0,0 +1,289 @@
+// Package archive records the relationships of a datastore into an archive of files, retained
+// for longer than the garbage collection window of the datastore, and loads the relationships
+// as they were at past times from the archive, such as to check permissions for compliance
+// investigations.
+//
+// The archive is a directory of segments, each of which is a file of JSON lines beginning with
+// a snapshot of the schema and relationships, followed by the changes made to the relationships
+// observed via the Watch stream, with the time at which each was received. A segment is
+// started every snapshot interval, and whenever the Watch stream fails, so that each time is
+// loaded by replaying at most one interval of changes.
+//
+// Changes made to the schema are not observed via the Watch stream, and so are only archived
+// when the next segment is started: the relationships at a time are checked against the schema
+// as it was when the segment covering the time was started.
+package archive
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	log "github.com/authzed/spicedb/internal/logging"
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+// segmentPattern matches the names of the segments of an archive, which embed the time at
+// which their snapshot was taken, and so sort in the order in which they were started.
+// Segments are written under a temporary name until their snapshot is complete.
+const (
+	segmentPrefix  = "segment-"
+	segmentSuffix  = ".jsonl"
+	segmentPattern = segmentPrefix + "*" + segmentSuffix
+	partialSuffix  = ".partial"
+)
+
+const retryInterval = 5 * time.Second
+
+type recordKind string
+
+const (
+	snapshotRecord     recordKind = "snapshot"
+	namespaceRecord    recordKind = "namespace"
+	caveatRecord       recordKind = "caveat"
+	relationshipRecord recordKind = "relationship"
+	changesRecord      recordKind = "changes"
+)
+
+// record is a line of a segment.
+type record struct {
+	Kind recordKind `json:"kind"`
+
+	// Timestamp is the time at which the snapshot was taken, or at which the changes were
+	// received.
+	Timestamp time.Time `json:"timestamp,omitempty"`
+
+	// Revision is the revision of the datastore of the snapshot or changes, for reference.
+	Revision string `json:"revision,omitempty"`
+
+	// Definition is a namespace or caveat definition, as protobuf JSON.
+	Definition json.RawMessage `json:"definition,omitempty"`
+
+	// Relationship is a relationship of the snapshot, as protobuf JSON.
+	Relationship json.RawMessage `json:"relationship,omitempty"`
+
+	// Updates are the changes made to the relationships at the revision, as protobuf JSON.
+	Updates []json.RawMessage `json:"updates,omitempty"`
+}
+
+// Config configures the recording of an archive.
+type Config struct {
+	// Directory is the directory to which the segments of the archive are written.
+	Directory string
+
+	// SnapshotInterval is the interval at which new segments are started.
+	SnapshotInterval time.Duration
+}
+
+// Recorder records the relationships of a datastore into an archive.
+type Recorder struct {
+	ds     datastore.Datastore
+	config Config
+}
+
+// NewRecorder creates a recorder of the datastore writing to the configured directory, which
+// is created if missing. The recorder only records the archive once started.
+func NewRecorder(ds datastore.Datastore, config Config) (*Recorder, error) {
+	if config.Directory == "" {
+		return nil, fmt.Errorf("a directory is required to record an archive")
+	}
+
+	if config.SnapshotInterval <= 0 {
+		return nil, fmt.Errorf("archive snapshot interval must be positive")
+	}
+
+	if err := os.MkdirAll(config.Directory, 0o700); err != nil {
+		return nil, fmt.Errorf("unable to create archive directory: %w", err)
+	}
+
+	return &Recorder{ds: ds, config: config}, nil
+}
+
+// Start records segments until the context is canceled.
+func (r *Recorder) Start(ctx context.Context) error {
+	log.Ctx(ctx).Info().Str("directory", r.config.Directory).Msg("archive recorder started")
+
+	for {
+		err := r.recordSegment(ctx)
+		if ctx.Err() != nil {
+			log.Ctx(ctx).Info().Msg("shutting down archive recorder")
+			return nil
+		}
+		if err == nil {
+			continue
+		}
+
+		log.Ctx(ctx).Warn().Err(err).Msg("error recording archive; starting a new segment")
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// recordSegment snapshots the datastore at its head revision into a new segment, and then
+// appends the changes following it until the snapshot interval elapses, or an error occurs.
+func (r *Recorder) recordSegment(ctx context.Context) error {
+	headRevision, err := r.ds.HeadRevision(ctx)
+	if err != nil {
+		return err
+	}
+
+	started := time.Now()
+	name := filepath.Join(r.config.Directory, fmt.Sprintf("%s%020d%s", segmentPrefix, started.UnixNano(), segmentSuffix))
+	file, err := os.OpenFile(name+partialSuffix, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("unable to create archive segment: %w", err)
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	if err := writeSnapshot(ctx, w, r.ds.SnapshotReader(headRevision), headRevision, started); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	if err := os.Rename(name+partialSuffix, name); err != nil {
+		os.Remove(file.Name())
+		return fmt.Errorf("unable to complete archive segment: %w", err)
+	}
+	log.Ctx(ctx).Info().Str("segment", name).Str("revision", headRevision.String()).Msg("started archive segment")
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	changes, errs := r.ds.Watch(watchCtx, headRevision)
+	timer := time.NewTimer(r.config.SnapshotInterval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case <-timer.C:
+			return nil
+
+		case revisionChanges, ok := <-changes:
+			if !ok {
+				return errors.New("watch stream closed")
+			}
+
+			rec := record{
+				Kind:      changesRecord,
+				Timestamp: time.Now(),
+				Revision:  revisionChanges.Revision.String(),
+				Updates:   make([]json.RawMessage, 0, len(revisionChanges.Changes)),
+			}
+			for _, update := range revisionChanges.Changes {
+				marshaled, err := protojson.Marshal(update)
+				if err != nil {
+					return err
+				}
+				rec.Updates = append(rec.Updates, marshaled)
+			}
+			if err := writeRecord(w, rec); err != nil {
+				return err
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+
+		case err := <-errs:
+			return err
+		}
+	}
+}
+
+// writeSnapshot writes the snapshot record, followed by the definitions and relationships of
+// the reader.
+func writeSnapshot(ctx context.Context, w *bufio.Writer, reader datastore.Reader, revision datastore.Revision, at time.Time) error {
+	if err := writeRecord(w, record{Kind: snapshotRecord, Timestamp: at, Revision: revision.String()}); err != nil {
+		return err
+	}
+
+	namespaces, err := reader.ListAllNamespaces(ctx)
+	if err != nil {
+		return err
+	}
+	for _, ns := range namespaces {
+		if err := writeDefinition(w, namespaceRecord, ns.Definition); err != nil {
+			return err
+		}
+	}
+
+	caveats, err := reader.ListAllCaveats(ctx)
+	if err != nil {
+		return err
+	}
+	for _, caveat := range caveats {
+		if err := writeDefinition(w, caveatRecord, caveat.Definition); err != nil {
+			return err
+		}
+	}
+
+	for _, ns := range namespaces {
+		if err := writeRelationships(ctx, w, reader, ns.Definition.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeDefinition(w *bufio.Writer, kind recordKind, definition proto.Message) error {
+	marshaled, err := protojson.Marshal(definition)
+	if err != nil {
+		return err
+	}
+	return writeRecord(w, record{Kind: kind, Definition: marshaled})
+}
+
+func writeRelationships(ctx context.Context, w *bufio.Writer, reader datastore.Reader, resourceType string) error {
+	it, err := reader.QueryRelationships(ctx, datastore.RelationshipsFilter{ResourceType: resourceType})
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	for {
+		tpl, err := it.Next()
+		if err != nil {
+			return err
+		}
+		if tpl == nil {
+			break
+		}
+
+		marshaled, err := protojson.Marshal(tpl)
+		if err != nil {
+			return err
+		}
+		if err := writeRecord(w, record{Kind: relationshipRecord, Relationship: marshaled}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeRecord(w *bufio.Writer, rec record) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(line); err != nil {
+		return err
+	}
+	return w.WriteByte('\n')
+}
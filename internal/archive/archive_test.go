@@ -0,0 +1,139 @@
+package archive
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/internal/testfixtures"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+func hasRelationship(t *testing.T, state *State, relationship string) bool {
+	tpl := tuple.MustParse(relationship)
+	it, err := state.Datastore.SnapshotReader(state.Revision).QueryRelationships(context.Background(), datastore.RelationshipsFilter{
+		ResourceType:             tpl.ResourceAndRelation.Namespace,
+		OptionalResourceIds:      []string{tpl.ResourceAndRelation.ObjectId},
+		OptionalResourceRelation: tpl.ResourceAndRelation.Relation,
+	})
+	require.NoError(t, err)
+	defer it.Close()
+
+	for {
+		found, err := it.Next()
+		require.NoError(t, err)
+		if found == nil {
+			break
+		}
+
+		if tuple.StringWithoutCaveat(found) == tuple.StringWithoutCaveat(tpl) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRecordAndLoad(t *testing.T) {
+	require := require.New(t)
+
+	rawDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	require.NoError(err)
+	ds, _ := testfixtures.StandardDatastoreWithData(rawDS, require)
+
+	directory := t.TempDir()
+	recorder, err := NewRecorder(ds, Config{Directory: directory, SnapshotInterval: time.Hour})
+	require.NoError(err)
+
+	beforeArchive := time.Now()
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error)
+	go func() { stopped <- recorder.Start(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(<-stopped)
+	})
+
+	require.Eventually(func() bool {
+		segments, err := filepath.Glob(filepath.Join(directory, segmentPattern))
+		return err == nil && len(segments) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	archive := NewArchive(directory)
+	_, err = archive.LoadAt(context.Background(), beforeArchive)
+	require.ErrorIs(err, ErrNotArchived)
+
+	beforeWrite := time.Now()
+	_, err = ds.ReadWriteTx(context.Background(), func(rwt datastore.ReadWriteTransaction) error {
+		return rwt.WriteRelationships(context.Background(), []*core.RelationTupleUpdate{
+			tuple.Create(tuple.MustParse("document:masterplan#viewer@user:auditor")),
+			tuple.Delete(tuple.MustParse("document:masterplan#owner@user:product_manager")),
+		})
+	})
+	require.NoError(err)
+
+	// The changes are applied once they have been received by the recorder.
+	require.Eventually(func() bool {
+		state, err := archive.LoadAt(context.Background(), time.Now())
+		require.NoError(err)
+		return hasRelationship(t, state, "document:masterplan#viewer@user:auditor")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	state, err := archive.LoadAt(context.Background(), time.Now())
+	require.NoError(err)
+	require.False(hasRelationship(t, state, "document:masterplan#owner@user:product_manager"))
+	require.True(state.ArchivedAt.After(beforeWrite))
+
+	before, err := archive.LoadAt(context.Background(), beforeWrite)
+	require.NoError(err)
+	require.False(hasRelationship(t, before, "document:masterplan#viewer@user:auditor"))
+	require.True(hasRelationship(t, before, "document:masterplan#owner@user:product_manager"))
+	require.False(before.ArchivedAt.After(beforeWrite))
+
+	headRevision, err := ds.HeadRevision(context.Background())
+	require.NoError(err)
+	expected, err := ds.SnapshotReader(headRevision).ListAllNamespaces(context.Background())
+	require.NoError(err)
+	namespaces, err := before.Datastore.SnapshotReader(before.Revision).ListAllNamespaces(context.Background())
+	require.NoError(err)
+	require.Len(namespaces, len(expected))
+
+	// Loads of the same state reuse it.
+	again, err := archive.LoadAt(context.Background(), beforeWrite)
+	require.NoError(err)
+	require.Same(before, again)
+}
+
+func TestLoadIgnoresPartialSegments(t *testing.T) {
+	require := require.New(t)
+	directory := t.TempDir()
+
+	partial := filepath.Join(directory, segmentPrefix+"00000000000000000001"+segmentSuffix+partialSuffix)
+	require.NoError(os.WriteFile(partial, []byte(`{"kind":"snapshot"}`+"\n"), 0o600))
+
+	_, err := NewArchive(directory).LoadAt(context.Background(), time.Now())
+	require.ErrorIs(err, ErrNotArchived)
+
+	complete := filepath.Join(directory, segmentPrefix+"00000000000000000002"+segmentSuffix)
+	require.NoError(os.WriteFile(complete, []byte(`{"kind":"relationship"}`+"\n"), 0o600))
+
+	_, err = NewArchive(directory).LoadAt(context.Background(), time.Now())
+	require.ErrorContains(err, "does not begin with a snapshot")
+}
+
+func TestNewRecorderErrors(t *testing.T) {
+	ds, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	require.NoError(t, err)
+
+	_, err = NewRecorder(ds, Config{SnapshotInterval: time.Hour})
+	require.Error(t, err)
+
+	_, err = NewRecorder(ds, Config{Directory: t.TempDir()})
+	require.Error(t, err)
+}
@@ -0,0 +1,245 @@
+package archive
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+const (
+	maximumRecordSize = 64 * 1024 * 1024
+	writeBatchSize    = 1000
+)
+
+// ErrNotArchived is returned when loading a time preceding the first segment of the archive.
+var ErrNotArchived = errors.New("the time precedes the archive")
+
+// State is the schema and relationships loaded from the archive as they were at a time, held
+// in an in-memory datastore. Its revisions are unrelated to those of the archived datastore.
+type State struct {
+	Datastore datastore.Datastore
+	Revision  datastore.Revision
+
+	// ArchivedAt is the time at which the last of the changes applied was received by the
+	// recorder, or at which the snapshot of the segment was taken if none were.
+	ArchivedAt time.Time
+
+	segment string
+	applied int
+}
+
+// Archive loads the relationships as they were at past times from the segments recorded in
+// a directory. The state last loaded is kept, and reused by loads of times at which it is the
+// same.
+type Archive struct {
+	directory string
+
+	lock sync.Mutex
+	last *State
+}
+
+// NewArchive creates an Archive of the segments in the directory.
+func NewArchive(directory string) *Archive {
+	return &Archive{directory: directory}
+}
+
+// LoadAt loads the schema and relationships as they were at the time, from the snapshot of the
+// last segment started at or before it, and the changes received up to it.
+func (a *Archive) LoadAt(ctx context.Context, at time.Time) (*State, error) {
+	segment, err := a.segmentAt(at)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(segment)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open archive segment: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, maximumRecordSize)
+
+	var namespaces []*core.NamespaceDefinition
+	var caveats []*core.CaveatDefinition
+	var archivedAt time.Time
+	relationships := make(map[string]*core.RelationTuple)
+	applied := 0
+
+records:
+	for line := 1; scanner.Scan(); line++ {
+		var rec record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("invalid record on line %d of archive segment %s: %w", line, segment, err)
+		}
+
+		if line == 1 && rec.Kind != snapshotRecord {
+			return nil, fmt.Errorf("archive segment %s does not begin with a snapshot", segment)
+		}
+
+		switch rec.Kind {
+		case snapshotRecord:
+			archivedAt = rec.Timestamp
+
+		case namespaceRecord:
+			ns := &core.NamespaceDefinition{}
+			if err := protojson.Unmarshal(rec.Definition, ns); err != nil {
+				return nil, fmt.Errorf("invalid namespace on line %d of archive segment %s: %w", line, segment, err)
+			}
+			namespaces = append(namespaces, ns)
+
+		case caveatRecord:
+			caveat := &core.CaveatDefinition{}
+			if err := protojson.Unmarshal(rec.Definition, caveat); err != nil {
+				return nil, fmt.Errorf("invalid caveat on line %d of archive segment %s: %w", line, segment, err)
+			}
+			caveats = append(caveats, caveat)
+
+		case relationshipRecord:
+			tpl := &core.RelationTuple{}
+			if err := protojson.Unmarshal(rec.Relationship, tpl); err != nil {
+				return nil, fmt.Errorf("invalid relationship on line %d of archive segment %s: %w", line, segment, err)
+			}
+			relationships[tuple.StringWithoutCaveat(tpl)] = tpl
+
+		case changesRecord:
+			if rec.Timestamp.After(at) {
+				break records
+			}
+
+			for _, marshaled := range rec.Updates {
+				update := &core.RelationTupleUpdate{}
+				if err := protojson.Unmarshal(marshaled, update); err != nil {
+					return nil, fmt.Errorf("invalid change on line %d of archive segment %s: %w", line, segment, err)
+				}
+
+				key := tuple.StringWithoutCaveat(update.Tuple)
+				if update.Operation == core.RelationTupleUpdate_DELETE {
+					delete(relationships, key)
+				} else {
+					relationships[key] = update.Tuple
+				}
+			}
+			archivedAt = rec.Timestamp
+			applied++
+
+		default:
+			return nil, fmt.Errorf("unknown record kind `%s` on line %d of archive segment %s", rec.Kind, line, segment)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read archive segment: %w", err)
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.last != nil && a.last.segment == segment && a.last.applied == applied {
+		return a.last, nil
+	}
+
+	state, err := load(ctx, namespaces, caveats, relationships)
+	if err != nil {
+		return nil, err
+	}
+	state.ArchivedAt = archivedAt
+	state.segment = segment
+	state.applied = applied
+
+	// The datastore of the previous state is not closed, as it may still be in use.
+	a.last = state
+	return state, nil
+}
+
+// segmentAt returns the path of the last segment started at or before the time.
+func (a *Archive) segmentAt(at time.Time) (string, error) {
+	segments, err := filepath.Glob(filepath.Join(a.directory, segmentPattern))
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(segments)
+
+	for i := len(segments) - 1; i >= 0; i-- {
+		started, err := segmentStart(segments[i])
+		if err != nil {
+			return "", err
+		}
+		if !started.After(at) {
+			return segments[i], nil
+		}
+	}
+	return "", ErrNotArchived
+}
+
+func segmentStart(path string) (time.Time, error) {
+	name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), segmentPrefix), segmentSuffix)
+	nanos, err := strconv.ParseInt(name, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid archive segment name `%s`", filepath.Base(path))
+	}
+	return time.Unix(0, nanos), nil
+}
+
+// load writes the definitions and relationships into a new in-memory datastore.
+func load(ctx context.Context, namespaces []*core.NamespaceDefinition, caveats []*core.CaveatDefinition, relationships map[string]*core.RelationTuple) (*State, error) {
+	ds, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	if err != nil {
+		return nil, err
+	}
+
+	revision, err := ds.ReadWriteTx(ctx, func(rwt datastore.ReadWriteTransaction) error {
+		if err := rwt.WriteNamespaces(ctx, namespaces...); err != nil {
+			return err
+		}
+		return rwt.WriteCaveats(ctx, caveats)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to load archived schema: %w", err)
+	}
+
+	updates := make([]*core.RelationTupleUpdate, 0, writeBatchSize)
+	flush := func() error {
+		if len(updates) == 0 {
+			return nil
+		}
+
+		revision, err = ds.ReadWriteTx(ctx, func(rwt datastore.ReadWriteTransaction) error {
+			return rwt.WriteRelationships(ctx, updates)
+		})
+		if err != nil {
+			return fmt.Errorf("unable to load archived relationships: %w", err)
+		}
+		updates = make([]*core.RelationTupleUpdate, 0, writeBatchSize)
+		return nil
+	}
+
+	for _, tpl := range relationships {
+		updates = append(updates, tuple.Create(tpl))
+		if len(updates) == writeBatchSize {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	return &State{Datastore: ds, Revision: revision}, nil
+}
@@ -19,6 +19,13 @@ var errInvalidToken = "invalid token"
 // MustRequirePresharedKey requires that gRPC requests have a Bearer Token value
 // equivalent to one of the provided preshared key(s).
 func MustRequirePresharedKey(presharedKeys []string) grpcauth.AuthFunc {
+	return MustRequireReloadablePresharedKey(presharedKeys, nil)
+}
+
+// MustRequireReloadablePresharedKey requires that gRPC requests have a Bearer Token value
+// equivalent to one of the provided preshared key(s), or to one of the keys currently returned
+// by the reloadable function, if any.
+func MustRequireReloadablePresharedKey(presharedKeys []string, reloadable func() []string) grpcauth.AuthFunc {
 	if len(presharedKeys) == 0 {
 		panic("RequirePresharedKey was given an empty preshared keys slice")
 	}
@@ -39,12 +46,27 @@ func MustRequirePresharedKey(presharedKeys []string) grpcauth.AuthFunc {
 			return nil, status.Errorf(codes.Unauthenticated, errMissingPresharedKey)
 		}
 
-		for _, presharedKey := range presharedKeys {
-			if match := subtle.ConstantTimeCompare([]byte(presharedKey), []byte(token)); match == 1 {
-				return ctx, nil
-			}
+		if matchesAny(presharedKeys, token) {
+			return ctx, nil
+		}
+
+		if reloadable != nil && matchesAny(reloadable(), token) {
+			return ctx, nil
 		}
 
 		return nil, status.Errorf(codes.PermissionDenied, errInvalidPresharedKey, errInvalidToken)
 	}
 }
+
+func matchesAny(presharedKeys []string, token string) bool {
+	for _, presharedKey := range presharedKeys {
+		if len(presharedKey) == 0 {
+			continue
+		}
+
+		if match := subtle.ConstantTimeCompare([]byte(presharedKey), []byte(token)); match == 1 {
+			return true
+		}
+	}
+	return false
+}
@@ -45,6 +45,24 @@ func TestPresharedKeys(t *testing.T) {
 	}
 }
 
+func TestReloadablePresharedKeys(t *testing.T) {
+	reloaded := []string{"two"}
+	f := MustRequireReloadablePresharedKey([]string{"one"}, func() []string { return reloaded })
+
+	_, err := f(withTokenMetadata("bearer one"))
+	require.NoError(t, err)
+
+	_, err = f(withTokenMetadata("bearer two"))
+	require.NoError(t, err)
+
+	reloaded = []string{"three", ""}
+	_, err = f(withTokenMetadata("bearer two"))
+	grpcutil.RequireStatus(t, codes.PermissionDenied, err)
+
+	_, err = f(withTokenMetadata("bearer three"))
+	require.NoError(t, err)
+}
+
 func withTokenMetadata(authzHeader string) context.Context {
 	md := metadata.Pairs("authorization", authzHeader)
 	return metautils.MD(md).ToIncoming(context.Background())
@@ -0,0 +1,115 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	for _, workload := range Workloads {
+		t.Run(string(workload), func(t *testing.T) {
+			report, err := Run(context.Background(), Config{
+				Workload:                 workload,
+				Params:                   Params{Depth: 4, Width: 5, Seed: 42},
+				Iterations:               20,
+				Concurrency:              4,
+				DispatchConcurrencyLimit: 10,
+			})
+			require.NoError(t, err)
+			require.Len(t, report.Operations, 2)
+			for _, op := range report.Operations {
+				require.Equal(t, 20, op.Count)
+				require.LessOrEqual(t, op.P50, op.P99)
+				require.LessOrEqual(t, op.P99, op.Max)
+			}
+		})
+	}
+}
+
+func TestRegressions(t *testing.T) {
+	baseline := &Report{
+		Workload: Arrows,
+		Params:   Params{Depth: 2, Width: 2},
+		Operations: []OperationReport{
+			{Operation: OperationCheck, P50: time.Millisecond, P90: 2 * time.Millisecond, P99: 4 * time.Millisecond},
+		},
+	}
+
+	current := &Report{
+		Workload: Arrows,
+		Params:   Params{Depth: 2, Width: 2},
+		Operations: []OperationReport{
+			{Operation: OperationCheck, P50: time.Millisecond, P90: 2100 * time.Microsecond, P99: 6 * time.Millisecond},
+			{Operation: OperationLookup, P50: time.Second},
+		},
+	}
+
+	regressions, err := current.Regressions(baseline, 0.1)
+	require.NoError(t, err)
+	require.Equal(t, []string{"check p99 regressed from 4ms to 6ms"}, regressions)
+
+	_, err = current.Regressions(&Report{Workload: DeepNesting}, 0.1)
+	require.Error(t, err)
+}
+
+func BenchmarkWorkloads(b *testing.B) {
+	for _, workload := range Workloads {
+		b.Run(string(workload), func(b *testing.B) {
+			dataset, err := Generate(workload, Params{Depth: 8, Width: 20})
+			require.NoError(b, err)
+
+			env, err := NewEnvironment(context.Background(), dataset, 50)
+			require.NoError(b, err)
+			b.Cleanup(func() { env.Close() })
+
+			ctx, err := env.Context(context.Background())
+			require.NoError(b, err)
+
+			b.Run("check", func(b *testing.B) {
+				for n := 0; n < b.N; n++ {
+					_, err := env.Check(ctx, dataset.ResourceIDs[n%len(dataset.ResourceIDs)], dataset.Members[n%len(dataset.Members)])
+					require.NoError(b, err)
+				}
+			})
+
+			b.Run("lookup", func(b *testing.B) {
+				for n := 0; n < b.N; n++ {
+					_, err := env.Lookup(ctx, dataset.Members[n%len(dataset.Members)])
+					require.NoError(b, err)
+				}
+			})
+		})
+	}
+}
+
+// FuzzWorkloads verifies that checks and lookups agree with the expected results of randomly
+// shaped workloads.
+func FuzzWorkloads(f *testing.F) {
+	f.Add(uint8(0), uint8(1), uint8(1), int64(0))
+	f.Add(uint8(1), uint8(5), uint8(3), int64(1))
+	f.Add(uint8(2), uint8(3), uint8(4), int64(2))
+
+	f.Fuzz(func(t *testing.T, workloadIndex, depth, width uint8, seed int64) {
+		params := Params{
+			Depth: int(depth)%10 + 1,
+			Width: int(width)%8 + 1,
+			Seed:  seed,
+		}
+		workload := Workloads[int(workloadIndex)%len(Workloads)]
+
+		dataset, err := Generate(workload, params)
+		require.NoError(t, err)
+
+		env, err := NewEnvironment(context.Background(), dataset, 10)
+		require.NoError(t, err)
+		defer env.Close()
+
+		ctx, err := env.Context(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, env.Verify(ctx), fmt.Sprintf("%s with %+v", workload, params))
+	})
+}
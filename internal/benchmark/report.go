@@ -0,0 +1,79 @@
+package benchmark
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// Report is the result of a benchmark run.
+type Report struct {
+	Workload      Workload          `json:"workload"`
+	Params        Params            `json:"params"`
+	Relationships int               `json:"relationships"`
+	Operations    []OperationReport `json:"operations"`
+}
+
+// OperationReport holds the latencies measured for an operation.
+type OperationReport struct {
+	Operation Operation     `json:"operation"`
+	Count     int           `json:"count"`
+	Mean      time.Duration `json:"mean"`
+	P50       time.Duration `json:"p50"`
+	P90       time.Duration `json:"p90"`
+	P99       time.Duration `json:"p99"`
+	Max       time.Duration `json:"max"`
+}
+
+// WriteTable writes the report as a human readable table.
+func (r *Report) WriteTable(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "workload %s (depth %d, width %d, seed %d): %d relationships\n\n",
+		r.Workload, r.Params.Depth, r.Params.Width, r.Params.Seed, r.Relationships); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "OPERATION\tCOUNT\tMEAN\tP50\tP90\tP99\tMAX")
+	for _, op := range r.Operations {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\t%s\n", op.Operation, op.Count, op.Mean, op.P50, op.P90, op.P99, op.Max)
+	}
+	return tw.Flush()
+}
+
+// Regressions compares the report against a baseline report of the same workload, returning a
+// description of each percentile that is slower than the baseline by more than the tolerance,
+// expressed as a fraction of the baseline.
+func (r *Report) Regressions(baseline *Report, tolerance float64) ([]string, error) {
+	if r.Workload != baseline.Workload || r.Params != baseline.Params {
+		return nil, fmt.Errorf("baseline is of workload %s with %+v, not %s with %+v", baseline.Workload, baseline.Params, r.Workload, r.Params)
+	}
+
+	baselineOps := make(map[Operation]OperationReport, len(baseline.Operations))
+	for _, op := range baseline.Operations {
+		baselineOps[op.Operation] = op
+	}
+
+	var regressions []string
+	for _, op := range r.Operations {
+		base, ok := baselineOps[op.Operation]
+		if !ok {
+			continue
+		}
+
+		for _, percentile := range []struct {
+			name     string
+			current  time.Duration
+			baseline time.Duration
+		}{
+			{"p50", op.P50, base.P50},
+			{"p90", op.P90, base.P90},
+			{"p99", op.P99, base.P99},
+		} {
+			if float64(percentile.current) > float64(percentile.baseline)*(1+tolerance) {
+				regressions = append(regressions, fmt.Sprintf("%s %s regressed from %s to %s", op.Operation, percentile.name, percentile.baseline, percentile.current))
+			}
+		}
+	}
+	return regressions, nil
+}
@@ -0,0 +1,344 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/internal/dispatch"
+	"github.com/authzed/spicedb/internal/dispatch/graph"
+	"github.com/authzed/spicedb/internal/graph/computed"
+	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
+	"github.com/authzed/spicedb/internal/services/shared"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
+	"github.com/authzed/spicedb/pkg/schemadsl/input"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+const (
+	maximumDepth = 50
+
+	// writeBatchSize is the number of relationships written per transaction when loading.
+	writeBatchSize = 1000
+)
+
+// Operation is a kind of request being benchmarked.
+type Operation string
+
+const (
+	OperationCheck  Operation = "check"
+	OperationLookup Operation = "lookup"
+)
+
+// Config is the configuration of a benchmark run.
+type Config struct {
+	Workload Workload
+	Params   Params
+
+	// Iterations is the number of requests made for each operation.
+	Iterations int
+
+	// Concurrency is the number of requests made in parallel.
+	Concurrency int
+
+	// DispatchConcurrencyLimit is the concurrency limit of the dispatcher.
+	DispatchConcurrencyLimit uint16
+}
+
+// Environment is a dataset loaded into a datastore, against which requests are dispatched.
+type Environment struct {
+	Dataset    *Dataset
+	Datastore  datastore.Datastore
+	Revision   datastore.Revision
+	Dispatcher dispatch.Dispatcher
+}
+
+// NewEnvironment loads the dataset into an in-memory datastore.
+func NewEnvironment(ctx context.Context, dataset *Dataset, dispatchConcurrencyLimit uint16) (*Environment, error) {
+	ds, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	if err != nil {
+		return nil, err
+	}
+
+	emptyDefaultPrefix := ""
+	compiled, err := compiler.Compile(compiler.InputSchema{
+		Source:       input.Source("benchmark"),
+		SchemaString: dataset.Schema,
+	}, &emptyDefaultPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("invalid generated schema: %w", err)
+	}
+
+	validated, err := shared.ValidateSchemaChanges(ctx, compiled, false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid generated schema: %w", err)
+	}
+
+	revision, err := ds.ReadWriteTx(ctx, func(rwt datastore.ReadWriteTransaction) error {
+		_, err := shared.ApplySchemaChanges(ctx, rwt, validated)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for start := 0; start < len(dataset.Relationships); start += writeBatchSize {
+		end := start + writeBatchSize
+		if end > len(dataset.Relationships) {
+			end = len(dataset.Relationships)
+		}
+
+		mutations := make([]*core.RelationTupleUpdate, 0, end-start)
+		for _, rel := range dataset.Relationships[start:end] {
+			mutations = append(mutations, tuple.Touch(rel))
+		}
+
+		revision, err = ds.ReadWriteTx(ctx, func(rwt datastore.ReadWriteTransaction) error {
+			return rwt.WriteRelationships(ctx, mutations)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Environment{
+		Dataset:    dataset,
+		Datastore:  ds,
+		Revision:   revision,
+		Dispatcher: graph.NewLocalOnlyDispatcher(dispatchConcurrencyLimit),
+	}, nil
+}
+
+// Context returns a context carrying the datastore of the environment, as required to dispatch.
+func (e *Environment) Context(ctx context.Context) (context.Context, error) {
+	ctx = datastoremw.ContextWithHandle(ctx)
+	if err := datastoremw.SetInContext(ctx, e.Datastore); err != nil {
+		return nil, err
+	}
+	return ctx, nil
+}
+
+// Close closes the datastore and dispatcher of the environment.
+func (e *Environment) Close() error {
+	if err := e.Dispatcher.Close(); err != nil {
+		return err
+	}
+	return e.Datastore.Close()
+}
+
+// Check checks whether the subject has the permission of the dataset on the resource.
+func (e *Environment) Check(ctx context.Context, resourceID string, subject *core.ObjectAndRelation) (bool, error) {
+	result, _, err := computed.ComputeCheck(ctx, e.Dispatcher, computed.CheckParameters{
+		ResourceType: e.Dataset.Permission,
+		Subject:      subject,
+		AtRevision:   e.Revision,
+		MaximumDepth: maximumDepth,
+	}, resourceID)
+	if err != nil {
+		return false, err
+	}
+	return result.Membership == v1.ResourceCheckResult_MEMBER, nil
+}
+
+// Lookup returns the IDs of the resources on which the subject has the permission of the dataset.
+func (e *Environment) Lookup(ctx context.Context, subject *core.ObjectAndRelation) ([]string, error) {
+	resp, err := e.Dispatcher.DispatchLookup(ctx, &v1.DispatchLookupRequest{
+		Metadata: &v1.ResolverMeta{
+			AtRevision:     e.Revision.String(),
+			DepthRemaining: maximumDepth,
+		},
+		ObjectRelation: e.Dataset.Permission,
+		Subject:        subject,
+		Limit:          ^uint32(0),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(resp.ResolvedResources))
+	for _, resource := range resp.ResolvedResources {
+		ids = append(ids, resource.ResourceId)
+	}
+	return ids, nil
+}
+
+// Verify checks that every check and lookup of the dataset returns the expected results.
+func (e *Environment) Verify(ctx context.Context) error {
+	expected := map[bool][]*core.ObjectAndRelation{
+		true:  e.Dataset.Members,
+		false: e.Dataset.NonMembers,
+	}
+
+	for isMember, subjects := range expected {
+		for _, subject := range subjects {
+			for _, resourceID := range e.Dataset.ResourceIDs {
+				found, err := e.Check(ctx, resourceID, subject)
+				if err != nil {
+					return err
+				}
+				if found != isMember {
+					return fmt.Errorf("expected check of %s for %s to be %v, found %v", resourceID, tuple.StringONR(subject), isMember, found)
+				}
+			}
+
+			ids, err := e.Lookup(ctx, subject)
+			if err != nil {
+				return err
+			}
+
+			expectedCount := 0
+			if isMember {
+				expectedCount = len(e.Dataset.ResourceIDs)
+			}
+			if len(ids) != expectedCount {
+				return fmt.Errorf("expected lookup for %s to find %d resources, found %d", tuple.StringONR(subject), expectedCount, len(ids))
+			}
+		}
+	}
+	return nil
+}
+
+// Run generates the configured workload, verifies the results of its requests, and then
+// measures the latency of checks and lookups against it.
+func Run(ctx context.Context, config Config) (*Report, error) {
+	if config.Iterations < 1 || config.Concurrency < 1 {
+		return nil, fmt.Errorf("iterations and concurrency must be at least 1")
+	}
+
+	dataset, err := Generate(config.Workload, config.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	env, err := NewEnvironment(ctx, dataset, config.DispatchConcurrencyLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer env.Close()
+
+	ctx, err = env.Context(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := env.Verify(ctx); err != nil {
+		return nil, fmt.Errorf("workload returned incorrect results: %w", err)
+	}
+
+	subjects := append(append([]*core.ObjectAndRelation{}, dataset.Members...), dataset.NonMembers...)
+	rnd := rand.New(rand.NewSource(config.Params.Seed))
+
+	checks := make([]func() error, 0, config.Iterations)
+	lookups := make([]func() error, 0, config.Iterations)
+	for i := 0; i < config.Iterations; i++ {
+		subject := subjects[rnd.Intn(len(subjects))]
+		resourceID := dataset.ResourceIDs[rnd.Intn(len(dataset.ResourceIDs))]
+		checks = append(checks, func() error {
+			_, err := env.Check(ctx, resourceID, subject)
+			return err
+		})
+
+		lookupSubject := subjects[rnd.Intn(len(subjects))]
+		lookups = append(lookups, func() error {
+			_, err := env.Lookup(ctx, lookupSubject)
+			return err
+		})
+	}
+
+	report := &Report{
+		Workload:      config.Workload,
+		Params:        config.Params,
+		Relationships: len(dataset.Relationships),
+	}
+	for _, op := range []struct {
+		operation Operation
+		requests  []func() error
+	}{
+		{OperationCheck, checks},
+		{OperationLookup, lookups},
+	} {
+		latencies, err := measure(op.requests, config.Concurrency)
+		if err != nil {
+			return nil, fmt.Errorf("error running %s: %w", op.operation, err)
+		}
+		report.Operations = append(report.Operations, summarize(op.operation, latencies))
+	}
+	return report, nil
+}
+
+// measure runs the requests with the given concurrency, returning their latencies.
+func measure(requests []func() error, concurrency int) ([]time.Duration, error) {
+	latencies := make([]time.Duration, len(requests))
+	errs := make(chan error, concurrency)
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				start := time.Now()
+				if err := requests[index](); err != nil {
+					errs <- err
+					return
+				}
+				latencies[index] = time.Since(start)
+			}
+		}()
+	}
+
+	var err error
+loop:
+	for index := range requests {
+		select {
+		case err = <-errs:
+			break loop
+		case indexes <- index:
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case err := <-errs:
+		return nil, err
+	default:
+		return latencies, nil
+	}
+}
+
+// summarize computes the latency percentiles of an operation.
+func summarize(operation Operation, latencies []time.Duration) OperationReport {
+	sorted := append([]time.Duration{}, latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+	for _, latency := range sorted {
+		total += latency
+	}
+
+	percentile := func(p float64) time.Duration {
+		return sorted[int(p*float64(len(sorted)-1))]
+	}
+
+	return OperationReport{
+		Operation: operation,
+		Count:     len(sorted),
+		Mean:      total / time.Duration(len(sorted)),
+		P50:       percentile(0.5),
+		P90:       percentile(0.9),
+		P99:       percentile(0.99),
+		Max:       sorted[len(sorted)-1],
+	}
+}
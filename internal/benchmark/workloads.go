@@ -0,0 +1,236 @@
+// Package benchmark implements synthetic workloads for measuring the latency of checks and
+// lookups, used to detect performance regressions in the graph.
+package benchmark
+
+import (
+	"fmt"
+	"math/rand"
+
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// Workload is the shape of the synthetic schema and relationships being benchmarked.
+type Workload string
+
+const (
+	// DeepNesting nests groups within each other, with the members at the bottom of the chain.
+	DeepNesting Workload = "deep-nesting"
+
+	// WideFanout grants access to many groups, each with many members.
+	WideFanout Workload = "wide-fanout"
+
+	// Arrows walks a tree of folders via arrows to reach documents in the leaves.
+	Arrows Workload = "arrows"
+)
+
+// Workloads are all the supported workloads.
+var Workloads = []Workload{DeepNesting, WideFanout, Arrows}
+
+// Params are the parameters from which a dataset is generated.
+type Params struct {
+	// Depth is the nesting depth of groups or folders.
+	Depth int
+
+	// Width is the fan-out of groups or folders, and the number of resources.
+	Width int
+
+	// Seed seeds the random placement of members.
+	Seed int64
+}
+
+// Dataset is a generated schema and relationships, along with the subjects which are known to
+// have the permission on every resource, and those known to have it on none.
+type Dataset struct {
+	Schema        string
+	Relationships []*core.RelationTuple
+
+	Permission  *core.RelationReference
+	ResourceIDs []string
+
+	Members    []*core.ObjectAndRelation
+	NonMembers []*core.ObjectAndRelation
+}
+
+// Generate generates the dataset of the given workload.
+func Generate(workload Workload, params Params) (*Dataset, error) {
+	if params.Depth < 1 || params.Width < 1 {
+		return nil, fmt.Errorf("depth and width must be at least 1")
+	}
+
+	rnd := rand.New(rand.NewSource(params.Seed))
+	switch workload {
+	case DeepNesting:
+		return generateDeepNesting(params, rnd), nil
+	case WideFanout:
+		return generateWideFanout(params, rnd), nil
+	case Arrows:
+		return generateArrows(params, rnd), nil
+	default:
+		return nil, fmt.Errorf("unknown workload `%s`", workload)
+	}
+}
+
+const groupSchema = `
+definition user {}
+
+definition group {
+	relation member: user | group#member
+}
+
+definition resource {
+	relation viewer: user | group#member
+	permission view = viewer
+}
+`
+
+func user(id string) *core.ObjectAndRelation {
+	return tuple.ParseSubjectONR("user:" + id)
+}
+
+func rel(format string, args ...any) *core.RelationTuple {
+	return tuple.MustParse(fmt.Sprintf(format, args...))
+}
+
+// nonMembers returns users who have no access to any resource.
+func nonMembers(count int) []*core.ObjectAndRelation {
+	subjects := make([]*core.ObjectAndRelation, 0, count)
+	for i := 0; i < count; i++ {
+		subjects = append(subjects, user(fmt.Sprintf("outsider%d", i)))
+	}
+	return subjects
+}
+
+func resourceIDs(width int) []string {
+	ids := make([]string, 0, width)
+	for i := 0; i < width; i++ {
+		ids = append(ids, fmt.Sprintf("resource%d", i))
+	}
+	return ids
+}
+
+// generateDeepNesting builds a chain of Depth groups, each a member of the previous one, with
+// every resource granting access to the first. Members are placed at random depths.
+func generateDeepNesting(params Params, rnd *rand.Rand) *Dataset {
+	ds := &Dataset{
+		Schema:      groupSchema,
+		Permission:  &core.RelationReference{Namespace: "resource", Relation: "view"},
+		ResourceIDs: resourceIDs(params.Width),
+		NonMembers:  nonMembers(params.Width),
+	}
+
+	for i := 0; i+1 < params.Depth; i++ {
+		ds.Relationships = append(ds.Relationships, rel("group:g%d#member@group:g%d#member", i, i+1))
+	}
+
+	// The deepest member is always at the bottom of the chain.
+	ds.Relationships = append(ds.Relationships, rel("group:g%d#member@user:member0", params.Depth-1))
+	ds.Members = append(ds.Members, user("member0"))
+	for i := 1; i < params.Width; i++ {
+		ds.Relationships = append(ds.Relationships, rel("group:g%d#member@user:member%d", rnd.Intn(params.Depth), i))
+		ds.Members = append(ds.Members, user(fmt.Sprintf("member%d", i)))
+	}
+
+	for _, id := range ds.ResourceIDs {
+		ds.Relationships = append(ds.Relationships, rel("resource:%s#viewer@group:g0#member", id))
+	}
+
+	// Outsiders are members of a group which grants no access.
+	for _, outsider := range ds.NonMembers {
+		ds.Relationships = append(ds.Relationships, rel("group:unused#member@%s", tuple.StringONR(outsider)))
+	}
+	return ds
+}
+
+// generateWideFanout grants access to every resource to Width groups of Width members each.
+// Each group has Depth levels of subgroups, so wide fan-out is combined with some nesting.
+func generateWideFanout(params Params, rnd *rand.Rand) *Dataset {
+	ds := &Dataset{
+		Schema:      groupSchema,
+		Permission:  &core.RelationReference{Namespace: "resource", Relation: "view"},
+		ResourceIDs: resourceIDs(params.Width),
+		NonMembers:  nonMembers(params.Width),
+	}
+
+	for g := 0; g < params.Width; g++ {
+		for level := 0; level+1 < params.Depth; level++ {
+			ds.Relationships = append(ds.Relationships, rel("group:g%d_%d#member@group:g%d_%d#member", g, level, g, level+1))
+		}
+		for m := 0; m < params.Width; m++ {
+			ds.Relationships = append(ds.Relationships, rel("group:g%d_%d#member@user:member%d_%d", g, rnd.Intn(params.Depth), g, m))
+		}
+	}
+
+	for _, id := range ds.ResourceIDs {
+		for g := 0; g < params.Width; g++ {
+			ds.Relationships = append(ds.Relationships, rel("resource:%s#viewer@group:g%d_0#member", id, g))
+		}
+	}
+
+	for i := 0; i < params.Width; i++ {
+		ds.Members = append(ds.Members, user(fmt.Sprintf("member%d_%d", rnd.Intn(params.Width), rnd.Intn(params.Width))))
+	}
+
+	// Outsiders are members of a group which grants no access.
+	for _, outsider := range ds.NonMembers {
+		ds.Relationships = append(ds.Relationships, rel("group:unused#member@%s", tuple.StringONR(outsider)))
+	}
+	return ds
+}
+
+const folderSchema = `
+definition user {}
+
+definition folder {
+	relation parent: folder
+	relation viewer: user
+	permission view = viewer + parent->view
+}
+
+definition resource {
+	relation parent: folder
+	relation viewer: user
+	permission view = viewer + parent->view
+}
+`
+
+// generateArrows builds a tree of folders Depth levels deep, with Width folders on each level
+// below the root attached to random parents on the level above, and Width resources in each
+// folder of the deepest level. Members are viewers of the root folder.
+func generateArrows(params Params, rnd *rand.Rand) *Dataset {
+	ds := &Dataset{
+		Schema:     folderSchema,
+		Permission: &core.RelationReference{Namespace: "resource", Relation: "view"},
+		NonMembers: nonMembers(params.Width),
+	}
+
+	level := []string{"root"}
+	for depth := 1; depth < params.Depth; depth++ {
+		var next []string
+		for i := 0; i < params.Width; i++ {
+			child := fmt.Sprintf("f%d_%d", depth, i)
+			ds.Relationships = append(ds.Relationships, rel("folder:%s#parent@folder:%s", child, level[rnd.Intn(len(level))]))
+			next = append(next, child)
+		}
+		level = next
+	}
+
+	for i, folder := range level {
+		for j := 0; j < params.Width; j++ {
+			id := fmt.Sprintf("resource%d_%d", i, j)
+			ds.ResourceIDs = append(ds.ResourceIDs, id)
+			ds.Relationships = append(ds.Relationships, rel("resource:%s#parent@folder:%s", id, folder))
+		}
+	}
+
+	for i := 0; i < params.Width; i++ {
+		ds.Relationships = append(ds.Relationships, rel("folder:root#viewer@user:member%d", i))
+		ds.Members = append(ds.Members, user(fmt.Sprintf("member%d", i)))
+	}
+
+	// Outsiders are viewers of a folder outside of the tree.
+	for _, outsider := range ds.NonMembers {
+		ds.Relationships = append(ds.Relationships, rel("folder:unused#viewer@%s", tuple.StringONR(outsider)))
+	}
+	return ds
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 
 	"golang.org/x/exp/maps"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/authzed/spicedb/pkg/caveats/types"
 	core "github.com/authzed/spicedb/pkg/proto/core/v1"
@@ -29,6 +30,13 @@ const (
 	// ParameterTypeChanged indicates that the type of the parameter was changed.
 	ParameterTypeChanged DeltaType = "parameter-type-changed"
 
+	// ParameterDefaultChanged indicates that the default value of the parameter was added, removed
+	// or changed.
+	ParameterDefaultChanged DeltaType = "parameter-default-changed"
+
+	// ContextPrecedenceChanged indicates that the context precedence of the caveat was changed.
+	ContextPrecedenceChanged DeltaType = "context-precedence-changed"
+
 	// CaveatExpressionMayHaveChanged indicates that the expression of the caveat *may* have changed.
 	// This uses a direct byte comparison which can return that a change occurred, even when it has
 	// not.
@@ -135,6 +143,20 @@ func DiffCaveats(existing *core.CaveatDefinition, updated *core.CaveatDefinition
 				CurrentType:   updatedParamType,
 			})
 		}
+
+		// Compare defaults.
+		if !proto.Equal(existing.ParameterDefaults[shared], updated.ParameterDefaults[shared]) {
+			deltas = append(deltas, Delta{
+				Type:          ParameterDefaultChanged,
+				ParameterName: shared,
+			})
+		}
+	}
+
+	if existing.ContextPrecedence != updated.ContextPrecedence {
+		deltas = append(deltas, Delta{
+			Type: ContextPrecedenceChanged,
+		})
 	}
 
 	if !bytes.Equal(existing.SerializedExpression, updated.SerializedExpression) {
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
 
 	"github.com/authzed/spicedb/pkg/caveats"
 	"github.com/authzed/spicedb/pkg/caveats/types"
@@ -128,6 +129,46 @@ func TestCaveatDiff(t *testing.T) {
 				},
 			},
 		},
+		{
+			"changed parameter default",
+			ns.MustCaveatDefinition(
+				caveats.MustEnvForVariables(map[string]types.VariableType{
+					"someparam": types.IntType,
+				}),
+				"somecaveat",
+				"true",
+			),
+			withDefault(ns.MustCaveatDefinition(
+				caveats.MustEnvForVariables(map[string]types.VariableType{
+					"someparam": types.IntType,
+				}),
+				"somecaveat",
+				"true",
+			), "someparam", structpb.NewNumberValue(42)),
+			[]Delta{
+				{Type: ParameterDefaultChanged, ParameterName: "someparam"},
+			},
+		},
+		{
+			"changed context precedence",
+			ns.MustCaveatDefinition(
+				caveats.MustEnvForVariables(map[string]types.VariableType{
+					"someparam": types.IntType,
+				}),
+				"somecaveat",
+				"true",
+			),
+			withContextPrecedence(ns.MustCaveatDefinition(
+				caveats.MustEnvForVariables(map[string]types.VariableType{
+					"someparam": types.IntType,
+				}),
+				"somecaveat",
+				"true",
+			), core.CaveatDefinition_ERROR_ON_CONFLICT),
+			[]Delta{
+				{Type: ContextPrecedenceChanged},
+			},
+		},
 		{
 			"rename parameter",
 			ns.MustCaveatDefinition(
@@ -200,3 +241,13 @@ func TestCaveatDiff(t *testing.T) {
 		})
 	}
 }
+
+func withDefault(def *core.CaveatDefinition, paramName string, value *structpb.Value) *core.CaveatDefinition {
+	def.ParameterDefaults = map[string]*structpb.Value{paramName: value}
+	return def
+}
+
+func withContextPrecedence(def *core.CaveatDefinition, precedence core.CaveatDefinition_ContextPrecedence) *core.CaveatDefinition {
+	def.ContextPrecedence = precedence
+	return def
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/authzed/spicedb/pkg/caveats"
 	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/redaction"
 	"github.com/authzed/spicedb/pkg/spiceerrors"
 )
 
@@ -24,7 +25,7 @@ type EvaluationErr struct {
 
 // MarshalZerologObject implements zerolog.LogObjectMarshaler
 func (err EvaluationErr) MarshalZerologObject(e *zerolog.Event) {
-	e.Err(err.error).Str("caveat_name", err.caveatExpr.GetCaveat().CaveatName).Interface("context", err.caveatExpr.GetCaveat().Context)
+	e.Err(err.error).Str("caveat_name", err.caveatExpr.GetCaveat().CaveatName).Interface("context", redaction.CaveatContext(err.caveatExpr.GetCaveat().Context))
 }
 
 // DetailsMetadata returns the metadata for details for this error.
@@ -62,7 +63,7 @@ type ParameterTypeError struct {
 func (err ParameterTypeError) MarshalZerologObject(e *zerolog.Event) {
 	evt := e.Err(err.error).
 		Str("caveat_name", err.caveatExpr.GetCaveat().CaveatName).
-		Interface("context", err.caveatExpr.GetCaveat().Context)
+		Interface("context", redaction.CaveatContext(err.caveatExpr.GetCaveat().Context))
 
 	if err.conversionError != nil {
 		evt.Str("parameter_name", err.conversionError.ParameterName())
@@ -105,3 +106,40 @@ func NewParameterTypeError(caveatExpr *core.CaveatExpression, err error) Paramet
 		conversionError,
 	}
 }
+
+// ContextConflictError is an error raised when the context written on a relationship conflicts
+// with that specified in the request, for a caveat requiring that they not conflict.
+type ContextConflictError struct {
+	error
+	caveatExpr *core.CaveatExpression
+}
+
+// MarshalZerologObject implements zerolog.LogObjectMarshaler
+func (err ContextConflictError) MarshalZerologObject(e *zerolog.Event) {
+	e.Err(err.error).Str("caveat_name", err.caveatExpr.GetCaveat().CaveatName).Interface("context", redaction.CaveatContext(err.caveatExpr.GetCaveat().Context))
+}
+
+// DetailsMetadata returns the metadata for details for this error.
+func (err ContextConflictError) DetailsMetadata() map[string]string {
+	return map[string]string{
+		"caveat_name": err.caveatExpr.GetCaveat().CaveatName,
+	}
+}
+
+func (err ContextConflictError) GRPCStatus() *status.Status {
+	return spiceerrors.WithCodeAndDetails(
+		err,
+		codes.InvalidArgument,
+		spiceerrors.ForReason(
+			v1.ErrorReason_ERROR_REASON_CAVEAT_EVALUATION_ERROR,
+			err.DetailsMetadata(),
+		),
+	)
+}
+
+func NewContextConflictError(caveatExpr *core.CaveatExpression, err error) ContextConflictError {
+	return ContextConflictError{
+		fmt.Errorf("context conflict for caveat `%s`: %w", caveatExpr.GetCaveat().CaveatName, err),
+		caveatExpr,
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"reflect"
 	"strings"
 
 	"golang.org/x/exp/maps"
@@ -164,14 +165,15 @@ func runExpressionWithCaveats(
 			return nil, err
 		}
 
-		// Create a combined context, with the written context taking precedence over that specified.
-		untypedFullContext := maps.Clone(context)
-		if untypedFullContext == nil {
-			untypedFullContext = map[string]any{}
-		}
-
+		// Create a combined context, with the written context and that specified merged as per the
+		// caveat's precedence, and the defaults of the caveat's parameters used for those found in
+		// neither.
 		relationshipContext := expr.GetCaveat().GetContext().AsMap()
-		maps.Copy(untypedFullContext, relationshipContext)
+		untypedFullContext, err := mergeContexts(caveat, context, relationshipContext)
+		if err != nil {
+			return nil, NewContextConflictError(expr, err)
+		}
+		untypedFullContext = caveats.WithParameterDefaults(untypedFullContext, caveat.ParameterDefaults)
 
 		// Perform type checking and conversion on the context map.
 		typedParameters, err := caveats.ConvertContextToParameters(
@@ -221,6 +223,9 @@ func runExpressionWithCaveats(
 		}
 	}
 
+	// A partially applied child only decides the result if no other child does, as a true child
+	// of an OR or a false child of an AND does regardless of the others.
+	var partialResult ExpressionResult
 	for _, child := range cop.Children {
 		childResult, err := runExpressionWithCaveats(ctx, env, child, context, loadedCaveats, debugOption)
 		if err != nil {
@@ -228,7 +233,10 @@ func runExpressionWithCaveats(
 		}
 
 		if childResult.IsPartial() {
-			return childResult, nil
+			if partialResult == nil {
+				partialResult = childResult
+			}
+			continue
 		}
 
 		switch cop.Op {
@@ -299,6 +307,10 @@ func runExpressionWithCaveats(
 		}
 	}
 
+	if partialResult != nil {
+		return partialResult, nil
+	}
+
 	built, err := buildExprString()
 	if err != nil {
 		return nil, err
@@ -307,6 +319,64 @@ func runExpressionWithCaveats(
 	return syntheticResult{boolResult, contextValues, built}, nil
 }
 
+// ExpressionDependencies returns the names of the parameters defined by the caveats referenced
+// in the expression, and whether any of the caveats depends upon the time at which it is run. The
+// result of running the expression depends on nothing else.
+func ExpressionDependencies(ctx context.Context, expr *core.CaveatExpression, reader datastore.CaveatReader) (parameterNames []string, timeDependent bool, err error) {
+	caveatNames := util.NewSet[string]()
+	collectCaveatNames(expr, caveatNames)
+
+	caveatDefs, err := reader.LookupCaveatsWithNames(ctx, caveatNames.AsSlice())
+	if err != nil {
+		return nil, false, err
+	}
+
+	names := util.NewSet[string]()
+	for _, cd := range caveatDefs {
+		for name := range cd.Definition.ParameterTypes {
+			names.Add(name)
+		}
+
+		if !timeDependent {
+			timeDependent, err = caveats.IsTimeDependent(cd.Definition.SerializedExpression)
+			if err != nil {
+				return nil, false, err
+			}
+		}
+	}
+	return names.AsSlice(), timeDependent, nil
+}
+
+// mergeContexts combines the context specified in the request with that written on the
+// relationship, as per the context precedence of the caveat.
+func mergeContexts(caveat *core.CaveatDefinition, requestContext map[string]any, relationshipContext map[string]any) (map[string]any, error) {
+	merged := make(map[string]any, len(requestContext)+len(relationshipContext))
+
+	switch caveat.ContextPrecedence {
+	case core.CaveatDefinition_RELATIONSHIP_WINS:
+		maps.Copy(merged, requestContext)
+		maps.Copy(merged, relationshipContext)
+
+	case core.CaveatDefinition_REQUEST_WINS:
+		maps.Copy(merged, relationshipContext)
+		maps.Copy(merged, requestContext)
+
+	case core.CaveatDefinition_ERROR_ON_CONFLICT:
+		maps.Copy(merged, relationshipContext)
+		for key, value := range requestContext {
+			if existing, ok := merged[key]; ok && !reflect.DeepEqual(existing, value) {
+				return nil, fmt.Errorf("parameter `%s` was given a value in the request context conflicting with that on the relationship", key)
+			}
+			merged[key] = value
+		}
+
+	default:
+		return nil, spiceerrors.MustBugf("unknown context precedence: %v", caveat.ContextPrecedence)
+	}
+
+	return merged, nil
+}
+
 func combineMaps(first map[string]any, second map[string]any) map[string]any {
 	if first == nil {
 		first = make(map[string]any, len(second))
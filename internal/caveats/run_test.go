@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
 
 	"github.com/authzed/spicedb/internal/caveats"
 	"github.com/authzed/spicedb/internal/datastore/memdb"
@@ -232,6 +233,57 @@ func TestRunCaveatWithMissingMap(t *testing.T) {
 	req.False(result.Value())
 }
 
+func TestRunCaveatWithPartialChildren(t *testing.T) {
+	req := require.New(t)
+
+	rawDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	req.NoError(err)
+
+	ds, _ := testfixtures.DatastoreFromSchemaAndTestRelationships(rawDS, `
+				caveat firstCaveat(first int) {
+					first == 42
+				}
+
+				caveat secondCaveat(second string) {
+					second == 'hello'
+				}
+				`, nil, req)
+
+	headRevision, err := ds.HeadRevision(context.Background())
+	req.NoError(err)
+
+	reader := ds.SnapshotReader(headRevision)
+
+	run := func(expr *core.CaveatExpression, contextValues map[string]any) caveats.ExpressionResult {
+		result, err := caveats.RunCaveatExpression(context.Background(), expr, contextValues, reader, caveats.RunCaveatExpressionNoDebugging)
+		req.NoError(err)
+		return result
+	}
+
+	// A true child of an OR decides the result, even after a partially applied child.
+	result := run(caveatOr(caveatexpr("firstCaveat"), caveatexpr("secondCaveat")), map[string]any{"second": "hello"})
+	req.False(result.IsPartial())
+	req.True(result.Value())
+
+	result = run(caveatOr(caveatexpr("firstCaveat"), caveatexpr("secondCaveat")), map[string]any{"second": "hi"})
+	req.True(result.IsPartial())
+
+	// As does a false child of an AND.
+	result = run(caveatAnd(caveatexpr("firstCaveat"), caveatexpr("secondCaveat")), map[string]any{"second": "hi"})
+	req.False(result.IsPartial())
+	req.False(result.Value())
+
+	result = run(caveatAnd(caveatexpr("firstCaveat"), caveatexpr("secondCaveat")), map[string]any{"second": "hello"})
+	req.True(result.IsPartial())
+
+	missing, err := result.MissingVarNames()
+	req.NoError(err)
+	req.Equal([]string{"first"}, missing)
+
+	result = run(caveatInvert(caveatexpr("firstCaveat")), map[string]any{})
+	req.True(result.IsPartial())
+}
+
 func TestRunCaveatWithEmptyMap(t *testing.T) {
 	req := require.New(t)
 
@@ -261,3 +313,109 @@ func TestRunCaveatWithEmptyMap(t *testing.T) {
 	req.Error(err)
 	req.True(errors.As(err, &caveats.EvaluationErr{}))
 }
+
+func TestRunCaveatWithParameterDefaults(t *testing.T) {
+	req := require.New(t)
+
+	rawDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	req.NoError(err)
+
+	ds, _ := testfixtures.DatastoreFromSchemaAndTestRelationships(rawDS, `
+				caveat under_limit(count int, limit int = 10) {
+					count < limit
+				}
+				`, nil, req)
+
+	headRevision, err := ds.HeadRevision(context.Background())
+	req.NoError(err)
+
+	reader := ds.SnapshotReader(headRevision)
+
+	run := func(contextValues map[string]any) caveats.ExpressionResult {
+		result, err := caveats.RunCaveatExpression(
+			context.Background(),
+			caveatexpr("under_limit"),
+			contextValues,
+			reader,
+			caveats.RunCaveatExpressionNoDebugging,
+		)
+		req.NoError(err)
+		return result
+	}
+
+	// Parameters without defaults are still required.
+	result := run(map[string]any{})
+	req.True(result.IsPartial())
+
+	missing, err := result.MissingVarNames()
+	req.NoError(err)
+	req.Equal([]string{"count"}, missing)
+
+	// The default is used when the parameter is not supplied.
+	req.True(run(map[string]any{"count": int64(5)}).Value())
+	req.False(run(map[string]any{"count": int64(15)}).Value())
+
+	// The context takes precedence over the default.
+	req.True(run(map[string]any{"count": int64(15), "limit": int64(20)}).Value())
+}
+
+func TestRunCaveatWithContextPrecedence(t *testing.T) {
+	req := require.New(t)
+
+	rawDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	req.NoError(err)
+
+	ds, _ := testfixtures.DatastoreFromSchemaAndTestRelationships(rawDS, `
+				caveat relationship_caveat(limit int, count int) {
+					count < limit
+				}
+
+				caveat request_caveat(limit int, count int) with precedence request_wins {
+					count < limit
+				}
+
+				caveat strict_caveat(limit int, count int) with precedence error_on_conflict {
+					count < limit
+				}
+				`, nil, req)
+
+	headRevision, err := ds.HeadRevision(context.Background())
+	req.NoError(err)
+
+	reader := ds.SnapshotReader(headRevision)
+
+	relationshipContext, err := structpb.NewStruct(map[string]any{"limit": 10})
+	req.NoError(err)
+
+	run := func(caveatName string, requestContext map[string]any) (caveats.ExpressionResult, error) {
+		return caveats.RunCaveatExpression(
+			context.Background(),
+			caveats.CaveatAsExpr(&core.ContextualizedCaveat{
+				CaveatName: caveatName,
+				Context:    relationshipContext,
+			}),
+			requestContext,
+			reader,
+			caveats.RunCaveatExpressionNoDebugging,
+		)
+	}
+
+	requestContext := map[string]any{"limit": float64(20), "count": float64(15)}
+
+	result, err := run("relationship_caveat", requestContext)
+	req.NoError(err)
+	req.False(result.Value())
+
+	result, err = run("request_caveat", requestContext)
+	req.NoError(err)
+	req.True(result.Value())
+
+	_, err = run("strict_caveat", requestContext)
+	req.Error(err)
+	req.True(errors.As(err, &caveats.ContextConflictError{}))
+
+	// Values equal to those on the relationship do not conflict.
+	result, err = run("strict_caveat", map[string]any{"limit": float64(10), "count": float64(5)})
+	req.NoError(err)
+	req.True(result.Value())
+}
@@ -0,0 +1,180 @@
+package common
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	log "github.com/authzed/spicedb/internal/logging"
+)
+
+// RelationCount is the number of live relationships of a relation of a namespace.
+type RelationCount struct {
+	Namespace string
+	Relation  string
+	Count     uint64
+}
+
+// RelationCounter represents any datastore that supports counting its live relationships by
+// namespace and relation.
+type RelationCounter interface {
+	CountRelationshipsByRelation(ctx context.Context) ([]RelationCount, error)
+}
+
+// RelationshipGrowth is the growth of the number of live relationships of a relation between
+// two counts, reported when it exceeds the maximum growth rate.
+type RelationshipGrowth struct {
+	Namespace string
+	Relation  string
+	Previous  uint64
+	Current   uint64
+	Interval  time.Duration
+}
+
+// Rate returns the rate of the growth, in relationships per second.
+func (g RelationshipGrowth) Rate() float64 {
+	return (float64(g.Current) - float64(g.Previous)) / g.Interval.Seconds()
+}
+
+var (
+	relationCountGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "spicedb",
+		Subsystem: "datastore",
+		Name:      "relation_relationships",
+		Help:      "The number of live relationships of each relation, as of the last count.",
+	}, []string{"namespace", "relation"})
+
+	namespaceCountGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "spicedb",
+		Subsystem: "datastore",
+		Name:      "namespace_relationships",
+		Help:      "The number of live relationships of each namespace, as of the last count.",
+	}, []string{"namespace"})
+
+	relationGrowthRateGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "spicedb",
+		Subsystem: "datastore",
+		Name:      "relation_relationships_growth_rate",
+		Help:      "The rate at which the number of live relationships of each relation grew between the last two counts, in relationships per second.",
+	}, []string{"namespace", "relation"})
+
+	relationGrowthAlertsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "spicedb",
+		Subsystem: "datastore",
+		Name:      "relation_relationships_growth_alerts_total",
+		Help:      "The number of counts at which the number of live relationships of each relation grew faster than the maximum growth rate.",
+	}, []string{"namespace", "relation"})
+)
+
+// RegisterRelationCountMetrics registers the metrics reported by the relation counts.
+func RegisterRelationCountMetrics() error {
+	for _, metric := range []prometheus.Collector{
+		relationCountGauge,
+		namespaceCountGauge,
+		relationGrowthRateGauge,
+		relationGrowthAlertsCounter,
+	} {
+		if err := prometheus.Register(metric); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type relationKey struct {
+	namespace string
+	relation  string
+}
+
+// StartRelationCounting counts the live relationships of each relation of the datastore
+// immediately and then at each interval, reporting the counts and their growth via metrics,
+// until the context is canceled. Relations whose relationships grow faster than the maximum
+// growth rate, in relationships per second, are logged and reported to the given function. A
+// maximum growth rate of zero disables the alerts.
+func StartRelationCounting(ctx context.Context, counter RelationCounter, interval time.Duration, maximumGrowthRate float64, alert func(growth RelationshipGrowth)) error {
+	log.Ctx(ctx).Info().
+		Dur("interval", interval).
+		Float64("maximumGrowthRate", maximumGrowthRate).
+		Msg("datastore relation counting started")
+
+	var (
+		previous   map[relationKey]uint64
+		previousAt time.Time
+	)
+	next := time.After(0)
+	for {
+		select {
+		case <-ctx.Done():
+			log.Ctx(ctx).Info().
+				Msg("shutting down datastore relation counting")
+			return nil
+
+		case <-next:
+			next = time.After(interval)
+
+			counts, err := counter.CountRelationshipsByRelation(ctx)
+			if err != nil {
+				log.Ctx(ctx).Warn().Err(err).Msg("error counting datastore relationships by relation")
+				continue
+			}
+			countedAt := time.Now()
+
+			current := make(map[relationKey]uint64, len(counts))
+			namespaces := make(map[string]uint64)
+			for _, count := range counts {
+				current[relationKey{count.Namespace, count.Relation}] = count.Count
+				namespaces[count.Namespace] += count.Count
+			}
+
+			// Relations and namespaces without live relationships are reset rather than
+			// removed, so that their drop to zero is visible.
+			for key := range previous {
+				if _, ok := current[key]; !ok {
+					current[key] = 0
+				}
+				if _, ok := namespaces[key.namespace]; !ok {
+					namespaces[key.namespace] = 0
+				}
+			}
+
+			for namespace, count := range namespaces {
+				namespaceCountGauge.WithLabelValues(namespace).Set(float64(count))
+			}
+
+			for key, count := range current {
+				relationCountGauge.WithLabelValues(key.namespace, key.relation).Set(float64(count))
+				if previous == nil {
+					continue
+				}
+
+				growth := RelationshipGrowth{
+					Namespace: key.namespace,
+					Relation:  key.relation,
+					Previous:  previous[key],
+					Current:   count,
+					Interval:  countedAt.Sub(previousAt),
+				}
+				relationGrowthRateGauge.WithLabelValues(key.namespace, key.relation).Set(growth.Rate())
+
+				if maximumGrowthRate > 0 && growth.Rate() > maximumGrowthRate {
+					relationGrowthAlertsCounter.WithLabelValues(key.namespace, key.relation).Inc()
+					log.Ctx(ctx).Warn().
+						Str("namespace", key.namespace).
+						Str("relation", key.relation).
+						Uint64("previous", growth.Previous).
+						Uint64("current", growth.Current).
+						Dur("interval", growth.Interval).
+						Float64("rate", growth.Rate()).
+						Msg("relationships of relation are growing faster than the maximum growth rate")
+					if alert != nil {
+						alert(growth)
+					}
+				}
+			}
+
+			previous, previousAt = current, countedAt
+		}
+	}
+}
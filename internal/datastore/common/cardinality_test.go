@@ -0,0 +1,86 @@
+package common_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/internal/testfixtures"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+func TestMemdbCountRelationshipsByRelation(t *testing.T) {
+	rawDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	require.NoError(t, err)
+	t.Cleanup(func() { rawDS.Close() })
+
+	testfixtures.StandardDatastoreWithData(rawDS, require.New(t))
+
+	expected := make(map[common.RelationCount]struct{})
+	counts := make(map[[2]string]uint64)
+	for _, tpl := range testfixtures.StandardTuples {
+		parsed := tuple.Parse(tpl)
+		counts[[2]string{parsed.ResourceAndRelation.Namespace, parsed.ResourceAndRelation.Relation}]++
+	}
+	for key, count := range counts {
+		expected[common.RelationCount{Namespace: key[0], Relation: key[1], Count: count}] = struct{}{}
+	}
+
+	found, err := rawDS.(common.RelationCounter).CountRelationshipsByRelation(context.Background())
+	require.NoError(t, err)
+
+	actual := make(map[common.RelationCount]struct{}, len(found))
+	for _, count := range found {
+		actual[count] = struct{}{}
+	}
+	require.Equal(t, expected, actual)
+}
+
+type fakeRelationCounter struct {
+	sync.Mutex
+	count uint64
+}
+
+func (f *fakeRelationCounter) CountRelationshipsByRelation(_ context.Context) ([]common.RelationCount, error) {
+	f.Lock()
+	defer f.Unlock()
+	f.count += 1000
+	return []common.RelationCount{
+		{Namespace: "document", Relation: "viewer", Count: f.count},
+		{Namespace: "document", Relation: "owner", Count: 1},
+	}, nil
+}
+
+func TestStartRelationCountingAlertsOnGrowth(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	alerts := make(chan common.RelationshipGrowth, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- common.StartRelationCounting(ctx, &fakeRelationCounter{}, 10*time.Millisecond, 1, func(growth common.RelationshipGrowth) {
+			select {
+			case alerts <- growth:
+			default:
+			}
+		})
+	}()
+
+	select {
+	case growth := <-alerts:
+		require.Equal(t, "document", growth.Namespace)
+		require.Equal(t, "viewer", growth.Relation)
+		require.Equal(t, growth.Previous+1000, growth.Current)
+		require.Greater(t, growth.Rate(), 1.0)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "expected an alert for the growing relation")
+	}
+
+	cancel()
+	require.NoError(t, <-done)
+}
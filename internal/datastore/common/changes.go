@@ -96,6 +96,7 @@ func (ch Changes[R, K]) AsRevisionChanges(lessThanFunc func(lhs, rhs K) bool) []
 				Tuple:     tpl,
 			})
 		}
+		changes[i].SortChanges()
 	}
 
 	return changes
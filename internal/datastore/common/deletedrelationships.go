@@ -0,0 +1,32 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrDeletionNotFound is returned when no relationships are retained for a deletion, because it
+// removed none, it has been restored, or its retention period has passed.
+var ErrDeletionNotFound = errors.New("deletion not found")
+
+// DeletedRelationshipStore represents any datastore that supports retaining the relationships
+// removed by a deletion, so that the deletion can be undone. Retained relationships are stored
+// apart from the relationships of the datastore, and so are hidden from all reads of them. Like
+// jobs, they are not revisioned, and are stored serialized.
+type DeletedRelationshipStore interface {
+	// RetainDeletedRelationships stores the relationships removed by the deletion with the given
+	// ID, replacing any previously stored for it, as deleted at the current time of the datastore.
+	RetainDeletedRelationships(ctx context.Context, deletionID string, relationships [][]byte) error
+
+	// ReadDeletedRelationships returns the relationships retained for the deletion, or
+	// ErrDeletionNotFound.
+	ReadDeletedRelationships(ctx context.Context, deletionID string) ([][]byte, error)
+
+	// ForgetDeletedRelationships removes the relationships retained for the deletion.
+	ForgetDeletedRelationships(ctx context.Context, deletionID string) error
+
+	// PurgeDeletedRelationships removes the relationships retained for longer than the retention
+	// period, and returns the number removed.
+	PurgeDeletedRelationships(ctx context.Context, retention time.Duration) (int64, error)
+}
@@ -0,0 +1,145 @@
+package common
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	log "github.com/authzed/spicedb/internal/logging"
+)
+
+// TableDefinition is the definition of a table of a datastore, by the names of its columns and
+// indexes.
+type TableDefinition struct {
+	Columns []string
+	Indexes []string
+}
+
+// SchemaDefinition is the definition of the tables of a datastore, by table name.
+type SchemaDefinition map[string]TableDefinition
+
+// SchemaDrift is a difference between the live schema of a datastore and the schema expected at
+// its migration revision.
+type SchemaDrift struct {
+	// Table is the name of the table which has drifted.
+	Table string
+
+	// Kind is the kind of object which has drifted: "table", "column" or "index".
+	Kind string
+
+	// Name is the name of the object which has drifted.
+	Name string
+
+	// Problem is either "missing" or "unexpected".
+	Problem string
+}
+
+// SchemaDriftChecker represents any datastore that supports checking its live schema against
+// the schema expected at its migration revision.
+type SchemaDriftChecker interface {
+	CheckSchemaDrift(ctx context.Context) ([]SchemaDrift, error)
+}
+
+var schemaDriftGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+	Namespace: "spicedb",
+	Subsystem: "datastore",
+	Name:      "schema_drift",
+	Help:      "The number of differences found by the last check between the live schema of the datastore and the schema expected at its migration revision.",
+})
+
+// RegisterSchemaDriftMetrics registers the metrics reported by the schema drift check.
+func RegisterSchemaDriftMetrics() error {
+	return prometheus.Register(schemaDriftGauge)
+}
+
+// CompareSchema returns the differences between the live schema and the expected schema. Tables
+// in the live schema that are not in the expected schema are ignored, as they may belong to
+// other applications sharing the database.
+func CompareSchema(expected, live SchemaDefinition) []SchemaDrift {
+	var drift []SchemaDrift
+	for table, expectedTable := range expected {
+		liveTable, ok := live[table]
+		if !ok {
+			drift = append(drift, SchemaDrift{Table: table, Kind: "table", Name: table, Problem: "missing"})
+			continue
+		}
+
+		drift = append(drift, compareNames(table, "column", expectedTable.Columns, liveTable.Columns)...)
+		drift = append(drift, compareNames(table, "index", expectedTable.Indexes, liveTable.Indexes)...)
+	}
+
+	sort.Slice(drift, func(i, j int) bool {
+		if drift[i].Table != drift[j].Table {
+			return drift[i].Table < drift[j].Table
+		}
+		if drift[i].Kind != drift[j].Kind {
+			return drift[i].Kind < drift[j].Kind
+		}
+		return drift[i].Name < drift[j].Name
+	})
+	return drift
+}
+
+func compareNames(table, kind string, expected, live []string) []SchemaDrift {
+	var drift []SchemaDrift
+	liveSet := make(map[string]struct{}, len(live))
+	for _, name := range live {
+		liveSet[name] = struct{}{}
+	}
+
+	expectedSet := make(map[string]struct{}, len(expected))
+	for _, name := range expected {
+		expectedSet[name] = struct{}{}
+		if _, ok := liveSet[name]; !ok {
+			drift = append(drift, SchemaDrift{Table: table, Kind: kind, Name: name, Problem: "missing"})
+		}
+	}
+
+	for _, name := range live {
+		if _, ok := expectedSet[name]; !ok {
+			drift = append(drift, SchemaDrift{Table: table, Kind: kind, Name: name, Problem: "unexpected"})
+		}
+	}
+	return drift
+}
+
+// StartSchemaDriftCheck checks the schema of the datastore for drift immediately and then at
+// each interval, reporting the result via metrics and to the given function, until the context
+// is canceled.
+func StartSchemaDriftCheck(ctx context.Context, checker SchemaDriftChecker, interval time.Duration, report func(drift []SchemaDrift)) error {
+	log.Ctx(ctx).Info().
+		Dur("interval", interval).
+		Msg("datastore schema drift check started")
+
+	next := time.After(0)
+	for {
+		select {
+		case <-ctx.Done():
+			log.Ctx(ctx).Info().
+				Msg("shutting down datastore schema drift check")
+			return nil
+
+		case <-next:
+			next = time.After(interval)
+
+			drift, err := checker.CheckSchemaDrift(ctx)
+			if err != nil {
+				log.Ctx(ctx).Warn().Err(err).Msg("error checking datastore schema for drift")
+				continue
+			}
+
+			schemaDriftGauge.Set(float64(len(drift)))
+			for _, d := range drift {
+				log.Ctx(ctx).Warn().
+					Str("table", d.Table).
+					Str("kind", d.Kind).
+					Str("name", d.Name).
+					Str("problem", d.Problem).
+					Msg("datastore schema has drifted from its migration revision")
+			}
+			report(drift)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareSchema(t *testing.T) {
+	expected := SchemaDefinition{
+		"relation_tuple": {
+			Columns: []string{"namespace", "object_id", "relation"},
+			Indexes: []string{"pk_relation_tuple", "ix_by_subject"},
+		},
+		"metadata": {
+			Columns: []string{"unique_id"},
+		},
+	}
+
+	require.Empty(t, CompareSchema(expected, SchemaDefinition{
+		"relation_tuple": {
+			Columns: []string{"relation", "object_id", "namespace"},
+			Indexes: []string{"ix_by_subject", "pk_relation_tuple"},
+		},
+		"metadata": {
+			Columns: []string{"unique_id"},
+		},
+		"unrelated": {
+			Columns: []string{"id"},
+		},
+	}))
+
+	require.Equal(t, []SchemaDrift{
+		{Table: "metadata", Kind: "table", Name: "metadata", Problem: "missing"},
+		{Table: "relation_tuple", Kind: "column", Name: "extra", Problem: "unexpected"},
+		{Table: "relation_tuple", Kind: "column", Name: "relation", Problem: "missing"},
+		{Table: "relation_tuple", Kind: "index", Name: "ix_by_subject", Problem: "missing"},
+	}, CompareSchema(expected, SchemaDefinition{
+		"relation_tuple": {
+			Columns: []string{"namespace", "object_id", "extra"},
+			Indexes: []string{"pk_relation_tuple"},
+		},
+	}))
+}
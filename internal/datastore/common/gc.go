@@ -42,6 +42,27 @@ var (
 		Name:      "gc_namespaces_total",
 		Help:      "The number of stale namespaces deleted by the datastore garbage collection.",
 	})
+
+	gcBatchSizeGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "spicedb",
+		Subsystem: "datastore",
+		Name:      "gc_batch_size",
+		Help:      "The number of rows deleted by each batch of the datastore garbage collection, as adapted to the load of the datastore.",
+	})
+
+	gcLockContentionCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "spicedb",
+		Subsystem: "datastore",
+		Name:      "gc_lock_contention_total",
+		Help:      "The number of batches of the datastore garbage collection which failed due to contention for locks, and were retried.",
+	})
+
+	gcPausedGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "spicedb",
+		Subsystem: "datastore",
+		Name:      "gc_paused",
+		Help:      "Whether the datastore garbage collection is paused.",
+	})
 )
 
 // RegisterGCMetrics registers garbage collection metrics to the default
@@ -52,6 +73,9 @@ func RegisterGCMetrics() error {
 		gcRelationshipsCounter,
 		gcTransactionsCounter,
 		gcNamespacesCounter,
+		gcBatchSizeGauge,
+		gcLockContentionCounter,
+		gcPausedGauge,
 	} {
 		if err := prometheus.Register(metric); err != nil {
 			return err
@@ -87,6 +111,10 @@ func (g DeletionCounts) MarshalZerologObject(e *zerolog.Event) {
 
 var MaxGCInterval = 60 * time.Minute
 
+// GCLeaseName is the name of the lease held by the replica running garbage collection, when it
+// is elected rather than run by every replica.
+const GCLeaseName = "garbage-collection"
+
 // StartGarbageCollector loops forever until the context is canceled and
 // performs garbage collection on the provided interval.
 func StartGarbageCollector(ctx context.Context, gc GarbageCollector, interval, window, timeout time.Duration) error {
@@ -147,6 +175,12 @@ func RunGarbageCollection(gc GarbageCollector, window, timeout time.Duration) er
 		return nil
 	}
 
+	if controlled, ok := gc.(GCControllingDatastore); ok && controlled.GCController().Paused() {
+		log.Ctx(ctx).Debug().
+			Msg("skipping datastore garbage collection, which is paused")
+		return nil
+	}
+
 	var (
 		startTime = time.Now()
 		collected DeletionCounts
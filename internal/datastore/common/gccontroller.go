@@ -0,0 +1,203 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// GCTuning configures the parallelism and batch sizing of the garbage collection of SQL
+// datastores.
+type GCTuning struct {
+	// Workers is the number of key ranges of each table deleted from in parallel.
+	Workers uint16
+
+	// MinBatchSize and MaxBatchSize bound the number of rows deleted by each statement.
+	MinBatchSize uint64
+	MaxBatchSize uint64
+
+	// TargetBatchLatency is the latency of deleting a batch above which the datastore is
+	// considered loaded, and batches shrink.
+	TargetBatchLatency time.Duration
+}
+
+// DefaultGCTuning is the tuning of garbage collection used unless configured otherwise.
+var DefaultGCTuning = GCTuning{
+	Workers:            4,
+	MinBatchSize:       100,
+	MaxBatchSize:       10_000,
+	TargetBatchLatency: 250 * time.Millisecond,
+}
+
+// GCControllingDatastore represents any datastore whose garbage collection is paced by a
+// GCController.
+type GCControllingDatastore interface {
+	GCController() *GCController
+}
+
+// GCController paces the garbage collection of a SQL datastore so that it does not impact
+// foreground traffic: the stale rows of each table are deleted in parallel over ranges of their
+// keys, in batches whose size adapts to the load of the datastore, and collection may be paused
+// and resumed.
+//
+// Batch sizes adapt additively and multiplicatively, as does TCP congestion control: they grow
+// by the minimum batch size while batches complete well within the target latency, and halve
+// whenever a batch exceeds it or contends for locks, so that collection backs off quickly under
+// load and recovers slowly.
+type GCController struct {
+	tuning GCTuning
+	paused atomic.Bool
+
+	lock      sync.Mutex
+	batchSize uint64
+}
+
+// NewGCController creates a GCController, starting with batches of the minimum size.
+func NewGCController(tuning GCTuning) (*GCController, error) {
+	if tuning.Workers == 0 {
+		return nil, fmt.Errorf("garbage collection workers must be above zero")
+	}
+	if tuning.MinBatchSize == 0 || tuning.MinBatchSize > tuning.MaxBatchSize {
+		return nil, fmt.Errorf("minimum garbage collection batch size (%d) must be above zero and at most the maximum (%d)", tuning.MinBatchSize, tuning.MaxBatchSize)
+	}
+	if tuning.TargetBatchLatency <= 0 {
+		return nil, fmt.Errorf("target garbage collection batch latency (%s) must be above zero", tuning.TargetBatchLatency)
+	}
+
+	gcBatchSizeGauge.Set(float64(tuning.MinBatchSize))
+	return &GCController{
+		tuning:    tuning,
+		batchSize: tuning.MinBatchSize,
+	}, nil
+}
+
+// Pause pauses garbage collection: runs in progress stop after their current batches, and
+// further runs are skipped until it is resumed.
+func (c *GCController) Pause() {
+	c.paused.Store(true)
+	gcPausedGauge.Set(1)
+}
+
+// Resume resumes paused garbage collection from its next run.
+func (c *GCController) Resume() {
+	c.paused.Store(false)
+	gcPausedGauge.Set(0)
+}
+
+// Paused returns whether garbage collection is paused.
+func (c *GCController) Paused() bool {
+	return c.paused.Load()
+}
+
+// BatchSize returns the number of rows to delete in the next batch.
+func (c *GCController) BatchSize() uint64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.batchSize
+}
+
+// observe adapts the batch size to the latency of a batch, and whether it contended for locks.
+func (c *GCController) observe(latency time.Duration, contended bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	switch {
+	case contended || latency > c.tuning.TargetBatchLatency:
+		c.batchSize /= 2
+		if c.batchSize < c.tuning.MinBatchSize {
+			c.batchSize = c.tuning.MinBatchSize
+		}
+
+	case latency < c.tuning.TargetBatchLatency/2:
+		c.batchSize += c.tuning.MinBatchSize
+		if c.batchSize > c.tuning.MaxBatchSize {
+			c.batchSize = c.tuning.MaxBatchSize
+		}
+	}
+	gcBatchSizeGauge.Set(float64(c.batchSize))
+}
+
+// KeyRange is a range of the values of the column by which the rows of a table are deleted,
+// from Start inclusive to End exclusive.
+type KeyRange struct {
+	Start uint64
+	End   uint64
+}
+
+// SplitKeyRange splits the keys from start inclusive to end exclusive into at most the given
+// number of contiguous ranges of near equal width.
+func SplitKeyRange(start, end uint64, parts uint16) []KeyRange {
+	if end <= start || parts == 0 {
+		return nil
+	}
+
+	width := (end - start) / uint64(parts)
+	if width == 0 {
+		width = 1
+	}
+
+	ranges := make([]KeyRange, 0, parts)
+	for rangeStart := start; rangeStart < end; rangeStart += width {
+		rangeEnd := rangeStart + width
+		if len(ranges) == int(parts)-1 || rangeEnd > end {
+			rangeEnd = end
+		}
+		ranges = append(ranges, KeyRange{Start: rangeStart, End: rangeEnd})
+		if rangeEnd == end {
+			break
+		}
+	}
+	return ranges
+}
+
+// BatchDeleter deletes at most limit stale rows whose keys are within the range, and returns
+// the number deleted.
+type BatchDeleter func(ctx context.Context, keys KeyRange, limit uint64) (int64, error)
+
+// DeleteInParallel deletes the stale rows with keys from start inclusive to end exclusive,
+// split into a range for each worker. Each worker deletes batches from its range until a batch
+// deletes fewer rows than its limit, or garbage collection is paused. A batch failing with an
+// error for which isContention returns true is retried after the target batch latency, with a
+// smaller batch size. The number of rows deleted is returned, even on error.
+func (c *GCController) DeleteInParallel(ctx context.Context, start, end uint64, deleteBatch BatchDeleter, isContention func(error) bool) (int64, error) {
+	var deleted int64
+	g, gctx := errgroup.WithContext(ctx)
+	for _, keys := range SplitKeyRange(start, end, c.tuning.Workers) {
+		keys := keys
+		g.Go(func() error {
+			for !c.Paused() {
+				limit := c.BatchSize()
+				batchStart := time.Now()
+				count, err := deleteBatch(gctx, keys, limit)
+				contended := err != nil && isContention(err)
+				c.observe(time.Since(batchStart), contended)
+
+				if contended {
+					gcLockContentionCounter.Inc()
+					select {
+					case <-gctx.Done():
+						return gctx.Err()
+					case <-time.After(c.tuning.TargetBatchLatency):
+						continue
+					}
+				}
+				if err != nil {
+					return err
+				}
+
+				atomic.AddInt64(&deleted, count)
+				if uint64(count) < limit {
+					return nil
+				}
+			}
+			return nil
+		})
+	}
+
+	err := g.Wait()
+	return atomic.LoadInt64(&deleted), err
+}
@@ -0,0 +1,148 @@
+package common_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
+)
+
+var errContention = errors.New("deadlock")
+
+func isContention(err error) bool {
+	return errors.Is(err, errContention)
+}
+
+// fakeTable is a table of stale rows keyed by their transaction IDs.
+type fakeTable struct {
+	sync.Mutex
+	rows      map[uint64]struct{}
+	contended int
+}
+
+func newFakeTable(start, end uint64) *fakeTable {
+	rows := make(map[uint64]struct{}, end-start)
+	for key := start; key < end; key++ {
+		rows[key] = struct{}{}
+	}
+	return &fakeTable{rows: rows}
+}
+
+func (ft *fakeTable) deleteBatch(_ context.Context, keys common.KeyRange, limit uint64) (int64, error) {
+	ft.Lock()
+	defer ft.Unlock()
+
+	if ft.contended > 0 {
+		ft.contended--
+		return 0, errContention
+	}
+
+	var deleted int64
+	for key := keys.Start; key < keys.End && uint64(deleted) < limit; key++ {
+		if _, ok := ft.rows[key]; ok {
+			delete(ft.rows, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func TestSplitKeyRange(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		start, end uint64
+		parts      uint16
+		expected   []common.KeyRange
+	}{
+		{"empty", 10, 10, 4, nil},
+		{"single part", 0, 10, 1, []common.KeyRange{{0, 10}}},
+		{"even", 0, 8, 4, []common.KeyRange{{0, 2}, {2, 4}, {4, 6}, {6, 8}}},
+		{"remainder in last part", 0, 10, 4, []common.KeyRange{{0, 2}, {2, 4}, {4, 6}, {6, 10}}},
+		{"fewer keys than parts", 5, 7, 4, []common.KeyRange{{5, 6}, {6, 7}}},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, common.SplitKeyRange(tc.start, tc.end, tc.parts))
+		})
+	}
+}
+
+func TestNewGCControllerValidatesTuning(t *testing.T) {
+	_, err := common.NewGCController(common.GCTuning{Workers: 0, MinBatchSize: 1, MaxBatchSize: 1, TargetBatchLatency: time.Second})
+	require.Error(t, err)
+
+	_, err = common.NewGCController(common.GCTuning{Workers: 1, MinBatchSize: 10, MaxBatchSize: 1, TargetBatchLatency: time.Second})
+	require.Error(t, err)
+
+	_, err = common.NewGCController(common.GCTuning{Workers: 1, MinBatchSize: 1, MaxBatchSize: 1})
+	require.Error(t, err)
+
+	_, err = common.NewGCController(common.DefaultGCTuning)
+	require.NoError(t, err)
+}
+
+func TestDeleteInParallel(t *testing.T) {
+	controller, err := common.NewGCController(common.GCTuning{
+		Workers:            4,
+		MinBatchSize:       10,
+		MaxBatchSize:       100,
+		TargetBatchLatency: time.Second,
+	})
+	require.NoError(t, err)
+
+	table := newFakeTable(100, 10_100)
+	deleted, err := controller.DeleteInParallel(context.Background(), 100, 10_100, table.deleteBatch, isContention)
+	require.NoError(t, err)
+	require.Equal(t, int64(10_000), deleted)
+	require.Empty(t, table.rows)
+
+	// Batches completing well within the target latency grow up to the maximum.
+	require.Equal(t, uint64(100), controller.BatchSize())
+
+	// Contention halves the batch size, and the batch is retried.
+	table = newFakeTable(0, 50)
+	table.contended = 2
+	deleted, err = controller.DeleteInParallel(context.Background(), 0, 50, table.deleteBatch, isContention)
+	require.NoError(t, err)
+	require.Equal(t, int64(50), deleted)
+	require.Empty(t, table.rows)
+	require.Less(t, controller.BatchSize(), uint64(100))
+}
+
+func TestDeleteInParallelReturnsErrors(t *testing.T) {
+	controller, err := common.NewGCController(common.DefaultGCTuning)
+	require.NoError(t, err)
+
+	failure := errors.New("failed")
+	_, err = controller.DeleteInParallel(context.Background(), 0, 100,
+		func(context.Context, common.KeyRange, uint64) (int64, error) {
+			return 0, failure
+		}, isContention)
+	require.ErrorIs(t, err, failure)
+}
+
+func TestDeleteInParallelPaused(t *testing.T) {
+	controller, err := common.NewGCController(common.DefaultGCTuning)
+	require.NoError(t, err)
+
+	controller.Pause()
+	require.True(t, controller.Paused())
+
+	table := newFakeTable(0, 100)
+	deleted, err := controller.DeleteInParallel(context.Background(), 0, 100, table.deleteBatch, isContention)
+	require.NoError(t, err)
+	require.Zero(t, deleted)
+	require.Len(t, table.rows, 100)
+
+	controller.Resume()
+	require.False(t, controller.Paused())
+
+	deleted, err = controller.DeleteInParallel(context.Background(), 0, 100, table.deleteBatch, isContention)
+	require.NoError(t, err)
+	require.Equal(t, int64(100), deleted)
+}
@@ -0,0 +1,67 @@
+package common
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	log "github.com/authzed/spicedb/internal/logging"
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+var heartbeatCounter = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "spicedb",
+	Subsystem: "datastore",
+	Name:      "revision_heartbeats_total",
+	Help:      "The number of empty transactions written to advance the revision of an otherwise idle datastore.",
+})
+
+// RegisterRevisionHeartbeatMetrics registers the metrics reported by the revision heartbeat.
+func RegisterRevisionHeartbeatMetrics() error {
+	return prometheus.Register(heartbeatCounter)
+}
+
+// StartRevisionHeartbeat writes an empty transaction to the datastore whenever its head revision
+// has not advanced over an interval, so that quantized revisions and Watch checkpoints keep
+// advancing on datastores which only create revisions when written to. It runs until the context
+// is canceled.
+func StartRevisionHeartbeat(ctx context.Context, ds datastore.Datastore, interval time.Duration) error {
+	log.Ctx(ctx).Info().
+		Dur("interval", interval).
+		Msg("datastore revision heartbeat started")
+
+	var last datastore.Revision
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Ctx(ctx).Info().
+				Msg("shutting down datastore revision heartbeat")
+			return nil
+
+		case <-ticker.C:
+			head, err := ds.HeadRevision(ctx)
+			if err != nil {
+				log.Ctx(ctx).Warn().Err(err).Msg("error reading head revision for heartbeat")
+				continue
+			}
+
+			if last != nil && head.Equal(last) {
+				head, err = ds.ReadWriteTx(ctx, func(rwt datastore.ReadWriteTransaction) error {
+					return nil
+				})
+				if err != nil {
+					log.Ctx(ctx).Warn().Err(err).Msg("error writing revision heartbeat")
+					continue
+				}
+
+				heartbeatCounter.Inc()
+				log.Ctx(ctx).Trace().Stringer("revision", head).Msg("wrote revision heartbeat")
+			}
+			last = head
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package common_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+)
+
+func TestRevisionHeartbeatAdvancesIdleDatastore(t *testing.T) {
+	ds, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	require.NoError(t, err)
+	t.Cleanup(func() { ds.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	start, err := ds.HeadRevision(ctx)
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- common.StartRevisionHeartbeat(ctx, ds, 10*time.Millisecond)
+	}()
+
+	require.Eventually(t, func() bool {
+		head, err := ds.HeadRevision(ctx)
+		require.NoError(t, err)
+		return head.GreaterThan(start)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+}
@@ -0,0 +1,55 @@
+package common
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+// RevisionAtTimeDatastore represents any datastore that can find the revision which was current
+// at a past time, so that relationships can be read as they were at that time.
+type RevisionAtTimeDatastore interface {
+	// RevisionAtTime returns the most recent revision at or before the time. The revision may
+	// have since been garbage collected, which is checked by CheckRevision.
+	RevisionAtTime(ctx context.Context, at time.Time) (datastore.Revision, error)
+}
+
+// RevisionTimeDatastore represents any datastore that can find the wall clock time at which a
+// revision was committed, so that the staleness of reads at the revision can be reported.
+type RevisionTimeDatastore interface {
+	// RevisionTime returns the time at which the revision was committed.
+	RevisionTime(ctx context.Context, revision datastore.Revision) (time.Time, error)
+}
+
+// RevisionTimeCache caches the time of the revision most recently looked up, for datastores which
+// must query for the time of a revision. As revisions are quantized, most requests are served at
+// the same revision as the last. The zero value is an empty cache.
+type RevisionTimeCache struct {
+	lock     sync.Mutex
+	revision datastore.Revision
+	at       time.Time
+}
+
+// Get returns the time of the revision, loading and caching it if it is not cached.
+func (c *RevisionTimeCache) Get(revision datastore.Revision, load func() (time.Time, error)) (time.Time, error) {
+	c.lock.Lock()
+	cached, at := c.revision, c.at
+	c.lock.Unlock()
+	if cached != nil && cached.Equal(revision) {
+		return at, nil
+	}
+
+	at, err := load()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.revision == nil || !c.revision.GreaterThan(revision) {
+		c.revision, c.at = revision, at
+	}
+	return at, nil
+}
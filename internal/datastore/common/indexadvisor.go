@@ -0,0 +1,202 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrIndexAdvisorDisabled is returned by datastores asked for index suggestions when their
+// index advisor has not been enabled.
+var ErrIndexAdvisorDisabled = errors.New("the index advisor of the datastore is disabled")
+
+// AdvisedIndexPrefix is the prefix of the names of the indexes created from suggestions of the
+// index advisor, by which they are told apart from those created by migrations.
+const AdvisedIndexPrefix = "ix_advised_"
+
+// FilterShape is a set of columns which relationship queries have filtered on, and the number
+// of queries which did.
+type FilterShape struct {
+	// Columns are the filtered columns, in the order of the columns of the advisor.
+	Columns []string
+
+	// Count is the number of queries which filtered on exactly these columns.
+	Count uint64
+
+	// LastUsed is the time of the last query which filtered on exactly these columns.
+	LastUsed time.Time
+}
+
+// IndexDefinition is an index of a table, by the names of its columns, in order.
+type IndexDefinition struct {
+	Name    string
+	Columns []string
+}
+
+// IndexSuggestion is an index suggested to serve the queries of a filter shape, as no existing
+// index has the columns of the shape as its leading columns.
+type IndexSuggestion struct {
+	// Table is the table to index.
+	Table string
+
+	// Shape is the filter shape for which the index is suggested.
+	Shape FilterShape
+
+	// Name is the name under which the index would be created.
+	Name string
+
+	// Statement is the statement creating the index.
+	Statement string
+
+	// Created is true if the index was created by the advisor.
+	Created bool
+}
+
+// IndexAdvisingDatastore represents any datastore that records the filter shapes of its
+// relationship queries, and can suggest indexes serving them.
+type IndexAdvisingDatastore interface {
+	// SuggestIndexes returns an index suggestion for each recorded filter shape which no
+	// existing index serves, and creates the suggested indexes if configured to do so.
+	SuggestIndexes(ctx context.Context) ([]IndexSuggestion, error)
+}
+
+// IndexAdvisor records the filter shapes of the relationship queries run against a table, so
+// that indexes serving the most common of them can be suggested.
+//
+// A nil IndexAdvisor records nothing.
+type IndexAdvisor struct {
+	table       string
+	columnOrder map[string]int
+
+	lock   sync.Mutex
+	shapes map[string]*FilterShape
+}
+
+// NewIndexAdvisor creates an IndexAdvisor for the table. The columns of the suggested indexes
+// are ordered as in the given columns, which should list the most selective first; columns
+// which are not listed are never suggested.
+func NewIndexAdvisor(table string, columns []string) *IndexAdvisor {
+	columnOrder := make(map[string]int, len(columns))
+	for index, column := range columns {
+		columnOrder[column] = index
+	}
+
+	return &IndexAdvisor{
+		table:       table,
+		columnOrder: columnOrder,
+		shapes:      make(map[string]*FilterShape),
+	}
+}
+
+// RecordQuery records a query which filtered on the given columns.
+func (ia *IndexAdvisor) RecordQuery(filteredColumns []string) {
+	if ia == nil {
+		return
+	}
+
+	columns := ia.shapeColumns(filteredColumns)
+	if len(columns) == 0 {
+		return
+	}
+	key := strings.Join(columns, ",")
+
+	ia.lock.Lock()
+	defer ia.lock.Unlock()
+
+	shape, ok := ia.shapes[key]
+	if !ok {
+		shape = &FilterShape{Columns: columns}
+		ia.shapes[key] = shape
+	}
+	shape.Count++
+	shape.LastUsed = time.Now()
+}
+
+// Shapes returns the recorded filter shapes, the most common first.
+func (ia *IndexAdvisor) Shapes() []FilterShape {
+	ia.lock.Lock()
+	shapes := make([]FilterShape, 0, len(ia.shapes))
+	for _, shape := range ia.shapes {
+		shapes = append(shapes, *shape)
+	}
+	ia.lock.Unlock()
+
+	sort.Slice(shapes, func(i, j int) bool {
+		if shapes[i].Count != shapes[j].Count {
+			return shapes[i].Count > shapes[j].Count
+		}
+		return strings.Join(shapes[i].Columns, ",") < strings.Join(shapes[j].Columns, ",")
+	})
+	return shapes
+}
+
+// Suggest returns a suggestion for each recorded filter shape which is not served by any of
+// the existing indexes of the table, the most common first. An index serves a shape if its
+// leading columns are exactly the columns of the shape, in any order.
+func (ia *IndexAdvisor) Suggest(existing []IndexDefinition) []IndexSuggestion {
+	var suggestions []IndexSuggestion
+	for _, shape := range ia.Shapes() {
+		if servedByAny(shape, existing) {
+			continue
+		}
+
+		name := AdvisedIndexPrefix + ia.table + "_" + StatementFingerprint(strings.Join(shape.Columns, ","))[:8]
+		suggestions = append(suggestions, IndexSuggestion{
+			Table:     ia.table,
+			Shape:     shape,
+			Name:      name,
+			Statement: fmt.Sprintf("CREATE INDEX %s ON %s (%s)", name, ia.table, strings.Join(shape.Columns, ", ")),
+		})
+	}
+	return suggestions
+}
+
+// shapeColumns returns the distinct known columns, in the order of the columns of the advisor.
+func (ia *IndexAdvisor) shapeColumns(filteredColumns []string) []string {
+	seen := make(map[string]struct{}, len(filteredColumns))
+	columns := make([]string, 0, len(filteredColumns))
+	for _, column := range filteredColumns {
+		if _, ok := ia.columnOrder[column]; !ok {
+			continue
+		}
+		if _, ok := seen[column]; ok {
+			continue
+		}
+		seen[column] = struct{}{}
+		columns = append(columns, column)
+	}
+
+	sort.Slice(columns, func(i, j int) bool {
+		return ia.columnOrder[columns[i]] < ia.columnOrder[columns[j]]
+	})
+	return columns
+}
+
+func servedByAny(shape FilterShape, indexes []IndexDefinition) bool {
+	for _, index := range indexes {
+		if len(index.Columns) < len(shape.Columns) {
+			continue
+		}
+
+		leading := make(map[string]struct{}, len(shape.Columns))
+		for _, column := range index.Columns[:len(shape.Columns)] {
+			leading[column] = struct{}{}
+		}
+
+		served := true
+		for _, column := range shape.Columns {
+			if _, ok := leading[column]; !ok {
+				served = false
+				break
+			}
+		}
+		if served {
+			return true
+		}
+	}
+	return false
+}
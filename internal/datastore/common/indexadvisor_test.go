@@ -0,0 +1,121 @@
+package common
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/options"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+var advisorSchema = SchemaInformation{
+	TableTuple:          "tuple",
+	ColNamespace:        "ns",
+	ColObjectID:         "object_id",
+	ColRelation:         "relation",
+	ColUsersetNamespace: "subject_ns",
+	ColUsersetObjectID:  "subject_object_id",
+	ColUsersetRelation:  "subject_relation",
+	ColCaveatName:       "caveat",
+}
+
+var advisorColumns = []string{"object_id", "subject_object_id", "ns", "relation", "subject_ns", "subject_relation", "caveat"}
+
+func TestIndexAdvisorRecordsFilterShapes(t *testing.T) {
+	advisor := NewIndexAdvisor("tuple", advisorColumns)
+	splitter := TupleQuerySplitter{
+		Executor: func(ctx context.Context, sql string, args []any) ([]*core.RelationTuple, error) {
+			return nil, nil
+		},
+		UsersetBatchSize: 2,
+		IndexAdvisor:     advisor,
+	}
+
+	query := func(filter func(SchemaQueryFilterer) SchemaQueryFilterer, opts ...options.QueryOptionsOption) {
+		it, err := splitter.SplitAndExecuteQuery(context.Background(), filter(NewSchemaQueryFilterer(advisorSchema, sq.Select("*"))), opts...)
+		require.NoError(t, err)
+		it.Close()
+	}
+
+	for i := 0; i < 3; i++ {
+		query(func(sqf SchemaQueryFilterer) SchemaQueryFilterer {
+			return sqf.FilterToResourceType("document").FilterToRelation("viewer")
+		})
+	}
+
+	query(func(sqf SchemaQueryFilterer) SchemaQueryFilterer {
+		return sqf.MustFilterWithSubjectsSelectors(
+			datastore.SubjectsSelector{OptionalSubjectType: "user", OptionalSubjectIds: []string{"tom"}},
+			datastore.SubjectsSelector{
+				OptionalSubjectType: "group",
+				RelationFilter:      datastore.SubjectRelationFilter{}.WithNonEllipsisRelation("member"),
+			},
+		)
+	})
+
+	// Relations other than the ellipsis cannot be served by an index.
+	query(func(sqf SchemaQueryFilterer) SchemaQueryFilterer {
+		return sqf.FilterToResourceType("document").MustFilterWithSubjectsSelectors(datastore.SubjectsSelector{
+			OptionalSubjectType: "group",
+			RelationFilter:      datastore.SubjectRelationFilter{}.WithOnlyNonEllipsisRelations(),
+		})
+	})
+
+	// The query is split in two, each filtering on the usersets.
+	query(func(sqf SchemaQueryFilterer) SchemaQueryFilterer {
+		return sqf.FilterToResourceType("document")
+	}, options.SetUsersets([]*core.ObjectAndRelation{
+		tuple.ParseONR("group:eng#member"),
+		tuple.ParseONR("group:sales#member"),
+		tuple.ParseONR("group:ops#member"),
+	}))
+
+	shapes := advisor.Shapes()
+	counts := make(map[string]uint64, len(shapes))
+	for _, shape := range shapes {
+		counts[strings.Join(shape.Columns, ",")] = shape.Count
+	}
+	require.Equal(t, map[string]uint64{
+		"ns,relation": 3,
+		"subject_object_id,subject_ns,subject_relation": 1,
+		"ns,subject_ns": 1,
+		"subject_object_id,ns,subject_ns,subject_relation": 2,
+	}, counts)
+	require.Equal(t, []string{"ns", "relation"}, shapes[0].Columns)
+}
+
+func TestIndexAdvisorSuggest(t *testing.T) {
+	advisor := NewIndexAdvisor("tuple", advisorColumns)
+	advisor.RecordQuery([]string{"ns", "object_id", "relation"})
+	advisor.RecordQuery([]string{"relation", "ns"})
+	advisor.RecordQuery([]string{"relation", "ns"})
+	advisor.RecordQuery([]string{"caveat"})
+	advisor.RecordQuery([]string{"labels"})
+
+	existing := []IndexDefinition{
+		{Name: "pk_tuple", Columns: []string{"ns", "object_id", "relation", "subject_ns", "subject_object_id", "subject_relation"}},
+		{Name: "ix_tuple_by_caveat", Columns: []string{"caveat"}},
+	}
+
+	suggestions := advisor.Suggest(existing)
+	require.Len(t, suggestions, 1)
+	require.Equal(t, "tuple", suggestions[0].Table)
+	require.Equal(t, []string{"ns", "relation"}, suggestions[0].Shape.Columns)
+	require.Equal(t, uint64(2), suggestions[0].Shape.Count)
+	require.Regexp(t, "^"+AdvisedIndexPrefix+"tuple_[0-9a-f]{8}$", suggestions[0].Name)
+	require.Equal(t, "CREATE INDEX "+suggestions[0].Name+" ON tuple (ns, relation)", suggestions[0].Statement)
+
+	existing = append(existing, IndexDefinition{Name: suggestions[0].Name, Columns: []string{"relation", "ns", "object_id"}})
+	require.Empty(t, advisor.Suggest(existing))
+}
+
+func TestNilIndexAdvisor(t *testing.T) {
+	var advisor *IndexAdvisor
+	advisor.RecordQuery([]string{"ns"})
+}
@@ -0,0 +1,34 @@
+package common
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrJobNotFound is returned when a job does not exist in the datastore.
+var ErrJobNotFound = errors.New("job not found")
+
+// StoredJob is the serialized state of a job, as stored in the datastore. The version is
+// incremented by each update, starting at one.
+type StoredJob struct {
+	ID      string
+	Version uint64
+	Data    []byte
+}
+
+// JobStore represents any datastore that supports storing the state of jobs. Jobs are not
+// revisioned: their state is updated in place, guarded by its version.
+type JobStore interface {
+	// CreateJob stores a new job with the given state, at version one.
+	CreateJob(ctx context.Context, id string, data []byte) error
+
+	// ReadJob returns the stored state of a job, or ErrJobNotFound.
+	ReadJob(ctx context.Context, id string) (StoredJob, error)
+
+	// ListJobs returns the stored state of every job.
+	ListJobs(ctx context.Context) ([]StoredJob, error)
+
+	// UpdateJob replaces the state of a job if it is still at the given version, incrementing
+	// the version, and returns whether the job was updated.
+	UpdateJob(ctx context.Context, id string, version uint64, data []byte) (bool, error)
+}
@@ -0,0 +1,136 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+
+	log "github.com/authzed/spicedb/internal/logging"
+)
+
+// DefaultLeaseDuration is the default duration of the leases taken to run singleton tasks.
+const DefaultLeaseDuration = 30 * time.Second
+
+// LeaseHolderID identifies this process as the holder of leases. It is unique to each run of
+// the process, so that a restarted process does not mistake the lease of its previous run for
+// its own.
+var LeaseHolderID = func() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s-%s", hostname, uuid.NewString()[:8])
+}()
+
+// LeaseStore represents any datastore that supports named leases, used to elect a single
+// replica to run a task without external coordination. Lease expiry is measured by the clock of
+// the datastore, so that the clocks of the replicas need not agree.
+type LeaseStore interface {
+	// AcquireLease takes the named lease for the holder for the duration, if it is free, has
+	// expired, or is already held by the holder, in which case it is renewed. It returns whether
+	// the holder now holds the lease.
+	AcquireLease(ctx context.Context, name, holder string, duration time.Duration) (bool, error)
+
+	// ReleaseLease frees the named lease, if it is held by the holder.
+	ReleaseLease(ctx context.Context, name, holder string) error
+}
+
+var leaseHeldGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "spicedb",
+	Subsystem: "datastore",
+	Name:      "lease_held",
+	Help:      "Whether this replica holds each lease, and so runs the task it guards.",
+}, []string{"lease"})
+
+// RegisterLeaseMetrics registers the metrics reported by the leases held.
+func RegisterLeaseMetrics() error {
+	return prometheus.Register(leaseHeldGauge)
+}
+
+// RunWithLease runs the task only while this process holds the named lease, until the context
+// is canceled. The lease is taken when free or expired and renewed at a third of its duration
+// while the task runs. If the lease is lost, because it could not be renewed before expiring,
+// the context of the task is canceled, and the lease contended for again. The lease is released
+// when the task returns. Errors returned by the task, other than its cancellation, are returned.
+func RunWithLease(ctx context.Context, leases LeaseStore, name string, duration time.Duration, run func(ctx context.Context) error) error {
+	renewInterval := duration / 3
+	for {
+		held, err := leases.AcquireLease(ctx, name, LeaseHolderID, duration)
+		if err != nil && ctx.Err() == nil {
+			log.Ctx(ctx).Warn().Err(err).Str("lease", name).Msg("error acquiring lease")
+		}
+
+		if held {
+			log.Ctx(ctx).Info().Str("lease", name).Str("holder", LeaseHolderID).Msg("acquired lease")
+			leaseHeldGauge.WithLabelValues(name).Set(1)
+			err := runWhileHeld(ctx, leases, name, duration, renewInterval, run)
+			leaseHeldGauge.WithLabelValues(name).Set(0)
+			if err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(renewInterval):
+		}
+	}
+}
+
+func runWhileHeld(ctx context.Context, leases LeaseStore, name string, duration, renewInterval time.Duration, run func(ctx context.Context) error) error {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- run(runCtx)
+	}()
+
+	renewed := time.Now()
+	renew := time.NewTicker(renewInterval)
+	defer renew.Stop()
+	for {
+		select {
+		case err := <-done:
+			// The context may have been canceled, so the lease is released under its own.
+			releaseCtx, cancelRelease := context.WithTimeout(context.Background(), renewInterval)
+			if releaseErr := leases.ReleaseLease(releaseCtx, name, LeaseHolderID); releaseErr != nil {
+				log.Ctx(ctx).Warn().Err(releaseErr).Str("lease", name).Msg("error releasing lease")
+			}
+			cancelRelease()
+
+			if err != nil && ctx.Err() == nil {
+				return err
+			}
+			return nil
+
+		case <-renew.C:
+			held, err := leases.AcquireLease(ctx, name, LeaseHolderID, duration)
+			switch {
+			case err == nil && held:
+				renewed = time.Now()
+				continue
+
+			case err != nil && time.Since(renewed)+renewInterval < duration:
+				// The lease remains held until the next renewal.
+				log.Ctx(ctx).Warn().Err(err).Str("lease", name).Msg("error renewing lease")
+				continue
+
+			case err != nil:
+				log.Ctx(ctx).Warn().Err(err).Str("lease", name).Msg("unable to renew lease before it expires; stopping its task")
+
+			default:
+				log.Ctx(ctx).Warn().Str("lease", name).Msg("lease was taken by another holder; stopping its task")
+			}
+
+			cancel()
+			<-done
+			return nil
+		}
+	}
+}
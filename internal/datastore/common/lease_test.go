@@ -0,0 +1,102 @@
+package common_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+)
+
+func newLeaseStore(t *testing.T) common.LeaseStore {
+	ds, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	require.NoError(t, err)
+	t.Cleanup(func() { ds.Close() })
+
+	return ds.(common.LeaseStore)
+}
+
+func TestLeaseAcquireAndRelease(t *testing.T) {
+	ctx := context.Background()
+	leases := newLeaseStore(t)
+
+	held, err := leases.AcquireLease(ctx, "task", "first", 50*time.Millisecond)
+	require.NoError(t, err)
+	require.True(t, held)
+
+	// The holder may renew its lease, but others may not take it until it expires.
+	held, err = leases.AcquireLease(ctx, "task", "first", 50*time.Millisecond)
+	require.NoError(t, err)
+	require.True(t, held)
+
+	held, err = leases.AcquireLease(ctx, "task", "second", 50*time.Millisecond)
+	require.NoError(t, err)
+	require.False(t, held)
+
+	held, err = leases.AcquireLease(ctx, "other-task", "second", 50*time.Millisecond)
+	require.NoError(t, err)
+	require.True(t, held)
+
+	require.Eventually(t, func() bool {
+		held, err := leases.AcquireLease(ctx, "task", "second", time.Minute)
+		require.NoError(t, err)
+		return held
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Releasing a lease held by another holder has no effect.
+	require.NoError(t, leases.ReleaseLease(ctx, "task", "first"))
+	held, err = leases.AcquireLease(ctx, "task", "first", time.Minute)
+	require.NoError(t, err)
+	require.False(t, held)
+
+	require.NoError(t, leases.ReleaseLease(ctx, "task", "second"))
+	held, err = leases.AcquireLease(ctx, "task", "first", time.Minute)
+	require.NoError(t, err)
+	require.True(t, held)
+}
+
+func TestRunWithLeaseWaitsForLease(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	leases := newLeaseStore(t)
+
+	// Another replica holds the lease until it expires.
+	held, err := leases.AcquireLease(ctx, "task", "other-replica", 200*time.Millisecond)
+	require.NoError(t, err)
+	require.True(t, held)
+
+	started := make(chan time.Time, 1)
+	done := make(chan error, 1)
+	begin := time.Now()
+	go func() {
+		done <- common.RunWithLease(ctx, leases, "task", 30*time.Millisecond, func(ctx context.Context) error {
+			started <- time.Now()
+			<-ctx.Done()
+			return ctx.Err()
+		})
+	}()
+
+	select {
+	case at := <-started:
+		require.GreaterOrEqual(t, at.Sub(begin), 150*time.Millisecond)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "task was never started")
+	}
+
+	// While the task runs, its lease is renewed and so cannot be taken.
+	time.Sleep(100 * time.Millisecond)
+	held, err = leases.AcquireLease(ctx, "task", "other-replica", time.Minute)
+	require.NoError(t, err)
+	require.False(t, held)
+
+	cancel()
+	require.NoError(t, <-done)
+
+	// The lease is released once the task stops.
+	held, err = leases.AcquireLease(context.Background(), "task", "other-replica", time.Minute)
+	require.NoError(t, err)
+	require.True(t, held)
+}
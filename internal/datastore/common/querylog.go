@@ -0,0 +1,135 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"regexp"
+	"strings"
+	"time"
+
+	log "github.com/authzed/spicedb/internal/logging"
+)
+
+var (
+	placeholderRegex     = regexp.MustCompile(`(\$|@p)\d+`)
+	placeholderListRegex = regexp.MustCompile(`\?(\s*,\s*\?)+`)
+)
+
+// QueryLogger logs a sample of the relationship queries executed by a SQL datastore, with
+// their normalized statement, number of parameters, number of rows returned and duration.
+// Logged through the logger of the context, the queries can be correlated with the API
+// request which caused them.
+//
+// A nil QueryLogger logs nothing.
+type QueryLogger struct {
+	sampleRate float64
+	sample     func() float64
+}
+
+// NewQueryLogger creates a QueryLogger which logs each executed statement with the given
+// probability, between 0 and 1. It returns nil if the rate is not above 0.
+func NewQueryLogger(sampleRate float64) *QueryLogger {
+	if sampleRate <= 0 {
+		return nil
+	}
+	return &QueryLogger{sampleRate: sampleRate, sample: rand.Float64}
+}
+
+// LogQuery logs the executed statement, if it is sampled.
+func (ql *QueryLogger) LogQuery(ctx context.Context, sql string, args []any, rows int, duration time.Duration, err error) {
+	if ql == nil || ql.sample() >= ql.sampleRate {
+		return
+	}
+
+	statement := NormalizeStatement(sql)
+	event := log.Ctx(ctx).Info()
+	if err != nil {
+		event = log.Ctx(ctx).Warn().Err(err)
+	}
+	event.
+		Str("statement", statement).
+		Str("fingerprint", StatementFingerprint(statement)).
+		Int("parameters", len(args)).
+		Int("rows", rows).
+		Dur("duration", duration).
+		Msg("datastore query")
+}
+
+// NormalizeStatement returns the SQL statement with its whitespace collapsed, its placeholders
+// replaced by `?`, and its lists of placeholders and repeated alternatives each reduced to
+// a single element, so that queries of the same shape have the same normalized statement,
+// whatever the number of values they filter on.
+func NormalizeStatement(sql string) string {
+	normalized := strings.Join(strings.Fields(sql), " ")
+	normalized = placeholderRegex.ReplaceAllString(normalized, "?")
+	normalized = placeholderListRegex.ReplaceAllString(normalized, "?")
+	return collapseRepeatedAlternatives(normalized)
+}
+
+// StatementFingerprint returns a short hash of a normalized statement, by which the queries
+// of the same shape can be grouped.
+func StatementFingerprint(normalized string) string {
+	hasher := fnv.New64a()
+	hasher.Write([]byte(normalized))
+	return fmt.Sprintf("%016x", hasher.Sum64())
+}
+
+// collapseRepeatedAlternatives replaces each run of identical alternatives, such as
+// `a = ? AND b = ? OR a = ? AND b = ?`, with its first alternative, within the statement and
+// each of its parenthesized groups.
+func collapseRepeatedAlternatives(sql string) string {
+	const separator = " OR "
+
+	var alternatives []string
+	var current strings.Builder
+	for i := 0; i < len(sql); {
+		switch {
+		case sql[i] == '(':
+			group := parenthesizedGroup(sql[i:])
+			inner := strings.TrimSuffix(group[1:], ")")
+			current.WriteString("(" + collapseRepeatedAlternatives(inner))
+			if len(inner) < len(group)-1 {
+				current.WriteString(")")
+			}
+			i += len(group)
+
+		case strings.HasPrefix(sql[i:], separator):
+			alternatives = append(alternatives, current.String())
+			current.Reset()
+			i += len(separator)
+
+		default:
+			current.WriteByte(sql[i])
+			i++
+		}
+	}
+	alternatives = append(alternatives, current.String())
+
+	collapsed := make([]string, 0, len(alternatives))
+	for i, alternative := range alternatives {
+		if i == 0 || alternative != alternatives[i-1] {
+			collapsed = append(collapsed, alternative)
+		}
+	}
+	return strings.Join(collapsed, separator)
+}
+
+// parenthesizedGroup returns the prefix of the string up to the parenthesis closing its
+// opening one, or the whole string if it is not closed.
+func parenthesizedGroup(sql string) string {
+	depth := 0
+	for i, c := range sql {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return sql[:i+1]
+			}
+		}
+	}
+	return sql
+}
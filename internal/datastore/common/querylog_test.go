@@ -0,0 +1,156 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+func TestNormalizeStatement(t *testing.T) {
+	tests := []struct {
+		name     string
+		sql      string
+		expected string
+	}{
+		{
+			"whitespace",
+			"SELECT *\n\tFROM tuple   WHERE ns = ?",
+			"SELECT * FROM tuple WHERE ns = ?",
+		},
+		{
+			"dollar placeholders",
+			"SELECT * FROM tuple WHERE ns = $1 AND relation = $2",
+			"SELECT * FROM tuple WHERE ns = ? AND relation = ?",
+		},
+		{
+			"spanner placeholders",
+			"SELECT * FROM tuple WHERE ns = @p1 AND relation = @p12",
+			"SELECT * FROM tuple WHERE ns = ? AND relation = ?",
+		},
+		{
+			"placeholder list",
+			"SELECT * FROM tuple WHERE object_id IN ($1, $2, $3)",
+			"SELECT * FROM tuple WHERE object_id IN (?)",
+		},
+		{
+			"repeated alternatives",
+			"SELECT * FROM tuple WHERE ns = ? AND (subject_ns = ? AND subject_object_id = ? OR subject_ns = ? AND subject_object_id = ?)",
+			"SELECT * FROM tuple WHERE ns = ? AND (subject_ns = ? AND subject_object_id = ?)",
+		},
+		{
+			"distinct alternatives",
+			"SELECT * FROM tuple WHERE (subject_relation = ? OR subject_relation IS NULL)",
+			"SELECT * FROM tuple WHERE (subject_relation = ? OR subject_relation IS NULL)",
+		},
+		{
+			"unbalanced parentheses",
+			"SELECT * FROM tuple WHERE (ns = ?",
+			"SELECT * FROM tuple WHERE (ns = ?",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expected, NormalizeStatement(test.sql))
+		})
+	}
+}
+
+func TestNormalizeStatementIgnoresUsersetCount(t *testing.T) {
+	render := func(usersets ...*core.ObjectAndRelation) string {
+		filterer := NewSchemaQueryFilterer(SchemaInformation{
+			TableTuple:          "tuple",
+			ColNamespace:        "ns",
+			ColObjectID:         "object_id",
+			ColRelation:         "relation",
+			ColUsersetNamespace: "subject_ns",
+			ColUsersetObjectID:  "subject_object_id",
+			ColUsersetRelation:  "subject_relation",
+		}, sq.Select("*").PlaceholderFormat(sq.Dollar))
+
+		sql, _, err := filterer.FilterToResourceType("document").filterToUsersets(usersets).queryBuilder.ToSql()
+		require.NoError(t, err)
+		return NormalizeStatement(sql)
+	}
+
+	one := render(tuple.ParseONR("group:eng#member"))
+	three := render(tuple.ParseONR("group:eng#member"), tuple.ParseONR("group:sales#member"), tuple.ParseONR("group:ops#member"))
+	require.Equal(t, one, three)
+	require.Equal(t, StatementFingerprint(one), StatementFingerprint(three))
+}
+
+func TestQueryLogger(t *testing.T) {
+	tests := []struct {
+		name       string
+		sampleRate float64
+		sampled    float64
+		err        error
+		expected   map[string]any
+	}{
+		{"not sampled", 0.5, 0.7, nil, nil},
+		{
+			"sampled",
+			0.5,
+			0.2,
+			nil,
+			map[string]any{
+				"level":      "info",
+				"statement":  "SELECT * FROM tuple WHERE object_id IN (?)",
+				"parameters": float64(3),
+				"rows":       float64(7),
+			},
+		},
+		{
+			"failed",
+			1,
+			0.9,
+			errors.New("connection reset"),
+			map[string]any{
+				"level":     "warn",
+				"error":     "connection reset",
+				"statement": "SELECT * FROM tuple WHERE object_id IN (?)",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			ctx := zerolog.New(&buf).WithContext(context.Background())
+
+			ql := NewQueryLogger(test.sampleRate)
+			ql.sample = func() float64 { return test.sampled }
+			ql.LogQuery(ctx, "SELECT * FROM tuple WHERE object_id IN ($1, $2, $3)", []any{"a", "b", "c"}, 7, time.Millisecond, test.err)
+
+			if test.expected == nil {
+				require.Zero(t, buf.Len())
+				return
+			}
+
+			var logged map[string]any
+			require.NoError(t, json.Unmarshal(buf.Bytes(), &logged))
+			for key, value := range test.expected {
+				require.Equal(t, value, logged[key], key)
+			}
+			require.Equal(t, StatementFingerprint(test.expected["statement"].(string)), logged["fingerprint"])
+			require.Contains(t, logged, "duration")
+		})
+	}
+}
+
+func TestNilQueryLogger(t *testing.T) {
+	require.Nil(t, NewQueryLogger(0))
+
+	var ql *QueryLogger
+	ql.LogQuery(context.Background(), "SELECT 1", nil, 1, time.Millisecond, nil)
+}
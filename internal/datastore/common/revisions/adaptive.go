@@ -0,0 +1,136 @@
+package revisions
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// writeRateSmoothingPeriod is the period over which the write rate is averaged.
+const writeRateSmoothingPeriod = time.Minute
+
+var (
+	quantizationWindowGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "spicedb",
+		Subsystem: "datastore",
+		Name:      "revision_quantization_window_seconds",
+		Help:      "The effective revision quantization window, adapted to the write rate.",
+	})
+
+	quantizationWriteRateGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "spicedb",
+		Subsystem: "datastore",
+		Name:      "revision_quantization_write_rate",
+		Help:      "The average number of writes per second to which the revision quantization window is adapted.",
+	})
+)
+
+// RegisterAdaptiveQuantizationMetrics registers the metrics of adaptive revision quantization
+// to the default registry.
+func RegisterAdaptiveQuantizationMetrics() error {
+	for _, metric := range []prometheus.Collector{
+		quantizationWindowGauge,
+		quantizationWriteRateGauge,
+	} {
+		if err := prometheus.Register(metric); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AdaptiveQuantization adapts the revision quantization window to the rate of writes to the
+// datastore. Under low write rates the data changes rarely, so a short window serves fresh
+// data while rarely changing the revision and invalidating caches; under high write rates a
+// longer window keeps revisions, and so cache entries, shared across more requests.
+//
+// The window grows from the minimum at no writes to the maximum at the full window write
+// rate. It is always the minimum multiplied by a power of two, or the maximum, so that the
+// boundaries of shorter windows include those of longer ones, and servers which have
+// measured slightly different write rates still mostly select the same revisions.
+type AdaptiveQuantization struct {
+	// writes is first, to be 64-bit aligned for atomic access on 32-bit platforms.
+	writes uint64
+
+	minimum             time.Duration
+	maximum             time.Duration
+	fullWindowWriteRate float64
+	clockFn             clock.Clock
+
+	lock       sync.Mutex
+	lastUpdate time.Time
+	writeRate  float64
+}
+
+// NewAdaptiveQuantization creates an AdaptiveQuantization with a window between the minimum
+// and the maximum, reaching the maximum at the given number of writes per second.
+func NewAdaptiveQuantization(minimum, maximum time.Duration, fullWindowWriteRate float64) (*AdaptiveQuantization, error) {
+	if minimum <= 0 || minimum > maximum {
+		return nil, fmt.Errorf("minimum revision quantization (%s) must be above zero and at most the revision quantization (%s)", minimum, maximum)
+	}
+	if fullWindowWriteRate <= 0 {
+		return nil, fmt.Errorf("write rate for the full revision quantization window (%f) must be above zero", fullWindowWriteRate)
+	}
+
+	clockFn := clock.New()
+	return &AdaptiveQuantization{
+		minimum:             minimum,
+		maximum:             maximum,
+		fullWindowWriteRate: fullWindowWriteRate,
+		clockFn:             clockFn,
+		lastUpdate:          clockFn.Now(),
+	}, nil
+}
+
+// RecordWrite records a write to the datastore. A nil AdaptiveQuantization records nothing.
+func (aq *AdaptiveQuantization) RecordWrite() {
+	if aq == nil {
+		return
+	}
+	atomic.AddUint64(&aq.writes, 1)
+}
+
+// Window updates the average write rate with the writes recorded since it was last called,
+// and returns the quantization window for it.
+func (aq *AdaptiveQuantization) Window() time.Duration {
+	aq.lock.Lock()
+	defer aq.lock.Unlock()
+
+	now := aq.clockFn.Now()
+	elapsed := now.Sub(aq.lastUpdate)
+	if elapsed > 0 {
+		writes := atomic.SwapUint64(&aq.writes, 0)
+		instantRate := float64(writes) / elapsed.Seconds()
+
+		// Average exponentially, weighting the interval by its length, so that the rate does
+		// not depend on how often the window is computed.
+		weight := 1 - math.Exp(-elapsed.Seconds()/writeRateSmoothingPeriod.Seconds())
+		aq.writeRate += weight * (instantRate - aq.writeRate)
+		aq.lastUpdate = now
+	}
+
+	window := aq.windowForRate(aq.writeRate)
+	quantizationWindowGauge.Set(window.Seconds())
+	quantizationWriteRateGauge.Set(aq.writeRate)
+	return window
+}
+
+func (aq *AdaptiveQuantization) windowForRate(writeRate float64) time.Duration {
+	fraction := math.Min(writeRate/aq.fullWindowWriteRate, 1)
+	ideal := aq.minimum + time.Duration(fraction*float64(aq.maximum-aq.minimum))
+	if ideal >= aq.maximum {
+		return aq.maximum
+	}
+
+	window := aq.minimum
+	for window*2 <= ideal {
+		window *= 2
+	}
+	return window
+}
@@ -0,0 +1,102 @@
+package revisions
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/pkg/datastore/revision"
+)
+
+func TestAdaptiveQuantizationWindowForRate(t *testing.T) {
+	aq, err := NewAdaptiveQuantization(100*time.Millisecond, 5*time.Second, 100)
+	require.NoError(t, err)
+
+	tests := []struct {
+		writeRate float64
+		expected  time.Duration
+	}{
+		{0, 100 * time.Millisecond},
+		{1, 100 * time.Millisecond},
+		{3, 200 * time.Millisecond},
+		{10, 400 * time.Millisecond},
+		{20, 800 * time.Millisecond},
+		{50, 1600 * time.Millisecond},
+		{80, 3200 * time.Millisecond},
+		{99, 3200 * time.Millisecond},
+		{100, 5 * time.Second},
+		{1000, 5 * time.Second},
+	}
+
+	for _, tc := range tests {
+		t.Run(fmt.Sprintf("%v", tc.writeRate), func(t *testing.T) {
+			require.Equal(t, tc.expected, aq.windowForRate(tc.writeRate))
+		})
+	}
+}
+
+func TestAdaptiveQuantizationFollowsWriteRate(t *testing.T) {
+	aq, err := NewAdaptiveQuantization(100*time.Millisecond, 5*time.Second, 100)
+	require.NoError(t, err)
+
+	mock := clock.NewMock()
+	aq.clockFn = mock
+	aq.lastUpdate = mock.Now()
+
+	require.Equal(t, 100*time.Millisecond, aq.Window())
+
+	// Sustained writes grow the window to the maximum.
+	for i := 0; i < 60; i++ {
+		for j := 0; j < 600; j++ {
+			aq.RecordWrite()
+		}
+		mock.Add(5 * time.Second)
+		aq.Window()
+	}
+	require.InDelta(t, 120, aq.writeRate, 1)
+	require.Equal(t, 5*time.Second, aq.Window())
+
+	// A single burst is averaged over the smoothing period.
+	aq.writeRate = 0
+	for j := 0; j < 500; j++ {
+		aq.RecordWrite()
+	}
+	mock.Add(time.Second)
+	require.Equal(t, 400*time.Millisecond, aq.Window())
+
+	// Without writes, the window shrinks back to the minimum.
+	mock.Add(10 * time.Minute)
+	require.Equal(t, 100*time.Millisecond, aq.Window())
+}
+
+func TestAdaptiveQuantizationInvalid(t *testing.T) {
+	_, err := NewAdaptiveQuantization(0, 5*time.Second, 100)
+	require.Error(t, err)
+
+	_, err = NewAdaptiveQuantization(10*time.Second, 5*time.Second, 100)
+	require.Error(t, err)
+
+	_, err = NewAdaptiveQuantization(100*time.Millisecond, 5*time.Second, 0)
+	require.Error(t, err)
+}
+
+func TestRemoteClockAdaptiveQuantization(t *testing.T) {
+	aq, err := NewAdaptiveQuantization(time.Second, 8*time.Second, 100)
+	require.NoError(t, err)
+
+	rcr := NewRemoteClockRevisions(24*time.Hour, 0, 0, 8*time.Second)
+	rcr.SetAdaptiveQuantization(aq)
+	rcr.SetNowFunc(func(ctx context.Context) (revision.Decimal, error) {
+		return revision.NewFromDecimal(decimal.NewFromInt(int64(10_500 * time.Millisecond))), nil
+	})
+
+	rev, validFor, err := rcr.optimizedRevisionFunc(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, revision.NewFromDecimal(decimal.NewFromInt(int64(10*time.Second))), rev)
+	require.Equal(t, 500*time.Millisecond, validFor)
+}
@@ -23,6 +23,7 @@ type RemoteClockRevisions struct {
 	nowFunc                RemoteNowFunction
 	followerReadDelayNanos int64
 	quantizationNanos      int64
+	adaptiveQuantization   *AdaptiveQuantization
 }
 
 // NewRemoteClockRevisions returns a RemoteClockRevisions for the given configuration
@@ -49,19 +50,35 @@ func (rcr *RemoteClockRevisions) optimizedRevisionFunc(ctx context.Context) (dat
 		return revision.NoRevision, 0, err
 	}
 
+	quantizationNanos := rcr.quantizationNanos
+	if rcr.adaptiveQuantization != nil {
+		quantizationNanos = rcr.adaptiveQuantization.Window().Nanoseconds()
+	}
+
 	delayedNow := nowHLC.IntPart() - rcr.followerReadDelayNanos
 	quantized := delayedNow
 	validForNanos := int64(0)
-	if rcr.quantizationNanos > 0 {
-		afterLastQuantization := delayedNow % rcr.quantizationNanos
+	if quantizationNanos > 0 {
+		afterLastQuantization := delayedNow % quantizationNanos
 		quantized -= afterLastQuantization
-		validForNanos = rcr.quantizationNanos - afterLastQuantization
+		validForNanos = quantizationNanos - afterLastQuantization
 	}
 	log.Ctx(ctx).Debug().Int64("readSkew", rcr.followerReadDelayNanos).Int64("totalSkew", nowHLC.IntPart()-quantized).Msg("revision skews")
 
 	return revision.NewFromDecimal(decimal.NewFromInt(quantized)), time.Duration(validForNanos) * time.Nanosecond, nil
 }
 
+// SetAdaptiveQuantization adapts the quantization of revisions to the write rate, with the
+// quantization given at construction as the longest window.
+func (rcr *RemoteClockRevisions) SetAdaptiveQuantization(adaptiveQuantization *AdaptiveQuantization) {
+	rcr.adaptiveQuantization = adaptiveQuantization
+}
+
+// RecordWrite records a write to the datastore, for adapting the quantization of revisions.
+func (rcr *RemoteClockRevisions) RecordWrite() {
+	rcr.adaptiveQuantization.RecordWrite()
+}
+
 // SetNowFunc sets the function used to determine the head revision
 func (rcr *RemoteClockRevisions) SetNowFunc(nowFunc RemoteNowFunction) {
 	rcr.nowFunc = nowFunc
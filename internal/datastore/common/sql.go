@@ -4,6 +4,8 @@ import (
 	"context"
 	"math"
 	"runtime"
+	"strings"
+	"time"
 
 	sq "github.com/Masterminds/squirrel"
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
@@ -32,6 +34,9 @@ var (
 	// ObjIDKey is a tracing attribute representing the resource object ID.
 	ObjIDKey = attribute.Key("authzed.com/spicedb/sql/objId")
 
+	// ObjIDPrefixKey is a tracing attribute representing a prefix of resource object IDs.
+	ObjIDPrefixKey = attribute.Key("authzed.com/spicedb/sql/objIdPrefix")
+
 	// SubNamespaceNameKey is a tracing attribute representing the subject object
 	// type.
 	SubNamespaceNameKey = attribute.Key("authzed.com/spicedb/sql/subNamespaceName")
@@ -44,6 +49,9 @@ var (
 	// ID.
 	SubObjectIDKey = attribute.Key("authzed.com/spicedb/sql/subObjectId")
 
+	// LabelKey is a tracing attribute representing a relationship label.
+	LabelKey = attribute.Key("authzed.com/spicedb/sql/label")
+
 	limitKey = attribute.Key("authzed.com/spicedb/sql/limit")
 
 	tracer = otel.Tracer("spicedb/internal/datastore/common")
@@ -59,6 +67,11 @@ type SchemaInformation struct {
 	ColUsersetObjectID  string
 	ColUsersetRelation  string
 	ColCaveatName       string
+	ColLabels           string
+
+	// MatchLabels returns a clause matching the rows whose labels, stored in the given column,
+	// include all of the given labels.
+	MatchLabels func(colLabels string, labels map[string]string) sq.Sqlizer
 }
 
 // SchemaQueryFilterer wraps a SchemaInformation and SelectBuilder to give an opinionated
@@ -67,6 +80,10 @@ type SchemaQueryFilterer struct {
 	schema           SchemaInformation
 	queryBuilder     sq.SelectBuilder
 	tracerAttributes []attribute.KeyValue
+
+	// filteredColumns are the columns which the query filters on with a condition that an
+	// index can serve, recorded for the index advisor.
+	filteredColumns []string
 }
 
 // NewSchemaQueryFilterer creates a new SchemaQueryFilterer object.
@@ -82,6 +99,7 @@ func NewSchemaQueryFilterer(schema SchemaInformation, initialQuery sq.SelectBuil
 func (sqf SchemaQueryFilterer) FilterToResourceType(resourceType string) SchemaQueryFilterer {
 	sqf.queryBuilder = sqf.queryBuilder.Where(sq.Eq{sqf.schema.ColNamespace: resourceType})
 	sqf.tracerAttributes = append(sqf.tracerAttributes, ObjNamespaceNameKey.String(resourceType))
+	sqf.filterOn(sqf.schema.ColNamespace)
 	return sqf
 }
 
@@ -90,6 +108,7 @@ func (sqf SchemaQueryFilterer) FilterToResourceType(resourceType string) SchemaQ
 func (sqf SchemaQueryFilterer) FilterToResourceID(objectID string) SchemaQueryFilterer {
 	sqf.queryBuilder = sqf.queryBuilder.Where(sq.Eq{sqf.schema.ColObjectID: objectID})
 	sqf.tracerAttributes = append(sqf.tracerAttributes, ObjIDKey.String(objectID))
+	sqf.filterOn(sqf.schema.ColObjectID)
 	return sqf
 }
 
@@ -128,14 +147,29 @@ func (sqf SchemaQueryFilterer) FilterToResourceIDs(resourceIds []string) (Schema
 	}
 
 	sqf.queryBuilder = sqf.queryBuilder.Where(inClause+")", args...)
+	sqf.filterOn(sqf.schema.ColObjectID)
 	return sqf, nil
 }
 
+// FilterToResourceIDPrefix returns a new SchemaQueryFilterer that is limited to resources whose
+// IDs start with the specified prefix.
+func (sqf SchemaQueryFilterer) FilterToResourceIDPrefix(prefix string) SchemaQueryFilterer {
+	sqf.queryBuilder = sqf.queryBuilder.Where(sq.Like{sqf.schema.ColObjectID: likeEscaper.Replace(prefix) + "%"})
+	sqf.tracerAttributes = append(sqf.tracerAttributes, ObjIDPrefixKey.String(prefix))
+	sqf.filterOn(sqf.schema.ColObjectID)
+	return sqf
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns, with the backslash which is the default
+// escape character of all of the SQL datastores.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
 // FilterToRelation returns a new SchemaQueryFilterer that is limited to resources with the
 // specified relation.
 func (sqf SchemaQueryFilterer) FilterToRelation(relation string) SchemaQueryFilterer {
 	sqf.queryBuilder = sqf.queryBuilder.Where(sq.Eq{sqf.schema.ColRelation: relation})
 	sqf.tracerAttributes = append(sqf.tracerAttributes, ObjRelationNameKey.String(relation))
+	sqf.filterOn(sqf.schema.ColRelation)
 	return sqf
 }
 
@@ -176,6 +210,10 @@ func (sqf SchemaQueryFilterer) FilterWithRelationshipsFilter(filter datastore.Re
 		sqf = sqf.FilterWithCaveatName(filter.OptionalCaveatName)
 	}
 
+	if len(filter.OptionalLabels) > 0 {
+		sqf = sqf.FilterWithLabels(filter.OptionalLabels)
+	}
+
 	return sqf, nil
 }
 
@@ -200,6 +238,7 @@ func (sqf SchemaQueryFilterer) FilterWithSubjectsSelectors(selectors ...datastor
 		if len(selector.OptionalSubjectType) > 0 {
 			selectorClause = append(selectorClause, sq.Eq{sqf.schema.ColUsersetNamespace: selector.OptionalSubjectType})
 			sqf.tracerAttributes = append(sqf.tracerAttributes, SubNamespaceNameKey.String(selector.OptionalSubjectType))
+			sqf.filterOn(sqf.schema.ColUsersetNamespace)
 		}
 
 		if len(selector.OptionalSubjectIds) > 0 {
@@ -227,6 +266,7 @@ func (sqf SchemaQueryFilterer) FilterWithSubjectsSelectors(selectors ...datastor
 			}
 
 			selectorClause = append(selectorClause, sq.Expr(inClause+")", args...))
+			sqf.filterOn(sqf.schema.ColUsersetObjectID)
 		}
 
 		if !selector.RelationFilter.IsEmpty() {
@@ -256,6 +296,7 @@ func (sqf SchemaQueryFilterer) FilterWithSubjectsSelectors(selectors ...datastor
 
 					selectorClause = append(selectorClause, orClause)
 				}
+				sqf.filterOn(sqf.schema.ColUsersetRelation)
 			}
 		}
 
@@ -271,10 +312,12 @@ func (sqf SchemaQueryFilterer) FilterWithSubjectsSelectors(selectors ...datastor
 func (sqf SchemaQueryFilterer) FilterToSubjectFilter(filter *v1.SubjectFilter) SchemaQueryFilterer {
 	sqf.queryBuilder = sqf.queryBuilder.Where(sq.Eq{sqf.schema.ColUsersetNamespace: filter.SubjectType})
 	sqf.tracerAttributes = append(sqf.tracerAttributes, SubNamespaceNameKey.String(filter.SubjectType))
+	sqf.filterOn(sqf.schema.ColUsersetNamespace)
 
 	if filter.OptionalSubjectId != "" {
 		sqf.queryBuilder = sqf.queryBuilder.Where(sq.Eq{sqf.schema.ColUsersetObjectID: filter.OptionalSubjectId})
 		sqf.tracerAttributes = append(sqf.tracerAttributes, SubObjectIDKey.String(filter.OptionalSubjectId))
+		sqf.filterOn(sqf.schema.ColUsersetObjectID)
 	}
 
 	if filter.OptionalRelation != nil {
@@ -282,6 +325,7 @@ func (sqf SchemaQueryFilterer) FilterToSubjectFilter(filter *v1.SubjectFilter) S
 
 		sqf.queryBuilder = sqf.queryBuilder.Where(sq.Eq{sqf.schema.ColUsersetRelation: dsRelationName})
 		sqf.tracerAttributes = append(sqf.tracerAttributes, SubRelationNameKey.String(dsRelationName))
+		sqf.filterOn(sqf.schema.ColUsersetRelation)
 	}
 
 	return sqf
@@ -290,6 +334,17 @@ func (sqf SchemaQueryFilterer) FilterToSubjectFilter(filter *v1.SubjectFilter) S
 func (sqf SchemaQueryFilterer) FilterWithCaveatName(caveatName string) SchemaQueryFilterer {
 	sqf.queryBuilder = sqf.queryBuilder.Where(sq.Eq{sqf.schema.ColCaveatName: caveatName})
 	sqf.tracerAttributes = append(sqf.tracerAttributes, CaveatNameKey.String(caveatName))
+	sqf.filterOn(sqf.schema.ColCaveatName)
+	return sqf
+}
+
+// FilterWithLabels returns a new SchemaQueryFilterer that is limited to relationships with all of
+// the specified labels.
+func (sqf SchemaQueryFilterer) FilterWithLabels(labels map[string]string) SchemaQueryFilterer {
+	sqf.queryBuilder = sqf.queryBuilder.Where(sqf.schema.MatchLabels(sqf.schema.ColLabels, labels))
+	for key, value := range labels {
+		sqf.tracerAttributes = append(sqf.tracerAttributes, LabelKey.String(key+"="+value))
+	}
 	return sqf
 }
 
@@ -311,10 +366,18 @@ func (sqf SchemaQueryFilterer) filterToUsersets(usersets []*core.ObjectAndRelati
 	}
 
 	sqf.queryBuilder = sqf.queryBuilder.Where(orClause)
+	sqf.filterOn(sqf.schema.ColUsersetNamespace, sqf.schema.ColUsersetObjectID, sqf.schema.ColUsersetRelation)
 
 	return sqf
 }
 
+// filterOn records that the query filters on the columns. The recorded columns are copied, as
+// filterers derived from the same filterer share them.
+func (sqf *SchemaQueryFilterer) filterOn(columns ...string) {
+	recorded := len(sqf.filteredColumns)
+	sqf.filteredColumns = append(sqf.filteredColumns[:recorded:recorded], columns...)
+}
+
 // Limit returns a new SchemaQueryFilterer which is limited to the specified number of results.
 func (sqf SchemaQueryFilterer) limit(limit uint64) SchemaQueryFilterer {
 	sqf.queryBuilder = sqf.queryBuilder.Limit(limit)
@@ -326,6 +389,12 @@ func (sqf SchemaQueryFilterer) limit(limit uint64) SchemaQueryFilterer {
 type TupleQuerySplitter struct {
 	Executor         ExecuteQueryFunc
 	UsersetBatchSize uint16
+
+	// QueryLogger, if set, logs a sample of the executed queries.
+	QueryLogger *QueryLogger
+
+	// IndexAdvisor, if set, records the columns filtered on by the executed queries.
+	IndexAdvisor *IndexAdvisor
 }
 
 // SplitAndExecuteQuery is used to split up the usersets in a very large query and execute
@@ -360,7 +429,11 @@ func (tqs TupleQuerySplitter) SplitAndExecuteQuery(
 			return nil, err
 		}
 
+		tqs.IndexAdvisor.RecordQuery(toExecute.filteredColumns)
+
+		start := time.Now()
 		queryTuples, err := tqs.Executor(ctx, sql, args)
+		tqs.QueryLogger.LogQuery(ctx, sql, args, len(queryTuples), time.Since(start), err)
 		if err != nil {
 			return nil, err
 		}
@@ -44,6 +44,14 @@ func TestSchemaQueryFilterer(t *testing.T) {
 			"SELECT * WHERE object_id IN (?, ?)",
 			[]any{"someresourceid", "anotherresourceid"},
 		},
+		{
+			"resource ID prefix filter",
+			func(filterer SchemaQueryFilterer) SchemaQueryFilterer {
+				return filterer.FilterToResourceIDPrefix(`team_a/50%`)
+			},
+			"SELECT * WHERE object_id LIKE ?",
+			[]any{`team\_a/50\%%`},
+		},
 		{
 			"resource type filter",
 			func(filterer SchemaQueryFilterer) SchemaQueryFilterer {
@@ -39,11 +39,12 @@ var (
 )
 
 const (
-	Engine            = "cockroachdb"
-	tableNamespace    = "namespace_config"
-	tableTuple        = "relation_tuple"
-	tableTransactions = "transactions"
-	tableCaveat       = "caveat"
+	Engine             = "cockroachdb"
+	tableNamespace     = "namespace_config"
+	tableTuple         = "relation_tuple"
+	tableTransactions  = "transactions"
+	tableCaveat        = "caveat"
+	tableSchemaVersion = "schema_version"
 
 	colNamespace         = "namespace"
 	colConfig            = "serialized_config"
@@ -58,6 +59,8 @@ const (
 	colCaveatDefinition  = "definition"
 	colCaveatContextName = "caveat_name"
 	colCaveatContext     = "caveat_context"
+	colLabels            = "labels"
+	colSchemaVersion     = "version"
 
 	errUnableToInstantiate = "unable to instantiate datastore: %w"
 	errRevision            = "unable to find revision: %w"
@@ -74,6 +77,18 @@ func newCRDBDatastore(url string, options ...Option) (datastore.Datastore, error
 		return nil, fmt.Errorf(errUnableToInstantiate, err)
 	}
 
+	var adaptiveQuantization *revisions.AdaptiveQuantization
+	if config.adaptiveQuantization {
+		adaptiveQuantization, err = revisions.NewAdaptiveQuantization(
+			config.minRevisionQuantization,
+			config.revisionQuantization,
+			config.fullQuantizationWriteRate,
+		)
+		if err != nil {
+			return nil, fmt.Errorf(errUnableToInstantiate, err)
+		}
+	}
+
 	poolConfig, err := pgxpool.ParseConfig(url)
 	if err != nil {
 		return nil, fmt.Errorf(errUnableToInstantiate, err)
@@ -81,11 +96,18 @@ func newCRDBDatastore(url string, options ...Option) (datastore.Datastore, error
 
 	configurePool(config, poolConfig)
 
+	authenticator, err := pgxcommon.NewAuthenticator(poolConfig.ConnConfig, config.auth)
+	if err != nil {
+		return nil, fmt.Errorf(errUnableToInstantiate, err)
+	}
+	poolConfig.BeforeConnect = authenticator.BeforeConnect
+
 	initCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
 	pool, err := pgxpool.ConnectConfig(initCtx, poolConfig)
 	if err != nil {
+		_ = authenticator.Close()
 		return nil, fmt.Errorf(errUnableToInstantiate, err)
 	}
 
@@ -108,6 +130,11 @@ func newCRDBDatastore(url string, options ...Option) (datastore.Datastore, error
 		if err := common.RegisterGCMetrics(); err != nil {
 			return nil, fmt.Errorf(errUnableToInstantiate, err)
 		}
+		if config.adaptiveQuantization {
+			if err := revisions.RegisterAdaptiveQuantizationMetrics(); err != nil {
+				return nil, fmt.Errorf(errUnableToInstantiate, err)
+			}
+		}
 	}
 
 	clusterTTLNanos, err := readClusterTTLNanos(initCtx, pool)
@@ -158,12 +185,15 @@ func newCRDBDatastore(url string, options ...Option) (datastore.Datastore, error
 		config.watchBufferLength,
 		keyer,
 		config.splitAtUsersetCount,
+		common.NewQueryLogger(config.queryLogSampleRate),
 		executeWithMaxRetries(config.maxRetries),
 		config.disableStats,
 		changefeedQuery,
+		authenticator,
 	}
 
 	ds.RemoteClockRevisions.SetNowFunc(ds.headRevisionInternal)
+	ds.RemoteClockRevisions.SetAdaptiveQuantization(adaptiveQuantization)
 
 	return ds, nil
 }
@@ -215,10 +245,12 @@ type crdbDatastore struct {
 	watchBufferLength uint16
 	writeOverlapKeyer overlapKeyer
 	usersetBatchSize  uint16
+	queryLogger       *common.QueryLogger
 	execute           executeTxRetryFunc
 	disableStats      bool
 
 	beginChangefeedQuery string
+	authenticator        *pgxcommon.Authenticator
 }
 
 func (cds *crdbDatastore) SnapshotReader(rev datastore.Revision) datastore.Reader {
@@ -250,6 +282,7 @@ func (cds *crdbDatastore) SnapshotReader(rev datastore.Revision) datastore.Reade
 	querySplitter := common.TupleQuerySplitter{
 		Executor:         pgxcommon.NewPGXExecutor(createTxFunc),
 		UsersetBatchSize: cds.usersetBatchSize,
+		QueryLogger:      cds.queryLogger,
 	}
 
 	return &crdbReader{createTxFunc, querySplitter, noOverlapKeyer, nil, cds.execute}
@@ -271,6 +304,7 @@ func (cds *crdbDatastore) ReadWriteTx(
 			querySplitter := common.TupleQuerySplitter{
 				Executor:         pgxcommon.NewPGXExecutor(longLivedTx),
 				UsersetBatchSize: cds.usersetBatchSize,
+				QueryLogger:      cds.queryLogger,
 			}
 
 			rwt := &crdbReadWriteTXN{
@@ -318,6 +352,7 @@ func (cds *crdbDatastore) ReadWriteTx(
 		return datastore.NoRevision, err
 	}
 
+	cds.RecordWrite()
 	return commitTimestamp, nil
 }
 
@@ -343,13 +378,28 @@ func (cds *crdbDatastore) IsReady(ctx context.Context) (bool, error) {
 
 func (cds *crdbDatastore) Close() error {
 	cds.pool.Close()
-	return nil
+	return cds.authenticator.Close()
 }
 
 func (cds *crdbDatastore) HeadRevision(ctx context.Context) (datastore.Revision, error) {
 	return cds.headRevisionInternal(ctx)
 }
 
+// RevisionAtTime returns the revision of the time itself, as the cluster can read at any hybrid
+// logical clock timestamp.
+func (cds *crdbDatastore) RevisionAtTime(ctx context.Context, at time.Time) (datastore.Revision, error) {
+	return revisionFromTimestamp(at), nil
+}
+
+// RevisionTime returns the wall clock time of the hybrid logical clock timestamp of the revision.
+func (cds *crdbDatastore) RevisionTime(ctx context.Context, revisionRaw datastore.Revision) (time.Time, error) {
+	r, ok := revisionRaw.(revision.Decimal)
+	if !ok {
+		return time.Time{}, datastore.NewInvalidRevisionErr(revisionRaw, datastore.CouldNotDetermineRevision)
+	}
+	return time.Unix(0, r.IntPart()).UTC(), nil
+}
+
 func (cds *crdbDatastore) headRevisionInternal(ctx context.Context) (revision.Decimal, error) {
 	var hlcNow revision.Decimal
 	err := cds.execute(ctx, func(ctx context.Context) error {
@@ -0,0 +1,138 @@
+package crdb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
+)
+
+const (
+	tableDeletedRelationship        = "deleted_relationship"
+	colDeletedRelationshipDeletion  = "deletion_id"
+	colDeletedRelationshipPosition  = "position"
+	colDeletedRelationshipDeletedAt = "deleted_at"
+	colDeletedRelationshipData      = "data"
+
+	// retainBatchSize is the number of deleted relationships inserted per statement.
+	retainBatchSize = 1000
+)
+
+var (
+	insertDeletedRelationships = psql.Insert(tableDeletedRelationship).Columns(
+		colDeletedRelationshipDeletion,
+		colDeletedRelationshipPosition,
+		colDeletedRelationshipDeletedAt,
+		colDeletedRelationshipData,
+	)
+	readDeletedRelationships   = psql.Select(colDeletedRelationshipData).From(tableDeletedRelationship)
+	deleteDeletedRelationships = psql.Delete(tableDeletedRelationship)
+)
+
+// RetainDeletedRelationships stores the relationships removed by a deletion.
+func (cds *crdbDatastore) RetainDeletedRelationships(ctx context.Context, deletionID string, relationships [][]byte) error {
+	forgetSQL, forgetArgs, err := deleteDeletedRelationships.Where(sq.Eq{colDeletedRelationshipDeletion: deletionID}).ToSql()
+	if err != nil {
+		return fmt.Errorf("unable to prepare forget deleted relationships sql: %w", err)
+	}
+
+	if err := cds.pool.BeginTxFunc(ctx, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, forgetSQL, forgetArgs...); err != nil {
+			return err
+		}
+
+		for start := 0; start < len(relationships); start += retainBatchSize {
+			end := start + retainBatchSize
+			if end > len(relationships) {
+				end = len(relationships)
+			}
+
+			insert := insertDeletedRelationships
+			for position := start; position < end; position++ {
+				insert = insert.Values(deletionID, position, sq.Expr("now()"), relationships[position])
+			}
+
+			sql, args, err := insert.ToSql()
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx, sql, args...); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("unable to retain deleted relationships: %w", err)
+	}
+	return nil
+}
+
+// ReadDeletedRelationships returns the relationships retained for a deletion.
+func (cds *crdbDatastore) ReadDeletedRelationships(ctx context.Context, deletionID string) ([][]byte, error) {
+	sql, args, err := readDeletedRelationships.
+		Where(sq.Eq{colDeletedRelationshipDeletion: deletionID}).
+		OrderBy(colDeletedRelationshipPosition).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("unable to prepare read deleted relationships sql: %w", err)
+	}
+
+	rows, err := cds.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read deleted relationships: %w", err)
+	}
+	defer rows.Close()
+
+	var relationships [][]byte
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("unable to read deleted relationship: %w", err)
+		}
+		relationships = append(relationships, data)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read deleted relationships: %w", err)
+	}
+
+	if len(relationships) == 0 {
+		return nil, common.ErrDeletionNotFound
+	}
+	return relationships, nil
+}
+
+// ForgetDeletedRelationships removes the relationships retained for a deletion.
+func (cds *crdbDatastore) ForgetDeletedRelationships(ctx context.Context, deletionID string) error {
+	sql, args, err := deleteDeletedRelationships.Where(sq.Eq{colDeletedRelationshipDeletion: deletionID}).ToSql()
+	if err != nil {
+		return fmt.Errorf("unable to prepare forget deleted relationships sql: %w", err)
+	}
+
+	if _, err := cds.pool.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("unable to forget deleted relationships: %w", err)
+	}
+	return nil
+}
+
+// PurgeDeletedRelationships removes the relationships retained for longer than the retention
+// period.
+func (cds *crdbDatastore) PurgeDeletedRelationships(ctx context.Context, retention time.Duration) (int64, error) {
+	sql, args, err := deleteDeletedRelationships.
+		Where(sq.Expr(colDeletedRelationshipDeletedAt+" < now() - ?::interval", fmt.Sprintf("%d microseconds", retention.Microseconds()))).
+		ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("unable to prepare purge deleted relationships sql: %w", err)
+	}
+
+	result, err := cds.pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return 0, fmt.Errorf("unable to purge deleted relationships: %w", err)
+	}
+	return result.RowsAffected(), nil
+}
+
+var _ common.DeletedRelationshipStore = &crdbDatastore{}
@@ -0,0 +1,76 @@
+package crdb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
+)
+
+const (
+	tableLease        = "lease"
+	colLeaseName      = "name"
+	colLeaseHolder    = "holder"
+	colLeaseExpiresAt = "expires_at"
+)
+
+var (
+	renewLease   = psql.Update(tableLease)
+	insertLease  = psql.Insert(tableLease).Columns(colLeaseName, colLeaseHolder, colLeaseExpiresAt).Suffix("ON CONFLICT DO NOTHING")
+	releaseLease = psql.Delete(tableLease)
+)
+
+func leaseExpiry(duration time.Duration) sq.Sqlizer {
+	return sq.Expr("now() + ?::interval", fmt.Sprintf("%d microseconds", duration.Microseconds()))
+}
+
+// AcquireLease takes or renews the named lease for the holder. The lease is first updated if it
+// is held by the holder or has expired, and otherwise inserted if it does not exist.
+func (cds *crdbDatastore) AcquireLease(ctx context.Context, name, holder string, duration time.Duration) (bool, error) {
+	sql, args, err := renewLease.
+		Set(colLeaseHolder, holder).
+		Set(colLeaseExpiresAt, leaseExpiry(duration)).
+		Where(sq.Eq{colLeaseName: name}).
+		Where(sq.Or{sq.Eq{colLeaseHolder: holder}, sq.Expr(colLeaseExpiresAt + " < now()")}).
+		ToSql()
+	if err != nil {
+		return false, fmt.Errorf("unable to prepare renew lease sql: %w", err)
+	}
+
+	result, err := cds.pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return false, fmt.Errorf("unable to renew lease: %w", err)
+	}
+	if result.RowsAffected() == 1 {
+		return true, nil
+	}
+
+	sql, args, err = insertLease.Values(name, holder, leaseExpiry(duration)).ToSql()
+	if err != nil {
+		return false, fmt.Errorf("unable to prepare insert lease sql: %w", err)
+	}
+
+	result, err = cds.pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return false, fmt.Errorf("unable to insert lease: %w", err)
+	}
+	return result.RowsAffected() == 1, nil
+}
+
+// ReleaseLease frees the named lease, if it is held by the holder.
+func (cds *crdbDatastore) ReleaseLease(ctx context.Context, name, holder string) error {
+	sql, args, err := releaseLease.Where(sq.Eq{colLeaseName: name, colLeaseHolder: holder}).ToSql()
+	if err != nil {
+		return fmt.Errorf("unable to prepare release lease sql: %w", err)
+	}
+
+	if _, err := cds.pool.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("unable to release lease: %w", err)
+	}
+	return nil
+}
+
+var _ common.LeaseStore = &crdbDatastore{}
@@ -90,4 +90,24 @@ func (apd *CRDBDriver) WriteVersion(ctx context.Context, tx pgx.Tx, version, rep
 	return nil
 }
 
-var _ migrate.Driver[*pgx.Conn, pgx.Tx] = &CRDBDriver{}
+// ReadProgress returns the recorded progress of the online migration to the version.
+func (apd *CRDBDriver) ReadProgress(ctx context.Context, version string) (*migrate.Progress, error) {
+	return pgxcommon.ReadMigrationProgress(ctx, apd.db, version)
+}
+
+// WriteProgress records the progress of the online migration to the version.
+func (apd *CRDBDriver) WriteProgress(ctx context.Context, version string, progress migrate.Progress) error {
+	return pgxcommon.WriteMigrationProgress(ctx, apd.db, version, progress)
+}
+
+// CheckPermissions returns whether the connected user has the privileges required to run
+// migrations.
+func (apd *CRDBDriver) CheckPermissions(ctx context.Context) ([]migrate.Permission, error) {
+	return pgxcommon.CheckMigrationPermissions(ctx, apd.db)
+}
+
+var (
+	_ migrate.Driver[*pgx.Conn, pgx.Tx] = &CRDBDriver{}
+	_ migrate.ProgressDriver            = &CRDBDriver{}
+	_ migrate.PreflightDriver           = &CRDBDriver{}
+)
@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	addRelationshipLabels = `ALTER TABLE relation_tuple
+		ADD COLUMN labels JSONB;`
+
+	addRelationshipLabelsIndex = `CREATE INVERTED INDEX IF NOT EXISTS ix_relation_tuple_labels
+		ON relation_tuple (labels);`
+)
+
+func init() {
+	err := CRDBMigrations.Register("add-relationship-labels", "add-caveats", addRelationshipLabelsFunc, noAtomicMigration)
+	if err != nil {
+		panic("failed to register migration: " + err.Error())
+	}
+}
+
+func addRelationshipLabelsFunc(ctx context.Context, conn *pgx.Conn) error {
+	if _, err := conn.Exec(ctx, addRelationshipLabels); err != nil {
+		return err
+	}
+	if _, err := conn.Exec(ctx, addRelationshipLabelsIndex); err != nil {
+		return err
+	}
+	return nil
+}
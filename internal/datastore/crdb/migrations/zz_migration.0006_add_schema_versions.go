@@ -0,0 +1,26 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+)
+
+const createSchemaVersionTable = `CREATE TABLE schema_version (
+		version INT8 NOT NULL,
+		definition BYTEA NOT NULL,
+		timestamp TIMESTAMP WITHOUT TIME ZONE DEFAULT now() NOT NULL,
+		CONSTRAINT pk_schema_version PRIMARY KEY (version)
+	);`
+
+func init() {
+	err := CRDBMigrations.Register("add-schema-versions", "add-relationship-labels", addSchemaVersionsFunc, noAtomicMigration)
+	if err != nil {
+		panic("failed to register migration: " + err.Error())
+	}
+}
+
+func addSchemaVersionsFunc(ctx context.Context, conn *pgx.Conn) error {
+	_, err := conn.Exec(ctx, createSchemaVersionTable)
+	return err
+}
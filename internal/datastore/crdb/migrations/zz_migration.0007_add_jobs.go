@@ -0,0 +1,26 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+)
+
+const createJobTable = `CREATE TABLE job (
+		id VARCHAR NOT NULL,
+		version INT8 NOT NULL,
+		data BYTEA NOT NULL,
+		CONSTRAINT pk_job PRIMARY KEY (id)
+	);`
+
+func init() {
+	err := CRDBMigrations.Register("add-jobs", "add-schema-versions", addJobsFunc, noAtomicMigration)
+	if err != nil {
+		panic("failed to register migration: " + err.Error())
+	}
+}
+
+func addJobsFunc(ctx context.Context, conn *pgx.Conn) error {
+	_, err := conn.Exec(ctx, createJobTable)
+	return err
+}
@@ -0,0 +1,26 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+)
+
+const createLeaseTable = `CREATE TABLE lease (
+		name VARCHAR NOT NULL,
+		holder VARCHAR NOT NULL,
+		expires_at TIMESTAMPTZ NOT NULL,
+		CONSTRAINT pk_lease PRIMARY KEY (name)
+	);`
+
+func init() {
+	err := CRDBMigrations.Register("add-leases", "add-jobs", addLeasesFunc, noAtomicMigration)
+	if err != nil {
+		panic("failed to register migration: " + err.Error())
+	}
+}
+
+func addLeasesFunc(ctx context.Context, conn *pgx.Conn) error {
+	_, err := conn.Exec(ctx, createLeaseTable)
+	return err
+}
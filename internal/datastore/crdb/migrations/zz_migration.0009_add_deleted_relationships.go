@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+)
+
+const createDeletedRelationshipTable = `CREATE TABLE deleted_relationship (
+		deletion_id VARCHAR NOT NULL,
+		position INT8 NOT NULL,
+		deleted_at TIMESTAMPTZ NOT NULL,
+		data BYTEA NOT NULL,
+		CONSTRAINT pk_deleted_relationship PRIMARY KEY (deletion_id, position),
+		INDEX ix_deleted_relationship_deleted_at (deleted_at)
+	);`
+
+func init() {
+	err := CRDBMigrations.Register("add-deleted-relationships", "add-leases", addDeletedRelationshipsFunc, noAtomicMigration)
+	if err != nil {
+		panic("failed to register migration: " + err.Error())
+	}
+}
+
+func addDeletedRelationshipsFunc(ctx context.Context, conn *pgx.Conn) error {
+	_, err := conn.Exec(ctx, createDeletedRelationshipTable)
+	return err
+}
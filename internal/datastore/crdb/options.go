@@ -3,6 +3,8 @@ package crdb
 import (
 	"fmt"
 	"time"
+
+	pgxcommon "github.com/authzed/spicedb/internal/datastore/postgres/common"
 )
 
 type crdbOptions struct {
@@ -14,16 +16,22 @@ type crdbOptions struct {
 
 	watchBufferLength           uint16
 	revisionQuantization        time.Duration
+	adaptiveQuantization        bool
+	minRevisionQuantization     time.Duration
+	fullQuantizationWriteRate   float64
 	followerReadDelay           time.Duration
 	maxRevisionStalenessPercent float64
 	gcWindow                    time.Duration
 	maxRetries                  uint8
 	splitAtUsersetCount         uint16
+	queryLogSampleRate          float64
 	overlapStrategy             string
 	overlapKey                  string
 	disableStats                bool
 
 	enablePrometheusStats bool
+
+	auth pgxcommon.AuthConfig
 }
 
 const (
@@ -34,6 +42,8 @@ const (
 	overlapStrategyInsecure = "insecure"
 
 	defaultRevisionQuantization        = 5 * time.Second
+	defaultMinRevisionQuantization     = 100 * time.Millisecond
+	defaultFullQuantizationWriteRate   = 100
 	defaultFollowerReadDelay           = 0 * time.Second
 	defaultMaxRevisionStalenessPercent = 0.1
 	defaultWatchBufferLength           = 128
@@ -52,6 +62,8 @@ type Option func(*crdbOptions)
 
 func generateConfig(options []Option) (crdbOptions, error) {
 	computed := crdbOptions{
+		minRevisionQuantization:     defaultMinRevisionQuantization,
+		fullQuantizationWriteRate:   defaultFullQuantizationWriteRate,
 		gcWindow:                    24 * time.Hour,
 		watchBufferLength:           defaultWatchBufferLength,
 		revisionQuantization:        defaultRevisionQuantization,
@@ -91,6 +103,17 @@ func SplitAtUsersetCount(splitAtUsersetCount uint16) Option {
 	}
 }
 
+// QueryLogSampleRate is the fraction, between 0 and 1, of the relationship queries
+// which are logged with their normalized statement, number of parameters, number of
+// rows returned and duration.
+//
+// This defaults to 0, logging no queries.
+func QueryLogSampleRate(sampleRate float64) Option {
+	return func(po *crdbOptions) {
+		po.queryLogSampleRate = sampleRate
+	}
+}
+
 // ConnHealthCheckInterval is the frequency at which both idle and max lifetime connections
 // are checked, and also the frequency at which the minimum number of connections is
 // checked. This happens asynchronously.
@@ -169,6 +192,37 @@ func RevisionQuantization(bucketSize time.Duration) Option {
 	}
 }
 
+// AdaptiveRevisionQuantization adapts the revision quantization window to the rate of writes,
+// from MinRevisionQuantization when there are no writes up to RevisionQuantization at
+// FullQuantizationWriteRate.
+//
+// This defaults to false.
+func AdaptiveRevisionQuantization(enabled bool) Option {
+	return func(po *crdbOptions) {
+		po.adaptiveQuantization = enabled
+	}
+}
+
+// MinRevisionQuantization is the shortest revision quantization window used when adapting it
+// to the rate of writes.
+//
+// This defaults to 100 milliseconds.
+func MinRevisionQuantization(minimum time.Duration) Option {
+	return func(po *crdbOptions) {
+		po.minRevisionQuantization = minimum
+	}
+}
+
+// FullQuantizationWriteRate is the number of writes per second at and above which the full
+// revision quantization window is used when adapting it to the rate of writes.
+//
+// This defaults to 100.
+func FullQuantizationWriteRate(writesPerSecond float64) Option {
+	return func(po *crdbOptions) {
+		po.fullQuantizationWriteRate = writesPerSecond
+	}
+}
+
 // FollowerReadDelay is the time delay to apply to enable historial reads.
 //
 // This value defaults to 0 seconds.
@@ -240,3 +294,44 @@ func WithEnablePrometheusStats(enablePrometheusStats bool) Option {
 		po.enablePrometheusStats = enablePrometheusStats
 	}
 }
+
+// ConnAuthMethod is the method by which connections obtain their password: "password" for the
+// password of the connection URI, "aws-iam" for AWS RDS IAM authentication tokens or "gcp-iam"
+// for GCP Cloud SQL IAM access tokens. Tokens are refreshed automatically.
+//
+// This value defaults to "password".
+func ConnAuthMethod(method string) Option {
+	return func(po *crdbOptions) {
+		po.auth.Method = pgxcommon.AuthMethod(method)
+	}
+}
+
+// ConnAWSRegion is the region of the database for AWS RDS IAM authentication.
+//
+// This value defaults to the region of the environment.
+func ConnAWSRegion(region string) Option {
+	return func(po *crdbOptions) {
+		po.auth.AWSRegion = region
+	}
+}
+
+// ConnPasswordPath is the path of a file containing the password of connections, which is
+// reloaded whenever the file changes. It cannot be combined with IAM authentication.
+//
+// By default, the password of the connection URI, if any, is used.
+func ConnPasswordPath(path string) Option {
+	return func(po *crdbOptions) {
+		po.auth.PasswordPath = path
+	}
+}
+
+// ConnClientCertificate is the client certificate and key presented to the database, which are
+// reloaded whenever their files change.
+//
+// By default, only the certificate of the connection URI, if any, is presented.
+func ConnClientCertificate(certPath, keyPath string) Option {
+	return func(po *crdbOptions) {
+		po.auth.ClientCertPath = certPath
+		po.auth.ClientKeyPath = keyPath
+	}
+}
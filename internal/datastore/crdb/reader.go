@@ -33,6 +33,7 @@ var (
 		colUsersetRelation,
 		colCaveatContextName,
 		colCaveatContext,
+		colLabels,
 	).From(tableTuple)
 
 	schema = common.SchemaInformation{
@@ -43,6 +44,8 @@ var (
 		ColUsersetObjectID:  colUsersetObjectID,
 		ColUsersetRelation:  colUsersetRelation,
 		ColCaveatName:       colCaveatContextName,
+		ColLabels:           colLabels,
+		MatchLabels:         pgxcommon.MatchLabels,
 	}
 )
 
@@ -178,6 +181,10 @@ func (cr *crdbReader) ReverseQueryRelationships(
 			FilterToRelation(queryOpts.ResRelation.Relation)
 	}
 
+	if queryOpts.ResIDPrefix != "" {
+		qBuilder = qBuilder.FilterToResourceIDPrefix(queryOpts.ResIDPrefix)
+	}
+
 	err = cr.execute(ctx, func(ctx context.Context) error {
 		iter, err = cr.querySplitter.SplitAndExecuteQuery(
 			ctx,
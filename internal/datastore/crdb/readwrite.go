@@ -47,7 +47,7 @@ type crdbReadWriteTXN struct {
 
 var (
 	upsertTupleSuffix = fmt.Sprintf(
-		"ON CONFLICT (%s,%s,%s,%s,%s,%s) DO UPDATE SET %s = now(), %s = excluded.%s, %s = excluded.%s",
+		"ON CONFLICT (%s,%s,%s,%s,%s,%s) DO UPDATE SET %s = now(), %s = excluded.%s, %s = excluded.%s, %s = excluded.%s",
 		colNamespace,
 		colObjectID,
 		colRelation,
@@ -59,6 +59,8 @@ var (
 		colCaveatContextName,
 		colCaveatContext,
 		colCaveatContext,
+		colLabels,
+		colLabels,
 	)
 
 	queryWriteTuple = psql.Insert(tableTuple).Columns(
@@ -70,6 +72,7 @@ var (
 		colUsersetRelation,
 		colCaveatContextName,
 		colCaveatContext,
+		colLabels,
 	)
 
 	queryTouchTuple = queryWriteTuple.Suffix(upsertTupleSuffix)
@@ -118,6 +121,7 @@ func (rwt *crdbReadWriteTXN) WriteRelationships(ctx context.Context, mutations [
 				rel.Subject.Relation,
 				caveatName,
 				caveatContext,
+				rel.Labels,
 			)
 			bulkTouchCount++
 		case core.RelationTupleUpdate_CREATE:
@@ -131,6 +135,7 @@ func (rwt *crdbReadWriteTXN) WriteRelationships(ctx context.Context, mutations [
 				rel.Subject.Relation,
 				caveatName,
 				caveatContext,
+				rel.Labels,
 			)
 			bulkWriteCount++
 		case core.RelationTupleUpdate_DELETE:
@@ -0,0 +1,97 @@
+package crdb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v4"
+
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+)
+
+var (
+	writeSchemaVersion  = psql.Insert(tableSchemaVersion).Columns(colSchemaVersion, colCaveatDefinition)
+	listSchemaVersions  = psql.Select(colCaveatDefinition).From(tableSchemaVersion).OrderBy(colSchemaVersion)
+	latestSchemaVersion = psql.Select(fmt.Sprintf("COALESCE(MAX(%s), 0)", colSchemaVersion)).From(tableSchemaVersion)
+)
+
+const (
+	errWriteSchemaVersion  = "unable to write schema version: %w"
+	errListSchemaVersions  = "unable to list schema versions: %w"
+	errLatestSchemaVersion = "unable to find latest schema version: %w"
+)
+
+func (cr *crdbReader) ListSchemaVersions(ctx context.Context) ([]*core.SchemaVersion, error) {
+	sql, args, err := listSchemaVersions.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf(errListSchemaVersions, err)
+	}
+
+	var allDefinitionBytes [][]byte
+	err = cr.executeWithTx(ctx, func(ctx context.Context, tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, sql, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var defBytes []byte
+			if err := rows.Scan(&defBytes); err != nil {
+				return err
+			}
+			allDefinitionBytes = append(allDefinitionBytes, defBytes)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, fmt.Errorf(errListSchemaVersions, err)
+	}
+
+	versions := make([]*core.SchemaVersion, 0, len(allDefinitionBytes))
+	for _, defBytes := range allDefinitionBytes {
+		version := &core.SchemaVersion{}
+		if err := version.UnmarshalVT(defBytes); err != nil {
+			return nil, fmt.Errorf(errListSchemaVersions, err)
+		}
+		versions = append(versions, version)
+	}
+	return versions, nil
+}
+
+func (cr *crdbReader) LatestSchemaVersion(ctx context.Context) (uint64, error) {
+	sql, args, err := latestSchemaVersion.ToSql()
+	if err != nil {
+		return 0, fmt.Errorf(errLatestSchemaVersion, err)
+	}
+
+	var latest int64
+	if err := cr.executeWithTx(ctx, func(ctx context.Context, tx pgx.Tx) error {
+		return tx.QueryRow(ctx, sql, args...).Scan(&latest)
+	}); err != nil {
+		return 0, fmt.Errorf(errLatestSchemaVersion, err)
+	}
+	return uint64(latest), nil
+}
+
+func (rwt *crdbReadWriteTXN) WriteSchemaVersion(ctx context.Context, version *core.SchemaVersion) error {
+	definitionBytes, err := version.MarshalVT()
+	if err != nil {
+		return fmt.Errorf(errWriteSchemaVersion, err)
+	}
+
+	sql, args, err := writeSchemaVersion.Values(version.Version, definitionBytes).ToSql()
+	if err != nil {
+		return fmt.Errorf(errWriteSchemaVersion, err)
+	}
+	return rwt.executeWithTx(ctx, func(ctx context.Context, tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, sql, args...); err != nil {
+			if sqlErrorCode(ctx, err) == crdbUniqueViolationErrCode {
+				return datastore.NewSchemaVersionExistsErr(version.Version)
+			}
+			return fmt.Errorf(errWriteSchemaVersion, err)
+		}
+		return nil
+	})
+}
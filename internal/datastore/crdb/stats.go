@@ -8,6 +8,7 @@ import (
 	"github.com/jackc/pgx/v4"
 	"github.com/shopspring/decimal"
 
+	"github.com/authzed/spicedb/internal/datastore/common"
 	"github.com/authzed/spicedb/pkg/datastore"
 	"github.com/authzed/spicedb/pkg/datastore/revision"
 )
@@ -23,6 +24,7 @@ const (
 
 var (
 	queryReadUniqueID         = psql.Select(colUniqueID).From(tableMetadata)
+	queryRelationCounts       = psql.Select(colNamespace, colRelation, "COUNT(*)").From(tableTuple).GroupBy(colNamespace, colRelation)
 	queryRelationshipEstimate = fmt.Sprintf("SELECT COALESCE(SUM(%s), 0) FROM %s", colCount, tableCounters)
 
 	upsertCounterQuery = psql.Insert(tableCounters).Columns(
@@ -66,6 +68,37 @@ func (cds *crdbDatastore) Statistics(ctx context.Context) (datastore.Stats, erro
 	}, nil
 }
 
+// CountRelationshipsByRelation counts the relationships of each relation.
+func (cds *crdbDatastore) CountRelationshipsByRelation(ctx context.Context) ([]common.RelationCount, error) {
+	sql, args, err := queryRelationCounts.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("unable to prepare relation counts sql: %w", err)
+	}
+
+	var counts []common.RelationCount
+	if err := cds.pool.BeginTxFunc(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly}, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, sql, args...)
+		if err != nil {
+			return fmt.Errorf("unable to count relationships: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var count common.RelationCount
+			if err := rows.Scan(&count.Namespace, &count.Relation, &count.Count); err != nil {
+				return fmt.Errorf("unable to read relation count: %w", err)
+			}
+			counts = append(counts, count)
+		}
+		return rows.Err()
+	}); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+var _ common.RelationCounter = &crdbDatastore{}
+
 func updateCounter(ctx context.Context, tx pgx.Tx, change int64) (revision.Decimal, error) {
 	counterID := make([]byte, 2)
 	_, err := rand.Read(counterID)
@@ -23,6 +23,8 @@ const (
 	crdbUnknownSQLState = "XXUUU"
 	// Error message encountered when crdb nodes have large clock skew
 	crdbClockSkewMessage = "cannot specify timestamp in the future"
+	// https://www.cockroachlabs.com/docs/stable/postgresql-compatibility.html#error-codes
+	crdbUniqueViolationErrCode = "23505"
 
 	errReachedMaxRetries = "maximum retries reached (%d/%d): %w"
 )
@@ -22,8 +22,9 @@ type changeDetails struct {
 	Resolved string
 	Updated  string
 	After    *struct {
-		CaveatContext map[string]any `json:"caveat_context"`
-		CaveatName    string         `json:"caveat_name"`
+		CaveatContext map[string]any    `json:"caveat_context"`
+		CaveatName    string            `json:"caveat_name"`
+		Labels        map[string]string `json:"labels"`
 	}
 }
 
@@ -106,6 +107,7 @@ func (cds *crdbDatastore) Watch(ctx context.Context, afterRevision datastore.Rev
 				})
 
 				for _, change := range toEmit {
+					change.SortChanges()
 					select {
 					case updates <- change:
 					default:
@@ -135,6 +137,10 @@ func (cds *crdbDatastore) Watch(ctx context.Context, afterRevision datastore.Rev
 				caveatName = details.After.CaveatName
 				caveatContext = details.After.CaveatContext
 			}
+			var labels map[string]string
+			if details.After != nil {
+				labels = details.After.Labels
+			}
 			ctxCaveat, err := common.ContextualizedCaveatFrom(caveatName, caveatContext)
 			if err != nil {
 				errs <- err
@@ -154,6 +160,7 @@ func (cds *crdbDatastore) Watch(ctx context.Context, afterRevision datastore.Rev
 						Relation:  pkValues[5],
 					},
 					Caveat: ctxCaveat,
+					Labels: labels,
 				},
 			}
 
@@ -0,0 +1,63 @@
+package memdb
+
+import (
+	"context"
+	"time"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
+)
+
+type deletion struct {
+	deletedAt     time.Time
+	relationships [][]byte
+}
+
+// RetainDeletedRelationships stores the relationships removed by a deletion.
+func (mdb *memdbDatastore) RetainDeletedRelationships(_ context.Context, deletionID string, relationships [][]byte) error {
+	mdb.Lock()
+	defer mdb.Unlock()
+
+	mdb.deletions[deletionID] = deletion{deletedAt: time.Now(), relationships: relationships}
+	return nil
+}
+
+// ReadDeletedRelationships returns the relationships retained for a deletion.
+func (mdb *memdbDatastore) ReadDeletedRelationships(_ context.Context, deletionID string) ([][]byte, error) {
+	mdb.RLock()
+	defer mdb.RUnlock()
+
+	found, ok := mdb.deletions[deletionID]
+	if !ok || len(found.relationships) == 0 {
+		return nil, common.ErrDeletionNotFound
+	}
+	return found.relationships, nil
+}
+
+// ForgetDeletedRelationships removes the relationships retained for a deletion.
+func (mdb *memdbDatastore) ForgetDeletedRelationships(_ context.Context, deletionID string) error {
+	mdb.Lock()
+	defer mdb.Unlock()
+
+	delete(mdb.deletions, deletionID)
+	return nil
+}
+
+// PurgeDeletedRelationships removes the relationships retained for longer than the retention
+// period.
+func (mdb *memdbDatastore) PurgeDeletedRelationships(_ context.Context, retention time.Duration) (int64, error) {
+	mdb.Lock()
+	defer mdb.Unlock()
+
+	cutoff := time.Now().Add(-retention)
+
+	var purged int64
+	for deletionID, found := range mdb.deletions {
+		if found.deletedAt.Before(cutoff) {
+			purged += int64(len(found.relationships))
+			delete(mdb.deletions, deletionID)
+		}
+	}
+	return purged, nil
+}
+
+var _ common.DeletedRelationshipStore = &memdbDatastore{}
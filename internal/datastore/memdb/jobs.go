@@ -0,0 +1,61 @@
+package memdb
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
+)
+
+// CreateJob stores a new job.
+func (mdb *memdbDatastore) CreateJob(_ context.Context, id string, data []byte) error {
+	mdb.Lock()
+	defer mdb.Unlock()
+
+	if _, ok := mdb.jobs[id]; ok {
+		return fmt.Errorf("job %s already exists", id)
+	}
+	mdb.jobs[id] = common.StoredJob{ID: id, Version: 1, Data: data}
+	return nil
+}
+
+// ReadJob returns the stored state of a job.
+func (mdb *memdbDatastore) ReadJob(_ context.Context, id string) (common.StoredJob, error) {
+	mdb.RLock()
+	defer mdb.RUnlock()
+
+	job, ok := mdb.jobs[id]
+	if !ok {
+		return common.StoredJob{}, common.ErrJobNotFound
+	}
+	return job, nil
+}
+
+// ListJobs returns the stored state of every job.
+func (mdb *memdbDatastore) ListJobs(_ context.Context) ([]common.StoredJob, error) {
+	mdb.RLock()
+	defer mdb.RUnlock()
+
+	jobs := make([]common.StoredJob, 0, len(mdb.jobs))
+	for _, job := range mdb.jobs {
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID < jobs[j].ID })
+	return jobs, nil
+}
+
+// UpdateJob replaces the state of a job if it is still at the given version.
+func (mdb *memdbDatastore) UpdateJob(_ context.Context, id string, version uint64, data []byte) (bool, error) {
+	mdb.Lock()
+	defer mdb.Unlock()
+
+	job, ok := mdb.jobs[id]
+	if !ok || job.Version != version {
+		return false, nil
+	}
+	mdb.jobs[id] = common.StoredJob{ID: id, Version: version + 1, Data: data}
+	return true, nil
+}
+
+var _ common.JobStore = &memdbDatastore{}
@@ -0,0 +1,39 @@
+package memdb
+
+import (
+	"context"
+	"time"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
+)
+
+type lease struct {
+	holder    string
+	expiresAt time.Time
+}
+
+// AcquireLease takes or renews the named lease for the holder.
+func (mdb *memdbDatastore) AcquireLease(_ context.Context, name, holder string, duration time.Duration) (bool, error) {
+	mdb.Lock()
+	defer mdb.Unlock()
+
+	now := time.Now()
+	if existing, ok := mdb.leases[name]; ok && existing.holder != holder && now.Before(existing.expiresAt) {
+		return false, nil
+	}
+	mdb.leases[name] = lease{holder: holder, expiresAt: now.Add(duration)}
+	return true, nil
+}
+
+// ReleaseLease frees the named lease, if it is held by the holder.
+func (mdb *memdbDatastore) ReleaseLease(_ context.Context, name, holder string) error {
+	mdb.Lock()
+	defer mdb.Unlock()
+
+	if existing, ok := mdb.leases[name]; ok && existing.holder == holder {
+		delete(mdb.leases, name)
+	}
+	return nil
+}
+
+var _ common.LeaseStore = &memdbDatastore{}
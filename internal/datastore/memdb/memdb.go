@@ -13,6 +13,7 @@ import (
 	"github.com/hashicorp/go-memdb"
 	"github.com/shopspring/decimal"
 
+	"github.com/authzed/spicedb/internal/datastore/common"
 	"github.com/authzed/spicedb/pkg/datastore"
 	"github.com/authzed/spicedb/pkg/datastore/revision"
 	corev1 "github.com/authzed/spicedb/pkg/proto/core/v1"
@@ -71,6 +72,9 @@ func NewMemdbDatastore(
 		quantizationPeriod: decimal.NewFromInt(revisionQuantization.Nanoseconds()),
 		watchBufferLength:  watchBufferLength,
 		uniqueID:           uniqueID,
+		jobs:               make(map[string]common.StoredJob),
+		leases:             make(map[string]lease),
+		deletions:          make(map[string]deletion),
 	}, nil
 }
 
@@ -86,6 +90,10 @@ type memdbDatastore struct {
 	quantizationPeriod decimal.Decimal
 	watchBufferLength  uint16
 	uniqueID           string
+
+	jobs      map[string]common.StoredJob
+	leases    map[string]lease
+	deletions map[string]deletion
 }
 
 type snapshot struct {
@@ -218,6 +226,7 @@ func (mdb *memdbDatastore) ReadWriteTx(
 				}
 			}
 
+			newChanges.SortChanges()
 			change := &changelog{
 				revisionNanos: newRevision.IntPart(),
 				changes:       newChanges,
@@ -12,6 +12,7 @@ import (
 	"golang.org/x/sync/errgroup"
 
 	"github.com/authzed/spicedb/pkg/datastore"
+	"github.com/authzed/spicedb/pkg/datastore/revision"
 	test "github.com/authzed/spicedb/pkg/datastore/test"
 	ns "github.com/authzed/spicedb/pkg/namespace"
 	corev1 "github.com/authzed/spicedb/pkg/proto/core/v1"
@@ -77,3 +78,36 @@ func TestConcurrentWritePanic(t *testing.T) {
 	}, 1*time.Second, 10*time.Millisecond)
 	require.ErrorIs(err, recoverErr)
 }
+
+func TestHybridLogicalClockRevisions(t *testing.T) {
+	require := require.New(t)
+
+	ds, err := NewMemdbDatastore(0, 1*time.Hour, 1*time.Hour)
+	require.NoError(err)
+	t.Cleanup(func() { ds.Close() })
+
+	ctx := context.Background()
+	previous, err := ds.HeadRevision(ctx)
+	require.NoError(err)
+
+	for i := 0; i < 100; i++ {
+		rev, err := ds.ReadWriteTx(ctx, func(rwt datastore.ReadWriteTransaction) error {
+			return nil
+		})
+		require.NoError(err)
+		require.True(rev.GreaterThan(previous), "revision %s is not after %s", rev, previous)
+
+		// The wall clock component must never be ahead of the clock, and the logical component
+		// must fit within the decimal places used by CockroachDB.
+		dr := rev.(revision.Decimal)
+		require.LessOrEqual(dr.IntPart(), time.Now().UnixNano())
+		require.GreaterOrEqual(dr.Exponent(), int32(-logicalDigits))
+
+		decoded, err := ds.RevisionFromString(rev.String())
+		require.NoError(err)
+		require.True(decoded.Equal(rev))
+
+		require.NoError(ds.CheckRevision(ctx, rev))
+		previous = rev
+	}
+}
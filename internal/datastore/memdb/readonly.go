@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"runtime"
+	"strings"
 
 	"github.com/hashicorp/go-memdb"
 	"github.com/jzelinskie/stringz"
@@ -49,9 +50,11 @@ func (r *memdbReader) QueryRelationships(
 	matchingRelationshipsFilterFunc := filterFuncForFilters(
 		filter.ResourceType,
 		filter.OptionalResourceIds,
+		"",
 		filter.OptionalResourceRelation,
 		filter.OptionalSubjectsSelectors,
 		filter.OptionalCaveatName,
+		filter.OptionalLabels,
 		queryOpts.Usersets,
 	)
 	filteredIterator := memdb.NewFilterIterator(bestIterator, matchingRelationshipsFilterFunc)
@@ -109,10 +112,12 @@ func (r *memdbReader) ReverseQueryRelationships(
 	matchingRelationshipsFilterFunc := filterFuncForFilters(
 		filterObjectType,
 		nil,
+		queryOpts.ResIDPrefix,
 		filterRelation,
 		[]datastore.SubjectsSelector{subjectsFilter.AsSelector()},
 		"",
 		nil,
+		nil,
 	)
 	filteredIterator := memdb.NewFilterIterator(iterator, matchingRelationshipsFilterFunc)
 
@@ -275,9 +280,11 @@ func iteratorForFilter(txn *memdb.Txn, filter datastore.RelationshipsFilter) (me
 func filterFuncForFilters(
 	optionalResourceType string,
 	optionalResourceIds []string,
+	optionalResourceIDPrefix string,
 	optionalRelation string,
 	optionalSubjectsSelectors []datastore.SubjectsSelector,
 	optionalCaveatFilter string,
+	optionalLabels map[string]string,
 	usersets []*core.ObjectAndRelation,
 ) memdb.FilterFunc {
 	return func(tupleRaw interface{}) bool {
@@ -288,12 +295,20 @@ func filterFuncForFilters(
 			return true
 		case len(optionalResourceIds) > 0 && !stringz.SliceContains(optionalResourceIds, tuple.resourceID):
 			return true
+		case optionalResourceIDPrefix != "" && !strings.HasPrefix(tuple.resourceID, optionalResourceIDPrefix):
+			return true
 		case optionalRelation != "" && optionalRelation != tuple.relation:
 			return true
 		case optionalCaveatFilter != "" && (tuple.caveat == nil || tuple.caveat.caveatName != optionalCaveatFilter):
 			return true
 		}
 
+		for key, value := range optionalLabels {
+			if labelValue, ok := tuple.labels[key]; !ok || labelValue != value {
+				return true
+			}
+		}
+
 		applySubjectSelector := func(selector datastore.SubjectsSelector) bool {
 			switch {
 			case len(selector.OptionalSubjectType) > 0 && selector.OptionalSubjectType != tuple.subjectNamespace:
@@ -357,27 +372,30 @@ type memdbTupleIterator struct {
 	err    error
 }
 
-func (mti *memdbTupleIterator) Next() *core.RelationTuple {
+func (mti *memdbTupleIterator) Next() (*core.RelationTuple, error) {
+	switch {
+	case mti.closed:
+		return nil, datastore.ErrClosedIterator
+	case mti.err != nil:
+		return nil, mti.err
+	}
+
 	foundRaw := mti.it.Next()
 	if foundRaw == nil {
-		return nil
+		return nil, nil
 	}
 
 	if mti.limit != nil && mti.count >= *mti.limit {
-		return nil
+		return nil, nil
 	}
 	mti.count++
 
 	rt, err := foundRaw.(*relationship).RelationTuple()
 	if err != nil {
 		mti.err = err
-		return nil
+		return nil, err
 	}
-	return rt
-}
-
-func (mti *memdbTupleIterator) Err() error {
-	return mti.err
+	return rt, nil
 }
 
 func (mti *memdbTupleIterator) Close() {
@@ -33,40 +33,49 @@ func (rwt *memdbReadWriteTx) WriteRelationships(ctx context.Context, mutations [
 }
 
 // Caller must already hold the concurrent access lock!
+//
+// The mutations are applied as the SQL datastores apply them: all deletions first, followed by
+// all creations and touches, so that the result does not depend upon the order of the
+// mutations. Creating a relationship which exists after the deletions, or writing the same
+// relationship more than once, fails with the same error as a violated unique constraint.
 func (rwt *memdbReadWriteTx) write(tx *memdb.Txn, mutations ...*core.RelationTupleUpdate) error {
-	// Apply the mutations
 	for _, mutation := range mutations {
-		rel := &relationship{
-			mutation.Tuple.ResourceAndRelation.Namespace,
-			mutation.Tuple.ResourceAndRelation.ObjectId,
-			mutation.Tuple.ResourceAndRelation.Relation,
-			mutation.Tuple.Subject.Namespace,
-			mutation.Tuple.Subject.ObjectId,
-			mutation.Tuple.Subject.Relation,
-			rwt.toCaveatReference(mutation),
+		switch mutation.Operation {
+		case core.RelationTupleUpdate_CREATE, core.RelationTupleUpdate_TOUCH:
+		case core.RelationTupleUpdate_DELETE:
+			existing, err := rwt.findExisting(tx, mutation.Tuple)
+			if err != nil {
+				return err
+			}
+
+			if existing != nil {
+				if err := tx.Delete(tableRelationship, existing); err != nil {
+					return fmt.Errorf("error deleting relationship: %w", err)
+				}
+			}
+		default:
+			return fmt.Errorf("unknown tuple mutation operation type: %s", mutation.Operation)
 		}
+	}
 
-		found, err := tx.First(
-			tableRelationship,
-			indexID,
-			rel.namespace,
-			rel.resourceID,
-			rel.relation,
-			rel.subjectNamespace,
-			rel.subjectObjectID,
-			rel.subjectRelation,
-		)
-		if err != nil {
-			return fmt.Errorf("error loading existing relationship: %w", err)
+	written := make(map[string]struct{}, len(mutations))
+	for _, mutation := range mutations {
+		if mutation.Operation == core.RelationTupleUpdate_DELETE {
+			continue
 		}
 
-		var existing *relationship
-		if found != nil {
-			existing = found.(*relationship)
+		key := tuple.StringWithoutCaveat(mutation.Tuple)
+		if _, ok := written[key]; ok {
+			return common.NewCreateRelationshipExistsError(mutation.Tuple)
 		}
+		written[key] = struct{}{}
+
+		if mutation.Operation == core.RelationTupleUpdate_CREATE {
+			existing, err := rwt.findExisting(tx, mutation.Tuple)
+			if err != nil {
+				return err
+			}
 
-		switch mutation.Operation {
-		case core.RelationTupleUpdate_CREATE:
 			if existing != nil {
 				rt, err := existing.RelationTuple()
 				if err != nil {
@@ -74,25 +83,47 @@ func (rwt *memdbReadWriteTx) write(tx *memdb.Txn, mutations ...*core.RelationTup
 				}
 				return common.NewCreateRelationshipExistsError(rt)
 			}
-			fallthrough
-		case core.RelationTupleUpdate_TOUCH:
-			if err := tx.Insert(tableRelationship, rel); err != nil {
-				return fmt.Errorf("error inserting relationship: %w", err)
-			}
-		case core.RelationTupleUpdate_DELETE:
-			if existing != nil {
-				if err := tx.Delete(tableRelationship, existing); err != nil {
-					return fmt.Errorf("error deleting relationship: %w", err)
-				}
-			}
-		default:
-			return fmt.Errorf("unknown tuple mutation operation type: %s", mutation.Operation)
+		}
+
+		rel := &relationship{
+			mutation.Tuple.ResourceAndRelation.Namespace,
+			mutation.Tuple.ResourceAndRelation.ObjectId,
+			mutation.Tuple.ResourceAndRelation.Relation,
+			mutation.Tuple.Subject.Namespace,
+			mutation.Tuple.Subject.ObjectId,
+			mutation.Tuple.Subject.Relation,
+			rwt.toCaveatReference(mutation),
+			mutation.Tuple.Labels,
+		}
+		if err := tx.Insert(tableRelationship, rel); err != nil {
+			return fmt.Errorf("error inserting relationship: %w", err)
 		}
 	}
 
 	return nil
 }
 
+func (rwt *memdbReadWriteTx) findExisting(tx *memdb.Txn, tpl *core.RelationTuple) (*relationship, error) {
+	found, err := tx.First(
+		tableRelationship,
+		indexID,
+		tpl.ResourceAndRelation.Namespace,
+		tpl.ResourceAndRelation.ObjectId,
+		tpl.ResourceAndRelation.Relation,
+		tpl.Subject.Namespace,
+		tpl.Subject.ObjectId,
+		tpl.Subject.Relation,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error loading existing relationship: %w", err)
+	}
+
+	if found == nil {
+		return nil, nil
+	}
+	return found.(*relationship), nil
+}
+
 func (rwt *memdbReadWriteTx) toCaveatReference(mutation *core.RelationTupleUpdate) *contextualizedCaveat {
 	var cr *contextualizedCaveat
 	if mutation.Tuple.Caveat != nil {
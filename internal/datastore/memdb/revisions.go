@@ -2,6 +2,7 @@ package memdb
 
 import (
 	"context"
+	"sort"
 	"time"
 
 	"github.com/shopspring/decimal"
@@ -10,29 +11,29 @@ import (
 	"github.com/authzed/spicedb/pkg/datastore/revision"
 )
 
+// logicalDigits is the number of decimal places holding the logical component of a revision,
+// matching the hybrid logical clock timestamps returned by CockroachDB.
+const logicalDigits = 10
+
+var logicalTick = decimal.New(1, -logicalDigits)
+
 func revisionFromTimestamp(t time.Time) revision.Decimal {
 	return revision.NewFromDecimal(decimal.NewFromInt(t.UnixNano()))
 }
 
+// newRevisionID returns a hybrid logical clock revision: the wall clock time in nanoseconds, with
+// a logical counter in the fractional part. If the wall clock has not advanced past the latest
+// revision, which happens on platforms with coarse clocks or if the clock steps backwards, the
+// latest revision's wall time is kept and its logical counter incremented, so that revisions are
+// strictly increasing while remaining comparable with wall clock times, as on CockroachDB.
 func (mdb *memdbDatastore) newRevisionID() revision.Decimal {
 	mdb.Lock()
 	defer mdb.Unlock()
 
 	existing := mdb.revisions[len(mdb.revisions)-1].revision
 	created := revisionFromTimestamp(time.Now().UTC()).Decimal
-
-	// NOTE: The time.Now().UTC() only appears to have *microsecond* level
-	// precision on macOS Monterey in Go 1.19.1. This means that HeadRevision
-	// and the result of a ReadWriteTx could return the *same* transaction ID
-	// if both are executed in sequence without any other forms of delay on
-	// macOS. We therefore check if the created transaction ID matches that
-	// previously created and, if not, add to it.
-	//
-	// See: https://github.com/golang/go/issues/22037 which appeared to fix
-	// this in Go 1.9.2, but there appears to have been a reversion with either
-	// the new version of macOS or Go.
-	if created.Equals(existing) {
-		return revision.NewFromDecimal(created.Add(decimal.NewFromInt(1)))
+	if created.LessThanOrEqual(existing) {
+		return revision.NewFromDecimal(existing.Add(logicalTick))
 	}
 	return revision.NewFromDecimal(created)
 }
@@ -82,8 +83,8 @@ func (mdb *memdbDatastore) checkRevisionLocalCallerMustLock(revisionRaw revision
 	// If the revision <= now and later than the GC window, it is assumed to be valid, even if
 	// HEAD revision is behind it.
 	if revisionRaw.GreaterThan(now) {
-		// If the revision is in the "future", then check to ensure that it is <= of HEAD to handle
-		// the microsecond granularity on macos (see comment above in newRevisionID)
+		// If the revision is in the "future", then check to ensure that it is <= of HEAD, which
+		// may be ahead of the wall clock by its logical component (see newRevisionID)
 		headRevision, err := mdb.headRevisionNoLock()
 		if err != nil {
 			return err
@@ -98,3 +99,29 @@ func (mdb *memdbDatastore) checkRevisionLocalCallerMustLock(revisionRaw revision
 
 	return nil
 }
+
+// RevisionTime returns the wall clock time of the revision, which it holds in its integer part.
+func (mdb *memdbDatastore) RevisionTime(ctx context.Context, revisionRaw datastore.Revision) (time.Time, error) {
+	dr, ok := revisionRaw.(revision.Decimal)
+	if !ok {
+		return time.Time{}, datastore.NewInvalidRevisionErr(revisionRaw, datastore.CouldNotDetermineRevision)
+	}
+	return time.Unix(0, dr.IntPart()).UTC(), nil
+}
+
+func (mdb *memdbDatastore) RevisionAtTime(ctx context.Context, at time.Time) (datastore.Revision, error) {
+	mdb.RLock()
+	defer mdb.RUnlock()
+
+	// Snapshots are read at the first revision at or after that requested, so the revision must
+	// be that of the last snapshot at or before the time.
+	requested := revisionFromTimestamp(at)
+	index := sort.Search(len(mdb.revisions), func(i int) bool {
+		return mdb.revisions[i].revision.GreaterThan(requested.Decimal)
+	})
+	if index == 0 {
+		return datastore.NoRevision, datastore.NewInvalidRevisionErr(requested, datastore.RevisionStale)
+	}
+
+	return revision.NewFromDecimal(mdb.revisions[index-1].revision), nil
+}
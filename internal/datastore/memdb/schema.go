@@ -44,6 +44,7 @@ type relationship struct {
 	subjectObjectID  string
 	subjectRelation  string
 	caveat           *contextualizedCaveat
+	labels           map[string]string
 }
 
 type contextualizedCaveat struct {
@@ -112,6 +113,7 @@ func (r relationship) RelationTuple() (*core.RelationTuple, error) {
 			Relation:  r.subjectRelation,
 		},
 		Caveat: cr,
+		Labels: r.labels,
 	}, nil
 }
 
@@ -212,5 +214,15 @@ var schema = &memdb.DBSchema{
 				},
 			},
 		},
+		tableSchemaVersions: {
+			Name: tableSchemaVersions,
+			Indexes: map[string]*memdb.IndexSchema{
+				indexID: {
+					Name:    indexID,
+					Unique:  true,
+					Indexer: &memdb.UintFieldIndex{Field: "version"},
+				},
+			},
+		},
 	},
 }
@@ -0,0 +1,88 @@
+package memdb
+
+import (
+	"context"
+
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+)
+
+const tableSchemaVersions = "schemaVersions"
+
+type schemaVersion struct {
+	version    uint64
+	definition []byte
+}
+
+func (sv *schemaVersion) Unwrap() (*core.SchemaVersion, error) {
+	version := core.SchemaVersion{}
+	err := version.UnmarshalVT(sv.definition)
+	return &version, err
+}
+
+func (r *memdbReader) ListSchemaVersions(_ context.Context) ([]*core.SchemaVersion, error) {
+	r.mustLock()
+	defer r.Unlock()
+
+	tx, err := r.txSource()
+	if err != nil {
+		return nil, err
+	}
+
+	it, err := tx.LowerBound(tableSchemaVersions, indexID)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []*core.SchemaVersion
+	for foundRaw := it.Next(); foundRaw != nil; foundRaw = it.Next() {
+		version, err := foundRaw.(*schemaVersion).Unwrap()
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+	return versions, nil
+}
+
+func (r *memdbReader) LatestSchemaVersion(_ context.Context) (uint64, error) {
+	r.mustLock()
+	defer r.Unlock()
+
+	tx, err := r.txSource()
+	if err != nil {
+		return 0, err
+	}
+
+	found, err := tx.Last(tableSchemaVersions, indexID)
+	if err != nil || found == nil {
+		return 0, err
+	}
+	return found.(*schemaVersion).version, nil
+}
+
+func (rwt *memdbReadWriteTx) WriteSchemaVersion(_ context.Context, version *core.SchemaVersion) error {
+	rwt.mustLock()
+	defer rwt.Unlock()
+	tx, err := rwt.txSource()
+	if err != nil {
+		return err
+	}
+
+	found, err := tx.First(tableSchemaVersions, indexID, version.Version)
+	if err != nil {
+		return err
+	}
+	if found != nil {
+		return datastore.NewSchemaVersionExistsErr(version.Version)
+	}
+
+	marshalled, err := version.MarshalVT()
+	if err != nil {
+		return err
+	}
+	return tx.Insert(tableSchemaVersions, &schemaVersion{
+		version:    version.Version,
+		definition: marshalled,
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/authzed/spicedb/internal/datastore/common"
 	"github.com/authzed/spicedb/pkg/datastore"
 )
 
@@ -49,3 +50,40 @@ func (mdb *memdbDatastore) countRelationships(ctx context.Context) (uint64, erro
 
 	return count, nil
 }
+
+// CountRelationshipsByRelation counts the relationships of each relation.
+func (mdb *memdbDatastore) CountRelationshipsByRelation(ctx context.Context) ([]common.RelationCount, error) {
+	mdb.RLock()
+	defer mdb.RUnlock()
+
+	txn := mdb.db.Txn(false)
+	defer txn.Abort()
+
+	it, err := txn.LowerBound(tableRelationship, indexID)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[relationKey]uint64)
+	for row := it.Next(); row != nil; row = it.Next() {
+		rel := row.(*relationship)
+		counts[relationKey{rel.namespace, rel.relation}]++
+	}
+
+	relationCounts := make([]common.RelationCount, 0, len(counts))
+	for key, count := range counts {
+		relationCounts = append(relationCounts, common.RelationCount{
+			Namespace: key.namespace,
+			Relation:  key.relation,
+			Count:     count,
+		})
+	}
+	return relationCounts, nil
+}
+
+type relationKey struct {
+	namespace string
+	relation  string
+}
+
+var _ common.RelationCounter = &memdbDatastore{}
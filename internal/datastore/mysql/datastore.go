@@ -47,10 +47,11 @@ const (
 	colCaveatDefinition = "definition"
 	colCaveatName       = "caveat_name"
 	colCaveatContext    = "caveat_context"
+	colLabels           = "labels"
+	colSchemaVersion    = "version"
 
 	errUnableToInstantiate = "unable to instantiate datastore: %w"
 	liveDeletedTxnID       = uint64(math.MaxInt64)
-	batchDeleteSize        = 1000
 	noLastInsertID         = 0
 	seedingTimeout         = 10 * time.Second
 
@@ -83,10 +84,6 @@ func init() {
 	datastore.Engines = append(datastore.Engines, Engine)
 }
 
-type sqlFilter interface {
-	ToSql() (string, []interface{}, error)
-}
-
 // NewMySQLDatastore creates a new mysql.Datastore value configured with the MySQL instance
 // specified in through the URI parameter. Supports customization via the various options available
 // in this package.
@@ -108,6 +105,23 @@ func newMySQLDatastore(uri string, options ...Option) (*Datastore, error) {
 		return nil, fmt.Errorf(errUnableToInstantiate, err)
 	}
 
+	var adaptiveQuantization *revisions.AdaptiveQuantization
+	if config.adaptiveQuantization {
+		adaptiveQuantization, err = revisions.NewAdaptiveQuantization(
+			config.minRevisionQuantization,
+			config.revisionQuantization,
+			config.fullQuantizationWriteRate,
+		)
+		if err != nil {
+			return nil, fmt.Errorf(errUnableToInstantiate, err)
+		}
+	}
+
+	gcController, err := common.NewGCController(config.gcTuning)
+	if err != nil {
+		return nil, fmt.Errorf(errUnableToInstantiate, err)
+	}
+
 	parsedURI, err := mysql.ParseDSN(uri)
 	if err != nil {
 		return nil, fmt.Errorf("NewMySQLDatastore: could not parse connection URI `%s`: %w", uri, err)
@@ -148,6 +162,11 @@ func newMySQLDatastore(uri string, options ...Option) (*Datastore, error) {
 		if err := common.RegisterGCMetrics(); err != nil {
 			return nil, fmt.Errorf(errUnableToInstantiate, err)
 		}
+		if config.adaptiveQuantization {
+			if err := revisions.RegisterAdaptiveQuantizationMetrics(); err != nil {
+				return nil, fmt.Errorf(errUnableToInstantiate, err)
+			}
+		}
 	} else {
 		db = sql.OpenDB(connector)
 	}
@@ -174,18 +193,7 @@ func newMySQLDatastore(uri string, options ...Option) (*Datastore, error) {
 	maxRevisionStaleness := time.Duration(float64(config.revisionQuantization.Nanoseconds())*
 		config.maxRevisionStalenessPercent) * time.Nanosecond
 
-	quantizationPeriodNanos := config.revisionQuantization.Nanoseconds()
-	if quantizationPeriodNanos < 1 {
-		quantizationPeriodNanos = 1
-	}
-
-	revisionQuery := fmt.Sprintf(
-		querySelectRevision,
-		colID,
-		driver.RelationTupleTransaction(),
-		colTimestamp,
-		quantizationPeriodNanos,
-	)
+	revisionQuery := selectRevisionQuery(driver.RelationTupleTransaction(), config.revisionQuantization)
 
 	validTransactionQuery := fmt.Sprintf(
 		queryValidTransaction,
@@ -203,11 +211,14 @@ func newMySQLDatastore(uri string, options ...Option) (*Datastore, error) {
 		gcWindow:               config.gcWindow,
 		gcInterval:             config.gcInterval,
 		gcTimeout:              config.gcMaxOperationTime,
+		gcController:           gcController,
 		gcCtx:                  gcCtx,
 		cancelGc:               cancelGc,
 		watchBufferLength:      config.watchBufferLength,
 		usersetBatchSize:       config.splitAtUsersetCount,
+		queryLogger:            common.NewQueryLogger(config.queryLogSampleRate),
 		optimizedRevisionQuery: revisionQuery,
+		adaptiveQuantization:   adaptiveQuantization,
 		validTransactionQuery:  validTransactionQuery,
 		createTxn:              createTxn,
 		createBaseTxn:          createBaseTxn,
@@ -232,14 +243,20 @@ func newMySQLDatastore(uri string, options ...Option) (*Datastore, error) {
 	// Start a goroutine for garbage collection.
 	if store.gcInterval > 0*time.Minute && config.gcEnabled {
 		store.gcGroup, store.gcCtx = errgroup.WithContext(store.gcCtx)
-		store.gcGroup.Go(func() error {
+		runGC := func(ctx context.Context) error {
 			return common.StartGarbageCollector(
-				store.gcCtx,
+				ctx,
 				store,
 				store.gcInterval,
 				store.gcWindow,
 				store.gcTimeout,
 			)
+		}
+		store.gcGroup.Go(func() error {
+			if config.gcLeaderElection {
+				return common.RunWithLease(store.gcCtx, store, common.GCLeaseName, common.DefaultLeaseDuration, runGC)
+			}
+			return runGC(store.gcCtx)
 		})
 	} else {
 		log.Warn().Msg("datastore background garbage collection disabled")
@@ -264,6 +281,7 @@ func (mds *Datastore) SnapshotReader(revisionRaw datastore.Revision) datastore.R
 	querySplitter := common.TupleQuerySplitter{
 		Executor:         newMySQLExecutor(mds.db),
 		UsersetBatchSize: mds.usersetBatchSize,
+		QueryLogger:      mds.queryLogger,
 	}
 
 	return &mysqlReader{
@@ -299,6 +317,7 @@ func (mds *Datastore) ReadWriteTx(
 			querySplitter := common.TupleQuerySplitter{
 				Executor:         newMySQLExecutor(tx),
 				UsersetBatchSize: mds.usersetBatchSize,
+				QueryLogger:      mds.queryLogger,
 			}
 
 			rwt := &mysqlReadWriteTXN{
@@ -325,6 +344,7 @@ func (mds *Datastore) ReadWriteTx(
 			return datastore.NoRevision, err
 		}
 
+		mds.adaptiveQuantization.RecordWrite()
 		return revisionFromTransaction(newTxnID), nil
 	}
 	return datastore.NoRevision, fmt.Errorf("max retries exceeded: %w", err)
@@ -380,6 +400,7 @@ func newMySQLExecutor(tx querier) common.ExecuteQueryFunc {
 
 			var caveatName string
 			var caveatContext caveatContextWrapper
+			var labels labelsWrapper
 			err := rows.Scan(
 				&nextTuple.ResourceAndRelation.Namespace,
 				&nextTuple.ResourceAndRelation.ObjectId,
@@ -389,6 +410,7 @@ func newMySQLExecutor(tx querier) common.ExecuteQueryFunc {
 				&nextTuple.Subject.Relation,
 				&caveatName,
 				&caveatContext,
+				&labels,
 			)
 			if err != nil {
 				return nil, fmt.Errorf(errUnableToQueryTuples, err)
@@ -398,6 +420,7 @@ func newMySQLExecutor(tx querier) common.ExecuteQueryFunc {
 			if err != nil {
 				return nil, fmt.Errorf(errUnableToQueryTuples, err)
 			}
+			nextTuple.Labels = labels
 
 			tuples = append(tuples, nextTuple)
 		}
@@ -421,11 +444,16 @@ type Datastore struct {
 	gcWindow             time.Duration
 	gcInterval           time.Duration
 	gcTimeout            time.Duration
+	gcController         *common.GCController
 	watchBufferLength    uint16
 	usersetBatchSize     uint16
 	maxRetries           uint8
 
+	queryLogger   *common.QueryLogger
+	revisionTimes common.RevisionTimeCache
+
 	optimizedRevisionQuery string
+	adaptiveQuantization   *revisions.AdaptiveQuantization
 	validTransactionQuery  string
 
 	gcGroup  *errgroup.Group
@@ -0,0 +1,142 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
+	"github.com/authzed/spicedb/internal/datastore/mysql/migrations"
+)
+
+const (
+	colDeletedRelationshipDeletion  = "deletion_id"
+	colDeletedRelationshipPosition  = "position"
+	colDeletedRelationshipDeletedAt = "deleted_at"
+	colDeletedRelationshipData      = "data"
+
+	// retainBatchSize is the number of deleted relationships inserted per statement.
+	retainBatchSize = 1000
+)
+
+// RetainDeletedRelationships stores the relationships removed by a deletion.
+func (mds *Datastore) RetainDeletedRelationships(ctx context.Context, deletionID string, relationships [][]byte) error {
+	forgetQuery, forgetArgs, err := sb.
+		Delete(mds.driver.DeletedRelationship()).
+		Where(squirrel.Eq{colDeletedRelationshipDeletion: deletionID}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("unable to prepare forget deleted relationships sql: %w", err)
+	}
+
+	if err := migrations.BeginTxFunc(ctx, mds.db, nil, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, forgetQuery, forgetArgs...); err != nil {
+			return err
+		}
+
+		for start := 0; start < len(relationships); start += retainBatchSize {
+			end := start + retainBatchSize
+			if end > len(relationships) {
+				end = len(relationships)
+			}
+
+			insert := sb.
+				Insert(mds.driver.DeletedRelationship()).
+				Columns(colDeletedRelationshipDeletion, colDeletedRelationshipPosition, colDeletedRelationshipDeletedAt, colDeletedRelationshipData)
+			for position := start; position < end; position++ {
+				insert = insert.Values(deletionID, position, squirrel.Expr("UTC_TIMESTAMP(6)"), relationships[position])
+			}
+
+			query, args, err := insert.ToSql()
+			if err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("unable to retain deleted relationships: %w", err)
+	}
+	return nil
+}
+
+// ReadDeletedRelationships returns the relationships retained for a deletion.
+func (mds *Datastore) ReadDeletedRelationships(ctx context.Context, deletionID string) ([][]byte, error) {
+	query, args, err := sb.
+		Select(colDeletedRelationshipData).
+		From(mds.driver.DeletedRelationship()).
+		Where(squirrel.Eq{colDeletedRelationshipDeletion: deletionID}).
+		OrderBy(colDeletedRelationshipPosition).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("unable to prepare read deleted relationships sql: %w", err)
+	}
+
+	rows, err := mds.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read deleted relationships: %w", err)
+	}
+	defer common.LogOnError(ctx, rows.Close)
+
+	var relationships [][]byte
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("unable to read deleted relationship: %w", err)
+		}
+		relationships = append(relationships, data)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read deleted relationships: %w", err)
+	}
+
+	if len(relationships) == 0 {
+		return nil, common.ErrDeletionNotFound
+	}
+	return relationships, nil
+}
+
+// ForgetDeletedRelationships removes the relationships retained for a deletion.
+func (mds *Datastore) ForgetDeletedRelationships(ctx context.Context, deletionID string) error {
+	query, args, err := sb.
+		Delete(mds.driver.DeletedRelationship()).
+		Where(squirrel.Eq{colDeletedRelationshipDeletion: deletionID}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("unable to prepare forget deleted relationships sql: %w", err)
+	}
+
+	if _, err := mds.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("unable to forget deleted relationships: %w", err)
+	}
+	return nil
+}
+
+// PurgeDeletedRelationships removes the relationships retained for longer than the retention
+// period.
+func (mds *Datastore) PurgeDeletedRelationships(ctx context.Context, retention time.Duration) (int64, error) {
+	query, args, err := sb.
+		Delete(mds.driver.DeletedRelationship()).
+		Where(squirrel.Expr(colDeletedRelationshipDeletedAt+" < UTC_TIMESTAMP(6) - INTERVAL ? MICROSECOND", retention.Microseconds())).
+		ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("unable to prepare purge deleted relationships sql: %w", err)
+	}
+
+	result, err := mds.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("unable to purge deleted relationships: %w", err)
+	}
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("unable to purge deleted relationships: %w", err)
+	}
+	return purged, nil
+}
+
+var _ common.DeletedRelationshipStore = &Datastore{}
@@ -3,6 +3,7 @@ package mysql
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"time"
 
 	sq "github.com/Masterminds/squirrel"
@@ -57,14 +58,21 @@ func (mds *Datastore) TxIDBefore(ctx context.Context, before time.Time) (datasto
 	return revision.NewFromDecimal(decimal.NewFromInt(value.Int64)), nil
 }
 
+// GCController returns the controller pacing the garbage collection of the datastore.
+func (mds *Datastore) GCController() *common.GCController {
+	return mds.gcController
+}
+
 // TODO (@vroldanbet) dupe from postgres datastore - need to refactor
 // - implementation misses metrics
 func (mds *Datastore) DeleteBeforeTx(
 	ctx context.Context,
 	txID datastore.Revision,
 ) (removed common.DeletionCounts, err error) {
+	before := transactionFromRevision(txID.(revision.Decimal))
+
 	// Delete any relationship rows with deleted_transaction <= the transaction ID.
-	removed.Relationships, err = mds.batchDelete(ctx, mds.driver.RelationTuple(), sq.LtOrEq{colDeletedTxn: txID})
+	removed.Relationships, err = mds.parallelDelete(ctx, mds.driver.RelationTuple(), colDeletedTxn, before+1)
 	if err != nil {
 		return
 	}
@@ -73,41 +81,52 @@ func (mds *Datastore) DeleteBeforeTx(
 	//
 	// We don't delete the transaction itself to ensure there is always at least
 	// one transaction present.
-	removed.Transactions, err = mds.batchDelete(ctx, mds.driver.RelationTupleTransaction(), sq.Lt{colID: txID})
+	removed.Transactions, err = mds.parallelDelete(ctx, mds.driver.RelationTupleTransaction(), colID, before)
 	if err != nil {
 		return
 	}
 
 	// Delete any namespace rows with deleted_transaction <= the transaction ID.
-	removed.Namespaces, err = mds.batchDelete(ctx, mds.driver.Namespace(), sq.LtOrEq{colDeletedTxn: txID})
+	removed.Namespaces, err = mds.parallelDelete(ctx, mds.driver.Namespace(), colDeletedTxn, before+1)
 	return
 }
 
 // TODO (@vroldanbet) dupe from postgres datastore - need to refactor
 // - query was reworked to make it compatible with Vitess
 // - API differences with PSQL driver
-func (mds *Datastore) batchDelete(ctx context.Context, tableName string, filter sqlFilter) (int64, error) {
-	query, args, err := sb.Delete(tableName).Where(filter).Limit(batchDeleteSize).ToSql()
+func (mds *Datastore) parallelDelete(ctx context.Context, tableName string, keyCol string, before uint64) (int64, error) {
+	// Deletion starts from the lowest ID, rather than from zero, so that the ranges split
+	// across the workers cover the stale rows evenly.
+	query, args, err := sb.Select(keyCol).From(tableName).Where(sq.Lt{keyCol: before}).OrderBy(keyCol).Limit(1).ToSql()
 	if err != nil {
 		return -1, err
 	}
 
-	var deletedCount int64
-	for {
-		cr, err := mds.db.ExecContext(ctx, query, args...)
-		if err != nil {
-			return deletedCount, err
-		}
-
-		rowsDeleted, err := cr.RowsAffected()
-		if err != nil {
-			return deletedCount, err
-		}
-		deletedCount += rowsDeleted
-		if rowsDeleted < batchDeleteSize {
-			break
+	var lowest uint64
+	if err := mds.db.QueryRowContext(ctx, query, args...).Scan(&lowest); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
 		}
+		return -1, err
 	}
 
-	return deletedCount, nil
+	return mds.gcController.DeleteInParallel(ctx, lowest, before,
+		func(ctx context.Context, keys common.KeyRange, limit uint64) (int64, error) {
+			query, args, err := sb.Delete(tableName).
+				Where(sq.GtOrEq{keyCol: keys.Start}).
+				Where(sq.Lt{keyCol: keys.End}).
+				Limit(limit).
+				ToSql()
+			if err != nil {
+				return -1, err
+			}
+
+			cr, err := mds.db.ExecContext(ctx, query, args...)
+			if err != nil {
+				return 0, err
+			}
+			return cr.RowsAffected()
+		},
+		isErrorRetryable,
+	)
 }
@@ -0,0 +1,110 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Masterminds/squirrel"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
+)
+
+const (
+	colJobID      = "id"
+	colJobVersion = "version"
+	colJobData    = "data"
+)
+
+// CreateJob stores a new job.
+func (mds *Datastore) CreateJob(ctx context.Context, id string, data []byte) error {
+	query, args, err := sb.
+		Insert(mds.driver.Job()).
+		Columns(colJobID, colJobVersion, colJobData).
+		Values(id, 1, data).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("unable to prepare create job sql: %w", err)
+	}
+
+	if _, err := mds.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("unable to create job: %w", err)
+	}
+	return nil
+}
+
+// ReadJob returns the stored state of a job.
+func (mds *Datastore) ReadJob(ctx context.Context, id string) (common.StoredJob, error) {
+	query, args, err := sb.
+		Select(colJobID, colJobVersion, colJobData).
+		From(mds.driver.Job()).
+		Where(squirrel.Eq{colJobID: id}).
+		ToSql()
+	if err != nil {
+		return common.StoredJob{}, fmt.Errorf("unable to prepare read job sql: %w", err)
+	}
+
+	var job common.StoredJob
+	if err := mds.db.QueryRowContext(ctx, query, args...).Scan(&job.ID, &job.Version, &job.Data); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return common.StoredJob{}, common.ErrJobNotFound
+		}
+		return common.StoredJob{}, fmt.Errorf("unable to read job: %w", err)
+	}
+	return job, nil
+}
+
+// ListJobs returns the stored state of every job.
+func (mds *Datastore) ListJobs(ctx context.Context) ([]common.StoredJob, error) {
+	query, args, err := sb.
+		Select(colJobID, colJobVersion, colJobData).
+		From(mds.driver.Job()).
+		OrderBy(colJobID).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("unable to prepare list jobs sql: %w", err)
+	}
+
+	rows, err := mds.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list jobs: %w", err)
+	}
+	defer common.LogOnError(ctx, rows.Close)
+
+	var jobs []common.StoredJob
+	for rows.Next() {
+		var job common.StoredJob
+		if err := rows.Scan(&job.ID, &job.Version, &job.Data); err != nil {
+			return nil, fmt.Errorf("unable to read job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// UpdateJob replaces the state of a job if it is still at the given version.
+func (mds *Datastore) UpdateJob(ctx context.Context, id string, version uint64, data []byte) (bool, error) {
+	query, args, err := sb.
+		Update(mds.driver.Job()).
+		Set(colJobVersion, version+1).
+		Set(colJobData, data).
+		Where(squirrel.Eq{colJobID: id, colJobVersion: version}).
+		ToSql()
+	if err != nil {
+		return false, fmt.Errorf("unable to prepare update job sql: %w", err)
+	}
+
+	result, err := mds.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, fmt.Errorf("unable to update job: %w", err)
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("unable to update job: %w", err)
+	}
+	return updated == 1, nil
+}
+
+var _ common.JobStore = &Datastore{}
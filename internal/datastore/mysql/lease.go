@@ -0,0 +1,84 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
+)
+
+const (
+	colLeaseName      = "name"
+	colLeaseHolder    = "holder"
+	colLeaseExpiresAt = "expires_at"
+)
+
+func leaseExpiry(duration time.Duration) squirrel.Sqlizer {
+	return squirrel.Expr("UTC_TIMESTAMP(6) + INTERVAL ? MICROSECOND", duration.Microseconds())
+}
+
+// AcquireLease takes or renews the named lease for the holder. The lease is first updated if it
+// is held by the holder or has expired, and otherwise inserted if it does not exist.
+func (mds *Datastore) AcquireLease(ctx context.Context, name, holder string, duration time.Duration) (bool, error) {
+	query, args, err := sb.
+		Update(mds.driver.Lease()).
+		Set(colLeaseHolder, holder).
+		Set(colLeaseExpiresAt, leaseExpiry(duration)).
+		Where(squirrel.Eq{colLeaseName: name}).
+		Where(squirrel.Or{squirrel.Eq{colLeaseHolder: holder}, squirrel.Expr(colLeaseExpiresAt + " < UTC_TIMESTAMP(6)")}).
+		ToSql()
+	if err != nil {
+		return false, fmt.Errorf("unable to prepare renew lease sql: %w", err)
+	}
+
+	result, err := mds.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, fmt.Errorf("unable to renew lease: %w", err)
+	}
+	if updated, err := result.RowsAffected(); err != nil {
+		return false, fmt.Errorf("unable to renew lease: %w", err)
+	} else if updated == 1 {
+		return true, nil
+	}
+
+	query, args, err = sb.
+		Insert(mds.driver.Lease()).
+		Options("IGNORE").
+		Columns(colLeaseName, colLeaseHolder, colLeaseExpiresAt).
+		Values(name, holder, leaseExpiry(duration)).
+		ToSql()
+	if err != nil {
+		return false, fmt.Errorf("unable to prepare insert lease sql: %w", err)
+	}
+
+	result, err = mds.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, fmt.Errorf("unable to insert lease: %w", err)
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("unable to insert lease: %w", err)
+	}
+	return inserted == 1, nil
+}
+
+// ReleaseLease frees the named lease, if it is held by the holder.
+func (mds *Datastore) ReleaseLease(ctx context.Context, name, holder string) error {
+	query, args, err := sb.
+		Delete(mds.driver.Lease()).
+		Where(squirrel.Eq{colLeaseName: name, colLeaseHolder: holder}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("unable to prepare release lease sql: %w", err)
+	}
+
+	if _, err := mds.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("unable to release lease: %w", err)
+	}
+	return nil
+}
+
+var _ common.LeaseStore = &Datastore{}
@@ -1,31 +1,43 @@
 package migrations
 
 const (
-	tableNamespaceDefault   = "namespace_config"
-	tableTransactionDefault = "relation_tuple_transaction"
-	tableTupleDefault       = "relation_tuple"
-	tableMigrationVersion   = "mysql_migration_version"
-	tableMetadataDefault    = "mysql_metadata"
-	tableCaveatDefault      = "caveat"
+	tableNamespaceDefault           = "namespace_config"
+	tableTransactionDefault         = "relation_tuple_transaction"
+	tableTupleDefault               = "relation_tuple"
+	tableMigrationVersion           = "mysql_migration_version"
+	tableMetadataDefault            = "mysql_metadata"
+	tableCaveatDefault              = "caveat"
+	tableSchemaVersionDefault       = "schema_version"
+	tableJobDefault                 = "job"
+	tableLeaseDefault               = "lease"
+	tableDeletedRelationshipDefault = "deleted_relationship"
 )
 
 type tables struct {
-	tableMigrationVersion string
-	tableTransaction      string
-	tableTuple            string
-	tableNamespace        string
-	tableMetadata         string
-	tableCaveat           string
+	tableMigrationVersion    string
+	tableTransaction         string
+	tableTuple               string
+	tableNamespace           string
+	tableMetadata            string
+	tableCaveat              string
+	tableSchemaVersion       string
+	tableJob                 string
+	tableLease               string
+	tableDeletedRelationship string
 }
 
 func newTables(prefix string) *tables {
 	return &tables{
-		tableMigrationVersion: prefix + tableMigrationVersion,
-		tableTransaction:      prefix + tableTransactionDefault,
-		tableTuple:            prefix + tableTupleDefault,
-		tableNamespace:        prefix + tableNamespaceDefault,
-		tableMetadata:         prefix + tableMetadataDefault,
-		tableCaveat:           prefix + tableCaveatDefault,
+		tableMigrationVersion:    prefix + tableMigrationVersion,
+		tableTransaction:         prefix + tableTransactionDefault,
+		tableTuple:               prefix + tableTupleDefault,
+		tableNamespace:           prefix + tableNamespaceDefault,
+		tableMetadata:            prefix + tableMetadataDefault,
+		tableCaveat:              prefix + tableCaveatDefault,
+		tableSchemaVersion:       prefix + tableSchemaVersionDefault,
+		tableJob:                 prefix + tableJobDefault,
+		tableLease:               prefix + tableLeaseDefault,
+		tableDeletedRelationship: prefix + tableDeletedRelationshipDefault,
 	}
 }
 
@@ -55,3 +67,23 @@ func (tn *tables) Metadata() string {
 func (tn *tables) Caveat() string {
 	return tn.tableCaveat
 }
+
+// SchemaVersion returns the prefixed schema version table name.
+func (tn *tables) SchemaVersion() string {
+	return tn.tableSchemaVersion
+}
+
+// Job returns the prefixed job table name.
+func (tn *tables) Job() string {
+	return tn.tableJob
+}
+
+// Lease returns the prefixed lease table name.
+func (tn *tables) Lease() string {
+	return tn.tableLease
+}
+
+// DeletedRelationship returns the prefixed deleted relationship table name.
+func (tn *tables) DeletedRelationship() string {
+	return tn.tableDeletedRelationship
+}
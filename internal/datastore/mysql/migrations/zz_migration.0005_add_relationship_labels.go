@@ -0,0 +1,18 @@
+package migrations
+
+import "fmt"
+
+func addLabelsToRelationTuplesTable(t *tables) string {
+	return fmt.Sprintf(`ALTER TABLE %s
+			ADD COLUMN labels JSON;`,
+		t.RelationTuple(),
+	)
+}
+
+func init() {
+	mustRegisterMigration("add_relationship_labels", "add_caveat", noNonatomicMigration,
+		newStatementBatch(
+			addLabelsToRelationTuplesTable,
+		).execute,
+	)
+}
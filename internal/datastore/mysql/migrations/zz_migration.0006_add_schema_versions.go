@@ -0,0 +1,22 @@
+package migrations
+
+import "fmt"
+
+func createSchemaVersionTable(t *tables) string {
+	return fmt.Sprintf(`CREATE TABLE %s (
+		version BIGINT NOT NULL,
+		definition LONGBLOB NOT NULL,
+		created_transaction BIGINT NOT NULL,
+		deleted_transaction BIGINT NOT NULL DEFAULT '9223372036854775807',
+		CONSTRAINT pk_schema_version PRIMARY KEY (version)) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
+		t.SchemaVersion(),
+	)
+}
+
+func init() {
+	mustRegisterMigration("add_schema_versions", "add_relationship_labels", noNonatomicMigration,
+		newStatementBatch(
+			createSchemaVersionTable,
+		).execute,
+	)
+}
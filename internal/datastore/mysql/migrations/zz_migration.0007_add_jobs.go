@@ -0,0 +1,21 @@
+package migrations
+
+import "fmt"
+
+func createJobTable(t *tables) string {
+	return fmt.Sprintf(`CREATE TABLE %s (
+		id VARCHAR(128) NOT NULL,
+		version BIGINT NOT NULL,
+		data LONGBLOB NOT NULL,
+		CONSTRAINT pk_job PRIMARY KEY (id)) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
+		t.Job(),
+	)
+}
+
+func init() {
+	mustRegisterMigration("add_jobs", "add_schema_versions", noNonatomicMigration,
+		newStatementBatch(
+			createJobTable,
+		).execute,
+	)
+}
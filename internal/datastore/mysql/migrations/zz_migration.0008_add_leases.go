@@ -0,0 +1,21 @@
+package migrations
+
+import "fmt"
+
+func createLeaseTable(t *tables) string {
+	return fmt.Sprintf(`CREATE TABLE %s (
+		name VARCHAR(128) NOT NULL,
+		holder VARCHAR(128) NOT NULL,
+		expires_at DATETIME(6) NOT NULL,
+		CONSTRAINT pk_lease PRIMARY KEY (name)) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
+		t.Lease(),
+	)
+}
+
+func init() {
+	mustRegisterMigration("add_leases", "add_jobs", noNonatomicMigration,
+		newStatementBatch(
+			createLeaseTable,
+		).execute,
+	)
+}
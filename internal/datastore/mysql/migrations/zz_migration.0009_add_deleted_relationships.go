@@ -0,0 +1,23 @@
+package migrations
+
+import "fmt"
+
+func createDeletedRelationshipTable(t *tables) string {
+	return fmt.Sprintf(`CREATE TABLE %s (
+		deletion_id VARCHAR(128) NOT NULL,
+		position BIGINT NOT NULL,
+		deleted_at DATETIME(6) NOT NULL,
+		data LONGBLOB NOT NULL,
+		CONSTRAINT pk_deleted_relationship PRIMARY KEY (deletion_id, position),
+		INDEX ix_deleted_relationship_deleted_at (deleted_at)) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
+		t.DeletedRelationship(),
+	)
+}
+
+func init() {
+	mustRegisterMigration("add_deleted_relationships", "add_leases", noNonatomicMigration,
+		newStatementBatch(
+			createDeletedRelationshipTable,
+		).execute,
+	)
+}
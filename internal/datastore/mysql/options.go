@@ -3,6 +3,8 @@ package mysql
 import (
 	"fmt"
 	"time"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
 )
 
 const (
@@ -17,6 +19,8 @@ const (
 	defaultWatchBufferLength                 = 128
 	defaultUsersetBatchSize                  = 1024
 	defaultQuantization                      = 5 * time.Second
+	defaultMinRevisionQuantization           = 100 * time.Millisecond
+	defaultFullQuantizationWriteRate         = 100
 	defaultMaxRevisionStalenessPercent       = 0.1
 	defaultEnablePrometheusStats             = false
 	defaultMaxRetries                        = 8
@@ -25,9 +29,13 @@ const (
 
 type mysqlOptions struct {
 	revisionQuantization        time.Duration
+	adaptiveQuantization        bool
+	minRevisionQuantization     time.Duration
+	fullQuantizationWriteRate   float64
 	gcWindow                    time.Duration
 	gcInterval                  time.Duration
 	gcMaxOperationTime          time.Duration
+	gcTuning                    common.GCTuning
 	maxRevisionStalenessPercent float64
 	watchBufferLength           uint16
 	tablePrefix                 string
@@ -36,10 +44,12 @@ type mysqlOptions struct {
 	connMaxIdleTime             time.Duration
 	connMaxLifetime             time.Duration
 	splitAtUsersetCount         uint16
+	queryLogSampleRate          float64
 	analyzeBeforeStats          bool
 	maxRetries                  uint8
 	lockWaitTimeoutSeconds      *uint8
 	gcEnabled                   bool
+	gcLeaderElection            bool
 }
 
 // Option provides the facility to configure how clients within the
@@ -48,9 +58,12 @@ type Option func(*mysqlOptions)
 
 func generateConfig(options []Option) (mysqlOptions, error) {
 	computed := mysqlOptions{
+		minRevisionQuantization:     defaultMinRevisionQuantization,
+		fullQuantizationWriteRate:   defaultFullQuantizationWriteRate,
 		gcWindow:                    defaultGarbageCollectionWindow,
 		gcInterval:                  defaultGarbageCollectionInterval,
 		gcMaxOperationTime:          defaultGarbageCollectionMaxOperationTime,
+		gcTuning:                    common.DefaultGCTuning,
 		watchBufferLength:           defaultWatchBufferLength,
 		maxOpenConns:                defaultMaxOpenConns,
 		connMaxIdleTime:             defaultConnMaxIdleTime,
@@ -99,6 +112,37 @@ func RevisionQuantization(quantization time.Duration) Option {
 	}
 }
 
+// AdaptiveRevisionQuantization adapts the revision quantization window to the rate of writes,
+// from MinRevisionQuantization when there are no writes up to RevisionQuantization at
+// FullQuantizationWriteRate.
+//
+// This defaults to false.
+func AdaptiveRevisionQuantization(enabled bool) Option {
+	return func(mo *mysqlOptions) {
+		mo.adaptiveQuantization = enabled
+	}
+}
+
+// MinRevisionQuantization is the shortest revision quantization window used when adapting it
+// to the rate of writes.
+//
+// This defaults to 100 milliseconds.
+func MinRevisionQuantization(minimum time.Duration) Option {
+	return func(mo *mysqlOptions) {
+		mo.minRevisionQuantization = minimum
+	}
+}
+
+// FullQuantizationWriteRate is the number of writes per second at and above which the full
+// revision quantization window is used when adapting it to the rate of writes.
+//
+// This defaults to 100.
+func FullQuantizationWriteRate(writesPerSecond float64) Option {
+	return func(mo *mysqlOptions) {
+		mo.fullQuantizationWriteRate = writesPerSecond
+	}
+}
+
 // MaxRevisionStalenessPercent is the amount of time, expressed as a percentage of
 // the revision quantization window, that a previously computed rounded revision
 // can still be advertised after the next rounded revision would otherwise be ready.
@@ -158,6 +202,17 @@ func SplitAtUsersetCount(splitAtUsersetCount uint16) Option {
 	}
 }
 
+// QueryLogSampleRate is the fraction, between 0 and 1, of the relationship queries
+// which are logged with their normalized statement, number of parameters, number of
+// rows returned and duration.
+//
+// This defaults to 0, logging no queries.
+func QueryLogSampleRate(sampleRate float64) Option {
+	return func(mo *mysqlOptions) {
+		mo.queryLogSampleRate = sampleRate
+	}
+}
+
 // WithEnablePrometheusStats marks whether Prometheus metrics provided by Go's database/sql package
 // are enabled.
 //
@@ -231,6 +286,16 @@ func GCEnabled(isGCEnabled bool) Option {
 	}
 }
 
+// GCLeaderElection indicates whether garbage collection is run by a single replica at a time,
+// elected by a lease held in the datastore, rather than by every replica.
+//
+// Leader election is disabled by default.
+func GCLeaderElection(enabled bool) Option {
+	return func(mo *mysqlOptions) {
+		mo.gcLeaderElection = enabled
+	}
+}
+
 // GCMaxOperationTime is the maximum operation time of a garbage collection
 // pass before it times out.
 //
@@ -240,3 +305,12 @@ func GCMaxOperationTime(time time.Duration) Option {
 		mo.gcMaxOperationTime = time
 	}
 }
+
+// GCTuning configures the parallelism and batch sizing of garbage collection.
+//
+// This value defaults to common.DefaultGCTuning.
+func GCTuning(tuning common.GCTuning) Option {
+	return func(mo *mysqlOptions) {
+		mo.gcTuning = tuning
+	}
+}
@@ -1,6 +1,8 @@
 package mysql
 
 import (
+	"fmt"
+
 	"github.com/authzed/spicedb/internal/datastore/mysql/migrations"
 
 	sq "github.com/Masterminds/squirrel"
@@ -9,8 +11,9 @@ import (
 // QueryBuilder captures all parameterizable queries used
 // by the MySQL datastore implementation
 type QueryBuilder struct {
-	GetLastRevision  sq.SelectBuilder
-	GetRevisionRange sq.SelectBuilder
+	GetLastRevision      sq.SelectBuilder
+	GetRevisionRange     sq.SelectBuilder
+	GetRevisionTimestamp sq.SelectBuilder
 
 	WriteNamespaceQuery        sq.InsertBuilder
 	ReadNamespaceQuery         sq.SelectBuilder
@@ -29,6 +32,10 @@ type QueryBuilder struct {
 	ReadCaveatQuery   sq.SelectBuilder
 	ListCaveatsQuery  sq.SelectBuilder
 	DeleteCaveatQuery sq.UpdateBuilder
+
+	WriteSchemaVersionQuery  sq.InsertBuilder
+	ListSchemaVersionsQuery  sq.SelectBuilder
+	LatestSchemaVersionQuery sq.SelectBuilder
 }
 
 // NewQueryBuilder returns a new QueryBuilder instance. The migration
@@ -39,6 +46,7 @@ func NewQueryBuilder(driver *migrations.MySQLDriver) *QueryBuilder {
 	// transaction builders
 	builder.GetLastRevision = getLastRevision(driver.RelationTupleTransaction())
 	builder.GetRevisionRange = getRevisionRange(driver.RelationTupleTransaction())
+	builder.GetRevisionTimestamp = getRevisionTimestamp(driver.RelationTupleTransaction())
 
 	// namespace builders
 	builder.WriteNamespaceQuery = writeNamespace(driver.Namespace())
@@ -61,6 +69,11 @@ func NewQueryBuilder(driver *migrations.MySQLDriver) *QueryBuilder {
 	builder.WriteCaveatQuery = writeCaveat(driver.Caveat())
 	builder.DeleteCaveatQuery = deleteCaveat(driver.Caveat())
 
+	// schema version builders
+	builder.WriteSchemaVersionQuery = writeSchemaVersion(driver.SchemaVersion())
+	builder.ListSchemaVersionsQuery = listSchemaVersions(driver.SchemaVersion())
+	builder.LatestSchemaVersionQuery = latestSchemaVersion(driver.SchemaVersion())
+
 	return &builder
 }
 
@@ -84,6 +97,22 @@ func readCaveat(tableCaveat string) sq.SelectBuilder {
 	return sb.Select(colCaveatDefinition, colCreatedTxn).From(tableCaveat)
 }
 
+func writeSchemaVersion(tableSchemaVersion string) sq.InsertBuilder {
+	return sb.Insert(tableSchemaVersion).Columns(
+		colSchemaVersion,
+		colCaveatDefinition,
+		colCreatedTxn,
+	)
+}
+
+func listSchemaVersions(tableSchemaVersion string) sq.SelectBuilder {
+	return sb.Select(colCaveatDefinition).From(tableSchemaVersion).OrderBy(colSchemaVersion)
+}
+
+func latestSchemaVersion(tableSchemaVersion string) sq.SelectBuilder {
+	return sb.Select(fmt.Sprintf("COALESCE(MAX(%s), 0)", colSchemaVersion)).From(tableSchemaVersion)
+}
+
 func getLastRevision(tableTransaction string) sq.SelectBuilder {
 	return sb.Select("MAX(id)").From(tableTransaction).Limit(1)
 }
@@ -92,6 +121,10 @@ func getRevisionRange(tableTransaction string) sq.SelectBuilder {
 	return sb.Select("MIN(id)", "MAX(id)").From(tableTransaction)
 }
 
+func getRevisionTimestamp(tableTransaction string) sq.SelectBuilder {
+	return sb.Select(colTimestamp).From(tableTransaction)
+}
+
 func writeNamespace(tableNamespace string) sq.InsertBuilder {
 	return sb.Insert(tableNamespace).Columns(
 		colNamespace,
@@ -128,6 +161,7 @@ func queryTuples(tableTuple string) sq.SelectBuilder {
 		colUsersetRelation,
 		colCaveatName,
 		colCaveatContext,
+		colLabels,
 	).From(tableTuple)
 }
 
@@ -155,6 +189,7 @@ func writeTuple(tableTuple string) sq.InsertBuilder {
 		colUsersetRelation,
 		colCaveatName,
 		colCaveatContext,
+		colLabels,
 		colCreatedTxn,
 	)
 }
@@ -169,6 +204,7 @@ func queryChanged(tableTuple string) sq.SelectBuilder {
 		colUsersetRelation,
 		colCaveatName,
 		colCaveatContext,
+		colLabels,
 		colCreatedTxn,
 		colDeletedTxn,
 	).From(tableTuple)
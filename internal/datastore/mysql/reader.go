@@ -3,6 +3,7 @@ package mysql
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 
@@ -45,6 +46,16 @@ var schema = common.SchemaInformation{
 	ColUsersetObjectID:  colUsersetObjectID,
 	ColUsersetRelation:  colUsersetRelation,
 	ColCaveatName:       colCaveatName,
+	ColLabels:           colLabels,
+	MatchLabels:         matchLabels,
+}
+
+// matchLabels returns a clause matching the rows whose labels, stored in the given JSON column,
+// include all of the given labels.
+func matchLabels(colLabels string, labels map[string]string) sq.Sqlizer {
+	// Marshaling a map of strings cannot fail.
+	encoded, _ := json.Marshal(labels)
+	return sq.Expr("JSON_CONTAINS("+colLabels+", ?)", string(encoded))
 }
 
 func (mr *mysqlReader) QueryRelationships(
@@ -81,6 +92,10 @@ func (mr *mysqlReader) ReverseQueryRelationships(
 			FilterToRelation(queryOpts.ResRelation.Relation)
 	}
 
+	if queryOpts.ResIDPrefix != "" {
+		qBuilder = qBuilder.FilterToResourceIDPrefix(queryOpts.ResIDPrefix)
+	}
+
 	return mr.querySplitter.SplitAndExecuteQuery(
 		ctx,
 		qBuilder,
@@ -52,6 +52,26 @@ func (cc *caveatContextWrapper) Value() (driver.Value, error) {
 	return json.Marshal(&cc)
 }
 
+// labelsWrapper is used to marshall relationship labels into MySQLs JSON data type
+type labelsWrapper map[string]string
+
+func (lw *labelsWrapper) Scan(val any) error {
+	if val == nil {
+		*lw = nil
+		return nil
+	}
+
+	v, ok := val.([]byte)
+	if !ok {
+		return fmt.Errorf("unsupported type: %T", v)
+	}
+	return json.Unmarshal(v, &lw)
+}
+
+func (lw *labelsWrapper) Value() (driver.Value, error) {
+	return json.Marshal(&lw)
+}
+
 // WriteRelationships takes a list of existing relationships that must exist, and a list of
 // tuple mutations and applies it to the datastore for the specified namespace.
 func (rwt *mysqlReadWriteTXN) WriteRelationships(ctx context.Context, mutations []*core.RelationTupleUpdate) error {
@@ -90,6 +110,7 @@ func (rwt *mysqlReadWriteTXN) WriteRelationships(ctx context.Context, mutations
 				tpl.Subject.Relation,
 				caveatName,
 				&caveatContext,
+				(*labelsWrapper)(&tpl.Labels),
 				rwt.newTxnID,
 			)
 			bulkWriteHasValues = true
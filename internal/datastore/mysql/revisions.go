@@ -8,6 +8,7 @@ import (
 	"math/big"
 	"time"
 
+	sq "github.com/Masterminds/squirrel"
 	"github.com/shopspring/decimal"
 
 	"github.com/authzed/spicedb/pkg/datastore"
@@ -58,10 +59,31 @@ const (
 		) as unknown;`
 )
 
+// selectRevisionQuery returns the query selecting the optimized revision from the transaction
+// table for the quantization.
+func selectRevisionQuery(transactionTable string, quantization time.Duration) string {
+	quantizationPeriodNanos := quantization.Nanoseconds()
+	if quantizationPeriodNanos < 1 {
+		quantizationPeriodNanos = 1
+	}
+	return fmt.Sprintf(
+		querySelectRevision,
+		colID,
+		transactionTable,
+		colTimestamp,
+		quantizationPeriodNanos,
+	)
+}
+
 func (mds *Datastore) optimizedRevisionFunc(ctx context.Context) (datastore.Revision, time.Duration, error) {
+	query := mds.optimizedRevisionQuery
+	if mds.adaptiveQuantization != nil {
+		query = selectRevisionQuery(mds.driver.RelationTupleTransaction(), mds.adaptiveQuantization.Window())
+	}
+
 	var rev uint64
 	var validForNanos time.Duration
-	if err := mds.db.QueryRowContext(ctx, mds.optimizedRevisionQuery).
+	if err := mds.db.QueryRowContext(ctx, query).
 		Scan(&rev, &validForNanos); err != nil {
 		return revision.NoRevision, 0, fmt.Errorf(errRevision, err)
 	}
@@ -108,6 +130,54 @@ func (mds *Datastore) CheckRevision(ctx context.Context, revisionRaw datastore.R
 	return nil
 }
 
+// RevisionAtTime returns the revision of the last transaction committed at or before the time.
+func (mds *Datastore) RevisionAtTime(ctx context.Context, at time.Time) (datastore.Revision, error) {
+	ctx, span := tracer.Start(ctx, "RevisionAtTime")
+	defer span.End()
+
+	query, args, err := mds.GetLastRevision.Where(sq.LtOrEq{colTimestamp: at.UTC()}).ToSql()
+	if err != nil {
+		return datastore.NoRevision, fmt.Errorf(errRevision, err)
+	}
+
+	var rev *uint64
+	if err := mds.db.QueryRowContext(ctx, query, args...).Scan(&rev); err != nil {
+		return datastore.NoRevision, fmt.Errorf(errRevision, err)
+	}
+	if rev == nil {
+		return datastore.NoRevision, datastore.NewInvalidRevisionErr(datastore.NoRevision, datastore.RevisionStale)
+	}
+
+	return revisionFromTransaction(*rev), nil
+}
+
+// RevisionTime returns the time at which the transaction of the revision was committed.
+func (mds *Datastore) RevisionTime(ctx context.Context, revisionRaw datastore.Revision) (time.Time, error) {
+	rev, ok := revisionRaw.(revision.Decimal)
+	if !ok {
+		return time.Time{}, datastore.NewInvalidRevisionErr(revisionRaw, datastore.CouldNotDetermineRevision)
+	}
+
+	return mds.revisionTimes.Get(rev, func() (time.Time, error) {
+		ctx, span := tracer.Start(ctx, "RevisionTime")
+		defer span.End()
+
+		query, args, err := mds.GetRevisionTimestamp.Where(sq.Eq{colID: transactionFromRevision(rev)}).ToSql()
+		if err != nil {
+			return time.Time{}, fmt.Errorf(errRevision, err)
+		}
+
+		var at time.Time
+		if err := mds.db.QueryRowContext(ctx, query, args...).Scan(&at); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return time.Time{}, datastore.NewInvalidRevisionErr(rev, datastore.RevisionStale)
+			}
+			return time.Time{}, fmt.Errorf(errRevision, err)
+		}
+		return at.UTC(), nil
+	})
+}
+
 func (mds *Datastore) loadRevision(ctx context.Context) (uint64, error) {
 	// TODO (@vroldanbet) dupe from postgres datastore - need to refactor
 	// slightly changed to support no revisions at all, needed for runtime seeding of first transaction
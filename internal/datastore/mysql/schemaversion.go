@@ -0,0 +1,97 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+)
+
+const (
+	errWriteSchemaVersion  = "unable to write schema version: %w"
+	errListSchemaVersions  = "unable to list schema versions: %w"
+	errLatestSchemaVersion = "unable to find latest schema version: %w"
+)
+
+func (mr *mysqlReader) ListSchemaVersions(ctx context.Context) ([]*core.SchemaVersion, error) {
+	listSQL, listArgs, err := mr.filterer(mr.ListSchemaVersionsQuery).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf(errListSchemaVersions, err)
+	}
+
+	tx, txCleanup, err := mr.txSource(ctx)
+	if err != nil {
+		return nil, fmt.Errorf(errListSchemaVersions, err)
+	}
+	defer common.LogOnError(ctx, txCleanup)
+
+	rows, err := tx.QueryContext(ctx, listSQL, listArgs...)
+	if err != nil {
+		return nil, fmt.Errorf(errListSchemaVersions, err)
+	}
+	defer common.LogOnError(ctx, rows.Close)
+
+	var versions []*core.SchemaVersion
+	for rows.Next() {
+		var defBytes []byte
+		if err := rows.Scan(&defBytes); err != nil {
+			return nil, fmt.Errorf(errListSchemaVersions, err)
+		}
+
+		version := &core.SchemaVersion{}
+		if err := version.UnmarshalVT(defBytes); err != nil {
+			return nil, fmt.Errorf(errListSchemaVersions, err)
+		}
+		versions = append(versions, version)
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf(errListSchemaVersions, rows.Err())
+	}
+
+	return versions, nil
+}
+
+func (mr *mysqlReader) LatestSchemaVersion(ctx context.Context) (uint64, error) {
+	latestSQL, latestArgs, err := mr.filterer(mr.LatestSchemaVersionQuery).ToSql()
+	if err != nil {
+		return 0, fmt.Errorf(errLatestSchemaVersion, err)
+	}
+
+	tx, txCleanup, err := mr.txSource(ctx)
+	if err != nil {
+		return 0, fmt.Errorf(errLatestSchemaVersion, err)
+	}
+	defer common.LogOnError(ctx, txCleanup)
+
+	var latest uint64
+	if err := tx.QueryRowContext(ctx, latestSQL, latestArgs...).Scan(&latest); err != nil {
+		return 0, fmt.Errorf(errLatestSchemaVersion, err)
+	}
+	return latest, nil
+}
+
+func (rwt *mysqlReadWriteTXN) WriteSchemaVersion(ctx context.Context, version *core.SchemaVersion) error {
+	serialized, err := version.MarshalVT()
+	if err != nil {
+		return fmt.Errorf(errWriteSchemaVersion, err)
+	}
+
+	writeSQL, writeArgs, err := rwt.WriteSchemaVersionQuery.Values(version.Version, serialized, rwt.newTxnID).ToSql()
+	if err != nil {
+		return fmt.Errorf(errWriteSchemaVersion, err)
+	}
+
+	if _, err := rwt.tx.ExecContext(ctx, writeSQL, writeArgs...); err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == errMysqlDuplicateEntry {
+			return datastore.NewSchemaVersionExistsErr(version.Version)
+		}
+		return fmt.Errorf(errWriteSchemaVersion, err)
+	}
+	return nil
+}
@@ -92,3 +92,34 @@ func (mds *Datastore) getUniqueID(ctx context.Context) (string, error) {
 
 	return uniqueID, nil
 }
+
+// CountRelationshipsByRelation counts the live relationships of each relation.
+func (mds *Datastore) CountRelationshipsByRelation(ctx context.Context) ([]common.RelationCount, error) {
+	query, args, err := sb.
+		Select(colNamespace, colRelation, "COUNT(*)").
+		From(mds.driver.RelationTuple()).
+		Where(squirrel.Eq{colDeletedTxn: liveDeletedTxnID}).
+		GroupBy(colNamespace, colRelation).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("unable to prepare relation counts sql: %w", err)
+	}
+
+	rows, err := mds.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to count relationships: %w", err)
+	}
+	defer common.LogOnError(ctx, rows.Close)
+
+	var counts []common.RelationCount
+	for rows.Next() {
+		var count common.RelationCount
+		if err := rows.Scan(&count.Namespace, &count.Relation, &count.Count); err != nil {
+			return nil, fmt.Errorf("unable to read relation count: %w", err)
+		}
+		counts = append(counts, count)
+	}
+	return counts, rows.Err()
+}
+
+var _ common.RelationCounter = &Datastore{}
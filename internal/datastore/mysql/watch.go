@@ -126,6 +126,7 @@ func (mds *Datastore) loadChanges(
 		var deletedTxn uint64
 		var caveatName string
 		var caveatContext caveatContextWrapper
+		var labels labelsWrapper
 		err = rows.Scan(
 			&nextTuple.ResourceAndRelation.Namespace,
 			&nextTuple.ResourceAndRelation.ObjectId,
@@ -135,6 +136,7 @@ func (mds *Datastore) loadChanges(
 			&nextTuple.Subject.Relation,
 			&caveatName,
 			&caveatContext,
+			&labels,
 			&createdTxn,
 			&deletedTxn,
 		)
@@ -145,6 +147,7 @@ func (mds *Datastore) loadChanges(
 		if err != nil {
 			return
 		}
+		nextTuple.Labels = labels
 
 		if createdTxn > afterRevision && createdTxn <= newRevision {
 			stagedChanges.AddChange(ctx, revisionFromTransaction(createdTxn), nextTuple, core.RelationTupleUpdate_TOUCH)
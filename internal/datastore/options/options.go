@@ -16,6 +16,10 @@ type QueryOptions struct {
 type ReverseQueryOptions struct {
 	ReverseLimit *uint64
 	ResRelation  *ResourceRelation
+
+	// ResIDPrefix, if not empty, limits the results to resources whose IDs start with it. It
+	// requires ResRelation.
+	ResIDPrefix string
 }
 
 // ResourceRelation combines a resource object type and relation.
@@ -67,6 +67,7 @@ func (r *ReverseQueryOptions) ToOption() ReverseQueryOptionsOption {
 	return func(to *ReverseQueryOptions) {
 		to.ReverseLimit = r.ReverseLimit
 		to.ResRelation = r.ResRelation
+		to.ResIDPrefix = r.ResIDPrefix
 	}
 }
 
@@ -91,3 +92,10 @@ func WithResRelation(resRelation *ResourceRelation) ReverseQueryOptionsOption {
 		r.ResRelation = resRelation
 	}
 }
+
+// WithResIDPrefix returns an option that can set ResIDPrefix on a ReverseQueryOptions
+func WithResIDPrefix(resIDPrefix string) ReverseQueryOptionsOption {
+	return func(r *ReverseQueryOptions) {
+		r.ResIDPrefix = resIDPrefix
+	}
+}
@@ -0,0 +1,237 @@
+package common
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/rds/rdsutils"
+	"github.com/jackc/pgx/v4"
+	"golang.org/x/oauth2/google"
+
+	"github.com/authzed/spicedb/internal/hotreload"
+)
+
+// AuthMethod is the method by which connections obtain their password.
+type AuthMethod string
+
+const (
+	// AuthMethodPassword uses the password of the connection URI, if any.
+	AuthMethodPassword AuthMethod = "password"
+
+	// AuthMethodAWSIAM uses an AWS RDS IAM authentication token, generated for each connection
+	// with the credentials of the environment.
+	AuthMethodAWSIAM AuthMethod = "aws-iam"
+
+	// AuthMethodGCPIAM uses an OAuth2 access token for GCP Cloud SQL IAM database
+	// authentication, obtained and refreshed with the application default credentials.
+	AuthMethodGCPIAM AuthMethod = "gcp-iam"
+)
+
+// AuthMethods is the list of supported authentication methods.
+var AuthMethods = []string{string(AuthMethodPassword), string(AuthMethodAWSIAM), string(AuthMethodGCPIAM)}
+
+const cloudSQLLoginScope = "https://www.googleapis.com/auth/sqlservice.login"
+
+// AuthConfig configures how connections authenticate beyond the credentials of the connection
+// URI.
+type AuthConfig struct {
+	// Method is the method by which connections obtain their password. Defaults to the
+	// password of the connection URI.
+	Method AuthMethod
+
+	// AWSRegion is the region of the RDS instance, for AuthMethodAWSIAM. Defaults to the region
+	// of the environment.
+	AWSRegion string
+
+	// PasswordPath is the path of a file containing the password, for AuthMethodPassword.
+	// Unlike the password of the connection URI, it is reloaded whenever it changes, so that
+	// the password can be rotated without restart.
+	PasswordPath string
+
+	// ClientCertPath and ClientKeyPath are the paths of the client certificate and key
+	// presented to the database. Unlike the `sslcert` and `sslkey` of the connection URI, they
+	// are reloaded whenever they change.
+	ClientCertPath string
+	ClientKeyPath  string
+}
+
+// Authenticator authenticates the connections made with a connection config, as configured by
+// an AuthConfig.
+type Authenticator struct {
+	password func(*pgx.ConnConfig) (string, error)
+	closers  []func() error
+}
+
+// NewAuthenticator configures the TLS of the connection config according to the auth config,
+// and returns the authenticator of its connections, whose BeforeConnect must be called before
+// each connection is made.
+func NewAuthenticator(connConfig *pgx.ConnConfig, config AuthConfig) (*Authenticator, error) {
+	a := &Authenticator{}
+	if err := a.configure(connConfig, config); err != nil {
+		_ = a.Close()
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *Authenticator) configure(connConfig *pgx.ConnConfig, config AuthConfig) error {
+	if config.ClientCertPath != "" || config.ClientKeyPath != "" {
+		if err := a.configureClientCertificate(connConfig, config.ClientCertPath, config.ClientKeyPath); err != nil {
+			return err
+		}
+	}
+
+	if config.PasswordPath != "" && config.Method != "" && config.Method != AuthMethodPassword {
+		return fmt.Errorf("a password file cannot be used with the %s auth method", config.Method)
+	}
+
+	switch config.Method {
+	case "", AuthMethodPassword:
+		if config.PasswordPath == "" {
+			return nil
+		}
+
+		passwordFile, err := hotreload.NewFile("datastore-password", func() (string, error) {
+			contents, err := os.ReadFile(config.PasswordPath)
+			if err != nil {
+				return "", err
+			}
+			return strings.TrimRight(string(contents), "\r\n"), nil
+		}, config.PasswordPath)
+		if err != nil {
+			return err
+		}
+		a.closers = append(a.closers, passwordFile.Close)
+
+		a.password = func(*pgx.ConnConfig) (string, error) {
+			return passwordFile.Load(), nil
+		}
+		return nil
+
+	case AuthMethodAWSIAM:
+		sessionConfig := aws.NewConfig()
+		if config.AWSRegion != "" {
+			sessionConfig = sessionConfig.WithRegion(config.AWSRegion)
+		}
+
+		sess, err := session.NewSessionWithOptions(session.Options{
+			Config:            *sessionConfig,
+			SharedConfigState: session.SharedConfigEnable,
+		})
+		if err != nil {
+			return fmt.Errorf("unable to load aws credentials: %w", err)
+		}
+
+		region := aws.StringValue(sess.Config.Region)
+		if region == "" {
+			return fmt.Errorf("an aws region must be configured for aws iam authentication")
+		}
+
+		credentials := sess.Config.Credentials
+		a.password = func(cc *pgx.ConnConfig) (string, error) {
+			// Tokens are signed locally and valid for 15 minutes, so a fresh token is generated
+			// for every connection.
+			endpoint := net.JoinHostPort(cc.Host, strconv.Itoa(int(cc.Port)))
+			token, err := rdsutils.BuildAuthToken(endpoint, region, cc.User, credentials)
+			if err != nil {
+				return "", fmt.Errorf("unable to build aws iam auth token: %w", err)
+			}
+			return token, nil
+		}
+		return nil
+
+	case AuthMethodGCPIAM:
+		// The token source caches the access token and refreshes it before it expires.
+		tokenSource, err := google.DefaultTokenSource(context.Background(), cloudSQLLoginScope)
+		if err != nil {
+			return fmt.Errorf("unable to load gcp application default credentials: %w", err)
+		}
+
+		a.password = func(*pgx.ConnConfig) (string, error) {
+			token, err := tokenSource.Token()
+			if err != nil {
+				return "", fmt.Errorf("unable to obtain gcp iam access token: %w", err)
+			}
+			return token.AccessToken, nil
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown datastore auth method `%s`: must be one of %v", config.Method, AuthMethods)
+	}
+}
+
+// configureClientCertificate configures the TLS configs of the connection config to present the
+// client certificate at the given paths, reloading it whenever it changes.
+func (a *Authenticator) configureClientCertificate(connConfig *pgx.ConnConfig, certPath, keyPath string) error {
+	if certPath == "" || keyPath == "" {
+		return fmt.Errorf("both a client certificate and a client key must be specified")
+	}
+
+	tlsConfigs := []*tls.Config{connConfig.TLSConfig}
+	for _, fallback := range connConfig.Fallbacks {
+		tlsConfigs = append(tlsConfigs, fallback.TLSConfig)
+	}
+
+	var configured []*tls.Config
+	for _, tlsConfig := range tlsConfigs {
+		if tlsConfig != nil {
+			configured = append(configured, tlsConfig)
+		}
+	}
+
+	if len(configured) == 0 {
+		return fmt.Errorf("a client certificate requires TLS, which is disabled by the `sslmode` of the connection URI")
+	}
+
+	certFile, err := hotreload.NewFile("datastore-client-certificate", func() (*tls.Certificate, error) {
+		cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+		return &cert, err
+	}, certPath, keyPath)
+	if err != nil {
+		return err
+	}
+	a.closers = append(a.closers, certFile.Close)
+
+	for _, tlsConfig := range configured {
+		// GetClientCertificate takes precedence over any certificates loaded from the URI.
+		tlsConfig.Certificates = nil
+		tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return certFile.Load(), nil
+		}
+	}
+	return nil
+}
+
+// BeforeConnect sets the password of the connection about to be made, if it is not that of the
+// connection URI. It is meant to be set as the BeforeConnect of pools.
+func (a *Authenticator) BeforeConnect(_ context.Context, cc *pgx.ConnConfig) error {
+	if a.password == nil {
+		return nil
+	}
+
+	password, err := a.password(cc)
+	if err != nil {
+		return err
+	}
+	cc.Password = password
+	return nil
+}
+
+// Close stops reloading the files of the authenticator.
+func (a *Authenticator) Close() error {
+	var err error
+	for _, closer := range a.closers {
+		if closeErr := closer(); closeErr != nil {
+			err = closeErr
+		}
+	}
+	return err
+}
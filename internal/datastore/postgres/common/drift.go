@@ -0,0 +1,99 @@
+package common
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v4"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
+)
+
+const (
+	queryLiveColumns = `SELECT table_name, column_name FROM information_schema.columns
+		WHERE table_schema = current_schema()`
+
+	queryLiveIndexes = `SELECT tablename, indexname FROM pg_indexes
+		WHERE schemaname = current_schema()`
+)
+
+// Querier is a connection or pool on which queries can be run.
+type Querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// LoadLiveSchema loads the columns and indexes of the tables in the current schema.
+func LoadLiveSchema(ctx context.Context, querier Querier) (common.SchemaDefinition, error) {
+	schema := common.SchemaDefinition{}
+	if err := loadNames(ctx, querier, queryLiveColumns, func(table, column string) {
+		definition := schema[table]
+		definition.Columns = append(definition.Columns, column)
+		schema[table] = definition
+	}); err != nil {
+		return nil, fmt.Errorf("unable to load columns: %w", err)
+	}
+
+	if err := loadNames(ctx, querier, queryLiveIndexes, func(table, index string) {
+		definition := schema[table]
+		definition.Indexes = append(definition.Indexes, index)
+		schema[table] = definition
+	}); err != nil {
+		return nil, fmt.Errorf("unable to load indexes: %w", err)
+	}
+
+	return schema, nil
+}
+
+func loadNames(ctx context.Context, querier Querier, query string, add func(table, name string)) error {
+	rows, err := querier.Query(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var table, name string
+		if err := rows.Scan(&table, &name); err != nil {
+			return err
+		}
+		add(table, name)
+	}
+	return rows.Err()
+}
+
+const queryLiveIndexColumns = `SELECT i.relname, a.attname
+	FROM pg_index x
+	JOIN pg_class t ON t.oid = x.indrelid
+	JOIN pg_class i ON i.oid = x.indexrelid
+	JOIN pg_namespace n ON n.oid = t.relnamespace
+	CROSS JOIN LATERAL unnest(x.indkey) WITH ORDINALITY AS k(attnum, ord)
+	JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = k.attnum
+	WHERE n.nspname = current_schema() AND t.relname = $1
+	ORDER BY i.relname, k.ord`
+
+// LoadLiveIndexes loads the indexes of the table in the current schema, with their columns in
+// order. Expression columns of indexes are omitted.
+func LoadLiveIndexes(ctx context.Context, querier Querier, table string) ([]common.IndexDefinition, error) {
+	rows, err := querier.Query(ctx, queryLiveIndexColumns, table)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load indexes: %w", err)
+	}
+	defer rows.Close()
+
+	var indexes []common.IndexDefinition
+	for rows.Next() {
+		var index, column string
+		if err := rows.Scan(&index, &column); err != nil {
+			return nil, fmt.Errorf("unable to load indexes: %w", err)
+		}
+
+		if len(indexes) == 0 || indexes[len(indexes)-1].Name != index {
+			indexes = append(indexes, common.IndexDefinition{Name: index})
+		}
+		indexes[len(indexes)-1].Columns = append(indexes[len(indexes)-1].Columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("unable to load indexes: %w", err)
+	}
+	return indexes, nil
+}
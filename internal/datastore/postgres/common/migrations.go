@@ -0,0 +1,94 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/authzed/spicedb/pkg/migrate"
+)
+
+const (
+	postgresMissingTableErrorCode = "42P01"
+
+	queryCreateMigrationProgress = `CREATE TABLE IF NOT EXISTS migration_progress (
+		version_num VARCHAR NOT NULL PRIMARY KEY,
+		phase VARCHAR NOT NULL,
+		backfill_cursor VARCHAR NOT NULL,
+		batches BIGINT NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`
+
+	queryReadMigrationProgress = `SELECT phase, backfill_cursor, batches, updated_at
+		FROM migration_progress WHERE version_num = $1`
+
+	queryCheckMigrationPermissions = `SELECT
+			has_database_privilege(current_database(), 'CREATE'),
+			has_schema_privilege(current_schema(), 'CREATE'),
+			has_schema_privilege(current_schema(), 'USAGE')`
+
+	queryWriteMigrationProgress = `INSERT INTO migration_progress (version_num, phase, backfill_cursor, batches)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (version_num) DO UPDATE SET
+			phase = excluded.phase,
+			backfill_cursor = excluded.backfill_cursor,
+			batches = excluded.batches,
+			updated_at = now()`
+)
+
+// ReadMigrationProgress returns the recorded progress of the online migration to the version,
+// or nil if none has been recorded.
+func ReadMigrationProgress(ctx context.Context, conn *pgx.Conn, version string) (*migrate.Progress, error) {
+	var progress migrate.Progress
+	err := conn.QueryRow(ctx, queryReadMigrationProgress, version).Scan(
+		&progress.Phase,
+		&progress.Cursor,
+		&progress.Batches,
+		&progress.UpdatedAt,
+	)
+
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return nil, nil
+	case errors.As(err, &pgErr) && pgErr.Code == postgresMissingTableErrorCode:
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("unable to load migration progress: %w", err)
+	}
+	return &progress, nil
+}
+
+// WriteMigrationProgress records the progress of the online migration to the version,
+// creating the table in which it is recorded if necessary.
+func WriteMigrationProgress(ctx context.Context, conn *pgx.Conn, version string, progress migrate.Progress) error {
+	if _, err := conn.Exec(ctx, queryCreateMigrationProgress); err != nil {
+		return fmt.Errorf("unable to create migration progress table: %w", err)
+	}
+
+	if _, err := conn.Exec(ctx, queryWriteMigrationProgress, version, progress.Phase, progress.Cursor, progress.Batches); err != nil {
+		return fmt.Errorf("unable to write migration progress: %w", err)
+	}
+	return nil
+}
+
+// CheckMigrationPermissions returns whether the connected user has the privileges required to
+// run migrations.
+func CheckMigrationPermissions(ctx context.Context, conn *pgx.Conn) ([]migrate.Permission, error) {
+	permissions := []migrate.Permission{
+		{Name: "CREATE ON DATABASE"},
+		{Name: "CREATE ON SCHEMA"},
+		{Name: "USAGE ON SCHEMA"},
+	}
+	if err := conn.QueryRow(ctx, queryCheckMigrationPermissions).Scan(
+		&permissions[0].Granted,
+		&permissions[1].Granted,
+		&permissions[2].Granted,
+	); err != nil {
+		return nil, fmt.Errorf("unable to check migration permissions: %w", err)
+	}
+	return permissions, nil
+}
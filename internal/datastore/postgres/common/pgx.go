@@ -10,6 +10,7 @@ import (
 	"github.com/authzed/spicedb/internal/logging"
 	corev1 "github.com/authzed/spicedb/pkg/proto/core/v1"
 
+	sq "github.com/Masterminds/squirrel"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/log/zerologadapter"
 	"go.opentelemetry.io/otel/attribute"
@@ -62,6 +63,7 @@ func queryTuples(ctx context.Context, sqlStatement string, args []any, span trac
 			&nextTuple.Subject.Relation,
 			&caveatName,
 			&caveatCtx,
+			&nextTuple.Labels,
 		)
 		if err != nil {
 			return nil, fmt.Errorf(errUnableToQueryTuples, err)
@@ -81,6 +83,12 @@ func queryTuples(ctx context.Context, sqlStatement string, args []any, span trac
 	return tuples, nil
 }
 
+// MatchLabels returns a clause matching the rows whose labels, stored in the given JSONB column,
+// include all of the given labels.
+func MatchLabels(colLabels string, labels map[string]string) sq.Sqlizer {
+	return sq.Expr(colLabels+" @> ?", labels)
+}
+
 // ConfigurePGXLogger sets zerolog global logger into the connection pool configuration, and maps
 // info level events to debug, as they are rather verbose for SpiceDB's info level
 func ConfigurePGXLogger(connConfig *pgx.ConnConfig) {
@@ -0,0 +1,49 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
+	pgxcommon "github.com/authzed/spicedb/internal/datastore/postgres/common"
+	"github.com/authzed/spicedb/internal/datastore/postgres/migrations"
+)
+
+// CheckSchemaDrift compares the live schema of the database to the schema expected at its
+// migration revision.
+func (pgd *pgDatastore) CheckSchemaDrift(ctx context.Context) ([]common.SchemaDrift, error) {
+	driver, err := migrations.NewAlembicPostgresDriver(pgd.dburl)
+	if err != nil {
+		return nil, err
+	}
+	defer driver.Close(ctx)
+
+	version, err := driver.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if version != migrations.HeadSchemaRevision {
+		return nil, fmt.Errorf("expected schema is only known for migration revision %s, found %s", migrations.HeadSchemaRevision, version)
+	}
+
+	live, err := pgxcommon.LoadLiveSchema(ctx, pgd.dbpool)
+	if err != nil {
+		return nil, err
+	}
+
+	// Indexes created from the suggestions of the index advisor are expected.
+	for table, definition := range live {
+		indexes := make([]string, 0, len(definition.Indexes))
+		for _, index := range definition.Indexes {
+			if !strings.HasPrefix(index, common.AdvisedIndexPrefix) {
+				indexes = append(indexes, index)
+			}
+		}
+		definition.Indexes = indexes
+		live[table] = definition
+	}
+	return common.CompareSchema(migrations.HeadSchema, live), nil
+}
+
+var _ common.SchemaDriftChecker = &pgDatastore{}
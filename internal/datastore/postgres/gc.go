@@ -2,12 +2,15 @@ package postgres
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgconn"
 	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
 
 	"github.com/authzed/spicedb/internal/datastore/common"
 	log "github.com/authzed/spicedb/internal/logging"
@@ -72,6 +75,11 @@ func (pgd *pgDatastore) TxIDBefore(ctx context.Context, before time.Time) (datas
 	return postgresRevision{value, xmin}, nil
 }
 
+// GCController returns the controller pacing the garbage collection of the datastore.
+func (pgd *pgDatastore) GCController() *common.GCController {
+	return pgd.gcController
+}
+
 func (pgd *pgDatastore) DeleteBeforeTx(ctx context.Context, txID datastore.Revision) (removed common.DeletionCounts, err error) {
 	revision := txID.(postgresRevision)
 
@@ -81,11 +89,12 @@ func (pgd *pgDatastore) DeleteBeforeTx(ctx context.Context, txID datastore.Revis
 	}
 
 	// Delete any relationship rows that were already dead when this transaction started
-	removed.Relationships, err = pgd.batchDelete(
+	removed.Relationships, err = pgd.parallelDelete(
 		ctx,
 		tableTuple,
 		relationTuplePKCols,
-		sq.Lt{colDeletedXid: minTxAlive},
+		colDeletedXid,
+		minTxAlive,
 	)
 	if err != nil {
 		return
@@ -95,22 +104,24 @@ func (pgd *pgDatastore) DeleteBeforeTx(ctx context.Context, txID datastore.Revis
 	//
 	// We don't delete the transaction itself to ensure there is always at least
 	// one transaction present.
-	removed.Transactions, err = pgd.batchDelete(
+	removed.Transactions, err = pgd.parallelDelete(
 		ctx,
 		tableTransaction,
 		transactionPKCols,
-		sq.Lt{colXID: revision.tx},
+		colXID,
+		revision.tx,
 	)
 	if err != nil {
 		return
 	}
 
 	// Delete any namespace rows with deleted_transaction <= the transaction ID.
-	removed.Namespaces, err = pgd.batchDelete(
+	removed.Namespaces, err = pgd.parallelDelete(
 		ctx,
 		tableNamespace,
 		namespacePKCols,
-		sq.Lt{colDeletedXid: minTxAlive},
+		colDeletedXid,
+		minTxAlive,
 	)
 	if err != nil {
 		return
@@ -119,37 +130,69 @@ func (pgd *pgDatastore) DeleteBeforeTx(ctx context.Context, txID datastore.Revis
 	return
 }
 
-func (pgd *pgDatastore) batchDelete(
+// parallelDelete deletes the rows of the table whose transaction ID in the key column is below
+// the given one, in parallel over ranges of the IDs, as paced by the GC controller.
+func (pgd *pgDatastore) parallelDelete(
 	ctx context.Context,
 	tableName string,
 	pkCols []string,
-	filter sqlFilter,
+	keyCol string,
+	before xid8,
 ) (int64, error) {
-	sql, args, err := psql.Select(pkCols...).From(tableName).Where(filter).Limit(batchDeleteSize).ToSql()
+	// Deletion starts from the lowest ID, rather than from zero, so that the ranges split
+	// across the workers cover the stale rows evenly.
+	sql, args, err := psql.Select(keyCol).From(tableName).Where(sq.Lt{keyCol: before}).OrderBy(keyCol).Limit(1).ToSql()
 	if err != nil {
 		return -1, err
 	}
 
-	pkColsExpression := strings.Join(pkCols, ", ")
-
-	query := fmt.Sprintf(`WITH rows AS (%[1]s)
-		  DELETE FROM %[2]s
-		  WHERE (%[3]s) IN (SELECT %[3]s FROM rows);
-	`, sql, tableName, pkColsExpression)
-
-	var deletedCount int64
-	for {
-		cr, err := pgd.dbpool.Exec(ctx, query, args...)
-		if err != nil {
-			return deletedCount, err
+	var lowest xid8
+	if err := pgd.dbpool.QueryRow(ctx, sql, args...).Scan(&lowest); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, nil
 		}
+		return -1, err
+	}
 
-		rowsDeleted := cr.RowsAffected()
-		deletedCount += rowsDeleted
-		if rowsDeleted < batchDeleteSize {
-			break
-		}
+	pkColsExpression := strings.Join(pkCols, ", ")
+	return pgd.gcController.DeleteInParallel(ctx, lowest.Uint, before.Uint,
+		func(ctx context.Context, keys common.KeyRange, limit uint64) (int64, error) {
+			sql, args, err := psql.Select(pkCols...).From(tableName).
+				Where(sq.GtOrEq{keyCol: xid8{Uint: keys.Start, Status: pgtype.Present}}).
+				Where(sq.Lt{keyCol: xid8{Uint: keys.End, Status: pgtype.Present}}).
+				Limit(limit).
+				ToSql()
+			if err != nil {
+				return -1, err
+			}
+
+			query := fmt.Sprintf(`WITH rows AS (%[1]s)
+				  DELETE FROM %[2]s
+				  WHERE (%[3]s) IN (SELECT %[3]s FROM rows);
+			`, sql, tableName, pkColsExpression)
+
+			cr, err := pgd.dbpool.Exec(ctx, query, args...)
+			if err != nil {
+				return 0, err
+			}
+			return cr.RowsAffected(), nil
+		},
+		isLockContention,
+	)
+}
+
+// isLockContention returns whether the error is due to contention for the locks of rows with
+// concurrent transactions.
+func isLockContention(err error) bool {
+	var pgerr *pgconn.PgError
+	if !errors.As(err, &pgerr) {
+		return false
 	}
 
-	return deletedCount, nil
+	switch pgerr.SQLState() {
+	case pgSerializationFailure, pgDeadlockDetected, pgLockNotAvailable:
+		return true
+	default:
+		return false
+	}
 }
@@ -0,0 +1,59 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
+	pgxcommon "github.com/authzed/spicedb/internal/datastore/postgres/common"
+	log "github.com/authzed/spicedb/internal/logging"
+)
+
+// indexAdvisorColumns are the columns of relationships which may be suggested for indexing, the
+// most selective first.
+var indexAdvisorColumns = []string{
+	colObjectID,
+	colUsersetObjectID,
+	colNamespace,
+	colRelation,
+	colUsersetNamespace,
+	colUsersetRelation,
+	colCaveatContextName,
+}
+
+// SuggestIndexes returns an index suggestion for each filter shape of the relationship queries
+// recorded since the datastore started which no index of the relationships table serves.
+func (pgd *pgDatastore) SuggestIndexes(ctx context.Context) ([]common.IndexSuggestion, error) {
+	if pgd.indexAdvisor == nil {
+		return nil, common.ErrIndexAdvisorDisabled
+	}
+
+	existing, err := pgxcommon.LoadLiveIndexes(ctx, pgd.dbpool, tableTuple)
+	if err != nil {
+		return nil, err
+	}
+
+	suggestions := pgd.indexAdvisor.Suggest(existing)
+	if !pgd.createAdvisedIndexes {
+		return suggestions, nil
+	}
+
+	for i, suggestion := range suggestions {
+		// CREATE INDEX CONCURRENTLY does not block writes to the table while the index is built.
+		statement := strings.Replace(suggestion.Statement, "CREATE INDEX", "CREATE INDEX CONCURRENTLY IF NOT EXISTS", 1)
+		if _, err := pgd.dbpool.Exec(ctx, statement); err != nil {
+			return nil, fmt.Errorf("unable to create suggested index %s: %w", suggestion.Name, err)
+		}
+
+		log.Ctx(ctx).Info().
+			Str("index", suggestion.Name).
+			Strs("columns", suggestion.Shape.Columns).
+			Uint64("queries", suggestion.Shape.Count).
+			Msg("created index suggested by the index advisor")
+		suggestions[i].Created = true
+	}
+	return suggestions, nil
+}
+
+var _ common.IndexAdvisingDatastore = &pgDatastore{}
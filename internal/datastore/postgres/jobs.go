@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
+)
+
+const (
+	tableJob      = "job"
+	colJobID      = "id"
+	colJobVersion = "version"
+	colJobData    = "data"
+)
+
+var (
+	createJob = psql.Insert(tableJob).Columns(colJobID, colJobVersion, colJobData)
+	readJob   = psql.Select(colJobID, colJobVersion, colJobData).From(tableJob)
+	updateJob = psql.Update(tableJob)
+)
+
+// CreateJob stores a new job.
+func (pgd *pgDatastore) CreateJob(ctx context.Context, id string, data []byte) error {
+	sql, args, err := createJob.Values(id, 1, data).ToSql()
+	if err != nil {
+		return fmt.Errorf("unable to prepare create job sql: %w", err)
+	}
+
+	if _, err := pgd.dbpool.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("unable to create job: %w", err)
+	}
+	return nil
+}
+
+// ReadJob returns the stored state of a job.
+func (pgd *pgDatastore) ReadJob(ctx context.Context, id string) (common.StoredJob, error) {
+	sql, args, err := readJob.Where(sq.Eq{colJobID: id}).ToSql()
+	if err != nil {
+		return common.StoredJob{}, fmt.Errorf("unable to prepare read job sql: %w", err)
+	}
+
+	var job common.StoredJob
+	if err := pgd.dbpool.QueryRow(ctx, sql, args...).Scan(&job.ID, &job.Version, &job.Data); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return common.StoredJob{}, common.ErrJobNotFound
+		}
+		return common.StoredJob{}, fmt.Errorf("unable to read job: %w", err)
+	}
+	return job, nil
+}
+
+// ListJobs returns the stored state of every job.
+func (pgd *pgDatastore) ListJobs(ctx context.Context) ([]common.StoredJob, error) {
+	sql, args, err := readJob.OrderBy(colJobID).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("unable to prepare list jobs sql: %w", err)
+	}
+
+	rows, err := pgd.dbpool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []common.StoredJob
+	for rows.Next() {
+		var job common.StoredJob
+		if err := rows.Scan(&job.ID, &job.Version, &job.Data); err != nil {
+			return nil, fmt.Errorf("unable to read job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// UpdateJob replaces the state of a job if it is still at the given version.
+func (pgd *pgDatastore) UpdateJob(ctx context.Context, id string, version uint64, data []byte) (bool, error) {
+	sql, args, err := updateJob.
+		Set(colJobVersion, version+1).
+		Set(colJobData, data).
+		Where(sq.Eq{colJobID: id, colJobVersion: version}).
+		ToSql()
+	if err != nil {
+		return false, fmt.Errorf("unable to prepare update job sql: %w", err)
+	}
+
+	result, err := pgd.dbpool.Exec(ctx, sql, args...)
+	if err != nil {
+		return false, fmt.Errorf("unable to update job: %w", err)
+	}
+	return result.RowsAffected() == 1, nil
+}
+
+var _ common.JobStore = &pgDatastore{}
@@ -9,6 +9,7 @@ import (
 	"github.com/jackc/pgx/v4"
 	"github.com/lib/pq"
 
+	pgxcommon "github.com/authzed/spicedb/internal/datastore/postgres/common"
 	"github.com/authzed/spicedb/pkg/migrate"
 )
 
@@ -88,4 +89,24 @@ func (apd *AlembicPostgresDriver) WriteVersion(ctx context.Context, tx pgx.Tx, v
 	return nil
 }
 
-var _ migrate.Driver[*pgx.Conn, pgx.Tx] = &AlembicPostgresDriver{}
+// ReadProgress returns the recorded progress of the online migration to the version.
+func (apd *AlembicPostgresDriver) ReadProgress(ctx context.Context, version string) (*migrate.Progress, error) {
+	return pgxcommon.ReadMigrationProgress(ctx, apd.db, version)
+}
+
+// WriteProgress records the progress of the online migration to the version.
+func (apd *AlembicPostgresDriver) WriteProgress(ctx context.Context, version string, progress migrate.Progress) error {
+	return pgxcommon.WriteMigrationProgress(ctx, apd.db, version, progress)
+}
+
+// CheckPermissions returns whether the connected user has the privileges required to run
+// migrations.
+func (apd *AlembicPostgresDriver) CheckPermissions(ctx context.Context) ([]migrate.Permission, error) {
+	return pgxcommon.CheckMigrationPermissions(ctx, apd.db)
+}
+
+var (
+	_ migrate.Driver[*pgx.Conn, pgx.Tx] = &AlembicPostgresDriver{}
+	_ migrate.ProgressDriver            = &AlembicPostgresDriver{}
+	_ migrate.PreflightDriver           = &AlembicPostgresDriver{}
+)
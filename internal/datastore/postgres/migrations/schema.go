@@ -0,0 +1,63 @@
+package migrations
+
+import "github.com/authzed/spicedb/internal/datastore/common"
+
+// HeadSchemaRevision is the migration revision described by HeadSchema.
+const HeadSchemaRevision = "add-deleted-relationships"
+
+// HeadSchema is the schema expected once the datastore has been migrated to HeadSchemaRevision.
+//
+// NOTE: this must be updated alongside every migration which changes the columns or indexes of
+// a table, so that the schema drift check does not report the migration itself as drift.
+var HeadSchema = common.SchemaDefinition{
+	"alembic_version": {
+		Columns: []string{"version_num"},
+	},
+	"metadata": {
+		Columns: []string{"unique_id"},
+		Indexes: []string{"metadata_pkey"},
+	},
+	"relation_tuple_transaction": {
+		Columns: []string{"timestamp", "xid", "snapshot"},
+		Indexes: []string{"pk_rttx", "ix_relation_tuple_transaction_by_timestamp"},
+	},
+	"namespace_config": {
+		Columns: []string{"namespace", "serialized_config", "created_xid", "deleted_xid"},
+		Indexes: []string{"pk_namespace_config", "uq_namespace_living_xid"},
+	},
+	"caveat": {
+		Columns: []string{
+			"name", "definition", "created_transaction", "deleted_transaction",
+			"created_xid", "deleted_xid",
+		},
+		Indexes: []string{"pk_caveat_v2", "uq_caveat_v2"},
+	},
+	"relation_tuple": {
+		Columns: []string{
+			"namespace", "object_id", "relation", "userset_namespace", "userset_object_id",
+			"userset_relation", "caveat_name", "caveat_context", "created_xid", "deleted_xid",
+			"labels",
+		},
+		Indexes: []string{
+			"pk_relation_tuple", "uq_relation_tuple_living_xid", "ix_relation_tuple_by_subject",
+			"ix_relation_tuple_by_subject_relation", "ix_relation_tuple_labels",
+			"ix_relation_tuple_by_deleted_xid",
+		},
+	},
+	"schema_version": {
+		Columns: []string{"version", "definition", "created_xid", "deleted_xid"},
+		Indexes: []string{"pk_schema_version"},
+	},
+	"job": {
+		Columns: []string{"id", "version", "data"},
+		Indexes: []string{"pk_job"},
+	},
+	"lease": {
+		Columns: []string{"name", "holder", "expires_at"},
+		Indexes: []string{"pk_lease"},
+	},
+	"deleted_relationship": {
+		Columns: []string{"deletion_id", "position", "deleted_at", "data"},
+		Indexes: []string{"pk_deleted_relationship", "ix_deleted_relationship_deleted_at"},
+	},
+}
@@ -33,4 +33,9 @@ func init() {
 		}); err != nil {
 		panic("failed to register migration: " + err.Error())
 	}
+
+	// Servers running versions before the xid8 columns were added still read the dropped columns.
+	if err := DatabaseMigrations.MarkDestructive("drop-bigserial-ids"); err != nil {
+		panic("failed to mark migration: " + err.Error())
+	}
 }
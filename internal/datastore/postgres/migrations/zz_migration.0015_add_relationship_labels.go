@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+)
+
+var addRelationshipLabelsStmts = []string{
+	`ALTER TABLE relation_tuple
+		ADD COLUMN labels JSONB;`,
+	`CREATE INDEX CONCURRENTLY IF NOT EXISTS ix_relation_tuple_labels
+		ON relation_tuple USING GIN (labels);`,
+}
+
+func init() {
+	if err := DatabaseMigrations.Register("add-relationship-labels", "drop-bigserial-ids",
+		func(ctx context.Context, conn *pgx.Conn) error {
+			for _, stmt := range addRelationshipLabelsStmts {
+				if _, err := conn.Exec(ctx, stmt); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+		noTxMigration); err != nil {
+		panic("failed to register migration: " + err.Error())
+	}
+}
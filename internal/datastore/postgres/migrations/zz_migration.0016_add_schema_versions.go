@@ -0,0 +1,25 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+)
+
+const createSchemaVersionTable = `CREATE TABLE schema_version (
+		version BIGINT NOT NULL,
+		definition BYTEA NOT NULL,
+		created_xid xid8 NOT NULL DEFAULT (pg_current_xact_id()),
+		deleted_xid xid8 NOT NULL DEFAULT ('9223372036854775807'),
+		CONSTRAINT pk_schema_version PRIMARY KEY (version));`
+
+func init() {
+	if err := DatabaseMigrations.Register("add-schema-versions", "add-relationship-labels",
+		noNonatomicMigration,
+		func(ctx context.Context, tx pgx.Tx) error {
+			_, err := tx.Exec(ctx, createSchemaVersionTable)
+			return err
+		}); err != nil {
+		panic("failed to register migration: " + err.Error())
+	}
+}
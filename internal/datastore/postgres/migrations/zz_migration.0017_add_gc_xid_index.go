@@ -0,0 +1,25 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// Garbage collection deletes relationships in ranges of their deleted_xid, which without an
+// index requires a scan of the whole table for each batch. Living relationships are excluded
+// from the index, since they are never collected.
+const createGCXidIndex = `CREATE INDEX CONCURRENTLY IF NOT EXISTS ix_relation_tuple_by_deleted_xid
+	ON relation_tuple (deleted_xid)
+	WHERE deleted_xid < '9223372036854775807'::xid8;`
+
+func init() {
+	if err := DatabaseMigrations.Register("add-gc-xid-index", "add-schema-versions",
+		func(ctx context.Context, conn *pgx.Conn) error {
+			_, err := conn.Exec(ctx, createGCXidIndex)
+			return err
+		},
+		noTxMigration); err != nil {
+		panic("failed to register migration: " + err.Error())
+	}
+}
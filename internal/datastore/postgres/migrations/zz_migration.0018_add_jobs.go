@@ -0,0 +1,24 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+)
+
+const createJobTable = `CREATE TABLE job (
+		id VARCHAR NOT NULL,
+		version BIGINT NOT NULL,
+		data BYTEA NOT NULL,
+		CONSTRAINT pk_job PRIMARY KEY (id));`
+
+func init() {
+	if err := DatabaseMigrations.Register("add-jobs", "add-gc-xid-index",
+		noNonatomicMigration,
+		func(ctx context.Context, tx pgx.Tx) error {
+			_, err := tx.Exec(ctx, createJobTable)
+			return err
+		}); err != nil {
+		panic("failed to register migration: " + err.Error())
+	}
+}
@@ -0,0 +1,24 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+)
+
+const createLeaseTable = `CREATE TABLE lease (
+		name VARCHAR NOT NULL,
+		holder VARCHAR NOT NULL,
+		expires_at TIMESTAMPTZ NOT NULL,
+		CONSTRAINT pk_lease PRIMARY KEY (name));`
+
+func init() {
+	if err := DatabaseMigrations.Register("add-leases", "add-jobs",
+		noNonatomicMigration,
+		func(ctx context.Context, tx pgx.Tx) error {
+			_, err := tx.Exec(ctx, createLeaseTable)
+			return err
+		}); err != nil {
+		panic("failed to register migration: " + err.Error())
+	}
+}
@@ -0,0 +1,33 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	createDeletedRelationshipTable = `CREATE TABLE deleted_relationship (
+		deletion_id VARCHAR NOT NULL,
+		position BIGINT NOT NULL,
+		deleted_at TIMESTAMPTZ NOT NULL,
+		data BYTEA NOT NULL,
+		CONSTRAINT pk_deleted_relationship PRIMARY KEY (deletion_id, position));`
+
+	createDeletedRelationshipDeletedAtIndex = `CREATE INDEX ix_deleted_relationship_deleted_at ON deleted_relationship (deleted_at);`
+)
+
+func init() {
+	if err := DatabaseMigrations.Register("add-deleted-relationships", "add-leases",
+		noNonatomicMigration,
+		func(ctx context.Context, tx pgx.Tx) error {
+			for _, stmt := range []string{createDeletedRelationshipTable, createDeletedRelationshipDeletedAtIndex} {
+				if _, err := tx.Exec(ctx, stmt); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+		panic("failed to register migration: " + err.Error())
+	}
+}
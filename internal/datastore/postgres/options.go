@@ -3,6 +3,9 @@ package postgres
 import (
 	"fmt"
 	"time"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
+	pgxcommon "github.com/authzed/spicedb/internal/datastore/postgres/common"
 )
 
 type postgresOptions struct {
@@ -13,20 +16,31 @@ type postgresOptions struct {
 	minOpenConns                *int
 	maxRevisionStalenessPercent float64
 
-	watchBufferLength    uint16
-	revisionQuantization time.Duration
-	gcWindow             time.Duration
-	gcInterval           time.Duration
-	gcMaxOperationTime   time.Duration
-	splitAtUsersetCount  uint16
-	maxRetries           uint8
+	watchBufferLength         uint16
+	revisionQuantization      time.Duration
+	adaptiveQuantization      bool
+	minRevisionQuantization   time.Duration
+	fullQuantizationWriteRate float64
+	gcWindow                  time.Duration
+	gcInterval                time.Duration
+	gcMaxOperationTime        time.Duration
+	gcTuning                  common.GCTuning
+	splitAtUsersetCount       uint16
+	queryLogSampleRate        float64
+	maxRetries                uint8
 
 	enablePrometheusStats   bool
 	analyzeBeforeStatistics bool
 	gcEnabled               bool
+	gcLeaderElection        bool
+
+	indexAdvisorEnabled  bool
+	createAdvisedIndexes bool
 
 	migrationPhase string
 
+	auth pgxcommon.AuthConfig
+
 	logger *tracingLogger
 }
 
@@ -53,6 +67,8 @@ const (
 	defaultGarbageCollectionMaxOperationTime = time.Minute
 	defaultUsersetBatchSize                  = 1024
 	defaultQuantization                      = 5 * time.Second
+	defaultMinRevisionQuantization           = 100 * time.Millisecond
+	defaultFullQuantizationWriteRate         = 100
 	defaultMaxRevisionStalenessPercent       = 0.1
 	defaultEnablePrometheusStats             = false
 	defaultMaxRetries                        = 10
@@ -65,9 +81,12 @@ type Option func(*postgresOptions)
 
 func generateConfig(options []Option) (postgresOptions, error) {
 	computed := postgresOptions{
+		minRevisionQuantization:     defaultMinRevisionQuantization,
+		fullQuantizationWriteRate:   defaultFullQuantizationWriteRate,
 		gcWindow:                    defaultGarbageCollectionWindow,
 		gcInterval:                  defaultGarbageCollectionInterval,
 		gcMaxOperationTime:          defaultGarbageCollectionMaxOperationTime,
+		gcTuning:                    common.DefaultGCTuning,
 		watchBufferLength:           defaultWatchBufferLength,
 		splitAtUsersetCount:         defaultUsersetBatchSize,
 		revisionQuantization:        defaultQuantization,
@@ -107,6 +126,37 @@ func SplitAtUsersetCount(splitAtUsersetCount uint16) Option {
 	}
 }
 
+// QueryLogSampleRate is the fraction, between 0 and 1, of the relationship queries
+// which are logged with their normalized statement, number of parameters, number of
+// rows returned and duration.
+//
+// This defaults to 0, logging no queries.
+func QueryLogSampleRate(sampleRate float64) Option {
+	return func(po *postgresOptions) {
+		po.queryLogSampleRate = sampleRate
+	}
+}
+
+// IndexAdvisorEnabled enables recording the columns filtered on by relationship queries, so
+// that indexes serving them can be suggested.
+//
+// This defaults to false.
+func IndexAdvisorEnabled(enabled bool) Option {
+	return func(po *postgresOptions) {
+		po.indexAdvisorEnabled = enabled
+	}
+}
+
+// CreateAdvisedIndexes creates the indexes suggested by the index advisor when suggestions are
+// requested, rather than only returning them.
+//
+// This defaults to false.
+func CreateAdvisedIndexes(create bool) Option {
+	return func(po *postgresOptions) {
+		po.createAdvisedIndexes = create
+	}
+}
+
 // ConnMaxIdleTime is the duration after which an idle connection will be
 // automatically closed by the health check.
 //
@@ -175,6 +225,37 @@ func RevisionQuantization(quantization time.Duration) Option {
 	}
 }
 
+// AdaptiveRevisionQuantization adapts the revision quantization window to the rate of writes,
+// from MinRevisionQuantization when there are no writes up to RevisionQuantization at
+// FullQuantizationWriteRate.
+//
+// This defaults to false.
+func AdaptiveRevisionQuantization(enabled bool) Option {
+	return func(po *postgresOptions) {
+		po.adaptiveQuantization = enabled
+	}
+}
+
+// MinRevisionQuantization is the shortest revision quantization window used when adapting it
+// to the rate of writes.
+//
+// This defaults to 100 milliseconds.
+func MinRevisionQuantization(minimum time.Duration) Option {
+	return func(po *postgresOptions) {
+		po.minRevisionQuantization = minimum
+	}
+}
+
+// FullQuantizationWriteRate is the number of writes per second at and above which the full
+// revision quantization window is used when adapting it to the rate of writes.
+//
+// This defaults to 100.
+func FullQuantizationWriteRate(writesPerSecond float64) Option {
+	return func(po *postgresOptions) {
+		po.fullQuantizationWriteRate = writesPerSecond
+	}
+}
+
 // MaxRevisionStalenessPercent is the amount of time, expressed as a percentage of
 // the revision quantization window, that a previously computed rounded revision
 // can still be advertised after the next rounded revision would otherwise be ready.
@@ -215,6 +296,15 @@ func GCMaxOperationTime(time time.Duration) Option {
 	}
 }
 
+// GCTuning configures the parallelism and batch sizing of garbage collection.
+//
+// This value defaults to common.DefaultGCTuning.
+func GCTuning(tuning common.GCTuning) Option {
+	return func(po *postgresOptions) {
+		po.gcTuning = tuning
+	}
+}
+
 // MaxRetries is the maximum number of times a retriable transaction will be
 // client-side retried.
 // Default: 10
@@ -253,6 +343,16 @@ func GCEnabled(isGCEnabled bool) Option {
 	}
 }
 
+// GCLeaderElection indicates whether garbage collection is run by a single replica at a time,
+// elected by a lease held in the datastore, rather than by every replica.
+//
+// Leader election is disabled by default.
+func GCLeaderElection(enabled bool) Option {
+	return func(po *postgresOptions) {
+		po.gcLeaderElection = enabled
+	}
+}
+
 // DebugAnalyzeBeforeStatistics signals to the Statistics method that it should
 // run Analyze on the database before returning statistics. This should only be
 // used for debug and testing.
@@ -273,3 +373,44 @@ func MigrationPhase(phase string) Option {
 		po.migrationPhase = phase
 	}
 }
+
+// ConnAuthMethod is the method by which connections obtain their password: "password" for the
+// password of the connection URI, "aws-iam" for AWS RDS IAM authentication tokens or "gcp-iam"
+// for GCP Cloud SQL IAM access tokens. Tokens are refreshed automatically.
+//
+// This value defaults to "password".
+func ConnAuthMethod(method string) Option {
+	return func(po *postgresOptions) {
+		po.auth.Method = pgxcommon.AuthMethod(method)
+	}
+}
+
+// ConnAWSRegion is the region of the database for AWS RDS IAM authentication.
+//
+// This value defaults to the region of the environment.
+func ConnAWSRegion(region string) Option {
+	return func(po *postgresOptions) {
+		po.auth.AWSRegion = region
+	}
+}
+
+// ConnPasswordPath is the path of a file containing the password of connections, which is
+// reloaded whenever the file changes. It cannot be combined with IAM authentication.
+//
+// By default, the password of the connection URI, if any, is used.
+func ConnPasswordPath(path string) Option {
+	return func(po *postgresOptions) {
+		po.auth.PasswordPath = path
+	}
+}
+
+// ConnClientCertificate is the client certificate and key presented to the database, which are
+// reloaded whenever their files change.
+//
+// By default, only the certificate of the connection URI, if any, is presented.
+func ConnClientCertificate(certPath, keyPath string) Option {
+	return func(po *postgresOptions) {
+		po.auth.ClientCertPath = certPath
+		po.auth.ClientKeyPath = keyPath
+	}
+}
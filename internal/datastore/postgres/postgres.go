@@ -32,11 +32,12 @@ func init() {
 }
 
 const (
-	Engine           = "postgres"
-	tableNamespace   = "namespace_config"
-	tableTransaction = "relation_tuple_transaction"
-	tableTuple       = "relation_tuple"
-	tableCaveat      = "caveat"
+	Engine             = "postgres"
+	tableNamespace     = "namespace_config"
+	tableTransaction   = "relation_tuple_transaction"
+	tableTuple         = "relation_tuple"
+	tableCaveat        = "caveat"
+	tableSchemaVersion = "schema_version"
 
 	colXID               = "xid"
 	colTimestamp         = "timestamp"
@@ -54,6 +55,8 @@ const (
 	colCaveatDefinition  = "definition"
 	colCaveatContextName = "caveat_name"
 	colCaveatContext     = "caveat_context"
+	colLabels            = "labels"
+	colSchemaVersion     = "version"
 
 	errUnableToInstantiate = "unable to instantiate datastore: %w"
 
@@ -70,9 +73,9 @@ const (
 
 	tracingDriverName = "postgres-tracing"
 
-	batchDeleteSize = 1000
-
 	pgSerializationFailure      = "40001"
+	pgDeadlockDetected          = "40P01"
+	pgLockNotAvailable          = "55P03"
 	pgUniqueConstraintViolation = "23505"
 
 	livingTupleConstraint = "uq_relation_tuple_living_xid"
@@ -91,6 +94,8 @@ var (
 			OrderByClause(fmt.Sprintf("%s DESC", colXID)).
 			Limit(1)
 
+	getRevisionTimestamp = psql.Select(colTimestamp).From(tableTransaction)
+
 	createTxn = fmt.Sprintf(
 		"INSERT INTO %s DEFAULT VALUES RETURNING %s, pg_snapshot_xmin(%s)",
 		tableTransaction,
@@ -103,10 +108,6 @@ var (
 	tracer = otel.Tracer("spicedb/internal/datastore/postgres")
 )
 
-type sqlFilter interface {
-	ToSql() (string, []interface{}, error)
-}
-
 // NewPostgresDatastore initializes a SpiceDB datastore that uses a PostgreSQL
 // database by leveraging manual book-keeping to implement revisioning.
 //
@@ -132,6 +133,23 @@ func newPostgresDatastore(
 		return nil, fmt.Errorf(errUnableToInstantiate, err)
 	}
 
+	var adaptiveQuantization *revisions.AdaptiveQuantization
+	if config.adaptiveQuantization {
+		adaptiveQuantization, err = revisions.NewAdaptiveQuantization(
+			config.minRevisionQuantization,
+			config.revisionQuantization,
+			config.fullQuantizationWriteRate,
+		)
+		if err != nil {
+			return nil, fmt.Errorf(errUnableToInstantiate, err)
+		}
+	}
+
+	gcController, err := common.NewGCController(config.gcTuning)
+	if err != nil {
+		return nil, fmt.Errorf(errUnableToInstantiate, err)
+	}
+
 	if config.migrationPhase != "" {
 		log.Info().
 			Str("phase", config.migrationPhase).
@@ -146,11 +164,18 @@ func newPostgresDatastore(
 
 	configurePool(config, pgxConfig)
 
+	authenticator, err := pgxcommon.NewAuthenticator(pgxConfig.ConnConfig, config.auth)
+	if err != nil {
+		return nil, fmt.Errorf(errUnableToInstantiate, err)
+	}
+	pgxConfig.BeforeConnect = authenticator.BeforeConnect
+
 	initializationContext, cancelInit := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancelInit()
 
 	dbpool, err := pgxpool.ConnectConfig(initializationContext, pgxConfig)
 	if err != nil {
+		_ = authenticator.Close()
 		return nil, fmt.Errorf(errUnableToInstantiate, err)
 	}
 
@@ -175,22 +200,16 @@ func newPostgresDatastore(
 		if err := common.RegisterGCMetrics(); err != nil {
 			return nil, fmt.Errorf(errUnableToInstantiate, err)
 		}
+		if config.adaptiveQuantization {
+			if err := revisions.RegisterAdaptiveQuantizationMetrics(); err != nil {
+				return nil, fmt.Errorf(errUnableToInstantiate, err)
+			}
+		}
 	}
 
 	gcCtx, cancelGc := context.WithCancel(context.Background())
 
-	quantizationPeriodNanos := config.revisionQuantization.Nanoseconds()
-	if quantizationPeriodNanos < 1 {
-		quantizationPeriodNanos = 1
-	}
-	revisionQuery := fmt.Sprintf(
-		querySelectRevision,
-		colXID,
-		tableTransaction,
-		colTimestamp,
-		quantizationPeriodNanos,
-		colSnapshot,
-	)
+	revisionQuery := selectRevisionQuery(config.revisionQuantization)
 
 	validTransactionQuery := fmt.Sprintf(
 		queryValidTransaction,
@@ -209,14 +228,19 @@ func newPostgresDatastore(
 		),
 		dburl:                   url,
 		dbpool:                  dbpool,
+		authenticator:           authenticator,
 		watchBufferLength:       config.watchBufferLength,
 		optimizedRevisionQuery:  revisionQuery,
+		adaptiveQuantization:    adaptiveQuantization,
 		validTransactionQuery:   validTransactionQuery,
 		gcWindow:                config.gcWindow,
 		gcInterval:              config.gcInterval,
 		gcTimeout:               config.gcMaxOperationTime,
+		gcController:            gcController,
 		analyzeBeforeStatistics: config.analyzeBeforeStatistics,
 		usersetBatchSize:        config.splitAtUsersetCount,
+		queryLogger:             common.NewQueryLogger(config.queryLogSampleRate),
+		createAdvisedIndexes:    config.createAdvisedIndexes,
 		watchEnabled:            watchEnabled,
 		gcCtx:                   gcCtx,
 		cancelGc:                cancelGc,
@@ -226,17 +250,27 @@ func newPostgresDatastore(
 
 	datastore.SetOptimizedRevisionFunc(datastore.optimizedRevisionFunc)
 
+	if config.indexAdvisorEnabled {
+		datastore.indexAdvisor = common.NewIndexAdvisor(tableTuple, indexAdvisorColumns)
+	}
+
 	// Start a goroutine for garbage collection.
 	if datastore.gcInterval > 0*time.Minute && config.gcEnabled {
 		datastore.gcGroup, datastore.gcCtx = errgroup.WithContext(datastore.gcCtx)
-		datastore.gcGroup.Go(func() error {
+		runGC := func(ctx context.Context) error {
 			return common.StartGarbageCollector(
-				datastore.gcCtx,
+				ctx,
 				datastore,
 				datastore.gcInterval,
 				datastore.gcWindow,
 				datastore.gcTimeout,
 			)
+		}
+		datastore.gcGroup.Go(func() error {
+			if config.gcLeaderElection {
+				return common.RunWithLease(datastore.gcCtx, datastore, common.GCLeaseName, common.DefaultLeaseDuration, runGC)
+			}
+			return runGC(datastore.gcCtx)
 		})
 	} else {
 		log.Warn().Msg("datastore background garbage collection disabled")
@@ -278,17 +312,24 @@ type pgDatastore struct {
 
 	dburl                   string
 	dbpool                  *pgxpool.Pool
+	authenticator           *pgxcommon.Authenticator
 	watchBufferLength       uint16
 	optimizedRevisionQuery  string
+	adaptiveQuantization    *revisions.AdaptiveQuantization
 	validTransactionQuery   string
 	gcWindow                time.Duration
 	gcInterval              time.Duration
 	gcTimeout               time.Duration
+	gcController            *common.GCController
 	usersetBatchSize        uint16
+	queryLogger             *common.QueryLogger
+	indexAdvisor            *common.IndexAdvisor
+	createAdvisedIndexes    bool
 	analyzeBeforeStatistics bool
 	readTxOptions           pgx.TxOptions
 	maxRetries              uint8
 	watchEnabled            bool
+	revisionTimes           common.RevisionTimeCache
 
 	gcGroup  *errgroup.Group
 	gcCtx    context.Context
@@ -316,6 +357,8 @@ func (pgd *pgDatastore) SnapshotReader(revRaw datastore.Revision) datastore.Read
 	querySplitter := common.TupleQuerySplitter{
 		Executor:         pgxcommon.NewPGXExecutor(createTxFunc),
 		UsersetBatchSize: pgd.usersetBatchSize,
+		QueryLogger:      pgd.queryLogger,
+		IndexAdvisor:     pgd.indexAdvisor,
 	}
 
 	return &pgReader{
@@ -350,6 +393,8 @@ func (pgd *pgDatastore) ReadWriteTx(
 			querySplitter := common.TupleQuerySplitter{
 				Executor:         pgxcommon.NewPGXExecutor(longLivedTx),
 				UsersetBatchSize: pgd.usersetBatchSize,
+				QueryLogger:      pgd.queryLogger,
+				IndexAdvisor:     pgd.indexAdvisor,
 			}
 
 			rwt := &pgReadWriteTXN{
@@ -371,6 +416,7 @@ func (pgd *pgDatastore) ReadWriteTx(
 			return datastore.NoRevision, err
 		}
 
+		pgd.adaptiveQuantization.RecordWrite()
 		return postgresRevision{newXID, newXmin}, nil
 	}
 	return datastore.NoRevision, fmt.Errorf("max retries exceeded: %w", err)
@@ -385,7 +431,7 @@ func (pgd *pgDatastore) Close() error {
 	}
 
 	pgd.dbpool.Close()
-	return nil
+	return pgd.authenticator.Close()
 }
 
 func errorRetryable(err error) bool {
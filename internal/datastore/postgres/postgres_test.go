@@ -117,6 +117,12 @@ func TestPostgresDatastore(t *testing.T) {
 				WatchNotEnabledTest(t, b)
 			})
 
+			t.Run("SchemaDrift", createDatastoreTest(
+				b,
+				SchemaDriftTest,
+				MigrationPhase(config.migrationPhase),
+			))
+
 			if config.migrationPhase == "" {
 				t.Run("RevisionInversion", createDatastoreTest(
 					b,
@@ -699,6 +705,28 @@ func RevisionInversionTest(t *testing.T, ds datastore.Datastore) {
 	require.False(commitFirstRev.Equal(commitLastRev))
 }
 
+func SchemaDriftTest(t *testing.T, ds datastore.Datastore) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	pds := ds.(*pgDatastore)
+	drift, err := pds.CheckSchemaDrift(ctx)
+	require.NoError(err)
+	require.Empty(drift, "the expected head schema is out of date")
+
+	_, err = pds.dbpool.Exec(ctx, "CREATE INDEX ix_manual ON relation_tuple (object_id)")
+	require.NoError(err)
+	_, err = pds.dbpool.Exec(ctx, "DROP INDEX ix_relation_tuple_by_subject")
+	require.NoError(err)
+
+	drift, err = pds.CheckSchemaDrift(ctx)
+	require.NoError(err)
+	require.Equal([]common.SchemaDrift{
+		{Table: "relation_tuple", Kind: "index", Name: "ix_manual", Problem: "unexpected"},
+		{Table: "relation_tuple", Kind: "index", Name: "ix_relation_tuple_by_subject", Problem: "missing"},
+	}, drift)
+}
+
 func WatchNotEnabledTest(t *testing.T, b testdatastore.RunningEngineForTest) {
 	require := require.New(t)
 
@@ -749,10 +777,15 @@ func BenchmarkPostgresQuery(b *testing.B) {
 
 			defer iter.Close()
 
-			for tpl := iter.Next(); tpl != nil; tpl = iter.Next() {
+			for {
+				tpl, err := iter.Next()
+				require.NoError(err)
+				if tpl == nil {
+					break
+				}
+
 				require.Equal(testfixtures.DocumentNS.Name, tpl.ResourceAndRelation.Namespace)
 			}
-			require.NoError(iter.Err())
 		}
 	})
 }
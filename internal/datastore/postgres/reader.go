@@ -33,6 +33,7 @@ var (
 		colUsersetRelation,
 		colCaveatContextName,
 		colCaveatContext,
+		colLabels,
 	).From(tableTuple)
 
 	schema = common.SchemaInformation{
@@ -43,6 +44,8 @@ var (
 		ColUsersetObjectID:  colUsersetObjectID,
 		ColUsersetRelation:  colUsersetRelation,
 		ColCaveatName:       colCaveatContextName,
+		ColLabels:           colLabels,
+		MatchLabels:         pgxcommon.MatchLabels,
 	}
 
 	readNamespace = psql.Select(colConfig, colCreatedXid).From(tableNamespace)
@@ -85,6 +88,10 @@ func (r *pgReader) ReverseQueryRelationships(
 			FilterToRelation(queryOpts.ResRelation.Relation)
 	}
 
+	if queryOpts.ResIDPrefix != "" {
+		qBuilder = qBuilder.FilterToResourceIDPrefix(queryOpts.ResIDPrefix)
+	}
+
 	return r.querySplitter.SplitAndExecuteQuery(ctx,
 		qBuilder,
 		options.WithLimit(queryOpts.ReverseLimit),
@@ -42,6 +42,7 @@ var (
 		colUsersetRelation,
 		colCaveatContextName,
 		colCaveatContext,
+		colLabels,
 	)
 
 	deleteTuple = psql.Update(tableTuple).Where(sq.Eq{colDeletedXid: liveDeletedTxnID})
@@ -82,6 +83,7 @@ func (rwt *pgReadWriteTXN) WriteRelationships(ctx context.Context, mutations []*
 				tpl.Subject.Relation,
 				caveatName,
 				caveatContext, // PGX driver serializes map[string]any to JSONB type columns
+				tpl.Labels,
 			}
 
 			bulkWrite = bulkWrite.Values(valuesToWrite...)
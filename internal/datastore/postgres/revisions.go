@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	sq "github.com/Masterminds/squirrel"
 	"github.com/jackc/pgtype"
 	"github.com/jackc/pgx/v4"
 	"github.com/shopspring/decimal"
@@ -60,10 +61,32 @@ const (
 	) as unknown;`
 )
 
+// selectRevisionQuery returns the query selecting the optimized revision for the quantization.
+func selectRevisionQuery(quantization time.Duration) string {
+	quantizationPeriodNanos := quantization.Nanoseconds()
+	if quantizationPeriodNanos < 1 {
+		quantizationPeriodNanos = 1
+	}
+	return fmt.Sprintf(
+		querySelectRevision,
+		colXID,
+		tableTransaction,
+		colTimestamp,
+		quantizationPeriodNanos,
+		colSnapshot,
+	)
+}
+
 func (pgd *pgDatastore) optimizedRevisionFunc(ctx context.Context) (datastore.Revision, time.Duration, error) {
+	query := pgd.optimizedRevisionQuery
+	if pgd.adaptiveQuantization != nil {
+		// The adapted windows are few, so the queries for them are prepared only once each.
+		query = selectRevisionQuery(pgd.adaptiveQuantization.Window())
+	}
+
 	var revision, xmin xid8
 	var validForNanos time.Duration
-	if err := pgd.dbpool.QueryRow(ctx, pgd.optimizedRevisionQuery).
+	if err := pgd.dbpool.QueryRow(ctx, query).
 		Scan(&revision, &xmin, &validForNanos); err != nil {
 		return datastore.NoRevision, 0, fmt.Errorf(errRevision, err)
 	}
@@ -149,6 +172,54 @@ func parseRevision(revisionStr string) (datastore.Revision, error) {
 	return postgresRevision{xid8{Uint: uint64(xid), Status: pgtype.Present}, xmin}, nil
 }
 
+// RevisionAtTime returns the revision of the last transaction committed at or before the time.
+func (pgd *pgDatastore) RevisionAtTime(ctx context.Context, at time.Time) (datastore.Revision, error) {
+	ctx, span := tracer.Start(ctx, "RevisionAtTime")
+	defer span.End()
+
+	sql, args, err := getRevision.Where(sq.LtOrEq{colTimestamp: at.UTC()}).ToSql()
+	if err != nil {
+		return datastore.NoRevision, fmt.Errorf(errRevision, err)
+	}
+
+	var revision, xmin xid8
+	if err := pgd.dbpool.QueryRow(ctx, sql, args...).Scan(&revision, &xmin); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return datastore.NoRevision, datastore.NewInvalidRevisionErr(datastore.NoRevision, datastore.RevisionStale)
+		}
+		return datastore.NoRevision, fmt.Errorf(errRevision, err)
+	}
+
+	return postgresRevision{revision, xmin}, nil
+}
+
+// RevisionTime returns the time at which the transaction of the revision was committed.
+func (pgd *pgDatastore) RevisionTime(ctx context.Context, revisionRaw datastore.Revision) (time.Time, error) {
+	revision, ok := revisionRaw.(postgresRevision)
+	if !ok {
+		return time.Time{}, datastore.NewInvalidRevisionErr(revisionRaw, datastore.CouldNotDetermineRevision)
+	}
+
+	return pgd.revisionTimes.Get(revision, func() (time.Time, error) {
+		ctx, span := tracer.Start(ctx, "RevisionTime")
+		defer span.End()
+
+		sql, args, err := getRevisionTimestamp.Where(sq.Eq{colXID: revision.tx}).ToSql()
+		if err != nil {
+			return time.Time{}, fmt.Errorf(errRevision, err)
+		}
+
+		var at time.Time
+		if err := pgd.dbpool.QueryRow(ctx, sql, args...).Scan(&at); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return time.Time{}, datastore.NewInvalidRevisionErr(revision, datastore.RevisionStale)
+			}
+			return time.Time{}, fmt.Errorf(errRevision, err)
+		}
+		return at.UTC(), nil
+	})
+}
+
 func (pgd *pgDatastore) loadRevision(ctx context.Context) (xid8, xid8, error) {
 	ctx, span := tracer.Start(ctx, "loadRevision")
 	defer span.End()
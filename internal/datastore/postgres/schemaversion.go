@@ -0,0 +1,101 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgconn"
+
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+)
+
+var (
+	writeSchemaVersion  = psql.Insert(tableSchemaVersion).Columns(colSchemaVersion, colCaveatDefinition)
+	listSchemaVersions  = psql.Select(colCaveatDefinition).From(tableSchemaVersion).OrderBy(colSchemaVersion)
+	latestSchemaVersion = psql.Select(fmt.Sprintf("COALESCE(MAX(%s), 0)", colSchemaVersion)).From(tableSchemaVersion)
+)
+
+const (
+	errWriteSchemaVersion  = "unable to write schema version: %w"
+	errListSchemaVersions  = "unable to list schema versions: %w"
+	errLatestSchemaVersion = "unable to find latest schema version: %w"
+)
+
+func (r *pgReader) ListSchemaVersions(ctx context.Context) ([]*core.SchemaVersion, error) {
+	sql, args, err := r.filterer(listSchemaVersions).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf(errListSchemaVersions, err)
+	}
+
+	tx, txCleanup, err := r.txSource(ctx)
+	if err != nil {
+		return nil, fmt.Errorf(errListSchemaVersions, err)
+	}
+	defer txCleanup(ctx)
+
+	rows, err := tx.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf(errListSchemaVersions, err)
+	}
+	defer rows.Close()
+
+	var versions []*core.SchemaVersion
+	for rows.Next() {
+		var defBytes []byte
+		if err := rows.Scan(&defBytes); err != nil {
+			return nil, fmt.Errorf(errListSchemaVersions, err)
+		}
+
+		version := &core.SchemaVersion{}
+		if err := version.UnmarshalVT(defBytes); err != nil {
+			return nil, fmt.Errorf(errListSchemaVersions, err)
+		}
+		versions = append(versions, version)
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf(errListSchemaVersions, rows.Err())
+	}
+
+	return versions, nil
+}
+
+func (r *pgReader) LatestSchemaVersion(ctx context.Context) (uint64, error) {
+	sql, args, err := r.filterer(latestSchemaVersion).ToSql()
+	if err != nil {
+		return 0, fmt.Errorf(errLatestSchemaVersion, err)
+	}
+
+	tx, txCleanup, err := r.txSource(ctx)
+	if err != nil {
+		return 0, fmt.Errorf(errLatestSchemaVersion, err)
+	}
+	defer txCleanup(ctx)
+
+	var latest int64
+	if err := tx.QueryRow(ctx, sql, args...).Scan(&latest); err != nil {
+		return 0, fmt.Errorf(errLatestSchemaVersion, err)
+	}
+	return uint64(latest), nil
+}
+
+func (rwt *pgReadWriteTXN) WriteSchemaVersion(ctx context.Context, version *core.SchemaVersion) error {
+	definitionBytes, err := version.MarshalVT()
+	if err != nil {
+		return fmt.Errorf(errWriteSchemaVersion, err)
+	}
+
+	sql, args, err := writeSchemaVersion.Values(version.Version, definitionBytes).ToSql()
+	if err != nil {
+		return fmt.Errorf(errWriteSchemaVersion, err)
+	}
+	if _, err := rwt.tx.Exec(ctx, sql, args...); err != nil {
+		var pgerr *pgconn.PgError
+		if errors.As(err, &pgerr) && pgerr.SQLState() == pgUniqueConstraintViolation {
+			return datastore.NewSchemaVersionExistsErr(version.Version)
+		}
+		return fmt.Errorf(errWriteSchemaVersion, err)
+	}
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	sq "github.com/Masterminds/squirrel"
 	"github.com/jackc/pgx/v4"
 
+	"github.com/authzed/spicedb/internal/datastore/common"
 	"github.com/authzed/spicedb/pkg/datastore"
 )
 
@@ -20,6 +21,12 @@ const (
 )
 
 var (
+	queryRelationCounts = psql.
+				Select(colNamespace, colRelation, "COUNT(*)").
+				From(tableTuple).
+				Where(sq.Eq{colDeletedXid: liveDeletedTxnID}).
+				GroupBy(colNamespace, colRelation)
+
 	queryUniqueID          = psql.Select(colUniqueID).From(tableMetadata)
 	queryEstimatedRowCount = psql.
 				Select(colReltuples).
@@ -84,3 +91,29 @@ func (pgd *pgDatastore) Statistics(ctx context.Context) (datastore.Stats, error)
 		EstimatedRelationshipCount: relCountUint,
 	}, nil
 }
+
+// CountRelationshipsByRelation counts the live relationships of each relation.
+func (pgd *pgDatastore) CountRelationshipsByRelation(ctx context.Context) ([]common.RelationCount, error) {
+	sql, args, err := queryRelationCounts.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("unable to prepare relation counts sql: %w", err)
+	}
+
+	rows, err := pgd.dbpool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to count relationships: %w", err)
+	}
+	defer rows.Close()
+
+	var counts []common.RelationCount
+	for rows.Next() {
+		var count common.RelationCount
+		if err := rows.Scan(&count.Namespace, &count.Relation, &count.Count); err != nil {
+			return nil, fmt.Errorf("unable to read relation count: %w", err)
+		}
+		counts = append(counts, count)
+	}
+	return counts, rows.Err()
+}
+
+var _ common.RelationCounter = &pgDatastore{}
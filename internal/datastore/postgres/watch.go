@@ -40,6 +40,7 @@ var (
 		colUsersetRelation,
 		colCaveatContextName,
 		colCaveatContext,
+		colLabels,
 		colCreatedXid,
 		colDeletedXid,
 	).From(tableTuple)
@@ -201,6 +202,7 @@ func (pgd *pgDatastore) loadChanges(ctx context.Context, revisions []postgresRev
 			&nextTuple.Subject.Relation,
 			&caveatName,
 			&caveatContext,
+			&nextTuple.Labels,
 			&createdXID,
 			&deletedXID,
 		); err != nil {
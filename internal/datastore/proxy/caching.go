@@ -3,8 +3,11 @@ package proxy
 import (
 	"context"
 	"errors"
+	"sort"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 	"unsafe"
 
 	"github.com/authzed/spicedb/pkg/util"
@@ -43,16 +46,41 @@ func DatastoreProxyTestCache(t testing.TB) cache.Cache {
 	return cache
 }
 
+// CachingProxyOption configures a datastore proxy created by NewCachingDatastoreProxy.
+type CachingProxyOption func(*definitionCachingProxy)
+
+// WithStaleWhileRevalidate serves reads of a definition at a revision for which it has not been
+// loaded yet with the definition last loaded at an earlier revision, if it was loaded at most
+// the given duration ago, while the definition is reloaded at the requested revision in the
+// background. This keeps a burst of reads at a new revision from waiting on the datastore, at
+// the cost of definitions changed by other servers being observed up to the duration later.
+// Definitions changed through the proxy are never served stale.
+//
+// A duration of zero, the default, disables serving stale definitions.
+func WithStaleWhileRevalidate(staleness time.Duration) CachingProxyOption {
+	return func(p *definitionCachingProxy) {
+		p.staleness = staleness
+	}
+}
+
 // NewCachingDatastoreProxy creates a new datastore proxy which caches definitions that
 // are loaded at specific datastore revisions.
-func NewCachingDatastoreProxy(delegate datastore.Datastore, c cache.Cache) datastore.Datastore {
+//
+// Concurrent loads of the same definitions at the same revision are coalesced into a single
+// read of the datastore.
+func NewCachingDatastoreProxy(delegate datastore.Datastore, c cache.Cache, opts ...CachingProxyOption) datastore.Datastore {
 	if c == nil {
 		c = cache.NoopCache()
 	}
-	return &definitionCachingProxy{
+	p := &definitionCachingProxy{
 		Datastore: delegate,
 		c:         c,
+		latest:    make(map[string]latestEntry),
 	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
 }
 
 type schemaDefinition interface {
@@ -64,6 +92,70 @@ type definitionCachingProxy struct {
 	datastore.Datastore
 	c         cache.Cache
 	readGroup singleflight.Group
+
+	// staleness is the maximum age of a definition served while revalidating.
+	staleness time.Duration
+
+	latestLock sync.RWMutex
+	latest     map[string]latestEntry
+}
+
+// latestEntry is the definition loaded at the latest revision, which may be served while the
+// definition is loaded at a later revision.
+type latestEntry struct {
+	entry    *cacheEntry
+	rev      datastore.Revision
+	loadedAt time.Time
+}
+
+// recordLatest records an entry loaded at a revision, if it is later than the latest one.
+func (p *definitionCachingProxy) recordLatest(key string, rev datastore.Revision, entry *cacheEntry) {
+	if p.staleness <= 0 {
+		return
+	}
+
+	p.latestLock.Lock()
+	defer p.latestLock.Unlock()
+
+	if existing, ok := p.latest[key]; ok && existing.rev.GreaterThan(rev) {
+		return
+	}
+	p.latest[key] = latestEntry{entry, rev, time.Now()}
+}
+
+// staleEntry returns the latest entry, if it was loaded at an earlier revision than the given one
+// recently enough to be served while revalidating.
+func (p *definitionCachingProxy) staleEntry(key string, rev datastore.Revision) (*cacheEntry, bool) {
+	if p.staleness <= 0 {
+		return nil, false
+	}
+
+	p.latestLock.RLock()
+	defer p.latestLock.RUnlock()
+
+	latest, ok := p.latest[key]
+	if !ok || !rev.GreaterThan(latest.rev) || time.Since(latest.loadedAt) > p.staleness {
+		return nil, false
+	}
+	return latest.entry, true
+}
+
+// forgetLatest forgets the latest entries of definitions which have been changed.
+func (p *definitionCachingProxy) forgetLatest(keys []string) {
+	if p.staleness <= 0 || len(keys) == 0 {
+		return
+	}
+
+	p.latestLock.Lock()
+	defer p.latestLock.Unlock()
+
+	for _, key := range keys {
+		delete(p.latest, key)
+	}
+}
+
+func (p *definitionCachingProxy) Unwrap() datastore.Datastore {
+	return p.Datastore
 }
 
 func (p *definitionCachingProxy) Close() error {
@@ -80,10 +172,26 @@ func (p *definitionCachingProxy) ReadWriteTx(
 	ctx context.Context,
 	f datastore.TxUserFunc,
 ) (datastore.Revision, error) {
-	return p.Datastore.ReadWriteTx(ctx, func(delegateRWT datastore.ReadWriteTransaction) error {
-		rwt := &definitionCachingRWT{delegateRWT, &sync.Map{}}
-		return f(rwt)
+	var written []string
+	rev, err := p.Datastore.ReadWriteTx(ctx, func(delegateRWT datastore.ReadWriteTransaction) error {
+		rwt := &definitionCachingRWT{delegateRWT, &sync.Map{}, &sync.Map{}}
+		if err := f(rwt); err != nil {
+			return err
+		}
+
+		written = written[:0]
+		rwt.writtenDefinitions.Range(func(key, _ any) bool {
+			written = append(written, key.(string))
+			return true
+		})
+		return nil
 	})
+	if err != nil {
+		return rev, err
+	}
+
+	p.forgetLatest(written)
+	return rev, nil
 }
 
 const (
@@ -157,44 +265,90 @@ func listAndCache[T schemaDefinition](
 	remainingToLoad := util.NewSet[string]()
 	remainingToLoad.Extend(names)
 
+	var toRevalidate []string
 	foundDefs := make([]datastore.RevisionedDefinition[T], 0, len(names))
 	for _, name := range names {
 		cacheRevisionKey := prefix + ":" + name + "@" + r.rev.String()
 		loadedRaw, found := r.p.c.Get(cacheRevisionKey)
 		if !found {
-			continue
+			stale, ok := r.p.staleEntry(prefix+":"+name, r.rev)
+			if !ok {
+				continue
+			}
+
+			toRevalidate = append(toRevalidate, name)
+			loadedRaw = stale
 		}
 
 		remainingToLoad.Remove(name)
 		loaded := loadedRaw.(*cacheEntry)
+		if loaded.notFound != nil {
+			continue
+		}
+
 		foundDefs = append(foundDefs, datastore.RevisionedDefinition[T]{
 			Definition:          loaded.definition.(T),
 			LastWrittenRevision: loaded.updated,
 		})
 	}
 
+	if len(toRevalidate) > 0 {
+		// Revalidate the stale entries in the background.
+		loadAndCacheDefinitions(ctx, r, prefix, toRevalidate, reader, estimator)
+	}
+
 	if !remainingToLoad.IsEmpty() {
 		// Load and cache the remaining names.
-		loadedDefs, err := reader(ctx, remainingToLoad.AsSlice())
+		result := <-loadAndCacheDefinitions(ctx, r, prefix, remainingToLoad.AsSlice(), reader, estimator)
+		if result.Err != nil {
+			return nil, result.Err
+		}
+
+		foundDefs = append(foundDefs, result.Val.([]datastore.RevisionedDefinition[T])...)
+	}
+
+	return foundDefs, nil
+}
+
+// loadAndCacheDefinitions loads the definitions with the given names and caches them, once for
+// all concurrent loads of the same names at the same revision. The returned channel receives
+// the loaded definitions, and may be ignored to load them in the background.
+func loadAndCacheDefinitions[T schemaDefinition](
+	ctx context.Context,
+	r *definitionCachingReader,
+	prefix string,
+	names []string,
+	reader func(ctx context.Context, names []string) ([]datastore.RevisionedDefinition[T], error),
+	estimator func(sizeVT int) int64,
+) <-chan singleflight.Result {
+	sorted := make([]string, len(names))
+	copy(sorted, names)
+	sort.Strings(sorted)
+
+	// The key is prefixed so that it never matches that of a single read, whose result differs.
+	listKey := "list:" + prefix + ":" + strings.Join(sorted, ",") + "@" + r.rev.String()
+	return r.p.readGroup.DoChan(listKey, func() (any, error) {
+		// sever the context so that another branch doesn't cancel the
+		// single-flighted read
+		loadedDefs, err := reader(SeparateContextWithTracing(ctx), sorted)
 		if err != nil {
 			return nil, err
 		}
 
 		for _, def := range loadedDefs {
-			foundDefs = append(foundDefs, def)
-
 			cacheRevisionKey := prefix + ":" + def.Definition.GetName() + "@" + r.rev.String()
 			estimatedDefinitionSize := estimator(def.Definition.SizeVT())
-			entry := &cacheEntry{def.Definition, def.LastWrittenRevision, estimatedDefinitionSize, err}
+			entry := &cacheEntry{def.Definition, def.LastWrittenRevision, estimatedDefinitionSize, nil}
 			r.p.c.Set(cacheRevisionKey, entry, entry.Size())
+			r.p.recordLatest(prefix+":"+def.Definition.GetName(), r.rev, entry)
 		}
 
 		// We have to call wait here or else Ristretto may not have the key(s)
 		// available to a subsequent caller.
 		r.p.c.Wait()
-	}
 
-	return foundDefs, nil
+		return loadedDefs, nil
+	})
 }
 
 func readAndCache[T schemaDefinition](
@@ -206,12 +360,11 @@ func readAndCache[T schemaDefinition](
 	estimator func(sizeVT int) int64,
 ) (T, datastore.Revision, error) {
 	// Check the cache.
-	cacheRevisionKey := prefix + ":" + name + "@" + r.rev.String()
+	latestKey := prefix + ":" + name
+	cacheRevisionKey := latestKey + "@" + r.rev.String()
 	loadedRaw, found := r.p.c.Get(cacheRevisionKey)
 	if !found {
-		// We couldn't use the cached entry, load one
-		var err error
-		loadedRaw, err, _ = r.p.readGroup.Do(cacheRevisionKey, func() (any, error) {
+		load := func() (any, error) {
 			// sever the context so that another branch doesn't cancel the
 			// single-flighted read
 			loaded, updatedRev, err := reader(SeparateContextWithTracing(ctx), name)
@@ -223,15 +376,27 @@ func readAndCache[T schemaDefinition](
 			estimatedDefinitionSize := estimator(loaded.SizeVT())
 			entry := &cacheEntry{loaded, updatedRev, estimatedDefinitionSize, err}
 			r.p.c.Set(cacheRevisionKey, entry, entry.Size())
+			r.p.recordLatest(latestKey, r.rev, entry)
 
 			// We have to call wait here or else Ristretto may not have the key
 			// available to a subsequent caller.
 			r.p.c.Wait()
 
 			return entry, nil
-		})
-		if err != nil {
-			return *new(T), datastore.NoRevision, err
+		}
+
+		if stale, ok := r.p.staleEntry(latestKey, r.rev); ok {
+			// Serve the stale entry, and revalidate it in the background, once for all
+			// readers of the definition at this revision.
+			r.p.readGroup.DoChan(cacheRevisionKey, load)
+			loadedRaw = stale
+		} else {
+			// We couldn't use the cached entry, load one
+			var err error
+			loadedRaw, err, _ = r.p.readGroup.Do(cacheRevisionKey, load)
+			if err != nil {
+				return *new(T), datastore.NoRevision, err
+			}
 		}
 	}
 
@@ -242,6 +407,10 @@ func readAndCache[T schemaDefinition](
 type definitionCachingRWT struct {
 	datastore.ReadWriteTransaction
 	definitionCache *sync.Map
+
+	// writtenDefinitions are the keys of the latest entries of the definitions written or
+	// deleted in the transaction.
+	writtenDefinitions *sync.Map
 }
 
 type rwtCacheEntry struct {
@@ -304,6 +473,20 @@ func (rwt *definitionCachingRWT) WriteNamespaces(ctx context.Context, newConfigs
 
 	for _, nsDef := range newConfigs {
 		rwt.definitionCache.Delete("namespace:" + nsDef.Name)
+		rwt.writtenDefinitions.Store(namespaceCacheKeyPrefix+":"+nsDef.Name, struct{}{})
+	}
+
+	return nil
+}
+
+func (rwt *definitionCachingRWT) DeleteNamespaces(ctx context.Context, nsNames ...string) error {
+	if err := rwt.ReadWriteTransaction.DeleteNamespaces(ctx, nsNames...); err != nil {
+		return err
+	}
+
+	for _, nsName := range nsNames {
+		rwt.definitionCache.Delete("namespace:" + nsName)
+		rwt.writtenDefinitions.Store(namespaceCacheKeyPrefix+":"+nsName, struct{}{})
 	}
 
 	return nil
@@ -316,6 +499,20 @@ func (rwt *definitionCachingRWT) WriteCaveats(ctx context.Context, newConfigs []
 
 	for _, caveatDef := range newConfigs {
 		rwt.definitionCache.Delete("caveat:" + caveatDef.Name)
+		rwt.writtenDefinitions.Store(caveatCacheKeyPrefix+":"+caveatDef.Name, struct{}{})
+	}
+
+	return nil
+}
+
+func (rwt *definitionCachingRWT) DeleteCaveats(ctx context.Context, names []string) error {
+	if err := rwt.ReadWriteTransaction.DeleteCaveats(ctx, names); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		rwt.definitionCache.Delete("caveat:" + name)
+		rwt.writtenDefinitions.Store(caveatCacheKeyPrefix+":"+name, struct{}{})
 	}
 
 	return nil
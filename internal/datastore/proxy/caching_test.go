@@ -506,3 +506,130 @@ func TestMixedCaching(t *testing.T) {
 		})
 	}
 }
+
+func TestLookupSingleFlight(t *testing.T) {
+	for _, tester := range testers {
+		t.Run(tester.name, func(t *testing.T) {
+			dsMock := &proxy_test.MockDatastore{}
+
+			defA := tester.createDef(nsA)
+			reader := &proxy_test.MockReader{}
+			reader.
+				On(tester.lookupFunctionName, []string{nsA, nsB}).
+				WaitUntil(time.After(10*time.Millisecond)).
+				Return(tester.wrapRevisioned(defA), nil).
+				Once()
+			dsMock.On("SnapshotReader", one).Return(reader)
+
+			require := require.New(t)
+			ds := NewCachingDatastoreProxy(dsMock, nil)
+
+			lookup := func(names ...string) func() error {
+				return func() error {
+					found, err := tester.lookupFunc(context.Background(), ds.SnapshotReader(one), names)
+					require.NoError(err)
+					require.Len(found, 1)
+					return err
+				}
+			}
+
+			g := errgroup.Group{}
+			g.Go(lookup(nsA, nsB))
+			g.Go(lookup(nsB, nsA))
+			require.NoError(g.Wait())
+
+			dsMock.AssertExpectations(t)
+			reader.AssertExpectations(t)
+		})
+	}
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	for _, tester := range testers {
+		t.Run(tester.name, func(t *testing.T) {
+			dsMock := &proxy_test.MockDatastore{}
+
+			defA := tester.createDef(nsA)
+			oneReader := &proxy_test.MockReader{}
+			oneReader.On(tester.readSingleFunctionName, nsA).Return(defA, old, nil).Once()
+			dsMock.On("SnapshotReader", one).Return(oneReader)
+
+			reloaded := make(chan time.Time)
+			twoReader := &proxy_test.MockReader{}
+			twoReader.On(tester.readSingleFunctionName, nsA).WaitUntil(reloaded).Return(defA, zero, nil).Once()
+			dsMock.On("SnapshotReader", two).Return(twoReader)
+
+			require := require.New(t)
+			ds := NewCachingDatastoreProxy(dsMock, DatastoreProxyTestCache(t), WithStaleWhileRevalidate(time.Minute))
+
+			_, updated, err := tester.readSingleFunc(context.Background(), ds.SnapshotReader(one), nsA)
+			require.NoError(err)
+			require.True(old.Equal(updated))
+
+			// Reads at the new revision are served the stale definition while it is reloaded once.
+			for i := 0; i < 3; i++ {
+				_, updated, err = tester.readSingleFunc(context.Background(), ds.SnapshotReader(two), nsA)
+				require.NoError(err)
+				require.True(old.Equal(updated))
+			}
+
+			close(reloaded)
+			require.Eventually(func() bool {
+				_, updated, err := tester.readSingleFunc(context.Background(), ds.SnapshotReader(two), nsA)
+				return err == nil && zero.Equal(updated)
+			}, time.Second, 5*time.Millisecond)
+
+			// Reads at an earlier revision are never served a later definition.
+			oneReader.On(tester.readSingleFunctionName, nsB).Return(nil, zero, tester.notFoundErr).Once()
+			_, _, err = tester.readSingleFunc(context.Background(), ds.SnapshotReader(one), nsB)
+			require.Error(err)
+
+			dsMock.AssertExpectations(t)
+			oneReader.AssertExpectations(t)
+			twoReader.AssertExpectations(t)
+		})
+	}
+}
+
+func TestStaleWhileRevalidateAfterWrite(t *testing.T) {
+	for _, tester := range testers {
+		t.Run(tester.name, func(t *testing.T) {
+			dsMock := &proxy_test.MockDatastore{}
+			rwtMock := &proxy_test.MockReadWriteTransaction{}
+
+			defA := tester.createDef(nsA)
+			oneReader := &proxy_test.MockReader{}
+			oneReader.On(tester.readSingleFunctionName, nsA).Return(nil, zero, tester.notFoundErr).Once()
+			dsMock.On("SnapshotReader", one).Return(oneReader)
+
+			twoReader := &proxy_test.MockReader{}
+			twoReader.On(tester.readSingleFunctionName, nsA).Return(defA, two, nil).Once()
+			dsMock.On("SnapshotReader", two).Return(twoReader)
+
+			dsMock.On("ReadWriteTx").Return(rwtMock, two, nil).Once()
+			rwtMock.On(tester.writeFunctionName, tester.wrap(defA)).Return(nil).Once()
+
+			require := require.New(t)
+			ds := NewCachingDatastoreProxy(dsMock, DatastoreProxyTestCache(t), WithStaleWhileRevalidate(time.Minute))
+
+			_, _, err := tester.readSingleFunc(context.Background(), ds.SnapshotReader(one), nsA)
+			require.Error(err)
+
+			_, err = ds.ReadWriteTx(context.Background(), func(rwt datastore.ReadWriteTransaction) error {
+				return tester.writeFunc(rwt, defA)
+			})
+			require.NoError(err)
+
+			// The written definition is never served stale.
+			def, updated, err := tester.readSingleFunc(context.Background(), ds.SnapshotReader(two), nsA)
+			require.NoError(err)
+			require.NotNil(def)
+			require.True(two.Equal(updated))
+
+			dsMock.AssertExpectations(t)
+			oneReader.AssertExpectations(t)
+			rwtMock.AssertExpectations(t)
+			twoReader.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,279 @@
+package proxy
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/authzed/spicedb/internal/datastore/options"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/secrets"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+const (
+	// encryptedContextField is the single field found in a caveat context that has been
+	// encrypted by the proxy. Its value is a struct holding the key ID and ciphertext.
+	encryptedContextField = "__spicedb_encrypted_context"
+
+	encryptedContextKeyIDField      = "key_id"
+	encryptedContextCiphertextField = "ciphertext"
+)
+
+// NewCaveatContextEncryptingProxy creates a new datastore proxy which encrypts the context
+// of caveated relationships before they are written to the delegate datastore, and transparently
+// decrypts them when they are read back. The relationship itself (minus the context) is used as
+// additional authenticated data, so that ciphertext cannot be moved between relationships.
+func NewCaveatContextEncryptingProxy(delegate datastore.Datastore, keyManager secrets.KeyManager) datastore.Datastore {
+	return &caveatEncryptingProxy{Datastore: delegate, keyManager: keyManager}
+}
+
+type caveatEncryptingProxy struct {
+	datastore.Datastore
+	keyManager secrets.KeyManager
+}
+
+func (p *caveatEncryptingProxy) SnapshotReader(rev datastore.Revision) datastore.Reader {
+	return &caveatEncryptingReader{p.Datastore.SnapshotReader(rev), p.keyManager}
+}
+
+func (p *caveatEncryptingProxy) ReadWriteTx(ctx context.Context, f datastore.TxUserFunc) (datastore.Revision, error) {
+	return p.Datastore.ReadWriteTx(ctx, func(delegateRWT datastore.ReadWriteTransaction) error {
+		return f(&caveatEncryptingRWT{delegateRWT, p.keyManager})
+	})
+}
+
+func (p *caveatEncryptingProxy) Watch(ctx context.Context, afterRevision datastore.Revision) (<-chan *datastore.RevisionChanges, <-chan error) {
+	delegateChanges, delegateErrs := p.Datastore.Watch(ctx, afterRevision)
+
+	changes := make(chan *datastore.RevisionChanges)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(changes)
+		defer close(errs)
+
+		for {
+			select {
+			case revChanges, ok := <-delegateChanges:
+				if !ok {
+					return
+				}
+
+				decrypted := make([]*core.RelationTupleUpdate, 0, len(revChanges.Changes))
+				for _, update := range revChanges.Changes {
+					tpl, err := decryptCaveatContext(ctx, p.keyManager, update.Tuple)
+					if err != nil {
+						errs <- err
+						return
+					}
+					decrypted = append(decrypted, &core.RelationTupleUpdate{
+						Operation: update.Operation,
+						Tuple:     tpl,
+					})
+				}
+
+				select {
+				case changes <- &datastore.RevisionChanges{Revision: revChanges.Revision, Changes: decrypted}:
+				case <-ctx.Done():
+					errs <- datastore.NewWatchCanceledErr()
+					return
+				}
+
+			case err, ok := <-delegateErrs:
+				if ok {
+					errs <- err
+				}
+				return
+			}
+		}
+	}()
+
+	return changes, errs
+}
+
+func (p *caveatEncryptingProxy) Unwrap() datastore.Datastore {
+	return p.Datastore
+}
+
+type caveatEncryptingReader struct {
+	datastore.Reader
+	keyManager secrets.KeyManager
+}
+
+func (r *caveatEncryptingReader) QueryRelationships(
+	ctx context.Context,
+	filter datastore.RelationshipsFilter,
+	options ...options.QueryOptionsOption,
+) (datastore.RelationshipIterator, error) {
+	it, err := r.Reader.QueryRelationships(ctx, filter, options...)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptingIterator{ctx: ctx, delegate: it, keyManager: r.keyManager}, nil
+}
+
+func (r *caveatEncryptingReader) ReverseQueryRelationships(
+	ctx context.Context,
+	subjectsFilter datastore.SubjectsFilter,
+	options ...options.ReverseQueryOptionsOption,
+) (datastore.RelationshipIterator, error) {
+	it, err := r.Reader.ReverseQueryRelationships(ctx, subjectsFilter, options...)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptingIterator{ctx: ctx, delegate: it, keyManager: r.keyManager}, nil
+}
+
+type caveatEncryptingRWT struct {
+	datastore.ReadWriteTransaction
+	keyManager secrets.KeyManager
+}
+
+func (rwt *caveatEncryptingRWT) QueryRelationships(
+	ctx context.Context,
+	filter datastore.RelationshipsFilter,
+	options ...options.QueryOptionsOption,
+) (datastore.RelationshipIterator, error) {
+	return (&caveatEncryptingReader{rwt.ReadWriteTransaction, rwt.keyManager}).QueryRelationships(ctx, filter, options...)
+}
+
+func (rwt *caveatEncryptingRWT) ReverseQueryRelationships(
+	ctx context.Context,
+	subjectsFilter datastore.SubjectsFilter,
+	options ...options.ReverseQueryOptionsOption,
+) (datastore.RelationshipIterator, error) {
+	return (&caveatEncryptingReader{rwt.ReadWriteTransaction, rwt.keyManager}).ReverseQueryRelationships(ctx, subjectsFilter, options...)
+}
+
+func (rwt *caveatEncryptingRWT) WriteRelationships(ctx context.Context, mutations []*core.RelationTupleUpdate) error {
+	encrypted := make([]*core.RelationTupleUpdate, 0, len(mutations))
+	for _, mutation := range mutations {
+		if mutation.Tuple.Caveat == nil || len(mutation.Tuple.Caveat.Context.GetFields()) == 0 {
+			encrypted = append(encrypted, mutation)
+			continue
+		}
+
+		tpl, err := encryptCaveatContext(ctx, rwt.keyManager, mutation.Tuple)
+		if err != nil {
+			return err
+		}
+
+		encrypted = append(encrypted, &core.RelationTupleUpdate{
+			Operation: mutation.Operation,
+			Tuple:     tpl,
+		})
+	}
+
+	return rwt.ReadWriteTransaction.WriteRelationships(ctx, encrypted)
+}
+
+type decryptingIterator struct {
+	ctx        context.Context
+	delegate   datastore.RelationshipIterator
+	keyManager secrets.KeyManager
+	err        error
+}
+
+func (di *decryptingIterator) Next() (*core.RelationTuple, error) {
+	if di.err != nil {
+		return nil, di.err
+	}
+
+	next, err := di.delegate.Next()
+	if next == nil || err != nil {
+		return nil, err
+	}
+
+	decrypted, err := decryptCaveatContext(di.ctx, di.keyManager, next)
+	if err != nil {
+		di.err = err
+		return nil, err
+	}
+	return decrypted, nil
+}
+
+func (di *decryptingIterator) Close() {
+	di.delegate.Close()
+}
+
+func encryptCaveatContext(ctx context.Context, keyManager secrets.KeyManager, tpl *core.RelationTuple) (*core.RelationTuple, error) {
+	key, err := keyManager.PrimaryKey(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load caveat context encryption key: %w", err)
+	}
+
+	plaintext, err := proto.MarshalOptions{Deterministic: true}.Marshal(tpl.Caveat.Context)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal caveat context: %w", err)
+	}
+
+	ciphertext, err := secrets.Encrypt(key, plaintext, additionalDataForTuple(tpl))
+	if err != nil {
+		return nil, fmt.Errorf("unable to encrypt caveat context: %w", err)
+	}
+
+	encryptedContext, err := structpb.NewStruct(map[string]any{
+		encryptedContextField: map[string]any{
+			encryptedContextKeyIDField:      key.ID,
+			encryptedContextCiphertextField: base64.StdEncoding.EncodeToString(ciphertext),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode encrypted caveat context: %w", err)
+	}
+
+	updated := tpl.CloneVT()
+	updated.Caveat.Context = encryptedContext
+	return updated, nil
+}
+
+func decryptCaveatContext(ctx context.Context, keyManager secrets.KeyManager, tpl *core.RelationTuple) (*core.RelationTuple, error) {
+	if tpl.Caveat == nil {
+		return tpl, nil
+	}
+
+	envelope := tpl.Caveat.Context.GetFields()[encryptedContextField].GetStructValue()
+	if envelope == nil {
+		// The context was written before encryption was enabled.
+		return tpl, nil
+	}
+
+	keyID := envelope.Fields[encryptedContextKeyIDField].GetStringValue()
+	key, err := keyManager.KeyByID(ctx, keyID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load caveat context encryption key: %w", err)
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(envelope.Fields[encryptedContextCiphertextField].GetStringValue())
+	if err != nil {
+		return nil, fmt.Errorf("malformed encrypted caveat context for relationship %s: %w", tuple.StringWithoutCaveat(tpl), err)
+	}
+
+	plaintext, err := secrets.Decrypt(key, ciphertext, additionalDataForTuple(tpl))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt caveat context for relationship %s: %w", tuple.StringWithoutCaveat(tpl), err)
+	}
+
+	caveatContext := &structpb.Struct{}
+	if err := proto.Unmarshal(plaintext, caveatContext); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal caveat context: %w", err)
+	}
+
+	updated := tpl.CloneVT()
+	updated.Caveat.Context = caveatContext
+	return updated, nil
+}
+
+func additionalDataForTuple(tpl *core.RelationTuple) []byte {
+	return []byte(tuple.StringWithoutCaveat(tpl) + "[" + tpl.Caveat.CaveatName + "]")
+}
+
+var (
+	_ datastore.Datastore            = &caveatEncryptingProxy{}
+	_ datastore.UnwrappableDatastore = &caveatEncryptingProxy{}
+)
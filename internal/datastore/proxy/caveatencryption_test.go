@@ -0,0 +1,166 @@
+package proxy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/secrets"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+var (
+	oldKey = secrets.Key{ID: "old", Material: []byte("0123456789abcdef")}
+	newKey = secrets.Key{ID: "new", Material: []byte("fedcba9876543210")}
+)
+
+func caveatedTuple(t *testing.T, tpl string, context map[string]any) *core.RelationTuple {
+	caveatContext, err := structpb.NewStruct(context)
+	require.NoError(t, err)
+
+	parsed := tuple.MustParse(tpl)
+	parsed.Caveat = &core.ContextualizedCaveat{
+		CaveatName: "somecaveat",
+		Context:    caveatContext,
+	}
+	return parsed
+}
+
+func readAll(t *testing.T, ds datastore.Datastore, rev datastore.Revision) []*core.RelationTuple {
+	it, err := ds.SnapshotReader(rev).QueryRelationships(context.Background(), datastore.RelationshipsFilter{
+		ResourceType: "document",
+	})
+	require.NoError(t, err)
+	defer it.Close()
+
+	var found []*core.RelationTuple
+	for {
+		tpl, err := it.Next()
+		require.NoError(t, err)
+		if tpl == nil {
+			break
+		}
+
+		found = append(found, tpl)
+	}
+	return found
+}
+
+func TestCaveatContextEncryption(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	rawDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	require.NoError(err)
+
+	km, err := secrets.NewStaticKeyManager(oldKey)
+	require.NoError(err)
+	ds := NewCaveatContextEncryptingProxy(rawDS, km)
+
+	tpl := caveatedTuple(t, "document:foo#viewer@user:tom", map[string]any{"ssn": "123-45-6789"})
+	rev, err := common.WriteTuples(ctx, ds, core.RelationTupleUpdate_CREATE, tpl)
+	require.NoError(err)
+
+	// The raw datastore must only hold the encrypted form.
+	stored := readAll(t, rawDS, rev)
+	require.Len(stored, 1)
+	require.NotContains(stored[0].Caveat.Context.Fields, "ssn")
+	require.Contains(stored[0].Caveat.Context.Fields, encryptedContextField)
+
+	// Reads through the proxy are transparently decrypted.
+	read := readAll(t, ds, rev)
+	require.Len(read, 1)
+	require.Equal("123-45-6789", read[0].Caveat.Context.Fields["ssn"].GetStringValue())
+
+	// Rotate the key: existing data must remain readable and new writes use the new key.
+	rotated, err := secrets.NewStaticKeyManager(newKey, oldKey)
+	require.NoError(err)
+	ds = NewCaveatContextEncryptingProxy(rawDS, rotated)
+
+	rev, err = common.WriteTuples(ctx, ds, core.RelationTupleUpdate_CREATE,
+		caveatedTuple(t, "document:bar#viewer@user:tom", map[string]any{"ssn": "987-65-4321"}))
+	require.NoError(err)
+
+	read = readAll(t, ds, rev)
+	require.Len(read, 2)
+	for _, tpl := range read {
+		require.Contains(tpl.Caveat.Context.Fields, "ssn")
+	}
+
+	// Without the old key, the original relationship can no longer be read.
+	newOnly, err := secrets.NewStaticKeyManager(newKey)
+	require.NoError(err)
+	ds = NewCaveatContextEncryptingProxy(rawDS, newOnly)
+
+	it, err := ds.SnapshotReader(rev).QueryRelationships(ctx, datastore.RelationshipsFilter{
+		ResourceType:        "document",
+		OptionalResourceIds: []string{"foo"},
+	})
+	require.NoError(err)
+	defer it.Close()
+	unreadable, err := it.Next()
+	require.Nil(unreadable)
+	require.ErrorAs(err, &secrets.ErrUnknownKey{})
+}
+
+func TestCaveatContextEncryptionBoundToRelationship(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	rawDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	require.NoError(err)
+
+	km, err := secrets.NewStaticKeyManager(oldKey)
+	require.NoError(err)
+	ds := NewCaveatContextEncryptingProxy(rawDS, km)
+
+	rev, err := common.WriteTuples(ctx, ds, core.RelationTupleUpdate_CREATE,
+		caveatedTuple(t, "document:foo#viewer@user:tom", map[string]any{"secret": "value"}))
+	require.NoError(err)
+
+	// Copy the encrypted context onto a different relationship, directly in the raw datastore.
+	stored := readAll(t, rawDS, rev)
+	require.Len(stored, 1)
+
+	moved := tuple.MustParse("document:foo#viewer@user:sarah")
+	moved.Caveat = stored[0].Caveat
+	rev, err = common.WriteTuples(ctx, rawDS, core.RelationTupleUpdate_CREATE, moved)
+	require.NoError(err)
+
+	it, err := ds.SnapshotReader(rev).QueryRelationships(ctx, datastore.RelationshipsFilter{
+		ResourceType: "document",
+		OptionalSubjectsSelectors: []datastore.SubjectsSelector{
+			{OptionalSubjectType: "user", OptionalSubjectIds: []string{"sarah"}},
+		},
+	})
+	require.NoError(err)
+	defer it.Close()
+	unreadable, err := it.Next()
+	require.Nil(unreadable)
+	require.Error(err)
+}
+
+func TestCaveatContextEncryptionUncaveated(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	rawDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	require.NoError(err)
+
+	km, err := secrets.NewStaticKeyManager(oldKey)
+	require.NoError(err)
+	ds := NewCaveatContextEncryptingProxy(rawDS, km)
+
+	rev, err := common.WriteTuples(ctx, ds, core.RelationTupleUpdate_CREATE, tuple.MustParse("document:foo#viewer@user:tom"))
+	require.NoError(err)
+
+	read := readAll(t, ds, rev)
+	require.Len(read, 1)
+	require.Nil(read[0].Caveat)
+}
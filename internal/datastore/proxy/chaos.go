@@ -0,0 +1,403 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	yamlv3 "gopkg.in/yaml.v3"
+
+	"github.com/authzed/spicedb/internal/datastore/options"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+)
+
+// ErrInjectedFault is the error returned by operations failed by the chaos proxy, unless the
+// fault specifies another.
+var ErrInjectedFault = errors.New("fault injected by the chaos datastore proxy")
+
+// Fault is a fault injected into a single call of an operation of the datastore.
+type Fault struct {
+	// Latency is added before the operation is performed.
+	Latency time.Duration
+
+	// Err, if set, fails the operation without performing it.
+	Err error
+
+	// FailIterator, for operations returning relationship iterators, fails the iterator with
+	// ErrInjectedFault once it has returned IteratorFailAfter relationships.
+	FailIterator      bool
+	IteratorFailAfter uint64
+}
+
+// FaultInjector decides the fault injected into each call of an operation of the datastore,
+// which is named by the method of the datastore, reader or transaction called, such as
+// `QueryRelationships` or `ReadWriteTx`.
+type FaultInjector interface {
+	Fault(operation string) Fault
+}
+
+// FaultRule configures the faults injected at random into the calls of an operation.
+type FaultRule struct {
+	// Latency is added before every call.
+	Latency time.Duration `yaml:"latency"`
+
+	// LatencyJitter adds a further random latency of up to the jitter to every call.
+	LatencyJitter time.Duration `yaml:"latency_jitter"`
+
+	// ErrorRate is the probability, between 0 and 1, that a call fails with ErrInjectedFault.
+	ErrorRate float64 `yaml:"error_rate"`
+
+	// IteratorFailureRate is the probability, between 0 and 1, that the iterator returned by
+	// a call fails partway through, after IteratorFailAfter relationships.
+	IteratorFailureRate float64 `yaml:"iterator_failure_rate"`
+	IteratorFailAfter   uint64  `yaml:"iterator_fail_after"`
+}
+
+// AllOperations is the name under which a FaultRule applies to every operation which has no
+// rule of its own.
+const AllOperations = "*"
+
+type probabilisticFaults struct {
+	sync.Mutex
+	rules map[string]FaultRule
+	rand  *rand.Rand
+}
+
+// NewProbabilisticFaults creates a fault injector which injects faults at random, according to
+// the rule for each operation. The seed makes the faults injected reproducible.
+func NewProbabilisticFaults(rules map[string]FaultRule, seed int64) FaultInjector {
+	return &probabilisticFaults{rules: rules, rand: rand.New(rand.NewSource(seed))}
+}
+
+func (pf *probabilisticFaults) Fault(operation string) Fault {
+	rule, ok := pf.rules[operation]
+	if !ok {
+		rule, ok = pf.rules[AllOperations]
+		if !ok {
+			return Fault{}
+		}
+	}
+
+	pf.Lock()
+	defer pf.Unlock()
+
+	fault := Fault{Latency: rule.Latency}
+	if rule.LatencyJitter > 0 {
+		fault.Latency += time.Duration(pf.rand.Int63n(int64(rule.LatencyJitter)))
+	}
+	if pf.rand.Float64() < rule.ErrorRate {
+		fault.Err = ErrInjectedFault
+	}
+	if pf.rand.Float64() < rule.IteratorFailureRate {
+		fault.FailIterator = true
+		fault.IteratorFailAfter = rule.IteratorFailAfter
+	}
+	return fault
+}
+
+type scriptedFaults struct {
+	sync.Mutex
+	script map[string][]Fault
+}
+
+// NewScriptedFaults creates a fault injector which injects the faults listed for each
+// operation, in order, into its successive calls. Once its faults are exhausted, an operation
+// is performed without fault.
+func NewScriptedFaults(script map[string][]Fault) FaultInjector {
+	copied := make(map[string][]Fault, len(script))
+	for operation, faults := range script {
+		copied[operation] = append([]Fault(nil), faults...)
+	}
+	return &scriptedFaults{script: copied}
+}
+
+func (sf *scriptedFaults) Fault(operation string) Fault {
+	sf.Lock()
+	defer sf.Unlock()
+
+	faults := sf.script[operation]
+	if len(faults) == 0 {
+		return Fault{}
+	}
+	sf.script[operation] = faults[1:]
+	return faults[0]
+}
+
+// ChaosConfigFile is the structural representation of the file configuring the faults injected
+// at random by the chaos proxy.
+type ChaosConfigFile struct {
+	// Seed seeds the random injection of faults.
+	Seed int64 `yaml:"seed"`
+
+	// Operations are the rules for injecting faults, by operation name, or AllOperations.
+	Operations map[string]FaultRule `yaml:"operations"`
+}
+
+// ReadChaosConfigFile reads the chaos configuration file at the path, returning the fault
+// injector it configures.
+func ReadChaosConfigFile(path string) (FaultInjector, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read chaos config file: %w", err)
+	}
+
+	var config ChaosConfigFile
+	if err := yamlv3.Unmarshal(contents, &config); err != nil {
+		return nil, fmt.Errorf("unable to parse chaos config file: %w", err)
+	}
+
+	for operation, rule := range config.Operations {
+		if rule.ErrorRate < 0 || rule.ErrorRate > 1 || rule.IteratorFailureRate < 0 || rule.IteratorFailureRate > 1 {
+			return nil, fmt.Errorf("rates for operation %s must be between 0 and 1", operation)
+		}
+	}
+	return NewProbabilisticFaults(config.Operations, config.Seed), nil
+}
+
+// NewChaosDatastoreProxy creates a proxy which injects the faults decided by the injector into
+// the operations of the delegate, for validating the handling of datastore failures in tests
+// and staging environments. It must never be used in production.
+func NewChaosDatastoreProxy(d datastore.Datastore, injector FaultInjector) datastore.Datastore {
+	return &chaosProxy{Datastore: d, injector: injector}
+}
+
+type chaosProxy struct {
+	datastore.Datastore
+	injector FaultInjector
+}
+
+func (p *chaosProxy) Unwrap() datastore.Datastore {
+	return p.Datastore
+}
+
+func (p *chaosProxy) SnapshotReader(rev datastore.Revision) datastore.Reader {
+	return &chaosReader{p.Datastore.SnapshotReader(rev), p.injector}
+}
+
+func (p *chaosProxy) ReadWriteTx(ctx context.Context, f datastore.TxUserFunc) (datastore.Revision, error) {
+	if _, err := inject(ctx, p.injector, "ReadWriteTx"); err != nil {
+		return datastore.NoRevision, err
+	}
+
+	return p.Datastore.ReadWriteTx(ctx, func(delegateRWT datastore.ReadWriteTransaction) error {
+		return f(&chaosRWT{&chaosReader{delegateRWT, p.injector}, delegateRWT})
+	})
+}
+
+func (p *chaosProxy) OptimizedRevision(ctx context.Context) (datastore.Revision, error) {
+	if _, err := inject(ctx, p.injector, "OptimizedRevision"); err != nil {
+		return datastore.NoRevision, err
+	}
+	return p.Datastore.OptimizedRevision(ctx)
+}
+
+func (p *chaosProxy) HeadRevision(ctx context.Context) (datastore.Revision, error) {
+	if _, err := inject(ctx, p.injector, "HeadRevision"); err != nil {
+		return datastore.NoRevision, err
+	}
+	return p.Datastore.HeadRevision(ctx)
+}
+
+func (p *chaosProxy) CheckRevision(ctx context.Context, revision datastore.Revision) error {
+	if _, err := inject(ctx, p.injector, "CheckRevision"); err != nil {
+		return err
+	}
+	return p.Datastore.CheckRevision(ctx, revision)
+}
+
+func (p *chaosProxy) Statistics(ctx context.Context) (datastore.Stats, error) {
+	if _, err := inject(ctx, p.injector, "Statistics"); err != nil {
+		return datastore.Stats{}, err
+	}
+	return p.Datastore.Statistics(ctx)
+}
+
+type chaosReader struct {
+	delegate datastore.Reader
+	injector FaultInjector
+}
+
+func (r *chaosReader) ReadCaveatByName(ctx context.Context, name string) (*core.CaveatDefinition, datastore.Revision, error) {
+	if _, err := inject(ctx, r.injector, "ReadCaveatByName"); err != nil {
+		return nil, datastore.NoRevision, err
+	}
+	return r.delegate.ReadCaveatByName(ctx, name)
+}
+
+func (r *chaosReader) LookupCaveatsWithNames(ctx context.Context, caveatNames []string) ([]datastore.RevisionedCaveat, error) {
+	if _, err := inject(ctx, r.injector, "LookupCaveatsWithNames"); err != nil {
+		return nil, err
+	}
+	return r.delegate.LookupCaveatsWithNames(ctx, caveatNames)
+}
+
+func (r *chaosReader) ListAllCaveats(ctx context.Context) ([]datastore.RevisionedCaveat, error) {
+	if _, err := inject(ctx, r.injector, "ListAllCaveats"); err != nil {
+		return nil, err
+	}
+	return r.delegate.ListAllCaveats(ctx)
+}
+
+func (r *chaosReader) ListSchemaVersions(ctx context.Context) ([]*core.SchemaVersion, error) {
+	if _, err := inject(ctx, r.injector, "ListSchemaVersions"); err != nil {
+		return nil, err
+	}
+	return r.delegate.ListSchemaVersions(ctx)
+}
+
+func (r *chaosReader) LatestSchemaVersion(ctx context.Context) (uint64, error) {
+	if _, err := inject(ctx, r.injector, "LatestSchemaVersion"); err != nil {
+		return 0, err
+	}
+	return r.delegate.LatestSchemaVersion(ctx)
+}
+
+func (r *chaosReader) ListAllNamespaces(ctx context.Context) ([]datastore.RevisionedNamespace, error) {
+	if _, err := inject(ctx, r.injector, "ListAllNamespaces"); err != nil {
+		return nil, err
+	}
+	return r.delegate.ListAllNamespaces(ctx)
+}
+
+func (r *chaosReader) LookupNamespacesWithNames(ctx context.Context, nsNames []string) ([]datastore.RevisionedNamespace, error) {
+	if _, err := inject(ctx, r.injector, "LookupNamespacesWithNames"); err != nil {
+		return nil, err
+	}
+	return r.delegate.LookupNamespacesWithNames(ctx, nsNames)
+}
+
+func (r *chaosReader) ReadNamespaceByName(ctx context.Context, nsName string) (*core.NamespaceDefinition, datastore.Revision, error) {
+	if _, err := inject(ctx, r.injector, "ReadNamespaceByName"); err != nil {
+		return nil, datastore.NoRevision, err
+	}
+	return r.delegate.ReadNamespaceByName(ctx, nsName)
+}
+
+func (r *chaosReader) QueryRelationships(ctx context.Context, filter datastore.RelationshipsFilter, options ...options.QueryOptionsOption) (datastore.RelationshipIterator, error) {
+	fault, err := inject(ctx, r.injector, "QueryRelationships")
+	if err != nil {
+		return nil, err
+	}
+
+	iterator, err := r.delegate.QueryRelationships(ctx, filter, options...)
+	if err != nil || !fault.FailIterator {
+		return iterator, err
+	}
+	return &chaosRelationshipIterator{delegate: iterator, remaining: fault.IteratorFailAfter}, nil
+}
+
+func (r *chaosReader) ReverseQueryRelationships(ctx context.Context, subjectFilter datastore.SubjectsFilter, options ...options.ReverseQueryOptionsOption) (datastore.RelationshipIterator, error) {
+	fault, err := inject(ctx, r.injector, "ReverseQueryRelationships")
+	if err != nil {
+		return nil, err
+	}
+
+	iterator, err := r.delegate.ReverseQueryRelationships(ctx, subjectFilter, options...)
+	if err != nil || !fault.FailIterator {
+		return iterator, err
+	}
+	return &chaosRelationshipIterator{delegate: iterator, remaining: fault.IteratorFailAfter}, nil
+}
+
+type chaosRelationshipIterator struct {
+	delegate  datastore.RelationshipIterator
+	remaining uint64
+}
+
+func (i *chaosRelationshipIterator) Next() (*core.RelationTuple, error) {
+	if i.remaining == 0 {
+		return nil, ErrInjectedFault
+	}
+
+	next, err := i.delegate.Next()
+	if next != nil {
+		i.remaining--
+	}
+	return next, err
+}
+
+func (i *chaosRelationshipIterator) Close() { i.delegate.Close() }
+
+type chaosRWT struct {
+	*chaosReader
+	delegate datastore.ReadWriteTransaction
+}
+
+func (rwt *chaosRWT) WriteCaveats(ctx context.Context, caveats []*core.CaveatDefinition) error {
+	if _, err := inject(ctx, rwt.injector, "WriteCaveats"); err != nil {
+		return err
+	}
+	return rwt.delegate.WriteCaveats(ctx, caveats)
+}
+
+func (rwt *chaosRWT) DeleteCaveats(ctx context.Context, names []string) error {
+	if _, err := inject(ctx, rwt.injector, "DeleteCaveats"); err != nil {
+		return err
+	}
+	return rwt.delegate.DeleteCaveats(ctx, names)
+}
+
+func (rwt *chaosRWT) WriteSchemaVersion(ctx context.Context, version *core.SchemaVersion) error {
+	if _, err := inject(ctx, rwt.injector, "WriteSchemaVersion"); err != nil {
+		return err
+	}
+	return rwt.delegate.WriteSchemaVersion(ctx, version)
+}
+
+func (rwt *chaosRWT) WriteRelationships(ctx context.Context, mutations []*core.RelationTupleUpdate) error {
+	if _, err := inject(ctx, rwt.injector, "WriteRelationships"); err != nil {
+		return err
+	}
+	return rwt.delegate.WriteRelationships(ctx, mutations)
+}
+
+func (rwt *chaosRWT) WriteNamespaces(ctx context.Context, newConfigs ...*core.NamespaceDefinition) error {
+	if _, err := inject(ctx, rwt.injector, "WriteNamespaces"); err != nil {
+		return err
+	}
+	return rwt.delegate.WriteNamespaces(ctx, newConfigs...)
+}
+
+func (rwt *chaosRWT) DeleteNamespaces(ctx context.Context, nsNames ...string) error {
+	if _, err := inject(ctx, rwt.injector, "DeleteNamespaces"); err != nil {
+		return err
+	}
+	return rwt.delegate.DeleteNamespaces(ctx, nsNames...)
+}
+
+func (rwt *chaosRWT) DeleteRelationships(ctx context.Context, filter *v1.RelationshipFilter) error {
+	if _, err := inject(ctx, rwt.injector, "DeleteRelationships"); err != nil {
+		return err
+	}
+	return rwt.delegate.DeleteRelationships(ctx, filter)
+}
+
+// inject applies the fault decided for a call of the operation, waiting out its latency and
+// returning its error, if any.
+func inject(ctx context.Context, injector FaultInjector, operation string) (Fault, error) {
+	fault := injector.Fault(operation)
+	if fault.Latency > 0 {
+		timer := time.NewTimer(fault.Latency)
+		defer timer.Stop()
+
+		select {
+		case <-ctx.Done():
+			return fault, ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return fault, fault.Err
+}
+
+var (
+	_ datastore.Datastore            = (*chaosProxy)(nil)
+	_ datastore.Reader               = (*chaosReader)(nil)
+	_ datastore.ReadWriteTransaction = (*chaosRWT)(nil)
+	_ datastore.RelationshipIterator = (*chaosRelationshipIterator)(nil)
+)
@@ -0,0 +1,117 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/internal/testfixtures"
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+func TestChaosScriptedFaults(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	rawDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	require.NoError(err)
+	rawDS, rev := testfixtures.StandardDatastoreWithData(rawDS, require)
+
+	boom := errors.New("boom")
+	ds := NewChaosDatastoreProxy(rawDS, NewScriptedFaults(map[string][]Fault{
+		"HeadRevision":       {{Err: boom}},
+		"QueryRelationships": {{}, {FailIterator: true, IteratorFailAfter: 1}},
+		"ReadWriteTx":        {{Latency: 10 * time.Millisecond}},
+	}))
+	require.Equal(rawDS, ds.(datastore.UnwrappableDatastore).Unwrap())
+
+	// The first call fails, and later calls succeed once the script is exhausted.
+	_, err = ds.HeadRevision(ctx)
+	require.ErrorIs(err, boom)
+	_, err = ds.HeadRevision(ctx)
+	require.NoError(err)
+
+	filter := datastore.RelationshipsFilter{ResourceType: "document"}
+	iter, err := ds.SnapshotReader(rev).QueryRelationships(ctx, filter)
+	require.NoError(err)
+	count := 0
+	for {
+		tpl, err := iter.Next()
+		require.NoError(err)
+		if tpl == nil {
+			break
+		}
+
+		count++
+	}
+	require.Greater(count, 1)
+	iter.Close()
+
+	iter, err = ds.SnapshotReader(rev).QueryRelationships(ctx, filter)
+	require.NoError(err)
+	tpl, err := iter.Next()
+	require.NoError(err)
+	require.NotNil(tpl)
+
+	tpl, err = iter.Next()
+	require.Nil(tpl)
+	require.ErrorIs(err, ErrInjectedFault)
+
+	// The fault is returned by every further call.
+	_, err = iter.Next()
+	require.ErrorIs(err, ErrInjectedFault)
+	iter.Close()
+
+	// Latency is abandoned if the context is canceled first.
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = ds.ReadWriteTx(canceled, func(rwt datastore.ReadWriteTransaction) error { return nil })
+	require.ErrorIs(err, context.Canceled)
+}
+
+func TestChaosProbabilisticFaults(t *testing.T) {
+	require := require.New(t)
+
+	injector := NewProbabilisticFaults(map[string]FaultRule{
+		"QueryRelationships": {ErrorRate: 1},
+		AllOperations:        {Latency: time.Millisecond, LatencyJitter: time.Millisecond},
+	}, 1)
+
+	fault := injector.Fault("QueryRelationships")
+	require.ErrorIs(fault.Err, ErrInjectedFault)
+	require.Zero(fault.Latency)
+
+	fault = injector.Fault("HeadRevision")
+	require.NoError(fault.Err)
+	require.GreaterOrEqual(fault.Latency, time.Millisecond)
+	require.Less(fault.Latency, 2*time.Millisecond)
+}
+
+func TestReadChaosConfigFile(t *testing.T) {
+	require := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "chaos.yaml")
+	require.NoError(os.WriteFile(path, []byte(`
+seed: 42
+operations:
+  ReverseQueryRelationships:
+    latency: 50ms
+    iterator_failure_rate: 1
+    iterator_fail_after: 10
+`), 0o600))
+
+	injector, err := ReadChaosConfigFile(path)
+	require.NoError(err)
+	require.Equal(Fault{Latency: 50 * time.Millisecond, FailIterator: true, IteratorFailAfter: 10}, injector.Fault("ReverseQueryRelationships"))
+	require.Equal(Fault{}, injector.Fault("QueryRelationships"))
+
+	require.NoError(os.WriteFile(path, []byte("operations: {HeadRevision: {error_rate: 2}}"), 0o600))
+	_, err = ReadChaosConfigFile(path)
+	require.ErrorContains(err, "between 0 and 1")
+}
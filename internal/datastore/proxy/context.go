@@ -99,6 +99,14 @@ func (r *ctxReader) LookupCaveatsWithNames(ctx context.Context, caveatNames []st
 	return r.delegate.LookupCaveatsWithNames(SeparateContextWithTracing(ctx), caveatNames)
 }
 
+func (r *ctxReader) ListSchemaVersions(ctx context.Context) ([]*core.SchemaVersion, error) {
+	return r.delegate.ListSchemaVersions(SeparateContextWithTracing(ctx))
+}
+
+func (r *ctxReader) LatestSchemaVersion(ctx context.Context) (uint64, error) {
+	return r.delegate.LatestSchemaVersion(SeparateContextWithTracing(ctx))
+}
+
 func (r *ctxReader) ListAllNamespaces(ctx context.Context) ([]datastore.RevisionedNamespace, error) {
 	return r.delegate.ListAllNamespaces(SeparateContextWithTracing(ctx))
 }
@@ -323,11 +323,13 @@ func TestDatastoreE2E(t *testing.T) {
 	)
 	require.NoError(err)
 
-	only := it.Next()
+	only, err := it.Next()
+	require.NoError(err)
 	require.Equal(expectedTuples[0], only)
 
-	require.Nil(it.Next())
-	require.NoError(it.Err())
+	next, err := it.Next()
+	require.NoError(err)
+	require.Nil(next)
 
 	delegateDatastore.AssertExpectations(t)
 	delegateReader.AssertExpectations(t)
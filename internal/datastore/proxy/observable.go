@@ -67,6 +67,10 @@ func NewObservableDatastoreProxy(d datastore.Datastore) datastore.Datastore {
 
 type observableProxy struct{ delegate datastore.Datastore }
 
+func (p *observableProxy) Unwrap() datastore.Datastore {
+	return p.delegate
+}
+
 func (p *observableProxy) SnapshotReader(rev datastore.Revision) datastore.Reader {
 	delegateReader := p.delegate.SnapshotReader(rev)
 	return &observableReader{delegateReader}
@@ -159,6 +163,20 @@ func (r *observableReader) ListAllCaveats(ctx context.Context) ([]datastore.Revi
 	return r.delegate.ListAllCaveats(ctx)
 }
 
+func (r *observableReader) ListSchemaVersions(ctx context.Context) ([]*core.SchemaVersion, error) {
+	ctx, closer := observe(ctx, "ListSchemaVersions")
+	defer closer()
+
+	return r.delegate.ListSchemaVersions(ctx)
+}
+
+func (r *observableReader) LatestSchemaVersion(ctx context.Context) (uint64, error) {
+	ctx, closer := observe(ctx, "LatestSchemaVersion")
+	defer closer()
+
+	return r.delegate.LatestSchemaVersion(ctx)
+}
+
 func (r *observableReader) ListAllNamespaces(ctx context.Context) ([]datastore.RevisionedNamespace, error) {
 	ctx, closer := observe(ctx, "ListAllNamespaces")
 	defer closer()
@@ -200,16 +218,14 @@ type observableRelationshipIterator struct {
 	count    uint32
 }
 
-func (i *observableRelationshipIterator) Next() *core.RelationTuple {
-	if next := i.delegate.Next(); next != nil {
+func (i *observableRelationshipIterator) Next() (*core.RelationTuple, error) {
+	next, err := i.delegate.Next()
+	if next != nil {
 		i.count++
-		return next
 	}
-	return nil
+	return next, err
 }
 
-func (i *observableRelationshipIterator) Err() error { return i.delegate.Err() }
-
 func (i *observableRelationshipIterator) Close() {
 	loadedRelationshipCount.Observe(float64(i.count))
 	i.closer()
@@ -253,6 +269,15 @@ func (rwt *observableRWT) DeleteCaveats(ctx context.Context, names []string) err
 	return rwt.delegate.DeleteCaveats(ctx, names)
 }
 
+func (rwt *observableRWT) WriteSchemaVersion(ctx context.Context, version *core.SchemaVersion) error {
+	ctx, closer := observe(ctx, "WriteSchemaVersion", trace.WithAttributes(
+		attribute.Int64("version", int64(version.Version)),
+	))
+	defer closer()
+
+	return rwt.delegate.WriteSchemaVersion(ctx, version)
+}
+
 func (rwt *observableRWT) WriteRelationships(ctx context.Context, mutations []*core.RelationTupleUpdate) error {
 	ctx, closer := observe(ctx, "WriteRelationships", trace.WithAttributes(
 		attribute.Int("mutations", len(mutations)),
@@ -0,0 +1,215 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/internal/datastore/options"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// Overlay is a set of hypothetical changes to the data of a datastore.
+type Overlay struct {
+	// Updates are relationship updates applied on top of the relationships of the datastore.
+	Updates []*core.RelationTupleUpdate
+
+	// Schema, if set, replaces the namespace and caveat definitions of the datastore.
+	Schema *OverlaySchema
+}
+
+// OverlaySchema is the set of definitions replacing those of a datastore.
+type OverlaySchema struct {
+	Namespaces []*core.NamespaceDefinition
+	Caveats    []*core.CaveatDefinition
+}
+
+type overlayDatastore struct {
+	datastore.Datastore
+
+	scratch         datastore.Datastore
+	scratchRevision datastore.Revision
+	replacesSchema  bool
+	shadowed        map[string]struct{}
+}
+
+// NewOverlayDatastoreProxy creates a proxy which reads from a downstream delegate datastore as if
+// the changes of the overlay had been written to it, without writing them. Writes are disabled.
+//
+// Relationships touched or deleted by the overlay hide those of the delegate with the same
+// resource, relation and subject, and relationships touched or created by the overlay are
+// added to the results of queries whose filters they match. Query limits are applied to the
+// relationships of the delegate and of the overlay separately, so a limited query may return
+// more relationships than its limit.
+func NewOverlayDatastoreProxy(ctx context.Context, delegate datastore.Datastore, overlay Overlay) (datastore.Datastore, error) {
+	scratch, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create overlay: %w", err)
+	}
+
+	shadowed := make(map[string]struct{}, len(overlay.Updates))
+	scratchRevision, err := scratch.ReadWriteTx(ctx, func(rwt datastore.ReadWriteTransaction) error {
+		if overlay.Schema != nil {
+			if err := rwt.WriteCaveats(ctx, overlay.Schema.Caveats); err != nil {
+				return err
+			}
+			if err := rwt.WriteNamespaces(ctx, overlay.Schema.Namespaces...); err != nil {
+				return err
+			}
+		}
+
+		var written []*core.RelationTupleUpdate
+		for _, update := range overlay.Updates {
+			shadowed[tuple.StringWithoutCaveat(update.Tuple)] = struct{}{}
+			if update.Operation != core.RelationTupleUpdate_DELETE {
+				written = append(written, tuple.Touch(update.Tuple))
+			}
+		}
+		return rwt.WriteRelationships(ctx, written)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create overlay: %w", err)
+	}
+
+	return &overlayDatastore{
+		Datastore:       delegate,
+		scratch:         scratch,
+		scratchRevision: scratchRevision,
+		replacesSchema:  overlay.Schema != nil,
+		shadowed:        shadowed,
+	}, nil
+}
+
+func (od *overlayDatastore) Unwrap() datastore.Datastore {
+	return od.Datastore
+}
+
+func (od *overlayDatastore) SnapshotReader(rev datastore.Revision) datastore.Reader {
+	return &overlayReader{
+		Reader:  od.Datastore.SnapshotReader(rev),
+		scratch: od.scratch.SnapshotReader(od.scratchRevision),
+		parent:  od,
+	}
+}
+
+func (od *overlayDatastore) ReadWriteTx(context.Context, datastore.TxUserFunc) (datastore.Revision, error) {
+	return datastore.NoRevision, errReadOnly
+}
+
+// Close releases the overlay, but not the delegate datastore, which typically outlives it.
+func (od *overlayDatastore) Close() error {
+	return od.scratch.Close()
+}
+
+type overlayReader struct {
+	datastore.Reader
+
+	scratch datastore.Reader
+	parent  *overlayDatastore
+}
+
+// schemaReader returns the reader from which definitions are read.
+func (or *overlayReader) schemaReader() datastore.Reader {
+	if or.parent.replacesSchema {
+		return or.scratch
+	}
+	return or.Reader
+}
+
+func (or *overlayReader) QueryRelationships(
+	ctx context.Context,
+	filter datastore.RelationshipsFilter,
+	opts ...options.QueryOptionsOption,
+) (datastore.RelationshipIterator, error) {
+	overlaid, err := or.scratch.QueryRelationships(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	underlying, err := or.Reader.QueryRelationships(ctx, filter, opts...)
+	if err != nil {
+		overlaid.Close()
+		return nil, err
+	}
+	return &overlayIterator{overlaid: overlaid, underlying: underlying, shadowed: or.parent.shadowed}, nil
+}
+
+func (or *overlayReader) ReverseQueryRelationships(
+	ctx context.Context,
+	subjectsFilter datastore.SubjectsFilter,
+	opts ...options.ReverseQueryOptionsOption,
+) (datastore.RelationshipIterator, error) {
+	overlaid, err := or.scratch.ReverseQueryRelationships(ctx, subjectsFilter, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	underlying, err := or.Reader.ReverseQueryRelationships(ctx, subjectsFilter, opts...)
+	if err != nil {
+		overlaid.Close()
+		return nil, err
+	}
+	return &overlayIterator{overlaid: overlaid, underlying: underlying, shadowed: or.parent.shadowed}, nil
+}
+
+func (or *overlayReader) ReadNamespaceByName(ctx context.Context, nsName string) (*core.NamespaceDefinition, datastore.Revision, error) {
+	return or.schemaReader().ReadNamespaceByName(ctx, nsName)
+}
+
+func (or *overlayReader) ListAllNamespaces(ctx context.Context) ([]datastore.RevisionedNamespace, error) {
+	return or.schemaReader().ListAllNamespaces(ctx)
+}
+
+func (or *overlayReader) LookupNamespacesWithNames(ctx context.Context, nsNames []string) ([]datastore.RevisionedNamespace, error) {
+	return or.schemaReader().LookupNamespacesWithNames(ctx, nsNames)
+}
+
+func (or *overlayReader) ReadCaveatByName(ctx context.Context, name string) (*core.CaveatDefinition, datastore.Revision, error) {
+	return or.schemaReader().ReadCaveatByName(ctx, name)
+}
+
+func (or *overlayReader) ListAllCaveats(ctx context.Context) ([]datastore.RevisionedCaveat, error) {
+	return or.schemaReader().ListAllCaveats(ctx)
+}
+
+func (or *overlayReader) LookupCaveatsWithNames(ctx context.Context, names []string) ([]datastore.RevisionedCaveat, error) {
+	return or.schemaReader().LookupCaveatsWithNames(ctx, names)
+}
+
+// overlayIterator returns the relationships of the overlay, followed by those of the delegate
+// which the overlay does not hide.
+type overlayIterator struct {
+	overlaid   datastore.RelationshipIterator
+	underlying datastore.RelationshipIterator
+	shadowed   map[string]struct{}
+}
+
+func (oi *overlayIterator) Next() (*core.RelationTuple, error) {
+	if oi.overlaid != nil {
+		next, err := oi.overlaid.Next()
+		if next != nil || err != nil {
+			return next, err
+		}
+		oi.overlaid.Close()
+		oi.overlaid = nil
+	}
+
+	for {
+		next, err := oi.underlying.Next()
+		if next == nil || err != nil {
+			return nil, err
+		}
+		if _, ok := oi.shadowed[tuple.StringWithoutCaveat(next)]; !ok {
+			return next, nil
+		}
+	}
+}
+
+func (oi *overlayIterator) Close() {
+	if oi.overlaid != nil {
+		oi.overlaid.Close()
+	}
+	oi.underlying.Close()
+}
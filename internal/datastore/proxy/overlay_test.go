@@ -0,0 +1,155 @@
+package proxy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/internal/testfixtures"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
+	"github.com/authzed/spicedb/pkg/schemadsl/input"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+const overlayTestSchema = `
+	definition user {}
+
+	definition document {
+		relation viewer: user
+		relation editor: user
+	}
+`
+
+func newOverlayTestDatastore(t *testing.T) (datastore.Datastore, datastore.Revision) {
+	rawDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	require.NoError(t, err)
+
+	return testfixtures.DatastoreFromSchemaAndTestRelationships(rawDS, overlayTestSchema, []*core.RelationTuple{
+		tuple.MustParse("document:first#viewer@user:tom"),
+		tuple.MustParse("document:first#viewer@user:sarah"),
+		tuple.MustParse("document:second#editor@user:tom"),
+	}, require.New(t))
+}
+
+func collectTuples(t *testing.T, it datastore.RelationshipIterator, err error) []string {
+	require.NoError(t, err)
+	defer it.Close()
+
+	var found []string
+	for {
+		tpl, err := it.Next()
+		require.NoError(t, err)
+		if tpl == nil {
+			break
+		}
+
+		found = append(found, tuple.MustString(tpl))
+	}
+	return found
+}
+
+func TestOverlayRelationships(t *testing.T) {
+	ctx := context.Background()
+	ds, rev := newOverlayTestDatastore(t)
+
+	overlaid, err := NewOverlayDatastoreProxy(ctx, ds, Overlay{
+		Updates: []*core.RelationTupleUpdate{
+			tuple.Delete(tuple.MustParse("document:first#viewer@user:sarah")),
+			tuple.Touch(tuple.MustParse("document:first#viewer@user:fred")),
+			tuple.Create(tuple.MustParse("document:third#viewer@user:tom")),
+		},
+	})
+	require.NoError(t, err)
+	defer overlaid.Close()
+
+	reader := overlaid.SnapshotReader(rev)
+
+	it, err := reader.QueryRelationships(ctx, datastore.RelationshipsFilter{
+		ResourceType:        "document",
+		OptionalResourceIds: []string{"first"},
+	})
+	require.ElementsMatch(t, []string{
+		"document:first#viewer@user:tom",
+		"document:first#viewer@user:fred",
+	}, collectTuples(t, it, err))
+
+	it, err = reader.ReverseQueryRelationships(ctx, datastore.SubjectsFilter{
+		SubjectType:        "user",
+		OptionalSubjectIds: []string{"tom"},
+	})
+	require.ElementsMatch(t, []string{
+		"document:first#viewer@user:tom",
+		"document:second#editor@user:tom",
+		"document:third#viewer@user:tom",
+	}, collectTuples(t, it, err))
+
+	// The delegate is unchanged.
+	it, err = ds.SnapshotReader(rev).QueryRelationships(ctx, datastore.RelationshipsFilter{
+		ResourceType: "document",
+	})
+	require.ElementsMatch(t, []string{
+		"document:first#viewer@user:tom",
+		"document:first#viewer@user:sarah",
+		"document:second#editor@user:tom",
+	}, collectTuples(t, it, err))
+}
+
+func TestOverlaySchema(t *testing.T) {
+	ctx := context.Background()
+	ds, rev := newOverlayTestDatastore(t)
+
+	emptyDefaultPrefix := ""
+	compiled, err := compiler.Compile(compiler.InputSchema{
+		Source: input.Source("schema"),
+		SchemaString: `
+			definition user {}
+
+			definition document {
+				relation viewer: user
+				relation editor: user
+				permission view = viewer + editor
+			}
+
+			definition folder {
+				relation viewer: user
+			}
+		`,
+	}, &emptyDefaultPrefix)
+	require.NoError(t, err)
+
+	overlaid, err := NewOverlayDatastoreProxy(ctx, ds, Overlay{
+		Schema: &OverlaySchema{Namespaces: compiled.ObjectDefinitions},
+	})
+	require.NoError(t, err)
+	defer overlaid.Close()
+
+	namespaces, err := overlaid.SnapshotReader(rev).ListAllNamespaces(ctx)
+	require.NoError(t, err)
+	require.Len(t, namespaces, 3)
+
+	document, _, err := overlaid.SnapshotReader(rev).ReadNamespaceByName(ctx, "document")
+	require.NoError(t, err)
+	require.Len(t, document.Relation, 3)
+
+	document, _, err = ds.SnapshotReader(rev).ReadNamespaceByName(ctx, "document")
+	require.NoError(t, err)
+	require.Len(t, document.Relation, 2)
+}
+
+func TestOverlayIsReadOnly(t *testing.T) {
+	ctx := context.Background()
+	ds, _ := newOverlayTestDatastore(t)
+
+	overlaid, err := NewOverlayDatastoreProxy(ctx, ds, Overlay{})
+	require.NoError(t, err)
+	defer overlaid.Close()
+
+	_, err = overlaid.ReadWriteTx(ctx, func(rwt datastore.ReadWriteTransaction) error {
+		return nil
+	})
+	require.ErrorAs(t, err, &datastore.ErrReadOnly{})
+}
@@ -168,6 +168,16 @@ func (dm *MockReader) ListAllCaveats(ctx context.Context) ([]datastore.Revisione
 	return args.Get(0).([]datastore.RevisionedCaveat), args.Error(1)
 }
 
+func (dm *MockReader) ListSchemaVersions(ctx context.Context) ([]*core.SchemaVersion, error) {
+	args := dm.Called()
+	return args.Get(0).([]*core.SchemaVersion), args.Error(1)
+}
+
+func (dm *MockReader) LatestSchemaVersion(ctx context.Context) (uint64, error) {
+	args := dm.Called()
+	return args.Get(0).(uint64), args.Error(1)
+}
+
 type MockReadWriteTransaction struct {
 	mock.Mock
 }
@@ -291,6 +301,21 @@ func (dm *MockReadWriteTransaction) DeleteCaveats(ctx context.Context, names []s
 	panic("not used")
 }
 
+func (dm *MockReadWriteTransaction) ListSchemaVersions(ctx context.Context) ([]*core.SchemaVersion, error) {
+	args := dm.Called()
+	return args.Get(0).([]*core.SchemaVersion), args.Error(1)
+}
+
+func (dm *MockReadWriteTransaction) LatestSchemaVersion(ctx context.Context) (uint64, error) {
+	args := dm.Called()
+	return args.Get(0).(uint64), args.Error(1)
+}
+
+func (dm *MockReadWriteTransaction) WriteSchemaVersion(ctx context.Context, version *core.SchemaVersion) error {
+	args := dm.Called(version)
+	return args.Error(0)
+}
+
 var (
 	_ datastore.Datastore            = &MockDatastore{}
 	_ datastore.Reader               = &MockReader{}
@@ -18,6 +18,10 @@ func NewReadonlyDatastore(delegate datastore.Datastore) datastore.Datastore {
 	return roDatastore{Datastore: delegate}
 }
 
+func (rd roDatastore) Unwrap() datastore.Datastore {
+	return rd.Datastore
+}
+
 func (rd roDatastore) ReadWriteTx(context.Context, datastore.TxUserFunc) (datastore.Revision, error) {
 	return datastore.NoRevision, errReadOnly
 }
@@ -0,0 +1,333 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/authzed/spicedb/internal/datastore/options"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/secrets"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+const (
+	// integrityLabel is the label in which the proxy stores the signature of a relationship, in
+	// the form `keyid:base64signature`. As it is not a valid label key, it cannot be set by callers.
+	integrityLabel = "__spicedb_integrity"
+
+	integrityReasonMissing   = "missing"
+	integrityReasonMalformed = "malformed"
+	integrityReasonUnknown   = "unknown_key"
+	integrityReasonMismatch  = "mismatch"
+)
+
+var integrityViolationsCount = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "spicedb",
+	Subsystem: "datastore",
+	Name:      "relationship_integrity_violations_total",
+	Help:      "total number of relationships read whose integrity signature could not be verified, by reason",
+}, []string{"reason"})
+
+// NewRelationshipIntegrityProxy creates a new datastore proxy which signs each relationship
+// written to the delegate datastore with an HMAC over its canonical encoding, stored alongside it,
+// and verifies the signature of each relationship read back. Relationships which fail verification,
+// including those stored without a signature, cause the read to fail with an
+// ErrRelationshipIntegrity, providing evidence of modification of the datastore other than by
+// SpiceDB.
+func NewRelationshipIntegrityProxy(delegate datastore.Datastore, keyManager secrets.KeyManager) datastore.Datastore {
+	return &relationshipIntegrityProxy{Datastore: delegate, keyManager: keyManager}
+}
+
+type relationshipIntegrityProxy struct {
+	datastore.Datastore
+	keyManager secrets.KeyManager
+}
+
+func (p *relationshipIntegrityProxy) SnapshotReader(rev datastore.Revision) datastore.Reader {
+	return &relationshipIntegrityReader{p.Datastore.SnapshotReader(rev), p.keyManager}
+}
+
+func (p *relationshipIntegrityProxy) ReadWriteTx(ctx context.Context, f datastore.TxUserFunc) (datastore.Revision, error) {
+	return p.Datastore.ReadWriteTx(ctx, func(delegateRWT datastore.ReadWriteTransaction) error {
+		return f(&relationshipIntegrityRWT{delegateRWT, p.keyManager})
+	})
+}
+
+func (p *relationshipIntegrityProxy) Watch(ctx context.Context, afterRevision datastore.Revision) (<-chan *datastore.RevisionChanges, <-chan error) {
+	delegateChanges, delegateErrs := p.Datastore.Watch(ctx, afterRevision)
+
+	changes := make(chan *datastore.RevisionChanges)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(changes)
+		defer close(errs)
+
+		for {
+			select {
+			case revChanges, ok := <-delegateChanges:
+				if !ok {
+					return
+				}
+
+				verified := make([]*core.RelationTupleUpdate, 0, len(revChanges.Changes))
+				for _, update := range revChanges.Changes {
+					var tpl *core.RelationTuple
+					if update.Operation == core.RelationTupleUpdate_DELETE {
+						// Only the key of a deleted relationship is meaningful.
+						tpl = withoutIntegrityLabel(update.Tuple)
+					} else {
+						var err error
+						tpl, err = verifyIntegrity(ctx, p.keyManager, update.Tuple)
+						if err != nil {
+							errs <- err
+							return
+						}
+					}
+
+					verified = append(verified, &core.RelationTupleUpdate{
+						Operation: update.Operation,
+						Tuple:     tpl,
+					})
+				}
+
+				select {
+				case changes <- &datastore.RevisionChanges{Revision: revChanges.Revision, Changes: verified}:
+				case <-ctx.Done():
+					errs <- datastore.NewWatchCanceledErr()
+					return
+				}
+
+			case err, ok := <-delegateErrs:
+				if ok {
+					errs <- err
+				}
+				return
+			}
+		}
+	}()
+
+	return changes, errs
+}
+
+func (p *relationshipIntegrityProxy) Unwrap() datastore.Datastore {
+	return p.Datastore
+}
+
+type relationshipIntegrityReader struct {
+	datastore.Reader
+	keyManager secrets.KeyManager
+}
+
+func (r *relationshipIntegrityReader) QueryRelationships(
+	ctx context.Context,
+	filter datastore.RelationshipsFilter,
+	options ...options.QueryOptionsOption,
+) (datastore.RelationshipIterator, error) {
+	it, err := r.Reader.QueryRelationships(ctx, filter, options...)
+	if err != nil {
+		return nil, err
+	}
+	return &verifyingIterator{ctx: ctx, delegate: it, keyManager: r.keyManager}, nil
+}
+
+func (r *relationshipIntegrityReader) ReverseQueryRelationships(
+	ctx context.Context,
+	subjectsFilter datastore.SubjectsFilter,
+	options ...options.ReverseQueryOptionsOption,
+) (datastore.RelationshipIterator, error) {
+	it, err := r.Reader.ReverseQueryRelationships(ctx, subjectsFilter, options...)
+	if err != nil {
+		return nil, err
+	}
+	return &verifyingIterator{ctx: ctx, delegate: it, keyManager: r.keyManager}, nil
+}
+
+type relationshipIntegrityRWT struct {
+	datastore.ReadWriteTransaction
+	keyManager secrets.KeyManager
+}
+
+func (rwt *relationshipIntegrityRWT) QueryRelationships(
+	ctx context.Context,
+	filter datastore.RelationshipsFilter,
+	options ...options.QueryOptionsOption,
+) (datastore.RelationshipIterator, error) {
+	return (&relationshipIntegrityReader{rwt.ReadWriteTransaction, rwt.keyManager}).QueryRelationships(ctx, filter, options...)
+}
+
+func (rwt *relationshipIntegrityRWT) ReverseQueryRelationships(
+	ctx context.Context,
+	subjectsFilter datastore.SubjectsFilter,
+	options ...options.ReverseQueryOptionsOption,
+) (datastore.RelationshipIterator, error) {
+	return (&relationshipIntegrityReader{rwt.ReadWriteTransaction, rwt.keyManager}).ReverseQueryRelationships(ctx, subjectsFilter, options...)
+}
+
+func (rwt *relationshipIntegrityRWT) WriteRelationships(ctx context.Context, mutations []*core.RelationTupleUpdate) error {
+	key, err := rwt.keyManager.PrimaryKey(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to load relationship integrity key: %w", err)
+	}
+
+	signed := make([]*core.RelationTupleUpdate, 0, len(mutations))
+	for _, mutation := range mutations {
+		if mutation.Operation == core.RelationTupleUpdate_DELETE {
+			signed = append(signed, mutation)
+			continue
+		}
+
+		tpl, err := signRelationship(key, mutation.Tuple)
+		if err != nil {
+			return err
+		}
+
+		signed = append(signed, &core.RelationTupleUpdate{
+			Operation: mutation.Operation,
+			Tuple:     tpl,
+		})
+	}
+
+	return rwt.ReadWriteTransaction.WriteRelationships(ctx, signed)
+}
+
+type verifyingIterator struct {
+	ctx        context.Context
+	delegate   datastore.RelationshipIterator
+	keyManager secrets.KeyManager
+	err        error
+}
+
+func (vi *verifyingIterator) Next() (*core.RelationTuple, error) {
+	if vi.err != nil {
+		return nil, vi.err
+	}
+
+	next, err := vi.delegate.Next()
+	if next == nil || err != nil {
+		return nil, err
+	}
+
+	verified, err := verifyIntegrity(vi.ctx, vi.keyManager, next)
+	if err != nil {
+		vi.err = err
+		return nil, err
+	}
+	return verified, nil
+}
+
+func (vi *verifyingIterator) Close() {
+	vi.delegate.Close()
+}
+
+func signRelationship(key secrets.Key, tpl *core.RelationTuple) (*core.RelationTuple, error) {
+	updated := withoutIntegrityLabel(tpl)
+	payload, err := integrityPayload(updated)
+	if err != nil {
+		return nil, err
+	}
+
+	if updated == tpl {
+		updated = tpl.CloneVT()
+	}
+	if updated.Labels == nil {
+		updated.Labels = make(map[string]string, 1)
+	}
+	updated.Labels[integrityLabel] = key.ID + ":" + base64.StdEncoding.EncodeToString(secrets.Sign(key, payload))
+	return updated, nil
+}
+
+func verifyIntegrity(ctx context.Context, keyManager secrets.KeyManager, tpl *core.RelationTuple) (*core.RelationTuple, error) {
+	stripped := withoutIntegrityLabel(tpl)
+
+	signature, ok := tpl.Labels[integrityLabel]
+	if !ok {
+		return nil, integrityViolation(tpl, integrityReasonMissing)
+	}
+
+	keyID, encoded, ok := strings.Cut(signature, ":")
+	if !ok {
+		return nil, integrityViolation(tpl, integrityReasonMalformed)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, integrityViolation(tpl, integrityReasonMalformed)
+	}
+
+	key, err := keyManager.KeyByID(ctx, keyID)
+	if err != nil {
+		if errors.As(err, &secrets.ErrUnknownKey{}) {
+			return nil, integrityViolation(tpl, integrityReasonUnknown)
+		}
+		return nil, fmt.Errorf("unable to load relationship integrity key: %w", err)
+	}
+
+	payload, err := integrityPayload(stripped)
+	if err != nil {
+		return nil, err
+	}
+
+	if !secrets.Verify(key, payload, decoded) {
+		return nil, integrityViolation(tpl, integrityReasonMismatch)
+	}
+	return stripped, nil
+}
+
+func integrityViolation(tpl *core.RelationTuple, reason string) error {
+	integrityViolationsCount.WithLabelValues(reason).Inc()
+	return datastore.NewRelationshipIntegrityErr(tuple.StringWithoutCaveat(tpl), reason)
+}
+
+// integrityPayload returns the canonical encoding of the relationship which is signed: its
+// resource and subject, the name and context of its caveat, and its labels ordered by key.
+func integrityPayload(tpl *core.RelationTuple) ([]byte, error) {
+	var payload bytes.Buffer
+	payload.WriteString(tuple.StringWithoutCaveat(tpl))
+	payload.WriteByte(0)
+
+	if tpl.Caveat != nil && tpl.Caveat.CaveatName != "" {
+		payload.WriteString(tpl.Caveat.CaveatName)
+		payload.WriteByte(0)
+
+		if len(tpl.Caveat.Context.GetFields()) > 0 {
+			context, err := proto.MarshalOptions{Deterministic: true}.Marshal(tpl.Caveat.Context)
+			if err != nil {
+				return nil, fmt.Errorf("unable to marshal caveat context: %w", err)
+			}
+			payload.WriteString(base64.StdEncoding.EncodeToString(context))
+		}
+	}
+	payload.WriteByte(0)
+
+	payload.WriteString(tuple.StringLabels(tpl.Labels))
+	return payload.Bytes(), nil
+}
+
+// withoutIntegrityLabel returns the relationship without the label holding its signature, if any.
+func withoutIntegrityLabel(tpl *core.RelationTuple) *core.RelationTuple {
+	if _, ok := tpl.Labels[integrityLabel]; !ok {
+		return tpl
+	}
+
+	updated := tpl.CloneVT()
+	delete(updated.Labels, integrityLabel)
+	if len(updated.Labels) == 0 {
+		updated.Labels = nil
+	}
+	return updated
+}
+
+var (
+	_ datastore.Datastore            = &relationshipIntegrityProxy{}
+	_ datastore.UnwrappableDatastore = &relationshipIntegrityProxy{}
+)
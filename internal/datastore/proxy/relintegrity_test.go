@@ -0,0 +1,124 @@
+package proxy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/secrets"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+func readOne(ds datastore.Datastore, rev datastore.Revision, subjectID string) (*core.RelationTuple, error) {
+	it, err := ds.SnapshotReader(rev).QueryRelationships(context.Background(), datastore.RelationshipsFilter{
+		ResourceType: "document",
+		OptionalSubjectsSelectors: []datastore.SubjectsSelector{
+			{OptionalSubjectType: "user", OptionalSubjectIds: []string{subjectID}},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+	return it.Next()
+}
+
+func requireIntegrityViolation(t *testing.T, err error, reason string) {
+	var integrityErr datastore.ErrRelationshipIntegrity
+	require.ErrorAs(t, err, &integrityErr)
+	require.Equal(t, reason, integrityErr.Reason())
+}
+
+func TestRelationshipIntegrity(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	rawDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	require.NoError(err)
+
+	km, err := secrets.NewStaticKeyManager(oldKey)
+	require.NoError(err)
+	ds := NewRelationshipIntegrityProxy(rawDS, km)
+
+	labelled := caveatedTuple(t, "document:foo#viewer@user:tom", map[string]any{"ip": "10.0.0.1"})
+	labelled.Labels = map[string]string{"origin": "import"}
+	rev, err := common.WriteTuples(ctx, ds, core.RelationTupleUpdate_CREATE, labelled)
+	require.NoError(err)
+
+	// The raw datastore holds the signature alongside the relationship.
+	stored := readAll(t, rawDS, rev)
+	require.Len(stored, 1)
+	require.Contains(stored[0].Labels, integrityLabel)
+	require.Equal("import", stored[0].Labels["origin"])
+
+	// Reads through the proxy are verified, and do not expose the signature.
+	read, err := readOne(ds, rev, "tom")
+	require.NoError(err)
+	require.Equal(map[string]string{"origin": "import"}, read.Labels)
+	require.Equal("10.0.0.1", read.Caveat.Context.Fields["ip"].GetStringValue())
+
+	// Modifying the caveat context of the stored relationship breaks its signature.
+	tampered := stored[0].CloneVT()
+	tampered.Caveat.Context.Fields["ip"] = structpb.NewStringValue("0.0.0.0")
+	rev, err = common.WriteTuples(ctx, rawDS, core.RelationTupleUpdate_TOUCH, tampered)
+	require.NoError(err)
+
+	_, err = readOne(ds, rev, "tom")
+	requireIntegrityViolation(t, err, integrityReasonMismatch)
+
+	// Copying the signature onto another relationship breaks it too.
+	moved := tuple.MustParse("document:foo#viewer@user:sarah")
+	moved.Labels = stored[0].Labels
+	rev, err = common.WriteTuples(ctx, rawDS, core.RelationTupleUpdate_CREATE, moved)
+	require.NoError(err)
+
+	_, err = readOne(ds, rev, "sarah")
+	requireIntegrityViolation(t, err, integrityReasonMismatch)
+
+	// Relationships written without a signature are reported as such.
+	rev, err = common.WriteTuples(ctx, rawDS, core.RelationTupleUpdate_CREATE, tuple.MustParse("document:foo#viewer@user:fred"))
+	require.NoError(err)
+
+	_, err = readOne(ds, rev, "fred")
+	requireIntegrityViolation(t, err, integrityReasonMissing)
+
+	// Rewriting the relationship through the proxy signs it again.
+	rev, err = common.WriteTuples(ctx, ds, core.RelationTupleUpdate_TOUCH, tuple.MustParse("document:foo#viewer@user:fred"))
+	require.NoError(err)
+
+	read, err = readOne(ds, rev, "fred")
+	require.NoError(err)
+	require.Nil(read.Labels)
+}
+
+func TestRelationshipIntegrityKeyRotation(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	rawDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	require.NoError(err)
+
+	km, err := secrets.NewStaticKeyManager(oldKey)
+	require.NoError(err)
+	rev, err := common.WriteTuples(ctx, NewRelationshipIntegrityProxy(rawDS, km), core.RelationTupleUpdate_CREATE,
+		tuple.MustParse("document:foo#viewer@user:tom"))
+	require.NoError(err)
+
+	// Relationships signed under a previous key remain readable.
+	rotated, err := secrets.NewStaticKeyManager(newKey, oldKey)
+	require.NoError(err)
+	_, err = readOne(NewRelationshipIntegrityProxy(rawDS, rotated), rev, "tom")
+	require.NoError(err)
+
+	// Without the key, they can no longer be verified.
+	newOnly, err := secrets.NewStaticKeyManager(newKey)
+	require.NoError(err)
+	_, err = readOne(NewRelationshipIntegrityProxy(rawDS, newOnly), rev, "tom")
+	requireIntegrityViolation(t, err, integrityReasonUnknown)
+}
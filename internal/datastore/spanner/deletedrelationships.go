@@ -0,0 +1,122 @@
+package spanner
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/spanner"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
+)
+
+const (
+	tableDeletedRelationship        = "deleted_relationship"
+	colDeletedRelationshipDeletion  = "deletion_id"
+	colDeletedRelationshipPosition  = "position"
+	colDeletedRelationshipDeletedAt = "deleted_at"
+	colDeletedRelationshipData      = "data"
+
+	// retainBatchSize is the number of deleted relationships inserted per commit, which must
+	// remain under the limit on the number of mutations of a commit.
+	retainBatchSize = 1000
+)
+
+var (
+	deletedRelationshipCols = []string{
+		colDeletedRelationshipDeletion,
+		colDeletedRelationshipPosition,
+		colDeletedRelationshipDeletedAt,
+		colDeletedRelationshipData,
+	}
+
+	purgeDeletedRelationshipsSQL = fmt.Sprintf(
+		"DELETE FROM %[1]s WHERE %[2]s < TIMESTAMP_SUB(CURRENT_TIMESTAMP(), INTERVAL @micros MICROSECOND)",
+		tableDeletedRelationship, colDeletedRelationshipDeletedAt,
+	)
+)
+
+func deletionKeys(deletionID string) spanner.KeySet {
+	return spanner.Key{deletionID}.AsPrefix()
+}
+
+// RetainDeletedRelationships stores the relationships removed by a deletion. Those previously
+// stored for the deletion are removed first, and the relationships are then inserted in batches.
+func (sd spannerDatastore) RetainDeletedRelationships(ctx context.Context, deletionID string, relationships [][]byte) error {
+	if _, err := sd.client.Apply(ctx, []*spanner.Mutation{
+		spanner.Delete(tableDeletedRelationship, deletionKeys(deletionID)),
+	}); err != nil {
+		return fmt.Errorf("unable to retain deleted relationships: %w", err)
+	}
+
+	for start := 0; start < len(relationships); start += retainBatchSize {
+		end := start + retainBatchSize
+		if end > len(relationships) {
+			end = len(relationships)
+		}
+
+		mutations := make([]*spanner.Mutation, 0, end-start)
+		for position := start; position < end; position++ {
+			mutations = append(mutations, spanner.Insert(
+				tableDeletedRelationship,
+				deletedRelationshipCols,
+				[]interface{}{deletionID, int64(position), spanner.CommitTimestamp, relationships[position]},
+			))
+		}
+
+		if _, err := sd.client.Apply(ctx, mutations); err != nil {
+			return fmt.Errorf("unable to retain deleted relationships: %w", err)
+		}
+	}
+	return nil
+}
+
+// ReadDeletedRelationships returns the relationships retained for a deletion.
+func (sd spannerDatastore) ReadDeletedRelationships(ctx context.Context, deletionID string) ([][]byte, error) {
+	var relationships [][]byte
+	if err := sd.client.Single().Read(
+		ctx,
+		tableDeletedRelationship,
+		deletionKeys(deletionID),
+		[]string{colDeletedRelationshipData},
+	).Do(func(row *spanner.Row) error {
+		var data []byte
+		if err := row.Columns(&data); err != nil {
+			return err
+		}
+		relationships = append(relationships, data)
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("unable to read deleted relationships: %w", err)
+	}
+
+	if len(relationships) == 0 {
+		return nil, common.ErrDeletionNotFound
+	}
+	return relationships, nil
+}
+
+// ForgetDeletedRelationships removes the relationships retained for a deletion.
+func (sd spannerDatastore) ForgetDeletedRelationships(ctx context.Context, deletionID string) error {
+	if _, err := sd.client.Apply(ctx, []*spanner.Mutation{
+		spanner.Delete(tableDeletedRelationship, deletionKeys(deletionID)),
+	}); err != nil {
+		return fmt.Errorf("unable to forget deleted relationships: %w", err)
+	}
+	return nil
+}
+
+// PurgeDeletedRelationships removes the relationships retained for longer than the retention
+// period.
+func (sd spannerDatastore) PurgeDeletedRelationships(ctx context.Context, retention time.Duration) (int64, error) {
+	purged, err := sd.client.PartitionedUpdate(ctx, spanner.Statement{
+		SQL:    purgeDeletedRelationshipsSQL,
+		Params: map[string]interface{}{"micros": retention.Microseconds()},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("unable to purge deleted relationships: %w", err)
+	}
+	return purged, nil
+}
+
+var _ common.DeletedRelationshipStore = spannerDatastore{}
@@ -0,0 +1,109 @@
+package spanner
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/grpc/codes"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
+)
+
+const (
+	tableJob      = "job"
+	colJobID      = "id"
+	colJobVersion = "version"
+	colJobData    = "data"
+)
+
+var jobCols = []string{colJobID, colJobVersion, colJobData}
+
+func jobFromRow(row *spanner.Row) (common.StoredJob, error) {
+	var (
+		job     common.StoredJob
+		version int64
+	)
+	if err := row.Columns(&job.ID, &version, &job.Data); err != nil {
+		return common.StoredJob{}, err
+	}
+	job.Version = uint64(version)
+	return job, nil
+}
+
+// CreateJob stores a new job.
+func (sd spannerDatastore) CreateJob(ctx context.Context, id string, data []byte) error {
+	if _, err := sd.client.Apply(ctx, []*spanner.Mutation{
+		spanner.Insert(tableJob, jobCols, []interface{}{id, int64(1), data}),
+	}); err != nil {
+		return fmt.Errorf("unable to create job: %w", err)
+	}
+	return nil
+}
+
+// ReadJob returns the stored state of a job.
+func (sd spannerDatastore) ReadJob(ctx context.Context, id string) (common.StoredJob, error) {
+	row, err := sd.client.Single().ReadRow(ctx, tableJob, spanner.Key{id}, jobCols)
+	if err != nil {
+		if spanner.ErrCode(err) == codes.NotFound {
+			return common.StoredJob{}, common.ErrJobNotFound
+		}
+		return common.StoredJob{}, fmt.Errorf("unable to read job: %w", err)
+	}
+
+	job, err := jobFromRow(row)
+	if err != nil {
+		return common.StoredJob{}, fmt.Errorf("unable to read job: %w", err)
+	}
+	return job, nil
+}
+
+// ListJobs returns the stored state of every job.
+func (sd spannerDatastore) ListJobs(ctx context.Context) ([]common.StoredJob, error) {
+	var jobs []common.StoredJob
+	if err := sd.client.Single().Read(ctx, tableJob, spanner.AllKeys(), jobCols).Do(func(row *spanner.Row) error {
+		job, err := jobFromRow(row)
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, job)
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("unable to list jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+// UpdateJob replaces the state of a job if it is still at the given version.
+func (sd spannerDatastore) UpdateJob(ctx context.Context, id string, version uint64, data []byte) (bool, error) {
+	var updated bool
+	if _, err := sd.client.ReadWriteTransaction(ctx, func(ctx context.Context, rwt *spanner.ReadWriteTransaction) error {
+		updated = false
+
+		row, err := rwt.ReadRow(ctx, tableJob, spanner.Key{id}, []string{colJobVersion})
+		if err != nil {
+			if spanner.ErrCode(err) == codes.NotFound {
+				return nil
+			}
+			return err
+		}
+
+		var current int64
+		if err := row.Columns(&current); err != nil {
+			return err
+		}
+		if uint64(current) != version {
+			return nil
+		}
+
+		updated = true
+		return rwt.BufferWrite([]*spanner.Mutation{
+			spanner.Update(tableJob, jobCols, []interface{}{id, int64(version + 1), data}),
+		})
+	}); err != nil {
+		return false, fmt.Errorf("unable to update job: %w", err)
+	}
+	return updated, nil
+}
+
+var _ common.JobStore = spannerDatastore{}
@@ -0,0 +1,57 @@
+package spanner
+
+import (
+	"fmt"
+	"sort"
+
+	"cloud.google.com/go/spanner"
+	sq "github.com/Masterminds/squirrel"
+
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+func labelsVal(r *core.RelationTuple) any {
+	if len(r.Labels) == 0 {
+		return nil
+	}
+	return spanner.NullJSON{Value: r.Labels, Valid: true}
+}
+
+func labelsFrom(labels spanner.NullJSON) map[string]string {
+	if !labels.Valid {
+		return nil
+	}
+
+	decoded, ok := labels.Value.(map[string]any)
+	if !ok || len(decoded) == 0 {
+		return nil
+	}
+
+	converted := make(map[string]string, len(decoded))
+	for key, value := range decoded {
+		converted[key] = fmt.Sprint(value)
+	}
+	return converted
+}
+
+// matchLabels returns a clause matching the rows whose labels, stored in the given JSON column,
+// include all of the given labels. As Spanner requires JSON paths to be literals, the keys are
+// inlined into the clause, which is only safe as valid label keys cannot require escaping.
+func matchLabels(colLabels string, labels map[string]string) sq.Sqlizer {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	clause := sq.And{}
+	for _, key := range keys {
+		if !tuple.IsValidLabelKey(key) {
+			// No relationship can have a label with an invalid key.
+			return sq.Expr("FALSE")
+		}
+		clause = append(clause, sq.Expr(fmt.Sprintf(`JSON_VALUE(%s, '$."%s"') = ?`, colLabels, key), labels[key]))
+	}
+	return clause
+}
@@ -0,0 +1,89 @@
+package spanner
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/grpc/codes"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
+)
+
+const (
+	tableLease        = "lease"
+	colLeaseName      = "name"
+	colLeaseHolder    = "holder"
+	colLeaseExpiresAt = "expires_at"
+)
+
+var (
+	renewLeaseSQL = fmt.Sprintf(
+		"UPDATE %[1]s SET %[3]s = @holder, %[4]s = TIMESTAMP_ADD(CURRENT_TIMESTAMP(), INTERVAL @micros MICROSECOND) WHERE %[2]s = @name AND (%[3]s = @holder OR %[4]s < CURRENT_TIMESTAMP())",
+		tableLease, colLeaseName, colLeaseHolder, colLeaseExpiresAt,
+	)
+	insertLeaseSQL = fmt.Sprintf(
+		"INSERT INTO %[1]s (%[2]s, %[3]s, %[4]s) VALUES (@name, @holder, TIMESTAMP_ADD(CURRENT_TIMESTAMP(), INTERVAL @micros MICROSECOND))",
+		tableLease, colLeaseName, colLeaseHolder, colLeaseExpiresAt,
+	)
+	releaseLeaseSQL = fmt.Sprintf(
+		"DELETE FROM %[1]s WHERE %[2]s = @name AND %[3]s = @holder",
+		tableLease, colLeaseName, colLeaseHolder,
+	)
+)
+
+// AcquireLease takes or renews the named lease for the holder. The lease is updated if it is held
+// by the holder or has expired, and otherwise inserted if it does not exist.
+func (sd spannerDatastore) AcquireLease(ctx context.Context, name, holder string, duration time.Duration) (bool, error) {
+	params := map[string]interface{}{
+		"name":   name,
+		"holder": holder,
+		"micros": duration.Microseconds(),
+	}
+
+	var acquired bool
+	if _, err := sd.client.ReadWriteTransaction(ctx, func(ctx context.Context, rwt *spanner.ReadWriteTransaction) error {
+		acquired = false
+
+		updated, err := rwt.Update(ctx, spanner.Statement{SQL: renewLeaseSQL, Params: params})
+		if err != nil {
+			return err
+		}
+		if updated == 1 {
+			acquired = true
+			return nil
+		}
+
+		_, err = rwt.ReadRow(ctx, tableLease, spanner.Key{name}, []string{colLeaseName})
+		if spanner.ErrCode(err) != codes.NotFound {
+			// The lease is held by another holder.
+			return err
+		}
+
+		if _, err := rwt.Update(ctx, spanner.Statement{SQL: insertLeaseSQL, Params: params}); err != nil {
+			return err
+		}
+		acquired = true
+		return nil
+	}); err != nil {
+		return false, fmt.Errorf("unable to acquire lease: %w", err)
+	}
+	return acquired, nil
+}
+
+// ReleaseLease frees the named lease, if it is held by the holder.
+func (sd spannerDatastore) ReleaseLease(ctx context.Context, name, holder string) error {
+	if _, err := sd.client.ReadWriteTransaction(ctx, func(ctx context.Context, rwt *spanner.ReadWriteTransaction) error {
+		_, err := rwt.Update(ctx, spanner.Statement{
+			SQL:    releaseLeaseSQL,
+			Params: map[string]interface{}{"name": name, "holder": holder},
+		})
+		return err
+	}); err != nil {
+		return fmt.Errorf("unable to release lease: %w", err)
+	}
+	return nil
+}
+
+var _ common.LeaseStore = spannerDatastore{}
@@ -0,0 +1,33 @@
+package migrations
+
+import (
+	"context"
+
+	"cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+)
+
+const (
+	addRelationshipLabels = `ALTER TABLE relation_tuple
+		ADD COLUMN labels JSON`
+
+	addChangelogLabels = `ALTER TABLE changelog
+		ADD COLUMN labels JSON`
+)
+
+func init() {
+	if err := SpannerMigrations.Register("add-relationship-labels", "add-caveats", func(ctx context.Context, w Wrapper) error {
+		updateOp, err := w.adminClient.UpdateDatabaseDdl(ctx, &databasepb.UpdateDatabaseDdlRequest{
+			Database: w.client.DatabaseName(),
+			Statements: []string{
+				addRelationshipLabels,
+				addChangelogLabels,
+			},
+		})
+		if err != nil {
+			return err
+		}
+		return updateOp.Wait(ctx)
+	}, nil); err != nil {
+		panic("failed to register migration: " + err.Error())
+	}
+}
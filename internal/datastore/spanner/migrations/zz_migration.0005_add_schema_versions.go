@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"context"
+
+	"cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+)
+
+const createSchemaVersionTable = `CREATE TABLE schema_version (
+		version INT64 NOT NULL,
+		definition BYTES(MAX) NOT NULL,
+		timestamp TIMESTAMP NOT NULL OPTIONS (allow_commit_timestamp=true)
+	) PRIMARY KEY (version)`
+
+func init() {
+	if err := SpannerMigrations.Register("add-schema-versions", "add-relationship-labels", func(ctx context.Context, w Wrapper) error {
+		updateOp, err := w.adminClient.UpdateDatabaseDdl(ctx, &databasepb.UpdateDatabaseDdlRequest{
+			Database: w.client.DatabaseName(),
+			Statements: []string{
+				createSchemaVersionTable,
+			},
+		})
+		if err != nil {
+			return err
+		}
+		return updateOp.Wait(ctx)
+	}, nil); err != nil {
+		panic("failed to register migration: " + err.Error())
+	}
+}
@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"context"
+
+	"cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+)
+
+const createJobTable = `CREATE TABLE job (
+		id STRING(MAX) NOT NULL,
+		version INT64 NOT NULL,
+		data BYTES(MAX) NOT NULL
+	) PRIMARY KEY (id)`
+
+func init() {
+	if err := SpannerMigrations.Register("add-jobs", "add-schema-versions", func(ctx context.Context, w Wrapper) error {
+		updateOp, err := w.adminClient.UpdateDatabaseDdl(ctx, &databasepb.UpdateDatabaseDdlRequest{
+			Database: w.client.DatabaseName(),
+			Statements: []string{
+				createJobTable,
+			},
+		})
+		if err != nil {
+			return err
+		}
+		return updateOp.Wait(ctx)
+	}, nil); err != nil {
+		panic("failed to register migration: " + err.Error())
+	}
+}
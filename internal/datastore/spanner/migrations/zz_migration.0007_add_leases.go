@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"context"
+
+	"cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+)
+
+const createLeaseTable = `CREATE TABLE lease (
+		name STRING(MAX) NOT NULL,
+		holder STRING(MAX) NOT NULL,
+		expires_at TIMESTAMP NOT NULL
+	) PRIMARY KEY (name)`
+
+func init() {
+	if err := SpannerMigrations.Register("add-leases", "add-jobs", func(ctx context.Context, w Wrapper) error {
+		updateOp, err := w.adminClient.UpdateDatabaseDdl(ctx, &databasepb.UpdateDatabaseDdlRequest{
+			Database: w.client.DatabaseName(),
+			Statements: []string{
+				createLeaseTable,
+			},
+		})
+		if err != nil {
+			return err
+		}
+		return updateOp.Wait(ctx)
+	}, nil); err != nil {
+		panic("failed to register migration: " + err.Error())
+	}
+}
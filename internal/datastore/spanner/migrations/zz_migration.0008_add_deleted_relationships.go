@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"context"
+
+	"cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+)
+
+const (
+	createDeletedRelationshipTable = `CREATE TABLE deleted_relationship (
+		deletion_id STRING(MAX) NOT NULL,
+		position INT64 NOT NULL,
+		deleted_at TIMESTAMP NOT NULL OPTIONS (allow_commit_timestamp=true),
+		data BYTES(MAX) NOT NULL
+	) PRIMARY KEY (deletion_id, position)`
+
+	createDeletedRelationshipDeletedAtIndex = `CREATE INDEX ix_deleted_relationship_deleted_at ON deleted_relationship (deleted_at)`
+)
+
+func init() {
+	if err := SpannerMigrations.Register("add-deleted-relationships", "add-leases", func(ctx context.Context, w Wrapper) error {
+		updateOp, err := w.adminClient.UpdateDatabaseDdl(ctx, &databasepb.UpdateDatabaseDdlRequest{
+			Database: w.client.DatabaseName(),
+			Statements: []string{
+				createDeletedRelationshipTable,
+				createDeletedRelationshipDeletedAtIndex,
+			},
+		})
+		if err != nil {
+			return err
+		}
+		return updateOp.Wait(ctx)
+	}, nil); err != nil {
+		panic("failed to register migration: " + err.Error())
+	}
+}
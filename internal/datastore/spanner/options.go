@@ -8,6 +8,9 @@ import (
 type spannerOptions struct {
 	watchBufferLength           uint16
 	revisionQuantization        time.Duration
+	adaptiveQuantization        bool
+	minRevisionQuantization     time.Duration
+	fullQuantizationWriteRate   float64
 	followerReadDelay           time.Duration
 	maxRevisionStalenessPercent float64
 	gcWindow                    time.Duration
@@ -15,12 +18,15 @@ type spannerOptions struct {
 	gcEnabled                   bool
 	credentialsFilePath         string
 	emulatorHost                string
+	queryLogSampleRate          float64
 }
 
 const (
 	errQuantizationTooLarge = "revision quantization (%s) must be less than GC window (%s)"
 
 	defaultRevisionQuantization        = 5 * time.Second
+	defaultMinRevisionQuantization     = 100 * time.Millisecond
+	defaultFullQuantizationWriteRate   = 100
 	defaultFollowerReadDelay           = 0 * time.Second
 	defaultMaxRevisionStalenessPercent = 0.1
 	defaultWatchBufferLength           = 128
@@ -35,6 +41,8 @@ type Option func(*spannerOptions)
 
 func generateConfig(options []Option) (spannerOptions, error) {
 	computed := spannerOptions{
+		minRevisionQuantization:     defaultMinRevisionQuantization,
+		fullQuantizationWriteRate:   defaultFullQuantizationWriteRate,
 		gcWindow:                    defaultGCWindow,
 		gcInterval:                  defaultGCInterval,
 		gcEnabled:                   defaultGCEnabled,
@@ -80,6 +88,37 @@ func RevisionQuantization(bucketSize time.Duration) Option {
 	}
 }
 
+// AdaptiveRevisionQuantization adapts the revision quantization window to the rate of writes,
+// from MinRevisionQuantization when there are no writes up to RevisionQuantization at
+// FullQuantizationWriteRate.
+//
+// This defaults to false.
+func AdaptiveRevisionQuantization(enabled bool) Option {
+	return func(so *spannerOptions) {
+		so.adaptiveQuantization = enabled
+	}
+}
+
+// MinRevisionQuantization is the shortest revision quantization window used when adapting it
+// to the rate of writes.
+//
+// This defaults to 100 milliseconds.
+func MinRevisionQuantization(minimum time.Duration) Option {
+	return func(so *spannerOptions) {
+		so.minRevisionQuantization = minimum
+	}
+}
+
+// FullQuantizationWriteRate is the number of writes per second at and above which the full
+// revision quantization window is used when adapting it to the rate of writes.
+//
+// This defaults to 100.
+func FullQuantizationWriteRate(writesPerSecond float64) Option {
+	return func(so *spannerOptions) {
+		so.fullQuantizationWriteRate = writesPerSecond
+	}
+}
+
 // FollowerReadDelay is the time delay to apply to enable historial reads.
 //
 // This value defaults to 0 seconds.
@@ -143,3 +182,14 @@ func GCEnabled(isGCEnabled bool) Option {
 		so.gcEnabled = isGCEnabled
 	}
 }
+
+// QueryLogSampleRate is the fraction, between 0 and 1, of the relationship queries
+// which are logged with their normalized statement, number of parameters, number of
+// rows returned and duration.
+//
+// This defaults to 0, logging no queries.
+func QueryLogSampleRate(sampleRate float64) Option {
+	return func(so *spannerOptions) {
+		so.queryLogSampleRate = sampleRate
+	}
+}
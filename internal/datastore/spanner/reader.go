@@ -64,6 +64,10 @@ func (sr spannerReader) ReverseQueryRelationships(
 			FilterToRelation(queryOpts.ResRelation.Relation)
 	}
 
+	if queryOpts.ResIDPrefix != "" {
+		qBuilder = qBuilder.FilterToResourceIDPrefix(queryOpts.ResIDPrefix)
+	}
+
 	return sr.querySplitter.SplitAndExecuteQuery(ctx,
 		qBuilder,
 		options.WithLimit(queryOpts.ReverseLimit),
@@ -90,6 +94,7 @@ func queryExecutor(txSource txFactory) common.ExecuteQueryFunc {
 			}
 			var caveatName spanner.NullString
 			var caveatCtx spanner.NullJSON
+			var labels spanner.NullJSON
 			err := row.Columns(
 				&nextTuple.ResourceAndRelation.Namespace,
 				&nextTuple.ResourceAndRelation.ObjectId,
@@ -99,6 +104,7 @@ func queryExecutor(txSource txFactory) common.ExecuteQueryFunc {
 				&nextTuple.Subject.Relation,
 				&caveatName,
 				&caveatCtx,
+				&labels,
 			)
 			if err != nil {
 				return err
@@ -108,6 +114,7 @@ func queryExecutor(txSource txFactory) common.ExecuteQueryFunc {
 			if err != nil {
 				return err
 			}
+			nextTuple.Labels = labelsFrom(labels)
 
 			tuples = append(tuples, nextTuple)
 
@@ -226,6 +233,7 @@ var queryTuples = sql.Select(
 	colUsersetRelation,
 	colCaveatName,
 	colCaveatContext,
+	colLabels,
 ).From(tableRelationship)
 
 var schema = common.SchemaInformation{
@@ -236,6 +244,8 @@ var schema = common.SchemaInformation{
 	ColUsersetObjectID:  colUsersetObjectID,
 	ColUsersetRelation:  colUsersetRelation,
 	ColCaveatName:       colCaveatName,
+	ColLabels:           colLabels,
+	MatchLabels:         matchLabels,
 }
 
 var _ datastore.Reader = spannerReader{}
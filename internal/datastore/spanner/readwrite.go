@@ -121,6 +121,7 @@ func deleteWithFilter(ctx context.Context, rwt *spanner.ReadWriteTransaction, fi
 	}
 	var caveatName spanner.NullString
 	var caveatCtx spanner.NullJSON
+	var labels spanner.NullJSON
 
 	var changelogMutations []*spanner.Mutation
 	if err := toDelete.Do(func(row *spanner.Row) error {
@@ -133,6 +134,7 @@ func deleteWithFilter(ctx context.Context, rwt *spanner.ReadWriteTransaction, fi
 			&rel.Subject.Relation,
 			&caveatName,
 			&caveatCtx,
+			&labels,
 		)
 		if err != nil {
 			return err
@@ -141,6 +143,7 @@ func deleteWithFilter(ctx context.Context, rwt *spanner.ReadWriteTransaction, fi
 		if err != nil {
 			return err
 		}
+		rel.Labels = labelsFrom(labels)
 
 		changelogMutations = append(changelogMutations, spanner.Insert(
 			tableChangelog,
@@ -177,6 +180,7 @@ func upsertVals(r *core.RelationTuple) []any {
 	key := keyFromRelationship(r)
 	key = append(key, spanner.CommitTimestamp)
 	key = append(key, caveatVals(r)...)
+	key = append(key, labelsVal(r))
 	return key
 }
 
@@ -204,6 +208,7 @@ func changeVals(changeUUID string, op int, r *core.RelationTuple) []any {
 		r.Subject.Relation,
 	}
 	vals = append(vals, caveatVals(r)...)
+	vals = append(vals, labelsVal(r))
 	return vals
 }
 
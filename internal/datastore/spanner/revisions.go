@@ -25,6 +25,20 @@ func (sd spannerDatastore) HeadRevision(ctx context.Context) (datastore.Revision
 	return sd.headRevisionInternal(ctx)
 }
 
+// RevisionAtTime returns the revision of the time itself, as Spanner can read at any timestamp.
+func (sd spannerDatastore) RevisionAtTime(ctx context.Context, at time.Time) (datastore.Revision, error) {
+	return revisionFromTimestamp(at), nil
+}
+
+// RevisionTime returns the commit timestamp of the revision.
+func (sd spannerDatastore) RevisionTime(ctx context.Context, revisionRaw datastore.Revision) (time.Time, error) {
+	r, ok := revisionRaw.(revision.Decimal)
+	if !ok {
+		return time.Time{}, datastore.NewInvalidRevisionErr(revisionRaw, datastore.CouldNotDetermineRevision)
+	}
+	return timestampFromRevision(r).UTC(), nil
+}
+
 func (sd spannerDatastore) now(ctx context.Context) (time.Time, error) {
 	ctx, span := tracer.Start(ctx, "now")
 	defer span.End()
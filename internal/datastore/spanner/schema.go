@@ -20,6 +20,7 @@ const (
 	colTimestamp        = "timestamp"
 	colCaveatName       = "caveat_name"
 	colCaveatContext    = "caveat_context"
+	colLabels           = "labels"
 
 	tableChangelog            = "changelog"
 	colChangeUUID             = "uuid"
@@ -33,12 +34,16 @@ const (
 	colChangeUsersetRelation  = "userset_relation"
 	colChangeCaveatName       = "caveat_name"
 	colChangeCaveatContext    = "caveat_context"
+	colChangeLabels           = "labels"
 
 	tableCaveat         = "caveat"
 	colName             = "name"
 	colCaveatDefinition = "definition"
 	colCaveatTS         = "timestamp"
 
+	tableSchemaVersion = "schema_version"
+	colSchemaVersion   = "version"
+
 	tableMetadata = "metadata"
 	colUniqueID   = "unique_id"
 
@@ -61,6 +66,7 @@ var allRelationshipCols = []string{
 	colTimestamp,
 	colCaveatName,
 	colCaveatContext,
+	colLabels,
 }
 
 var allChangelogCols = []string{
@@ -75,6 +81,7 @@ var allChangelogCols = []string{
 	colChangeUsersetRelation,
 	colChangeCaveatName,
 	colChangeCaveatContext,
+	colChangeLabels,
 }
 
 // Both creates and touches are emitted as touched to match other datastores.
@@ -0,0 +1,69 @@
+package spanner
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/spanner"
+
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+)
+
+func (sr spannerReader) ListSchemaVersions(ctx context.Context) ([]*core.SchemaVersion, error) {
+	iter := sr.txSource().Read(
+		ctx,
+		tableSchemaVersion,
+		spanner.AllKeys(),
+		[]string{colCaveatDefinition},
+	)
+
+	var versions []*core.SchemaVersion
+	if err := iter.Do(func(row *spanner.Row) error {
+		var serialized []byte
+		if err := row.Columns(&serialized); err != nil {
+			return err
+		}
+
+		version := &core.SchemaVersion{}
+		if err := version.UnmarshalVT(serialized); err != nil {
+			return err
+		}
+		versions = append(versions, version)
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf(errUnableToListSchemaVersions, err)
+	}
+
+	return versions, nil
+}
+
+func (sr spannerReader) LatestSchemaVersion(ctx context.Context) (uint64, error) {
+	iter := sr.txSource().Query(ctx, spanner.Statement{
+		SQL: fmt.Sprintf("SELECT MAX(%s) FROM %s", colSchemaVersion, tableSchemaVersion),
+	})
+
+	var latest spanner.NullInt64
+	if err := iter.Do(func(row *spanner.Row) error {
+		return row.Columns(&latest)
+	}); err != nil {
+		return 0, fmt.Errorf(errUnableToFindLatestSchemaVersion, err)
+	}
+
+	return uint64(latest.Int64), nil
+}
+
+func (rwt spannerReadWriteTXN) WriteSchemaVersion(ctx context.Context, version *core.SchemaVersion) error {
+	serialized, err := version.MarshalVT()
+	if err != nil {
+		return fmt.Errorf(errUnableToWriteSchemaVersion, err)
+	}
+
+	if err := rwt.spannerRWT.BufferWrite([]*spanner.Mutation{spanner.Insert(
+		tableSchemaVersion,
+		[]string{colSchemaVersion, colCaveatDefinition, colCaveatTS},
+		[]interface{}{int64(version.Version), serialized, spanner.CommitTimestamp},
+	)}); err != nil {
+		return fmt.Errorf(errUnableToWriteSchemaVersion, err)
+	}
+	return nil
+}
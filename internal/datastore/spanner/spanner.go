@@ -47,6 +47,11 @@ const (
 	errUnableToListCaveats  = "unable to list caveats: %w"
 	errUnableToDeleteCaveat = "unable to delete caveat: %w"
 
+	errUnableToWriteSchemaVersion = "unable to write schema version: %w"
+	errUnableToListSchemaVersions = "unable to list schema versions: %w"
+
+	errUnableToFindLatestSchemaVersion = "unable to find latest schema version: %w"
+
 	// Spanner requires a much smaller userset batch size than other datastores because of the
 	// limitation on the maximum number of function calls.
 	// https://cloud.google.com/spanner/quotas
@@ -59,15 +64,18 @@ var (
 	tracer = otel.Tracer("spicedb/internal/datastore/spanner")
 
 	alreadyExistsRegex = regexp.MustCompile(`^Table relation_tuple: Row {String\("([^\"]+)"\), String\("([^\"]+)"\), String\("([^\"]+)"\), String\("([^\"]+)"\), String\("([^\"]+)"\), String\("([^\"]+)"\)} already exists.$`)
+
+	schemaVersionExistsRegex = regexp.MustCompile(`^Table schema_version: Row {Int64\((\d+)\)} already exists.$`)
 )
 
 type spannerDatastore struct {
 	*revisions.RemoteClockRevisions
 	revision.DecimalDecoder
 
-	client *spanner.Client
-	config spannerOptions
-	stopGC context.CancelFunc
+	client      *spanner.Client
+	config      spannerOptions
+	stopGC      context.CancelFunc
+	queryLogger *common.QueryLogger
 }
 
 // NewSpannerDatastore returns a datastore backed by cloud spanner
@@ -77,6 +85,24 @@ func NewSpannerDatastore(database string, opts ...Option) (datastore.Datastore,
 		return nil, fmt.Errorf(errUnableToInstantiate, err)
 	}
 
+	var adaptiveQuantization *revisions.AdaptiveQuantization
+	if config.adaptiveQuantization {
+		adaptiveQuantization, err = revisions.NewAdaptiveQuantization(
+			config.minRevisionQuantization,
+			config.revisionQuantization,
+			config.fullQuantizationWriteRate,
+		)
+		if err != nil {
+			return nil, fmt.Errorf(errUnableToInstantiate, err)
+		}
+	}
+
+	if config.adaptiveQuantization {
+		if err := revisions.RegisterAdaptiveQuantizationMetrics(); err != nil {
+			return nil, fmt.Errorf(errUnableToInstantiate, err)
+		}
+	}
+
 	if len(config.emulatorHost) > 0 {
 		os.Setenv("SPANNER_EMULATOR_HOST", config.emulatorHost)
 	}
@@ -102,10 +128,12 @@ func NewSpannerDatastore(database string, opts ...Option) (datastore.Datastore,
 			config.followerReadDelay,
 			config.revisionQuantization,
 		),
-		client: client,
-		config: config,
+		client:      client,
+		config:      config,
+		queryLogger: common.NewQueryLogger(config.queryLogSampleRate),
 	}
 	ds.RemoteClockRevisions.SetNowFunc(ds.headRevisionInternal)
+	ds.RemoteClockRevisions.SetAdaptiveQuantization(adaptiveQuantization)
 
 	if config.gcInterval > 0*time.Minute && config.gcEnabled {
 		ctx, cancel := context.WithCancel(context.Background())
@@ -130,6 +158,7 @@ func (sd spannerDatastore) SnapshotReader(revisionRaw datastore.Revision) datast
 	querySplitter := common.TupleQuerySplitter{
 		Executor:         queryExecutor(txSource),
 		UsersetBatchSize: usersetBatchsize,
+		QueryLogger:      sd.queryLogger,
 	}
 
 	return spannerReader{querySplitter, txSource}
@@ -147,6 +176,7 @@ func (sd spannerDatastore) ReadWriteTx(
 		querySplitter := common.TupleQuerySplitter{
 			Executor:         queryExecutor(txSource),
 			UsersetBatchSize: usersetBatchsize,
+			QueryLogger:      sd.queryLogger,
 		}
 		rwt := spannerReadWriteTXN{spannerReader{querySplitter, txSource}, spannerRWT}
 		return fn(rwt)
@@ -158,6 +188,7 @@ func (sd spannerDatastore) ReadWriteTx(
 		return datastore.NoRevision, err
 	}
 
+	sd.RecordWrite()
 	return revisionFromTimestamp(ts), nil
 }
 
@@ -206,6 +237,11 @@ func statementFromSQL(sql string, args []interface{}) spanner.Statement {
 func convertToWriteConstraintError(err error) error {
 	if spanner.ErrCode(err) == codes.AlreadyExists {
 		description := spanner.ErrDesc(err)
+		if found := schemaVersionExistsRegex.FindStringSubmatch(description); found != nil {
+			version, _ := strconv.ParseUint(found[1], 10, 64)
+			return datastore.NewSchemaVersionExistsErr(version)
+		}
+
 		found := alreadyExistsRegex.FindStringSubmatch(description)
 		if found != nil {
 			return common.NewCreateRelationshipExistsError(&core.RelationTuple{
@@ -99,6 +99,7 @@ func (sd spannerDatastore) loadChanges(
 		var colChangeUUID string
 		var caveatName spanner.NullString
 		var caveatCtx spanner.NullJSON
+		var labels spanner.NullJSON
 		err := r.Columns(
 			&timestamp,
 			&colChangeUUID,
@@ -111,6 +112,7 @@ func (sd spannerDatastore) loadChanges(
 			&tpl.Subject.Relation,
 			&caveatName,
 			&caveatCtx,
+			&labels,
 		)
 		if err != nil {
 			return err
@@ -119,6 +121,7 @@ func (sd spannerDatastore) loadChanges(
 		if err != nil {
 			return err
 		}
+		tpl.Labels = labelsFrom(labels)
 
 		newTimestamp = maxTime(newTimestamp, timestamp)
 
@@ -99,6 +99,11 @@ func populateFoundSubjects(rootONR *core.ObjectAndRelation, treeNode *core.Relat
 			}
 
 			for _, child := range typed.IntermediateNode.ChildNodes[1:] {
+				// The remaining branches cannot add anything to an empty intersection.
+				if toReturn.IsEmpty() {
+					break
+				}
+
 				childSet, err := populateFoundSubjects(rootONR, child)
 				if err != nil {
 					return nil, err
@@ -132,6 +137,11 @@ func populateFoundSubjects(rootONR *core.ObjectAndRelation, treeNode *core.Relat
 			}
 
 			for _, child := range typed.IntermediateNode.ChildNodes[1:] {
+				// Nothing remains for the remaining branches to exclude.
+				if toReturn.IsEmpty() {
+					break
+				}
+
 				childSet, err := populateFoundSubjects(rootONR, child)
 				if err != nil {
 					return nil, err
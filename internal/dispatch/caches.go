@@ -0,0 +1,52 @@
+package dispatch
+
+import (
+	"context"
+
+	"github.com/authzed/spicedb/internal/dispatch/keys"
+	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+)
+
+type caveatResultCache interface {
+	GetCaveatResult(key keys.DispatchCacheKey) (*v1.ResourceCheckResult, bool)
+	SetCaveatResult(key keys.DispatchCacheKey, result *v1.ResourceCheckResult)
+}
+
+type checkCachePredictor interface {
+	IsCheckCached(ctx context.Context, req *v1.DispatchCheckRequest) (bool, error)
+}
+
+// CacheForwarder forwards the optional interfaces implemented by the caching dispatcher, which
+// are found on the dispatcher given to the API via type assertions, to a delegate dispatcher.
+// Dispatchers wrapping another embed it, so that the caches remain reachable through them.
+type CacheForwarder struct {
+	delegate Dispatcher
+}
+
+// ForwardCaches returns a CacheForwarder forwarding to the delegate.
+func ForwardCaches(delegate Dispatcher) CacheForwarder {
+	return CacheForwarder{delegate}
+}
+
+// GetCaveatResult implements computed.CaveatResultCache
+func (cf CacheForwarder) GetCaveatResult(key keys.DispatchCacheKey) (*v1.ResourceCheckResult, bool) {
+	if cache, ok := cf.delegate.(caveatResultCache); ok {
+		return cache.GetCaveatResult(key)
+	}
+	return nil, false
+}
+
+// SetCaveatResult implements computed.CaveatResultCache
+func (cf CacheForwarder) SetCaveatResult(key keys.DispatchCacheKey, result *v1.ResourceCheckResult) {
+	if cache, ok := cf.delegate.(caveatResultCache); ok {
+		cache.SetCaveatResult(key, result)
+	}
+}
+
+// IsCheckCached implements graph.CheckCachePredictor
+func (cf CacheForwarder) IsCheckCached(ctx context.Context, req *v1.DispatchCheckRequest) (bool, error) {
+	if predictor, ok := cf.delegate.(checkCachePredictor); ok {
+		return predictor.IsCheckCached(ctx, req)
+	}
+	return false, nil
+}
@@ -8,6 +8,7 @@ import (
 	"unsafe"
 
 	"golang.org/x/exp/maps"
+	"golang.org/x/sync/singleflight"
 
 	"github.com/dustin/go-humanize"
 	"github.com/prometheus/client_golang/prometheus"
@@ -40,6 +41,14 @@ type Dispatcher struct {
 	reachableResourcesFromCacheCounter prometheus.Counter
 	lookupSubjectsTotalCounter         prometheus.Counter
 	lookupSubjectsFromCacheCounter     prometheus.Counter
+	checkCoalescedCounter              prometheus.Counter
+	lookupCoalescedCounter             prometheus.Counter
+	caveatResultFromCacheCounter       prometheus.Counter
+
+	checkGroup  singleflight.Group
+	lookupGroup singleflight.Group
+
+	persistence *cachePersistence
 }
 
 func DispatchTestCache(t testing.TB) cache.Cache {
@@ -102,6 +111,23 @@ func NewCachingDispatcher(cacheInst cache.Cache, metricsEnabled bool, prometheus
 		Name:      "lookup_subjects_from_cache_total",
 	})
 
+	checkCoalescedCounter := prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: prometheusNamespace,
+		Subsystem: prometheusSubsystem,
+		Name:      "check_coalesced_total",
+	})
+	lookupCoalescedCounter := prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: prometheusNamespace,
+		Subsystem: prometheusSubsystem,
+		Name:      "lookup_coalesced_total",
+	})
+
+	caveatResultFromCacheCounter := prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: prometheusNamespace,
+		Subsystem: prometheusSubsystem,
+		Name:      "check_caveat_result_from_cache_total",
+	})
+
 	if metricsEnabled && prometheusSubsystem != "" {
 		err := prometheus.Register(checkTotalCounter)
 		if err != nil {
@@ -135,6 +161,18 @@ func NewCachingDispatcher(cacheInst cache.Cache, metricsEnabled bool, prometheus
 		if err != nil {
 			return nil, fmt.Errorf(errCachingInitialization, err)
 		}
+		err = prometheus.Register(checkCoalescedCounter)
+		if err != nil {
+			return nil, fmt.Errorf(errCachingInitialization, err)
+		}
+		err = prometheus.Register(lookupCoalescedCounter)
+		if err != nil {
+			return nil, fmt.Errorf(errCachingInitialization, err)
+		}
+		err = prometheus.Register(caveatResultFromCacheCounter)
+		if err != nil {
+			return nil, fmt.Errorf(errCachingInitialization, err)
+		}
 	}
 
 	if keyHandler == nil {
@@ -153,6 +191,9 @@ func NewCachingDispatcher(cacheInst cache.Cache, metricsEnabled bool, prometheus
 		reachableResourcesFromCacheCounter: reachableResourcesFromCacheCounter,
 		lookupSubjectsTotalCounter:         lookupSubjectsTotalCounter,
 		lookupSubjectsFromCacheCounter:     lookupSubjectsFromCacheCounter,
+		checkCoalescedCounter:              checkCoalescedCounter,
+		lookupCoalescedCounter:             lookupCoalescedCounter,
+		caveatResultFromCacheCounter:       caveatResultFromCacheCounter,
 	}, nil
 }
 
@@ -171,9 +212,9 @@ func (cd *Dispatcher) DispatchCheck(ctx context.Context, req *v1.DispatchCheckRe
 	}
 
 	// Disable caching when debugging is enabled.
-	if cachedResultRaw, found := cd.c.Get(requestKey); found {
+	if cachedResult, found := cd.getCachedBytes(requestKey, req.Metadata.AtRevision); found {
 		var response v1.DispatchCheckResponse
-		if err := response.UnmarshalVT(cachedResultRaw.([]byte)); err != nil {
+		if err := response.UnmarshalVT(cachedResult); err != nil {
 			return &v1.DispatchCheckResponse{Metadata: &v1.ResponseMeta{}}, err
 		}
 
@@ -193,7 +234,15 @@ func (cd *Dispatcher) DispatchCheck(ctx context.Context, req *v1.DispatchCheckRe
 			return &response, nil
 		}
 	}
-	computed, err := cd.d.DispatchCheck(ctx, req)
+
+	var computed *v1.DispatchCheckResponse
+	if req.Debug == v1.DispatchCheckRequest_NO_DEBUG {
+		computed, err = coalesce(ctx, &cd.checkGroup, requestKey, req.Metadata, cd.checkCoalescedCounter, func() (*v1.DispatchCheckResponse, error) {
+			return cd.d.DispatchCheck(ctx, req)
+		})
+	} else {
+		computed, err = cd.d.DispatchCheck(ctx, req)
+	}
 
 	// We only want to cache the result if there was no error
 	if err == nil {
@@ -207,7 +256,7 @@ func (cd *Dispatcher) DispatchCheck(ctx context.Context, req *v1.DispatchCheckRe
 			return &v1.DispatchCheckResponse{Metadata: &v1.ResponseMeta{}}, err
 		}
 
-		cd.c.Set(requestKey, adjustedBytes, sliceSize(adjustedBytes))
+		cd.setCachedBytes(requestKey, req.Metadata.AtRevision, adjustedBytes)
 	}
 
 	// Return both the computed and err in ALL cases: computed contains resolved
@@ -215,6 +264,54 @@ func (cd *Dispatcher) DispatchCheck(ctx context.Context, req *v1.DispatchCheckRe
 	return computed, err
 }
 
+// IsCheckCached implements graph.CheckCachePredictor
+func (cd *Dispatcher) IsCheckCached(ctx context.Context, req *v1.DispatchCheckRequest) (bool, error) {
+	requestKey, err := cd.keyHandler.CheckCacheKey(ctx, req)
+	if err != nil {
+		return false, err
+	}
+
+	cachedResultRaw, found := cd.c.Get(requestKey)
+	if !found {
+		return false, nil
+	}
+
+	var response v1.DispatchCheckResponse
+	if err := response.UnmarshalVT(cachedResultRaw.([]byte)); err != nil {
+		return false, err
+	}
+
+	return req.Metadata.DepthRemaining >= response.Metadata.DepthRequired, nil
+}
+
+// GetCaveatResult implements computed.CaveatResultCache
+func (cd *Dispatcher) GetCaveatResult(key keys.DispatchCacheKey) (*v1.ResourceCheckResult, bool) {
+	cachedResultRaw, found := cd.c.Get(key)
+	if !found {
+		return nil, false
+	}
+
+	var result v1.ResourceCheckResult
+	if err := result.UnmarshalVT(cachedResultRaw.([]byte)); err != nil {
+		log.Warn().Err(err).Msg("failed to unmarshal cached caveat result")
+		return nil, false
+	}
+
+	cd.caveatResultFromCacheCounter.Inc()
+	return &result, true
+}
+
+// SetCaveatResult implements computed.CaveatResultCache
+func (cd *Dispatcher) SetCaveatResult(key keys.DispatchCacheKey, result *v1.ResourceCheckResult) {
+	resultBytes, err := result.MarshalVT()
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to marshal caveat result for caching")
+		return
+	}
+
+	cd.c.Set(key, resultBytes, sliceSize(resultBytes))
+}
+
 // DispatchExpand implements dispatch.Expand interface and does not do any caching yet.
 func (cd *Dispatcher) DispatchExpand(ctx context.Context, req *v1.DispatchExpandRequest) (*v1.DispatchExpandResponse, error) {
 	resp, err := cd.d.DispatchExpand(ctx, req)
@@ -230,9 +327,9 @@ func (cd *Dispatcher) DispatchLookup(ctx context.Context, req *v1.DispatchLookup
 		return &v1.DispatchLookupResponse{Metadata: &v1.ResponseMeta{}}, err
 	}
 
-	if cachedResultRaw, found := cd.c.Get(requestKey); found {
+	if cachedResult, found := cd.getCachedBytes(requestKey, req.Metadata.AtRevision); found {
 		var response v1.DispatchLookupResponse
-		if err := response.UnmarshalVT(cachedResultRaw.([]byte)); err != nil {
+		if err := response.UnmarshalVT(cachedResult); err != nil {
 			return &v1.DispatchLookupResponse{Metadata: &v1.ResponseMeta{}}, err
 		}
 
@@ -242,7 +339,10 @@ func (cd *Dispatcher) DispatchLookup(ctx context.Context, req *v1.DispatchLookup
 			return &response, nil
 		}
 	}
-	computed, err := cd.d.DispatchLookup(ctx, req)
+
+	computed, err := coalesce(ctx, &cd.lookupGroup, requestKey, req.Metadata, cd.lookupCoalescedCounter, func() (*v1.DispatchLookupResponse, error) {
+		return cd.d.DispatchLookup(ctx, req)
+	})
 
 	// We only want to cache the result if there was no error.
 	if err == nil {
@@ -258,7 +358,7 @@ func (cd *Dispatcher) DispatchLookup(ctx context.Context, req *v1.DispatchLookup
 			return &v1.DispatchLookupResponse{Metadata: &v1.ResponseMeta{}}, err
 		}
 
-		cd.c.Set(requestKey, adjustedBytes, sliceSize(adjustedBytes))
+		cd.setCachedBytes(requestKey, req.Metadata.AtRevision, adjustedBytes)
 	}
 
 	// Return both the computed and err in ALL cases: computed contains resolved
@@ -408,11 +508,18 @@ func (cd *Dispatcher) Close() error {
 	prometheus.Unregister(cd.reachableResourcesFromCacheCounter)
 	prometheus.Unregister(cd.lookupSubjectsFromCacheCounter)
 	prometheus.Unregister(cd.lookupSubjectsTotalCounter)
+	prometheus.Unregister(cd.caveatResultFromCacheCounter)
+
+	var persistErr error
+	if cd.persistence != nil {
+		persistErr = cd.closePersistence()
+	}
+
 	if cache := cd.c; cache != nil {
 		cache.Close()
 	}
 
-	return nil
+	return persistErr
 }
 
 func (cd *Dispatcher) IsReady() bool {
@@ -2,6 +2,9 @@ package caching
 
 import (
 	"context"
+	"errors"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -145,6 +148,221 @@ func TestMaxDepthCaching(t *testing.T) {
 	}
 }
 
+func TestCoalescedCheck(t *testing.T) {
+	require := require.New(t)
+
+	parsed := tuple.ParseONR("document:doc1#read")
+	req := &v1.DispatchCheckRequest{
+		ResourceRelation: RR(parsed.Namespace, parsed.Relation),
+		ResourceIds:      []string{parsed.ObjectId},
+		Subject:          tuple.ParseSubjectONR("user:user1#..."),
+		Metadata: &v1.ResolverMeta{
+			AtRevision:     decimal.Zero.String(),
+			DepthRemaining: 50,
+		},
+	}
+
+	release := make(chan time.Time)
+	delegate := delegateDispatchMock{&mock.Mock{}}
+	delegate.On("DispatchCheck", req).WaitUntil(release).Return(&v1.DispatchCheckResponse{
+		ResultsByResourceId: map[string]*v1.ResourceCheckResult{
+			parsed.ObjectId: {
+				Membership: v1.ResourceCheckResult_MEMBER,
+			},
+		},
+		Metadata: &v1.ResponseMeta{
+			DispatchCount: 1,
+			DepthRequired: 1,
+		},
+	}, nil).Times(1)
+
+	dispatch, err := NewCachingDispatcher(DispatchTestCache(t), false, "", nil)
+	require.NoError(err)
+	dispatch.SetDelegate(delegate)
+	defer dispatch.Close()
+
+	const concurrentRequests = 5
+	responses := make(chan *v1.DispatchCheckResponse, concurrentRequests)
+	for i := 0; i < concurrentRequests; i++ {
+		go func() {
+			resp, err := dispatch.DispatchCheck(context.Background(), req)
+			require.NoError(err)
+			responses <- resp
+		}()
+	}
+
+	// Give all of the requests time to become in flight before the delegate returns.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	var dispatchCount, cachedDispatchCount uint32
+	for i := 0; i < concurrentRequests; i++ {
+		resp := <-responses
+		require.Equal(v1.ResourceCheckResult_MEMBER, resp.ResultsByResourceId[parsed.ObjectId].Membership)
+		dispatchCount += resp.Metadata.DispatchCount
+		cachedDispatchCount += resp.Metadata.CachedDispatchCount
+	}
+
+	require.Equal(uint32(1), dispatchCount)
+	require.Equal(uint32(concurrentRequests-1), cachedDispatchCount)
+	delegate.AssertExpectations(t)
+}
+
+func TestCoalescedCheckCanceledFollower(t *testing.T) {
+	require := require.New(t)
+
+	parsed := tuple.ParseONR("document:doc1#read")
+	req := &v1.DispatchCheckRequest{
+		ResourceRelation: RR(parsed.Namespace, parsed.Relation),
+		ResourceIds:      []string{parsed.ObjectId},
+		Subject:          tuple.ParseSubjectONR("user:user1#..."),
+		Metadata: &v1.ResolverMeta{
+			AtRevision:     decimal.Zero.String(),
+			DepthRemaining: 50,
+		},
+	}
+
+	release := make(chan time.Time)
+	delegate := delegateDispatchMock{&mock.Mock{}}
+	delegate.On("DispatchCheck", req).WaitUntil(release).Return(&v1.DispatchCheckResponse{
+		ResultsByResourceId: map[string]*v1.ResourceCheckResult{
+			parsed.ObjectId: {
+				Membership: v1.ResourceCheckResult_MEMBER,
+			},
+		},
+		Metadata: &v1.ResponseMeta{
+			DispatchCount: 1,
+			DepthRequired: 1,
+		},
+	}, nil).Times(1)
+
+	dispatch, err := NewCachingDispatcher(DispatchTestCache(t), false, "", nil)
+	require.NoError(err)
+	dispatch.SetDelegate(delegate)
+	defer dispatch.Close()
+
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := dispatch.DispatchCheck(context.Background(), req)
+		leaderErr <- err
+	}()
+
+	// Give the leader time to become in flight before the follower joins it.
+	time.Sleep(50 * time.Millisecond)
+
+	// The follower returns the error of its own context, while the leader is still in flight.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = dispatch.DispatchCheck(ctx, req)
+	require.ErrorIs(err, context.DeadlineExceeded)
+
+	close(release)
+	require.NoError(<-leaderErr)
+	delegate.AssertExpectations(t)
+}
+
+func TestPersistedCheckResults(t *testing.T) {
+	require := require.New(t)
+
+	parsed := tuple.ParseONR("document:doc1#read")
+	checkAt := func(revision string) *v1.DispatchCheckRequest {
+		return &v1.DispatchCheckRequest{
+			ResourceRelation: RR(parsed.Namespace, parsed.Relation),
+			ResourceIds:      []string{parsed.ObjectId},
+			Subject:          tuple.ParseSubjectONR("user:user1#..."),
+			Metadata: &v1.ResolverMeta{
+				AtRevision:     revision,
+				DepthRemaining: 50,
+			},
+		}
+	}
+	memberResponse := &v1.DispatchCheckResponse{
+		ResultsByResourceId: map[string]*v1.ResourceCheckResult{
+			parsed.ObjectId: {
+				Membership: v1.ResourceCheckResult_MEMBER,
+			},
+		},
+		Metadata: &v1.ResponseMeta{
+			DispatchCount: 1,
+			DepthRequired: 1,
+		},
+	}
+
+	path := filepath.Join(t.TempDir(), "dispatch.cache")
+	newDispatcher := func(delegate delegateDispatchMock, version string) *Dispatcher {
+		dispatch, err := NewCachingDispatcher(DispatchTestCache(t), false, "", nil)
+		require.NoError(err)
+		dispatch.SetDelegate(delegate)
+
+		require.NoError(dispatch.EnablePersistence(PersistenceConfig{
+			Path:    path,
+			Version: version,
+			ValidateRevision: func(ctx context.Context, revision string) error {
+				if revision == "2" {
+					return errors.New("revision has been garbage collected")
+				}
+				return nil
+			},
+		}))
+
+		// Wait for the persisted results to be loaded.
+		<-dispatch.persistence.done
+		return dispatch
+	}
+
+	// Compute and cache the results at two revisions, and then persist them on close.
+	first := delegateDispatchMock{&mock.Mock{}}
+	first.On("DispatchCheck", checkAt("1")).Return(memberResponse, nil).Times(1)
+	first.On("DispatchCheck", checkAt("2")).Return(memberResponse, nil).Times(1)
+
+	dispatch := newDispatcher(first, "v1.0.0")
+	for _, revision := range []string{"1", "2"} {
+		_, err := dispatch.DispatchCheck(context.Background(), checkAt(revision))
+		require.NoError(err)
+	}
+	require.NoError(dispatch.Close())
+	first.AssertExpectations(t)
+
+	// The result at the first revision is loaded, while the one at the second revision fails
+	// validation and must be recomputed.
+	second := delegateDispatchMock{&mock.Mock{}}
+	second.On("DispatchCheck", checkAt("2")).Return(memberResponse, nil).Times(1)
+
+	dispatch = newDispatcher(second, "v1.0.0")
+	for _, revision := range []string{"1", "2"} {
+		resp, err := dispatch.DispatchCheck(context.Background(), checkAt(revision))
+		require.NoError(err)
+		require.Equal(v1.ResourceCheckResult_MEMBER, resp.ResultsByResourceId[parsed.ObjectId].Membership)
+	}
+	require.NoError(dispatch.Close())
+	second.AssertExpectations(t)
+
+	// The results persisted by another version are discarded, and must be recomputed.
+	third := delegateDispatchMock{&mock.Mock{}}
+	third.On("DispatchCheck", checkAt("1")).Return(memberResponse, nil).Times(1)
+
+	dispatch = newDispatcher(third, "v1.1.0")
+	defer dispatch.Close()
+
+	_, err := dispatch.DispatchCheck(context.Background(), checkAt("1"))
+	require.NoError(err)
+	third.AssertExpectations(t)
+}
+
+func TestPersistedCacheRejectsInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dispatch.cache")
+	require.NoError(t, os.WriteFile(path, []byte("not a cache"), 0o600))
+
+	cp := &cachePersistence{
+		config:  PersistenceConfig{Path: path, MaxEntries: 10},
+		tracked: make(map[persistentKey]trackedEntry),
+		loaded:  make(map[persistentKey]persistedEntry),
+	}
+
+	_, err := cp.load(context.Background())
+	require.ErrorContains(t, err, "invalid persisted dispatch cache")
+}
+
 type delegateDispatchMock struct {
 	*mock.Mock
 }
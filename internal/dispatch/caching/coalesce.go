@@ -0,0 +1,90 @@
+package caching
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/singleflight"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/authzed/spicedb/internal/dispatch/keys"
+	"github.com/authzed/spicedb/internal/experiments"
+	"github.com/authzed/spicedb/internal/graph"
+	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+)
+
+type coalescableResponse[T any] interface {
+	CloneVT() T
+	GetMetadata() *v1.ResponseMeta
+}
+
+// coalesce invokes compute, unless an identical request (as determined by its cache key) is
+// already in flight, in which case the result of the in-flight request is shared instead. Callers
+// stop waiting for the result once their own context is done.
+func coalesce[T coalescableResponse[T]](
+	ctx context.Context,
+	group *singleflight.Group,
+	requestKey keys.DispatchCacheKey,
+	metadata *v1.ResolverMeta,
+	coalescedCounter prometheus.Counter,
+	compute func() (T, error),
+) (T, error) {
+	if !experiments.IsEnabled(experiments.DispatchCoalescing) {
+		return compute()
+	}
+
+	executed := false
+	resultChan := group.DoChan(coalescingKey(requestKey), func() (any, error) {
+		executed = true
+		return compute()
+	})
+
+	var result singleflight.Result
+	select {
+	case <-ctx.Done():
+		var canceled T
+		return canceled, ctx.Err()
+	case result = <-resultChan:
+	}
+
+	resp, err := result.Val.(T), result.Err
+	if executed {
+		return resp, err
+	}
+
+	// The result was computed for another request. If that request was canceled or had
+	// insufficient depth, its result cannot be used, so compute the result directly.
+	if (err != nil && isCancellation(err) && ctx.Err() == nil) ||
+		metadata.DepthRemaining < resp.GetMetadata().GetDepthRequired() {
+		return compute()
+	}
+
+	coalescedCounter.Inc()
+
+	// The response is shared between all callers, so a copy is returned with the work
+	// performed to compute it marked as cached.
+	adjusted := resp.CloneVT()
+	if adjustedMetadata := adjusted.GetMetadata(); adjustedMetadata != nil {
+		adjustedMetadata.CachedDispatchCount += adjustedMetadata.DispatchCount
+		adjustedMetadata.DispatchCount = 0
+		adjustedMetadata.DebugInfo = nil
+	}
+	return adjusted, err
+}
+
+func coalescingKey(requestKey keys.DispatchCacheKey) string {
+	first, second := requestKey.AsUInt64s()
+	return strconv.FormatUint(first, 36) + ":" + strconv.FormatUint(second, 36)
+}
+
+func isCancellation(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.As(err, &graph.ErrRequestCanceled{}) {
+		return true
+	}
+
+	code := status.Code(err)
+	return code == codes.Canceled || code == codes.DeadlineExceeded
+}
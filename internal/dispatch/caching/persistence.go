@@ -0,0 +1,483 @@
+package caching
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/authzed/spicedb/internal/dispatch/keys"
+	log "github.com/authzed/spicedb/internal/logging"
+	"github.com/authzed/spicedb/pkg/releases"
+)
+
+const (
+	persistenceFileMagic   = "SPICEDBDC"
+	persistenceFileVersion = 2
+
+	defaultMaxPersistedEntries = 1_000_000
+)
+
+// RevisionValidator returns an error if cached results computed at the revision can no longer be
+// used, for example because the revision has been garbage collected.
+type RevisionValidator func(ctx context.Context, revision string) error
+
+// PersistenceConfig configures persisting the cached check and lookup results of a dispatcher
+// to disk, so that they survive restarts.
+type PersistenceConfig struct {
+	// Path is the file to which the cached results are persisted.
+	Path string
+
+	// Interval is the interval at which the cached results are persisted, in addition to when
+	// the dispatcher is closed. If zero, results are only persisted on close.
+	Interval time.Duration
+
+	// MaxEntries is the maximum number of results persisted. Defaults to 1,000,000.
+	MaxEntries int
+
+	// ValidateRevision validates the revisions of the results loaded from disk. Results at
+	// revisions failing validation are discarded.
+	ValidateRevision RevisionValidator
+
+	// Version is the version of SpiceDB recorded in the file, which is discarded when loaded by
+	// any other version, as the cache keys or the results themselves may be computed differently.
+	// Defaults to the current version.
+	Version string
+}
+
+type persistentKey [2]uint64
+
+func persistentKeyFor(key keys.DispatchCacheKey) persistentKey {
+	first, second := key.PersistentSums()
+	return persistentKey{first, second}
+}
+
+type trackedEntry struct {
+	key      keys.DispatchCacheKey
+	revision string
+}
+
+type persistedEntry struct {
+	revision string
+	value    []byte
+}
+
+// cachePersistence tracks the keys of the results added to the cache, so that they can be read
+// back from the cache and persisted, as well as the results loaded from disk but not yet used.
+//
+// NOTE: the results loaded from disk cannot be added to the cache directly, as the cache is keyed
+// in part by a process-specific hash, which is only known once a request computes its key.
+type cachePersistence struct {
+	config PersistenceConfig
+
+	mu      sync.Mutex
+	tracked map[persistentKey]trackedEntry
+	loaded  map[persistentKey]persistedEntry
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// EnablePersistence enables persisting cached results to disk. The results persisted by a
+// previous process are loaded in the background.
+func (cd *Dispatcher) EnablePersistence(config PersistenceConfig) error {
+	if config.Path == "" {
+		return errors.New("missing path for dispatch cache persistence")
+	}
+
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = defaultMaxPersistedEntries
+	}
+
+	if config.Version == "" {
+		version, err := releases.CurrentVersion()
+		if err != nil {
+			return fmt.Errorf("unable to determine the version for dispatch cache persistence: %w", err)
+		}
+		config.Version = version
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cd.persistence = &cachePersistence{
+		config:  config,
+		tracked: make(map[persistentKey]trackedEntry),
+		loaded:  make(map[persistentKey]persistedEntry),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+
+	go cd.runPersistence(ctx)
+	return nil
+}
+
+func (cd *Dispatcher) runPersistence(ctx context.Context) {
+	defer close(cd.persistence.done)
+
+	start := time.Now()
+	loaded, err := cd.persistence.load(ctx)
+	if err != nil {
+		log.Ctx(ctx).Warn().Err(err).Str("path", cd.persistence.config.Path).Msg("failed to load persisted dispatch cache")
+	} else {
+		log.Ctx(ctx).Info().
+			Int("entries", loaded).
+			Dur("duration", time.Since(start)).
+			Str("path", cd.persistence.config.Path).
+			Msg("loaded persisted dispatch cache")
+	}
+
+	if cd.persistence.config.Interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(cd.persistence.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			if err := cd.persist(); err != nil {
+				log.Ctx(ctx).Warn().Err(err).Msg("failed to persist dispatch cache")
+			}
+		}
+	}
+}
+
+// closePersistence stops persisting in the background and persists the cache a final time.
+func (cd *Dispatcher) closePersistence() error {
+	cd.persistence.cancel()
+	<-cd.persistence.done
+	return cd.persist()
+}
+
+// getCachedBytes returns the cached result for the key, falling back to the results loaded from
+// disk, if any.
+func (cd *Dispatcher) getCachedBytes(key keys.DispatchCacheKey, revision string) ([]byte, bool) {
+	if cachedResultRaw, found := cd.c.Get(key); found {
+		return cachedResultRaw.([]byte), true
+	}
+
+	if cd.persistence == nil {
+		return nil, false
+	}
+
+	value, found := cd.persistence.take(key)
+	if !found {
+		return nil, false
+	}
+
+	cd.setCachedBytes(key, revision, value)
+	return value, true
+}
+
+// setCachedBytes adds the result to the cache, tracking it for persistence if enabled.
+func (cd *Dispatcher) setCachedBytes(key keys.DispatchCacheKey, revision string, value []byte) {
+	cd.c.Set(key, value, sliceSize(value))
+	if cd.persistence != nil {
+		cd.persistence.track(key, revision)
+	}
+}
+
+func (cp *cachePersistence) take(key keys.DispatchCacheKey) ([]byte, bool) {
+	pk := persistentKeyFor(key)
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	entry, ok := cp.loaded[pk]
+	if !ok {
+		return nil, false
+	}
+	delete(cp.loaded, pk)
+	return entry.value, true
+}
+
+func (cp *cachePersistence) track(key keys.DispatchCacheKey, revision string) {
+	pk := persistentKeyFor(key)
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	if _, ok := cp.tracked[pk]; !ok && len(cp.tracked) >= cp.config.MaxEntries {
+		// Evict an arbitrary entry, relying on the random iteration order of maps.
+		for existing := range cp.tracked {
+			delete(cp.tracked, existing)
+			break
+		}
+	}
+
+	cp.tracked[pk] = trackedEntry{key: key, revision: revision}
+}
+
+// persist writes the results found in the cache for the tracked keys, as well as the loaded
+// results not yet used, to disk. The file is replaced atomically.
+func (cd *Dispatcher) persist() error {
+	cp := cd.persistence
+
+	cp.mu.Lock()
+	tracked := make(map[persistentKey]trackedEntry, len(cp.tracked))
+	for pk, entry := range cp.tracked {
+		tracked[pk] = entry
+	}
+	loaded := make(map[persistentKey]persistedEntry, len(cp.loaded))
+	for pk, entry := range cp.loaded {
+		loaded[pk] = entry
+	}
+	cp.mu.Unlock()
+
+	// Ensure that all pending sets have been applied to the cache.
+	cd.c.Wait()
+
+	tmpPath := cp.config.Path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	if err := writePersistenceHeader(writer, cp.config.Version); err != nil {
+		return err
+	}
+
+	written := 0
+	var evicted []persistentKey
+	for pk, entry := range tracked {
+		if written >= cp.config.MaxEntries {
+			break
+		}
+
+		// Entries no longer in the cache have been evicted or have expired.
+		cachedResultRaw, found := cd.c.Get(entry.key)
+		if !found {
+			evicted = append(evicted, pk)
+			continue
+		}
+
+		if err := writePersistedEntry(writer, pk, persistedEntry{entry.revision, cachedResultRaw.([]byte)}); err != nil {
+			return err
+		}
+		written++
+	}
+
+	for pk, entry := range loaded {
+		if written >= cp.config.MaxEntries {
+			break
+		}
+
+		if _, ok := tracked[pk]; ok {
+			continue
+		}
+
+		if err := writePersistedEntry(writer, pk, entry); err != nil {
+			return err
+		}
+		written++
+	}
+
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, cp.config.Path); err != nil {
+		return err
+	}
+
+	cp.mu.Lock()
+	for _, pk := range evicted {
+		if current, ok := cp.tracked[pk]; ok && current.key == tracked[pk].key {
+			delete(cp.tracked, pk)
+		}
+	}
+	cp.mu.Unlock()
+
+	log.Debug().Int("entries", written).Str("path", cp.config.Path).Msg("persisted dispatch cache")
+	return nil
+}
+
+// load reads the results persisted by a previous process, discarding those whose revision
+// fails validation, or all of them if they were persisted by another version. Returns the
+// number of results loaded.
+func (cp *cachePersistence) load(ctx context.Context) (int, error) {
+	file, err := os.Open(cp.config.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	version, err := readPersistenceHeader(reader)
+	if err != nil {
+		return 0, err
+	}
+
+	if version != cp.config.Version {
+		log.Ctx(ctx).Info().
+			Str("persisted-version", version).
+			Str("current-version", cp.config.Version).
+			Msg("discarding dispatch cache persisted by another version")
+		return 0, nil
+	}
+
+	validRevisions := make(map[string]bool)
+	loaded := make(map[persistentKey]persistedEntry)
+	for len(loaded) < cp.config.MaxEntries {
+		pk, entry, err := readPersistedEntry(reader)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		valid, ok := validRevisions[entry.revision]
+		if !ok {
+			valid = true
+			if cp.config.ValidateRevision != nil {
+				if err := cp.config.ValidateRevision(ctx, entry.revision); err != nil {
+					if ctx.Err() != nil {
+						return 0, ctx.Err()
+					}
+
+					log.Ctx(ctx).Debug().Err(err).Str("revision", entry.revision).Msg("discarding persisted dispatch cache entries")
+					valid = false
+				}
+			}
+			validRevisions[entry.revision] = valid
+		}
+
+		if valid {
+			loaded[pk] = entry
+		}
+	}
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	for pk, entry := range loaded {
+		// Results computed since startup are already in the cache.
+		if _, ok := cp.tracked[pk]; !ok {
+			cp.loaded[pk] = entry
+		}
+	}
+	return len(cp.loaded), nil
+}
+
+func writePersistenceHeader(writer *bufio.Writer, version string) error {
+	if _, err := writer.WriteString(persistenceFileMagic); err != nil {
+		return err
+	}
+	if err := writer.WriteByte(persistenceFileVersion); err != nil {
+		return err
+	}
+
+	buf := binary.AppendUvarint(make([]byte, 0, binary.MaxVarintLen64+len(version)), uint64(len(version)))
+	_, err := writer.Write(append(buf, version...))
+	return err
+}
+
+// readPersistenceHeader reads the header of the file, returning the version of SpiceDB which
+// persisted it.
+func readPersistenceHeader(reader *bufio.Reader) (string, error) {
+	magic := make([]byte, len(persistenceFileMagic))
+	if _, err := io.ReadFull(reader, magic); err != nil {
+		return "", fmt.Errorf("invalid persisted dispatch cache: %w", err)
+	}
+
+	if string(magic) != persistenceFileMagic {
+		return "", errors.New("invalid persisted dispatch cache: not a dispatch cache file")
+	}
+
+	fileVersion, err := reader.ReadByte()
+	if err != nil {
+		return "", fmt.Errorf("invalid persisted dispatch cache: %w", err)
+	}
+
+	if fileVersion != persistenceFileVersion {
+		return "", fmt.Errorf("unsupported persisted dispatch cache version %d", fileVersion)
+	}
+
+	version, err := readLengthPrefixed(reader)
+	if err != nil {
+		return "", truncatedErr(err)
+	}
+	return string(version), nil
+}
+
+func writePersistedEntry(writer *bufio.Writer, pk persistentKey, entry persistedEntry) error {
+	buf := make([]byte, 0, 2*binary.MaxVarintLen64+len(entry.revision)+len(entry.value)+2*binary.MaxVarintLen64)
+	buf = binary.AppendUvarint(buf, pk[0])
+	buf = binary.AppendUvarint(buf, pk[1])
+	buf = binary.AppendUvarint(buf, uint64(len(entry.revision)))
+	buf = append(buf, entry.revision...)
+	buf = binary.AppendUvarint(buf, uint64(len(entry.value)))
+	buf = append(buf, entry.value...)
+
+	_, err := writer.Write(buf)
+	return err
+}
+
+func readPersistedEntry(reader *bufio.Reader) (persistentKey, persistedEntry, error) {
+	first, err := binary.ReadUvarint(reader)
+	if err != nil {
+		// A clean EOF is only possible before the first field of an entry.
+		return persistentKey{}, persistedEntry{}, err
+	}
+
+	second, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return persistentKey{}, persistedEntry{}, truncatedErr(err)
+	}
+
+	revision, err := readLengthPrefixed(reader)
+	if err != nil {
+		return persistentKey{}, persistedEntry{}, truncatedErr(err)
+	}
+
+	value, err := readLengthPrefixed(reader)
+	if err != nil {
+		return persistentKey{}, persistedEntry{}, truncatedErr(err)
+	}
+
+	return persistentKey{first, second}, persistedEntry{string(revision), value}, nil
+}
+
+// maxPersistedFieldSize guards against allocating absurd amounts of memory when reading a
+// corrupted file.
+const maxPersistedFieldSize = 64 * 1024 * 1024
+
+func readLengthPrefixed(reader *bufio.Reader) ([]byte, error) {
+	length, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if length > maxPersistedFieldSize {
+		return nil, fmt.Errorf("field of %d bytes exceeds maximum size", length)
+	}
+
+	value := make([]byte, length)
+	_, err = io.ReadFull(reader, value)
+	return value, err
+}
+
+func truncatedErr(err error) error {
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	return fmt.Errorf("invalid persisted dispatch cache: %w", err)
+}
@@ -3,12 +3,17 @@
 package combined
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"os"
 	"time"
 
 	"github.com/authzed/grpcutil"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
 
 	"github.com/authzed/spicedb/internal/dispatch"
 	"github.com/authzed/spicedb/internal/dispatch/caching"
@@ -28,11 +33,19 @@ type optionState struct {
 	prometheusSubsystem   string
 	upstreamAddr          string
 	upstreamCAPath        string
+	upstreamCAPool        func() *x509.CertPool
 	grpcPresharedKey      string
 	grpcDialOpts          []grpc.DialOption
 	cache                 cache.Cache
+	cachePersistence      caching.PersistenceConfig
 	concurrencyLimits     graph.ConcurrencyLimits
 	remoteDispatchTimeout time.Duration
+	deadlineMargin        time.Duration
+	hedgingDelay          time.Duration
+	circuitBreaker        remote.CircuitBreakerConfig
+	upstreamConnections   uint16
+	upstreamKeepalive     keepalive.ClientParameters
+	maxInflightPerPeer    uint32
 }
 
 // MetricsEnabled enables issuing prometheus metrics
@@ -64,6 +77,16 @@ func UpstreamCAPath(path string) Option {
 	}
 }
 
+// UpstreamCAPool sets the optional cluster dispatching upstream certificate
+// authority to the pool returned by the function when each connection is made,
+// so that the certificate authority can be rotated. It takes precedence over
+// UpstreamCAPath.
+func UpstreamCAPool(pool func() *x509.CertPool) Option {
+	return func(state *optionState) {
+		state.upstreamCAPool = pool
+	}
+}
+
 // GrpcPresharedKey sets the preshared key used to authenticate for optional
 // cluster dispatching.
 func GrpcPresharedKey(key string) Option {
@@ -87,6 +110,14 @@ func Cache(c cache.Cache) Option {
 	}
 }
 
+// CachePersistence enables persisting the cached results of the dispatcher to disk, if a path
+// is configured.
+func CachePersistence(config caching.PersistenceConfig) Option {
+	return func(state *optionState) {
+		state.cachePersistence = config
+	}
+}
+
 // ConcurrencyLimits sets the max number of goroutines per operation
 func ConcurrencyLimits(limits graph.ConcurrencyLimits) Option {
 	return func(state *optionState) {
@@ -102,6 +133,56 @@ func RemoteDispatchTimeout(remoteDispatchTimeout time.Duration) Option {
 	}
 }
 
+// DeadlineMargin sets the safety margin subtracted from the time remaining to
+// each request to give the deadline budget of the request dispatched to the
+// upstream, so that the upstream abandons the request before its caller does.
+func DeadlineMargin(margin time.Duration) Option {
+	return func(state *optionState) {
+		state.deadlineMargin = margin
+	}
+}
+
+// HedgingDelay sets the duration after which a check, expand or lookup
+// dispatched to the upstream which has not been answered is also computed
+// locally, with the first result returned. Zero disables hedging.
+func HedgingDelay(delay time.Duration) Option {
+	return func(state *optionState) {
+		state.hedgingDelay = delay
+	}
+}
+
+// CircuitBreaker configures the circuit breakers of each upstream peer, which
+// route requests to local computation whilst the peer is failing.
+func CircuitBreaker(config remote.CircuitBreakerConfig) Option {
+	return func(state *optionState) {
+		state.circuitBreaker = config
+	}
+}
+
+// UpstreamConnections sets the number of connections made to the upstream, and
+// thereby to each of its peers, across which requests are spread. Defaults to 1.
+func UpstreamConnections(count uint16) Option {
+	return func(state *optionState) {
+		state.upstreamConnections = count
+	}
+}
+
+// UpstreamKeepalive sets the keepalive parameters of the connections to the
+// upstream. Keepalive pings are disabled if the time is zero.
+func UpstreamKeepalive(params keepalive.ClientParameters) Option {
+	return func(state *optionState) {
+		state.upstreamKeepalive = params
+	}
+}
+
+// MaxInflightPerPeer sets the maximum number of requests in flight to each
+// upstream peer, beyond which requests are computed locally. Zero is unlimited.
+func MaxInflightPerPeer(limit uint32) Option {
+	return func(state *optionState) {
+		state.maxInflightPerPeer = limit
+	}
+}
+
 // NewDispatcher initializes a Dispatcher that caches and redispatches
 // optionally to the provided upstream.
 func NewDispatcher(options ...Option) (dispatch.Dispatcher, error) {
@@ -120,11 +201,25 @@ func NewDispatcher(options ...Option) (dispatch.Dispatcher, error) {
 		return nil, err
 	}
 
+	if opts.cachePersistence.Path != "" {
+		if err := cachingRedispatch.EnablePersistence(opts.cachePersistence); err != nil {
+			return nil, err
+		}
+	}
+
 	redispatch := graph.NewDispatcher(cachingRedispatch, opts.concurrencyLimits)
 
 	// If an upstream is specified, create a cluster dispatcher.
 	if opts.upstreamAddr != "" {
-		if opts.upstreamCAPath != "" {
+		if opts.upstreamCAPool != nil {
+			opts.grpcDialOpts = append(opts.grpcDialOpts, grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+				// The certificate chain is verified against the current pool by VerifyConnection
+				// instead.
+				InsecureSkipVerify: true, //nolint:gosec
+				VerifyConnection:   verifyWithPool(opts.upstreamCAPool),
+			})))
+			opts.grpcDialOpts = append(opts.grpcDialOpts, grpcutil.WithBearerToken(opts.grpcPresharedKey))
+		} else if opts.upstreamCAPath != "" {
 			// Ensure that the CA path exists.
 			if _, err := os.Stat(opts.upstreamCAPath); err != nil {
 				return nil, err
@@ -138,13 +233,32 @@ func NewDispatcher(options ...Option) (dispatch.Dispatcher, error) {
 
 		opts.grpcDialOpts = append(opts.grpcDialOpts, grpc.WithDefaultCallOptions(grpc.UseCompressor("s2")))
 
-		conn, err := grpc.Dial(opts.upstreamAddr, opts.grpcDialOpts...)
-		if err != nil {
-			return nil, err
+		if opts.upstreamKeepalive.Time > 0 {
+			opts.grpcDialOpts = append(opts.grpcDialOpts, grpc.WithKeepaliveParams(opts.upstreamKeepalive))
+		}
+
+		connections := int(opts.upstreamConnections)
+		if connections == 0 {
+			connections = 1
 		}
-		redispatch = remote.NewClusterDispatcher(v1.NewDispatchServiceClient(conn), conn, remote.ClusterDispatcherConfig{
+
+		pool := &connPool{conns: make([]*grpc.ClientConn, 0, connections)}
+		for i := 0; i < connections; i++ {
+			conn, err := grpc.Dial(opts.upstreamAddr, opts.grpcDialOpts...)
+			if err != nil {
+				return nil, err
+			}
+			pool.conns = append(pool.conns, conn)
+		}
+
+		redispatch = remote.NewClusterDispatcher(v1.NewDispatchServiceClient(pool), pool.conns[0], remote.ClusterDispatcherConfig{
 			KeyHandler:             &keys.CanonicalKeyHandler{},
 			DispatchOverallTimeout: opts.remoteDispatchTimeout,
+			DeadlineMargin:         opts.deadlineMargin,
+			HedgingDelay:           opts.hedgingDelay,
+			CircuitBreaker:         opts.circuitBreaker,
+			MaxInflightPerPeer:     opts.maxInflightPerPeer,
+			LocalDispatcher:        redispatch,
 		})
 	}
 
@@ -152,3 +266,25 @@ func NewDispatcher(options ...Option) (dispatch.Dispatcher, error) {
 
 	return cachingRedispatch, nil
 }
+
+// verifyWithPool returns a function which verifies the certificate chain and
+// host name of connections against the pool returned by the given function.
+func verifyWithPool(pool func() *x509.CertPool) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		if len(cs.PeerCertificates) == 0 {
+			return errors.New("upstream presented no certificates")
+		}
+
+		intermediates := x509.NewCertPool()
+		for _, cert := range cs.PeerCertificates[1:] {
+			intermediates.AddCert(cert)
+		}
+
+		_, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+			DNSName:       cs.ServerName,
+			Roots:         pool(),
+			Intermediates: intermediates,
+		})
+		return err
+	}
+}
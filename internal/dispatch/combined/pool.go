@@ -0,0 +1,30 @@
+package combined
+
+import (
+	"context"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+)
+
+// connPool spreads requests round-robin across several connections to the same upstream. Each
+// connection balances across all of the peers, so requests to each peer are spread across as
+// many connections to it, rather than all being multiplexed over one.
+type connPool struct {
+	conns []*grpc.ClientConn
+	next  atomic.Uint64
+}
+
+func (cp *connPool) conn() *grpc.ClientConn {
+	return cp.conns[cp.next.Add(1)%uint64(len(cp.conns))]
+}
+
+func (cp *connPool) Invoke(ctx context.Context, method string, args any, reply any, opts ...grpc.CallOption) error {
+	return cp.conn().Invoke(ctx, method, args, reply, opts...)
+}
+
+func (cp *connPool) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return cp.conn().NewStream(ctx, desc, method, opts...)
+}
+
+var _ grpc.ClientConnInterface = &connPool{}
@@ -0,0 +1,31 @@
+package dispatch
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ErrDeadlineBudgetExhausted is returned when a request is not dispatched because the time
+// remaining to its caller, less the safety margin, is already exhausted.
+var ErrDeadlineBudgetExhausted = fmt.Errorf("dispatch deadline budget exhausted: %w", context.DeadlineExceeded)
+
+// DeadlineBudget returns the time remaining before the deadline of the context less the safety
+// margin, and whether the context has a deadline. The budget is zero or negative if exhausted.
+func DeadlineBudget(ctx context.Context, margin time.Duration) (time.Duration, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+	return time.Until(deadline) - margin, true
+}
+
+// ContextWithDeadlineBudget returns a context canceled once the deadline budget found in the
+// metadata of the request is exhausted, if it has one.
+func ContextWithDeadlineBudget(ctx context.Context, req HasMetadata) (context.Context, context.CancelFunc) {
+	budget := req.GetMetadata().GetDeadlineBudget()
+	if budget == nil {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, budget.AsDuration())
+}
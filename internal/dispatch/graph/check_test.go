@@ -442,3 +442,51 @@ func newLocalDispatcherWithSchemaAndRels(t testing.TB, schema string, rels []*co
 
 	return ctx, cachingDispatcher, revision
 }
+
+func BenchmarkCheckGeneratedDataset(b *testing.B) {
+	for _, config := range []testfixtures.GeneratorConfig{
+		{Seed: 1, Depth: 3, FanOut: 3, Users: 100, CaveatRatio: 0},
+		{Seed: 1, Depth: 4, FanOut: 5, Users: 1000, CaveatRatio: 0},
+		{Seed: 1, Depth: 4, FanOut: 5, Users: 1000, CaveatRatio: 0.25},
+	} {
+		config := config
+		name := fmt.Sprintf("depth=%d,fanout=%d,caveats=%.2f", config.Depth, config.FanOut, config.CaveatRatio)
+
+		b.Run(name, func(b *testing.B) {
+			require := require.New(b)
+			rawDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+			require.NoError(err)
+
+			ds, revision := testfixtures.GeneratedDatastore(config)(rawDS, require)
+
+			ctx := log.Logger.WithContext(datastoremw.ContextWithHandle(context.Background()))
+			require.NoError(datastoremw.SetInContext(ctx, ds))
+
+			dispatcher := NewLocalOnlyDispatcher(10)
+
+			// The deepest resource is checked, so that every level of the dataset is walked.
+			generated := testfixtures.Generate(config)
+			var deepest string
+			for _, rel := range generated.Relationships {
+				if rel.ResourceAndRelation.Relation == "parent" {
+					deepest = rel.ResourceAndRelation.ObjectId
+				}
+			}
+
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				_, err := dispatcher.DispatchCheck(ctx, &v1.DispatchCheckRequest{
+					ResourceRelation: RR("resource", "view"),
+					ResourceIds:      []string{deepest},
+					ResultsSetting:   v1.DispatchCheckRequest_REQUIRE_ALL_RESULTS,
+					Subject:          ONR("user", fmt.Sprintf("user%d", n%config.Users), graph.Ellipsis),
+					Metadata: &v1.ResolverMeta{
+						AtRevision:     revision.String(),
+						DepthRemaining: 50,
+					},
+				})
+				require.NoError(err)
+			}
+		})
+	}
+}
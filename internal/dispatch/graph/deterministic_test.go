@@ -0,0 +1,131 @@
+package graph
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/internal/dispatch"
+	"github.com/authzed/spicedb/internal/graph"
+	log "github.com/authzed/spicedb/internal/logging"
+	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
+	"github.com/authzed/spicedb/internal/testfixtures"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+const simulationSchema = `
+	definition user {}
+
+	definition group {
+		relation member: user | group#member
+		relation banned: user
+		permission active_member = member - banned
+	}
+
+	definition document {
+		relation viewer: user | group#member
+		relation editor: user | group#active_member
+		relation reviewer: user
+		permission edit = editor & reviewer
+		permission view = viewer + edit - banned->active_member
+		relation banned: group
+	}
+`
+
+var simulationRelationships = []*core.RelationTuple{
+	tuple.MustParse("group:eng#member@user:tom"),
+	tuple.MustParse("group:eng#member@user:sarah"),
+	tuple.MustParse("group:eng#member@group:contractors#member"),
+	tuple.MustParse("group:contractors#member@user:fred"),
+	tuple.MustParse("group:contractors#member@user:mallory"),
+	tuple.MustParse("group:eng#banned@user:mallory"),
+	tuple.MustParse("group:blocked#member@user:sarah"),
+	tuple.MustParse("document:plan#viewer@group:eng#member"),
+	tuple.MustParse("document:plan#editor@group:eng#active_member"),
+	tuple.MustParse("document:plan#reviewer@user:tom"),
+	tuple.MustParse("document:plan#reviewer@user:mallory"),
+	tuple.MustParse("document:plan#banned@group:blocked"),
+}
+
+// TestDeterministicSimulation explores many interleavings of the children of the reducers used
+// by check and lookup subjects, requiring that each returns the same results.
+func TestDeterministicSimulation(t *testing.T) {
+	rawDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	require.NoError(t, err)
+
+	ds, revision := testfixtures.DatastoreFromSchemaAndTestRelationships(rawDS, simulationSchema, simulationRelationships, require.New(t))
+
+	baseCtx := log.Logger.WithContext(datastoremw.ContextWithHandle(context.Background()))
+	require.NoError(t, datastoremw.SetInContext(baseCtx, ds))
+
+	expectedViewers := []string{"fred", "mallory", "tom"}
+	expectedEditors := []string{"tom"}
+
+	for seed := int64(0); seed < 50; seed++ {
+		// A dispatcher without caching is used, so every seed evaluates the full graph.
+		dis := NewLocalOnlyDispatcher(10)
+		ctx := graph.ContextWithDeterministicScheduling(baseCtx, seed)
+
+		for _, tc := range []struct {
+			permission string
+			expected   []string
+		}{
+			{"view", expectedViewers},
+			{"edit", expectedEditors},
+		} {
+			stream := dispatch.NewCollectingDispatchStream[*v1.DispatchLookupSubjectsResponse](ctx)
+			err := dis.DispatchLookupSubjects(&v1.DispatchLookupSubjectsRequest{
+				ResourceRelation: RR("document", tc.permission),
+				ResourceIds:      []string{"plan"},
+				SubjectRelation:  RR("user", "..."),
+				Metadata: &v1.ResolverMeta{
+					AtRevision:     revision.String(),
+					DepthRemaining: 50,
+				},
+			}, stream)
+			require.NoError(t, err)
+
+			var found []string
+			for _, result := range stream.Results() {
+				for _, subject := range result.FoundSubjectsByResourceId["plan"].GetFoundSubjects() {
+					found = append(found, subject.SubjectId)
+				}
+			}
+			sort.Strings(found)
+			require.Equal(t, tc.expected, found, "lookup subjects of %s with seed %d", tc.permission, seed)
+
+			for _, userID := range []string{"tom", "sarah", "fred", "mallory", "unknown"} {
+				checkResult, err := dis.DispatchCheck(ctx, &v1.DispatchCheckRequest{
+					ResourceRelation: RR("document", tc.permission),
+					ResourceIds:      []string{"plan"},
+					ResultsSetting:   v1.DispatchCheckRequest_ALLOW_SINGLE_RESULT,
+					Subject:          ONR("user", userID, graph.Ellipsis),
+					Metadata: &v1.ResolverMeta{
+						AtRevision:     revision.String(),
+						DepthRemaining: 50,
+					},
+				})
+				require.NoError(t, err)
+
+				isMember := checkResult.ResultsByResourceId["plan"].GetMembership() == v1.ResourceCheckResult_MEMBER
+				require.Equal(t, contains(tc.expected, userID), isMember, "check of %s for %s with seed %d", tc.permission, userID, seed)
+			}
+		}
+
+		require.NoError(t, dis.Close())
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
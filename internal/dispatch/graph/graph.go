@@ -12,10 +12,12 @@ import (
 
 	"github.com/authzed/spicedb/internal/dispatch"
 	"github.com/authzed/spicedb/internal/graph"
+	"github.com/authzed/spicedb/internal/middleware/concurrencylimit"
 	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
 	"github.com/authzed/spicedb/pkg/datastore"
 	core "github.com/authzed/spicedb/pkg/proto/core/v1"
 	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+	"github.com/authzed/spicedb/pkg/redaction"
 	"github.com/authzed/spicedb/pkg/tuple"
 )
 
@@ -89,6 +91,8 @@ func NewLocalOnlyDispatcherWithLimits(concurrencyLimits ConcurrencyLimits) dispa
 	d.lookupHandler = graph.NewConcurrentLookup(d, d, concurrencyLimits.LookupResources)
 	d.reachableResourcesHandler = graph.NewConcurrentReachableResources(d, concurrencyLimits.ReachableResources)
 	d.lookupSubjectsHandler = graph.NewConcurrentLookupSubjects(d, concurrencyLimits.LookupSubjects)
+	d.redispatcher = d
+	d.concurrencyLimits = concurrencyLimits
 
 	return d
 }
@@ -110,6 +114,8 @@ func NewDispatcher(redispatcher dispatch.Dispatcher, concurrencyLimits Concurren
 		lookupHandler:             lookupHandler,
 		reachableResourcesHandler: reachableResourcesHandler,
 		lookupSubjectsHandler:     lookupSubjectsHandler,
+		redispatcher:              redispatcher,
+		concurrencyLimits:         concurrencyLimits,
 	}
 }
 
@@ -119,6 +125,9 @@ type localDispatcher struct {
 	lookupHandler             *graph.ConcurrentLookup
 	reachableResourcesHandler *graph.ConcurrentReachableResources
 	lookupSubjectsHandler     *graph.ConcurrentLookupSubjects
+
+	redispatcher      dispatch.Dispatcher
+	concurrencyLimits ConcurrencyLimits
 }
 
 func (ld *localDispatcher) loadNamespace(ctx context.Context, nsName string, revision datastore.Revision) (*core.NamespaceDefinition, error) {
@@ -158,8 +167,8 @@ func (ld *localDispatcher) lookupRelation(ctx context.Context, ns *core.Namespac
 func (ld *localDispatcher) DispatchCheck(ctx context.Context, req *v1.DispatchCheckRequest) (*v1.DispatchCheckResponse, error) {
 	ctx, span := tracer.Start(ctx, "DispatchCheck", trace.WithAttributes(
 		attribute.String("resource-type", tuple.StringRR(req.ResourceRelation)),
-		attribute.StringSlice("resource-ids", req.ResourceIds),
-		attribute.String("subject", tuple.StringONR(req.Subject)),
+		attribute.StringSlice("resource-ids", redaction.ObjectIDs(req.ResourceIds)),
+		attribute.String("subject", redaction.ONR(req.Subject)),
 	))
 	defer span.End()
 
@@ -185,6 +194,11 @@ func (ld *localDispatcher) DispatchCheck(ctx context.Context, req *v1.DispatchCh
 		}, err
 	}
 
+	if limit := concurrencylimit.ForRequest(ctx, req.Metadata); limit != req.Metadata.ConcurrencyLimit {
+		req = req.CloneVT()
+		req.Metadata.ConcurrencyLimit = limit
+	}
+
 	revision, err := ld.parseRevision(ctx, req.Metadata.AtRevision)
 	if err != nil {
 		return &v1.DispatchCheckResponse{Metadata: emptyMetadata}, err
@@ -225,10 +239,10 @@ func (ld *localDispatcher) DispatchCheck(ctx context.Context, req *v1.DispatchCh
 			Revision: revision,
 		}
 
-		return ld.checker.Check(ctx, validatedReq, relation)
+		return ld.checkerFor(req.Metadata).Check(ctx, validatedReq, relation)
 	}
 
-	return ld.checker.Check(ctx, graph.ValidatedCheckRequest{
+	return ld.checkerFor(req.Metadata).Check(ctx, graph.ValidatedCheckRequest{
 		DispatchCheckRequest: req,
 		Revision:             revision,
 	}, relation)
@@ -237,7 +251,7 @@ func (ld *localDispatcher) DispatchCheck(ctx context.Context, req *v1.DispatchCh
 // DispatchExpand implements dispatch.Expand interface
 func (ld *localDispatcher) DispatchExpand(ctx context.Context, req *v1.DispatchExpandRequest) (*v1.DispatchExpandResponse, error) {
 	ctx, span := tracer.Start(ctx, "DispatchExpand", trace.WithAttributes(
-		attribute.String("start", tuple.StringONR(req.ResourceAndRelation)),
+		attribute.String("start", redaction.ONR(req.ResourceAndRelation)),
 	))
 	defer span.End()
 
@@ -272,7 +286,7 @@ func (ld *localDispatcher) DispatchLookup(ctx context.Context, req *v1.DispatchL
 	// probably move it out of the dispatcher and into computed
 	ctx, span := tracer.Start(ctx, "DispatchLookup", trace.WithAttributes(
 		attribute.String("start", tuple.StringRR(req.ObjectRelation)),
-		attribute.String("subject", tuple.StringONR(req.Subject)),
+		attribute.String("subject", redaction.ONR(req.Subject)),
 		attribute.Int64("limit", int64(req.Limit)),
 	))
 	defer span.End()
@@ -281,6 +295,11 @@ func (ld *localDispatcher) DispatchLookup(ctx context.Context, req *v1.DispatchL
 		return &v1.DispatchLookupResponse{Metadata: emptyMetadata}, err
 	}
 
+	if limit := concurrencylimit.ForRequest(ctx, req.Metadata); limit != req.Metadata.ConcurrencyLimit {
+		req = req.CloneVT()
+		req.Metadata.ConcurrencyLimit = limit
+	}
+
 	revision, err := ld.parseRevision(ctx, req.Metadata.AtRevision)
 	if err != nil {
 		return &v1.DispatchLookupResponse{Metadata: emptyMetadata}, err
@@ -290,7 +309,7 @@ func (ld *localDispatcher) DispatchLookup(ctx context.Context, req *v1.DispatchL
 		return &v1.DispatchLookupResponse{Metadata: emptyMetadata, ResolvedResources: []*v1.ResolvedResource{}}, nil
 	}
 
-	return ld.lookupHandler.LookupViaReachability(ctx, graph.ValidatedLookupRequest{
+	return ld.lookupHandlerFor(req.Metadata).LookupViaReachability(ctx, graph.ValidatedLookupRequest{
 		DispatchLookupRequest: req,
 		Revision:              revision,
 	})
@@ -304,7 +323,7 @@ func (ld *localDispatcher) DispatchReachableResources(
 	ctx, span := tracer.Start(stream.Context(), "DispatchReachableResources", trace.WithAttributes(
 		attribute.String("resource-type", tuple.StringRR(req.ResourceRelation)),
 		attribute.String("subject-type", tuple.StringRR(req.SubjectRelation)),
-		attribute.StringSlice("subject-ids", req.SubjectIds),
+		attribute.StringSlice("subject-ids", redaction.ObjectIDs(req.SubjectIds)),
 	))
 	defer span.End()
 
@@ -312,12 +331,17 @@ func (ld *localDispatcher) DispatchReachableResources(
 		return err
 	}
 
+	if limit := concurrencylimit.ForRequest(ctx, req.Metadata); limit != req.Metadata.ConcurrencyLimit {
+		req = req.CloneVT()
+		req.Metadata.ConcurrencyLimit = limit
+	}
+
 	revision, err := ld.parseRevision(ctx, req.Metadata.AtRevision)
 	if err != nil {
 		return err
 	}
 
-	return ld.reachableResourcesHandler.ReachableResources(
+	return ld.reachableResourcesHandlerFor(req.Metadata).ReachableResources(
 		graph.ValidatedReachableResourcesRequest{
 			DispatchReachableResourcesRequest: req,
 			Revision:                          revision,
@@ -334,7 +358,7 @@ func (ld *localDispatcher) DispatchLookupSubjects(
 	ctx, span := tracer.Start(stream.Context(), "DispatchLookupSubjects", trace.WithAttributes(
 		attribute.String("resource-type", tuple.StringRR(req.ResourceRelation)),
 		attribute.String("subject-type", tuple.StringRR(req.SubjectRelation)),
-		attribute.StringSlice("resource-ids", req.ResourceIds),
+		attribute.StringSlice("resource-ids", redaction.ObjectIDs(req.ResourceIds)),
 	))
 	defer span.End()
 
@@ -342,12 +366,17 @@ func (ld *localDispatcher) DispatchLookupSubjects(
 		return err
 	}
 
+	if limit := concurrencylimit.ForRequest(ctx, req.Metadata); limit != req.Metadata.ConcurrencyLimit {
+		req = req.CloneVT()
+		req.Metadata.ConcurrencyLimit = limit
+	}
+
 	revision, err := ld.parseRevision(ctx, req.Metadata.AtRevision)
 	if err != nil {
 		return err
 	}
 
-	return ld.lookupSubjectsHandler.LookupSubjects(
+	return ld.lookupSubjectsHandlerFor(req.Metadata).LookupSubjects(
 		graph.ValidatedLookupSubjectsRequest{
 			DispatchLookupSubjectsRequest: req,
 			Revision:                      revision,
@@ -356,6 +385,43 @@ func (ld *localDispatcher) DispatchLookupSubjects(
 	)
 }
 
+// requestLimit returns the concurrency limit requested in the metadata, if it is lower
+// than the configured limit.
+func requestLimit(md *v1.ResolverMeta, configured uint16) (uint16, bool) {
+	if md.ConcurrencyLimit > 0 && md.ConcurrencyLimit < uint32(configured) {
+		return uint16(md.ConcurrencyLimit), true
+	}
+	return 0, false
+}
+
+func (ld *localDispatcher) checkerFor(md *v1.ResolverMeta) *graph.ConcurrentChecker {
+	if limit, ok := requestLimit(md, ld.concurrencyLimits.Check); ok {
+		return graph.NewConcurrentChecker(ld.redispatcher, limit)
+	}
+	return ld.checker
+}
+
+func (ld *localDispatcher) lookupHandlerFor(md *v1.ResolverMeta) *graph.ConcurrentLookup {
+	if limit, ok := requestLimit(md, ld.concurrencyLimits.LookupResources); ok {
+		return graph.NewConcurrentLookup(ld.redispatcher, ld.redispatcher, limit)
+	}
+	return ld.lookupHandler
+}
+
+func (ld *localDispatcher) reachableResourcesHandlerFor(md *v1.ResolverMeta) *graph.ConcurrentReachableResources {
+	if limit, ok := requestLimit(md, ld.concurrencyLimits.ReachableResources); ok {
+		return graph.NewConcurrentReachableResources(ld.redispatcher, limit)
+	}
+	return ld.reachableResourcesHandler
+}
+
+func (ld *localDispatcher) lookupSubjectsHandlerFor(md *v1.ResolverMeta) *graph.ConcurrentLookupSubjects {
+	if limit, ok := requestLimit(md, ld.concurrencyLimits.LookupSubjects); ok {
+		return graph.NewConcurrentLookupSubjects(ld.redispatcher, limit)
+	}
+	return ld.lookupSubjectsHandler
+}
+
 func (ld *localDispatcher) Close() error {
 	return nil
 }
@@ -4,6 +4,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
 )
 
 func TestConcurrencyLimitsWithOverallDefaultLimit(t *testing.T) {
@@ -45,3 +47,21 @@ func TestSharedConcurrencyLimits(t *testing.T) {
 	require.Equal(t, uint16(42), withDefaults.LookupSubjects)
 	require.Equal(t, uint16(42), withDefaults.ReachableResources)
 }
+
+func TestRequestedConcurrencyLimits(t *testing.T) {
+	d := NewLocalOnlyDispatcherWithLimits(SharedConcurrencyLimits(10)).(*localDispatcher)
+
+	require.Same(t, d.checker, d.checkerFor(&v1.ResolverMeta{}))
+	require.Same(t, d.checker, d.checkerFor(&v1.ResolverMeta{ConcurrencyLimit: 10}))
+	require.Same(t, d.checker, d.checkerFor(&v1.ResolverMeta{ConcurrencyLimit: 50}))
+	require.NotSame(t, d.checker, d.checkerFor(&v1.ResolverMeta{ConcurrencyLimit: 2}))
+
+	require.Same(t, d.lookupHandler, d.lookupHandlerFor(&v1.ResolverMeta{}))
+	require.NotSame(t, d.lookupHandler, d.lookupHandlerFor(&v1.ResolverMeta{ConcurrencyLimit: 2}))
+
+	require.Same(t, d.reachableResourcesHandler, d.reachableResourcesHandlerFor(&v1.ResolverMeta{}))
+	require.NotSame(t, d.reachableResourcesHandler, d.reachableResourcesHandlerFor(&v1.ResolverMeta{ConcurrencyLimit: 2}))
+
+	require.Same(t, d.lookupSubjectsHandler, d.lookupSubjectsHandlerFor(&v1.ResolverMeta{}))
+	require.NotSame(t, d.lookupSubjectsHandler, d.lookupSubjectsHandlerFor(&v1.ResolverMeta{ConcurrencyLimit: 2}))
+}
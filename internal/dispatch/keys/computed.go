@@ -1,6 +1,8 @@
 package keys
 
 import (
+	"github.com/authzed/spicedb/pkg/caveats"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
 	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
 	"github.com/authzed/spicedb/pkg/spiceerrors"
 	"github.com/authzed/spicedb/pkg/tuple"
@@ -18,6 +20,7 @@ const (
 	expandPrefix             cachePrefix = "e"
 	reachableResourcesPrefix cachePrefix = "rr"
 	lookupSubjectsPrefix     cachePrefix = "ls"
+	caveatResultPrefix       cachePrefix = "cv"
 )
 
 var cachePrefixes = []cachePrefix{
@@ -27,6 +30,7 @@ var cachePrefixes = []cachePrefix{
 	expandPrefix,
 	reachableResourcesPrefix,
 	lookupSubjectsPrefix,
+	caveatResultPrefix,
 }
 
 // checkRequestToKey converts a check request into a cache key based on the relation
@@ -61,9 +65,11 @@ func checkRequestToKeyWithCanonical(req *v1.DispatchCheckRequest, canonicalKey s
 // lookupRequestToKey converts a lookup request into a cache key
 func lookupRequestToKey(req *v1.DispatchLookupRequest, option dispatchCacheKeyHashComputeOption) DispatchCacheKey {
 	return dispatchCacheKeyHash(lookupPrefix, req.Metadata.AtRevision, option,
-		hashableRelationReference{req.ObjectRelation},
-		hashableOnr{req.Subject},
-		hashableContext{req.Context}, // NOTE: context is included here because lookup does a single dispatch
+		withResourceIDPrefix(req.OptionalResourceIdPrefix,
+			hashableRelationReference{req.ObjectRelation},
+			hashableOnr{req.Subject},
+			hashableContext{req.Context}, // NOTE: context is included here because lookup does a single dispatch
+		)...,
 	)
 }
 
@@ -77,12 +83,23 @@ func expandRequestToKey(req *v1.DispatchExpandRequest, option dispatchCacheKeyHa
 // reachableResourcesRequestToKey converts a reachable resources request into a cache key
 func reachableResourcesRequestToKey(req *v1.DispatchReachableResourcesRequest, option dispatchCacheKeyHashComputeOption) DispatchCacheKey {
 	return dispatchCacheKeyHash(reachableResourcesPrefix, req.Metadata.AtRevision, option,
-		hashableRelationReference{req.ResourceRelation},
-		hashableRelationReference{req.SubjectRelation},
-		hashableIds(req.SubjectIds),
+		withResourceIDPrefix(req.OptionalResourceIdPrefix,
+			hashableRelationReference{req.ResourceRelation},
+			hashableRelationReference{req.SubjectRelation},
+			hashableIds(req.SubjectIds),
+		)...,
 	)
 }
 
+// withResourceIDPrefix appends the optional resource ID prefix of a request to the values of its
+// key only if it is set, so that the keys of requests without one are unchanged.
+func withResourceIDPrefix(prefix string, values ...hashableValue) []hashableValue {
+	if prefix == "" {
+		return values
+	}
+	return append(values, hashableString(prefix))
+}
+
 // lookupSubjectsRequestToKey converts a lookup subjects request into a cache key
 func lookupSubjectsRequestToKey(req *v1.DispatchLookupSubjectsRequest, option dispatchCacheKeyHashComputeOption) DispatchCacheKey {
 	return dispatchCacheKeyHash(lookupSubjectsPrefix, req.Metadata.AtRevision, option,
@@ -91,3 +108,25 @@ func lookupSubjectsRequestToKey(req *v1.DispatchLookupSubjectsRequest, option di
 		hashableIds(req.ResourceIds),
 	)
 }
+
+// CaveatResultKey computes the cache key for the result of evaluating a caveat expression found
+// by a check. Only the values in the caveat context for the given parameter names, which must
+// include every parameter referenced by the expression, are included in the key.
+func CaveatResultKey(atRevision string, expr *core.CaveatExpression, caveatContext map[string]any, parameterNames []string) (DispatchCacheKey, error) {
+	relevantContext := make(map[string]any, len(parameterNames))
+	for _, name := range parameterNames {
+		if value, ok := caveatContext[name]; ok {
+			relevantContext[name] = value
+		}
+	}
+
+	contextStruct, err := caveats.ConvertContextToStruct(relevantContext)
+	if err != nil {
+		return emptyDispatchCacheKey, err
+	}
+
+	return dispatchCacheKeyHash(caveatResultPrefix, atRevision, computeBothHashes,
+		hashableCaveatExpression{expr},
+		hashableContext{contextStruct},
+	), nil
+}
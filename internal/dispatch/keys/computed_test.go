@@ -253,6 +253,21 @@ func TestStableCacheKeys(t *testing.T) {
 			},
 			"e8848b9dd68f93a6c801",
 		},
+		{
+			"reachable resources with resource ID prefix",
+			func() DispatchCacheKey {
+				return reachableResourcesRequestToKey(&v1.DispatchReachableResourcesRequest{
+					ResourceRelation:         RR("document", "view"),
+					SubjectRelation:          RR("user", "..."),
+					SubjectIds:               []string{"mariah", "tom"},
+					OptionalResourceIdPrefix: "team_a/",
+					Metadata: &v1.ResolverMeta{
+						AtRevision: "1234",
+					},
+				}, computeBothHashes)
+			},
+			"f6abf8d7c9aeedc962",
+		},
 		{
 			"lookup subjects",
 			func() DispatchCacheKey {
@@ -411,6 +426,31 @@ var generatorFuncs = map[string]generatorFunc{
 				subjectRelation.Relation,
 			}, resourceIds...)
 	},
+
+	// Caveat results.
+	string(caveatResultPrefix): func(
+		resourceIds []string,
+		subjectIds []string,
+		resourceRelation *core.RelationReference,
+		subjectRelation *core.RelationReference,
+		metadata *v1.ResolverMeta,
+	) (DispatchCacheKey, []string) {
+		key, err := CaveatResultKey(metadata.AtRevision, &core.CaveatExpression{
+			OperationOrCaveat: &core.CaveatExpression_Caveat{
+				Caveat: &core.ContextualizedCaveat{CaveatName: resourceRelation.Relation},
+			},
+		}, map[string]any{
+			"subject": subjectIds[0],
+		}, []string{"subject"})
+		if err != nil {
+			panic(err)
+		}
+
+		return key, []string{
+			resourceRelation.Relation,
+			subjectIds[0],
+		}
+	},
 }
 
 func TestCacheKeyNoOverlap(t *testing.T) {
@@ -446,6 +486,7 @@ func TestCacheKeyNoOverlap(t *testing.T) {
 	dataCombinationSeen := util.NewSet[string]()
 	stableCacheKeysSeen := util.NewSet[string]()
 	unstableCacheKeysSeen := util.NewSet[uint64]()
+	persistentCacheKeysSeen := util.NewSet[uint64]()
 
 	// Ensure all key functions are generated.
 	require.Equal(t, len(generatorFuncs), len(cachePrefixes))
@@ -471,6 +512,7 @@ func TestCacheKeyNoOverlap(t *testing.T) {
 													if dataCombinationSeen.Add(usedDataString) {
 														require.True(t, stableCacheKeysSeen.Add(hex.EncodeToString((generated.StableSumAsBytes()))))
 														require.True(t, unstableCacheKeysSeen.Add(generated.processSpecificSum))
+														require.True(t, persistentCacheKeysSeen.Add(generated.persistentSum))
 													}
 												})
 											}
@@ -497,6 +539,7 @@ func TestComputeOnlyStableHash(t *testing.T) {
 	}, computeOnlyStableHash)
 
 	require.Equal(t, uint64(0), result.processSpecificSum)
+	require.Equal(t, uint64(0), result.persistentSum)
 }
 
 func TestComputeContextHash(t *testing.T) {
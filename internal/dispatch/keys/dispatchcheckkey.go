@@ -9,6 +9,7 @@ import (
 type DispatchCacheKey struct {
 	stableSum          uint64
 	processSpecificSum uint64
+	persistentSum      uint64
 }
 
 // StableSumAsBytes returns the stable portion of the dispatch cache key as bytes. Note that since
@@ -25,4 +26,11 @@ func (dck DispatchCacheKey) AsUInt64s() (uint64, uint64) {
 	return dck.processSpecificSum, dck.stableSum
 }
 
-var emptyDispatchCacheKey = DispatchCacheKey{0, 0}
+// PersistentSums returns the cache key in the form of two uint64's which, unlike those returned
+// by AsUInt64s, are the same across processes and can therefore be used to persist cache entries
+// across restarts. Only keys computed for caching have a persistent form.
+func (dck DispatchCacheKey) PersistentSums() (uint64, uint64) {
+	return dck.stableSum, dck.persistentSum
+}
+
+var emptyDispatchCacheKey = DispatchCacheKey{0, 0, 0}
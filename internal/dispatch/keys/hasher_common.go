@@ -70,6 +70,29 @@ func (hs hashableString) AppendToHash(hasher hasherInterface) {
 	hasher.WriteString(string(hs))
 }
 
+type hashableCaveatExpression struct {
+	*core.CaveatExpression
+}
+
+func (hce hashableCaveatExpression) AppendToHash(hasher hasherInterface) {
+	if caveat := hce.GetCaveat(); caveat != nil {
+		hasher.WriteString(caveat.CaveatName)
+		hasher.WriteString("(")
+		hashableContext{caveat.Context}.AppendToHash(hasher)
+		hasher.WriteString(")")
+		return
+	}
+
+	operation := hce.GetOperation()
+	hasher.WriteString(operation.Op.String())
+	hasher.WriteString("[")
+	for _, child := range operation.Children {
+		hashableCaveatExpression{child}.AppendToHash(hasher)
+		hasher.WriteString(",")
+	}
+	hasher.WriteString("]")
+}
+
 type hashableContext struct{ *structpb.Struct }
 
 func (hc hashableContext) AppendToHash(hasher hasherInterface) {
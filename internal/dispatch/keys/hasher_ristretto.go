@@ -32,16 +32,26 @@ func dispatchCacheKeyHash(prefix cachePrefix, atRevision string, computeOption d
 
 type dispatchCacheKeyHasher struct {
 	stableHasher       *xxhash.Digest
+	persistentHasher   *xxhash.Digest
 	computeOption      dispatchCacheKeyHashComputeOption
 	processSpecificSum uint64
 }
 
+// persistentHashSalt is written first into the persistent hasher, so that its sum is distinct
+// from the stable sum of the same input.
+const persistentHashSalt = "persistent/"
+
 func newDispatchCacheKeyHasher(prefix cachePrefix, computeOption dispatchCacheKeyHashComputeOption) *dispatchCacheKeyHasher {
 	h := &dispatchCacheKeyHasher{
 		stableHasher:  xxhash.New(),
 		computeOption: computeOption,
 	}
 
+	if computeOption == computeBothHashes {
+		h.persistentHasher = xxhash.New()
+		_, _ = h.persistentHasher.WriteString(persistentHashSalt)
+	}
+
 	prefixString := string(prefix)
 	h.WriteString(prefixString)
 	h.WriteString("/")
@@ -63,6 +73,7 @@ func (h *dispatchCacheKeyHasher) mustWriteString(value string) {
 
 	if h.computeOption == computeBothHashes {
 		h.processSpecificSum = runMemHash(h.processSpecificSum, []byte(value))
+		_, _ = h.persistentHasher.WriteString(value)
 	}
 }
 
@@ -83,8 +94,12 @@ func runMemHash(seed uint64, data []byte) uint64 {
 
 // BuildKey returns the constructed DispatchCheckKey.
 func (h *dispatchCacheKeyHasher) BuildKey() DispatchCacheKey {
-	return DispatchCacheKey{
+	key := DispatchCacheKey{
 		stableSum:          h.stableHasher.Sum64(),
 		processSpecificSum: h.processSpecificSum,
 	}
+	if h.persistentHasher != nil {
+		key.persistentSum = h.persistentHasher.Sum64()
+	}
+	return key
 }
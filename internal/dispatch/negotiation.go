@@ -0,0 +1,101 @@
+package dispatch
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+)
+
+// APIVersion is the version of the dispatch API implemented by this node. It must be
+// incremented whenever the dispatch API changes in a way that older nodes cannot safely ignore.
+const APIVersion uint32 = 1
+
+const (
+	// FeatureCaveatedLookup indicates that the node honors the caveat context found on
+	// lookup requests.
+	FeatureCaveatedLookup = "caveated-lookup"
+
+	// FeatureRequiredFeatures indicates that the node enforces the required features
+	// found in the resolver metadata of a request.
+	FeatureRequiredFeatures = "required-features"
+)
+
+// SupportedFeatures are the dispatch features supported by this node.
+var SupportedFeatures = []string{
+	FeatureCaveatedLookup,
+	FeatureRequiredFeatures,
+}
+
+// NegotiateFeatures returns the subset of the given features that are also supported by
+// this node.
+func NegotiateFeatures(features []string) []string {
+	negotiated := make([]string, 0, len(features))
+	for _, feature := range features {
+		if isSupportedFeature(feature) {
+			negotiated = append(negotiated, feature)
+		}
+	}
+	return negotiated
+}
+
+// CheckRequiredFeatures returns an ErrUnsupportedFeatures if the request requires any
+// features not supported by this node.
+func CheckRequiredFeatures(req HasMetadata) error {
+	var unsupported []string
+	for _, feature := range req.GetMetadata().GetRequiredFeatures() {
+		if !isSupportedFeature(feature) {
+			unsupported = append(unsupported, feature)
+		}
+	}
+
+	if len(unsupported) > 0 {
+		return NewUnsupportedFeaturesErr(unsupported)
+	}
+	return nil
+}
+
+// WithRequiredFeatures returns a copy of the resolver metadata with the given features
+// added to its required features.
+func WithRequiredFeatures(metadata *v1.ResolverMeta, features ...string) *v1.ResolverMeta {
+	updated := metadata.CloneVT()
+	updated.RequiredFeatures = append(updated.RequiredFeatures, features...)
+	return updated
+}
+
+func isSupportedFeature(feature string) bool {
+	for _, supported := range SupportedFeatures {
+		if supported == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrUnsupportedFeatures is returned when a dispatch requires features that are not
+// supported by the node handling it, such as during a rolling upgrade.
+type ErrUnsupportedFeatures struct {
+	error
+	features []string
+}
+
+// Features returns the unsupported features.
+func (err ErrUnsupportedFeatures) Features() []string {
+	return err.features
+}
+
+// GRPCStatus implements retrieving the gRPC status for the error.
+func (err ErrUnsupportedFeatures) GRPCStatus() *status.Status {
+	return status.New(codes.FailedPrecondition, err.Error())
+}
+
+// NewUnsupportedFeaturesErr constructs a new unsupported features error.
+func NewUnsupportedFeaturesErr(features []string) error {
+	return ErrUnsupportedFeatures{
+		error:    fmt.Errorf("dispatch requires unsupported features: %s", strings.Join(features, ", ")),
+		features: features,
+	}
+}
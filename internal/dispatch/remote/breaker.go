@@ -0,0 +1,164 @@
+package remote
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	log "github.com/authzed/spicedb/internal/logging"
+)
+
+// CircuitBreakerConfig configures the circuit breakers kept for each peer, which route the
+// requests for the peer's ranges of the hashring to local computation whilst it is failing.
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive failed or timed out requests to a peer
+	// after which its circuit is opened. Zero disables circuit breaking.
+	FailureThreshold uint32
+
+	// OpenDuration is how long a peer's circuit remains open before a single request is sent to
+	// the peer to probe whether it has recovered. Defaults to 10s.
+	OpenDuration time.Duration
+}
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+var circuitBreakerStateGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "spicedb",
+	Subsystem: "dispatch",
+	Name:      "circuit_breaker_state",
+	Help:      "The state of the circuit breaker of each peer: 0 if closed, 1 if open and 2 if half-open.",
+}, []string{"peer"})
+
+func init() {
+	prometheus.MustRegister(circuitBreakerStateGauge)
+}
+
+type peerBreaker struct {
+	state    breakerState
+	failures uint32
+	openedAt time.Time
+	probing  bool
+}
+
+// circuitBreakers holds the circuit breakers of each peer, by the key of the peer's member of
+// the hashring.
+type circuitBreakers struct {
+	sync.Mutex
+	config CircuitBreakerConfig
+	peers  map[string]*peerBreaker
+	now    func() time.Time
+}
+
+func newCircuitBreakers(config CircuitBreakerConfig) *circuitBreakers {
+	if config.FailureThreshold == 0 {
+		return nil
+	}
+	if config.OpenDuration <= 0 {
+		config.OpenDuration = 10 * time.Second
+	}
+	return &circuitBreakers{config: config, peers: make(map[string]*peerBreaker), now: time.Now}
+}
+
+// allow returns whether a request may be sent to the peer. Once the circuit of a peer has been
+// open for the open duration, a single request is allowed as a probe.
+func (cb *circuitBreakers) allow(peer string) bool {
+	cb.Lock()
+	defer cb.Unlock()
+
+	breaker, ok := cb.peers[peer]
+	if !ok {
+		return true
+	}
+
+	switch breaker.state {
+	case breakerOpen:
+		if cb.now().Sub(breaker.openedAt) < cb.config.OpenDuration {
+			return false
+		}
+		cb.setState(peer, breaker, breakerHalfOpen)
+		breaker.probing = true
+		return true
+
+	case breakerHalfOpen:
+		if breaker.probing {
+			return false
+		}
+		breaker.probing = true
+		return true
+
+	default:
+		return true
+	}
+}
+
+// record records the outcome of a request sent to the peer. Only unavailability and timeouts
+// count as failures, as other errors are returned by healthy peers, and requests canceled by
+// the caller say nothing of the health of the peer.
+func (cb *circuitBreakers) record(peer string, err error) {
+	code := status.Code(err)
+	failed := code == codes.Unavailable || code == codes.DeadlineExceeded
+
+	cb.Lock()
+	defer cb.Unlock()
+
+	breaker, ok := cb.peers[peer]
+	if !ok {
+		if !failed {
+			return
+		}
+		breaker = &peerBreaker{}
+		cb.peers[peer] = breaker
+	}
+
+	if code == codes.Canceled {
+		breaker.probing = false
+		return
+	}
+
+	if breaker.state == breakerHalfOpen {
+		breaker.probing = false
+		if failed {
+			breaker.openedAt = cb.now()
+			cb.setState(peer, breaker, breakerOpen)
+		} else {
+			breaker.failures = 0
+			cb.setState(peer, breaker, breakerClosed)
+		}
+		return
+	}
+
+	if !failed {
+		breaker.failures = 0
+		return
+	}
+
+	breaker.failures++
+	if breaker.state == breakerClosed && breaker.failures >= cb.config.FailureThreshold {
+		breaker.openedAt = cb.now()
+		cb.setState(peer, breaker, breakerOpen)
+	}
+}
+
+func (cb *circuitBreakers) setState(peer string, breaker *peerBreaker, state breakerState) {
+	if breaker.state == state {
+		return
+	}
+
+	if state == breakerOpen {
+		log.Warn().Str("peer", peer).Uint32("failures", breaker.failures).Msg("opened circuit breaker for dispatch peer")
+	} else if state == breakerClosed {
+		log.Info().Str("peer", peer).Msg("closed circuit breaker for dispatch peer")
+	}
+
+	breaker.state = state
+	circuitBreakerStateGauge.WithLabelValues(peer).Set(float64(state))
+}
@@ -8,10 +8,12 @@ import (
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/connectivity"
+	"google.golang.org/protobuf/types/known/durationpb"
 
 	"github.com/authzed/spicedb/internal/dispatch"
 	"github.com/authzed/spicedb/internal/dispatch/keys"
 	log "github.com/authzed/spicedb/internal/logging"
+	"github.com/authzed/spicedb/internal/middleware/concurrencylimit"
 	"github.com/authzed/spicedb/pkg/balancer"
 	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
 )
@@ -22,6 +24,7 @@ type clusterClient interface {
 	DispatchLookup(ctx context.Context, req *v1.DispatchLookupRequest, opts ...grpc.CallOption) (*v1.DispatchLookupResponse, error)
 	DispatchReachableResources(ctx context.Context, in *v1.DispatchReachableResourcesRequest, opts ...grpc.CallOption) (v1.DispatchService_DispatchReachableResourcesClient, error)
 	DispatchLookupSubjects(ctx context.Context, in *v1.DispatchLookupSubjectsRequest, opts ...grpc.CallOption) (v1.DispatchService_DispatchLookupSubjectsClient, error)
+	DispatchNegotiate(ctx context.Context, in *v1.DispatchNegotiateRequest, opts ...grpc.CallOption) (*v1.DispatchNegotiateResponse, error)
 }
 
 type ClusterDispatcherConfig struct {
@@ -31,6 +34,30 @@ type ClusterDispatcherConfig struct {
 	// DispatchOverallTimeout is the maximum duration of a dispatched request
 	// before it should timeout.
 	DispatchOverallTimeout time.Duration
+
+	// HedgingDelay is the duration after which a check, expand or lookup which
+	// has not been answered by the peer is also computed by the LocalDispatcher,
+	// with the first result returned. Zero disables hedging.
+	HedgingDelay time.Duration
+
+	// CircuitBreaker configures the circuit breakers of each peer, which route
+	// requests to the LocalDispatcher whilst the peer is failing.
+	CircuitBreaker CircuitBreakerConfig
+
+	// MaxInflightPerPeer is the maximum number of requests in flight to each
+	// peer, beyond which requests are routed to the LocalDispatcher. Zero is
+	// unlimited.
+	MaxInflightPerPeer uint32
+
+	// DeadlineMargin is subtracted from the time remaining to each request to give the
+	// deadline budget of the dispatched request, allowing for the transit of the response,
+	// so that the peer abandons the request before its caller does.
+	DeadlineMargin time.Duration
+
+	// LocalDispatcher computes hedged requests, and those for peers which are
+	// failing or at their limit of requests in flight, on this node. Hedging,
+	// circuit breaking and in-flight limits are disabled if it is nil.
+	LocalDispatcher dispatch.Dispatcher
 }
 
 // NewClusterDispatcher creates a dispatcher implementation that uses the provided client
@@ -46,11 +73,25 @@ func NewClusterDispatcher(client clusterClient, conn *grpc.ClientConn, config Cl
 		dispatchOverallTimeout = 60 * time.Second
 	}
 
+	hedgingDelay := config.HedgingDelay
+	breakers := newCircuitBreakers(config.CircuitBreaker)
+	inflight := newInflightLimiter(config.MaxInflightPerPeer)
+	if config.LocalDispatcher == nil {
+		hedgingDelay = 0
+		breakers = nil
+		inflight = nil
+	}
+
 	return &clusterDispatcher{
 		clusterClient:          client,
 		conn:                   conn,
 		keyHandler:             keyHandler,
 		dispatchOverallTimeout: dispatchOverallTimeout,
+		deadlineMargin:         config.DeadlineMargin,
+		hedgingDelay:           hedgingDelay,
+		breakers:               breakers,
+		inflight:               inflight,
+		localDispatcher:        config.LocalDispatcher,
 	}
 }
 
@@ -59,6 +100,12 @@ type clusterDispatcher struct {
 	conn                   *grpc.ClientConn
 	keyHandler             keys.Handler
 	dispatchOverallTimeout time.Duration
+	deadlineMargin         time.Duration
+	hedgingDelay           time.Duration
+	breakers               *circuitBreakers
+	inflight               *inflightLimiter
+	localDispatcher        dispatch.Dispatcher
+	negotiations           peerNegotiations
 }
 
 func (cr *clusterDispatcher) DispatchCheck(ctx context.Context, req *v1.DispatchCheckRequest) (*v1.DispatchCheckResponse, error) {
@@ -73,10 +120,18 @@ func (cr *clusterDispatcher) DispatchCheck(ctx context.Context, req *v1.Dispatch
 
 	ctx = context.WithValue(ctx, balancer.CtxKey, requestKey)
 
-	withTimeout, cancelFn := context.WithTimeout(ctx, cr.dispatchOverallTimeout)
-	defer cancelFn()
+	req, err = withDeadlineBudget(ctx, cr, req)
+	if err != nil {
+		return &v1.DispatchCheckResponse{Metadata: emptyMetadata}, err
+	}
 
-	resp, err := cr.clusterClient.DispatchCheck(withTimeout, req)
+	resp, err := dispatchUnary(ctx, cr, "check", func(ctx context.Context) (*v1.DispatchCheckResponse, error) {
+		withTimeout, cancelFn := context.WithTimeout(ctx, cr.dispatchOverallTimeout)
+		defer cancelFn()
+		return cr.clusterClient.DispatchCheck(withTimeout, withConcurrencyLimit(ctx, req))
+	}, func(ctx context.Context) (*v1.DispatchCheckResponse, error) {
+		return cr.localDispatcher.DispatchCheck(ctx, req)
+	})
 	if err != nil {
 		return &v1.DispatchCheckResponse{Metadata: requestFailureMetadata}, err
 	}
@@ -96,10 +151,18 @@ func (cr *clusterDispatcher) DispatchExpand(ctx context.Context, req *v1.Dispatc
 
 	ctx = context.WithValue(ctx, balancer.CtxKey, requestKey)
 
-	withTimeout, cancelFn := context.WithTimeout(ctx, cr.dispatchOverallTimeout)
-	defer cancelFn()
+	req, err = withDeadlineBudget(ctx, cr, req)
+	if err != nil {
+		return &v1.DispatchExpandResponse{Metadata: emptyMetadata}, err
+	}
 
-	resp, err := cr.clusterClient.DispatchExpand(withTimeout, req)
+	resp, err := dispatchUnary(ctx, cr, "expand", func(ctx context.Context) (*v1.DispatchExpandResponse, error) {
+		withTimeout, cancelFn := context.WithTimeout(ctx, cr.dispatchOverallTimeout)
+		defer cancelFn()
+		return cr.clusterClient.DispatchExpand(withTimeout, req)
+	}, func(ctx context.Context) (*v1.DispatchExpandResponse, error) {
+		return cr.localDispatcher.DispatchExpand(ctx, req)
+	})
 	if err != nil {
 		return &v1.DispatchExpandResponse{Metadata: requestFailureMetadata}, err
 	}
@@ -119,11 +182,34 @@ func (cr *clusterDispatcher) DispatchLookup(ctx context.Context, req *v1.Dispatc
 
 	ctx = context.WithValue(ctx, balancer.CtxKey, requestKey)
 
-	withTimeout, cancelFn := context.WithTimeout(ctx, cr.dispatchOverallTimeout)
-	defer cancelFn()
+	var gate *featureGate
+	if len(req.Context.GetFields()) > 0 {
+		var metadata *v1.ResolverMeta
+		ctx, gate, metadata, err = cr.requireFeatures(ctx, req.Metadata, dispatch.FeatureCaveatedLookup)
+		if err != nil {
+			return &v1.DispatchLookupResponse{Metadata: emptyMetadata}, err
+		}
+
+		req = req.CloneVT()
+		req.Metadata = metadata
+	}
 
-	resp, err := cr.clusterClient.DispatchLookup(withTimeout, req)
+	req, err = withDeadlineBudget(ctx, cr, req)
 	if err != nil {
+		return &v1.DispatchLookupResponse{Metadata: emptyMetadata}, err
+	}
+
+	resp, err := dispatchUnary(ctx, cr, "lookup", func(ctx context.Context) (*v1.DispatchLookupResponse, error) {
+		withTimeout, cancelFn := context.WithTimeout(ctx, cr.dispatchOverallTimeout)
+		defer cancelFn()
+		return cr.clusterClient.DispatchLookup(withTimeout, withConcurrencyLimit(ctx, req))
+	}, func(ctx context.Context) (*v1.DispatchLookupResponse, error) {
+		return cr.localDispatcher.DispatchLookup(ctx, req)
+	})
+	if err != nil {
+		if gateErr := gate.err(); gateErr != nil {
+			err = gateErr
+		}
 		return &v1.DispatchLookupResponse{Metadata: requestFailureMetadata}, err
 	}
 
@@ -146,11 +232,22 @@ func (cr *clusterDispatcher) DispatchReachableResources(
 		return err
 	}
 
+	req, err = withDeadlineBudget(ctx, cr, req)
+	if err != nil {
+		return err
+	}
+
 	withTimeout, cancelFn := context.WithTimeout(ctx, cr.dispatchOverallTimeout)
 	defer cancelFn()
 
-	client, err := cr.clusterClient.DispatchReachableResources(withTimeout, req)
-	if err != nil {
+	// If the peer is refused, the request is instead computed locally, publishing its results
+	// to the stream directly.
+	client, err := withPeerFallback(withTimeout, cr, "reachableresources", func(ctx context.Context) (v1.DispatchService_DispatchReachableResourcesClient, error) {
+		return cr.clusterClient.DispatchReachableResources(ctx, withConcurrencyLimit(ctx, req))
+	}, func(context.Context) (v1.DispatchService_DispatchReachableResourcesClient, error) {
+		return nil, cr.localDispatcher.DispatchReachableResources(req, stream)
+	})
+	if err != nil || client == nil {
 		return err
 	}
 
@@ -191,11 +288,22 @@ func (cr *clusterDispatcher) DispatchLookupSubjects(
 		return err
 	}
 
+	req, err = withDeadlineBudget(ctx, cr, req)
+	if err != nil {
+		return err
+	}
+
 	withTimeout, cancelFn := context.WithTimeout(ctx, cr.dispatchOverallTimeout)
 	defer cancelFn()
 
-	client, err := cr.clusterClient.DispatchLookupSubjects(withTimeout, req)
-	if err != nil {
+	// If the peer is refused, the request is instead computed locally, publishing its results
+	// to the stream directly.
+	client, err := withPeerFallback(withTimeout, cr, "lookupsubjects", func(ctx context.Context) (v1.DispatchService_DispatchLookupSubjectsClient, error) {
+		return cr.clusterClient.DispatchLookupSubjects(ctx, withConcurrencyLimit(ctx, req))
+	}, func(context.Context) (v1.DispatchService_DispatchLookupSubjectsClient, error) {
+		return nil, cr.localDispatcher.DispatchLookupSubjects(req, stream)
+	})
+	if err != nil || client == nil {
 		return err
 	}
 
@@ -220,6 +328,58 @@ func (cr *clusterDispatcher) DispatchLookupSubjects(
 	}
 }
 
+// dispatchUnary dispatches a request to the peer, unless it is refused, hedged by computing it
+// locally if the peer is slow to answer.
+func dispatchUnary[T any](
+	ctx context.Context,
+	cr *clusterDispatcher,
+	method string,
+	peer func(ctx context.Context) (T, error),
+	local func(ctx context.Context) (T, error),
+) (T, error) {
+	return hedge(ctx, method, cr.hedgingDelay, func(ctx context.Context) (T, error) {
+		return withPeerFallback(ctx, cr, method, peer, local)
+	}, local)
+}
+
+type dispatchRequest[T any] interface {
+	dispatch.HasMetadata
+	CloneVT() T
+}
+
+// withConcurrencyLimit returns the request with any concurrency limit requested by the API
+// caller applied to its metadata, so that it is honored by the peer.
+func withConcurrencyLimit[T dispatchRequest[T]](ctx context.Context, req T) T {
+	limit := concurrencylimit.ForRequest(ctx, req.GetMetadata())
+	if limit == req.GetMetadata().GetConcurrencyLimit() {
+		return req
+	}
+
+	updated := req.CloneVT()
+	updated.GetMetadata().ConcurrencyLimit = limit
+	return updated
+}
+
+// withDeadlineBudget returns the request with the time remaining to the caller in the context,
+// less the safety margin, as its deadline budget. It fails without dispatching the request if
+// the budget is already exhausted, since the caller would abandon its response.
+func withDeadlineBudget[T dispatchRequest[T]](ctx context.Context, cr *clusterDispatcher, req T) (T, error) {
+	budget, ok := dispatch.DeadlineBudget(ctx, cr.deadlineMargin)
+	if !ok {
+		return req, nil
+	}
+	if budget <= 0 {
+		return req, dispatch.ErrDeadlineBudgetExhausted
+	}
+	if budget > cr.dispatchOverallTimeout {
+		budget = cr.dispatchOverallTimeout
+	}
+
+	updated := req.CloneVT()
+	updated.GetMetadata().DeadlineBudget = durationpb.New(budget)
+	return updated, nil
+}
+
 func (cr *clusterDispatcher) Close() error {
 	return nil
 }
@@ -2,20 +2,27 @@ package remote
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/authzed/spicedb/internal/dispatch"
 
+	"github.com/authzed/grpcutil"
 	humanize "github.com/dustin/go-humanize"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
 
 	"github.com/authzed/spicedb/internal/dispatch/keys"
+	"github.com/authzed/spicedb/pkg/balancer"
 	core "github.com/authzed/spicedb/pkg/proto/core/v1"
 	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
 )
@@ -122,3 +129,556 @@ func TestDispatchTimeout(t *testing.T) {
 		})
 	}
 }
+
+type negotiatingDispatchSvc struct {
+	v1.UnimplementedDispatchServiceServer
+
+	negotiatedFeatures []string
+	negotiationCount   int
+	requiredFeatures   []string
+}
+
+func (nds *negotiatingDispatchSvc) DispatchNegotiate(_ context.Context, req *v1.DispatchNegotiateRequest) (*v1.DispatchNegotiateResponse, error) {
+	nds.negotiationCount++
+	return &v1.DispatchNegotiateResponse{ApiVersion: req.ApiVersion, Features: nds.negotiatedFeatures}, nil
+}
+
+func (nds *negotiatingDispatchSvc) DispatchLookup(_ context.Context, req *v1.DispatchLookupRequest) (*v1.DispatchLookupResponse, error) {
+	nds.requiredFeatures = req.Metadata.RequiredFeatures
+	return &v1.DispatchLookupResponse{}, nil
+}
+
+func TestDispatchNegotiation(t *testing.T) {
+	for _, tc := range []struct {
+		name               string
+		negotiatedFeatures []string
+		legacyPeer         bool
+		expectedError      bool
+	}{
+		{"supported", []string{dispatch.FeatureCaveatedLookup, dispatch.FeatureRequiredFeatures}, false, false},
+		{"unsupported", []string{dispatch.FeatureRequiredFeatures}, false, true},
+		{"legacy peer", nil, true, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			listener := bufconn.Listen(humanize.MiByte)
+			s := grpc.NewServer()
+
+			svc := &negotiatingDispatchSvc{negotiatedFeatures: tc.negotiatedFeatures}
+			if tc.legacyPeer {
+				v1.RegisterDispatchServiceServer(s, &legacyDispatchSvc{svc})
+			} else {
+				v1.RegisterDispatchServiceServer(s, svc)
+			}
+
+			go func() {
+				// Ignore any errors
+				_ = s.Serve(listener)
+			}()
+
+			conn, err := grpc.DialContext(
+				context.Background(),
+				"",
+				grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+					return listener.Dial()
+				}),
+				grpc.WithTransportCredentials(insecure.NewCredentials()),
+				grpc.WithBlock(),
+			)
+			require.NoError(t, err)
+
+			t.Cleanup(func() {
+				conn.Close()
+				listener.Close()
+				s.Stop()
+			})
+
+			dispatcher := NewClusterDispatcher(v1.NewDispatchServiceClient(conn), conn, ClusterDispatcherConfig{
+				KeyHandler: &keys.DirectKeyHandler{},
+			})
+
+			req := &v1.DispatchLookupRequest{
+				ObjectRelation: &core.RelationReference{Namespace: "sometype", Relation: "somerel"},
+				Subject:        &core.ObjectAndRelation{Namespace: "foo", ObjectId: "bar", Relation: "..."},
+				Metadata:       &v1.ResolverMeta{DepthRemaining: 50},
+				Context:        &structpb.Struct{Fields: map[string]*structpb.Value{"somefield": structpb.NewBoolValue(true)}},
+			}
+
+			for i := 0; i < 2; i++ {
+				_, err = dispatcher.DispatchLookup(context.Background(), req)
+				if tc.expectedError {
+					require.ErrorAs(t, err, &dispatch.ErrUnsupportedFeatures{})
+					require.Nil(t, svc.requiredFeatures)
+				} else {
+					require.NoError(t, err)
+					require.Equal(t, []string{dispatch.FeatureCaveatedLookup}, svc.requiredFeatures)
+				}
+			}
+
+			// The original request must not be modified.
+			require.Empty(t, req.Metadata.RequiredFeatures)
+
+			if !tc.legacyPeer {
+				require.Equal(t, 1, svc.negotiationCount)
+			}
+		})
+	}
+}
+
+// legacyDispatchSvc is a dispatch service from before negotiation was introduced.
+type legacyDispatchSvc struct {
+	*negotiatingDispatchSvc
+}
+
+func (lds *legacyDispatchSvc) DispatchNegotiate(context.Context, *v1.DispatchNegotiateRequest) (*v1.DispatchNegotiateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DispatchNegotiate not implemented")
+}
+
+// pickingClusterClient emulates the balancer picking the next of the given peers for each
+// request, each of which negotiates its own features. If a spread is given, the peers are
+// instead picked in turn from those of the spread accepted by the request, as the balancer
+// does with a spread greater than one.
+type pickingClusterClient struct {
+	clusterClient
+
+	features     map[string][]string
+	picks        []string
+	spread       []string
+	spreadPicks  int
+	negotiations map[string]int
+	sent         map[string]int
+}
+
+func (pcc *pickingClusterClient) pick(ctx context.Context) (string, error) {
+	observer, ok := ctx.Value(balancer.PickObserverCtxKey).(balancer.PickObserver)
+	peer := pcc.nextPick(observer)
+	if !ok {
+		return peer, nil
+	}
+	if err := observer.Picked(peer); err != nil {
+		return "", err
+	}
+	observer.Done(peer, nil)
+	return peer, nil
+}
+
+func (pcc *pickingClusterClient) nextPick(observer balancer.PickObserver) string {
+	if len(pcc.spread) == 0 {
+		peer := pcc.picks[0]
+		pcc.picks = pcc.picks[1:]
+		return peer
+	}
+
+	candidates := pcc.spread
+	if filter, ok := observer.(balancer.PickFilter); ok {
+		var accepted []string
+		for _, peer := range pcc.spread {
+			if filter.Accepts(peer) {
+				accepted = append(accepted, peer)
+			}
+		}
+		if len(accepted) > 0 {
+			candidates = accepted
+		}
+	}
+
+	peer := candidates[pcc.spreadPicks%len(candidates)]
+	pcc.spreadPicks++
+	return peer
+}
+
+func (pcc *pickingClusterClient) DispatchNegotiate(ctx context.Context, req *v1.DispatchNegotiateRequest, _ ...grpc.CallOption) (*v1.DispatchNegotiateResponse, error) {
+	peer, err := pcc.pick(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pcc.negotiations[peer]++
+	return &v1.DispatchNegotiateResponse{ApiVersion: req.ApiVersion, Features: pcc.features[peer]}, nil
+}
+
+func (pcc *pickingClusterClient) DispatchLookup(ctx context.Context, _ *v1.DispatchLookupRequest, _ ...grpc.CallOption) (*v1.DispatchLookupResponse, error) {
+	peer, err := pcc.pick(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pcc.sent[peer]++
+	return &v1.DispatchLookupResponse{}, nil
+}
+
+type localLookupDispatcher struct {
+	dispatch.Dispatcher
+
+	calls int
+}
+
+func (lld *localLookupDispatcher) DispatchLookup(context.Context, *v1.DispatchLookupRequest) (*v1.DispatchLookupResponse, error) {
+	lld.calls++
+	return &v1.DispatchLookupResponse{}, nil
+}
+
+func TestDispatchNegotiationPerPeer(t *testing.T) {
+	req := &v1.DispatchLookupRequest{
+		ObjectRelation: &core.RelationReference{Namespace: "sometype", Relation: "somerel"},
+		Subject:        &core.ObjectAndRelation{Namespace: "foo", ObjectId: "bar", Relation: "..."},
+		Metadata:       &v1.ResolverMeta{DepthRemaining: 50},
+		Context:        &structpb.Struct{Fields: map[string]*structpb.Value{"somefield": structpb.NewBoolValue(true)}},
+	}
+
+	for _, withLocal := range []bool{false, true} {
+		withLocal := withLocal
+		t.Run(fmt.Sprintf("local fallback %v", withLocal), func(t *testing.T) {
+			client := &pickingClusterClient{
+				features: map[string][]string{
+					"new": {dispatch.FeatureCaveatedLookup, dispatch.FeatureRequiredFeatures},
+					"old": {dispatch.FeatureRequiredFeatures},
+				},
+				negotiations: map[string]int{},
+				sent:         map[string]int{},
+			}
+
+			config := ClusterDispatcherConfig{KeyHandler: &keys.DirectKeyHandler{}}
+			local := &localLookupDispatcher{}
+			if withLocal {
+				config.LocalDispatcher = local
+			}
+			dispatcher := NewClusterDispatcher(client, nil, config)
+
+			// Negotiated with, and sent to, a peer supporting the features.
+			client.picks = []string{"new", "new"}
+			_, err := dispatcher.DispatchLookup(context.Background(), req)
+			require.NoError(t, err)
+			require.Equal(t, 1, client.sent["new"])
+
+			// Negotiated with a peer not supporting the features, and never sent to it, instead
+			// being computed locally where possible.
+			client.picks = []string{"old", "old"}
+			_, err = dispatcher.DispatchLookup(context.Background(), req)
+			if withLocal {
+				require.NoError(t, err)
+			} else {
+				require.ErrorAs(t, err, &dispatch.ErrUnsupportedFeatures{})
+			}
+
+			// Negotiation with a peer already negotiated with is not sent again, and the request
+			// is never sent to a peer not supporting the features, or not yet negotiated with.
+			client.picks = []string{"new", "old"}
+			_, err = dispatcher.DispatchLookup(context.Background(), req)
+			if withLocal {
+				require.NoError(t, err)
+			} else {
+				require.ErrorAs(t, err, &dispatch.ErrUnsupportedFeatures{})
+			}
+
+			client.picks = []string{"new", "unknown"}
+			_, err = dispatcher.DispatchLookup(context.Background(), req)
+			if withLocal {
+				require.NoError(t, err)
+				require.Equal(t, 3, local.calls)
+			} else {
+				grpcutil.RequireStatus(t, codes.Unavailable, err)
+			}
+
+			require.Equal(t, map[string]int{"new": 1, "old": 1}, client.negotiations)
+			require.Equal(t, map[string]int{"new": 1}, client.sent)
+		})
+	}
+}
+
+func TestDispatchNegotiationWithSpread(t *testing.T) {
+	req := &v1.DispatchLookupRequest{
+		ObjectRelation: &core.RelationReference{Namespace: "sometype", Relation: "somerel"},
+		Subject:        &core.ObjectAndRelation{Namespace: "foo", ObjectId: "bar", Relation: "..."},
+		Metadata:       &v1.ResolverMeta{DepthRemaining: 50},
+		Context:        &structpb.Struct{Fields: map[string]*structpb.Value{"somefield": structpb.NewBoolValue(true)}},
+	}
+
+	client := &pickingClusterClient{
+		features: map[string][]string{
+			"first":  {dispatch.FeatureCaveatedLookup, dispatch.FeatureRequiredFeatures},
+			"old":    {dispatch.FeatureRequiredFeatures},
+			"second": {dispatch.FeatureCaveatedLookup, dispatch.FeatureRequiredFeatures},
+		},
+		spread:       []string{"first", "old", "second"},
+		negotiations: map[string]int{},
+		sent:         map[string]int{},
+	}
+
+	// Without a local fallback, a request sent to a peer not yet negotiated with would fail.
+	dispatcher := NewClusterDispatcher(client, nil, ClusterDispatcherConfig{KeyHandler: &keys.DirectKeyHandler{}})
+	for i := 0; i < 6; i++ {
+		_, err := dispatcher.DispatchLookup(context.Background(), req)
+		require.NoError(t, err)
+	}
+
+	// Each peer of the spread is negotiated with once, and requests are only sent to those
+	// supporting the features.
+	require.Equal(t, map[string]int{"first": 1, "old": 1, "second": 1}, client.negotiations)
+	require.Zero(t, client.sent["old"])
+	require.Equal(t, 6, client.sent["first"]+client.sent["second"])
+}
+
+type localCheckDispatcher struct {
+	dispatch.Dispatcher
+
+	calls int32
+}
+
+func (lcd *localCheckDispatcher) DispatchCheck(context.Context, *v1.DispatchCheckRequest) (*v1.DispatchCheckResponse, error) {
+	atomic.AddInt32(&lcd.calls, 1)
+	return &v1.DispatchCheckResponse{Metadata: &v1.ResponseMeta{DispatchCount: 42}}, nil
+}
+
+func TestDispatchHedging(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		sleepTime     time.Duration
+		hedgingDelay  time.Duration
+		expectedLocal bool
+	}{
+		{"slow peer", time.Second, 10 * time.Millisecond, true},
+		{"fast peer", 0, time.Second, false},
+		{"disabled", 20 * time.Millisecond, 0, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			listener := bufconn.Listen(humanize.MiByte)
+			s := grpc.NewServer()
+			v1.RegisterDispatchServiceServer(s, &fakeDispatchSvc{sleepTime: tc.sleepTime})
+
+			go func() {
+				// Ignore any errors
+				_ = s.Serve(listener)
+			}()
+
+			conn, err := grpc.DialContext(
+				context.Background(),
+				"",
+				grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+					return listener.Dial()
+				}),
+				grpc.WithTransportCredentials(insecure.NewCredentials()),
+				grpc.WithBlock(),
+			)
+			require.NoError(t, err)
+
+			t.Cleanup(func() {
+				conn.Close()
+				listener.Close()
+				s.Stop()
+			})
+
+			local := &localCheckDispatcher{}
+			dispatcher := NewClusterDispatcher(v1.NewDispatchServiceClient(conn), conn, ClusterDispatcherConfig{
+				KeyHandler:      &keys.DirectKeyHandler{},
+				HedgingDelay:    tc.hedgingDelay,
+				LocalDispatcher: local,
+			})
+
+			resp, err := dispatcher.DispatchCheck(context.Background(), &v1.DispatchCheckRequest{
+				ResourceRelation: &core.RelationReference{Namespace: "sometype", Relation: "somerel"},
+				ResourceIds:      []string{"foo"},
+				Metadata:         &v1.ResolverMeta{DepthRemaining: 50},
+				Subject:          &core.ObjectAndRelation{Namespace: "foo", ObjectId: "bar", Relation: "..."},
+			})
+			require.NoError(t, err)
+
+			if tc.expectedLocal {
+				require.Equal(t, uint32(42), resp.GetMetadata().GetDispatchCount())
+				require.Equal(t, int32(1), atomic.LoadInt32(&local.calls))
+			} else {
+				require.Zero(t, resp.GetMetadata().GetDispatchCount())
+				require.Zero(t, atomic.LoadInt32(&local.calls))
+			}
+		})
+	}
+}
+
+func TestHedgeReturnsFirstFailure(t *testing.T) {
+	peerErr := errors.New("peer failed")
+	_, err := hedge(context.Background(), "check", time.Millisecond, func(ctx context.Context) (int, error) {
+		time.Sleep(10 * time.Millisecond)
+		return 0, peerErr
+	}, func(ctx context.Context) (int, error) {
+		time.Sleep(50 * time.Millisecond)
+		return 0, errors.New("local failed")
+	})
+	require.ErrorIs(t, err, peerErr)
+
+	// A peer which fails before the delay is not hedged.
+	_, err = hedge(context.Background(), "check", time.Second, func(ctx context.Context) (int, error) {
+		return 0, peerErr
+	}, func(ctx context.Context) (int, error) {
+		t.Fatal("unexpected local computation")
+		return 0, nil
+	})
+	require.ErrorIs(t, err, peerErr)
+}
+
+func TestCircuitBreakers(t *testing.T) {
+	now := time.Now()
+	breakers := newCircuitBreakers(CircuitBreakerConfig{FailureThreshold: 2, OpenDuration: time.Minute})
+	breakers.now = func() time.Time { return now }
+
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+
+	// Errors other than unavailability and timeouts, and successes, do not trip the circuit.
+	breakers.record("peer", unavailable)
+	breakers.record("peer", status.Error(codes.FailedPrecondition, "failed precondition"))
+	breakers.record("peer", nil)
+	breakers.record("peer", unavailable)
+	require.True(t, breakers.allow("peer"))
+
+	breakers.record("peer", status.Error(codes.DeadlineExceeded, "deadline exceeded"))
+	require.False(t, breakers.allow("peer"))
+	require.True(t, breakers.allow("otherpeer"))
+
+	// Once open for the open duration, a single probe is allowed, and its failure reopens the
+	// circuit.
+	now = now.Add(time.Minute)
+	require.True(t, breakers.allow("peer"))
+	require.False(t, breakers.allow("peer"))
+	breakers.record("peer", unavailable)
+	require.False(t, breakers.allow("peer"))
+
+	// A canceled probe allows another.
+	now = now.Add(time.Minute)
+	require.True(t, breakers.allow("peer"))
+	breakers.record("peer", status.Error(codes.Canceled, "canceled"))
+	require.True(t, breakers.allow("peer"))
+
+	// A successful probe closes the circuit.
+	breakers.record("peer", nil)
+	require.True(t, breakers.allow("peer"))
+	require.True(t, breakers.allow("peer"))
+
+	require.Nil(t, newCircuitBreakers(CircuitBreakerConfig{}))
+}
+
+// observingClusterClient emulates the balancer picking the same peer for every request, which
+// is always unavailable.
+type observingClusterClient struct {
+	clusterClient
+
+	sent int
+}
+
+func (occ *observingClusterClient) DispatchCheck(ctx context.Context, _ *v1.DispatchCheckRequest, _ ...grpc.CallOption) (*v1.DispatchCheckResponse, error) {
+	observer, _ := ctx.Value(balancer.PickObserverCtxKey).(balancer.PickObserver)
+	if err := observer.Picked("peer"); err != nil {
+		return nil, err
+	}
+
+	occ.sent++
+	err := status.Error(codes.Unavailable, "peer unavailable")
+	observer.Done("peer", err)
+	return nil, err
+}
+
+func TestCircuitBreakerFallback(t *testing.T) {
+	client := &observingClusterClient{}
+	local := &localCheckDispatcher{}
+	dispatcher := NewClusterDispatcher(client, nil, ClusterDispatcherConfig{
+		KeyHandler:      &keys.DirectKeyHandler{},
+		CircuitBreaker:  CircuitBreakerConfig{FailureThreshold: 3},
+		LocalDispatcher: local,
+	})
+
+	req := &v1.DispatchCheckRequest{
+		ResourceRelation: &core.RelationReference{Namespace: "sometype", Relation: "somerel"},
+		ResourceIds:      []string{"foo"},
+		Metadata:         &v1.ResolverMeta{DepthRemaining: 50},
+		Subject:          &core.ObjectAndRelation{Namespace: "foo", ObjectId: "bar", Relation: "..."},
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err := dispatcher.DispatchCheck(context.Background(), req)
+		grpcutil.RequireStatus(t, codes.Unavailable, err)
+	}
+
+	resp, err := dispatcher.DispatchCheck(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, uint32(42), resp.GetMetadata().GetDispatchCount())
+	require.Equal(t, 3, client.sent)
+	require.Equal(t, int32(1), atomic.LoadInt32(&local.calls))
+}
+
+func TestPeerCallInflightLimit(t *testing.T) {
+	inflight := newInflightLimiter(2)
+	breakers := newCircuitBreakers(CircuitBreakerConfig{FailureThreshold: 1})
+
+	first := &peerCall{inflight: inflight, breakers: breakers}
+	second := &peerCall{inflight: inflight, breakers: breakers}
+	third := &peerCall{inflight: inflight, breakers: breakers}
+	require.NoError(t, first.Picked("peer"))
+	require.NoError(t, second.Picked("peer"))
+	require.Error(t, third.Picked("peer"))
+	require.Equal(t, fallbackReasonInflightLimit, *third.refused.Load())
+	require.NoError(t, third.Picked("otherpeer"))
+
+	// Requests refused for an open circuit do not count against the limit.
+	first.Done("peer", status.Error(codes.Unavailable, "unavailable"))
+	refused := &peerCall{inflight: inflight, breakers: breakers}
+	require.Error(t, refused.Picked("peer"))
+	require.Equal(t, fallbackReasonCircuitOpen, *refused.refused.Load())
+	require.Equal(t, uint32(1), inflight.inflight["peer"])
+
+	second.Done("peer", nil)
+	require.NotContains(t, inflight.inflight, "peer")
+}
+
+// budgetRecordingClusterClient records the deadline budget of each check dispatched to it.
+type budgetRecordingClusterClient struct {
+	clusterClient
+
+	budgets []time.Duration
+}
+
+func (bcc *budgetRecordingClusterClient) DispatchCheck(_ context.Context, req *v1.DispatchCheckRequest, _ ...grpc.CallOption) (*v1.DispatchCheckResponse, error) {
+	bcc.budgets = append(bcc.budgets, req.Metadata.GetDeadlineBudget().AsDuration())
+	return &v1.DispatchCheckResponse{Metadata: &v1.ResponseMeta{DispatchCount: 1}}, nil
+}
+
+func TestDispatchDeadlineBudget(t *testing.T) {
+	client := &budgetRecordingClusterClient{}
+	dispatcher := NewClusterDispatcher(client, nil, ClusterDispatcherConfig{
+		KeyHandler:             &keys.DirectKeyHandler{},
+		DispatchOverallTimeout: 1 * time.Minute,
+		DeadlineMargin:         100 * time.Millisecond,
+	})
+
+	req := &v1.DispatchCheckRequest{
+		ResourceRelation: &core.RelationReference{Namespace: "sometype", Relation: "somerel"},
+		ResourceIds:      []string{"foo"},
+		Metadata:         &v1.ResolverMeta{DepthRemaining: 50},
+		Subject:          &core.ObjectAndRelation{Namespace: "foo", ObjectId: "bar", Relation: "..."},
+	}
+
+	// Without a deadline, the request is bounded only by the overall timeout.
+	_, err := dispatcher.DispatchCheck(context.Background(), req)
+	require.NoError(t, err)
+	require.Zero(t, client.budgets[0])
+
+	// The budget is the time remaining to the caller, less the margin.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = dispatcher.DispatchCheck(ctx, req)
+	require.NoError(t, err)
+	require.LessOrEqual(t, client.budgets[1], 4900*time.Millisecond)
+	require.Greater(t, client.budgets[1], 4*time.Second)
+	require.Nil(t, req.Metadata.DeadlineBudget, "the request of the caller must not be modified")
+
+	// The budget is capped by the overall timeout.
+	longCtx, longCancel := context.WithTimeout(context.Background(), 1*time.Hour)
+	defer longCancel()
+	_, err = dispatcher.DispatchCheck(longCtx, req)
+	require.NoError(t, err)
+	require.Equal(t, 1*time.Minute, client.budgets[2])
+
+	// A request whose budget is already exhausted is never dispatched.
+	shortCtx, shortCancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer shortCancel()
+	_, err = dispatcher.DispatchCheck(shortCtx, req)
+	require.ErrorIs(t, err, dispatch.ErrDeadlineBudgetExhausted)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Len(t, client.budgets, 3)
+}
@@ -0,0 +1,90 @@
+package remote
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var hedgedDispatchCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "spicedb",
+	Subsystem: "dispatch",
+	Name:      "hedged_total",
+	Help:      "The number of dispatches to peers which were also computed locally after the hedging delay, by method and the source of the result returned.",
+}, []string{"method", "winner"})
+
+func init() {
+	prometheus.MustRegister(hedgedDispatchCounter)
+}
+
+const (
+	hedgeWinnerPeer  = "peer"
+	hedgeWinnerLocal = "local"
+	hedgeWinnerNone  = "none"
+)
+
+type hedgedResult[T any] struct {
+	resp   T
+	err    error
+	winner string
+}
+
+// hedge invokes the peer and, if it has not answered within the delay, also computes the
+// request locally, returning the first successful result and canceling the other. If both fail,
+// the error of the first to fail is returned. A delay of zero disables hedging.
+func hedge[T any](
+	ctx context.Context,
+	method string,
+	delay time.Duration,
+	peer func(ctx context.Context) (T, error),
+	local func(ctx context.Context) (T, error),
+) (T, error) {
+	if delay <= 0 {
+		return peer(ctx)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Buffered so that the loser does not block once the winner has been returned.
+	results := make(chan hedgedResult[T], 2)
+	invoke := func(fn func(ctx context.Context) (T, error), winner string) {
+		resp, err := fn(ctx)
+		results <- hedgedResult[T]{resp, err, winner}
+	}
+
+	go invoke(peer, hedgeWinnerPeer)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	pending := 1
+	hedged := false
+	var firstFailure *hedgedResult[T]
+	for {
+		select {
+		case <-timer.C:
+			hedged = true
+			pending++
+			go invoke(local, hedgeWinnerLocal)
+
+		case result := <-results:
+			pending--
+			if result.err != nil && pending > 0 {
+				firstFailure = &result
+				continue
+			}
+
+			if result.err != nil && firstFailure != nil {
+				result = *firstFailure
+				result.winner = hedgeWinnerNone
+			}
+
+			if hedged {
+				hedgedDispatchCounter.WithLabelValues(method, result.winner).Inc()
+			}
+			return result.resp, result.err
+		}
+	}
+}
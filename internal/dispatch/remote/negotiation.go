@@ -0,0 +1,260 @@
+package remote
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/authzed/spicedb/internal/dispatch"
+	log "github.com/authzed/spicedb/internal/logging"
+	"github.com/authzed/spicedb/pkg/balancer"
+	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+)
+
+const (
+	// negotiationTTL is how long a negotiated result is used before negotiating again, so that
+	// peers which have been upgraded (or rolled back) are eventually observed.
+	negotiationTTL = 1 * time.Minute
+
+	negotiationTimeout = 5 * time.Second
+
+	// unpickedPeer is the key of the negotiated state of connections which do not report the
+	// peer picked for each request, such as those not using the hashring balancer.
+	unpickedPeer = ""
+)
+
+// legacyFeatures are the features supported by peers which predate negotiation.
+var legacyFeatures = []string{dispatch.FeatureCaveatedLookup}
+
+var (
+	errNegotiationCurrent = status.Error(codes.Canceled, "dispatch features already negotiated with peer")
+	errPeerNotNegotiated  = status.Error(codes.Unavailable, "dispatch features not yet negotiated with peer")
+)
+
+type negotiatedState struct {
+	apiVersion uint32
+	features   []string
+	expiresAt  time.Time
+}
+
+func (ns *negotiatedState) supports(feature string) bool {
+	for _, supported := range ns.features {
+		if supported == feature {
+			return true
+		}
+	}
+	return false
+}
+
+func (ns *negotiatedState) current() bool {
+	return ns != nil && time.Now().Before(ns.expiresAt)
+}
+
+// peerNegotiations holds the state negotiated with each peer, by hashring member key.
+type peerNegotiations struct {
+	sync.Mutex
+	byPeer map[string]*negotiatedState
+}
+
+func (pn *peerNegotiations) get(peer string) *negotiatedState {
+	pn.Lock()
+	defer pn.Unlock()
+	return pn.byPeer[peer]
+}
+
+func (pn *peerNegotiations) store(peer string, negotiated *negotiatedState) {
+	pn.Lock()
+	defer pn.Unlock()
+
+	if pn.byPeer == nil {
+		pn.byPeer = make(map[string]*negotiatedState)
+	}
+
+	// Peers which have left the cluster are never negotiated with again.
+	for key, existing := range pn.byPeer {
+		if !existing.current() {
+			delete(pn.byPeer, key)
+		}
+	}
+	pn.byPeer[peer] = negotiated
+}
+
+// negotiationProbe observes the peer picked for a negotiation, preferring peers for which the
+// state negotiated is not current, and aborting it if the state negotiated with the peer picked
+// is still current.
+type negotiationProbe struct {
+	negotiations *peerNegotiations
+	peer         atomic.Pointer[string]
+	cached       atomic.Pointer[negotiatedState]
+}
+
+func (np *negotiationProbe) Picked(memberKey string) error {
+	np.peer.Store(&memberKey)
+	if existing := np.negotiations.get(memberKey); existing.current() {
+		np.cached.Store(existing)
+		return errNegotiationCurrent
+	}
+	return nil
+}
+
+func (np *negotiationProbe) Accepts(memberKey string) bool {
+	return !np.negotiations.get(memberKey).current()
+}
+
+func (np *negotiationProbe) Done(string, error) {}
+
+func (np *negotiationProbe) pickedPeer() string {
+	if peer := np.peer.Load(); peer != nil {
+		return *peer
+	}
+	return unpickedPeer
+}
+
+var (
+	_ balancer.PickObserver = &negotiationProbe{}
+	_ balancer.PickFilter   = &negotiationProbe{}
+)
+
+// negotiate returns the dispatch API version and features agreed upon with the peer picked by
+// the balancer for the request key in the context, along with the key of the peer. The balancer
+// picks a peer not yet negotiated with, amongst those the request may be sent to, if there are
+// any, so that each is negotiated with before the request is sent to it. The result is shared
+// across requests picking the same peer until it expires.
+//
+// Since the balancer may pick a different peer for the request itself, features required by
+// the request must also be checked against the peer it is sent to, which is done by
+// requireFeatures.
+func (cr *clusterDispatcher) negotiate(ctx context.Context) (*negotiatedState, string) {
+	if current := cr.negotiations.get(unpickedPeer); current.current() {
+		return current, unpickedPeer
+	}
+
+	withTimeout, cancelFn := context.WithTimeout(ctx, negotiationTimeout)
+	defer cancelFn()
+
+	probe := &negotiationProbe{negotiations: &cr.negotiations}
+	resp, err := cr.clusterClient.DispatchNegotiate(context.WithValue(withTimeout, balancer.PickObserverCtxKey, probe), &v1.DispatchNegotiateRequest{
+		ApiVersion: dispatch.APIVersion,
+		Features:   dispatch.SupportedFeatures,
+	})
+	if cached := probe.cached.Load(); cached != nil {
+		return cached, probe.pickedPeer()
+	}
+
+	peer := probe.pickedPeer()
+	previous := cr.negotiations.get(peer)
+
+	negotiated := &negotiatedState{expiresAt: time.Now().Add(negotiationTTL)}
+	switch {
+	case status.Code(err) == codes.Unimplemented:
+		negotiated.features = legacyFeatures
+
+	case err != nil:
+		log.Ctx(ctx).Warn().Err(err).Str("peer", peer).Msg("failed to negotiate dispatch API version")
+		if previous != nil {
+			return previous, peer
+		}
+		return &negotiatedState{features: legacyFeatures}, peer
+
+	default:
+		negotiated.apiVersion = resp.ApiVersion
+		negotiated.features = resp.Features
+	}
+
+	if previous == nil || previous.apiVersion != negotiated.apiVersion {
+		log.Ctx(ctx).Info().
+			Str("peer", peer).
+			Uint32("local-version", dispatch.APIVersion).
+			Uint32("peer-version", negotiated.apiVersion).
+			Strs("features", negotiated.features).
+			Msg("negotiated dispatch API version")
+	}
+
+	cr.negotiations.store(peer, negotiated)
+	return negotiated, peer
+}
+
+// featureGate has the balancer pick a peer with which the features the request requires have
+// been negotiated, amongst those the request may be sent to, and refuses the peer picked if
+// there is none. Peers which have not been negotiated with are refused, since they may predate
+// the features and ignore the fields carrying them.
+type featureGate struct {
+	negotiations *peerNegotiations
+	features     []string
+	failure      atomic.Pointer[error]
+}
+
+func (fg *featureGate) Accepts(memberKey string) bool {
+	negotiated := fg.negotiations.get(memberKey)
+	return negotiated != nil && len(unsupportedFeatures(negotiated, fg.features)) == 0
+}
+
+func (fg *featureGate) Picked(memberKey string) error {
+	negotiated := fg.negotiations.get(memberKey)
+	if negotiated == nil {
+		return fg.refuse(errPeerNotNegotiated)
+	}
+
+	if unsupported := unsupportedFeatures(negotiated, fg.features); len(unsupported) > 0 {
+		return fg.refuse(dispatch.NewUnsupportedFeaturesErr(unsupported))
+	}
+	return nil
+}
+
+// refuse records the reason the peer was refused, and returns an error ending the request. The
+// balancer does not allow every status code to be returned from a pick, so the reason must be
+// recovered with err.
+func (fg *featureGate) refuse(reason error) error {
+	fg.failure.Store(&reason)
+	return errPeerRefused
+}
+
+func (fg *featureGate) Done(string, error) {}
+
+// err returns the reason the peer picked for the request was refused, if it was.
+func (fg *featureGate) err() error {
+	if fg == nil {
+		return nil
+	}
+	if failure := fg.failure.Load(); failure != nil {
+		return *failure
+	}
+	return nil
+}
+
+var (
+	_ balancer.PickObserver = &featureGate{}
+	_ balancer.PickFilter   = &featureGate{}
+)
+
+func unsupportedFeatures(negotiated *negotiatedState, features []string) []string {
+	var unsupported []string
+	for _, feature := range features {
+		if !negotiated.supports(feature) {
+			unsupported = append(unsupported, feature)
+		}
+	}
+	return unsupported
+}
+
+// requireFeatures returns an error if the features have not been negotiated with the cluster,
+// when the connection does not report the peer picked for each request. Otherwise, it returns a
+// copy of the metadata marking the features as required, so that any peer not supporting them
+// rejects the request instead of ignoring the fields, and a context gating the peer picked for
+// the request on having negotiated the features.
+func (cr *clusterDispatcher) requireFeatures(ctx context.Context, metadata *v1.ResolverMeta, features ...string) (context.Context, *featureGate, *v1.ResolverMeta, error) {
+	// The features negotiated with a picked peer need not be checked here, as the request may
+	// be sent to another peer with which they have been negotiated.
+	if negotiated, peer := cr.negotiate(ctx); peer == unpickedPeer {
+		if unsupported := unsupportedFeatures(negotiated, features); len(unsupported) > 0 {
+			return ctx, nil, nil, dispatch.NewUnsupportedFeaturesErr(unsupported)
+		}
+	}
+
+	gate := &featureGate{negotiations: &cr.negotiations, features: features}
+	return context.WithValue(ctx, balancer.PickObserverCtxKey, gate), gate, dispatch.WithRequiredFeatures(metadata, features...), nil
+}
@@ -0,0 +1,162 @@
+package remote
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/authzed/spicedb/pkg/balancer"
+)
+
+const (
+	fallbackReasonCircuitOpen   = "circuit-open"
+	fallbackReasonInflightLimit = "inflight-limit"
+	fallbackReasonFeatures      = "features-not-negotiated"
+)
+
+var localFallbackCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "spicedb",
+	Subsystem: "dispatch",
+	Name:      "local_fallbacks_total",
+	Help:      "The number of dispatches computed locally because the peer picked for them was refused, by method and reason.",
+}, []string{"method", "reason"})
+
+func init() {
+	prometheus.MustRegister(localFallbackCounter)
+}
+
+var errPeerRefused = status.Error(codes.Unavailable, "dispatch peer refused by client")
+
+// inflightLimiter limits the number of requests in flight to each peer.
+type inflightLimiter struct {
+	sync.Mutex
+	limit    uint32
+	inflight map[string]uint32
+}
+
+func newInflightLimiter(limit uint32) *inflightLimiter {
+	if limit == 0 {
+		return nil
+	}
+	return &inflightLimiter{limit: limit, inflight: make(map[string]uint32)}
+}
+
+func (il *inflightLimiter) acquire(peer string) bool {
+	il.Lock()
+	defer il.Unlock()
+
+	if il.inflight[peer] >= il.limit {
+		return false
+	}
+	il.inflight[peer]++
+	return true
+}
+
+func (il *inflightLimiter) release(peer string) {
+	il.Lock()
+	defer il.Unlock()
+
+	if il.inflight[peer] <= 1 {
+		delete(il.inflight, peer)
+		return
+	}
+	il.inflight[peer]--
+}
+
+// peerCall observes the peer picked by the balancer for a single dispatch, refusing it if the
+// peer has reached its limit of requests in flight or its circuit is open, or if it is refused
+// by the observer already in the context of the request, and recording the outcome once the
+// request completes.
+type peerCall struct {
+	breakers *circuitBreakers
+	inflight *inflightLimiter
+	next     balancer.PickObserver
+	refused  atomic.Pointer[string]
+}
+
+func (pc *peerCall) Picked(memberKey string) error {
+	if pc.next != nil {
+		if err := pc.next.Picked(memberKey); err != nil {
+			return pc.refuse(fallbackReasonFeatures)
+		}
+	}
+
+	if reason, ok := pc.admit(memberKey); !ok {
+		if pc.next != nil {
+			pc.next.Done(memberKey, errPeerRefused)
+		}
+		return pc.refuse(reason)
+	}
+	return nil
+}
+
+func (pc *peerCall) Accepts(memberKey string) bool {
+	if filter, ok := pc.next.(balancer.PickFilter); ok {
+		return filter.Accepts(memberKey)
+	}
+	return true
+}
+
+func (pc *peerCall) admit(memberKey string) (string, bool) {
+	if pc.inflight != nil && !pc.inflight.acquire(memberKey) {
+		return fallbackReasonInflightLimit, false
+	}
+
+	if pc.breakers != nil && !pc.breakers.allow(memberKey) {
+		if pc.inflight != nil {
+			pc.inflight.release(memberKey)
+		}
+		return fallbackReasonCircuitOpen, false
+	}
+	return "", true
+}
+
+func (pc *peerCall) refuse(reason string) error {
+	pc.refused.Store(&reason)
+	return errPeerRefused
+}
+
+func (pc *peerCall) Done(memberKey string, err error) {
+	if pc.next != nil {
+		pc.next.Done(memberKey, err)
+	}
+	if pc.inflight != nil {
+		pc.inflight.release(memberKey)
+	}
+	if pc.breakers != nil {
+		pc.breakers.record(memberKey, err)
+	}
+}
+
+var (
+	_ balancer.PickObserver = &peerCall{}
+	_ balancer.PickFilter   = &peerCall{}
+)
+
+// withPeerFallback invokes the peer, unless the peer picked for the request is refused, in
+// which case the request is computed locally instead. Requests are only ever computed locally
+// if the dispatcher has a LocalDispatcher.
+func withPeerFallback[T any](
+	ctx context.Context,
+	cr *clusterDispatcher,
+	method string,
+	peer func(ctx context.Context) (T, error),
+	local func(ctx context.Context) (T, error),
+) (T, error) {
+	if cr.localDispatcher == nil {
+		return peer(ctx)
+	}
+
+	next, _ := ctx.Value(balancer.PickObserverCtxKey).(balancer.PickObserver)
+	call := &peerCall{breakers: cr.breakers, inflight: cr.inflight, next: next}
+	resp, err := peer(context.WithValue(ctx, balancer.PickObserverCtxKey, call))
+	if reason := call.refused.Load(); err != nil && reason != nil {
+		localFallbackCounter.WithLabelValues(method, *reason).Inc()
+		return local(ctx)
+	}
+	return resp, err
+}
@@ -0,0 +1,222 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	grpcauth "github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/auth"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/authzed/spicedb/internal/dispatch"
+	"github.com/authzed/spicedb/internal/middleware/priority"
+	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+)
+
+var poolDispatchesCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "spicedb",
+	Subsystem: "dispatch",
+	Name:      "pool_dispatches_total",
+	Help:      "The number of API dispatches routed to each dedicated pool.",
+}, []string{"pool"})
+
+func init() {
+	prometheus.MustRegister(poolDispatchesCounter)
+}
+
+// PoolRoutes are the rules routing the dispatches of API requests to dedicated pools, by pool
+// name. Routes by token take precedence over routes by namespace.
+type PoolRoutes struct {
+	// Tokens maps the preshared keys whose requests are routed to a pool to the pool.
+	Tokens map[string]string
+
+	// Namespaces maps the namespaces whose dispatches are routed to a pool to the pool. Each
+	// is either the name of a namespace, or a prefix ending in `/*`, such as `tenant/*`,
+	// matching every namespace with the prefix. Names take precedence over prefixes, and
+	// longer prefixes over shorter ones.
+	Namespaces map[string]string
+}
+
+type prefixRoute struct {
+	prefix string
+	pool   string
+}
+
+// PoolDispatcher is a dispatcher which routes the dispatches of API requests to dedicated
+// pools, each admitting a limited number of concurrent dispatches independently of the others
+// by priority class, so that the requests of noisy tenants cannot delay those of others.
+// Dispatches matching no route are delegated to the default dispatcher.
+//
+// NOTE: as with Dispatcher, only dispatches made by the API should be routed.
+type PoolDispatcher struct {
+	dispatch.CacheForwarder
+
+	delegate       dispatch.Dispatcher
+	defaultRoute   dispatch.Dispatcher
+	tokenPools     map[string]string
+	namespacePools map[string]string
+	prefixRoutes   []prefixRoute
+	pools          map[string]*Scheduler
+}
+
+// NewPoolDispatcher creates a new dispatcher routing dispatches to the pools with the given
+// numbers of slots by the routes, before delegating them, and dispatching those matching no
+// route to the default dispatcher.
+func NewPoolDispatcher(delegate dispatch.Dispatcher, defaultRoute dispatch.Dispatcher, slots map[string]uint16, routes PoolRoutes, weights map[priority.Priority]uint32) (*PoolDispatcher, error) {
+	pools := make(map[string]*Scheduler, len(slots))
+	for name, poolSlots := range slots {
+		pool, err := NewScheduler(poolSlots, weights)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dispatch pool `%s`: %w", name, err)
+		}
+		pools[name] = pool
+	}
+
+	pd := &PoolDispatcher{
+		CacheForwarder: dispatch.ForwardCaches(delegate),
+		delegate:       delegate,
+		defaultRoute:   defaultRoute,
+		tokenPools:     routes.Tokens,
+		namespacePools: make(map[string]string, len(routes.Namespaces)),
+		pools:          pools,
+	}
+
+	for _, name := range routes.Tokens {
+		if _, ok := pools[name]; !ok {
+			return nil, fmt.Errorf("unknown dispatch pool `%s` in token route", name)
+		}
+	}
+
+	for namespace, name := range routes.Namespaces {
+		if _, ok := pools[name]; !ok {
+			return nil, fmt.Errorf("unknown dispatch pool `%s` in route of namespace `%s`", name, namespace)
+		}
+
+		switch {
+		case strings.HasSuffix(namespace, "/*"):
+			pd.prefixRoutes = append(pd.prefixRoutes, prefixRoute{strings.TrimSuffix(namespace, "*"), name})
+		case namespace == "" || strings.Contains(namespace, "*"):
+			return nil, fmt.Errorf("invalid namespace `%s` in route to dispatch pool `%s`: wildcards are only allowed as a `/*` suffix", namespace, name)
+		default:
+			pd.namespacePools[namespace] = name
+		}
+	}
+	sort.Slice(pd.prefixRoutes, func(i, j int) bool {
+		return len(pd.prefixRoutes[i].prefix) > len(pd.prefixRoutes[j].prefix)
+	})
+	return pd, nil
+}
+
+// route returns the pool to which the dispatch of the namespace in the context is routed, or
+// nil if it is routed to the default dispatcher.
+func (pd *PoolDispatcher) route(ctx context.Context, namespace string) *Scheduler {
+	name, ok := "", false
+	if token, err := grpcauth.AuthFromMD(ctx, "bearer"); err == nil {
+		name, ok = pd.tokenPools[token]
+	}
+	if !ok {
+		name, ok = pd.namespacePools[namespace]
+	}
+	if !ok {
+		for _, route := range pd.prefixRoutes {
+			if strings.HasPrefix(namespace, route.prefix) {
+				name, ok = route.pool, true
+				break
+			}
+		}
+	}
+	if !ok {
+		return nil
+	}
+
+	poolDispatchesCounter.WithLabelValues(name).Inc()
+	return pd.pools[name]
+}
+
+func (pd *PoolDispatcher) DispatchCheck(ctx context.Context, req *v1.DispatchCheckRequest) (*v1.DispatchCheckResponse, error) {
+	pool := pd.route(ctx, req.GetResourceRelation().GetNamespace())
+	if pool == nil {
+		return pd.defaultRoute.DispatchCheck(ctx, req)
+	}
+
+	release, err := pool.Acquire(ctx, priority.FromContext(ctx))
+	if err != nil {
+		return &v1.DispatchCheckResponse{Metadata: &v1.ResponseMeta{}}, err
+	}
+	defer release()
+
+	return pd.delegate.DispatchCheck(ctx, req)
+}
+
+func (pd *PoolDispatcher) DispatchExpand(ctx context.Context, req *v1.DispatchExpandRequest) (*v1.DispatchExpandResponse, error) {
+	pool := pd.route(ctx, req.GetResourceAndRelation().GetNamespace())
+	if pool == nil {
+		return pd.defaultRoute.DispatchExpand(ctx, req)
+	}
+
+	release, err := pool.Acquire(ctx, priority.FromContext(ctx))
+	if err != nil {
+		return &v1.DispatchExpandResponse{Metadata: &v1.ResponseMeta{}}, err
+	}
+	defer release()
+
+	return pd.delegate.DispatchExpand(ctx, req)
+}
+
+func (pd *PoolDispatcher) DispatchLookup(ctx context.Context, req *v1.DispatchLookupRequest) (*v1.DispatchLookupResponse, error) {
+	pool := pd.route(ctx, req.GetObjectRelation().GetNamespace())
+	if pool == nil {
+		return pd.defaultRoute.DispatchLookup(ctx, req)
+	}
+
+	release, err := pool.Acquire(ctx, priority.FromContext(ctx))
+	if err != nil {
+		return &v1.DispatchLookupResponse{Metadata: &v1.ResponseMeta{}}, err
+	}
+	defer release()
+
+	return pd.delegate.DispatchLookup(ctx, req)
+}
+
+func (pd *PoolDispatcher) DispatchReachableResources(req *v1.DispatchReachableResourcesRequest, stream dispatch.ReachableResourcesStream) error {
+	ctx := stream.Context()
+	pool := pd.route(ctx, req.GetResourceRelation().GetNamespace())
+	if pool == nil {
+		return pd.defaultRoute.DispatchReachableResources(req, stream)
+	}
+
+	release, err := pool.Acquire(ctx, priority.FromContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return pd.delegate.DispatchReachableResources(req, stream)
+}
+
+func (pd *PoolDispatcher) DispatchLookupSubjects(req *v1.DispatchLookupSubjectsRequest, stream dispatch.LookupSubjectsStream) error {
+	ctx := stream.Context()
+	pool := pd.route(ctx, req.GetResourceRelation().GetNamespace())
+	if pool == nil {
+		return pd.defaultRoute.DispatchLookupSubjects(req, stream)
+	}
+
+	release, err := pool.Acquire(ctx, priority.FromContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return pd.delegate.DispatchLookupSubjects(req, stream)
+}
+
+func (pd *PoolDispatcher) Close() error {
+	return pd.delegate.Close()
+}
+
+func (pd *PoolDispatcher) IsReady() bool {
+	return pd.delegate.IsReady()
+}
+
+var _ dispatch.Dispatcher = &PoolDispatcher{}
@@ -0,0 +1,118 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/authzed/spicedb/internal/dispatch"
+	"github.com/authzed/spicedb/internal/middleware/priority"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+)
+
+// namedDispatcher answers checks with its name as the only member.
+type namedDispatcher struct {
+	dispatch.Dispatcher
+
+	name string
+}
+
+func (nd *namedDispatcher) DispatchCheck(ctx context.Context, req *v1.DispatchCheckRequest) (*v1.DispatchCheckResponse, error) {
+	return &v1.DispatchCheckResponse{
+		Metadata:            &v1.ResponseMeta{},
+		ResultsByResourceId: map[string]*v1.ResourceCheckResult{nd.name: {Membership: v1.ResourceCheckResult_MEMBER}},
+	}, nil
+}
+
+func checkRequest(namespace string) *v1.DispatchCheckRequest {
+	return &v1.DispatchCheckRequest{ResourceRelation: &core.RelationReference{Namespace: namespace, Relation: "view"}}
+}
+
+func TestPoolDispatcherRoutes(t *testing.T) {
+	require := require.New(t)
+
+	pd, err := NewPoolDispatcher(&namedDispatcher{name: "pooled"}, &namedDispatcher{name: "default"}, map[string]uint16{
+		"noisy":     1,
+		"sensitive": 4,
+	}, PoolRoutes{
+		Tokens: map[string]string{"batchtoken": "noisy"},
+		Namespaces: map[string]string{
+			"tenant/*":           "noisy",
+			"tenant/important/*": "sensitive",
+			"tenant/document":    "sensitive",
+		},
+	}, DefaultWeights)
+	require.NoError(err)
+
+	withToken := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "bearer batchtoken"))
+	for _, tc := range []struct {
+		name         string
+		ctx          context.Context
+		namespace    string
+		expectedPool string
+	}{
+		{"unrouted namespace", context.Background(), "document", ""},
+		{"namespace name", context.Background(), "tenant/document", "sensitive"},
+		{"namespace prefix", context.Background(), "tenant/folder", "noisy"},
+		{"longest namespace prefix", context.Background(), "tenant/important/folder", "sensitive"},
+		{"token over namespace", withToken, "tenant/document", "noisy"},
+		{"token without namespace route", withToken, "document", "noisy"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			pool := pd.route(tc.ctx, tc.namespace)
+			if tc.expectedPool == "" {
+				require.Nil(pool)
+			} else {
+				require.Same(pd.pools[tc.expectedPool], pool)
+			}
+		})
+	}
+}
+
+func TestPoolDispatcherIsolation(t *testing.T) {
+	require := require.New(t)
+
+	pd, err := NewPoolDispatcher(&namedDispatcher{name: "pooled"}, &namedDispatcher{name: "default"}, map[string]uint16{
+		"noisy": 1,
+	}, PoolRoutes{Namespaces: map[string]string{"tenant/*": "noisy"}}, DefaultWeights)
+	require.NoError(err)
+
+	// Hold the only slot of the noisy pool.
+	release, err := pd.pools["noisy"].Acquire(context.Background(), priority.Interactive)
+	require.NoError(err)
+
+	resp, err := pd.DispatchCheck(context.Background(), checkRequest("document"))
+	require.NoError(err)
+	require.Contains(resp.ResultsByResourceId, "default")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = pd.DispatchCheck(ctx, checkRequest("tenant/document"))
+	require.ErrorIs(err, context.DeadlineExceeded)
+
+	release()
+	resp, err = pd.DispatchCheck(context.Background(), checkRequest("tenant/document"))
+	require.NoError(err)
+	require.Contains(resp.ResultsByResourceId, "pooled")
+}
+
+func TestNewPoolDispatcherValidation(t *testing.T) {
+	delegate := &namedDispatcher{name: "pooled"}
+	slots := map[string]uint16{"noisy": 1}
+
+	_, err := NewPoolDispatcher(delegate, delegate, map[string]uint16{"empty": 0}, PoolRoutes{}, DefaultWeights)
+	require.ErrorContains(t, err, "invalid dispatch pool `empty`")
+
+	_, err = NewPoolDispatcher(delegate, delegate, slots, PoolRoutes{Namespaces: map[string]string{"document": "missing"}}, DefaultWeights)
+	require.ErrorContains(t, err, "unknown dispatch pool `missing`")
+
+	_, err = NewPoolDispatcher(delegate, delegate, slots, PoolRoutes{Tokens: map[string]string{"token": "missing"}}, DefaultWeights)
+	require.ErrorContains(t, err, "unknown dispatch pool `missing`")
+
+	_, err = NewPoolDispatcher(delegate, delegate, slots, PoolRoutes{Namespaces: map[string]string{"tenant*": "noisy"}}, DefaultWeights)
+	require.ErrorContains(t, err, "wildcards are only allowed")
+}
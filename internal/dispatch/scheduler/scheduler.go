@@ -0,0 +1,247 @@
+// Package scheduler implements a dispatcher which schedules the dispatches of API requests
+// by priority class, using weighted fair queuing, so that bulk requests cannot starve
+// interactive ones.
+package scheduler
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/authzed/spicedb/internal/dispatch"
+	"github.com/authzed/spicedb/internal/middleware/priority"
+	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+)
+
+// strideScale is divided by the weight of a priority class to compute its stride. It is
+// large enough for the strides of different weights to remain distinct.
+const strideScale = 1 << 20
+
+var queueWaitHistogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "spicedb",
+	Subsystem: "dispatch",
+	Name:      "priority_queue_wait_seconds",
+	Help:      "The time spent by dispatches waiting to be scheduled, by priority class.",
+	Buckets:   []float64{.0005, .001, .005, .01, .05, .1, .5, 1, 5},
+}, []string{"priority"})
+
+func init() {
+	prometheus.MustRegister(queueWaitHistogram)
+}
+
+// DefaultWeights are the default weights of the priority classes.
+var DefaultWeights = map[priority.Priority]uint32{
+	priority.Interactive: 4,
+	priority.Bulk:        1,
+}
+
+type waiter struct {
+	granted chan struct{}
+}
+
+type class struct {
+	stride  uint64
+	pass    uint64
+	waiters *list.List
+}
+
+// Scheduler admits a limited number of concurrent operations, choosing amongst those waiting
+// via stride scheduling, such that each backlogged priority class is admitted in proportion
+// to its weight.
+type Scheduler struct {
+	sync.Mutex
+	available  int
+	globalPass uint64
+	classes    map[priority.Priority]*class
+}
+
+// NewScheduler creates a new scheduler admitting up to the given number of concurrent
+// operations. Priority classes without a weight are given a weight of one.
+func NewScheduler(slots uint16, weights map[priority.Priority]uint32) (*Scheduler, error) {
+	if slots == 0 {
+		return nil, fmt.Errorf("scheduler requires at least one slot")
+	}
+
+	classes := make(map[priority.Priority]*class, len(priority.Priorities))
+	for _, p := range priority.Priorities {
+		weight, ok := weights[p]
+		if !ok {
+			weight = 1
+		}
+		if weight == 0 {
+			return nil, fmt.Errorf("weight of priority class `%s` must be positive", p)
+		}
+
+		classes[p] = &class{stride: strideScale / uint64(weight), waiters: list.New()}
+	}
+
+	return &Scheduler{available: int(slots), classes: classes}, nil
+}
+
+// Acquire waits until an operation of the given priority class is admitted, returning a
+// function which must be called once the operation completes.
+func (s *Scheduler) Acquire(ctx context.Context, p priority.Priority) (func(), error) {
+	c, ok := s.classes[p]
+	if !ok {
+		c = s.classes[priority.Interactive]
+	}
+
+	s.Lock()
+	if s.available > 0 && !s.hasWaiters() {
+		s.available--
+		s.Unlock()
+		return s.release, nil
+	}
+
+	// A class becoming backlogged cannot claim credit for the time it was idle.
+	if c.waiters.Len() == 0 && c.pass < s.globalPass {
+		c.pass = s.globalPass
+	}
+
+	w := &waiter{granted: make(chan struct{})}
+	element := c.waiters.PushBack(w)
+	s.Unlock()
+
+	start := time.Now()
+	defer func() {
+		queueWaitHistogram.WithLabelValues(string(p)).Observe(time.Since(start).Seconds())
+	}()
+
+	select {
+	case <-w.granted:
+		return s.release, nil
+
+	case <-ctx.Done():
+		s.Lock()
+		select {
+		case <-w.granted:
+			// The slot was granted concurrently, so pass it on.
+			s.Unlock()
+			s.release()
+		default:
+			c.waiters.Remove(element)
+			s.Unlock()
+		}
+		return nil, ctx.Err()
+	}
+}
+
+func (s *Scheduler) hasWaiters() bool {
+	for _, c := range s.classes {
+		if c.waiters.Len() > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// release grants the slot to the backlogged class with the lowest pass, if any.
+func (s *Scheduler) release() {
+	s.Lock()
+	defer s.Unlock()
+
+	var next *class
+	for _, p := range priority.Priorities {
+		c := s.classes[p]
+		if c.waiters.Len() > 0 && (next == nil || c.pass < next.pass) {
+			next = c
+		}
+	}
+
+	if next == nil {
+		s.available++
+		return
+	}
+
+	s.globalPass = next.pass
+	next.pass += next.stride
+	w := next.waiters.Remove(next.waiters.Front()).(*waiter)
+	close(w.granted)
+}
+
+// Dispatcher is a dispatcher which schedules dispatches by the priority class found in their
+// context before delegating them.
+//
+// NOTE: only dispatches made by the API should be scheduled; subdispatches hold no slot, as
+// otherwise requests could deadlock waiting on slots held by their parents.
+type Dispatcher struct {
+	dispatch.CacheForwarder
+
+	delegate  dispatch.Dispatcher
+	scheduler *Scheduler
+}
+
+// NewDispatcher creates a new dispatcher scheduling the dispatches made to the delegate.
+func NewDispatcher(delegate dispatch.Dispatcher, slots uint16, weights map[priority.Priority]uint32) (*Dispatcher, error) {
+	scheduler, err := NewScheduler(slots, weights)
+	if err != nil {
+		return nil, err
+	}
+	return &Dispatcher{dispatch.ForwardCaches(delegate), delegate, scheduler}, nil
+}
+
+func (d *Dispatcher) DispatchCheck(ctx context.Context, req *v1.DispatchCheckRequest) (*v1.DispatchCheckResponse, error) {
+	release, err := d.scheduler.Acquire(ctx, priority.FromContext(ctx))
+	if err != nil {
+		return &v1.DispatchCheckResponse{Metadata: &v1.ResponseMeta{}}, err
+	}
+	defer release()
+
+	return d.delegate.DispatchCheck(ctx, req)
+}
+
+func (d *Dispatcher) DispatchExpand(ctx context.Context, req *v1.DispatchExpandRequest) (*v1.DispatchExpandResponse, error) {
+	release, err := d.scheduler.Acquire(ctx, priority.FromContext(ctx))
+	if err != nil {
+		return &v1.DispatchExpandResponse{Metadata: &v1.ResponseMeta{}}, err
+	}
+	defer release()
+
+	return d.delegate.DispatchExpand(ctx, req)
+}
+
+func (d *Dispatcher) DispatchLookup(ctx context.Context, req *v1.DispatchLookupRequest) (*v1.DispatchLookupResponse, error) {
+	release, err := d.scheduler.Acquire(ctx, priority.FromContext(ctx))
+	if err != nil {
+		return &v1.DispatchLookupResponse{Metadata: &v1.ResponseMeta{}}, err
+	}
+	defer release()
+
+	return d.delegate.DispatchLookup(ctx, req)
+}
+
+func (d *Dispatcher) DispatchReachableResources(req *v1.DispatchReachableResourcesRequest, stream dispatch.ReachableResourcesStream) error {
+	ctx := stream.Context()
+	release, err := d.scheduler.Acquire(ctx, priority.FromContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return d.delegate.DispatchReachableResources(req, stream)
+}
+
+func (d *Dispatcher) DispatchLookupSubjects(req *v1.DispatchLookupSubjectsRequest, stream dispatch.LookupSubjectsStream) error {
+	ctx := stream.Context()
+	release, err := d.scheduler.Acquire(ctx, priority.FromContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return d.delegate.DispatchLookupSubjects(req, stream)
+}
+
+func (d *Dispatcher) Close() error {
+	return d.delegate.Close()
+}
+
+func (d *Dispatcher) IsReady() bool {
+	return d.delegate.IsReady()
+}
+
+var _ dispatch.Dispatcher = &Dispatcher{}
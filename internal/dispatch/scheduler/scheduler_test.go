@@ -0,0 +1,107 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/middleware/priority"
+)
+
+func TestSchedulerWeightedOrdering(t *testing.T) {
+	require := require.New(t)
+
+	s, err := NewScheduler(1, map[priority.Priority]uint32{
+		priority.Interactive: 3,
+		priority.Bulk:        1,
+	})
+	require.NoError(err)
+
+	// Hold the only slot while the waiters are queued.
+	release, err := s.Acquire(context.Background(), priority.Interactive)
+	require.NoError(err)
+
+	admitted := make(chan priority.Priority, 16)
+	queue := func(p priority.Priority) {
+		go func() {
+			release, err := s.Acquire(context.Background(), p)
+			if err != nil {
+				return
+			}
+			admitted <- p
+			release()
+		}()
+	}
+
+	for i := 0; i < 4; i++ {
+		queue(priority.Bulk)
+	}
+	for i := 0; i < 6; i++ {
+		queue(priority.Interactive)
+	}
+	require.Eventually(func() bool {
+		s.Lock()
+		defer s.Unlock()
+		return s.classes[priority.Interactive].waiters.Len() == 6 && s.classes[priority.Bulk].waiters.Len() == 4
+	}, time.Second, time.Millisecond)
+
+	release()
+
+	order := make([]priority.Priority, 0, 10)
+	for i := 0; i < 10; i++ {
+		order = append(order, <-admitted)
+	}
+
+	// While both classes are backlogged, interactive dispatches are admitted three times as
+	// often as bulk ones, without bulk dispatches being starved.
+	countInteractive := 0
+	for _, p := range order[:8] {
+		if p == priority.Interactive {
+			countInteractive++
+		}
+	}
+	require.Equal(6, countInteractive)
+	require.Contains(order[:4], priority.Bulk)
+}
+
+func TestSchedulerCancellation(t *testing.T) {
+	require := require.New(t)
+
+	s, err := NewScheduler(1, DefaultWeights)
+	require.NoError(err)
+
+	release, err := s.Acquire(context.Background(), priority.Bulk)
+	require.NoError(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error)
+	go func() {
+		_, err := s.Acquire(ctx, priority.Interactive)
+		errs <- err
+	}()
+
+	require.Eventually(func() bool {
+		s.Lock()
+		defer s.Unlock()
+		return s.classes[priority.Interactive].waiters.Len() == 1
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	require.ErrorIs(<-errs, context.Canceled)
+
+	// The cancelled waiter must not hold the slot once released.
+	release()
+	release, err = s.Acquire(context.Background(), priority.Bulk)
+	require.NoError(err)
+	release()
+}
+
+func TestNewSchedulerValidation(t *testing.T) {
+	_, err := NewScheduler(0, DefaultWeights)
+	require.Error(t, err)
+
+	_, err = NewScheduler(1, map[priority.Priority]uint32{priority.Bulk: 0})
+	require.Error(t, err)
+}
@@ -0,0 +1,378 @@
+// Package shadow implements a dispatcher which dispatches a sample of requests to a shadow
+// dispatcher, such as a cluster running a new implementation of a resolver, in addition to the
+// primary dispatcher, and reports where their results diverge. The results of the shadow
+// dispatcher are never returned.
+package shadow
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/authzed/spicedb/internal/dispatch"
+	log "github.com/authzed/spicedb/internal/logging"
+	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+)
+
+var comparisonsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "spicedb",
+	Subsystem: "dispatch_shadow",
+	Name:      "comparisons_total",
+	Help:      "The number of sampled dispatches made to the shadow dispatcher, by method and result: matched, diverged, failed if the shadow dispatch failed, or dropped if too many were in flight.",
+}, []string{"method", "result"})
+
+func init() {
+	prometheus.MustRegister(comparisonsCounter)
+}
+
+// maxLoggedDifferences is the maximum number of the results found by only one of the
+// dispatchers which are logged for each divergence.
+const maxLoggedDifferences = 10
+
+// Config configures the sampling of dispatches made to the shadow dispatcher.
+type Config struct {
+	// SampleRate is the fraction of dispatches also made to the shadow dispatcher.
+	SampleRate float64
+
+	// Timeout is the time given to each shadow dispatch.
+	Timeout time.Duration
+
+	// MaxInflight is the maximum number of shadow dispatches in flight, beyond which sampled
+	// dispatches are dropped.
+	MaxInflight int
+}
+
+// Dispatcher returns the results of the primary dispatcher, and compares those of a sample of
+// its checks, lookups and reachable resources against those of the shadow dispatcher once they
+// have completed. Expansions are never compared, since the shapes of their trees legitimately
+// differ between implementations.
+//
+// NOTE: only dispatches made by the API should be compared, since the results of
+// subdispatches are compared as part of those of their parents.
+type Dispatcher struct {
+	dispatch.CacheForwarder
+
+	primary  dispatch.Dispatcher
+	shadow   dispatch.Dispatcher
+	config   Config
+	inflight chan struct{}
+}
+
+// NewDispatcher creates a new dispatcher comparing a sample of the dispatches made to the
+// primary dispatcher against those of the shadow dispatcher.
+func NewDispatcher(primary, shadow dispatch.Dispatcher, config Config) (*Dispatcher, error) {
+	if config.SampleRate < 0 || config.SampleRate > 1 {
+		return nil, fmt.Errorf("shadow dispatch sample rate must be between 0 and 1")
+	}
+
+	if config.Timeout <= 0 {
+		return nil, fmt.Errorf("shadow dispatch timeout must be positive")
+	}
+
+	if config.MaxInflight <= 0 {
+		return nil, fmt.Errorf("shadow dispatch max inflight must be positive")
+	}
+
+	return &Dispatcher{
+		CacheForwarder: dispatch.ForwardCaches(primary),
+		primary:        primary,
+		shadow:         shadow,
+		config:         config,
+		inflight:       make(chan struct{}, config.MaxInflight),
+	}, nil
+}
+
+func (d *Dispatcher) sampled() bool {
+	return d.config.SampleRate >= 1 || rand.Float64() < d.config.SampleRate
+}
+
+// compare runs the shadow dispatch in the background, once the primary dispatch has completed,
+// and compares the summaries of their results.
+func (d *Dispatcher) compare(ctx context.Context, method string, req dispatch.HasMetadata, primary []string, shadow func(ctx context.Context) ([]string, error)) {
+	select {
+	case d.inflight <- struct{}{}:
+	default:
+		comparisonsCounter.WithLabelValues(method, "dropped").Inc()
+		return
+	}
+
+	go func() {
+		defer func() { <-d.inflight }()
+
+		ctx, cancel := context.WithTimeout(detachedContext{ctx}, d.config.Timeout)
+		defer cancel()
+
+		results, err := shadow(ctx)
+		if err != nil {
+			comparisonsCounter.WithLabelValues(method, "failed").Inc()
+			log.Ctx(ctx).Debug().Err(err).Str("method", method).Object("request", req).Msg("shadow dispatch failed")
+			return
+		}
+
+		onlyPrimary, onlyShadow := difference(primary, results), difference(results, primary)
+		if len(onlyPrimary) == 0 && len(onlyShadow) == 0 {
+			comparisonsCounter.WithLabelValues(method, "matched").Inc()
+			return
+		}
+
+		comparisonsCounter.WithLabelValues(method, "diverged").Inc()
+		log.Ctx(ctx).Warn().
+			Str("method", method).
+			Object("request", req).
+			Int("primary-results", len(primary)).
+			Int("shadow-results", len(results)).
+			Strs("only-primary", truncate(onlyPrimary)).
+			Strs("only-shadow", truncate(onlyShadow)).
+			Msg("shadow dispatch diverged from primary")
+	}()
+}
+
+func (d *Dispatcher) DispatchCheck(ctx context.Context, req *v1.DispatchCheckRequest) (*v1.DispatchCheckResponse, error) {
+	if !d.sampled() {
+		return d.primary.DispatchCheck(ctx, req)
+	}
+
+	shadowReq := proto.Clone(req).(*v1.DispatchCheckRequest)
+	resp, err := d.primary.DispatchCheck(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+
+	d.compare(ctx, "check", shadowReq, summarizeCheck(req, resp), func(ctx context.Context) ([]string, error) {
+		shadowResp, err := d.shadow.DispatchCheck(ctx, shadowReq)
+		if err != nil {
+			return nil, err
+		}
+		return summarizeCheck(shadowReq, shadowResp), nil
+	})
+	return resp, err
+}
+
+func (d *Dispatcher) DispatchExpand(ctx context.Context, req *v1.DispatchExpandRequest) (*v1.DispatchExpandResponse, error) {
+	return d.primary.DispatchExpand(ctx, req)
+}
+
+func (d *Dispatcher) DispatchLookup(ctx context.Context, req *v1.DispatchLookupRequest) (*v1.DispatchLookupResponse, error) {
+	if !d.sampled() {
+		return d.primary.DispatchLookup(ctx, req)
+	}
+
+	shadowReq := proto.Clone(req).(*v1.DispatchLookupRequest)
+	resp, err := d.primary.DispatchLookup(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+
+	// Limited lookups which reached their limit may legitimately return different resources.
+	if req.Limit > 0 && len(resp.ResolvedResources) >= int(req.Limit) {
+		return resp, err
+	}
+
+	d.compare(ctx, "lookup", shadowReq, summarizeLookup(resp), func(ctx context.Context) ([]string, error) {
+		shadowResp, err := d.shadow.DispatchLookup(ctx, shadowReq)
+		if err != nil {
+			return nil, err
+		}
+		return summarizeLookup(shadowResp), nil
+	})
+	return resp, err
+}
+
+func (d *Dispatcher) DispatchReachableResources(req *v1.DispatchReachableResourcesRequest, stream dispatch.ReachableResourcesStream) error {
+	if !d.sampled() {
+		return d.primary.DispatchReachableResources(req, stream)
+	}
+
+	shadowReq := proto.Clone(req).(*v1.DispatchReachableResourcesRequest)
+	collecting := &collectingStream[*v1.DispatchReachableResourcesResponse]{Stream: stream}
+	if err := d.primary.DispatchReachableResources(req, collecting); err != nil {
+		return err
+	}
+
+	d.compare(stream.Context(), "reachableresources", shadowReq, summarizeReachableResources(collecting.results), func(ctx context.Context) ([]string, error) {
+		shadowStream := dispatch.NewCollectingDispatchStream[*v1.DispatchReachableResourcesResponse](ctx)
+		if err := d.shadow.DispatchReachableResources(shadowReq, shadowStream); err != nil {
+			return nil, err
+		}
+		return summarizeReachableResources(shadowStream.Results()), nil
+	})
+	return nil
+}
+
+func (d *Dispatcher) DispatchLookupSubjects(req *v1.DispatchLookupSubjectsRequest, stream dispatch.LookupSubjectsStream) error {
+	if !d.sampled() {
+		return d.primary.DispatchLookupSubjects(req, stream)
+	}
+
+	shadowReq := proto.Clone(req).(*v1.DispatchLookupSubjectsRequest)
+	collecting := &collectingStream[*v1.DispatchLookupSubjectsResponse]{Stream: stream}
+	if err := d.primary.DispatchLookupSubjects(req, collecting); err != nil {
+		return err
+	}
+
+	d.compare(stream.Context(), "lookupsubjects", shadowReq, summarizeLookupSubjects(collecting.results), func(ctx context.Context) ([]string, error) {
+		shadowStream := dispatch.NewCollectingDispatchStream[*v1.DispatchLookupSubjectsResponse](ctx)
+		if err := d.shadow.DispatchLookupSubjects(shadowReq, shadowStream); err != nil {
+			return nil, err
+		}
+		return summarizeLookupSubjects(shadowStream.Results()), nil
+	})
+	return nil
+}
+
+// Close closes the primary dispatcher. The shadow dispatcher is closed by its creator.
+func (d *Dispatcher) Close() error {
+	return d.primary.Close()
+}
+
+// IsReady returns whether the primary dispatcher is ready; the shadow dispatcher does not
+// affect the readiness of the server.
+func (d *Dispatcher) IsReady() bool {
+	return d.primary.IsReady()
+}
+
+var _ dispatch.Dispatcher = &Dispatcher{}
+
+// collectingStream publishes results to the wrapped stream, collecting those published.
+type collectingStream[T any] struct {
+	dispatch.Stream[T]
+
+	mu      sync.Mutex
+	results []T
+}
+
+func (s *collectingStream[T]) Publish(result T) error {
+	if err := s.Stream.Publish(result); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results = append(s.results, result)
+	return nil
+}
+
+// detachedContext carries the values of its parent, but neither its deadline nor its
+// cancellation, so that shadow dispatches outlive the requests which sampled them.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (c detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (c detachedContext) Done() <-chan struct{}       { return nil }
+func (c detachedContext) Err() error                  { return nil }
+func (c detachedContext) Value(key any) any           { return c.parent.Value(key) }
+
+// The summarize functions below reduce the results of dispatches to sorted strings, such that
+// equivalent results of different implementations have equal summaries.
+
+func summarizeCheck(req *v1.DispatchCheckRequest, resp *v1.DispatchCheckResponse) []string {
+	// Checks allowing a single result may stop at any of the resources which are members.
+	if req.ResultsSetting == v1.DispatchCheckRequest_ALLOW_SINGLE_RESULT {
+		for _, result := range resp.ResultsByResourceId {
+			if result.Membership == v1.ResourceCheckResult_MEMBER {
+				return []string{"any:MEMBER"}
+			}
+		}
+	}
+
+	summary := make([]string, 0, len(resp.ResultsByResourceId))
+	for resourceID, result := range resp.ResultsByResourceId {
+		if result.Membership == v1.ResourceCheckResult_NOT_MEMBER {
+			continue
+		}
+		summary = append(summary, resourceID+":"+result.Membership.String())
+	}
+	return sorted(summary)
+}
+
+func summarizeLookup(resp *v1.DispatchLookupResponse) []string {
+	summary := make([]string, 0, len(resp.ResolvedResources))
+	for _, resource := range resp.ResolvedResources {
+		summary = append(summary, resource.ResourceId+":"+resource.Permissionship.String())
+	}
+	return sorted(summary)
+}
+
+// summarizeReachableResources summarizes the resources found, but not their statuses, which
+// depend upon the paths by which they were reached.
+func summarizeReachableResources(responses []*v1.DispatchReachableResourcesResponse) []string {
+	found := make(map[string]struct{})
+	for _, resp := range responses {
+		for _, resource := range resp.Resources {
+			found[resource.ResourceId] = struct{}{}
+		}
+	}
+
+	summary := make([]string, 0, len(found))
+	for resourceID := range found {
+		summary = append(summary, resourceID)
+	}
+	return sorted(summary)
+}
+
+func summarizeLookupSubjects(responses []*v1.DispatchLookupSubjectsResponse) []string {
+	found := make(map[string]struct{})
+	for _, resp := range responses {
+		for resourceID, subjects := range resp.FoundSubjectsByResourceId {
+			for _, subject := range subjects.FoundSubjects {
+				entry := resourceID + ":" + subject.SubjectId
+				if subject.CaveatExpression != nil {
+					entry += "[caveated]"
+				}
+				if len(subject.ExcludedSubjects) > 0 {
+					excluded := make([]string, 0, len(subject.ExcludedSubjects))
+					for _, excludedSubject := range subject.ExcludedSubjects {
+						excluded = append(excluded, excludedSubject.SubjectId)
+					}
+					entry += " - {" + strings.Join(sorted(excluded), ", ") + "}"
+				}
+				found[entry] = struct{}{}
+			}
+		}
+	}
+
+	summary := make([]string, 0, len(found))
+	for entry := range found {
+		summary = append(summary, entry)
+	}
+	return sorted(summary)
+}
+
+func sorted(values []string) []string {
+	sort.Strings(values)
+	return values
+}
+
+// difference returns the values of the sorted a which are not in the sorted b.
+func difference(a, b []string) []string {
+	var diff []string
+	i, j := 0, 0
+	for i < len(a) {
+		switch {
+		case j >= len(b) || a[i] < b[j]:
+			diff = append(diff, a[i])
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			i++
+			j++
+		}
+	}
+	return diff
+}
+
+func truncate(values []string) []string {
+	if len(values) > maxLoggedDifferences {
+		return values[:maxLoggedDifferences]
+	}
+	return values
+}
@@ -0,0 +1,201 @@
+package shadow
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/dispatch"
+	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+)
+
+// fakeDispatcher returns the configured results of checks, lookups and reachable resources.
+type fakeDispatcher struct {
+	dispatch.Dispatcher
+
+	members   []string
+	resources []string
+	err       error
+	blocked   chan struct{}
+}
+
+func (fd *fakeDispatcher) DispatchCheck(ctx context.Context, req *v1.DispatchCheckRequest) (*v1.DispatchCheckResponse, error) {
+	if fd.blocked != nil {
+		<-fd.blocked
+	}
+	if fd.err != nil {
+		return nil, fd.err
+	}
+
+	results := make(map[string]*v1.ResourceCheckResult, len(fd.members))
+	for _, member := range fd.members {
+		results[member] = &v1.ResourceCheckResult{Membership: v1.ResourceCheckResult_MEMBER}
+	}
+	return &v1.DispatchCheckResponse{Metadata: &v1.ResponseMeta{}, ResultsByResourceId: results}, nil
+}
+
+func (fd *fakeDispatcher) DispatchLookup(ctx context.Context, req *v1.DispatchLookupRequest) (*v1.DispatchLookupResponse, error) {
+	resolved := make([]*v1.ResolvedResource, 0, len(fd.resources))
+	for _, resource := range fd.resources {
+		resolved = append(resolved, &v1.ResolvedResource{ResourceId: resource, Permissionship: v1.ResolvedResource_HAS_PERMISSION})
+	}
+	return &v1.DispatchLookupResponse{Metadata: &v1.ResponseMeta{}, ResolvedResources: resolved}, nil
+}
+
+func (fd *fakeDispatcher) DispatchReachableResources(req *v1.DispatchReachableResourcesRequest, stream dispatch.ReachableResourcesStream) error {
+	// Publish the resources in reverse, to check that their order is not compared.
+	for i := len(fd.resources) - 1; i >= 0; i-- {
+		if err := stream.Publish(&v1.DispatchReachableResourcesResponse{
+			Resources: []*v1.ReachableResource{{ResourceId: fd.resources[i]}},
+			Metadata:  &v1.ResponseMeta{},
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func comparisons(method, result string) float64 {
+	return testutil.ToFloat64(comparisonsCounter.WithLabelValues(method, result))
+}
+
+func requireCompared(t *testing.T, method, result string, before float64) {
+	require.Eventually(t, func() bool {
+		return comparisons(method, result) == before+1
+	}, time.Second, time.Millisecond)
+}
+
+var config = Config{SampleRate: 1, Timeout: time.Second, MaxInflight: 10}
+
+func TestShadowCheck(t *testing.T) {
+	primary := &fakeDispatcher{members: []string{"first", "second"}}
+
+	for _, tc := range []struct {
+		name     string
+		shadow   *fakeDispatcher
+		expected string
+	}{
+		{"matched", &fakeDispatcher{members: []string{"second", "first"}}, "matched"},
+		{"missing member", &fakeDispatcher{members: []string{"first"}}, "diverged"},
+		{"extra member", &fakeDispatcher{members: []string{"first", "second", "third"}}, "diverged"},
+		{"failed", &fakeDispatcher{err: errors.New("shadow failed")}, "failed"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d, err := NewDispatcher(primary, tc.shadow, config)
+			require.NoError(t, err)
+
+			before := comparisons("check", tc.expected)
+			resp, err := d.DispatchCheck(context.Background(), &v1.DispatchCheckRequest{ResourceIds: []string{"first", "second", "third"}})
+			require.NoError(t, err)
+			require.Len(t, resp.ResultsByResourceId, 2)
+			requireCompared(t, "check", tc.expected, before)
+		})
+	}
+}
+
+func TestShadowCheckSingleResult(t *testing.T) {
+	d, err := NewDispatcher(&fakeDispatcher{members: []string{"first"}}, &fakeDispatcher{members: []string{"second"}}, config)
+	require.NoError(t, err)
+
+	before := comparisons("check", "matched")
+	_, err = d.DispatchCheck(context.Background(), &v1.DispatchCheckRequest{
+		ResourceIds:    []string{"first", "second"},
+		ResultsSetting: v1.DispatchCheckRequest_ALLOW_SINGLE_RESULT,
+	})
+	require.NoError(t, err)
+	requireCompared(t, "check", "matched", before)
+}
+
+func TestShadowLookup(t *testing.T) {
+	d, err := NewDispatcher(&fakeDispatcher{resources: []string{"first", "second"}}, &fakeDispatcher{resources: []string{"first"}}, config)
+	require.NoError(t, err)
+
+	before := comparisons("lookup", "diverged")
+	resp, err := d.DispatchLookup(context.Background(), &v1.DispatchLookupRequest{})
+	require.NoError(t, err)
+	require.Len(t, resp.ResolvedResources, 2)
+	requireCompared(t, "lookup", "diverged", before)
+
+	// Lookups which reached their limit are not compared.
+	matched, diverged := comparisons("lookup", "matched"), comparisons("lookup", "diverged")
+	_, err = d.DispatchLookup(context.Background(), &v1.DispatchLookupRequest{Limit: 2})
+	require.NoError(t, err)
+	require.Never(t, func() bool {
+		return comparisons("lookup", "matched") != matched || comparisons("lookup", "diverged") != diverged
+	}, 50*time.Millisecond, time.Millisecond)
+}
+
+func TestShadowReachableResources(t *testing.T) {
+	primary := &fakeDispatcher{resources: []string{"first", "second"}}
+
+	for _, tc := range []struct {
+		name      string
+		resources []string
+		expected  string
+	}{
+		{"matched", []string{"first", "second"}, "matched"},
+		{"diverged", []string{"first", "third"}, "diverged"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d, err := NewDispatcher(primary, &fakeDispatcher{resources: tc.resources}, config)
+			require.NoError(t, err)
+
+			before := comparisons("reachableresources", tc.expected)
+			stream := dispatch.NewCollectingDispatchStream[*v1.DispatchReachableResourcesResponse](context.Background())
+			require.NoError(t, d.DispatchReachableResources(&v1.DispatchReachableResourcesRequest{}, stream))
+			require.Equal(t, []string{"first", "second"}, summarizeReachableResources(stream.Results()))
+			requireCompared(t, "reachableresources", tc.expected, before)
+		})
+	}
+}
+
+func TestShadowDropped(t *testing.T) {
+	blocked := make(chan struct{})
+	d, err := NewDispatcher(&fakeDispatcher{members: []string{"first"}}, &fakeDispatcher{members: []string{"first"}, blocked: blocked}, Config{
+		SampleRate:  1,
+		Timeout:     time.Second,
+		MaxInflight: 1,
+	})
+	require.NoError(t, err)
+
+	dropped, matched := comparisons("check", "dropped"), comparisons("check", "matched")
+	for i := 0; i < 2; i++ {
+		_, err := d.DispatchCheck(context.Background(), &v1.DispatchCheckRequest{ResourceIds: []string{"first"}})
+		require.NoError(t, err)
+	}
+	require.Equal(t, dropped+1, comparisons("check", "dropped"))
+
+	close(blocked)
+	requireCompared(t, "check", "matched", matched)
+}
+
+func TestShadowDetachedFromRequest(t *testing.T) {
+	blocked := make(chan struct{})
+	d, err := NewDispatcher(&fakeDispatcher{members: []string{"first"}}, &fakeDispatcher{members: []string{"first"}, blocked: blocked}, config)
+	require.NoError(t, err)
+
+	// Shadow dispatches are not cancelled along with the requests which sampled them.
+	ctx, cancel := context.WithCancel(context.Background())
+	before := comparisons("check", "matched")
+	_, err = d.DispatchCheck(ctx, &v1.DispatchCheckRequest{ResourceIds: []string{"first"}})
+	require.NoError(t, err)
+	cancel()
+
+	close(blocked)
+	requireCompared(t, "check", "matched", before)
+}
+
+func TestNewDispatcherErrors(t *testing.T) {
+	for _, invalid := range []Config{
+		{SampleRate: 2, Timeout: time.Second, MaxInflight: 1},
+		{SampleRate: 1, MaxInflight: 1},
+		{SampleRate: 1, Timeout: time.Second},
+	} {
+		_, err := NewDispatcher(&fakeDispatcher{}, &fakeDispatcher{}, invalid)
+		require.Error(t, err)
+	}
+}
@@ -0,0 +1,162 @@
+// Package experiments implements a process-wide registry of experiments: risky new behaviors
+// which are gated, so that they can be enabled or disabled per deployment and rolled out
+// gradually.
+package experiments
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Experiment is the name of a gated behavior.
+type Experiment string
+
+const (
+	// CaveatResultCaching caches the results of evaluating caveat expressions under the subset
+	// of the context upon which they depend.
+	CaveatResultCaching Experiment = "caveat-result-caching"
+
+	// DispatchCoalescing shares the result of an in-flight dispatch with identical dispatches
+	// made while it is in flight.
+	DispatchCoalescing Experiment = "dispatch-coalescing"
+)
+
+type definition struct {
+	description      string
+	enabledByDefault bool
+}
+
+// registry holds every known experiment. Experiments which have proven themselves are enabled
+// by default, and can still be disabled until their gate is removed.
+var registry = map[Experiment]definition{
+	CaveatResultCaching: {
+		description:      "cache the results of caveat evaluation by the context upon which they depend",
+		enabledByDefault: true,
+	},
+	DispatchCoalescing: {
+		description:      "share the results of in-flight dispatches with identical dispatches",
+		enabledByDefault: true,
+	},
+}
+
+var enabledGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "spicedb",
+	Subsystem: "experiments",
+	Name:      "enabled",
+	Help:      "Whether each experiment is enabled (1) or disabled (0).",
+}, []string{"experiment"})
+
+func init() {
+	prometheus.MustRegister(enabledGauge)
+	if err := Configure(nil, nil); err != nil {
+		panic(err)
+	}
+}
+
+var current atomic.Pointer[map[Experiment]bool]
+
+// Names returns the names of all known experiments.
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for experiment := range registry {
+		names = append(names, string(experiment))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Configure sets the process-wide experiments: the named experiments are enabled or disabled,
+// and all others are left in their default state. Unknown names are an error.
+func Configure(enable, disable []string) error {
+	enabled := make(map[Experiment]bool, len(registry))
+	for experiment, def := range registry {
+		enabled[experiment] = def.enabledByDefault
+	}
+
+	for _, names := range []struct {
+		names []string
+		value bool
+	}{{enable, true}, {disable, false}} {
+		for _, name := range names.names {
+			experiment := Experiment(name)
+			if _, ok := registry[experiment]; !ok {
+				return fmt.Errorf("unknown experiment `%s`: must be one of %s", name, strings.Join(Names(), ", "))
+			}
+			enabled[experiment] = names.value
+		}
+	}
+
+	for _, name := range enable {
+		for _, disabled := range disable {
+			if name == disabled {
+				return fmt.Errorf("experiment `%s` cannot be both enabled and disabled", name)
+			}
+		}
+	}
+
+	for experiment, value := range enabled {
+		if value {
+			enabledGauge.WithLabelValues(string(experiment)).Set(1)
+		} else {
+			enabledGauge.WithLabelValues(string(experiment)).Set(0)
+		}
+	}
+
+	current.Store(&enabled)
+	return nil
+}
+
+// IsEnabled returns whether the experiment is enabled in this process.
+func IsEnabled(experiment Experiment) bool {
+	return (*current.Load())[experiment]
+}
+
+// Status is the state of an experiment.
+type Status struct {
+	Name             string `json:"name"`
+	Description      string `json:"description"`
+	Enabled          bool   `json:"enabled"`
+	EnabledByDefault bool   `json:"enabled_by_default"`
+}
+
+// Statuses returns the state of all known experiments, sorted by name.
+func Statuses() []Status {
+	statuses := make([]Status, 0, len(registry))
+	for _, name := range Names() {
+		experiment := Experiment(name)
+		statuses = append(statuses, Status{
+			Name:             name,
+			Description:      registry[experiment].description,
+			Enabled:          IsEnabled(experiment),
+			EnabledByDefault: registry[experiment].enabledByDefault,
+		})
+	}
+	return statuses
+}
+
+// Enabled returns the names of the enabled experiments, sorted by name.
+func Enabled() []string {
+	var enabled []string
+	for _, name := range Names() {
+		if IsEnabled(Experiment(name)) {
+			enabled = append(enabled, name)
+		}
+	}
+	return enabled
+}
+
+// Handler returns an HTTP handler reporting the state of all experiments as JSON.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]any{"experiments": Statuses()}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
@@ -0,0 +1,46 @@
+package experiments
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigure(t *testing.T) {
+	t.Cleanup(func() { require.NoError(t, Configure(nil, nil)) })
+
+	require.True(t, IsEnabled(CaveatResultCaching))
+	require.True(t, IsEnabled(DispatchCoalescing))
+
+	require.NoError(t, Configure(nil, []string{string(DispatchCoalescing)}))
+	require.True(t, IsEnabled(CaveatResultCaching))
+	require.False(t, IsEnabled(DispatchCoalescing))
+	require.Equal(t, []string{string(CaveatResultCaching)}, Enabled())
+
+	require.ErrorContains(t, Configure([]string{"lookup-planner"}, nil), "unknown experiment `lookup-planner`")
+	require.ErrorContains(t, Configure([]string{string(DispatchCoalescing)}, []string{string(DispatchCoalescing)}), "both enabled and disabled")
+
+	// A failed configuration leaves the previous configuration in place.
+	require.False(t, IsEnabled(DispatchCoalescing))
+}
+
+func TestHandler(t *testing.T) {
+	t.Cleanup(func() { require.NoError(t, Configure(nil, nil)) })
+	require.NoError(t, Configure(nil, []string{string(CaveatResultCaching)}))
+
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/experiments", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var body struct {
+		Experiments []Status `json:"experiments"`
+	}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+	require.Equal(t, []Status{
+		{Name: string(CaveatResultCaching), Description: registry[CaveatResultCaching].description, Enabled: false, EnabledByDefault: true},
+		{Name: string(DispatchCoalescing), Description: registry[DispatchCoalescing].description, Enabled: true, EnabledByDefault: true},
+	}, body.Experiments)
+}
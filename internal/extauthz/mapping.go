@@ -0,0 +1,161 @@
+package extauthz
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+const defaultJWTPayloadMetadataKey = "jwt_payload"
+
+// MappingFile is the structural representation of the file mapping HTTP requests onto
+// permission checks, such as:
+//
+//	jwt_payload_metadata_key: jwt_payload
+//	rules:
+//	  - methods: [GET]
+//	    path: /documents/{id}
+//	    resource: document:{id}
+//	    permission: view
+//	    subject: user:{claims.sub}
+//
+// The first rule matching a request decides it; requests matching no rule are denied, unless
+// allow_unmatched is set.
+type MappingFile struct {
+	// JWTPayloadMetadataKey is the key under which the Envoy jwt_authn filter places the payload
+	// of the verified JWT in its dynamic metadata, as configured by its `payload_in_metadata`.
+	// Claims are read from the payload. Defaults to `jwt_payload`.
+	JWTPayloadMetadataKey string `yaml:"jwt_payload_metadata_key"`
+
+	// AllowUnmatched, if true, allows requests matching no rule.
+	AllowUnmatched bool `yaml:"allow_unmatched"`
+
+	// Rules are the rules, in the order in which they are matched.
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule maps the requests it matches onto a permission check.
+type Rule struct {
+	// Methods are the HTTP methods matched. If empty, all methods are matched.
+	Methods []string `yaml:"methods"`
+
+	// Path is the template of the paths matched, such as `/documents/{id}`. `{name}` matches a
+	// single segment and a final `{name...}` all remaining segments, each available to the other
+	// templates of the rule as `{name}`.
+	Path string `yaml:"path"`
+
+	// Resource is the template of the resource checked, as `type:id`.
+	Resource string `yaml:"resource"`
+
+	// Permission is the permission checked.
+	Permission string `yaml:"permission"`
+
+	// Subject is the template of the subject checked, as `type:id` or `type:id#relation`.
+	// Headers are available as `{header.name}` and JWT claims as `{claims.name}`.
+	Subject string `yaml:"subject"`
+
+	path     *pathTemplate
+	resource *template
+	subject  *template
+}
+
+// ReadMappingFile reads and compiles the mapping file at the given path.
+func ReadMappingFile(path string) (*MappingFile, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read ext_authz mapping file: %w", err)
+	}
+
+	return DecodeMappingFile(contents)
+}
+
+// DecodeMappingFile decodes and compiles the given contents of a mapping file.
+func DecodeMappingFile(contents []byte) (*MappingFile, error) {
+	mf := MappingFile{}
+	if err := yamlv3.Unmarshal(contents, &mf); err != nil {
+		return nil, fmt.Errorf("unable to decode ext_authz mapping file: %w", err)
+	}
+
+	if len(mf.Rules) == 0 {
+		return nil, fmt.Errorf("ext_authz mapping file defines no rules")
+	}
+
+	if mf.JWTPayloadMetadataKey == "" {
+		mf.JWTPayloadMetadataKey = defaultJWTPayloadMetadataKey
+	}
+
+	for index := range mf.Rules {
+		if err := mf.Rules[index].compile(); err != nil {
+			return nil, fmt.Errorf("invalid ext_authz rule #%d: %w", index+1, err)
+		}
+	}
+
+	return &mf, nil
+}
+
+func (r *Rule) compile() error {
+	if r.Path == "" || r.Resource == "" || r.Permission == "" || r.Subject == "" {
+		return fmt.Errorf("rule must specify path, resource, permission and subject")
+	}
+
+	for index, method := range r.Methods {
+		r.Methods[index] = strings.ToUpper(method)
+	}
+
+	var err error
+	if r.path, err = parsePathTemplate(r.Path); err != nil {
+		return err
+	}
+
+	if r.resource, err = parseTemplate(r.Resource); err != nil {
+		return err
+	}
+
+	if r.subject, err = parseTemplate(r.Subject); err != nil {
+		return err
+	}
+
+	for _, tmpl := range []*template{r.resource, r.subject} {
+		for _, p := range tmpl.placeholders {
+			if p.source != "path" {
+				continue
+			}
+
+			if !r.path.hasParam(p.name) {
+				return fmt.Errorf("`%s` references `{%s}`, which is not a parameter of path `%s`", tmpl.raw, p.name, r.Path)
+			}
+		}
+	}
+
+	return nil
+}
+
+// match returns the parameters of the path if the rule matches the request.
+func (r *Rule) match(method, path string) (map[string]string, bool) {
+	if len(r.Methods) > 0 {
+		found := false
+		for _, allowed := range r.Methods {
+			if allowed == method {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return nil, false
+		}
+	}
+
+	return r.path.match(path)
+}
+
+func (pt *pathTemplate) hasParam(name string) bool {
+	for _, segment := range pt.segments {
+		if segment.param == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+// Package extauthz implements the Envoy external authorization (ext_authz) gRPC service,
+// deciding HTTP requests by mapping them onto CheckPermission requests with the rules of a
+// mapping file.
+package extauthz
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	envoycore "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	envoytype "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	log "github.com/authzed/spicedb/internal/logging"
+)
+
+const jwtAuthnFilterName = "envoy.filters.http.jwt_authn"
+
+type authorizationServer struct {
+	client  v1.PermissionsServiceClient
+	mapping *MappingFile
+}
+
+// RegisterGrpcServices registers the ext_authz service on the given server, checking permissions
+// with the given client as directed by the mapping file.
+func RegisterGrpcServices(srv *grpc.Server, client v1.PermissionsServiceClient, mapping *MappingFile) {
+	authv3.RegisterAuthorizationServer(srv, &authorizationServer{client, mapping})
+}
+
+func (as *authorizationServer) Check(ctx context.Context, req *authv3.CheckRequest) (*authv3.CheckResponse, error) {
+	httpReq := req.GetAttributes().GetRequest().GetHttp()
+	method := strings.ToUpper(httpReq.GetMethod())
+
+	for index := range as.mapping.Rules {
+		rule := &as.mapping.Rules[index]
+		pathParams, ok := rule.match(method, httpReq.GetPath())
+		if !ok {
+			continue
+		}
+
+		attributes := requestAttributes{
+			pathParams: pathParams,
+			headers:    httpReq.GetHeaders(),
+			claims:     as.claims(req.GetAttributes().GetMetadataContext()),
+		}
+		return as.check(ctx, rule, attributes)
+	}
+
+	if as.mapping.AllowUnmatched {
+		return allowed(), nil
+	}
+	return denied(envoytype.StatusCode_Forbidden, "request matches no authorization rule"), nil
+}
+
+// check decides the request matched by the rule by checking the permission of the rule.
+func (as *authorizationServer) check(ctx context.Context, rule *Rule, attributes requestAttributes) (*authv3.CheckResponse, error) {
+	subjectStr, err := rule.subject.render(attributes)
+	if err != nil {
+		return denied(envoytype.StatusCode_Unauthorized, fmt.Sprintf("unable to determine subject: %s", err)), nil
+	}
+
+	resourceStr, err := rule.resource.render(attributes)
+	if err != nil {
+		return denied(envoytype.StatusCode_Forbidden, fmt.Sprintf("unable to determine resource: %s", err)), nil
+	}
+
+	resource, subject, err := parseReferences(resourceStr, subjectStr)
+	if err != nil {
+		return denied(envoytype.StatusCode_Forbidden, err.Error()), nil
+	}
+
+	resp, err := as.client.CheckPermission(ctx, &v1.CheckPermissionRequest{
+		Resource:   resource,
+		Permission: rule.Permission,
+		Subject:    subject,
+	})
+	if err != nil {
+		if status.Code(err) == codes.InvalidArgument {
+			return denied(envoytype.StatusCode_Forbidden, fmt.Sprintf("invalid permission check: %s", status.Convert(err).Message())), nil
+		}
+
+		// Failing the check leaves the request to Envoy's failure mode.
+		log.Ctx(ctx).Warn().Err(err).Str("rule", rule.Path).Msg("unable to check permission for ext_authz request")
+		return nil, err
+	}
+
+	if resp.Permissionship != v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION {
+		return denied(envoytype.StatusCode_Forbidden, fmt.Sprintf("%s does not have permission %s on %s", subjectStr, rule.Permission, resourceStr)), nil
+	}
+	return allowed(), nil
+}
+
+// claims returns the claims of the JWT verified by the Envoy jwt_authn filter, if any.
+func (as *authorizationServer) claims(metadata *envoycore.Metadata) map[string]any {
+	filterMetadata, ok := metadata.GetFilterMetadata()[jwtAuthnFilterName]
+	if !ok {
+		return nil
+	}
+
+	payload, ok := filterMetadata.GetFields()[as.mapping.JWTPayloadMetadataKey]
+	if !ok {
+		return nil
+	}
+	return payload.GetStructValue().AsMap()
+}
+
+func parseReferences(resourceStr, subjectStr string) (*v1.ObjectReference, *v1.SubjectReference, error) {
+	resourceType, resourceID, ok := strings.Cut(resourceStr, ":")
+	if !ok {
+		return nil, nil, fmt.Errorf("resource `%s` must be of the form `type:id`", resourceStr)
+	}
+
+	subjectObject, subjectRelation, _ := strings.Cut(subjectStr, "#")
+	subjectType, subjectID, ok := strings.Cut(subjectObject, ":")
+	if !ok {
+		return nil, nil, fmt.Errorf("subject `%s` must be of the form `type:id` or `type:id#relation`", subjectStr)
+	}
+
+	return &v1.ObjectReference{ObjectType: resourceType, ObjectId: resourceID},
+		&v1.SubjectReference{
+			Object:           &v1.ObjectReference{ObjectType: subjectType, ObjectId: subjectID},
+			OptionalRelation: subjectRelation,
+		}, nil
+}
+
+func allowed() *authv3.CheckResponse {
+	return &authv3.CheckResponse{
+		Status:       &rpcstatus.Status{Code: int32(codes.OK)},
+		HttpResponse: &authv3.CheckResponse_OkResponse{OkResponse: &authv3.OkHttpResponse{}},
+	}
+}
+
+// denied returns a response denying the request with the given HTTP status. The reason is only
+// reported to Envoy, rather than to the client.
+func denied(code envoytype.StatusCode, reason string) *authv3.CheckResponse {
+	body := "permission denied"
+	if code == envoytype.StatusCode_Unauthorized {
+		body = "unauthenticated"
+	}
+
+	return &authv3.CheckResponse{
+		Status: &rpcstatus.Status{Code: int32(codes.PermissionDenied), Message: reason},
+		HttpResponse: &authv3.CheckResponse_DeniedResponse{DeniedResponse: &authv3.DeniedHttpResponse{
+			Status: &envoytype.HttpStatus{Code: code},
+			Body:   body,
+		}},
+	}
+}
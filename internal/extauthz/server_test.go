@@ -0,0 +1,187 @@
+package extauthz
+
+import (
+	"context"
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	envoycore "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	envoytype "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+const testMappingFile = `
+rules:
+  - methods: [get]
+    path: /documents/{id}
+    resource: document:{id}
+    permission: view
+    subject: user:{claims.sub}
+  - methods: [PUT, PATCH]
+    path: /documents/{id}
+    resource: document:{id}
+    permission: edit
+    subject: user:{claims.sub}
+  - path: /orgs/{org}/files/{file...}
+    resource: org:{org}
+    permission: read_files
+    subject: "{header.x-team}"
+`
+
+// fakePermissionsClient grants the permissions listed, as relationships from the resource to the
+// subject, and fails checks for unknown permissions.
+type fakePermissionsClient struct {
+	v1.PermissionsServiceClient
+
+	granted map[string]struct{}
+}
+
+func (c fakePermissionsClient) CheckPermission(_ context.Context, req *v1.CheckPermissionRequest, _ ...grpc.CallOption) (*v1.CheckPermissionResponse, error) {
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	permissionship := v1.CheckPermissionResponse_PERMISSIONSHIP_NO_PERMISSION
+	if _, ok := c.granted[tuple.MustRelString(&v1.Relationship{
+		Resource: req.Resource,
+		Relation: req.Permission,
+		Subject:  req.Subject,
+	})]; ok {
+		permissionship = v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION
+	}
+	return &v1.CheckPermissionResponse{Permissionship: permissionship}, nil
+}
+
+func checkRequest(method, path string, headers map[string]string, claims map[string]any) *authv3.CheckRequest {
+	req := &authv3.CheckRequest{Attributes: &authv3.AttributeContext{
+		Request: &authv3.AttributeContext_Request{Http: &authv3.AttributeContext_HttpRequest{
+			Method:  method,
+			Path:    path,
+			Headers: headers,
+		}},
+	}}
+
+	if claims != nil {
+		payload, err := structpb.NewStruct(claims)
+		if err != nil {
+			panic(err)
+		}
+
+		req.Attributes.MetadataContext = &envoycore.Metadata{FilterMetadata: map[string]*structpb.Struct{
+			jwtAuthnFilterName: {Fields: map[string]*structpb.Value{
+				defaultJWTPayloadMetadataKey: structpb.NewStructValue(payload),
+			}},
+		}}
+	}
+	return req
+}
+
+func TestCheck(t *testing.T) {
+	mapping, err := DecodeMappingFile([]byte(testMappingFile))
+	require.NoError(t, err)
+
+	server := &authorizationServer{
+		client: fakePermissionsClient{granted: map[string]struct{}{
+			"document:readme#view@user:tom":            {},
+			"document:readme#edit@user:sarah":          {},
+			"org:acme#read_files@team:platform#member": {},
+		}},
+		mapping: mapping,
+	}
+
+	tcs := []struct {
+		name           string
+		req            *authv3.CheckRequest
+		expectedStatus envoytype.StatusCode
+	}{
+		{
+			"allowed by claim",
+			checkRequest("GET", "/documents/readme?version=2", nil, map[string]any{"sub": "tom"}),
+			envoytype.StatusCode_OK,
+		},
+		{
+			"denied by claim",
+			checkRequest("GET", "/documents/readme", nil, map[string]any{"sub": "sarah"}),
+			envoytype.StatusCode_Forbidden,
+		},
+		{
+			"second rule by method",
+			checkRequest("PATCH", "/documents/readme", nil, map[string]any{"sub": "sarah"}),
+			envoytype.StatusCode_OK,
+		},
+		{
+			"missing jwt",
+			checkRequest("GET", "/documents/readme", nil, nil),
+			envoytype.StatusCode_Unauthorized,
+		},
+		{
+			"remaining segments and header subject",
+			checkRequest("GET", "/orgs/acme/files/some/nested/file", map[string]string{"x-team": "team:platform#member"}, nil),
+			envoytype.StatusCode_OK,
+		},
+		{
+			"invalid resource id",
+			checkRequest("GET", "/documents/not%20valid", nil, map[string]any{"sub": "tom"}),
+			envoytype.StatusCode_Forbidden,
+		},
+		{
+			"unmatched method",
+			checkRequest("DELETE", "/documents/readme", nil, map[string]any{"sub": "sarah"}),
+			envoytype.StatusCode_Forbidden,
+		},
+		{
+			"unmatched path",
+			checkRequest("GET", "/documents", nil, map[string]any{"sub": "tom"}),
+			envoytype.StatusCode_Forbidden,
+		},
+	}
+
+	for _, tc := range tcs {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := server.Check(context.Background(), tc.req)
+			require.NoError(t, err)
+
+			if tc.expectedStatus == envoytype.StatusCode_OK {
+				require.Equal(t, int32(codes.OK), resp.Status.Code)
+				require.NotNil(t, resp.GetOkResponse())
+				return
+			}
+
+			require.Equal(t, int32(codes.PermissionDenied), resp.Status.Code)
+			require.Equal(t, tc.expectedStatus, resp.GetDeniedResponse().Status.Code)
+		})
+	}
+}
+
+func TestDecodeMappingFile(t *testing.T) {
+	tcs := []struct {
+		name          string
+		contents      string
+		expectedError string
+	}{
+		{"no rules", `rules: []`, "defines no rules"},
+		{"missing permission", "rules:\n  - path: /a\n    resource: a:b\n    subject: user:{claims.sub}", "must specify"},
+		{"relative path", "rules:\n  - path: a/{id}\n    resource: a:{id}\n    permission: view\n    subject: user:{claims.sub}", "must start with `/`"},
+		{"unknown path parameter", "rules:\n  - path: /a/{id}\n    resource: a:{other}\n    permission: view\n    subject: user:{claims.sub}", "not a parameter"},
+		{"unknown source", "rules:\n  - path: /a/{id}\n    resource: a:{id}\n    permission: view\n    subject: user:{cookie.sub}", "unknown attribute source"},
+		{"partial segment", "rules:\n  - path: /a/x{id}\n    resource: a:{id}\n    permission: view\n    subject: user:{claims.sub}", "whole segments"},
+		{"non-final remaining", "rules:\n  - path: /a/{id...}/b\n    resource: a:{id}\n    permission: view\n    subject: user:{claims.sub}", "final segment"},
+		{"unmatched brace", "rules:\n  - path: /a/{id}\n    resource: a:{id\n    permission: view\n    subject: user:{claims.sub}", "unmatched"},
+	}
+
+	for _, tc := range tcs {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := DecodeMappingFile([]byte(tc.contents))
+			require.ErrorContains(t, err, tc.expectedError)
+		})
+	}
+}
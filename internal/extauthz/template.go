@@ -0,0 +1,215 @@
+package extauthz
+
+import (
+	"fmt"
+	"strings"
+)
+
+// requestAttributes are the attributes of an HTTP request referenced by templates.
+type requestAttributes struct {
+	pathParams map[string]string
+	headers    map[string]string
+	claims     map[string]any
+}
+
+// placeholder is a reference to a request attribute within a template, written as `{name}` for a
+// parameter of the path template, `{header.name}` for a header or `{claims.name}` for a JWT claim,
+// with nested claims separated by further dots.
+type placeholder struct {
+	source string
+	name   string
+}
+
+// template is a string with placeholders for request attributes.
+type template struct {
+	raw          string
+	literals     []string
+	placeholders []placeholder
+}
+
+func parseTemplate(raw string) (*template, error) {
+	t := &template{raw: raw}
+	remaining := raw
+	for {
+		start := strings.IndexByte(remaining, '{')
+		if start < 0 {
+			if strings.IndexByte(remaining, '}') >= 0 {
+				return nil, fmt.Errorf("unmatched `}` in `%s`", raw)
+			}
+			t.literals = append(t.literals, remaining)
+			return t, nil
+		}
+
+		end := strings.IndexByte(remaining[start:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("unmatched `{` in `%s`", raw)
+		}
+		end += start
+
+		p, err := parsePlaceholder(remaining[start+1 : end])
+		if err != nil {
+			return nil, fmt.Errorf("invalid placeholder in `%s`: %w", raw, err)
+		}
+
+		t.literals = append(t.literals, remaining[:start])
+		t.placeholders = append(t.placeholders, p)
+		remaining = remaining[end+1:]
+	}
+}
+
+func parsePlaceholder(ref string) (placeholder, error) {
+	source, name, ok := strings.Cut(ref, ".")
+	if !ok {
+		if ref == "" {
+			return placeholder{}, fmt.Errorf("empty placeholder")
+		}
+		return placeholder{source: "path", name: ref}, nil
+	}
+
+	switch source {
+	case "header":
+		return placeholder{source: source, name: strings.ToLower(name)}, nil
+	case "claims":
+		return placeholder{source: source, name: name}, nil
+	default:
+		return placeholder{}, fmt.Errorf("unknown attribute source `%s`; must be `header` or `claims`", source)
+	}
+}
+
+// render fills in the placeholders of the template with the attributes of the request. It fails
+// if any attribute is missing.
+func (t *template) render(attributes requestAttributes) (string, error) {
+	var rendered strings.Builder
+	for index, literal := range t.literals {
+		rendered.WriteString(literal)
+		if index >= len(t.placeholders) {
+			continue
+		}
+
+		value, err := t.placeholders[index].value(attributes)
+		if err != nil {
+			return "", err
+		}
+		rendered.WriteString(value)
+	}
+	return rendered.String(), nil
+}
+
+func (p placeholder) value(attributes requestAttributes) (string, error) {
+	switch p.source {
+	case "path":
+		if value, ok := attributes.pathParams[p.name]; ok {
+			return value, nil
+		}
+		return "", fmt.Errorf("path parameter `%s` not found", p.name)
+
+	case "header":
+		if value, ok := attributes.headers[p.name]; ok && value != "" {
+			return value, nil
+		}
+		return "", fmt.Errorf("header `%s` not found", p.name)
+
+	default:
+		var current any = attributes.claims
+		for _, key := range strings.Split(p.name, ".") {
+			fields, ok := current.(map[string]any)
+			if !ok {
+				return "", fmt.Errorf("claim `%s` not found", p.name)
+			}
+
+			if current, ok = fields[key]; !ok {
+				return "", fmt.Errorf("claim `%s` not found", p.name)
+			}
+		}
+
+		switch value := current.(type) {
+		case string:
+			return value, nil
+		case float64:
+			return fmt.Sprintf("%v", value), nil
+		default:
+			return "", fmt.Errorf("claim `%s` is not a string or number", p.name)
+		}
+	}
+}
+
+// pathTemplate matches request paths, such as `/documents/{id}/comments`, where `{name}` matches
+// a single segment of the path and a final `{name...}` matches all remaining segments.
+type pathTemplate struct {
+	raw      string
+	segments []pathSegment
+}
+
+type pathSegment struct {
+	literal   string
+	param     string
+	remaining bool
+}
+
+func parsePathTemplate(raw string) (*pathTemplate, error) {
+	if !strings.HasPrefix(raw, "/") {
+		return nil, fmt.Errorf("path template `%s` must start with `/`", raw)
+	}
+
+	rawSegments := strings.Split(raw[1:], "/")
+	segments := make([]pathSegment, 0, len(rawSegments))
+	for index, rawSegment := range rawSegments {
+		if !strings.HasPrefix(rawSegment, "{") || !strings.HasSuffix(rawSegment, "}") {
+			if strings.ContainsAny(rawSegment, "{}") {
+				return nil, fmt.Errorf("path template `%s` must have parameters as whole segments", raw)
+			}
+			segments = append(segments, pathSegment{literal: rawSegment})
+			continue
+		}
+
+		name := rawSegment[1 : len(rawSegment)-1]
+		remaining := strings.HasSuffix(name, "...")
+		name = strings.TrimSuffix(name, "...")
+		if name == "" || strings.ContainsAny(name, "{}.") {
+			return nil, fmt.Errorf("path template `%s` has invalid parameter `%s`", raw, rawSegment)
+		}
+
+		if remaining && index != len(rawSegments)-1 {
+			return nil, fmt.Errorf("path template `%s` can only match remaining segments in its final segment", raw)
+		}
+
+		segments = append(segments, pathSegment{param: name, remaining: remaining})
+	}
+
+	return &pathTemplate{raw, segments}, nil
+}
+
+// match returns the parameters of the path if it matches the template.
+func (pt *pathTemplate) match(path string) (map[string]string, bool) {
+	if index := strings.IndexByte(path, '?'); index >= 0 {
+		path = path[:index]
+	}
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+
+	params := make(map[string]string)
+	for index, segment := range pt.segments {
+		if index >= len(segments) {
+			return nil, false
+		}
+
+		switch {
+		case segment.remaining:
+			params[segment.param] = strings.Join(segments[index:], "/")
+			return params, true
+
+		case segment.param != "":
+			if segments[index] == "" {
+				return nil, false
+			}
+			params[segment.param] = segments[index]
+
+		case segment.literal != segments[index]:
+			return nil, false
+		}
+	}
+
+	if len(segments) != len(pt.segments) {
+		return nil, false
+	}
+	return params, true
+}
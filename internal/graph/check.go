@@ -278,9 +278,13 @@ func (cc *ConcurrentChecker) checkDirect(ctx context.Context, crc currentRequest
 		queryCount += 1.0
 
 		// Find the matching subject(s).
-		for tpl := it.Next(); tpl != nil; tpl = it.Next() {
-			if it.Err() != nil {
-				return checkResultError(NewCheckFailureErr(it.Err()), emptyMetadata)
+		for {
+			tpl, err := it.Next()
+			if err != nil {
+				return checkResultError(NewCheckFailureErr(err), emptyMetadata)
+			}
+			if tpl == nil {
+				break
 			}
 
 			// If the subject of the relationship matches the target subject, then we've found
@@ -346,9 +350,13 @@ func (cc *ConcurrentChecker) checkDirect(ctx context.Context, crc currentRequest
 	subjectsToDispatch := tuple.NewONRByTypeSet()
 	relationshipsBySubjectONR := util.NewMultiMap[string, *core.RelationTuple]()
 
-	for tpl := it.Next(); tpl != nil; tpl = it.Next() {
-		if it.Err() != nil {
-			return checkResultError(NewCheckFailureErr(it.Err()), emptyMetadata)
+	for {
+		tpl, err := it.Next()
+		if err != nil {
+			return checkResultError(NewCheckFailureErr(err), emptyMetadata)
+		}
+		if tpl == nil {
+			break
 		}
 
 		// Add the subject as an object over which to dispatch.
@@ -550,9 +558,13 @@ func (cc *ConcurrentChecker) checkTupleToUserset(ctx context.Context, crc curren
 
 	subjectsToDispatch := tuple.NewONRByTypeSet()
 	relationshipsBySubjectONR := util.NewMultiMap[string, *core.RelationTuple]()
-	for tpl := it.Next(); tpl != nil; tpl = it.Next() {
-		if it.Err() != nil {
-			return checkResultError(NewCheckFailureErr(it.Err()), emptyMetadata)
+	for {
+		tpl, err := it.Next()
+		if err != nil {
+			return checkResultError(NewCheckFailureErr(err), emptyMetadata)
+		}
+		if tpl == nil {
+			break
 		}
 
 		subjectsToDispatch.Add(tpl.Subject)
@@ -602,38 +614,29 @@ func union[T any](
 		return noMembers()
 	}
 
-	resultChan := make(chan CheckResult, len(children))
-	childCtx, cancelFn := context.WithCancel(ctx)
-
-	dispatcherCleanup := dispatchAllAsync(childCtx, crc, children, handler, resultChan, concurrencyLimit)
-
-	defer func() {
-		cancelFn()
-		dispatcherCleanup()
-		close(resultChan)
-	}()
+	results := runChildren(ctx, crc, children, handler, concurrencyLimit)
+	defer results.cleanup()
 
 	responseMetadata := emptyMetadata
 	membershipSet := NewMembershipSet()
 
 	for i := 0; i < len(children); i++ {
-		select {
-		case result := <-resultChan:
-			log.Ctx(ctx).Trace().Object("anyResult", result.Resp).Send()
-			responseMetadata = combineResponseMetadata(responseMetadata, result.Resp.Metadata)
-			if result.Err != nil {
-				return checkResultError(result.Err, responseMetadata)
-			}
-
-			membershipSet.UnionWith(result.Resp.ResultsByResourceId)
-			if membershipSet.HasDeterminedMember() && crc.resultsSetting == v1.DispatchCheckRequest_ALLOW_SINGLE_RESULT {
-				return checkResultsForMembership(membershipSet, responseMetadata)
-			}
-
-		case <-ctx.Done():
+		result, ok := results.next(ctx)
+		if !ok {
 			log.Ctx(ctx).Trace().Msg("anyCanceled")
 			return checkResultError(NewRequestCanceledErr(), responseMetadata)
 		}
+
+		log.Ctx(ctx).Trace().Object("anyResult", result.Resp).Send()
+		responseMetadata = combineResponseMetadata(responseMetadata, result.Resp.Metadata)
+		if result.Err != nil {
+			return checkResultError(result.Err, responseMetadata)
+		}
+
+		membershipSet.UnionWith(result.Resp.ResultsByResourceId)
+		if membershipSet.HasDeterminedMember() && crc.resultsSetting == v1.DispatchCheckRequest_ALLOW_SINGLE_RESULT {
+			return checkResultsForMembership(membershipSet, responseMetadata)
+		}
 	}
 
 	return checkResultsForMembership(membershipSet, responseMetadata)
@@ -652,43 +655,36 @@ func all[T any](
 	}
 
 	responseMetadata := emptyMetadata
-	resultChan := make(chan CheckResult, len(children))
-	childCtx, cancelFn := context.WithCancel(ctx)
 
-	cleanupFunc := dispatchAllAsync(childCtx, currentRequestContext{
+	results := runChildren(ctx, currentRequestContext{
 		parentReq:           crc.parentReq,
 		filteredResourceIDs: crc.filteredResourceIDs,
 		resultsSetting:      v1.DispatchCheckRequest_REQUIRE_ALL_RESULTS,
 		maxDispatchCount:    crc.maxDispatchCount,
-	}, children, handler, resultChan, concurrencyLimit)
-
-	defer func() {
-		cancelFn()
-		cleanupFunc()
-		close(resultChan)
-	}()
+	}, children, handler, concurrencyLimit)
+	defer results.cleanup()
 
 	var membershipSet *MembershipSet
 	for i := 0; i < len(children); i++ {
-		select {
-		case result := <-resultChan:
-			responseMetadata = combineResponseMetadata(responseMetadata, result.Resp.Metadata)
-			if result.Err != nil {
-				return checkResultError(result.Err, responseMetadata)
-			}
+		result, ok := results.next(ctx)
+		if !ok {
+			return checkResultError(NewRequestCanceledErr(), responseMetadata)
+		}
 
-			if membershipSet == nil {
-				membershipSet = NewMembershipSet()
-				membershipSet.UnionWith(result.Resp.ResultsByResourceId)
-			} else {
-				membershipSet.IntersectWith(result.Resp.ResultsByResourceId)
-			}
+		responseMetadata = combineResponseMetadata(responseMetadata, result.Resp.Metadata)
+		if result.Err != nil {
+			return checkResultError(result.Err, responseMetadata)
+		}
 
-			if membershipSet.IsEmpty() {
-				return noMembersWithMetadata(responseMetadata)
-			}
-		case <-ctx.Done():
-			return checkResultError(NewRequestCanceledErr(), responseMetadata)
+		if membershipSet == nil {
+			membershipSet = NewMembershipSet()
+			membershipSet.UnionWith(result.Resp.ResultsByResourceId)
+		} else {
+			membershipSet.IntersectWith(result.Resp.ResultsByResourceId)
+		}
+
+		if membershipSet.IsEmpty() {
+			return noMembersWithMetadata(responseMetadata)
 		}
 	}
 
@@ -711,72 +707,51 @@ func difference[T any](
 		return checkResultError(fmt.Errorf("difference requires more than a single child"), emptyMetadata)
 	}
 
-	childCtx, cancelFn := context.WithCancel(ctx)
+	baseResults := runChildren(ctx, crc, children[:1], handler, 1)
+	defer baseResults.cleanup()
 
-	baseChan := make(chan CheckResult, 1)
-	othersChan := make(chan CheckResult, len(children)-1)
-
-	var wg sync.WaitGroup
-	wg.Add(1)
-	go func() {
-		result := handler(childCtx, crc, children[0])
-		baseChan <- result
-		wg.Done()
-	}()
-
-	cleanupFunc := dispatchAllAsync(childCtx, currentRequestContext{
+	othersResults := runChildren(ctx, currentRequestContext{
 		parentReq:           crc.parentReq,
 		filteredResourceIDs: crc.filteredResourceIDs,
 		resultsSetting:      v1.DispatchCheckRequest_REQUIRE_ALL_RESULTS,
 		maxDispatchCount:    crc.maxDispatchCount,
-	}, children[1:], handler, othersChan, concurrencyLimit-1)
-
-	defer func() {
-		cancelFn()
-		cleanupFunc()
-		close(othersChan)
-		wg.Wait()
-		close(baseChan)
-	}()
+	}, children[1:], handler, concurrencyLimit-1)
+	defer othersResults.cleanup()
 
 	responseMetadata := emptyMetadata
 	membershipSet := NewMembershipSet()
 
 	// Wait for the base set to return.
-	select {
-	case base := <-baseChan:
-		responseMetadata = combineResponseMetadata(responseMetadata, base.Resp.Metadata)
-
-		if base.Err != nil {
-			return checkResultError(base.Err, responseMetadata)
-		}
+	base, ok := baseResults.next(ctx)
+	if !ok {
+		return checkResultError(NewRequestCanceledErr(), responseMetadata)
+	}
 
-		membershipSet.UnionWith(base.Resp.ResultsByResourceId)
-		if membershipSet.IsEmpty() {
-			return noMembersWithMetadata(responseMetadata)
-		}
+	responseMetadata = combineResponseMetadata(responseMetadata, base.Resp.Metadata)
+	if base.Err != nil {
+		return checkResultError(base.Err, responseMetadata)
+	}
 
-	case <-ctx.Done():
-		return checkResultError(NewRequestCanceledErr(), responseMetadata)
+	membershipSet.UnionWith(base.Resp.ResultsByResourceId)
+	if membershipSet.IsEmpty() {
+		return noMembersWithMetadata(responseMetadata)
 	}
 
 	// Subtract the remaining sets.
 	for i := 1; i < len(children); i++ {
-		select {
-		case sub := <-othersChan:
-			responseMetadata = combineResponseMetadata(responseMetadata, sub.Resp.Metadata)
-
-			if sub.Err != nil {
-				return checkResultError(sub.Err, responseMetadata)
-			}
+		sub, ok := othersResults.next(ctx)
+		if !ok {
+			return checkResultError(NewRequestCanceledErr(), responseMetadata)
+		}
 
-			membershipSet.Subtract(sub.Resp.ResultsByResourceId)
-			if membershipSet.IsEmpty() {
-				return noMembersWithMetadata(responseMetadata)
-			}
+		responseMetadata = combineResponseMetadata(responseMetadata, sub.Resp.Metadata)
+		if sub.Err != nil {
+			return checkResultError(sub.Err, responseMetadata)
+		}
 
-		case <-ctx.Done():
-			return checkResultError(NewRequestCanceledErr(), responseMetadata)
+		membershipSet.Subtract(sub.Resp.ResultsByResourceId)
+		if membershipSet.IsEmpty() {
+			return noMembersWithMetadata(responseMetadata)
 		}
 	}
 
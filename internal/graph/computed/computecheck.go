@@ -2,13 +2,20 @@ package computed
 
 import (
 	"context"
+	"time"
+
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
 
 	cexpr "github.com/authzed/spicedb/internal/caveats"
 	"github.com/authzed/spicedb/internal/dispatch"
+	"github.com/authzed/spicedb/internal/dispatch/keys"
+	"github.com/authzed/spicedb/internal/experiments"
 	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
 	"github.com/authzed/spicedb/pkg/datastore"
 	core "github.com/authzed/spicedb/pkg/proto/core/v1"
 	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+	"github.com/authzed/spicedb/pkg/redaction"
 )
 
 // DebugOption defines the various debug level options for Checks.
@@ -36,6 +43,16 @@ const (
 	TraceDebuggingEnabled DebugOption = 2
 )
 
+// CaveatResultCache is implemented by check dispatchers which can cache the results of
+// evaluating the caveat expressions found by checks.
+type CaveatResultCache interface {
+	// GetCaveatResult returns the cached result of evaluating a caveat expression, if any.
+	GetCaveatResult(key keys.DispatchCacheKey) (*v1.ResourceCheckResult, bool)
+
+	// SetCaveatResult caches the result of evaluating a caveat expression.
+	SetCaveatResult(key keys.DispatchCacheKey, result *v1.ResourceCheckResult)
+}
+
 // CheckParameters are the parameters for the ComputeCheck call. *All* are required.
 type CheckParameters struct {
 	ResourceType  *core.RelationReference
@@ -104,18 +121,38 @@ func computeCheck(ctx context.Context,
 		return nil, checkResult.Metadata, err
 	}
 
+	// When debugging, each evaluation of a caveat expression is recorded in the debug information.
+	// The response may be shared with coalesced callers, so they are recorded on a copy of its
+	// metadata.
+	meta := checkResult.Metadata
+	var evaluations *[]*v1.CaveatEvaluation
+	if params.DebugOption != NoDebugging {
+		meta = meta.CloneVT()
+		if meta.DebugInfo == nil {
+			meta.DebugInfo = &v1.DebugInformation{}
+		}
+		evaluations = &meta.DebugInfo.CaveatEvaluations
+	}
+
 	results := make(map[string]*v1.ResourceCheckResult, len(resourceIDs))
 	for _, resourceID := range resourceIDs {
-		computed, err := computeCaveatedCheckResult(ctx, params, resourceID, checkResult)
+		computed, err := computeCaveatedCheckResult(ctx, d, params, resourceID, checkResult, evaluations)
 		if err != nil {
-			return nil, checkResult.Metadata, err
+			return nil, meta, err
 		}
 		results[resourceID] = computed
 	}
-	return results, checkResult.Metadata, nil
+	return results, meta, nil
 }
 
-func computeCaveatedCheckResult(ctx context.Context, params CheckParameters, resourceID string, checkResult *v1.DispatchCheckResponse) (*v1.ResourceCheckResult, error) {
+func computeCaveatedCheckResult(
+	ctx context.Context,
+	d dispatch.Check,
+	params CheckParameters,
+	resourceID string,
+	checkResult *v1.DispatchCheckResponse,
+	evaluations *[]*v1.CaveatEvaluation,
+) (*v1.ResourceCheckResult, error) {
 	result, ok := checkResult.ResultsByResourceId[resourceID]
 	if !ok {
 		return &v1.ResourceCheckResult{
@@ -130,26 +167,127 @@ func computeCaveatedCheckResult(ctx context.Context, params CheckParameters, res
 	ds := datastoremw.MustFromContext(ctx)
 	reader := ds.SnapshotReader(params.AtRevision)
 
-	caveatResult, err := cexpr.RunCaveatExpression(ctx, result.Expression, params.CaveatContext, reader, cexpr.RunCaveatExpressionNoDebugging)
+	// If the dispatcher supports it, cache the evaluated result under the subset of the context
+	// upon which the caveats depend, so that checks supplying the same values for those
+	// parameters can skip evaluation.
+	resultCache, ok := d.(CaveatResultCache)
+	if !ok || !experiments.IsEnabled(experiments.CaveatResultCaching) {
+		return runCaveatExpression(ctx, params, resourceID, result.Expression, reader, evaluations)
+	}
+
+	parameterNames, timeDependent, err := cexpr.ExpressionDependencies(ctx, result.Expression, reader)
 	if err != nil {
 		return nil, err
 	}
 
-	if caveatResult.IsPartial() {
+	// The results of caveats calling now() change over time, even at the same revision.
+	if timeDependent {
+		return runCaveatExpression(ctx, params, resourceID, result.Expression, reader, evaluations)
+	}
+
+	cacheKey, err := keys.CaveatResultKey(params.AtRevision.String(), result.Expression, params.CaveatContext, parameterNames)
+	if err != nil {
+		return nil, err
+	}
+
+	if cached, found := resultCache.GetCaveatResult(cacheKey); found {
+		if evaluations != nil {
+			*evaluations = append(*evaluations, &v1.CaveatEvaluation{
+				ResourceId:           resourceID,
+				CaveatName:           result.Expression.GetCaveat().GetCaveatName(),
+				Result:               evaluationResult(cached),
+				MissingContextFields: cached.MissingExprFields,
+				WasCachedResult:      true,
+			})
+		}
+		return cached, nil
+	}
+
+	computed, err := runCaveatExpression(ctx, params, resourceID, result.Expression, reader, evaluations)
+	if err != nil {
+		return nil, err
+	}
+
+	resultCache.SetCaveatResult(cacheKey, computed)
+	return computed, nil
+}
+
+// runCaveatExpression evaluates the caveat expression found for the resource, recording the
+// evaluation if evaluations is non-nil.
+func runCaveatExpression(
+	ctx context.Context,
+	params CheckParameters,
+	resourceID string,
+	expr *core.CaveatExpression,
+	reader datastore.CaveatReader,
+	evaluations *[]*v1.CaveatEvaluation,
+) (*v1.ResourceCheckResult, error) {
+	debugOption := cexpr.RunCaveatExpressionNoDebugging
+	if evaluations != nil {
+		debugOption = cexpr.RunCaveatExpressionWithDebugInformation
+	}
+
+	start := time.Now()
+	caveatResult, err := cexpr.RunCaveatExpression(ctx, expr, params.CaveatContext, reader, debugOption)
+	if err != nil {
+		return nil, err
+	}
+	duration := time.Since(start)
+
+	var computed *v1.ResourceCheckResult
+	switch {
+	case caveatResult.IsPartial():
 		missingFields, _ := caveatResult.MissingVarNames()
-		return &v1.ResourceCheckResult{
+		computed = &v1.ResourceCheckResult{
 			Membership:        v1.ResourceCheckResult_CAVEATED_MEMBER,
 			MissingExprFields: missingFields,
-		}, nil
-	}
+		}
 
-	if caveatResult.Value() {
-		return &v1.ResourceCheckResult{
+	case caveatResult.Value():
+		computed = &v1.ResourceCheckResult{
 			Membership: v1.ResourceCheckResult_MEMBER,
-		}, nil
+		}
+
+	default:
+		computed = &v1.ResourceCheckResult{
+			Membership: v1.ResourceCheckResult_NOT_MEMBER,
+		}
+	}
+
+	if evaluations != nil {
+		exprString, err := caveatResult.ExpressionString()
+		if err != nil {
+			return nil, err
+		}
+
+		// Not all context values can be serialized, such as IP addresses, in which case the
+		// evaluation is recorded without its context rather than failing the check.
+		var redactedContext *structpb.Struct
+		if contextStruct, err := caveatResult.ContextStruct(); err == nil {
+			redactedContext = redaction.CaveatContext(contextStruct)
+		}
+
+		*evaluations = append(*evaluations, &v1.CaveatEvaluation{
+			ResourceId:           resourceID,
+			CaveatName:           expr.GetCaveat().GetCaveatName(),
+			Expression:           exprString,
+			Context:              redactedContext,
+			Result:               evaluationResult(computed),
+			MissingContextFields: computed.MissingExprFields,
+			Duration:             durationpb.New(duration),
+		})
 	}
 
-	return &v1.ResourceCheckResult{
-		Membership: v1.ResourceCheckResult_NOT_MEMBER,
-	}, nil
+	return computed, nil
+}
+
+func evaluationResult(result *v1.ResourceCheckResult) v1.CaveatEvaluation_Result {
+	switch result.Membership {
+	case v1.ResourceCheckResult_MEMBER:
+		return v1.CaveatEvaluation_TRUE
+	case v1.ResourceCheckResult_CAVEATED_MEMBER:
+		return v1.CaveatEvaluation_MISSING_SOME_CONTEXT
+	default:
+		return v1.CaveatEvaluation_FALSE
+	}
 }
@@ -3,11 +3,14 @@ package computed_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"google.golang.org/protobuf/types/known/structpb"
 
 	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/internal/dispatch"
 	"github.com/authzed/spicedb/internal/dispatch/graph"
+	"github.com/authzed/spicedb/internal/dispatch/keys"
 	"github.com/authzed/spicedb/internal/graph/computed"
 	log "github.com/authzed/spicedb/internal/logging"
 	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
@@ -930,6 +933,202 @@ func TestComputeBulkCheck(t *testing.T) {
 	require.Equal(t, resp["third"].Membership, v1.ResourceCheckResult_NOT_MEMBER)
 }
 
+func TestComputeCheckRecordsCaveatEvaluations(t *testing.T) {
+	ds, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	require.NoError(t, err)
+
+	dispatch := graph.NewLocalOnlyDispatcher(10)
+	ctx := log.Logger.WithContext(datastoremw.ContextWithHandle(context.Background()))
+	require.NoError(t, datastoremw.SetInContext(ctx, ds))
+
+	revision, err := writeCaveatedTuples(ctx, t, ds, `
+	definition user {}
+
+	caveat somecaveat(somecondition int, anothercondition int) {
+		somecondition == 42 && anothercondition == 42
+	}
+
+	definition document {
+		relation viewer: user | user with somecaveat
+		permission view = viewer
+	}
+	`, []caveatedUpdate{
+		{core.RelationTupleUpdate_CREATE, "document:direct#viewer@user:tom", "", nil},
+		{core.RelationTupleUpdate_CREATE, "document:first#viewer@user:tom", "somecaveat", map[string]any{
+			"somecondition": 42,
+		}},
+		{core.RelationTupleUpdate_CREATE, "document:second#viewer@user:tom", "somecaveat", map[string]any{}},
+		{core.RelationTupleUpdate_CREATE, "document:third#viewer@user:tom", "somecaveat", map[string]any{
+			"somecondition": 32,
+		}},
+	})
+	require.NoError(t, err)
+
+	check := func(debugOption computed.DebugOption) *v1.ResponseMeta {
+		_, meta, err := computed.ComputeBulkCheck(ctx, dispatch,
+			computed.CheckParameters{
+				ResourceType:  &core.RelationReference{Namespace: "document", Relation: "view"},
+				Subject:       &core.ObjectAndRelation{Namespace: "user", ObjectId: "tom", Relation: "..."},
+				CaveatContext: map[string]any{"anothercondition": "42"},
+				AtRevision:    revision,
+				MaximumDepth:  50,
+				DebugOption:   debugOption,
+			},
+			[]string{"direct", "first", "second", "third"},
+		)
+		require.NoError(t, err)
+		return meta
+	}
+
+	require.Empty(t, check(computed.NoDebugging).GetDebugInfo().GetCaveatEvaluations())
+
+	evaluations := check(computed.BasicDebuggingEnabled).DebugInfo.CaveatEvaluations
+	require.Len(t, evaluations, 3)
+
+	expected := []struct {
+		resourceID    string
+		result        v1.CaveatEvaluation_Result
+		missingFields []string
+	}{
+		{"first", v1.CaveatEvaluation_TRUE, nil},
+		{"second", v1.CaveatEvaluation_MISSING_SOME_CONTEXT, []string{"somecondition"}},
+		{"third", v1.CaveatEvaluation_FALSE, nil},
+	}
+	for index, evaluation := range evaluations {
+		require.Equal(t, expected[index].resourceID, evaluation.ResourceId)
+		require.Equal(t, "somecaveat", evaluation.CaveatName)
+		require.NotEmpty(t, evaluation.Expression)
+		require.Equal(t, expected[index].result, evaluation.Result)
+		require.Equal(t, expected[index].missingFields, evaluation.MissingContextFields)
+		require.NotNil(t, evaluation.Duration)
+		require.False(t, evaluation.WasCachedResult)
+		require.Contains(t, evaluation.Context.Fields, "anothercondition")
+	}
+	require.Equal(t, float64(42), evaluations[0].Context.Fields["somecondition"].GetNumberValue())
+}
+
+type recordingCaveatResultCache struct {
+	dispatch.Dispatcher
+	results map[keys.DispatchCacheKey]*v1.ResourceCheckResult
+	hits    int
+}
+
+func (rc *recordingCaveatResultCache) GetCaveatResult(key keys.DispatchCacheKey) (*v1.ResourceCheckResult, bool) {
+	result, ok := rc.results[key]
+	if ok {
+		rc.hits++
+	}
+	return result, ok
+}
+
+func (rc *recordingCaveatResultCache) SetCaveatResult(key keys.DispatchCacheKey, result *v1.ResourceCheckResult) {
+	rc.results[key] = result
+}
+
+func TestComputeCheckCachesCaveatResults(t *testing.T) {
+	ds, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	require.NoError(t, err)
+
+	dispatcher := &recordingCaveatResultCache{
+		Dispatcher: graph.NewLocalOnlyDispatcher(10),
+		results:    map[keys.DispatchCacheKey]*v1.ResourceCheckResult{},
+	}
+	ctx := log.Logger.WithContext(datastoremw.ContextWithHandle(context.Background()))
+	require.NoError(t, datastoremw.SetInContext(ctx, ds))
+
+	revision, err := writeCaveatedTuples(ctx, t, ds, `
+	definition user {}
+
+	caveat somecaveat(somecondition int) {
+		somecondition == 42
+	}
+
+	definition document {
+		relation viewer: user | user with somecaveat
+		permission view = viewer
+	}
+	`, []caveatedUpdate{
+		{core.RelationTupleUpdate_CREATE, "document:first#viewer@user:tom", "somecaveat", map[string]any{}},
+	})
+	require.NoError(t, err)
+
+	check := func(caveatContext map[string]any) v1.ResourceCheckResult_Membership {
+		result, _, err := computed.ComputeCheck(ctx, dispatcher,
+			computed.CheckParameters{
+				ResourceType:  &core.RelationReference{Namespace: "document", Relation: "view"},
+				Subject:       &core.ObjectAndRelation{Namespace: "user", ObjectId: "tom", Relation: "..."},
+				CaveatContext: caveatContext,
+				AtRevision:    revision,
+				MaximumDepth:  50,
+				DebugOption:   computed.NoDebugging,
+			},
+			"first",
+		)
+		require.NoError(t, err)
+		return result.Membership
+	}
+
+	require.Equal(t, v1.ResourceCheckResult_MEMBER, check(map[string]any{"somecondition": "42", "unrelated": "a"}))
+	require.Equal(t, 0, dispatcher.hits)
+
+	// Values for parameters not referenced by the caveat do not affect the cache key.
+	require.Equal(t, v1.ResourceCheckResult_MEMBER, check(map[string]any{"somecondition": "42", "unrelated": "b"}))
+	require.Equal(t, 1, dispatcher.hits)
+
+	require.Equal(t, v1.ResourceCheckResult_NOT_MEMBER, check(map[string]any{"somecondition": "41"}))
+	require.Equal(t, v1.ResourceCheckResult_CAVEATED_MEMBER, check(nil))
+	require.Equal(t, 1, dispatcher.hits)
+	require.Len(t, dispatcher.results, 3)
+}
+
+func TestComputeCheckDoesNotCacheTimeDependentCaveatResults(t *testing.T) {
+	ds, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	require.NoError(t, err)
+
+	dispatcher := &recordingCaveatResultCache{
+		Dispatcher: graph.NewLocalOnlyDispatcher(10),
+		results:    map[keys.DispatchCacheKey]*v1.ResourceCheckResult{},
+	}
+	ctx := log.Logger.WithContext(datastoremw.ContextWithHandle(context.Background()))
+	require.NoError(t, datastoremw.SetInContext(ctx, ds))
+
+	revision, err := writeCaveatedTuples(ctx, t, ds, `
+	definition user {}
+
+	caveat unexpired(expiration timestamp) {
+		now() < expiration
+	}
+
+	definition document {
+		relation viewer: user with unexpired
+		permission view = viewer
+	}
+	`, []caveatedUpdate{
+		{core.RelationTupleUpdate_CREATE, "document:first#viewer@user:tom", "unexpired", map[string]any{}},
+	})
+	require.NoError(t, err)
+
+	// The result of a caveat calling now() changes over time at the same revision, and so is
+	// never cached.
+	for i := 0; i < 2; i++ {
+		result, _, err := computed.ComputeCheck(ctx, dispatcher,
+			computed.CheckParameters{
+				ResourceType:  &core.RelationReference{Namespace: "document", Relation: "view"},
+				Subject:       &core.ObjectAndRelation{Namespace: "user", ObjectId: "tom", Relation: "..."},
+				CaveatContext: map[string]any{"expiration": time.Now().Add(time.Hour).Format(time.RFC3339)},
+				AtRevision:    revision,
+				MaximumDepth:  50,
+				DebugOption:   computed.NoDebugging,
+			},
+			"first",
+		)
+		require.NoError(t, err)
+		require.Equal(t, v1.ResourceCheckResult_MEMBER, result.Membership)
+	}
+	require.Zero(t, dispatcher.hits)
+	require.Empty(t, dispatcher.results)
+}
+
 func writeCaveatedTuples(ctx context.Context, t *testing.T, ds datastore.Datastore, schema string, updates []caveatedUpdate) (datastore.Revision, error) {
 	empty := ""
 	compiled, err := compiler.Compile(compiler.InputSchema{
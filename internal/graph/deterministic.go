@@ -0,0 +1,176 @@
+package graph
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// DeterministicScheduler replaces the concurrent evaluation of the children of reducers with
+// their sequential evaluation, in an order chosen by a seeded source of randomness. Each seed
+// thus explores a single interleaving of the children, reproducibly, which allows for bugs that
+// depend upon the order in which results arrive to be found and replayed in tests.
+//
+// NOTE: this is only intended for use in tests, as it removes all parallelism.
+type DeterministicScheduler struct {
+	sync.Mutex
+	rnd *rand.Rand
+}
+
+type deterministicSchedulerKey struct{}
+
+// ContextWithDeterministicScheduling returns a context under which reducers evaluate their
+// children sequentially, in an order determined by the given seed.
+func ContextWithDeterministicScheduling(ctx context.Context, seed int64) context.Context {
+	return context.WithValue(ctx, deterministicSchedulerKey{}, &DeterministicScheduler{
+		rnd: rand.New(rand.NewSource(seed)),
+	})
+}
+
+func deterministicSchedulerFromContext(ctx context.Context) *DeterministicScheduler {
+	scheduler, _ := ctx.Value(deterministicSchedulerKey{}).(*DeterministicScheduler)
+	return scheduler
+}
+
+// order returns the order in which to evaluate n children.
+func (s *DeterministicScheduler) order(n int) []int {
+	s.Lock()
+	defer s.Unlock()
+	return s.rnd.Perm(n)
+}
+
+// checkResults yields the results of the children of a check reducer.
+type checkResults interface {
+	// next returns the result of another child, or false if the context was canceled first.
+	next(ctx context.Context) (CheckResult, bool)
+
+	// cleanup cancels any children still running and waits for them to stop.
+	cleanup()
+}
+
+// runChildren starts the evaluation of the children of a check reducer, concurrently unless
+// deterministic scheduling is enabled on the context.
+func runChildren[T any](
+	ctx context.Context,
+	crc currentRequestContext,
+	children []T,
+	handler func(ctx context.Context, crc currentRequestContext, child T) CheckResult,
+	concurrencyLimit uint16,
+) checkResults {
+	childCtx, cancelFn := context.WithCancel(ctx)
+
+	if scheduler := deterministicSchedulerFromContext(ctx); scheduler != nil {
+		return &sequentialCheckResults[T]{
+			ctx:      childCtx,
+			cancel:   cancelFn,
+			crc:      crc,
+			children: children,
+			handler:  handler,
+			order:    scheduler.order(len(children)),
+		}
+	}
+
+	resultChan := make(chan CheckResult, len(children))
+	dispatcherCleanup := dispatchAllAsync(childCtx, crc, children, handler, resultChan, concurrencyLimit)
+	return &asyncCheckResults{
+		resultChan:        resultChan,
+		cancel:            cancelFn,
+		dispatcherCleanup: dispatcherCleanup,
+	}
+}
+
+type asyncCheckResults struct {
+	resultChan        chan CheckResult
+	cancel            func()
+	dispatcherCleanup func()
+}
+
+func (acr *asyncCheckResults) next(ctx context.Context) (CheckResult, bool) {
+	select {
+	case result := <-acr.resultChan:
+		return result, true
+	case <-ctx.Done():
+		return CheckResult{}, false
+	}
+}
+
+func (acr *asyncCheckResults) cleanup() {
+	acr.cancel()
+	acr.dispatcherCleanup()
+	close(acr.resultChan)
+}
+
+// sequentialCheckResults evaluates each child only when its result is requested, so children
+// after a short-circuiting result are never evaluated, as if they had been canceled.
+type sequentialCheckResults[T any] struct {
+	ctx      context.Context
+	cancel   func()
+	crc      currentRequestContext
+	children []T
+	handler  func(ctx context.Context, crc currentRequestContext, child T) CheckResult
+	order    []int
+	position int
+}
+
+func (scr *sequentialCheckResults[T]) next(ctx context.Context) (CheckResult, bool) {
+	if ctx.Err() != nil || scr.position >= len(scr.order) {
+		return CheckResult{}, false
+	}
+
+	child := scr.children[scr.order[scr.position]]
+	scr.position++
+	return scr.handler(scr.ctx, scr.crc, child), true
+}
+
+func (scr *sequentialCheckResults[T]) cleanup() {
+	scr.cancel()
+}
+
+// taskGroup runs a group of tasks, returning the first error encountered.
+type taskGroup interface {
+	Go(f func() error)
+	Wait() error
+}
+
+// newTaskGroup returns a group running its tasks concurrently, up to the given limit, unless
+// deterministic scheduling is enabled on the context. The returned context is canceled when any
+// task fails.
+func newTaskGroup(ctx context.Context, concurrencyLimit uint16) (taskGroup, context.Context) {
+	if scheduler := deterministicSchedulerFromContext(ctx); scheduler != nil {
+		subCtx, cancel := context.WithCancel(ctx)
+		return &sequentialTaskGroup{scheduler: scheduler, ctx: subCtx, cancel: cancel}, subCtx
+	}
+
+	g, subCtx := errgroup.WithContext(ctx)
+	g.SetLimit(int(concurrencyLimit))
+	return g, subCtx
+}
+
+// sequentialTaskGroup runs its tasks one at a time when waited upon, in an order determined
+// by its scheduler.
+type sequentialTaskGroup struct {
+	scheduler *DeterministicScheduler
+	ctx       context.Context
+	cancel    func()
+	tasks     []func() error
+}
+
+func (stg *sequentialTaskGroup) Go(f func() error) {
+	stg.tasks = append(stg.tasks, f)
+}
+
+func (stg *sequentialTaskGroup) Wait() error {
+	defer stg.cancel()
+
+	for _, index := range stg.scheduler.order(len(stg.tasks)) {
+		if err := stg.ctx.Err(); err != nil {
+			return err
+		}
+		if err := stg.tasks[index](); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+)
+
+func recordingHandler(order *[]int, members map[int]bool) func(ctx context.Context, crc currentRequestContext, child int) CheckResult {
+	return func(ctx context.Context, crc currentRequestContext, child int) CheckResult {
+		*order = append(*order, child)
+		if !members[child] {
+			return noMembers()
+		}
+
+		membershipSet := NewMembershipSet()
+		membershipSet.AddDirectMember("someresource", nil)
+		return checkResultsForMembership(membershipSet, emptyMetadata)
+	}
+}
+
+func TestDeterministicSchedulingIsReproducible(t *testing.T) {
+	children := []int{0, 1, 2, 3, 4, 5, 6, 7}
+
+	orderForSeed := func(seed int64) []int {
+		var order []int
+		ctx := ContextWithDeterministicScheduling(context.Background(), seed)
+		result := union(ctx, currentRequestContext{resultsSetting: v1.DispatchCheckRequest_REQUIRE_ALL_RESULTS}, children, recordingHandler(&order, nil), 3)
+		require.NoError(t, result.Err)
+		return order
+	}
+
+	first := orderForSeed(42)
+	require.ElementsMatch(t, children, first)
+	require.Equal(t, first, orderForSeed(42))
+
+	// Some other seed must explore a different interleaving.
+	explored := false
+	for seed := int64(0); seed < 10; seed++ {
+		if !equalOrders(first, orderForSeed(seed)) {
+			explored = true
+			break
+		}
+	}
+	require.True(t, explored)
+}
+
+func equalOrders(lhs, rhs []int) bool {
+	if len(lhs) != len(rhs) {
+		return false
+	}
+	for i := range lhs {
+		if lhs[i] != rhs[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDeterministicSchedulingShortCircuits(t *testing.T) {
+	children := []int{0, 1, 2, 3}
+	crc := currentRequestContext{resultsSetting: v1.DispatchCheckRequest_ALLOW_SINGLE_RESULT}
+
+	for seed := int64(0); seed < 20; seed++ {
+		ctx := ContextWithDeterministicScheduling(context.Background(), seed)
+
+		// The union stops at the only member, wherever it is ordered.
+		var order []int
+		result := union(ctx, crc, children, recordingHandler(&order, map[int]bool{2: true}), 4)
+		require.NoError(t, result.Err)
+		require.Contains(t, result.Resp.ResultsByResourceId, "someresource")
+		require.Equal(t, 2, order[len(order)-1])
+
+		// The intersection stops at the first non-member.
+		order = nil
+		result = all(ctx, crc, children, recordingHandler(&order, map[int]bool{0: true, 1: true, 3: true}), 4)
+		require.NoError(t, result.Err)
+		require.Empty(t, result.Resp.ResultsByResourceId)
+		require.Equal(t, 2, order[len(order)-1])
+
+		// The exclusion always evaluates its base first.
+		order = nil
+		result = difference(ctx, crc, children, recordingHandler(&order, map[int]bool{0: true}), 4)
+		require.NoError(t, result.Err)
+		require.Contains(t, result.Resp.ResultsByResourceId, "someresource")
+		require.Equal(t, 0, order[0])
+		require.ElementsMatch(t, children, order)
+	}
+}
+
+func TestSequentialTaskGroup(t *testing.T) {
+	ctx := ContextWithDeterministicScheduling(context.Background(), 7)
+
+	g, subCtx := newTaskGroup(ctx, 10)
+	var ran []int
+	for i := 0; i < 5; i++ {
+		i := i
+		g.Go(func() error {
+			ran = append(ran, i)
+			if i == 3 {
+				return errors.New("failed")
+			}
+			return nil
+		})
+	}
+
+	require.Empty(t, ran, "tasks must only run when waited upon")
+	require.EqualError(t, g.Wait(), "failed")
+	require.Equal(t, 3, ran[len(ran)-1])
+	require.Error(t, subCtx.Err())
+}
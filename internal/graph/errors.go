@@ -3,10 +3,14 @@ package graph
 import (
 	"errors"
 	"fmt"
+	"strconv"
 
 	"github.com/rs/zerolog"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/authzed/spicedb/internal/sharederrors"
+	"github.com/authzed/spicedb/pkg/spiceerrors"
 )
 
 // ErrRequestCanceled occurs when a request has been canceled.
@@ -162,3 +166,44 @@ func NewUnimplementedErr(baseErr error) error {
 		error: baseErr,
 	}
 }
+
+// ErrResultTooLarge occurs when the result of an expand or lookup would exceed the maximum
+// result size, and so is not materialized.
+type ErrResultTooLarge struct {
+	error
+	maximumSize uint64
+}
+
+// MaximumSize returns the maximum result size exceeded.
+func (err ErrResultTooLarge) MaximumSize() uint64 {
+	return err.maximumSize
+}
+
+func (err ErrResultTooLarge) MarshalZerologObject(e *zerolog.Event) {
+	e.Err(err.error).Uint64("maximumSize", err.maximumSize)
+}
+
+// GRPCStatus implements retrieving the gRPC status for the error.
+func (err ErrResultTooLarge) GRPCStatus() *status.Status {
+	return spiceerrors.WithCodeAndDetails(
+		err,
+		codes.ResourceExhausted,
+		spiceerrors.ForReasonName(
+			spiceerrors.ReasonResultTooLarge,
+			map[string]string{
+				"maximum_result_size": strconv.FormatUint(err.maximumSize, 10),
+			},
+		),
+	)
+}
+
+// NewResultTooLargeErr constructs a new result too large error.
+func NewResultTooLargeErr(operation string, maximumSize uint64) error {
+	return ErrResultTooLarge{
+		error: fmt.Errorf(
+			"result of %s exceeds the maximum of %d results; narrow the request, or page through the relationships with ReadRelationships instead",
+			operation, maximumSize,
+		),
+		maximumSize: maximumSize,
+	}
+}
@@ -48,9 +48,35 @@ func (ce *ConcurrentExpander) Expand(ctx context.Context, req ValidatedExpandReq
 
 	resolved := expandOne(ctx, directFunc)
 	resolved.Resp.Metadata = addCallToResponseMetadata(resolved.Resp.Metadata)
+	if resolved.Err == nil && req.MaximumResultSize > 0 && ExpansionTreeSize(resolved.Resp.TreeNode) > req.MaximumResultSize {
+		return resolved.Resp, NewResultTooLargeErr("expand", req.MaximumResultSize)
+	}
 	return resolved.Resp, resolved.Err
 }
 
+// ExpansionTreeSize returns the number of intermediate nodes and subjects in the expansion tree.
+func ExpansionTreeSize(node *core.RelationTupleTreeNode) uint64 {
+	if node == nil {
+		return 0
+	}
+
+	if leaf := node.GetLeafNode(); leaf != nil {
+		return uint64(len(leaf.Subjects))
+	}
+
+	size := uint64(1)
+	for _, child := range node.GetIntermediateNode().GetChildNodes() {
+		size += ExpansionTreeSize(child)
+	}
+	return size
+}
+
+// exceedsResultSize returns whether the count of results found exceeds the maximum result size
+// of the request, if any.
+func exceedsResultSize(req ValidatedExpandRequest, count int) bool {
+	return req.MaximumResultSize > 0 && uint64(count) > req.MaximumResultSize
+}
+
 func (ce *ConcurrentExpander) expandDirect(
 	ctx context.Context,
 	req ValidatedExpandRequest,
@@ -71,11 +97,15 @@ func (ce *ConcurrentExpander) expandDirect(
 
 		var foundNonTerminalUsersets []*core.DirectSubject
 		var foundTerminalUsersets []*core.DirectSubject
-		for tpl := it.Next(); tpl != nil; tpl = it.Next() {
-			if it.Err() != nil {
-				resultChan <- expandResultError(NewExpansionFailureErr(it.Err()), emptyMetadata)
+		for {
+			tpl, err := it.Next()
+			if err != nil {
+				resultChan <- expandResultError(NewExpansionFailureErr(err), emptyMetadata)
 				return
 			}
+			if tpl == nil {
+				break
+			}
 
 			ds := &core.DirectSubject{
 				Subject:          tpl.Subject,
@@ -86,6 +116,11 @@ func (ce *ConcurrentExpander) expandDirect(
 			} else {
 				foundNonTerminalUsersets = append(foundNonTerminalUsersets, ds)
 			}
+
+			if exceedsResultSize(req, len(foundTerminalUsersets)+len(foundNonTerminalUsersets)) {
+				resultChan <- expandResultError(NewResultTooLargeErr("expand", req.MaximumResultSize), emptyMetadata)
+				return
+			}
 		}
 		it.Close()
 
@@ -115,6 +150,7 @@ func (ce *ConcurrentExpander) expandDirect(
 					ResourceAndRelation: nonTerminalUser.Subject,
 					Metadata:            decrementDepth(req.Metadata),
 					ExpansionMode:       req.ExpansionMode,
+					MaximumResultSize:   req.MaximumResultSize,
 				},
 				req.Revision,
 			})
@@ -246,8 +282,9 @@ func (ce *ConcurrentExpander) expandComputedUserset(ctx context.Context, req Val
 				ObjectId:  start.ObjectId,
 				Relation:  cu.Relation,
 			},
-			Metadata:      decrementDepth(req.Metadata),
-			ExpansionMode: req.ExpansionMode,
+			Metadata:          decrementDepth(req.Metadata),
+			ExpansionMode:     req.ExpansionMode,
+			MaximumResultSize: req.MaximumResultSize,
 		},
 		req.Revision,
 	})
@@ -268,14 +305,23 @@ func (ce *ConcurrentExpander) expandTupleToUserset(ctx context.Context, req Vali
 		defer it.Close()
 
 		var requestsToDispatch []ReduceableExpandFunc
-		for tpl := it.Next(); tpl != nil; tpl = it.Next() {
-			if it.Err() != nil {
-				resultChan <- expandResultError(NewExpansionFailureErr(it.Err()), emptyMetadata)
+		for {
+			tpl, err := it.Next()
+			if err != nil {
+				resultChan <- expandResultError(NewExpansionFailureErr(err), emptyMetadata)
 				return
 			}
+			if tpl == nil {
+				break
+			}
 
 			toDispatch := ce.expandComputedUserset(ctx, req, ttu.ComputedUserset, tpl)
 			requestsToDispatch = append(requestsToDispatch, decorateWithCaveatIfNecessary(toDispatch, caveats.CaveatAsExpr(tpl.Caveat)))
+
+			if exceedsResultSize(req, len(requestsToDispatch)) {
+				resultChan <- expandResultError(NewResultTooLargeErr("expand", req.MaximumResultSize), emptyMetadata)
+				return
+			}
 		}
 		it.Close()
 
@@ -0,0 +1,575 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/authzed/spicedb/internal/namespace"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+	"github.com/authzed/spicedb/pkg/spiceerrors"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// PlanNodeKind is the kind of step represented by a node in an explained plan.
+type PlanNodeKind string
+
+const (
+	// PlanNodeCheck is a (possibly dispatched) check of a relation or permission.
+	PlanNodeCheck PlanNodeKind = "check"
+
+	// PlanNodeDirect is a lookup of the relationships directly written for a relation.
+	PlanNodeDirect PlanNodeKind = "direct"
+
+	// PlanNodeUnion is a union of its children.
+	PlanNodeUnion PlanNodeKind = "union"
+
+	// PlanNodeIntersection is an intersection of its children.
+	PlanNodeIntersection PlanNodeKind = "intersection"
+
+	// PlanNodeExclusion is the first child excluding the remaining children.
+	PlanNodeExclusion PlanNodeKind = "exclusion"
+
+	// PlanNodeComputedUserset is a rewrite to another relation or permission on the same resource.
+	PlanNodeComputedUserset PlanNodeKind = "computed-userset"
+
+	// PlanNodeTupleToUserset is an arrow, walking a tupleset relation to another relation or
+	// permission on its subjects.
+	PlanNodeTupleToUserset PlanNodeKind = "tuple-to-userset"
+
+	// PlanNodeNil is a branch which never contains any members.
+	PlanNodeNil PlanNodeKind = "nil"
+
+	// PlanNodeLookupResources is a lookup of the resources for which a subject has a permission.
+	PlanNodeLookupResources PlanNodeKind = "lookup-resources"
+
+	// PlanNodeReachableResources is a walk of the reachability graph from a subject type to
+	// a resource type.
+	PlanNodeReachableResources PlanNodeKind = "reachable-resources"
+
+	// PlanNodeEntrypoint is an entrypoint into the reachability graph.
+	PlanNodeEntrypoint PlanNodeKind = "entrypoint"
+)
+
+// CachePrediction is the predicted outcome of looking up a dispatched check in the cache.
+type CachePrediction int
+
+const (
+	// CachePredictionUnknown indicates that the outcome cannot be predicted, for example because
+	// the resource IDs are only known once the plan is executed.
+	CachePredictionUnknown CachePrediction = iota
+
+	// CachePredictionHit indicates that the check would be answered from the cache.
+	CachePredictionHit
+
+	// CachePredictionMiss indicates that the check would have to be computed.
+	CachePredictionMiss
+)
+
+// PlanNode is a step in the planned traversal of a check or lookup.
+type PlanNode struct {
+	// Kind is the kind of step.
+	Kind PlanNodeKind
+
+	// Relation is the relation or permission on which the step operates, if any.
+	Relation *core.RelationReference
+
+	// Strategy describes how the step would be executed.
+	Strategy string
+
+	// Queries are the datastore queries expected to be issued by the step.
+	Queries []string
+
+	// Cache is the predicted outcome of looking up the step in the dispatch cache.
+	Cache CachePrediction
+
+	// Recursive is true if the step was already planned along the same path, and would
+	// therefore be dispatched recursively until the depth is exhausted.
+	Recursive bool
+
+	// Children are the steps on which this step depends.
+	Children []*PlanNode
+}
+
+// CheckCachePredictor is implemented by dispatchers able to predict whether a dispatched check
+// would be answered from their cache.
+type CheckCachePredictor interface {
+	IsCheckCached(ctx context.Context, req *v1.DispatchCheckRequest) (bool, error)
+}
+
+// ExplainParams are the parameters used when explaining the plan for a request.
+type ExplainParams struct {
+	// Reader is used to read the schema at the revision of the request.
+	Reader datastore.Reader
+
+	// Revision is the revision at which the request would be executed.
+	Revision datastore.Revision
+
+	// Predictor, if non-nil, is used to predict cache hits for dispatched checks.
+	Predictor CheckCachePredictor
+
+	// MaximumDepth is the maximum depth of the plan, as well as the depth given to the
+	// dispatched checks for which cache hits are predicted.
+	MaximumDepth uint32
+}
+
+type pathKey = string
+
+// ExplainCheck returns the planned traversal for checking whether the subject has the relation
+// or permission on the resource, without executing it. If the resource ID is empty, the plan is
+// that for any resource of the type.
+func ExplainCheck(
+	ctx context.Context,
+	params ExplainParams,
+	resourceRelation *core.RelationReference,
+	resourceID string,
+	subject *core.ObjectAndRelation,
+) (*PlanNode, error) {
+	return explainCheck(ctx, params, resourceRelation, resourceID, subject, 0, map[pathKey]struct{}{})
+}
+
+func explainCheck(
+	ctx context.Context,
+	params ExplainParams,
+	resourceRelation *core.RelationReference,
+	resourceID string,
+	subject *core.ObjectAndRelation,
+	depth uint32,
+	path map[pathKey]struct{},
+) (*PlanNode, error) {
+	node := &PlanNode{Kind: PlanNodeCheck, Relation: resourceRelation, Strategy: "dispatch"}
+
+	if resourceID != "" {
+		if onrEqual(&core.ObjectAndRelation{
+			Namespace: resourceRelation.Namespace,
+			ObjectId:  resourceID,
+			Relation:  resourceRelation.Relation,
+		}, subject) {
+			node.Strategy = "subject is the resource"
+			return node, nil
+		}
+
+		cache, err := predictCheckCache(ctx, params, resourceRelation, resourceID, subject, depth)
+		if err != nil {
+			return nil, err
+		}
+		node.Cache = cache
+	}
+
+	key := tuple.StringRR(resourceRelation)
+	if _, ok := path[key]; ok {
+		node.Recursive = true
+		return node, nil
+	}
+
+	if depth >= params.MaximumDepth {
+		node.Strategy = "maximum depth reached"
+		return node, nil
+	}
+
+	_, relation, err := namespace.ReadNamespaceAndRelation(ctx, resourceRelation.Namespace, resourceRelation.Relation, params.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	path[key] = struct{}{}
+	defer delete(path, key)
+
+	var child *PlanNode
+	if relation.UsersetRewrite == nil {
+		child, err = explainDirect(ctx, params, resourceRelation, relation, resourceID, subject, depth, path)
+	} else {
+		child, err = explainRewrite(ctx, params, resourceRelation, relation.UsersetRewrite, resourceID, subject, depth, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	node.Children = []*PlanNode{child}
+	return node, nil
+}
+
+func predictCheckCache(
+	ctx context.Context,
+	params ExplainParams,
+	resourceRelation *core.RelationReference,
+	resourceID string,
+	subject *core.ObjectAndRelation,
+	depth uint32,
+) (CachePrediction, error) {
+	if params.Predictor == nil {
+		return CachePredictionUnknown, nil
+	}
+
+	cached, err := params.Predictor.IsCheckCached(ctx, &v1.DispatchCheckRequest{
+		ResourceRelation: resourceRelation,
+		ResourceIds:      []string{resourceID},
+		ResultsSetting:   v1.DispatchCheckRequest_ALLOW_SINGLE_RESULT,
+		Subject:          subject,
+		Metadata: &v1.ResolverMeta{
+			AtRevision:     params.Revision.String(),
+			DepthRemaining: params.MaximumDepth - depth,
+		},
+	})
+	if err != nil {
+		return CachePredictionUnknown, err
+	}
+
+	if cached {
+		return CachePredictionHit, nil
+	}
+	return CachePredictionMiss, nil
+}
+
+func explainDirect(
+	ctx context.Context,
+	params ExplainParams,
+	resourceRelation *core.RelationReference,
+	relation *core.Relation,
+	resourceID string,
+	subject *core.ObjectAndRelation,
+	depth uint32,
+	path map[pathKey]struct{},
+) (*PlanNode, error) {
+	node := &PlanNode{Kind: PlanNodeDirect, Relation: resourceRelation}
+
+	// Mirror the queries issued by checkDirect.
+	var subjectSelectors []string
+	var nonTerminals []*core.RelationReference
+	for _, allowed := range relation.GetTypeInformation().GetAllowedDirectRelations() {
+		if allowed.GetNamespace() == subject.Namespace {
+			if allowed.GetPublicWildcard() != nil {
+				subjectSelectors = append(subjectSelectors, tuple.StringONR(&core.ObjectAndRelation{
+					Namespace: subject.Namespace,
+					ObjectId:  tuple.PublicWildcard,
+					Relation:  tuple.Ellipsis,
+				}))
+			} else if allowed.GetRelation() == subject.Relation {
+				subjectSelectors = append(subjectSelectors, tuple.StringONR(subject))
+			}
+		}
+
+		if allowed.GetPublicWildcard() == nil && allowed.GetRelation() != tuple.Ellipsis {
+			nonTerminals = append(nonTerminals, &core.RelationReference{
+				Namespace: allowed.GetNamespace(),
+				Relation:  allowed.GetRelation(),
+			})
+		}
+	}
+
+	var strategies []string
+	if len(subjectSelectors) > 0 {
+		strategies = append(strategies, "direct subject lookup")
+		node.Queries = append(node.Queries, describeQuery(resourceRelation, resourceID, "subjects in ["+strings.Join(subjectSelectors, ", ")+"]"))
+	}
+
+	if len(nonTerminals) > 0 {
+		strategies = append(strategies, "dispatch over subject sets")
+		node.Queries = append(node.Queries, describeQuery(resourceRelation, resourceID, "non-ellipsis subjects"))
+
+		for _, subjectRelation := range nonTerminals {
+			child, err := explainCheck(ctx, params, subjectRelation, "", subject, depth+1, path)
+			if err != nil {
+				return nil, err
+			}
+			node.Children = append(node.Children, child)
+		}
+	}
+
+	if len(strategies) == 0 {
+		strategies = append(strategies, "no allowed subject types can reach the subject")
+	}
+
+	node.Strategy = strings.Join(strategies, ", then ")
+	return node, nil
+}
+
+func explainRewrite(
+	ctx context.Context,
+	params ExplainParams,
+	resourceRelation *core.RelationReference,
+	rewrite *core.UsersetRewrite,
+	resourceID string,
+	subject *core.ObjectAndRelation,
+	depth uint32,
+	path map[pathKey]struct{},
+) (*PlanNode, error) {
+	var node *PlanNode
+	var children []*core.SetOperation_Child
+	switch rw := rewrite.RewriteOperation.(type) {
+	case *core.UsersetRewrite_Union:
+		node = &PlanNode{Kind: PlanNodeUnion, Strategy: "branches run concurrently, short-circuiting on the first member found"}
+		children = rw.Union.Child
+
+	case *core.UsersetRewrite_Intersection:
+		node = &PlanNode{Kind: PlanNodeIntersection, Strategy: "branches run concurrently, short-circuiting on the first branch without members"}
+		children = rw.Intersection.Child
+
+	case *core.UsersetRewrite_Exclusion:
+		node = &PlanNode{Kind: PlanNodeExclusion, Strategy: "base branch runs concurrently with the excluded branches"}
+		children = rw.Exclusion.Child
+
+	default:
+		return nil, spiceerrors.MustBugf("unknown userset rewrite operator")
+	}
+
+	node.Relation = resourceRelation
+	for _, child := range children {
+		childNode, err := explainSetOperationChild(ctx, params, resourceRelation, child, resourceID, subject, depth, path)
+		if err != nil {
+			return nil, err
+		}
+		node.Children = append(node.Children, childNode)
+	}
+	return node, nil
+}
+
+func explainSetOperationChild(
+	ctx context.Context,
+	params ExplainParams,
+	resourceRelation *core.RelationReference,
+	child *core.SetOperation_Child,
+	resourceID string,
+	subject *core.ObjectAndRelation,
+	depth uint32,
+	path map[pathKey]struct{},
+) (*PlanNode, error) {
+	switch child := child.ChildType.(type) {
+	case *core.SetOperation_Child_XThis:
+		return nil, errors.New("use of _this is unsupported; please rewrite your schema")
+
+	case *core.SetOperation_Child_ComputedUserset:
+		rewritten := &core.RelationReference{
+			Namespace: resourceRelation.Namespace,
+			Relation:  child.ComputedUserset.Relation,
+		}
+
+		checkNode, err := explainCheck(ctx, params, rewritten, resourceID, subject, depth+1, path)
+		if err != nil {
+			return nil, err
+		}
+
+		return &PlanNode{
+			Kind:     PlanNodeComputedUserset,
+			Relation: rewritten,
+			Strategy: "dispatch on the same resource",
+			Children: []*PlanNode{checkNode},
+		}, nil
+
+	case *core.SetOperation_Child_UsersetRewrite:
+		return explainRewrite(ctx, params, resourceRelation, child.UsersetRewrite, resourceID, subject, depth, path)
+
+	case *core.SetOperation_Child_TupleToUserset:
+		return explainTupleToUserset(ctx, params, resourceRelation, child.TupleToUserset, resourceID, subject, depth, path)
+
+	case *core.SetOperation_Child_XNil:
+		return &PlanNode{Kind: PlanNodeNil, Strategy: "no members"}, nil
+
+	default:
+		return nil, spiceerrors.MustBugf("unknown set operation child `%T` in check", child)
+	}
+}
+
+func explainTupleToUserset(
+	ctx context.Context,
+	params ExplainParams,
+	resourceRelation *core.RelationReference,
+	ttu *core.TupleToUserset,
+	resourceID string,
+	subject *core.ObjectAndRelation,
+	depth uint32,
+	path map[pathKey]struct{},
+) (*PlanNode, error) {
+	tuplesetRelation := &core.RelationReference{
+		Namespace: resourceRelation.Namespace,
+		Relation:  ttu.Tupleset.Relation,
+	}
+
+	node := &PlanNode{
+		Kind:     PlanNodeTupleToUserset,
+		Relation: tuplesetRelation,
+		Strategy: fmt.Sprintf("dispatch `%s` on each subject of the tupleset", ttu.ComputedUserset.Relation),
+		Queries:  []string{describeQuery(tuplesetRelation, resourceID, "all subjects")},
+	}
+
+	_, ts, err := namespace.ReadNamespaceAndTypes(ctx, resourceRelation.Namespace, params.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	subjectRelations, err := ts.AllowedSubjectRelations(ttu.Tupleset.Relation)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only subject types which define the computed relation are dispatched.
+	seen := map[string]struct{}{}
+	for _, subjectRelation := range subjectRelations {
+		if _, ok := seen[subjectRelation.Namespace]; ok {
+			continue
+		}
+		seen[subjectRelation.Namespace] = struct{}{}
+
+		_, subjectTS, err := namespace.ReadNamespaceAndTypes(ctx, subjectRelation.Namespace, params.Reader)
+		if err != nil {
+			return nil, err
+		}
+
+		if !subjectTS.HasRelation(ttu.ComputedUserset.Relation) {
+			continue
+		}
+
+		child, err := explainCheck(ctx, params, &core.RelationReference{
+			Namespace: subjectRelation.Namespace,
+			Relation:  ttu.ComputedUserset.Relation,
+		}, "", subject, depth+1, path)
+		if err != nil {
+			return nil, err
+		}
+		node.Children = append(node.Children, child)
+	}
+
+	return node, nil
+}
+
+// ExplainLookupResources returns the planned traversal for finding the resources of the given
+// type for which the subject has the relation or permission, without executing it.
+func ExplainLookupResources(
+	ctx context.Context,
+	params ExplainParams,
+	resourceRelation *core.RelationReference,
+	subject *core.ObjectAndRelation,
+) (*PlanNode, error) {
+	reachable, err := explainReachableResources(ctx, params, &core.RelationReference{
+		Namespace: subject.Namespace,
+		Relation:  subject.Relation,
+	}, resourceRelation, 0, map[pathKey]struct{}{})
+	if err != nil {
+		return nil, err
+	}
+
+	check, err := ExplainCheck(ctx, params, resourceRelation, "", subject)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PlanNode{
+		Kind:     PlanNodeLookupResources,
+		Relation: resourceRelation,
+		Strategy: "find the reachable resources, then check those only conditionally reachable",
+		Children: []*PlanNode{reachable, check},
+	}, nil
+}
+
+func explainReachableResources(
+	ctx context.Context,
+	params ExplainParams,
+	subjectRelation *core.RelationReference,
+	resourceRelation *core.RelationReference,
+	depth uint32,
+	path map[pathKey]struct{},
+) (*PlanNode, error) {
+	node := &PlanNode{
+		Kind:     PlanNodeReachableResources,
+		Relation: subjectRelation,
+		Strategy: "dispatch",
+	}
+
+	key := tuple.StringRR(subjectRelation)
+	if _, ok := path[key]; ok {
+		node.Recursive = true
+		return node, nil
+	}
+
+	if depth >= params.MaximumDepth {
+		node.Strategy = "maximum depth reached"
+		return node, nil
+	}
+
+	_, ts, err := namespace.ReadNamespaceAndTypes(ctx, resourceRelation.Namespace, params.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	rg := namespace.ReachabilityGraphFor(ts.AsValidated())
+	entrypoints, err := rg.OptimizedEntrypointsForSubjectToResource(ctx, subjectRelation, resourceRelation)
+	if err != nil {
+		return nil, err
+	}
+
+	isTarget := subjectRelation.Namespace == resourceRelation.Namespace && subjectRelation.Relation == resourceRelation.Relation
+	if len(entrypoints) == 0 {
+		node.Strategy = "no further entrypoints"
+		if isTarget {
+			node.Strategy = "report as found resources"
+		}
+		return node, nil
+	}
+
+	if isTarget {
+		node.Strategy = "report as found resources, then dispatch"
+	}
+
+	path[key] = struct{}{}
+	defer delete(path, key)
+
+	for _, entrypoint := range entrypoints {
+		containingRelation := entrypoint.ContainingRelationOrPermission()
+		entrypointNode := &PlanNode{
+			Kind:     PlanNodeEntrypoint,
+			Relation: containingRelation,
+			Strategy: strings.ToLower(strings.TrimSuffix(entrypoint.EntrypointKind().String(), "_ENTRYPOINT")),
+		}
+
+		nextSubjectRelation := containingRelation
+		switch entrypoint.EntrypointKind() {
+		case core.ReachabilityEntrypoint_RELATION_ENTRYPOINT:
+			directRelation, err := entrypoint.DirectRelation()
+			if err != nil {
+				return nil, err
+			}
+			nextSubjectRelation = directRelation
+			entrypointNode.Queries = []string{describeReverseQuery(subjectRelation, directRelation)}
+
+		case core.ReachabilityEntrypoint_TUPLESET_TO_USERSET_ENTRYPOINT:
+			tuplesetRelation, err := entrypoint.TuplesetRelation()
+			if err != nil {
+				return nil, err
+			}
+			entrypointNode.Queries = []string{describeReverseQuery(subjectRelation, &core.RelationReference{
+				Namespace: containingRelation.Namespace,
+				Relation:  tuplesetRelation,
+			})}
+
+		case core.ReachabilityEntrypoint_COMPUTED_USERSET_ENTRYPOINT:
+			// Computed usersets are rewritten without any queries.
+
+		default:
+			return nil, spiceerrors.MustBugf("Unknown kind of entrypoint: %v", entrypoint.EntrypointKind())
+		}
+
+		child, err := explainReachableResources(ctx, params, nextSubjectRelation, resourceRelation, depth+1, path)
+		if err != nil {
+			return nil, err
+		}
+		entrypointNode.Children = []*PlanNode{child}
+		node.Children = append(node.Children, entrypointNode)
+	}
+
+	return node, nil
+}
+
+func describeQuery(resourceRelation *core.RelationReference, resourceID string, subjects string) string {
+	resource := resourceRelation.Namespace + ":*"
+	if resourceID != "" {
+		resource = resourceRelation.Namespace + ":" + resourceID
+	}
+	return fmt.Sprintf("QueryRelationships(%s#%s, %s)", resource, resourceRelation.Relation, subjects)
+}
+
+func describeReverseQuery(subjectRelation *core.RelationReference, resourceRelation *core.RelationReference) string {
+	return fmt.Sprintf("ReverseQueryRelationships(%s, %s)", tuple.StringRR(subjectRelation), tuple.StringRR(resourceRelation))
+}
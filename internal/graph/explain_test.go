@@ -0,0 +1,155 @@
+package graph
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/internal/testfixtures"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+const explainSchema = `
+	definition user {}
+
+	definition group {
+		relation member: user | group#member
+	}
+
+	definition folder {
+		relation viewer: user
+		permission view = viewer
+	}
+
+	definition document {
+		relation parent: folder
+		relation viewer: user | group#member
+		permission view = viewer + parent->view
+	}
+`
+
+type fakeCheckCachePredictor struct {
+	cached map[string]bool
+}
+
+func (f fakeCheckCachePredictor) IsCheckCached(_ context.Context, req *v1.DispatchCheckRequest) (bool, error) {
+	return f.cached[tuple.StringRR(req.ResourceRelation)], nil
+}
+
+func childKinds(node *PlanNode) []PlanNodeKind {
+	kinds := make([]PlanNodeKind, 0, len(node.Children))
+	for _, child := range node.Children {
+		kinds = append(kinds, child.Kind)
+	}
+	return kinds
+}
+
+func TestExplainCheck(t *testing.T) {
+	require := require.New(t)
+
+	rawDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	require.NoError(err)
+
+	ds, revision := testfixtures.DatastoreFromSchemaAndTestRelationships(rawDS, explainSchema, nil, require)
+
+	params := ExplainParams{
+		Reader:       ds.SnapshotReader(revision),
+		Revision:     revision,
+		Predictor:    fakeCheckCachePredictor{cached: map[string]bool{"document#viewer": true}},
+		MaximumDepth: 50,
+	}
+
+	plan, err := ExplainCheck(context.Background(), params, &core.RelationReference{
+		Namespace: "document",
+		Relation:  "view",
+	}, "somedoc", tuple.ParseSubjectONR("user:tom"))
+	require.NoError(err)
+
+	require.Equal(PlanNodeCheck, plan.Kind)
+	require.Equal(CachePredictionMiss, plan.Cache)
+	require.Equal([]PlanNodeKind{PlanNodeUnion}, childKinds(plan))
+
+	union := plan.Children[0]
+	require.Equal([]PlanNodeKind{PlanNodeComputedUserset, PlanNodeTupleToUserset}, childKinds(union))
+
+	// The computed userset is on the same resource, so its cache outcome can be predicted.
+	viewerCheck := union.Children[0].Children[0]
+	require.Equal("document#viewer", tuple.StringRR(viewerCheck.Relation))
+	require.Equal(CachePredictionHit, viewerCheck.Cache)
+
+	direct := viewerCheck.Children[0]
+	require.Equal(PlanNodeDirect, direct.Kind)
+	require.Equal([]string{
+		"QueryRelationships(document:somedoc#viewer, subjects in [user:tom])",
+		"QueryRelationships(document:somedoc#viewer, non-ellipsis subjects)",
+	}, direct.Queries)
+
+	// Nested groups are dispatched recursively.
+	memberCheck := direct.Children[0]
+	require.Equal("group#member", tuple.StringRR(memberCheck.Relation))
+	require.Equal(CachePredictionUnknown, memberCheck.Cache)
+	require.False(memberCheck.Recursive)
+	require.True(memberCheck.Children[0].Children[0].Recursive)
+
+	// The arrow walks the parent relation to the folder's permission.
+	ttu := union.Children[1]
+	require.Equal("document#parent", tuple.StringRR(ttu.Relation))
+	require.Equal([]string{"QueryRelationships(document:somedoc#parent, all subjects)"}, ttu.Queries)
+	require.Equal(1, len(ttu.Children))
+	require.Equal("folder#view", tuple.StringRR(ttu.Children[0].Relation))
+}
+
+func TestExplainCheckMaximumDepth(t *testing.T) {
+	require := require.New(t)
+
+	rawDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	require.NoError(err)
+
+	ds, revision := testfixtures.DatastoreFromSchemaAndTestRelationships(rawDS, explainSchema, nil, require)
+
+	plan, err := ExplainCheck(context.Background(), ExplainParams{
+		Reader:       ds.SnapshotReader(revision),
+		Revision:     revision,
+		MaximumDepth: 1,
+	}, &core.RelationReference{Namespace: "document", Relation: "view"}, "", tuple.ParseSubjectONR("user:tom"))
+	require.NoError(err)
+
+	computedCheck := plan.Children[0].Children[0].Children[0]
+	require.Equal("maximum depth reached", computedCheck.Strategy)
+	require.Empty(computedCheck.Children)
+}
+
+func TestExplainLookupResources(t *testing.T) {
+	require := require.New(t)
+
+	rawDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	require.NoError(err)
+
+	ds, revision := testfixtures.DatastoreFromSchemaAndTestRelationships(rawDS, explainSchema, nil, require)
+
+	plan, err := ExplainLookupResources(context.Background(), ExplainParams{
+		Reader:       ds.SnapshotReader(revision),
+		Revision:     revision,
+		MaximumDepth: 50,
+	}, &core.RelationReference{Namespace: "document", Relation: "view"}, tuple.ParseSubjectONR("user:tom"))
+	require.NoError(err)
+
+	require.Equal(PlanNodeLookupResources, plan.Kind)
+	require.Equal([]PlanNodeKind{PlanNodeReachableResources, PlanNodeCheck}, childKinds(plan))
+
+	reachable := plan.Children[0]
+	require.Equal("user#...", tuple.StringRR(reachable.Relation))
+	require.NotEmpty(reachable.Children)
+
+	var queries []string
+	for _, entrypoint := range reachable.Children {
+		require.Equal(PlanNodeEntrypoint, entrypoint.Kind)
+		queries = append(queries, entrypoint.Queries...)
+	}
+	require.Contains(queries, "ReverseQueryRelationships(user#..., document#viewer)")
+	require.Contains(queries, "ReverseQueryRelationships(user#..., folder#viewer)")
+}
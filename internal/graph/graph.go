@@ -67,8 +67,9 @@ type ExpandReducer func(
 
 func decrementDepth(md *v1.ResolverMeta) *v1.ResolverMeta {
 	return &v1.ResolverMeta{
-		AtRevision:     md.AtRevision,
-		DepthRemaining: md.DepthRemaining - 1,
+		AtRevision:       md.AtRevision,
+		DepthRemaining:   md.DepthRemaining - 1,
+		ConcurrencyLimit: md.ConcurrencyLimit,
 	}
 }
 
@@ -103,10 +103,11 @@ func (cl *ConcurrentLookup) LookupViaReachability(ctx context.Context, req Valid
 			Namespace: req.Subject.Namespace,
 			Relation:  req.Subject.Relation,
 		},
-		SubjectIds: []string{req.Subject.ObjectId},
-		Metadata:   req.Metadata,
+		SubjectIds:               []string{req.Subject.ObjectId},
+		OptionalResourceIdPrefix: req.OptionalResourceIdPrefix,
+		Metadata:                 req.Metadata,
 	}, stream)
-	if err != nil {
+	if err != nil && !checker.LimitReached() {
 		resp := lookupResultError(err, emptyMetadata)
 		return resp.Resp, resp.Err
 	}
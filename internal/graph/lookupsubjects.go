@@ -5,8 +5,6 @@ import (
 	"errors"
 	"fmt"
 
-	"golang.org/x/sync/errgroup"
-
 	"github.com/authzed/spicedb/internal/datasets"
 	"github.com/authzed/spicedb/internal/dispatch"
 	log "github.com/authzed/spicedb/internal/logging"
@@ -112,9 +110,13 @@ func (cl *ConcurrentLookupSubjects) lookupDirectSubjects(
 	toDispatchByType := datasets.NewSubjectByTypeSet()
 	foundSubjectsByResourceID := datasets.NewSubjectSetByResourceID()
 	relationshipsBySubjectONR := util.NewMultiMap[string, *core.RelationTuple]()
-	for tpl := it.Next(); tpl != nil; tpl = it.Next() {
-		if it.Err() != nil {
-			return it.Err()
+	for {
+		tpl, err := it.Next()
+		if err != nil {
+			return err
+		}
+		if tpl == nil {
+			break
 		}
 
 		if tpl.Subject.Namespace == req.SubjectRelation.Namespace &&
@@ -181,8 +183,9 @@ func (cl *ConcurrentLookupSubjects) lookupViaComputed(
 		ResourceIds:     parentRequest.ResourceIds,
 		SubjectRelation: parentRequest.SubjectRelation,
 		Metadata: &v1.ResolverMeta{
-			AtRevision:     parentRequest.Revision.String(),
-			DepthRemaining: parentRequest.Metadata.DepthRemaining - 1,
+			AtRevision:       parentRequest.Revision.String(),
+			DepthRemaining:   parentRequest.Metadata.DepthRemaining - 1,
+			ConcurrencyLimit: parentRequest.Metadata.ConcurrencyLimit,
 		},
 	}, stream)
 }
@@ -206,14 +209,17 @@ func (cl *ConcurrentLookupSubjects) lookupViaTupleToUserset(
 
 	toDispatchByTuplesetType := datasets.NewSubjectByTypeSet()
 	relationshipsBySubjectONR := util.NewMultiMap[string, *core.RelationTuple]()
-	for tpl := it.Next(); tpl != nil; tpl = it.Next() {
-		if it.Err() != nil {
-			return it.Err()
+	for {
+		tpl, err := it.Next()
+		if err != nil {
+			return err
+		}
+		if tpl == nil {
+			break
 		}
 
 		// Add the subject to be dispatched.
-		err := toDispatchByTuplesetType.AddSubjectOf(tpl)
-		if err != nil {
+		if err := toDispatchByTuplesetType.AddSubjectOf(tpl); err != nil {
 			return err
 		}
 
@@ -279,8 +285,7 @@ func (cl *ConcurrentLookupSubjects) lookupSetOperation(
 	cancelCtx, checkCancel := context.WithCancel(ctx)
 	defer checkCancel()
 
-	g, subCtx := errgroup.WithContext(cancelCtx)
-	g.SetLimit(int(cl.concurrencyLimit))
+	g, subCtx := newTaskGroup(cancelCtx, cl.concurrencyLimit)
 
 	for index, childOneof := range so.Child {
 		stream := reducer.ForIndex(subCtx, index)
@@ -335,8 +340,7 @@ func (cl *ConcurrentLookupSubjects) dispatchTo(
 	cancelCtx, checkCancel := context.WithCancel(ctx)
 	defer checkCancel()
 
-	g, subCtx := errgroup.WithContext(cancelCtx)
-	g.SetLimit(int(cl.concurrencyLimit))
+	g, subCtx := newTaskGroup(cancelCtx, cl.concurrencyLimit)
 
 	toDispatchByType.ForEachType(func(resourceType *core.RelationReference, foundSubjects datasets.SubjectSet) {
 		slice := foundSubjects.AsSlice()
@@ -423,8 +427,9 @@ func (cl *ConcurrentLookupSubjects) dispatchTo(
 					ResourceIds:      resourceIdChunk,
 					SubjectRelation:  parentRequest.SubjectRelation,
 					Metadata: &v1.ResolverMeta{
-						AtRevision:     parentRequest.Revision.String(),
-						DepthRemaining: parentRequest.Metadata.DepthRemaining - 1,
+						AtRevision:       parentRequest.Revision.String(),
+						DepthRemaining:   parentRequest.Metadata.DepthRemaining - 1,
+						ConcurrencyLimit: parentRequest.Metadata.ConcurrencyLimit,
 					},
 				}, stream)
 			})
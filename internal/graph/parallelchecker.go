@@ -111,7 +111,6 @@ func (pc *parallelChecker) QueueToCheck(resourceID string) bool {
 		pc.mu.Lock()
 		defer pc.mu.Unlock()
 		if len(pc.foundResourceIDs) >= int(pc.lookupRequest.Limit) {
-			close(pc.toCheck)
 			return false
 		}
 
@@ -125,11 +124,20 @@ func (pc *parallelChecker) QueueToCheck(resourceID string) bool {
 	return true
 }
 
+// LimitReached returns whether the limit of the lookup has been reached, in which case any further
+// work has been canceled.
+func (pc *parallelChecker) LimitReached() bool {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	return len(pc.foundResourceIDs) >= int(pc.lookupRequest.Limit)
+}
+
 // Start starts the parallel checks over those items added via QueueToCheck.
 func (pc *parallelChecker) Start() {
 	meta := &v1.ResolverMeta{
-		AtRevision:     pc.lookupRequest.Revision.String(),
-		DepthRemaining: pc.lookupRequest.Metadata.DepthRemaining,
+		AtRevision:       pc.lookupRequest.Revision.String(),
+		DepthRemaining:   pc.lookupRequest.Metadata.DepthRemaining,
+		ConcurrencyLimit: pc.lookupRequest.Metadata.ConcurrencyLimit,
 	}
 
 	pc.t.Schedule(func(ctx context.Context) error {
@@ -217,7 +225,7 @@ func (pc *parallelChecker) Start() {
 // error occurred. Once called, no new items can be added via QueueToCheck.
 func (pc *parallelChecker) Wait() ([]*v1.ResolvedResource, error) {
 	close(pc.toCheck)
-	if err := pc.t.Wait(); err != nil {
+	if err := pc.t.Wait(); err != nil && !pc.LimitReached() {
 		return nil, err
 	}
 
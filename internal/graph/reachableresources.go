@@ -3,6 +3,7 @@ package graph
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/authzed/spicedb/internal/datastore/options"
 	"github.com/authzed/spicedb/internal/dispatch"
@@ -58,12 +59,15 @@ func (crr *ConcurrentReachableResources) ReachableResources(
 			})
 		}
 
-		err := stream.Publish(&v1.DispatchReachableResourcesResponse{
-			Resources: resources,
-			Metadata:  emptyMetadata,
-		})
-		if err != nil {
-			return err
+		resources = filterResourceIDPrefix(resources, req.OptionalResourceIdPrefix)
+		if len(resources) > 0 {
+			err := stream.Publish(&v1.DispatchReachableResourcesResponse{
+				Resources: resources,
+				Metadata:  emptyMetadata,
+			})
+			if err != nil {
+				return err
+			}
 		}
 	}
 
@@ -188,7 +192,12 @@ func (crr *ConcurrentReachableResources) lookupRelationEntrypoint(
 		},
 	}
 
-	crr.scheduleChunkedRedispatch(t, reader, subjectsFilter, relationReference, dispatched,
+	resourceIDPrefix, err := resourceIDPrefixForQuery(ctx, relationReference, rg, req)
+	if err != nil {
+		return err
+	}
+
+	crr.scheduleChunkedRedispatch(t, reader, subjectsFilter, relationReference, resourceIDPrefix, dispatched,
 		func(ctx context.Context, drsm dispatchableResourcesSubjectMap) error {
 			return crr.redispatchOrReport(ctx, t, relationReference, drsm, rg, entrypoint, stream, req)
 		})
@@ -207,6 +216,7 @@ func (crr *ConcurrentReachableResources) scheduleChunkedRedispatch(
 	reader datastore.Reader,
 	subjectsFilter datastore.SubjectsFilter,
 	resourceType *core.RelationReference,
+	resourceIDPrefix string,
 	dispatched *syncONRSet,
 	handler func(ctx context.Context, resources dispatchableResourcesSubjectMap) error,
 ) {
@@ -219,6 +229,7 @@ func (crr *ConcurrentReachableResources) scheduleChunkedRedispatch(
 				Namespace: resourceType.Namespace,
 				Relation:  resourceType.Relation,
 			}),
+			options.WithResIDPrefix(resourceIDPrefix),
 		)
 		if err != nil {
 			return err
@@ -227,14 +238,17 @@ func (crr *ConcurrentReachableResources) scheduleChunkedRedispatch(
 
 		rsm := newResourcesSubjectMap(resourceType)
 		chunkIndex := 0
-		for tpl := it.Next(); tpl != nil; tpl = it.Next() {
-			chunkSize := progressiveDispatchChunkSizes[min(chunkIndex, len(progressiveDispatchChunkSizes)-1)]
-			if it.Err() != nil {
-				return it.Err()
+		for {
+			tpl, err := it.Next()
+			if err != nil {
+				return err
+			}
+			if tpl == nil {
+				break
 			}
 
-			err := rsm.addRelationship(tpl)
-			if err != nil {
+			chunkSize := progressiveDispatchChunkSizes[min(chunkIndex, len(progressiveDispatchChunkSizes)-1)]
+			if err := rsm.addRelationship(tpl); err != nil {
 				return err
 			}
 
@@ -322,7 +336,13 @@ func (crr *ConcurrentReachableResources) lookupTTUEntrypoint(ctx context.Context
 		Relation:  tuplesetRelation,
 	}
 
-	crr.scheduleChunkedRedispatch(t, reader, subjectsFilter, tuplesetRelationReference, dispatched,
+	// The resources of the tupleset are those of the containing relation.
+	resourceIDPrefix, err := resourceIDPrefixForQuery(ctx, containingRelation, rg, req)
+	if err != nil {
+		return err
+	}
+
+	crr.scheduleChunkedRedispatch(t, reader, subjectsFilter, tuplesetRelationReference, resourceIDPrefix, dispatched,
 		func(ctx context.Context, drsm dispatchableResourcesSubjectMap) error {
 			return crr.redispatchOrReport(ctx, t, containingRelation, drsm, rg, entrypoint, stream, req)
 		})
@@ -358,8 +378,13 @@ func (crr *ConcurrentReachableResources) redispatchOrReport(
 		// If the found resource matches the target resource type and relation, yield the resource.
 		if foundResourceType.Namespace == parentRequest.ResourceRelation.Namespace &&
 			foundResourceType.Relation == parentRequest.ResourceRelation.Relation {
+			resources := filterResourceIDPrefix(foundResources.asReachableResources(entrypoint.IsDirectResult()), parentRequest.OptionalResourceIdPrefix)
+			if len(resources) == 0 {
+				return nil
+			}
+
 			return parentStream.Publish(&v1.DispatchReachableResourcesResponse{
-				Resources: foundResources.asReachableResources(entrypoint.IsDirectResult()),
+				Resources: resources,
 				Metadata:  emptyMetadata,
 			})
 		}
@@ -399,14 +424,58 @@ func (crr *ConcurrentReachableResources) redispatchOrReport(
 		// Dispatch the found resources as the subjects for the next call, to continue the
 		// resolution.
 		return crr.d.DispatchReachableResources(&v1.DispatchReachableResourcesRequest{
-			ResourceRelation: parentRequest.ResourceRelation,
-			SubjectRelation:  foundResourceType,
-			SubjectIds:       foundResources.resourceIDs(),
+			ResourceRelation:         parentRequest.ResourceRelation,
+			SubjectRelation:          foundResourceType,
+			SubjectIds:               foundResources.resourceIDs(),
+			OptionalResourceIdPrefix: parentRequest.OptionalResourceIdPrefix,
 			Metadata: &v1.ResolverMeta{
-				AtRevision:     parentRequest.Revision.String(),
-				DepthRemaining: parentRequest.Metadata.DepthRemaining - 1,
+				AtRevision:       parentRequest.Revision.String(),
+				DepthRemaining:   parentRequest.Metadata.DepthRemaining - 1,
+				ConcurrencyLimit: parentRequest.Metadata.ConcurrencyLimit,
 			},
 		}, stream)
 	})
 	return nil
 }
+
+// resourceIDPrefixForQuery returns the prefix of resource IDs to which the query for the resources
+// of the given type can be limited. Only resources of the target type which are reported
+// directly can be limited: those from which other resources are reachable, such as the parents
+// of recursive relations, may lead to resources having the prefix through IDs without it.
+func resourceIDPrefixForQuery(
+	ctx context.Context,
+	foundResourceType *core.RelationReference,
+	rg *namespace.ReachabilityGraph,
+	req ValidatedReachableResourcesRequest,
+) (string, error) {
+	if req.OptionalResourceIdPrefix == "" ||
+		foundResourceType.Namespace != req.ResourceRelation.Namespace ||
+		foundResourceType.Relation != req.ResourceRelation.Relation {
+		return "", nil
+	}
+
+	hasResourceEntrypoints, err := rg.HasOptimizedEntrypointsForSubjectToResource(ctx, foundResourceType, req.ResourceRelation)
+	if err != nil {
+		return "", err
+	}
+
+	if hasResourceEntrypoints {
+		return "", nil
+	}
+	return req.OptionalResourceIdPrefix, nil
+}
+
+// filterResourceIDPrefix returns the resources whose IDs start with the given prefix, if any.
+func filterResourceIDPrefix(resources []*v1.ReachableResource, prefix string) []*v1.ReachableResource {
+	if prefix == "" {
+		return resources
+	}
+
+	filtered := make([]*v1.ReachableResource, 0, len(resources))
+	for _, resource := range resources {
+		if strings.HasPrefix(resource.ResourceId, prefix) {
+			filtered = append(filtered, resource)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,184 @@
+// Package hotreload reloads values, such as credentials and certificates, from files whenever
+// the files change, so that they can be rotated without restarting.
+package hotreload
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/prometheus/client_golang/prometheus"
+
+	log "github.com/authzed/spicedb/internal/logging"
+)
+
+var (
+	reloadsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "spicedb",
+		Subsystem: "hotreload",
+		Name:      "reloads_total",
+		Help:      "The number of loads of values from watched files, by name and result.",
+	}, []string{"name", "result"})
+
+	lastSuccessGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "spicedb",
+		Subsystem: "hotreload",
+		Name:      "last_success_timestamp_seconds",
+		Help:      "The time at which values were last successfully loaded from watched files, by name.",
+	}, []string{"name"})
+)
+
+func init() {
+	prometheus.MustRegister(reloadsCounter, lastSuccessGauge)
+}
+
+// debounce is the time waited after a change to the files before reloading, so that files
+// written in several steps, or together with others, are reloaded once.
+const debounce = 100 * time.Millisecond
+
+// File holds a value loaded from files, reloading it whenever the files change. If reloading
+// fails, the previous value is kept.
+type File[T any] struct {
+	name    string
+	load    func() (T, error)
+	value   atomic.Pointer[T]
+	watched map[string]struct{}
+	watcher *fsnotify.Watcher
+	done    chan struct{}
+
+	subscribersLock sync.Mutex
+	subscribers     []func(T)
+}
+
+// NewFile loads the value with the given function, which reads the files at the given paths,
+// and watches the files for changes until closed. Paths may be directories, whose files are all
+// watched. The name identifies the value in logs and metrics.
+func NewFile[T any](name string, load func() (T, error), paths ...string) (*File[T], error) {
+	f := &File[T]{
+		name:    name,
+		load:    load,
+		watched: make(map[string]struct{}, len(paths)),
+		done:    make(chan struct{}),
+	}
+
+	if err := f.reload(); err != nil {
+		return nil, err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("unable to watch %s: %w", name, err)
+	}
+
+	// The directories containing the files are watched, rather than the files themselves, so
+	// that files replaced by renames or by swapping symlinks, as Kubernetes does for mounted
+	// secrets, continue to be watched.
+	for _, path := range paths {
+		dir := filepath.Dir(path)
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			dir = path
+		}
+		f.watched[filepath.Clean(path)] = struct{}{}
+
+		if err := watcher.Add(dir); err != nil {
+			_ = watcher.Close()
+			return nil, fmt.Errorf("unable to watch %s at %s: %w", name, path, err)
+		}
+	}
+
+	f.watcher = watcher
+	go f.watch()
+	return f, nil
+}
+
+// Load returns the current value.
+func (f *File[T]) Load() T {
+	return *f.value.Load()
+}
+
+// Subscribe registers a function called with the value whenever it is reloaded successfully.
+func (f *File[T]) Subscribe(fn func(T)) {
+	f.subscribersLock.Lock()
+	defer f.subscribersLock.Unlock()
+	f.subscribers = append(f.subscribers, fn)
+}
+
+// Close stops watching the files.
+func (f *File[T]) Close() error {
+	err := f.watcher.Close()
+	<-f.done
+	return err
+}
+
+func (f *File[T]) watch() {
+	defer close(f.done)
+
+	var pending <-chan time.Time
+	for {
+		select {
+		case event, ok := <-f.watcher.Events:
+			if !ok {
+				return
+			}
+
+			if event.Op != fsnotify.Chmod && f.affects(event.Name) {
+				pending = time.After(debounce)
+			}
+
+		case err, ok := <-f.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Warn().Err(err).Str("name", f.name).Msg("error watching files for changes")
+
+		case <-pending:
+			pending = nil
+			if err := f.reload(); err != nil {
+				log.Warn().Err(err).Str("name", f.name).Msg("failed to reload changed files; continuing to use the previous value")
+				continue
+			}
+			log.Info().Str("name", f.name).Msg("reloaded changed files")
+
+			value := f.Load()
+			f.subscribersLock.Lock()
+			subscribers := f.subscribers
+			f.subscribersLock.Unlock()
+			for _, fn := range subscribers {
+				fn(value)
+			}
+		}
+	}
+}
+
+// affects returns whether a change to the file at the given path may change the value.
+func (f *File[T]) affects(path string) bool {
+	path = filepath.Clean(path)
+	if _, ok := f.watched[path]; ok {
+		return true
+	}
+
+	// Files within watched directories, and the `..data` symlinks through which Kubernetes
+	// swaps the files of mounted volumes.
+	if _, ok := f.watched[filepath.Dir(path)]; ok {
+		return true
+	}
+	return strings.HasPrefix(filepath.Base(path), "..")
+}
+
+func (f *File[T]) reload() error {
+	value, err := f.load()
+	if err != nil {
+		reloadsCounter.WithLabelValues(f.name, "error").Inc()
+		return fmt.Errorf("unable to load %s: %w", f.name, err)
+	}
+
+	reloadsCounter.WithLabelValues(f.name, "success").Inc()
+	lastSuccessGauge.WithLabelValues(f.name).SetToCurrentTime()
+	f.value.Store(&value)
+	return nil
+}
@@ -0,0 +1,58 @@
+package hotreload
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func readFile(path string) func() (string, error) {
+	return func() (string, error) {
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		if len(contents) == 0 {
+			return "", os.ErrInvalid
+		}
+		return string(contents), nil
+	}
+}
+
+func TestFileReloaded(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "value")
+	require.NoError(t, os.WriteFile(path, []byte("first"), 0o600))
+
+	f, err := NewFile("test", readFile(path), path)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, f.Close()) })
+	require.Equal(t, "first", f.Load())
+
+	reloaded := make(chan string, 10)
+	f.Subscribe(func(value string) { reloaded <- value })
+
+	require.NoError(t, os.WriteFile(path, []byte("second"), 0o600))
+	require.Eventually(t, func() bool { return f.Load() == "second" }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, "second", <-reloaded)
+
+	// Replacing the file by a rename, as atomic writers do, is also observed.
+	replacement := filepath.Join(filepath.Dir(path), "replacement")
+	require.NoError(t, os.WriteFile(replacement, []byte("third"), 0o600))
+	require.NoError(t, os.Rename(replacement, path))
+	require.Eventually(t, func() bool { return f.Load() == "third" }, 5*time.Second, 10*time.Millisecond)
+
+	// A value which fails to load leaves the previous value in use.
+	require.NoError(t, os.WriteFile(path, nil, 0o600))
+	time.Sleep(3 * debounce)
+	require.Equal(t, "third", f.Load())
+}
+
+func TestFileInitialLoadFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing")
+
+	_, err := NewFile("test", readFile(path), path)
+	require.ErrorContains(t, err, "unable to load test")
+}
@@ -0,0 +1,557 @@
+// Package impact estimates the changes to permissions which proposed relationship updates and
+// schema changes would make, by comparing the permissions of a bounded sample of the resources
+// they may affect before and after the changes.
+package impact
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/authzed/spicedb/internal/datastore/options"
+	"github.com/authzed/spicedb/internal/datastore/proxy"
+	"github.com/authzed/spicedb/internal/dispatch"
+	"github.com/authzed/spicedb/internal/dispatch/graph"
+	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
+	"github.com/authzed/spicedb/internal/namespace"
+	"github.com/authzed/spicedb/internal/relationships"
+	"github.com/authzed/spicedb/pkg/datastore"
+	nspkg "github.com/authzed/spicedb/pkg/namespace"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+	iv1 "github.com/authzed/spicedb/pkg/proto/impl/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+const dispatchConcurrencyLimit = 10
+
+// Kind is the kind of a change to a permission.
+type Kind int
+
+const (
+	// Gained indicates that the subject would gain the permission.
+	Gained Kind = iota
+
+	// Lost indicates that the subject would lose the permission.
+	Lost
+)
+
+// Change is a change to whether a subject has a permission on a resource.
+type Change struct {
+	// Resource is the resource, with the permission as its relation.
+	Resource *core.ObjectAndRelation
+	Subject  *core.ObjectAndRelation
+	Kind     Kind
+}
+
+// Params are the parameters for estimating the impact of changes.
+type Params struct {
+	// Datastore is the datastore to which the changes would be written.
+	Datastore datastore.Datastore
+
+	// Revision is the revision on top of which the changes are estimated.
+	Revision datastore.Revision
+
+	// MaximumDepth is the maximum depth of the dispatches comparing permissions.
+	MaximumDepth uint32
+
+	// MaxResources is the maximum number of resources whose permissions are compared.
+	MaxResources int
+
+	// SubjectTypes are the types of subjects whose permissions are compared. If empty, they are
+	// inferred from the changes.
+	SubjectTypes []*core.RelationReference
+}
+
+// Estimate is the estimated impact of changes.
+type Estimate struct {
+	Changes []Change
+
+	// ResourcesExamined is the number of resources whose permissions were compared.
+	ResourcesExamined int
+
+	// Truncated is true if more resources may be affected than were examined.
+	Truncated bool
+}
+
+// errMaxResources stops the search for affected resources once enough have been found.
+var errMaxResources = errors.New("maximum resources found")
+
+// EstimateImpact estimates the changes to permissions which writing the overlay would make. The
+// updates of the overlay must be valid under its schema, if any.
+//
+// The resources which may be affected are those reachable from the resources of the updated
+// relationships, along with a sample of the resources of the definitions changed by the schema,
+// up to the maximum number of resources. For each of them, the subjects of each permission are
+// looked up before and after the changes and compared. Caveats are not evaluated, so a subject
+// whose permission is conditional is compared as if it had the permission.
+//
+// Dispatches are made by a dispatcher local to the estimate, so that the results computed under
+// the overlay are never cached or dispatched to other servers.
+func EstimateImpact(ctx context.Context, params Params, overlay proxy.Overlay) (*Estimate, error) {
+	overlaid, err := proxy.NewOverlayDatastoreProxy(ctx, params.Datastore, overlay)
+	if err != nil {
+		return nil, err
+	}
+	defer overlaid.Close()
+
+	before, err := newState(ctx, params.Datastore, params.Revision)
+	if err != nil {
+		return nil, err
+	}
+	defer before.dispatcher.Close()
+
+	after, err := newState(ctx, overlaid, params.Revision)
+	if err != nil {
+		return nil, err
+	}
+	defer after.dispatcher.Close()
+
+	if err := relationships.ValidateRelationshipUpdates(ctx, after.reader, overlay.Updates); err != nil {
+		return nil, err
+	}
+
+	e := &estimator{
+		params:       params,
+		states:       []*state{before, after},
+		seen:         map[string]struct{}{},
+		subjectTypes: newRelationReferenceSet(params.SubjectTypes),
+	}
+	inferSubjectTypes := len(params.SubjectTypes) == 0
+
+	err = e.findResources(ctx, overlay, inferSubjectTypes)
+	if errors.Is(err, errMaxResources) {
+		e.truncated = true
+	} else if err != nil {
+		return nil, err
+	}
+
+	changes, err := e.compare()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Estimate{
+		Changes:           changes,
+		ResourcesExamined: len(e.resources),
+		Truncated:         e.truncated,
+	}, nil
+}
+
+// state is the datastore as it is before, or would be after, the changes.
+type state struct {
+	ctx        context.Context
+	reader     datastore.Reader
+	dispatcher dispatch.Dispatcher
+	revision   datastore.Revision
+	namespaces map[string]*core.NamespaceDefinition
+}
+
+func newState(ctx context.Context, ds datastore.Datastore, revision datastore.Revision) (*state, error) {
+	reader := ds.SnapshotReader(revision)
+	found, err := reader.ListAllNamespaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	namespaces := make(map[string]*core.NamespaceDefinition, len(found))
+	for _, ns := range found {
+		namespaces[ns.Definition.Name] = ns.Definition
+	}
+
+	return &state{
+		ctx:        datastoremw.ContextWithDatastore(ctx, ds),
+		reader:     reader,
+		dispatcher: graph.NewLocalOnlyDispatcher(dispatchConcurrencyLimit),
+		revision:   revision,
+		namespaces: namespaces,
+	}, nil
+}
+
+// hasRelation returns whether the relation, or permission, is defined in this state.
+func (s *state) hasRelation(rr *core.RelationReference) bool {
+	nsDef, ok := s.namespaces[rr.Namespace]
+	if !ok {
+		return false
+	}
+	if rr.Relation == tuple.Ellipsis {
+		return true
+	}
+
+	for _, relation := range nsDef.Relation {
+		if relation.Name == rr.Relation {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *state) metadata(maximumDepth uint32) *v1.ResolverMeta {
+	return &v1.ResolverMeta{
+		AtRevision:     s.revision.String(),
+		DepthRemaining: maximumDepth,
+	}
+}
+
+type resource struct {
+	namespace string
+	objectID  string
+}
+
+type estimator struct {
+	params       Params
+	states       []*state
+	subjectTypes *relationReferenceSet
+
+	resources []resource
+	seen      map[string]struct{}
+	truncated bool
+}
+
+// findResources finds the resources whose permissions may be changed by the overlay, and the
+// subject types to compare if they are to be inferred.
+func (e *estimator) findResources(ctx context.Context, overlay proxy.Overlay, inferSubjectTypes bool) error {
+	before, after := e.states[0], e.states[1]
+
+	var changed []*core.RelationReference
+	if overlay.Schema != nil {
+		var err error
+		changed, err = changedRelations(before.namespaces, after.namespaces)
+		if err != nil {
+			return err
+		}
+	}
+
+	if inferSubjectTypes {
+		for _, update := range overlay.Updates {
+			e.subjectTypes.add(&core.RelationReference{
+				Namespace: update.Tuple.Subject.Namespace,
+				Relation:  update.Tuple.Subject.Relation,
+			})
+		}
+		for _, rr := range changed {
+			e.subjectTypes.addAllowed(before.namespaces[rr.Namespace])
+			e.subjectTypes.addAllowed(after.namespaces[rr.Namespace])
+		}
+	}
+
+	for _, update := range overlay.Updates {
+		onr := update.Tuple.ResourceAndRelation
+		if err := e.addReachable(&core.RelationReference{
+			Namespace: onr.Namespace,
+			Relation:  onr.Relation,
+		}, []string{onr.ObjectId}); err != nil {
+			return err
+		}
+	}
+
+	sampled := make(map[string][]string)
+	for _, rr := range changed {
+		resourceIDs, ok := sampled[rr.Namespace]
+		if !ok {
+			var err error
+			resourceIDs, err = e.sampleResourceIDs(ctx, after.reader, rr.Namespace)
+			if err != nil {
+				return err
+			}
+			sampled[rr.Namespace] = resourceIDs
+		}
+
+		if err := e.addReachable(rr, resourceIDs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sampleResourceIDs returns the IDs of some of the resources of the definition which have
+// relationships.
+func (e *estimator) sampleResourceIDs(ctx context.Context, reader datastore.Reader, nsName string) ([]string, error) {
+	limit := uint64(e.params.MaxResources)
+	it, err := reader.QueryRelationships(ctx, datastore.RelationshipsFilter{
+		ResourceType: nsName,
+	}, options.WithLimit(&limit))
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	var resourceIDs []string
+	seen := make(map[string]struct{})
+	for {
+		tpl, err := it.Next()
+		if err != nil {
+			return nil, err
+		}
+		if tpl == nil {
+			break
+		}
+
+		if _, ok := seen[tpl.ResourceAndRelation.ObjectId]; !ok {
+			seen[tpl.ResourceAndRelation.ObjectId] = struct{}{}
+			resourceIDs = append(resourceIDs, tpl.ResourceAndRelation.ObjectId)
+		}
+	}
+	return resourceIDs, nil
+}
+
+// addReachable adds the resources and those whose permissions are reachable from the relation
+// on them, either before or after the changes.
+func (e *estimator) addReachable(rr *core.RelationReference, resourceIDs []string) error {
+	for _, resourceID := range resourceIDs {
+		if err := e.addResource(rr.Namespace, resourceID); err != nil {
+			return err
+		}
+	}
+
+	for _, s := range e.states {
+		if !s.hasRelation(rr) {
+			continue
+		}
+
+		for _, nsName := range sortedNames(s.namespaces) {
+			for _, relation := range s.namespaces[nsName].Relation {
+				if nspkg.GetRelationKind(relation) != iv1.RelationMetadata_PERMISSION {
+					continue
+				}
+
+				stream := dispatch.NewHandlingDispatchStream(s.ctx, func(result *v1.DispatchReachableResourcesResponse) error {
+					for _, found := range result.Resources {
+						if err := e.addResource(nsName, found.ResourceId); err != nil {
+							return err
+						}
+					}
+					return nil
+				})
+
+				err := s.dispatcher.DispatchReachableResources(&v1.DispatchReachableResourcesRequest{
+					Metadata: s.metadata(e.params.MaximumDepth),
+					ResourceRelation: &core.RelationReference{
+						Namespace: nsName,
+						Relation:  relation.Name,
+					},
+					SubjectRelation: rr,
+					SubjectIds:      resourceIDs,
+				}, stream)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (e *estimator) addResource(nsName, resourceID string) error {
+	key := nsName + ":" + resourceID
+	if _, ok := e.seen[key]; ok {
+		return nil
+	}
+	if len(e.resources) >= e.params.MaxResources {
+		return errMaxResources
+	}
+
+	e.seen[key] = struct{}{}
+	e.resources = append(e.resources, resource{namespace: nsName, objectID: resourceID})
+	return nil
+}
+
+// compare compares the subjects of the permissions of each resource found, before and after
+// the changes.
+func (e *estimator) compare() ([]Change, error) {
+	before, after := e.states[0], e.states[1]
+
+	var changes []Change
+	for _, res := range e.resources {
+		for _, permission := range permissionNames(before.namespaces[res.namespace], after.namespaces[res.namespace]) {
+			onr := &core.ObjectAndRelation{
+				Namespace: res.namespace,
+				ObjectId:  res.objectID,
+				Relation:  permission,
+			}
+
+			for _, subjectType := range e.subjectTypes.items {
+				subjectsBefore, err := e.lookupSubjects(before, onr, subjectType)
+				if err != nil {
+					return nil, err
+				}
+
+				subjectsAfter, err := e.lookupSubjects(after, onr, subjectType)
+				if err != nil {
+					return nil, err
+				}
+
+				changes = appendChanges(changes, onr, subjectType, subjectsAfter, subjectsBefore, Gained)
+				changes = appendChanges(changes, onr, subjectType, subjectsBefore, subjectsAfter, Lost)
+			}
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		left, right := tuple.StringONR(changes[i].Resource), tuple.StringONR(changes[j].Resource)
+		if left != right {
+			return left < right
+		}
+		return tuple.StringONR(changes[i].Subject) < tuple.StringONR(changes[j].Subject)
+	})
+	return changes, nil
+}
+
+// lookupSubjects returns the IDs of the subjects of the type which have the permission on the
+// resource. A permission or subject type which is not defined has no subjects.
+func (e *estimator) lookupSubjects(s *state, onr *core.ObjectAndRelation, subjectType *core.RelationReference) (map[string]struct{}, error) {
+	found := make(map[string]struct{})
+	if !s.hasRelation(&core.RelationReference{Namespace: onr.Namespace, Relation: onr.Relation}) || !s.hasRelation(subjectType) {
+		return found, nil
+	}
+
+	stream := dispatch.NewHandlingDispatchStream(s.ctx, func(result *v1.DispatchLookupSubjectsResponse) error {
+		for _, subject := range result.FoundSubjectsByResourceId[onr.ObjectId].GetFoundSubjects() {
+			found[subject.SubjectId] = struct{}{}
+		}
+		return nil
+	})
+
+	err := s.dispatcher.DispatchLookupSubjects(&v1.DispatchLookupSubjectsRequest{
+		Metadata: s.metadata(e.params.MaximumDepth),
+		ResourceRelation: &core.RelationReference{
+			Namespace: onr.Namespace,
+			Relation:  onr.Relation,
+		},
+		ResourceIds:     []string{onr.ObjectId},
+		SubjectRelation: subjectType,
+	}, stream)
+	return found, err
+}
+
+// appendChanges appends a change of the kind for each subject found in subjects but not in
+// others.
+func appendChanges(changes []Change, onr *core.ObjectAndRelation, subjectType *core.RelationReference, subjects, others map[string]struct{}, kind Kind) []Change {
+	for subjectID := range subjects {
+		if _, ok := others[subjectID]; ok {
+			continue
+		}
+
+		changes = append(changes, Change{
+			Resource: onr,
+			Subject: &core.ObjectAndRelation{
+				Namespace: subjectType.Namespace,
+				ObjectId:  subjectID,
+				Relation:  subjectType.Relation,
+			},
+			Kind: kind,
+		})
+	}
+	return changes
+}
+
+// changedRelations returns the relations and permissions which differ between the definitions,
+// ordered by definition.
+func changedRelations(before, after map[string]*core.NamespaceDefinition) ([]*core.RelationReference, error) {
+	names := sortedNames(before)
+	for _, name := range sortedNames(after) {
+		if _, ok := before[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var changed []*core.RelationReference
+	for _, name := range names {
+		diff, err := namespace.DiffNamespaces(before[name], after[name])
+		if err != nil {
+			return nil, err
+		}
+
+		relations := make(map[string]struct{})
+		for _, delta := range diff.Deltas() {
+			switch delta.Type {
+			case namespace.NamespaceAdded, namespace.NamespaceRemoved:
+				for _, relation := range permissionNames(before[name], after[name]) {
+					relations[relation] = struct{}{}
+				}
+			default:
+				if delta.RelationName != "" {
+					relations[delta.RelationName] = struct{}{}
+				}
+			}
+		}
+
+		for _, relation := range sortedKeys(relations) {
+			changed = append(changed, &core.RelationReference{Namespace: name, Relation: relation})
+		}
+	}
+	return changed, nil
+}
+
+// permissionNames returns the names of the permissions of either definition, which may be nil.
+func permissionNames(defs ...*core.NamespaceDefinition) []string {
+	names := make(map[string]struct{})
+	for _, def := range defs {
+		for _, relation := range def.GetRelation() {
+			if nspkg.GetRelationKind(relation) == iv1.RelationMetadata_PERMISSION {
+				names[relation.Name] = struct{}{}
+			}
+		}
+	}
+	return sortedKeys(names)
+}
+
+func sortedNames(namespaces map[string]*core.NamespaceDefinition) []string {
+	names := make([]string, 0, len(namespaces))
+	for name := range namespaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedKeys(set map[string]struct{}) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// relationReferenceSet is an ordered set of subject types.
+type relationReferenceSet struct {
+	items []*core.RelationReference
+	seen  map[string]struct{}
+}
+
+func newRelationReferenceSet(items []*core.RelationReference) *relationReferenceSet {
+	set := &relationReferenceSet{seen: make(map[string]struct{})}
+	for _, item := range items {
+		set.add(item)
+	}
+	return set
+}
+
+func (rs *relationReferenceSet) add(rr *core.RelationReference) {
+	key := tuple.StringRR(rr)
+	if _, ok := rs.seen[key]; ok {
+		return
+	}
+	rs.seen[key] = struct{}{}
+	rs.items = append(rs.items, rr)
+}
+
+// addAllowed adds the types of subjects allowed directly on the relations of the definition,
+// which may be nil. Wildcards are added as their subject type.
+func (rs *relationReferenceSet) addAllowed(def *core.NamespaceDefinition) {
+	for _, relation := range def.GetRelation() {
+		for _, allowed := range relation.GetTypeInformation().GetAllowedDirectRelations() {
+			subjectRelation := allowed.GetRelation()
+			if allowed.GetPublicWildcard() != nil {
+				subjectRelation = tuple.Ellipsis
+			}
+
+			rs.add(&core.RelationReference{
+				Namespace: allowed.Namespace,
+				Relation:  subjectRelation,
+			})
+		}
+	}
+}
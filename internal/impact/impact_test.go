@@ -0,0 +1,155 @@
+package impact
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/internal/datastore/proxy"
+	"github.com/authzed/spicedb/internal/services/shared"
+	"github.com/authzed/spicedb/internal/testfixtures"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
+	"github.com/authzed/spicedb/pkg/schemadsl/input"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+const testSchema = `
+	definition user {}
+
+	definition group {
+		relation member: user
+	}
+
+	definition document {
+		relation viewer: user | group#member
+		relation editor: user
+		permission view = viewer
+	}
+`
+
+func newTestDatastore(t *testing.T) (datastore.Datastore, datastore.Revision) {
+	rawDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	require.NoError(t, err)
+
+	return testfixtures.DatastoreFromSchemaAndTestRelationships(rawDS, testSchema, []*core.RelationTuple{
+		tuple.MustParse("group:eng#member@user:tom"),
+		tuple.MustParse("document:first#viewer@group:eng#member"),
+		tuple.MustParse("document:second#viewer@user:sarah"),
+		tuple.MustParse("document:third#editor@user:amy"),
+	}, require.New(t))
+}
+
+func changeStrings(changes []Change) []string {
+	found := make([]string, 0, len(changes))
+	for _, change := range changes {
+		kind := "gained"
+		if change.Kind == Lost {
+			kind = "lost"
+		}
+		found = append(found, kind+" "+tuple.StringONR(change.Resource)+" "+tuple.StringONR(change.Subject))
+	}
+	return found
+}
+
+func TestEstimateImpact(t *testing.T) {
+	ds, rev := newTestDatastore(t)
+
+	estimate, err := EstimateImpact(context.Background(), Params{
+		Datastore:    ds,
+		Revision:     rev,
+		MaximumDepth: 50,
+		MaxResources: 100,
+	}, proxy.Overlay{
+		Updates: []*core.RelationTupleUpdate{
+			tuple.Create(tuple.MustParse("group:eng#member@user:fred")),
+			tuple.Delete(tuple.MustParse("document:second#viewer@user:sarah")),
+		},
+	})
+	require.NoError(t, err)
+	require.False(t, estimate.Truncated)
+	require.Equal(t, 3, estimate.ResourcesExamined)
+	require.Equal(t, []string{
+		"gained document:first#view user:fred",
+		"lost document:second#view user:sarah",
+	}, changeStrings(estimate.Changes))
+}
+
+func TestEstimateImpactOfSchema(t *testing.T) {
+	ds, rev := newTestDatastore(t)
+
+	emptyDefaultPrefix := ""
+	compiled, err := compiler.Compile(compiler.InputSchema{
+		Source: input.Source("schema"),
+		SchemaString: `
+			definition user {}
+
+			definition group {
+				relation member: user
+			}
+
+			definition document {
+				relation viewer: user | group#member
+				relation editor: user
+				permission view = viewer + editor
+			}
+		`,
+	}, &emptyDefaultPrefix)
+	require.NoError(t, err)
+
+	_, err = shared.ValidateSchemaChanges(context.Background(), compiled, false)
+	require.NoError(t, err)
+
+	estimate, err := EstimateImpact(context.Background(), Params{
+		Datastore:    ds,
+		Revision:     rev,
+		MaximumDepth: 50,
+		MaxResources: 100,
+	}, proxy.Overlay{
+		Schema: &proxy.OverlaySchema{Namespaces: compiled.ObjectDefinitions},
+	})
+	require.NoError(t, err)
+	require.False(t, estimate.Truncated)
+	require.Equal(t, []string{
+		"gained document:third#view user:amy",
+	}, changeStrings(estimate.Changes))
+}
+
+func TestEstimateImpactTruncated(t *testing.T) {
+	ds, rev := newTestDatastore(t)
+
+	estimate, err := EstimateImpact(context.Background(), Params{
+		Datastore:    ds,
+		Revision:     rev,
+		MaximumDepth: 50,
+		MaxResources: 1,
+		SubjectTypes: []*core.RelationReference{{Namespace: "user", Relation: tuple.Ellipsis}},
+	}, proxy.Overlay{
+		Updates: []*core.RelationTupleUpdate{
+			tuple.Create(tuple.MustParse("group:eng#member@user:fred")),
+		},
+	})
+	require.NoError(t, err)
+	require.True(t, estimate.Truncated)
+	require.Equal(t, 1, estimate.ResourcesExamined)
+	require.Empty(t, estimate.Changes)
+}
+
+func TestEstimateImpactInvalidUpdate(t *testing.T) {
+	ds, rev := newTestDatastore(t)
+
+	_, err := EstimateImpact(context.Background(), Params{
+		Datastore:    ds,
+		Revision:     rev,
+		MaximumDepth: 50,
+		MaxResources: 100,
+	}, proxy.Overlay{
+		Updates: []*core.RelationTupleUpdate{
+			tuple.Create(tuple.MustParse("document:first#view@user:fred")),
+		},
+	})
+	require.Error(t, err)
+}
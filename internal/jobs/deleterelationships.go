@@ -0,0 +1,143 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/authzed/spicedb/internal/datastore/options"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+const (
+	// KindDeleteRelationships is the kind of the jobs deleting the relationships matching a
+	// filter, in batches.
+	KindDeleteRelationships = "delete-relationships"
+
+	// DefaultDeleteBatchSize is the default number of relationships deleted by each
+	// transaction of a delete relationships job.
+	DefaultDeleteBatchSize = 1000
+)
+
+// DeleteRelationshipsParameters are the parameters of a delete relationships job.
+type DeleteRelationshipsParameters struct {
+	// Filter is the relationship filter, as JSON.
+	Filter    json.RawMessage `json:"filter"`
+	BatchSize uint64          `json:"batch_size"`
+}
+
+// NewDeleteRelationshipsParameters returns the serialized parameters of a job deleting the
+// relationships matching the filter, in batches of the given size.
+func NewDeleteRelationshipsParameters(filter *v1.RelationshipFilter, batchSize uint64) (json.RawMessage, error) {
+	if batchSize == 0 {
+		batchSize = DefaultDeleteBatchSize
+	}
+
+	serialized, err := protojson.Marshal(filter)
+	if err != nil {
+		return nil, fmt.Errorf("unable to serialize relationship filter: %w", err)
+	}
+
+	return json.Marshal(DeleteRelationshipsParameters{Filter: serialized, BatchSize: batchSize})
+}
+
+// DeleteRelationships returns the runner of the jobs deleting relationships from the datastore.
+// Each batch is deleted in its own transaction, so the deletion is not atomic: relationships
+// matching the filter which are written while the job runs may or may not be deleted.
+func DeleteRelationships(ds datastore.Datastore) RunFunc {
+	return func(ctx context.Context, parameters json.RawMessage, progress ProgressFunc) error {
+		var params DeleteRelationshipsParameters
+		if err := json.Unmarshal(parameters, &params); err != nil {
+			return fmt.Errorf("invalid parameters: %w", err)
+		}
+
+		filter := &v1.RelationshipFilter{}
+		if err := protojson.Unmarshal(params.Filter, filter); err != nil {
+			return fmt.Errorf("invalid relationship filter: %w", err)
+		}
+		dsFilter := datastore.RelationshipsFilterFromPublicFilter(filter)
+
+		headRevision, err := ds.HeadRevision(ctx)
+		if err != nil {
+			return err
+		}
+
+		total, err := countRelationships(ctx, ds.SnapshotReader(headRevision), dsFilter)
+		if err != nil {
+			return err
+		}
+
+		var deleted uint64
+		progress(deleted, total)
+		for {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			var batchCount uint64
+			if _, err := ds.ReadWriteTx(ctx, func(rwt datastore.ReadWriteTransaction) error {
+				iter, err := rwt.QueryRelationships(ctx, dsFilter, options.WithLimit(&params.BatchSize))
+				if err != nil {
+					return err
+				}
+
+				var deletes []*core.RelationTupleUpdate
+				for {
+					tpl, err := iter.Next()
+					if err != nil {
+						iter.Close()
+						return err
+					}
+					if tpl == nil {
+						break
+					}
+					deletes = append(deletes, tuple.Delete(tpl))
+				}
+				iter.Close()
+
+				batchCount = uint64(len(deletes))
+				if batchCount == 0 {
+					return nil
+				}
+				return rwt.WriteRelationships(ctx, deletes)
+			}); err != nil {
+				return err
+			}
+
+			if batchCount == 0 {
+				return nil
+			}
+
+			deleted += batchCount
+			if deleted > total {
+				total = deleted
+			}
+			progress(deleted, total)
+		}
+	}
+}
+
+func countRelationships(ctx context.Context, reader datastore.Reader, filter datastore.RelationshipsFilter) (uint64, error) {
+	iter, err := reader.QueryRelationships(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	defer iter.Close()
+
+	var count uint64
+	for {
+		tpl, err := iter.Next()
+		if err != nil {
+			return 0, err
+		}
+		if tpl == nil {
+			return count, nil
+		}
+		count++
+	}
+}
@@ -0,0 +1,459 @@
+// Package jobs runs long-running tasks, such as bulk deletes, as jobs whose state is persisted
+// in the datastore, so that their status and progress can be read and their cancellation
+// requested from any server.
+//
+// Each server runs a Manager, which claims the pending jobs of the kinds for which it has a
+// runner by taking a lease on them in the datastore, and renews the lease while the job runs.
+// Claims and renewals are compare-and-swap updates of the stored job, so that only one server
+// runs each job at a time. A job whose lease expires, because its server stopped, is claimed
+// again by another server and run from the beginning: runners must therefore be safe to rerun.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
+	log "github.com/authzed/spicedb/internal/logging"
+)
+
+const (
+	// DefaultLeaseDuration is the default duration of the lease taken by a server on a job,
+	// after which the job is claimed by another server unless the lease is renewed.
+	DefaultLeaseDuration = 30 * time.Second
+
+	// DefaultPollInterval is the default interval at which a server looks for jobs to claim.
+	DefaultPollInterval = 5 * time.Second
+
+	maxUpdateAttempts = 10
+)
+
+var (
+	// ErrUnknownKind is returned when submitting a job of a kind without a runner.
+	ErrUnknownKind = errors.New("unknown job kind")
+
+	// ErrJobFinished is returned when cancelling a job which has already finished.
+	ErrJobFinished = errors.New("the job has already finished")
+
+	errLeaseLost = errors.New("the lease on the job was lost")
+)
+
+// State is the state of a job.
+type State string
+
+const (
+	// StatePending is the state of a job waiting to be claimed by a server.
+	StatePending State = "pending"
+
+	// StateRunning is the state of a job being run by a server.
+	StateRunning State = "running"
+
+	// StateSucceeded is the state of a job whose runner completed.
+	StateSucceeded State = "succeeded"
+
+	// StateFailed is the state of a job whose runner returned an error.
+	StateFailed State = "failed"
+
+	// StateCancelled is the state of a job cancelled before it completed.
+	StateCancelled State = "cancelled"
+)
+
+// Finished returns whether the state is final.
+func (s State) Finished() bool {
+	return s == StateSucceeded || s == StateFailed || s == StateCancelled
+}
+
+// Job is the state of a job.
+type Job struct {
+	ID         string          `json:"id"`
+	Kind       string          `json:"kind"`
+	Parameters json.RawMessage `json:"parameters,omitempty"`
+	State      State           `json:"state"`
+
+	// Completed and Total are the progress of the job, in units of work defined by its kind.
+	Completed uint64 `json:"completed"`
+	Total     uint64 `json:"total"`
+
+	// Error is the error returned by the runner of a failed job.
+	Error string `json:"error,omitempty"`
+
+	// Owner is the server holding the lease on a running job, until LeaseExpiresAt.
+	Owner          string    `json:"owner,omitempty"`
+	LeaseExpiresAt time.Time `json:"lease_expires_at"`
+
+	// CancelRequested is set when the cancellation of a running job has been requested, and
+	// is observed by its server when it next renews its lease.
+	CancelRequested bool `json:"cancel_requested,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ProgressFunc reports the progress of a job.
+type ProgressFunc func(completed, total uint64)
+
+// RunFunc runs a job with the given parameters, reporting its progress, until it completes or
+// the context is canceled.
+type RunFunc func(ctx context.Context, parameters json.RawMessage, progress ProgressFunc) error
+
+// Manager submits jobs and runs those of the kinds for which it has a runner.
+type Manager struct {
+	store         common.JobStore
+	owner         string
+	leaseDuration time.Duration
+	pollInterval  time.Duration
+
+	sync.Mutex
+	runners map[string]RunFunc
+	running map[string]struct{}
+}
+
+// NewManager creates a new job manager storing jobs in the given store, taking leases of the
+// given duration on the jobs it runs, and looking for jobs to claim at the poll interval. Zero
+// durations are replaced by their defaults.
+func NewManager(store common.JobStore, leaseDuration time.Duration, pollInterval time.Duration) *Manager {
+	if leaseDuration == 0 {
+		leaseDuration = DefaultLeaseDuration
+	}
+	if pollInterval == 0 {
+		pollInterval = DefaultPollInterval
+	}
+
+	return &Manager{
+		store:         store,
+		owner:         uuid.NewString(),
+		leaseDuration: leaseDuration,
+		pollInterval:  pollInterval,
+		runners:       make(map[string]RunFunc),
+		running:       make(map[string]struct{}),
+	}
+}
+
+// Register registers the runner of the jobs of the given kind.
+func (m *Manager) Register(kind string, run RunFunc) {
+	m.Lock()
+	defer m.Unlock()
+	m.runners[kind] = run
+}
+
+func (m *Manager) runner(kind string) (RunFunc, bool) {
+	m.Lock()
+	defer m.Unlock()
+	run, ok := m.runners[kind]
+	return run, ok
+}
+
+// Submit stores a new pending job of the given kind, to be run by the first server to claim it.
+func (m *Manager) Submit(ctx context.Context, kind string, parameters json.RawMessage) (Job, error) {
+	if _, ok := m.runner(kind); !ok {
+		return Job{}, fmt.Errorf("%w: %s", ErrUnknownKind, kind)
+	}
+
+	now := time.Now().UTC()
+	job := Job{
+		ID:         uuid.NewString(),
+		Kind:       kind,
+		Parameters: parameters,
+		State:      StatePending,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+
+	data, err := json.Marshal(job)
+	if err != nil {
+		return Job{}, fmt.Errorf("unable to serialize job: %w", err)
+	}
+	if err := m.store.CreateJob(ctx, job.ID, data); err != nil {
+		return Job{}, err
+	}
+	return job, nil
+}
+
+// Get returns the job with the given ID, or common.ErrJobNotFound.
+func (m *Manager) Get(ctx context.Context, id string) (Job, error) {
+	stored, err := m.store.ReadJob(ctx, id)
+	if err != nil {
+		return Job{}, err
+	}
+	return decodeJob(stored)
+}
+
+// List returns every job.
+func (m *Manager) List(ctx context.Context) ([]Job, error) {
+	stored, err := m.store.ListJobs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]Job, 0, len(stored))
+	for _, storedJob := range stored {
+		job, err := decodeJob(storedJob)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// Cancel cancels a pending job immediately, or requests the cancellation of a running job,
+// which is cancelled once its server observes the request.
+func (m *Manager) Cancel(ctx context.Context, id string) (Job, error) {
+	job, err := m.update(ctx, id, func(job *Job) error {
+		switch {
+		case job.State.Finished():
+			return ErrJobFinished
+		case job.State == StatePending:
+			job.State = StateCancelled
+		default:
+			job.CancelRequested = true
+		}
+		return nil
+	})
+	return job, err
+}
+
+// Run claims and runs jobs until the context is canceled.
+func (m *Manager) Run(ctx context.Context) error {
+	log.Ctx(ctx).Info().Str("owner", m.owner).Msg("job manager started")
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	next := time.After(0)
+	for {
+		select {
+		case <-ctx.Done():
+			log.Ctx(ctx).Info().Msg("shutting down job manager")
+			return nil
+
+		case <-next:
+			next = time.After(m.pollInterval)
+
+			jobs, err := m.List(ctx)
+			if err != nil {
+				log.Ctx(ctx).Warn().Err(err).Msg("error listing jobs")
+				continue
+			}
+
+			for _, job := range jobs {
+				if !m.claimable(job) {
+					continue
+				}
+
+				claimed, err := m.claim(ctx, job.ID)
+				if err != nil {
+					log.Ctx(ctx).Warn().Err(err).Str("job", job.ID).Msg("error claiming job")
+					continue
+				}
+				if claimed == nil {
+					continue
+				}
+
+				m.Lock()
+				m.running[claimed.ID] = struct{}{}
+				m.Unlock()
+
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					m.execute(ctx, *claimed)
+
+					m.Lock()
+					delete(m.running, claimed.ID)
+					m.Unlock()
+				}()
+			}
+		}
+	}
+}
+
+// claimable returns whether the job is pending, or running under an expired lease, and of a
+// kind with a runner.
+func (m *Manager) claimable(job Job) bool {
+	switch job.State {
+	case StatePending:
+	case StateRunning:
+		if time.Now().Before(job.LeaseExpiresAt) {
+			return false
+		}
+	default:
+		return false
+	}
+
+	if _, ok := m.runner(job.Kind); !ok {
+		return false
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	_, running := m.running[job.ID]
+	return !running
+}
+
+// claim takes the lease on a job, returning nil if the job is no longer claimable.
+func (m *Manager) claim(ctx context.Context, id string) (*Job, error) {
+	errNotClaimable := errors.New("not claimable")
+	job, err := m.update(ctx, id, func(job *Job) error {
+		if !m.claimable(*job) {
+			return errNotClaimable
+		}
+		if job.CancelRequested {
+			job.State = StateCancelled
+			return nil
+		}
+
+		job.State = StateRunning
+		job.Owner = m.owner
+		job.LeaseExpiresAt = time.Now().UTC().Add(m.leaseDuration)
+		return nil
+	})
+	if errors.Is(err, errNotClaimable) || (err == nil && job.State != StateRunning) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// execute runs a claimed job, renewing its lease and persisting its progress until the runner
+// returns, and then records its final state.
+func (m *Manager) execute(ctx context.Context, job Job) {
+	run, _ := m.runner(job.Kind)
+	logger := log.Ctx(ctx).With().Str("job", job.ID).Str("kind", job.Kind).Logger()
+	logger.Info().Msg("running job")
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		progressLock     sync.Mutex
+		completed, total = job.Completed, job.Total
+	)
+	progress := func(c, t uint64) {
+		progressLock.Lock()
+		defer progressLock.Unlock()
+		completed, total = c, t
+	}
+	withProgress := func(job *Job) {
+		progressLock.Lock()
+		defer progressLock.Unlock()
+		job.Completed, job.Total = completed, total
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- run(runCtx, job.Parameters, progress)
+	}()
+
+	cancelRequested := false
+	renew := time.NewTicker(m.leaseDuration / 3)
+	defer renew.Stop()
+	for {
+		select {
+		case <-renew.C:
+			renewed, err := m.update(ctx, job.ID, func(job *Job) error {
+				if job.Owner != m.owner || job.State != StateRunning {
+					return errLeaseLost
+				}
+				withProgress(job)
+				job.LeaseExpiresAt = time.Now().UTC().Add(m.leaseDuration)
+				return nil
+			})
+			switch {
+			case errors.Is(err, errLeaseLost):
+				logger.Warn().Msg("lost the lease on the job; stopping it")
+				cancel()
+				<-done
+				return
+			case err != nil:
+				logger.Warn().Err(err).Msg("error renewing the lease on the job")
+			case renewed.CancelRequested && !cancelRequested:
+				logger.Info().Msg("cancellation of the job requested")
+				cancelRequested = true
+				cancel()
+			}
+
+		case runErr := <-done:
+			if ctx.Err() != nil {
+				// The server is shutting down: the job is left to be claimed again once its
+				// lease expires.
+				return
+			}
+
+			final, err := m.update(ctx, job.ID, func(job *Job) error {
+				if job.Owner != m.owner || job.State != StateRunning {
+					return errLeaseLost
+				}
+				withProgress(job)
+				job.LeaseExpiresAt = time.Time{}
+				switch {
+				case runErr == nil:
+					job.State = StateSucceeded
+				case job.CancelRequested && errors.Is(runErr, context.Canceled):
+					job.State = StateCancelled
+				default:
+					job.State = StateFailed
+					job.Error = runErr.Error()
+				}
+				return nil
+			})
+			if err != nil {
+				logger.Warn().Err(err).Msg("unable to record the final state of the job")
+				return
+			}
+			logger.Info().Str("state", string(final.State)).Msg("job finished")
+			return
+		}
+	}
+}
+
+// update applies the mutation to the stored job, retrying on concurrent updates, and returns
+// the updated job. Errors returned by the mutation abort the update.
+func (m *Manager) update(ctx context.Context, id string, mutate func(job *Job) error) (Job, error) {
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		stored, err := m.store.ReadJob(ctx, id)
+		if err != nil {
+			return Job{}, err
+		}
+
+		job, err := decodeJob(stored)
+		if err != nil {
+			return Job{}, err
+		}
+
+		if err := mutate(&job); err != nil {
+			return job, err
+		}
+		job.UpdatedAt = time.Now().UTC()
+
+		data, err := json.Marshal(job)
+		if err != nil {
+			return Job{}, fmt.Errorf("unable to serialize job: %w", err)
+		}
+
+		updated, err := m.store.UpdateJob(ctx, id, stored.Version, data)
+		if err != nil {
+			return Job{}, err
+		}
+		if updated {
+			return job, nil
+		}
+	}
+	return Job{}, fmt.Errorf("unable to update job %s: too many concurrent updates", id)
+}
+
+func decodeJob(stored common.StoredJob) (Job, error) {
+	var job Job
+	if err := json.Unmarshal(stored.Data, &job); err != nil {
+		return Job{}, fmt.Errorf("unable to deserialize job %s: %w", stored.ID, err)
+	}
+	return job, nil
+}
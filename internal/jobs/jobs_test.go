@@ -0,0 +1,214 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/internal/testfixtures"
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+func newTestManager(t *testing.T) (*Manager, common.JobStore) {
+	ds, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	require.NoError(t, err)
+	t.Cleanup(func() { ds.Close() })
+
+	store := ds.(common.JobStore)
+	return NewManager(store, 300*time.Millisecond, 10*time.Millisecond), store
+}
+
+func runManager(t *testing.T, m *Manager) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- m.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, <-done)
+	})
+}
+
+func waitForState(t *testing.T, m *Manager, id string, state State) Job {
+	var job Job
+	require.Eventually(t, func() bool {
+		var err error
+		job, err = m.Get(context.Background(), id)
+		require.NoError(t, err)
+		return job.State == state
+	}, 5*time.Second, 10*time.Millisecond)
+	return job
+}
+
+func TestManagerRunsJobs(t *testing.T) {
+	m, _ := newTestManager(t)
+	m.Register("succeeds", func(ctx context.Context, parameters json.RawMessage, progress ProgressFunc) error {
+		require.JSONEq(t, `{"count":3}`, string(parameters))
+		progress(3, 3)
+		return nil
+	})
+	m.Register("fails", func(ctx context.Context, parameters json.RawMessage, progress ProgressFunc) error {
+		return errors.New("something went wrong")
+	})
+
+	_, err := m.Submit(context.Background(), "unknown", nil)
+	require.ErrorIs(t, err, ErrUnknownKind)
+
+	succeeds, err := m.Submit(context.Background(), "succeeds", json.RawMessage(`{"count":3}`))
+	require.NoError(t, err)
+	require.Equal(t, StatePending, succeeds.State)
+
+	fails, err := m.Submit(context.Background(), "fails", nil)
+	require.NoError(t, err)
+
+	runManager(t, m)
+
+	job := waitForState(t, m, succeeds.ID, StateSucceeded)
+	require.Equal(t, uint64(3), job.Completed)
+	require.Equal(t, uint64(3), job.Total)
+
+	job = waitForState(t, m, fails.ID, StateFailed)
+	require.Equal(t, "something went wrong", job.Error)
+
+	listed, err := m.List(context.Background())
+	require.NoError(t, err)
+	require.Len(t, listed, 2)
+
+	_, err = m.Cancel(context.Background(), succeeds.ID)
+	require.ErrorIs(t, err, ErrJobFinished)
+
+	_, err = m.Get(context.Background(), "missing")
+	require.ErrorIs(t, err, common.ErrJobNotFound)
+}
+
+func TestManagerCancelsJobs(t *testing.T) {
+	m, _ := newTestManager(t)
+	started := make(chan struct{})
+	m.Register("blocks", func(ctx context.Context, parameters json.RawMessage, progress ProgressFunc) error {
+		progress(1, 10)
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	pending, err := m.Submit(context.Background(), "blocks", nil)
+	require.NoError(t, err)
+	cancelled, err := m.Cancel(context.Background(), pending.ID)
+	require.NoError(t, err)
+	require.Equal(t, StateCancelled, cancelled.State)
+
+	running, err := m.Submit(context.Background(), "blocks", nil)
+	require.NoError(t, err)
+
+	runManager(t, m)
+	<-started
+
+	requested, err := m.Cancel(context.Background(), running.ID)
+	require.NoError(t, err)
+	require.True(t, requested.CancelRequested)
+
+	job := waitForState(t, m, running.ID, StateCancelled)
+	require.Equal(t, uint64(1), job.Completed)
+	require.Equal(t, uint64(10), job.Total)
+}
+
+func TestManagerRunsEachJobOnce(t *testing.T) {
+	first, store := newTestManager(t)
+	second := NewManager(store, 300*time.Millisecond, 10*time.Millisecond)
+
+	var runs int32
+	run := func(ctx context.Context, parameters json.RawMessage, progress ProgressFunc) error {
+		atomic.AddInt32(&runs, 1)
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	}
+	first.Register("counted", run)
+	second.Register("counted", run)
+
+	var submitted []Job
+	for i := 0; i < 5; i++ {
+		job, err := first.Submit(context.Background(), "counted", nil)
+		require.NoError(t, err)
+		submitted = append(submitted, job)
+	}
+
+	runManager(t, first)
+	runManager(t, second)
+
+	for _, job := range submitted {
+		waitForState(t, first, job.ID, StateSucceeded)
+	}
+	require.Equal(t, int32(len(submitted)), atomic.LoadInt32(&runs))
+}
+
+func TestManagerReclaimsExpiredLeases(t *testing.T) {
+	m, store := newTestManager(t)
+	m.Register("succeeds", func(ctx context.Context, parameters json.RawMessage, progress ProgressFunc) error {
+		return nil
+	})
+
+	// Simulate a job left running by a server which stopped.
+	abandoned, err := m.Submit(context.Background(), "succeeds", nil)
+	require.NoError(t, err)
+	stored, err := store.ReadJob(context.Background(), abandoned.ID)
+	require.NoError(t, err)
+
+	abandoned.State = StateRunning
+	abandoned.Owner = "stopped-server"
+	abandoned.LeaseExpiresAt = time.Now().Add(-time.Second)
+	data, err := json.Marshal(abandoned)
+	require.NoError(t, err)
+	updated, err := store.UpdateJob(context.Background(), abandoned.ID, stored.Version, data)
+	require.NoError(t, err)
+	require.True(t, updated)
+
+	runManager(t, m)
+	job := waitForState(t, m, abandoned.ID, StateSucceeded)
+	require.Equal(t, m.owner, job.Owner)
+}
+
+func TestDeleteRelationshipsJob(t *testing.T) {
+	rawDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	require.NoError(t, err)
+	t.Cleanup(func() { rawDS.Close() })
+
+	ds, _ := testfixtures.StandardDatastoreWithData(rawDS, require.New(t))
+
+	m := NewManager(rawDS.(common.JobStore), 300*time.Millisecond, 10*time.Millisecond)
+	m.Register(KindDeleteRelationships, DeleteRelationships(ds))
+
+	filter := &v1.RelationshipFilter{ResourceType: "document"}
+	parameters, err := NewDeleteRelationshipsParameters(filter, 2)
+	require.NoError(t, err)
+
+	headRevision, err := ds.HeadRevision(context.Background())
+	require.NoError(t, err)
+	expected, err := countRelationships(context.Background(), ds.SnapshotReader(headRevision), datastore.RelationshipsFilterFromPublicFilter(filter))
+	require.NoError(t, err)
+	require.Greater(t, expected, uint64(2))
+
+	submitted, err := m.Submit(context.Background(), KindDeleteRelationships, parameters)
+	require.NoError(t, err)
+
+	runManager(t, m)
+	job := waitForState(t, m, submitted.ID, StateSucceeded)
+	require.Equal(t, expected, job.Completed)
+	require.Equal(t, expected, job.Total)
+
+	headRevision, err = ds.HeadRevision(context.Background())
+	require.NoError(t, err)
+	remaining, err := countRelationships(context.Background(), ds.SnapshotReader(headRevision), datastore.RelationshipsFilterFromPublicFilter(filter))
+	require.NoError(t, err)
+	require.Zero(t, remaining)
+
+	others, err := countRelationships(context.Background(), ds.SnapshotReader(headRevision), datastore.RelationshipsFilter{ResourceType: "folder"})
+	require.NoError(t, err)
+	require.NotZero(t, others)
+}
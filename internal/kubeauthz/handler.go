@@ -0,0 +1,232 @@
+// Package kubeauthz implements the Kubernetes authorization webhook, answering the
+// SubjectAccessReviews sent by the API server with CheckPermission requests.
+//
+// Requests are mapped onto the schema by convention:
+//
+//   - A request on a namespaced resource checks a permission on `<namespace type>:<namespace>`,
+//     and one on a cluster-scoped resource checks a permission on `<cluster type>:<cluster id>`.
+//   - The permission checked is `<verb>_<resource>`, or `<verb>_<resource>_<subresource>` for
+//     subresources, with any character not valid in a permission name replaced by `_`: getting the
+//     logs of a pod checks `get_pods_log`. The API group of the resource is not considered.
+//   - The subject is `<user type>:<user>` and, failing that, `<group type>:<group>#<group relation>`
+//     for each group of the user. Characters of users and groups not valid in object IDs are
+//     escaped as `|` followed by their hexadecimal value, e.g. `system:admin` as `system|3Aadmin`.
+//
+// Requests on non-resource URLs are left to other authorizers.
+package kubeauthz
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+
+	log "github.com/authzed/spicedb/internal/logging"
+)
+
+// Config configures the convention by which SubjectAccessReviews are mapped onto the schema.
+type Config struct {
+	// NamespaceType is the object definition of Kubernetes namespaces.
+	NamespaceType string
+
+	// ClusterType is the object definition of the cluster, on which permissions for
+	// cluster-scoped resources are checked.
+	ClusterType string
+
+	// ClusterID is the object ID of the cluster.
+	ClusterID string
+
+	// UserType is the object definition of Kubernetes users.
+	UserType string
+
+	// GroupType is the object definition of Kubernetes groups. If empty, the groups of users
+	// are not checked.
+	GroupType string
+
+	// GroupRelation is the relation of GroupType whose subjects are the members of the group.
+	GroupRelation string
+
+	// Deny, if true, denies requests for which the permission is not found, rather than leaving
+	// them to other authorizers.
+	Deny bool
+
+	// BearerTokens are the tokens accepted to authenticate the API server.
+	BearerTokens []string
+}
+
+type handler struct {
+	client v1.PermissionsServiceClient
+	config Config
+}
+
+// NewHandler returns an http.Handler answering SubjectAccessReviews by checking permissions
+// with the given client.
+func NewHandler(client v1.PermissionsServiceClient, config Config) (http.Handler, error) {
+	if config.NamespaceType == "" || config.ClusterType == "" || config.ClusterID == "" || config.UserType == "" {
+		return nil, fmt.Errorf("kubernetes namespace type, cluster type, cluster id and user type must all be specified")
+	}
+
+	if config.GroupType != "" && config.GroupRelation == "" {
+		return nil, fmt.Errorf("a group relation must be specified to check kubernetes groups")
+	}
+
+	if len(config.BearerTokens) == 0 {
+		return nil, fmt.Errorf("a bearer token must be provided to authenticate the kubernetes api server")
+	}
+
+	return &handler{client, config}, nil
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticated(r) {
+		http.Error(w, "missing or invalid bearer token", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "subject access reviews must be posted", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var review subjectAccessReview
+	if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
+		http.Error(w, fmt.Sprintf("invalid subject access review: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	if review.Kind != kind {
+		http.Error(w, fmt.Sprintf("expected a %s, got %s", kind, review.Kind), http.StatusBadRequest)
+		return
+	}
+
+	status := h.review(r.Context(), review.Spec)
+
+	response := subjectAccessReview{
+		APIVersion: review.APIVersion,
+		Kind:       kind,
+		Status:     &status,
+	}
+	if response.APIVersion == "" {
+		response.APIVersion = apiVersion
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Msg("failed to write subject access review response")
+	}
+}
+
+func (h *handler) authenticated(r *http.Request) bool {
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return false
+	}
+	token := strings.TrimPrefix(authorization, "Bearer ")
+
+	for _, bearerToken := range h.config.BearerTokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(bearerToken)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// review decides the SubjectAccessReview with the given spec.
+func (h *handler) review(ctx context.Context, spec subjectAccessReviewSpec) subjectAccessReviewStatus {
+	attributes := spec.ResourceAttributes
+	if attributes == nil {
+		return subjectAccessReviewStatus{Reason: "non-resource requests are not authorized by spicedb"}
+	}
+
+	resource := &v1.ObjectReference{ObjectType: h.config.NamespaceType, ObjectId: escapeObjectID(attributes.Namespace)}
+	if attributes.Namespace == "" {
+		resource = &v1.ObjectReference{ObjectType: h.config.ClusterType, ObjectId: h.config.ClusterID}
+	}
+
+	permission := permissionName(attributes)
+	if !permissionRegex.MatchString(permission) {
+		return subjectAccessReviewStatus{EvaluationError: fmt.Sprintf("`%s` is not a valid permission name", permission)}
+	}
+
+	var subjects []*v1.SubjectReference
+	if spec.User != "" {
+		subjects = append(subjects, &v1.SubjectReference{
+			Object: &v1.ObjectReference{ObjectType: h.config.UserType, ObjectId: escapeObjectID(spec.User)},
+		})
+	}
+	if h.config.GroupType != "" {
+		for _, group := range spec.Groups {
+			subjects = append(subjects, &v1.SubjectReference{
+				Object:           &v1.ObjectReference{ObjectType: h.config.GroupType, ObjectId: escapeObjectID(group)},
+				OptionalRelation: h.config.GroupRelation,
+			})
+		}
+	}
+
+	var evaluationErrors []string
+	for _, subject := range subjects {
+		resp, err := h.client.CheckPermission(ctx, &v1.CheckPermissionRequest{
+			Resource:   resource,
+			Permission: permission,
+			Subject:    subject,
+		})
+		if err != nil {
+			log.Ctx(ctx).Debug().Err(err).Str("permission", permission).Msg("unable to check permission for subject access review")
+			evaluationErrors = append(evaluationErrors, err.Error())
+			continue
+		}
+
+		if resp.Permissionship == v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION {
+			return subjectAccessReviewStatus{
+				Allowed: true,
+				Reason:  fmt.Sprintf("%s:%s has permission %s on %s:%s", subject.Object.ObjectType, subject.Object.ObjectId, permission, resource.ObjectType, resource.ObjectId),
+			}
+		}
+	}
+
+	if len(evaluationErrors) > 0 {
+		return subjectAccessReviewStatus{EvaluationError: strings.Join(evaluationErrors, "; ")}
+	}
+
+	return subjectAccessReviewStatus{
+		Denied: h.config.Deny,
+		Reason: fmt.Sprintf("permission %s on %s:%s not found", permission, resource.ObjectType, resource.ObjectId),
+	}
+}
+
+var (
+	permissionRegex       = regexp.MustCompile(`^[a-z][a-z0-9_]{1,62}[a-z0-9]$`)
+	invalidPermissionChar = regexp.MustCompile(`[^a-z0-9_]`)
+)
+
+// permissionName returns the name of the permission checked for the given attributes.
+func permissionName(attributes *resourceAttributes) string {
+	parts := []string{attributes.Verb, attributes.Resource}
+	if attributes.Subresource != "" {
+		parts = append(parts, attributes.Subresource)
+	}
+	return invalidPermissionChar.ReplaceAllString(strings.ToLower(strings.Join(parts, "_")), "_")
+}
+
+// escapeObjectID escapes the characters of the given string not valid in an object ID as `|`
+// followed by their hexadecimal value, as is `|` itself and a leading character not valid at
+// the start of an object ID.
+func escapeObjectID(value string) string {
+	var escaped strings.Builder
+	for index := 0; index < len(value); index++ {
+		c := value[index]
+		valid := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_' ||
+			(index > 0 && (c == '/' || c == '-'))
+		if valid {
+			escaped.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&escaped, "|%02X", c)
+	}
+	return escaped.String()
+}
@@ -0,0 +1,157 @@
+package kubeauthz
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+const testToken = "sometoken"
+
+// fakePermissionsClient answers checks from a fixed set of relationships, rather than a schema.
+type fakePermissionsClient struct {
+	v1.PermissionsServiceClient
+
+	permissions map[string]struct{}
+	granted     map[string]struct{}
+}
+
+func (c fakePermissionsClient) CheckPermission(_ context.Context, req *v1.CheckPermissionRequest, _ ...grpc.CallOption) (*v1.CheckPermissionResponse, error) {
+	if _, ok := c.permissions[req.Resource.ObjectType+"#"+req.Permission]; !ok {
+		return nil, status.Errorf(codes.FailedPrecondition, "permission %s not found", req.Permission)
+	}
+
+	permissionship := v1.CheckPermissionResponse_PERMISSIONSHIP_NO_PERMISSION
+	if _, ok := c.granted[tuple.MustRelString(&v1.Relationship{
+		Resource: req.Resource,
+		Relation: req.Permission,
+		Subject:  req.Subject,
+	})]; ok {
+		permissionship = v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION
+	}
+	return &v1.CheckPermissionResponse{Permissionship: permissionship}, nil
+}
+
+func TestSubjectAccessReview(t *testing.T) {
+	client := fakePermissionsClient{
+		permissions: map[string]struct{}{
+			"kubernetes/namespace#get_pods":     {},
+			"kubernetes/namespace#get_pods_log": {},
+			"kubernetes/cluster#list_nodes":     {},
+		},
+		granted: map[string]struct{}{
+			"kubernetes/namespace:default#get_pods@kubernetes/user:tom":                            {},
+			"kubernetes/namespace:default#get_pods_log@kubernetes/user:tom":                        {},
+			"kubernetes/namespace:default#get_pods@kubernetes/group:system|3Aauthenticated#member": {},
+			"kubernetes/namespace:kube-system#get_pods@kubernetes/user:system|3Aadmin":             {},
+			"kubernetes/cluster:cluster#list_nodes@kubernetes/user:tom":                            {},
+		},
+	}
+
+	handler, err := NewHandler(client, Config{
+		NamespaceType: "kubernetes/namespace",
+		ClusterType:   "kubernetes/cluster",
+		ClusterID:     "cluster",
+		UserType:      "kubernetes/user",
+		GroupType:     "kubernetes/group",
+		GroupRelation: "member",
+		Deny:          true,
+		BearerTokens:  []string{testToken},
+	})
+	require.NoError(t, err)
+
+	tcs := []struct {
+		name              string
+		spec              string
+		expectedAllowed   bool
+		expectedDenied    bool
+		expectedEvalError bool
+	}{
+		{
+			"namespaced resource allowed to user",
+			`{"user": "tom", "resourceAttributes": {"namespace": "default", "verb": "get", "resource": "pods"}}`,
+			true, false, false,
+		},
+		{
+			"subresource allowed to user",
+			`{"user": "tom", "resourceAttributes": {"namespace": "default", "verb": "get", "resource": "pods", "subresource": "log"}}`,
+			true, false, false,
+		},
+		{
+			"namespaced resource allowed to group",
+			`{"user": "sarah", "groups": ["system:authenticated"], "resourceAttributes": {"namespace": "default", "verb": "get", "resource": "pods"}}`,
+			true, false, false,
+		},
+		{
+			"escaped user allowed",
+			`{"user": "system:admin", "resourceAttributes": {"namespace": "kube-system", "verb": "get", "resource": "pods"}}`,
+			true, false, false,
+		},
+		{
+			"namespaced resource denied",
+			`{"user": "sarah", "resourceAttributes": {"namespace": "default", "verb": "get", "resource": "pods"}}`,
+			false, true, false,
+		},
+		{
+			"cluster-scoped resource allowed",
+			`{"user": "tom", "resourceAttributes": {"verb": "list", "resource": "nodes"}}`,
+			true, false, false,
+		},
+		{
+			"permission missing from schema",
+			`{"user": "tom", "resourceAttributes": {"namespace": "default", "verb": "delete", "resource": "pods"}}`,
+			false, false, true,
+		},
+		{
+			"non-resource request",
+			`{"user": "tom", "nonResourceAttributes": {"path": "/healthz", "verb": "get"}}`,
+			false, false, false,
+		},
+	}
+
+	for _, tc := range tcs {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			body := `{"apiVersion": "authorization.k8s.io/v1", "kind": "SubjectAccessReview", "spec": ` + tc.spec + `}`
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer "+testToken)
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			require.Equal(http.StatusOK, rec.Code)
+
+			var review subjectAccessReview
+			require.NoError(json.Unmarshal(rec.Body.Bytes(), &review))
+			require.Equal(apiVersion, review.APIVersion)
+			require.Equal(tc.expectedAllowed, review.Status.Allowed)
+			require.Equal(tc.expectedDenied, review.Status.Denied)
+			require.Equal(tc.expectedEvalError, review.Status.EvaluationError != "", review.Status.EvaluationError)
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{}`))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestEscapeObjectID(t *testing.T) {
+	require.Equal(t, "tom", escapeObjectID("tom"))
+	require.Equal(t, "system|3Aserviceaccount|3Adefault|3Abuilder", escapeObjectID("system:serviceaccount:default:builder"))
+	require.Equal(t, "tom|40example|2Ecom", escapeObjectID("tom@example.com"))
+	require.Equal(t, "|2Dleading-dash", escapeObjectID("-leading-dash"))
+	require.Equal(t, "pipe|7C", escapeObjectID("pipe|"))
+}
@@ -0,0 +1,46 @@
+package kubeauthz
+
+// The subset of the `authorization.k8s.io/v1` SubjectAccessReview used by the Kubernetes
+// authorization webhook, as sent by the API server and expected in response.
+
+const (
+	apiVersion = "authorization.k8s.io/v1"
+	kind       = "SubjectAccessReview"
+)
+
+type subjectAccessReview struct {
+	APIVersion string                     `json:"apiVersion"`
+	Kind       string                     `json:"kind"`
+	Spec       subjectAccessReviewSpec    `json:"spec"`
+	Status     *subjectAccessReviewStatus `json:"status,omitempty"`
+}
+
+type subjectAccessReviewSpec struct {
+	ResourceAttributes    *resourceAttributes    `json:"resourceAttributes,omitempty"`
+	NonResourceAttributes *nonResourceAttributes `json:"nonResourceAttributes,omitempty"`
+	User                  string                 `json:"user,omitempty"`
+	Groups                []string               `json:"groups,omitempty"`
+	UID                   string                 `json:"uid,omitempty"`
+}
+
+type resourceAttributes struct {
+	Namespace   string `json:"namespace,omitempty"`
+	Verb        string `json:"verb,omitempty"`
+	Group       string `json:"group,omitempty"`
+	Version     string `json:"version,omitempty"`
+	Resource    string `json:"resource,omitempty"`
+	Subresource string `json:"subresource,omitempty"`
+	Name        string `json:"name,omitempty"`
+}
+
+type nonResourceAttributes struct {
+	Path string `json:"path,omitempty"`
+	Verb string `json:"verb,omitempty"`
+}
+
+type subjectAccessReviewStatus struct {
+	Allowed         bool   `json:"allowed"`
+	Denied          bool   `json:"denied,omitempty"`
+	Reason          string `json:"reason,omitempty"`
+	EvaluationError string `json:"evaluationError,omitempty"`
+}
@@ -0,0 +1,131 @@
+package ldapsync
+
+import (
+	"fmt"
+	"os"
+
+	yamlv3 "gopkg.in/yaml.v3"
+
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// MappingFile is the structural representation of the file configuring how groups read from
+// LDAP are converged into relationships.
+type MappingFile struct {
+	// Mappings are the mappings of LDAP groups onto relations.
+	Mappings []Mapping `yaml:"mappings"`
+}
+
+// Mapping maps the groups found by an LDAP search onto a relation: each member of each group
+// found becomes a subject of the relation on the resource identified by the group.
+//
+// The relation is designated to the mapping: on reconciliation, all of its relationships to
+// subjects of the subject type are converged to match the groups in LDAP.
+type Mapping struct {
+	// Name identifies the mapping in logs and metrics.
+	Name string `yaml:"name"`
+
+	// BaseDN is the DN under which groups are searched for.
+	BaseDN string `yaml:"base_dn"`
+
+	// Filter is the LDAP filter matching groups. Defaults to `(objectClass=groupOfNames)`.
+	Filter string `yaml:"filter"`
+
+	// GroupIDAttribute is the attribute of the group used as the resource ID. Defaults to `cn`.
+	GroupIDAttribute string `yaml:"group_id_attribute"`
+
+	// MemberAttribute is the attribute of the group listing its members. Defaults to `member`.
+	MemberAttribute string `yaml:"member_attribute"`
+
+	// MemberIDAttribute, if specified, is the attribute of the member's DN used as the subject
+	// ID, such as `uid` for members listed as `uid=tom,ou=people,dc=example,dc=com`. If empty,
+	// the members are listed by ID, as with `memberUid`.
+	MemberIDAttribute string `yaml:"member_id_attribute"`
+
+	// ResourceType is the object definition of the resources identified by groups.
+	ResourceType string `yaml:"resource_type"`
+
+	// Relation is the relation of the resources, of which the members are subjects.
+	Relation string `yaml:"relation"`
+
+	// SubjectType is the object definition of the subjects identified by members.
+	SubjectType string `yaml:"subject_type"`
+}
+
+const (
+	defaultFilter           = "(objectClass=groupOfNames)"
+	defaultGroupIDAttribute = "cn"
+	defaultMemberAttribute  = "member"
+)
+
+// ReadMappingFile reads and validates the mapping file at the given path.
+func ReadMappingFile(path string) (*MappingFile, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read ldap mapping file: %w", err)
+	}
+
+	return DecodeMappingFile(contents)
+}
+
+// DecodeMappingFile decodes and validates the given contents of a mapping file, filling in the
+// defaults of each mapping.
+func DecodeMappingFile(contents []byte) (*MappingFile, error) {
+	mf := MappingFile{}
+	if err := yamlv3.Unmarshal(contents, &mf); err != nil {
+		return nil, fmt.Errorf("unable to decode ldap mapping file: %w", err)
+	}
+
+	if len(mf.Mappings) == 0 {
+		return nil, fmt.Errorf("ldap mapping file defines no mappings")
+	}
+
+	names := make(map[string]struct{}, len(mf.Mappings))
+	relations := make(map[string]struct{}, len(mf.Mappings))
+	for index := range mf.Mappings {
+		mapping := &mf.Mappings[index]
+		if mapping.Name == "" {
+			return nil, fmt.Errorf("ldap mapping #%d has no name", index+1)
+		}
+
+		if _, ok := names[mapping.Name]; ok {
+			return nil, fmt.Errorf("ldap mapping `%s` is defined more than once", mapping.Name)
+		}
+		names[mapping.Name] = struct{}{}
+
+		if mapping.BaseDN == "" || mapping.ResourceType == "" || mapping.Relation == "" || mapping.SubjectType == "" {
+			return nil, fmt.Errorf("ldap mapping `%s` must specify base_dn, resource_type, relation and subject_type", mapping.Name)
+		}
+
+		// Two mappings converging the same relation would undo each other's changes.
+		designated := mapping.designatedRelation()
+		if _, ok := relations[designated]; ok {
+			return nil, fmt.Errorf("ldap mapping `%s` converges %s, which is converged by another mapping", mapping.Name, designated)
+		}
+		relations[designated] = struct{}{}
+
+		if mapping.Filter == "" {
+			mapping.Filter = defaultFilter
+		}
+		if mapping.GroupIDAttribute == "" {
+			mapping.GroupIDAttribute = defaultGroupIDAttribute
+		}
+		if mapping.MemberAttribute == "" {
+			mapping.MemberAttribute = defaultMemberAttribute
+		}
+	}
+
+	return &mf, nil
+}
+
+func (m Mapping) designatedRelation() string {
+	return fmt.Sprintf("%s#%s@%s", m.ResourceType, m.Relation, m.SubjectType)
+}
+
+func validGroupID(groupID string) bool {
+	return tuple.ValidateResourceID(groupID) == nil
+}
+
+func validMemberID(memberID string) bool {
+	return tuple.ValidateSubjectID(memberID) == nil && memberID != tuple.PublicWildcard
+}
@@ -0,0 +1,304 @@
+// Package ldapsync converges relations to the groups and members found in LDAP.
+package ldapsync
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	log "github.com/authzed/spicedb/internal/logging"
+	"github.com/authzed/spicedb/internal/relationships"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+const (
+	// SourceLabel is the label set on every relationship written by reconciliation, with the
+	// value SourceLabelValue.
+	SourceLabel = "source"
+
+	// SourceLabelValue is the value of SourceLabel on relationships written by reconciliation.
+	SourceLabelValue = "ldap"
+
+	// writeBatchSize is the number of relationship updates written at a time.
+	writeBatchSize = 1000
+)
+
+var sourceLabels = map[string]string{SourceLabel: SourceLabelValue}
+
+var (
+	relationshipsAddedCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "spicedb",
+		Subsystem: "ldapsync",
+		Name:      "relationships_added_total",
+		Help:      "The number of relationships written to converge relations to LDAP groups.",
+	}, []string{"mapping"})
+
+	relationshipsRemovedCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "spicedb",
+		Subsystem: "ldapsync",
+		Name:      "relationships_removed_total",
+		Help:      "The number of relationships deleted to converge relations to LDAP groups.",
+	}, []string{"mapping"})
+
+	reconcileErrorsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "spicedb",
+		Subsystem: "ldapsync",
+		Name:      "reconcile_errors_total",
+		Help:      "The number of failed reconciliations of relations to LDAP groups.",
+	}, []string{"mapping"})
+
+	lastReconciledGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "spicedb",
+		Subsystem: "ldapsync",
+		Name:      "last_reconciled_timestamp_seconds",
+		Help:      "The time of the last successful reconciliation of relations to LDAP groups.",
+	}, []string{"mapping"})
+)
+
+// RegisterMetrics registers LDAP reconciliation metrics to the default registry.
+func RegisterMetrics() error {
+	for _, collector := range []prometheus.Collector{
+		relationshipsAddedCounter,
+		relationshipsRemovedCounter,
+		reconcileErrorsCounter,
+		lastReconciledGauge,
+	} {
+		if err := prometheus.Register(collector); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Diff is the change made, or in dry-run mode planned, to converge the relation of a mapping.
+type Diff struct {
+	// Mapping is the name of the mapping.
+	Mapping string
+
+	// Added are the relationships written for members missing from the relation.
+	Added []*core.RelationTuple
+
+	// Removed are the relationships deleted for subjects no longer members.
+	Removed []*core.RelationTuple
+}
+
+// Reconciler converges the relations designated by mappings to the groups read from a source.
+type Reconciler struct {
+	ds       datastore.Datastore
+	source   Source
+	mappings []Mapping
+	dryRun   bool
+}
+
+// NewReconciler returns a Reconciler for the mappings of the given file. In dry-run mode, the
+// diffs are computed and logged, but not applied.
+func NewReconciler(ds datastore.Datastore, source Source, mappingFile *MappingFile, dryRun bool) *Reconciler {
+	return &Reconciler{ds, source, mappingFile.Mappings, dryRun}
+}
+
+// Reconcile converges the relation of each mapping, returning the diffs. Reconciliation of the
+// remaining mappings continues if one fails, with the first error returned.
+func (r *Reconciler) Reconcile(ctx context.Context) ([]Diff, error) {
+	var firstErr error
+	diffs := make([]Diff, 0, len(r.mappings))
+	for _, mapping := range r.mappings {
+		diff, err := r.reconcileMapping(ctx, mapping)
+		if err != nil {
+			reconcileErrorsCounter.WithLabelValues(mapping.Name).Inc()
+			log.Ctx(ctx).Warn().Err(err).Str("mapping", mapping.Name).Msg("error reconciling ldap groups")
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		r.logDiff(ctx, diff)
+		if !r.dryRun {
+			relationshipsAddedCounter.WithLabelValues(mapping.Name).Add(float64(len(diff.Added)))
+			relationshipsRemovedCounter.WithLabelValues(mapping.Name).Add(float64(len(diff.Removed)))
+			lastReconciledGauge.WithLabelValues(mapping.Name).SetToCurrentTime()
+		}
+		diffs = append(diffs, diff)
+	}
+	return diffs, firstErr
+}
+
+func (r *Reconciler) reconcileMapping(ctx context.Context, mapping Mapping) (Diff, error) {
+	groups, err := r.source.Groups(ctx, mapping)
+	if err != nil {
+		return Diff{}, err
+	}
+
+	if r.dryRun {
+		headRevision, err := r.ds.HeadRevision(ctx)
+		if err != nil {
+			return Diff{}, err
+		}
+		return computeDiff(ctx, r.ds.SnapshotReader(headRevision), mapping, groups)
+	}
+
+	var diff Diff
+	_, err = r.ds.ReadWriteTx(ctx, func(rwt datastore.ReadWriteTransaction) error {
+		var err error
+		diff, err = computeDiff(ctx, rwt, mapping, groups)
+		if err != nil {
+			return err
+		}
+
+		updates := make([]*core.RelationTupleUpdate, 0, len(diff.Added)+len(diff.Removed))
+		for _, tpl := range diff.Added {
+			updates = append(updates, tuple.Touch(tpl))
+		}
+		for _, tpl := range diff.Removed {
+			updates = append(updates, tuple.Delete(tpl))
+		}
+
+		for start := 0; start < len(updates); start += writeBatchSize {
+			end := start + writeBatchSize
+			if end > len(updates) {
+				end = len(updates)
+			}
+
+			if err := relationships.ValidateRelationshipUpdates(ctx, rwt, updates[start:end]); err != nil {
+				return err
+			}
+
+			if err := relationships.CheckConstraints(ctx, rwt, updates[start:end]); err != nil {
+				return err
+			}
+
+			if err := rwt.WriteRelationships(ctx, updates[start:end]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return diff, err
+}
+
+// computeDiff compares the relationships of the mapping's relation with the given groups.
+func computeDiff(ctx context.Context, reader datastore.Reader, mapping Mapping, groups map[string][]string) (Diff, error) {
+	iter, err := reader.QueryRelationships(ctx, datastore.RelationshipsFilter{
+		ResourceType:             mapping.ResourceType,
+		OptionalResourceRelation: mapping.Relation,
+		OptionalSubjectsSelectors: []datastore.SubjectsSelector{{
+			OptionalSubjectType: mapping.SubjectType,
+			RelationFilter:      datastore.SubjectRelationFilter{}.WithEllipsisRelation(),
+		}},
+	})
+	if err != nil {
+		return Diff{}, err
+	}
+
+	existing := make(map[string]*core.RelationTuple)
+	for {
+		tpl, err := iter.Next()
+		if err != nil {
+			iter.Close()
+			return Diff{}, err
+		}
+		if tpl == nil {
+			break
+		}
+
+		existing[tuple.StringWithoutCaveat(tpl)] = tpl
+	}
+	iter.Close()
+
+	diff := Diff{Mapping: mapping.Name}
+	desired := make(map[string]struct{})
+	for groupID, memberIDs := range groups {
+		for _, memberID := range memberIDs {
+			tpl := &core.RelationTuple{
+				ResourceAndRelation: &core.ObjectAndRelation{
+					Namespace: mapping.ResourceType,
+					ObjectId:  groupID,
+					Relation:  mapping.Relation,
+				},
+				Subject: &core.ObjectAndRelation{
+					Namespace: mapping.SubjectType,
+					ObjectId:  memberID,
+					Relation:  tuple.Ellipsis,
+				},
+				Labels: sourceLabels,
+			}
+
+			key := tuple.StringWithoutCaveat(tpl)
+			if _, ok := desired[key]; ok {
+				continue
+			}
+			desired[key] = struct{}{}
+
+			if _, ok := existing[key]; !ok {
+				diff.Added = append(diff.Added, tpl)
+			}
+		}
+	}
+
+	for key, tpl := range existing {
+		if _, ok := desired[key]; !ok {
+			diff.Removed = append(diff.Removed, tpl)
+		}
+	}
+
+	sortTuples(diff.Added)
+	sortTuples(diff.Removed)
+	return diff, nil
+}
+
+func (r *Reconciler) logDiff(ctx context.Context, diff Diff) {
+	for _, tpl := range diff.Added {
+		log.Ctx(ctx).Info().Str("mapping", diff.Mapping).Bool("dry-run", r.dryRun).Str("relationship", tuple.StringWithoutCaveat(tpl)).Msg("ldap sync added relationship")
+	}
+	for _, tpl := range diff.Removed {
+		log.Ctx(ctx).Info().Str("mapping", diff.Mapping).Bool("dry-run", r.dryRun).Str("relationship", tuple.StringWithoutCaveat(tpl)).Msg("ldap sync removed relationship")
+	}
+}
+
+// Start reconciles immediately and then at the given interval, until the context is canceled.
+func (r *Reconciler) Start(ctx context.Context, interval time.Duration) error {
+	log.Ctx(ctx).Info().
+		Dur("interval", interval).
+		Int("mappings", len(r.mappings)).
+		Bool("dry-run", r.dryRun).
+		Msg("ldap group reconciler started")
+
+	next := time.After(0)
+	for {
+		select {
+		case <-ctx.Done():
+			log.Ctx(ctx).Info().
+				Msg("shutting down ldap group reconciler")
+			return nil
+
+		case <-next:
+			start := time.Now()
+			diffs, err := r.Reconcile(ctx)
+
+			added, removed := 0, 0
+			for _, diff := range diffs {
+				added += len(diff.Added)
+				removed += len(diff.Removed)
+			}
+
+			log.Ctx(ctx).Debug().
+				Err(err).
+				Dur("duration", time.Since(start)).
+				Int("added", added).
+				Int("removed", removed).
+				Msg("reconciled relations to ldap groups")
+
+			next = time.After(interval)
+		}
+	}
+}
+
+func sortTuples(tpls []*core.RelationTuple) {
+	sort.Slice(tpls, func(i, j int) bool {
+		return tuple.StringWithoutCaveat(tpls[i]) < tuple.StringWithoutCaveat(tpls[j])
+	})
+}
@@ -0,0 +1,162 @@
+package ldapsync
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/internal/testfixtures"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+const testSchema = `
+	definition user {}
+
+	definition team {
+		relation member: user
+		relation admin: user
+	}`
+
+const testMappingFile = `
+mappings:
+  - name: teams
+    base_dn: ou=groups,dc=example,dc=com
+    member_id_attribute: uid
+    resource_type: team
+    relation: member
+    subject_type: user
+`
+
+type staticSource map[string][]string
+
+func (s staticSource) Groups(_ context.Context, _ Mapping) (map[string][]string, error) {
+	return s, nil
+}
+
+func tuples(t *testing.T, ds datastore.Datastore) []string {
+	ctx := context.Background()
+	headRevision, err := ds.HeadRevision(ctx)
+	require.NoError(t, err)
+
+	iter, err := ds.SnapshotReader(headRevision).QueryRelationships(ctx, datastore.RelationshipsFilter{ResourceType: "team"})
+	require.NoError(t, err)
+	defer iter.Close()
+
+	var found []string
+	for {
+		tpl, err := iter.Next()
+		require.NoError(t, err)
+		if tpl == nil {
+			break
+		}
+
+		found = append(found, tuple.StringWithoutCaveat(tpl))
+	}
+	return found
+}
+
+func diffStrings(tpls []*core.RelationTuple) []string {
+	strs := make([]string, 0, len(tpls))
+	for _, tpl := range tpls {
+		strs = append(strs, tuple.StringWithoutCaveat(tpl))
+	}
+	return strs
+}
+
+func TestReconcile(t *testing.T) {
+	for _, dryRun := range []bool{false, true} {
+		dryRun := dryRun
+		t.Run(map[bool]string{false: "apply", true: "dry run"}[dryRun], func(t *testing.T) {
+			require := require.New(t)
+
+			rawDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+			require.NoError(err)
+
+			ds, _ := testfixtures.DatastoreFromSchemaAndTestRelationships(rawDS, testSchema, []*core.RelationTuple{
+				tuple.MustParse("team:eng#member@user:tom"),
+				tuple.MustParse("team:eng#member@user:fred"),
+				tuple.MustParse("team:eng#admin@user:fred"),
+			}, require)
+
+			mappingFile, err := DecodeMappingFile([]byte(testMappingFile))
+			require.NoError(err)
+
+			source := staticSource{
+				"eng":   {"tom", "sarah"},
+				"sales": {"jill"},
+			}
+
+			diffs, err := NewReconciler(ds, source, mappingFile, dryRun).Reconcile(context.Background())
+			require.NoError(err)
+			require.Len(diffs, 1)
+			require.Equal("teams", diffs[0].Mapping)
+			require.Equal([]string{"team:eng#member@user:sarah", "team:sales#member@user:jill"}, diffStrings(diffs[0].Added))
+			require.Equal([]string{"team:eng#member@user:fred"}, diffStrings(diffs[0].Removed))
+
+			if dryRun {
+				require.ElementsMatch([]string{
+					"team:eng#member@user:tom",
+					"team:eng#member@user:fred",
+					"team:eng#admin@user:fred",
+				}, tuples(t, ds))
+				return
+			}
+
+			require.ElementsMatch([]string{
+				"team:eng#member@user:tom",
+				"team:eng#member@user:sarah",
+				"team:sales#member@user:jill",
+				"team:eng#admin@user:fred",
+			}, tuples(t, ds))
+
+			// Once converged, reconciliation makes no further changes.
+			diffs, err = NewReconciler(ds, source, mappingFile, dryRun).Reconcile(context.Background())
+			require.NoError(err)
+			require.Empty(diffs[0].Added)
+			require.Empty(diffs[0].Removed)
+		})
+	}
+}
+
+func TestDecodeMappingFile(t *testing.T) {
+	mappingFile, err := DecodeMappingFile([]byte(testMappingFile))
+	require.NoError(t, err)
+	require.Equal(t, defaultFilter, mappingFile.Mappings[0].Filter)
+	require.Equal(t, defaultGroupIDAttribute, mappingFile.Mappings[0].GroupIDAttribute)
+	require.Equal(t, defaultMemberAttribute, mappingFile.Mappings[0].MemberAttribute)
+
+	_, err = DecodeMappingFile([]byte(`mappings: []`))
+	require.ErrorContains(t, err, "no mappings")
+
+	_, err = DecodeMappingFile([]byte(`
+mappings:
+  - name: first
+    base_dn: ou=groups,dc=example,dc=com
+    resource_type: team
+    relation: member
+    subject_type: user
+  - name: second
+    base_dn: ou=other,dc=example,dc=com
+    resource_type: team
+    relation: member
+    subject_type: user
+`))
+	require.ErrorContains(t, err, "converged by another mapping")
+}
+
+func TestMemberIDFromValue(t *testing.T) {
+	memberID, err := memberIDFromValue("uid=tom,ou=people,dc=example,dc=com", "uid")
+	require.NoError(t, err)
+	require.Equal(t, "tom", memberID)
+
+	memberID, err = memberIDFromValue("tom", "")
+	require.NoError(t, err)
+	require.Equal(t, "tom", memberID)
+
+	_, err = memberIDFromValue("cn=tom,ou=people,dc=example,dc=com", "uid")
+	require.Error(t, err)
+}
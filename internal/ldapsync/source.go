@@ -0,0 +1,121 @@
+package ldapsync
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+
+	log "github.com/authzed/spicedb/internal/logging"
+)
+
+// searchPageSize is the number of entries requested per page of an LDAP search.
+const searchPageSize = 500
+
+// Source reads the groups to converge relations to.
+type Source interface {
+	// Groups returns the IDs of the members of each group found for the mapping, by group ID.
+	Groups(ctx context.Context, mapping Mapping) (map[string][]string, error)
+}
+
+// LDAPConfig configures the connection to the LDAP server groups are read from.
+type LDAPConfig struct {
+	// URL is the URL of the server, such as `ldaps://ldap.example.com:636`.
+	URL string
+
+	// BindDN is the DN to bind as. If empty, the search is made anonymously.
+	BindDN string
+
+	// BindPassword is the password of BindDN.
+	BindPassword string
+}
+
+type ldapSource struct {
+	config LDAPConfig
+}
+
+// NewLDAPSource returns a Source reading groups from an LDAP server.
+func NewLDAPSource(config LDAPConfig) (Source, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("an ldap server url must be provided")
+	}
+	return &ldapSource{config}, nil
+}
+
+func (s *ldapSource) Groups(ctx context.Context, mapping Mapping) (map[string][]string, error) {
+	conn, err := ldap.DialURL(s.config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to ldap server: %w", err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetTimeout(time.Until(deadline))
+	}
+
+	if s.config.BindDN != "" {
+		if err := conn.Bind(s.config.BindDN, s.config.BindPassword); err != nil {
+			return nil, fmt.Errorf("unable to bind to ldap server: %w", err)
+		}
+	}
+
+	result, err := conn.SearchWithPaging(ldap.NewSearchRequest(
+		mapping.BaseDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		0,
+		0,
+		false,
+		mapping.Filter,
+		[]string{mapping.GroupIDAttribute, mapping.MemberAttribute},
+		nil,
+	), searchPageSize)
+	if err != nil {
+		return nil, fmt.Errorf("unable to search ldap for groups of mapping `%s`: %w", mapping.Name, err)
+	}
+
+	groups := make(map[string][]string, len(result.Entries))
+	for _, entry := range result.Entries {
+		groupID := entry.GetAttributeValue(mapping.GroupIDAttribute)
+		if !validGroupID(groupID) {
+			log.Ctx(ctx).Warn().Str("mapping", mapping.Name).Str("dn", entry.DN).Msg("skipping ldap group without a valid id")
+			continue
+		}
+
+		members := make([]string, 0, len(entry.GetAttributeValues(mapping.MemberAttribute)))
+		for _, value := range entry.GetAttributeValues(mapping.MemberAttribute) {
+			memberID, err := memberIDFromValue(value, mapping.MemberIDAttribute)
+			if err != nil || !validMemberID(memberID) {
+				log.Ctx(ctx).Warn().Str("mapping", mapping.Name).Str("dn", entry.DN).Str("member", value).Msg("skipping ldap group member without a valid id")
+				continue
+			}
+			members = append(members, memberID)
+		}
+		groups[groupID] = append(groups[groupID], members...)
+	}
+	return groups, nil
+}
+
+// memberIDFromValue returns the ID of a member as listed in a group: the value of the given
+// attribute of the member's DN, or the value itself if no attribute is given.
+func memberIDFromValue(value string, idAttribute string) (string, error) {
+	if idAttribute == "" {
+		return value, nil
+	}
+
+	dn, err := ldap.ParseDN(value)
+	if err != nil {
+		return "", err
+	}
+
+	for _, rdn := range dn.RDNs {
+		for _, attribute := range rdn.Attributes {
+			if strings.EqualFold(attribute.Type, idAttribute) {
+				return attribute.Value, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("member `%s` has no `%s` attribute", value, idAttribute)
+}
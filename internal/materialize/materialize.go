@@ -0,0 +1,599 @@
+// Package materialize maintains denormalized tables of the subjects having selected permissions
+// on each resource, updated incrementally from the Watch stream of the datastore, to serve
+// lookups of the resources on which a subject has a permission without dispatching.
+//
+// The tables are held in the memory of each server which materializes permissions, and are
+// consistent snapshots at the revision reported with each lookup. When the schema changes, the
+// tables are discarded and rebuilt, and lookups fail until the rebuild completes, as the changes
+// made to the relationships of a permission cannot be applied across a change to its definition.
+package materialize
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+
+	"github.com/authzed/spicedb/internal/datasets"
+	"github.com/authzed/spicedb/internal/dispatch"
+	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+const (
+	defaultSchemaCheckInterval = 5 * time.Second
+	defaultMaximumAffected     = 10_000
+	retryInterval              = 5 * time.Second
+)
+
+var (
+	// ErrNotMaterialized is returned when looking up a permission which is not materialized.
+	ErrNotMaterialized = errors.New("the permission is not materialized")
+
+	// ErrNotReady is returned when looking up a permission whose table is being built.
+	ErrNotReady = errors.New("the materialized permissions are being built")
+
+	errSchemaChanged   = errors.New("the schema has changed")
+	errTooManyAffected = errors.New("too many objects affected by the changes")
+)
+
+var (
+	rowsGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "spicedb",
+		Subsystem: "materialize",
+		Name:      "rows",
+		Help:      "The number of (subject, resource) rows of each materialized permission.",
+	}, []string{"permission"})
+
+	rebuildsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "spicedb",
+		Subsystem: "materialize",
+		Name:      "rebuilds_total",
+		Help:      "The number of times the materialized permissions were rebuilt, by reason.",
+	}, []string{"reason"})
+
+	appliedCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "spicedb",
+		Subsystem: "materialize",
+		Name:      "applied_changes_total",
+		Help:      "The number of relationship changes applied incrementally to the materialized permissions.",
+	})
+)
+
+// RegisterMetrics registers the materialized permission metrics to the default registry.
+func RegisterMetrics() error {
+	for _, collector := range []prometheus.Collector{rowsGauge, rebuildsCounter, appliedCounter} {
+		if err := prometheus.Register(collector); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Spec identifies a materialized permission, and the type of the subjects materialized for it.
+type Spec struct {
+	ResourceType string
+	Permission   string
+	SubjectType  string
+}
+
+// ParseSpec parses a spec of the form `resource_type#permission@subject_type`.
+func ParseSpec(spec string) (Spec, error) {
+	resource, subjectType, ok := strings.Cut(spec, "@")
+	if !ok {
+		return Spec{}, fmt.Errorf("invalid materialized permission `%s`: expected `resource_type#permission@subject_type`", spec)
+	}
+
+	resourceType, permission, ok := strings.Cut(resource, "#")
+	if !ok || resourceType == "" || permission == "" || subjectType == "" {
+		return Spec{}, fmt.Errorf("invalid materialized permission `%s`: expected `resource_type#permission@subject_type`", spec)
+	}
+
+	return Spec{ResourceType: resourceType, Permission: permission, SubjectType: subjectType}, nil
+}
+
+func (s Spec) String() string {
+	return s.ResourceType + "#" + s.Permission + "@" + s.SubjectType
+}
+
+// Materializer maintains the tables of the materialized permissions.
+type Materializer struct {
+	ds           datastore.Datastore
+	dispatcher   dispatch.Dispatcher
+	specs        []Spec
+	maximumDepth uint32
+
+	schemaCheckInterval time.Duration
+	maximumAffected     int
+
+	lock sync.RWMutex
+
+	// tables holds the table of each spec, or nil while the tables are being built.
+	tables map[Spec]*table
+
+	// undefined holds the error of each spec whose permission or subject type is not defined by
+	// the schema at which the tables were built.
+	undefined map[Spec]error
+
+	revision          datastore.Revision
+	schemaFingerprint string
+}
+
+// NewMaterializer creates a new Materializer of the permissions of the specs, which dispatches
+// the lookups materializing them with the dispatcher. Its tables are built and maintained once
+// it is started.
+func NewMaterializer(ds datastore.Datastore, dispatcher dispatch.Dispatcher, specs []Spec, maximumDepth uint32) *Materializer {
+	return &Materializer{
+		ds:                  ds,
+		dispatcher:          dispatcher,
+		specs:               specs,
+		maximumDepth:        maximumDepth,
+		schemaCheckInterval: defaultSchemaCheckInterval,
+		maximumAffected:     defaultMaximumAffected,
+	}
+}
+
+// LookupResources returns the resources on which the subject has the permission of the spec,
+// ordered by ID, along with the revision at which they were materialized.
+func (m *Materializer) LookupResources(spec Spec, subjectID string) ([]Resource, datastore.Revision, error) {
+	if !m.materializes(spec) {
+		return nil, nil, ErrNotMaterialized
+	}
+
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	if m.tables == nil {
+		return nil, nil, ErrNotReady
+	}
+	if err, ok := m.undefined[spec]; ok {
+		return nil, nil, err
+	}
+	return m.tables[spec].lookup(subjectID), m.revision, nil
+}
+
+func (m *Materializer) materializes(spec Spec) bool {
+	for _, materialized := range m.specs {
+		if materialized == spec {
+			return true
+		}
+	}
+	return false
+}
+
+// Start builds the tables, and then applies the changes to the relationships observed via the
+// Watch stream until the context is canceled. The tables are rebuilt whenever the schema
+// changes, or the stream fails.
+func (m *Materializer) Start(ctx context.Context) error {
+	specs := make([]string, 0, len(m.specs))
+	for _, spec := range m.specs {
+		specs = append(specs, spec.String())
+	}
+	log.Ctx(ctx).Info().Strs("permissions", specs).Msg("permission materializer started")
+
+	reason := "start"
+	for {
+		err := m.run(ctx, reason)
+		if ctx.Err() != nil {
+			log.Ctx(ctx).Info().Msg("shutting down permission materializer")
+			return nil
+		}
+
+		m.invalidate()
+		if errors.Is(err, errSchemaChanged) {
+			log.Ctx(ctx).Info().Msg("schema changed; rebuilding materialized permissions")
+			reason = "schema"
+			continue
+		}
+
+		log.Ctx(ctx).Warn().Err(err).Msg("error maintaining materialized permissions; rebuilding")
+		reason = "error"
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// run builds the tables at the head revision, and then applies the changes following it until
+// the schema changes, or an error occurs.
+func (m *Materializer) run(ctx context.Context, reason string) error {
+	headRevision, err := m.ds.HeadRevision(ctx)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	if err := m.build(ctx, headRevision); err != nil {
+		return err
+	}
+	rebuildsCounter.WithLabelValues(reason).Inc()
+	log.Ctx(ctx).Info().Dur("duration", time.Since(start)).Str("revision", headRevision.String()).Msg("built materialized permissions")
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	changes, errs := m.ds.Watch(watchCtx, headRevision)
+	ticker := time.NewTicker(m.schemaCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case revisionChanges, ok := <-changes:
+			if !ok {
+				return errors.New("watch stream closed")
+			}
+			if err := m.apply(ctx, revisionChanges); err != nil {
+				return err
+			}
+
+		case err := <-errs:
+			return err
+
+		case <-ticker.C:
+			// Changes to the schema are not observed via the Watch stream, so the schema is
+			// also checked when no relationships are written.
+			headRevision, err := m.ds.HeadRevision(ctx)
+			if err != nil {
+				return err
+			}
+			if err := m.checkSchema(ctx, headRevision); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// invalidate discards the tables, failing lookups until they are rebuilt.
+func (m *Materializer) invalidate() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.tables = nil
+	m.undefined = nil
+}
+
+// build builds the tables at the revision, replacing any existing ones.
+func (m *Materializer) build(ctx context.Context, revision datastore.Revision) error {
+	reader := m.ds.SnapshotReader(revision)
+	namespaces, fingerprint, err := readSchema(ctx, reader)
+	if err != nil {
+		return err
+	}
+
+	tables := make(map[Spec]*table, len(m.specs))
+	undefined := make(map[Spec]error)
+	for _, spec := range m.specs {
+		relevant, err := relevantRelations(namespaces, spec)
+		if err != nil {
+			log.Ctx(ctx).Warn().Err(err).Str("permission", spec.String()).Msg("unable to materialize permission")
+			undefined[spec] = err
+			continue
+		}
+
+		tbl := newTable(spec, relevant)
+		if err := m.buildTable(ctx, reader, revision, tbl); err != nil {
+			return err
+		}
+		tables[spec] = tbl
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.tables = tables
+	m.undefined = undefined
+	m.revision = revision
+	m.schemaFingerprint = fingerprint
+	m.publishMetrics()
+	return nil
+}
+
+// buildTable fills the table with the subjects of every resource of its type which has
+// relationships, as a permission cannot be held on a resource without any.
+func (m *Materializer) buildTable(ctx context.Context, reader datastore.Reader, revision datastore.Revision, tbl *table) error {
+	it, err := reader.QueryRelationships(ctx, datastore.RelationshipsFilter{
+		ResourceType: tbl.spec.ResourceType,
+	})
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	var resourceIDs []string
+	seen := make(map[string]struct{})
+	for {
+		tpl, err := it.Next()
+		if err != nil {
+			return err
+		}
+		if tpl == nil {
+			break
+		}
+
+		if _, ok := seen[tpl.ResourceAndRelation.ObjectId]; !ok {
+			seen[tpl.ResourceAndRelation.ObjectId] = struct{}{}
+			resourceIDs = append(resourceIDs, tpl.ResourceAndRelation.ObjectId)
+		}
+	}
+
+	found, err := m.lookupSubjects(ctx, revision, tbl.spec, resourceIDs)
+	if err != nil {
+		return err
+	}
+	for _, resourceID := range resourceIDs {
+		tbl.set(resourceID, found[resourceID])
+	}
+	return nil
+}
+
+// apply applies the changes to the tables, by recomputing the subjects of the resources they
+// may affect, or rebuilding a table if they may affect too many.
+func (m *Materializer) apply(ctx context.Context, changes *datastore.RevisionChanges) error {
+	if err := m.checkSchema(ctx, changes.Revision); err != nil {
+		return err
+	}
+
+	m.lock.RLock()
+	tables := m.tables
+	m.lock.RUnlock()
+
+	reader := m.ds.SnapshotReader(changes.Revision)
+	rebuilt := make(map[Spec]*table)
+	updated := make(map[Spec]map[string]*v1.FoundSubjects)
+	for spec, tbl := range tables {
+		affected, err := m.affectedResources(ctx, reader, tbl, changes.Changes)
+		if errors.Is(err, errTooManyAffected) {
+			rebuiltTable := newTable(spec, tbl.relevant)
+			if err := m.buildTable(ctx, reader, changes.Revision, rebuiltTable); err != nil {
+				return err
+			}
+			rebuildsCounter.WithLabelValues("changes").Inc()
+			rebuilt[spec] = rebuiltTable
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		found, err := m.lookupSubjects(ctx, changes.Revision, spec, affected)
+		if err != nil {
+			return err
+		}
+
+		updated[spec] = make(map[string]*v1.FoundSubjects, len(affected))
+		for _, resourceID := range affected {
+			updated[spec][resourceID] = found[resourceID]
+		}
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for spec, tbl := range rebuilt {
+		m.tables[spec] = tbl
+	}
+	for spec, found := range updated {
+		for resourceID, subjects := range found {
+			m.tables[spec].set(resourceID, subjects)
+		}
+	}
+	m.revision = changes.Revision
+	appliedCounter.Add(float64(len(changes.Changes)))
+	m.publishMetrics()
+	return nil
+}
+
+// checkSchema returns errSchemaChanged if the schema at the revision differs from that at which
+// the tables were built.
+func (m *Materializer) checkSchema(ctx context.Context, revision datastore.Revision) error {
+	_, fingerprint, err := readSchema(ctx, m.ds.SnapshotReader(revision))
+	if err != nil {
+		return err
+	}
+
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	if fingerprint != m.schemaFingerprint {
+		return errSchemaChanged
+	}
+	return nil
+}
+
+type object struct {
+	namespace string
+	objectID  string
+}
+
+// affectedResources returns the IDs of the resources of the table whose permission may have been
+// changed by the changes, as read at the revision following them.
+//
+// They are found by walking up from the resource of each changed relationship read by the
+// permission, through the relationships read by the permission whose subject is the object
+// reached. Any resource whose permission could have read a changed relationship, before or after
+// the changes, is reached by this walk: the relationships above the first changed relationship
+// on each path read are unchanged, and the resource of that relationship is itself walked from.
+// Unlike the reachable resources dispatched for lookups, every branch of intersections and
+// exclusions is walked.
+func (m *Materializer) affectedResources(ctx context.Context, reader datastore.Reader, tbl *table, changes []*core.RelationTupleUpdate) ([]string, error) {
+	var affected []string
+	var frontier []object
+	seen := make(map[object]struct{})
+	add := func(obj object) error {
+		if _, ok := seen[obj]; ok {
+			return nil
+		}
+		if len(seen) >= m.maximumAffected {
+			return errTooManyAffected
+		}
+
+		seen[obj] = struct{}{}
+		frontier = append(frontier, obj)
+		if obj.namespace == tbl.spec.ResourceType {
+			affected = append(affected, obj.objectID)
+		}
+		return nil
+	}
+
+	for _, change := range changes {
+		onr := change.Tuple.ResourceAndRelation
+		if !tbl.isRelevant(onr.Namespace, onr.Relation) {
+			continue
+		}
+		if err := add(object{onr.Namespace, onr.ObjectId}); err != nil {
+			return nil, err
+		}
+	}
+
+	for len(frontier) > 0 {
+		objectIDsByNamespace := make(map[string][]string)
+		for _, obj := range frontier {
+			objectIDsByNamespace[obj.namespace] = append(objectIDsByNamespace[obj.namespace], obj.objectID)
+		}
+		frontier = nil
+
+		for namespace, objectIDs := range objectIDsByNamespace {
+			for _, chunk := range chunk(objectIDs, int(datastore.FilterMaximumIDCount)) {
+				it, err := reader.ReverseQueryRelationships(ctx, datastore.SubjectsFilter{
+					SubjectType:        namespace,
+					OptionalSubjectIds: chunk,
+				})
+				if err != nil {
+					return nil, err
+				}
+
+				for {
+					tpl, err := it.Next()
+					if err != nil {
+						it.Close()
+						return nil, err
+					}
+					if tpl == nil {
+						break
+					}
+
+					onr := tpl.ResourceAndRelation
+					if !tbl.isRelevant(onr.Namespace, onr.Relation) {
+						continue
+					}
+					if err := add(object{onr.Namespace, onr.ObjectId}); err != nil {
+						it.Close()
+						return nil, err
+					}
+				}
+				it.Close()
+			}
+		}
+	}
+	return affected, nil
+}
+
+// lookupSubjects returns the subjects of the permission of the spec on each of the resources
+// which has any, at the revision.
+func (m *Materializer) lookupSubjects(ctx context.Context, revision datastore.Revision, spec Spec, resourceIDs []string) (map[string]*v1.FoundSubjects, error) {
+	ctx = datastoremw.ContextWithDatastore(ctx, m.ds)
+	found := make(map[string]*v1.FoundSubjects, len(resourceIDs))
+	for _, resourceIDs := range chunk(resourceIDs, int(datastore.FilterMaximumIDCount)) {
+		// The subjects of a resource may be streamed in several responses, and so are merged.
+		subjectSets := make(map[string]datasets.SubjectSet)
+		stream := dispatch.NewHandlingDispatchStream(ctx, func(result *v1.DispatchLookupSubjectsResponse) error {
+			for resourceID, foundSubjects := range result.FoundSubjectsByResourceId {
+				subjectSet, ok := subjectSets[resourceID]
+				if !ok {
+					subjectSet = datasets.NewSubjectSet()
+					subjectSets[resourceID] = subjectSet
+				}
+
+				for _, foundSubject := range foundSubjects.FoundSubjects {
+					if err := subjectSet.Add(foundSubject); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+
+		err := m.dispatcher.DispatchLookupSubjects(&v1.DispatchLookupSubjectsRequest{
+			Metadata: &v1.ResolverMeta{
+				AtRevision:     revision.String(),
+				DepthRemaining: m.maximumDepth,
+			},
+			ResourceRelation: &core.RelationReference{
+				Namespace: spec.ResourceType,
+				Relation:  spec.Permission,
+			},
+			ResourceIds: resourceIDs,
+			SubjectRelation: &core.RelationReference{
+				Namespace: spec.SubjectType,
+				Relation:  tuple.Ellipsis,
+			},
+		}, stream)
+		if err != nil {
+			return nil, err
+		}
+
+		for resourceID, subjectSet := range subjectSets {
+			found[resourceID] = subjectSet.AsFoundSubjects()
+		}
+	}
+	return found, nil
+}
+
+// publishMetrics publishes the number of rows of each table. It must be called with the lock
+// held.
+func (m *Materializer) publishMetrics() {
+	for spec, tbl := range m.tables {
+		rowsGauge.WithLabelValues(spec.String()).Set(float64(tbl.rows()))
+	}
+}
+
+// readSchema reads the namespaces of the schema, along with a fingerprint of the namespace and
+// caveat definitions which changes whenever any of them is written.
+func readSchema(ctx context.Context, reader datastore.Reader) (map[string]*core.NamespaceDefinition, string, error) {
+	namespaces, err := reader.ListAllNamespaces(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	caveats, err := reader.ListAllCaveats(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	written := make([]string, 0, len(namespaces)+len(caveats))
+	byName := make(map[string]*core.NamespaceDefinition, len(namespaces))
+	for _, ns := range namespaces {
+		byName[ns.Definition.Name] = ns.Definition
+		written = append(written, "namespace:"+ns.Definition.Name+"@"+ns.LastWrittenRevision.String())
+	}
+	for _, caveat := range caveats {
+		written = append(written, "caveat:"+caveat.Definition.Name+"@"+caveat.LastWrittenRevision.String())
+	}
+	sort.Strings(written)
+
+	sum := sha256.Sum256([]byte(strings.Join(written, "\n")))
+	return byName, hex.EncodeToString(sum[:]), nil
+}
+
+func chunk(ids []string, size int) [][]string {
+	var chunks [][]string
+	for len(ids) > size {
+		chunks = append(chunks, ids[:size])
+		ids = ids[size:]
+	}
+	if len(ids) > 0 {
+		chunks = append(chunks, ids)
+	}
+	return chunks
+}
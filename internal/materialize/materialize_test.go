@@ -0,0 +1,210 @@
+package materialize
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/internal/dispatch/graph"
+	"github.com/authzed/spicedb/internal/testfixtures"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
+	"github.com/authzed/spicedb/pkg/schemadsl/input"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+const testSchema = `
+	definition user {}
+
+	definition group {
+		relation member: user | group#member
+	}
+
+	definition folder {
+		relation parent: folder
+		relation viewer: user | group#member
+		permission view = viewer + parent->view
+	}
+
+	definition document {
+		relation folder: folder
+		relation viewer: user | user:*
+		relation banned: user | group#member
+		relation editor: user
+		permission view = (viewer + folder->view) - banned
+	}
+`
+
+var emptyPrefix = ""
+
+var viewSpec = Spec{ResourceType: "document", Permission: "view", SubjectType: "user"}
+
+func TestParseSpec(t *testing.T) {
+	spec, err := ParseSpec("document#view@user")
+	require.NoError(t, err)
+	require.Equal(t, viewSpec, spec)
+	require.Equal(t, "document#view@user", spec.String())
+
+	for _, invalid := range []string{"document#view", "document@user", "#view@user", "document#view@"} {
+		_, err := ParseSpec(invalid)
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestRelevantRelations(t *testing.T) {
+	compiled, err := compiler.Compile(compiler.InputSchema{
+		Source:       input.Source("schema"),
+		SchemaString: testSchema,
+	}, &emptyPrefix)
+	require.NoError(t, err)
+
+	namespaces := make(map[string]*core.NamespaceDefinition)
+	for _, def := range compiled.ObjectDefinitions {
+		namespaces[def.Name] = def
+	}
+
+	relevant, err := relevantRelations(namespaces, viewSpec)
+	require.NoError(t, err)
+	require.Equal(t, map[relationKey]struct{}{
+		{"document", "view"}:   {},
+		{"document", "viewer"}: {},
+		{"document", "folder"}: {},
+		{"document", "banned"}: {},
+		{"folder", "view"}:     {},
+		{"folder", "viewer"}:   {},
+		{"folder", "parent"}:   {},
+		{"group", "member"}:    {},
+	}, relevant)
+
+	_, err = relevantRelations(namespaces, Spec{ResourceType: "document", Permission: "unknown", SubjectType: "user"})
+	require.Error(t, err)
+
+	_, err = relevantRelations(namespaces, Spec{ResourceType: "document", Permission: "view", SubjectType: "unknown"})
+	require.Error(t, err)
+}
+
+func write(t *testing.T, ds datastore.Datastore, updates ...*core.RelationTupleUpdate) {
+	_, err := ds.ReadWriteTx(context.Background(), func(rwt datastore.ReadWriteTransaction) error {
+		return rwt.WriteRelationships(context.Background(), updates)
+	})
+	require.NoError(t, err)
+}
+
+func requireResources(t *testing.T, m *Materializer, subjectID string, expected ...Resource) {
+	require.Eventually(t, func() bool {
+		found, _, err := m.LookupResources(viewSpec, subjectID)
+		if err != nil {
+			return false
+		}
+		if len(expected) == 0 {
+			return len(found) == 0
+		}
+		return assert.ObjectsAreEqual(expected, found)
+	}, 5*time.Second, 10*time.Millisecond, "resources of %s", subjectID)
+}
+
+func newTestDatastore(t *testing.T) datastore.Datastore {
+	rawDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	require.NoError(t, err)
+
+	ds, _ := testfixtures.DatastoreFromSchemaAndTestRelationships(rawDS, testSchema, []*core.RelationTuple{
+		tuple.MustParse("group:eng#member@user:tom"),
+		tuple.MustParse("group:all#member@group:eng#member"),
+		tuple.MustParse("folder:root#viewer@group:all#member"),
+		tuple.MustParse("folder:sub#parent@folder:root"),
+		tuple.MustParse("document:first#folder@folder:sub"),
+		tuple.MustParse("document:second#viewer@user:sarah"),
+		tuple.MustParse("document:public#viewer@user:*"),
+		tuple.MustParse("document:public#banned@user:amy"),
+		tuple.MustParse("document:third#editor@user:tom"),
+	}, require.New(t))
+	return ds
+}
+
+func TestMaterializer(t *testing.T) {
+	ds := newTestDatastore(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m := NewMaterializer(ds, graph.NewLocalOnlyDispatcher(10), []Spec{viewSpec}, 50)
+	m.schemaCheckInterval = 10 * time.Millisecond
+
+	_, _, err := m.LookupResources(Spec{ResourceType: "document", Permission: "edit", SubjectType: "user"}, "tom")
+	require.ErrorIs(t, err, ErrNotMaterialized)
+
+	_, _, err = m.LookupResources(viewSpec, "tom")
+	require.ErrorIs(t, err, ErrNotReady)
+
+	done := make(chan error)
+	go func() { done <- m.Start(ctx) }()
+
+	requireResources(t, m, "tom", Resource{ResourceID: "first"}, Resource{ResourceID: "public"})
+	requireResources(t, m, "sarah", Resource{ResourceID: "public"}, Resource{ResourceID: "second"})
+	requireResources(t, m, "amy")
+
+	// Changes deep below the resource are applied.
+	write(t, ds, tuple.Create(tuple.MustParse("group:eng#member@user:amy")))
+	requireResources(t, m, "amy", Resource{ResourceID: "first"})
+
+	// As are changes to the excluded branch of the permission.
+	write(t, ds,
+		tuple.Create(tuple.MustParse("group:interns#member@user:sarah")),
+		tuple.Create(tuple.MustParse("document:second#banned@group:interns#member")),
+	)
+	requireResources(t, m, "sarah", Resource{ResourceID: "public"})
+
+	write(t, ds, tuple.Delete(tuple.MustParse("folder:sub#parent@folder:root")))
+	requireResources(t, m, "tom", Resource{ResourceID: "public"})
+
+	// Changes to relations which the permission does not read are ignored.
+	write(t, ds, tuple.Create(tuple.MustParse("document:third#editor@user:sarah")))
+	requireResources(t, m, "sarah", Resource{ResourceID: "public"})
+
+	// Changes to the schema rebuild the tables under the new schema.
+	compiled, err := compiler.Compile(compiler.InputSchema{
+		Source:       input.Source("schema"),
+		SchemaString: "definition document {\n relation viewer: user\n}",
+	}, &emptyPrefix)
+	require.NoError(t, err)
+
+	_, err = ds.ReadWriteTx(ctx, func(rwt datastore.ReadWriteTransaction) error {
+		return rwt.WriteNamespaces(ctx, compiled.ObjectDefinitions...)
+	})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		_, _, err := m.LookupResources(viewSpec, "tom")
+		return err != nil && err != ErrNotReady
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestMaterializerRebuildsOnWideChanges(t *testing.T) {
+	ds := newTestDatastore(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m := NewMaterializer(ds, graph.NewLocalOnlyDispatcher(10), []Spec{viewSpec}, 50)
+	m.maximumAffected = 1
+
+	done := make(chan error)
+	go func() { done <- m.Start(ctx) }()
+
+	requireResources(t, m, "tom", Resource{ResourceID: "first"}, Resource{ResourceID: "public"})
+
+	// Changes which may affect more objects than the maximum rebuild the table instead.
+	write(t, ds, tuple.Delete(tuple.MustParse("group:eng#member@user:tom")))
+	requireResources(t, m, "tom", Resource{ResourceID: "public"})
+
+	cancel()
+	require.NoError(t, <-done)
+}
@@ -0,0 +1,208 @@
+package materialize
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/authzed/spicedb/pkg/graph"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// Resource is a resource on which a subject has a materialized permission.
+type Resource struct {
+	ResourceID string
+
+	// Conditional is true if the permission depends on caveats, which are not evaluated when
+	// materializing.
+	Conditional bool
+}
+
+// subjectEntry is a subject having the permission on a resource.
+type subjectEntry struct {
+	conditional bool
+
+	// excluded holds the subjects excluded from a wildcard, and whether their exclusion is
+	// conditional.
+	excluded map[string]bool
+}
+
+type relationKey struct {
+	namespace string
+	relation  string
+}
+
+// table is the materialized permission of a spec: the subjects having the permission on each
+// resource, indexed by subject.
+type table struct {
+	spec Spec
+
+	// relevant holds the relations and permissions whose relationships the permission may
+	// read, under the schema at which the table was built.
+	relevant map[relationKey]struct{}
+
+	subjectsByResource map[string]map[string]subjectEntry
+	resourcesBySubject map[string]map[string]struct{}
+}
+
+func newTable(spec Spec, relevant map[relationKey]struct{}) *table {
+	return &table{
+		spec:               spec,
+		relevant:           relevant,
+		subjectsByResource: make(map[string]map[string]subjectEntry),
+		resourcesBySubject: make(map[string]map[string]struct{}),
+	}
+}
+
+func (t *table) isRelevant(namespace, relation string) bool {
+	_, ok := t.relevant[relationKey{namespace, relation}]
+	return ok
+}
+
+// set replaces the subjects of the resource with those found, which may be nil.
+func (t *table) set(resourceID string, found *v1.FoundSubjects) {
+	for subjectID := range t.subjectsByResource[resourceID] {
+		delete(t.resourcesBySubject[subjectID], resourceID)
+		if len(t.resourcesBySubject[subjectID]) == 0 {
+			delete(t.resourcesBySubject, subjectID)
+		}
+	}
+	delete(t.subjectsByResource, resourceID)
+
+	if len(found.GetFoundSubjects()) == 0 {
+		return
+	}
+
+	subjects := make(map[string]subjectEntry, len(found.FoundSubjects))
+	for _, subject := range found.FoundSubjects {
+		entry := subjectEntry{conditional: subject.CaveatExpression != nil}
+		if len(subject.ExcludedSubjects) > 0 {
+			entry.excluded = make(map[string]bool, len(subject.ExcludedSubjects))
+			for _, excluded := range subject.ExcludedSubjects {
+				entry.excluded[excluded.SubjectId] = excluded.CaveatExpression != nil
+			}
+		}
+		subjects[subject.SubjectId] = entry
+
+		if _, ok := t.resourcesBySubject[subject.SubjectId]; !ok {
+			t.resourcesBySubject[subject.SubjectId] = make(map[string]struct{})
+		}
+		t.resourcesBySubject[subject.SubjectId][resourceID] = struct{}{}
+	}
+	t.subjectsByResource[resourceID] = subjects
+}
+
+// lookup returns the resources on which the subject has the permission, ordered by ID.
+func (t *table) lookup(subjectID string) []Resource {
+	found := make(map[string]bool)
+	for resourceID := range t.resourcesBySubject[subjectID] {
+		found[resourceID] = t.subjectsByResource[resourceID][subjectID].conditional
+	}
+
+	for resourceID := range t.resourcesBySubject[tuple.PublicWildcard] {
+		wildcard := t.subjectsByResource[resourceID][tuple.PublicWildcard]
+		conditional := wildcard.conditional
+		if excludedConditionally, ok := wildcard.excluded[subjectID]; ok {
+			if !excludedConditionally {
+				continue
+			}
+			conditional = true
+		}
+
+		if existing, ok := found[resourceID]; !ok || (existing && !conditional) {
+			found[resourceID] = conditional
+		}
+	}
+
+	resources := make([]Resource, 0, len(found))
+	for resourceID, conditional := range found {
+		resources = append(resources, Resource{ResourceID: resourceID, Conditional: conditional})
+	}
+	sort.Slice(resources, func(i, j int) bool {
+		return resources[i].ResourceID < resources[j].ResourceID
+	})
+	return resources
+}
+
+// rows returns the number of (subject, resource) rows of the table.
+func (t *table) rows() int {
+	rows := 0
+	for _, subjects := range t.subjectsByResource {
+		rows += len(subjects)
+	}
+	return rows
+}
+
+// relevantRelations returns the relations and permissions whose relationships the permission
+// of the spec may read, directly or through other relations, permissions and arrows, under the
+// schema formed by the namespaces. Every branch of intersections and exclusions is included.
+func relevantRelations(namespaces map[string]*core.NamespaceDefinition, spec Spec) (map[relationKey]struct{}, error) {
+	if _, ok := namespaces[spec.SubjectType]; !ok {
+		return nil, fmt.Errorf("subject type `%s` is not defined", spec.SubjectType)
+	}
+	if findRelation(namespaces, spec.ResourceType, spec.Permission) == nil {
+		return nil, fmt.Errorf("permission `%s#%s` is not defined", spec.ResourceType, spec.Permission)
+	}
+
+	relevant := make(map[relationKey]struct{})
+	var visit func(namespace, relationName string) error
+	visit = func(namespace, relationName string) error {
+		key := relationKey{namespace, relationName}
+		if _, ok := relevant[key]; ok {
+			return nil
+		}
+
+		relation := findRelation(namespaces, namespace, relationName)
+		if relation == nil {
+			return nil
+		}
+		relevant[key] = struct{}{}
+
+		var referenced []relationKey
+		for _, allowed := range relation.GetTypeInformation().GetAllowedDirectRelations() {
+			if allowed.GetPublicWildcard() == nil && allowed.GetRelation() != tuple.Ellipsis {
+				referenced = append(referenced, relationKey{allowed.Namespace, allowed.GetRelation()})
+			}
+		}
+
+		_, err := graph.WalkRewrite(relation.GetUsersetRewrite(), func(childOneof *core.SetOperation_Child) interface{} {
+			switch child := childOneof.ChildType.(type) {
+			case *core.SetOperation_Child_ComputedUserset:
+				referenced = append(referenced, relationKey{namespace, child.ComputedUserset.GetRelation()})
+
+			case *core.SetOperation_Child_TupleToUserset:
+				tupleset := child.TupleToUserset.GetTupleset().GetRelation()
+				referenced = append(referenced, relationKey{namespace, tupleset})
+				for _, allowed := range findRelation(namespaces, namespace, tupleset).GetTypeInformation().GetAllowedDirectRelations() {
+					referenced = append(referenced, relationKey{allowed.Namespace, child.TupleToUserset.GetComputedUserset().GetRelation()})
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, key := range referenced {
+			if err := visit(key.namespace, key.relation); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := visit(spec.ResourceType, spec.Permission); err != nil {
+		return nil, err
+	}
+	return relevant, nil
+}
+
+func findRelation(namespaces map[string]*core.NamespaceDefinition, namespace, relationName string) *core.Relation {
+	for _, relation := range namespaces[namespace].GetRelation() {
+		if relation.Name == relationName {
+			return relation
+		}
+	}
+	return nil
+}
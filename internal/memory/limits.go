@@ -0,0 +1,52 @@
+package memory
+
+import (
+	"errors"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// cgroupLimitPaths are the files from which the memory limit of the container is read, for
+// cgroups v2 and v1 respectively.
+var cgroupLimitPaths = []string{
+	"/sys/fs/cgroup/memory.max",
+	"/sys/fs/cgroup/memory/memory.limit_in_bytes",
+}
+
+// cgroupV1Unlimited is the smallest value reported by cgroups v1 when no limit is set, which is
+// the maximum int64 rounded down to the page size.
+const cgroupV1Unlimited = math.MaxInt64 &^ (1<<12 - 1)
+
+// CgroupLimit returns the memory limit of the cgroup of the process, in bytes, or zero if there
+// is no limit or it cannot be determined.
+func CgroupLimit() (uint64, error) {
+	for _, path := range cgroupLimitPaths {
+		contents, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		return parseCgroupLimit(string(contents))
+	}
+	return 0, nil
+}
+
+func parseCgroupLimit(contents string) (uint64, error) {
+	contents = strings.TrimSpace(contents)
+	if contents == "max" {
+		return 0, nil
+	}
+
+	limit, err := strconv.ParseUint(contents, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if limit >= cgroupV1Unlimited {
+		return 0, nil
+	}
+	return limit, nil
+}
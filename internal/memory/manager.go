@@ -0,0 +1,264 @@
+// Package memory implements a manager which tunes the garbage collector to the memory limit of
+// the container in which SpiceDB runs, and signals when load should be shed to avoid running out
+// of memory.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"runtime/debug"
+	"runtime/metrics"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	log "github.com/authzed/spicedb/internal/logging"
+)
+
+const (
+	// relaxedUsageRatio is the ratio of heap usage to the memory limit below which the garbage
+	// collector runs with its base GC percent.
+	relaxedUsageRatio = 0.5
+
+	// shedHysteresis is how far below the shed threshold the heap usage ratio must fall for load
+	// shedding to stop, which avoids flapping around the threshold.
+	shedHysteresis = 0.05
+
+	heapObjectsMetric = "/memory/classes/heap/objects:bytes"
+)
+
+var (
+	limitGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "spicedb",
+		Subsystem: "memory",
+		Name:      "limit_bytes",
+		Help:      "The memory limit of the container, as detected or configured.",
+	})
+
+	softLimitGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "spicedb",
+		Subsystem: "memory",
+		Name:      "soft_limit_bytes",
+		Help:      "The soft memory limit of the Go runtime (GOMEMLIMIT).",
+	})
+
+	gcPercentGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "spicedb",
+		Subsystem: "memory",
+		Name:      "gc_percent",
+		Help:      "The current GC percent (GOGC) of the Go runtime.",
+	})
+
+	usageRatioGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "spicedb",
+		Subsystem: "memory",
+		Name:      "heap_usage_ratio",
+		Help:      "The ratio of heap usage, excluding the ballast, to the memory limit.",
+	})
+
+	sheddingGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "spicedb",
+		Subsystem: "memory",
+		Name:      "shedding",
+		Help:      "Whether new requests are being shed due to memory pressure.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(limitGauge, softLimitGauge, gcPercentGauge, usageRatioGauge, sheddingGauge)
+}
+
+// Config is the configuration of the memory manager.
+type Config struct {
+	// Limit is the memory limit in bytes. If zero, the limit of the cgroup is used.
+	Limit uint64
+
+	// SoftLimitFraction is the fraction of the limit at which the soft memory limit of the Go
+	// runtime is set, unless GOMEMLIMIT is set explicitly.
+	SoftLimitFraction float64
+
+	// BallastFraction is the fraction of the limit to allocate as a ballast, which delays
+	// garbage collections while the heap is small. Zero disables the ballast.
+	BallastFraction float64
+
+	// ShedThreshold is the ratio of heap usage to the limit at which new requests are shed.
+	// Zero disables load shedding.
+	ShedThreshold float64
+
+	// MinimumGCPercent is the GC percent to which the garbage collector is tuned as heap usage
+	// reaches the shed threshold.
+	MinimumGCPercent int
+
+	// CheckInterval is the interval at which heap usage is checked.
+	CheckInterval time.Duration
+}
+
+// Manager tunes the garbage collector as heap usage grows towards the memory limit.
+type Manager struct {
+	config Config
+	limit  uint64
+
+	baseGCPercent    int
+	currentGCPercent int
+	adaptiveGC       bool
+
+	ballast []byte
+	sample  []metrics.Sample
+
+	shedding atomic.Bool
+}
+
+// NewManager creates a memory manager, setting the soft memory limit and allocating the ballast.
+// If no limit is configured or detected, the manager does nothing.
+func NewManager(ctx context.Context, config Config) (*Manager, error) {
+	if config.SoftLimitFraction <= 0 || config.SoftLimitFraction > 1 {
+		return nil, fmt.Errorf("soft limit fraction must be in (0, 1], found %v", config.SoftLimitFraction)
+	}
+	if config.BallastFraction < 0 || config.BallastFraction >= config.SoftLimitFraction {
+		return nil, fmt.Errorf("ballast fraction must be in [0, %v), found %v", config.SoftLimitFraction, config.BallastFraction)
+	}
+	if config.ShedThreshold < 0 || config.ShedThreshold > 1 {
+		return nil, fmt.Errorf("shed threshold must be in [0, 1], found %v", config.ShedThreshold)
+	}
+	if config.MinimumGCPercent <= 0 || config.MinimumGCPercent > 100 {
+		return nil, fmt.Errorf("minimum GC percent must be in (0, 100], found %d", config.MinimumGCPercent)
+	}
+	if config.CheckInterval <= 0 {
+		return nil, fmt.Errorf("check interval must be positive")
+	}
+
+	limit := config.Limit
+	if limit == 0 {
+		detected, err := CgroupLimit()
+		if err != nil {
+			log.Ctx(ctx).Warn().Err(err).Msg("unable to determine cgroup memory limit")
+		}
+		limit = detected
+	}
+
+	m := &Manager{
+		config:        config,
+		limit:         limit,
+		baseGCPercent: 100,
+		adaptiveGC:    true,
+		sample:        []metrics.Sample{{Name: heapObjectsMetric}},
+	}
+	if limit == 0 {
+		log.Ctx(ctx).Info().Msg("no memory limit configured or detected; memory manager disabled")
+		return m, nil
+	}
+	limitGauge.Set(float64(limit))
+
+	if _, ok := os.LookupEnv("GOMEMLIMIT"); !ok {
+		debug.SetMemoryLimit(int64(float64(limit) * config.SoftLimitFraction))
+	}
+	softLimitGauge.Set(float64(debug.SetMemoryLimit(-1)))
+
+	// An explicitly configured GOGC is left untouched.
+	if _, ok := os.LookupEnv("GOGC"); ok {
+		m.adaptiveGC = false
+	} else {
+		debug.SetGCPercent(m.baseGCPercent)
+		m.currentGCPercent = m.baseGCPercent
+		gcPercentGauge.Set(float64(m.currentGCPercent))
+	}
+
+	if config.BallastFraction > 0 {
+		// The ballast is never written to, so it is not resident in memory.
+		m.ballast = make([]byte, uint64(float64(limit)*config.BallastFraction))
+	}
+
+	log.Ctx(ctx).Info().
+		Uint64("limit", limit).
+		Int64("soft-limit", debug.SetMemoryLimit(-1)).
+		Int("ballast", len(m.ballast)).
+		Bool("adaptive-gc", m.adaptiveGC).
+		Float64("shed-threshold", config.ShedThreshold).
+		Msg("memory manager configured")
+
+	return m, nil
+}
+
+// ShouldShed returns whether new requests should be rejected due to memory pressure.
+func (m *Manager) ShouldShed() bool {
+	return m.shedding.Load()
+}
+
+// Start loops until the context is canceled, checking heap usage on the configured interval.
+func (m *Manager) Start(ctx context.Context) error {
+	if m.limit == 0 {
+		return nil
+	}
+
+	ticker := time.NewTicker(m.config.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case <-ticker.C:
+			m.check(ctx)
+		}
+	}
+}
+
+// check samples heap usage, adjusting the GC percent and load shedding accordingly.
+func (m *Manager) check(ctx context.Context) {
+	metrics.Read(m.sample)
+	heap := m.sample[0].Value.Uint64()
+	if ballast := uint64(len(m.ballast)); heap > ballast {
+		heap -= ballast
+	}
+
+	ratio := float64(heap) / float64(m.limit)
+	usageRatioGauge.Set(ratio)
+
+	if m.adaptiveGC {
+		if gcPercent := m.gcPercentForRatio(ratio); gcPercent != m.currentGCPercent {
+			debug.SetGCPercent(gcPercent)
+			m.currentGCPercent = gcPercent
+			gcPercentGauge.Set(float64(gcPercent))
+			log.Ctx(ctx).Debug().Float64("heap-usage-ratio", ratio).Int("gc-percent", gcPercent).Msg("adjusted GC percent")
+		}
+	}
+
+	if m.config.ShedThreshold == 0 {
+		return
+	}
+
+	switch {
+	case ratio >= m.config.ShedThreshold && !m.shedding.Load():
+		m.shedding.Store(true)
+		sheddingGauge.Set(1)
+		log.Ctx(ctx).Warn().Float64("heap-usage-ratio", ratio).Uint64("heap", heap).Msg("memory pressure: shedding new requests")
+
+	case ratio < m.config.ShedThreshold-shedHysteresis && m.shedding.Load():
+		m.shedding.Store(false)
+		sheddingGauge.Set(0)
+		log.Ctx(ctx).Info().Float64("heap-usage-ratio", ratio).Msg("memory pressure relieved: no longer shedding requests")
+	}
+}
+
+// gcPercentForRatio returns the GC percent for the given heap usage ratio, decreasing linearly
+// from the base GC percent once usage is above the relaxed ratio, down to the minimum at the
+// shed threshold.
+func (m *Manager) gcPercentForRatio(ratio float64) int {
+	floor := m.config.ShedThreshold
+	if floor == 0 {
+		floor = 1
+	}
+	if ratio <= relaxedUsageRatio || floor <= relaxedUsageRatio {
+		return m.baseGCPercent
+	}
+	if ratio >= floor {
+		return m.config.MinimumGCPercent
+	}
+
+	progress := (ratio - relaxedUsageRatio) / (floor - relaxedUsageRatio)
+	return m.baseGCPercent - int(math.Round(progress*float64(m.baseGCPercent-m.config.MinimumGCPercent)))
+}
@@ -0,0 +1,94 @@
+package memory
+
+import (
+	"context"
+	"runtime/debug"
+	"runtime/metrics"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCgroupLimit(t *testing.T) {
+	for _, tc := range []struct {
+		contents      string
+		expectedLimit uint64
+		expectError   bool
+	}{
+		{"max\n", 0, false},
+		{"1073741824\n", 1073741824, false},
+		{"9223372036854771712\n", 0, false},
+		{"lots", 0, true},
+	} {
+		t.Run(tc.contents, func(t *testing.T) {
+			limit, err := parseCgroupLimit(tc.contents)
+			if tc.expectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedLimit, limit)
+		})
+	}
+}
+
+func TestGCPercentForRatio(t *testing.T) {
+	m := &Manager{
+		config:        Config{ShedThreshold: 0.9, MinimumGCPercent: 20},
+		baseGCPercent: 100,
+	}
+
+	require.Equal(t, 100, m.gcPercentForRatio(0.1))
+	require.Equal(t, 100, m.gcPercentForRatio(0.5))
+	require.Equal(t, 60, m.gcPercentForRatio(0.7))
+	require.Equal(t, 20, m.gcPercentForRatio(0.9))
+	require.Equal(t, 20, m.gcPercentForRatio(1.5))
+}
+
+func TestShedding(t *testing.T) {
+	defer debug.SetGCPercent(debug.SetGCPercent(100))
+
+	m := &Manager{
+		config:        Config{ShedThreshold: 0.9, MinimumGCPercent: 20, CheckInterval: time.Second},
+		limit:         1,
+		baseGCPercent: 100,
+		sample:        []metrics.Sample{{Name: heapObjectsMetric}},
+	}
+
+	// Any heap usage exceeds a limit of a single byte.
+	m.check(context.Background())
+	require.True(t, m.ShouldShed())
+
+	m.limit = 1 << 62
+	m.check(context.Background())
+	require.False(t, m.ShouldShed())
+}
+
+func TestNewManagerValidation(t *testing.T) {
+	valid := Config{
+		Limit:             1 << 30,
+		SoftLimitFraction: 0.9,
+		ShedThreshold:     0.95,
+		MinimumGCPercent:  25,
+		CheckInterval:     time.Second,
+	}
+
+	for _, tc := range []struct {
+		name   string
+		modify func(*Config)
+	}{
+		{"soft limit fraction", func(c *Config) { c.SoftLimitFraction = 1.5 }},
+		{"ballast fraction", func(c *Config) { c.BallastFraction = 0.95 }},
+		{"shed threshold", func(c *Config) { c.ShedThreshold = -1 }},
+		{"minimum GC percent", func(c *Config) { c.MinimumGCPercent = 0 }},
+		{"check interval", func(c *Config) { c.CheckInterval = 0 }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			config := valid
+			tc.modify(&config)
+			_, err := NewManager(context.Background(), config)
+			require.Error(t, err)
+		})
+	}
+}
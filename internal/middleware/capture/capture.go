@@ -0,0 +1,409 @@
+// Package capture implements middleware which captures a sample of the read requests served,
+// along with their responses, into files of JSON lines, and the replay of the captured
+// requests against another cluster, such as a staging cluster running a new version, comparing
+// its responses against those captured.
+package capture
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	log "github.com/authzed/spicedb/internal/logging"
+)
+
+var (
+	capturedCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "spicedb",
+		Subsystem: "capture",
+		Name:      "captured_exchanges_total",
+		Help:      "The number of requests captured along with their responses.",
+	})
+
+	droppedCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "spicedb",
+		Subsystem: "capture",
+		Name:      "dropped_exchanges_total",
+		Help:      "The number of sampled requests dropped because the buffer of those awaiting writing was full.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(capturedCounter, droppedCounter)
+}
+
+// filePattern matches the names of the files to which exchanges are written, which sort in the
+// order in which they were created.
+const (
+	filePrefix  = "capture-"
+	fileSuffix  = ".jsonl"
+	filePattern = filePrefix + "*" + fileSuffix
+)
+
+// capturedMethods are the methods whose requests are captured, along with constructors of
+// their requests and responses, and whether they are server streaming. Only methods which do
+// not modify the cluster are captured, so that they may be safely replayed.
+var capturedMethods = map[string]struct {
+	newRequest  func() proto.Message
+	newResponse func() proto.Message
+	streaming   bool
+}{
+	"/authzed.api.v1.PermissionsService/CheckPermission": {
+		newRequest:  func() proto.Message { return &v1.CheckPermissionRequest{} },
+		newResponse: func() proto.Message { return &v1.CheckPermissionResponse{} },
+	},
+	"/authzed.api.v1.PermissionsService/ExpandPermissionTree": {
+		newRequest:  func() proto.Message { return &v1.ExpandPermissionTreeRequest{} },
+		newResponse: func() proto.Message { return &v1.ExpandPermissionTreeResponse{} },
+	},
+	"/authzed.api.v1.PermissionsService/LookupResources": {
+		newRequest:  func() proto.Message { return &v1.LookupResourcesRequest{} },
+		newResponse: func() proto.Message { return &v1.LookupResourcesResponse{} },
+		streaming:   true,
+	},
+	"/authzed.api.v1.PermissionsService/LookupSubjects": {
+		newRequest:  func() proto.Message { return &v1.LookupSubjectsRequest{} },
+		newResponse: func() proto.Message { return &v1.LookupSubjectsResponse{} },
+		streaming:   true,
+	},
+	"/authzed.api.v1.PermissionsService/ReadRelationships": {
+		newRequest:  func() proto.Message { return &v1.ReadRelationshipsRequest{} },
+		newResponse: func() proto.Message { return &v1.ReadRelationshipsResponse{} },
+		streaming:   true,
+	},
+	"/authzed.api.v1.SchemaService/ReadSchema": {
+		newRequest:  func() proto.Message { return &v1.ReadSchemaRequest{} },
+		newResponse: func() proto.Message { return &v1.ReadSchemaResponse{} },
+	},
+}
+
+// revisionSpecificMessages are the messages removed from captured requests and responses,
+// since they reference revisions which exist only in the cluster from which they were captured.
+var revisionSpecificMessages = map[protoreflect.FullName]struct{}{
+	"authzed.api.v1.ZedToken": {},
+	"authzed.api.v1.Cursor":   {},
+}
+
+// Exchange is a captured request along with its responses.
+type Exchange struct {
+	// Method is the full name of the gRPC method called.
+	Method string `json:"method"`
+
+	// Request is the request, as protobuf JSON.
+	Request json.RawMessage `json:"request"`
+
+	// Responses are the responses sent, as protobuf JSON. Unary methods send at most one.
+	Responses []json.RawMessage `json:"responses,omitempty"`
+
+	// Truncated is true if more responses were sent than were captured.
+	Truncated bool `json:"truncated,omitempty"`
+
+	// Code is the status code with which the request completed.
+	Code string `json:"code"`
+
+	// Error is the message of the error with which the request failed, if any.
+	Error string `json:"error,omitempty"`
+
+	// Duration is the time taken to serve the request.
+	Duration time.Duration `json:"duration"`
+
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Config configures the capture of requests.
+type Config struct {
+	// Directory is the directory to which captured exchanges are written. Requests are not
+	// captured if it is empty.
+	Directory string
+
+	// SampleRate is the fraction of requests captured.
+	SampleRate float64
+
+	// MaxExchangesPerFile is the number of exchanges written to a file before another is started.
+	MaxExchangesPerFile int
+
+	// MaxFiles is the number of the most recent files kept, beyond which the oldest are removed.
+	MaxFiles int
+
+	// MaxResponses is the number of responses captured of each streaming request.
+	MaxResponses int
+
+	// MaxBufferedExchanges is the maximum number of exchanges awaiting writing, beyond which
+	// newly sampled requests are dropped.
+	MaxBufferedExchanges int
+}
+
+// Recorder captures a sample of the requests served, and writes them to files.
+type Recorder struct {
+	config    Config
+	exchanges chan Exchange
+}
+
+// NewRecorder creates a recorder writing to the configured directory, which is created if
+// missing. The recorder only writes the exchanges it captures once started.
+func NewRecorder(config Config) (*Recorder, error) {
+	if config.Directory == "" {
+		return nil, fmt.Errorf("a directory is required to capture requests")
+	}
+
+	if config.SampleRate < 0 || config.SampleRate > 1 {
+		return nil, fmt.Errorf("capture sample rate must be between 0 and 1")
+	}
+
+	if config.MaxExchangesPerFile <= 0 {
+		return nil, fmt.Errorf("capture max exchanges per file must be positive")
+	}
+
+	if config.MaxFiles <= 0 {
+		return nil, fmt.Errorf("capture max files must be positive")
+	}
+
+	if config.MaxResponses <= 0 {
+		return nil, fmt.Errorf("capture max responses must be positive")
+	}
+
+	if config.MaxBufferedExchanges <= 0 {
+		return nil, fmt.Errorf("capture max buffered exchanges must be positive")
+	}
+
+	if err := os.MkdirAll(config.Directory, 0o700); err != nil {
+		return nil, fmt.Errorf("unable to create capture directory: %w", err)
+	}
+
+	return &Recorder{
+		config:    config,
+		exchanges: make(chan Exchange, config.MaxBufferedExchanges),
+	}, nil
+}
+
+// sample returns whether a request of the method should be captured. A nil recorder captures
+// nothing.
+func (r *Recorder) sample(ctx context.Context, method string) bool {
+	if r == nil || isReplayed(ctx) {
+		return false
+	}
+
+	if _, ok := capturedMethods[method]; !ok {
+		return false
+	}
+
+	return r.config.SampleRate >= 1 || rand.Float64() < r.config.SampleRate
+}
+
+// record buffers the exchange of the sampled request for writing, dropping it if the buffer is
+// full.
+func (r *Recorder) record(ctx context.Context, method string, req proto.Message, responses []proto.Message, truncated bool, err error, start time.Time) {
+	exchange := Exchange{
+		Method:    method,
+		Truncated: truncated,
+		Code:      status.Code(err).String(),
+		Duration:  time.Since(start),
+		Timestamp: start.UTC(),
+	}
+	if err != nil {
+		exchange.Error = status.Convert(err).Message()
+	}
+
+	marshaled, merr := marshal(req)
+	if merr != nil {
+		log.Ctx(ctx).Debug().Err(merr).Str("method", method).Msg("unable to marshal request for capture")
+		return
+	}
+	exchange.Request = marshaled
+
+	for _, resp := range responses {
+		marshaled, merr := marshal(resp)
+		if merr != nil {
+			log.Ctx(ctx).Debug().Err(merr).Str("method", method).Msg("unable to marshal response for capture")
+			return
+		}
+		exchange.Responses = append(exchange.Responses, marshaled)
+	}
+
+	select {
+	case r.exchanges <- exchange:
+	default:
+		droppedCounter.Inc()
+	}
+}
+
+// Start writes the exchanges captured until the context is cancelled, at which point those still
+// buffered are written.
+func (r *Recorder) Start(ctx context.Context) error {
+	w := &writer{config: r.config}
+	defer w.close(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			for {
+				select {
+				case exchange := <-r.exchanges:
+					w.write(ctx, exchange)
+				default:
+					return nil
+				}
+			}
+
+		case exchange := <-r.exchanges:
+			w.write(ctx, exchange)
+		}
+	}
+}
+
+// writer writes exchanges to the current file, starting another once it is full.
+type writer struct {
+	config  Config
+	file    *os.File
+	written int
+	opened  int
+}
+
+func (w *writer) write(ctx context.Context, exchange Exchange) {
+	if w.file == nil || w.written >= w.config.MaxExchangesPerFile {
+		w.close(ctx)
+		if err := w.open(); err != nil {
+			log.Ctx(ctx).Warn().Err(err).Str("directory", w.config.Directory).Msg("failed to start capture file")
+			return
+		}
+	}
+
+	line, err := json.Marshal(exchange)
+	if err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("unable to marshal captured exchange")
+		return
+	}
+
+	if _, err := w.file.Write(append(line, '\n')); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Str("path", w.file.Name()).Msg("failed to write captured exchange")
+		return
+	}
+	w.written++
+	capturedCounter.Inc()
+}
+
+func (w *writer) open() error {
+	name := fmt.Sprintf("%s%s-%06d%s", filePrefix, time.Now().UTC().Format("20060102T150405.000000000"), w.opened, fileSuffix)
+	file, err := os.OpenFile(filepath.Join(w.config.Directory, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	w.file = file
+	w.written = 0
+	w.opened++
+
+	// Remove the oldest files beyond those kept, including the one just started.
+	paths, err := filepath.Glob(filepath.Join(w.config.Directory, filePattern))
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+	for len(paths) > w.config.MaxFiles {
+		if err := os.Remove(paths[0]); err != nil {
+			return err
+		}
+		paths = paths[1:]
+	}
+	return nil
+}
+
+func (w *writer) close(ctx context.Context) {
+	if w.file == nil {
+		return
+	}
+	if err := w.file.Close(); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Str("path", w.file.Name()).Msg("failed to close capture file")
+	}
+	w.file = nil
+}
+
+// ReadExchanges reads the exchanges written to the file.
+func ReadExchanges(path string) ([]Exchange, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read exchanges: %w", err)
+	}
+	defer file.Close()
+
+	var exchanges []Exchange
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var exchange Exchange
+		if err := json.Unmarshal(scanner.Bytes(), &exchange); err != nil {
+			return nil, fmt.Errorf("unable to unmarshal exchange: %w", err)
+		}
+		exchanges = append(exchanges, exchange)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read exchanges: %w", err)
+	}
+	return exchanges, nil
+}
+
+// marshal marshals the sanitized message as protobuf JSON.
+func marshal(message proto.Message) (json.RawMessage, error) {
+	return protojson.Marshal(sanitize(message))
+}
+
+// sanitize returns a copy of the message with its revision specific messages removed.
+// Requests are captured without their metadata, so never include the tokens with which they
+// were made.
+func sanitize(message proto.Message) proto.Message {
+	sanitized := proto.Clone(message)
+	clearRevisionSpecific(sanitized.ProtoReflect())
+	return sanitized
+}
+
+func clearRevisionSpecific(message protoreflect.Message) {
+	var cleared []protoreflect.FieldDescriptor
+	message.Range(func(field protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+		switch {
+		case field.IsMap():
+			if field.MapValue().Message() != nil {
+				value.Map().Range(func(_ protoreflect.MapKey, entry protoreflect.Value) bool {
+					clearRevisionSpecific(entry.Message())
+					return true
+				})
+			}
+
+		case field.Message() == nil:
+
+		case field.IsList():
+			list := value.List()
+			for i := 0; i < list.Len(); i++ {
+				clearRevisionSpecific(list.Get(i).Message())
+			}
+
+		default:
+			if _, ok := revisionSpecificMessages[field.Message().FullName()]; ok {
+				cleared = append(cleared, field)
+			} else {
+				clearRevisionSpecific(value.Message())
+			}
+		}
+		return true
+	})
+
+	for _, field := range cleared {
+		message.Clear(field)
+	}
+}
@@ -0,0 +1,250 @@
+package capture_test
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/internal/middleware/capture"
+	tf "github.com/authzed/spicedb/internal/testfixtures"
+	"github.com/authzed/spicedb/internal/testserver"
+)
+
+const (
+	checkMethod  = "/authzed.api.v1.PermissionsService/CheckPermission"
+	lookupMethod = "/authzed.api.v1.PermissionsService/LookupResources"
+)
+
+var engLead = &v1.SubjectReference{Object: &v1.ObjectReference{ObjectType: "user", ObjectId: "eng_lead"}}
+
+// testStream is a server stream receiving the request, and collecting the responses sent.
+type testStream struct {
+	grpc.ServerStream
+	ctx     context.Context
+	request proto.Message
+}
+
+func (s *testStream) Context() context.Context { return s.ctx }
+
+func (s *testStream) RecvMsg(m any) error {
+	proto.Merge(m.(proto.Message), s.request)
+	return nil
+}
+
+func (s *testStream) SendMsg(m any) error { return nil }
+
+func newRecorder(t *testing.T, config capture.Config) (*capture.Recorder, func()) {
+	recorder, err := capture.NewRecorder(config)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- recorder.Start(ctx) }()
+	return recorder, func() {
+		cancel()
+		require.NoError(t, <-done)
+	}
+}
+
+func readAll(t *testing.T, directory string) ([]string, []capture.Exchange) {
+	paths, err := filepath.Glob(filepath.Join(directory, "capture-*.jsonl"))
+	require.NoError(t, err)
+
+	var exchanges []capture.Exchange
+	for _, path := range paths {
+		read, err := capture.ReadExchanges(path)
+		require.NoError(t, err)
+		exchanges = append(exchanges, read...)
+	}
+	return paths, exchanges
+}
+
+func TestCaptureAndReplay(t *testing.T) {
+	require := require.New(t)
+	conn, cleanup, _, _ := testserver.NewTestServer(require, 0, memdb.DisableGC, true, tf.StandardDatastoreWithData)
+	t.Cleanup(cleanup)
+	client := v1.NewPermissionsServiceClient(conn)
+
+	directory := t.TempDir()
+	recorder, stop := newRecorder(t, capture.Config{
+		Directory:            directory,
+		SampleRate:           1,
+		MaxExchangesPerFile:  2,
+		MaxFiles:             10,
+		MaxResponses:         100,
+		MaxBufferedExchanges: 10,
+	})
+
+	unary := capture.UnaryServerInterceptor(recorder)
+	check := func(ctx context.Context, req any) (any, error) {
+		return client.CheckPermission(ctx, req.(*v1.CheckPermissionRequest))
+	}
+
+	ctx := context.Background()
+	checked, err := client.CheckPermission(ctx, &v1.CheckPermissionRequest{
+		Resource:   &v1.ObjectReference{ObjectType: "document", ObjectId: "masterplan"},
+		Permission: "view",
+		Subject:    engLead,
+	})
+	require.NoError(err)
+
+	for _, req := range []*v1.CheckPermissionRequest{
+		{
+			Consistency: &v1.Consistency{Requirement: &v1.Consistency_AtLeastAsFresh{AtLeastAsFresh: checked.CheckedAt}},
+			Resource:    &v1.ObjectReference{ObjectType: "document", ObjectId: "masterplan"},
+			Permission:  "view",
+			Subject:     engLead,
+		},
+		{
+			Resource:   &v1.ObjectReference{ObjectType: "document", ObjectId: "masterplan"},
+			Permission: "unknown",
+			Subject:    engLead,
+		},
+	} {
+		_, _ = unary(ctx, req, &grpc.UnaryServerInfo{FullMethod: checkMethod}, check)
+	}
+
+	// Replayed requests are not captured again.
+	replayedCtx := metadata.NewIncomingContext(ctx, metadata.Pairs("x-spicedb-replay", "true"))
+	_, err = unary(replayedCtx, &v1.CheckPermissionRequest{}, &grpc.UnaryServerInfo{FullMethod: checkMethod}, check)
+	require.Error(err)
+
+	stream := capture.StreamServerInterceptor(recorder)
+	lookupReq := &v1.LookupResourcesRequest{ResourceObjectType: "document", Permission: "view", Subject: engLead}
+	err = stream(nil, &testStream{ctx: ctx, request: lookupReq}, &grpc.StreamServerInfo{FullMethod: lookupMethod, IsServerStream: true}, func(srv any, ss grpc.ServerStream) error {
+		req := &v1.LookupResourcesRequest{}
+		if err := ss.RecvMsg(req); err != nil {
+			return err
+		}
+
+		results, err := client.LookupResources(ss.Context(), req)
+		if err != nil {
+			return err
+		}
+		for {
+			resp, err := results.Recv()
+			if err != nil {
+				return nil
+			}
+			if err := ss.SendMsg(resp); err != nil {
+				return err
+			}
+		}
+	})
+	require.NoError(err)
+	stop()
+
+	paths, exchanges := readAll(t, directory)
+	require.Len(paths, 2)
+	require.Len(exchanges, 3)
+
+	require.Equal(checkMethod, exchanges[0].Method)
+	require.Equal("OK", exchanges[0].Code)
+	require.Len(exchanges[0].Responses, 1)
+	require.NotContains(string(exchanges[0].Request), checked.CheckedAt.Token)
+	require.NotContains(string(exchanges[0].Responses[0]), "checkedAt")
+
+	require.Equal("FailedPrecondition", exchanges[1].Code)
+	require.Empty(exchanges[1].Responses)
+	require.NotEmpty(exchanges[1].Error)
+
+	require.Equal(lookupMethod, exchanges[2].Method)
+	require.NotEmpty(exchanges[2].Responses)
+
+	// Replaying against the same data matches every response.
+	report := capture.Replay(ctx, conn, exchanges, 2)
+	require.Empty(report.Mismatches)
+	require.Equal([]capture.MethodReport{
+		{Method: checkMethod, Replayed: 2, Matched: 2},
+		{Method: lookupMethod, Replayed: 1, Matched: 1},
+	}, report.Methods)
+
+	// Responses which differ, and missing responses, are reported as mismatches, and requests of
+	// unknown methods are skipped.
+	changed, err := json.Marshal(map[string]any{"permissionship": "PERMISSIONSHIP_NO_PERMISSION"})
+	require.NoError(err)
+	exchanges[0].Responses = []json.RawMessage{changed}
+	exchanges[2].Responses = append(exchanges[2].Responses, exchanges[2].Responses[0])
+	exchanges = append(exchanges, capture.Exchange{Method: "/authzed.api.v1.PermissionsService/WriteRelationships", Request: json.RawMessage("{}")})
+
+	report = capture.Replay(ctx, conn, exchanges, 2)
+	require.Len(report.Mismatches, 2)
+	require.Equal([]capture.MethodReport{
+		{Method: checkMethod, Replayed: 2, Matched: 1, Mismatched: 1},
+		{Method: lookupMethod, Replayed: 1, Mismatched: 1},
+		{Method: "/authzed.api.v1.PermissionsService/WriteRelationships", Skipped: 1},
+	}, report.Methods)
+}
+
+func TestCaptureRotation(t *testing.T) {
+	directory := t.TempDir()
+	recorder, stop := newRecorder(t, capture.Config{
+		Directory:            directory,
+		SampleRate:           1,
+		MaxExchangesPerFile:  1,
+		MaxFiles:             2,
+		MaxResponses:         1,
+		MaxBufferedExchanges: 10,
+	})
+
+	stream := capture.StreamServerInterceptor(recorder)
+	info := &grpc.StreamServerInfo{FullMethod: lookupMethod, IsServerStream: true}
+	for i := 0; i < 3; i++ {
+		err := stream(nil, &testStream{ctx: context.Background(), request: &v1.LookupResourcesRequest{}}, info, func(srv any, ss grpc.ServerStream) error {
+			if err := ss.RecvMsg(&v1.LookupResourcesRequest{}); err != nil {
+				return err
+			}
+			for j := 0; j < 2; j++ {
+				if err := ss.SendMsg(&v1.LookupResourcesResponse{ResourceObjectId: "someid"}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		require.NoError(t, err)
+	}
+	stop()
+
+	paths, exchanges := readAll(t, directory)
+	require.Len(t, paths, 2)
+	require.Len(t, exchanges, 2)
+	for _, exchange := range exchanges {
+		require.Len(t, exchange.Responses, 1)
+		require.True(t, exchange.Truncated)
+	}
+}
+
+func TestNewRecorderErrors(t *testing.T) {
+	valid := capture.Config{
+		Directory:            t.TempDir(),
+		SampleRate:           1,
+		MaxExchangesPerFile:  1,
+		MaxFiles:             1,
+		MaxResponses:         1,
+		MaxBufferedExchanges: 1,
+	}
+	_, err := capture.NewRecorder(valid)
+	require.NoError(t, err)
+
+	for _, invalidate := range []func(*capture.Config){
+		func(c *capture.Config) { c.Directory = "" },
+		func(c *capture.Config) { c.SampleRate = 2 },
+		func(c *capture.Config) { c.MaxExchangesPerFile = 0 },
+		func(c *capture.Config) { c.MaxFiles = 0 },
+		func(c *capture.Config) { c.MaxResponses = 0 },
+		func(c *capture.Config) { c.MaxBufferedExchanges = 0 },
+	} {
+		config := valid
+		invalidate(&config)
+		_, err := capture.NewRecorder(config)
+		require.Error(t, err)
+	}
+}
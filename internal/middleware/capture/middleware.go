@@ -0,0 +1,100 @@
+package capture
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// replayedMetadataKey is the metadata key set on replayed requests, which are not captured
+// again.
+const replayedMetadataKey = "x-spicedb-replay"
+
+func isReplayed(ctx context.Context) bool {
+	md, ok := metadata.FromIncomingContext(ctx)
+	return ok && len(md.Get(replayedMetadataKey)) > 0
+}
+
+// UnaryServerInterceptor returns a new interceptor which captures a sample of the requests
+// served, along with their responses, with the recorder. A nil recorder captures nothing.
+func UnaryServerInterceptor(recorder *Recorder) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !recorder.sample(ctx, info.FullMethod) {
+			return handler(ctx, req)
+		}
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		request, ok := req.(proto.Message)
+		if !ok {
+			return resp, err
+		}
+
+		var responses []proto.Message
+		if response, ok := resp.(proto.Message); ok && err == nil {
+			responses = append(responses, response)
+		}
+		recorder.record(ctx, info.FullMethod, request, responses, false, err, start)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns a new interceptor which captures a sample of the streaming
+// requests served, along with up to the configured maximum number of their responses, with the
+// recorder. A nil recorder captures nothing.
+func StreamServerInterceptor(recorder *Recorder) grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !recorder.sample(stream.Context(), info.FullMethod) {
+			return handler(srv, stream)
+		}
+
+		start := time.Now()
+		wrapped := &capturingStream{ServerStream: stream, maxResponses: recorder.config.MaxResponses}
+		err := handler(srv, wrapped)
+		if wrapped.request != nil {
+			recorder.record(stream.Context(), info.FullMethod, wrapped.request, wrapped.responses, wrapped.truncated, err, start)
+		}
+		return err
+	}
+}
+
+// capturingStream captures the first message received on a stream, which is the request of
+// server streaming methods, and the messages sent.
+type capturingStream struct {
+	grpc.ServerStream
+	maxResponses int
+
+	request   proto.Message
+	responses []proto.Message
+	truncated bool
+}
+
+func (s *capturingStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	if message, ok := m.(proto.Message); ok && s.request == nil {
+		s.request = message
+	}
+	return nil
+}
+
+func (s *capturingStream) SendMsg(m any) error {
+	if err := s.ServerStream.SendMsg(m); err != nil {
+		return err
+	}
+
+	if message, ok := m.(proto.Message); ok {
+		if len(s.responses) < s.maxResponses {
+			s.responses = append(s.responses, message)
+		} else {
+			s.truncated = true
+		}
+	}
+	return nil
+}
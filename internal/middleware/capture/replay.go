@@ -0,0 +1,265 @@
+package capture
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// fullyConsistent is the consistency at which requests are replayed, since the revisions at
+// which they were served exist only in the cluster from which they were captured.
+var fullyConsistent = &v1.Consistency{Requirement: &v1.Consistency_FullyConsistent{FullyConsistent: true}}
+
+// Outcome is the outcome of a request: the status code with which it completed, and its
+// responses.
+type Outcome struct {
+	Code      string            `json:"code"`
+	Responses []json.RawMessage `json:"responses,omitempty"`
+}
+
+// Mismatch is a replayed request whose outcome differed from that captured.
+type Mismatch struct {
+	Method   string          `json:"method"`
+	Request  json.RawMessage `json:"request"`
+	Expected Outcome         `json:"expected"`
+	Actual   Outcome         `json:"actual"`
+}
+
+// MethodReport counts the results of replaying the requests of a method.
+type MethodReport struct {
+	Method     string `json:"method"`
+	Replayed   int    `json:"replayed"`
+	Matched    int    `json:"matched"`
+	Mismatched int    `json:"mismatched"`
+	Skipped    int    `json:"skipped"`
+}
+
+// Report is the result of a replay.
+type Report struct {
+	Methods    []MethodReport `json:"methods"`
+	Mismatches []Mismatch     `json:"mismatches,omitempty"`
+}
+
+// WriteTable writes the counts of the report as a table.
+func (r *Report) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "METHOD\tREPLAYED\tMATCHED\tMISMATCHED\tSKIPPED")
+	for _, method := range r.Methods {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\n", method.Method, method.Replayed, method.Matched, method.Mismatched, method.Skipped)
+	}
+	return tw.Flush()
+}
+
+// Replay replays the exchanges on the connection, with the given number concurrently, and
+// compares the outcome of each request against that captured. Requests are replayed fully
+// consistently, and their responses compared without regard to their order, since neither
+// the revisions nor the orders of the responses of the cluster from which they were captured
+// can be reproduced. Only the status codes of exchanges whose responses were truncated are
+// compared. Exchanges which cannot be replayed, and those remaining once the context is
+// cancelled, are skipped.
+func Replay(ctx context.Context, conn grpc.ClientConnInterface, exchanges []Exchange, concurrency int) *Report {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	ctx = metadata.AppendToOutgoingContext(ctx, replayedMetadataKey, "true")
+
+	var mu sync.Mutex
+	methods := make(map[string]*MethodReport)
+	report := &Report{}
+	count := func(exchange Exchange, mismatch *Mismatch, skipped bool) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		method, ok := methods[exchange.Method]
+		if !ok {
+			method = &MethodReport{Method: exchange.Method}
+			methods[exchange.Method] = method
+		}
+
+		switch {
+		case skipped:
+			method.Skipped++
+		case mismatch != nil:
+			method.Replayed++
+			method.Mismatched++
+			report.Mismatches = append(report.Mismatches, *mismatch)
+		default:
+			method.Replayed++
+			method.Matched++
+		}
+	}
+
+	pending := make(chan Exchange)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for exchange := range pending {
+				if ctx.Err() != nil {
+					count(exchange, nil, true)
+					continue
+				}
+
+				mismatch, err := replay(ctx, conn, exchange)
+				if err != nil {
+					count(exchange, nil, true)
+					continue
+				}
+				count(exchange, mismatch, false)
+			}
+		}()
+	}
+
+	for _, exchange := range exchanges {
+		pending <- exchange
+	}
+	close(pending)
+	wg.Wait()
+
+	for _, method := range methods {
+		report.Methods = append(report.Methods, *method)
+	}
+	sort.Slice(report.Methods, func(i, j int) bool { return report.Methods[i].Method < report.Methods[j].Method })
+	return report
+}
+
+// replay replays the exchange, returning the mismatch of its outcome, if any, or an error if it
+// cannot be replayed.
+func replay(ctx context.Context, conn grpc.ClientConnInterface, exchange Exchange) (*Mismatch, error) {
+	method, ok := capturedMethods[exchange.Method]
+	if !ok {
+		return nil, fmt.Errorf("unable to replay requests of method %s", exchange.Method)
+	}
+
+	req := method.newRequest()
+	if err := protojson.Unmarshal(exchange.Request, req); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal request: %w", err)
+	}
+
+	expected := make([]proto.Message, 0, len(exchange.Responses))
+	for _, response := range exchange.Responses {
+		resp := method.newResponse()
+		if err := protojson.Unmarshal(response, resp); err != nil {
+			return nil, fmt.Errorf("unable to unmarshal response: %w", err)
+		}
+		expected = append(expected, resp)
+	}
+
+	switch typed := req.(type) {
+	case *v1.CheckPermissionRequest:
+		typed.Consistency = fullyConsistent
+	case *v1.ExpandPermissionTreeRequest:
+		typed.Consistency = fullyConsistent
+	case *v1.LookupResourcesRequest:
+		typed.Consistency = fullyConsistent
+	case *v1.LookupSubjectsRequest:
+		typed.Consistency = fullyConsistent
+	case *v1.ReadRelationshipsRequest:
+		typed.Consistency = fullyConsistent
+	}
+
+	var actual []proto.Message
+	var err error
+	if method.streaming {
+		actual, err = invokeStreaming(ctx, conn, exchange.Method, req, method.newResponse)
+	} else {
+		resp := method.newResponse()
+		if err = conn.Invoke(ctx, exchange.Method, req, resp); err == nil {
+			actual = append(actual, resp)
+		}
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	code := status.Code(err).String()
+	if code == exchange.Code && (exchange.Truncated || equalResponses(expected, actual)) {
+		return nil, nil
+	}
+
+	mismatch := &Mismatch{
+		Method:   exchange.Method,
+		Request:  exchange.Request,
+		Expected: Outcome{Code: exchange.Code, Responses: exchange.Responses},
+		Actual:   Outcome{Code: code},
+	}
+	for _, resp := range actual {
+		marshaled, err := marshal(resp)
+		if err != nil {
+			return nil, fmt.Errorf("unable to marshal response: %w", err)
+		}
+		mismatch.Actual.Responses = append(mismatch.Actual.Responses, marshaled)
+	}
+	return mismatch, nil
+}
+
+func invokeStreaming(ctx context.Context, conn grpc.ClientConnInterface, method string, req proto.Message, newResponse func() proto.Message) ([]proto.Message, error) {
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, method)
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.SendMsg(req); err != nil {
+		return nil, err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, err
+	}
+
+	var responses []proto.Message
+	for {
+		resp := newResponse()
+		if err := stream.RecvMsg(resp); errors.Is(err, io.EOF) {
+			return responses, nil
+		} else if err != nil {
+			return responses, err
+		}
+		responses = append(responses, resp)
+	}
+}
+
+// equalResponses returns whether the sanitized responses are equal, without regard to their
+// order.
+func equalResponses(expected, actual []proto.Message) bool {
+	if len(expected) != len(actual) {
+		return false
+	}
+
+	encode := func(responses []proto.Message) [][]byte {
+		encoded := make([][]byte, 0, len(responses))
+		for _, resp := range responses {
+			marshaled, err := proto.MarshalOptions{Deterministic: true}.Marshal(sanitize(resp))
+			if err != nil {
+				return nil
+			}
+			encoded = append(encoded, marshaled)
+		}
+		sort.Slice(encoded, func(i, j int) bool { return bytes.Compare(encoded[i], encoded[j]) < 0 })
+		return encoded
+	}
+
+	expectedEncoded, actualEncoded := encode(expected), encode(actual)
+	if expectedEncoded == nil || actualEncoded == nil {
+		return false
+	}
+	for i := range expectedEncoded {
+		if !bytes.Equal(expectedEncoded[i], actualEncoded[i]) {
+			return false
+		}
+	}
+	return true
+}
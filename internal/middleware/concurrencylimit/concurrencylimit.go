@@ -0,0 +1,128 @@
+// Package concurrencylimit implements middleware which allows callers to lower the
+// maximum number of branches evaluated concurrently for their API requests.
+package concurrencylimit
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+
+	middleware "github.com/grpc-ecosystem/go-grpc-middleware/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+)
+
+// RequestConcurrencyLimitHeader is the request header in which callers can specify the
+// maximum number of branches to evaluate concurrently for the request.
+const RequestConcurrencyLimitHeader = "io.spicedb.requestconcurrencylimit"
+
+type ctxKeyType struct{}
+
+var concurrencyLimitKey ctxKeyType = struct{}{}
+
+// ContextWithLimit returns a new context with the given concurrency limit.
+func ContextWithLimit(ctx context.Context, limit uint32) context.Context {
+	return context.WithValue(ctx, concurrencyLimitKey, limit)
+}
+
+// FromContext returns the concurrency limit requested for the current request, or zero
+// if none.
+func FromContext(ctx context.Context) uint32 {
+	if limit, ok := ctx.Value(concurrencyLimitKey).(uint32); ok {
+		return limit
+	}
+	return 0
+}
+
+// ForRequest returns the concurrency limit for a dispatch request: either that found in its
+// metadata or, for requests originating from this node's API, that found in the context.
+func ForRequest(ctx context.Context, md *v1.ResolverMeta) uint32 {
+	if md.GetConcurrencyLimit() > 0 {
+		return md.ConcurrencyLimit
+	}
+	return FromContext(ctx)
+}
+
+// DefaultLimit is the concurrency limit applied to requests which do not specify one. It can be
+// changed while serving.
+type DefaultLimit struct {
+	limit atomic.Uint32
+}
+
+// NewDefaultLimit returns a new default limit. A limit of zero applies no limit beyond that
+// configured for dispatch.
+func NewDefaultLimit(limit uint16) *DefaultLimit {
+	d := &DefaultLimit{}
+	d.Set(limit)
+	return d
+}
+
+// Set changes the default limit of subsequent requests.
+func (d *DefaultLimit) Set(limit uint16) {
+	d.limit.Store(uint32(limit))
+}
+
+// Get returns the current default limit.
+func (d *DefaultLimit) Get() uint16 {
+	if d == nil {
+		return 0
+	}
+	return uint16(d.limit.Load())
+}
+
+type handleConcurrencyLimit struct {
+	defaultLimit *DefaultLimit
+}
+
+func (h *handleConcurrencyLimit) fromRequest(ctx context.Context) (context.Context, error) {
+	limit := uint32(h.defaultLimit.Get())
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(RequestConcurrencyLimitHeader); len(values) > 0 {
+			parsed, err := strconv.ParseUint(values[0], 10, 16)
+			if err != nil || parsed == 0 {
+				return ctx, status.Errorf(codes.InvalidArgument, "invalid value for %s: must be a positive integer", RequestConcurrencyLimitHeader)
+			}
+			limit = uint32(parsed)
+		}
+	}
+
+	if limit == 0 {
+		return ctx, nil
+	}
+	return ContextWithLimit(ctx, limit), nil
+}
+
+// UnaryServerInterceptor returns a new interceptor which applies the concurrency limit
+// requested by the caller, or the default limit if none was requested. A nil default limit
+// applies no limit beyond that configured for dispatch.
+func UnaryServerInterceptor(defaultLimit *DefaultLimit) grpc.UnaryServerInterceptor {
+	h := &handleConcurrencyLimit{defaultLimit}
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := h.fromRequest(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns a new interceptor which applies the concurrency limit
+// requested by the caller, or the default limit if none was requested. A nil default limit
+// applies no limit beyond that configured for dispatch.
+func StreamServerInterceptor(defaultLimit *DefaultLimit) grpc.StreamServerInterceptor {
+	h := &handleConcurrencyLimit{defaultLimit}
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := h.fromRequest(stream.Context())
+		if err != nil {
+			return err
+		}
+
+		wrapped := middleware.WrapServerStream(stream)
+		wrapped.WrappedContext = ctx
+		return handler(srv, wrapped)
+	}
+}
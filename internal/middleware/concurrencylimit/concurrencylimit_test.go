@@ -0,0 +1,78 @@
+package concurrencylimit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		header        []string
+		defaultLimit  uint16
+		expectedLimit uint32
+		expectedCode  codes.Code
+	}{
+		{"no header, no default", nil, 0, 0, codes.OK},
+		{"no header, default", nil, 5, 5, codes.OK},
+		{"header overrides default", []string{"2"}, 5, 2, codes.OK},
+		{"header without default", []string{"12"}, 0, 12, codes.OK},
+		{"zero header", []string{"0"}, 5, 0, codes.InvalidArgument},
+		{"invalid header", []string{"many"}, 5, 0, codes.InvalidArgument},
+		{"out of range header", []string{"100000"}, 5, 0, codes.InvalidArgument},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tc.header != nil {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(RequestConcurrencyLimitHeader, tc.header[0]))
+			}
+
+			var foundLimit uint32
+			_, err := UnaryServerInterceptor(NewDefaultLimit(tc.defaultLimit))(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req any) (any, error) {
+				foundLimit = FromContext(ctx)
+				return nil, nil
+			})
+			require.Equal(t, tc.expectedCode, status.Code(err))
+			require.Equal(t, tc.expectedLimit, foundLimit)
+		})
+	}
+}
+
+func TestDefaultLimitChanged(t *testing.T) {
+	defaultLimit := NewDefaultLimit(5)
+	interceptor := UnaryServerInterceptor(defaultLimit)
+
+	limitOf := func() uint32 {
+		var foundLimit uint32
+		_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req any) (any, error) {
+			foundLimit = FromContext(ctx)
+			return nil, nil
+		})
+		require.NoError(t, err)
+		return foundLimit
+	}
+
+	require.Equal(t, uint32(5), limitOf())
+
+	defaultLimit.Set(2)
+	require.Equal(t, uint32(2), limitOf())
+
+	defaultLimit.Set(0)
+	require.Equal(t, uint32(0), limitOf())
+}
+
+func TestForRequest(t *testing.T) {
+	ctx := ContextWithLimit(context.Background(), 4)
+
+	require.Equal(t, uint32(0), ForRequest(context.Background(), &v1.ResolverMeta{}))
+	require.Equal(t, uint32(4), ForRequest(ctx, &v1.ResolverMeta{}))
+	require.Equal(t, uint32(7), ForRequest(ctx, &v1.ResolverMeta{ConcurrencyLimit: 7}))
+}
@@ -8,9 +8,9 @@ import (
 
 	"github.com/authzed/spicedb/internal/services/shared"
 
+	"github.com/authzed/authzed-go/pkg/responsemeta"
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
 	log "github.com/authzed/spicedb/internal/logging"
@@ -27,10 +27,19 @@ type ctxKeyType struct{}
 
 var revisionKey ctxKeyType = struct{}{}
 
+// EvaluatedAt is the key in the response header metadata holding the ZedToken of the exact
+// revision at which the request was evaluated. Unlike the tokens found in streamed responses,
+// it is returned even when no results are found.
+const EvaluatedAt responsemeta.ResponseMetadataHeaderKey = "io.spicedb.respmeta.evaluatedat"
+
 var errInvalidZedToken = errors.New("invalid revision requested")
 
 type revisionHandle struct {
 	revision datastore.Revision
+
+	// datastoreRevision is the revision read from the datastore to select the revision, if any,
+	// as opposed to one supplied by the request.
+	datastoreRevision datastore.Revision
 }
 
 // ContextWithHandle adds a placeholder to a context that will later be
@@ -60,6 +69,15 @@ func MustRevisionFromContext(ctx context.Context) (datastore.Revision, *v1.ZedTo
 	return rev, zedtoken.MustNewFromRevision(rev)
 }
 
+// SetEvaluatedRevisionHeader sets the ZedToken for the revision selected for the request in the
+// response header metadata, so that clients may chain subsequent requests requiring at least
+// that revision, whichever consistency was requested.
+func SetEvaluatedRevisionHeader(ctx context.Context, revisionToken *v1.ZedToken) error {
+	return responsemeta.SetResponseHeaderMetadata(ctx, map[responsemeta.ResponseMetadataHeaderKey]string{
+		EvaluatedAt: revisionToken.Token,
+	})
+}
+
 // AddRevisionToContext adds a revision to the given context, based on the consistency block found
 // in the given request (if applicable).
 func AddRevisionToContext(ctx context.Context, req interface{}, ds datastore.Datastore) error {
@@ -83,6 +101,7 @@ func addHeadRevision(ctx context.Context, ds datastore.Datastore) error {
 		return rewriteDatastoreError(ctx, err)
 	}
 	handle.(*revisionHandle).revision = revision
+	handle.(*revisionHandle).datastoreRevision = revision
 	return nil
 }
 
@@ -94,7 +113,7 @@ func addRevisionToContextFromConsistency(ctx context.Context, req hasConsistency
 		return nil
 	}
 
-	var revision datastore.Revision
+	var revision, datastoreRevision datastore.Revision
 	consistency := req.GetConsistency()
 
 	switch {
@@ -104,7 +123,7 @@ func addRevisionToContextFromConsistency(ctx context.Context, req hasConsistency
 		if err != nil {
 			return rewriteDatastoreError(ctx, err)
 		}
-		revision = databaseRev
+		revision, datastoreRevision = databaseRev, databaseRev
 
 	case consistency.GetFullyConsistent():
 		// Fully Consistent: Use the datastore's synchronized revision.
@@ -112,16 +131,16 @@ func addRevisionToContextFromConsistency(ctx context.Context, req hasConsistency
 		if err != nil {
 			return rewriteDatastoreError(ctx, err)
 		}
-		revision = databaseRev
+		revision, datastoreRevision = databaseRev, databaseRev
 
 	case consistency.GetAtLeastAsFresh() != nil:
 		// At least as fresh as: Pick one of the datastore's revision and that specified, which
 		// ever is later.
-		picked, err := pickBestRevision(ctx, consistency.GetAtLeastAsFresh(), ds)
+		picked, databaseRev, err := pickBestRevision(ctx, consistency.GetAtLeastAsFresh(), ds)
 		if err != nil {
 			return rewriteDatastoreError(ctx, err)
 		}
-		revision = picked
+		revision, datastoreRevision = picked, databaseRev
 
 	case consistency.GetAtExactSnapshot() != nil:
 		// Exact snapshot: Use the revision as encoded in the zed token.
@@ -142,6 +161,16 @@ func addRevisionToContextFromConsistency(ctx context.Context, req hasConsistency
 	}
 
 	handle.(*revisionHandle).revision = revision
+	handle.(*revisionHandle).datastoreRevision = datastoreRevision
+	return nil
+}
+
+// datastoreRevisionFromContext reads the revision read from the datastore to select the revision
+// of the request out of a context.Context, and returns nil if there is none.
+func datastoreRevisionFromContext(ctx context.Context) datastore.Revision {
+	if c := ctx.Value(revisionKey); c != nil {
+		return c.(*revisionHandle).datastoreRevision
+	}
 	return nil
 }
 
@@ -153,6 +182,13 @@ var bypassServiceWhitelist = map[string]struct{}{
 // UnaryServerInterceptor returns a new unary server interceptor that performs per-request exchange of
 // the specified consistency configuration for the revision at which to perform the request.
 func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return UnaryServerInterceptorWithStaleFallback(nil)
+}
+
+// UnaryServerInterceptorWithStaleFallback returns a new unary server interceptor as does
+// UnaryServerInterceptor, which serves checks at the last known good revision recorded by the
+// fallback when the revision of the datastore cannot be read. A nil fallback never serves them.
+func UnaryServerInterceptorWithStaleFallback(fallback *StaleFallback) grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 		for bypass := range bypassServiceWhitelist {
 			if strings.HasPrefix(info.FullMethod, bypass) {
@@ -162,7 +198,14 @@ func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
 		ds := datastoremw.MustFromContext(ctx)
 		newCtx := ContextWithHandle(ctx)
 		if err := AddRevisionToContext(newCtx, req, ds); err != nil {
-			return nil, err
+			if !fallback.serve(newCtx, req, ds, err) {
+				return nil, err
+			}
+		} else {
+			fallback.record(datastoreRevisionFromContext(newCtx))
+		}
+		if req, ok := req.(hasConsistency); ok {
+			setFreshnessHeaders(newCtx, req, ds)
 		}
 
 		return handler(newCtx, req)
@@ -201,30 +244,35 @@ func (s *recvWrapper) RecvMsg(m interface{}) error {
 	if err := AddRevisionToContext(s.ctx, m, ds); err != nil {
 		return err
 	}
+	if req, ok := m.(hasConsistency); ok {
+		setFreshnessHeaders(s.ctx, req, ds)
+	}
 
 	return nil
 }
 
-func pickBestRevision(ctx context.Context, requested *v1.ZedToken, ds datastore.Datastore) (datastore.Revision, error) {
+// pickBestRevision returns the later of the requested revision and the optimized revision of the
+// datastore, along with the latter.
+func pickBestRevision(ctx context.Context, requested *v1.ZedToken, ds datastore.Datastore) (datastore.Revision, datastore.Revision, error) {
 	// Calculate a revision as we see fit
 	databaseRev, err := ds.OptimizedRevision(ctx)
 	if err != nil {
-		return datastore.NoRevision, err
+		return datastore.NoRevision, datastore.NoRevision, err
 	}
 
 	if requested != nil {
 		requestedRev, err := zedtoken.DecodeRevision(requested, ds)
 		if err != nil {
-			return datastore.NoRevision, errInvalidZedToken
+			return datastore.NoRevision, datastore.NoRevision, errInvalidZedToken
 		}
 
 		if databaseRev.GreaterThan(requestedRev) {
-			return databaseRev, nil
+			return databaseRev, databaseRev, nil
 		}
-		return requestedRev, nil
+		return requestedRev, databaseRev, nil
 	}
 
-	return databaseRev, nil
+	return databaseRev, databaseRev, nil
 }
 
 func rewriteDatastoreError(ctx context.Context, err error) error {
@@ -233,9 +281,10 @@ func rewriteDatastoreError(ctx context.Context, err error) error {
 		return err
 	}
 
+	var invalidRevisionErr datastore.ErrInvalidRevision
 	switch {
-	case errors.As(err, &datastore.ErrInvalidRevision{}):
-		return status.Errorf(codes.OutOfRange, "invalid revision: %s", err)
+	case errors.As(err, &invalidRevisionErr):
+		return shared.RewriteInvalidRevisionErr(invalidRevisionErr, "invalid revision")
 
 	case errors.As(err, &datastore.ErrReadOnly{}):
 		return shared.ErrServiceReadOnly
@@ -0,0 +1,88 @@
+package consistency
+
+import (
+	"context"
+	"time"
+
+	"github.com/authzed/authzed-go/pkg/responsemeta"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/prometheus/client_golang/prometheus"
+
+	dscommon "github.com/authzed/spicedb/internal/datastore/common"
+	log "github.com/authzed/spicedb/internal/logging"
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+const (
+	// RevisionTimestamp is the key in the response header metadata holding the wall clock time,
+	// in RFC 3339 format, at which the revision at which a read was evaluated was committed.
+	RevisionTimestamp responsemeta.ResponseMetadataHeaderKey = "io.spicedb.respmeta.revisiontimestamp"
+
+	// Staleness is the key in the response header metadata holding the duration between the time
+	// at which the revision at which a read was evaluated was committed and the time at which the
+	// read was evaluated. The revision may have been current for some or all of that time.
+	Staleness responsemeta.ResponseMetadataHeaderKey = "io.spicedb.respmeta.staleness"
+)
+
+var revisionStalenessHistogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "spicedb",
+	Subsystem: "consistency",
+	Name:      "revision_staleness_seconds",
+	Help:      "The duration between the commit of the revision at which reads were evaluated and their evaluation, by the consistency requested.",
+	Buckets:   []float64{.001, .01, .1, .5, 1, 2.5, 5, 10, 30, 60, 300},
+}, []string{"consistency"})
+
+func init() {
+	prometheus.MustRegister(revisionStalenessHistogram)
+}
+
+// setFreshnessHeaders sets the time of the revision selected for the read, and its staleness, in
+// the response header metadata, where the datastore can find the time of the revision. Freshness
+// is reported on a best effort basis, and never fails the read.
+func setFreshnessHeaders(ctx context.Context, req hasConsistency, ds datastore.Datastore) {
+	revision := RevisionFromContext(ctx)
+	if revision == nil {
+		return
+	}
+
+	timer, ok := datastore.Unwrap(ds).(dscommon.RevisionTimeDatastore)
+	if !ok {
+		return
+	}
+
+	at, err := timer.RevisionTime(ctx, revision)
+	if err != nil {
+		log.Ctx(ctx).Debug().Err(err).Stringer("revision", revision).Msg("unable to find the time of the revision")
+		return
+	}
+
+	// Revisions may be chosen slightly ahead of the clock of this server.
+	staleness := time.Since(at)
+	if staleness < 0 {
+		staleness = 0
+	}
+	revisionStalenessHistogram.WithLabelValues(consistencyName(req.GetConsistency())).Observe(staleness.Seconds())
+
+	if err := responsemeta.SetResponseHeaderMetadata(ctx, map[responsemeta.ResponseMetadataHeaderKey]string{
+		RevisionTimestamp: at.Format(time.RFC3339Nano),
+		Staleness:         staleness.String(),
+	}); err != nil {
+		log.Ctx(ctx).Debug().Err(err).Msg("unable to set the freshness of the revision")
+	}
+}
+
+// consistencyName returns the name of the consistency requested, for metrics.
+func consistencyName(consistency *v1.Consistency) string {
+	switch {
+	case consistency == nil || consistency.GetMinimizeLatency():
+		return "minimize_latency"
+	case consistency.GetFullyConsistent():
+		return "fully_consistent"
+	case consistency.GetAtLeastAsFresh() != nil:
+		return "at_least_as_fresh"
+	case consistency.GetAtExactSnapshot() != nil:
+		return "at_exact_snapshot"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,50 @@
+package consistency
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
+)
+
+func TestFreshnessHeaders(t *testing.T) {
+	ds, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	require.NoError(t, err)
+	t.Cleanup(func() { ds.Close() })
+
+	ctx := datastoremw.ContextWithDatastore(context.Background(), ds)
+	interceptor := UnaryServerInterceptor()
+	handler := func(ctx context.Context, req any) (any, error) {
+		return nil, nil
+	}
+
+	before := time.Now()
+	recorder := &headerRecorder{}
+	_, err = interceptor(grpc.NewContextWithServerTransportStream(ctx, recorder), &v1.CheckPermissionRequest{
+		Consistency: &v1.Consistency{Requirement: &v1.Consistency_FullyConsistent{FullyConsistent: true}},
+	}, &grpc.UnaryServerInfo{}, handler)
+	require.NoError(t, err)
+
+	require.Len(t, recorder.header.Get(string(RevisionTimestamp)), 1)
+	at, err := time.Parse(time.RFC3339Nano, recorder.header.Get(string(RevisionTimestamp))[0])
+	require.NoError(t, err)
+	require.False(t, at.After(time.Now()))
+
+	require.Len(t, recorder.header.Get(string(Staleness)), 1)
+	staleness, err := time.ParseDuration(recorder.header.Get(string(Staleness))[0])
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, staleness, before.Sub(at))
+
+	// Requests without a consistency are not reads, and are not annotated.
+	recorder = &headerRecorder{}
+	_, err = interceptor(grpc.NewContextWithServerTransportStream(ctx, recorder), &v1.WriteRelationshipsRequest{}, &grpc.UnaryServerInfo{}, handler)
+	require.NoError(t, err)
+	require.Empty(t, recorder.header.Get(string(RevisionTimestamp)))
+	require.Empty(t, recorder.header.Get(string(Staleness)))
+}
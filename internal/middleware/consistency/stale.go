@@ -0,0 +1,134 @@
+package consistency
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/authzed/authzed-go/pkg/responsemeta"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	log "github.com/authzed/spicedb/internal/logging"
+	"github.com/authzed/spicedb/pkg/datastore"
+	"github.com/authzed/spicedb/pkg/zedtoken"
+)
+
+// ServedStale is the key in the response header metadata holding the duration since the
+// revision at which a check was evaluated was last known to be current, set when the check was
+// served at that revision because the revision of the datastore could not be read.
+const ServedStale responsemeta.ResponseMetadataHeaderKey = "io.spicedb.respmeta.servedstale"
+
+var staleChecksCounter = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "spicedb",
+	Subsystem: "consistency",
+	Name:      "stale_checks_total",
+	Help:      "The number of checks served at the last known good revision because the revision of the datastore could not be read.",
+})
+
+func init() {
+	prometheus.MustRegister(staleChecksCounter)
+}
+
+// StaleFallback records the latest revision read from the datastore, at which checks are served
+// when the revision of the datastore cannot be read, such as during a brief outage of the
+// database, for as long as the revision was known to be current within the maximum staleness.
+// Checks served at the revision are answered by the caches of the server where they can be, and
+// fail as they would have otherwise where they cannot.
+type StaleFallback struct {
+	maximumStaleness time.Duration
+
+	// known and readAt are updated without locking, as they are by every request. A revision may
+	// briefly be paired with the time at which a later revision was read, which is at most as
+	// stale as the revision.
+	known  atomic.Pointer[knownRevision]
+	readAt atomic.Int64
+}
+
+type knownRevision struct {
+	revision datastore.Revision
+}
+
+// NewStaleFallback creates a new StaleFallback serving checks at revisions known to be current
+// within the maximum staleness.
+func NewStaleFallback(maximumStaleness time.Duration) *StaleFallback {
+	return &StaleFallback{maximumStaleness: maximumStaleness}
+}
+
+// record records the revision read from the datastore for a request, if it is at least the
+// latest revision recorded. Revisions supplied by requests are never recorded, as they may not
+// exist.
+func (sf *StaleFallback) record(revision datastore.Revision) {
+	if sf == nil || revision == nil {
+		return
+	}
+
+	for {
+		known := sf.known.Load()
+		if known != nil && known.revision.GreaterThan(revision) {
+			return
+		}
+
+		if known == nil || !known.revision.Equal(revision) {
+			if !sf.known.CompareAndSwap(known, &knownRevision{revision}) {
+				continue
+			}
+		}
+
+		sf.readAt.Store(time.Now().UnixNano())
+		return
+	}
+}
+
+// serve selects the last known good revision for the request, which failed to select its
+// revision with the given error, and returns whether it did. Only checks which do not require
+// full consistency or an exact snapshot are served, and only when the datastore could not be
+// reached.
+func (sf *StaleFallback) serve(ctx context.Context, req any, ds datastore.Datastore, err error) bool {
+	if sf == nil || ctx.Err() != nil || errors.Is(err, errInvalidZedToken) {
+		return false
+	}
+	switch status.Code(err) {
+	case codes.Unknown, codes.Unavailable, codes.Internal, codes.DeadlineExceeded:
+		// The datastore could not be reached, as opposed to the request being invalid.
+	default:
+		return false
+	}
+
+	checkReq, ok := req.(*v1.CheckPermissionRequest)
+	if !ok || checkReq.GetConsistency().GetFullyConsistent() || checkReq.GetConsistency().GetAtExactSnapshot() != nil {
+		return false
+	}
+
+	known := sf.known.Load()
+	if known == nil {
+		return false
+	}
+
+	revision := known.revision
+	staleness := time.Since(time.Unix(0, sf.readAt.Load()))
+	if staleness > sf.maximumStaleness {
+		return false
+	}
+
+	if requested := checkReq.GetConsistency().GetAtLeastAsFresh(); requested != nil {
+		requestedRev, err := zedtoken.DecodeRevision(requested, ds)
+		if err != nil || requestedRev.GreaterThan(revision) {
+			return false
+		}
+	}
+
+	if err := responsemeta.SetResponseHeaderMetadata(ctx, map[responsemeta.ResponseMetadataHeaderKey]string{
+		ServedStale: staleness.String(),
+	}); err != nil {
+		return false
+	}
+
+	log.Ctx(ctx).Warn().Err(err).Stringer("revision", revision).Dur("staleness", staleness).Msg("serving check at last known good revision")
+	staleChecksCounter.Inc()
+	ctx.Value(revisionKey).(*revisionHandle).revision = revision
+	return true
+}
@@ -0,0 +1,126 @@
+package consistency
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/authzed/spicedb/internal/datastore/proxy/proxy_test"
+	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
+	"github.com/authzed/spicedb/pkg/datastore"
+	"github.com/authzed/spicedb/pkg/zedtoken"
+)
+
+type headerRecorder struct {
+	grpc.ServerTransportStream
+	header metadata.MD
+}
+
+func (hr *headerRecorder) SetHeader(md metadata.MD) error {
+	hr.header = metadata.Join(hr.header, md)
+	return nil
+}
+
+func TestStaleFallback(t *testing.T) {
+	outage := errors.New("connection refused")
+
+	for _, tc := range []struct {
+		name          string
+		req           any
+		err           error
+		staleness     time.Duration
+		expectedStale bool
+	}{
+		{"check", &v1.CheckPermissionRequest{}, outage, 0, true},
+		{"check at least as fresh as known revision", &v1.CheckPermissionRequest{Consistency: &v1.Consistency{Requirement: &v1.Consistency_AtLeastAsFresh{AtLeastAsFresh: zedtoken.MustNewFromRevision(zero)}}}, outage, 0, true},
+		{"check fresher than known revision", &v1.CheckPermissionRequest{Consistency: &v1.Consistency{Requirement: &v1.Consistency_AtLeastAsFresh{AtLeastAsFresh: zedtoken.MustNewFromRevision(head)}}}, outage, 0, false},
+		{"fully consistent check", &v1.CheckPermissionRequest{Consistency: &v1.Consistency{Requirement: &v1.Consistency_FullyConsistent{FullyConsistent: true}}}, outage, 0, false},
+		{"known revision too stale", &v1.CheckPermissionRequest{}, outage, time.Hour, false},
+		{"invalid request", &v1.CheckPermissionRequest{}, status.Error(codes.InvalidArgument, "invalid"), 0, false},
+		{"other request", &v1.ReadRelationshipsRequest{}, outage, 0, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ds := &proxy_test.MockDatastore{}
+			ds.On("OptimizedRevision").Return(optimized, nil).Once()
+			ds.On("OptimizedRevision").Return(datastore.NoRevision, tc.err)
+			ds.On("HeadRevision").Return(datastore.NoRevision, tc.err)
+			ds.On("RevisionFromString", zero.String()).Return(zero, nil)
+			ds.On("RevisionFromString", head.String()).Return(head, nil)
+
+			fallback := NewStaleFallback(time.Minute)
+			interceptor := UnaryServerInterceptorWithStaleFallback(fallback)
+			ctx := datastoremw.ContextWithDatastore(context.Background(), ds)
+
+			// The first request records the revision read.
+			_, err := interceptor(ctx, &v1.CheckPermissionRequest{}, &grpc.UnaryServerInfo{}, func(ctx context.Context, req any) (any, error) {
+				return nil, nil
+			})
+			require.NoError(t, err)
+			fallback.readAt.Add(-tc.staleness.Nanoseconds())
+
+			recorder := &headerRecorder{}
+			var servedAt datastore.Revision
+			_, err = interceptor(grpc.NewContextWithServerTransportStream(ctx, recorder), tc.req, &grpc.UnaryServerInfo{}, func(ctx context.Context, req any) (any, error) {
+				servedAt = RevisionFromContext(ctx)
+				return nil, nil
+			})
+			if !tc.expectedStale {
+				require.Error(t, err)
+				require.Empty(t, recorder.header.Get(string(ServedStale)))
+				return
+			}
+
+			require.NoError(t, err)
+			require.True(t, optimized.Equal(servedAt))
+			require.Len(t, recorder.header.Get(string(ServedStale)), 1)
+		})
+	}
+}
+
+func TestStaleFallbackRecordsDatastoreRevisions(t *testing.T) {
+	ds := &proxy_test.MockDatastore{}
+	ds.On("OptimizedRevision").Return(optimized, nil).Once()
+	ds.On("OptimizedRevision").Return(datastore.NoRevision, errors.New("connection refused"))
+	ds.On("RevisionFromString", head.String()).Return(head, nil)
+
+	fallback := NewStaleFallback(time.Minute)
+	interceptor := UnaryServerInterceptorWithStaleFallback(fallback)
+	ctx := datastoremw.ContextWithDatastore(context.Background(), ds)
+
+	// A request for a revision later than that of the datastore is served at the requested
+	// revision, which is not recorded as known good.
+	atLeastAsFreshAsHead := &v1.CheckPermissionRequest{Consistency: &v1.Consistency{Requirement: &v1.Consistency_AtLeastAsFresh{AtLeastAsFresh: zedtoken.MustNewFromRevision(head)}}}
+	var servedAt datastore.Revision
+	_, err := interceptor(ctx, atLeastAsFreshAsHead, &grpc.UnaryServerInfo{}, func(ctx context.Context, req any) (any, error) {
+		servedAt = RevisionFromContext(ctx)
+		return nil, nil
+	})
+	require.NoError(t, err)
+	require.True(t, head.Equal(servedAt))
+
+	_, err = interceptor(grpc.NewContextWithServerTransportStream(ctx, &headerRecorder{}), &v1.CheckPermissionRequest{}, &grpc.UnaryServerInfo{}, func(ctx context.Context, req any) (any, error) {
+		servedAt = RevisionFromContext(ctx)
+		return nil, nil
+	})
+	require.NoError(t, err)
+	require.True(t, optimized.Equal(servedAt))
+}
+
+func TestWithoutStaleFallback(t *testing.T) {
+	ds := &proxy_test.MockDatastore{}
+	ds.On("OptimizedRevision").Return(datastore.NoRevision, errors.New("connection refused"))
+
+	ctx := datastoremw.ContextWithDatastore(context.Background(), ds)
+	_, err := UnaryServerInterceptor()(ctx, &v1.CheckPermissionRequest{}, &grpc.UnaryServerInfo{}, func(ctx context.Context, req any) (any, error) {
+		return nil, nil
+	})
+	require.Error(t, err)
+}
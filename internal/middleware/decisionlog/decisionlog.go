@@ -0,0 +1,277 @@
+// Package decisionlog implements middleware which records the decisions of permission checks
+// as decision logs: JSON lines in the style of the decision logs of Open Policy Agent, uploaded
+// to object storage in gzipped batches for consumption by decision log pipelines.
+package decisionlog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+
+	log "github.com/authzed/spicedb/internal/logging"
+)
+
+var (
+	droppedEntriesCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "spicedb",
+		Subsystem: "decisionlog",
+		Name:      "dropped_entries_total",
+		Help:      "The number of decision log entries dropped because the buffer was full.",
+	})
+
+	uploadsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "spicedb",
+		Subsystem: "decisionlog",
+		Name:      "uploads_total",
+		Help:      "The number of batches of decision log entries uploaded, by result.",
+	}, []string{"result"})
+)
+
+func init() {
+	prometheus.MustRegister(droppedEntriesCounter, uploadsCounter)
+}
+
+// finalFlushTimeout is the time given to upload the entries still buffered when the logger
+// stops.
+const finalFlushTimeout = 10 * time.Second
+
+// Entry is a single decision log entry.
+type Entry struct {
+	// DecisionID uniquely identifies the decision; it is the ID of the request, if any.
+	DecisionID string `json:"decision_id"`
+
+	// Path is the API method which made the decision.
+	Path string `json:"path"`
+
+	// Input is the input of the decision.
+	Input Input `json:"input"`
+
+	// Result is the result of the decision. It is absent if the decision failed.
+	Result *Result `json:"result,omitempty"`
+
+	// Error is the error with which the decision failed, if any.
+	Error string `json:"error,omitempty"`
+
+	// Revision is the ZedToken of the revision at which the decision was made.
+	Revision string `json:"revision,omitempty"`
+
+	// Timestamp is the time at which the decision was requested.
+	Timestamp time.Time `json:"timestamp"`
+
+	// Metrics are the metrics of the decision, currently its latency as `timer_server_handler_ns`.
+	Metrics map[string]int64 `json:"metrics"`
+
+	// Labels are the labels of the logger which recorded the decision.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// Input is the input of a permission check.
+type Input struct {
+	Resource   string         `json:"resource"`
+	Permission string         `json:"permission"`
+	Subject    string         `json:"subject"`
+	Context    map[string]any `json:"context,omitempty"`
+}
+
+// Result is the result of a permission check.
+type Result struct {
+	// Allowed is true if the subject has the permission unconditionally.
+	Allowed bool `json:"allowed"`
+
+	// Permissionship is the permissionship returned by the check.
+	Permissionship string `json:"permissionship"`
+
+	// MissingContext are the caveat context fields missing to decide a conditional permission.
+	MissingContext []string `json:"missing_context,omitempty"`
+}
+
+// Uploader uploads batches of decision logs to object storage.
+type Uploader interface {
+	// Upload uploads the gzipped JSON lines in body as the object with the given key.
+	Upload(ctx context.Context, key string, body []byte) error
+}
+
+// Config configures the batching of decision logs.
+type Config struct {
+	// BatchSize is the maximum number of entries uploaded in a single object. A batch is
+	// uploaded as soon as it is full.
+	BatchSize int
+
+	// FlushInterval is the interval at which partial batches are uploaded.
+	FlushInterval time.Duration
+
+	// MaxBufferedEntries is the maximum number of entries awaiting upload, beyond which new
+	// entries are dropped.
+	MaxBufferedEntries int
+
+	// Labels are added to every entry. An `id` label identifying the logger is added if absent.
+	Labels map[string]string
+}
+
+// Logger buffers decision log entries and uploads them in batches.
+type Logger struct {
+	config   Config
+	uploader Uploader
+	full     chan struct{}
+
+	mu      sync.Mutex
+	entries []Entry
+}
+
+// NewLogger creates a logger uploading batches of entries with the given uploader. The logger
+// only uploads entries once started.
+func NewLogger(config Config, uploader Uploader) (*Logger, error) {
+	if config.BatchSize <= 0 {
+		return nil, fmt.Errorf("decision log batch size must be positive")
+	}
+
+	if config.FlushInterval <= 0 {
+		return nil, fmt.Errorf("decision log flush interval must be positive")
+	}
+
+	if config.MaxBufferedEntries < config.BatchSize {
+		return nil, fmt.Errorf("decision log buffer of %d entries cannot hold a batch of %d", config.MaxBufferedEntries, config.BatchSize)
+	}
+
+	labels := make(map[string]string, len(config.Labels)+1)
+	for key, value := range config.Labels {
+		labels[key] = value
+	}
+	if _, ok := labels["id"]; !ok {
+		labels["id"] = uuid.NewString()
+	}
+	config.Labels = labels
+
+	return &Logger{
+		config:   config,
+		uploader: uploader,
+		full:     make(chan struct{}, 1),
+	}, nil
+}
+
+// Log buffers the entry for upload, dropping it if the buffer is full.
+func (l *Logger) Log(entry Entry) {
+	entry.Labels = l.config.Labels
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.entries) >= l.config.MaxBufferedEntries {
+		droppedEntriesCounter.Inc()
+		return
+	}
+
+	l.entries = append(l.entries, entry)
+	if len(l.entries) >= l.config.BatchSize {
+		select {
+		case l.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Start uploads batches of entries until the context is cancelled, at which point the entries
+// still buffered are uploaded.
+func (l *Logger) Start(ctx context.Context) error {
+	ticker := time.NewTicker(l.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), finalFlushTimeout)
+			defer cancel()
+			l.flush(flushCtx)
+			return nil
+
+		case <-ticker.C:
+			l.flush(ctx)
+
+		case <-l.full:
+			l.flush(ctx)
+		}
+	}
+}
+
+// flush uploads all buffered entries, in batches. Batches which fail to upload are returned to
+// the buffer, space permitting, to be retried at the next flush.
+func (l *Logger) flush(ctx context.Context) {
+	l.mu.Lock()
+	entries := l.entries
+	l.entries = nil
+	l.mu.Unlock()
+
+	for start := 0; start < len(entries); start += l.config.BatchSize {
+		end := start + l.config.BatchSize
+		if end > len(entries) {
+			end = len(entries)
+		}
+
+		if err := l.upload(ctx, entries[start:end]); err != nil {
+			log.Ctx(ctx).Warn().Err(err).Int("entries", len(entries)-start).Msg("failed to upload decision logs")
+			uploadsCounter.WithLabelValues("error").Inc()
+			l.requeue(entries[start:])
+			return
+		}
+		uploadsCounter.WithLabelValues("success").Inc()
+	}
+}
+
+func (l *Logger) requeue(failed []Entry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	available := l.config.MaxBufferedEntries - len(l.entries)
+	if available <= 0 {
+		droppedEntriesCounter.Add(float64(len(failed)))
+		return
+	}
+
+	if len(failed) > available {
+		droppedEntriesCounter.Add(float64(len(failed) - available))
+		failed = failed[:available]
+	}
+
+	l.entries = append(append(make([]Entry, 0, len(failed)+len(l.entries)), failed...), l.entries...)
+}
+
+func (l *Logger) upload(ctx context.Context, batch []Entry) error {
+	body, err := encodeBatch(batch)
+	if err != nil {
+		return err
+	}
+
+	return l.uploader.Upload(ctx, objectKey(time.Now()), body)
+}
+
+// encodeBatch encodes the entries as gzipped JSON lines.
+func encodeBatch(batch []Entry) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	encoder := json.NewEncoder(gz)
+	for _, entry := range batch {
+		if err := encoder.Encode(entry); err != nil {
+			return nil, fmt.Errorf("unable to encode decision log entry: %w", err)
+		}
+	}
+
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("unable to compress decision logs: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// objectKey returns the key of a batch uploaded at the given time, partitioned by date so that
+// pipelines can consume the logs of a single day.
+func objectKey(now time.Time) string {
+	now = now.UTC()
+	return path.Join(now.Format("2006/01/02"), fmt.Sprintf("%s-%s.jsonl.gz", now.Format("20060102T150405.000000000Z"), uuid.NewString()))
+}
@@ -0,0 +1,157 @@
+package decisionlog
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/authzed/spicedb/pkg/middleware/requestid"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+type memoryUploader struct {
+	sync.Mutex
+	objects map[string][]byte
+	fail    bool
+}
+
+func (mu *memoryUploader) Upload(_ context.Context, key string, body []byte) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if mu.fail {
+		return errors.New("upload failed")
+	}
+	mu.objects[key] = body
+	return nil
+}
+
+func (mu *memoryUploader) entries(t *testing.T) []Entry {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var entries []Entry
+	for _, body := range mu.objects {
+		gz, err := gzip.NewReader(bytes.NewReader(body))
+		require.NoError(t, err)
+
+		scanner := bufio.NewScanner(gz)
+		for scanner.Scan() {
+			var entry Entry
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+			entries = append(entries, entry)
+		}
+		require.NoError(t, scanner.Err())
+	}
+	return entries
+}
+
+func check(t *testing.T, interceptor grpc.UnaryServerInterceptor, ctx context.Context, rel string, permissionship v1.CheckPermissionResponse_Permissionship) {
+	parsed := tuple.ParseRel(rel)
+	req := &v1.CheckPermissionRequest{
+		Resource:   parsed.Resource,
+		Permission: parsed.Relation,
+		Subject:    parsed.Subject,
+	}
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/authzed.api.v1.PermissionsService/CheckPermission"}
+	_, err := interceptor(ctx, req, info, func(ctx context.Context, req any) (any, error) {
+		return &v1.CheckPermissionResponse{
+			CheckedAt:      &v1.ZedToken{Token: "sometoken"},
+			Permissionship: permissionship,
+		}, nil
+	})
+	require.NoError(t, err)
+}
+
+func TestDecisionLogs(t *testing.T) {
+	uploader := &memoryUploader{objects: map[string][]byte{}}
+	logger, err := NewLogger(Config{
+		BatchSize:          2,
+		FlushInterval:      time.Hour,
+		MaxBufferedEntries: 10,
+		Labels:             map[string]string{"version": "v1.0.0"},
+	}, uploader)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error)
+	go func() {
+		stopped <- logger.Start(ctx)
+	}()
+
+	interceptor := UnaryServerInterceptor(logger)
+	requestCtx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(requestid.RequestIDMetadataKey, "somerequest"))
+	check(t, interceptor, requestCtx, "document:readme#view@user:tom", v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION)
+	check(t, interceptor, context.Background(), "document:readme#view@user:sarah", v1.CheckPermissionResponse_PERMISSIONSHIP_NO_PERMISSION)
+
+	// A full batch is uploaded without waiting for the flush interval.
+	require.Eventually(t, func() bool {
+		return len(uploader.entries(t)) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The remaining entries are uploaded when the logger stops.
+	check(t, interceptor, context.Background(), "document:readme#edit@team:eng#member", v1.CheckPermissionResponse_PERMISSIONSHIP_NO_PERMISSION)
+	cancel()
+	require.NoError(t, <-stopped)
+
+	entries := uploader.entries(t)
+	require.Len(t, entries, 3)
+
+	byDecisionID := make(map[string]Entry, len(entries))
+	for _, entry := range entries {
+		require.Equal(t, "authzed.api.v1.PermissionsService/CheckPermission", entry.Path)
+		require.Equal(t, "sometoken", entry.Revision)
+		require.Equal(t, "v1.0.0", entry.Labels["version"])
+		require.NotEmpty(t, entry.Labels["id"])
+		require.Contains(t, entry.Metrics, "timer_server_handler_ns")
+		byDecisionID[entry.DecisionID] = entry
+	}
+
+	entry, ok := byDecisionID["somerequest"]
+	require.True(t, ok)
+	require.Equal(t, Input{Resource: "document:readme", Permission: "view", Subject: "user:tom"}, entry.Input)
+	require.Equal(t, &Result{Allowed: true, Permissionship: "PERMISSIONSHIP_HAS_PERMISSION"}, entry.Result)
+}
+
+func TestFailedUploadsRequeued(t *testing.T) {
+	uploader := &memoryUploader{objects: map[string][]byte{}, fail: true}
+	logger, err := NewLogger(Config{BatchSize: 2, FlushInterval: time.Hour, MaxBufferedEntries: 3}, uploader)
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		logger.Log(Entry{Path: "somepath"})
+	}
+	require.Len(t, logger.entries, 3)
+
+	logger.flush(context.Background())
+	require.Len(t, logger.entries, 3)
+
+	uploader.fail = false
+	logger.flush(context.Background())
+	require.Empty(t, logger.entries)
+	require.Len(t, uploader.entries(t), 3)
+	require.Len(t, uploader.objects, 2)
+}
+
+func TestNewLoggerValidation(t *testing.T) {
+	_, err := NewLogger(Config{BatchSize: 0, FlushInterval: time.Second, MaxBufferedEntries: 10}, nil)
+	require.ErrorContains(t, err, "batch size")
+
+	_, err = NewLogger(Config{BatchSize: 10, FlushInterval: 0, MaxBufferedEntries: 10}, nil)
+	require.ErrorContains(t, err, "flush interval")
+
+	_, err = NewLogger(Config{BatchSize: 10, FlushInterval: time.Second, MaxBufferedEntries: 5}, nil)
+	require.ErrorContains(t, err, "cannot hold a batch")
+}
@@ -0,0 +1,92 @@
+package decisionlog
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/authzed/spicedb/pkg/middleware/requestid"
+	"github.com/authzed/spicedb/pkg/redaction"
+)
+
+// UnaryServerInterceptor returns a new interceptor which records the decisions of
+// CheckPermission requests with the logger. A nil logger records nothing.
+func UnaryServerInterceptor(logger *Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		checkReq, ok := req.(*v1.CheckPermissionRequest)
+		if logger == nil || !ok {
+			return handler(ctx, req)
+		}
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		entry := Entry{
+			DecisionID: decisionID(ctx),
+			Path:       strings.TrimPrefix(info.FullMethod, "/"),
+			Input:      checkInput(checkReq),
+			Timestamp:  start.UTC(),
+			Metrics:    map[string]int64{"timer_server_handler_ns": time.Since(start).Nanoseconds()},
+		}
+
+		if err != nil {
+			entry.Error = err.Error()
+		} else if checkResp, ok := resp.(*v1.CheckPermissionResponse); ok {
+			entry.Result = checkResult(checkResp)
+			entry.Revision = checkResp.GetCheckedAt().GetToken()
+		}
+
+		logger.Log(entry)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns a new interceptor for streaming requests, which do not make
+// decisions recorded in decision logs.
+func StreamServerInterceptor(_ *Logger) grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, stream)
+	}
+}
+
+// decisionID returns the ID of the request, as set by the requestid middleware, or a new ID.
+func decisionID(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if requestIDs := md.Get(requestid.RequestIDMetadataKey); len(requestIDs) > 0 && requestIDs[0] != "" {
+			return requestIDs[0]
+		}
+	}
+	return uuid.NewString()
+}
+
+// checkInput returns the input of the check, redacted according to the redaction policy. The
+// request has not necessarily been validated.
+func checkInput(req *v1.CheckPermissionRequest) Input {
+	subject := req.GetSubject().GetObject().GetObjectType() + ":" + redaction.ObjectID(req.GetSubject().GetObject().GetObjectId())
+	if relation := req.GetSubject().GetOptionalRelation(); relation != "" {
+		subject += "#" + relation
+	}
+
+	input := Input{
+		Resource:   req.GetResource().GetObjectType() + ":" + redaction.ObjectID(req.GetResource().GetObjectId()),
+		Permission: req.GetPermission(),
+		Subject:    subject,
+	}
+	if req.GetContext() != nil {
+		input.Context = redaction.CaveatContext(req.GetContext()).AsMap()
+	}
+	return input
+}
+
+func checkResult(resp *v1.CheckPermissionResponse) *Result {
+	return &Result{
+		Allowed:        resp.Permissionship == v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION,
+		Permissionship: resp.Permissionship.String(),
+		MissingContext: resp.GetPartialCaveatInfo().GetMissingRequiredContext(),
+	}
+}
@@ -0,0 +1,49 @@
+package decisionlog
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+type s3Uploader struct {
+	bucket   string
+	prefix   string
+	s3Client *s3.S3
+}
+
+// NewS3Uploader creates an uploader writing the batches of decision logs to the given bucket,
+// under the given key prefix, with the given config for connecting to S3 or an S3-compatible
+// API.
+func NewS3Uploader(bucket string, prefix string, config *aws.Config) (Uploader, error) {
+	if bucket == "" {
+		return nil, fmt.Errorf("a bucket must be specified to upload decision logs")
+	}
+
+	sess, err := session.NewSession(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3Uploader{
+		bucket:   bucket,
+		prefix:   prefix,
+		s3Client: s3.New(sess),
+	}, nil
+}
+
+func (s3u *s3Uploader) Upload(ctx context.Context, key string, body []byte) error {
+	_, err := s3u.s3Client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket:          aws.String(s3u.bucket),
+		Key:             aws.String(path.Join(s3u.prefix, key)),
+		Body:            bytes.NewReader(body),
+		ContentType:     aws.String("application/x-ndjson"),
+		ContentEncoding: aws.String("gzip"),
+	})
+	return err
+}
@@ -0,0 +1,64 @@
+// Package loadshed implements middleware which rejects new requests while the server is
+// overloaded.
+package loadshed
+
+import (
+	"context"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var shedRequestsCounter = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "spicedb",
+	Subsystem: "api",
+	Name:      "shed_requests_total",
+	Help:      "The number of requests rejected while the server was overloaded.",
+})
+
+func init() {
+	prometheus.MustRegister(shedRequestsCounter)
+}
+
+// Shedder decides whether new requests should be rejected.
+type Shedder interface {
+	ShouldShed() bool
+}
+
+// healthServicePrefix is the prefix of the methods of the health service, which are never shed
+// so that orchestrators can distinguish an overloaded server from one that is down.
+const healthServicePrefix = "/grpc.health.v1.Health/"
+
+func shed(shedder Shedder, fullMethod string) error {
+	if shedder == nil || strings.HasPrefix(fullMethod, healthServicePrefix) || !shedder.ShouldShed() {
+		return nil
+	}
+
+	shedRequestsCounter.Inc()
+	return status.Error(codes.ResourceExhausted, "server is under memory pressure; retry the request later")
+}
+
+// UnaryServerInterceptor returns a new interceptor which rejects requests while the shedder
+// indicates the server is overloaded. A nil shedder never rejects requests.
+func UnaryServerInterceptor(shedder Shedder) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := shed(shedder, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns a new interceptor which rejects requests while the shedder
+// indicates the server is overloaded. A nil shedder never rejects requests.
+func StreamServerInterceptor(shedder Shedder) grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := shed(shedder, info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, stream)
+	}
+}
@@ -0,0 +1,139 @@
+// Package priority implements middleware which determines the priority class of API
+// requests, used to schedule their dispatches.
+package priority
+
+import (
+	"context"
+	"fmt"
+
+	middleware "github.com/grpc-ecosystem/go-grpc-middleware/v2"
+	grpcauth "github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RequestPriorityHeader is the request header in which callers can specify the priority class
+// of the request.
+const RequestPriorityHeader = "io.spicedb.priority"
+
+// Priority is the priority class of a request.
+type Priority string
+
+const (
+	// Interactive is the priority class of latency-sensitive requests, such as checks made
+	// while serving end users.
+	Interactive Priority = "interactive"
+
+	// Bulk is the priority class of throughput-oriented requests, such as lookups and exports.
+	Bulk Priority = "bulk"
+)
+
+// Priorities are all the priority classes, from highest to lowest.
+var Priorities = []Priority{Interactive, Bulk}
+
+// Parse returns the priority class with the given name.
+func Parse(name string) (Priority, error) {
+	for _, priority := range Priorities {
+		if string(priority) == name {
+			return priority, nil
+		}
+	}
+	return "", fmt.Errorf("unknown priority class `%s`", name)
+}
+
+// rank returns the rank of the priority class, with the highest priority ranked zero.
+func (p Priority) rank() int {
+	for index, priority := range Priorities {
+		if priority == p {
+			return index
+		}
+	}
+	return len(Priorities)
+}
+
+type ctxKeyType struct{}
+
+var priorityKey ctxKeyType = struct{}{}
+
+// ContextWithPriority returns a new context with the given priority class.
+func ContextWithPriority(ctx context.Context, priority Priority) context.Context {
+	return context.WithValue(ctx, priorityKey, priority)
+}
+
+// FromContext returns the priority class of the current request, or Interactive if none.
+func FromContext(ctx context.Context) Priority {
+	if priority, ok := ctx.Value(priorityKey).(Priority); ok {
+		return priority
+	}
+	return Interactive
+}
+
+type handlePriority struct {
+	tokenPriorities map[string]Priority
+}
+
+// fromRequest determines the priority of the request. By default, unary requests are
+// interactive and streaming requests are bulk. Tokens can be assigned a priority, in which
+// case requests made with the token cannot exceed it. Callers can lower the priority of their
+// requests further via RequestPriorityHeader.
+func (h *handlePriority) fromRequest(ctx context.Context, defaultPriority Priority) (context.Context, error) {
+	maximum := Interactive
+	if token, err := grpcauth.AuthFromMD(ctx, "bearer"); err == nil {
+		if tokenPriority, ok := h.tokenPriorities[token]; ok {
+			maximum = tokenPriority
+			if defaultPriority.rank() < maximum.rank() {
+				defaultPriority = maximum
+			}
+		}
+	}
+
+	priority := defaultPriority
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(RequestPriorityHeader); len(values) > 0 {
+			parsed, err := Parse(values[0])
+			if err != nil {
+				return ctx, status.Errorf(codes.InvalidArgument, "invalid value for %s: %s", RequestPriorityHeader, err)
+			}
+
+			if parsed.rank() < maximum.rank() {
+				return ctx, status.Errorf(codes.PermissionDenied, "priority `%s` exceeds the maximum of `%s` for the token", parsed, maximum)
+			}
+			priority = parsed
+		}
+	}
+
+	return ContextWithPriority(ctx, priority), nil
+}
+
+// UnaryServerInterceptor returns a new interceptor which determines the priority class of
+// the request. The token priorities map preshared keys to the maximum priority of requests
+// made with them.
+func UnaryServerInterceptor(tokenPriorities map[string]Priority) grpc.UnaryServerInterceptor {
+	h := &handlePriority{tokenPriorities}
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := h.fromRequest(ctx, Interactive)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns a new interceptor which determines the priority class of
+// the request. The token priorities map preshared keys to the maximum priority of requests
+// made with them.
+func StreamServerInterceptor(tokenPriorities map[string]Priority) grpc.StreamServerInterceptor {
+	h := &handlePriority{tokenPriorities}
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := h.fromRequest(stream.Context(), Bulk)
+		if err != nil {
+			return err
+		}
+
+		wrapped := middleware.WrapServerStream(stream)
+		wrapped.WrappedContext = ctx
+		return handler(srv, wrapped)
+	}
+}
@@ -0,0 +1,53 @@
+package priority
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	tokenPriorities := map[string]Priority{"bulktoken": Bulk}
+
+	for _, tc := range []struct {
+		name             string
+		token            string
+		header           []string
+		expectedPriority Priority
+		expectedCode     codes.Code
+	}{
+		{"no token priority, no header", "sometoken", nil, Interactive, codes.OK},
+		{"header lowers priority", "sometoken", []string{"bulk"}, Bulk, codes.OK},
+		{"header with same priority", "sometoken", []string{"interactive"}, Interactive, codes.OK},
+		{"token priority", "bulktoken", nil, Bulk, codes.OK},
+		{"header within token priority", "bulktoken", []string{"bulk"}, Bulk, codes.OK},
+		{"header exceeds token priority", "bulktoken", []string{"interactive"}, "", codes.PermissionDenied},
+		{"invalid header", "sometoken", []string{"urgent"}, "", codes.InvalidArgument},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			md := metadata.Pairs("authorization", "bearer "+tc.token)
+			if tc.header != nil {
+				md.Set(RequestPriorityHeader, tc.header[0])
+			}
+			ctx := metadata.NewIncomingContext(context.Background(), md)
+
+			var foundPriority Priority
+			_, err := UnaryServerInterceptor(tokenPriorities)(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req any) (any, error) {
+				foundPriority = FromContext(ctx)
+				return nil, nil
+			})
+			require.Equal(t, tc.expectedCode, status.Code(err))
+			require.Equal(t, tc.expectedPriority, foundPriority)
+		})
+	}
+}
+
+func TestFromContextDefault(t *testing.T) {
+	require.Equal(t, Interactive, FromContext(context.Background()))
+	require.Equal(t, Bulk, FromContext(ContextWithPriority(context.Background(), Bulk)))
+}
@@ -0,0 +1,148 @@
+package quota
+
+import (
+	"context"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	middleware "github.com/grpc-ecosystem/go-grpc-middleware/v2"
+	grpcauth "github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	experimentalv1 "github.com/authzed/spicedb/pkg/proto/experimental/v1"
+	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
+	"github.com/authzed/spicedb/pkg/schemadsl/input"
+)
+
+type ctxKeyType struct{}
+
+var trackerKey ctxKeyType = struct{}{}
+
+// ContextWithTracker adds the tracker to the context.
+func ContextWithTracker(ctx context.Context, tracker *Tracker) context.Context {
+	return context.WithValue(ctx, trackerKey, tracker)
+}
+
+// FromContext reads the tracker out of a context.Context and returns nil if it does not exist.
+func FromContext(ctx context.Context) *Tracker {
+	if tracker, ok := ctx.Value(trackerKey).(*Tracker); ok {
+		return tracker
+	}
+	return nil
+}
+
+// UnaryServerInterceptor returns a new interceptor which tracks the relationships and namespaces
+// written by each token with the tracker, denies writes which would exceed their hard limits, and
+// adds the tracker to the context. A nil tracker tracks nothing.
+func UnaryServerInterceptor(tracker *Tracker) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if tracker == nil {
+			return handler(ctx, req)
+		}
+
+		ctx = ContextWithTracker(ctx, tracker)
+		token, err := grpcauth.AuthFromMD(ctx, "bearer")
+		if err != nil {
+			return handler(ctx, req)
+		}
+
+		undo, err := reserve(tracker, TokenID(token), req)
+		if err != nil {
+			return nil, status.Errorf(codes.ResourceExhausted, "%s", err)
+		}
+
+		resp, err := handler(ctx, req)
+		if err != nil && undo != nil {
+			undo()
+		}
+		if err == nil {
+			releaseFailed(tracker, TokenID(token), req, resp)
+		}
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns a new interceptor which adds the tracker to the context.
+// Streaming requests do not write relationships or namespaces, and so are not tracked.
+func StreamServerInterceptor(tracker *Tracker) grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if tracker == nil {
+			return handler(srv, stream)
+		}
+
+		wrapped := middleware.WrapServerStream(stream)
+		wrapped.WrappedContext = ContextWithTracker(wrapped.WrappedContext, tracker)
+		return handler(srv, wrapped)
+	}
+}
+
+// reserve reserves the usage of the write made by the request, which has not necessarily been
+// validated, returning the function undoing the reservation, or nil if the request writes
+// nothing tracked.
+func reserve(tracker *Tracker, tokenID string, req any) (func(), error) {
+	switch req := req.(type) {
+	case *v1.WriteRelationshipsRequest:
+		return tracker.Reserve(tokenID, Relationships, updatesDelta(req.GetUpdates()))
+
+	case *experimentalv1.BatchWriteRelationshipsRequest:
+		return tracker.Reserve(tokenID, Relationships, updatesDelta(req.GetUpdates()))
+
+	case *v1.WriteSchemaRequest:
+		emptyDefaultPrefix := ""
+		compiled, err := compiler.Compile(compiler.InputSchema{
+			Source:       input.Source("schema"),
+			SchemaString: req.GetSchema(),
+		}, &emptyDefaultPrefix)
+		if err != nil {
+			// The schema service reports the error.
+			return nil, nil
+		}
+		return tracker.Set(tokenID, Namespaces, uint64(len(compiled.ObjectDefinitions)))
+
+	default:
+		return nil, nil
+	}
+}
+
+// releaseFailed releases the usage reserved for the updates of a batch write which failed, as
+// the batch succeeds even when some of its updates fail. Releasing that of failed deletions
+// raises the usage, which is left as it is should that exceed the hard limit.
+func releaseFailed(tracker *Tracker, tokenID string, req any, resp any) {
+	batch, ok := req.(*experimentalv1.BatchWriteRelationshipsRequest)
+	if !ok {
+		return
+	}
+
+	written, ok := resp.(*experimentalv1.BatchWriteRelationshipsResponse)
+	if !ok {
+		return
+	}
+
+	results := written.GetResults()
+	var failed []*v1.RelationshipUpdate
+	for index, update := range batch.GetUpdates() {
+		if index < len(results) && results[index].GetError() != nil {
+			failed = append(failed, update)
+		}
+	}
+
+	if delta := updatesDelta(failed); delta != 0 {
+		_, _ = tracker.Reserve(tokenID, Relationships, -delta)
+	}
+}
+
+// updatesDelta returns the change in the number of relationships made by the updates, counting
+// each creation or touch as one more and each deletion as one fewer.
+func updatesDelta(updates []*v1.RelationshipUpdate) int64 {
+	var delta int64
+	for _, update := range updates {
+		switch update.GetOperation() {
+		case v1.RelationshipUpdate_OPERATION_CREATE, v1.RelationshipUpdate_OPERATION_TOUCH:
+			delta++
+		case v1.RelationshipUpdate_OPERATION_DELETE:
+			delta--
+		}
+	}
+	return delta
+}
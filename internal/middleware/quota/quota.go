@@ -0,0 +1,214 @@
+// Package quota implements middleware which tracks the relationships and namespaces written by
+// each token, and enforces limits on them at write time, for clusters shared between tenants.
+package quota
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Resource is a resource whose usage is limited per token.
+type Resource string
+
+const (
+	// Relationships is the number of relationships created or touched by a token, less those
+	// it has deleted by update. As touches of existing relationships are counted, and deletions
+	// by filter are not, it is an upper bound on the relationships held for the token.
+	Relationships Resource = "relationships"
+
+	// Namespaces is the number of object definitions in the schema last written by a token.
+	Namespaces Resource = "namespaces"
+)
+
+// Limit is the limits on the usage of a resource by each token. A limit of zero is unlimited.
+type Limit struct {
+	// Soft is the usage above which writes are reported, but still allowed.
+	Soft uint64
+
+	// Hard is the usage above which writes are denied.
+	Hard uint64
+}
+
+// Limits are the limits on the usage of each resource by each token.
+type Limits map[Resource]Limit
+
+// Usage is the usage of a resource by a token.
+type Usage struct {
+	// TokenID identifies the token, without revealing it.
+	TokenID  string
+	Resource Resource
+	Used     uint64
+	Limit    Limit
+}
+
+var (
+	usageGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "spicedb",
+		Subsystem: "quota",
+		Name:      "usage",
+		Help:      "The usage of each resource by each token, as observed by this server since it started.",
+	}, []string{"token_id", "resource"})
+
+	exceededCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "spicedb",
+		Subsystem: "quota",
+		Name:      "exceeded_total",
+		Help:      "The number of writes which exceeded the soft or hard limit on the usage of a resource by a token.",
+	}, []string{"token_id", "resource", "limit"})
+)
+
+// RegisterMetrics registers the quota metrics to the default registry.
+func RegisterMetrics() error {
+	for _, collector := range []prometheus.Collector{usageGauge, exceededCounter} {
+		if err := prometheus.Register(collector); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TokenID returns the identifier of a token reported in metrics and by the admin API, which is
+// derived from the token without revealing it.
+func TokenID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:8])
+}
+
+type usageKey struct {
+	tokenID  string
+	resource Resource
+}
+
+// Tracker tracks the usage of each resource by each token, as observed by this server since it
+// started, and enforces the limits on it.
+type Tracker struct {
+	limits Limits
+
+	lock  sync.Mutex
+	usage map[usageKey]uint64
+}
+
+// NewTracker creates a new Tracker enforcing the given limits, with no recorded usage.
+func NewTracker(limits Limits) *Tracker {
+	return &Tracker{
+		limits: limits,
+		usage:  make(map[usageKey]uint64),
+	}
+}
+
+// exceededError is returned when a write would exceed the hard limit on the usage of a
+// resource.
+type exceededError struct {
+	resource Resource
+	used     uint64
+	limit    uint64
+}
+
+func (err exceededError) Error() string {
+	return fmt.Sprintf("the write would raise the %s of the token to %d, above its limit of %d", err.resource, err.used, err.limit)
+}
+
+// Reserve adds the delta to the usage of the resource by the token, unless an increase would
+// take it above the hard limit, in which case it returns an exceededError. The returned function
+// undoes the reservation, should the write fail.
+func (t *Tracker) Reserve(tokenID string, resource Resource, delta int64) (func(), error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	key := usageKey{tokenID, resource}
+	previous := t.usage[key]
+	updated, err := t.apply(key, previous, addDelta(previous, delta))
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		t.lock.Lock()
+		defer t.lock.Unlock()
+
+		// Undo the change, rather than restore the previous usage, to preserve any changes
+		// reserved concurrently.
+		current := t.usage[key]
+		t.usage[key] = addDelta(current, int64(previous)-int64(updated))
+		usageGauge.WithLabelValues(tokenID, string(resource)).Set(float64(t.usage[key]))
+	}, nil
+}
+
+// Set sets the usage of the resource by the token, unless an increase would take it above the
+// hard limit, in which case it returns an exceededError. The returned function restores the
+// previous usage, should the write fail.
+func (t *Tracker) Set(tokenID string, resource Resource, used uint64) (func(), error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	key := usageKey{tokenID, resource}
+	previous := t.usage[key]
+	if _, err := t.apply(key, previous, used); err != nil {
+		return nil, err
+	}
+
+	return func() {
+		t.lock.Lock()
+		defer t.lock.Unlock()
+
+		if t.usage[key] == used {
+			t.usage[key] = previous
+			usageGauge.WithLabelValues(tokenID, string(resource)).Set(float64(previous))
+		}
+	}, nil
+}
+
+// apply changes the usage, enforcing the limits on increases. It must be called with the lock
+// held.
+func (t *Tracker) apply(key usageKey, previous, updated uint64) (uint64, error) {
+	limit := t.limits[key.resource]
+	if updated > previous {
+		if limit.Hard > 0 && updated > limit.Hard {
+			exceededCounter.WithLabelValues(key.tokenID, string(key.resource), "hard").Inc()
+			return 0, exceededError{key.resource, updated, limit.Hard}
+		}
+		if limit.Soft > 0 && updated > limit.Soft {
+			exceededCounter.WithLabelValues(key.tokenID, string(key.resource), "soft").Inc()
+		}
+	}
+
+	t.usage[key] = updated
+	usageGauge.WithLabelValues(key.tokenID, string(key.resource)).Set(float64(updated))
+	return updated, nil
+}
+
+// Usage returns the usage of each resource by each token which has written any, ordered by
+// token and resource.
+func (t *Tracker) Usage() []Usage {
+	t.lock.Lock()
+	usages := make([]Usage, 0, len(t.usage))
+	for key, used := range t.usage {
+		usages = append(usages, Usage{
+			TokenID:  key.tokenID,
+			Resource: key.resource,
+			Used:     used,
+			Limit:    t.limits[key.resource],
+		})
+	}
+	t.lock.Unlock()
+
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].TokenID != usages[j].TokenID {
+			return usages[i].TokenID < usages[j].TokenID
+		}
+		return usages[i].Resource < usages[j].Resource
+	})
+	return usages
+}
+
+func addDelta(used uint64, delta int64) uint64 {
+	if delta < 0 && uint64(-delta) > used {
+		return 0
+	}
+	return uint64(int64(used) + delta)
+}
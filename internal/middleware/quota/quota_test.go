@@ -0,0 +1,148 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/require"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	experimentalv1 "github.com/authzed/spicedb/pkg/proto/experimental/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+func withToken(token string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "bearer "+token))
+}
+
+func writeRequest(creates int, deletes int) *v1.WriteRelationshipsRequest {
+	req := &v1.WriteRelationshipsRequest{}
+	for i := 0; i < creates; i++ {
+		req.Updates = append(req.Updates, tuple.UpdateToRelationshipUpdate(tuple.Create(tuple.MustParse("document:first#viewer@user:tom"))))
+	}
+	for i := 0; i < deletes; i++ {
+		req.Updates = append(req.Updates, tuple.UpdateToRelationshipUpdate(tuple.Delete(tuple.MustParse("document:first#viewer@user:tom"))))
+	}
+	return req
+}
+
+func TestInterceptorEnforcesRelationshipLimits(t *testing.T) {
+	tracker := NewTracker(Limits{Relationships: {Soft: 2, Hard: 3}})
+	unary := UnaryServerInterceptor(tracker)
+
+	var handled *Tracker
+	handler := func(ctx context.Context, req any) (any, error) {
+		handled = FromContext(ctx)
+		return nil, nil
+	}
+
+	_, err := unary(withToken("first"), writeRequest(3, 0), &grpc.UnaryServerInfo{}, handler)
+	require.NoError(t, err)
+	require.Same(t, tracker, handled)
+
+	// The hard limit applies to each token separately.
+	_, err = unary(withToken("first"), writeRequest(1, 0), &grpc.UnaryServerInfo{}, handler)
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	_, err = unary(withToken("second"), writeRequest(1, 0), &grpc.UnaryServerInfo{}, handler)
+	require.NoError(t, err)
+
+	// Deletions free up the quota.
+	_, err = unary(withToken("first"), writeRequest(1, 2), &grpc.UnaryServerInfo{}, handler)
+	require.NoError(t, err)
+
+	// Failed writes are not counted.
+	_, err = unary(withToken("first"), writeRequest(1, 0), &grpc.UnaryServerInfo{}, func(ctx context.Context, req any) (any, error) {
+		return nil, errors.New("failed")
+	})
+	require.Error(t, err)
+
+	usages := make(map[string]Usage)
+	for _, usage := range tracker.Usage() {
+		usages[usage.TokenID] = usage
+	}
+	require.Equal(t, map[string]Usage{
+		TokenID("first"):  {TokenID: TokenID("first"), Resource: Relationships, Used: 2, Limit: Limit{Soft: 2, Hard: 3}},
+		TokenID("second"): {TokenID: TokenID("second"), Resource: Relationships, Used: 1, Limit: Limit{Soft: 2, Hard: 3}},
+	}, usages)
+}
+
+func TestInterceptorReleasesFailedBatchUpdates(t *testing.T) {
+	tracker := NewTracker(Limits{Relationships: {Hard: 3}})
+	unary := UnaryServerInterceptor(tracker)
+
+	batch := &experimentalv1.BatchWriteRelationshipsRequest{Updates: writeRequest(3, 0).Updates}
+	_, err := unary(withToken("first"), batch, &grpc.UnaryServerInfo{}, func(ctx context.Context, req any) (any, error) {
+		return &experimentalv1.BatchWriteRelationshipsResponse{
+			Results: []*experimentalv1.BatchWriteRelationshipsResult{
+				{Result: &experimentalv1.BatchWriteRelationshipsResult_WrittenAt{WrittenAt: &v1.ZedToken{Token: "written"}}},
+				{Result: &experimentalv1.BatchWriteRelationshipsResult_Error{Error: &rpcstatus.Status{Code: int32(codes.InvalidArgument)}}},
+				{Result: &experimentalv1.BatchWriteRelationshipsResult_Error{Error: &rpcstatus.Status{Code: int32(codes.InvalidArgument)}}},
+			},
+		}, nil
+	})
+	require.NoError(t, err)
+
+	// Only the update which was applied is counted.
+	require.Equal(t, []Usage{
+		{TokenID: TokenID("first"), Resource: Relationships, Used: 1, Limit: Limit{Hard: 3}},
+	}, tracker.Usage())
+
+	// The batch is denied as a whole should its updates exceed the hard limit.
+	batch = &experimentalv1.BatchWriteRelationshipsRequest{Updates: writeRequest(3, 0).Updates}
+	_, err = unary(withToken("first"), batch, &grpc.UnaryServerInfo{}, func(ctx context.Context, req any) (any, error) {
+		return nil, nil
+	})
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
+
+func TestInterceptorEnforcesNamespaceLimits(t *testing.T) {
+	tracker := NewTracker(Limits{Namespaces: {Hard: 2}})
+	unary := UnaryServerInterceptor(tracker)
+	handler := func(ctx context.Context, req any) (any, error) { return nil, nil }
+
+	_, err := unary(withToken("first"), &v1.WriteSchemaRequest{
+		Schema: "definition user {}\ndefinition document {\n relation viewer: user\n}",
+	}, &grpc.UnaryServerInfo{}, handler)
+	require.NoError(t, err)
+
+	_, err = unary(withToken("first"), &v1.WriteSchemaRequest{
+		Schema: "definition user {}\ndefinition group {}\ndefinition document {}",
+	}, &grpc.UnaryServerInfo{}, handler)
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// Namespace usage is that of the last schema written, rather than accumulated.
+	_, err = unary(withToken("first"), &v1.WriteSchemaRequest{
+		Schema: "definition user {}",
+	}, &grpc.UnaryServerInfo{}, handler)
+	require.NoError(t, err)
+
+	// Invalid schemas are left to the schema service to reject.
+	_, err = unary(withToken("first"), &v1.WriteSchemaRequest{Schema: "definition {"}, &grpc.UnaryServerInfo{}, handler)
+	require.NoError(t, err)
+
+	require.Equal(t, []Usage{
+		{TokenID: TokenID("first"), Resource: Namespaces, Used: 1, Limit: Limit{Hard: 2}},
+	}, tracker.Usage())
+}
+
+func TestNilTracker(t *testing.T) {
+	unary := UnaryServerInterceptor(nil)
+	_, err := unary(withToken("first"), writeRequest(1, 0), &grpc.UnaryServerInfo{}, func(ctx context.Context, req any) (any, error) {
+		require.Nil(t, FromContext(ctx))
+		return nil, nil
+	})
+	require.NoError(t, err)
+}
+
+func TestTokenID(t *testing.T) {
+	require.Len(t, TokenID("first"), 16)
+	require.NotEqual(t, TokenID("first"), TokenID("second"))
+	require.NotContains(t, TokenID("first"), "first")
+}
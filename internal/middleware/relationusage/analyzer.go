@@ -0,0 +1,204 @@
+package relationusage
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	log "github.com/authzed/spicedb/internal/logging"
+	"github.com/authzed/spicedb/pkg/datastore"
+	nspkg "github.com/authzed/spicedb/pkg/namespace"
+	iv1 "github.com/authzed/spicedb/pkg/proto/impl/v1"
+)
+
+// RelationUsage is the usage of a single relation or permission in the schema.
+type RelationUsage struct {
+	Namespace    string
+	Relation     string
+	IsPermission bool
+
+	// Checks and Lookups are the number of checks and lookups of the relation or permission
+	// made to this server since it started.
+	Checks  uint64
+	Lookups uint64
+
+	// Relationships is the number of relationships held for the relation, which is always
+	// zero for permissions.
+	Relationships uint64
+
+	// LastWrite is the time of the last write to the relation made to this server since it
+	// started, or the zero time if there has been none.
+	LastWrite time.Time
+}
+
+// Report is the usage of every relation and permission in the schema, as of an analysis.
+type Report struct {
+	AnalyzedAt time.Time
+	Revision   datastore.Revision
+
+	// Relations are ordered as they are in the schema.
+	Relations []RelationUsage
+}
+
+var (
+	relationRequestsGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "spicedb",
+		Subsystem: "schema",
+		Name:      "relation_requests",
+		Help:      "The number of requests made to this server for each relation and permission since it started, as of the last analysis.",
+	}, []string{"namespace", "relation", "kind"})
+
+	relationRelationshipsGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "spicedb",
+		Subsystem: "schema",
+		Name:      "relation_relationships",
+		Help:      "The number of relationships held for each relation, as of the last analysis.",
+	}, []string{"namespace", "relation"})
+
+	relationLastWriteGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "spicedb",
+		Subsystem: "schema",
+		Name:      "relation_last_write_timestamp_seconds",
+		Help:      "The time of the last write to each relation made to this server, as of the last analysis.",
+	}, []string{"namespace", "relation"})
+)
+
+// RegisterMetrics registers relation usage metrics to the default registry.
+func RegisterMetrics() error {
+	for _, collector := range []prometheus.Collector{
+		relationRequestsGauge,
+		relationRelationshipsGauge,
+		relationLastWriteGauge,
+	} {
+		if err := prometheus.Register(collector); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Analyze reports the usage of every relation and permission in the schema, counting the
+// relationships held for each relation at the revision of the reader.
+func (t *Tracker) Analyze(ctx context.Context, reader datastore.Reader) ([]RelationUsage, error) {
+	nsDefs, err := reader.ListAllNamespaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var usages []RelationUsage
+	for _, nsDef := range nsDefs {
+		relationshipCounts, err := countRelationships(ctx, reader, nsDef.Definition.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, relation := range nsDef.Definition.Relation {
+			counts := t.requestCounts(nsDef.Definition.Name, relation.Name)
+			usages = append(usages, RelationUsage{
+				Namespace:     nsDef.Definition.Name,
+				Relation:      relation.Name,
+				IsPermission:  nspkg.GetRelationKind(relation) == iv1.RelationMetadata_PERMISSION,
+				Checks:        counts.checks,
+				Lookups:       counts.lookups,
+				Relationships: relationshipCounts[relation.Name],
+				LastWrite:     counts.lastWrite,
+			})
+		}
+	}
+	return usages, nil
+}
+
+func countRelationships(ctx context.Context, reader datastore.Reader, namespaceName string) (map[string]uint64, error) {
+	it, err := reader.QueryRelationships(ctx, datastore.RelationshipsFilter{ResourceType: namespaceName})
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	counts := make(map[string]uint64)
+	for {
+		tpl, err := it.Next()
+		if err != nil {
+			return nil, err
+		}
+		if tpl == nil {
+			return counts, nil
+		}
+
+		counts[tpl.ResourceAndRelation.Relation]++
+	}
+}
+
+// Start analyzes the usage of the schema at the head revision of the datastore every interval,
+// keeping the report and publishing it as metrics, until the context is canceled.
+func (t *Tracker) Start(ctx context.Context, ds datastore.Datastore, interval time.Duration) error {
+	log.Ctx(ctx).Info().
+		Dur("interval", interval).
+		Msg("relation usage analyzer started")
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Ctx(ctx).Info().
+				Msg("shutting down relation usage analyzer")
+			return nil
+
+		case <-time.After(interval):
+			start := time.Now()
+			report, err := t.analyzeHead(ctx, ds)
+			if err != nil {
+				log.Ctx(ctx).Warn().Err(err).Msg("error analyzing relation usage")
+				continue
+			}
+
+			t.setReport(report)
+			publishMetrics(report)
+
+			log.Ctx(ctx).Debug().
+				Dur("duration", time.Since(start)).
+				Int("relations", len(report.Relations)).
+				Msg("analyzed relation usage")
+		}
+	}
+}
+
+func (t *Tracker) analyzeHead(ctx context.Context, ds datastore.Datastore) (*Report, error) {
+	headRevision, err := ds.HeadRevision(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	analyzedAt := time.Now()
+	usages, err := t.Analyze(ctx, ds.SnapshotReader(headRevision))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Report{
+		AnalyzedAt: analyzedAt,
+		Revision:   headRevision,
+		Relations:  usages,
+	}, nil
+}
+
+func publishMetrics(report *Report) {
+	// Reset the metrics so that relations removed from the schema are no longer reported.
+	relationRequestsGauge.Reset()
+	relationRelationshipsGauge.Reset()
+	relationLastWriteGauge.Reset()
+
+	for _, usage := range report.Relations {
+		relationRequestsGauge.WithLabelValues(usage.Namespace, usage.Relation, "check").Set(float64(usage.Checks))
+		relationRequestsGauge.WithLabelValues(usage.Namespace, usage.Relation, "lookup").Set(float64(usage.Lookups))
+
+		if usage.IsPermission {
+			continue
+		}
+
+		relationRelationshipsGauge.WithLabelValues(usage.Namespace, usage.Relation).Set(float64(usage.Relationships))
+		if !usage.LastWrite.IsZero() {
+			relationLastWriteGauge.WithLabelValues(usage.Namespace, usage.Relation).Set(float64(usage.LastWrite.Unix()))
+		}
+	}
+}
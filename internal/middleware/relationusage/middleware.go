@@ -0,0 +1,121 @@
+package relationusage
+
+import (
+	"context"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	middleware "github.com/grpc-ecosystem/go-grpc-middleware/v2"
+	"google.golang.org/grpc"
+
+	experimentalv1 "github.com/authzed/spicedb/pkg/proto/experimental/v1"
+)
+
+type ctxKeyType struct{}
+
+var trackerKey ctxKeyType = struct{}{}
+
+// ContextWithTracker adds the tracker to the context.
+func ContextWithTracker(ctx context.Context, tracker *Tracker) context.Context {
+	return context.WithValue(ctx, trackerKey, tracker)
+}
+
+// FromContext reads the tracker out of a context.Context and returns nil if it does not exist.
+func FromContext(ctx context.Context) *Tracker {
+	if tracker, ok := ctx.Value(trackerKey).(*Tracker); ok {
+		return tracker
+	}
+	return nil
+}
+
+// UnaryServerInterceptor returns a new interceptor which records the relations checked, looked
+// up and written by requests with the tracker, and adds it to the context. A nil tracker
+// records nothing.
+func UnaryServerInterceptor(tracker *Tracker) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if tracker == nil {
+			return handler(ctx, req)
+		}
+
+		recordRequest(tracker, req)
+		resp, err := handler(ContextWithTracker(ctx, tracker), req)
+		if err == nil {
+			recordWrites(tracker, req, resp, time.Now())
+		}
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns a new interceptor which records the relations looked up by
+// streaming requests with the tracker, and adds it to the context. A nil tracker records
+// nothing.
+func StreamServerInterceptor(tracker *Tracker) grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if tracker == nil {
+			return handler(srv, stream)
+		}
+
+		wrapped := middleware.WrapServerStream(stream)
+		wrapped.WrappedContext = ContextWithTracker(wrapped.WrappedContext, tracker)
+		return handler(srv, &recvWrapper{wrapped, tracker})
+	}
+}
+
+type recvWrapper struct {
+	grpc.ServerStream
+	tracker *Tracker
+}
+
+func (s *recvWrapper) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	recordRequest(s.tracker, m)
+	return nil
+}
+
+// recordRequest records the checks and lookups made by the request, which has not necessarily
+// been validated.
+func recordRequest(tracker *Tracker, req any) {
+	switch req := req.(type) {
+	case *v1.CheckPermissionRequest:
+		tracker.RecordCheck(req.GetResource().GetObjectType(), req.GetPermission())
+
+	case *experimentalv1.CheckPermissionForSubjectsRequest:
+		tracker.RecordCheck(req.GetResource().GetObjectType(), req.GetPermission())
+
+	case *experimentalv1.CheckPermissionForResourcesRequest:
+		tracker.RecordCheck(req.GetResourceObjectType(), req.GetPermission())
+
+	case *v1.LookupResourcesRequest:
+		tracker.RecordLookup(req.GetResourceObjectType(), req.GetPermission())
+
+	case *v1.LookupSubjectsRequest:
+		tracker.RecordLookup(req.GetResource().GetObjectType(), req.GetPermission())
+	}
+}
+
+// recordWrites records the relations written by the request, which has succeeded.
+func recordWrites(tracker *Tracker, req any, resp any, at time.Time) {
+	switch req := req.(type) {
+	case *v1.WriteRelationshipsRequest:
+		for _, update := range req.GetUpdates() {
+			tracker.RecordWrite(update.GetRelationship().GetResource().GetObjectType(), update.GetRelationship().GetRelation(), at)
+		}
+
+	case *experimentalv1.BatchWriteRelationshipsRequest:
+		// Only the updates which were applied are recorded.
+		written, _ := resp.(*experimentalv1.BatchWriteRelationshipsResponse)
+		results := written.GetResults()
+		for index, update := range req.GetUpdates() {
+			if index < len(results) && results[index].GetWrittenAt() != nil {
+				tracker.RecordWrite(update.GetRelationship().GetResource().GetObjectType(), update.GetRelationship().GetRelation(), at)
+			}
+		}
+
+	case *v1.DeleteRelationshipsRequest:
+		filter := req.GetRelationshipFilter()
+		tracker.RecordWrite(filter.GetResourceType(), filter.GetOptionalRelation(), at)
+	}
+}
@@ -0,0 +1,154 @@
+package relationusage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	tf "github.com/authzed/spicedb/internal/testfixtures"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+type lookupStream struct {
+	grpc.ServerStream
+	req *v1.LookupResourcesRequest
+}
+
+func (s *lookupStream) Context() context.Context { return context.Background() }
+
+func (s *lookupStream) RecvMsg(m any) error {
+	proto.Merge(m.(*v1.LookupResourcesRequest), s.req)
+	return nil
+}
+
+func TestInterceptorsRecordUsage(t *testing.T) {
+	tracker := NewTracker()
+	unary := UnaryServerInterceptor(tracker)
+
+	var handled *Tracker
+	handler := func(ctx context.Context, req any) (any, error) {
+		handled = FromContext(ctx)
+		return nil, nil
+	}
+
+	check := &v1.CheckPermissionRequest{
+		Resource:   &v1.ObjectReference{ObjectType: "document", ObjectId: "first"},
+		Permission: "view",
+	}
+	_, err := unary(context.Background(), check, &grpc.UnaryServerInfo{}, handler)
+	require.NoError(t, err)
+	require.Same(t, tracker, handled)
+
+	write := &v1.WriteRelationshipsRequest{
+		Updates: []*v1.RelationshipUpdate{
+			tuple.UpdateToRelationshipUpdate(tuple.Create(tuple.MustParse("document:first#viewer@user:tom"))),
+		},
+	}
+	before := time.Now()
+	_, err = unary(context.Background(), write, &grpc.UnaryServerInfo{}, handler)
+	require.NoError(t, err)
+
+	// Failed writes are not recorded.
+	failing := func(ctx context.Context, req any) (any, error) { return nil, errors.New("failed") }
+	deleteReq := &v1.DeleteRelationshipsRequest{
+		RelationshipFilter: &v1.RelationshipFilter{ResourceType: "document", OptionalRelation: "editor"},
+	}
+	_, err = unary(context.Background(), deleteReq, &grpc.UnaryServerInfo{}, failing)
+	require.Error(t, err)
+
+	stream := StreamServerInterceptor(tracker)
+	err = stream(nil, &lookupStream{req: &v1.LookupResourcesRequest{
+		ResourceObjectType: "document",
+		Permission:         "view",
+	}}, &grpc.StreamServerInfo{}, func(srv any, stream grpc.ServerStream) error {
+		require.Same(t, tracker, FromContext(stream.Context()))
+		return stream.RecvMsg(&v1.LookupResourcesRequest{})
+	})
+	require.NoError(t, err)
+
+	view := tracker.requestCounts("document", "view")
+	require.Equal(t, uint64(1), view.checks)
+	require.Equal(t, uint64(1), view.lookups)
+	require.True(t, view.lastWrite.IsZero())
+
+	viewer := tracker.requestCounts("document", "viewer")
+	require.False(t, viewer.lastWrite.Before(before))
+	require.True(t, tracker.requestCounts("document", "editor").lastWrite.IsZero())
+
+	// A nil tracker records nothing, and is not added to the context.
+	_, err = UnaryServerInterceptor(nil)(context.Background(), check, &grpc.UnaryServerInfo{}, handler)
+	require.NoError(t, err)
+	require.Nil(t, handled)
+}
+
+func TestAnalyze(t *testing.T) {
+	ctx := context.Background()
+	rawDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	require.NoError(t, err)
+
+	ds, revision := tf.DatastoreFromSchemaAndTestRelationships(rawDS, `
+		definition user {}
+
+		definition document {
+			relation viewer: user
+			relation editor: user
+			permission view = viewer + editor
+		}
+	`, []*core.RelationTuple{
+		tuple.MustParse("document:first#viewer@user:tom"),
+		tuple.MustParse("document:second#viewer@user:tom"),
+		tuple.MustParse("document:first#editor@user:fred"),
+	}, require.New(t))
+
+	tracker := NewTracker()
+	tracker.RecordCheck("document", "view")
+	tracker.RecordCheck("document", "view")
+	tracker.RecordLookup("document", "view")
+	tracker.RecordCheck("document", "unknown")
+
+	usages, err := tracker.Analyze(ctx, ds.SnapshotReader(revision))
+	require.NoError(t, err)
+
+	byName := make(map[string]RelationUsage, len(usages))
+	for _, usage := range usages {
+		byName[usage.Namespace+"#"+usage.Relation] = usage
+	}
+	require.Len(t, byName, 3)
+
+	require.Equal(t, uint64(2), byName["document#viewer"].Relationships)
+	require.False(t, byName["document#viewer"].IsPermission)
+	require.Equal(t, uint64(1), byName["document#editor"].Relationships)
+	require.Zero(t, byName["document#editor"].Checks)
+
+	view := byName["document#view"]
+	require.True(t, view.IsPermission)
+	require.Equal(t, uint64(2), view.Checks)
+	require.Equal(t, uint64(1), view.Lookups)
+	require.Zero(t, view.Relationships)
+
+	require.Nil(t, tracker.Report())
+	report, err := tracker.analyzeHead(ctx, ds)
+	require.NoError(t, err)
+	require.Len(t, report.Relations, 3)
+}
+
+func TestTrackerBoundsTrackedRelations(t *testing.T) {
+	tracker := NewTracker()
+	for i := 0; i < maxTrackedRelations+10; i++ {
+		tracker.RecordCheck("document", fmt.Sprintf("relation%d", i))
+	}
+	require.Len(t, tracker.counts, maxTrackedRelations)
+
+	var nilTracker *Tracker
+	nilTracker.RecordCheck("document", "view")
+	require.Nil(t, nilTracker.Report())
+}
@@ -0,0 +1,102 @@
+package relationusage
+
+import (
+	"sync"
+	"time"
+)
+
+// maxTrackedRelations bounds the number of relations for which requests are counted. Requests
+// are counted before they are validated, so the names they reference are not necessarily in
+// the schema.
+const maxTrackedRelations = 10_000
+
+type relationKey struct {
+	namespace string
+	relation  string
+}
+
+type requestCounts struct {
+	checks    uint64
+	lookups   uint64
+	lastWrite time.Time
+}
+
+// Tracker counts the checks and lookups of each relation and permission, and the time of the
+// last write to each relation, as observed by this server since it started. It holds the
+// report of the most recent analysis of the usage of the schema.
+type Tracker struct {
+	sync.Mutex
+	counts map[relationKey]*requestCounts
+	report *Report
+}
+
+// NewTracker creates a new Tracker, with no recorded requests.
+func NewTracker() *Tracker {
+	return &Tracker{counts: make(map[relationKey]*requestCounts)}
+}
+
+// RecordCheck records a check of the permission or relation.
+func (t *Tracker) RecordCheck(namespace, relation string) {
+	t.record(namespace, relation, func(counts *requestCounts) { counts.checks++ })
+}
+
+// RecordLookup records a lookup of the permission or relation.
+func (t *Tracker) RecordLookup(namespace, relation string) {
+	t.record(namespace, relation, func(counts *requestCounts) { counts.lookups++ })
+}
+
+// RecordWrite records a write to the relation at the given time.
+func (t *Tracker) RecordWrite(namespace, relation string, at time.Time) {
+	t.record(namespace, relation, func(counts *requestCounts) {
+		if at.After(counts.lastWrite) {
+			counts.lastWrite = at
+		}
+	})
+}
+
+func (t *Tracker) record(namespace, relation string, fn func(counts *requestCounts)) {
+	if t == nil || namespace == "" || relation == "" {
+		return
+	}
+
+	t.Lock()
+	defer t.Unlock()
+
+	key := relationKey{namespace, relation}
+	counts, ok := t.counts[key]
+	if !ok {
+		if len(t.counts) >= maxTrackedRelations {
+			return
+		}
+		counts = &requestCounts{}
+		t.counts[key] = counts
+	}
+	fn(counts)
+}
+
+// Report returns the report of the most recent analysis, or nil if none has completed.
+func (t *Tracker) Report() *Report {
+	if t == nil {
+		return nil
+	}
+
+	t.Lock()
+	defer t.Unlock()
+	return t.report
+}
+
+func (t *Tracker) requestCounts(namespace, relation string) requestCounts {
+	t.Lock()
+	defer t.Unlock()
+
+	if counts, ok := t.counts[relationKey{namespace, relation}]; ok {
+		return *counts
+	}
+	return requestCounts{}
+}
+
+func (t *Tracker) setReport(report *Report) {
+	t.Lock()
+	defer t.Unlock()
+	t.report = report
+}
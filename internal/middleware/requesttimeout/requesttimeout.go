@@ -0,0 +1,119 @@
+// Package requesttimeout implements middleware which bounds the duration of API requests by
+// timeouts configured for each method.
+package requesttimeout
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	middleware "github.com/grpc-ecosystem/go-grpc-middleware/v2"
+	"google.golang.org/grpc"
+)
+
+// Timeout is the timeout of the requests made to a method.
+type Timeout struct {
+	// Default is the timeout of requests made without a deadline. Zero for no timeout.
+	Default time.Duration
+
+	// Maximum is the maximum timeout of requests made with a deadline, which is shortened to
+	// the maximum if it is later. Zero for no maximum.
+	Maximum time.Duration
+}
+
+// Timeouts are the timeouts of the methods of the API, keyed by either the full name of a
+// method, such as /authzed.api.v1.PermissionsService/CheckPermission, or its name alone, such
+// as CheckPermission, which applies to methods of that name of any service. Methods without a
+// timeout are not bounded.
+type Timeouts map[string]Timeout
+
+// Parse returns the timeouts of the methods, from the default timeouts and the maximum timeouts
+// of the methods, each keyed by method and formatted as durations.
+func Parse(defaults map[string]string, maximums map[string]string) (Timeouts, error) {
+	timeouts := make(Timeouts, len(defaults))
+	for method, value := range defaults {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout for method `%s`: %w", method, err)
+		}
+
+		t := timeouts[method]
+		t.Default = timeout
+		timeouts[method] = t
+	}
+
+	for method, value := range maximums {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid maximum timeout for method `%s`: %w", method, err)
+		}
+
+		t := timeouts[method]
+		t.Maximum = timeout
+		timeouts[method] = t
+	}
+
+	for method, t := range timeouts {
+		if t.Default < 0 || t.Maximum < 0 {
+			return nil, fmt.Errorf("timeouts for method `%s` must not be negative", method)
+		}
+		if t.Maximum > 0 && t.Default > t.Maximum {
+			return nil, fmt.Errorf("timeout of %s for method `%s` exceeds its maximum of %s", t.Default, method, t.Maximum)
+		}
+	}
+	return timeouts, nil
+}
+
+// forMethod returns the timeout of the method with the given full name.
+func (t Timeouts) forMethod(fullMethod string) (Timeout, bool) {
+	if timeout, ok := t[fullMethod]; ok {
+		return timeout, true
+	}
+
+	name := fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+	timeout, ok := t[name]
+	return timeout, ok
+}
+
+// withTimeout returns a context bounded by the timeout of the method. Requests made without a
+// deadline are given the default timeout of the method, while the deadlines of those made with
+// one are kept, within the maximum timeout of the method.
+func (t Timeouts) withTimeout(ctx context.Context, fullMethod string) (context.Context, context.CancelFunc) {
+	timeout, ok := t.forMethod(fullMethod)
+	if !ok {
+		return ctx, func() {}
+	}
+
+	bound := timeout.Default
+	if _, ok := ctx.Deadline(); ok {
+		bound = timeout.Maximum
+	}
+	if bound <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, bound)
+}
+
+// UnaryServerInterceptor returns a new interceptor which bounds the duration of requests by
+// the timeout of their method.
+func UnaryServerInterceptor(timeouts Timeouts) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, cancel := timeouts.withTimeout(ctx, info.FullMethod)
+		defer cancel()
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns a new interceptor which bounds the duration of requests by
+// the timeout of their method.
+func StreamServerInterceptor(timeouts Timeouts) grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, cancel := timeouts.withTimeout(stream.Context(), info.FullMethod)
+		defer cancel()
+
+		wrapped := middleware.WrapServerStream(stream)
+		wrapped.WrappedContext = ctx
+		return handler(srv, wrapped)
+	}
+}
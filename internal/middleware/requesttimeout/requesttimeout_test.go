@@ -0,0 +1,74 @@
+package requesttimeout
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestParse(t *testing.T) {
+	timeouts, err := Parse(
+		map[string]string{"CheckPermission": "10s", "LookupResources": "1m"},
+		map[string]string{"CheckPermission": "30s", "ReadRelationships": "1h"},
+	)
+	require.NoError(t, err)
+	require.Equal(t, Timeouts{
+		"CheckPermission":   {Default: 10 * time.Second, Maximum: 30 * time.Second},
+		"LookupResources":   {Default: time.Minute},
+		"ReadRelationships": {Maximum: time.Hour},
+	}, timeouts)
+
+	_, err = Parse(map[string]string{"CheckPermission": "soon"}, nil)
+	require.ErrorContains(t, err, "invalid timeout for method `CheckPermission`")
+
+	_, err = Parse(map[string]string{"CheckPermission": "-1s"}, nil)
+	require.ErrorContains(t, err, "must not be negative")
+
+	_, err = Parse(map[string]string{"CheckPermission": "1m"}, map[string]string{"CheckPermission": "10s"})
+	require.ErrorContains(t, err, "exceeds its maximum")
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	timeouts := Timeouts{
+		"CheckPermission": {Default: time.Second, Maximum: time.Minute},
+		"/authzed.api.v1.PermissionsService/LookupResources": {Default: time.Hour},
+	}
+
+	for _, tc := range []struct {
+		name             string
+		fullMethod       string
+		callerTimeout    time.Duration
+		expectedDeadline bool
+		expectedTimeout  time.Duration
+	}{
+		{"default timeout", "/authzed.api.v1.PermissionsService/CheckPermission", 0, true, time.Second},
+		{"shorter caller deadline", "/authzed.api.v1.PermissionsService/CheckPermission", 10 * time.Second, true, 10 * time.Second},
+		{"caller deadline beyond maximum", "/authzed.api.v1.PermissionsService/CheckPermission", time.Hour, true, time.Minute},
+		{"full method name", "/authzed.api.v1.PermissionsService/LookupResources", 0, true, time.Hour},
+		{"caller deadline without maximum", "/authzed.api.v1.PermissionsService/LookupResources", 2 * time.Hour, true, 2 * time.Hour},
+		{"method without timeout", "/authzed.api.v1.WatchService/Watch", 0, false, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tc.callerTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tc.callerTimeout)
+				defer cancel()
+			}
+
+			start := time.Now()
+			_, err := UnaryServerInterceptor(timeouts)(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tc.fullMethod}, func(ctx context.Context, req any) (any, error) {
+				deadline, ok := ctx.Deadline()
+				require.Equal(t, tc.expectedDeadline, ok)
+				if ok {
+					require.WithinDuration(t, start.Add(tc.expectedTimeout), deadline, time.Second)
+				}
+				return nil, nil
+			})
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,54 @@
+// Package restrictedtokens implements middleware which limits the requests made with tokens
+// restricted to templates to invoking the templates allowed for them.
+package restrictedtokens
+
+import (
+	"context"
+
+	grpcauth "github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/authzed/spicedb/internal/templates"
+	experimentalv1 "github.com/authzed/spicedb/pkg/proto/experimental/v1"
+)
+
+func restrictions(ctx context.Context) (map[string]struct{}, bool) {
+	token, err := grpcauth.AuthFromMD(ctx, "bearer")
+	if err != nil {
+		return nil, false
+	}
+	return templates.Current().Restrictions(token)
+}
+
+// UnaryServerInterceptor returns a new interceptor which denies requests made with restricted
+// tokens, other than checks of the templates allowed for them.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		allowed, restricted := restrictions(ctx)
+		if !restricted {
+			return handler(ctx, req)
+		}
+
+		templateReq, ok := req.(*experimentalv1.CheckTemplateRequest)
+		if !ok {
+			return nil, status.Errorf(codes.PermissionDenied, "token may only be used to check templates")
+		}
+		if _, ok := allowed[templateReq.Name]; !ok {
+			return nil, status.Errorf(codes.PermissionDenied, "token may not check template `%s`", templateReq.Name)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns a new interceptor which denies streaming requests made with
+// restricted tokens, as templates are only checked by unary requests.
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if _, restricted := restrictions(stream.Context()); restricted {
+			return status.Errorf(codes.PermissionDenied, "token may only be used to check templates")
+		}
+		return handler(srv, stream)
+	}
+}
@@ -0,0 +1,75 @@
+package restrictedtokens
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/authzed/grpcutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/authzed/spicedb/internal/templates"
+	experimentalv1 "github.com/authzed/spicedb/pkg/proto/experimental/v1"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	digest := sha256.Sum256([]byte("restricted"))
+	registry, err := templates.Parse([]byte(`
+templates:
+  - name: can-view
+    resource: document:$doc
+    permission: view
+    subject: user:$user
+  - name: can-edit
+    resource: document:$doc
+    permission: edit
+    subject: user:$user
+restricted_tokens:
+  - sha256: ` + hex.EncodeToString(digest[:]) + `
+    templates: [can-view]
+`))
+	require.NoError(t, err)
+
+	interceptor := UnaryServerInterceptor()
+	handler := func(ctx context.Context, req any) (any, error) {
+		return req, nil
+	}
+
+	for _, tc := range []struct {
+		name     string
+		registry *templates.Registry
+		token    string
+		req      any
+		expected codes.Code
+	}{
+		{"no templates", nil, "restricted", &v1.CheckPermissionRequest{}, codes.OK},
+		{"no token", registry, "", &v1.CheckPermissionRequest{}, codes.OK},
+		{"unrestricted token", registry, "other", &v1.CheckPermissionRequest{}, codes.OK},
+		{"restricted token allowed template", registry, "restricted", &experimentalv1.CheckTemplateRequest{Name: "can-view"}, codes.OK},
+		{"restricted token disallowed template", registry, "restricted", &experimentalv1.CheckTemplateRequest{Name: "can-edit"}, codes.PermissionDenied},
+		{"restricted token other API", registry, "restricted", &v1.CheckPermissionRequest{}, codes.PermissionDenied},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			templates.Set(tc.registry)
+			t.Cleanup(func() { templates.Set(nil) })
+
+			ctx := context.Background()
+			if tc.token != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "bearer "+tc.token))
+			}
+
+			_, err := interceptor(ctx, tc.req, &grpc.UnaryServerInfo{}, handler)
+			if tc.expected == codes.OK {
+				require.NoError(t, err)
+			} else {
+				grpcutil.RequireStatus(t, tc.expected, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+// Package retryinfo annotates the errors returned by the API with whether the request may
+// succeed if retried, and the backoff suggested before doing so.
+package retryinfo
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/runtime/protoiface"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+
+	"github.com/authzed/spicedb/pkg/datastore"
+	"github.com/authzed/spicedb/pkg/spiceerrors"
+)
+
+// RetryableMetadataKey is the key of the ErrorInfo metadata holding whether the request may
+// succeed if retried.
+const RetryableMetadataKey = "retryable"
+
+// backoffByCode is the backoff suggested before retrying a request failing with each code
+// considered retryable.
+var backoffByCode = map[codes.Code]time.Duration{
+	codes.Aborted:           50 * time.Millisecond,
+	codes.Unavailable:       100 * time.Millisecond,
+	codes.DeadlineExceeded:  100 * time.Millisecond,
+	codes.ResourceExhausted: 1 * time.Second,
+}
+
+// notRetryableReasons are the reasons of errors whose code is otherwise retryable, but which will
+// fail again if retried unchanged.
+var notRetryableReasons = map[string]struct{}{
+	v1.ErrorReason_name[int32(v1.ErrorReason_ERROR_REASON_SERVICE_READ_ONLY)]:           {},
+	v1.ErrorReason_name[int32(v1.ErrorReason_ERROR_REASON_TOO_MANY_UPDATES_IN_REQUEST)]: {},
+	spiceerrors.ReasonMaximumDepthExceeded:                                              {},
+	spiceerrors.ReasonResultTooLarge:                                                    {},
+	spiceerrors.ReasonRevisionStale:                                                     {},
+}
+
+// Classify returns whether a request failing with the error may succeed if retried, and the
+// backoff suggested before doing so.
+func Classify(err error) (bool, time.Duration) {
+	switch {
+	case err == nil:
+		return false, 0
+	case errors.As(err, &datastore.ErrReadOnly{}):
+		return false, 0
+	case errors.Is(err, context.Canceled):
+		return false, 0
+	}
+
+	st := status.Convert(err)
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			if _, ok := notRetryableReasons[info.GetReason()]; ok {
+				return false, 0
+			}
+		}
+	}
+
+	backoff, ok := backoffByCode[st.Code()]
+	return ok, backoff
+}
+
+// Annotate returns the error as a gRPC status, with any ErrorInfo recording whether the request
+// may succeed if retried and, if so, a RetryInfo holding the backoff suggested before doing so.
+func Annotate(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	retryable, backoff := Classify(err)
+
+	hasRetryInfo := false
+	st := status.Convert(err)
+	details := make([]protoiface.MessageV1, 0, len(st.Details())+1)
+	for _, detail := range st.Details() {
+		switch detail := detail.(type) {
+		case *errdetails.ErrorInfo:
+			info := proto.Clone(detail).(*errdetails.ErrorInfo)
+			if info.Metadata == nil {
+				info.Metadata = map[string]string{}
+			}
+			info.Metadata[RetryableMetadataKey] = strconv.FormatBool(retryable)
+			details = append(details, info)
+
+		case *errdetails.RetryInfo:
+			// Keep the backoff given by the error itself.
+			hasRetryInfo = true
+			details = append(details, detail)
+
+		case protoiface.MessageV1:
+			details = append(details, detail)
+
+		default:
+			// A detail could not be decoded, so leave the error as it is rather than drop it.
+			return err
+		}
+	}
+
+	if retryable && !hasRetryInfo {
+		details = append(details, &errdetails.RetryInfo{RetryDelay: durationpb.New(backoff)})
+	}
+
+	annotated, derr := status.New(st.Code(), st.Message()).WithDetails(details...)
+	if derr != nil {
+		return err
+	}
+	return annotated.Err()
+}
+
+// UnaryServerInterceptor returns a new interceptor which annotates the errors returned with
+// retry information.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		resp, err := handler(ctx, req)
+		return resp, Annotate(err)
+	}
+}
+
+// StreamServerInterceptor returns a new interceptor which annotates the errors returned with
+// retry information.
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return Annotate(handler(srv, stream))
+	}
+}
@@ -0,0 +1,91 @@
+package retryinfo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+
+	"github.com/authzed/spicedb/internal/services/shared"
+	"github.com/authzed/spicedb/pkg/spiceerrors"
+)
+
+func TestAnnotate(t *testing.T) {
+	testCases := []struct {
+		name              string
+		err               error
+		expectedRetryable bool
+		expectedBackoff   time.Duration
+	}{
+		{"unavailable", status.Error(codes.Unavailable, "down"), true, 100 * time.Millisecond},
+		{"aborted", status.Error(codes.Aborted, "serialization failure"), true, 50 * time.Millisecond},
+		{"resource exhausted", status.Error(codes.ResourceExhausted, "shed"), true, 1 * time.Second},
+		{"invalid argument", status.Error(codes.InvalidArgument, "bad"), false, 0},
+		{"plain error", errors.New("unknown"), false, 0},
+		{"canceled", context.Canceled, false, 0},
+		{"read only", shared.ErrServiceReadOnly, false, 0},
+		{
+			"max depth",
+			spiceerrors.WithCodeAndReasonName(errors.New("max depth"), codes.ResourceExhausted, spiceerrors.ReasonMaximumDepthExceeded),
+			false,
+			0,
+		},
+		{
+			"unknown definition",
+			spiceerrors.WithCodeAndReason(errors.New("missing"), codes.FailedPrecondition, v1.ErrorReason_ERROR_REASON_UNKNOWN_DEFINITION),
+			false,
+			0,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			annotated := Annotate(tc.err)
+			st, ok := status.FromError(annotated)
+			require.True(t, ok)
+			require.Equal(t, status.Convert(tc.err).Code(), st.Code())
+
+			var retryInfo *errdetails.RetryInfo
+			for _, detail := range st.Details() {
+				switch detail := detail.(type) {
+				case *errdetails.RetryInfo:
+					retryInfo = detail
+				case *errdetails.ErrorInfo:
+					expected := "false"
+					if tc.expectedRetryable {
+						expected = "true"
+					}
+					require.Equal(t, expected, detail.Metadata[RetryableMetadataKey])
+				}
+			}
+
+			if !tc.expectedRetryable {
+				require.Nil(t, retryInfo)
+				return
+			}
+			require.NotNil(t, retryInfo)
+			require.Equal(t, tc.expectedBackoff, retryInfo.RetryDelay.AsDuration())
+		})
+	}
+}
+
+func TestAnnotateKeepsExistingRetryInfo(t *testing.T) {
+	st, err := status.New(codes.Unavailable, "down").WithDetails(&errdetails.RetryInfo{})
+	require.NoError(t, err)
+
+	annotated, ok := status.FromError(Annotate(st.Err()))
+	require.True(t, ok)
+	require.Len(t, annotated.Details(), 1)
+}
+
+func TestAnnotateNil(t *testing.T) {
+	require.NoError(t, Annotate(nil))
+}
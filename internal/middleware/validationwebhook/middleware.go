@@ -0,0 +1,81 @@
+package validationwebhook
+
+import (
+	"context"
+
+	grpcauth "github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	log "github.com/authzed/spicedb/internal/logging"
+	"github.com/authzed/spicedb/internal/middleware/quota"
+	"github.com/authzed/spicedb/pkg/middleware/requestid"
+)
+
+// UnaryServerInterceptor returns a new interceptor which submits the mutations made by requests
+// to the webhook, and only applies those which it allows. A nil webhook allows every mutation.
+func UnaryServerInterceptor(webhook *Webhook) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if webhook == nil || !isMutation(req) {
+			return handler(ctx, req)
+		}
+
+		if err := webhook.check(ctx, info.FullMethod, req); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns a new interceptor for streaming requests, which do not mutate
+// the relationships or schema, and so are not submitted to the webhook.
+func StreamServerInterceptor(_ *Webhook) grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, stream)
+	}
+}
+
+// check submits the request to the webhook, returning the error with which the request fails
+// if it is denied, or if the review fails and the webhook fails closed.
+func (w *Webhook) check(ctx context.Context, method string, req any) error {
+	request, err := marshalRequest(req)
+	if err != nil {
+		return status.Errorf(codes.Internal, "unable to submit request to validation webhook: %s", err)
+	}
+
+	review := Review{Method: method, Request: request}
+	if token, err := grpcauth.AuthFromMD(ctx, "bearer"); err == nil {
+		review.TokenID = quota.TokenID(token)
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if requestIDs := md.Get(requestid.RequestIDMetadataKey); len(requestIDs) > 0 {
+			review.RequestID = requestIDs[0]
+		}
+	}
+
+	verdict, err := w.review(ctx, review)
+	switch {
+	case err != nil && w.config.FailOpen:
+		reviewsCounter.WithLabelValues(method, outcomeFailedOpen).Inc()
+		log.Ctx(ctx).Warn().Err(err).Str("method", method).Msg("validation webhook failed; allowing mutation")
+		return nil
+
+	case err != nil:
+		reviewsCounter.WithLabelValues(method, outcomeFailed).Inc()
+		log.Ctx(ctx).Warn().Err(err).Str("method", method).Msg("validation webhook failed; rejecting mutation")
+		return status.Errorf(codes.Unavailable, "unable to validate the mutation with the validation webhook")
+
+	case !verdict.Allowed:
+		reviewsCounter.WithLabelValues(method, outcomeDenied).Inc()
+		if verdict.Reason == "" {
+			return status.Errorf(codes.PermissionDenied, "mutation denied by validation webhook")
+		}
+		return status.Errorf(codes.PermissionDenied, "mutation denied by validation webhook: %s", verdict.Reason)
+
+	default:
+		reviewsCounter.WithLabelValues(method, outcomeAllowed).Inc()
+		return nil
+	}
+}
@@ -0,0 +1,159 @@
+// Package validationwebhook implements middleware which submits the mutations made by requests
+// to an external webhook before they are applied, so that organizations can enforce central
+// policies, such as on the naming of definitions or the approval of changes to ownership. The
+// webhook allows or denies each mutation, and a mutation is only applied once allowed.
+package validationwebhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	experimentalv1 "github.com/authzed/spicedb/pkg/proto/experimental/v1"
+)
+
+// maximumVerdictSize is the maximum size of the body of a response from the webhook.
+const maximumVerdictSize = 64 * 1024
+
+var reviewsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "spicedb",
+	Subsystem: "validation_webhook",
+	Name:      "reviews_total",
+	Help:      "The number of mutations submitted to the validation webhook, by method and outcome.",
+}, []string{"method", "outcome"})
+
+func init() {
+	prometheus.MustRegister(reviewsCounter)
+}
+
+const (
+	outcomeAllowed    = "allowed"
+	outcomeDenied     = "denied"
+	outcomeFailedOpen = "failed_open"
+	outcomeFailed     = "failed"
+)
+
+// isMutation returns whether the request mutates the relationships or schema, and so is
+// submitted to the webhook.
+func isMutation(req any) bool {
+	switch req.(type) {
+	case *v1.WriteRelationshipsRequest, *v1.WriteSchemaRequest, *experimentalv1.BatchWriteRelationshipsRequest:
+		return true
+	default:
+		return false
+	}
+}
+
+// Review is the body of the request made to the webhook for each mutation.
+type Review struct {
+	// Method is the full name of the gRPC method called.
+	Method string `json:"method"`
+
+	// Request is the request, as protobuf JSON.
+	Request json.RawMessage `json:"request"`
+
+	// TokenID identifies the token with which the request was made, if any, without revealing
+	// it, as reported in the metrics of quotas.
+	TokenID string `json:"token_id,omitempty"`
+
+	// RequestID is the ID of the request, as logged.
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Verdict is the body of the response of the webhook.
+type Verdict struct {
+	// Allowed is true if the mutation may be applied.
+	Allowed bool `json:"allowed"`
+
+	// Reason is the reason for which the mutation was denied, which is returned to the caller.
+	Reason string `json:"reason,omitempty"`
+}
+
+// Config configures the validation webhook.
+type Config struct {
+	// URL is the URL to which reviews are POSTed.
+	URL string
+
+	// Timeout is the time after which a review which has not been answered fails.
+	Timeout time.Duration
+
+	// FailOpen, if true, applies mutations whose review fails, rather than rejecting them.
+	FailOpen bool
+}
+
+// Webhook submits mutations to the configured webhook for review.
+type Webhook struct {
+	config Config
+	client *http.Client
+}
+
+// NewWebhook creates a webhook submitting reviews to the configured URL.
+func NewWebhook(config Config) (*Webhook, error) {
+	parsed, err := url.Parse(config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid validation webhook URL: %w", err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, fmt.Errorf("validation webhook URL must be http or https")
+	}
+
+	if config.Timeout <= 0 {
+		return nil, fmt.Errorf("validation webhook timeout must be positive")
+	}
+
+	return &Webhook{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+	}, nil
+}
+
+// review submits the request to the webhook, and returns its verdict.
+func (w *Webhook) review(ctx context.Context, review Review) (Verdict, error) {
+	body, err := json.Marshal(review)
+	if err != nil {
+		return Verdict{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, w.config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return Verdict{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return Verdict{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Verdict{}, fmt.Errorf("validation webhook responded with status %d", resp.StatusCode)
+	}
+
+	var verdict Verdict
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maximumVerdictSize)).Decode(&verdict); err != nil {
+		return Verdict{}, fmt.Errorf("invalid response from validation webhook: %w", err)
+	}
+	return verdict, nil
+}
+
+func marshalRequest(req any) (json.RawMessage, error) {
+	message, ok := req.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("unexpected request type %T", req)
+	}
+	return protojson.Marshal(message)
+}
@@ -0,0 +1,116 @@
+package validationwebhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/authzed/spicedb/internal/middleware/quota"
+)
+
+const writeSchemaMethod = "/authzed.api.v1.SchemaService/WriteSchema"
+
+func newTestWebhook(t *testing.T, failOpen bool, handler http.HandlerFunc) *Webhook {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	webhook, err := NewWebhook(Config{URL: server.URL, Timeout: 100 * time.Millisecond, FailOpen: failOpen})
+	require.NoError(t, err)
+	return webhook
+}
+
+func callWriteSchema(webhook *Webhook, ctx context.Context) (handled bool, err error) {
+	unary := UnaryServerInterceptor(webhook)
+	_, err = unary(ctx, &v1.WriteSchemaRequest{Schema: "definition user {}"}, &grpc.UnaryServerInfo{FullMethod: writeSchemaMethod},
+		func(ctx context.Context, req any) (any, error) {
+			handled = true
+			return nil, nil
+		})
+	return handled, err
+}
+
+func TestWebhookReviewsMutations(t *testing.T) {
+	var reviews []Review
+	webhook := newTestWebhook(t, false, func(w http.ResponseWriter, r *http.Request) {
+		var review Review
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&review))
+		reviews = append(reviews, review)
+
+		var req v1.WriteSchemaRequest
+		require.NoError(t, protojson.Unmarshal(review.Request, &req))
+		require.NoError(t, json.NewEncoder(w).Encode(Verdict{Allowed: req.Schema == "definition user {}", Reason: "unknown schema"}))
+	})
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "bearer sometoken", "x-request-id", "somerequest"))
+	handled, err := callWriteSchema(webhook, ctx)
+	require.NoError(t, err)
+	require.True(t, handled)
+	require.Equal(t, []Review{{
+		Method:    writeSchemaMethod,
+		Request:   reviews[0].Request,
+		TokenID:   quota.TokenID("sometoken"),
+		RequestID: "somerequest",
+	}}, reviews)
+
+	unary := UnaryServerInterceptor(webhook)
+	_, err = unary(context.Background(), &v1.WriteSchemaRequest{Schema: "definition document {}"}, &grpc.UnaryServerInfo{FullMethod: writeSchemaMethod},
+		func(ctx context.Context, req any) (any, error) {
+			require.Fail(t, "denied mutations must not be applied")
+			return nil, nil
+		})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+	require.Contains(t, err.Error(), "unknown schema")
+
+	// Requests which do not mutate are not reviewed.
+	_, err = unary(context.Background(), &v1.ReadSchemaRequest{}, &grpc.UnaryServerInfo{}, func(ctx context.Context, req any) (any, error) {
+		return nil, nil
+	})
+	require.NoError(t, err)
+	require.Len(t, reviews, 2)
+}
+
+func TestWebhookFailures(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"error status", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusInternalServerError) }},
+		{"invalid verdict", func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte("allowed")) }},
+		{"timeout", func(w http.ResponseWriter, r *http.Request) { time.Sleep(time.Second) }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			handled, err := callWriteSchema(newTestWebhook(t, false, tc.handler), context.Background())
+			require.Equal(t, codes.Unavailable, status.Code(err))
+			require.False(t, handled)
+
+			handled, err = callWriteSchema(newTestWebhook(t, true, tc.handler), context.Background())
+			require.NoError(t, err)
+			require.True(t, handled)
+		})
+	}
+}
+
+func TestNilWebhook(t *testing.T) {
+	handled, err := callWriteSchema(nil, context.Background())
+	require.NoError(t, err)
+	require.True(t, handled)
+}
+
+func TestNewWebhookErrors(t *testing.T) {
+	_, err := NewWebhook(Config{URL: "ftp://example.com", Timeout: time.Second})
+	require.Error(t, err)
+
+	_, err = NewWebhook(Config{URL: "https://example.com"})
+	require.Error(t, err)
+}
@@ -0,0 +1,68 @@
+package visibility
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// UnaryServerInterceptor returns a new interceptor which denies requests made with tokens
+// restricted to allowlists of namespaces which reference other namespaces, and filters other
+// namespaces from their responses.
+func UnaryServerInterceptor(allowlists TokenAllowlists) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		allowlist, restricted := allowlists.fromContext(ctx)
+		if !restricted {
+			return handler(ctx, req)
+		}
+
+		if err := checkRequest(allowlist, info.FullMethod, req); err != nil {
+			return nil, err
+		}
+
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		return filterResponse(allowlist, resp)
+	}
+}
+
+// StreamServerInterceptor returns a new interceptor which denies streaming requests made with
+// tokens restricted to allowlists of namespaces which reference other namespaces, and filters
+// other namespaces from the responses sent.
+func StreamServerInterceptor(allowlists TokenAllowlists) grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		allowlist, restricted := allowlists.fromContext(stream.Context())
+		if !restricted {
+			return handler(srv, stream)
+		}
+
+		return handler(srv, &filteringStream{ServerStream: stream, allowlist: allowlist, method: info.FullMethod})
+	}
+}
+
+// filteringStream checks the requests received on a stream, and filters the responses sent.
+type filteringStream struct {
+	grpc.ServerStream
+	allowlist *Allowlist
+	method    string
+}
+
+func (s *filteringStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return checkRequest(s.allowlist, s.method, m)
+}
+
+func (s *filteringStream) SendMsg(m any) error {
+	filtered, err := filterResponse(s.allowlist, m)
+	if err != nil {
+		return err
+	}
+	if filtered == nil {
+		return nil
+	}
+	return s.ServerStream.SendMsg(filtered)
+}
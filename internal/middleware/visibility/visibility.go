@@ -0,0 +1,451 @@
+// Package visibility implements middleware which limits the requests made with tokens restricted
+// to allowlists of namespaces to those namespaces: requests referencing other namespaces are
+// denied, and relationships, subjects and definitions of other namespaces are filtered from
+// their responses.
+package visibility
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	grpcauth "github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/authzed/spicedb/internal/templates"
+	experimentalv1 "github.com/authzed/spicedb/pkg/proto/experimental/v1"
+	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
+	"github.com/authzed/spicedb/pkg/schemadsl/generator"
+	"github.com/authzed/spicedb/pkg/schemadsl/input"
+)
+
+// Allowlist is the namespaces visible to a token.
+type Allowlist struct {
+	names    map[string]struct{}
+	prefixes []string
+}
+
+// ParseAllowlist parses an allowlist of namespaces, each of which is either the name of a
+// namespace, or a prefix ending in `/*`, such as `tenant/*`, allowing every namespace with the
+// prefix.
+func ParseAllowlist(entries []string) (*Allowlist, error) {
+	allowlist := &Allowlist{names: make(map[string]struct{}, len(entries))}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		switch {
+		case entry == "":
+			return nil, fmt.Errorf("empty namespace in allowlist")
+
+		case strings.HasSuffix(entry, "/*"):
+			allowlist.prefixes = append(allowlist.prefixes, strings.TrimSuffix(entry, "*"))
+
+		case strings.Contains(entry, "*"):
+			return nil, fmt.Errorf("invalid namespace `%s` in allowlist: wildcards are only allowed as a `/*` suffix", entry)
+
+		default:
+			allowlist.names[entry] = struct{}{}
+		}
+	}
+	return allowlist, nil
+}
+
+// Allows returns whether the namespace is visible.
+func (a *Allowlist) Allows(namespace string) bool {
+	if _, ok := a.names[namespace]; ok {
+		return true
+	}
+	for _, prefix := range a.prefixes {
+		if strings.HasPrefix(namespace, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// TokenAllowlists maps tokens to the namespaces visible to them. Tokens without an allowlist
+// may access every namespace.
+type TokenAllowlists map[string]*Allowlist
+
+func (ta TokenAllowlists) fromContext(ctx context.Context) (*Allowlist, bool) {
+	if len(ta) == 0 {
+		return nil, false
+	}
+
+	token, err := grpcauth.AuthFromMD(ctx, "bearer")
+	if err != nil {
+		return nil, false
+	}
+
+	allowlist, ok := ta[token]
+	return allowlist, ok
+}
+
+// unrestrictedServicePrefixes are the prefixes of the methods which do not access namespaces,
+// and so are allowed for every token.
+var unrestrictedServicePrefixes = []string{
+	"/grpc.health.v1.Health/",
+	"/grpc.reflection.",
+}
+
+func denied(namespace string) error {
+	return status.Errorf(codes.PermissionDenied, "token may not access namespace `%s`", namespace)
+}
+
+// checkRequest returns an error if the request, which has not necessarily been validated,
+// references namespaces which are not visible. Requests of methods which are not known to be
+// limited to the namespaces they reference are denied.
+func checkRequest(allowlist *Allowlist, method string, req any) error {
+	check := func(namespaces ...string) error {
+		for _, namespace := range namespaces {
+			if !allowlist.Allows(namespace) {
+				return denied(namespace)
+			}
+		}
+		return nil
+	}
+
+	checkFilter := func(filter *v1.RelationshipFilter) error {
+		if err := check(filter.GetResourceType()); err != nil {
+			return err
+		}
+		if subjectFilter := filter.GetOptionalSubjectFilter(); subjectFilter != nil {
+			return check(subjectFilter.GetSubjectType())
+		}
+		return nil
+	}
+
+	checkPreconditions := func(preconditions []*v1.Precondition) error {
+		for _, precondition := range preconditions {
+			if err := checkFilter(precondition.GetFilter()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	checkRelationships := func(relationships []*v1.Relationship) error {
+		for _, relationship := range relationships {
+			if err := check(relationship.GetResource().GetObjectType(), relationship.GetSubject().GetObject().GetObjectType()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	switch req := req.(type) {
+	case *v1.CheckPermissionRequest:
+		return check(req.GetResource().GetObjectType(), req.GetSubject().GetObject().GetObjectType())
+
+	case *v1.ExpandPermissionTreeRequest:
+		return check(req.GetResource().GetObjectType())
+
+	case *v1.LookupResourcesRequest:
+		return check(req.GetResourceObjectType(), req.GetSubject().GetObject().GetObjectType())
+
+	case *v1.LookupSubjectsRequest:
+		return check(req.GetResource().GetObjectType(), req.GetSubjectObjectType())
+
+	case *v1.ReadRelationshipsRequest:
+		return checkFilter(req.GetRelationshipFilter())
+
+	case *v1.WriteRelationshipsRequest:
+		for _, update := range req.GetUpdates() {
+			relationship := update.GetRelationship()
+			if err := check(relationship.GetResource().GetObjectType(), relationship.GetSubject().GetObject().GetObjectType()); err != nil {
+				return err
+			}
+		}
+		return checkPreconditions(req.GetOptionalPreconditions())
+
+	case *v1.DeleteRelationshipsRequest:
+		if err := checkFilter(req.GetRelationshipFilter()); err != nil {
+			return err
+		}
+		return checkPreconditions(req.GetOptionalPreconditions())
+
+	case *v1.ReadSchemaRequest, *experimentalv1.ReflectSchemaRequest:
+		return nil
+
+	case *experimentalv1.FormatSchemaRequest:
+		// Only the schema given is formatted.
+		return nil
+
+	case *v1.WriteSchemaRequest:
+		return status.Errorf(codes.PermissionDenied, "token restricted to namespaces may not write the schema, which replaces the definitions of every namespace")
+
+	case *v1.WatchRequest:
+		return check(req.GetOptionalObjectTypes()...)
+
+	case *experimentalv1.CheckPermissionForSubjectsRequest:
+		if err := check(req.GetResource().GetObjectType()); err != nil {
+			return err
+		}
+		for _, subject := range req.GetSubjects() {
+			if err := check(subject.GetObject().GetObjectType()); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case *experimentalv1.CheckPermissionForResourcesRequest:
+		return check(req.GetResourceObjectType(), req.GetSubject().GetObject().GetObjectType())
+
+	case *experimentalv1.CheckTemplateRequest:
+		template, ok := templates.Current().Lookup(req.GetName())
+		if !ok {
+			// The experimental service reports the unknown template.
+			return nil
+		}
+		return check(namespaceOf(template.Resource), namespaceOf(template.Subject))
+
+	case *experimentalv1.StreamExpandPermissionTreeRequest:
+		return check(req.GetResource().GetObjectType())
+
+	case *experimentalv1.LookupMaterializedResourcesRequest:
+		return check(req.GetResourceObjectType(), req.GetSubject().GetObjectType())
+
+	case *experimentalv1.ReflectPermissionSubjectTypesRequest:
+		return check(req.GetResourceObjectType())
+
+	case *experimentalv1.ReadRelationshipsAtRequest:
+		return checkFilter(req.GetRelationshipFilter())
+
+	case *experimentalv1.ReadRelationshipChangesRequest:
+		return checkFilter(req.GetRelationshipFilter())
+
+	case *experimentalv1.BatchWriteRelationshipsRequest:
+		for _, update := range req.GetUpdates() {
+			relationship := update.GetRelationship()
+			if err := check(relationship.GetResource().GetObjectType(), relationship.GetSubject().GetObject().GetObjectType()); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case *experimentalv1.CheckPermissionAtTimeRequest:
+		return check(req.GetResource().GetObjectType(), req.GetSubject().GetObject().GetObjectType())
+
+	case *experimentalv1.ContextualCheckPermissionRequest:
+		if err := check(req.GetResource().GetObjectType(), req.GetSubject().GetObject().GetObjectType()); err != nil {
+			return err
+		}
+		return checkRelationships(req.GetContextualRelationships())
+
+	case *experimentalv1.ContextualLookupResourcesRequest:
+		if err := check(req.GetResourceObjectType(), req.GetSubject().GetObject().GetObjectType()); err != nil {
+			return err
+		}
+		return checkRelationships(req.GetContextualRelationships())
+
+	case *experimentalv1.ContextualLookupSubjectsRequest:
+		if err := check(req.GetResource().GetObjectType(), req.GetSubjectObjectType()); err != nil {
+			return err
+		}
+		return checkRelationships(req.GetContextualRelationships())
+
+	case *experimentalv1.WhatIfRequest:
+		if req.GetSchema() != "" {
+			return status.Errorf(codes.PermissionDenied, "token restricted to namespaces may not evaluate a candidate schema, which replaces the definitions of every namespace")
+		}
+		for _, update := range req.GetUpdates() {
+			relationship := update.GetRelationship()
+			if err := check(relationship.GetResource().GetObjectType(), relationship.GetSubject().GetObject().GetObjectType()); err != nil {
+				return err
+			}
+		}
+		for _, probe := range req.GetProbes() {
+			if err := check(probe.GetResource().GetObjectType(), probe.GetSubject().GetObject().GetObjectType()); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case *experimentalv1.CountAccessibleResourcesRequest:
+		return check(req.GetResourceObjectType(), req.GetSubject().GetObject().GetObjectType())
+
+	case *experimentalv1.StartDeleteRelationshipsJobRequest:
+		return checkFilter(req.GetRelationshipFilter())
+
+	case *experimentalv1.GetJobRequest, *experimentalv1.ListJobsRequest, *experimentalv1.CancelJobRequest:
+		return status.Errorf(codes.PermissionDenied, "token restricted to namespaces may not manage jobs, which may operate on every namespace")
+
+	default:
+		for _, prefix := range unrestrictedServicePrefixes {
+			if strings.HasPrefix(method, prefix) {
+				return nil
+			}
+		}
+		return status.Errorf(codes.PermissionDenied, "token restricted to namespaces may not call %s", method)
+	}
+}
+
+// filterResponse returns the response with the relationships, subjects and definitions of
+// namespaces which are not visible removed, or nil if nothing visible remains of a response
+// sent on a stream.
+func filterResponse(allowlist *Allowlist, resp any) (any, error) {
+	switch resp := resp.(type) {
+	case *v1.ExpandPermissionTreeResponse:
+		return &v1.ExpandPermissionTreeResponse{
+			ExpandedAt: resp.ExpandedAt,
+			TreeRoot:   filterTree(allowlist, resp.TreeRoot),
+		}, nil
+
+	case *v1.ReadRelationshipsResponse:
+		if !allowsRelationship(allowlist, resp.GetRelationship()) {
+			return nil, nil
+		}
+		return resp, nil
+
+	case *experimentalv1.ReadRelationshipsAtResponse:
+		if !allowsRelationship(allowlist, resp.GetRelationship()) {
+			return nil, nil
+		}
+		return resp, nil
+
+	case *experimentalv1.ReadRelationshipChangesResponse:
+		if !allowsRelationship(allowlist, resp.GetUpdate().GetRelationship()) {
+			return nil, nil
+		}
+		return resp, nil
+
+	case *v1.ReadSchemaResponse:
+		schemaText, err := filterSchema(allowlist, resp.SchemaText)
+		if err != nil {
+			return nil, err
+		}
+		return &v1.ReadSchemaResponse{SchemaText: schemaText}, nil
+
+	case *v1.WatchResponse:
+		updates := make([]*v1.RelationshipUpdate, 0, len(resp.Updates))
+		for _, update := range resp.Updates {
+			if allowsRelationship(allowlist, update.GetRelationship()) {
+				updates = append(updates, update)
+			}
+		}
+		if len(updates) == 0 && len(resp.Updates) > 0 {
+			return nil, nil
+		}
+		return &v1.WatchResponse{Updates: updates, ChangesThrough: resp.ChangesThrough}, nil
+
+	case *experimentalv1.StreamExpandPermissionTreeResponse:
+		tree := filterTree(allowlist, resp.Tree)
+		if tree == nil {
+			return nil, nil
+		}
+		return &experimentalv1.StreamExpandPermissionTreeResponse{
+			ExpandedAt:       resp.ExpandedAt,
+			FragmentId:       resp.FragmentId,
+			ParentFragmentId: resp.ParentFragmentId,
+			Tree:             tree,
+		}, nil
+
+	case *experimentalv1.ReflectPermissionSubjectTypesResponse:
+		subjectTypes := make([]*experimentalv1.PermissionSubjectType, 0, len(resp.SubjectTypes))
+		for _, subjectType := range resp.SubjectTypes {
+			if allowlist.Allows(subjectType.SubjectObjectType) {
+				subjectTypes = append(subjectTypes, subjectType)
+			}
+		}
+		return &experimentalv1.ReflectPermissionSubjectTypesResponse{ReadAt: resp.ReadAt, SubjectTypes: subjectTypes}, nil
+
+	case *experimentalv1.ReflectSchemaResponse:
+		// As with ReadSchema, the caveats are all kept.
+		definitions := make([]*experimentalv1.ReflectionDefinition, 0, len(resp.Definitions))
+		for _, definition := range resp.Definitions {
+			if allowlist.Allows(definition.Name) {
+				definitions = append(definitions, definition)
+			}
+		}
+		return &experimentalv1.ReflectSchemaResponse{ReadAt: resp.ReadAt, Definitions: definitions, Caveats: resp.Caveats}, nil
+
+	default:
+		return resp, nil
+	}
+}
+
+// allowsRelationship returns whether the namespaces of both the resource and the subject of the
+// relationship are visible.
+func allowsRelationship(allowlist *Allowlist, relationship *v1.Relationship) bool {
+	return allowlist.Allows(relationship.GetResource().GetObjectType()) && allowlist.Allows(relationship.GetSubject().GetObject().GetObjectType())
+}
+
+// filterTree returns the tree with the subtrees expanding objects of namespaces which are not
+// visible, and the subjects of those namespaces, removed, or nil if the root is not visible.
+func filterTree(allowlist *Allowlist, tree *v1.PermissionRelationshipTree) *v1.PermissionRelationshipTree {
+	if tree == nil || !allowlist.Allows(tree.GetExpandedObject().GetObjectType()) {
+		return nil
+	}
+
+	filtered := &v1.PermissionRelationshipTree{
+		ExpandedObject:   tree.ExpandedObject,
+		ExpandedRelation: tree.ExpandedRelation,
+	}
+
+	switch treeType := tree.TreeType.(type) {
+	case *v1.PermissionRelationshipTree_Intermediate:
+		children := make([]*v1.PermissionRelationshipTree, 0, len(treeType.Intermediate.GetChildren()))
+		for _, child := range treeType.Intermediate.GetChildren() {
+			if filteredChild := filterTree(allowlist, child); filteredChild != nil {
+				children = append(children, filteredChild)
+			}
+		}
+		filtered.TreeType = &v1.PermissionRelationshipTree_Intermediate{
+			Intermediate: &v1.AlgebraicSubjectSet{Operation: treeType.Intermediate.GetOperation(), Children: children},
+		}
+
+	case *v1.PermissionRelationshipTree_Leaf:
+		subjects := make([]*v1.SubjectReference, 0, len(treeType.Leaf.GetSubjects()))
+		for _, subject := range treeType.Leaf.GetSubjects() {
+			if allowlist.Allows(subject.GetObject().GetObjectType()) {
+				subjects = append(subjects, subject)
+			}
+		}
+		filtered.TreeType = &v1.PermissionRelationshipTree_Leaf{Leaf: &v1.DirectSubjectSet{Subjects: subjects}}
+	}
+	return filtered
+}
+
+// filterSchema returns the schema with the definitions of namespaces which are not visible
+// removed. Caveats are not namespaced, and so are always visible.
+func filterSchema(allowlist *Allowlist, schemaText string) (string, error) {
+	emptyDefaultPrefix := ""
+	compiled, err := compiler.Compile(compiler.InputSchema{
+		Source:       input.Source("schema"),
+		SchemaString: schemaText,
+	}, &emptyDefaultPrefix)
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "unable to filter schema: %s", err)
+	}
+
+	definitions := make([]compiler.SchemaDefinition, 0, len(compiled.CaveatDefinitions)+len(compiled.ObjectDefinitions))
+	for _, caveatDef := range compiled.CaveatDefinitions {
+		definitions = append(definitions, caveatDef)
+	}
+
+	visible := 0
+	for _, nsDef := range compiled.ObjectDefinitions {
+		if allowlist.Allows(nsDef.Name) {
+			definitions = append(definitions, nsDef)
+			visible++
+		}
+	}
+
+	if visible == 0 {
+		return "", status.Errorf(codes.NotFound, "No schema has been defined; please call WriteSchema to start")
+	}
+
+	filtered, _, err := generator.GenerateSchema(definitions)
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "unable to filter schema: %s", err)
+	}
+	return filtered, nil
+}
+
+// namespaceOf returns the namespace of an object in the form `namespace:id`, such as those of
+// templates.
+func namespaceOf(object string) string {
+	namespace, _, _ := strings.Cut(object, ":")
+	return namespace
+}
@@ -0,0 +1,476 @@
+package visibility
+
+import (
+	"context"
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	experimentalv1 "github.com/authzed/spicedb/pkg/proto/experimental/v1"
+)
+
+func object(objectType string) *v1.ObjectReference {
+	return &v1.ObjectReference{ObjectType: objectType, ObjectId: "someid"}
+}
+
+func subject(objectType string) *v1.SubjectReference {
+	return &v1.SubjectReference{Object: object(objectType)}
+}
+
+func relationship(resourceType, subjectType string) *v1.Relationship {
+	return &v1.Relationship{Resource: object(resourceType), Relation: "viewer", Subject: subject(subjectType)}
+}
+
+func TestParseAllowlist(t *testing.T) {
+	allowlist, err := ParseAllowlist([]string{"user", " tenant/* "})
+	require.NoError(t, err)
+
+	require.True(t, allowlist.Allows("user"))
+	require.True(t, allowlist.Allows("tenant/document"))
+	require.False(t, allowlist.Allows("tenant"))
+	require.False(t, allowlist.Allows("users"))
+	require.False(t, allowlist.Allows("other/document"))
+
+	for _, entries := range [][]string{{""}, {"user", " "}, {"tenant*"}, {"*/document"}} {
+		_, err := ParseAllowlist(entries)
+		require.Error(t, err, "expected error for %v", entries)
+	}
+}
+
+func TestCheckRequest(t *testing.T) {
+	allowlist, err := ParseAllowlist([]string{"user", "tenant/*"})
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name    string
+		method  string
+		req     any
+		allowed bool
+	}{
+		{"check", "", &v1.CheckPermissionRequest{Resource: object("tenant/document"), Subject: subject("user")}, true},
+		{"check of other resource", "", &v1.CheckPermissionRequest{Resource: object("document"), Subject: subject("user")}, false},
+		{"check of other subject", "", &v1.CheckPermissionRequest{Resource: object("tenant/document"), Subject: subject("team")}, false},
+		{"expand", "", &v1.ExpandPermissionTreeRequest{Resource: object("tenant/document")}, true},
+		{"expand of other resource", "", &v1.ExpandPermissionTreeRequest{Resource: object("document")}, false},
+		{"lookup resources", "", &v1.LookupResourcesRequest{ResourceObjectType: "tenant/document", Subject: subject("user")}, true},
+		{"lookup other resources", "", &v1.LookupResourcesRequest{ResourceObjectType: "document", Subject: subject("user")}, false},
+		{"lookup resources of other subject", "", &v1.LookupResourcesRequest{ResourceObjectType: "tenant/document", Subject: subject("team")}, false},
+		{"lookup subjects", "", &v1.LookupSubjectsRequest{Resource: object("tenant/document"), SubjectObjectType: "user"}, true},
+		{"lookup other subjects", "", &v1.LookupSubjectsRequest{Resource: object("tenant/document"), SubjectObjectType: "team"}, false},
+		{"read relationships", "", &v1.ReadRelationshipsRequest{RelationshipFilter: &v1.RelationshipFilter{ResourceType: "tenant/document"}}, true},
+		{"read other relationships", "", &v1.ReadRelationshipsRequest{RelationshipFilter: &v1.RelationshipFilter{ResourceType: "document"}}, false},
+		{
+			"read relationships of other subjects", "",
+			&v1.ReadRelationshipsRequest{RelationshipFilter: &v1.RelationshipFilter{
+				ResourceType:          "tenant/document",
+				OptionalSubjectFilter: &v1.SubjectFilter{SubjectType: "team"},
+			}},
+			false,
+		},
+		{
+			"write relationships", "",
+			&v1.WriteRelationshipsRequest{Updates: []*v1.RelationshipUpdate{
+				{Operation: v1.RelationshipUpdate_OPERATION_TOUCH, Relationship: relationship("tenant/document", "user")},
+			}},
+			true,
+		},
+		{
+			"write other relationships", "",
+			&v1.WriteRelationshipsRequest{Updates: []*v1.RelationshipUpdate{
+				{Operation: v1.RelationshipUpdate_OPERATION_TOUCH, Relationship: relationship("tenant/document", "user")},
+				{Operation: v1.RelationshipUpdate_OPERATION_TOUCH, Relationship: relationship("document", "user")},
+			}},
+			false,
+		},
+		{
+			"write relationships with other precondition", "",
+			&v1.WriteRelationshipsRequest{
+				Updates: []*v1.RelationshipUpdate{
+					{Operation: v1.RelationshipUpdate_OPERATION_TOUCH, Relationship: relationship("tenant/document", "user")},
+				},
+				OptionalPreconditions: []*v1.Precondition{
+					{Operation: v1.Precondition_OPERATION_MUST_MATCH, Filter: &v1.RelationshipFilter{ResourceType: "document"}},
+				},
+			},
+			false,
+		},
+		{"delete relationships", "", &v1.DeleteRelationshipsRequest{RelationshipFilter: &v1.RelationshipFilter{ResourceType: "tenant/document"}}, true},
+		{"delete other relationships", "", &v1.DeleteRelationshipsRequest{RelationshipFilter: &v1.RelationshipFilter{ResourceType: "document"}}, false},
+		{
+			"delete relationships with other precondition", "",
+			&v1.DeleteRelationshipsRequest{
+				RelationshipFilter: &v1.RelationshipFilter{ResourceType: "tenant/document"},
+				OptionalPreconditions: []*v1.Precondition{
+					{Operation: v1.Precondition_OPERATION_MUST_NOT_MATCH, Filter: &v1.RelationshipFilter{ResourceType: "document"}},
+				},
+			},
+			false,
+		},
+		{"read schema", "", &v1.ReadSchemaRequest{}, true},
+		{"write schema", "", &v1.WriteSchemaRequest{Schema: "definition tenant/document {}"}, false},
+		{"watch", "", &v1.WatchRequest{OptionalObjectTypes: []string{"tenant/document", "user"}}, true},
+		{"watch of other namespaces", "", &v1.WatchRequest{OptionalObjectTypes: []string{"tenant/document", "document"}}, false},
+		{
+			"check for subjects", "",
+			&experimentalv1.CheckPermissionForSubjectsRequest{Resource: object("tenant/document"), Subjects: []*v1.SubjectReference{subject("user")}},
+			true,
+		},
+		{
+			"check for other subjects", "",
+			&experimentalv1.CheckPermissionForSubjectsRequest{Resource: object("tenant/document"), Subjects: []*v1.SubjectReference{subject("user"), subject("team")}},
+			false,
+		},
+		{"check for resources", "", &experimentalv1.CheckPermissionForResourcesRequest{ResourceObjectType: "tenant/document", Subject: subject("user")}, true},
+		{"check for other resources", "", &experimentalv1.CheckPermissionForResourcesRequest{ResourceObjectType: "document", Subject: subject("user")}, false},
+		{"unknown template", "", &experimentalv1.CheckTemplateRequest{Name: "unknown"}, true},
+		{"stream expand", "", &experimentalv1.StreamExpandPermissionTreeRequest{Resource: object("tenant/document")}, true},
+		{"stream expand of other resource", "", &experimentalv1.StreamExpandPermissionTreeRequest{Resource: object("document")}, false},
+		{"lookup materialized", "", &experimentalv1.LookupMaterializedResourcesRequest{ResourceObjectType: "tenant/document", Subject: object("user")}, true},
+		{"lookup materialized of other subject", "", &experimentalv1.LookupMaterializedResourcesRequest{ResourceObjectType: "tenant/document", Subject: object("team")}, false},
+		{"reflect", "", &experimentalv1.ReflectPermissionSubjectTypesRequest{ResourceObjectType: "tenant/document"}, true},
+		{"reflect of other resource", "", &experimentalv1.ReflectPermissionSubjectTypesRequest{ResourceObjectType: "document"}, false},
+		{"reflect schema", "", &experimentalv1.ReflectSchemaRequest{}, true},
+		{"format schema", "", &experimentalv1.FormatSchemaRequest{Schema: "definition document {}"}, true},
+		{"read relationships at", "", &experimentalv1.ReadRelationshipsAtRequest{RelationshipFilter: &v1.RelationshipFilter{ResourceType: "tenant/document"}}, true},
+		{"read other relationships at", "", &experimentalv1.ReadRelationshipsAtRequest{RelationshipFilter: &v1.RelationshipFilter{ResourceType: "document"}}, false},
+		{"read relationship changes", "", &experimentalv1.ReadRelationshipChangesRequest{RelationshipFilter: &v1.RelationshipFilter{ResourceType: "tenant/document"}}, true},
+		{
+			"read relationship changes of other subjects", "",
+			&experimentalv1.ReadRelationshipChangesRequest{RelationshipFilter: &v1.RelationshipFilter{
+				ResourceType:          "tenant/document",
+				OptionalSubjectFilter: &v1.SubjectFilter{SubjectType: "team"},
+			}},
+			false,
+		},
+		{
+			"batch write", "",
+			&experimentalv1.BatchWriteRelationshipsRequest{Updates: []*v1.RelationshipUpdate{
+				{Operation: v1.RelationshipUpdate_OPERATION_TOUCH, Relationship: relationship("tenant/document", "user")},
+			}},
+			true,
+		},
+		{
+			"batch write of other resource", "",
+			&experimentalv1.BatchWriteRelationshipsRequest{Updates: []*v1.RelationshipUpdate{
+				{Operation: v1.RelationshipUpdate_OPERATION_TOUCH, Relationship: relationship("tenant/document", "user")},
+				{Operation: v1.RelationshipUpdate_OPERATION_TOUCH, Relationship: relationship("document", "user")},
+			}},
+			false,
+		},
+		{"check at time", "", &experimentalv1.CheckPermissionAtTimeRequest{Resource: object("tenant/document"), Subject: subject("user")}, true},
+		{"check at time of other subject", "", &experimentalv1.CheckPermissionAtTimeRequest{Resource: object("tenant/document"), Subject: subject("team")}, false},
+		{
+			"contextual check", "",
+			&experimentalv1.ContextualCheckPermissionRequest{
+				Resource:                object("tenant/document"),
+				Subject:                 subject("user"),
+				ContextualRelationships: []*v1.Relationship{relationship("tenant/document", "user")},
+			},
+			true,
+		},
+		{
+			"contextual check with other relationship", "",
+			&experimentalv1.ContextualCheckPermissionRequest{
+				Resource:                object("tenant/document"),
+				Subject:                 subject("user"),
+				ContextualRelationships: []*v1.Relationship{relationship("tenant/document", "team")},
+			},
+			false,
+		},
+		{"contextual lookup resources", "", &experimentalv1.ContextualLookupResourcesRequest{ResourceObjectType: "tenant/document", Subject: subject("user")}, true},
+		{"contextual lookup of other resources", "", &experimentalv1.ContextualLookupResourcesRequest{ResourceObjectType: "document", Subject: subject("user")}, false},
+		{"contextual lookup subjects", "", &experimentalv1.ContextualLookupSubjectsRequest{Resource: object("tenant/document"), SubjectObjectType: "user"}, true},
+		{
+			"contextual lookup subjects with other relationship", "",
+			&experimentalv1.ContextualLookupSubjectsRequest{
+				Resource:                object("tenant/document"),
+				SubjectObjectType:       "user",
+				ContextualRelationships: []*v1.Relationship{relationship("document", "user")},
+			},
+			false,
+		},
+		{
+			"what if", "",
+			&experimentalv1.WhatIfRequest{
+				Updates: []*v1.RelationshipUpdate{
+					{Operation: v1.RelationshipUpdate_OPERATION_DELETE, Relationship: relationship("tenant/document", "user")},
+				},
+				Probes: []*experimentalv1.WhatIfProbe{{Resource: object("tenant/document"), Subject: subject("user")}},
+			},
+			true,
+		},
+		{
+			"what if of other subject", "",
+			&experimentalv1.WhatIfRequest{
+				Probes: []*experimentalv1.WhatIfProbe{
+					{Resource: object("tenant/document"), Subject: subject("user")},
+					{Resource: object("tenant/document"), Subject: subject("team")},
+				},
+			},
+			false,
+		},
+		{
+			"what if with candidate schema", "",
+			&experimentalv1.WhatIfRequest{
+				Schema: "definition tenant/document {}",
+				Probes: []*experimentalv1.WhatIfProbe{{Resource: object("tenant/document"), Subject: subject("user")}},
+			},
+			false,
+		},
+		{"count accessible resources", "", &experimentalv1.CountAccessibleResourcesRequest{ResourceObjectType: "tenant/document", Subject: subject("user")}, true},
+		{"count other accessible resources", "", &experimentalv1.CountAccessibleResourcesRequest{ResourceObjectType: "document", Subject: subject("user")}, false},
+		{"health", "/grpc.health.v1.Health/Check", &healthpb.HealthCheckRequest{}, true},
+		{"other methods", "/experimental.v1.ExperimentalService/ListSchemaVersions", &experimentalv1.ListSchemaVersionsRequest{}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkRequest(allowlist, tc.method, tc.req)
+			if tc.allowed {
+				require.NoError(t, err)
+			} else {
+				require.Equal(t, codes.PermissionDenied, status.Code(err))
+			}
+		})
+	}
+}
+
+func TestFilterResponse(t *testing.T) {
+	allowlist, err := ParseAllowlist([]string{"user", "tenant/*"})
+	require.NoError(t, err)
+
+	t.Run("expand", func(t *testing.T) {
+		resp, err := filterResponse(allowlist, &v1.ExpandPermissionTreeResponse{
+			TreeRoot: &v1.PermissionRelationshipTree{
+				ExpandedObject:   object("tenant/document"),
+				ExpandedRelation: "view",
+				TreeType: &v1.PermissionRelationshipTree_Intermediate{Intermediate: &v1.AlgebraicSubjectSet{
+					Operation: v1.AlgebraicSubjectSet_OPERATION_UNION,
+					Children: []*v1.PermissionRelationshipTree{
+						{
+							ExpandedObject:   object("tenant/document"),
+							ExpandedRelation: "viewer",
+							TreeType: &v1.PermissionRelationshipTree_Leaf{Leaf: &v1.DirectSubjectSet{
+								Subjects: []*v1.SubjectReference{subject("user"), subject("team")},
+							}},
+						},
+						{
+							ExpandedObject:   object("team"),
+							ExpandedRelation: "member",
+							TreeType:         &v1.PermissionRelationshipTree_Leaf{Leaf: &v1.DirectSubjectSet{Subjects: []*v1.SubjectReference{subject("user")}}},
+						},
+					},
+				}},
+			},
+		})
+		require.NoError(t, err)
+
+		children := resp.(*v1.ExpandPermissionTreeResponse).TreeRoot.GetIntermediate().GetChildren()
+		require.Len(t, children, 1)
+		require.Equal(t, []*v1.SubjectReference{subject("user")}, children[0].GetLeaf().GetSubjects())
+	})
+
+	t.Run("stream expand", func(t *testing.T) {
+		resp, err := filterResponse(allowlist, &experimentalv1.StreamExpandPermissionTreeResponse{
+			Tree: &v1.PermissionRelationshipTree{ExpandedObject: object("team"), ExpandedRelation: "member"},
+		})
+		require.NoError(t, err)
+		require.Nil(t, resp)
+	})
+
+	t.Run("read relationships", func(t *testing.T) {
+		visible := &v1.ReadRelationshipsResponse{Relationship: relationship("tenant/document", "user")}
+		resp, err := filterResponse(allowlist, visible)
+		require.NoError(t, err)
+		require.Equal(t, visible, resp)
+
+		resp, err = filterResponse(allowlist, &v1.ReadRelationshipsResponse{Relationship: relationship("tenant/document", "team")})
+		require.NoError(t, err)
+		require.Nil(t, resp)
+	})
+
+	t.Run("read relationships at", func(t *testing.T) {
+		visible := &experimentalv1.ReadRelationshipsAtResponse{Relationship: relationship("tenant/document", "user")}
+		resp, err := filterResponse(allowlist, visible)
+		require.NoError(t, err)
+		require.Equal(t, visible, resp)
+
+		resp, err = filterResponse(allowlist, &experimentalv1.ReadRelationshipsAtResponse{Relationship: relationship("document", "user")})
+		require.NoError(t, err)
+		require.Nil(t, resp)
+	})
+
+	t.Run("read relationship changes", func(t *testing.T) {
+		visible := &experimentalv1.ReadRelationshipChangesResponse{Update: &v1.RelationshipUpdate{Relationship: relationship("tenant/document", "user")}}
+		resp, err := filterResponse(allowlist, visible)
+		require.NoError(t, err)
+		require.Equal(t, visible, resp)
+
+		resp, err = filterResponse(allowlist, &experimentalv1.ReadRelationshipChangesResponse{Update: &v1.RelationshipUpdate{Relationship: relationship("tenant/document", "team")}})
+		require.NoError(t, err)
+		require.Nil(t, resp)
+	})
+
+	t.Run("read schema", func(t *testing.T) {
+		resp, err := filterResponse(allowlist, &v1.ReadSchemaResponse{SchemaText: `
+			caveat only_on_tuesday(day_of_week string) {
+				day_of_week == 'tuesday'
+			}
+
+			definition user {}
+
+			definition team {
+				relation member: user
+			}
+
+			definition tenant/document {
+				relation viewer: user with only_on_tuesday
+			}`,
+		})
+		require.NoError(t, err)
+
+		schemaText := resp.(*v1.ReadSchemaResponse).SchemaText
+		require.Contains(t, schemaText, "caveat only_on_tuesday")
+		require.Contains(t, schemaText, "definition user")
+		require.Contains(t, schemaText, "definition tenant/document")
+		require.NotContains(t, schemaText, "definition team")
+
+		_, err = filterResponse(allowlist, &v1.ReadSchemaResponse{SchemaText: "definition team {}"})
+		require.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("watch", func(t *testing.T) {
+		resp, err := filterResponse(allowlist, &v1.WatchResponse{Updates: []*v1.RelationshipUpdate{
+			{Operation: v1.RelationshipUpdate_OPERATION_TOUCH, Relationship: relationship("tenant/document", "user")},
+			{Operation: v1.RelationshipUpdate_OPERATION_TOUCH, Relationship: relationship("document", "user")},
+		}})
+		require.NoError(t, err)
+		require.Len(t, resp.(*v1.WatchResponse).Updates, 1)
+
+		resp, err = filterResponse(allowlist, &v1.WatchResponse{Updates: []*v1.RelationshipUpdate{
+			{Operation: v1.RelationshipUpdate_OPERATION_DELETE, Relationship: relationship("document", "user")},
+		}})
+		require.NoError(t, err)
+		require.Nil(t, resp)
+	})
+
+	t.Run("reflect", func(t *testing.T) {
+		resp, err := filterResponse(allowlist, &experimentalv1.ReflectPermissionSubjectTypesResponse{
+			SubjectTypes: []*experimentalv1.PermissionSubjectType{
+				{SubjectObjectType: "user", Concrete: true},
+				{SubjectObjectType: "team", OptionalSubjectRelation: "member"},
+			},
+		})
+		require.NoError(t, err)
+
+		subjectTypes := resp.(*experimentalv1.ReflectPermissionSubjectTypesResponse).SubjectTypes
+		require.Len(t, subjectTypes, 1)
+		require.Equal(t, "user", subjectTypes[0].SubjectObjectType)
+	})
+
+	t.Run("reflect schema", func(t *testing.T) {
+		resp, err := filterResponse(allowlist, &experimentalv1.ReflectSchemaResponse{
+			Definitions: []*experimentalv1.ReflectionDefinition{{Name: "team"}, {Name: "tenant/document"}, {Name: "user"}},
+			Caveats:     []*experimentalv1.ReflectionCaveat{{Name: "only_on_tuesday"}},
+		})
+		require.NoError(t, err)
+
+		reflected := resp.(*experimentalv1.ReflectSchemaResponse)
+		require.Len(t, reflected.Definitions, 2)
+		require.Equal(t, "tenant/document", reflected.Definitions[0].Name)
+		require.Equal(t, "user", reflected.Definitions[1].Name)
+		require.Len(t, reflected.Caveats, 1)
+	})
+}
+
+func withToken(token string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "bearer "+token))
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	allowlist, err := ParseAllowlist([]string{"user", "tenant/*"})
+	require.NoError(t, err)
+
+	interceptor := UnaryServerInterceptor(TokenAllowlists{"restricted": allowlist})
+	info := &grpc.UnaryServerInfo{FullMethod: "/authzed.api.v1.PermissionsService/CheckPermission"}
+	req := &v1.CheckPermissionRequest{Resource: object("document"), Subject: subject("user")}
+
+	for _, tc := range []struct {
+		name         string
+		ctx          context.Context
+		expectedCode codes.Code
+	}{
+		{"no token", context.Background(), codes.OK},
+		{"unrestricted token", withToken("unrestricted"), codes.OK},
+		{"restricted token", withToken("restricted"), codes.PermissionDenied},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			called := false
+			_, err := interceptor(tc.ctx, req, info, func(ctx context.Context, req any) (any, error) {
+				called = true
+				return &v1.CheckPermissionResponse{}, nil
+			})
+			require.Equal(t, tc.expectedCode, status.Code(err))
+			require.Equal(t, tc.expectedCode == codes.OK, called)
+		})
+	}
+}
+
+type testStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	req  proto.Message
+	sent []any
+}
+
+func (s *testStream) Context() context.Context { return s.ctx }
+
+func (s *testStream) RecvMsg(m any) error {
+	proto.Merge(m.(proto.Message), s.req)
+	return nil
+}
+
+func (s *testStream) SendMsg(m any) error {
+	s.sent = append(s.sent, m)
+	return nil
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	allowlist, err := ParseAllowlist([]string{"user", "tenant/*"})
+	require.NoError(t, err)
+
+	interceptor := StreamServerInterceptor(TokenAllowlists{"restricted": allowlist})
+	info := &grpc.StreamServerInfo{FullMethod: "/authzed.api.v1.PermissionsService/ReadRelationships", IsServerStream: true}
+	handler := func(srv any, stream grpc.ServerStream) error {
+		req := &v1.ReadRelationshipsRequest{}
+		if err := stream.RecvMsg(req); err != nil {
+			return err
+		}
+		for _, rel := range []*v1.Relationship{relationship("tenant/document", "user"), relationship("tenant/document", "team")} {
+			if err := stream.SendMsg(&v1.ReadRelationshipsResponse{Relationship: rel}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	allowedReq := &v1.ReadRelationshipsRequest{RelationshipFilter: &v1.RelationshipFilter{ResourceType: "tenant/document"}}
+	deniedReq := &v1.ReadRelationshipsRequest{RelationshipFilter: &v1.RelationshipFilter{ResourceType: "document"}}
+
+	stream := &testStream{ctx: withToken("unrestricted"), req: deniedReq}
+	require.NoError(t, interceptor(nil, stream, info, handler))
+	require.Len(t, stream.sent, 2)
+
+	stream = &testStream{ctx: withToken("restricted"), req: allowedReq}
+	require.NoError(t, interceptor(nil, stream, info, handler))
+	require.Len(t, stream.sent, 1)
+
+	stream = &testStream{ctx: withToken("restricted"), req: deniedReq}
+	require.Equal(t, codes.PermissionDenied, status.Code(interceptor(nil, stream, info, handler)))
+	require.Empty(t, stream.sent)
+}
@@ -2,7 +2,11 @@ package namespace
 
 import (
 	"encoding/hex"
+	"fmt"
 	"hash/fnv"
+	"io"
+	"sort"
+	"strings"
 
 	"github.com/authzed/spicedb/pkg/spiceerrors"
 
@@ -54,6 +58,14 @@ const computedKeyPrefix = "%"
 // canonical representation of the binary expression. These hashes can then be used for caching,
 // representing the same *logical* expressions for a permission, even if the relations have
 // different names.
+//
+// So that the keys are stable across schema writes which only reorder the definition, the indexes
+// are assigned in the order of the names of the relations and arrows, rather than that in which
+// they are declared, each rewrite is converted from its canonical form (see canonicalizeRewrite),
+// and the nodes of each BDD are numbered in the order in which they are reached from its root,
+// rather than by their IDs, which depend on the nodes built for the other permissions. The nodes
+// are written with the (alias-resolved) names of their variables rather than their indexes, so
+// that the key of a permission does not change when unrelated relations are added or removed.
 func computeCanonicalCacheKeys(typeSystem *ValidatedNamespaceTypeSystem, aliasMap map[string]string) (map[string]string, error) {
 	varMap, err := buildBddVarMap(typeSystem.nsDef.Relation, aliasMap)
 	if err != nil {
@@ -81,18 +93,192 @@ func computeCanonicalCacheKeys(typeSystem *ValidatedNamespaceTypeSystem, aliasMa
 		}
 
 		hasher := fnv.New64a()
-		node, err := convertRewriteToBdd(rel, bdd, rewrite, varMap)
+		node, err := convertRewriteToBdd(rel, bdd, canonicalizeRewrite(rewrite), varMap)
 		if err != nil {
 			return nil, err
 		}
 
-		bdd.Print(hasher, node)
+		if err := writeBdd(hasher, bdd, node, varMap); err != nil {
+			return nil, err
+		}
 		cacheKeys[rel.Name] = computedKeyPrefix + hex.EncodeToString(hasher.Sum(nil))
 	}
 
 	return cacheKeys, nil
 }
 
+// writeBdd writes the nodes of the BDD reachable from the node, numbered in the order in which
+// they are reached from it, each with the name of its variable rather than its index.
+func writeBdd(w io.Writer, bdd *rudd.BDD, node rudd.Node, varMap bddVarMap) error {
+	type bddNode struct {
+		level, low, high int
+	}
+
+	nodes := make(map[int]bddNode)
+	err := bdd.Allnodes(func(id, level, low, high int) error {
+		nodes[id] = bddNode{level, low, high}
+		return nil
+	}, node)
+	if err != nil {
+		return err
+	}
+
+	// The IDs of the terminal nodes, false and true, are fixed.
+	numbers := map[int]int{0: 0, 1: 1}
+	var visit func(id int) error
+	visit = func(id int) error {
+		if _, ok := numbers[id]; ok {
+			return nil
+		}
+
+		n := nodes[id]
+		if err := visit(n.low); err != nil {
+			return err
+		}
+		if err := visit(n.high); err != nil {
+			return err
+		}
+
+		numbers[id] = len(numbers)
+		_, err := fmt.Fprintf(w, "%d [%s] ? %d : %d\n", numbers[id], varMap.Name(n.level), numbers[n.low], numbers[n.high])
+		return err
+	}
+	if err := visit(*node); err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "root %d\n", numbers[*node])
+	return err
+}
+
+// canonicalizeRewrite returns the canonical form of the rewrite, in which nested unions and
+// intersections are flattened into their parent operation, as are exclusions nested as the first
+// child of an exclusion, and the children of unions and intersections, and those after the first
+// of exclusions, are deduplicated and sorted. Rewrites which differ only in the order of their
+// commutative operands, or in the nesting of associative operations, have the same canonical
+// form. The rewrite itself is left unchanged.
+func canonicalizeRewrite(rewrite *core.UsersetRewrite) *core.UsersetRewrite {
+	switch rw := rewrite.RewriteOperation.(type) {
+	case *core.UsersetRewrite_Union:
+		children := canonicalizeChildren(rw.Union.Child, func(child *core.UsersetRewrite) *core.SetOperation {
+			return child.GetUnion()
+		})
+		return &core.UsersetRewrite{
+			RewriteOperation: &core.UsersetRewrite_Union{Union: &core.SetOperation{Child: sortChildren(children)}},
+		}
+
+	case *core.UsersetRewrite_Intersection:
+		children := canonicalizeChildren(rw.Intersection.Child, func(child *core.UsersetRewrite) *core.SetOperation {
+			return child.GetIntersection()
+		})
+		return &core.UsersetRewrite{
+			RewriteOperation: &core.UsersetRewrite_Intersection{Intersection: &core.SetOperation{Child: sortChildren(children)}},
+		}
+
+	case *core.UsersetRewrite_Exclusion:
+		if len(rw.Exclusion.Child) == 0 {
+			return rewrite
+		}
+
+		// (a - b) - c is a - b - c, whereas a - (b - c) is not.
+		first := canonicalizeChildren(rw.Exclusion.Child[:1], func(child *core.UsersetRewrite) *core.SetOperation {
+			return child.GetExclusion()
+		})
+		rest := canonicalizeChildren(rw.Exclusion.Child[1:], func(*core.UsersetRewrite) *core.SetOperation {
+			return nil
+		})
+		children := append(first[:1:1], sortChildren(append(first[1:], rest...))...)
+		return &core.UsersetRewrite{
+			RewriteOperation: &core.UsersetRewrite_Exclusion{Exclusion: &core.SetOperation{Child: children}},
+		}
+
+	default:
+		return rewrite
+	}
+}
+
+// canonicalizeChildren canonicalizes the nested rewrites of the children, replacing those of
+// the same operation, as returned by sameOperation, by their own children.
+func canonicalizeChildren(children []*core.SetOperation_Child, sameOperation func(*core.UsersetRewrite) *core.SetOperation) []*core.SetOperation_Child {
+	canonicalized := make([]*core.SetOperation_Child, 0, len(children))
+	for _, child := range children {
+		nested := child.GetUsersetRewrite()
+		if nested == nil {
+			canonicalized = append(canonicalized, child)
+			continue
+		}
+
+		nested = canonicalizeRewrite(nested)
+		if so := sameOperation(nested); so != nil {
+			canonicalized = append(canonicalized, so.Child...)
+			continue
+		}
+
+		canonicalized = append(canonicalized, &core.SetOperation_Child{
+			ChildType: &core.SetOperation_Child_UsersetRewrite{UsersetRewrite: nested},
+		})
+	}
+	return canonicalized
+}
+
+// sortChildren sorts and deduplicates the children of a commutative operation.
+func sortChildren(children []*core.SetOperation_Child) []*core.SetOperation_Child {
+	keyed := make(map[string]*core.SetOperation_Child, len(children))
+	keys := make([]string, 0, len(children))
+	for _, child := range children {
+		key := childKey(child)
+		if _, ok := keyed[key]; !ok {
+			keyed[key] = child
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	sorted := make([]*core.SetOperation_Child, 0, len(keys))
+	for _, key := range keys {
+		sorted = append(sorted, keyed[key])
+	}
+	return sorted
+}
+
+// childKey returns a string identifying the child of a canonicalized rewrite, by which children
+// are sorted.
+func childKey(child *core.SetOperation_Child) string {
+	switch c := child.ChildType.(type) {
+	case *core.SetOperation_Child_XThis:
+		return "_this"
+	case *core.SetOperation_Child_XNil:
+		return "nil"
+	case *core.SetOperation_Child_ComputedUserset:
+		return "cu:" + c.ComputedUserset.Relation
+	case *core.SetOperation_Child_TupleToUserset:
+		return "ttu:" + c.TupleToUserset.Tupleset.Relation + "->" + c.TupleToUserset.ComputedUserset.Relation
+	case *core.SetOperation_Child_UsersetRewrite:
+		return "rewrite:" + rewriteKey(c.UsersetRewrite)
+	default:
+		return fmt.Sprintf("%T", c)
+	}
+}
+
+func rewriteKey(rewrite *core.UsersetRewrite) string {
+	var operation string
+	var children []*core.SetOperation_Child
+	switch rw := rewrite.RewriteOperation.(type) {
+	case *core.UsersetRewrite_Union:
+		operation, children = "union", rw.Union.Child
+	case *core.UsersetRewrite_Intersection:
+		operation, children = "intersection", rw.Intersection.Child
+	case *core.UsersetRewrite_Exclusion:
+		operation, children = "exclusion", rw.Exclusion.Child
+	}
+
+	keys := make([]string, 0, len(children))
+	for _, child := range children {
+		keys = append(keys, childKey(child))
+	}
+	return operation + "(" + strings.Join(keys, ",") + ")"
+}
+
 func convertRewriteToBdd(relation *core.Relation, bdd *rudd.BDD, rewrite *core.UsersetRewrite, varMap bddVarMap) (rudd.Node, error) {
 	switch rw := rewrite.RewriteOperation.(type) {
 	case *core.UsersetRewrite_Union:
@@ -164,9 +350,13 @@ func convertToBdd(relation *core.Relation, bdd *rudd.BDD, so *core.SetOperation,
 	return combiner(values...), nil
 }
 
+// nilVarName is the name of the variable for `nil`, which cannot be the name of a relation.
+const nilVarName = "_nil"
+
 type bddVarMap struct {
 	aliasMap map[string]string
 	varMap   map[string]int
+	names    []string
 }
 
 func (bvm bddVarMap) GetArrow(tuplesetName string, relName string) (int, error) {
@@ -194,18 +384,26 @@ func (bvm bddVarMap) Get(relName string) (int, error) {
 	return index, nil
 }
 
+// Name returns the name of the relation or arrow of the variable at the index.
+func (bvm bddVarMap) Name(index int) string {
+	if index == bvm.Nil() {
+		return nilVarName
+	}
+	return bvm.names[index]
+}
+
 func (bvm bddVarMap) Len() int {
 	return len(bvm.varMap) + 1 // +1 for `nil`
 }
 
 func buildBddVarMap(relations []*core.Relation, aliasMap map[string]string) (bddVarMap, error) {
-	varMap := map[string]int{}
+	keys := make(map[string]struct{})
 	for _, rel := range relations {
 		if _, ok := aliasMap[rel.Name]; ok {
 			continue
 		}
 
-		varMap[rel.Name] = len(varMap)
+		keys[rel.Name] = struct{}{}
 
 		rewrite := rel.GetUsersetRewrite()
 		if rewrite == nil {
@@ -215,10 +413,7 @@ func buildBddVarMap(relations []*core.Relation, aliasMap map[string]string) (bdd
 		_, err := graph.WalkRewrite(rewrite, func(childOneof *core.SetOperation_Child) interface{} {
 			switch child := childOneof.ChildType.(type) {
 			case *core.SetOperation_Child_TupleToUserset:
-				key := child.TupleToUserset.Tupleset.Relation + "->" + child.TupleToUserset.ComputedUserset.Relation
-				if _, ok := varMap[key]; !ok {
-					varMap[key] = len(varMap)
-				}
+				keys[child.TupleToUserset.Tupleset.Relation+"->"+child.TupleToUserset.ComputedUserset.Relation] = struct{}{}
 			}
 			return nil
 		})
@@ -226,8 +421,22 @@ func buildBddVarMap(relations []*core.Relation, aliasMap map[string]string) (bdd
 			return bddVarMap{}, err
 		}
 	}
+
+	// Assign the indexes in the order of the keys, so that they do not depend on the order in
+	// which the relations are declared.
+	sortedKeys := make([]string, 0, len(keys))
+	for key := range keys {
+		sortedKeys = append(sortedKeys, key)
+	}
+	sort.Strings(sortedKeys)
+
+	varMap := make(map[string]int, len(sortedKeys))
+	for index, key := range sortedKeys {
+		varMap[key] = index
+	}
 	return bddVarMap{
 		aliasMap: aliasMap,
 		varMap:   varMap,
+		names:    sortedKeys,
 	}, nil
 }
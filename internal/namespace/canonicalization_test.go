@@ -48,8 +48,8 @@ func TestCanonicalization(t *testing.T) {
 			map[string]string{
 				"owner":  "owner",
 				"viewer": "viewer",
-				"edit":   computedKeyPrefix + "596a8660f9a0c085",
-				"view":   computedKeyPrefix + "0cb51da20fc9f20f",
+				"edit":   computedKeyPrefix + "1c3f72476f417638",
+				"view":   computedKeyPrefix + "a96e0749ea564960",
 			},
 		},
 		{
@@ -69,8 +69,8 @@ func TestCanonicalization(t *testing.T) {
 			map[string]string{
 				"owner":      "owner",
 				"viewer":     "viewer",
-				"edit":       computedKeyPrefix + "596a8660f9a0c085",
-				"other_edit": computedKeyPrefix + "596a8660f9a0c085",
+				"edit":       computedKeyPrefix + "1c3f72476f417638",
+				"other_edit": computedKeyPrefix + "1c3f72476f417638",
 			},
 		},
 		{
@@ -90,8 +90,8 @@ func TestCanonicalization(t *testing.T) {
 			map[string]string{
 				"owner":      "owner",
 				"viewer":     "viewer",
-				"edit":       computedKeyPrefix + "596a8660f9a0c085",
-				"other_edit": computedKeyPrefix + "596a8660f9a0c085",
+				"edit":       computedKeyPrefix + "1c3f72476f417638",
+				"other_edit": computedKeyPrefix + "1c3f72476f417638",
 			},
 		},
 		{
@@ -113,8 +113,8 @@ func TestCanonicalization(t *testing.T) {
 			map[string]string{
 				"owner":  "owner",
 				"viewer": "viewer",
-				"first":  computedKeyPrefix + "62152badef526205",
-				"second": computedKeyPrefix + "62152badef526205",
+				"first":  computedKeyPrefix + "a96e0749ea564960",
+				"second": computedKeyPrefix + "a96e0749ea564960",
 			},
 		},
 		{
@@ -139,9 +139,9 @@ func TestCanonicalization(t *testing.T) {
 			map[string]string{
 				"owner":  "owner",
 				"viewer": "viewer",
-				"edit":   computedKeyPrefix + "596a8660f9a0c085",
-				"first":  computedKeyPrefix + "62152badef526205",
-				"second": computedKeyPrefix + "62152badef526205",
+				"edit":   computedKeyPrefix + "1c3f72476f417638",
+				"first":  computedKeyPrefix + "a96e0749ea564960",
+				"second": computedKeyPrefix + "a96e0749ea564960",
 			},
 		},
 		{
@@ -163,8 +163,8 @@ func TestCanonicalization(t *testing.T) {
 			map[string]string{
 				"owner":  "owner",
 				"viewer": "viewer",
-				"first":  computedKeyPrefix + "18cf8af8ff02bad0",
-				"second": computedKeyPrefix + "18cf8af8ff02bad0",
+				"first":  computedKeyPrefix + "21b51ef657d9dc5f",
+				"second": computedKeyPrefix + "21b51ef657d9dc5f",
 			},
 		},
 		{
@@ -186,8 +186,8 @@ func TestCanonicalization(t *testing.T) {
 			map[string]string{
 				"owner":  "owner",
 				"viewer": "viewer",
-				"first":  computedKeyPrefix + "2cd554a00f7f2d94",
-				"second": computedKeyPrefix + "69d4722141f74043",
+				"first":  computedKeyPrefix + "216d92c5f5cd559f",
+				"second": computedKeyPrefix + "32b8744ba30fee7f",
 			},
 		},
 		{
@@ -213,10 +213,10 @@ func TestCanonicalization(t *testing.T) {
 			map[string]string{
 				"owner":     "owner",
 				"viewer":    "viewer",
-				"first":     computedKeyPrefix + "9fd2b03cabeb2e42",
-				"second":    computedKeyPrefix + "9fd2b03cabeb2e42",
-				"diffrel":   computedKeyPrefix + "ab86f3a255f31908",
-				"difftuple": computedKeyPrefix + "dddc650e89a7bf1a",
+				"first":     computedKeyPrefix + "11dec459981bdb4d",
+				"second":    computedKeyPrefix + "11dec459981bdb4d",
+				"diffrel":   computedKeyPrefix + "68727f7b6e491a1a",
+				"difftuple": computedKeyPrefix + "9f7f7628de1b1c20",
 			},
 		},
 		{
@@ -250,8 +250,8 @@ func TestCanonicalization(t *testing.T) {
 				"owner":  "owner",
 				"editor": "editor",
 				"viewer": "viewer",
-				"first":  computedKeyPrefix + "4c49627fbdbaf248",
-				"second": computedKeyPrefix + "4c49627fbdbaf248",
+				"first":  computedKeyPrefix + "fa97dc4f6d495929",
+				"second": computedKeyPrefix + "fa97dc4f6d495929",
 			},
 		},
 		{
@@ -285,8 +285,8 @@ func TestCanonicalization(t *testing.T) {
 				"owner":  "owner",
 				"editor": "editor",
 				"viewer": "viewer",
-				"first":  computedKeyPrefix + "7c52666bb7593f0a",
-				"second": computedKeyPrefix + "7c52666bb7593f0a",
+				"first":  computedKeyPrefix + "c5fa9b0dbb7a9abf",
+				"second": computedKeyPrefix + "c5fa9b0dbb7a9abf",
 			},
 		},
 		{
@@ -320,8 +320,8 @@ func TestCanonicalization(t *testing.T) {
 				"owner":  "owner",
 				"editor": "editor",
 				"viewer": "viewer",
-				"first":  computedKeyPrefix + "bb955307170373ae",
-				"second": computedKeyPrefix + "6ccf7bece2e540a1",
+				"first":  computedKeyPrefix + "0e065b52e0b91c7f",
+				"second": computedKeyPrefix + "218040e48fb62a1f",
 			},
 		},
 		{
@@ -345,8 +345,8 @@ func TestCanonicalization(t *testing.T) {
 				"owner":  "owner",
 				"editor": "editor",
 				"viewer": "viewer",
-				"first":  computedKeyPrefix + "95f5633117d42867",
-				"second": computedKeyPrefix + "f786018d066f37b4",
+				"first":  computedKeyPrefix + "1a5089f754f57c46",
+				"second": computedKeyPrefix + "4bd8d63bde02206f",
 			},
 		},
 		{
@@ -370,8 +370,8 @@ func TestCanonicalization(t *testing.T) {
 				"owner":  "owner",
 				"editor": "editor",
 				"viewer": "viewer",
-				"first":  computedKeyPrefix + "bfc8d945d7030961",
-				"second": computedKeyPrefix + "bfc8d945d7030961",
+				"first":  computedKeyPrefix + "4bd8d63bde02206f",
+				"second": computedKeyPrefix + "4bd8d63bde02206f",
 			},
 		},
 	}
@@ -500,6 +500,30 @@ func TestCanonicalizationComparison(t *testing.T) {
 			"(owner & nil) & editor",
 			true,
 		},
+		{
+			"excluded operand commutativity",
+			"viewer - owner - editor",
+			"viewer - editor - owner",
+			true,
+		},
+		{
+			"nested exclusion associativity",
+			"(viewer - owner) - editor",
+			"viewer - editor - owner",
+			true,
+		},
+		{
+			"union idempotence",
+			"viewer + owner + viewer",
+			"owner + viewer",
+			true,
+		},
+		{
+			"union absorption",
+			"viewer + (viewer & owner)",
+			"viewer + viewer",
+			true,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -537,3 +561,131 @@ func TestCanonicalizationComparison(t *testing.T) {
 		})
 	}
 }
+
+func canonicalCacheKeys(t *testing.T, schemaText string) map[string]string {
+	require := require.New(t)
+
+	ds, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	require.NoError(err)
+
+	empty := ""
+	compiled, err := compiler.Compile(compiler.InputSchema{
+		Source:       input.Source("schema"),
+		SchemaString: schemaText,
+	}, &empty)
+	require.NoError(err)
+
+	lastRevision, err := ds.HeadRevision(context.Background())
+	require.NoError(err)
+
+	ts, err := NewNamespaceTypeSystem(compiled.ObjectDefinitions[0], ResolverForDatastoreReader(ds.SnapshotReader(lastRevision)))
+	require.NoError(err)
+
+	vts, err := ts.Validate(context.Background())
+	require.NoError(err)
+
+	aliases, err := computePermissionAliases(vts)
+	require.NoError(err)
+
+	cacheKeys, err := computeCanonicalCacheKeys(vts, aliases)
+	require.NoError(err)
+	return cacheKeys
+}
+
+func TestCanonicalizationStableAcrossReordering(t *testing.T) {
+	original := canonicalCacheKeys(t, `
+		definition document {
+			relation parent: document
+			relation viewer: document
+			relation editor: document
+			relation banned: document
+
+			permission edit = editor - banned
+			permission view = (viewer + edit + parent->view) - banned
+		}
+	`)
+
+	reordered := canonicalCacheKeys(t, `
+		definition document {
+			relation banned: document
+			relation editor: document
+			relation viewer: document
+			relation parent: document
+
+			permission view = (parent->view + (edit + viewer)) - banned
+			permission edit = editor - banned
+		}
+	`)
+
+	require.Equal(t, original, reordered)
+	require.NotEqual(t, original["edit"], original["view"])
+}
+
+func TestCanonicalizationStableAcrossUnrelatedRelations(t *testing.T) {
+	original := canonicalCacheKeys(t, `
+		definition document {
+			relation parent: document
+			relation viewer: document
+			relation editor: document
+
+			permission edit = editor
+			permission view = viewer + edit + parent->view
+		}
+	`)
+
+	// Relations and arrows which sort both before and after those referenced by the permissions.
+	extended := canonicalCacheKeys(t, `
+		definition document {
+			relation parent: document
+			relation viewer: document
+			relation editor: document
+			relation auditor: document
+			relation zone: document
+
+			permission edit = editor
+			permission view = viewer + edit + parent->view
+			permission audit = auditor + zone->audit + parent->audit
+		}
+	`)
+
+	require.Equal(t, original["edit"], extended["edit"])
+	require.Equal(t, original["view"], extended["view"])
+}
+
+func TestCanonicalizeRewrite(t *testing.T) {
+	testCases := []struct {
+		expression string
+		expected   string
+	}{
+		{"viewer", "union(cu:viewer)"},
+		{"viewer + owner", "union(cu:owner,cu:viewer)"},
+		{"owner + (viewer + (editor + owner))", "union(cu:editor,cu:owner,cu:viewer)"},
+		{"viewer & (owner + editor)", "intersection(cu:viewer,rewrite:union(cu:editor,cu:owner))"},
+		{"(owner & editor) & viewer", "intersection(cu:editor,cu:owner,cu:viewer)"},
+		{"(viewer - owner) - (editor + nil)", "exclusion(cu:viewer,cu:owner,rewrite:union(cu:editor,nil))"},
+		{"viewer - (owner - editor)", "exclusion(cu:viewer,rewrite:exclusion(cu:owner,cu:editor))"},
+		{"owner->viewer + viewer", "union(cu:viewer,ttu:owner->viewer)"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expression, func(t *testing.T) {
+			empty := ""
+			compiled, err := compiler.Compile(compiler.InputSchema{
+				Source:       input.Source("schema"),
+				SchemaString: fmt.Sprintf(comparisonSchemaTemplate, tc.expression, "viewer"),
+			}, &empty)
+			require.NoError(t, err)
+
+			var rewrite *core.UsersetRewrite
+			for _, relation := range compiled.ObjectDefinitions[0].Relation {
+				if relation.Name == "first" {
+					rewrite = relation.UsersetRewrite
+				}
+			}
+
+			original := rewriteKey(rewrite)
+			require.Equal(t, tc.expected, rewriteKey(canonicalizeRewrite(rewrite)))
+			require.Equal(t, original, rewriteKey(rewrite), "the rewrite must not be modified")
+		})
+	}
+}
@@ -51,5 +51,33 @@ func ValidateCaveatDefinition(caveat *core.CaveatDefinition) error {
 		}
 	}
 
+	for paramName, defaultValue := range caveat.ParameterDefaults {
+		paramType, ok := caveat.ParameterTypes[paramName]
+		if !ok {
+			return newTypeErrorWithSource(
+				fmt.Errorf("default defined for unknown parameter `%s` for caveat `%s`", paramName, caveat.Name),
+				caveat,
+				paramName,
+			)
+		}
+
+		decoded, err := caveattypes.DecodeParameterType(paramType)
+		if err != nil {
+			return newTypeErrorWithSource(
+				fmt.Errorf("type error for parameter `%s` for caveat `%s`: %w", paramName, caveat.Name, err),
+				caveat,
+				paramName,
+			)
+		}
+
+		if _, err := decoded.ConvertValue(defaultValue.AsInterface()); err != nil {
+			return newTypeErrorWithSource(
+				fmt.Errorf("invalid default for parameter `%s` for caveat `%s`: %w", paramName, caveat.Name, err),
+				caveat,
+				paramName,
+			)
+		}
+	}
+
 	return nil
 }
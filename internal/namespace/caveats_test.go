@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
 
 	"github.com/authzed/spicedb/pkg/caveats"
 	caveattypes "github.com/authzed/spicedb/pkg/caveats/types"
@@ -44,6 +45,30 @@ func TestValidateCaveatDefinition(t *testing.T) {
 			},
 			"could not decode caveat",
 		},
+		{
+			withDefault(ns.MustCaveatDefinition(caveats.MustEnvForVariables(
+				map[string]caveattypes.VariableType{
+					"someCondition": caveattypes.IntType,
+				},
+			), "validdefault", "someCondition == 42"), "someCondition", structpb.NewNumberValue(42)),
+			"",
+		},
+		{
+			withDefault(ns.MustCaveatDefinition(caveats.MustEnvForVariables(
+				map[string]caveattypes.VariableType{
+					"someCondition": caveattypes.IntType,
+				},
+			), "wrongdefault", "someCondition == 42"), "someCondition", structpb.NewStringValue("hello")),
+			"invalid default for parameter `someCondition` for caveat `wrongdefault`",
+		},
+		{
+			withDefault(ns.MustCaveatDefinition(caveats.MustEnvForVariables(
+				map[string]caveattypes.VariableType{
+					"someCondition": caveattypes.IntType,
+				},
+			), "unknowndefault", "someCondition == 42"), "another", structpb.NewNumberValue(42)),
+			"default defined for unknown parameter `another` for caveat `unknowndefault`",
+		},
 	}
 
 	for _, tc := range tcs {
@@ -58,3 +83,8 @@ func TestValidateCaveatDefinition(t *testing.T) {
 		})
 	}
 }
+
+func withDefault(caveat *core.CaveatDefinition, paramName string, value *structpb.Value) *core.CaveatDefinition {
+	caveat.ParameterDefaults = map[string]*structpb.Value{paramName: value}
+	return caveat
+}
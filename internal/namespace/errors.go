@@ -127,6 +127,50 @@ func (err ErrPermissionUsedOnLeftOfArrow) DetailsMetadata() map[string]string {
 	}
 }
 
+// ErrConstraintOnPermission occurs when a constraint references a permission, which, unlike a
+// relation, has no relationships to constrain.
+type ErrConstraintOnPermission struct {
+	error
+	namespaceName  string
+	permissionName string
+}
+
+// MarshalZerologObject implements zerolog object marshalling.
+func (err ErrConstraintOnPermission) MarshalZerologObject(e *zerolog.Event) {
+	e.Err(err.error).Str("namespace", err.namespaceName).Str("permission", err.permissionName)
+}
+
+// DetailsMetadata returns the metadata for details for this error.
+func (err ErrConstraintOnPermission) DetailsMetadata() map[string]string {
+	return map[string]string{
+		"definition_name": err.namespaceName,
+		"permission_name": err.permissionName,
+	}
+}
+
+// ErrAliasOfAlias occurs when an alias references another alias, rather than the relation or
+// permission which it aliases.
+type ErrAliasOfAlias struct {
+	error
+	namespaceName string
+	aliasName     string
+	targetName    string
+}
+
+// MarshalZerologObject implements zerolog object marshalling.
+func (err ErrAliasOfAlias) MarshalZerologObject(e *zerolog.Event) {
+	e.Err(err.error).Str("namespace", err.namespaceName).Str("alias", err.aliasName).Str("target", err.targetName)
+}
+
+// DetailsMetadata returns the metadata for details for this error.
+func (err ErrAliasOfAlias) DetailsMetadata() map[string]string {
+	return map[string]string{
+		"definition_name": err.namespaceName,
+		"alias_name":      err.aliasName,
+		"target_name":     err.targetName,
+	}
+}
+
 // ErrWildcardUsedInArrow occurs when an arrow operates over a relation that contains a wildcard.
 type ErrWildcardUsedInArrow struct {
 	error
@@ -306,6 +350,25 @@ func NewPermissionUsedOnLeftOfArrowErr(nsName string, parentPermissionName strin
 	}
 }
 
+// NewConstraintOnPermissionErr constructs an error indicating that a constraint references a permission.
+func NewConstraintOnPermissionErr(nsName string, permissionName string) error {
+	return ErrConstraintOnPermission{
+		error:          fmt.Errorf("under definition `%s`: constraints can only reference relations (found permission `%s`)", nsName, permissionName),
+		namespaceName:  nsName,
+		permissionName: permissionName,
+	}
+}
+
+// NewAliasOfAliasErr constructs an error indicating that an alias references another alias.
+func NewAliasOfAliasErr(nsName string, aliasName string, targetName string) error {
+	return ErrAliasOfAlias{
+		error:         fmt.Errorf("under definition `%s`: alias `%s` references alias `%s`: aliases must reference a relation or permission", nsName, aliasName, targetName),
+		namespaceName: nsName,
+		aliasName:     aliasName,
+		targetName:    targetName,
+	}
+}
+
 // NewWildcardUsedInArrowErr constructs an error indicating that an arrow operated over a relation with a wildcard type.
 func NewWildcardUsedInArrowErr(nsName string, parentPermissionName string, foundRelationName string, wildcardTypeName string, wildcardRelationName string) error {
 	return ErrWildcardUsedInArrow{
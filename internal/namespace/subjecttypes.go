@@ -0,0 +1,342 @@
+package namespace
+
+import (
+	"context"
+	"sort"
+
+	"github.com/authzed/spicedb/pkg/spiceerrors"
+
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// PossibleSubjectType is a type of subject which can possibly hold a relation or permission.
+type PossibleSubjectType struct {
+	// SubjectType is the type of the subjects, whose relation is the ellipsis for subjects which
+	// are objects, or the relation of subject sets, such as `group#member`.
+	SubjectType *core.RelationReference
+
+	// Concrete is true if individual subjects of the type can hold it.
+	Concrete bool
+
+	// Wildcard is true if every subject of the type can hold it via a wildcard.
+	Wildcard bool
+
+	// Caveats are the names of the caveats under which subjects of the type may hold it, ordered
+	// by name.
+	Caveats []string
+
+	// RequiresCaveat is true if subjects of the type can only hold it under a caveat.
+	RequiresCaveat bool
+}
+
+// PossibleSubjectTypes returns the types of subjects which can possibly hold the relation or
+// permission, ordered by type and relation, as computed from the type system: those allowed on
+// the relations it reads, followed through subject sets and arrows. Subjects can only hold an
+// intersection if they can hold each of its branches, and never hold a permission via the
+// excluded branches of an exclusion.
+//
+// Whether a subject holds the permission depends on the relationships written, so the types
+// returned are those which can hold it, rather than those which do.
+func (nts *TypeSystem) PossibleSubjectTypes(ctx context.Context, relationName string) ([]PossibleSubjectType, error) {
+	if _, ok := nts.relationMap[relationName]; !ok {
+		return nil, NewRelationNotFoundErr(nts.nsDef.Name, relationName)
+	}
+
+	found, err := nts.possibleSubjectTypes(ctx, relationName, &possibleSubjectTypesState{
+		inProgress: make(map[string]struct{}),
+		computed:   make(map[string]subjectTypeSet),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	possible := make([]PossibleSubjectType, 0, len(found))
+	for _, entry := range found {
+		caveats := make([]string, 0, len(entry.caveats))
+		for caveat := range entry.caveats {
+			caveats = append(caveats, caveat)
+		}
+		sort.Strings(caveats)
+
+		possible = append(possible, PossibleSubjectType{
+			SubjectType:    entry.subjectType,
+			Concrete:       entry.concrete,
+			Wildcard:       entry.wildcard,
+			Caveats:        caveats,
+			RequiresCaveat: !entry.uncaveated,
+		})
+	}
+
+	sort.Slice(possible, func(i, j int) bool {
+		left, right := possible[i].SubjectType, possible[j].SubjectType
+		if left.Namespace != right.Namespace {
+			return left.Namespace < right.Namespace
+		}
+		return left.Relation < right.Relation
+	})
+	return possible, nil
+}
+
+type subjectTypeEntry struct {
+	subjectType *core.RelationReference
+	concrete    bool
+	wildcard    bool
+	caveats     map[string]struct{}
+	uncaveated  bool
+}
+
+// subjectTypeSet is the set of subject types which can hold a relation, keyed by type and
+// relation. A nil set is a relation whose subject types are being computed further up a cycle,
+// which places no constraint on the subject types of the relations using it.
+type subjectTypeSet map[string]*subjectTypeEntry
+
+type possibleSubjectTypesState struct {
+	inProgress map[string]struct{}
+	computed   map[string]subjectTypeSet
+}
+
+func (nts *TypeSystem) possibleSubjectTypes(ctx context.Context, relationName string, state *possibleSubjectTypesState) (subjectTypeSet, error) {
+	key := tuple.JoinRelRef(nts.nsDef.Name, relationName)
+	if computed, ok := state.computed[key]; ok {
+		return computed, nil
+	}
+	if _, ok := state.inProgress[key]; ok {
+		return nil, nil
+	}
+
+	relation, ok := nts.relationMap[relationName]
+	if !ok {
+		return nil, asTypeError(NewRelationNotFoundErr(nts.nsDef.Name, relationName))
+	}
+
+	state.inProgress[key] = struct{}{}
+	defer delete(state.inProgress, key)
+
+	var found subjectTypeSet
+	var err error
+	if rewrite := relation.GetUsersetRewrite(); rewrite != nil {
+		found, err = nts.rewriteSubjectTypes(ctx, rewrite, state)
+	} else {
+		found, err = nts.directSubjectTypes(ctx, relationName, state)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	state.computed[key] = found
+	return found, nil
+}
+
+// directSubjectTypes returns the subject types allowed directly on the relation, along with
+// those which can hold the subject sets allowed on it.
+func (nts *TypeSystem) directSubjectTypes(ctx context.Context, relationName string, state *possibleSubjectTypesState) (subjectTypeSet, error) {
+	allowedRelations, err := nts.AllowedDirectRelationsAndWildcards(relationName)
+	if err != nil {
+		return nil, err
+	}
+
+	found := subjectTypeSet{}
+	for _, allowed := range allowedRelations {
+		caveat := allowed.GetRequiredCaveat().GetCaveatName()
+		switch {
+		case allowed.GetPublicWildcard() != nil:
+			found.add(&core.RelationReference{Namespace: allowed.Namespace, Relation: tuple.Ellipsis}, false, true, caveat)
+
+		case allowed.GetRelation() == tuple.Ellipsis:
+			found.add(&core.RelationReference{Namespace: allowed.Namespace, Relation: tuple.Ellipsis}, true, false, caveat)
+
+		default:
+			found.add(&core.RelationReference{Namespace: allowed.Namespace, Relation: allowed.GetRelation()}, true, false, caveat)
+
+			subjectTS, err := nts.typeSystemForNamespace(ctx, allowed.Namespace)
+			if err != nil {
+				return nil, asTypeError(err)
+			}
+
+			holding, err := subjectTS.possibleSubjectTypes(ctx, allowed.GetRelation(), state)
+			if err != nil {
+				return nil, err
+			}
+			found.union(holding.withCaveat(caveat))
+		}
+	}
+	return found, nil
+}
+
+// rewriteSubjectTypes returns the subject types which can hold the rewrite.
+func (nts *TypeSystem) rewriteSubjectTypes(ctx context.Context, rewrite *core.UsersetRewrite, state *possibleSubjectTypesState) (subjectTypeSet, error) {
+	switch rw := rewrite.RewriteOperation.(type) {
+	case *core.UsersetRewrite_Union:
+		found := subjectTypeSet{}
+		for _, child := range rw.Union.Child {
+			holding, err := nts.childSubjectTypes(ctx, child, state)
+			if err != nil {
+				return nil, err
+			}
+			found.union(holding)
+		}
+		return found, nil
+
+	case *core.UsersetRewrite_Intersection:
+		var found subjectTypeSet
+		for _, child := range rw.Intersection.Child {
+			holding, err := nts.childSubjectTypes(ctx, child, state)
+			if err != nil {
+				return nil, err
+			}
+			found = found.intersect(holding)
+		}
+		return found, nil
+
+	case *core.UsersetRewrite_Exclusion:
+		// Subjects only hold an exclusion via its first branch.
+		if len(rw.Exclusion.Child) == 0 {
+			return subjectTypeSet{}, nil
+		}
+		return nts.childSubjectTypes(ctx, rw.Exclusion.Child[0], state)
+
+	default:
+		return nil, spiceerrors.MustBugf("unknown type of rewrite operation: %T", rw)
+	}
+}
+
+func (nts *TypeSystem) childSubjectTypes(ctx context.Context, childOneof *core.SetOperation_Child, state *possibleSubjectTypesState) (subjectTypeSet, error) {
+	switch child := childOneof.ChildType.(type) {
+	case *core.SetOperation_Child_XThis:
+		return nil, spiceerrors.MustBugf("use of _this is disallowed")
+
+	case *core.SetOperation_Child_XNil:
+		return subjectTypeSet{}, nil
+
+	case *core.SetOperation_Child_ComputedUserset:
+		return nts.possibleSubjectTypes(ctx, child.ComputedUserset.Relation, state)
+
+	case *core.SetOperation_Child_UsersetRewrite:
+		return nts.rewriteSubjectTypes(ctx, child.UsersetRewrite, state)
+
+	case *core.SetOperation_Child_TupleToUserset:
+		allowedRelations, err := nts.AllowedDirectRelationsAndWildcards(child.TupleToUserset.Tupleset.Relation)
+		if err != nil {
+			return nil, err
+		}
+
+		found := subjectTypeSet{}
+		for _, allowed := range allowedRelations {
+			subjectTS, err := nts.typeSystemForNamespace(ctx, allowed.Namespace)
+			if err != nil {
+				return nil, asTypeError(err)
+			}
+
+			// Arrows may walk to types which do not define the relation, which are skipped.
+			if !subjectTS.HasRelation(child.TupleToUserset.ComputedUserset.Relation) {
+				continue
+			}
+
+			holding, err := subjectTS.possibleSubjectTypes(ctx, child.TupleToUserset.ComputedUserset.Relation, state)
+			if err != nil {
+				return nil, err
+			}
+			found.union(holding.withCaveat(allowed.GetRequiredCaveat().GetCaveatName()))
+		}
+		return found, nil
+
+	default:
+		return nil, spiceerrors.MustBugf("unknown set operation child %T", child)
+	}
+}
+
+func (sts subjectTypeSet) add(subjectType *core.RelationReference, concrete, wildcard bool, caveat string) {
+	entry := &subjectTypeEntry{
+		subjectType: subjectType,
+		concrete:    concrete,
+		wildcard:    wildcard,
+		caveats:     map[string]struct{}{},
+		uncaveated:  caveat == "",
+	}
+	if caveat != "" {
+		entry.caveats[caveat] = struct{}{}
+	}
+	sts.union(subjectTypeSet{tuple.StringRR(subjectType): entry})
+}
+
+// union adds the subject types of the other set to this one.
+func (sts subjectTypeSet) union(other subjectTypeSet) {
+	for key, entry := range other {
+		existing, ok := sts[key]
+		if !ok {
+			existing = &subjectTypeEntry{subjectType: entry.subjectType, caveats: map[string]struct{}{}}
+			sts[key] = existing
+		}
+
+		existing.concrete = existing.concrete || entry.concrete
+		existing.wildcard = existing.wildcard || entry.wildcard
+		existing.uncaveated = existing.uncaveated || entry.uncaveated
+		for caveat := range entry.caveats {
+			existing.caveats[caveat] = struct{}{}
+		}
+	}
+}
+
+// intersect returns the subject types which can hold both sets, either of which may be nil.
+func (sts subjectTypeSet) intersect(other subjectTypeSet) subjectTypeSet {
+	if sts == nil {
+		return other
+	}
+	if other == nil {
+		return sts
+	}
+
+	intersected := subjectTypeSet{}
+	for key, left := range sts {
+		right, ok := other[key]
+		if !ok {
+			continue
+		}
+
+		// A concrete subject holds a branch if it is allowed on it, or via a wildcard, whereas
+		// the wildcard itself only holds the intersection if it holds every branch.
+		entry := &subjectTypeEntry{
+			subjectType: left.subjectType,
+			wildcard:    left.wildcard && right.wildcard,
+			concrete:    (left.concrete || right.concrete) && (left.concrete || left.wildcard) && (right.concrete || right.wildcard),
+			caveats:     map[string]struct{}{},
+			uncaveated:  left.uncaveated && right.uncaveated,
+		}
+		if !entry.concrete && !entry.wildcard {
+			continue
+		}
+
+		for caveat := range left.caveats {
+			entry.caveats[caveat] = struct{}{}
+		}
+		for caveat := range right.caveats {
+			entry.caveats[caveat] = struct{}{}
+		}
+		intersected[key] = entry
+	}
+	return intersected
+}
+
+// withCaveat returns the subject types of the set, held under the caveat if non-empty.
+func (sts subjectTypeSet) withCaveat(caveat string) subjectTypeSet {
+	if caveat == "" {
+		return sts
+	}
+
+	caveated := make(subjectTypeSet, len(sts))
+	for key, entry := range sts {
+		caveats := map[string]struct{}{caveat: {}}
+		for existing := range entry.caveats {
+			caveats[existing] = struct{}{}
+		}
+
+		caveated[key] = &subjectTypeEntry{
+			subjectType: entry.subjectType,
+			concrete:    entry.concrete,
+			wildcard:    entry.wildcard,
+			caveats:     caveats,
+		}
+	}
+	return caveated
+}
@@ -0,0 +1,286 @@
+package namespace
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	ns "github.com/authzed/spicedb/pkg/namespace"
+	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
+	"github.com/authzed/spicedb/pkg/schemadsl/input"
+)
+
+func pst(namespace, relation string, concrete, wildcard, requiresCaveat bool, caveats ...string) PossibleSubjectType {
+	if caveats == nil {
+		caveats = []string{}
+	}
+	return PossibleSubjectType{
+		SubjectType:    ns.RelationReference(namespace, relation),
+		Concrete:       concrete,
+		Wildcard:       wildcard,
+		Caveats:        caveats,
+		RequiresCaveat: requiresCaveat,
+	}
+}
+
+func TestPossibleSubjectTypes(t *testing.T) {
+	testCases := []struct {
+		name         string
+		schema       string
+		resourceType string
+		permission   string
+		expected     []PossibleSubjectType
+	}{
+		{
+			"direct relation",
+			`definition user {}
+			definition document {
+				relation viewer: user
+			}`,
+			"document",
+			"viewer",
+			[]PossibleSubjectType{
+				pst("user", "...", true, false, false),
+			},
+		},
+		{
+			"wildcard and concrete",
+			`definition user {}
+			definition document {
+				relation viewer: user | user:*
+			}`,
+			"document",
+			"viewer",
+			[]PossibleSubjectType{
+				pst("user", "...", true, true, false),
+			},
+		},
+		{
+			"subject set",
+			`definition user {}
+			definition group {
+				relation member: user
+			}
+			definition document {
+				relation viewer: group#member
+			}`,
+			"document",
+			"viewer",
+			[]PossibleSubjectType{
+				pst("group", "member", true, false, false),
+				pst("user", "...", true, false, false),
+			},
+		},
+		{
+			"recursive subject set",
+			`definition user {}
+			definition group {
+				relation member: user | group#member
+			}
+			definition document {
+				relation viewer: group#member
+				permission view = viewer
+			}`,
+			"document",
+			"view",
+			[]PossibleSubjectType{
+				pst("group", "member", true, false, false),
+				pst("user", "...", true, false, false),
+			},
+		},
+		{
+			"arrow",
+			`definition user {}
+			definition team {}
+			definition organization {
+				relation admin: user
+			}
+			definition folder {
+				relation viewer: team
+			}
+			definition document {
+				relation parent: organization | folder
+				permission view = parent->admin
+			}`,
+			"document",
+			"view",
+			[]PossibleSubjectType{
+				pst("user", "...", true, false, false),
+			},
+		},
+		{
+			"recursive arrow",
+			`definition user {}
+			definition folder {
+				relation parent: folder
+				relation viewer: user
+				permission view = viewer + parent->view
+			}`,
+			"folder",
+			"view",
+			[]PossibleSubjectType{
+				pst("user", "...", true, false, false),
+			},
+		},
+		{
+			"caveats",
+			`definition user {}
+			definition group {
+				relation member: user | user with second
+			}
+			caveat first(somecondition int) {
+				somecondition == 42
+			}
+			caveat second(somecondition int) {
+				somecondition == 42
+			}
+			definition document {
+				relation viewer: user with first | group#member
+				relation editor: user
+				permission view = viewer + editor
+			}`,
+			"document",
+			"viewer",
+			[]PossibleSubjectType{
+				pst("group", "member", true, false, false),
+				pst("user", "...", true, false, false, "first", "second"),
+			},
+		},
+		{
+			"caveats unioned with uncaveated",
+			`definition user {}
+			caveat somecaveat(somecondition int) {
+				somecondition == 42
+			}
+			definition document {
+				relation viewer: user with somecaveat
+				relation editor: user
+				permission view = viewer + editor
+			}`,
+			"document",
+			"view",
+			[]PossibleSubjectType{
+				pst("user", "...", true, false, false, "somecaveat"),
+			},
+		},
+		{
+			"caveated arrow",
+			`definition user {}
+			caveat somecaveat(somecondition int) {
+				somecondition == 42
+			}
+			definition organization {
+				relation admin: user
+			}
+			definition document {
+				relation org: organization with somecaveat
+				permission view = org->admin
+			}`,
+			"document",
+			"view",
+			[]PossibleSubjectType{
+				pst("user", "...", true, false, true, "somecaveat"),
+			},
+		},
+		{
+			"intersection",
+			`definition user {}
+			definition team {}
+			definition document {
+				relation viewer: user | team
+				relation allowed: user:* | team:*
+				relation editor: user
+				permission view = viewer & allowed & editor
+			}`,
+			"document",
+			"view",
+			[]PossibleSubjectType{
+				pst("user", "...", true, false, false),
+			},
+		},
+		{
+			"intersection of wildcards",
+			`definition user {}
+			definition document {
+				relation viewer: user:*
+				relation allowed: user:*
+				permission view = viewer & allowed
+			}`,
+			"document",
+			"view",
+			[]PossibleSubjectType{
+				pst("user", "...", false, true, false),
+			},
+		},
+		{
+			"exclusion",
+			`definition user {}
+			definition team {}
+			definition document {
+				relation viewer: user
+				relation banned: user | team
+				permission view = viewer - banned
+			}`,
+			"document",
+			"view",
+			[]PossibleSubjectType{
+				pst("user", "...", true, false, false),
+			},
+		},
+		{
+			"nil",
+			`definition user {}
+			definition document {
+				permission view = nil
+			}`,
+			"document",
+			"view",
+			[]PossibleSubjectType{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			ds, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+			require.NoError(err)
+
+			empty := ""
+			compiled, err := compiler.Compile(compiler.InputSchema{
+				Source:       input.Source("schema"),
+				SchemaString: tc.schema,
+			}, &empty)
+			require.NoError(err)
+
+			lastRevision, err := ds.HeadRevision(context.Background())
+			require.NoError(err)
+
+			resolver := ResolverForDatastoreReader(ds.SnapshotReader(lastRevision)).WithPredefinedElements(PredefinedElements{
+				Namespaces: compiled.ObjectDefinitions,
+				Caveats:    compiled.CaveatDefinitions,
+			})
+
+			var ts *TypeSystem
+			for _, nsDef := range compiled.ObjectDefinitions {
+				if nsDef.Name == tc.resourceType {
+					ts, err = NewNamespaceTypeSystem(nsDef, resolver)
+					require.NoError(err)
+				}
+			}
+			require.NotNil(ts)
+
+			_, err = ts.Validate(context.Background())
+			require.NoError(err)
+
+			found, err := ts.PossibleSubjectTypes(context.Background(), tc.permission)
+			require.NoError(err)
+			require.Equal(tc.expected, found)
+
+			_, err = ts.PossibleSubjectTypes(context.Background(), "unknown")
+			require.Error(err)
+		})
+	}
+}
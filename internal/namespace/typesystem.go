@@ -125,6 +125,17 @@ func (nts *TypeSystem) IsPermission(relationName string) bool {
 	return nspkg.GetRelationKind(found) == iv1.RelationMetadata_PERMISSION
 }
 
+// AliasOf returns the name of the relation or permission of which the given relation is an
+// alias, or empty if it is not an alias.
+func (nts *TypeSystem) AliasOf(relationName string) string {
+	found, ok := nts.relationMap[relationName]
+	if !ok {
+		return ""
+	}
+
+	return nspkg.GetAliasOf(found)
+}
+
 // IsAllowedPublicNamespace returns whether the target namespace is defined as public on the source relation.
 func (nts *TypeSystem) IsAllowedPublicNamespace(sourceRelationName string, targetNamespaceName string) (AllowedPublicSubject, error) {
 	found, ok := nts.relationMap[sourceRelationName]
@@ -329,6 +340,17 @@ func (nts *TypeSystem) AsValidated() *ValidatedNamespaceTypeSystem {
 // Validate runs validation on the type system for the namespace to ensure it is consistent.
 func (nts *TypeSystem) Validate(ctx context.Context) (*ValidatedNamespaceTypeSystem, error) {
 	for _, relation := range nts.relationMap {
+		// Validate that aliases do not alias other aliases.
+		if aliasOf := nspkg.GetAliasOf(relation); aliasOf != "" {
+			if target, ok := nts.relationMap[aliasOf]; ok && nspkg.GetAliasOf(target) != "" {
+				return nil, newTypeErrorWithSource(
+					NewAliasOfAliasErr(nts.nsDef.Name, relation.Name, aliasOf),
+					relation,
+					relation.Name,
+				)
+			}
+		}
+
 		// Validate the usersets's.
 		usersetRewrite := relation.GetUsersetRewrite()
 		rerr, err := graph.WalkRewrite(usersetRewrite, func(childOneof *core.SetOperation_Child) interface{} {
@@ -517,6 +539,33 @@ func (nts *TypeSystem) Validate(ctx context.Context) (*ValidatedNamespaceTypeSys
 		}
 	}
 
+	// Validate the constraints, which can only reference relations of this namespace.
+	for _, constraint := range nts.nsDef.Constraints {
+		relationNames := []string{constraint.Relation}
+		if excludedRelationName := constraint.GetExcludedRelation(); excludedRelationName != "" {
+			relationNames = append(relationNames, excludedRelationName)
+		}
+
+		for _, relationName := range relationNames {
+			found, ok := nts.relationMap[relationName]
+			if !ok {
+				return nil, newTypeErrorWithSource(
+					NewRelationNotFoundErr(nts.nsDef.Name, relationName),
+					constraint,
+					relationName,
+				)
+			}
+
+			if nspkg.GetRelationKind(found) == iv1.RelationMetadata_PERMISSION {
+				return nil, newTypeErrorWithSource(
+					NewConstraintOnPermissionErr(nts.nsDef.Name, relationName),
+					constraint,
+					relationName,
+				)
+			}
+		}
+	}
+
 	return &ValidatedNamespaceTypeSystem{nts}, nil
 }
 
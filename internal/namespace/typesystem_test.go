@@ -344,6 +344,94 @@ func TestTypeSystem(t *testing.T) {
 			},
 			"",
 		},
+		{
+			"valid constraints",
+			withConstraints(ns.Namespace(
+				"document",
+				ns.MustRelation("owner", nil, ns.AllowedRelation("user", "...")),
+				ns.MustRelation("approver", nil, ns.AllowedRelation("user", "...")),
+				ns.MustRelation("author", nil, ns.AllowedRelation("user", "...")),
+			),
+				&core.RelationConstraint{
+					Relation: "owner",
+					Rule:     &core.RelationConstraint_MaximumRelationships{MaximumRelationships: 1},
+				},
+				&core.RelationConstraint{
+					Relation: "approver",
+					Rule:     &core.RelationConstraint_ExcludedRelation{ExcludedRelation: "author"},
+				},
+			),
+			[]*core.NamespaceDefinition{ns.Namespace("user")},
+			nil,
+			"",
+		},
+		{
+			"constraint excluding unknown relation",
+			withConstraints(ns.Namespace(
+				"document",
+				ns.MustRelation("approver", nil, ns.AllowedRelation("user", "...")),
+			),
+				&core.RelationConstraint{
+					Relation: "approver",
+					Rule:     &core.RelationConstraint_ExcludedRelation{ExcludedRelation: "author"},
+				},
+			),
+			[]*core.NamespaceDefinition{ns.Namespace("user")},
+			nil,
+			"relation/permission `author` not found under definition `document`",
+		},
+		{
+			"constraint on permission",
+			withConstraints(ns.Namespace(
+				"document",
+				ns.MustRelation("owner", nil, ns.AllowedRelation("user", "...")),
+				ns.MustRelation("edit", ns.Union(
+					ns.ComputedUserset("owner"),
+				)),
+			),
+				&core.RelationConstraint{
+					Relation: "edit",
+					Rule:     &core.RelationConstraint_MaximumRelationships{MaximumRelationships: 1},
+				},
+			),
+			[]*core.NamespaceDefinition{ns.Namespace("user")},
+			nil,
+			"under definition `document`: constraints can only reference relations (found permission `edit`)",
+		},
+		{
+			"alias",
+			ns.Namespace(
+				"document",
+				ns.MustRelation("viewer", nil, ns.AllowedRelation("user", "...")),
+				ns.MustAlias("reader", "viewer"),
+			),
+			[]*core.NamespaceDefinition{ns.Namespace("user")},
+			nil,
+			"",
+		},
+		{
+			"alias of unknown relation",
+			ns.Namespace(
+				"document",
+				ns.MustRelation("viewer", nil, ns.AllowedRelation("user", "...")),
+				ns.MustAlias("reader", "unknown"),
+			),
+			[]*core.NamespaceDefinition{ns.Namespace("user")},
+			nil,
+			"relation/permission `unknown` not found under definition `document`",
+		},
+		{
+			"alias of alias",
+			ns.Namespace(
+				"document",
+				ns.MustRelation("viewer", nil, ns.AllowedRelation("user", "...")),
+				ns.MustAlias("reader", "viewer"),
+				ns.MustAlias("looker", "reader"),
+			),
+			[]*core.NamespaceDefinition{ns.Namespace("user")},
+			nil,
+			"under definition `document`: alias `looker` references alias `reader`: aliases must reference a relation or permission",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -382,3 +470,8 @@ func TestTypeSystem(t *testing.T) {
 		})
 	}
 }
+
+func withConstraints(def *core.NamespaceDefinition, constraints ...*core.RelationConstraint) *core.NamespaceDefinition {
+	def.Constraints = constraints
+	return def
+}
@@ -0,0 +1,203 @@
+package relationships
+
+import (
+	"context"
+
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+	"github.com/authzed/spicedb/pkg/util"
+)
+
+// CheckConstraints returns an error if applying the updates would leave an object with
+// relationships which violate a constraint of its definition.
+//
+// It must be called with the transaction applying the updates, before they are written, so that
+// the relationships checked are exactly those the updates are applied to and a concurrent write
+// cannot also satisfy the check. Only the relationships of the constrained relations of the
+// objects updated are read, by object and relation, which every datastore indexes.
+func CheckConstraints(ctx context.Context, reader datastore.Reader, updates []*core.RelationTupleUpdate) error {
+	namespaceNames := util.NewSet[string]()
+	for _, update := range updates {
+		namespaceNames.Add(update.Tuple.ResourceAndRelation.Namespace)
+	}
+
+	namespaces, err := reader.LookupNamespacesWithNames(ctx, namespaceNames.AsSlice())
+	if err != nil {
+		return err
+	}
+
+	constrained := make(map[string]*core.NamespaceDefinition, len(namespaces))
+	for _, ns := range namespaces {
+		if len(ns.Definition.Constraints) > 0 {
+			constrained[ns.Definition.Name] = ns.Definition
+		}
+	}
+	if len(constrained) == 0 {
+		return nil
+	}
+
+	// Group the updates by the object they update, in the order in which the objects are first
+	// updated, so that the first violation found is deterministic.
+	var objects []string
+	updatesByObject := make(map[string][]*core.RelationTupleUpdate)
+	for _, update := range updates {
+		if _, ok := constrained[update.Tuple.ResourceAndRelation.Namespace]; !ok {
+			continue
+		}
+
+		key := tuple.StringONR(&core.ObjectAndRelation{
+			Namespace: update.Tuple.ResourceAndRelation.Namespace,
+			ObjectId:  update.Tuple.ResourceAndRelation.ObjectId,
+		})
+		if _, ok := updatesByObject[key]; !ok {
+			objects = append(objects, key)
+		}
+		updatesByObject[key] = append(updatesByObject[key], update)
+	}
+
+	for _, key := range objects {
+		objectUpdates := updatesByObject[key]
+		nsDef := constrained[objectUpdates[0].Tuple.ResourceAndRelation.Namespace]
+		for _, constraint := range nsDef.Constraints {
+			if err := checkConstraint(ctx, reader, constraint, objectUpdates); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkConstraint checks a constraint against the updates of a single object. Only updates
+// writing relationships can violate a constraint, so the relationships of the object are only
+// read if a relation constrained is written.
+func checkConstraint(ctx context.Context, reader datastore.Reader, constraint *core.RelationConstraint, objectUpdates []*core.RelationTupleUpdate) error {
+	switch rule := constraint.Rule.(type) {
+	case *core.RelationConstraint_MaximumRelationships:
+		written := writtenTo(objectUpdates, constraint.Relation)
+		if written == nil {
+			return nil
+		}
+
+		relationships, err := relationshipsAfter(ctx, reader, written, constraint.Relation, objectUpdates)
+		if err != nil {
+			return err
+		}
+
+		if len(relationships) > int(rule.MaximumRelationships) {
+			return NewConstraintViolationError(written, constraint)
+		}
+		return nil
+
+	case *core.RelationConstraint_ExcludedRelation:
+		written := writtenTo(objectUpdates, constraint.Relation)
+		if written == nil {
+			written = writtenTo(objectUpdates, rule.ExcludedRelation)
+		}
+		if written == nil {
+			return nil
+		}
+
+		included, err := relationshipsAfter(ctx, reader, written, constraint.Relation, objectUpdates)
+		if err != nil {
+			return err
+		}
+
+		excluded, err := relationshipsAfter(ctx, reader, written, rule.ExcludedRelation, objectUpdates)
+		if err != nil {
+			return err
+		}
+
+		includedSubjects := subjectsOf(included)
+		excludedSubjects := subjectsOf(excluded)
+
+		// A subject written to either relation which is then found in both violates the
+		// constraint.
+		for _, update := range objectUpdates {
+			if update.Operation == core.RelationTupleUpdate_DELETE {
+				continue
+			}
+
+			relation := update.Tuple.ResourceAndRelation.Relation
+			if relation != constraint.Relation && relation != rule.ExcludedRelation {
+				continue
+			}
+
+			subject := tuple.StringONR(update.Tuple.Subject)
+			_, isIncluded := includedSubjects[subject]
+			_, isExcluded := excludedSubjects[subject]
+			if isIncluded && isExcluded {
+				return NewConstraintViolationError(update, constraint)
+			}
+		}
+		return nil
+
+	default:
+		return nil
+	}
+}
+
+func subjectsOf(relationships map[string]*core.RelationTuple) map[string]struct{} {
+	subjects := make(map[string]struct{}, len(relationships))
+	for _, tpl := range relationships {
+		subjects[tuple.StringONR(tpl.Subject)] = struct{}{}
+	}
+	return subjects
+}
+
+// writtenTo returns the first update writing a relationship to the relation, if any.
+func writtenTo(objectUpdates []*core.RelationTupleUpdate, relation string) *core.RelationTupleUpdate {
+	for _, update := range objectUpdates {
+		if update.Operation != core.RelationTupleUpdate_DELETE && update.Tuple.ResourceAndRelation.Relation == relation {
+			return update
+		}
+	}
+	return nil
+}
+
+// relationshipsAfter returns the relationships the object would have on the relation after the
+// updates are applied, keyed by the relationship without its caveat.
+func relationshipsAfter(
+	ctx context.Context,
+	reader datastore.Reader,
+	objectUpdate *core.RelationTupleUpdate,
+	relation string,
+	objectUpdates []*core.RelationTupleUpdate,
+) (map[string]*core.RelationTuple, error) {
+	it, err := reader.QueryRelationships(ctx, datastore.RelationshipsFilter{
+		ResourceType:             objectUpdate.Tuple.ResourceAndRelation.Namespace,
+		OptionalResourceIds:      []string{objectUpdate.Tuple.ResourceAndRelation.ObjectId},
+		OptionalResourceRelation: relation,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	relationships := make(map[string]*core.RelationTuple)
+	for {
+		tpl, err := it.Next()
+		if err != nil {
+			return nil, err
+		}
+		if tpl == nil {
+			break
+		}
+
+		relationships[tuple.StringWithoutCaveat(tpl)] = tpl
+	}
+
+	for _, update := range objectUpdates {
+		if update.Tuple.ResourceAndRelation.Relation != relation {
+			continue
+		}
+
+		key := tuple.StringWithoutCaveat(update.Tuple)
+		if update.Operation == core.RelationTupleUpdate_DELETE {
+			delete(relationships, key)
+		} else {
+			relationships[key] = update.Tuple
+		}
+	}
+	return relationships, nil
+}
@@ -0,0 +1,163 @@
+package relationships
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+func TestCheckConstraints(t *testing.T) {
+	tcs := []struct {
+		name          string
+		existing      []string
+		updates       []*core.RelationTupleUpdate
+		expectedError string
+	}{
+		{
+			"no constrained relations written",
+			[]string{"document:first#owner@user:tom"},
+			[]*core.RelationTupleUpdate{
+				tuple.Create(tuple.MustParse("document:first#viewer@user:sarah")),
+			},
+			"",
+		},
+		{
+			"first owner",
+			nil,
+			[]*core.RelationTupleUpdate{
+				tuple.Create(tuple.MustParse("document:first#owner@user:tom")),
+			},
+			"",
+		},
+		{
+			"second owner",
+			[]string{"document:first#owner@user:tom"},
+			[]*core.RelationTupleUpdate{
+				tuple.Create(tuple.MustParse("document:first#owner@user:sarah")),
+			},
+			"relationship `document:first#owner@user:sarah` violates the constraint that objects of definition `document` have at most 1 relationships on relation `owner`",
+		},
+		{
+			"second owner written in the same batch",
+			nil,
+			[]*core.RelationTupleUpdate{
+				tuple.Create(tuple.MustParse("document:first#owner@user:tom")),
+				tuple.Create(tuple.MustParse("document:first#owner@user:sarah")),
+			},
+			"violates the constraint that objects of definition `document` have at most 1 relationships on relation `owner`",
+		},
+		{
+			"owner of another document",
+			[]string{"document:first#owner@user:tom"},
+			[]*core.RelationTupleUpdate{
+				tuple.Create(tuple.MustParse("document:second#owner@user:sarah")),
+			},
+			"",
+		},
+		{
+			"transfer of ownership",
+			[]string{"document:first#owner@user:tom"},
+			[]*core.RelationTupleUpdate{
+				tuple.Delete(tuple.MustParse("document:first#owner@user:tom")),
+				tuple.Create(tuple.MustParse("document:first#owner@user:sarah")),
+			},
+			"",
+		},
+		{
+			"touch of existing owner",
+			[]string{"document:first#owner@user:tom"},
+			[]*core.RelationTupleUpdate{
+				tuple.Touch(tuple.MustParse("document:first#owner@user:tom")),
+			},
+			"",
+		},
+		{
+			"approver who is the author",
+			[]string{"document:first#author@user:tom"},
+			[]*core.RelationTupleUpdate{
+				tuple.Create(tuple.MustParse("document:first#approver@user:tom")),
+			},
+			"relationship `document:first#approver@user:tom` violates the constraint that no subject is in both relations `approver` and `author` of an object of definition `document`",
+		},
+		{
+			"author who is the approver",
+			[]string{"document:first#approver@user:tom"},
+			[]*core.RelationTupleUpdate{
+				tuple.Touch(tuple.MustParse("document:first#author@user:tom")),
+			},
+			"relationship `document:first#author@user:tom` violates the constraint that no subject is in both relations `approver` and `author`",
+		},
+		{
+			"approver who is another author",
+			[]string{"document:first#author@user:tom"},
+			[]*core.RelationTupleUpdate{
+				tuple.Create(tuple.MustParse("document:first#approver@user:sarah")),
+			},
+			"",
+		},
+		{
+			"approver replacing the author",
+			[]string{"document:first#author@user:tom"},
+			[]*core.RelationTupleUpdate{
+				tuple.Delete(tuple.MustParse("document:first#author@user:tom")),
+				tuple.Create(tuple.MustParse("document:first#approver@user:tom")),
+			},
+			"",
+		},
+		{
+			"approver who is the author of another document",
+			[]string{"document:second#author@user:tom"},
+			[]*core.RelationTupleUpdate{
+				tuple.Create(tuple.MustParse("document:first#approver@user:tom")),
+			},
+			"",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			ds, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+			require.NoError(t, err)
+
+			writeSchema(t, ds, `
+				definition user {}
+				definition document {
+					relation owner: user
+					relation approver: user
+					relation author: user
+					relation viewer: user
+
+					constraint owner at most 1
+					constraint approver excludes author
+				}
+			`)
+
+			revision, err := ds.ReadWriteTx(ctx, func(rwt datastore.ReadWriteTransaction) error {
+				updates := make([]*core.RelationTupleUpdate, 0, len(tc.existing))
+				for _, rel := range tc.existing {
+					updates = append(updates, tuple.Create(tuple.MustParse(rel)))
+				}
+				return rwt.WriteRelationships(ctx, updates)
+			})
+			require.NoError(t, err)
+
+			err = CheckConstraints(ctx, ds.SnapshotReader(revision), tc.updates)
+			if tc.expectedError == "" {
+				require.NoError(t, err)
+				return
+			}
+
+			require.ErrorContains(t, err, tc.expectedError)
+			require.Equal(t, codes.FailedPrecondition, status.Code(err))
+		})
+	}
+}
@@ -2,9 +2,12 @@ package relationships
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/authzed/spicedb/internal/namespace"
 
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
@@ -86,6 +89,44 @@ func (err ErrCannotWriteToPermission) GRPCStatus() *status.Status {
 	)
 }
 
+// ErrCannotWriteToAlias indicates that a write was attempted on an alias, rather than on the
+// relation which it aliases.
+type ErrCannotWriteToAlias struct {
+	error
+	update  *core.RelationTupleUpdate
+	aliasOf string
+}
+
+// NewCannotWriteToAliasError constructs a new error for attempting to write to an alias.
+func NewCannotWriteToAliasError(update *core.RelationTupleUpdate, aliasOf string) ErrCannotWriteToAlias {
+	return ErrCannotWriteToAlias{
+		error: fmt.Errorf(
+			"cannot write a relationship to alias `%s` under definition `%s`: relationships must be written to `%s`, of which it is an alias",
+			update.Tuple.ResourceAndRelation.Relation,
+			update.Tuple.ResourceAndRelation.Namespace,
+			aliasOf,
+		),
+		update:  update,
+		aliasOf: aliasOf,
+	}
+}
+
+// GRPCStatus implements retrieving the gRPC status for the error.
+func (err ErrCannotWriteToAlias) GRPCStatus() *status.Status {
+	return spiceerrors.WithCodeAndDetails(
+		err,
+		codes.InvalidArgument,
+		spiceerrors.ForReason(
+			v1.ErrorReason_ERROR_REASON_CANNOT_UPDATE_PERMISSION,
+			map[string]string{
+				"definition_name": err.update.Tuple.ResourceAndRelation.Namespace,
+				"permission_name": err.update.Tuple.ResourceAndRelation.Relation,
+				"alias_of":        err.aliasOf,
+			},
+		),
+	)
+}
+
 // ErrCaveatNotFound indicates that a caveat referenced in a relationship update was not found.
 type ErrCaveatNotFound struct {
 	error
@@ -117,3 +158,139 @@ func (err ErrCaveatNotFound) GRPCStatus() *status.Status {
 		),
 	)
 }
+
+// ErrConstraintViolation indicates that a write would leave an object with relationships which
+// violate a constraint of its definition.
+type ErrConstraintViolation struct {
+	error
+	update     *core.RelationTupleUpdate
+	constraint *core.RelationConstraint
+}
+
+// NewConstraintViolationError constructs a new error for an update violating a constraint.
+func NewConstraintViolationError(update *core.RelationTupleUpdate, constraint *core.RelationConstraint) ErrConstraintViolation {
+	namespaceName := update.Tuple.ResourceAndRelation.Namespace
+
+	var rule string
+	switch typed := constraint.Rule.(type) {
+	case *core.RelationConstraint_MaximumRelationships:
+		rule = fmt.Sprintf("objects of definition `%s` have at most %d relationships on relation `%s`", namespaceName, typed.MaximumRelationships, constraint.Relation)
+	case *core.RelationConstraint_ExcludedRelation:
+		rule = fmt.Sprintf("no subject is in both relations `%s` and `%s` of an object of definition `%s`", constraint.Relation, typed.ExcludedRelation, namespaceName)
+	}
+
+	return ErrConstraintViolation{
+		error: fmt.Errorf(
+			"relationship `%s` violates the constraint that %s",
+			tuple.MustString(update.Tuple),
+			rule,
+		),
+		update:     update,
+		constraint: constraint,
+	}
+}
+
+// GRPCStatus implements retrieving the gRPC status for the error.
+func (err ErrConstraintViolation) GRPCStatus() *status.Status {
+	metadata := map[string]string{
+		"definition_name": err.update.Tuple.ResourceAndRelation.Namespace,
+		"relation_name":   err.constraint.Relation,
+		"relationship":    tuple.MustString(err.update.Tuple),
+	}
+	if excluded := err.constraint.GetExcludedRelation(); excluded != "" {
+		metadata["excluded_relation_name"] = excluded
+	}
+
+	return spiceerrors.WithCodeAndDetails(
+		err,
+		codes.FailedPrecondition,
+		spiceerrors.ForReasonName(spiceerrors.ReasonConstraintViolated, metadata),
+	)
+}
+
+// ErrNestingDepthExceeded indicates that a write would create a chain of nested relationships
+// deeper than the maximum allowed.
+type ErrNestingDepthExceeded struct {
+	error
+	update   *core.RelationTupleUpdate
+	maxDepth uint32
+}
+
+// NewNestingDepthExceededError constructs a new error for an update creating a chain of nested
+// relationships deeper than the maximum.
+func NewNestingDepthExceededError(update *core.RelationTupleUpdate, maxDepth uint32) ErrNestingDepthExceeded {
+	return ErrNestingDepthExceeded{
+		error: fmt.Errorf(
+			"relationship `%s` would create a chain of nested relationships deeper than the maximum of %d",
+			tuple.MustString(update.Tuple),
+			maxDepth,
+		),
+		update:   update,
+		maxDepth: maxDepth,
+	}
+}
+
+// GRPCStatus implements retrieving the gRPC status for the error.
+func (err ErrNestingDepthExceeded) GRPCStatus() *status.Status {
+	return spiceerrors.WithCodeAndDetails(
+		err,
+		codes.FailedPrecondition,
+		spiceerrors.ForReasonName(
+			spiceerrors.ReasonMaximumNestingDepthExceeded,
+			map[string]string{
+				"relationship":  tuple.MustString(err.update.Tuple),
+				"maximum_depth": strconv.FormatUint(uint64(err.maxDepth), 10),
+			},
+		),
+	)
+}
+
+// InvalidUpdate is a relationship update found to be invalid, along with its index amongst the
+// updates validated.
+type InvalidUpdate struct {
+	Index  int
+	Update *core.RelationTupleUpdate
+	Err    error
+}
+
+// ErrInvalidRelationshipUpdates indicates that one or more relationship updates were invalid
+// under the schema.
+type ErrInvalidRelationshipUpdates struct {
+	error
+	invalid []InvalidUpdate
+}
+
+// NewInvalidRelationshipUpdatesError constructs a new error for the given invalid updates.
+func NewInvalidRelationshipUpdatesError(invalid []InvalidUpdate) ErrInvalidRelationshipUpdates {
+	messages := make([]string, 0, len(invalid))
+	for _, update := range invalid {
+		messages = append(messages, fmt.Sprintf("update #%d (`%s`): %s", update.Index, tuple.MustString(update.Update.Tuple), update.Err))
+	}
+
+	return ErrInvalidRelationshipUpdates{
+		error:   fmt.Errorf("%d relationship update(s) are invalid: %s", len(invalid), strings.Join(messages, "; ")),
+		invalid: invalid,
+	}
+}
+
+// InvalidUpdates returns the invalid updates.
+func (err ErrInvalidRelationshipUpdates) InvalidUpdates() []InvalidUpdate {
+	return err.invalid
+}
+
+// GRPCStatus implements retrieving the gRPC status for the error.
+func (err ErrInvalidRelationshipUpdates) GRPCStatus() *status.Status {
+	violations := make([]*errdetails.BadRequest_FieldViolation, 0, len(err.invalid))
+	for _, update := range err.invalid {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{
+			Field:       fmt.Sprintf("updates[%d]", update.Index),
+			Description: update.Err.Error(),
+		})
+	}
+
+	return spiceerrors.WithCodeAndDetails(
+		err,
+		codes.InvalidArgument,
+		&errdetails.BadRequest{FieldViolations: violations},
+	)
+}
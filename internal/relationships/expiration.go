@@ -0,0 +1,205 @@
+package relationships
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	log "github.com/authzed/spicedb/internal/logging"
+	caveattypes "github.com/authzed/spicedb/pkg/caveats/types"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
+	"github.com/authzed/spicedb/pkg/tuple"
+	"github.com/authzed/spicedb/pkg/util"
+)
+
+// expirationBatchSize is the number of resources whose expired relationships are deleted by each
+// transaction.
+const expirationBatchSize = 100
+
+var expiredRelationshipsCounter = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "spicedb",
+	Subsystem: "datastore",
+	Name:      "expired_relationships_deleted_total",
+	Help:      "The number of relationships of time-bounded relations deleted once their bound had passed.",
+})
+
+// RegisterExpirationMetrics registers expired relationship metrics to the default registry.
+func RegisterExpirationMetrics() error {
+	return prometheus.Register(expiredRelationshipsCounter)
+}
+
+// DeleteExpiredRelationships deletes the relationships written with a caveat synthesized for a
+// relation bounded with `within`, whose bound had passed at the given time. Such relationships
+// can never grant access again (see compiler.WithinCaveatParameter).
+//
+// The expired relationships are found at the head revision, and are read again by the transaction
+// deleting them, so that relationships rewritten with a later bound in the meantime are kept.
+func DeleteExpiredRelationships(ctx context.Context, ds datastore.Datastore, now time.Time) (uint64, error) {
+	headRevision, err := ds.HeadRevision(ctx)
+	if err != nil {
+		return 0, err
+	}
+	reader := ds.SnapshotReader(headRevision)
+
+	caveatDefs, err := reader.ListAllCaveats(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	parameters := make(map[string]string)
+	for _, caveatDef := range caveatDefs {
+		if parameter, ok := compiler.WithinCaveatParameter(caveatDef.Definition); ok {
+			parameters[caveatDef.Definition.Name] = parameter
+		}
+	}
+	if len(parameters) == 0 {
+		return 0, nil
+	}
+
+	nsDefs, err := reader.ListAllNamespaces(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var deleted uint64
+	for _, nsDef := range nsDefs {
+		for caveatName, parameter := range parameters {
+			filter := datastore.RelationshipsFilter{
+				ResourceType:       nsDef.Definition.Name,
+				OptionalCaveatName: caveatName,
+			}
+
+			count, err := deleteExpired(ctx, ds, reader, filter, parameter, now)
+			deleted += count
+			if err != nil {
+				return deleted, err
+			}
+		}
+	}
+	return deleted, nil
+}
+
+func deleteExpired(ctx context.Context, ds datastore.Datastore, reader datastore.Reader, filter datastore.RelationshipsFilter, parameter string, now time.Time) (uint64, error) {
+	resourceIDs, err := expiredResourceIDs(ctx, reader, filter, parameter, now)
+	if err != nil {
+		return 0, err
+	}
+
+	var deleted uint64
+	for start := 0; start < len(resourceIDs); start += expirationBatchSize {
+		end := start + expirationBatchSize
+		if end > len(resourceIDs) {
+			end = len(resourceIDs)
+		}
+
+		batchFilter := filter
+		batchFilter.OptionalResourceIds = resourceIDs[start:end]
+
+		var batchCount uint64
+		if _, err := ds.ReadWriteTx(ctx, func(rwt datastore.ReadWriteTransaction) error {
+			var deletes []*core.RelationTupleUpdate
+			if err := forEachExpired(ctx, rwt, batchFilter, parameter, now, func(tpl *core.RelationTuple) {
+				deletes = append(deletes, tuple.Delete(tpl))
+			}); err != nil {
+				return err
+			}
+
+			batchCount = uint64(len(deletes))
+			if batchCount == 0 {
+				return nil
+			}
+			return rwt.WriteRelationships(ctx, deletes)
+		}); err != nil {
+			return deleted, err
+		}
+
+		deleted += batchCount
+		expiredRelationshipsCounter.Add(float64(batchCount))
+	}
+	return deleted, nil
+}
+
+// expiredResourceIDs returns the IDs of the resources of the relationships matching the filter
+// whose bound had passed at the given time.
+func expiredResourceIDs(ctx context.Context, reader datastore.Reader, filter datastore.RelationshipsFilter, parameter string, now time.Time) ([]string, error) {
+	ids := util.NewSet[string]()
+	if err := forEachExpired(ctx, reader, filter, parameter, now, func(tpl *core.RelationTuple) {
+		ids.Add(tpl.ResourceAndRelation.ObjectId)
+	}); err != nil {
+		return nil, err
+	}
+	return ids.AsSlice(), nil
+}
+
+func forEachExpired(ctx context.Context, reader datastore.Reader, filter datastore.RelationshipsFilter, parameter string, now time.Time, fn func(tpl *core.RelationTuple)) error {
+	it, err := reader.QueryRelationships(ctx, filter)
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	for {
+		tpl, err := it.Next()
+		if err != nil {
+			return err
+		}
+		if tpl == nil {
+			return nil
+		}
+
+		if isExpired(tpl, parameter, now) {
+			fn(tpl)
+		}
+	}
+}
+
+// isExpired returns whether the relationship carries a bound, as the value of the parameter of its
+// caveat, which had passed at the given time. Relationships without a valid bound of their own are
+// never expired, as their bound may be supplied by requests.
+func isExpired(tpl *core.RelationTuple, parameter string, now time.Time) bool {
+	value, ok := tpl.GetCaveat().GetContext().GetFields()[parameter]
+	if !ok {
+		return false
+	}
+
+	converted, err := caveattypes.TimestampType.ConvertValue(value.AsInterface())
+	if err != nil {
+		return false
+	}
+
+	bound, ok := converted.(time.Time)
+	return ok && !now.Before(bound)
+}
+
+// StartExpiredRelationshipReaper loops until the context is canceled, deleting the relationships
+// whose bound has passed on the provided interval, as per DeleteExpiredRelationships.
+func StartExpiredRelationshipReaper(ctx context.Context, ds datastore.Datastore, interval time.Duration) error {
+	log.Ctx(ctx).Info().
+		Dur("interval", interval).
+		Msg("expired relationship reaper started")
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Ctx(ctx).Info().
+				Msg("shutting down expired relationship reaper")
+			return nil
+
+		case <-time.After(interval):
+			start := time.Now()
+			deleted, err := DeleteExpiredRelationships(ctx, ds, start)
+			if err != nil {
+				log.Ctx(ctx).Warn().Err(err).Uint64("deleted", deleted).Msg("error deleting expired relationships")
+				continue
+			}
+
+			log.Ctx(ctx).Debug().
+				Dur("duration", time.Since(start)).
+				Uint64("deleted", deleted).
+				Msg("deleted expired relationships")
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package relationships
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+func TestDeleteExpiredRelationships(t *testing.T) {
+	ctx := context.Background()
+	ds, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	require.NoError(t, err)
+
+	writeSchema(t, ds, `
+		caveat deadline(deadline timestamp) with precedence request_wins {
+			now() < deadline
+		}
+
+		definition user {}
+
+		definition document {
+			relation reader: user
+			relation editor: user with deadline
+			permission view = reader.within(expiration)
+			permission edit = editor
+		}
+	`)
+
+	now := time.Now()
+	future := map[string]any{"expiration": now.Add(time.Hour).UTC().Format(time.RFC3339)}
+	past := map[string]any{"expiration": now.Add(-time.Hour).UTC().Format(time.RFC3339)}
+	pastDeadline := map[string]any{"deadline": now.Add(-time.Hour).UTC().Format(time.RFC3339)}
+
+	rels := []*core.RelationTuple{
+		tuple.MustWithCaveat(tuple.MustParse("document:first#reader@user:sarah"), "expiration", future),
+		tuple.MustWithCaveat(tuple.MustParse("document:first#reader@user:fred"), "expiration", past),
+		tuple.MustWithCaveat(tuple.MustParse("document:second#reader@user:fred"), "expiration", past),
+		// The bound of relationships without one of their own is given by each request.
+		tuple.MustWithCaveat(tuple.MustParse("document:first#reader@user:tom"), "expiration"),
+		// Requests may extend the deadline, so the relationship may grant access again.
+		tuple.MustWithCaveat(tuple.MustParse("document:first#editor@user:fred"), "deadline", pastDeadline),
+	}
+	_, err = ds.ReadWriteTx(ctx, func(rwt datastore.ReadWriteTransaction) error {
+		updates := make([]*core.RelationTupleUpdate, 0, len(rels))
+		for _, rel := range rels {
+			updates = append(updates, tuple.Create(rel))
+		}
+		return rwt.WriteRelationships(ctx, updates)
+	})
+	require.NoError(t, err)
+
+	deleted, err := DeleteExpiredRelationships(ctx, ds, now)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), deleted)
+
+	headRevision, err := ds.HeadRevision(ctx)
+	require.NoError(t, err)
+
+	it, err := ds.SnapshotReader(headRevision).QueryRelationships(ctx, datastore.RelationshipsFilter{ResourceType: "document"})
+	require.NoError(t, err)
+	defer it.Close()
+
+	var remaining []string
+	for tpl, err := it.Next(); tpl != nil; tpl, err = it.Next() {
+		require.NoError(t, err)
+		remaining = append(remaining, tuple.StringWithoutCaveat(tpl))
+	}
+	require.ElementsMatch(t, []string{
+		"document:first#reader@user:sarah",
+		"document:first#reader@user:tom",
+		"document:first#editor@user:fred",
+	}, remaining)
+
+	// Nothing further has expired.
+	deleted, err = DeleteExpiredRelationships(ctx, ds, now)
+	require.NoError(t, err)
+	require.Zero(t, deleted)
+}
@@ -0,0 +1,217 @@
+package relationships
+
+import (
+	"context"
+	"sort"
+
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// CheckNestingDepth returns an error if applying the updates would create a chain of more than
+// maxDepth nested relationships, each having as its subject the resource and relation of the
+// next, such as a chain of groups each a member of the next.
+//
+// Only updates writing a relationship with a subject set can create such a chain. For each, the
+// chains above its resource and below its subject are searched breadth first, one query per
+// level and type, and the search stops once the chains found reach the maximum depth, so that
+// at most maxDepth+1 levels are ever read. Like CheckConstraints, it must be called with the
+// transaction applying the updates, before they are written.
+func CheckNestingDepth(ctx context.Context, reader datastore.Reader, updates []*core.RelationTupleUpdate, maxDepth uint32) error {
+	for _, update := range updates {
+		if update.Operation == core.RelationTupleUpdate_DELETE || update.Tuple.Subject.Relation == tuple.Ellipsis {
+			continue
+		}
+
+		above, err := chainDepth(ctx, reader, update.Tuple.ResourceAndRelation, updates, parentsOf, maxDepth)
+		if err != nil {
+			return err
+		}
+
+		if above >= maxDepth {
+			return NewNestingDepthExceededError(update, maxDepth)
+		}
+
+		below, err := chainDepth(ctx, reader, update.Tuple.Subject, updates, childrenOf, maxDepth-above-1)
+		if err != nil {
+			return err
+		}
+
+		if above+1+below > maxDepth {
+			return NewNestingDepthExceededError(update, maxDepth)
+		}
+	}
+	return nil
+}
+
+// nestedFunc returns the objects and relations one level away from those of the frontier, in
+// one direction of a chain of nested relationships.
+type nestedFunc func(ctx context.Context, reader datastore.Reader, frontier []*core.ObjectAndRelation, updates []*core.RelationTupleUpdate) ([]*core.ObjectAndRelation, error)
+
+// chainDepth returns the depth of the longest chain of nested relationships starting from the
+// object and relation in the direction given, or limit+1 if it is deeper than the limit. Chains
+// containing a cycle are infinitely deep, and so always deeper than the limit.
+func chainDepth(
+	ctx context.Context,
+	reader datastore.Reader,
+	start *core.ObjectAndRelation,
+	updates []*core.RelationTupleUpdate,
+	nested nestedFunc,
+	limit uint32,
+) (uint32, error) {
+	frontier := []*core.ObjectAndRelation{start}
+	for depth := uint32(0); depth <= limit; depth++ {
+		next, err := nested(ctx, reader, frontier, updates)
+		if err != nil {
+			return 0, err
+		}
+		if len(next) == 0 {
+			return depth, nil
+		}
+		frontier = next
+	}
+	return limit + 1, nil
+}
+
+// parentsOf returns the resources and relations of the nested relationships whose subject is in
+// the frontier, after the updates are applied.
+func parentsOf(ctx context.Context, reader datastore.Reader, frontier []*core.ObjectAndRelation, updates []*core.RelationTupleUpdate) ([]*core.ObjectAndRelation, error) {
+	found := make(map[string]*core.ObjectAndRelation)
+	for _, group := range groupByTypeAndRelation(frontier) {
+		it, err := reader.ReverseQueryRelationships(ctx, datastore.SubjectsFilter{
+			SubjectType:        group.namespace,
+			OptionalSubjectIds: group.objectIDs,
+			RelationFilter:     datastore.SubjectRelationFilter{}.WithNonEllipsisRelation(group.relation),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		relationships, err := collectAfter(it, updates, func(tpl *core.RelationTuple) bool {
+			return group.contains(tpl.Subject)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, tpl := range relationships {
+			found[tuple.StringONR(tpl.ResourceAndRelation)] = tpl.ResourceAndRelation
+		}
+	}
+	return sortedONRs(found), nil
+}
+
+// childrenOf returns the subject sets of the relationships whose resource and relation is in the
+// frontier, after the updates are applied.
+func childrenOf(ctx context.Context, reader datastore.Reader, frontier []*core.ObjectAndRelation, updates []*core.RelationTupleUpdate) ([]*core.ObjectAndRelation, error) {
+	found := make(map[string]*core.ObjectAndRelation)
+	for _, group := range groupByTypeAndRelation(frontier) {
+		it, err := reader.QueryRelationships(ctx, datastore.RelationshipsFilter{
+			ResourceType:             group.namespace,
+			OptionalResourceIds:      group.objectIDs,
+			OptionalResourceRelation: group.relation,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		relationships, err := collectAfter(it, updates, func(tpl *core.RelationTuple) bool {
+			return group.contains(tpl.ResourceAndRelation)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, tpl := range relationships {
+			if tpl.Subject.Relation != tuple.Ellipsis {
+				found[tuple.StringONR(tpl.Subject)] = tpl.Subject
+			}
+		}
+	}
+	return sortedONRs(found), nil
+}
+
+// collectAfter returns the relationships of the iterator, after applying those updates whose
+// relationship matches the query of the iterator, which it closes.
+func collectAfter(it datastore.RelationshipIterator, updates []*core.RelationTupleUpdate, matches func(*core.RelationTuple) bool) (map[string]*core.RelationTuple, error) {
+	defer it.Close()
+
+	relationships := make(map[string]*core.RelationTuple)
+	for {
+		tpl, err := it.Next()
+		if err != nil {
+			return nil, err
+		}
+		if tpl == nil {
+			break
+		}
+
+		relationships[tuple.StringWithoutCaveat(tpl)] = tpl
+	}
+
+	for _, update := range updates {
+		if !matches(update.Tuple) {
+			continue
+		}
+
+		key := tuple.StringWithoutCaveat(update.Tuple)
+		if update.Operation == core.RelationTupleUpdate_DELETE {
+			delete(relationships, key)
+		} else {
+			relationships[key] = update.Tuple
+		}
+	}
+	return relationships, nil
+}
+
+// onrGroup is a set of objects of the same type, with the same relation.
+type onrGroup struct {
+	namespace string
+	relation  string
+	objectIDs []string
+	contained map[string]struct{}
+}
+
+func (g onrGroup) contains(onr *core.ObjectAndRelation) bool {
+	if onr.Namespace != g.namespace || onr.Relation != g.relation {
+		return false
+	}
+	_, ok := g.contained[onr.ObjectId]
+	return ok
+}
+
+func groupByTypeAndRelation(onrs []*core.ObjectAndRelation) []onrGroup {
+	var groups []onrGroup
+	indexes := make(map[string]int)
+	for _, onr := range onrs {
+		key := onr.Namespace + "#" + onr.Relation
+		index, ok := indexes[key]
+		if !ok {
+			index = len(groups)
+			indexes[key] = index
+			groups = append(groups, onrGroup{
+				namespace: onr.Namespace,
+				relation:  onr.Relation,
+				contained: make(map[string]struct{}),
+			})
+		}
+		groups[index].objectIDs = append(groups[index].objectIDs, onr.ObjectId)
+		groups[index].contained[onr.ObjectId] = struct{}{}
+	}
+	return groups
+}
+
+func sortedONRs(onrs map[string]*core.ObjectAndRelation) []*core.ObjectAndRelation {
+	keys := make([]string, 0, len(onrs))
+	for key := range onrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	sorted := make([]*core.ObjectAndRelation, 0, len(keys))
+	for _, key := range keys {
+		sorted = append(sorted, onrs[key])
+	}
+	return sorted
+}
@@ -0,0 +1,160 @@
+package relationships
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+func TestCheckNestingDepth(t *testing.T) {
+	tcs := []struct {
+		name          string
+		existing      []string
+		updates       []*core.RelationTupleUpdate
+		expectedError string
+	}{
+		{
+			"direct members only",
+			[]string{"group:first#member@user:tom"},
+			[]*core.RelationTupleUpdate{
+				tuple.Create(tuple.MustParse("group:first#member@user:sarah")),
+			},
+			"",
+		},
+		{
+			"nesting within the maximum",
+			[]string{"group:first#member@group:second#member"},
+			[]*core.RelationTupleUpdate{
+				tuple.Create(tuple.MustParse("group:second#member@group:third#member")),
+			},
+			"",
+		},
+		{
+			"nesting below a chain at the maximum",
+			[]string{
+				"group:first#member@group:second#member",
+				"group:second#member@group:third#member",
+			},
+			[]*core.RelationTupleUpdate{
+				tuple.Create(tuple.MustParse("group:third#member@group:fourth#member")),
+			},
+			"relationship `group:third#member@group:fourth#member` would create a chain of nested relationships deeper than the maximum of 2",
+		},
+		{
+			"nesting above a chain at the maximum",
+			[]string{
+				"group:second#member@group:third#member",
+				"group:third#member@group:fourth#member",
+			},
+			[]*core.RelationTupleUpdate{
+				tuple.Touch(tuple.MustParse("group:first#member@group:second#member")),
+			},
+			"relationship `group:first#member@group:second#member` would create a chain of nested relationships deeper than the maximum of 2",
+		},
+		{
+			"nesting joining two chains",
+			[]string{
+				"group:first#member@group:second#member",
+				"group:third#member@group:fourth#member",
+			},
+			[]*core.RelationTupleUpdate{
+				tuple.Create(tuple.MustParse("group:second#member@group:third#member")),
+			},
+			"relationship `group:second#member@group:third#member` would create a chain",
+		},
+		{
+			"nesting within the same batch",
+			nil,
+			[]*core.RelationTupleUpdate{
+				tuple.Create(tuple.MustParse("group:first#member@group:second#member")),
+				tuple.Create(tuple.MustParse("group:second#member@group:third#member")),
+				tuple.Create(tuple.MustParse("group:third#member@group:fourth#member")),
+			},
+			"would create a chain of nested relationships deeper than the maximum of 2",
+		},
+		{
+			"nesting replacing a deleted chain",
+			[]string{
+				"group:first#member@group:second#member",
+				"group:second#member@group:third#member",
+			},
+			[]*core.RelationTupleUpdate{
+				tuple.Delete(tuple.MustParse("group:first#member@group:second#member")),
+				tuple.Create(tuple.MustParse("group:third#member@group:fourth#member")),
+			},
+			"",
+		},
+		{
+			"cycle",
+			[]string{"group:first#member@group:second#member"},
+			[]*core.RelationTupleUpdate{
+				tuple.Create(tuple.MustParse("group:second#member@group:first#member")),
+			},
+			"relationship `group:second#member@group:first#member` would create a chain",
+		},
+		{
+			"chains through other relations",
+			[]string{
+				"group:first#member@group:second#manager",
+				"group:second#manager@group:third#member",
+			},
+			[]*core.RelationTupleUpdate{
+				tuple.Create(tuple.MustParse("group:third#member@group:fourth#member")),
+			},
+			"would create a chain of nested relationships deeper than the maximum of 2",
+		},
+		{
+			"unrelated chains",
+			[]string{
+				"group:first#member@group:second#member",
+				"group:second#member@group:third#member",
+			},
+			[]*core.RelationTupleUpdate{
+				tuple.Create(tuple.MustParse("group:fourth#member@group:fifth#member")),
+			},
+			"",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			ds, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+			require.NoError(t, err)
+
+			writeSchema(t, ds, `
+				definition user {}
+				definition group {
+					relation member: user | group#member | group#manager
+					relation manager: user | group#member
+				}
+			`)
+
+			revision, err := ds.ReadWriteTx(ctx, func(rwt datastore.ReadWriteTransaction) error {
+				updates := make([]*core.RelationTupleUpdate, 0, len(tc.existing))
+				for _, rel := range tc.existing {
+					updates = append(updates, tuple.Create(tuple.MustParse(rel)))
+				}
+				return rwt.WriteRelationships(ctx, updates)
+			})
+			require.NoError(t, err)
+
+			err = CheckNestingDepth(ctx, ds.SnapshotReader(revision), tc.updates, 2)
+			if tc.expectedError == "" {
+				require.NoError(t, err)
+				return
+			}
+
+			require.ErrorContains(t, err, tc.expectedError)
+			require.Equal(t, codes.FailedPrecondition, status.Code(err))
+		})
+	}
+}
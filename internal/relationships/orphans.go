@@ -0,0 +1,263 @@
+package relationships
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	log "github.com/authzed/spicedb/internal/logging"
+	"github.com/authzed/spicedb/internal/namespace"
+	"github.com/authzed/spicedb/pkg/datastore"
+	ns "github.com/authzed/spicedb/pkg/namespace"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+	"github.com/authzed/spicedb/pkg/util"
+)
+
+// OrphanReason is the reason a relationship is no longer valid under the schema.
+type OrphanReason string
+
+const (
+	// OrphanReasonMissingDefinition indicates that the object definition of the resource
+	// no longer exists.
+	OrphanReasonMissingDefinition OrphanReason = "missing-definition"
+
+	// OrphanReasonMissingRelation indicates that the relation of the resource no longer exists.
+	OrphanReasonMissingRelation OrphanReason = "missing-relation"
+
+	// OrphanReasonPermission indicates that the relation of the resource is now a permission.
+	OrphanReasonPermission OrphanReason = "permission"
+
+	// OrphanReasonMissingSubjectDefinition indicates that the object definition of the subject
+	// no longer exists.
+	OrphanReasonMissingSubjectDefinition OrphanReason = "missing-subject-definition"
+
+	// OrphanReasonDisallowedSubject indicates that the subject type (or caveat) of the
+	// relationship is no longer allowed on the relation.
+	OrphanReasonDisallowedSubject OrphanReason = "disallowed-subject"
+)
+
+// OrphanReasons are all the reasons for which relationships can be orphaned.
+var OrphanReasons = []OrphanReason{
+	OrphanReasonMissingDefinition,
+	OrphanReasonMissingRelation,
+	OrphanReasonPermission,
+	OrphanReasonMissingSubjectDefinition,
+	OrphanReasonDisallowedSubject,
+}
+
+// OrphanCounts are the number of orphaned relationships found, by reason.
+type OrphanCounts map[OrphanReason]uint64
+
+// OrphanedRelationshipFunc is invoked for each orphaned relationship found.
+type OrphanedRelationshipFunc func(tpl *core.RelationTuple, reason OrphanReason) error
+
+var orphanedRelationshipsGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "spicedb",
+	Subsystem: "datastore",
+	Name:      "orphaned_relationships",
+	Help:      "The number of relationships found by the last scan which are no longer valid under the schema.",
+}, []string{"reason"})
+
+// RegisterOrphanMetrics registers orphaned relationship metrics to the default registry.
+func RegisterOrphanMetrics() error {
+	return prometheus.Register(orphanedRelationshipsGauge)
+}
+
+// FindOrphanedRelationships scans the relationships under every object definition in the schema,
+// as well as those under the given removed definitions, invoking fn for each relationship that is
+// no longer valid under the schema.
+//
+// NOTE: relationships under removed definitions can only be found if the definitions are given,
+// as the datastore cannot list the resource types of the relationships it holds.
+func FindOrphanedRelationships(
+	ctx context.Context,
+	reader datastore.Reader,
+	removedDefinitions []string,
+	fn OrphanedRelationshipFunc,
+) error {
+	nsDefs, err := reader.ListAllNamespaces(ctx)
+	if err != nil {
+		return err
+	}
+
+	typeSystems := make(map[string]*namespace.TypeSystem, len(nsDefs))
+	resolver := namespace.ResolverForDatastoreReader(reader)
+	for _, nsDef := range nsDefs {
+		ts, err := namespace.NewNamespaceTypeSystem(nsDef.Definition, resolver)
+		if err != nil {
+			return err
+		}
+		typeSystems[nsDef.Definition.Name] = ts
+	}
+
+	toScan := util.NewSet[string]()
+	for _, nsDef := range nsDefs {
+		toScan.Add(nsDef.Definition.Name)
+	}
+	toScan.Extend(removedDefinitions)
+
+	return toScan.ForEach(func(namespaceName string) error {
+		it, err := reader.QueryRelationships(ctx, datastore.RelationshipsFilter{ResourceType: namespaceName})
+		if err != nil {
+			return err
+		}
+		defer it.Close()
+
+		for {
+			tpl, err := it.Next()
+			if err != nil {
+				return err
+			}
+			if tpl == nil {
+				break
+			}
+
+			reason, err := orphanReason(tpl, typeSystems)
+			if err != nil {
+				return err
+			}
+
+			if reason != "" {
+				if err := fn(tpl, reason); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func orphanReason(tpl *core.RelationTuple, typeSystems map[string]*namespace.TypeSystem) (OrphanReason, error) {
+	ts, ok := typeSystems[tpl.ResourceAndRelation.Namespace]
+	if !ok {
+		return OrphanReasonMissingDefinition, nil
+	}
+
+	if !ts.HasRelation(tpl.ResourceAndRelation.Relation) {
+		return OrphanReasonMissingRelation, nil
+	}
+
+	if ts.IsPermission(tpl.ResourceAndRelation.Relation) {
+		return OrphanReasonPermission, nil
+	}
+
+	if _, ok := typeSystems[tpl.Subject.Namespace]; !ok {
+		return OrphanReasonMissingSubjectDefinition, nil
+	}
+
+	var caveat *core.AllowedCaveat
+	if tpl.Caveat != nil {
+		caveat = ns.AllowedCaveat(tpl.Caveat.CaveatName)
+	}
+
+	var relationToCheck *core.AllowedRelation
+	if tpl.Subject.ObjectId == tuple.PublicWildcard {
+		relationToCheck = ns.AllowedPublicNamespaceWithCaveat(tpl.Subject.Namespace, caveat)
+	} else {
+		relationToCheck = ns.AllowedRelationWithCaveat(tpl.Subject.Namespace, tpl.Subject.Relation, caveat)
+	}
+
+	isAllowed, err := ts.HasAllowedRelation(tpl.ResourceAndRelation.Relation, relationToCheck)
+	if err != nil {
+		return "", err
+	}
+
+	if isAllowed == namespace.AllowedRelationNotValid {
+		return OrphanReasonDisallowedSubject, nil
+	}
+	return "", nil
+}
+
+// DeleteOrphanedRelationships finds the relationships that are no longer valid under the schema,
+// as per FindOrphanedRelationships, and deletes them unless dryRun is set. The relationships are
+// found and deleted within a single transaction, so that concurrent schema changes cannot cause
+// valid relationships to be deleted.
+func DeleteOrphanedRelationships(
+	ctx context.Context,
+	ds datastore.Datastore,
+	removedDefinitions []string,
+	dryRun bool,
+	fn OrphanedRelationshipFunc,
+) (OrphanCounts, datastore.Revision, error) {
+	counts := OrphanCounts{}
+	collect := func(reader datastore.Reader) ([]*core.RelationTupleUpdate, error) {
+		var deletes []*core.RelationTupleUpdate
+		err := FindOrphanedRelationships(ctx, reader, removedDefinitions, func(tpl *core.RelationTuple, reason OrphanReason) error {
+			counts[reason]++
+			deletes = append(deletes, tuple.Delete(tpl))
+			if fn != nil {
+				return fn(tpl, reason)
+			}
+			return nil
+		})
+		return deletes, err
+	}
+
+	if dryRun {
+		headRevision, err := ds.HeadRevision(ctx)
+		if err != nil {
+			return nil, datastore.NoRevision, err
+		}
+
+		_, err = collect(ds.SnapshotReader(headRevision))
+		return counts, headRevision, err
+	}
+
+	revision, err := ds.ReadWriteTx(ctx, func(rwt datastore.ReadWriteTransaction) error {
+		// Reset the counts, in case the transaction is retried.
+		counts = OrphanCounts{}
+
+		deletes, err := collect(rwt)
+		if err != nil {
+			return err
+		}
+
+		if len(deletes) == 0 {
+			return nil
+		}
+		return rwt.WriteRelationships(ctx, deletes)
+	})
+	return counts, revision, err
+}
+
+// StartOrphanScanner loops until the context is canceled, scanning for orphaned relationships on
+// the provided interval and reporting the number found via metrics.
+func StartOrphanScanner(ctx context.Context, ds datastore.Datastore, interval time.Duration) error {
+	log.Ctx(ctx).Info().
+		Dur("interval", interval).
+		Msg("orphaned relationship scanner started")
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Ctx(ctx).Info().
+				Msg("shutting down orphaned relationship scanner")
+			return nil
+
+		case <-time.After(interval):
+			start := time.Now()
+			counts, _, err := DeleteOrphanedRelationships(ctx, ds, nil, true, nil)
+			if err != nil {
+				log.Ctx(ctx).Warn().Err(err).Msg("error scanning for orphaned relationships")
+				continue
+			}
+
+			total := uint64(0)
+			for _, reason := range OrphanReasons {
+				orphanedRelationshipsGauge.WithLabelValues(string(reason)).Set(float64(counts[reason]))
+				total += counts[reason]
+			}
+
+			logEvent := log.Ctx(ctx).Debug()
+			if total > 0 {
+				logEvent = log.Ctx(ctx).Warn()
+			}
+			logEvent.
+				Dur("duration", time.Since(start)).
+				Uint64("orphaned", total).
+				Msg("scanned for orphaned relationships")
+		}
+	}
+}
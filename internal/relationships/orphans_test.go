@@ -0,0 +1,115 @@
+package relationships
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
+	"github.com/authzed/spicedb/pkg/schemadsl/input"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+func writeSchema(t *testing.T, ds datastore.Datastore, schema string) {
+	ctx := context.Background()
+	emptyDefaultPrefix := ""
+	compiled, err := compiler.Compile(compiler.InputSchema{
+		Source:       input.Source("schema"),
+		SchemaString: schema,
+	}, &emptyDefaultPrefix)
+	require.NoError(t, err)
+
+	_, err = ds.ReadWriteTx(ctx, func(rwt datastore.ReadWriteTransaction) error {
+		if len(compiled.CaveatDefinitions) > 0 {
+			if err := rwt.WriteCaveats(ctx, compiled.CaveatDefinitions); err != nil {
+				return err
+			}
+		}
+		return rwt.WriteNamespaces(ctx, compiled.ObjectDefinitions...)
+	})
+	require.NoError(t, err)
+}
+
+func TestDeleteOrphanedRelationships(t *testing.T) {
+	ctx := context.Background()
+	ds, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	require.NoError(t, err)
+
+	rels := []string{
+		"document:valid#viewer@user:tom",
+		"document:teamviewer#viewer@team:eng#member",
+		"document:first#editor@user:tom",
+		"document:second#owner@user:tom",
+		"folder:first#viewer@user:tom",
+		"team:eng#member@user:tom",
+	}
+	_, err = ds.ReadWriteTx(ctx, func(rwt datastore.ReadWriteTransaction) error {
+		updates := make([]*core.RelationTupleUpdate, 0, len(rels))
+		for _, rel := range rels {
+			updates = append(updates, tuple.Create(tuple.MustParse(rel)))
+		}
+		return rwt.WriteRelationships(ctx, updates)
+	})
+	require.NoError(t, err)
+
+	// Write a schema under which the relationships above are no longer all valid, as can occur if
+	// relationships are written concurrently with schema changes.
+	writeSchema(t, ds, `
+		definition user {}
+		definition document {
+			relation viewer: user
+			permission owner = viewer
+		}
+	`)
+
+	found := map[string]OrphanReason{}
+	collect := func(tpl *core.RelationTuple, reason OrphanReason) error {
+		found[tuple.MustString(tpl)] = reason
+		return nil
+	}
+
+	counts, _, err := DeleteOrphanedRelationships(ctx, ds, []string{"folder"}, true, collect)
+	require.NoError(t, err)
+	require.Equal(t, map[string]OrphanReason{
+		"document:teamviewer#viewer@team:eng#member": OrphanReasonMissingSubjectDefinition,
+		"document:first#editor@user:tom":             OrphanReasonMissingRelation,
+		"document:second#owner@user:tom":             OrphanReasonPermission,
+		"folder:first#viewer@user:tom":               OrphanReasonMissingDefinition,
+	}, found)
+	require.Equal(t, uint64(1), counts[OrphanReasonMissingRelation])
+
+	// Nothing should have been deleted in the dry run, so the same relationships are deleted now.
+	found = map[string]OrphanReason{}
+	counts, revision, err := DeleteOrphanedRelationships(ctx, ds, []string{"folder"}, false, collect)
+	require.NoError(t, err)
+	require.Len(t, found, 4)
+	require.Equal(t, uint64(1), counts[OrphanReasonMissingDefinition])
+
+	it, err := ds.SnapshotReader(revision).QueryRelationships(ctx, datastore.RelationshipsFilter{ResourceType: "document"})
+	require.NoError(t, err)
+	defer it.Close()
+
+	var remaining []string
+	for {
+		tpl, err := it.Next()
+		require.NoError(t, err)
+		if tpl == nil {
+			break
+		}
+
+		remaining = append(remaining, tuple.MustString(tpl))
+	}
+	require.Equal(t, []string{"document:valid#viewer@user:tom"}, remaining)
+
+	// Relationships under removed definitions are only found when the definitions are given.
+	found = map[string]OrphanReason{}
+	_, _, err = DeleteOrphanedRelationships(ctx, ds, []string{"folder", "team"}, true, collect)
+	require.NoError(t, err)
+	require.Equal(t, map[string]OrphanReason{
+		"team:eng#member@user:tom": OrphanReasonMissingDefinition,
+	}, found)
+}
@@ -0,0 +1,55 @@
+package relationships
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
+	log "github.com/authzed/spicedb/internal/logging"
+)
+
+var purgedRelationshipsCounter = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "spicedb",
+	Subsystem: "datastore",
+	Name:      "deleted_relationships_purged_total",
+	Help:      "The number of deleted relationships purged once their retention period for restoring had passed.",
+})
+
+// RegisterPurgeMetrics registers deleted relationship purge metrics to the default registry.
+func RegisterPurgeMetrics() error {
+	return prometheus.Register(purgedRelationshipsCounter)
+}
+
+// StartDeletedRelationshipPurger loops until the context is canceled, purging the deleted
+// relationships retained for longer than the retention period on the provided interval.
+func StartDeletedRelationshipPurger(ctx context.Context, store common.DeletedRelationshipStore, retention, interval time.Duration) error {
+	log.Ctx(ctx).Info().
+		Dur("interval", interval).
+		Dur("retention", retention).
+		Msg("deleted relationship purger started")
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Ctx(ctx).Info().
+				Msg("shutting down deleted relationship purger")
+			return nil
+
+		case <-time.After(interval):
+			start := time.Now()
+			purged, err := store.PurgeDeletedRelationships(ctx, retention)
+			if err != nil {
+				log.Ctx(ctx).Warn().Err(err).Msg("error purging deleted relationships")
+				continue
+			}
+			purgedRelationshipsCounter.Add(float64(purged))
+
+			log.Ctx(ctx).Debug().
+				Dur("duration", time.Since(start)).
+				Int64("purged", purged).
+				Msg("purged deleted relationships")
+		}
+	}
+}
@@ -84,7 +84,11 @@ func ValidateRelationshipUpdates(
 			return err
 		}
 
-		// Validate that the relationship is not writing to a permission.
+		// Validate that the relationship is not writing to an alias or a permission.
+		if aliasOf := ts.AliasOf(update.Tuple.ResourceAndRelation.Relation); aliasOf != "" {
+			return NewCannotWriteToAliasError(update, aliasOf)
+		}
+
 		if ts.IsPermission(update.Tuple.ResourceAndRelation.Relation) {
 			return NewCannotWriteToPermissionError(update)
 		}
@@ -143,6 +147,150 @@ func ValidateRelationshipUpdates(
 	return nil
 }
 
+// ValidateAllRelationshipUpdates performs strict validation on every given relationship update
+// against the schema visible to the reader, returning an ErrInvalidRelationshipUpdates describing
+// every invalid update found, rather than only the first. Unlike ValidateRelationshipUpdates, the
+// caveat referenced by an update must exist even if no caveat context is given.
+func ValidateAllRelationshipUpdates(
+	ctx context.Context,
+	reader datastore.Reader,
+	updates []*core.RelationTupleUpdate,
+) error {
+	// Load all referenced definitions and caveats up front, so that any error found when checking
+	// an update is a validation error for that update, rather than an error reading the schema.
+	namespaceNames := util.NewSet[string]()
+	caveatNames := util.NewSet[string]()
+	for _, update := range updates {
+		namespaceNames.Add(update.Tuple.ResourceAndRelation.Namespace)
+		namespaceNames.Add(update.Tuple.Subject.Namespace)
+		if update.Tuple.Caveat != nil && update.Tuple.Caveat.CaveatName != "" {
+			caveatNames.Add(update.Tuple.Caveat.CaveatName)
+		}
+	}
+
+	nsDefs, err := reader.LookupNamespacesWithNames(ctx, namespaceNames.AsSlice())
+	if err != nil {
+		return err
+	}
+
+	typeSystems := make(map[string]*namespace.TypeSystem, len(nsDefs))
+	resolver := namespace.ResolverForDatastoreReader(reader)
+	for _, nsDef := range nsDefs {
+		ts, err := namespace.NewNamespaceTypeSystem(nsDef.Definition, resolver)
+		if err != nil {
+			return err
+		}
+		typeSystems[nsDef.Definition.Name] = ts
+	}
+
+	caveatDefs := make(map[string]*core.CaveatDefinition, caveatNames.Len())
+	if !caveatNames.IsEmpty() {
+		foundCaveats, err := reader.LookupCaveatsWithNames(ctx, caveatNames.AsSlice())
+		if err != nil {
+			return err
+		}
+
+		for _, caveatDef := range foundCaveats {
+			caveatDefs[caveatDef.Definition.Name] = caveatDef.Definition
+		}
+	}
+
+	var invalid []InvalidUpdate
+	for index, update := range updates {
+		if err := validateUpdate(update, typeSystems, caveatDefs); err != nil {
+			invalid = append(invalid, InvalidUpdate{Index: index, Update: update, Err: err})
+		}
+	}
+
+	if len(invalid) > 0 {
+		return NewInvalidRelationshipUpdatesError(invalid)
+	}
+	return nil
+}
+
+func validateUpdate(
+	update *core.RelationTupleUpdate,
+	typeSystems map[string]*namespace.TypeSystem,
+	caveatDefs map[string]*core.CaveatDefinition,
+) error {
+	resource := update.Tuple.ResourceAndRelation
+	subject := update.Tuple.Subject
+
+	if err := tuple.ValidateResourceID(resource.ObjectId); err != nil {
+		return err
+	}
+
+	if err := tuple.ValidateSubjectID(subject.ObjectId); err != nil {
+		return err
+	}
+
+	ts, ok := typeSystems[resource.Namespace]
+	if !ok {
+		return datastore.NewNamespaceNotFoundErr(resource.Namespace)
+	}
+
+	if !ts.HasRelation(resource.Relation) {
+		return namespace.NewRelationNotFoundErr(resource.Namespace, resource.Relation)
+	}
+
+	subjectTS, ok := typeSystems[subject.Namespace]
+	if !ok {
+		return datastore.NewNamespaceNotFoundErr(subject.Namespace)
+	}
+
+	if subject.Relation != datastore.Ellipsis && !subjectTS.HasRelation(subject.Relation) {
+		return namespace.NewRelationNotFoundErr(subject.Namespace, subject.Relation)
+	}
+
+	if aliasOf := ts.AliasOf(resource.Relation); aliasOf != "" {
+		return NewCannotWriteToAliasError(update, aliasOf)
+	}
+
+	if ts.IsPermission(resource.Relation) {
+		return NewCannotWriteToPermissionError(update)
+	}
+
+	var caveat *core.AllowedCaveat
+	if update.Tuple.Caveat != nil {
+		caveat = ns.AllowedCaveat(update.Tuple.Caveat.CaveatName)
+	}
+
+	var relationToCheck *core.AllowedRelation
+	if subject.ObjectId == tuple.PublicWildcard {
+		relationToCheck = ns.AllowedPublicNamespaceWithCaveat(subject.Namespace, caveat)
+	} else {
+		relationToCheck = ns.AllowedRelationWithCaveat(subject.Namespace, subject.Relation, caveat)
+	}
+
+	isAllowed, err := ts.HasAllowedRelation(resource.Relation, relationToCheck)
+	if err != nil {
+		return err
+	}
+
+	if isAllowed != namespace.AllowedRelationValid {
+		return NewInvalidSubjectTypeError(update, relationToCheck)
+	}
+
+	if update.Tuple.Caveat == nil || update.Tuple.Caveat.CaveatName == "" {
+		return nil
+	}
+
+	caveatDef, ok := caveatDefs[update.Tuple.Caveat.CaveatName]
+	if !ok {
+		return NewCaveatNotFoundError(update)
+	}
+
+	if hasNonEmptyCaveatContext(update) {
+		_, err := caveats.ConvertContextToParameters(
+			update.Tuple.Caveat.Context.AsMap(),
+			caveatDef.ParameterTypes,
+			caveats.ErrorForUnknownParameters,
+		)
+		return err
+	}
+	return nil
+}
+
 func hasNonEmptyCaveatContext(update *core.RelationTupleUpdate) bool {
 	return update.Tuple.Caveat != nil &&
 		update.Tuple.Caveat.CaveatName != "" &&
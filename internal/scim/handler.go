@@ -0,0 +1,637 @@
+// Package scim implements a SCIM 2.0 endpoint which identity providers can provision users and
+// groups against, translating group memberships into relationships.
+//
+// Each member of a group is written as a relationship of the form
+// `<group type>:<group id>#<member relation>@<user type>:<user id>`, carrying the SourceLabel.
+// Users are not stored: every user exists as far as the endpoint is concerned, and deprovisioning
+// a user removes all of its memberships.
+package scim
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	log "github.com/authzed/spicedb/internal/logging"
+	"github.com/authzed/spicedb/pkg/datastore"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// BasePath is the path under which the SCIM endpoint is served.
+const BasePath = "/scim/v2"
+
+// Config configures how SCIM resources are translated into relationships.
+type Config struct {
+	// GroupType is the object definition of groups.
+	GroupType string
+
+	// MemberRelation is the relation of GroupType under which members are written.
+	MemberRelation string
+
+	// UserType is the object definition of users, the subjects of memberships.
+	UserType string
+
+	// DryRun, if true, logs the relationship updates which would be made instead of making them.
+	DryRun bool
+
+	// BearerTokens are the tokens accepted to authenticate requests.
+	BearerTokens []string
+}
+
+type handler struct {
+	syncer syncer
+}
+
+// NewHandler returns an http.Handler serving the SCIM endpoint under BasePath, syncing group
+// memberships into the given datastore.
+func NewHandler(ds datastore.Datastore, config Config) (http.Handler, error) {
+	if config.GroupType == "" || config.MemberRelation == "" || config.UserType == "" {
+		return nil, fmt.Errorf("scim group type, member relation and user type must all be specified")
+	}
+
+	if len(config.BearerTokens) == 0 {
+		return nil, fmt.Errorf("a bearer token must be provided to authenticate scim requests")
+	}
+
+	return &handler{syncer{ds, config}}, nil
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticated(r) {
+		writeError(w, requestError{http.StatusUnauthorized, "", "missing or invalid bearer token"})
+		return
+	}
+
+	if !strings.HasPrefix(r.URL.Path, BasePath) {
+		writeError(w, requestError{http.StatusNotFound, "", "not found"})
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, BasePath)
+
+	resourceType, id, _ := strings.Cut(strings.Trim(path, "/"), "/")
+
+	var (
+		status int
+		body   any
+		err    error
+	)
+	switch {
+	case resourceType == "Groups" && id == "" && r.Method == http.MethodGet:
+		status, body, err = h.listGroups(r)
+	case resourceType == "Groups" && id == "" && r.Method == http.MethodPost:
+		status, body, err = h.createGroup(r)
+	case resourceType == "Groups" && id != "" && r.Method == http.MethodGet:
+		status, body, err = h.getGroup(r, id)
+	case resourceType == "Groups" && id != "" && r.Method == http.MethodPut:
+		status, body, err = h.replaceGroup(r, id)
+	case resourceType == "Groups" && id != "" && r.Method == http.MethodPatch:
+		status, body, err = h.patchGroup(r, id)
+	case resourceType == "Groups" && id != "" && r.Method == http.MethodDelete:
+		status, body, err = h.deleteGroup(r, id)
+
+	case resourceType == "Users" && id == "" && r.Method == http.MethodGet:
+		status, body, err = h.listUsers(r)
+	case resourceType == "Users" && id == "" && r.Method == http.MethodPost:
+		status, body, err = h.createUser(r)
+	case resourceType == "Users" && id != "" && r.Method == http.MethodGet:
+		status, body, err = h.getUser(id)
+	case resourceType == "Users" && id != "" && (r.Method == http.MethodPut || r.Method == http.MethodPatch):
+		status, body, err = h.updateUser(r, id)
+	case resourceType == "Users" && id != "" && r.Method == http.MethodDelete:
+		status, body, err = h.deleteUser(r, id)
+
+	case resourceType == "ServiceProviderConfig" && id == "" && r.Method == http.MethodGet:
+		status, body = http.StatusOK, serviceProviderConfig
+
+	default:
+		err = requestError{http.StatusNotFound, "", fmt.Sprintf("no such endpoint: %s %s", r.Method, r.URL.Path)}
+	}
+
+	if err != nil {
+		var reqErr requestError
+		if !errors.As(err, &reqErr) {
+			log.Ctx(r.Context()).Err(err).Str("path", r.URL.Path).Msg("scim request failed")
+			reqErr = requestError{http.StatusInternalServerError, "", "internal error"}
+		}
+		writeError(w, reqErr)
+		return
+	}
+
+	writeJSON(w, status, body)
+}
+
+func (h *handler) authenticated(r *http.Request) bool {
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return false
+	}
+	token := strings.TrimPrefix(authorization, "Bearer ")
+
+	for _, bearerToken := range h.syncer.config.BearerTokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(bearerToken)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *handler) listGroups(r *http.Request) (int, any, error) {
+	var groupIDs []string
+	if filter := r.URL.Query().Get("filter"); filter != "" {
+		attribute, value, err := parseEqualityFilter(filter)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		if attribute != "id" && attribute != "displayname" && attribute != "externalid" {
+			return 0, nil, invalidFilterErr("groups cannot be filtered by `%s`", attribute)
+		}
+
+		groupIDs = []string{value}
+	}
+
+	memberships, err := h.syncer.groupMembers(r.Context(), groupIDs...)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// A group named in the filter always exists, even without members.
+	for _, groupID := range groupIDs {
+		if tuple.ValidateResourceID(groupID) == nil {
+			if _, ok := memberships[groupID]; !ok {
+				memberships[groupID] = nil
+			}
+		}
+	}
+
+	sortedIDs := make([]string, 0, len(memberships))
+	for groupID := range memberships {
+		sortedIDs = append(sortedIDs, groupID)
+	}
+	sort.Strings(sortedIDs)
+
+	resources := make([]any, 0, len(sortedIDs))
+	for _, groupID := range sortedIDs {
+		resources = append(resources, groupResource(groupID, memberships[groupID]))
+	}
+	return http.StatusOK, listOf(resources), nil
+}
+
+func (h *handler) createGroup(r *http.Request) (int, any, error) {
+	var g group
+	if err := readJSON(r, &g); err != nil {
+		return 0, nil, err
+	}
+
+	groupID := g.ExternalID
+	if groupID == "" {
+		groupID = g.DisplayName
+	}
+	if err := validateGroupID(groupID); err != nil {
+		return 0, nil, err
+	}
+
+	desired, err := memberIDs(g.Members)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	members, err := h.reconcile(r, groupID, func(_ []string) ([]string, error) { return desired, nil })
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, groupResource(groupID, members), nil
+}
+
+func (h *handler) getGroup(r *http.Request, groupID string) (int, any, error) {
+	if err := validateGroupID(groupID); err != nil {
+		return 0, nil, err
+	}
+
+	memberships, err := h.syncer.groupMembers(r.Context(), groupID)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, groupResource(groupID, memberships[groupID]), nil
+}
+
+func (h *handler) replaceGroup(r *http.Request, groupID string) (int, any, error) {
+	if err := validateGroupID(groupID); err != nil {
+		return 0, nil, err
+	}
+
+	var g group
+	if err := readJSON(r, &g); err != nil {
+		return 0, nil, err
+	}
+
+	desired, err := memberIDs(g.Members)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	members, err := h.reconcile(r, groupID, func(_ []string) ([]string, error) { return desired, nil })
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, groupResource(groupID, members), nil
+}
+
+func (h *handler) patchGroup(r *http.Request, groupID string) (int, any, error) {
+	if err := validateGroupID(groupID); err != nil {
+		return 0, nil, err
+	}
+
+	var patch patchRequest
+	if err := readJSON(r, &patch); err != nil {
+		return 0, nil, err
+	}
+
+	members, err := h.reconcile(r, groupID, func(current []string) ([]string, error) {
+		return applyMemberOperations(current, patch.Operations)
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, groupResource(groupID, members), nil
+}
+
+func (h *handler) deleteGroup(r *http.Request, groupID string) (int, any, error) {
+	if err := validateGroupID(groupID); err != nil {
+		return 0, nil, err
+	}
+
+	if _, err := h.reconcile(r, groupID, func(_ []string) ([]string, error) { return nil, nil }); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusNoContent, nil, nil
+}
+
+// reconcile brings the members of the group to those computed by desiredFunc, returning the
+// resulting members.
+func (h *handler) reconcile(r *http.Request, groupID string, desiredFunc func(current []string) ([]string, error)) ([]string, error) {
+	var members []string
+	plan := h.syncer.reconcileGroup(groupID, func(current []string) ([]string, error) {
+		desired, err := desiredFunc(current)
+		members = desired
+		return desired, err
+	})
+
+	if _, err := h.syncer.apply(r.Context(), plan); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(members)
+	return members, nil
+}
+
+func (h *handler) listUsers(r *http.Request) (int, any, error) {
+	filter := r.URL.Query().Get("filter")
+	if filter != "" {
+		attribute, value, err := parseEqualityFilter(filter)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		if attribute != "id" && attribute != "username" && attribute != "externalid" {
+			return 0, nil, invalidFilterErr("users cannot be filtered by `%s`", attribute)
+		}
+
+		// As users are not stored, any valid user exists.
+		if tuple.ValidateSubjectID(value) != nil {
+			return http.StatusOK, listOf(nil), nil
+		}
+		return http.StatusOK, listOf([]any{userResource(value, true)}), nil
+	}
+
+	memberships, err := h.syncer.groupMembers(r.Context())
+	if err != nil {
+		return 0, nil, err
+	}
+
+	userIDs := make(map[string]struct{})
+	for _, members := range memberships {
+		for _, userID := range members {
+			userIDs[userID] = struct{}{}
+		}
+	}
+
+	sortedIDs := make([]string, 0, len(userIDs))
+	for userID := range userIDs {
+		sortedIDs = append(sortedIDs, userID)
+	}
+	sort.Strings(sortedIDs)
+
+	resources := make([]any, 0, len(sortedIDs))
+	for _, userID := range sortedIDs {
+		resources = append(resources, userResource(userID, true))
+	}
+	return http.StatusOK, listOf(resources), nil
+}
+
+func (h *handler) createUser(r *http.Request) (int, any, error) {
+	var u user
+	if err := readJSON(r, &u); err != nil {
+		return 0, nil, err
+	}
+
+	if err := validateUserID(u.UserName); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, userResource(u.UserName, true), nil
+}
+
+func (h *handler) getUser(userID string) (int, any, error) {
+	if err := validateUserID(userID); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, userResource(userID, true), nil
+}
+
+// updateUser handles both replacing and patching a user, of which only deactivation has any
+// effect: deactivated users are removed from all groups.
+func (h *handler) updateUser(r *http.Request, userID string) (int, any, error) {
+	if err := validateUserID(userID); err != nil {
+		return 0, nil, err
+	}
+
+	active := true
+	if r.Method == http.MethodPut {
+		var u user
+		if err := readJSON(r, &u); err != nil {
+			return 0, nil, err
+		}
+		active = u.Active == nil || *u.Active
+	} else {
+		var patch patchRequest
+		if err := readJSON(r, &patch); err != nil {
+			return 0, nil, err
+		}
+		active = activeAfterOperations(patch.Operations)
+	}
+
+	if !active {
+		if _, err := h.syncer.apply(r.Context(), h.syncer.removeUser(userID)); err != nil {
+			return 0, nil, err
+		}
+	}
+	return http.StatusOK, userResource(userID, active), nil
+}
+
+func (h *handler) deleteUser(r *http.Request, userID string) (int, any, error) {
+	if err := validateUserID(userID); err != nil {
+		return 0, nil, err
+	}
+
+	if _, err := h.syncer.apply(r.Context(), h.syncer.removeUser(userID)); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusNoContent, nil, nil
+}
+
+// applyMemberOperations applies the member operations of a patch to the given members,
+// returning the resulting members. Operations on other attributes are ignored.
+func applyMemberOperations(current []string, operations []patchOperation) ([]string, error) {
+	members := make(map[string]struct{}, len(current))
+	for _, userID := range current {
+		members[userID] = struct{}{}
+	}
+
+	for _, operation := range operations {
+		op := strings.ToLower(operation.Op)
+		path := strings.TrimSpace(operation.Path)
+
+		var values []member
+		switch {
+		case strings.EqualFold(path, "members"):
+			if operation.Value != nil {
+				if err := decodeValue(operation.Value, &values); err != nil {
+					return nil, err
+				}
+			}
+
+		case path == "":
+			// Without a path, the value holds the attributes to change.
+			var attributes struct {
+				Members *[]member `json:"members"`
+			}
+			if err := decodeValue(operation.Value, &attributes); err != nil {
+				return nil, err
+			}
+			if attributes.Members == nil {
+				continue
+			}
+			values = *attributes.Members
+
+		case memberPathRegex.MatchString(path):
+			if op != "remove" {
+				return nil, invalidPathErr("path `%s` can only be used to remove members", path)
+			}
+			values = []member{{Value: memberPathRegex.FindStringSubmatch(path)[1]}}
+
+		default:
+			// Operations on other attributes, such as the display name, do not affect members.
+			continue
+		}
+
+		userIDs, err := memberIDs(values)
+		if err != nil {
+			return nil, err
+		}
+
+		switch op {
+		case "add":
+			for _, userID := range userIDs {
+				members[userID] = struct{}{}
+			}
+
+		case "remove":
+			if len(userIDs) == 0 && operation.Value == nil {
+				members = make(map[string]struct{})
+			}
+			for _, userID := range userIDs {
+				delete(members, userID)
+			}
+
+		case "replace":
+			members = make(map[string]struct{}, len(userIDs))
+			for _, userID := range userIDs {
+				members[userID] = struct{}{}
+			}
+
+		default:
+			return nil, invalidSyntaxErr("unknown patch operation `%s`", operation.Op)
+		}
+	}
+
+	result := make([]string, 0, len(members))
+	for userID := range members {
+		result = append(result, userID)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// activeAfterOperations returns whether a user is active after the operations of a patch.
+func activeAfterOperations(operations []patchOperation) bool {
+	active := true
+	for _, operation := range operations {
+		if strings.ToLower(operation.Op) == "remove" {
+			continue
+		}
+
+		var value any
+		switch {
+		case strings.EqualFold(operation.Path, "active"):
+			value = operation.Value
+
+		case operation.Path == "":
+			attributes, ok := operation.Value.(map[string]any)
+			if !ok {
+				continue
+			}
+			value = attributes["active"]
+
+		default:
+			continue
+		}
+
+		switch v := value.(type) {
+		case bool:
+			active = v
+		case string:
+			// Some identity providers send booleans as strings.
+			active = !strings.EqualFold(v, "false")
+		}
+	}
+	return active
+}
+
+func memberIDs(members []member) ([]string, error) {
+	userIDs := make([]string, 0, len(members))
+	for _, m := range members {
+		if m.Type != "" && !strings.EqualFold(m.Type, "User") {
+			return nil, invalidValueErr("member `%s` is of type `%s`, but only users can be members", m.Value, m.Type)
+		}
+
+		if err := validateUserID(m.Value); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, m.Value)
+	}
+	return userIDs, nil
+}
+
+func validateGroupID(groupID string) error {
+	if err := tuple.ValidateResourceID(groupID); err != nil {
+		return invalidValueErr("group `%s` cannot be synced: %s", groupID, err)
+	}
+	return nil
+}
+
+func validateUserID(userID string) error {
+	if err := tuple.ValidateSubjectID(userID); err != nil || userID == tuple.PublicWildcard {
+		return invalidValueErr("user `%s` cannot be synced: must be a valid object ID", userID)
+	}
+	return nil
+}
+
+func groupResource(groupID string, userIDs []string) group {
+	members := make([]member, 0, len(userIDs))
+	for _, userID := range userIDs {
+		members = append(members, member{Value: userID, Type: "User"})
+	}
+
+	return group{
+		Schemas:     []string{groupSchema},
+		ID:          groupID,
+		DisplayName: groupID,
+		Members:     members,
+		Meta:        &meta{ResourceType: "Group", Location: BasePath + "/Groups/" + groupID},
+	}
+}
+
+func userResource(userID string, active bool) user {
+	return user{
+		Schemas:  []string{userSchema},
+		ID:       userID,
+		UserName: userID,
+		Active:   &active,
+		Meta:     &meta{ResourceType: "User", Location: BasePath + "/Users/" + userID},
+	}
+}
+
+func listOf(resources []any) listResponse {
+	if resources == nil {
+		resources = []any{}
+	}
+
+	return listResponse{
+		Schemas:      []string{listResponseSchema},
+		TotalResults: len(resources),
+		StartIndex:   1,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}
+}
+
+var serviceProviderConfig = map[string]any{
+	"schemas":        []string{spConfigSchema},
+	"patch":          map[string]bool{"supported": true},
+	"bulk":           map[string]any{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+	"filter":         map[string]any{"supported": true, "maxResults": 0},
+	"changePassword": map[string]bool{"supported": false},
+	"sort":           map[string]bool{"supported": false},
+	"etag":           map[string]bool{"supported": false},
+	"authenticationSchemes": []map[string]string{{
+		"type":        "oauthbearertoken",
+		"name":        "Bearer Token",
+		"description": "Authentication with a preconfigured bearer token",
+	}},
+}
+
+func readJSON(r *http.Request, v any) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return invalidSyntaxErr("invalid request body: %s", err)
+	}
+	return nil
+}
+
+// decodeValue decodes the value of a patch operation into v.
+func decodeValue(value any, v any) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return invalidSyntaxErr("invalid patch value: %s", err)
+	}
+
+	if err := json.Unmarshal(encoded, v); err != nil {
+		return invalidSyntaxErr("invalid patch value: %s", err)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	if body == nil {
+		w.WriteHeader(status)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Warn().Err(err).Msg("failed to write scim response")
+	}
+}
+
+func writeError(w http.ResponseWriter, err requestError) {
+	writeJSON(w, err.status, errorResponse{
+		Schemas:  []string{errorSchema},
+		Status:   fmt.Sprint(err.status),
+		ScimType: err.scimType,
+		Detail:   err.detail,
+	})
+}
@@ -0,0 +1,211 @@
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/internal/testfixtures"
+	"github.com/authzed/spicedb/pkg/datastore"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+const (
+	testSchema = `
+		definition user {}
+
+		definition group {
+			relation member: user
+		}`
+
+	testToken = "sometoken"
+)
+
+func newTestHandler(t *testing.T, dryRun bool) (http.Handler, datastore.Datastore) {
+	require := require.New(t)
+
+	rawDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	require.NoError(err)
+
+	ds, _ := testfixtures.DatastoreFromSchemaAndTestRelationships(rawDS, testSchema, nil, require)
+
+	handler, err := NewHandler(ds, Config{
+		GroupType:      "group",
+		MemberRelation: "member",
+		UserType:       "user",
+		DryRun:         dryRun,
+		BearerTokens:   []string{testToken},
+	})
+	require.NoError(err)
+	return handler, ds
+}
+
+func doRequest(t *testing.T, handler http.Handler, method, path, body string) (int, map[string]any) {
+	req := httptest.NewRequest(method, BasePath+path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+testToken)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	var decoded map[string]any
+	if rec.Body.Len() > 0 {
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &decoded))
+	}
+	return rec.Code, decoded
+}
+
+func readRelationships(t *testing.T, ds datastore.Datastore) []string {
+	ctx := context.Background()
+	headRevision, err := ds.HeadRevision(ctx)
+	require.NoError(t, err)
+
+	iter, err := ds.SnapshotReader(headRevision).QueryRelationships(ctx, datastore.RelationshipsFilter{ResourceType: "group"})
+	require.NoError(t, err)
+	defer iter.Close()
+
+	var found []string
+	for {
+		tpl, err := iter.Next()
+		require.NoError(t, err)
+		if tpl == nil {
+			break
+		}
+
+		require.Equal(t, SourceLabelValue, tpl.Labels[SourceLabel])
+		found = append(found, tuple.StringWithoutCaveat(tpl))
+	}
+	return found
+}
+
+func TestGroupSync(t *testing.T) {
+	handler, ds := newTestHandler(t, false)
+
+	status, _ := doRequest(t, handler, http.MethodPost, "/Groups", `{
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:Group"],
+		"displayName": "engineering",
+		"members": [{"value": "tom"}, {"value": "sarah"}]
+	}`)
+	require.Equal(t, http.StatusCreated, status)
+	require.ElementsMatch(t, []string{"group:engineering#member@user:tom", "group:engineering#member@user:sarah"}, readRelationships(t, ds))
+
+	status, _ = doRequest(t, handler, http.MethodPatch, "/Groups/engineering", `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [
+			{"op": "Add", "path": "members", "value": [{"value": "fred"}]},
+			{"op": "remove", "path": "members[value eq \"tom\"]"}
+		]
+	}`)
+	require.Equal(t, http.StatusOK, status)
+	require.ElementsMatch(t, []string{"group:engineering#member@user:sarah", "group:engineering#member@user:fred"}, readRelationships(t, ds))
+
+	status, body := doRequest(t, handler, http.MethodPut, "/Groups/engineering", `{
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:Group"],
+		"displayName": "engineering",
+		"members": [{"value": "fred"}, {"value": "jill"}]
+	}`)
+	require.Equal(t, http.StatusOK, status)
+	require.Len(t, body["members"], 2)
+	require.ElementsMatch(t, []string{"group:engineering#member@user:fred", "group:engineering#member@user:jill"}, readRelationships(t, ds))
+
+	status, body = doRequest(t, handler, http.MethodGet, `/Groups?filter=displayName+eq+"engineering"`, "")
+	require.Equal(t, http.StatusOK, status)
+	require.EqualValues(t, 1, body["totalResults"])
+
+	status, _ = doRequest(t, handler, http.MethodPatch, "/Users/fred", `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [{"op": "replace", "value": {"active": false}}]
+	}`)
+	require.Equal(t, http.StatusOK, status)
+	require.ElementsMatch(t, []string{"group:engineering#member@user:jill"}, readRelationships(t, ds))
+
+	status, _ = doRequest(t, handler, http.MethodDelete, "/Groups/engineering", "")
+	require.Equal(t, http.StatusNoContent, status)
+	require.Empty(t, readRelationships(t, ds))
+}
+
+func TestGroupSyncDryRun(t *testing.T) {
+	handler, ds := newTestHandler(t, true)
+
+	status, body := doRequest(t, handler, http.MethodPost, "/Groups", `{
+		"displayName": "engineering",
+		"members": [{"value": "tom"}]
+	}`)
+	require.Equal(t, http.StatusCreated, status)
+	require.Len(t, body["members"], 1)
+	require.Empty(t, readRelationships(t, ds))
+}
+
+func TestGroupSyncErrors(t *testing.T) {
+	handler, _ := newTestHandler(t, false)
+
+	req := httptest.NewRequest(http.MethodGet, BasePath+"/Groups", nil)
+	req.Header.Set("Authorization", "Bearer wrongtoken")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	status, body := doRequest(t, handler, http.MethodPost, "/Groups", `{"displayName": "not a valid id"}`)
+	require.Equal(t, http.StatusBadRequest, status)
+	require.Equal(t, "invalidValue", body["scimType"])
+
+	status, _ = doRequest(t, handler, http.MethodGet, `/Groups?filter=members+co+"tom"`, "")
+	require.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestApplyMemberOperations(t *testing.T) {
+	tcs := []struct {
+		name       string
+		current    []string
+		operations string
+		expected   []string
+	}{
+		{
+			"add without path",
+			[]string{"tom"},
+			`[{"op": "add", "value": {"members": [{"value": "sarah"}]}}]`,
+			[]string{"sarah", "tom"},
+		},
+		{
+			"remove listed members",
+			[]string{"fred", "sarah", "tom"},
+			`[{"op": "remove", "path": "members", "value": [{"value": "sarah"}, {"value": "tom"}]}]`,
+			[]string{"fred"},
+		},
+		{
+			"remove all members",
+			[]string{"sarah", "tom"},
+			`[{"op": "remove", "path": "members"}]`,
+			[]string{},
+		},
+		{
+			"replace members",
+			[]string{"sarah", "tom"},
+			`[{"op": "replace", "path": "members", "value": [{"value": "fred"}]}]`,
+			[]string{"fred"},
+		},
+		{
+			"display name change is ignored",
+			[]string{"tom"},
+			`[{"op": "replace", "path": "displayName", "value": "other"}]`,
+			[]string{"tom"},
+		},
+	}
+
+	for _, tc := range tcs {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var operations []patchOperation
+			require.NoError(t, json.Unmarshal([]byte(tc.operations), &operations))
+
+			members, err := applyMemberOperations(tc.current, operations)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, members)
+		})
+	}
+}
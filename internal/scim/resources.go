@@ -0,0 +1,116 @@
+package scim
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+const (
+	userSchema         = "urn:ietf:params:scim:schemas:core:2.0:User"
+	groupSchema        = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	listResponseSchema = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	patchOpSchema      = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	errorSchema        = "urn:ietf:params:scim:api:messages:2.0:Error"
+	spConfigSchema     = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+
+	contentType = "application/scim+json"
+)
+
+type meta struct {
+	ResourceType string `json:"resourceType"`
+	Location     string `json:"location,omitempty"`
+}
+
+type user struct {
+	Schemas    []string `json:"schemas"`
+	ID         string   `json:"id"`
+	ExternalID string   `json:"externalId,omitempty"`
+	UserName   string   `json:"userName"`
+	Active     *bool    `json:"active,omitempty"`
+	Meta       *meta    `json:"meta,omitempty"`
+}
+
+type member struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Display string `json:"display,omitempty"`
+}
+
+type group struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id"`
+	ExternalID  string   `json:"externalId,omitempty"`
+	DisplayName string   `json:"displayName"`
+	Members     []member `json:"members"`
+	Meta        *meta    `json:"meta,omitempty"`
+}
+
+type listResponse struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int      `json:"totalResults"`
+	StartIndex   int      `json:"startIndex"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	Resources    []any    `json:"Resources"`
+}
+
+type patchOperation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path,omitempty"`
+	Value any    `json:"value,omitempty"`
+}
+
+type patchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []patchOperation `json:"Operations"`
+}
+
+type errorResponse struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail"`
+}
+
+// requestError is an error to be returned to the SCIM client with the given status.
+type requestError struct {
+	status   int
+	scimType string
+	detail   string
+}
+
+func (err requestError) Error() string {
+	return err.detail
+}
+
+func invalidValueErr(format string, args ...any) requestError {
+	return requestError{http.StatusBadRequest, "invalidValue", fmt.Sprintf(format, args...)}
+}
+
+func invalidSyntaxErr(format string, args ...any) requestError {
+	return requestError{http.StatusBadRequest, "invalidSyntax", fmt.Sprintf(format, args...)}
+}
+
+func invalidPathErr(format string, args ...any) requestError {
+	return requestError{http.StatusBadRequest, "invalidPath", fmt.Sprintf(format, args...)}
+}
+
+func invalidFilterErr(format string, args ...any) requestError {
+	return requestError{http.StatusBadRequest, "invalidFilter", fmt.Sprintf(format, args...)}
+}
+
+var (
+	equalityFilterRegex = regexp.MustCompile(`^(?i)(\w+)\s+eq\s+"([^"]*)"$`)
+	memberPathRegex     = regexp.MustCompile(`^(?i)members\[\s*value\s+eq\s+"([^"]*)"\s*\]$`)
+)
+
+// parseEqualityFilter parses a filter of the form `attribute eq "value"`, the only form of
+// filter supported, as used by identity providers to look up existing resources.
+func parseEqualityFilter(filter string) (attribute string, value string, err error) {
+	parts := equalityFilterRegex.FindStringSubmatch(strings.TrimSpace(filter))
+	if parts == nil {
+		return "", "", invalidFilterErr("unsupported filter `%s`: only filters of the form `attribute eq \"value\"` are supported", filter)
+	}
+	return strings.ToLower(parts[1]), parts[2], nil
+}
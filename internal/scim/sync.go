@@ -0,0 +1,203 @@
+package scim
+
+import (
+	"context"
+	"sort"
+
+	log "github.com/authzed/spicedb/internal/logging"
+	"github.com/authzed/spicedb/internal/relationships"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+	"github.com/authzed/spicedb/pkg/util"
+)
+
+const (
+	// SourceLabel is the label set on every relationship written by the SCIM endpoint, with the
+	// value SourceLabelValue. Only relationships with the label are changed or removed by the
+	// endpoint, so that memberships written by other means are left untouched.
+	SourceLabel = "source"
+
+	// SourceLabelValue is the value of SourceLabel on relationships written by the SCIM endpoint.
+	SourceLabelValue = "scim"
+)
+
+var sourceLabels = map[string]string{SourceLabel: SourceLabelValue}
+
+// planFunc plans the updates to apply to the memberships read from the given reader.
+type planFunc func(ctx context.Context, reader datastore.Reader) ([]*core.RelationTupleUpdate, error)
+
+// syncer translates changes to SCIM resources into relationship updates.
+type syncer struct {
+	ds     datastore.Datastore
+	config Config
+}
+
+func (s *syncer) membership(groupID, userID string) *core.RelationTuple {
+	return &core.RelationTuple{
+		ResourceAndRelation: &core.ObjectAndRelation{
+			Namespace: s.config.GroupType,
+			ObjectId:  groupID,
+			Relation:  s.config.MemberRelation,
+		},
+		Subject: &core.ObjectAndRelation{
+			Namespace: s.config.UserType,
+			ObjectId:  userID,
+			Relation:  tuple.Ellipsis,
+		},
+		Labels: sourceLabels,
+	}
+}
+
+func (s *syncer) membershipsFilter(groupIDs []string, userIDs []string) datastore.RelationshipsFilter {
+	filter := datastore.RelationshipsFilter{
+		ResourceType:             s.config.GroupType,
+		OptionalResourceIds:      groupIDs,
+		OptionalResourceRelation: s.config.MemberRelation,
+		OptionalSubjectsSelectors: []datastore.SubjectsSelector{{
+			OptionalSubjectType: s.config.UserType,
+			OptionalSubjectIds:  userIDs,
+			RelationFilter:      datastore.SubjectRelationFilter{}.WithEllipsisRelation(),
+		}},
+		OptionalLabels: sourceLabels,
+	}
+	return filter
+}
+
+// readMemberships returns the memberships matching the filter, as user IDs by group ID.
+func readMemberships(ctx context.Context, reader datastore.Reader, filter datastore.RelationshipsFilter) (map[string][]string, error) {
+	iter, err := reader.QueryRelationships(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make(map[string][]string)
+	for {
+		tpl, err := iter.Next()
+		if err != nil {
+			iter.Close()
+			return nil, err
+		}
+		if tpl == nil {
+			break
+		}
+
+		groupID := tpl.ResourceAndRelation.ObjectId
+		members[groupID] = append(members[groupID], tpl.Subject.ObjectId)
+	}
+	iter.Close()
+
+	for _, userIDs := range members {
+		sort.Strings(userIDs)
+	}
+	return members, nil
+}
+
+// groupMembers returns the members of the given groups, as user IDs by group ID. If no group
+// IDs are given, the members of every group with memberships are returned.
+func (s *syncer) groupMembers(ctx context.Context, groupIDs ...string) (map[string][]string, error) {
+	headRevision, err := s.ds.HeadRevision(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return readMemberships(ctx, s.ds.SnapshotReader(headRevision), s.membershipsFilter(groupIDs, nil))
+}
+
+// reconcileGroup returns a plan which brings the members of the group to those computed by
+// desiredFunc from the current members, adding and removing memberships as necessary.
+func (s *syncer) reconcileGroup(groupID string, desiredFunc func(current []string) ([]string, error)) planFunc {
+	return func(ctx context.Context, reader datastore.Reader) ([]*core.RelationTupleUpdate, error) {
+		memberships, err := readMemberships(ctx, reader, s.membershipsFilter([]string{groupID}, nil))
+		if err != nil {
+			return nil, err
+		}
+
+		current := memberships[groupID]
+		desired, err := desiredFunc(current)
+		if err != nil {
+			return nil, err
+		}
+
+		currentSet := util.NewSet(current...)
+		desiredSet := util.NewSet(desired...)
+
+		var updates []*core.RelationTupleUpdate
+		for _, userID := range desiredSet.Subtract(currentSet).AsSlice() {
+			updates = append(updates, tuple.Touch(s.membership(groupID, userID)))
+		}
+		for _, userID := range currentSet.Subtract(desiredSet).AsSlice() {
+			updates = append(updates, tuple.Delete(s.membership(groupID, userID)))
+		}
+		return updates, nil
+	}
+}
+
+// removeUser returns a plan which removes all memberships of the given user.
+func (s *syncer) removeUser(userID string) planFunc {
+	return func(ctx context.Context, reader datastore.Reader) ([]*core.RelationTupleUpdate, error) {
+		memberships, err := readMemberships(ctx, reader, s.membershipsFilter(nil, []string{userID}))
+		if err != nil {
+			return nil, err
+		}
+
+		var updates []*core.RelationTupleUpdate
+		for groupID := range memberships {
+			updates = append(updates, tuple.Delete(s.membership(groupID, userID)))
+		}
+		return updates, nil
+	}
+}
+
+// apply plans and applies the updates within a single transaction. In dry-run mode, the
+// updates are planned against the head revision and logged, but not applied.
+func (s *syncer) apply(ctx context.Context, plan planFunc) ([]*core.RelationTupleUpdate, error) {
+	if s.config.DryRun {
+		headRevision, err := s.ds.HeadRevision(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		updates, err := plan(ctx, s.ds.SnapshotReader(headRevision))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, update := range updates {
+			log.Ctx(ctx).Info().
+				Str("operation", update.Operation.String()).
+				Str("relationship", tuple.StringWithoutCaveat(update.Tuple)).
+				Msg("scim dry run: skipped relationship update")
+		}
+		return updates, nil
+	}
+
+	var updates []*core.RelationTupleUpdate
+	_, err := s.ds.ReadWriteTx(ctx, func(rwt datastore.ReadWriteTransaction) error {
+		var err error
+		updates, err = plan(ctx, rwt)
+		if err != nil {
+			return err
+		}
+
+		if len(updates) == 0 {
+			return nil
+		}
+
+		if err := relationships.ValidateRelationshipUpdates(ctx, rwt, updates); err != nil {
+			return invalidValueErr("%s", err)
+		}
+
+		if err := relationships.CheckConstraints(ctx, rwt, updates); err != nil {
+			return invalidValueErr("%s", err)
+		}
+
+		return rwt.WriteRelationships(ctx, updates)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Ctx(ctx).Debug().Int("updates", len(updates)).Msg("scim sync applied relationship updates")
+	return updates, nil
+}
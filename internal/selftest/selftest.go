@@ -0,0 +1,239 @@
+// Package selftest implements checks of the configuration of a server against the environment
+// in which it is deployed, such as whether its datastore can be reached and is migrated, and
+// reports their results for deployment pipelines.
+package selftest
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/authzed/spicedb/pkg/cmd/util"
+	"github.com/authzed/spicedb/pkg/datastore"
+	"github.com/authzed/spicedb/pkg/datastore/revision"
+)
+
+// Status is the outcome of a check.
+type Status string
+
+const (
+	// StatusPassed is the status of a check which found no problem.
+	StatusPassed Status = "passed"
+
+	// StatusFailed is the status of a check which found a problem.
+	StatusFailed Status = "failed"
+
+	// StatusSkipped is the status of a check which does not apply to the configuration, or
+	// could not be run because a check it depends upon failed.
+	StatusSkipped Status = "skipped"
+)
+
+// Check is a named check of the configuration.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) (Status, string)
+}
+
+// Result is the result of a check.
+type Result struct {
+	Name     string        `json:"name"`
+	Status   Status        `json:"status"`
+	Detail   string        `json:"detail,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Report is the report of the results of the checks of a configuration.
+type Report struct {
+	Passed  bool     `json:"passed"`
+	Results []Result `json:"results"`
+}
+
+// WriteTable writes the results of the checks as a table.
+func (r *Report) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tSTATUS\tDURATION\tDETAIL")
+	for _, result := range r.Results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", result.Name, result.Status, result.Duration.Round(time.Millisecond), result.Detail)
+	}
+	return tw.Flush()
+}
+
+// Run runs the checks in order, each bounded by the timeout, and reports their results. The
+// report has passed if no check failed.
+func Run(ctx context.Context, checks []Check, timeout time.Duration) *Report {
+	report := &Report{Passed: true}
+	for _, check := range checks {
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		start := time.Now()
+		status, detail := check.Run(checkCtx)
+		cancel()
+
+		if status == StatusFailed {
+			report.Passed = false
+		}
+		report.Results = append(report.Results, Result{
+			Name:     check.Name,
+			Status:   status,
+			Detail:   detail,
+			Duration: time.Since(start),
+		})
+	}
+	return report
+}
+
+// Skipped returns a check which is skipped for the given reason.
+func Skipped(name string, reason string) Check {
+	return Check{
+		Name: name,
+		Run: func(ctx context.Context) (Status, string) {
+			return StatusSkipped, reason
+		},
+	}
+}
+
+// Datastore returns a check that the datastore can be reached, by reading its head revision.
+func Datastore(ds datastore.Datastore) Check {
+	return Check{
+		Name: "datastore",
+		Run: func(ctx context.Context) (Status, string) {
+			rev, err := ds.HeadRevision(ctx)
+			if err != nil {
+				return StatusFailed, fmt.Sprintf("failed to read the head revision: %s", err)
+			}
+			return StatusPassed, fmt.Sprintf("head revision %s", rev)
+		},
+	}
+}
+
+// Migration returns a check that the datastore has been migrated to the head migration.
+func Migration(ds datastore.Datastore) Check {
+	return Check{
+		Name: "migration",
+		Run: func(ctx context.Context) (Status, string) {
+			ready, err := ds.IsReady(ctx)
+			if err != nil {
+				return StatusFailed, fmt.Sprintf("failed to read the migration level: %s", err)
+			}
+			if !ready {
+				return StatusFailed, "the datastore is not migrated to the head migration"
+			}
+			return StatusPassed, "the datastore is migrated to the head migration"
+		},
+	}
+}
+
+// ClockSkew returns a check that the local clock is within the maximum skew of the clock of
+// the datastore, for datastores whose revisions are the timestamps of their clocks in
+// nanoseconds, such as CockroachDB and Spanner. The skew is measured against the midpoint of
+// the read of the head revision.
+func ClockSkew(ds datastore.Datastore, maximum time.Duration) Check {
+	return Check{
+		Name: "clock-skew",
+		Run: func(ctx context.Context) (Status, string) {
+			before := time.Now()
+			rev, err := ds.HeadRevision(ctx)
+			roundTrip := time.Since(before)
+			if err != nil {
+				return StatusFailed, fmt.Sprintf("failed to read the head revision: %s", err)
+			}
+
+			decimalRev, ok := rev.(revision.Decimal)
+			if !ok {
+				return StatusSkipped, "the revisions of the datastore are not timestamps"
+			}
+
+			local := before.Add(roundTrip / 2)
+			skew := time.Unix(0, decimalRev.IntPart()).Sub(local)
+			if skew < 0 {
+				skew = -skew
+			}
+
+			detail := fmt.Sprintf("skew of %s with a round trip of %s", skew.Round(time.Microsecond), roundTrip.Round(time.Microsecond))
+			if skew > maximum {
+				return StatusFailed, fmt.Sprintf("%s exceeds the maximum of %s", detail, maximum)
+			}
+			return StatusPassed, detail
+		},
+	}
+}
+
+// TLS returns a check that the certificate and key at the given paths, with which the named
+// server is served, can be loaded and that the certificate is currently valid. The check is
+// skipped if neither path is set, since the server is then served without TLS.
+func TLS(name string, certPath string, keyPath string) Check {
+	return Check{
+		Name: name + "-tls",
+		Run: func(ctx context.Context) (Status, string) {
+			switch {
+			case certPath == "" && keyPath == "":
+				return StatusSkipped, "TLS is not configured"
+			case certPath == "" || keyPath == "":
+				return StatusFailed, "both a certificate and a key are required"
+			}
+
+			keyPair, err := tls.LoadX509KeyPair(certPath, keyPath)
+			if err != nil {
+				return StatusFailed, fmt.Sprintf("failed to load the certificate and key: %s", err)
+			}
+
+			cert, err := x509.ParseCertificate(keyPair.Certificate[0])
+			if err != nil {
+				return StatusFailed, fmt.Sprintf("failed to parse the certificate: %s", err)
+			}
+
+			now := time.Now()
+			switch {
+			case now.Before(cert.NotBefore):
+				return StatusFailed, fmt.Sprintf("the certificate is not valid until %s", cert.NotBefore.Format(time.RFC3339))
+			case now.After(cert.NotAfter):
+				return StatusFailed, fmt.Sprintf("the certificate expired at %s", cert.NotAfter.Format(time.RFC3339))
+			}
+			return StatusPassed, fmt.Sprintf("the certificate expires at %s", cert.NotAfter.Format(time.RFC3339))
+		},
+	}
+}
+
+// TLSPolicy returns a check that TLS connections can be established under the policy applied to
+// every listener, negotiating a version and cipher suite which it allows.
+func TLSPolicy(policy util.TLSPolicy) Check {
+	return Check{
+		Name: "tls-policy",
+		Run: func(_ context.Context) (Status, string) {
+			negotiated, err := policy.SelfTest()
+			if err != nil {
+				return StatusFailed, err.Error()
+			}
+			if policy.FIPSMode {
+				return StatusPassed, negotiated + " in FIPS mode"
+			}
+			return StatusPassed, negotiated
+		},
+	}
+}
+
+// DispatchPeer returns a check that a connection can be established to the dispatch peers at
+// the given address, dialed with the given options. The check is skipped if no address is set,
+// since requests are then not dispatched to peers.
+func DispatchPeer(addr string, opts ...grpc.DialOption) Check {
+	return Check{
+		Name: "dispatch-peer",
+		Run: func(ctx context.Context) (Status, string) {
+			if addr == "" {
+				return StatusSkipped, "dispatching to peers is not configured"
+			}
+
+			conn, err := grpc.DialContext(ctx, addr, append(opts, grpc.WithBlock(), grpc.WithReturnConnectionError())...)
+			if err != nil {
+				return StatusFailed, fmt.Sprintf("failed to connect to %s: %s", addr, err)
+			}
+			defer conn.Close()
+			return StatusPassed, fmt.Sprintf("connected to %s", addr)
+		},
+	}
+}
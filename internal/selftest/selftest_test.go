@@ -0,0 +1,109 @@
+package selftest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/pkg/cmd/util"
+)
+
+func TestRun(t *testing.T) {
+	ds, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	require.NoError(t, err)
+	defer ds.Close()
+
+	report := Run(context.Background(), []Check{
+		Datastore(ds),
+		Migration(ds),
+		ClockSkew(ds, time.Minute),
+		Skipped("skipped", "not applicable"),
+	}, time.Second)
+	require.True(t, report.Passed)
+	require.Len(t, report.Results, 4)
+	for i, expected := range []Status{StatusPassed, StatusPassed, StatusPassed, StatusSkipped} {
+		require.Equal(t, expected, report.Results[i].Status, report.Results[i].Detail)
+	}
+
+	report = Run(context.Background(), []Check{
+		Datastore(ds),
+		DispatchPeer("localhost:0", grpc.WithTransportCredentials(insecure.NewCredentials())),
+	}, 100*time.Millisecond)
+	require.False(t, report.Passed)
+	require.Equal(t, StatusFailed, report.Results[1].Status)
+}
+
+func TestTLS(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	for _, tc := range []struct {
+		name           string
+		notBefore      time.Time
+		notAfter       time.Time
+		expectedStatus Status
+	}{
+		{"valid", now.Add(-time.Hour), now.Add(time.Hour), StatusPassed},
+		{"expired", now.Add(-2 * time.Hour), now.Add(-time.Hour), StatusFailed},
+		{"not yet valid", now.Add(time.Hour), now.Add(2 * time.Hour), StatusFailed},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			certPath, keyPath := writeKeyPair(t, dir, tc.name, tc.notBefore, tc.notAfter)
+			status, detail := TLS("grpc", certPath, keyPath).Run(context.Background())
+			require.Equal(t, tc.expectedStatus, status, detail)
+		})
+	}
+
+	status, _ := TLS("grpc", "", "").Run(context.Background())
+	require.Equal(t, StatusSkipped, status)
+
+	status, _ = TLS("grpc", filepath.Join(dir, "missing.crt"), "").Run(context.Background())
+	require.Equal(t, StatusFailed, status)
+}
+
+func TestTLSPolicy(t *testing.T) {
+	status, detail := TLSPolicy(util.TLSPolicy{MinVersion: "1.3"}).Run(context.Background())
+	require.Equal(t, StatusPassed, status, detail)
+	require.Contains(t, detail, "TLS 1.3")
+
+	status, detail = TLSPolicy(util.TLSPolicy{CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"}}).Run(context.Background())
+	require.Equal(t, StatusPassed, status, detail)
+
+	status, _ = TLSPolicy(util.TLSPolicy{MinVersion: "1.1"}).Run(context.Background())
+	require.Equal(t, StatusFailed, status)
+}
+
+func writeKeyPair(t *testing.T, dir string, name string, notBefore time.Time, notAfter time.Time) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPath := filepath.Join(dir, name+".crt")
+	keyPath := filepath.Join(dir, name+".key")
+	require.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certPath, keyPath
+}
@@ -0,0 +1,112 @@
+package v1
+
+import (
+	"context"
+	"errors"
+
+	grpcvalidate "github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/validator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/authzed/spicedb/internal/dispatch"
+	log "github.com/authzed/spicedb/internal/logging"
+	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
+	"github.com/authzed/spicedb/internal/namespace"
+	"github.com/authzed/spicedb/internal/relationships"
+	"github.com/authzed/spicedb/internal/services/shared"
+	"github.com/authzed/spicedb/internal/tracestore"
+	"github.com/authzed/spicedb/pkg/datastore"
+	adminv1 "github.com/authzed/spicedb/pkg/proto/admin/v1"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+)
+
+const defaultMaxSamples = 100
+
+type adminServer struct {
+	adminv1.UnimplementedAdminServiceServer
+	shared.WithServiceSpecificInterceptors
+
+	dispatch        dispatch.Dispatcher
+	maximumAPIDepth uint32
+	traceRecorder   *tracestore.Recorder
+}
+
+// NewAdminServer creates a server for administering SpiceDB. The dispatcher and maximum API
+// depth must match those used by the permissions service, so that explained plans reflect
+// the execution of its requests, and the trace recorder, if any, must be that of the permissions
+// service, so that its traces can be retrieved.
+func NewAdminServer(dispatch dispatch.Dispatcher, maximumAPIDepth uint32, traceRecorder *tracestore.Recorder) adminv1.AdminServiceServer {
+	return &adminServer{
+		dispatch:        dispatch,
+		maximumAPIDepth: maximumAPIDepth,
+		traceRecorder:   traceRecorder,
+		WithServiceSpecificInterceptors: shared.WithServiceSpecificInterceptors{
+			Unary:  grpcvalidate.UnaryServerInterceptor(true),
+			Stream: grpcvalidate.StreamServerInterceptor(true),
+		},
+	}
+}
+
+func (as *adminServer) CleanupOrphanedRelationships(ctx context.Context, req *adminv1.CleanupOrphanedRelationshipsRequest) (*adminv1.CleanupOrphanedRelationshipsResponse, error) {
+	ds := datastoremw.MustFromContext(ctx)
+
+	maxSamples := int(req.MaxSamples)
+	if maxSamples == 0 {
+		maxSamples = defaultMaxSamples
+	}
+
+	var samples []*core.RelationTuple
+	counts, revision, err := relationships.DeleteOrphanedRelationships(ctx, ds, req.RemovedDefinitions, req.DryRun,
+		func(tpl *core.RelationTuple, reason relationships.OrphanReason) error {
+			if len(samples) < maxSamples {
+				samples = append(samples, tpl)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, rewriteError(err)
+	}
+
+	resp := &adminv1.CleanupOrphanedRelationshipsResponse{
+		Samples:  samples,
+		Revision: revision.String(),
+	}
+	for _, reason := range relationships.OrphanReasons {
+		if count := counts[reason]; count > 0 {
+			resp.Counts = append(resp.Counts, &adminv1.OrphanedRelationshipCount{
+				Reason: string(reason),
+				Count:  count,
+			})
+		}
+	}
+
+	log.Ctx(ctx).Info().
+		Bool("dry-run", req.DryRun).
+		Int("reasons", len(resp.Counts)).
+		Str("revision", resp.Revision).
+		Msg("cleaned up orphaned relationships")
+
+	return resp, nil
+}
+
+func rewriteError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	switch {
+	case errors.As(err, &datastore.ErrNamespaceNotFound{}):
+		return status.Errorf(codes.FailedPrecondition, "%s", err)
+	case errors.As(err, &namespace.ErrRelationNotFound{}):
+		return status.Errorf(codes.FailedPrecondition, "%s", err)
+	case errors.As(err, &datastore.ErrReadOnly{}):
+		return shared.ErrServiceReadOnly
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Errorf(codes.DeadlineExceeded, "%s", err)
+	case errors.Is(err, context.Canceled):
+		return status.Errorf(codes.Canceled, "%s", err)
+	default:
+		return status.Errorf(codes.Internal, "internal error: %s", err)
+	}
+}
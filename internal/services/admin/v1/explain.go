@@ -0,0 +1,99 @@
+package v1
+
+import (
+	"context"
+
+	"github.com/authzed/spicedb/internal/graph"
+	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
+	adminv1 "github.com/authzed/spicedb/pkg/proto/admin/v1"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+)
+
+func (as *adminServer) ExplainCheck(ctx context.Context, req *adminv1.ExplainCheckRequest) (*adminv1.ExplainResponse, error) {
+	params, err := as.explainParams(ctx, req.MaxDepth)
+	if err != nil {
+		return nil, rewriteError(err)
+	}
+
+	plan, err := graph.ExplainCheck(ctx, params, &core.RelationReference{
+		Namespace: req.ResourceType,
+		Relation:  req.Permission,
+	}, req.ResourceId, req.Subject)
+	if err != nil {
+		return nil, rewriteError(err)
+	}
+
+	return &adminv1.ExplainResponse{
+		Plan:     planNodeToProto(plan),
+		Revision: params.Revision.String(),
+	}, nil
+}
+
+func (as *adminServer) ExplainLookupResources(ctx context.Context, req *adminv1.ExplainLookupResourcesRequest) (*adminv1.ExplainResponse, error) {
+	params, err := as.explainParams(ctx, req.MaxDepth)
+	if err != nil {
+		return nil, rewriteError(err)
+	}
+
+	plan, err := graph.ExplainLookupResources(ctx, params, &core.RelationReference{
+		Namespace: req.ResourceType,
+		Relation:  req.Permission,
+	}, req.Subject)
+	if err != nil {
+		return nil, rewriteError(err)
+	}
+
+	return &adminv1.ExplainResponse{
+		Plan:     planNodeToProto(plan),
+		Revision: params.Revision.String(),
+	}, nil
+}
+
+// explainParams returns the parameters for explaining a request made with minimized latency,
+// as such requests are the ones served from the dispatch cache.
+func (as *adminServer) explainParams(ctx context.Context, maxDepth uint32) (graph.ExplainParams, error) {
+	ds := datastoremw.MustFromContext(ctx)
+	revision, err := ds.OptimizedRevision(ctx)
+	if err != nil {
+		return graph.ExplainParams{}, err
+	}
+
+	if maxDepth == 0 || maxDepth > as.maximumAPIDepth {
+		maxDepth = as.maximumAPIDepth
+	}
+
+	predictor, _ := as.dispatch.(graph.CheckCachePredictor)
+	return graph.ExplainParams{
+		Reader:       ds.SnapshotReader(revision),
+		Revision:     revision,
+		Predictor:    predictor,
+		MaximumDepth: maxDepth,
+	}, nil
+}
+
+func planNodeToProto(node *graph.PlanNode) *adminv1.PlanNode {
+	children := make([]*adminv1.PlanNode, 0, len(node.Children))
+	for _, child := range node.Children {
+		children = append(children, planNodeToProto(child))
+	}
+
+	var cache adminv1.PlanNode_CachePrediction
+	switch node.Cache {
+	case graph.CachePredictionHit:
+		cache = adminv1.PlanNode_CACHE_PREDICTION_HIT
+	case graph.CachePredictionMiss:
+		cache = adminv1.PlanNode_CACHE_PREDICTION_MISS
+	default:
+		cache = adminv1.PlanNode_CACHE_PREDICTION_UNKNOWN
+	}
+
+	return &adminv1.PlanNode{
+		Kind:             string(node.Kind),
+		Relation:         node.Relation,
+		Strategy:         node.Strategy,
+		DatastoreQueries: node.Queries,
+		Cache:            cache,
+		Recursive:        node.Recursive,
+		Children:         children,
+	}
+}
@@ -0,0 +1,48 @@
+package v1
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	dscommon "github.com/authzed/spicedb/internal/datastore/common"
+	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
+	"github.com/authzed/spicedb/pkg/datastore"
+	adminv1 "github.com/authzed/spicedb/pkg/proto/admin/v1"
+)
+
+func (as *adminServer) PauseGarbageCollection(ctx context.Context, _ *adminv1.PauseGarbageCollectionRequest) (*adminv1.GarbageCollectionStatus, error) {
+	controller, err := gcController(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	controller.Pause()
+	return gcStatus(controller), nil
+}
+
+func (as *adminServer) ResumeGarbageCollection(ctx context.Context, _ *adminv1.ResumeGarbageCollectionRequest) (*adminv1.GarbageCollectionStatus, error) {
+	controller, err := gcController(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	controller.Resume()
+	return gcStatus(controller), nil
+}
+
+func gcController(ctx context.Context) (*dscommon.GCController, error) {
+	controlling, ok := datastore.Unwrap(datastoremw.MustFromContext(ctx)).(dscommon.GCControllingDatastore)
+	if !ok {
+		return nil, status.Errorf(codes.FailedPrecondition, "the datastore does not support pausing garbage collection")
+	}
+	return controlling.GCController(), nil
+}
+
+func gcStatus(controller *dscommon.GCController) *adminv1.GarbageCollectionStatus {
+	return &adminv1.GarbageCollectionStatus{
+		Paused:    controller.Paused(),
+		BatchSize: controller.BatchSize(),
+	}
+}
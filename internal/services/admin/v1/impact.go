@@ -0,0 +1,79 @@
+package v1
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/authzed/spicedb/internal/datastore/proxy"
+	"github.com/authzed/spicedb/internal/impact"
+	log "github.com/authzed/spicedb/internal/logging"
+	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
+	"github.com/authzed/spicedb/internal/services/shared"
+	adminv1 "github.com/authzed/spicedb/pkg/proto/admin/v1"
+)
+
+func (as *adminServer) EstimateImpact(ctx context.Context, req *adminv1.EstimateImpactRequest) (*adminv1.EstimateImpactResponse, error) {
+	ds := datastoremw.MustFromContext(ctx)
+
+	overlay := proxy.Overlay{Updates: req.Updates}
+	if req.Schema != "" {
+		schema, err := shared.CompileProposedSchema(ctx, req.Schema)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid schema: %s", err)
+		}
+		overlay.Schema = schema
+	}
+
+	// Estimate on top of the head revision, as the changes would be written on top of it.
+	revision, err := ds.HeadRevision(ctx)
+	if err != nil {
+		return nil, rewriteError(err)
+	}
+
+	maxResources := int(req.MaxResources)
+	if maxResources == 0 {
+		maxResources = defaultMaxSamples
+	}
+
+	estimate, err := impact.EstimateImpact(ctx, impact.Params{
+		Datastore:    ds,
+		Revision:     revision,
+		MaximumDepth: as.maximumAPIDepth,
+		MaxResources: maxResources,
+		SubjectTypes: req.SubjectTypes,
+	}, overlay)
+	if err != nil {
+		return nil, rewriteError(err)
+	}
+
+	changes := make([]*adminv1.PermissionChange, 0, len(estimate.Changes))
+	for _, change := range estimate.Changes {
+		kind := adminv1.PermissionChange_KIND_GAINED
+		if change.Kind == impact.Lost {
+			kind = adminv1.PermissionChange_KIND_LOST
+		}
+
+		changes = append(changes, &adminv1.PermissionChange{
+			Resource: change.Resource,
+			Subject:  change.Subject,
+			Kind:     kind,
+		})
+	}
+
+	log.Ctx(ctx).Info().
+		Int("updates", len(req.Updates)).
+		Bool("schema", overlay.Schema != nil).
+		Int("changes", len(changes)).
+		Int("resources-examined", estimate.ResourcesExamined).
+		Bool("truncated", estimate.Truncated).
+		Msg("estimated impact of changes")
+
+	return &adminv1.EstimateImpactResponse{
+		Changes:           changes,
+		ResourcesExamined: uint32(estimate.ResourcesExamined),
+		Truncated:         estimate.Truncated,
+		Revision:          revision.String(),
+	}, nil
+}
@@ -0,0 +1,44 @@
+package v1
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	dscommon "github.com/authzed/spicedb/internal/datastore/common"
+	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
+	"github.com/authzed/spicedb/pkg/datastore"
+	adminv1 "github.com/authzed/spicedb/pkg/proto/admin/v1"
+)
+
+func (as *adminServer) GetIndexSuggestions(ctx context.Context, _ *adminv1.GetIndexSuggestionsRequest) (*adminv1.GetIndexSuggestionsResponse, error) {
+	advisor, ok := datastore.Unwrap(datastoremw.MustFromContext(ctx)).(dscommon.IndexAdvisingDatastore)
+	if !ok {
+		return nil, status.Errorf(codes.FailedPrecondition, "the datastore does not support index suggestions")
+	}
+
+	suggestions, err := advisor.SuggestIndexes(ctx)
+	if errors.Is(err, dscommon.ErrIndexAdvisorDisabled) {
+		return nil, status.Errorf(codes.FailedPrecondition, "%s", err)
+	} else if err != nil {
+		return nil, rewriteError(err)
+	}
+
+	resp := &adminv1.GetIndexSuggestionsResponse{
+		Suggestions: make([]*adminv1.IndexSuggestion, 0, len(suggestions)),
+	}
+	for _, suggestion := range suggestions {
+		resp.Suggestions = append(resp.Suggestions, &adminv1.IndexSuggestion{
+			Table:         suggestion.Table,
+			Columns:       suggestion.Shape.Columns,
+			QueryCount:    suggestion.Shape.Count,
+			LastQueriedAt: timestamppb.New(suggestion.Shape.LastUsed),
+			Statement:     suggestion.Statement,
+			Created:       suggestion.Created,
+		})
+	}
+	return resp, nil
+}
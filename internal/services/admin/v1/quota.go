@@ -0,0 +1,33 @@
+package v1
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/authzed/spicedb/internal/middleware/quota"
+	adminv1 "github.com/authzed/spicedb/pkg/proto/admin/v1"
+)
+
+func (as *adminServer) GetQuotaUsage(ctx context.Context, _ *adminv1.GetQuotaUsageRequest) (*adminv1.GetQuotaUsageResponse, error) {
+	tracker := quota.FromContext(ctx)
+	if tracker == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "quotas are disabled")
+	}
+
+	usages := tracker.Usage()
+	resp := &adminv1.GetQuotaUsageResponse{
+		Usages: make([]*adminv1.QuotaUsage, 0, len(usages)),
+	}
+	for _, usage := range usages {
+		resp.Usages = append(resp.Usages, &adminv1.QuotaUsage{
+			TokenId:   usage.TokenID,
+			Resource:  string(usage.Resource),
+			Used:      usage.Used,
+			SoftLimit: usage.Limit.Soft,
+			HardLimit: usage.Limit.Hard,
+		})
+	}
+	return resp, nil
+}
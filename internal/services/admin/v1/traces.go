@@ -0,0 +1,41 @@
+package v1
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/authzed/spicedb/internal/tracestore"
+	adminv1 "github.com/authzed/spicedb/pkg/proto/admin/v1"
+)
+
+func (as *adminServer) GetTrace(ctx context.Context, req *adminv1.GetTraceRequest) (*adminv1.GetTraceResponse, error) {
+	if as.traceRecorder == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "trace recording is disabled")
+	}
+
+	trace, err := as.traceRecorder.Get(ctx, req.RequestId)
+	if errors.Is(err, tracestore.ErrTraceNotFound) {
+		return nil, status.Errorf(codes.NotFound, "no trace recorded for request %s", req.RequestId)
+	} else if err != nil {
+		return nil, rewriteError(err)
+	}
+
+	return &adminv1.GetTraceResponse{
+		Trace: &adminv1.Trace{
+			RequestId:             trace.RequestID,
+			Method:                trace.Method,
+			RecordedAt:            timestamppb.New(trace.RecordedAt),
+			Duration:              durationpb.New(trace.Duration),
+			RequestJson:           string(trace.Request),
+			DebugInformationJson:  string(trace.DebugInformation),
+			CaveatEvaluationsJson: string(trace.CaveatEvaluations),
+			Error:                 trace.Error,
+			Metadata:              trace.Metadata,
+		},
+	}, nil
+}
@@ -0,0 +1,48 @@
+package v1
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/authzed/spicedb/internal/middleware/relationusage"
+	adminv1 "github.com/authzed/spicedb/pkg/proto/admin/v1"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+)
+
+func (as *adminServer) GetRelationUsage(ctx context.Context, _ *adminv1.GetRelationUsageRequest) (*adminv1.GetRelationUsageResponse, error) {
+	tracker := relationusage.FromContext(ctx)
+	if tracker == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "relation usage analysis is disabled")
+	}
+
+	report := tracker.Report()
+	if report == nil {
+		return nil, status.Errorf(codes.Unavailable, "relation usage has not yet been analyzed")
+	}
+
+	resp := &adminv1.GetRelationUsageResponse{
+		AnalyzedAt: timestamppb.New(report.AnalyzedAt),
+		Revision:   report.Revision.String(),
+		Relations:  make([]*adminv1.RelationUsage, 0, len(report.Relations)),
+	}
+	for _, usage := range report.Relations {
+		converted := &adminv1.RelationUsage{
+			Relation: &core.RelationReference{
+				Namespace: usage.Namespace,
+				Relation:  usage.Relation,
+			},
+			IsPermission:      usage.IsPermission,
+			CheckCount:        usage.Checks,
+			LookupCount:       usage.Lookups,
+			RelationshipCount: usage.Relationships,
+		}
+		if !usage.LastWrite.IsZero() {
+			converted.LastWrittenAt = timestamppb.New(usage.LastWrite)
+		}
+		resp.Relations = append(resp.Relations, converted)
+	}
+	return resp, nil
+}
@@ -44,16 +44,37 @@ func NewDispatchServer(localDispatch dispatch.Dispatcher) dispatchv1.DispatchSer
 }
 
 func (ds *dispatchServer) DispatchCheck(ctx context.Context, req *dispatchv1.DispatchCheckRequest) (*dispatchv1.DispatchCheckResponse, error) {
+	if err := dispatch.CheckRequiredFeatures(req); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := dispatch.ContextWithDeadlineBudget(ctx, req)
+	defer cancel()
+
 	resp, err := ds.localDispatch.DispatchCheck(ctx, req)
 	return resp, rewriteGraphError(ctx, err)
 }
 
 func (ds *dispatchServer) DispatchExpand(ctx context.Context, req *dispatchv1.DispatchExpandRequest) (*dispatchv1.DispatchExpandResponse, error) {
+	if err := dispatch.CheckRequiredFeatures(req); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := dispatch.ContextWithDeadlineBudget(ctx, req)
+	defer cancel()
+
 	resp, err := ds.localDispatch.DispatchExpand(ctx, req)
 	return resp, rewriteGraphError(ctx, err)
 }
 
 func (ds *dispatchServer) DispatchLookup(ctx context.Context, req *dispatchv1.DispatchLookupRequest) (*dispatchv1.DispatchLookupResponse, error) {
+	if err := dispatch.CheckRequiredFeatures(req); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := dispatch.ContextWithDeadlineBudget(ctx, req)
+	defer cancel()
+
 	resp, err := ds.localDispatch.DispatchLookup(ctx, req)
 	return resp, rewriteGraphError(ctx, err)
 }
@@ -62,16 +83,37 @@ func (ds *dispatchServer) DispatchReachableResources(
 	req *dispatchv1.DispatchReachableResourcesRequest,
 	resp dispatchv1.DispatchService_DispatchReachableResourcesServer,
 ) error {
-	return ds.localDispatch.DispatchReachableResources(req,
-		dispatch.WrapGRPCStream[*dispatchv1.DispatchReachableResourcesResponse](resp))
+	if err := dispatch.CheckRequiredFeatures(req); err != nil {
+		return err
+	}
+
+	ctx, cancel := dispatch.ContextWithDeadlineBudget(resp.Context(), req)
+	defer cancel()
+
+	return ds.localDispatch.DispatchReachableResources(req, dispatch.StreamWithContext(ctx,
+		dispatch.WrapGRPCStream[*dispatchv1.DispatchReachableResourcesResponse](resp)))
 }
 
 func (ds *dispatchServer) DispatchLookupSubjects(
 	req *dispatchv1.DispatchLookupSubjectsRequest,
 	resp dispatchv1.DispatchService_DispatchLookupSubjectsServer,
 ) error {
-	return ds.localDispatch.DispatchLookupSubjects(req,
-		dispatch.WrapGRPCStream[*dispatchv1.DispatchLookupSubjectsResponse](resp))
+	if err := dispatch.CheckRequiredFeatures(req); err != nil {
+		return err
+	}
+
+	ctx, cancel := dispatch.ContextWithDeadlineBudget(resp.Context(), req)
+	defer cancel()
+
+	return ds.localDispatch.DispatchLookupSubjects(req, dispatch.StreamWithContext(ctx,
+		dispatch.WrapGRPCStream[*dispatchv1.DispatchLookupSubjectsResponse](resp)))
+}
+
+func (ds *dispatchServer) DispatchNegotiate(_ context.Context, req *dispatchv1.DispatchNegotiateRequest) (*dispatchv1.DispatchNegotiateResponse, error) {
+	return &dispatchv1.DispatchNegotiateResponse{
+		ApiVersion: dispatch.APIVersion,
+		Features:   dispatch.NegotiateFeatures(req.Features),
+	}, nil
 }
 
 func (ds *dispatchServer) Close() error {
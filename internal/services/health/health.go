@@ -14,12 +14,17 @@ import (
 
 const datastoreReadyTimeout = time.Millisecond * 500
 
+// DatastoreSchemaHealthCheckKey is the key of the health check reporting whether the live schema
+// of the datastore matches the schema expected at its migration revision. It is only reported
+// if the schema is checked for drift.
+const DatastoreSchemaHealthCheckKey = "spicedb.datastore.schema"
+
 // NewHealthManager creates and returns a new health manager that checks the IsReady
 // status of the given dispatcher and datastore checker and sets the health check to
 // return healthy once both have gone to true.
 func NewHealthManager(dispatcher dispatch.Dispatcher, dsc DatastoreChecker) Manager {
 	healthSvc := grpcutil.NewAuthlessHealthServer()
-	return &healthManager{healthSvc, dispatcher, dsc, map[string]struct{}{}}
+	return &healthManager{healthSvc, dispatcher, dsc, map[string]struct{}{}, nil}
 }
 
 // DatastoreChecker is an interface for determining if the datastore is ready for
@@ -40,6 +45,15 @@ type Manager interface {
 
 	// Checker returns a function that can be run via an errgroup to perform the health checks.
 	Checker(ctx context.Context) func() error
+
+	// ReportSchemaDrift reports whether the schema of the datastore has drifted from the schema
+	// expected at its migration revision, under DatastoreSchemaHealthCheckKey.
+	ReportSchemaDrift(drifted bool)
+
+	// RegisterWarmup registers a function which is run once the dispatcher and datastore are
+	// ready, such as to populate caches, before the services are reported as serving. The
+	// services are reported as serving even if the warmup fails.
+	RegisterWarmup(warmup func(ctx context.Context) error)
 }
 
 type healthManager struct {
@@ -47,6 +61,7 @@ type healthManager struct {
 	dispatcher   dispatch.Dispatcher
 	dsc          DatastoreChecker
 	serviceNames map[string]struct{}
+	warmups      []func(ctx context.Context) error
 }
 
 func (hm *healthManager) HealthSvc() *grpcutil.AuthlessHealthServer {
@@ -58,6 +73,18 @@ func (hm *healthManager) RegisterReportedService(serviceName string) {
 	hm.healthSvc.Server.SetServingStatus(serviceName, healthpb.HealthCheckResponse_NOT_SERVING)
 }
 
+func (hm *healthManager) ReportSchemaDrift(drifted bool) {
+	status := healthpb.HealthCheckResponse_SERVING
+	if drifted {
+		status = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	hm.healthSvc.Server.SetServingStatus(DatastoreSchemaHealthCheckKey, status)
+}
+
+func (hm *healthManager) RegisterWarmup(warmup func(ctx context.Context) error) {
+	hm.warmups = append(hm.warmups, warmup)
+}
+
 func (hm *healthManager) Checker(ctx context.Context) func() error {
 	return func() error {
 		// Run immediately for the initial check
@@ -79,6 +106,12 @@ func (hm *healthManager) Checker(ctx context.Context) func() error {
 
 			isReady := hm.checkIsReady(ctx)
 			if isReady {
+				for _, warmup := range hm.warmups {
+					if err := warmup(ctx); err != nil {
+						log.Ctx(ctx).Warn().Err(err).Msg("failed to warm up before serving")
+					}
+				}
+
 				for serviceName := range hm.serviceNames {
 					hm.healthSvc.Server.SetServingStatus(serviceName, healthpb.HealthCheckResponse_SERVING)
 				}
@@ -6,6 +6,7 @@ package integrationtesting_test
 import (
 	"context"
 	"fmt"
+	"os"
 	"path"
 	"sort"
 	"testing"
@@ -22,6 +23,7 @@ import (
 	"github.com/authzed/spicedb/internal/graph"
 	"github.com/authzed/spicedb/internal/namespace"
 	"github.com/authzed/spicedb/internal/services/integrationtesting/consistencytestutil"
+	"github.com/authzed/spicedb/internal/testfixtures"
 	"github.com/authzed/spicedb/pkg/datastore"
 	"github.com/authzed/spicedb/pkg/development"
 	core "github.com/authzed/spicedb/pkg/proto/core/v1"
@@ -66,6 +68,30 @@ func TestConsistency(t *testing.T) {
 	}
 }
 
+// TestConsistencyWithGeneratedData runs the consistency test suite against a generated
+// dataset, which exercises deeper and wider graphs than the hand-written test files.
+func TestConsistencyWithGeneratedData(t *testing.T) {
+	graph.SetDispatchChunkSizesForTesting(t, []uint16{5, 10})
+
+	// The dataset is uncaveated: LookupSubjects returns a subject found via both caveated and
+	// uncaveated relationships once for each, which the suite reports as an inconsistency. It
+	// is also narrower than the small config, as the suite checks every pair of objects.
+	config := testfixtures.GeneratorConfig{Seed: 1, Depth: 3, FanOut: 2, Users: 8}
+
+	filePath := path.Join(t.TempDir(), "generated.yaml")
+	contents := testfixtures.Generate(config).ValidationFile()
+	require.NoError(t, os.WriteFile(filePath, []byte(contents), 0o600))
+
+	for _, dispatcherKind := range []string{"local", "caching"} {
+		dispatcherKind := dispatcherKind
+
+		t.Run(dispatcherKind, func(t *testing.T) {
+			t.Parallel()
+			runConsistencyTestSuiteForFile(t, filePath, dispatcherKind == "caching")
+		})
+	}
+}
+
 func runConsistencyTestSuiteForFile(t *testing.T, filePath string, useCachingDispatcher bool) {
 	cad := consistencytestutil.LoadDataAndCreateClusterForTesting(t, filePath, testTimedelta)
 
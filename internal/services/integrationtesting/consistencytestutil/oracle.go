@@ -0,0 +1,376 @@
+package consistencytestutil
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+
+	"github.com/authzed/spicedb/internal/caveats"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// truth is a three-valued truth value, ordered so that union is the maximum and intersection
+// the minimum of its operands.
+type truth int
+
+const (
+	no truth = iota
+	conditional
+	yes
+)
+
+func (t truth) not() truth {
+	return yes - t
+}
+
+func (t truth) and(other truth) truth {
+	if other < t {
+		return other
+	}
+	return t
+}
+
+func (t truth) or(other truth) truth {
+	if other > t {
+		return other
+	}
+	return t
+}
+
+func (t truth) permissionship() v1.CheckPermissionResponse_Permissionship {
+	switch t {
+	case yes:
+		return v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION
+	case conditional:
+		return v1.CheckPermissionResponse_PERMISSIONSHIP_CONDITIONAL_PERMISSION
+	default:
+		return v1.CheckPermissionResponse_PERMISSIONSHIP_NO_PERMISSION
+	}
+}
+
+// oracleMode is the way in which the oracle treats caveats and wildcards.
+type oracleMode int
+
+const (
+	// checkMode evaluates caveats over the context stored on relationships, as Check does
+	// without a request context, and matches subjects against wildcards.
+	checkMode oracleMode = iota
+
+	// expandMode leaves every caveat unevaluated, matches no subject against wildcards and
+	// finds subjects only in relationships, as the terminal subjects of an expansion are
+	// computed.
+	expandMode
+)
+
+// maxOracleIterations bounds the number of passes made over the objects when computing a
+// closure, which only fails to converge for schemas with cycles through exclusions.
+const maxOracleIterations = 1000
+
+// Oracle computes permissions by a naive closure over a full snapshot of the relationships,
+// independently of the dispatcher, for cross-checking the results of the API.
+type Oracle struct {
+	ctx       context.Context
+	reader    datastore.CaveatReader
+	relations map[string][]*core.Relation
+	objectIDs map[string][]string
+	tuples    map[string][]*core.RelationTuple
+	caveats   map[*core.RelationTuple]truth
+	closures  map[string]map[string]truth
+}
+
+// NewOracle returns an oracle over the given namespaces and relationships, reading the
+// definitions of caveats from the reader.
+func NewOracle(ctx context.Context, reader datastore.CaveatReader, namespaces []*core.NamespaceDefinition, tuples []*core.RelationTuple) *Oracle {
+	o := &Oracle{
+		ctx:       ctx,
+		reader:    reader,
+		relations: make(map[string][]*core.Relation, len(namespaces)),
+		objectIDs: make(map[string][]string, len(namespaces)),
+		tuples:    make(map[string][]*core.RelationTuple),
+		caveats:   make(map[*core.RelationTuple]truth),
+		closures:  make(map[string]map[string]truth),
+	}
+
+	for _, nsDef := range namespaces {
+		o.relations[nsDef.Name] = nsDef.Relation
+	}
+
+	seen := make(map[string]struct{})
+	addObject := func(namespace, objectID string) {
+		key := namespace + ":" + objectID
+		if _, ok := seen[key]; ok || objectID == tuple.PublicWildcard {
+			return
+		}
+		seen[key] = struct{}{}
+		o.objectIDs[namespace] = append(o.objectIDs[namespace], objectID)
+	}
+
+	for _, tpl := range tuples {
+		addObject(tpl.ResourceAndRelation.Namespace, tpl.ResourceAndRelation.ObjectId)
+		addObject(tpl.Subject.Namespace, tpl.Subject.ObjectId)
+
+		key := tuple.StringONR(tpl.ResourceAndRelation)
+		o.tuples[key] = append(o.tuples[key], tpl)
+	}
+
+	for _, ids := range o.objectIDs {
+		sort.Strings(ids)
+	}
+	return o
+}
+
+// ObjectIDs returns the IDs of the objects of the namespace found in the relationships.
+func (o *Oracle) ObjectIDs(namespace string) []string {
+	return o.objectIDs[namespace]
+}
+
+// Check returns the permissionship of the subject for the resource, as returned by a check
+// without a caveat context.
+func (o *Oracle) Check(resource *core.ObjectAndRelation, subject *core.ObjectAndRelation) (v1.CheckPermissionResponse_Permissionship, error) {
+	closure, err := o.closure(subject, checkMode)
+	if err != nil {
+		return v1.CheckPermissionResponse_PERMISSIONSHIP_UNSPECIFIED, err
+	}
+	return closure[tuple.StringONR(resource)].permissionship(), nil
+}
+
+// LookupResources returns the permissionship of the subject for each resource of the type
+// for which it has permission, keyed by resource ID.
+func (o *Oracle) LookupResources(resourceRelation *core.RelationReference, subject *core.ObjectAndRelation) (map[string]v1.CheckPermissionResponse_Permissionship, error) {
+	closure, err := o.closure(subject, checkMode)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]v1.CheckPermissionResponse_Permissionship)
+	for _, objectID := range o.objectIDs[resourceRelation.Namespace] {
+		onr := tuple.StringONR(&core.ObjectAndRelation{
+			Namespace: resourceRelation.Namespace,
+			ObjectId:  objectID,
+			Relation:  resourceRelation.Relation,
+		})
+		if t := closure[onr]; t != no {
+			found[objectID] = t.permissionship()
+		}
+	}
+	return found, nil
+}
+
+// ExpandedSubjects returns the subjects, of those given, which are found amongst the terminal
+// subjects of a recursive expansion of the resource. Such subjects may be found even if
+// caveats deny them permission.
+func (o *Oracle) ExpandedSubjects(resource *core.ObjectAndRelation, subjects []*core.ObjectAndRelation) ([]*core.ObjectAndRelation, error) {
+	var found []*core.ObjectAndRelation
+	for _, subject := range subjects {
+		closure, err := o.closure(subject, expandMode)
+		if err != nil {
+			return nil, err
+		}
+		if closure[tuple.StringONR(resource)] != no {
+			found = append(found, subject)
+		}
+	}
+	return found, nil
+}
+
+// closure computes the truth of the subject being a member of each relation of each object,
+// by repeatedly evaluating every relation until no value changes.
+func (o *Oracle) closure(subject *core.ObjectAndRelation, mode oracleMode) (map[string]truth, error) {
+	cacheKey := fmt.Sprintf("%s/%d", tuple.StringONR(subject), mode)
+	if closure, ok := o.closures[cacheKey]; ok {
+		return closure, nil
+	}
+
+	closure := make(map[string]truth)
+	for iteration := 0; ; iteration++ {
+		if iteration == maxOracleIterations {
+			return nil, fmt.Errorf("closure for subject %s did not converge", tuple.StringONR(subject))
+		}
+
+		changed := false
+		for namespace, relations := range o.relations {
+			for _, objectID := range o.objectIDs[namespace] {
+				for _, relation := range relations {
+					onr := &core.ObjectAndRelation{Namespace: namespace, ObjectId: objectID, Relation: relation.Name}
+
+					t, err := o.evaluate(closure, onr, relation, subject, mode)
+					if err != nil {
+						return nil, err
+					}
+
+					key := tuple.StringONR(onr)
+					if closure[key] != t {
+						closure[key] = t
+						changed = true
+					}
+				}
+			}
+		}
+
+		if !changed {
+			break
+		}
+	}
+
+	o.closures[cacheKey] = closure
+	return closure, nil
+}
+
+func (o *Oracle) evaluate(closure map[string]truth, onr *core.ObjectAndRelation, relation *core.Relation, subject *core.ObjectAndRelation, mode oracleMode) (truth, error) {
+	if mode == checkMode && onr.EqualVT(subject) {
+		return yes, nil
+	}
+
+	if relation.UsersetRewrite == nil {
+		return o.direct(closure, onr, subject, mode)
+	}
+	return o.rewrite(closure, onr, relation.UsersetRewrite, subject, mode)
+}
+
+// direct evaluates the relationships of a relation.
+func (o *Oracle) direct(closure map[string]truth, onr *core.ObjectAndRelation, subject *core.ObjectAndRelation, mode oracleMode) (truth, error) {
+	result := no
+	for _, tpl := range o.tuples[tuple.StringONR(onr)] {
+		var matched truth
+		switch {
+		case tpl.Subject.ObjectId == tuple.PublicWildcard:
+			if mode == checkMode && tpl.Subject.Namespace == subject.Namespace && subject.Relation == tuple.Ellipsis {
+				matched = yes
+			}
+
+		case tpl.Subject.EqualVT(subject):
+			matched = yes
+
+		case tpl.Subject.Relation != tuple.Ellipsis:
+			matched = closure[tuple.StringONR(tpl.Subject)]
+		}
+
+		if matched == no {
+			continue
+		}
+
+		caveat, err := o.caveat(tpl, mode)
+		if err != nil {
+			return no, err
+		}
+		result = result.or(matched.and(caveat))
+	}
+	return result, nil
+}
+
+func (o *Oracle) rewrite(closure map[string]truth, onr *core.ObjectAndRelation, rewrite *core.UsersetRewrite, subject *core.ObjectAndRelation, mode oracleMode) (truth, error) {
+	var children []*core.SetOperation_Child
+	switch op := rewrite.RewriteOperation.(type) {
+	case *core.UsersetRewrite_Union:
+		children = op.Union.Child
+	case *core.UsersetRewrite_Intersection:
+		children = op.Intersection.Child
+	case *core.UsersetRewrite_Exclusion:
+		children = op.Exclusion.Child
+	default:
+		return no, fmt.Errorf("unknown rewrite operation %T", op)
+	}
+
+	var result truth
+	for index, child := range children {
+		t, err := o.child(closure, onr, child, subject, mode)
+		if err != nil {
+			return no, err
+		}
+
+		switch {
+		case index == 0:
+			result = t
+		case rewrite.GetUnion() != nil:
+			result = result.or(t)
+		case rewrite.GetIntersection() != nil:
+			result = result.and(t)
+		default:
+			result = result.and(t.not())
+		}
+	}
+	return result, nil
+}
+
+func (o *Oracle) child(closure map[string]truth, onr *core.ObjectAndRelation, child *core.SetOperation_Child, subject *core.ObjectAndRelation, mode oracleMode) (truth, error) {
+	switch ct := child.ChildType.(type) {
+	case *core.SetOperation_Child_XThis:
+		return o.direct(closure, onr, subject, mode)
+
+	case *core.SetOperation_Child_XNil:
+		return no, nil
+
+	case *core.SetOperation_Child_ComputedUserset:
+		computed := &core.ObjectAndRelation{Namespace: onr.Namespace, ObjectId: onr.ObjectId, Relation: ct.ComputedUserset.Relation}
+		if mode == checkMode && computed.EqualVT(subject) {
+			return yes, nil
+		}
+		return closure[tuple.StringONR(computed)], nil
+
+	case *core.SetOperation_Child_TupleToUserset:
+		tupleset := &core.ObjectAndRelation{Namespace: onr.Namespace, ObjectId: onr.ObjectId, Relation: ct.TupleToUserset.Tupleset.Relation}
+
+		result := no
+		for _, tpl := range o.tuples[tuple.StringONR(tupleset)] {
+			computed := &core.ObjectAndRelation{
+				Namespace: tpl.Subject.Namespace,
+				ObjectId:  tpl.Subject.ObjectId,
+				Relation:  ct.TupleToUserset.ComputedUserset.Relation,
+			}
+
+			matched := closure[tuple.StringONR(computed)]
+			if mode == checkMode && computed.EqualVT(subject) {
+				matched = yes
+			}
+			if matched == no {
+				continue
+			}
+
+			caveat, err := o.caveat(tpl, mode)
+			if err != nil {
+				return no, err
+			}
+			result = result.or(matched.and(caveat))
+		}
+		return result, nil
+
+	case *core.SetOperation_Child_UsersetRewrite:
+		return o.rewrite(closure, onr, ct.UsersetRewrite, subject, mode)
+
+	default:
+		return no, fmt.Errorf("unknown child type %T", ct)
+	}
+}
+
+// caveat evaluates the caveat of the relationship over the context stored on it.
+func (o *Oracle) caveat(tpl *core.RelationTuple, mode oracleMode) (truth, error) {
+	if tpl.Caveat == nil {
+		return yes, nil
+	}
+	if mode == expandMode {
+		return conditional, nil
+	}
+
+	if t, ok := o.caveats[tpl]; ok {
+		return t, nil
+	}
+
+	result, err := caveats.RunCaveatExpression(o.ctx, caveats.CaveatAsExpr(tpl.Caveat), nil, o.reader, caveats.RunCaveatExpressionNoDebugging)
+	if err != nil {
+		return no, err
+	}
+
+	t := no
+	switch {
+	case result.IsPartial():
+		t = conditional
+	case result.Value():
+		t = yes
+	}
+	o.caveats[tpl] = t
+	return t, nil
+}
@@ -0,0 +1,183 @@
+//go:build !skipintegrationtests
+// +build !skipintegrationtests
+
+package integrationtesting_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/developmentmembership"
+	"github.com/authzed/spicedb/internal/services/integrationtesting/consistencytestutil"
+	"github.com/authzed/spicedb/internal/testfixtures"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	dispatchv1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+	"github.com/authzed/spicedb/pkg/zedtoken"
+)
+
+// FuzzOracleConsistency cross-checks the results of Check, LookupResources and Expand over
+// randomly generated datasets against those computed by the brute-force oracle. The seed
+// corpus runs as part of the regular tests; the nightly fuzz job explores further.
+func FuzzOracleConsistency(f *testing.F) {
+	f.Add(int64(1), uint8(3), uint8(2), uint8(8), uint8(0))
+	f.Add(int64(2), uint8(2), uint8(3), uint8(5), uint8(30))
+	f.Add(int64(3), uint8(4), uint8(1), uint8(3), uint8(100))
+
+	f.Fuzz(func(t *testing.T, seed int64, depth, fanOut, users, caveatPercent uint8) {
+		config := testfixtures.GeneratorConfig{
+			Seed:        seed,
+			Depth:       int(depth)%3 + 1,
+			FanOut:      int(fanOut)%3 + 1,
+			Users:       int(users)%8 + 1,
+			CaveatRatio: float64(caveatPercent%101) / 100,
+		}
+		runOracleConsistency(t, config)
+	})
+}
+
+func runOracleConsistency(t *testing.T, config testfixtures.GeneratorConfig) {
+	filePath := path.Join(t.TempDir(), "generated.yaml")
+	contents := testfixtures.Generate(config).ValidationFile()
+	require.NoError(t, os.WriteFile(filePath, []byte(contents), 0o600))
+
+	cad := consistencytestutil.LoadDataAndCreateClusterForTesting(t, filePath, testTimedelta)
+	revision, err := cad.DataStore.HeadRevision(cad.Ctx)
+	require.NoError(t, err)
+
+	oracle := consistencytestutil.NewOracle(cad.Ctx, cad.DataStore.SnapshotReader(revision), cad.Populated.NamespaceDefinitions, cad.Populated.Tuples)
+	tester := consistencytestutil.ServiceTesters(cad.Conn)[0]
+	client := v1.NewPermissionsServiceClient(cad.Conn)
+	dispatcher := consistencytestutil.CreateDispatcherForTesting(t, false)
+
+	var subjects []*core.ObjectAndRelation
+	seenSubjects := make(map[string]struct{})
+	for _, tpl := range cad.Populated.Tuples {
+		if _, ok := seenSubjects[tuple.StringONR(tpl.Subject)]; ok || tpl.Subject.ObjectId == tuple.PublicWildcard {
+			continue
+		}
+		seenSubjects[tuple.StringONR(tpl.Subject)] = struct{}{}
+		subjects = append(subjects, tpl.Subject)
+	}
+
+	for _, nsDef := range cad.Populated.NamespaceDefinitions {
+		for _, relation := range nsDef.Relation {
+			resourceRelation := &core.RelationReference{Namespace: nsDef.Name, Relation: relation.Name}
+
+			for _, subject := range subjects {
+				expected, err := oracle.LookupResources(resourceRelation, subject)
+				require.NoError(t, err)
+
+				found, err := lookupResources(client, resourceRelation, subject, revision)
+				require.NoError(t, err)
+				require.Equal(t, expected, found, "lookup of %s#%s for %s in %+v", nsDef.Name, relation.Name, tuple.StringONR(subject), config)
+			}
+
+			for _, resourceID := range oracle.ObjectIDs(nsDef.Name) {
+				resource := &core.ObjectAndRelation{Namespace: nsDef.Name, ObjectId: resourceID, Relation: relation.Name}
+
+				for _, subject := range subjects {
+					expected, err := oracle.Check(resource, subject)
+					require.NoError(t, err)
+
+					found, err := tester.Check(cad.Ctx, resource, subject, revision, nil)
+					require.NoError(t, err)
+					require.Equal(t, expected, found, "check of %s for %s in %+v", tuple.StringONR(resource), tuple.StringONR(subject), config)
+				}
+
+				expected, err := oracle.ExpandedSubjects(resource, subjects)
+				require.NoError(t, err)
+
+				resp, err := dispatcher.DispatchExpand(cad.Ctx, &dispatchv1.DispatchExpandRequest{
+					ResourceAndRelation: resource,
+					Metadata: &dispatchv1.ResolverMeta{
+						AtRevision:     revision.String(),
+						DepthRemaining: 100,
+					},
+					ExpansionMode: dispatchv1.DispatchExpandRequest_RECURSIVE,
+				})
+				require.NoError(t, err)
+
+				expanded, err := developmentmembership.AccessibleExpansionSubjects(resp.TreeNode)
+				require.NoError(t, err)
+
+				var found []*core.ObjectAndRelation
+				for _, subject := range subjects {
+					if expanded.Contains(subject) {
+						found = append(found, subject)
+					}
+				}
+				require.Equal(t, onrStrings(expected), onrStrings(found), "expansion of %s in %+v", tuple.StringONR(resource), config)
+			}
+		}
+	}
+}
+
+// lookupResources returns the permissionship of the subject for each resource found by
+// LookupResources. A resource reachable along several paths may be returned once for each, in
+// which case it is accessible if it is accessible along any of them.
+func lookupResources(client v1.PermissionsServiceClient, resourceRelation *core.RelationReference, subject *core.ObjectAndRelation, revision datastore.Revision) (map[string]v1.CheckPermissionResponse_Permissionship, error) {
+	subjectRelation := subject.Relation
+	if subjectRelation == tuple.Ellipsis {
+		subjectRelation = ""
+	}
+
+	stream, err := client.LookupResources(context.Background(), &v1.LookupResourcesRequest{
+		ResourceObjectType: resourceRelation.Namespace,
+		Permission:         resourceRelation.Relation,
+		Subject: &v1.SubjectReference{
+			Object: &v1.ObjectReference{
+				ObjectType: subject.Namespace,
+				ObjectId:   subject.ObjectId,
+			},
+			OptionalRelation: subjectRelation,
+		},
+		Consistency: &v1.Consistency{
+			Requirement: &v1.Consistency_AtLeastAsFresh{
+				AtLeastAsFresh: zedtoken.MustNewFromRevision(revision),
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]v1.CheckPermissionResponse_Permissionship)
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return found, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch resp.Permissionship {
+		case v1.LookupPermissionship_LOOKUP_PERMISSIONSHIP_HAS_PERMISSION:
+			found[resp.ResourceObjectId] = v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION
+		case v1.LookupPermissionship_LOOKUP_PERMISSIONSHIP_CONDITIONAL_PERMISSION:
+			if _, ok := found[resp.ResourceObjectId]; !ok {
+				found[resp.ResourceObjectId] = v1.CheckPermissionResponse_PERMISSIONSHIP_CONDITIONAL_PERMISSION
+			}
+		default:
+			return nil, fmt.Errorf("unexpected permissionship %v", resp.Permissionship)
+		}
+	}
+}
+
+func onrStrings(onrs []*core.ObjectAndRelation) []string {
+	strs := make([]string, 0, len(onrs))
+	for _, onr := range onrs {
+		strs = append(strs, tuple.StringONR(onr))
+	}
+	return strs
+}
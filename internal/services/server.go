@@ -1,6 +1,7 @@
 package services
 
 import (
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	"github.com/authzed/grpcutil"
 	"google.golang.org/grpc"
@@ -8,8 +9,12 @@ import (
 	"google.golang.org/grpc/reflection"
 
 	"github.com/authzed/spicedb/internal/dispatch"
+	adminsvc "github.com/authzed/spicedb/internal/services/admin/v1"
 	"github.com/authzed/spicedb/internal/services/health"
+	v0svc "github.com/authzed/spicedb/internal/services/v0"
 	v1svc "github.com/authzed/spicedb/internal/services/v1"
+	adminv1 "github.com/authzed/spicedb/pkg/proto/admin/v1"
+	experimentalv1 "github.com/authzed/spicedb/pkg/proto/experimental/v1"
 )
 
 // SchemaServiceOption defines the options for enabling or disabling the V1 Schema service.
@@ -18,6 +23,9 @@ type SchemaServiceOption int
 // WatchServiceOption defines the options for enabling or disabling the V1 Watch service.
 type WatchServiceOption int
 
+// AdminServiceOption defines the options for enabling or disabling the admin service.
+type AdminServiceOption int
+
 // CaveatsOption defines the options for enabling or disabling caveats in the V1 services.
 type CaveatsOption int
 
@@ -37,6 +45,12 @@ const (
 
 	// WatchServiceEnabled indicates that the V1 watch service is enabled.
 	WatchServiceEnabled WatchServiceOption = 1
+
+	// AdminServiceDisabled indicates that the admin service is disabled.
+	AdminServiceDisabled AdminServiceOption = 0
+
+	// AdminServiceEnabled indicates that the admin service is enabled.
+	AdminServiceEnabled AdminServiceOption = 1
 )
 
 const (
@@ -51,6 +65,7 @@ func RegisterGrpcServices(
 	dispatch dispatch.Dispatcher,
 	schemaServiceOption SchemaServiceOption,
 	watchServiceOption WatchServiceOption,
+	adminServiceOption AdminServiceOption,
 	permSysConfig v1svc.PermissionsServerConfig,
 ) {
 	healthManager.RegisterReportedService(OverallServerHealthCheckKey)
@@ -58,6 +73,13 @@ func RegisterGrpcServices(
 	v1.RegisterPermissionsServiceServer(srv, v1svc.NewPermissionsServer(dispatch, permSysConfig))
 	healthManager.RegisterReportedService(v1.PermissionsService_ServiceDesc.ServiceName)
 
+	// Rolling back the schema is a write to the schema, subject to the same restrictions.
+	permSysConfig.SchemaRollbackDisabled = schemaServiceOption == V1SchemaServiceDisabled
+	permSysConfig.AdditiveOnlySchema = schemaServiceOption == V1SchemaServiceAdditiveOnly
+
+	experimentalv1.RegisterExperimentalServiceServer(srv, v1svc.NewExperimentalServer(dispatch, permSysConfig))
+	healthManager.RegisterReportedService(experimentalv1.ExperimentalService_ServiceDesc.ServiceName)
+
 	if watchServiceOption == WatchServiceEnabled {
 		v1.RegisterWatchServiceServer(srv, v1svc.NewWatchServer())
 		healthManager.RegisterReportedService(v1.WatchService_ServiceDesc.ServiceName)
@@ -68,6 +90,19 @@ func RegisterGrpcServices(
 		healthManager.RegisterReportedService(v1.SchemaService_ServiceDesc.ServiceName)
 	}
 
+	if adminServiceOption == AdminServiceEnabled {
+		adminv1.RegisterAdminServiceServer(srv, adminsvc.NewAdminServer(dispatch, permSysConfig.MaximumAPIDepth, permSysConfig.TraceRecorder))
+		healthManager.RegisterReportedService(adminv1.AdminService_ServiceDesc.ServiceName)
+	}
+
 	healthpb.RegisterHealthServer(srv, healthManager.HealthSvc())
 	reflection.Register(grpcutil.NewAuthlessReflectionInterceptor(srv))
 }
+
+// RegisterDeveloperService registers the developer service used by the playground, which
+// compiles and validates schemas and test data in memory, independently of the datastore, and
+// shares them through the given share store.
+func RegisterDeveloperService(srv *grpc.Server, healthManager health.Manager, shareStore v0svc.ShareStore) {
+	v0.RegisterDeveloperServiceServer(srv, v0svc.NewDeveloperServer(shareStore))
+	healthManager.RegisterReportedService(v0.DeveloperService_ServiceDesc.ServiceName)
+}
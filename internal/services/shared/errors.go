@@ -11,6 +11,7 @@ import (
 
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 
+	"github.com/authzed/spicedb/pkg/datastore"
 	"github.com/authzed/spicedb/pkg/spiceerrors"
 )
 
@@ -28,6 +29,16 @@ func mustMakeStatusReadonly() error {
 	return status.Err()
 }
 
+// RewriteInvalidRevisionErr returns the gRPC error for an invalid revision, with the reason the
+// revision is invalid.
+func RewriteInvalidRevisionErr(err datastore.ErrInvalidRevision, prefix string) error {
+	reason := spiceerrors.ReasonInvalidRevision
+	if err.Reason() == datastore.RevisionStale {
+		reason = spiceerrors.ReasonRevisionStale
+	}
+	return spiceerrors.WithCodeAndReasonName(fmt.Errorf("%s: %w", prefix, err), codes.OutOfRange, reason)
+}
+
 // NewSchemaWriteDataValidationError creates a new error representing that a schema write cannot be
 // completed due to existing data that would be left unreferenced.
 func NewSchemaWriteDataValidationError(message string, args ...any) ErrSchemaWriteDataValidation {
@@ -5,11 +5,13 @@ import (
 
 	"github.com/authzed/spicedb/internal/caveats"
 	"github.com/authzed/spicedb/internal/datastore/options"
+	"github.com/authzed/spicedb/internal/datastore/proxy"
 	log "github.com/authzed/spicedb/internal/logging"
 	"github.com/authzed/spicedb/internal/namespace"
 	"github.com/authzed/spicedb/pkg/datastore"
 	core "github.com/authzed/spicedb/pkg/proto/core/v1"
 	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
+	"github.com/authzed/spicedb/pkg/schemadsl/input"
 	"github.com/authzed/spicedb/pkg/tuple"
 	"github.com/authzed/spicedb/pkg/util"
 )
@@ -393,12 +395,35 @@ func errorIfTupleIteratorReturnsTuples(ctx context.Context, qy datastore.Relatio
 	}
 	defer qy.Close()
 
-	if rt := qy.Next(); rt != nil {
-		if qy.Err() != nil {
-			return qy.Err()
-		}
-
+	rt, err := qy.Next()
+	if err != nil {
+		return err
+	}
+	if rt != nil {
 		return NewSchemaWriteDataValidationError(message, args...)
 	}
 	return nil
 }
+
+// CompileProposedSchema compiles and validates the schema text, returning its definitions as
+// they would be written.
+func CompileProposedSchema(ctx context.Context, schemaText string) (*proxy.OverlaySchema, error) {
+	emptyDefaultPrefix := ""
+	compiled, err := compiler.Compile(compiler.InputSchema{
+		Source:       input.Source("schema"),
+		SchemaString: schemaText,
+	}, &emptyDefaultPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validation annotates the definitions with their types, as when they are written.
+	if _, err := ValidateSchemaChanges(ctx, compiled, false); err != nil {
+		return nil, err
+	}
+
+	return &proxy.OverlaySchema{
+		Namespaces: compiled.ObjectDefinitions,
+		Caveats:    compiled.CaveatDefinitions,
+	}, nil
+}
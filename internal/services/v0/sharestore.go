@@ -2,6 +2,7 @@ package v0
 
 import (
 	"bytes"
+	"container/list"
 	"context"
 	"crypto/sha256"
 	"encoding/base64"
@@ -11,6 +12,7 @@ import (
 	"io"
 	"io/ioutil"
 	"strings"
+	"sync"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
@@ -104,6 +106,71 @@ func (ims *inMemoryShareStore) StoreShared(shared SharedDataV2) (string, error)
 	return reference, nil
 }
 
+// NewBoundedInMemoryShareStore creates a new in memory share store holding the most recently
+// shared data, up to the given total size in bytes, which is safe for concurrent use.
+func NewBoundedInMemoryShareStore(salt string, maxBytes int) ShareStore {
+	return &boundedInMemoryShareStore{
+		salt:     salt,
+		maxBytes: maxBytes,
+		order:    list.New(),
+		shared:   map[string]*list.Element{},
+	}
+}
+
+type boundedShare struct {
+	reference string
+	data      []byte
+}
+
+type boundedInMemoryShareStore struct {
+	sync.Mutex
+	salt     string
+	maxBytes int
+	bytes    int
+	order    *list.List
+	shared   map[string]*list.Element
+}
+
+func (bms *boundedInMemoryShareStore) LookupSharedByReference(reference string) (SharedDataV2, LookupStatus, error) {
+	bms.Lock()
+	found, ok := bms.shared[reference]
+	bms.Unlock()
+	if !ok {
+		return SharedDataV2{}, LookupNotFound, nil
+	}
+
+	return unmarshalShared(found.Value.(boundedShare).data)
+}
+
+func (bms *boundedInMemoryShareStore) StoreShared(shared SharedDataV2) (string, error) {
+	data, reference, err := marshalShared(shared, bms.salt)
+	if err != nil {
+		return "", err
+	}
+	if len(data) > bms.maxBytes {
+		return "", fmt.Errorf("shared data of %d bytes exceeds the share store size of %d bytes", len(data), bms.maxBytes)
+	}
+
+	bms.Lock()
+	defer bms.Unlock()
+
+	if existing, ok := bms.shared[reference]; ok {
+		bms.order.Remove(existing)
+		bms.bytes -= len(existing.Value.(boundedShare).data)
+	}
+	bms.shared[reference] = bms.order.PushBack(boundedShare{reference, data})
+	bms.bytes += len(data)
+
+	for bms.bytes > bms.maxBytes {
+		oldest := bms.order.Front()
+		bms.order.Remove(oldest)
+		evicted := oldest.Value.(boundedShare)
+		delete(bms.shared, evicted.reference)
+		bms.bytes -= len(evicted.data)
+	}
+	return reference, nil
+}
+
 type s3ShareStore struct {
 	bucket   string
 	salt     string
@@ -2,6 +2,7 @@ package v0
 
 import (
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -55,3 +56,41 @@ func TestS3ShareStore(t *testing.T) {
 	require.Equal(LookupSuccess, status)
 	require.Equal("foo", sd.Schema)
 }
+
+func TestBoundedInMemoryShareStore(t *testing.T) {
+	require := require.New(t)
+
+	first := SharedDataV2{Version: sharedDataVersion, Schema: "definition user {}"}
+	data, _, err := marshalShared(first, "salt")
+	require.NoError(err)
+
+	// The store holds two shares of the size.
+	sharestore := NewBoundedInMemoryShareStore("salt", 2*len(data)+1)
+
+	firstRef, err := sharestore.StoreShared(first)
+	require.NoError(err)
+	secondRef, err := sharestore.StoreShared(SharedDataV2{Version: sharedDataVersion, Schema: "definition team {}"})
+	require.NoError(err)
+
+	_, status, err := sharestore.LookupSharedByReference(firstRef)
+	require.NoError(err)
+	require.Equal(LookupSuccess, status)
+
+	// Storing a third share evicts the oldest.
+	thirdRef, err := sharestore.StoreShared(SharedDataV2{Version: sharedDataVersion, Schema: "definition role {}"})
+	require.NoError(err)
+
+	_, status, err = sharestore.LookupSharedByReference(firstRef)
+	require.NoError(err)
+	require.Equal(LookupNotFound, status)
+
+	for _, ref := range []string{secondRef, thirdRef} {
+		_, status, err = sharestore.LookupSharedByReference(ref)
+		require.NoError(err)
+		require.Equal(LookupSuccess, status)
+	}
+
+	// Shares larger than the store are rejected.
+	_, err = sharestore.StoreShared(SharedDataV2{Version: sharedDataVersion, Schema: strings.Repeat("definition user {}\n", 10)})
+	require.Error(err)
+}
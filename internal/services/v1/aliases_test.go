@@ -0,0 +1,106 @@
+package v1_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/authzed/grpcutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	tf "github.com/authzed/spicedb/internal/testfixtures"
+	"github.com/authzed/spicedb/internal/testserver"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+func TestRelationAliases(t *testing.T) {
+	req := require.New(t)
+	conn, cleanup, _, _ := testserver.NewTestServer(req, 0, memdb.DisableGC, true,
+		func(ds datastore.Datastore, require *require.Assertions) (datastore.Datastore, datastore.Revision) {
+			return tf.DatastoreFromSchemaAndTestRelationships(ds, `
+				definition user {}
+
+				definition group {
+					relation member: user
+					alias participant = member
+				}
+
+				definition document {
+					relation viewer: user | group#member
+					alias reader = viewer
+				}
+			`, []*core.RelationTuple{
+				tuple.MustParse("document:first#viewer@user:tom"),
+				tuple.MustParse("document:first#viewer@group:eng#member"),
+				tuple.MustParse("group:eng#member@user:sarah"),
+			}, require)
+		})
+	t.Cleanup(cleanup)
+
+	client := v1.NewPermissionsServiceClient(conn)
+	fullyConsistent := &v1.Consistency{Requirement: &v1.Consistency_FullyConsistent{FullyConsistent: true}}
+
+	// Checks of the alias are checks of the relation.
+	for _, subject := range []string{"tom", "sarah"} {
+		resp, err := client.CheckPermission(context.Background(), &v1.CheckPermissionRequest{
+			Consistency: fullyConsistent,
+			Resource:    &v1.ObjectReference{ObjectType: "document", ObjectId: "first"},
+			Permission:  "reader",
+			Subject:     &v1.SubjectReference{Object: &v1.ObjectReference{ObjectType: "user", ObjectId: subject}},
+		})
+		req.NoError(err)
+		req.Equal(v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION, resp.Permissionship)
+	}
+
+	// Reads of the alias are reads of the relation.
+	readRelationships := func(filter *v1.RelationshipFilter) []string {
+		stream, err := client.ReadRelationships(context.Background(), &v1.ReadRelationshipsRequest{
+			Consistency:        fullyConsistent,
+			RelationshipFilter: filter,
+		})
+		req.NoError(err)
+
+		var found []string
+		for {
+			resp, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				return found
+			}
+			req.NoError(err)
+			found = append(found, tuple.MustRelString(resp.Relationship))
+		}
+	}
+
+	req.ElementsMatch([]string{
+		"document:first#viewer@user:tom",
+		"document:first#viewer@group:eng#member",
+	}, readRelationships(&v1.RelationshipFilter{ResourceType: "document", OptionalRelation: "reader"}))
+
+	req.Equal([]string{"document:first#viewer@group:eng#member"}, readRelationships(&v1.RelationshipFilter{
+		ResourceType: "document",
+		OptionalSubjectFilter: &v1.SubjectFilter{
+			SubjectType:      "group",
+			OptionalRelation: &v1.SubjectFilter_RelationFilter{Relation: "participant"},
+		},
+	}))
+
+	// Writes and deletes must use the relation.
+	_, err := client.WriteRelationships(context.Background(), &v1.WriteRelationshipsRequest{
+		Updates: []*v1.RelationshipUpdate{createUpdate("document:second#reader@user:tom")},
+	})
+	grpcutil.RequireStatus(t, codes.InvalidArgument, err)
+	req.Contains(err.Error(), "must be written to `viewer`")
+
+	_, err = client.DeleteRelationships(context.Background(), &v1.DeleteRelationshipsRequest{
+		RelationshipFilter: &v1.RelationshipFilter{ResourceType: "document", OptionalRelation: "reader"},
+	})
+	grpcutil.RequireStatus(t, codes.InvalidArgument, err)
+
+	req.Len(readRelationships(&v1.RelationshipFilter{ResourceType: "document", OptionalRelation: "viewer"}), 2)
+}
@@ -0,0 +1,67 @@
+package v1
+
+import (
+	"context"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/authzed/spicedb/internal/middleware/usagemetrics"
+	dispatchv1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+	experimentalv1 "github.com/authzed/spicedb/pkg/proto/experimental/v1"
+)
+
+// BatchWriteRelationships applies each of the updates in turn as a call to WriteRelationships
+// of its own, so that each is validated and applied exactly as it would be alone, and records
+// the result of each rather than failing the call.
+func (es *experimentalServer) BatchWriteRelationships(ctx context.Context, req *experimentalv1.BatchWriteRelationshipsRequest) (*experimentalv1.BatchWriteRelationshipsResponse, error) {
+	permissions := es.permissions.(*permissionServer)
+	if len(req.Updates) > int(permissions.config.MaxUpdatesPerWrite) {
+		return nil, rewriteError(
+			ctx,
+			NewExceedsMaximumUpdatesErr(uint16(len(req.Updates)), permissions.config.MaxUpdatesPerWrite),
+		)
+	}
+
+	resp := &experimentalv1.BatchWriteRelationshipsResponse{
+		Results: make([]*experimentalv1.BatchWriteRelationshipsResult, 0, len(req.Updates)),
+	}
+	for _, update := range req.Updates {
+		written, err := es.writeRelationship(ctx, permissions, update)
+		if err != nil {
+			resp.Results = append(resp.Results, &experimentalv1.BatchWriteRelationshipsResult{
+				Result: &experimentalv1.BatchWriteRelationshipsResult_Error{
+					Error: status.Convert(rewriteError(ctx, err)).Proto(),
+				},
+			})
+			continue
+		}
+
+		resp.Results = append(resp.Results, &experimentalv1.BatchWriteRelationshipsResult{
+			Result: &experimentalv1.BatchWriteRelationshipsResult_WrittenAt{
+				WrittenAt: written.WrittenAt,
+			},
+		})
+	}
+
+	// Each write sets its own metadata, which is replaced with that of the whole batch.
+	usagemetrics.SetInContext(ctx, &dispatchv1.ResponseMeta{
+		DispatchCount: uint32(len(req.Updates)),
+	})
+	return resp, nil
+}
+
+// writeRelationship validates the update as the request of a call to WriteRelationships would
+// be, as the items of batches are not validated with the batch, and applies it.
+func (es *experimentalServer) writeRelationship(ctx context.Context, permissions *permissionServer, update *v1.RelationshipUpdate) (*v1.WriteRelationshipsResponse, error) {
+	writeReq := &v1.WriteRelationshipsRequest{Updates: []*v1.RelationshipUpdate{update}}
+	if err := writeReq.ValidateAll(); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%s", err)
+	}
+	if err := writeReq.HandwrittenValidate(); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%s", err)
+	}
+
+	return permissions.WriteRelationships(ctx, writeReq)
+}
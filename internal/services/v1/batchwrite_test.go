@@ -0,0 +1,78 @@
+package v1_test
+
+import (
+	"context"
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/authzed/grpcutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	tf "github.com/authzed/spicedb/internal/testfixtures"
+	"github.com/authzed/spicedb/internal/testserver"
+	experimentalv1 "github.com/authzed/spicedb/pkg/proto/experimental/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+func createUpdate(relationship string) *v1.RelationshipUpdate {
+	return tuple.UpdateToRelationshipUpdate(tuple.Create(tuple.MustParse(relationship)))
+}
+
+func TestBatchWriteRelationships(t *testing.T) {
+	require := require.New(t)
+	conn, cleanup, _, _ := testserver.NewTestServer(require, 0, memdb.DisableGC, true, tf.StandardDatastoreWithData)
+	t.Cleanup(cleanup)
+
+	client := experimentalv1.NewExperimentalServiceClient(conn)
+	invalid := createUpdate("document:secret#viewer@user:auditor")
+	invalid.Relationship.Resource.ObjectId = "not valid!"
+
+	resp, err := client.BatchWriteRelationships(context.Background(), &experimentalv1.BatchWriteRelationshipsRequest{
+		Updates: []*v1.RelationshipUpdate{
+			createUpdate("document:secret#viewer@user:auditor"),
+			createUpdate("document:masterplan#owner@user:product_manager"),
+			createUpdate("unknown:secret#viewer@user:auditor"),
+			invalid,
+			tuple.UpdateToRelationshipUpdate(tuple.Touch(tuple.MustParse("document:secret#viewer@user:tom"))),
+			nil,
+		},
+	})
+	require.NoError(err)
+	require.Len(resp.Results, 6)
+
+	// The updates which fail do not prevent the others from being applied.
+	require.NotNil(resp.Results[0].GetWrittenAt())
+	require.Contains(resp.Results[1].GetError().GetMessage(), "already existed")
+	require.Equal(int32(codes.FailedPrecondition), resp.Results[2].GetError().GetCode())
+	require.Equal(int32(codes.InvalidArgument), resp.Results[3].GetError().GetCode())
+	require.NotNil(resp.Results[4].GetWrittenAt())
+	require.Equal(int32(codes.InvalidArgument), resp.Results[5].GetError().GetCode())
+
+	found, err := readRelationshipsAt(client, atRevision(resp.Results[4].GetWrittenAt()), &v1.RelationshipFilter{
+		ResourceType:       "document",
+		OptionalResourceId: "secret",
+	})
+	require.NoError(err)
+	require.Equal([]string{"document:secret#viewer@user:auditor", "document:secret#viewer@user:tom"}, found)
+}
+
+func TestBatchWriteRelationshipsLimit(t *testing.T) {
+	require := require.New(t)
+	conn, cleanup, _, _ := testserver.NewTestServerWithConfig(require, 0, memdb.DisableGC, true,
+		testserver.ServerConfig{
+			MaxUpdatesPerWrite:    1,
+			MaxPreconditionsCount: 1000,
+		},
+		tf.StandardDatastoreWithData)
+	t.Cleanup(cleanup)
+
+	_, err := experimentalv1.NewExperimentalServiceClient(conn).BatchWriteRelationships(context.Background(), &experimentalv1.BatchWriteRelationshipsRequest{
+		Updates: []*v1.RelationshipUpdate{
+			createUpdate("document:secret#viewer@user:auditor"),
+			createUpdate("document:secret#viewer@user:tom"),
+		},
+	})
+	grpcutil.RequireStatus(t, codes.InvalidArgument, err)
+}
@@ -0,0 +1,142 @@
+package v1
+
+import (
+	"context"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+
+	"github.com/authzed/spicedb/internal/datastore/proxy"
+	"github.com/authzed/spicedb/internal/dispatch/graph"
+	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
+	"github.com/authzed/spicedb/internal/relationships"
+	"github.com/authzed/spicedb/pkg/middleware/consistency"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	experimentalv1 "github.com/authzed/spicedb/pkg/proto/experimental/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// contextualDispatchConcurrency is the concurrency of the dispatches of requests evaluated with
+// contextual relationships or other hypothetical changes, which are made locally, rather than
+// across the cluster, as the changes are only known to the server handling the request.
+const contextualDispatchConcurrency = 10
+
+// ContextualCheckPermission checks the permission as if the contextual relationships had been
+// written.
+func (es *experimentalServer) ContextualCheckPermission(ctx context.Context, req *experimentalv1.ContextualCheckPermissionRequest) (*v1.CheckPermissionResponse, error) {
+	ctx, permissions, closer, err := es.withContextualRelationships(ctx, req.ContextualRelationships)
+	if err != nil {
+		return nil, rewriteError(ctx, err)
+	}
+	defer closer()
+
+	return permissions.CheckPermission(ctx, &v1.CheckPermissionRequest{
+		Consistency: req.Consistency,
+		Resource:    req.Resource,
+		Permission:  req.Permission,
+		Subject:     req.Subject,
+		Context:     req.Context,
+	})
+}
+
+// ContextualLookupResources looks up the resources as if the contextual relationships had been
+// written.
+func (es *experimentalServer) ContextualLookupResources(req *experimentalv1.ContextualLookupResourcesRequest, resp experimentalv1.ExperimentalService_ContextualLookupResourcesServer) error {
+	ctx, permissions, closer, err := es.withContextualRelationships(resp.Context(), req.ContextualRelationships)
+	if err != nil {
+		return rewriteError(ctx, err)
+	}
+	defer closer()
+
+	return permissions.LookupResources(&v1.LookupResourcesRequest{
+		Consistency:        req.Consistency,
+		ResourceObjectType: req.ResourceObjectType,
+		Permission:         req.Permission,
+		Subject:            req.Subject,
+		Context:            req.Context,
+	}, &contextualLookupResourcesStream{resp, ctx})
+}
+
+// ContextualLookupSubjects looks up the subjects as if the contextual relationships had been
+// written.
+func (es *experimentalServer) ContextualLookupSubjects(req *experimentalv1.ContextualLookupSubjectsRequest, resp experimentalv1.ExperimentalService_ContextualLookupSubjectsServer) error {
+	ctx, permissions, closer, err := es.withContextualRelationships(resp.Context(), req.ContextualRelationships)
+	if err != nil {
+		return rewriteError(ctx, err)
+	}
+	defer closer()
+
+	return permissions.LookupSubjects(&v1.LookupSubjectsRequest{
+		Consistency:             req.Consistency,
+		Resource:                req.Resource,
+		Permission:              req.Permission,
+		SubjectObjectType:       req.SubjectObjectType,
+		OptionalSubjectRelation: req.OptionalSubjectRelation,
+		Context:                 req.Context,
+	}, &contextualLookupSubjectsStream{resp, ctx})
+}
+
+// withContextualRelationships returns a context whose datastore reads the contextual
+// relationships as if they had been written, along with a permissions server dispatching
+// against it. The closer must be called once the request has been handled.
+func (es *experimentalServer) withContextualRelationships(ctx context.Context, contextual []*v1.Relationship) (context.Context, *permissionServer, func(), error) {
+	updates := make([]*core.RelationTupleUpdate, 0, len(contextual))
+	for _, rel := range contextual {
+		updates = append(updates, tuple.Touch(tuple.MustFromRelationship(rel)))
+	}
+	return es.withOverlay(ctx, proxy.Overlay{Updates: updates})
+}
+
+// withOverlay validates the updates of the overlay against the schema of the overlay at the
+// revision of the request, and returns a context whose datastore reads the overlay as if it had
+// been written, along with a permissions server dispatching locally against it. Dispatches are
+// made by a dispatcher local to the request, so that results computed with the overlay are never
+// cached or dispatched to other servers. The closer must be called once the request has been
+// handled.
+func (es *experimentalServer) withOverlay(ctx context.Context, overlay proxy.Overlay) (context.Context, *permissionServer, func(), error) {
+	permissions := *es.permissions.(*permissionServer)
+	if len(overlay.Updates) == 0 && overlay.Schema == nil {
+		return ctx, &permissions, func() {}, nil
+	}
+
+	atRevision, _ := consistency.MustRevisionFromContext(ctx)
+	overlaid, err := proxy.NewOverlayDatastoreProxy(ctx, datastoremw.MustFromContext(ctx), overlay)
+	if err != nil {
+		return ctx, nil, nil, err
+	}
+
+	validate := relationships.ValidateRelationshipUpdates
+	if permissions.config.StrictRelationshipValidation {
+		validate = relationships.ValidateAllRelationshipUpdates
+	}
+	if err := validate(ctx, overlaid.SnapshotReader(atRevision), overlay.Updates); err != nil {
+		overlaid.Close()
+		return ctx, nil, nil, err
+	}
+
+	local := graph.NewLocalOnlyDispatcher(contextualDispatchConcurrency)
+	permissions.dispatch = local
+
+	closer := func() {
+		local.Close()
+		overlaid.Close()
+	}
+	return datastoremw.ContextWithDatastore(ctx, overlaid), &permissions, closer, nil
+}
+
+type contextualLookupResourcesStream struct {
+	experimentalv1.ExperimentalService_ContextualLookupResourcesServer
+	ctx context.Context
+}
+
+func (s *contextualLookupResourcesStream) Context() context.Context {
+	return s.ctx
+}
+
+type contextualLookupSubjectsStream struct {
+	experimentalv1.ExperimentalService_ContextualLookupSubjectsServer
+	ctx context.Context
+}
+
+func (s *contextualLookupSubjectsStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,231 @@
+package v1_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/authzed/grpcutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	tf "github.com/authzed/spicedb/internal/testfixtures"
+	"github.com/authzed/spicedb/internal/testserver"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	experimentalv1 "github.com/authzed/spicedb/pkg/proto/experimental/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+func TestContextualRelationships(t *testing.T) {
+	req := require.New(t)
+	conn, cleanup, _, _ := testserver.NewTestServer(req, 0, memdb.DisableGC, true,
+		func(ds datastore.Datastore, require *require.Assertions) (datastore.Datastore, datastore.Revision) {
+			return tf.DatastoreFromSchemaAndTestRelationships(ds, `
+				definition user {}
+
+				definition document {
+					relation viewer: user
+					relation editor: user
+					permission view = viewer + editor
+				}
+			`, []*core.RelationTuple{
+				tuple.MustParse("document:first#viewer@user:tom"),
+			}, require)
+		})
+	t.Cleanup(cleanup)
+
+	client := experimentalv1.NewExperimentalServiceClient(conn)
+	permissionsClient := v1.NewPermissionsServiceClient(conn)
+	fullyConsistent := &v1.Consistency{Requirement: &v1.Consistency_FullyConsistent{FullyConsistent: true}}
+	sarah := &v1.SubjectReference{Object: &v1.ObjectReference{ObjectType: "user", ObjectId: "sarah"}}
+	first := &v1.ObjectReference{ObjectType: "document", ObjectId: "first"}
+
+	check := func(contextual ...string) (*v1.CheckPermissionResponse, error) {
+		return client.ContextualCheckPermission(context.Background(), &experimentalv1.ContextualCheckPermissionRequest{
+			Consistency:             fullyConsistent,
+			Resource:                first,
+			Permission:              "view",
+			Subject:                 sarah,
+			ContextualRelationships: relationships(contextual...),
+		})
+	}
+
+	resp, err := check()
+	req.NoError(err)
+	req.Equal(v1.CheckPermissionResponse_PERMISSIONSHIP_NO_PERMISSION, resp.Permissionship)
+
+	resp, err = check("document:first#editor@user:sarah")
+	req.NoError(err)
+	req.Equal(v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION, resp.Permissionship)
+
+	// The contextual relationships are never written.
+	checkResp, err := permissionsClient.CheckPermission(context.Background(), &v1.CheckPermissionRequest{
+		Consistency: fullyConsistent,
+		Resource:    first,
+		Permission:  "view",
+		Subject:     sarah,
+	})
+	req.NoError(err)
+	req.Equal(v1.CheckPermissionResponse_PERMISSIONSHIP_NO_PERMISSION, checkResp.Permissionship)
+
+	// The contextual relationships must be valid under the schema.
+	_, err = check("document:first#view@user:sarah")
+	grpcutil.RequireStatus(t, codes.InvalidArgument, err)
+
+	_, err = check("folder:first#viewer@user:sarah")
+	grpcutil.RequireStatus(t, codes.FailedPrecondition, err)
+
+	resourcesStream, err := client.ContextualLookupResources(context.Background(), &experimentalv1.ContextualLookupResourcesRequest{
+		Consistency:             fullyConsistent,
+		ResourceObjectType:      "document",
+		Permission:              "view",
+		Subject:                 sarah,
+		ContextualRelationships: relationships("document:second#viewer@user:sarah"),
+	})
+	req.NoError(err)
+
+	var resources []string
+	for {
+		resp, err := resourcesStream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		req.NoError(err)
+		resources = append(resources, resp.ResourceObjectId)
+	}
+	req.Equal([]string{"second"}, resources)
+
+	subjectsStream, err := client.ContextualLookupSubjects(context.Background(), &experimentalv1.ContextualLookupSubjectsRequest{
+		Consistency:             fullyConsistent,
+		Resource:                first,
+		Permission:              "view",
+		SubjectObjectType:       "user",
+		ContextualRelationships: relationships("document:first#editor@user:sarah"),
+	})
+	req.NoError(err)
+
+	var subjects []string
+	for {
+		resp, err := subjectsStream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		req.NoError(err)
+		subjects = append(subjects, resp.Subject.SubjectObjectId)
+	}
+	req.ElementsMatch([]string{"tom", "sarah"}, subjects)
+}
+
+func relationships(rels ...string) []*v1.Relationship {
+	parsed := make([]*v1.Relationship, 0, len(rels))
+	for _, rel := range rels {
+		parsed = append(parsed, tuple.MustToRelationship(tuple.MustParse(rel)))
+	}
+	return parsed
+}
+
+func TestWhatIf(t *testing.T) {
+	req := require.New(t)
+	conn, cleanup, _, _ := testserver.NewTestServer(req, 0, memdb.DisableGC, true,
+		func(ds datastore.Datastore, require *require.Assertions) (datastore.Datastore, datastore.Revision) {
+			return tf.DatastoreFromSchemaAndTestRelationships(ds, `
+				definition user {}
+
+				definition document {
+					relation viewer: user
+					relation editor: user
+					permission view = viewer + editor
+				}
+			`, []*core.RelationTuple{
+				tuple.MustParse("document:first#viewer@user:tom"),
+				tuple.MustParse("document:first#editor@user:fred"),
+			}, require)
+		})
+	t.Cleanup(cleanup)
+
+	client := experimentalv1.NewExperimentalServiceClient(conn)
+	probe := func(permission, subjectID string) *experimentalv1.WhatIfProbe {
+		return &experimentalv1.WhatIfProbe{
+			Resource:   &v1.ObjectReference{ObjectType: "document", ObjectId: "first"},
+			Permission: permission,
+			Subject:    &v1.SubjectReference{Object: &v1.ObjectReference{ObjectType: "user", ObjectId: subjectID}},
+		}
+	}
+
+	resp, err := client.WhatIf(context.Background(), &experimentalv1.WhatIfRequest{
+		Consistency: &v1.Consistency{Requirement: &v1.Consistency_FullyConsistent{FullyConsistent: true}},
+		Updates: []*v1.RelationshipUpdate{
+			{Operation: v1.RelationshipUpdate_OPERATION_DELETE, Relationship: relationships("document:first#viewer@user:tom")[0]},
+			createUpdate("document:first#viewer@user:sarah"),
+		},
+		Schema: `
+			definition user {}
+
+			definition document {
+				relation viewer: user
+				relation editor: user
+				permission view = viewer
+				permission edit = editor
+			}
+		`,
+		Probes: []*experimentalv1.WhatIfProbe{
+			probe("view", "tom"),
+			probe("view", "sarah"),
+			probe("view", "fred"),
+			probe("edit", "fred"),
+			probe("view", "bob"),
+		},
+	})
+	req.NoError(err)
+	req.NotNil(resp.EvaluatedAt)
+	req.Len(resp.Results, 5)
+
+	has := v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION
+	no := v1.CheckPermissionResponse_PERMISSIONSHIP_NO_PERMISSION
+	for i, expected := range []struct {
+		live, hypothetical v1.CheckPermissionResponse_Permissionship
+		changed            bool
+	}{
+		{has, no, true},
+		{no, has, true},
+		{has, no, true},
+		{v1.CheckPermissionResponse_PERMISSIONSHIP_UNSPECIFIED, has, true},
+		{no, no, false},
+	} {
+		result := resp.Results[i]
+		req.Equal(expected.live, result.Live.Permissionship, "probe %d", i)
+		req.Equal(expected.hypothetical, result.Hypothetical.Permissionship, "probe %d", i)
+		req.Equal(expected.changed, result.Changed, "probe %d", i)
+	}
+
+	// The edit permission does not exist in the live world.
+	req.Equal(int32(codes.FailedPrecondition), resp.Results[3].Live.Error.Code)
+	req.Nil(resp.Results[3].Hypothetical.Error)
+
+	// The updates must be valid under the candidate schema.
+	_, err = client.WhatIf(context.Background(), &experimentalv1.WhatIfRequest{
+		Updates: []*v1.RelationshipUpdate{createUpdate("document:first#view@user:sarah")},
+		Probes:  []*experimentalv1.WhatIfProbe{probe("view", "sarah")},
+	})
+	grpcutil.RequireStatus(t, codes.InvalidArgument, err)
+
+	_, err = client.WhatIf(context.Background(), &experimentalv1.WhatIfRequest{
+		Schema: "definition document { relation viewer: unknown }",
+		Probes: []*experimentalv1.WhatIfProbe{probe("view", "sarah")},
+	})
+	grpcutil.RequireStatus(t, codes.InvalidArgument, err)
+
+	// Nothing is written.
+	checkResp, err := v1.NewPermissionsServiceClient(conn).CheckPermission(context.Background(), &v1.CheckPermissionRequest{
+		Consistency: &v1.Consistency{Requirement: &v1.Consistency_FullyConsistent{FullyConsistent: true}},
+		Resource:    &v1.ObjectReference{ObjectType: "document", ObjectId: "first"},
+		Permission:  "view",
+		Subject:     &v1.SubjectReference{Object: &v1.ObjectReference{ObjectType: "user", ObjectId: "tom"}},
+	})
+	req.NoError(err)
+	req.Equal(has, checkResp.Permissionship)
+}
@@ -0,0 +1,96 @@
+package v1
+
+import (
+	"context"
+
+	"golang.org/x/sync/errgroup"
+
+	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
+	"github.com/authzed/spicedb/internal/middleware/usagemetrics"
+	"github.com/authzed/spicedb/internal/namespace"
+	"github.com/authzed/spicedb/pkg/middleware/consistency"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	dispatchv1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+	experimentalv1 "github.com/authzed/spicedb/pkg/proto/experimental/v1"
+)
+
+// defaultCountLimit is the number of resources up to which resources are counted exactly when
+// the request does not specify a count limit.
+const defaultCountLimit = 1000
+
+// CountAccessibleResources looks up one more resource than the count limit, so that the lookup
+// is stopped as soon as the limit is known to be exceeded, and counts the resources found.
+func (es *experimentalServer) CountAccessibleResources(ctx context.Context, req *experimentalv1.CountAccessibleResourcesRequest) (*experimentalv1.CountAccessibleResourcesResponse, error) {
+	atRevision, countedAt := consistency.MustRevisionFromContext(ctx)
+	ds := datastoremw.MustFromContext(ctx).SnapshotReader(atRevision)
+
+	// Perform our preflight checks in parallel
+	errG, checksCtx := errgroup.WithContext(ctx)
+	errG.Go(func() error {
+		return namespace.CheckNamespaceAndRelation(
+			checksCtx,
+			req.ResourceObjectType,
+			req.Permission,
+			false,
+			ds,
+		)
+	})
+	errG.Go(func() error {
+		return namespace.CheckNamespaceAndRelation(
+			checksCtx,
+			req.Subject.Object.ObjectType,
+			normalizeSubjectRelation(req.Subject),
+			true,
+			ds,
+		)
+	})
+	if err := errG.Wait(); err != nil {
+		return nil, rewriteError(ctx, err)
+	}
+
+	resourceIDPrefix, err := resourceIDPrefixFromRequest(ctx)
+	if err != nil {
+		return nil, rewriteError(ctx, err)
+	}
+
+	countLimit := defaultIfZero(req.CountLimit, defaultCountLimit)
+	lookupResp, err := es.dispatch.DispatchLookup(ctx, &dispatchv1.DispatchLookupRequest{
+		Metadata: &dispatchv1.ResolverMeta{
+			AtRevision:     atRevision.String(),
+			DepthRemaining: es.maximumAPIDepth,
+		},
+		ObjectRelation: &core.RelationReference{
+			Namespace: req.ResourceObjectType,
+			Relation:  req.Permission,
+		},
+		Subject: &core.ObjectAndRelation{
+			Namespace: req.Subject.Object.ObjectType,
+			ObjectId:  req.Subject.Object.ObjectId,
+			Relation:  normalizeSubjectRelation(req.Subject),
+		},
+		Context:                  req.Context,
+		Limit:                    countLimit + 1,
+		OptionalResourceIdPrefix: resourceIDPrefix,
+	})
+	usagemetrics.SetInContext(ctx, lookupResp.GetMetadata())
+	if err != nil {
+		return nil, rewriteError(ctx, err)
+	}
+
+	resources := lookupResp.ResolvedResources
+	resp := &experimentalv1.CountAccessibleResourcesResponse{
+		CountedAt: countedAt,
+		Exact:     len(resources) <= int(countLimit),
+	}
+	if !resp.Exact {
+		resources = resources[:countLimit]
+	}
+
+	resp.Count = uint64(len(resources))
+	for _, resource := range resources {
+		if resource.Permissionship == dispatchv1.ResolvedResource_CONDITIONALLY_HAS_PERMISSION {
+			resp.ConditionalCount++
+		}
+	}
+	return resp, nil
+}
@@ -2,20 +2,42 @@ package v1
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"sort"
 	"strings"
 
+	"github.com/authzed/authzed-go/pkg/responsemeta"
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"google.golang.org/protobuf/encoding/protojson"
 
 	cexpr "github.com/authzed/spicedb/internal/caveats"
 	"github.com/authzed/spicedb/pkg/datastore"
 	dispatch "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+	"github.com/authzed/spicedb/pkg/redaction"
 	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
 	"github.com/authzed/spicedb/pkg/schemadsl/generator"
 	"github.com/authzed/spicedb/pkg/tuple"
 )
 
+// CaveatEvaluationsTrailer is the key in the response trailer metadata holding the evaluations of
+// caveat expressions performed by a CheckPermission call for which debug information is
+// requested, as a JSON array of dispatch.v1.CaveatEvaluation.
+const CaveatEvaluationsTrailer responsemeta.ResponseMetadataTrailerKey = "io.spicedb.respmeta.caveatevaluations"
+
+// MarshalCaveatEvaluations marshals the caveat evaluations into a JSON array.
+func MarshalCaveatEvaluations(evaluations []*dispatch.CaveatEvaluation) ([]byte, error) {
+	marshaled := make([]json.RawMessage, 0, len(evaluations))
+	for _, evaluation := range evaluations {
+		encoded, err := protojson.Marshal(evaluation)
+		if err != nil {
+			return nil, err
+		}
+		marshaled = append(marshaled, encoded)
+	}
+	return json.Marshal(marshaled)
+}
+
 // ConvertCheckDispatchDebugInformation converts dispatch debug information found in the response metadata
 // into DebugInformation returnable to the API.
 func ConvertCheckDispatchDebugInformation(
@@ -25,7 +47,7 @@ func ConvertCheckDispatchDebugInformation(
 	reader datastore.Reader,
 ) (*v1.DebugInformation, error) {
 	debugInfo := metadata.DebugInfo
-	if debugInfo == nil {
+	if debugInfo == nil || debugInfo.Check == nil {
 		return nil, nil
 	}
 
@@ -128,7 +150,7 @@ func convertCheckTrace(ctx context.Context, caveatContext map[string]any, ct *di
 		caveatEvalInfo = &v1.CaveatEvalInfo{
 			Expression:        exprString,
 			Result:            caveatResult,
-			Context:           contextStruct,
+			Context:           redaction.CaveatContext(contextStruct),
 			PartialCaveatInfo: partialCaveatInfo,
 			CaveatName:        caveatName,
 		}
@@ -150,14 +172,14 @@ func convertCheckTrace(ctx context.Context, caveatContext map[string]any, ct *di
 		return &v1.CheckDebugTrace{
 			Resource: &v1.ObjectReference{
 				ObjectType: ct.Request.ResourceRelation.Namespace,
-				ObjectId:   strings.Join(ct.Request.ResourceIds, ","),
+				ObjectId:   strings.Join(redaction.ObjectIDs(ct.Request.ResourceIds), ","),
 			},
 			Permission:     ct.Request.ResourceRelation.Relation,
 			PermissionType: permissionType,
 			Subject: &v1.SubjectReference{
 				Object: &v1.ObjectReference{
 					ObjectType: ct.Request.Subject.Namespace,
-					ObjectId:   ct.Request.Subject.ObjectId,
+					ObjectId:   redaction.ObjectID(ct.Request.Subject.ObjectId),
 				},
 				OptionalRelation: subRelation,
 			},
@@ -174,14 +196,14 @@ func convertCheckTrace(ctx context.Context, caveatContext map[string]any, ct *di
 	return &v1.CheckDebugTrace{
 		Resource: &v1.ObjectReference{
 			ObjectType: ct.Request.ResourceRelation.Namespace,
-			ObjectId:   strings.Join(ct.Request.ResourceIds, ","),
+			ObjectId:   strings.Join(redaction.ObjectIDs(ct.Request.ResourceIds), ","),
 		},
 		Permission:     ct.Request.ResourceRelation.Relation,
 		PermissionType: permissionType,
 		Subject: &v1.SubjectReference{
 			Object: &v1.ObjectReference{
 				ObjectType: ct.Request.Subject.Namespace,
-				ObjectId:   ct.Request.Subject.ObjectId,
+				ObjectId:   redaction.ObjectID(ct.Request.Subject.ObjectId),
 			},
 			OptionalRelation: subRelation,
 		},
@@ -2,6 +2,7 @@ package v1_test
 
 import (
 	"context"
+	"encoding/json"
 	"sort"
 	"strings"
 	"testing"
@@ -17,10 +18,12 @@ import (
 	"google.golang.org/protobuf/types/known/structpb"
 
 	"github.com/authzed/spicedb/internal/datastore/memdb"
+	v1svc "github.com/authzed/spicedb/internal/services/v1"
 	tf "github.com/authzed/spicedb/internal/testfixtures"
 	"github.com/authzed/spicedb/internal/testserver"
 	"github.com/authzed/spicedb/pkg/datastore"
 	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	dispatchv1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
 	"github.com/authzed/spicedb/pkg/tuple"
 	"github.com/authzed/spicedb/pkg/util"
 	"github.com/authzed/spicedb/pkg/zedtoken"
@@ -475,3 +478,72 @@ func TestCheckPermissionWithDebug(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckPermissionCaveatEvaluationsTrailer(t *testing.T) {
+	req := require.New(t)
+	conn, cleanup, _, revision := testserver.NewTestServer(req, testTimedeltas[0], memdb.DisableGC, true,
+		func(ds datastore.Datastore, require *require.Assertions) (datastore.Datastore, datastore.Revision) {
+			return tf.DatastoreFromSchemaAndTestRelationships(ds, `
+				definition user {}
+
+				caveat somecaveat(somecondition int) {
+					somecondition == 42
+				}
+
+				definition document {
+					relation viewer: user with somecaveat
+					permission view = viewer
+				}
+			`, []*core.RelationTuple{
+				tuple.MustWithCaveat(tuple.MustParse("document:first#viewer@user:tom"), "somecaveat"),
+			}, require)
+		})
+
+	client := v1.NewPermissionsServiceClient(conn)
+	t.Cleanup(cleanup)
+
+	caveatContext, err := structpb.NewStruct(map[string]any{"somecondition": 41})
+	req.NoError(err)
+
+	check := func(ctx context.Context) metadata.MD {
+		var trailer metadata.MD
+		checkResp, err := client.CheckPermission(ctx, &v1.CheckPermissionRequest{
+			Consistency: &v1.Consistency{
+				Requirement: &v1.Consistency_AtLeastAsFresh{
+					AtLeastAsFresh: zedtoken.MustNewFromRevision(revision),
+				},
+			},
+			Resource:   obj("document", "first"),
+			Permission: "view",
+			Subject:    sub("user", "tom", ""),
+			Context:    caveatContext,
+		}, grpc.Trailer(&trailer))
+		req.NoError(err)
+		req.Equal(v1.CheckPermissionResponse_PERMISSIONSHIP_NO_PERMISSION, checkResp.Permissionship)
+		return trailer
+	}
+
+	// Evaluations are only returned when debug information is requested.
+	encoded, err := responsemeta.GetResponseTrailerMetadataOrNil(check(context.Background()), v1svc.CaveatEvaluationsTrailer)
+	req.NoError(err)
+	req.Nil(encoded)
+
+	encoded, err = responsemeta.GetResponseTrailerMetadataOrNil(
+		check(requestmeta.AddRequestHeaders(context.Background(), requestmeta.RequestDebugInformation)),
+		v1svc.CaveatEvaluationsTrailer,
+	)
+	req.NoError(err)
+	req.NotNil(encoded)
+
+	var evaluations []json.RawMessage
+	req.NoError(json.Unmarshal([]byte(*encoded), &evaluations))
+	req.Len(evaluations, 1)
+
+	evaluation := &dispatchv1.CaveatEvaluation{}
+	req.NoError(protojson.Unmarshal(evaluations[0], evaluation))
+	req.Equal("first", evaluation.ResourceId)
+	req.Equal("somecaveat", evaluation.CaveatName)
+	req.Equal(dispatchv1.CaveatEvaluation_FALSE, evaluation.Result)
+	req.Equal(float64(41), evaluation.Context.Fields["somecondition"].GetNumberValue())
+	req.NotNil(evaluation.Duration)
+}
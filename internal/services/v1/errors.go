@@ -5,13 +5,16 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/rs/zerolog"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 
+	"github.com/authzed/spicedb/internal/dispatch"
 	"github.com/authzed/spicedb/internal/graph"
 	log "github.com/authzed/spicedb/internal/logging"
 	"github.com/authzed/spicedb/internal/namespace"
@@ -154,38 +157,99 @@ func (err ErrPreconditionFailed) GRPCStatus() *status.Status {
 	)
 }
 
+// DuplicateRelationshipUpdates are the indexes of the updates in a request which were found to
+// refer to the same relationship.
+type DuplicateRelationshipUpdates struct {
+	// Relationship is the relationship, without its caveat, updated more than once.
+	Relationship *v1.Relationship
+
+	// Indexes are the indexes of the updates referring to the relationship, in order.
+	Indexes []int
+
+	// Conflicting is true if the updates specify different operations, such as creating and
+	// deleting the relationship, rather than simply repeating the same operation.
+	Conflicting bool
+}
+
 // ErrDuplicateRelationshipError indicates that an update was attempted on the same relationship.
 type ErrDuplicateRelationshipError struct {
 	error
-	update *v1.RelationshipUpdate
+	duplicates []DuplicateRelationshipUpdates
 }
 
-// NewDuplicateRelationshipErr constructs a new invalid subject error.
-func NewDuplicateRelationshipErr(update *v1.RelationshipUpdate) ErrDuplicateRelationshipError {
+// NewDuplicateRelationshipErr constructs a new error for relationships updated more than once in
+// the same request. At least one set of duplicates must be given.
+func NewDuplicateRelationshipErr(duplicates []DuplicateRelationshipUpdates) ErrDuplicateRelationshipError {
+	first := duplicates[0]
+	kind := "duplicate"
+	if first.Conflicting {
+		kind = "conflicting"
+	}
+
+	message := fmt.Sprintf(
+		"found more than one update with relationship `%s` in this request (%s updates at indexes %s); a relationship can only be specified in an update once per overall WriteRelationships request",
+		tuple.StringRelationshipWithoutCaveat(first.Relationship),
+		kind,
+		joinIndexes(first.Indexes),
+	)
+	if len(duplicates) > 1 {
+		message = fmt.Sprintf("%s; %d other relationship(s) were also updated more than once", message, len(duplicates)-1)
+	}
+
 	return ErrDuplicateRelationshipError{
-		error: fmt.Errorf(
-			"found more than one update with relationship `%s` in this request; a relationship can only be specified in an update once per overall WriteRelationships request",
-			tuple.StringRelationshipWithoutCaveat(update.Relationship),
-		),
-		update: update,
+		error:      errors.New(message),
+		duplicates: duplicates,
 	}
 }
 
+// Duplicates returns the sets of updates referring to the same relationship.
+func (err ErrDuplicateRelationshipError) Duplicates() []DuplicateRelationshipUpdates {
+	return err.duplicates
+}
+
 // GRPCStatus implements retrieving the gRPC status for the error.
 func (err ErrDuplicateRelationshipError) GRPCStatus() *status.Status {
+	first := err.duplicates[0]
+
+	var violations []*errdetails.BadRequest_FieldViolation
+	for _, duplicate := range err.duplicates {
+		relString := tuple.StringRelationshipWithoutCaveat(duplicate.Relationship)
+		for _, index := range duplicate.Indexes[1:] {
+			description := fmt.Sprintf("duplicates update #%d for relationship `%s`", duplicate.Indexes[0], relString)
+			if duplicate.Conflicting {
+				description = fmt.Sprintf("conflicts with update #%d for relationship `%s`", duplicate.Indexes[0], relString)
+			}
+
+			violations = append(violations, &errdetails.BadRequest_FieldViolation{
+				Field:       fmt.Sprintf("updates[%d]", index),
+				Description: description,
+			})
+		}
+	}
+
 	return spiceerrors.WithCodeAndDetails(
 		err,
 		codes.InvalidArgument,
 		spiceerrors.ForReason(
-			v1.ErrorReason_ERROR_REASON_INVALID_SUBJECT_TYPE,
+			v1.ErrorReason_ERROR_REASON_UPDATES_ON_SAME_RELATIONSHIP,
 			map[string]string{
-				"definition_name": err.update.Relationship.Resource.ObjectType,
-				"relationship":    tuple.MustRelString(err.update.Relationship),
+				"definition_name": first.Relationship.Resource.ObjectType,
+				"relationship":    tuple.StringRelationshipWithoutCaveat(first.Relationship),
+				"update_indexes":  joinIndexes(first.Indexes),
 			},
 		),
+		&errdetails.BadRequest{FieldViolations: violations},
 	)
 }
 
+func joinIndexes(indexes []int) string {
+	strs := make([]string, 0, len(indexes))
+	for _, index := range indexes {
+		strs = append(strs, strconv.Itoa(index))
+	}
+	return strings.Join(strs, ", ")
+}
+
 func rewriteError(ctx context.Context, err error) error {
 	// Check if the error can be directly used.
 	if _, ok := status.FromError(err); ok {
@@ -199,6 +263,8 @@ func rewriteError(ctx context.Context, err error) error {
 	var compilerError compiler.BaseCompilerError
 	var sourceError spiceerrors.ErrorWithSource
 	var typeError namespace.TypeError
+	var invalidRevisionErr datastore.ErrInvalidRevision
+	var integrityErr datastore.ErrRelationshipIntegrity
 
 	switch {
 	case errors.As(err, &typeError):
@@ -215,30 +281,35 @@ func rewriteError(ctx context.Context, err error) error {
 
 	case errors.As(err, &datastore.ErrReadOnly{}):
 		return shared.ErrServiceReadOnly
-	case errors.As(err, &datastore.ErrInvalidRevision{}):
-		return status.Errorf(codes.OutOfRange, "invalid zedtoken: %s", err)
-	case errors.As(err, &datastore.ErrReadOnly{}):
-		return shared.ErrServiceReadOnly
+	case errors.As(err, &invalidRevisionErr):
+		return shared.RewriteInvalidRevisionErr(invalidRevisionErr, "invalid zedtoken")
 	case errors.As(err, &datastore.ErrCaveatNameNotFound{}):
 		return spiceerrors.WithCodeAndReason(err, codes.FailedPrecondition, v1.ErrorReason_ERROR_REASON_UNKNOWN_CAVEAT)
 	case errors.As(err, &datastore.ErrWatchDisabled{}):
 		return status.Errorf(codes.FailedPrecondition, "%s", err)
+	case errors.As(err, &datastore.ErrSchemaVersionExists{}):
+		return status.Errorf(codes.Aborted, "schema was written concurrently: %s", err)
+	case errors.As(err, &integrityErr):
+		log.Ctx(ctx).Error().Object("error", integrityErr).Msg("relationship failed integrity verification")
+		return status.Errorf(codes.DataLoss, "%s", err)
 
 	case errors.As(err, &graph.ErrInvalidArgument{}):
 		return status.Errorf(codes.InvalidArgument, "%s", err)
 	case errors.As(err, &graph.ErrRequestCanceled{}):
-		return status.Errorf(codes.Canceled, "request canceled: %s", err)
+		return spiceerrors.WithCodeAndReasonName(fmt.Errorf("request canceled: %w", err), codes.Canceled, spiceerrors.ReasonRequestCanceled)
 	case errors.As(err, &graph.ErrRelationMissingTypeInfo{}):
 		return status.Errorf(codes.FailedPrecondition, "failed precondition: %s", err)
 	case errors.As(err, &graph.ErrAlwaysFail{}):
 		log.Ctx(ctx).Err(err).Msg("received internal error")
 		return status.Errorf(codes.Internal, "internal error: %s", err)
+	case errors.Is(err, dispatch.ErrMaxDepth):
+		return spiceerrors.WithCodeAndReasonName(err, codes.ResourceExhausted, spiceerrors.ReasonMaximumDepthExceeded)
 	case errors.As(err, &graph.ErrUnimplemented{}):
 		return status.Errorf(codes.Unimplemented, "%s", err)
 	case errors.Is(err, context.DeadlineExceeded):
-		return status.Errorf(codes.DeadlineExceeded, "%s", err)
+		return spiceerrors.WithCodeAndReasonName(err, codes.DeadlineExceeded, spiceerrors.ReasonDeadlineExceeded)
 	case errors.Is(err, context.Canceled):
-		return status.Errorf(codes.Canceled, "%s", err)
+		return spiceerrors.WithCodeAndReasonName(err, codes.Canceled, spiceerrors.ReasonRequestCanceled)
 	default:
 		log.Ctx(ctx).Err(err).Msg("received unexpected error")
 		return err
@@ -2,11 +2,20 @@ package v1
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/authzed/grpcutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/authzed/spicedb/internal/dispatch"
+	"github.com/authzed/spicedb/pkg/datastore"
+	"github.com/authzed/spicedb/pkg/datastore/revision"
+	"github.com/authzed/spicedb/pkg/spiceerrors"
 )
 
 func TestRewriteCanceledError(t *testing.T) {
@@ -14,6 +23,7 @@ func TestRewriteCanceledError(t *testing.T) {
 	cancelFunc()
 	errorRewritten := rewriteError(ctx, ctx.Err())
 	grpcutil.RequireStatus(t, codes.Canceled, errorRewritten)
+	requireReasonName(t, spiceerrors.ReasonRequestCanceled, errorRewritten)
 }
 
 func TestRewriteDeadlineExceededError(t *testing.T) {
@@ -21,4 +31,63 @@ func TestRewriteDeadlineExceededError(t *testing.T) {
 	defer cancelFunc()
 	errorRewritten := rewriteError(ctx, ctx.Err())
 	grpcutil.RequireStatus(t, codes.DeadlineExceeded, errorRewritten)
+	requireReasonName(t, spiceerrors.ReasonDeadlineExceeded, errorRewritten)
+}
+
+func TestRewriteErrorReasons(t *testing.T) {
+	testCases := []struct {
+		name           string
+		err            error
+		expectedCode   codes.Code
+		expectedReason string
+	}{
+		{
+			"max depth",
+			fmt.Errorf("error dispatching request: %w", dispatch.ErrMaxDepth),
+			codes.ResourceExhausted,
+			spiceerrors.ReasonMaximumDepthExceeded,
+		},
+		{
+			"stale revision",
+			datastore.NewInvalidRevisionErr(revision.NoRevision, datastore.RevisionStale),
+			codes.OutOfRange,
+			spiceerrors.ReasonRevisionStale,
+		},
+		{
+			"unknown revision",
+			datastore.NewInvalidRevisionErr(revision.NoRevision, datastore.CouldNotDetermineRevision),
+			codes.OutOfRange,
+			spiceerrors.ReasonInvalidRevision,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			errorRewritten := rewriteError(context.Background(), tc.err)
+			grpcutil.RequireStatus(t, tc.expectedCode, errorRewritten)
+			requireReasonName(t, tc.expectedReason, errorRewritten)
+		})
+	}
+}
+
+func requireReasonName(t *testing.T, reason string, err error) {
+	withStatus, ok := status.FromError(err)
+	require.True(t, ok)
+
+	var info *errdetails.ErrorInfo
+	var debug *errdetails.DebugInfo
+	for _, detail := range withStatus.Details() {
+		switch detail := detail.(type) {
+		case *errdetails.ErrorInfo:
+			info = detail
+		case *errdetails.DebugInfo:
+			debug = detail
+		}
+	}
+	require.NotNil(t, info)
+	require.Equal(t, reason, info.GetReason())
+	require.Equal(t, spiceerrors.Domain, info.GetDomain())
+	require.NotNil(t, debug)
+	require.NotEmpty(t, debug.GetStackEntries())
 }
@@ -0,0 +1,447 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	grpcvalidate "github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/validator"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/authzed/spicedb/internal/archive"
+	cexpr "github.com/authzed/spicedb/internal/caveats"
+	dscommon "github.com/authzed/spicedb/internal/datastore/common"
+	"github.com/authzed/spicedb/internal/dispatch"
+	"github.com/authzed/spicedb/internal/graph/computed"
+	"github.com/authzed/spicedb/internal/jobs"
+	"github.com/authzed/spicedb/internal/materialize"
+	"github.com/authzed/spicedb/internal/middleware"
+	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
+	"github.com/authzed/spicedb/internal/middleware/usagemetrics"
+	"github.com/authzed/spicedb/internal/namespace"
+	"github.com/authzed/spicedb/internal/services/shared"
+	"github.com/authzed/spicedb/internal/templates"
+	"github.com/authzed/spicedb/pkg/datastore"
+	"github.com/authzed/spicedb/pkg/middleware/consistency"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	dispatchv1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+	experimentalv1 "github.com/authzed/spicedb/pkg/proto/experimental/v1"
+	"github.com/authzed/spicedb/pkg/secrets"
+	"github.com/authzed/spicedb/pkg/tuple"
+	"github.com/authzed/spicedb/pkg/util"
+)
+
+// NewExperimentalServer creates an ExperimentalServiceServer instance, configured as the
+// permissions service.
+func NewExperimentalServer(dispatch dispatch.Dispatcher, config PermissionsServerConfig) experimentalv1.ExperimentalServiceServer {
+	return &experimentalServer{
+		dispatch:             dispatch,
+		maximumAPIDepth:      defaultIfZero(config.MaximumAPIDepth, 50),
+		permissions:          NewPermissionsServer(dispatch, config),
+		restoreWindow:        config.RelationshipRestoreWindow,
+		restoreTokenKeys:     config.RelationshipRestoreTokenKeys,
+		deletedRelationships: config.DeletedRelationships,
+		materializer:         config.Materializer,
+		archive:              config.Archive,
+		jobs:                 config.Jobs,
+
+		maximumResultSize:      config.MaximumResultSize,
+		schemaRollbackDisabled: config.SchemaRollbackDisabled,
+		additiveOnlySchema:     config.AdditiveOnlySchema,
+		pointInTimeCheck:       config.PointInTimeCheckEnabled,
+		WithServiceSpecificInterceptors: shared.WithServiceSpecificInterceptors{
+			Unary: middleware.ChainUnaryServer(
+				grpcvalidate.UnaryServerInterceptor(true),
+				usagemetrics.UnaryServerInterceptor(),
+			),
+			Stream: middleware.ChainStreamServer(
+				grpcvalidate.StreamServerInterceptor(true),
+				usagemetrics.StreamServerInterceptor(),
+			),
+		},
+	}
+}
+
+type experimentalServer struct {
+	experimentalv1.UnimplementedExperimentalServiceServer
+	shared.WithServiceSpecificInterceptors
+
+	dispatch             dispatch.Dispatcher
+	maximumAPIDepth      uint32
+	permissions          v1.PermissionsServiceServer
+	restoreWindow        time.Duration
+	restoreTokenKeys     secrets.KeyManager
+	deletedRelationships dscommon.DeletedRelationshipStore
+	materializer         *materialize.Materializer
+	archive              *archive.Archive
+	jobs                 *jobs.Manager
+
+	maximumResultSize      uint64
+	schemaRollbackDisabled bool
+	additiveOnlySchema     bool
+	pointInTimeCheck       bool
+}
+
+// CheckTemplate expands the named template of the process-wide registry into a check of a
+// permission, and performs it.
+func (es *experimentalServer) CheckTemplate(ctx context.Context, req *experimentalv1.CheckTemplateRequest) (*v1.CheckPermissionResponse, error) {
+	registry := templates.Current()
+	if registry == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "no templates are configured")
+	}
+
+	template, ok := registry.Lookup(req.Name)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown template `%s`", req.Name)
+	}
+
+	checkReq, err := template.Expand(req.Parameters)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid parameters for template `%s`: %s", req.Name, err)
+	}
+	checkReq.Consistency = req.Consistency
+	checkReq.Context = req.Context
+	if err := checkReq.Validate(); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid parameters for template `%s`: %s", req.Name, err)
+	}
+
+	return es.permissions.CheckPermission(ctx, checkReq)
+}
+
+// CheckPermissionForSubjects looks up the subjects of the permission on the resource once for
+// each of the distinct subject types requested, and then finds each of the requested subjects
+// amongst those found.
+func (es *experimentalServer) CheckPermissionForSubjects(ctx context.Context, req *experimentalv1.CheckPermissionForSubjectsRequest) (*experimentalv1.CheckPermissionForSubjectsResponse, error) {
+	atRevision, checkedAt := consistency.MustRevisionFromContext(ctx)
+	ds := datastoremw.MustFromContext(ctx).SnapshotReader(atRevision)
+
+	caveatContext, err := getCaveatContext(ctx, req.Context)
+	if err != nil {
+		return nil, rewriteError(ctx, err)
+	}
+
+	subjectTypes := make(map[string]*core.RelationReference)
+	for _, subject := range req.Subjects {
+		subjectType := &core.RelationReference{
+			Namespace: subject.Object.ObjectType,
+			Relation:  normalizeSubjectRelation(subject),
+		}
+		subjectTypes[tuple.StringRR(subjectType)] = subjectType
+	}
+
+	// Perform our preflight checks in parallel
+	errG, checksCtx := errgroup.WithContext(ctx)
+	errG.Go(func() error {
+		return namespace.CheckNamespaceAndRelation(
+			checksCtx,
+			req.Resource.ObjectType,
+			req.Permission,
+			false,
+			ds,
+		)
+	})
+	for _, subjectType := range subjectTypes {
+		subjectType := subjectType
+		errG.Go(func() error {
+			return namespace.CheckNamespaceAndRelation(
+				checksCtx,
+				subjectType.Namespace,
+				subjectType.Relation,
+				true,
+				ds,
+			)
+		})
+	}
+	if err := errG.Wait(); err != nil {
+		return nil, rewriteError(ctx, err)
+	}
+
+	respMetadata := &dispatchv1.ResponseMeta{}
+	usagemetrics.SetInContext(ctx, respMetadata)
+
+	var lock sync.Mutex
+	foundByType := make(map[string]*foundSubjectsIndex, len(subjectTypes))
+
+	errG, lookupCtx := errgroup.WithContext(ctx)
+	for key, subjectType := range subjectTypes {
+		key := key
+		subjectType := subjectType
+		index := newFoundSubjectsIndex()
+		foundByType[key] = index
+
+		errG.Go(func() error {
+			stream := dispatch.NewHandlingDispatchStream(lookupCtx, func(result *dispatchv1.DispatchLookupSubjectsResponse) error {
+				foundSubjects, ok := result.FoundSubjectsByResourceId[req.Resource.ObjectId]
+				if !ok {
+					return fmt.Errorf("missing resource ID in returned LS")
+				}
+
+				lock.Lock()
+				defer lock.Unlock()
+				index.add(foundSubjects.FoundSubjects)
+				dispatch.AddResponseMetadata(respMetadata, result.Metadata)
+				return nil
+			})
+
+			return es.dispatch.DispatchLookupSubjects(
+				&dispatchv1.DispatchLookupSubjectsRequest{
+					Metadata: &dispatchv1.ResolverMeta{
+						AtRevision:     atRevision.String(),
+						DepthRemaining: es.maximumAPIDepth,
+					},
+					ResourceRelation: &core.RelationReference{
+						Namespace: req.Resource.ObjectType,
+						Relation:  req.Permission,
+					},
+					ResourceIds:     []string{req.Resource.ObjectId},
+					SubjectRelation: subjectType,
+				},
+				stream)
+		})
+	}
+	if err := errG.Wait(); err != nil {
+		return nil, rewriteError(ctx, err)
+	}
+
+	results := make([]*experimentalv1.CheckPermissionForSubjectsResult, 0, len(req.Subjects))
+	for _, subject := range req.Subjects {
+		key := tuple.StringRR(&core.RelationReference{
+			Namespace: subject.Object.ObjectType,
+			Relation:  normalizeSubjectRelation(subject),
+		})
+
+		result, err := foundByType[key].check(ctx, subject, caveatContext, ds)
+		if err != nil {
+			return nil, rewriteError(ctx, err)
+		}
+		results = append(results, result)
+	}
+
+	return &experimentalv1.CheckPermissionForSubjectsResponse{
+		CheckedAt: checkedAt,
+		Results:   results,
+	}, nil
+}
+
+// CheckPermissionForResources checks the permission of the subject on each of the distinct
+// resources requested, dispatching the checks in batches of resources.
+func (es *experimentalServer) CheckPermissionForResources(ctx context.Context, req *experimentalv1.CheckPermissionForResourcesRequest) (*experimentalv1.CheckPermissionForResourcesResponse, error) {
+	atRevision, checkedAt := consistency.MustRevisionFromContext(ctx)
+	ds := datastoremw.MustFromContext(ctx).SnapshotReader(atRevision)
+
+	caveatContext, err := getCaveatContext(ctx, req.Context)
+	if err != nil {
+		return nil, rewriteError(ctx, err)
+	}
+
+	// Perform our preflight checks in parallel
+	errG, checksCtx := errgroup.WithContext(ctx)
+	errG.Go(func() error {
+		return namespace.CheckNamespaceAndRelation(
+			checksCtx,
+			req.ResourceObjectType,
+			req.Permission,
+			false,
+			ds,
+		)
+	})
+	errG.Go(func() error {
+		return namespace.CheckNamespaceAndRelation(
+			checksCtx,
+			req.Subject.Object.ObjectType,
+			normalizeSubjectRelation(req.Subject),
+			true,
+			ds,
+		)
+	})
+	if err := errG.Wait(); err != nil {
+		return nil, rewriteError(ctx, err)
+	}
+
+	distinctIDs := util.NewSet[string]()
+	distinctIDs.Extend(req.ResourceObjectIds)
+	resourceIDs := distinctIDs.AsSlice()
+
+	respMetadata := &dispatchv1.ResponseMeta{}
+	usagemetrics.SetInContext(ctx, respMetadata)
+
+	var lock sync.Mutex
+	results := make(map[string]*dispatchv1.ResourceCheckResult, len(resourceIDs))
+
+	errG, checkCtx := errgroup.WithContext(ctx)
+	for _, batch := range chunkResourceIDs(resourceIDs, int(datastore.FilterMaximumIDCount)) {
+		batch := batch
+		errG.Go(func() error {
+			batchResults, batchMetadata, err := computed.ComputeBulkCheck(checkCtx, es.dispatch,
+				computed.CheckParameters{
+					ResourceType: &core.RelationReference{
+						Namespace: req.ResourceObjectType,
+						Relation:  req.Permission,
+					},
+					Subject: &core.ObjectAndRelation{
+						Namespace: req.Subject.Object.ObjectType,
+						ObjectId:  req.Subject.Object.ObjectId,
+						Relation:  normalizeSubjectRelation(req.Subject),
+					},
+					CaveatContext: caveatContext,
+					AtRevision:    atRevision,
+					MaximumDepth:  es.maximumAPIDepth,
+					DebugOption:   computed.NoDebugging,
+				},
+				batch,
+			)
+
+			lock.Lock()
+			defer lock.Unlock()
+			if batchMetadata != nil {
+				dispatch.AddResponseMetadata(respMetadata, batchMetadata)
+			}
+			if err != nil {
+				return err
+			}
+
+			for resourceID, result := range batchResults {
+				results[resourceID] = result
+			}
+			return nil
+		})
+	}
+	if err := errG.Wait(); err != nil {
+		return nil, rewriteError(ctx, err)
+	}
+
+	resp := &experimentalv1.CheckPermissionForResourcesResponse{
+		CheckedAt:       checkedAt,
+		Permissionships: make([]v1.CheckPermissionResponse_Permissionship, 0, len(req.ResourceObjectIds)),
+	}
+	for index, resourceID := range req.ResourceObjectIds {
+		permissionship := v1.CheckPermissionResponse_PERMISSIONSHIP_NO_PERMISSION
+		switch result := results[resourceID]; result.GetMembership() {
+		case dispatchv1.ResourceCheckResult_MEMBER:
+			permissionship = v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION
+
+		case dispatchv1.ResourceCheckResult_CAVEATED_MEMBER:
+			permissionship = v1.CheckPermissionResponse_PERMISSIONSHIP_CONDITIONAL_PERMISSION
+			if resp.PartialCaveatInfo == nil {
+				resp.PartialCaveatInfo = make(map[uint32]*v1.PartialCaveatInfo)
+			}
+			resp.PartialCaveatInfo[uint32(index)] = &v1.PartialCaveatInfo{
+				MissingRequiredContext: result.MissingExprFields,
+			}
+		}
+		resp.Permissionships = append(resp.Permissionships, permissionship)
+	}
+	return resp, nil
+}
+
+// chunkResourceIDs splits the resource IDs into batches of at most the given size.
+func chunkResourceIDs(resourceIDs []string, size int) [][]string {
+	batches := make([][]string, 0, (len(resourceIDs)+size-1)/size)
+	for len(resourceIDs) > size {
+		batches = append(batches, resourceIDs[:size])
+		resourceIDs = resourceIDs[size:]
+	}
+	return append(batches, resourceIDs)
+}
+
+// foundSubjectsIndex indexes the subjects of a single type found by a lookup of subjects.
+type foundSubjectsIndex struct {
+	byID      map[string][]*dispatchv1.FoundSubject
+	wildcards []*dispatchv1.FoundSubject
+}
+
+func newFoundSubjectsIndex() *foundSubjectsIndex {
+	return &foundSubjectsIndex{byID: make(map[string][]*dispatchv1.FoundSubject)}
+}
+
+func (fsi *foundSubjectsIndex) add(foundSubjects []*dispatchv1.FoundSubject) {
+	for _, foundSubject := range foundSubjects {
+		if foundSubject.SubjectId == tuple.PublicWildcard {
+			fsi.wildcards = append(fsi.wildcards, foundSubject)
+		}
+		fsi.byID[foundSubject.SubjectId] = append(fsi.byID[foundSubject.SubjectId], foundSubject)
+	}
+}
+
+// membership returns whether the subject with the given ID was found, and if so, the caveat
+// expression under which it was found, which is nil if it was found unconditionally.
+func (fsi *foundSubjectsIndex) membership(subjectID string) (bool, *core.CaveatExpression) {
+	found := false
+	var caveats []*core.CaveatExpression
+
+	for _, foundSubject := range fsi.byID[subjectID] {
+		if foundSubject.CaveatExpression == nil {
+			return true, nil
+		}
+		found = true
+		caveats = append(caveats, foundSubject.CaveatExpression)
+	}
+
+	if subjectID != tuple.PublicWildcard {
+	wildcards:
+		for _, wildcard := range fsi.wildcards {
+			caveat := wildcard.CaveatExpression
+			for _, excluded := range wildcard.ExcludedSubjects {
+				if excluded.SubjectId != subjectID {
+					continue
+				}
+
+				if excluded.CaveatExpression == nil {
+					continue wildcards
+				}
+				caveat = cexpr.Subtract(caveat, excluded.CaveatExpression)
+			}
+
+			if caveat == nil {
+				return true, nil
+			}
+			found = true
+			caveats = append(caveats, caveat)
+		}
+	}
+
+	if !found {
+		return false, nil
+	}
+
+	var caveat *core.CaveatExpression
+	for _, c := range caveats {
+		caveat = cexpr.Or(caveat, c)
+	}
+	return true, caveat
+}
+
+func (fsi *foundSubjectsIndex) check(ctx context.Context, subject *v1.SubjectReference, caveatContext map[string]any, ds datastore.CaveatReader) (*experimentalv1.CheckPermissionForSubjectsResult, error) {
+	result := &experimentalv1.CheckPermissionForSubjectsResult{
+		Subject:        subject,
+		Permissionship: v1.CheckPermissionResponse_PERMISSIONSHIP_NO_PERMISSION,
+	}
+
+	found, caveat := fsi.membership(subject.Object.ObjectId)
+	if !found {
+		return result, nil
+	}
+
+	resolved, err := foundSubjectToResolvedSubject(ctx, &dispatchv1.FoundSubject{
+		SubjectId:        subject.Object.ObjectId,
+		CaveatExpression: caveat,
+	}, caveatContext, ds)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case resolved == nil:
+		// The caveat evaluated to false.
+	case resolved.Permissionship == v1.LookupPermissionship_LOOKUP_PERMISSIONSHIP_HAS_PERMISSION:
+		result.Permissionship = v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION
+	default:
+		result.Permissionship = v1.CheckPermissionResponse_PERMISSIONSHIP_CONDITIONAL_PERMISSION
+		result.PartialCaveatInfo = resolved.PartialCaveatInfo
+	}
+	return result, nil
+}
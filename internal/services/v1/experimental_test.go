@@ -0,0 +1,660 @@
+package v1_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/authzed/grpcutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/internal/templates"
+	tf "github.com/authzed/spicedb/internal/testfixtures"
+	"github.com/authzed/spicedb/internal/testserver"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	experimentalv1 "github.com/authzed/spicedb/pkg/proto/experimental/v1"
+	"github.com/authzed/spicedb/pkg/testutil"
+	"github.com/authzed/spicedb/pkg/tuple"
+	"github.com/authzed/spicedb/pkg/zedtoken"
+)
+
+func TestCheckPermissionForSubjects(t *testing.T) {
+	req := require.New(t)
+	conn, cleanup, _, revision := testserver.NewTestServer(req, testTimedeltas[0], memdb.DisableGC, true,
+		func(ds datastore.Datastore, require *require.Assertions) (datastore.Datastore, datastore.Revision) {
+			return tf.DatastoreFromSchemaAndTestRelationships(ds, `
+				definition user {}
+
+				caveat is_weekday(day string) {
+					day != "saturday" && day != "sunday"
+				}
+
+				definition group {
+					relation member: user | user with is_weekday
+				}
+
+				definition document {
+					relation viewer: user | user:* | group#member
+					relation banned: user | user with is_weekday
+					permission view = viewer - banned
+				}
+			`, []*core.RelationTuple{
+				tuple.MustParse("document:first#viewer@user:tom"),
+				tuple.MustParse("document:first#viewer@group:eng#member"),
+				tuple.MustParse("group:eng#member@user:sarah"),
+				tuple.MustWithCaveat(tuple.MustParse("group:eng#member@user:fred"), "is_weekday"),
+				tuple.MustParse("document:first#banned@user:tom"),
+				tuple.MustParse("document:public#viewer@user:*"),
+				tuple.MustParse("document:public#banned@user:tom"),
+				tuple.MustWithCaveat(tuple.MustParse("document:public#banned@user:sarah"), "is_weekday"),
+			}, require)
+		})
+	t.Cleanup(cleanup)
+
+	client := experimentalv1.NewExperimentalServiceClient(conn)
+	permissionsClient := v1.NewPermissionsServiceClient(conn)
+
+	subjects := []*v1.SubjectReference{
+		sub("user", "tom", ""),
+		sub("user", "sarah", ""),
+		sub("user", "fred", ""),
+		sub("user", "unknown", ""),
+		sub("group", "eng", "member"),
+	}
+
+	for _, resourceID := range []string{"first", "public"} {
+		for _, caveatContext := range []map[string]any{nil, {"day": "monday"}, {"day": "sunday"}} {
+			resourceID := resourceID
+			caveatContext := caveatContext
+			t.Run(resourceID, func(t *testing.T) {
+				require := require.New(t)
+
+				var requestContext *structpb.Struct
+				if caveatContext != nil {
+					var err error
+					requestContext, err = structpb.NewStruct(caveatContext)
+					require.NoError(err)
+				}
+
+				resp, err := client.CheckPermissionForSubjects(context.Background(), &experimentalv1.CheckPermissionForSubjectsRequest{
+					Consistency: &v1.Consistency{
+						Requirement: &v1.Consistency_AtLeastAsFresh{
+							AtLeastAsFresh: zedtoken.MustNewFromRevision(revision),
+						},
+					},
+					Resource:   obj("document", resourceID),
+					Permission: "view",
+					Subjects:   subjects,
+					Context:    requestContext,
+				})
+				require.NoError(err)
+				require.NotNil(resp.CheckedAt)
+				require.Len(resp.Results, len(subjects))
+
+				// Each result must match that of checking the subject individually.
+				for i, subject := range subjects {
+					result := resp.Results[i]
+					require.Equal(subject.Object.ObjectId, result.Subject.Object.ObjectId)
+
+					expected, err := permissionsClient.CheckPermission(context.Background(), &v1.CheckPermissionRequest{
+						Consistency: &v1.Consistency{
+							Requirement: &v1.Consistency_AtLeastAsFresh{
+								AtLeastAsFresh: zedtoken.MustNewFromRevision(revision),
+							},
+						},
+						Resource:   obj("document", resourceID),
+						Permission: "view",
+						Subject:    subject,
+						Context:    requestContext,
+					})
+					require.NoError(err)
+					require.Equal(expected.Permissionship, result.Permissionship, "for subject %s", subject.Object.ObjectId)
+					require.Equal(expected.PartialCaveatInfo.GetMissingRequiredContext(), result.PartialCaveatInfo.GetMissingRequiredContext())
+				}
+			})
+		}
+	}
+}
+
+func TestCheckPermissionForSubjectsErrors(t *testing.T) {
+	req := require.New(t)
+	conn, cleanup, _, _ := testserver.NewTestServer(req, testTimedeltas[0], memdb.DisableGC, true, tf.StandardDatastoreWithData)
+	t.Cleanup(cleanup)
+
+	client := experimentalv1.NewExperimentalServiceClient(conn)
+
+	for _, tc := range []struct {
+		name       string
+		permission string
+		subjects   []*v1.SubjectReference
+		expected   codes.Code
+	}{
+		{"no subjects", "view", nil, codes.InvalidArgument},
+		{"unknown permission", "unknown", []*v1.SubjectReference{sub("user", "eng_lead", "")}, codes.FailedPrecondition},
+		{"unknown subject type", "view", []*v1.SubjectReference{sub("user", "eng_lead", ""), sub("unknown", "foo", "")}, codes.FailedPrecondition},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := client.CheckPermissionForSubjects(context.Background(), &experimentalv1.CheckPermissionForSubjectsRequest{
+				Resource:   obj("document", "masterplan"),
+				Permission: tc.permission,
+				Subjects:   tc.subjects,
+			})
+			grpcutil.RequireStatus(t, tc.expected, err)
+		})
+	}
+}
+
+func TestCheckPermissionForResources(t *testing.T) {
+	req := require.New(t)
+	conn, cleanup, _, revision := testserver.NewTestServer(req, testTimedeltas[0], memdb.DisableGC, true,
+		func(ds datastore.Datastore, require *require.Assertions) (datastore.Datastore, datastore.Revision) {
+			return tf.DatastoreFromSchemaAndTestRelationships(ds, `
+				definition user {}
+
+				caveat is_weekday(day string) {
+					day != "saturday" && day != "sunday"
+				}
+
+				definition group {
+					relation member: user | user with is_weekday
+				}
+
+				definition document {
+					relation viewer: user | user:* | group#member
+					relation banned: user | user with is_weekday
+					permission view = viewer - banned
+				}
+			`, []*core.RelationTuple{
+				tuple.MustParse("document:first#viewer@user:tom"),
+				tuple.MustParse("document:first#viewer@group:eng#member"),
+				tuple.MustParse("group:eng#member@user:sarah"),
+				tuple.MustWithCaveat(tuple.MustParse("group:eng#member@user:fred"), "is_weekday"),
+				tuple.MustParse("document:first#banned@user:tom"),
+				tuple.MustParse("document:public#viewer@user:*"),
+				tuple.MustParse("document:public#banned@user:tom"),
+				tuple.MustWithCaveat(tuple.MustParse("document:public#banned@user:sarah"), "is_weekday"),
+				tuple.MustParse("document:doc150#viewer@user:tom"),
+			}, require)
+		})
+	t.Cleanup(cleanup)
+
+	client := experimentalv1.NewExperimentalServiceClient(conn)
+	permissionsClient := v1.NewPermissionsServiceClient(conn)
+
+	// The resources span several batches, and repeat.
+	resourceIDs := []string{"first", "public", "unknown", "first"}
+	for i := 0; i < 250; i++ {
+		resourceIDs = append(resourceIDs, fmt.Sprintf("doc%d", i))
+	}
+
+	consistency := &v1.Consistency{
+		Requirement: &v1.Consistency_AtLeastAsFresh{
+			AtLeastAsFresh: zedtoken.MustNewFromRevision(revision),
+		},
+	}
+
+	for _, subject := range []*v1.SubjectReference{sub("user", "tom", ""), sub("user", "sarah", ""), sub("user", "fred", "")} {
+		for _, caveatContext := range []map[string]any{nil, {"day": "monday"}, {"day": "sunday"}} {
+			subject := subject
+			caveatContext := caveatContext
+			t.Run(subject.Object.ObjectId, func(t *testing.T) {
+				require := require.New(t)
+
+				var requestContext *structpb.Struct
+				if caveatContext != nil {
+					var err error
+					requestContext, err = structpb.NewStruct(caveatContext)
+					require.NoError(err)
+				}
+
+				resp, err := client.CheckPermissionForResources(context.Background(), &experimentalv1.CheckPermissionForResourcesRequest{
+					Consistency:        consistency,
+					ResourceObjectType: "document",
+					ResourceObjectIds:  resourceIDs,
+					Permission:         "view",
+					Subject:            subject,
+					Context:            requestContext,
+				})
+				require.NoError(err)
+				require.NotNil(resp.CheckedAt)
+				require.Len(resp.Permissionships, len(resourceIDs))
+
+				// Each result must match that of checking the resource individually.
+				for i, resourceID := range resourceIDs {
+					expected, err := permissionsClient.CheckPermission(context.Background(), &v1.CheckPermissionRequest{
+						Consistency: consistency,
+						Resource:    obj("document", resourceID),
+						Permission:  "view",
+						Subject:     subject,
+						Context:     requestContext,
+					})
+					require.NoError(err)
+					require.Equal(expected.Permissionship, resp.Permissionships[i], "for resource %s", resourceID)
+					require.Equal(expected.PartialCaveatInfo.GetMissingRequiredContext(), resp.PartialCaveatInfo[uint32(i)].GetMissingRequiredContext())
+				}
+			})
+		}
+	}
+}
+
+func TestCheckPermissionForResourcesErrors(t *testing.T) {
+	req := require.New(t)
+	conn, cleanup, _, _ := testserver.NewTestServer(req, testTimedeltas[0], memdb.DisableGC, true, tf.StandardDatastoreWithData)
+	t.Cleanup(cleanup)
+
+	client := experimentalv1.NewExperimentalServiceClient(conn)
+
+	for _, tc := range []struct {
+		name        string
+		permission  string
+		resourceIDs []string
+		subject     *v1.SubjectReference
+		expected    codes.Code
+	}{
+		{"no resources", "view", nil, sub("user", "eng_lead", ""), codes.InvalidArgument},
+		{"wildcard resource", "view", []string{"*"}, sub("user", "eng_lead", ""), codes.InvalidArgument},
+		{"unknown permission", "unknown", []string{"masterplan"}, sub("user", "eng_lead", ""), codes.FailedPrecondition},
+		{"unknown subject type", "view", []string{"masterplan"}, sub("unknown", "foo", ""), codes.FailedPrecondition},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := client.CheckPermissionForResources(context.Background(), &experimentalv1.CheckPermissionForResourcesRequest{
+				ResourceObjectType: "document",
+				ResourceObjectIds:  tc.resourceIDs,
+				Permission:         tc.permission,
+				Subject:            tc.subject,
+			})
+			grpcutil.RequireStatus(t, tc.expected, err)
+		})
+	}
+}
+
+func TestCheckTemplate(t *testing.T) {
+	req := require.New(t)
+	conn, cleanup, _, revision := testserver.NewTestServer(req, testTimedeltas[0], memdb.DisableGC, true, tf.StandardDatastoreWithData)
+	t.Cleanup(cleanup)
+
+	client := experimentalv1.NewExperimentalServiceClient(conn)
+	permissionsClient := v1.NewPermissionsServiceClient(conn)
+
+	consistency := &v1.Consistency{
+		Requirement: &v1.Consistency_AtLeastAsFresh{
+			AtLeastAsFresh: zedtoken.MustNewFromRevision(revision),
+		},
+	}
+
+	_, err := client.CheckTemplate(context.Background(), &experimentalv1.CheckTemplateRequest{Name: "can-view"})
+	grpcutil.RequireStatus(t, codes.FailedPrecondition, err)
+
+	registry, err := templates.Parse([]byte(`
+templates:
+  - name: can-view
+    resource: document:$doc
+    permission: view
+    subject: user:$user
+`))
+	req.NoError(err)
+	templates.Set(registry)
+	t.Cleanup(func() { templates.Set(nil) })
+
+	for _, docID := range []string{"masterplan", "healthplan"} {
+		resp, err := client.CheckTemplate(context.Background(), &experimentalv1.CheckTemplateRequest{
+			Consistency: consistency,
+			Name:        "can-view",
+			Parameters:  map[string]string{"doc": docID, "user": "eng_lead"},
+		})
+		req.NoError(err)
+
+		expected, err := permissionsClient.CheckPermission(context.Background(), &v1.CheckPermissionRequest{
+			Consistency: consistency,
+			Resource:    obj("document", docID),
+			Permission:  "view",
+			Subject:     sub("user", "eng_lead", ""),
+		})
+		req.NoError(err)
+		req.Equal(expected.Permissionship, resp.Permissionship)
+	}
+
+	for _, tc := range []struct {
+		name       string
+		template   string
+		parameters map[string]string
+		expected   codes.Code
+	}{
+		{"unknown template", "unknown", nil, codes.NotFound},
+		{"missing parameter", "can-view", map[string]string{"doc": "masterplan"}, codes.InvalidArgument},
+		{"unknown parameter", "can-view", map[string]string{"doc": "masterplan", "user": "eng_lead", "other": "foo"}, codes.InvalidArgument},
+		{"invalid parameter", "can-view", map[string]string{"doc": "masterplan", "user": "eng_lead#owner"}, codes.InvalidArgument},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := client.CheckTemplate(context.Background(), &experimentalv1.CheckTemplateRequest{
+				Consistency: consistency,
+				Name:        tc.template,
+				Parameters:  tc.parameters,
+			})
+			grpcutil.RequireStatus(t, tc.expected, err)
+		})
+	}
+}
+
+func TestStreamExpandPermissionTree(t *testing.T) {
+	req := require.New(t)
+	conn, cleanup, _, revision := testserver.NewTestServer(req, testTimedeltas[0], memdb.DisableGC, true,
+		func(ds datastore.Datastore, require *require.Assertions) (datastore.Datastore, datastore.Revision) {
+			return tf.DatastoreFromSchemaAndTestRelationships(ds, `
+				definition user {}
+
+				definition group {
+					relation member: user | group#member
+				}
+
+				definition document {
+					relation viewer: user | group#member
+					permission view = viewer
+				}
+			`, []*core.RelationTuple{
+				tuple.MustParse("document:first#viewer@user:tom"),
+				tuple.MustParse("document:first#viewer@group:eng#member"),
+				tuple.MustParse("group:eng#member@user:fred"),
+				tuple.MustParse("group:eng#member@group:backend#member"),
+				tuple.MustParse("group:backend#member@user:sarah"),
+				tuple.MustParse("group:backend#member@group:eng#member"),
+			}, require)
+		})
+	t.Cleanup(cleanup)
+
+	client := experimentalv1.NewExperimentalServiceClient(conn)
+	stream, err := client.StreamExpandPermissionTree(context.Background(), &experimentalv1.StreamExpandPermissionTreeRequest{
+		Consistency: &v1.Consistency{
+			Requirement: &v1.Consistency_AtLeastAsFresh{
+				AtLeastAsFresh: zedtoken.MustNewFromRevision(revision),
+			},
+		},
+		Resource:   &v1.ObjectReference{ObjectType: "document", ObjectId: "first"},
+		Permission: "view",
+	})
+	req.NoError(err)
+
+	type fragment struct {
+		id       uint32
+		parentID uint32
+		expanded string
+	}
+
+	var fragments []fragment
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		req.NoError(err)
+		req.NotNil(resp.ExpandedAt)
+
+		fragments = append(fragments, fragment{
+			id:       resp.FragmentId,
+			parentID: resp.ParentFragmentId,
+			expanded: resp.Tree.ExpandedObject.ObjectType + ":" + resp.Tree.ExpandedObject.ObjectId + "#" + resp.Tree.ExpandedRelation,
+		})
+	}
+
+	// The cycle between the groups ends once each has been expanded.
+	req.Equal([]fragment{
+		{1, 0, "document:first#view"},
+		{2, 1, "group:eng#member"},
+		{3, 2, "group:backend#member"},
+	}, fragments)
+}
+
+func TestStreamExpandPermissionTreeErrors(t *testing.T) {
+	req := require.New(t)
+	conn, cleanup, _, revision := testserver.NewTestServer(req, testTimedeltas[0], memdb.DisableGC, true, tf.StandardDatastoreWithData)
+	t.Cleanup(cleanup)
+
+	client := experimentalv1.NewExperimentalServiceClient(conn)
+	stream, err := client.StreamExpandPermissionTree(context.Background(), &experimentalv1.StreamExpandPermissionTreeRequest{
+		Consistency: &v1.Consistency{
+			Requirement: &v1.Consistency_AtLeastAsFresh{
+				AtLeastAsFresh: zedtoken.MustNewFromRevision(revision),
+			},
+		},
+		Resource:   &v1.ObjectReference{ObjectType: "document", ObjectId: "masterplan"},
+		Permission: "unknown",
+	})
+	req.NoError(err)
+
+	_, err = stream.Recv()
+	grpcutil.RequireStatus(t, codes.FailedPrecondition, err)
+}
+
+func TestReflectPermissionSubjectTypes(t *testing.T) {
+	testCases := []struct {
+		name       string
+		permission string
+		expected   []*experimentalv1.PermissionSubjectType
+	}{
+		{
+			"permission",
+			"view",
+			[]*experimentalv1.PermissionSubjectType{
+				{SubjectObjectType: "folder", OptionalSubjectRelation: "viewer", Concrete: true, Caveats: []string{}},
+				{SubjectObjectType: "user", Concrete: true, Caveats: []string{}},
+			},
+		},
+		{
+			"caveated relation",
+			"caveated_viewer",
+			[]*experimentalv1.PermissionSubjectType{
+				{SubjectObjectType: "user", Concrete: true, Caveats: []string{"test"}, RequiresCaveat: true},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := require.New(t)
+			conn, cleanup, _, revision := testserver.NewTestServer(req, testTimedeltas[0], memdb.DisableGC, true, tf.StandardDatastoreWithData)
+			t.Cleanup(cleanup)
+
+			client := experimentalv1.NewExperimentalServiceClient(conn)
+			resp, err := client.ReflectPermissionSubjectTypes(context.Background(), &experimentalv1.ReflectPermissionSubjectTypesRequest{
+				Consistency: &v1.Consistency{
+					Requirement: &v1.Consistency_AtLeastAsFresh{
+						AtLeastAsFresh: zedtoken.MustNewFromRevision(revision),
+					},
+				},
+				ResourceObjectType: "document",
+				Permission:         tc.permission,
+			})
+			req.NoError(err)
+			req.NotNil(resp.ReadAt)
+
+			req.Len(resp.SubjectTypes, len(tc.expected))
+			for index, expected := range tc.expected {
+				testutil.RequireProtoEqual(t, expected, resp.SubjectTypes[index], "mismatch in subject type %d", index)
+			}
+		})
+	}
+}
+
+func TestReflectPermissionSubjectTypesErrors(t *testing.T) {
+	req := require.New(t)
+	conn, cleanup, _, _ := testserver.NewTestServer(req, testTimedeltas[0], memdb.DisableGC, true, tf.StandardDatastoreWithData)
+	t.Cleanup(cleanup)
+
+	client := experimentalv1.NewExperimentalServiceClient(conn)
+	for _, resourceType := range []string{"document", "unknown"} {
+		_, err := client.ReflectPermissionSubjectTypes(context.Background(), &experimentalv1.ReflectPermissionSubjectTypesRequest{
+			ResourceObjectType: resourceType,
+			Permission:         "unknown",
+		})
+		grpcutil.RequireStatus(t, codes.FailedPrecondition, err)
+	}
+}
+
+func TestReflectSchema(t *testing.T) {
+	req := require.New(t)
+	conn, cleanup, _, _ := testserver.NewTestServer(req, testTimedeltas[0], memdb.DisableGC, true, tf.EmptyDatastore)
+	t.Cleanup(cleanup)
+
+	_, err := v1.NewSchemaServiceClient(conn).WriteSchema(context.Background(), &v1.WriteSchemaRequest{
+		Schema: `
+			// is_tuesday is true on tuesdays.
+			caveat is_tuesday(day_of_week string) {
+				day_of_week == 'tuesday'
+			}
+
+			definition user {}
+
+			/**
+			 * document is a document.
+			 *
+			 * Documents are owned by users.
+			 */
+			definition document {
+				// owner is the owner of the document.
+				relation owner: user | user:* with is_tuesday
+
+				relation parent: document#owner
+
+				// view is the permission to view the document.
+				permission view = owner + parent
+			}`,
+	})
+	req.NoError(err)
+
+	resp, err := experimentalv1.NewExperimentalServiceClient(conn).ReflectSchema(context.Background(), &experimentalv1.ReflectSchemaRequest{
+		Consistency: &v1.Consistency{Requirement: &v1.Consistency_FullyConsistent{FullyConsistent: true}},
+	})
+	req.NoError(err)
+	req.NotNil(resp.ReadAt)
+
+	testutil.RequireProtoEqual(t, &experimentalv1.ReflectSchemaResponse{
+		ReadAt: resp.ReadAt,
+		Definitions: []*experimentalv1.ReflectionDefinition{
+			{
+				Name:    "document",
+				Comment: "document is a document.\n\nDocuments are owned by users.",
+				Relations: []*experimentalv1.ReflectionRelation{
+					{
+						Name:    "owner",
+						Comment: "owner is the owner of the document.",
+						SubjectTypes: []*experimentalv1.ReflectionSubjectType{
+							{SubjectObjectType: "user"},
+							{SubjectObjectType: "user", Wildcard: true, OptionalCaveatName: "is_tuesday"},
+						},
+					},
+					{
+						Name: "parent",
+						SubjectTypes: []*experimentalv1.ReflectionSubjectType{
+							{SubjectObjectType: "document", OptionalSubjectRelation: "owner"},
+						},
+					},
+				},
+				Permissions: []*experimentalv1.ReflectionPermission{
+					{Name: "view", Comment: "view is the permission to view the document."},
+				},
+			},
+			{Name: "user"},
+		},
+		Caveats: []*experimentalv1.ReflectionCaveat{
+			{
+				Name:       "is_tuesday",
+				Comment:    "is_tuesday is true on tuesdays.",
+				Parameters: []*experimentalv1.ReflectionCaveatParameter{{Name: "day_of_week", Type: "string"}},
+			},
+		},
+	}, resp, "mismatch in reflected schema")
+}
+
+func TestFormatSchema(t *testing.T) {
+	req := require.New(t)
+	conn, cleanup, _, _ := testserver.NewTestServer(req, testTimedeltas[0], memdb.DisableGC, true, tf.EmptyDatastore)
+	t.Cleanup(cleanup)
+
+	client := experimentalv1.NewExperimentalServiceClient(conn)
+	resp, err := client.FormatSchema(context.Background(), &experimentalv1.FormatSchemaRequest{
+		Schema:          "definition user {}   definition document {\n relation viewer: user\n}",
+		SortDefinitions: true,
+	})
+	req.NoError(err)
+	req.Equal("definition document {\n\trelation viewer: user\n}\n\ndefinition user {}", resp.FormattedSchema)
+
+	_, err = client.FormatSchema(context.Background(), &experimentalv1.FormatSchemaRequest{Schema: "definition user {"})
+	grpcutil.RequireStatus(t, codes.InvalidArgument, err)
+
+	// The schema is not written.
+	_, err = v1.NewSchemaServiceClient(conn).ReadSchema(context.Background(), &v1.ReadSchemaRequest{})
+	grpcutil.RequireStatus(t, codes.NotFound, err)
+}
+
+func TestCountAccessibleResources(t *testing.T) {
+	testCases := []struct {
+		name          string
+		subject       *v1.SubjectReference
+		countLimit    uint32
+		expectedCount uint64
+		expectedExact bool
+	}{
+		{"no resources", sub("user", "villain", ""), 0, 0, true},
+		{"under the limit", sub("user", "chief_financial_officer", ""), 0, 2, true},
+		{"at the limit", sub("user", "chief_financial_officer", ""), 2, 2, true},
+		{"over the limit", sub("user", "chief_financial_officer", ""), 1, 1, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := require.New(t)
+			conn, cleanup, _, revision := testserver.NewTestServer(req, testTimedeltas[0], memdb.DisableGC, true, tf.StandardDatastoreWithData)
+			t.Cleanup(cleanup)
+
+			client := experimentalv1.NewExperimentalServiceClient(conn)
+			resp, err := client.CountAccessibleResources(context.Background(), &experimentalv1.CountAccessibleResourcesRequest{
+				Consistency: &v1.Consistency{
+					Requirement: &v1.Consistency_AtLeastAsFresh{
+						AtLeastAsFresh: zedtoken.MustNewFromRevision(revision),
+					},
+				},
+				ResourceObjectType: "document",
+				Permission:         "view",
+				Subject:            tc.subject,
+				CountLimit:         tc.countLimit,
+			})
+			req.NoError(err)
+			req.NotNil(resp.CountedAt)
+			req.Equal(tc.expectedCount, resp.Count)
+			req.Equal(tc.expectedExact, resp.Exact)
+			req.Zero(resp.ConditionalCount)
+		})
+	}
+}
+
+func TestCountAccessibleResourcesErrors(t *testing.T) {
+	req := require.New(t)
+	conn, cleanup, _, _ := testserver.NewTestServer(req, testTimedeltas[0], memdb.DisableGC, true, tf.StandardDatastoreWithData)
+	t.Cleanup(cleanup)
+
+	client := experimentalv1.NewExperimentalServiceClient(conn)
+	_, err := client.CountAccessibleResources(context.Background(), &experimentalv1.CountAccessibleResourcesRequest{
+		ResourceObjectType: "document",
+		Permission:         "unknown",
+		Subject:            sub("user", "villain", ""),
+	})
+	grpcutil.RequireStatus(t, codes.FailedPrecondition, err)
+
+	_, err = client.CountAccessibleResources(context.Background(), &experimentalv1.CountAccessibleResourcesRequest{
+		ResourceObjectType: "document",
+		Permission:         "view",
+		Subject:            sub("user", "villain", ""),
+		CountLimit:         10001,
+	})
+	grpcutil.RequireStatus(t, codes.InvalidArgument, err)
+}
@@ -0,0 +1,320 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/authzed/spicedb/internal/archive"
+	dscommon "github.com/authzed/spicedb/internal/datastore/common"
+	"github.com/authzed/spicedb/internal/dispatch/graph"
+	"github.com/authzed/spicedb/internal/graph/computed"
+	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
+	"github.com/authzed/spicedb/internal/middleware/usagemetrics"
+	"github.com/authzed/spicedb/internal/namespace"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	dispatchv1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+	experimentalv1 "github.com/authzed/spicedb/pkg/proto/experimental/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+	"github.com/authzed/spicedb/pkg/zedtoken"
+)
+
+// historicalRevision returns the revision of the point in the history of the relationships,
+// having checked that it has not been garbage collected.
+func historicalRevision(ctx context.Context, ds datastore.Datastore, point *experimentalv1.HistoricalPoint) (datastore.Revision, error) {
+	var revision datastore.Revision
+	switch point := point.Point.(type) {
+	case *experimentalv1.HistoricalPoint_AtRevision:
+		decoded, err := zedtoken.DecodeRevision(point.AtRevision, ds)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid revision: %s", err)
+		}
+		revision = decoded
+
+	case *experimentalv1.HistoricalPoint_AtTime:
+		at := point.AtTime.AsTime()
+		if at.After(time.Now()) {
+			return nil, status.Errorf(codes.InvalidArgument, "time %s is in the future", at.Format(time.RFC3339Nano))
+		}
+
+		finder, ok := datastore.Unwrap(ds).(dscommon.RevisionAtTimeDatastore)
+		if !ok {
+			return nil, status.Errorf(codes.Unimplemented, "the datastore does not support reading relationships at a time")
+		}
+
+		found, err := finder.RevisionAtTime(ctx, at)
+		if err != nil {
+			return nil, err
+		}
+		revision = found
+
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown historical point: %T", point)
+	}
+
+	if err := ds.CheckRevision(ctx, revision); err != nil {
+		return nil, err
+	}
+	return revision, nil
+}
+
+// ReadRelationshipsAt reads the relationships matching the filter at the revision of the point
+// requested.
+func (es *experimentalServer) ReadRelationshipsAt(req *experimentalv1.ReadRelationshipsAtRequest, resp experimentalv1.ExperimentalService_ReadRelationshipsAtServer) error {
+	ctx := resp.Context()
+	ds := datastoremw.MustFromContext(ctx)
+
+	revision, err := historicalRevision(ctx, ds, req.At)
+	if err != nil {
+		return rewriteError(ctx, err)
+	}
+
+	reader := ds.SnapshotReader(revision)
+	if err := es.permissions.(*permissionServer).checkFilterNamespaces(ctx, req.RelationshipFilter, reader); err != nil {
+		return rewriteError(ctx, err)
+	}
+
+	usagemetrics.SetInContext(ctx, &dispatchv1.ResponseMeta{
+		DispatchCount: 1,
+	})
+
+	iter, err := reader.QueryRelationships(ctx, datastore.RelationshipsFilterFromPublicFilter(req.RelationshipFilter))
+	if err != nil {
+		return rewriteError(ctx, err)
+	}
+	defer iter.Close()
+
+	readAt := zedtoken.MustNewFromRevision(revision)
+	for {
+		tpl, err := iter.Next()
+		if err != nil {
+			return status.Errorf(codes.Internal, "error when reading tuples: %s", err)
+		}
+		if tpl == nil {
+			break
+		}
+
+		if err := resp.Send(&experimentalv1.ReadRelationshipsAtResponse{
+			ReadAt:       readAt,
+			Relationship: tuple.ToRelationship(tpl),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadRelationshipChanges compares the relationships matching the filter at the revisions of the
+// points requested, and returns those deleted, followed by those written, ordered by
+// relationship.
+func (es *experimentalServer) ReadRelationshipChanges(req *experimentalv1.ReadRelationshipChangesRequest, resp experimentalv1.ExperimentalService_ReadRelationshipChangesServer) error {
+	ctx := resp.Context()
+	ds := datastoremw.MustFromContext(ctx)
+
+	fromRevision, err := historicalRevision(ctx, ds, req.From)
+	if err != nil {
+		return rewriteError(ctx, err)
+	}
+
+	toRevision, err := historicalRevision(ctx, ds, req.To)
+	if err != nil {
+		return rewriteError(ctx, err)
+	}
+
+	if toRevision.LessThan(fromRevision) {
+		return status.Errorf(codes.InvalidArgument, "the revision to read changes to precedes that to read changes from")
+	}
+
+	// The filter need only be valid at one of the revisions, as its namespace may have been
+	// created or deleted in between.
+	permissions := es.permissions.(*permissionServer)
+	if err := permissions.checkFilterNamespaces(ctx, req.RelationshipFilter, ds.SnapshotReader(toRevision)); err != nil {
+		if err := permissions.checkFilterNamespaces(ctx, req.RelationshipFilter, ds.SnapshotReader(fromRevision)); err != nil {
+			return rewriteError(ctx, err)
+		}
+	}
+
+	usagemetrics.SetInContext(ctx, &dispatchv1.ResponseMeta{
+		DispatchCount: 2,
+	})
+
+	filter := datastore.RelationshipsFilterFromPublicFilter(req.RelationshipFilter)
+	before, err := readRelationshipsByKey(ctx, ds.SnapshotReader(fromRevision), filter)
+	if err != nil {
+		return rewriteError(ctx, err)
+	}
+
+	after, err := readRelationshipsByKey(ctx, ds.SnapshotReader(toRevision), filter)
+	if err != nil {
+		return rewriteError(ctx, err)
+	}
+
+	var deleted, written []*core.RelationTuple
+	for key, tpl := range before {
+		if _, ok := after[key]; !ok {
+			deleted = append(deleted, tpl)
+		}
+	}
+	for key, tpl := range after {
+		if existing, ok := before[key]; !ok || !proto.Equal(existing.Caveat, tpl.Caveat) {
+			written = append(written, tpl)
+		}
+	}
+
+	fromToken, toToken := zedtoken.MustNewFromRevision(fromRevision), zedtoken.MustNewFromRevision(toRevision)
+	for _, change := range []struct {
+		operation v1.RelationshipUpdate_Operation
+		tuples    []*core.RelationTuple
+	}{
+		{v1.RelationshipUpdate_OPERATION_DELETE, deleted},
+		{v1.RelationshipUpdate_OPERATION_TOUCH, written},
+	} {
+		sort.Slice(change.tuples, func(i, j int) bool {
+			return tuple.StringWithoutCaveat(change.tuples[i]) < tuple.StringWithoutCaveat(change.tuples[j])
+		})
+
+		for _, tpl := range change.tuples {
+			if err := resp.Send(&experimentalv1.ReadRelationshipChangesResponse{
+				FromRevision: fromToken,
+				ToRevision:   toToken,
+				Update: &v1.RelationshipUpdate{
+					Operation:    change.operation,
+					Relationship: tuple.ToRelationship(tpl),
+				},
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// archiveDispatchConcurrency is the concurrency of the dispatches of checks against the
+// archive, which are made locally, rather than across the cluster, as the relationships loaded
+// from the archive are held in the memory of the server.
+const archiveDispatchConcurrency = 10
+
+// CheckPermissionAtTime checks the permission at the revision current at the time, or if it
+// has been garbage collected, against the relationships loaded from the archive.
+func (es *experimentalServer) CheckPermissionAtTime(ctx context.Context, req *experimentalv1.CheckPermissionAtTimeRequest) (*experimentalv1.CheckPermissionAtTimeResponse, error) {
+	if !es.pointInTimeCheck {
+		return nil, status.Errorf(codes.FailedPrecondition, "point-in-time checks are not enabled")
+	}
+
+	resp := &experimentalv1.CheckPermissionAtTimeResponse{}
+	dispatcher := es.dispatch
+	ds := datastoremw.MustFromContext(ctx)
+	revision, err := historicalRevision(ctx, ds, &experimentalv1.HistoricalPoint{
+		Point: &experimentalv1.HistoricalPoint_AtTime{AtTime: req.At},
+	})
+
+	var invalidRevision datastore.ErrInvalidRevision
+	switch {
+	case err == nil:
+		resp.CheckedAt = zedtoken.MustNewFromRevision(revision)
+
+	case es.archive != nil && errors.As(err, &invalidRevision) && invalidRevision.Reason() == datastore.RevisionStale:
+		state, err := es.archive.LoadAt(ctx, req.At.AsTime())
+		if errors.Is(err, archive.ErrNotArchived) {
+			return nil, status.Errorf(codes.OutOfRange, "time %s precedes both the garbage collection window and the archive", req.At.AsTime().Format(time.RFC3339Nano))
+		}
+		if err != nil {
+			return nil, rewriteError(ctx, err)
+		}
+
+		// The archived relationships are checked locally, without the caches of the dispatcher,
+		// whose entries are keyed by revisions of the datastore.
+		local := graph.NewLocalOnlyDispatcher(archiveDispatchConcurrency)
+		defer local.Close()
+
+		dispatcher = local
+		ds = state.Datastore
+		revision = state.Revision
+		ctx = datastoremw.ContextWithDatastore(ctx, ds)
+		resp.FromArchive = true
+		resp.ArchivedAt = timestamppb.New(state.ArchivedAt)
+
+	default:
+		return nil, rewriteError(ctx, err)
+	}
+
+	reader := ds.SnapshotReader(revision)
+	if err := namespace.CheckNamespaceAndRelation(ctx, req.Resource.ObjectType, req.Permission, false, reader); err != nil {
+		return nil, rewriteError(ctx, err)
+	}
+	if err := namespace.CheckNamespaceAndRelation(ctx, req.Subject.Object.ObjectType, normalizeSubjectRelation(req.Subject), true, reader); err != nil {
+		return nil, rewriteError(ctx, err)
+	}
+
+	caveatContext, err := getCaveatContext(ctx, req.Context)
+	if err != nil {
+		return nil, rewriteError(ctx, err)
+	}
+
+	cr, metadata, err := computed.ComputeCheck(ctx, dispatcher,
+		computed.CheckParameters{
+			ResourceType: &core.RelationReference{
+				Namespace: req.Resource.ObjectType,
+				Relation:  req.Permission,
+			},
+			Subject: &core.ObjectAndRelation{
+				Namespace: req.Subject.Object.ObjectType,
+				ObjectId:  req.Subject.Object.ObjectId,
+				Relation:  normalizeSubjectRelation(req.Subject),
+			},
+			CaveatContext: caveatContext,
+			AtRevision:    revision,
+			MaximumDepth:  es.maximumAPIDepth,
+			DebugOption:   computed.NoDebugging,
+		},
+		req.Resource.ObjectId,
+	)
+	usagemetrics.SetInContext(ctx, metadata)
+	if err != nil {
+		return nil, rewriteError(ctx, err)
+	}
+
+	resp.Permissionship = v1.CheckPermissionResponse_PERMISSIONSHIP_NO_PERMISSION
+	switch cr.Membership {
+	case dispatchv1.ResourceCheckResult_MEMBER:
+		resp.Permissionship = v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION
+
+	case dispatchv1.ResourceCheckResult_CAVEATED_MEMBER:
+		resp.Permissionship = v1.CheckPermissionResponse_PERMISSIONSHIP_CONDITIONAL_PERMISSION
+		resp.PartialCaveatInfo = &v1.PartialCaveatInfo{
+			MissingRequiredContext: cr.MissingExprFields,
+		}
+	}
+	return resp, nil
+}
+
+// readRelationshipsByKey reads the relationships matching the filter, keyed by their string
+// form without caveats.
+func readRelationshipsByKey(ctx context.Context, reader datastore.Reader, filter datastore.RelationshipsFilter) (map[string]*core.RelationTuple, error) {
+	iter, err := reader.QueryRelationships(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	found := make(map[string]*core.RelationTuple)
+	for {
+		tpl, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		if tpl == nil {
+			return found, nil
+		}
+
+		found[tuple.StringWithoutCaveat(tpl)] = tpl
+	}
+}
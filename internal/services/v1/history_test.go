@@ -0,0 +1,267 @@
+package v1_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/authzed/grpcutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/authzed/spicedb/internal/archive"
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	tf "github.com/authzed/spicedb/internal/testfixtures"
+	"github.com/authzed/spicedb/internal/testserver"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	experimentalv1 "github.com/authzed/spicedb/pkg/proto/experimental/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+	"github.com/authzed/spicedb/pkg/zedtoken"
+)
+
+func atRevision(token *v1.ZedToken) *experimentalv1.HistoricalPoint {
+	return &experimentalv1.HistoricalPoint{Point: &experimentalv1.HistoricalPoint_AtRevision{AtRevision: token}}
+}
+
+func atTime(at time.Time) *experimentalv1.HistoricalPoint {
+	return &experimentalv1.HistoricalPoint{Point: &experimentalv1.HistoricalPoint_AtTime{AtTime: timestamppb.New(at)}}
+}
+
+func readRelationshipsAt(client experimentalv1.ExperimentalServiceClient, at *experimentalv1.HistoricalPoint, filter *v1.RelationshipFilter) ([]string, error) {
+	stream, err := client.ReadRelationshipsAt(context.Background(), &experimentalv1.ReadRelationshipsAtRequest{
+		At:                 at,
+		RelationshipFilter: filter,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var relationships []string
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		relationships = append(relationships, tuple.MustRelString(resp.Relationship))
+	}
+	sort.Strings(relationships)
+	return relationships, nil
+}
+
+func readRelationshipChanges(client experimentalv1.ExperimentalServiceClient, from, to *experimentalv1.HistoricalPoint, filter *v1.RelationshipFilter) ([]string, error) {
+	stream, err := client.ReadRelationshipChanges(context.Background(), &experimentalv1.ReadRelationshipChangesRequest{
+		From:               from,
+		To:                 to,
+		RelationshipFilter: filter,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []string
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, resp.Update.Operation.String()+" "+tuple.MustRelString(resp.Update.Relationship))
+	}
+	return changes, nil
+}
+
+func TestRelationshipHistory(t *testing.T) {
+	require := require.New(t)
+	conn, cleanup, _, revision := testserver.NewTestServer(require, 0, memdb.DisableGC, true, tf.StandardDatastoreWithData)
+	t.Cleanup(cleanup)
+
+	client := v1.NewPermissionsServiceClient(conn)
+	experimentalClient := experimentalv1.NewExperimentalServiceClient(conn)
+	filter := &v1.RelationshipFilter{ResourceType: "document", OptionalResourceId: "masterplan"}
+
+	original, err := readRelationshipsAt(experimentalClient, atRevision(zedtoken.MustNewFromRevision(revision)), filter)
+	require.NoError(err)
+	require.NotEmpty(original)
+
+	before := time.Now()
+	deleted := tuple.MustParse(original[0])
+	written, err := client.WriteRelationships(context.Background(), &v1.WriteRelationshipsRequest{
+		Updates: []*v1.RelationshipUpdate{
+			tuple.UpdateToRelationshipUpdate(tuple.Create(tuple.MustParse("document:masterplan#viewer@user:auditor"))),
+			tuple.UpdateToRelationshipUpdate(tuple.Delete(deleted)),
+		},
+	})
+	require.NoError(err)
+	after := time.Now()
+
+	// Relationships are read as they were at the time, or the revision, requested.
+	found, err := readRelationshipsAt(experimentalClient, atTime(before), filter)
+	require.NoError(err)
+	require.Equal(original, found)
+
+	found, err = readRelationshipsAt(experimentalClient, atRevision(written.WrittenAt), filter)
+	require.NoError(err)
+	require.Contains(found, "document:masterplan#viewer@user:auditor")
+	require.NotContains(found, original[0])
+	require.Len(found, len(original))
+
+	// The net changes between two points are returned, deletions first.
+	changes, err := readRelationshipChanges(experimentalClient, atTime(before), atTime(after), filter)
+	require.NoError(err)
+	require.Equal([]string{
+		"OPERATION_DELETE " + original[0],
+		"OPERATION_TOUCH document:masterplan#viewer@user:auditor",
+	}, changes)
+
+	changes, err = readRelationshipChanges(experimentalClient, atRevision(written.WrittenAt), atTime(after), filter)
+	require.NoError(err)
+	require.Empty(changes)
+
+	// Changes made to other relationships are not returned.
+	changes, err = readRelationshipChanges(experimentalClient, atTime(before), atTime(after), &v1.RelationshipFilter{ResourceType: "folder"})
+	require.NoError(err)
+	require.Empty(changes)
+}
+
+func TestRelationshipHistoryErrors(t *testing.T) {
+	require := require.New(t)
+	conn, cleanup, _, revision := testserver.NewTestServer(require, 0, memdb.DisableGC, true, tf.StandardDatastoreWithData)
+	t.Cleanup(cleanup)
+
+	client := experimentalv1.NewExperimentalServiceClient(conn)
+	filter := &v1.RelationshipFilter{ResourceType: "document"}
+	current := atRevision(zedtoken.MustNewFromRevision(revision))
+
+	_, err := readRelationshipsAt(client, atTime(time.Now().Add(time.Hour)), filter)
+	grpcutil.RequireStatus(t, codes.InvalidArgument, err)
+
+	_, err = readRelationshipsAt(client, atRevision(&v1.ZedToken{Token: "invalid"}), filter)
+	grpcutil.RequireStatus(t, codes.InvalidArgument, err)
+
+	_, err = readRelationshipsAt(client, current, &v1.RelationshipFilter{ResourceType: "unknown"})
+	grpcutil.RequireStatus(t, codes.FailedPrecondition, err)
+
+	_, err = readRelationshipsAt(client, &experimentalv1.HistoricalPoint{}, filter)
+	grpcutil.RequireStatus(t, codes.InvalidArgument, err)
+
+	// Times preceding the history retained are rejected.
+	_, err = readRelationshipsAt(client, atTime(time.Now().Add(-time.Hour)), filter)
+	grpcutil.RequireStatus(t, codes.OutOfRange, err)
+
+	written, err := v1.NewPermissionsServiceClient(conn).WriteRelationships(context.Background(), &v1.WriteRelationshipsRequest{
+		Updates: []*v1.RelationshipUpdate{
+			tuple.UpdateToRelationshipUpdate(tuple.Create(tuple.MustParse("document:masterplan#viewer@user:auditor"))),
+		},
+	})
+	require.NoError(err)
+
+	_, err = readRelationshipChanges(client, atRevision(written.WrittenAt), current, filter)
+	grpcutil.RequireStatus(t, codes.InvalidArgument, err)
+}
+
+func checkAtTime(client experimentalv1.ExperimentalServiceClient, at time.Time) (*experimentalv1.CheckPermissionAtTimeResponse, error) {
+	return client.CheckPermissionAtTime(context.Background(), &experimentalv1.CheckPermissionAtTimeRequest{
+		At:         timestamppb.New(at),
+		Resource:   &v1.ObjectReference{ObjectType: "document", ObjectId: "secret"},
+		Permission: "view",
+		Subject:    &v1.SubjectReference{Object: &v1.ObjectReference{ObjectType: "user", ObjectId: "auditor"}},
+	})
+}
+
+func TestCheckPermissionAtTime(t *testing.T) {
+	require := require.New(t)
+
+	// The archive is recorded from a datastore whose history precedes that of the server.
+	archived, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	require.NoError(err)
+	archived, _ = tf.StandardDatastoreWithData(archived, require)
+
+	directory := t.TempDir()
+	recorder, err := archive.NewRecorder(archived, archive.Config{Directory: directory, SnapshotInterval: time.Hour})
+	require.NoError(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error)
+	go func() { stopped <- recorder.Start(ctx) }()
+
+	require.Eventually(func() bool {
+		segments, err := filepath.Glob(filepath.Join(directory, "segment-*.jsonl"))
+		return err == nil && len(segments) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	beforeWrite := time.Now()
+	_, err = archived.ReadWriteTx(context.Background(), func(rwt datastore.ReadWriteTransaction) error {
+		return rwt.WriteRelationships(context.Background(), []*core.RelationTupleUpdate{
+			tuple.Create(tuple.MustParse("document:secret#viewer@user:auditor")),
+		})
+	})
+	require.NoError(err)
+
+	archiveReader := archive.NewArchive(directory)
+	require.Eventually(func() bool {
+		state, err := archiveReader.LoadAt(context.Background(), time.Now())
+		return err == nil && state.ArchivedAt.After(beforeWrite)
+	}, 5*time.Second, 10*time.Millisecond)
+	afterWrite := time.Now()
+
+	cancel()
+	require.NoError(<-stopped)
+
+	conn, cleanup, _, _ := testserver.NewTestServerWithConfig(require, 0, memdb.DisableGC, true,
+		testserver.ServerConfig{
+			MaxUpdatesPerWrite:      1000,
+			MaxPreconditionsCount:   1000,
+			PointInTimeCheckEnabled: true,
+			ArchiveDirectory:        directory,
+		},
+		tf.StandardDatastoreWithData)
+	t.Cleanup(cleanup)
+	client := experimentalv1.NewExperimentalServiceClient(conn)
+
+	// Times preceding the history of the datastore are checked against the archive.
+	resp, err := checkAtTime(client, beforeWrite)
+	require.NoError(err)
+	require.True(resp.FromArchive)
+	require.Nil(resp.CheckedAt)
+	require.Equal(v1.CheckPermissionResponse_PERMISSIONSHIP_NO_PERMISSION, resp.Permissionship)
+
+	resp, err = checkAtTime(client, afterWrite)
+	require.NoError(err)
+	require.True(resp.FromArchive)
+	require.False(resp.ArchivedAt.AsTime().Before(beforeWrite))
+	require.Equal(v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION, resp.Permissionship)
+
+	// Times within the history of the datastore are checked against it.
+	resp, err = checkAtTime(client, time.Now())
+	require.NoError(err)
+	require.False(resp.FromArchive)
+	require.NotNil(resp.CheckedAt)
+	require.Equal(v1.CheckPermissionResponse_PERMISSIONSHIP_NO_PERMISSION, resp.Permissionship)
+
+	_, err = checkAtTime(client, beforeWrite.Add(-time.Hour))
+	grpcutil.RequireStatus(t, codes.OutOfRange, err)
+
+	_, err = checkAtTime(client, time.Now().Add(time.Hour))
+	grpcutil.RequireStatus(t, codes.InvalidArgument, err)
+}
+
+func TestCheckPermissionAtTimeDisabled(t *testing.T) {
+	require := require.New(t)
+	conn, cleanup, _, _ := testserver.NewTestServer(require, 0, memdb.DisableGC, true, tf.StandardDatastoreWithData)
+	t.Cleanup(cleanup)
+
+	_, err := checkAtTime(experimentalv1.NewExperimentalServiceClient(conn), time.Now())
+	grpcutil.RequireStatus(t, codes.FailedPrecondition, err)
+}
@@ -0,0 +1,135 @@
+package v1
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
+	"github.com/authzed/spicedb/internal/jobs"
+	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
+	experimentalv1 "github.com/authzed/spicedb/pkg/proto/experimental/v1"
+)
+
+var jobStates = map[jobs.State]experimentalv1.Job_State{
+	jobs.StatePending:   experimentalv1.Job_STATE_PENDING,
+	jobs.StateRunning:   experimentalv1.Job_STATE_RUNNING,
+	jobs.StateSucceeded: experimentalv1.Job_STATE_SUCCEEDED,
+	jobs.StateFailed:    experimentalv1.Job_STATE_FAILED,
+	jobs.StateCancelled: experimentalv1.Job_STATE_CANCELLED,
+}
+
+// StartDeleteRelationshipsJob submits a job deleting the relationships matching the filter.
+func (es *experimentalServer) StartDeleteRelationshipsJob(ctx context.Context, req *experimentalv1.StartDeleteRelationshipsJobRequest) (*experimentalv1.StartDeleteRelationshipsJobResponse, error) {
+	if es.jobs == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "jobs are not supported by the datastore")
+	}
+
+	ds := datastoremw.MustFromContext(ctx)
+	headRevision, err := ds.HeadRevision(ctx)
+	if err != nil {
+		return nil, rewriteError(ctx, err)
+	}
+
+	reader := ds.SnapshotReader(headRevision)
+	if err := es.permissions.(*permissionServer).checkFilterNamespaces(ctx, req.RelationshipFilter, reader); err != nil {
+		return nil, rewriteError(ctx, err)
+	}
+
+	// As with DeleteRelationships, the relationships must be deleted by the relations aliased.
+	resourceAliasOf, subjectAliasOf, err := filterAliases(ctx, req.RelationshipFilter, reader)
+	if err != nil {
+		return nil, rewriteError(ctx, err)
+	}
+	if resourceAliasOf != "" || subjectAliasOf != "" {
+		return nil, status.Errorf(codes.InvalidArgument, "cannot delete relationships by alias: relationships must be deleted by the relation which the alias references")
+	}
+
+	parameters, err := jobs.NewDeleteRelationshipsParameters(req.RelationshipFilter, uint64(req.BatchSize))
+	if err != nil {
+		return nil, rewriteError(ctx, err)
+	}
+
+	job, err := es.jobs.Submit(ctx, jobs.KindDeleteRelationships, parameters)
+	if err != nil {
+		return nil, rewriteJobError(ctx, err)
+	}
+
+	return &experimentalv1.StartDeleteRelationshipsJobResponse{Job: jobToProto(job)}, nil
+}
+
+// GetJob returns the state of a job.
+func (es *experimentalServer) GetJob(ctx context.Context, req *experimentalv1.GetJobRequest) (*experimentalv1.GetJobResponse, error) {
+	if es.jobs == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "jobs are not supported by the datastore")
+	}
+
+	job, err := es.jobs.Get(ctx, req.JobId)
+	if err != nil {
+		return nil, rewriteJobError(ctx, err)
+	}
+
+	return &experimentalv1.GetJobResponse{Job: jobToProto(job)}, nil
+}
+
+// ListJobs returns the state of every job.
+func (es *experimentalServer) ListJobs(ctx context.Context, _ *experimentalv1.ListJobsRequest) (*experimentalv1.ListJobsResponse, error) {
+	if es.jobs == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "jobs are not supported by the datastore")
+	}
+
+	found, err := es.jobs.List(ctx)
+	if err != nil {
+		return nil, rewriteJobError(ctx, err)
+	}
+
+	resp := &experimentalv1.ListJobsResponse{Jobs: make([]*experimentalv1.Job, 0, len(found))}
+	for _, job := range found {
+		resp.Jobs = append(resp.Jobs, jobToProto(job))
+	}
+	return resp, nil
+}
+
+// CancelJob cancels a job, or requests the cancellation of a running job.
+func (es *experimentalServer) CancelJob(ctx context.Context, req *experimentalv1.CancelJobRequest) (*experimentalv1.CancelJobResponse, error) {
+	if es.jobs == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "jobs are not supported by the datastore")
+	}
+
+	job, err := es.jobs.Cancel(ctx, req.JobId)
+	if err != nil {
+		return nil, rewriteJobError(ctx, err)
+	}
+
+	return &experimentalv1.CancelJobResponse{Job: jobToProto(job)}, nil
+}
+
+func rewriteJobError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(err, common.ErrJobNotFound):
+		return status.Errorf(codes.NotFound, "%s", err)
+	case errors.Is(err, jobs.ErrJobFinished):
+		return status.Errorf(codes.FailedPrecondition, "%s", err)
+	case errors.Is(err, jobs.ErrUnknownKind):
+		return status.Errorf(codes.Unimplemented, "%s", err)
+	default:
+		return rewriteError(ctx, err)
+	}
+}
+
+func jobToProto(job jobs.Job) *experimentalv1.Job {
+	return &experimentalv1.Job{
+		Id:              job.ID,
+		Kind:            job.Kind,
+		State:           jobStates[job.State],
+		Completed:       job.Completed,
+		Total:           job.Total,
+		Error:           job.Error,
+		CancelRequested: job.CancelRequested,
+		CreatedAt:       timestamppb.New(job.CreatedAt),
+		UpdatedAt:       timestamppb.New(job.UpdatedAt),
+	}
+}
@@ -0,0 +1,97 @@
+package v1_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/authzed/grpcutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	tf "github.com/authzed/spicedb/internal/testfixtures"
+	"github.com/authzed/spicedb/internal/testserver"
+	experimentalv1 "github.com/authzed/spicedb/pkg/proto/experimental/v1"
+	"github.com/authzed/spicedb/pkg/zedtoken"
+)
+
+func TestDeleteRelationshipsJob(t *testing.T) {
+	require := require.New(t)
+	conn, cleanup, _, revision := testserver.NewTestServerWithConfig(
+		require,
+		testTimedeltas[0],
+		memdb.DisableGC,
+		true,
+		testserver.ServerConfig{
+			MaxUpdatesPerWrite:    1000,
+			MaxPreconditionsCount: 1000,
+			JobsEnabled:           true,
+			JobPollInterval:       10 * time.Millisecond,
+		},
+		tf.StandardDatastoreWithData,
+	)
+	t.Cleanup(cleanup)
+
+	client := v1.NewPermissionsServiceClient(conn)
+	experimentalClient := experimentalv1.NewExperimentalServiceClient(conn)
+
+	var expected uint64
+	for rel := range readAll(require, client, zedtoken.MustNewFromRevision(revision)) {
+		if strings.HasPrefix(rel, "document:") {
+			expected++
+		}
+	}
+	require.NotZero(expected)
+
+	started, err := experimentalClient.StartDeleteRelationshipsJob(context.Background(), &experimentalv1.StartDeleteRelationshipsJobRequest{
+		RelationshipFilter: &v1.RelationshipFilter{ResourceType: "document"},
+		BatchSize:          3,
+	})
+	require.NoError(err)
+	require.Equal("delete-relationships", started.Job.Kind)
+	require.Equal(experimentalv1.Job_STATE_PENDING, started.Job.State)
+
+	var job *experimentalv1.Job
+	require.Eventually(func() bool {
+		resp, err := experimentalClient.GetJob(context.Background(), &experimentalv1.GetJobRequest{JobId: started.Job.Id})
+		require.NoError(err)
+		job = resp.Job
+		return job.State == experimentalv1.Job_STATE_SUCCEEDED
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(expected, job.Completed)
+	require.Equal(expected, job.Total)
+
+	listed, err := experimentalClient.ListJobs(context.Background(), &experimentalv1.ListJobsRequest{})
+	require.NoError(err)
+	require.Len(listed.Jobs, 1)
+	require.Equal(started.Job.Id, listed.Jobs[0].Id)
+
+	_, err = experimentalClient.CancelJob(context.Background(), &experimentalv1.CancelJobRequest{JobId: started.Job.Id})
+	grpcutil.RequireStatus(t, codes.FailedPrecondition, err)
+
+	_, err = experimentalClient.GetJob(context.Background(), &experimentalv1.GetJobRequest{JobId: "missing"})
+	grpcutil.RequireStatus(t, codes.NotFound, err)
+
+	_, err = experimentalClient.StartDeleteRelationshipsJob(context.Background(), &experimentalv1.StartDeleteRelationshipsJobRequest{
+		RelationshipFilter: &v1.RelationshipFilter{ResourceType: "unknown"},
+	})
+	grpcutil.RequireStatus(t, codes.FailedPrecondition, err)
+}
+
+func TestJobsDisabled(t *testing.T) {
+	require := require.New(t)
+	conn, cleanup, _, _ := testserver.NewTestServer(require, testTimedeltas[0], memdb.DisableGC, true, tf.StandardDatastoreWithData)
+	t.Cleanup(cleanup)
+
+	experimentalClient := experimentalv1.NewExperimentalServiceClient(conn)
+	_, err := experimentalClient.StartDeleteRelationshipsJob(context.Background(), &experimentalv1.StartDeleteRelationshipsJobRequest{
+		RelationshipFilter: &v1.RelationshipFilter{ResourceType: "document"},
+	})
+	grpcutil.RequireStatus(t, codes.FailedPrecondition, err)
+
+	_, err = experimentalClient.ListJobs(context.Background(), &experimentalv1.ListJobsRequest{})
+	grpcutil.RequireStatus(t, codes.FailedPrecondition, err)
+}
@@ -0,0 +1,101 @@
+package v1
+
+import (
+	"context"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+const (
+	// RelationshipLabelsHeader is the request header in which callers of WriteRelationships can
+	// specify labels, in the form `key=value,otherkey=othervalue`, to set on every relationship
+	// created or touched by the request.
+	RelationshipLabelsHeader = "io.spicedb.relationshiplabels"
+
+	// RelationshipLabelFilterHeader is the request header in which callers of ReadRelationships
+	// and DeleteRelationships can specify labels, in the same form, which the relationships read
+	// or deleted must have.
+	RelationshipLabelFilterHeader = "io.spicedb.relationshiplabelfilter"
+
+	// deleteLabelledBatchSize is the number of labelled relationships deleted per write.
+	deleteLabelledBatchSize = 1000
+)
+
+// labelsFromRequest returns the labels found in the given header of the request, if any.
+func labelsFromRequest(ctx context.Context, header string) (map[string]string, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, nil
+	}
+
+	values := md.Get(header)
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	labels, err := tuple.ParseLabels(values[0])
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid value for %s: %s", header, err)
+	}
+	return labels, nil
+}
+
+// applyLabels sets the given labels on every relationship created or touched by the updates.
+func applyLabels(updates []*core.RelationTupleUpdate, labels map[string]string) {
+	if len(labels) == 0 {
+		return
+	}
+
+	for _, update := range updates {
+		if update.Operation != core.RelationTupleUpdate_DELETE {
+			update.Tuple.Labels = labels
+		}
+	}
+}
+
+// deleteLabelledRelationships deletes the relationships matching the filter which have all of the
+// given labels. As the filter for deletion cannot express labels, the matching relationships
+// are read and then deleted individually.
+func deleteLabelledRelationships(ctx context.Context, rwt datastore.ReadWriteTransaction, filter *v1.RelationshipFilter, labels map[string]string) error {
+	dsFilter := datastore.RelationshipsFilterFromPublicFilter(filter)
+	dsFilter.OptionalLabels = labels
+
+	iter, err := rwt.QueryRelationships(ctx, dsFilter)
+	if err != nil {
+		return err
+	}
+
+	var deletes []*core.RelationTupleUpdate
+	for {
+		tpl, err := iter.Next()
+		if err != nil {
+			iter.Close()
+			return err
+		}
+		if tpl == nil {
+			break
+		}
+
+		deletes = append(deletes, tuple.Delete(tpl))
+	}
+	iter.Close()
+
+	for start := 0; start < len(deletes); start += deleteLabelledBatchSize {
+		end := start + deleteLabelledBatchSize
+		if end > len(deletes) {
+			end = len(deletes)
+		}
+
+		if err := rwt.WriteRelationships(ctx, deletes[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
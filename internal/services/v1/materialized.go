@@ -0,0 +1,55 @@
+package v1
+
+import (
+	"context"
+	"errors"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/authzed/spicedb/internal/materialize"
+	experimentalv1 "github.com/authzed/spicedb/pkg/proto/experimental/v1"
+	"github.com/authzed/spicedb/pkg/zedtoken"
+)
+
+// LookupMaterializedResources looks up the resources on which the subject has the permission in
+// the table materialized by the server, without dispatching.
+func (es *experimentalServer) LookupMaterializedResources(ctx context.Context, req *experimentalv1.LookupMaterializedResourcesRequest) (*experimentalv1.LookupMaterializedResourcesResponse, error) {
+	if es.materializer == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "no permissions are materialized")
+	}
+
+	spec := materialize.Spec{
+		ResourceType: req.ResourceObjectType,
+		Permission:   req.Permission,
+		SubjectType:  req.Subject.ObjectType,
+	}
+	resources, materializedAt, err := es.materializer.LookupResources(spec, req.Subject.ObjectId)
+	switch {
+	case errors.Is(err, materialize.ErrNotMaterialized):
+		return nil, status.Errorf(codes.FailedPrecondition, "permission `%s` is not materialized", spec)
+	case errors.Is(err, materialize.ErrNotReady):
+		return nil, status.Errorf(codes.Unavailable, "%s", err)
+	case err != nil:
+		return nil, status.Errorf(codes.FailedPrecondition, "unable to materialize permission `%s`: %s", spec, err)
+	}
+
+	found := make([]*experimentalv1.MaterializedResource, 0, len(resources))
+	for _, resource := range resources {
+		permissionship := v1.LookupPermissionship_LOOKUP_PERMISSIONSHIP_HAS_PERMISSION
+		if resource.Conditional {
+			permissionship = v1.LookupPermissionship_LOOKUP_PERMISSIONSHIP_CONDITIONAL_PERMISSION
+		}
+
+		found = append(found, &experimentalv1.MaterializedResource{
+			ResourceObjectId: resource.ResourceID,
+			Permissionship:   permissionship,
+		})
+	}
+
+	return &experimentalv1.LookupMaterializedResourcesResponse{
+		MaterializedAt: zedtoken.MustNewFromRevision(materializedAt),
+		Resources:      found,
+	}, nil
+}
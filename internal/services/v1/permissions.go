@@ -3,6 +3,8 @@ package v1
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/authzed/spicedb/pkg/datastore"
 
@@ -19,6 +21,7 @@ import (
 	"google.golang.org/protobuf/types/known/structpb"
 
 	cexpr "github.com/authzed/spicedb/internal/caveats"
+	"github.com/authzed/spicedb/internal/datasets"
 	dispatchpkg "github.com/authzed/spicedb/internal/dispatch"
 	"github.com/authzed/spicedb/internal/graph"
 	"github.com/authzed/spicedb/internal/graph/computed"
@@ -33,7 +36,13 @@ import (
 
 const maxCaveatContextBytes = 4096
 
+// ResourceIDPrefixHeader is the request header in which callers of LookupResources can specify a
+// prefix which the IDs of the resources found must have, such as that of the IDs of the
+// documents within a folder.
+const ResourceIDPrefixHeader = "io.spicedb.resourceidprefix"
+
 func (ps *permissionServer) CheckPermission(ctx context.Context, req *v1.CheckPermissionRequest) (*v1.CheckPermissionResponse, error) {
+	start := time.Now()
 	atRevision, checkedAt := consistency.MustRevisionFromContext(ctx)
 	ds := datastoremw.MustFromContext(ctx).SnapshotReader(atRevision)
 
@@ -66,12 +75,18 @@ func (ps *permissionServer) CheckPermission(ctx context.Context, req *v1.CheckPe
 		return nil, rewriteError(ctx, err)
 	}
 
-	debugOption := computed.NoDebugging
+	isDebuggingEnabled := false
 	if md, ok := metadata.FromIncomingContext(ctx); ok {
-		_, isDebuggingEnabled := md[string(requestmeta.RequestDebugInformation)]
-		if isDebuggingEnabled {
-			debugOption = computed.BasicDebuggingEnabled
-		}
+		_, isDebuggingEnabled = md[string(requestmeta.RequestDebugInformation)]
+	}
+
+	// Debug information is also computed for requests sampled for tracing, but only returned to
+	// the caller if requested.
+	isTraced := ps.config.TraceRecorder != nil && (isDebuggingEnabled || ps.config.TraceRecorder.Sample())
+
+	debugOption := computed.NoDebugging
+	if isDebuggingEnabled || isTraced {
+		debugOption = computed.BasicDebuggingEnabled
 	}
 
 	cr, metadata, err := computed.ComputeCheck(ctx, ps.dispatch,
@@ -94,6 +109,7 @@ func (ps *permissionServer) CheckPermission(ctx context.Context, req *v1.CheckPe
 	)
 	usagemetrics.SetInContext(ctx, metadata)
 
+	var marshaled []byte
 	if debugOption != computed.NoDebugging && metadata.DebugInfo != nil {
 		// Convert the dispatch debug information into API debug information and marshal into
 		// the footer.
@@ -102,19 +118,36 @@ func (ps *permissionServer) CheckPermission(ctx context.Context, req *v1.CheckPe
 			return nil, rewriteError(ctx, cerr)
 		}
 
-		marshaled, merr := protojson.Marshal(converted)
+		var merr error
+		marshaled, merr = protojson.Marshal(converted)
 		if merr != nil {
 			return nil, rewriteError(ctx, merr)
 		}
 
-		serr := responsemeta.SetResponseTrailerMetadata(ctx, map[responsemeta.ResponseMetadataTrailerKey]string{
-			responsemeta.DebugInformation: string(marshaled),
-		})
-		if serr != nil {
-			return nil, rewriteError(ctx, serr)
+		if isDebuggingEnabled {
+			trailer := map[responsemeta.ResponseMetadataTrailerKey]string{
+				responsemeta.DebugInformation: string(marshaled),
+			}
+
+			if evaluations := metadata.DebugInfo.CaveatEvaluations; len(evaluations) > 0 {
+				marshaledEvaluations, merr := MarshalCaveatEvaluations(evaluations)
+				if merr != nil {
+					return nil, rewriteError(ctx, merr)
+				}
+				trailer[CaveatEvaluationsTrailer] = string(marshaledEvaluations)
+			}
+
+			serr := responsemeta.SetResponseTrailerMetadata(ctx, trailer)
+			if serr != nil {
+				return nil, rewriteError(ctx, serr)
+			}
 		}
 	}
 
+	if isTraced {
+		ps.recordTrace(ctx, "CheckPermission", start, req, marshaled, metadata, err, nil)
+	}
+
 	if err != nil {
 		return nil, rewriteError(ctx, err)
 	}
@@ -146,6 +179,11 @@ func (ps *permissionServer) ExpandPermissionTree(ctx context.Context, req *v1.Ex
 		return nil, rewriteError(ctx, err)
 	}
 
+	maximumResultSize, err := ps.maximumResultSize(ctx)
+	if err != nil {
+		return nil, rewriteError(ctx, err)
+	}
+
 	resp, err := ps.dispatch.DispatchExpand(ctx, &dispatch.DispatchExpandRequest{
 		Metadata: &dispatch.ResolverMeta{
 			AtRevision:     atRevision.String(),
@@ -156,13 +194,19 @@ func (ps *permissionServer) ExpandPermissionTree(ctx context.Context, req *v1.Ex
 			ObjectId:  req.Resource.ObjectId,
 			Relation:  req.Permission,
 		},
-		ExpansionMode: dispatch.DispatchExpandRequest_SHALLOW,
+		ExpansionMode:     dispatch.DispatchExpandRequest_SHALLOW,
+		MaximumResultSize: maximumResultSize,
 	})
 	usagemetrics.SetInContext(ctx, resp.Metadata)
 	if err != nil {
 		return nil, rewriteError(ctx, err)
 	}
 
+	// Expansions served from the dispatch cache may have been computed under a larger maximum.
+	if maximumResultSize > 0 && graph.ExpansionTreeSize(resp.TreeNode) > maximumResultSize {
+		return nil, rewriteError(ctx, graph.NewResultTooLargeErr("expand", maximumResultSize))
+	}
+
 	// TODO(jschorr): Change to either using shared interfaces for nodes, or switch the internal
 	// dispatched expand to return V1 node types.
 	return &v1.ExpandPermissionTreeResponse{
@@ -317,6 +361,7 @@ func TranslateExpansionTree(node *core.RelationTupleTreeNode) *v1.PermissionRela
 }
 
 func (ps *permissionServer) LookupResources(req *v1.LookupResourcesRequest, resp v1.PermissionsService_LookupResourcesServer) error {
+	start := time.Now()
 	ctx := resp.Context()
 	atRevision, revisionReadAt := consistency.MustRevisionFromContext(ctx)
 	ds := datastoremw.MustFromContext(ctx).SnapshotReader(atRevision)
@@ -345,6 +390,26 @@ func (ps *permissionServer) LookupResources(req *v1.LookupResourcesRequest, resp
 		return rewriteError(ctx, err)
 	}
 
+	resourceIDPrefix, err := resourceIDPrefixFromRequest(ctx)
+	if err != nil {
+		return rewriteError(ctx, err)
+	}
+
+	maximumResultSize, err := ps.maximumResultSize(ctx)
+	if err != nil {
+		return rewriteError(ctx, err)
+	}
+
+	// Look up one more resource than the maximum, to find whether the maximum is exceeded.
+	limit := ^uint32(0) // Set no limit for now
+	if maximumResultSize > 0 && maximumResultSize < uint64(limit) {
+		limit = uint32(maximumResultSize) + 1
+	}
+
+	if err := consistency.SetEvaluatedRevisionHeader(ctx, revisionReadAt); err != nil {
+		return rewriteError(ctx, err)
+	}
+
 	// TODO(jschorr): Change the internal dispatched lookup to also be streamed.
 	lookupResp, err := ps.dispatch.DispatchLookup(ctx, &dispatch.DispatchLookupRequest{
 		Metadata: &dispatch.ResolverMeta{
@@ -360,14 +425,24 @@ func (ps *permissionServer) LookupResources(req *v1.LookupResourcesRequest, resp
 			ObjectId:  req.Subject.Object.ObjectId,
 			Relation:  normalizeSubjectRelation(req.Subject),
 		},
-		Context: req.Context,
-		Limit:   ^uint32(0), // Set no limit for now
+		Context:                  req.Context,
+		Limit:                    limit,
+		OptionalResourceIdPrefix: resourceIDPrefix,
 	})
 	usagemetrics.SetInContext(ctx, lookupResp.Metadata)
+	if ps.config.TraceRecorder.Sample() {
+		ps.recordTrace(ctx, "LookupResources", start, req, nil, lookupResp.Metadata, err, map[string]string{
+			"result_count": strconv.Itoa(len(lookupResp.GetResolvedResources())),
+		})
+	}
 	if err != nil {
 		return rewriteError(ctx, err)
 	}
 
+	if maximumResultSize > 0 && uint64(len(lookupResp.ResolvedResources)) > maximumResultSize {
+		return rewriteError(ctx, graph.NewResultTooLargeErr("lookup", maximumResultSize))
+	}
+
 	for _, found := range lookupResp.ResolvedResources {
 		var partial *v1.PartialCaveatInfo
 		permissionship := v1.LookupPermissionship_LOOKUP_PERMISSIONSHIP_HAS_PERMISSION
@@ -426,6 +501,10 @@ func (ps *permissionServer) LookupSubjects(req *v1.LookupSubjectsRequest, resp v
 		return rewriteError(ctx, err)
 	}
 
+	if err := consistency.SetEvaluatedRevisionHeader(ctx, revisionReadAt); err != nil {
+		return rewriteError(ctx, err)
+	}
+
 	respMetadata := &dispatch.ResponseMeta{
 		DispatchCount:       0,
 		CachedDispatchCount: 0,
@@ -434,6 +513,50 @@ func (ps *permissionServer) LookupSubjects(req *v1.LookupSubjectsRequest, resp v
 	}
 	usagemetrics.SetInContext(ctx, respMetadata)
 
+	sendFoundSubject := func(foundSubject *dispatch.FoundSubject) error {
+		excludedSubjects := make([]*v1.ResolvedSubject, 0, len(foundSubject.ExcludedSubjects))
+		excludedSubjectIDs := make([]string, 0, len(foundSubject.ExcludedSubjects))
+		for _, excludedSubject := range foundSubject.ExcludedSubjects {
+			resolvedExcludedSubject, err := foundSubjectToResolvedSubject(ctx, excludedSubject, caveatContext, ds)
+			if err != nil {
+				return err
+			}
+
+			// An exclusion whose caveat is false excludes no one.
+			if resolvedExcludedSubject == nil {
+				continue
+			}
+
+			excludedSubjects = append(excludedSubjects, resolvedExcludedSubject)
+			excludedSubjectIDs = append(excludedSubjectIDs, excludedSubject.SubjectId)
+		}
+
+		subject, err := foundSubjectToResolvedSubject(ctx, foundSubject, caveatContext, ds)
+		if err != nil {
+			return err
+		}
+		if subject == nil {
+			return nil
+		}
+
+		return resp.Send(&v1.LookupSubjectsResponse{
+			Subject:            subject,
+			ExcludedSubjects:   excludedSubjects,
+			LookedUpAt:         revisionReadAt,
+			SubjectObjectId:    foundSubject.SubjectId,    // Deprecated
+			ExcludedSubjectIds: excludedSubjectIDs,        // Deprecated
+			Permissionship:     subject.Permissionship,    // Deprecated
+			PartialCaveatInfo:  subject.PartialCaveatInfo, // Deprecated
+		})
+	}
+
+	// Wildcards can be found by several branches of the lookup, each with its own exclusions, and
+	// a subject excluded by one can be found concretely by another. Concrete subjects are sent as
+	// they are found, but wildcards are held back and unioned with every subject found thereafter,
+	// so that a single wildcard is sent at the end, excluding only those subjects excluded by every
+	// branch and not found concretely. Found subjects are not retained, so if any were sent before
+	// the wildcard was found, its exclusions are instead checked once the lookup completes.
+	var wildcard pendingWildcard
 	stream := dispatchpkg.NewHandlingDispatchStream(ctx, func(result *dispatch.DispatchLookupSubjectsResponse) error {
 		foundSubjects, ok := result.FoundSubjectsByResourceId[req.Resource.ObjectId]
 		if !ok {
@@ -441,43 +564,15 @@ func (ps *permissionServer) LookupSubjects(req *v1.LookupSubjectsRequest, resp v
 		}
 
 		for _, foundSubject := range foundSubjects.FoundSubjects {
-			excludedSubjectIDs := make([]string, 0, len(foundSubject.ExcludedSubjects))
-			for _, excludedSubject := range foundSubject.ExcludedSubjects {
-				excludedSubjectIDs = append(excludedSubjectIDs, excludedSubject.SubjectId)
-			}
-
-			excludedSubjects := make([]*v1.ResolvedSubject, 0, len(foundSubject.ExcludedSubjects))
-			for _, excludedSubject := range foundSubject.ExcludedSubjects {
-				resolvedExcludedSubject, err := foundSubjectToResolvedSubject(ctx, excludedSubject, caveatContext, ds)
-				if err != nil {
-					return err
-				}
-
-				if resolvedExcludedSubject == nil {
-					continue
-				}
-
-				excludedSubjects = append(excludedSubjects, resolvedExcludedSubject)
-			}
-
-			subject, err := foundSubjectToResolvedSubject(ctx, foundSubject, caveatContext, ds)
-			if err != nil {
+			if err := wildcard.union(foundSubject); err != nil {
 				return err
 			}
-			if subject == nil {
+
+			if foundSubject.SubjectId == tuple.PublicWildcard {
 				continue
 			}
 
-			err = resp.Send(&v1.LookupSubjectsResponse{
-				Subject:            subject,
-				ExcludedSubjects:   excludedSubjects,
-				LookedUpAt:         revisionReadAt,
-				SubjectObjectId:    foundSubject.SubjectId,    // Deprecated
-				ExcludedSubjectIds: excludedSubjectIDs,        // Deprecated
-				Permissionship:     subject.Permissionship,    // Deprecated
-				PartialCaveatInfo:  subject.PartialCaveatInfo, // Deprecated
-			})
-			if err != nil {
+			if err := sendFoundSubject(foundSubject); err != nil {
 				return err
 			}
 		}
@@ -507,9 +602,112 @@ func (ps *permissionServer) LookupSubjects(req *v1.LookupSubjectsRequest, resp v
 		return rewriteError(ctx, err)
 	}
 
+	if wildcard.found == nil {
+		return nil
+	}
+
+	if wildcard.sentBeforeFound && len(wildcard.found.ExcludedSubjects) > 0 {
+		checkParams := computed.CheckParameters{
+			ResourceType: &core.RelationReference{
+				Namespace: req.Resource.ObjectType,
+				Relation:  req.Permission,
+			},
+			CaveatContext: caveatContext,
+			AtRevision:    atRevision,
+			MaximumDepth:  ps.config.MaximumAPIDepth,
+			DebugOption:   computed.NoDebugging,
+		}
+
+		excludedSubjects := make([]*dispatch.FoundSubject, 0, len(wildcard.found.ExcludedSubjects))
+		for _, excludedSubject := range wildcard.found.ExcludedSubjects {
+			checkParams.Subject = &core.ObjectAndRelation{
+				Namespace: req.SubjectObjectType,
+				ObjectId:  excludedSubject.SubjectId,
+				Relation:  stringz.DefaultEmpty(req.OptionalSubjectRelation, tuple.Ellipsis),
+			}
+
+			cr, metadata, err := computed.ComputeCheck(ctx, ps.dispatch, checkParams, req.Resource.ObjectId)
+			dispatchpkg.AddResponseMetadata(respMetadata, metadata)
+			if err != nil {
+				return rewriteError(ctx, err)
+			}
+
+			// A subject which has the permission was found concretely.
+			if cr.Membership == dispatch.ResourceCheckResult_MEMBER {
+				continue
+			}
+			excludedSubjects = append(excludedSubjects, excludedSubject)
+		}
+
+		found := wildcard.found.CloneVT()
+		found.ExcludedSubjects = excludedSubjects
+		wildcard.found = found
+	}
+
+	if err := sendFoundSubject(wildcard.found); err != nil {
+		return rewriteError(ctx, err)
+	}
+
 	return nil
 }
 
+// pendingWildcard is the union of the wildcards found by a lookup of subjects, if any, with the
+// exclusions of those subjects found concretely since removed.
+type pendingWildcard struct {
+	found *dispatch.FoundSubject
+
+	// sentBeforeFound is whether any concrete subject was found before the wildcard, and so may
+	// still be excluded by it.
+	sentBeforeFound bool
+}
+
+func (pw *pendingWildcard) union(foundSubject *dispatch.FoundSubject) error {
+	if pw.found == nil {
+		if foundSubject.SubjectId == tuple.PublicWildcard {
+			pw.found = foundSubject
+		} else {
+			pw.sentBeforeFound = true
+		}
+		return nil
+	}
+
+	if foundSubject.SubjectId != tuple.PublicWildcard && len(pw.found.ExcludedSubjects) == 0 {
+		return nil
+	}
+
+	// The set holds the concrete subject only for the duration of the union.
+	combined := datasets.NewSubjectSet()
+	if err := combined.Add(pw.found); err != nil {
+		return err
+	}
+	if err := combined.Add(foundSubject); err != nil {
+		return err
+	}
+
+	pw.found, _ = combined.Get(tuple.PublicWildcard)
+	return nil
+}
+
+// resourceIDPrefixFromRequest returns the prefix of resource IDs found in the header of the
+// request, if any.
+func resourceIDPrefixFromRequest(ctx context.Context) (string, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", nil
+	}
+
+	values := md.Get(ResourceIDPrefixHeader)
+	if len(values) == 0 || values[0] == "" {
+		return "", nil
+	}
+
+	// Any prefix of a valid resource ID is itself a valid resource ID.
+	if err := tuple.ValidateResourceID(values[0]); err != nil {
+		return "", status.Errorf(codes.InvalidArgument, "invalid value for %s: %s", ResourceIDPrefixHeader, err)
+	}
+	return values[0], nil
+}
+
 func foundSubjectToResolvedSubject(ctx context.Context, foundSubject *dispatch.FoundSubject, caveatContext map[string]any, ds datastore.CaveatReader) (*v1.ResolvedSubject, error) {
 	var partialCaveat *v1.PartialCaveatInfo
 	permissionship := v1.LookupPermissionship_LOOKUP_PERMISSIONSHIP_HAS_PERMISSION
@@ -7,6 +7,7 @@ import (
 	"io"
 	"math/rand"
 	"sort"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -865,6 +866,64 @@ func TestCheckWithCaveats(t *testing.T) {
 	grpcutil.RequireStatus(t, codes.InvalidArgument, err)
 }
 
+func TestCheckWithinBoundedRelation(t *testing.T) {
+	req := require.New(t)
+	conn, cleanup, _, revision := testserver.NewTestServer(req, testTimedeltas[0], memdb.DisableGC, true,
+		func(ds datastore.Datastore, require *require.Assertions) (datastore.Datastore, datastore.Revision) {
+			return tf.DatastoreFromSchemaAndTestRelationships(ds, `
+				definition user {}
+
+				definition document {
+					relation reader: user
+					permission view = reader.within(expiration)
+				}
+			`, []*core.RelationTuple{
+				tuple.MustWithCaveat(tuple.MustParse("document:first#reader@user:sarah"), "expiration", map[string]any{
+					"expiration": time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+				}),
+				tuple.MustWithCaveat(tuple.MustParse("document:first#reader@user:fred"), "expiration", map[string]any{
+					"expiration": time.Now().Add(-time.Hour).UTC().Format(time.RFC3339),
+				}),
+			}, require)
+		})
+
+	client := v1.NewPermissionsServiceClient(conn)
+	t.Cleanup(cleanup)
+
+	// Relationships of the bounded relation must carry the bound.
+	_, err := v1.NewPermissionsServiceClient(conn).WriteRelationships(context.Background(), &v1.WriteRelationshipsRequest{
+		Updates: []*v1.RelationshipUpdate{{
+			Operation:    v1.RelationshipUpdate_OPERATION_TOUCH,
+			Relationship: tuple.MustToRelationship(tuple.MustParse("document:first#reader@user:tom")),
+		}},
+	})
+	grpcutil.RequireStatus(t, codes.InvalidArgument, err)
+
+	for _, tc := range []struct {
+		userID   string
+		expected v1.CheckPermissionResponse_Permissionship
+	}{
+		{"sarah", v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION},
+		{"fred", v1.CheckPermissionResponse_PERMISSIONSHIP_NO_PERMISSION},
+	} {
+		tc := tc
+		t.Run(tc.userID, func(t *testing.T) {
+			checkResp, err := client.CheckPermission(context.Background(), &v1.CheckPermissionRequest{
+				Consistency: &v1.Consistency{
+					Requirement: &v1.Consistency_AtLeastAsFresh{
+						AtLeastAsFresh: zedtoken.MustNewFromRevision(revision),
+					},
+				},
+				Resource:   obj("document", "first"),
+				Permission: "view",
+				Subject:    sub("user", tc.userID, ""),
+			})
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, checkResp.Permissionship)
+		})
+	}
+}
+
 func TestCheckWithCaveatErrors(t *testing.T) {
 	req := require.New(t)
 	conn, cleanup, _, revision := testserver.NewTestServer(
@@ -1341,6 +1400,83 @@ func TestLookupSubjectsWithCaveatedWildcards(t *testing.T) {
 	require.True(t, found)
 }
 
+func TestLookupSubjectsWithWildcardExclusions(t *testing.T) {
+	req := require.New(t)
+	conn, cleanup, _, revision := testserver.NewTestServer(req, testTimedeltas[0], memdb.DisableGC, true,
+		func(ds datastore.Datastore, require *require.Assertions) (datastore.Datastore, datastore.Revision) {
+			return tf.DatastoreFromSchemaAndTestRelationships(ds, `
+				definition user {}
+
+				definition group {
+					relation member: user:*
+					relation banned: user
+					permission allowed = member - banned
+				}
+
+				definition team {
+					relation member: user:*
+					relation banned: user
+					permission allowed = member - banned
+				}
+
+				definition document {
+					relation viewer: user | group#allowed | team#allowed
+				}
+			`, []*core.RelationTuple{
+				tuple.MustParse("document:first#viewer@user:fred"),
+				tuple.MustParse("document:first#viewer@group:first#allowed"),
+				tuple.MustParse("document:first#viewer@team:second#allowed"),
+				tuple.MustParse("group:first#member@user:*"),
+				tuple.MustParse("group:first#banned@user:tom"),
+				tuple.MustParse("group:first#banned@user:sarah"),
+				tuple.MustParse("group:first#banned@user:fred"),
+				tuple.MustParse("team:second#member@user:*"),
+				tuple.MustParse("team:second#banned@user:tom"),
+				tuple.MustParse("team:second#banned@user:fred"),
+			}, require)
+		})
+
+	client := v1.NewPermissionsServiceClient(conn)
+	t.Cleanup(cleanup)
+
+	lookupClient, err := client.LookupSubjects(context.Background(), &v1.LookupSubjectsRequest{
+		Consistency: &v1.Consistency{
+			Requirement: &v1.Consistency_AtLeastAsFresh{
+				AtLeastAsFresh: zedtoken.MustNewFromRevision(revision),
+			},
+		},
+		Resource:          obj("document", "first"),
+		Permission:        "viewer",
+		SubjectObjectType: "user",
+	})
+	req.NoError(err)
+
+	// The wildcards of the group and team are returned as a single wildcard, excluding only the
+	// subject banned from both and not found concretely.
+	var wildcards []*v1.LookupSubjectsResponse
+	var concrete []string
+	for {
+		resp, err := lookupClient.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		req.NoError(err)
+
+		if resp.Subject.SubjectObjectId == "*" {
+			wildcards = append(wildcards, resp)
+			continue
+		}
+		concrete = append(concrete, resp.Subject.SubjectObjectId)
+	}
+
+	req.Equal([]string{"fred"}, concrete)
+	req.Len(wildcards, 1)
+	req.Equal(v1.LookupPermissionship_LOOKUP_PERMISSIONSHIP_HAS_PERMISSION, wildcards[0].Subject.Permissionship)
+	req.Len(wildcards[0].ExcludedSubjects, 1)
+	req.Equal("tom", wildcards[0].ExcludedSubjects[0].SubjectObjectId)
+	req.Equal([]string{"tom"}, wildcards[0].ExcludedSubjectIds)
+}
+
 type expectedSubject struct {
 	subjectID     string
 	isConditional bool
@@ -1370,3 +1506,191 @@ func randString(length int) string {
 	}
 	return string(b)
 }
+
+func TestLookupResourcesWithResourceIDPrefix(t *testing.T) {
+	req := require.New(t)
+	conn, cleanup, _, revision := testserver.NewTestServer(req, testTimedeltas[0], memdb.DisableGC, true,
+		func(ds datastore.Datastore, require *require.Assertions) (datastore.Datastore, datastore.Revision) {
+			return tf.DatastoreFromSchemaAndTestRelationships(ds, `
+				definition user {}
+
+				definition folder {
+					relation parent: folder
+					relation viewer: user
+					permission view = viewer + parent->view
+				}
+
+				definition document {
+					relation folder: folder
+					relation viewer: user
+					permission view = viewer + folder->view
+				}
+			`, []*core.RelationTuple{
+				tuple.MustParse("folder:root#viewer@user:tom"),
+				tuple.MustParse("folder:team_a#parent@folder:root"),
+				tuple.MustParse("folder:team_a/sub#parent@folder:team_a"),
+				tuple.MustParse("folder:teamXa#parent@folder:root"),
+				tuple.MustParse("document:team_a/first#folder@folder:team_a"),
+				tuple.MustParse("document:team_a/second#viewer@user:tom"),
+				tuple.MustParse("document:team_b/first#folder@folder:root"),
+				tuple.MustParse("document:teamXa/first#viewer@user:tom"),
+			}, require)
+		})
+	t.Cleanup(cleanup)
+
+	client := v1.NewPermissionsServiceClient(conn)
+
+	for _, tc := range []struct {
+		resourceType string
+		prefix       string
+		expectedIDs  []string
+	}{
+		{"document", "", []string{"teamXa/first", "team_a/first", "team_a/second", "team_b/first"}},
+		{"document", "team_a/", []string{"team_a/first", "team_a/second"}},
+		{"document", "team_", []string{"team_a/first", "team_a/second", "team_b/first"}},
+		{"document", "team_c/", nil},
+		{"folder", "team_a", []string{"team_a", "team_a/sub"}},
+		{"folder", "team_a/", []string{"team_a/sub"}},
+	} {
+		tc := tc
+		t.Run(tc.resourceType+"/"+tc.prefix, func(t *testing.T) {
+			ctx := context.Background()
+			if tc.prefix != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, v1svc.ResourceIDPrefixHeader, tc.prefix)
+			}
+
+			cli, err := client.LookupResources(ctx, &v1.LookupResourcesRequest{
+				Consistency: &v1.Consistency{
+					Requirement: &v1.Consistency_AtLeastAsFresh{
+						AtLeastAsFresh: zedtoken.MustNewFromRevision(revision),
+					},
+				},
+				ResourceObjectType: tc.resourceType,
+				Permission:         "view",
+				Subject:            sub("user", "tom", ""),
+			})
+			require.NoError(t, err)
+
+			var foundIDs []string
+			for {
+				res, err := cli.Recv()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+
+				require.NoError(t, err)
+				foundIDs = append(foundIDs, res.ResourceObjectId)
+			}
+
+			sort.Strings(foundIDs)
+			require.Equal(t, tc.expectedIDs, foundIDs)
+		})
+	}
+
+	t.Run("invalid prefix", func(t *testing.T) {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), v1svc.ResourceIDPrefixHeader, "team a")
+		cli, err := client.LookupResources(ctx, &v1.LookupResourcesRequest{
+			ResourceObjectType: "document",
+			Permission:         "view",
+			Subject:            sub("user", "tom", ""),
+		})
+		require.NoError(t, err)
+
+		_, err = cli.Recv()
+		grpcutil.RequireStatus(t, codes.InvalidArgument, err)
+	})
+}
+
+func TestMaximumResultSize(t *testing.T) {
+	req := require.New(t)
+	conn, cleanup, _, revision := testserver.NewTestServer(req, testTimedeltas[0], memdb.DisableGC, true, tf.StandardDatastoreWithData)
+	t.Cleanup(cleanup)
+
+	client := v1.NewPermissionsServiceClient(conn)
+	consistency := &v1.Consistency{
+		Requirement: &v1.Consistency_AtLeastAsFresh{
+			AtLeastAsFresh: zedtoken.MustNewFromRevision(revision),
+		},
+	}
+
+	expand := func(maximum string) (*v1.ExpandPermissionTreeResponse, error) {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), v1svc.MaximumResultSizeHeader, maximum)
+		return client.ExpandPermissionTree(ctx, &v1.ExpandPermissionTreeRequest{
+			Resource:    obj("document", "masterplan"),
+			Permission:  "view",
+			Consistency: consistency,
+		})
+	}
+
+	expanded, err := expand("1000")
+	req.NoError(err)
+	req.NotNil(expanded.TreeRoot)
+
+	_, err = expand("2")
+	grpcutil.RequireStatus(t, codes.ResourceExhausted, err)
+
+	_, err = expand("none")
+	grpcutil.RequireStatus(t, codes.InvalidArgument, err)
+
+	lookup := func(maximum string) ([]string, error) {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), v1svc.MaximumResultSizeHeader, maximum)
+		cli, err := client.LookupResources(ctx, &v1.LookupResourcesRequest{
+			Consistency:        consistency,
+			ResourceObjectType: "document",
+			Permission:         "view",
+			Subject:            sub("user", "legal", ""),
+		})
+		req.NoError(err)
+
+		var foundIDs []string
+		for {
+			res, err := cli.Recv()
+			if errors.Is(err, io.EOF) {
+				return foundIDs, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			foundIDs = append(foundIDs, res.ResourceObjectId)
+		}
+	}
+
+	found, err := lookup("1000")
+	req.NoError(err)
+	req.Len(found, 2)
+
+	exact, err := lookup(strconv.Itoa(len(found)))
+	req.NoError(err)
+	req.ElementsMatch(found, exact)
+
+	_, err = lookup(strconv.Itoa(len(found) - 1))
+	grpcutil.RequireStatus(t, codes.ResourceExhausted, err)
+}
+
+func TestConfiguredMaximumResultSize(t *testing.T) {
+	req := require.New(t)
+	conn, cleanup, _, revision := testserver.NewTestServerWithConfig(req, testTimedeltas[0], memdb.DisableGC, true,
+		testserver.ServerConfig{
+			MaxUpdatesPerWrite:    1000,
+			MaxPreconditionsCount: 1000,
+			MaximumResultSize:     1,
+		},
+		tf.StandardDatastoreWithData)
+	t.Cleanup(cleanup)
+
+	client := v1.NewPermissionsServiceClient(conn)
+	cli, err := client.LookupResources(context.Background(), &v1.LookupResourcesRequest{
+		Consistency: &v1.Consistency{
+			Requirement: &v1.Consistency_AtLeastAsFresh{
+				AtLeastAsFresh: zedtoken.MustNewFromRevision(revision),
+			},
+		},
+		ResourceObjectType: "document",
+		Permission:         "view",
+		Subject:            sub("user", "legal", ""),
+	})
+	req.NoError(err)
+
+	_, err = cli.Recv()
+	grpcutil.RequireStatus(t, codes.ResourceExhausted, err)
+}
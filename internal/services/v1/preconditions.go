@@ -26,8 +26,8 @@ func checkPreconditions(
 		}
 		defer iter.Close()
 
-		first := iter.Next()
-		if first == nil && iter.Err() != nil {
+		first, err := iter.Next()
+		if err != nil {
 			return fmt.Errorf("error reading relationships from iterator: %w", err)
 		}
 		iter.Close()
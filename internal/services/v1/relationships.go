@@ -10,7 +10,11 @@ import (
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
+	"github.com/authzed/spicedb/internal/archive"
+	dscommon "github.com/authzed/spicedb/internal/datastore/common"
 	"github.com/authzed/spicedb/internal/dispatch"
+	"github.com/authzed/spicedb/internal/jobs"
+	"github.com/authzed/spicedb/internal/materialize"
 	"github.com/authzed/spicedb/internal/middleware"
 	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
 	"github.com/authzed/spicedb/internal/middleware/handwrittenvalidation"
@@ -19,11 +23,12 @@ import (
 	"github.com/authzed/spicedb/internal/namespace"
 	"github.com/authzed/spicedb/internal/relationships"
 	"github.com/authzed/spicedb/internal/services/shared"
+	"github.com/authzed/spicedb/internal/tracestore"
 	"github.com/authzed/spicedb/pkg/datastore"
 	"github.com/authzed/spicedb/pkg/middleware/consistency"
 	dispatchv1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+	"github.com/authzed/spicedb/pkg/secrets"
 	"github.com/authzed/spicedb/pkg/tuple"
-	"github.com/authzed/spicedb/pkg/util"
 	"github.com/authzed/spicedb/pkg/zedtoken"
 )
 
@@ -44,6 +49,63 @@ type PermissionsServerConfig struct {
 	// StreamingAPITimeout is the timeout for streaming APIs when no response has been
 	// recently received.
 	StreamingAPITimeout time.Duration
+
+	// StrictRelationshipValidation, if true, validates every update in a WriteRelationships
+	// call, returning the details of all invalid updates rather than only the first, and
+	// requires that the caveats referenced by updates exist.
+	StrictRelationshipValidation bool
+
+	// RelationshipRestoreWindow, if non-zero, is the period after a call to DeleteRelationships
+	// during which the deleted relationships can be restored via the restore token returned in
+	// its response headers. Deleted relationships are retained in DeletedRelationships, which
+	// must purge them once the window has passed.
+	RelationshipRestoreWindow time.Duration
+
+	// RelationshipRestoreTokenKeys are the keys with which restore tokens are signed and verified.
+	// Restoring is only enabled if they are provided along with the restore window.
+	RelationshipRestoreTokenKeys secrets.KeyManager
+
+	// DeletedRelationships is the store in which deleted relationships are retained to be
+	// restored. Restoring is only enabled if it is provided along with the restore window.
+	DeletedRelationships dscommon.DeletedRelationshipStore
+
+	// SchemaRollbackDisabled, if true, disables rolling back the schema via the experimental
+	// service, as is done when writes to the schema are disabled.
+	SchemaRollbackDisabled bool
+
+	// AdditiveOnlySchema, if true, restricts rollbacks of the schema to additive changes, as
+	// writes to the schema are restricted.
+	AdditiveOnlySchema bool
+
+	// MaximumResultSize, if non-zero, is the maximum number of nodes and subjects of the tree
+	// returned by ExpandPermissionTree, and of resources returned by LookupResources, beyond
+	// which the call fails rather than materializing the result in memory.
+	MaximumResultSize uint64
+
+	// MaximumNestingDepth, if non-zero, is the maximum depth of the chains of nested
+	// relationships, each having as its subject the resource and relation of the next, which
+	// WriteRelationships may create.
+	MaximumNestingDepth uint32
+
+	// TraceRecorder, if non-nil, records the debug traces of CheckPermission and LookupResources
+	// requests sampled by it, or for which debug information is requested.
+	TraceRecorder *tracestore.Recorder
+
+	// Materializer, if non-nil, serves the permissions materialized by the server via the
+	// experimental service.
+	Materializer *materialize.Materializer
+
+	// PointInTimeCheckEnabled, if true, enables checking permissions at past times via the
+	// experimental service.
+	PointInTimeCheckEnabled bool
+
+	// Archive, if non-nil, is the archive from which the relationships are loaded when checking
+	// permissions at times preceding the garbage collection window of the datastore.
+	Archive *archive.Archive
+
+	// Jobs, if non-nil, is the manager of the jobs started and managed via the experimental
+	// service.
+	Jobs *jobs.Manager
 }
 
 // NewPermissionsServer creates a PermissionsServiceServer instance.
@@ -56,6 +118,14 @@ func NewPermissionsServer(
 		MaxUpdatesPerWrite:    defaultIfZero(config.MaxUpdatesPerWrite, 1000),
 		MaximumAPIDepth:       defaultIfZero(config.MaximumAPIDepth, 50),
 		StreamingAPITimeout:   defaultIfZero(config.StreamingAPITimeout, 30*time.Second),
+
+		StrictRelationshipValidation: config.StrictRelationshipValidation,
+		RelationshipRestoreWindow:    config.RelationshipRestoreWindow,
+		RelationshipRestoreTokenKeys: config.RelationshipRestoreTokenKeys,
+		DeletedRelationships:         config.DeletedRelationships,
+		MaximumResultSize:            config.MaximumResultSize,
+		MaximumNestingDepth:          config.MaximumNestingDepth,
+		TraceRecorder:                config.TraceRecorder,
 	}
 
 	return &permissionServer{
@@ -109,6 +179,59 @@ func (ps *permissionServer) checkFilterNamespaces(ctx context.Context, filter *v
 	return nil
 }
 
+// aliasOf returns the relation of which the relation of the given object type is an alias, or
+// empty if it is not an alias.
+func aliasOf(ctx context.Context, objectType, relation string, ds datastore.Reader) (string, error) {
+	if relation == "" {
+		return "", nil
+	}
+
+	_, ts, err := namespace.ReadNamespaceAndTypes(ctx, objectType, ds)
+	if err != nil {
+		return "", err
+	}
+	return ts.AliasOf(relation), nil
+}
+
+// filterAliases returns the relations of which the resource and subject relations of the filter
+// are aliases, each empty if not an alias.
+func filterAliases(ctx context.Context, filter *v1.RelationshipFilter, ds datastore.Reader) (resourceAliasOf, subjectAliasOf string, err error) {
+	resourceAliasOf, err = aliasOf(ctx, filter.ResourceType, filter.OptionalRelation, ds)
+	if err != nil {
+		return "", "", err
+	}
+
+	if subjectFilter := filter.OptionalSubjectFilter; subjectFilter != nil && subjectFilter.OptionalRelation != nil {
+		subjectAliasOf, err = aliasOf(ctx, subjectFilter.SubjectType, subjectFilter.OptionalRelation.Relation, ds)
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	return resourceAliasOf, subjectAliasOf, nil
+}
+
+// resolveFilterAliases returns the filter with the aliases which it references replaced by the
+// relations which they alias, under which relationships are written.
+func resolveFilterAliases(ctx context.Context, filter *v1.RelationshipFilter, ds datastore.Reader) (*v1.RelationshipFilter, error) {
+	resourceAliasOf, subjectAliasOf, err := filterAliases(ctx, filter, ds)
+	if err != nil {
+		return nil, err
+	}
+	if resourceAliasOf == "" && subjectAliasOf == "" {
+		return filter, nil
+	}
+
+	resolved := filter.CloneVT()
+	if resourceAliasOf != "" {
+		resolved.OptionalRelation = resourceAliasOf
+	}
+	if subjectAliasOf != "" {
+		resolved.OptionalSubjectFilter.OptionalRelation.Relation = subjectAliasOf
+	}
+	return resolved, nil
+}
+
 func (ps *permissionServer) ReadRelationships(req *v1.ReadRelationshipsRequest, resp v1.PermissionsService_ReadRelationshipsServer) error {
 	ctx := resp.Context()
 	atRevision, revisionReadAt := consistency.MustRevisionFromContext(ctx)
@@ -118,22 +241,44 @@ func (ps *permissionServer) ReadRelationships(req *v1.ReadRelationshipsRequest,
 		return rewriteError(ctx, err)
 	}
 
+	// Aliases are read as the relations which they alias.
+	relationshipFilter, err := resolveFilterAliases(ctx, req.RelationshipFilter, ds)
+	if err != nil {
+		return rewriteError(ctx, err)
+	}
+
+	if err := consistency.SetEvaluatedRevisionHeader(ctx, revisionReadAt); err != nil {
+		return rewriteError(ctx, err)
+	}
+
+	labels, err := labelsFromRequest(ctx, RelationshipLabelFilterHeader)
+	if err != nil {
+		return err
+	}
+
 	usagemetrics.SetInContext(ctx, &dispatchv1.ResponseMeta{
 		DispatchCount: 1,
 	})
 
-	tupleIterator, err := ds.QueryRelationships(ctx, datastore.RelationshipsFilterFromPublicFilter(req.RelationshipFilter))
+	filter := datastore.RelationshipsFilterFromPublicFilter(relationshipFilter)
+	filter.OptionalLabels = labels
+
+	tupleIterator, err := ds.QueryRelationships(ctx, filter)
 	if err != nil {
 		return rewriteError(ctx, err)
 	}
 	defer tupleIterator.Close()
 
-	for tpl := tupleIterator.Next(); tpl != nil; tpl = tupleIterator.Next() {
-		if tupleIterator.Err() != nil {
-			return status.Errorf(codes.Internal, "error when reading tuples: %s", tupleIterator.Err())
+	for {
+		tpl, err := tupleIterator.Next()
+		if err != nil {
+			return status.Errorf(codes.Internal, "error when reading tuples: %s", err)
+		}
+		if tpl == nil {
+			break
 		}
 
-		err := resp.Send(&v1.ReadRelationshipsResponse{
+		err = resp.Send(&v1.ReadRelationshipsResponse{
 			ReadAt:       revisionReadAt,
 			Relationship: tuple.ToRelationship(tpl),
 		})
@@ -163,16 +308,14 @@ func (ps *permissionServer) WriteRelationships(ctx context.Context, req *v1.Writ
 		)
 	}
 
-	// Check for duplicate updates and create the set of caveat names to load.
-	updateRelationshipSet := util.NewSet[string]()
-	for _, update := range req.Updates {
-		tupleStr := tuple.StringRelationshipWithoutCaveat(update.Relationship)
-		if !updateRelationshipSet.Add(tupleStr) {
-			return nil, rewriteError(
-				ctx,
-				NewDuplicateRelationshipErr(update),
-			)
-		}
+	// Check for duplicate or conflicting updates.
+	if duplicates := findDuplicateUpdates(req.Updates); len(duplicates) > 0 {
+		return nil, rewriteError(ctx, NewDuplicateRelationshipErr(duplicates))
+	}
+
+	labels, err := labelsFromRequest(ctx, RelationshipLabelsHeader)
+	if err != nil {
+		return nil, err
 	}
 
 	// Execute the write operation(s).
@@ -186,11 +329,27 @@ func (ps *permissionServer) WriteRelationships(ctx context.Context, req *v1.Writ
 
 		// Validate the updates.
 		tupleUpdates := tuple.UpdateFromRelationshipUpdates(req.Updates)
-		err := relationships.ValidateRelationshipUpdates(ctx, rwt, tupleUpdates)
+		applyLabels(tupleUpdates, labels)
+		validate := relationships.ValidateRelationshipUpdates
+		if ps.config.StrictRelationshipValidation {
+			validate = relationships.ValidateAllRelationshipUpdates
+		}
+
+		err := validate(ctx, rwt, tupleUpdates)
 		if err != nil {
 			return rewriteError(ctx, err)
 		}
 
+		if err := relationships.CheckConstraints(ctx, rwt, tupleUpdates); err != nil {
+			return rewriteError(ctx, err)
+		}
+
+		if ps.config.MaximumNestingDepth > 0 {
+			if err := relationships.CheckNestingDepth(ctx, rwt, tupleUpdates, ps.config.MaximumNestingDepth); err != nil {
+				return rewriteError(ctx, err)
+			}
+		}
+
 		usagemetrics.SetInContext(ctx, &dispatchv1.ResponseMeta{
 			// One request per precondition and one request for the actual writes.
 			DispatchCount: uint32(len(req.OptionalPreconditions)) + 1,
@@ -219,13 +378,32 @@ func (ps *permissionServer) DeleteRelationships(ctx context.Context, req *v1.Del
 		)
 	}
 
+	labels, err := labelsFromRequest(ctx, RelationshipLabelFilterHeader)
+	if err != nil {
+		return nil, err
+	}
+
 	ds := datastoremw.MustFromContext(ctx)
 
+	var restorer *deletionRestorer
+	if ps.config.RelationshipRestoreWindow > 0 && ps.config.RelationshipRestoreTokenKeys != nil && ps.config.DeletedRelationships != nil {
+		restorer = newDeletionRestorer(ps.config.DeletedRelationships, ps.config.RelationshipRestoreTokenKeys)
+	}
+
 	revision, err := ds.ReadWriteTx(ctx, func(rwt datastore.ReadWriteTransaction) error {
 		if err := ps.checkFilterNamespaces(ctx, req.RelationshipFilter, rwt); err != nil {
 			return err
 		}
 
+		// As with writes, deletes must use the relations aliased, rather than their aliases.
+		resourceAliasOf, subjectAliasOf, err := filterAliases(ctx, req.RelationshipFilter, rwt)
+		if err != nil {
+			return err
+		}
+		if resourceAliasOf != "" || subjectAliasOf != "" {
+			return status.Errorf(codes.InvalidArgument, "cannot delete relationships by alias: relationships must be deleted by the relation which the alias references")
+		}
+
 		usagemetrics.SetInContext(ctx, &dispatchv1.ResponseMeta{
 			// One request per precondition and one request for the actual delete.
 			DispatchCount: uint32(len(req.OptionalPreconditions)) + 1,
@@ -235,13 +413,69 @@ func (ps *permissionServer) DeleteRelationships(ctx context.Context, req *v1.Del
 			return err
 		}
 
+		if restorer != nil {
+			if err := restorer.retain(ctx, rwt, req.RelationshipFilter, labels); err != nil {
+				return err
+			}
+		}
+
+		if len(labels) > 0 {
+			return deleteLabelledRelationships(ctx, rwt, req.RelationshipFilter, labels)
+		}
+
 		return rwt.DeleteRelationships(ctx, req.RelationshipFilter)
 	})
 	if err != nil {
+		if restorer != nil {
+			restorer.forget(ctx)
+		}
 		return nil, rewriteError(ctx, err)
 	}
 
+	if restorer != nil {
+		if err := restorer.setHeader(ctx); err != nil {
+			return nil, rewriteError(ctx, err)
+		}
+	}
+
 	return &v1.DeleteRelationshipsResponse{
 		DeletedAt: zedtoken.MustNewFromRevision(revision),
 	}, nil
 }
+
+// findDuplicateUpdates returns the sets of updates referring to the same relationship, ignoring
+// caveats, in order of first appearance.
+func findDuplicateUpdates(updates []*v1.RelationshipUpdate) []DuplicateRelationshipUpdates {
+	indexesByRelationship := make(map[string][]int, len(updates))
+	ordered := make([]string, 0, len(updates))
+	for index, update := range updates {
+		tupleStr := tuple.StringRelationshipWithoutCaveat(update.Relationship)
+		if _, ok := indexesByRelationship[tupleStr]; !ok {
+			ordered = append(ordered, tupleStr)
+		}
+		indexesByRelationship[tupleStr] = append(indexesByRelationship[tupleStr], index)
+	}
+
+	var duplicates []DuplicateRelationshipUpdates
+	for _, tupleStr := range ordered {
+		indexes := indexesByRelationship[tupleStr]
+		if len(indexes) < 2 {
+			continue
+		}
+
+		conflicting := false
+		for _, index := range indexes[1:] {
+			if updates[index].Operation != updates[indexes[0]].Operation {
+				conflicting = true
+				break
+			}
+		}
+
+		duplicates = append(duplicates, DuplicateRelationshipUpdates{
+			Relationship: updates[indexes[0]].Relationship,
+			Indexes:      indexes,
+			Conflicting:  conflicting,
+		})
+	}
+	return duplicates
+}
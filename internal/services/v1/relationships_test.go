@@ -11,12 +11,16 @@ import (
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	"github.com/authzed/grpcutil"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/structpb"
 
 	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/internal/middleware/consistency"
+	v1svc "github.com/authzed/spicedb/internal/services/v1"
 	tf "github.com/authzed/spicedb/internal/testfixtures"
 	"github.com/authzed/spicedb/internal/testserver"
 	core "github.com/authzed/spicedb/pkg/proto/core/v1"
@@ -1068,6 +1072,92 @@ func TestWriteRelationshipsPreconditionsOverLimit(t *testing.T) {
 	require.Contains(err.Error(), "precondition count of 2 is greater than maximum allowed of 1")
 }
 
+func TestWriteRelationshipsStrictValidation(t *testing.T) {
+	require := require.New(t)
+	conn, cleanup, _, _ := testserver.NewTestServerWithConfig(
+		require,
+		testTimedeltas[0],
+		memdb.DisableGC,
+		true,
+		testserver.ServerConfig{
+			MaxPreconditionsCount:        1000,
+			MaxUpdatesPerWrite:           1000,
+			StrictRelationshipValidation: true,
+		},
+		tf.StandardDatastoreWithData,
+	)
+	client := v1.NewPermissionsServiceClient(conn)
+	t.Cleanup(cleanup)
+
+	_, err := client.WriteRelationships(context.Background(), &v1.WriteRelationshipsRequest{
+		Updates: []*v1.RelationshipUpdate{
+			tuple.UpdateToRelationshipUpdate(tuple.Create(tuple.MustParse("document:newdoc#viewer@user:tom"))),
+			tuple.UpdateToRelationshipUpdate(tuple.Create(tuple.MustParse("document:newdoc#viewer@folder:company"))),
+			tuple.UpdateToRelationshipUpdate(tuple.Create(tuple.MustParse("document:newdoc#view@user:tom"))),
+		},
+	})
+	require.Equal(codes.InvalidArgument, status.Code(err))
+
+	var fields []string
+	for _, detail := range status.Convert(err).Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			for _, violation := range badRequest.FieldViolations {
+				fields = append(fields, violation.Field)
+			}
+		}
+	}
+	require.Equal([]string{"updates[1]", "updates[2]"}, fields)
+
+	// Ensure that the valid update was not written either.
+	stream, err := client.ReadRelationships(context.Background(), &v1.ReadRelationshipsRequest{
+		Consistency: &v1.Consistency{
+			Requirement: &v1.Consistency_FullyConsistent{FullyConsistent: true},
+		},
+		RelationshipFilter: &v1.RelationshipFilter{
+			ResourceType:       "document",
+			OptionalResourceId: "newdoc",
+		},
+	})
+	require.NoError(err)
+
+	_, err = stream.Recv()
+	require.ErrorIs(err, io.EOF)
+}
+
+func TestWriteRelationshipsDuplicateUpdates(t *testing.T) {
+	require := require.New(t)
+	conn, cleanup, _, _ := testserver.NewTestServer(require, 0, memdb.DisableGC, true, tf.StandardDatastoreWithData)
+	client := v1.NewPermissionsServiceClient(conn)
+	t.Cleanup(cleanup)
+
+	_, err := client.WriteRelationships(context.Background(), &v1.WriteRelationshipsRequest{
+		Updates: []*v1.RelationshipUpdate{
+			tuple.UpdateToRelationshipUpdate(tuple.Create(tuple.MustParse("document:newdoc#viewer@user:tom"))),
+			tuple.UpdateToRelationshipUpdate(tuple.Touch(tuple.MustParse("document:newdoc#viewer@user:sarah"))),
+			tuple.UpdateToRelationshipUpdate(tuple.Delete(tuple.MustParse("document:newdoc#viewer@user:tom"))),
+			tuple.UpdateToRelationshipUpdate(tuple.Touch(tuple.MustParse("document:newdoc#viewer@user:sarah"))),
+			tuple.UpdateToRelationshipUpdate(tuple.Create(tuple.MustParse("document:newdoc#viewer@user:fred"))),
+		},
+	})
+	require.Equal(codes.InvalidArgument, status.Code(err))
+	require.ErrorContains(err, "conflicting updates at indexes 0, 2")
+	require.ErrorContains(err, "1 other relationship(s)")
+
+	var fields []string
+	for _, detail := range status.Convert(err).Details() {
+		switch detail := detail.(type) {
+		case *errdetails.ErrorInfo:
+			require.Equal(v1.ErrorReason_ERROR_REASON_UPDATES_ON_SAME_RELATIONSHIP.String(), detail.Reason)
+			require.Equal("0, 2", detail.Metadata["update_indexes"])
+		case *errdetails.BadRequest:
+			for _, violation := range detail.FieldViolations {
+				fields = append(fields, violation.Field)
+			}
+		}
+	}
+	require.Equal([]string{"updates[2]", "updates[3]"}, fields)
+}
+
 func TestWriteRelationshipsUpdatesOverLimit(t *testing.T) {
 	require := require.New(t)
 	conn, cleanup, _, _ := testserver.NewTestServerWithConfig(
@@ -1141,3 +1231,120 @@ func standardTuplesWithout(without map[string]struct{}) map[string]struct{} {
 	}
 	return out
 }
+
+func TestReadRelationshipsAndLookupResourcesReturnEvaluatedRevision(t *testing.T) {
+	require := require.New(t)
+	conn, cleanup, ds, revision := testserver.NewTestServer(require, 0, memdb.DisableGC, true, tf.StandardDatastoreWithData)
+	client := v1.NewPermissionsServiceClient(conn)
+	t.Cleanup(cleanup)
+
+	minimizeLatency := &v1.Consistency{
+		Requirement: &v1.Consistency_MinimizeLatency{MinimizeLatency: true},
+	}
+
+	requireEvaluatedAt := func(header metadata.MD) {
+		values := header.Get(string(consistency.EvaluatedAt))
+		require.Len(values, 1)
+
+		evaluatedAt, err := zedtoken.DecodeRevision(&v1.ZedToken{Token: values[0]}, ds)
+		require.NoError(err)
+		require.False(revision.GreaterThan(evaluatedAt))
+	}
+
+	// The token must be returned even when no results are found.
+	readStream, err := client.ReadRelationships(context.Background(), &v1.ReadRelationshipsRequest{
+		Consistency: minimizeLatency,
+		RelationshipFilter: &v1.RelationshipFilter{
+			ResourceType:       tf.DocumentNS.Name,
+			OptionalResourceId: "unknowndoc",
+		},
+	})
+	require.NoError(err)
+	_, err = readStream.Recv()
+	require.ErrorIs(err, io.EOF)
+	header, err := readStream.Header()
+	require.NoError(err)
+	requireEvaluatedAt(header)
+
+	lookupStream, err := client.LookupResources(context.Background(), &v1.LookupResourcesRequest{
+		Consistency:        minimizeLatency,
+		ResourceObjectType: tf.DocumentNS.Name,
+		Permission:         "view",
+		Subject: &v1.SubjectReference{
+			Object: &v1.ObjectReference{ObjectType: "user", ObjectId: "unknownuser"},
+		},
+	})
+	require.NoError(err)
+	_, err = lookupStream.Recv()
+	require.ErrorIs(err, io.EOF)
+	header, err = lookupStream.Header()
+	require.NoError(err)
+	requireEvaluatedAt(header)
+}
+
+func TestRelationshipLabels(t *testing.T) {
+	require := require.New(t)
+	conn, cleanup, _, _ := testserver.NewTestServer(require, 0, memdb.DisableGC, true, tf.StandardDatastoreWithData)
+	client := v1.NewPermissionsServiceClient(conn)
+	t.Cleanup(cleanup)
+
+	write := func(ctx context.Context, relationships ...string) *v1.ZedToken {
+		updates := make([]*v1.RelationshipUpdate, 0, len(relationships))
+		for _, relationship := range relationships {
+			updates = append(updates, tuple.UpdateToRelationshipUpdate(tuple.Touch(tuple.MustParse(relationship))))
+		}
+
+		resp, err := client.WriteRelationships(ctx, &v1.WriteRelationshipsRequest{Updates: updates})
+		require.NoError(err)
+		return resp.WrittenAt
+	}
+
+	read := func(ctx context.Context, token *v1.ZedToken) []string {
+		stream, err := client.ReadRelationships(ctx, &v1.ReadRelationshipsRequest{
+			Consistency: &v1.Consistency{
+				Requirement: &v1.Consistency_AtLeastAsFresh{AtLeastAsFresh: token},
+			},
+			RelationshipFilter: &v1.RelationshipFilter{
+				ResourceType:       tf.DocumentNS.Name,
+				OptionalResourceId: "syncdoc",
+			},
+		})
+		require.NoError(err)
+
+		var found []string
+		for {
+			rel, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			require.NoError(err)
+			found = append(found, tuple.MustRelString(rel.Relationship))
+		}
+		return found
+	}
+
+	synced := metadata.AppendToOutgoingContext(context.Background(), v1svc.RelationshipLabelsHeader, "source=scim-sync,batch=1")
+	write(synced, "document:syncdoc#viewer@user:tom", "document:syncdoc#viewer@user:sarah")
+	token := write(context.Background(), "document:syncdoc#viewer@user:fred")
+
+	filtered := metadata.AppendToOutgoingContext(context.Background(), v1svc.RelationshipLabelFilterHeader, "source=scim-sync")
+	require.ElementsMatch([]string{
+		"document:syncdoc#viewer@user:sarah",
+		"document:syncdoc#viewer@user:tom",
+	}, read(filtered, token))
+	require.Len(read(context.Background(), token), 3)
+
+	deleted, err := client.DeleteRelationships(filtered, &v1.DeleteRelationshipsRequest{
+		RelationshipFilter: &v1.RelationshipFilter{ResourceType: tf.DocumentNS.Name},
+	})
+	require.NoError(err)
+	require.Equal([]string{"document:syncdoc#viewer@user:fred"}, read(context.Background(), deleted.DeletedAt))
+
+	invalid := metadata.AppendToOutgoingContext(context.Background(), v1svc.RelationshipLabelsHeader, "Source")
+	_, err = client.WriteRelationships(invalid, &v1.WriteRelationshipsRequest{
+		Updates: []*v1.RelationshipUpdate{
+			tuple.UpdateToRelationshipUpdate(tuple.Touch(tuple.MustParse("document:syncdoc#viewer@user:tom"))),
+		},
+	})
+	grpcutil.RequireStatus(t, codes.InvalidArgument, err)
+}
@@ -0,0 +1,296 @@
+package v1
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	dscommon "github.com/authzed/spicedb/internal/datastore/common"
+	log "github.com/authzed/spicedb/internal/logging"
+	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	experimentalv1 "github.com/authzed/spicedb/pkg/proto/experimental/v1"
+	implv1 "github.com/authzed/spicedb/pkg/proto/impl/v1"
+	"github.com/authzed/spicedb/pkg/secrets"
+	"github.com/authzed/spicedb/pkg/tuple"
+	"github.com/authzed/spicedb/pkg/zedtoken"
+)
+
+const (
+	// RestoreTokenHeader is the response header in which DeleteRelationships returns the token
+	// with which the deleted relationships can be restored, when a restore window is configured.
+	RestoreTokenHeader = "io.spicedb.restoretoken"
+
+	// restoreBatchSize is the number of relationships recreated per write.
+	restoreBatchSize = 1000
+)
+
+// errInvalidRestoreTokenSignature is returned for restore tokens which were not issued by a
+// server holding the restore token keys, or which have been modified since.
+var errInvalidRestoreTokenSignature = errors.New("invalid signature")
+
+// deletionRestorer retains the relationships removed by a deletion, so that it can be restored
+// within the restore window. The retained relationships are stored apart from those of the
+// datastore, and so are hidden from all reads of relationships until they are restored.
+type deletionRestorer struct {
+	id         string
+	retained   int
+	store      dscommon.DeletedRelationshipStore
+	keyManager secrets.KeyManager
+}
+
+func newDeletionRestorer(store dscommon.DeletedRelationshipStore, keyManager secrets.KeyManager) *deletionRestorer {
+	return &deletionRestorer{id: uuid.NewString(), store: store, keyManager: keyManager}
+}
+
+// retain must be called by the transaction making the deletion, before the relationships are
+// deleted. As the transaction may be retried, the relationships retained by any previous attempt
+// are replaced.
+func (dr *deletionRestorer) retain(ctx context.Context, rwt datastore.ReadWriteTransaction, filter *v1.RelationshipFilter, labels map[string]string) error {
+	dsFilter := datastore.RelationshipsFilterFromPublicFilter(filter)
+	dsFilter.OptionalLabels = labels
+
+	iter, err := rwt.QueryRelationships(ctx, dsFilter)
+	if err != nil {
+		return err
+	}
+	defer iter.Close()
+
+	var relationships [][]byte
+	for {
+		tpl, err := iter.Next()
+		if err != nil {
+			return err
+		}
+		if tpl == nil {
+			break
+		}
+
+		marshalled, err := tpl.MarshalVT()
+		if err != nil {
+			return fmt.Errorf("error encoding deleted relationship: %w", err)
+		}
+		relationships = append(relationships, marshalled)
+	}
+
+	dr.retained = len(relationships)
+	return dr.store.RetainDeletedRelationships(ctx, dr.id, relationships)
+}
+
+// forget removes the retained relationships of a deletion which failed.
+func (dr *deletionRestorer) forget(ctx context.Context) {
+	if err := dr.store.ForgetDeletedRelationships(ctx, dr.id); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Str("deletion", dr.id).Msg("unable to forget the relationships retained for a failed deletion")
+	}
+}
+
+// setHeader sets the restore token of the deletion in the response headers, if it deleted any
+// relationships.
+func (dr *deletionRestorer) setHeader(ctx context.Context) error {
+	if dr.retained == 0 {
+		return nil
+	}
+
+	decoded := &implv1.DecodedRestoreToken{
+		VersionOneof: &implv1.DecodedRestoreToken_V1{
+			V1: &implv1.DecodedRestoreToken_V1RestoreToken{
+				DeletionId:         dr.id,
+				DeletedAtUnixNanos: time.Now().UnixNano(),
+			},
+		},
+	}
+
+	marshalled, err := decoded.MarshalVT()
+	if err != nil {
+		return fmt.Errorf("error encoding restore token: %w", err)
+	}
+
+	key, err := dr.keyManager.PrimaryKey(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to load restore token key: %w", err)
+	}
+	return grpc.SetHeader(ctx, metadata.Pairs(RestoreTokenHeader, encodeRestoreToken(key, marshalled)))
+}
+
+// encodeRestoreToken encodes the marshalled token with its signature under the key, in the form
+// `base64token.base64signature.keyid`, so that the token, and with it the relationships restored,
+// cannot be forged by clients.
+func encodeRestoreToken(key secrets.Key, marshalled []byte) string {
+	return base64.StdEncoding.EncodeToString(marshalled) + "." +
+		base64.StdEncoding.EncodeToString(secrets.Sign(key, marshalled)) + "." +
+		key.ID
+}
+
+func decodeRestoreToken(ctx context.Context, keyManager secrets.KeyManager, encoded string) (*implv1.DecodedRestoreToken_V1RestoreToken, error) {
+	encodedToken, signed, ok := strings.Cut(encoded, ".")
+	if !ok {
+		return nil, errInvalidRestoreTokenSignature
+	}
+	encodedSignature, keyID, ok := strings.Cut(signed, ".")
+	if !ok {
+		return nil, errInvalidRestoreTokenSignature
+	}
+
+	decodedBytes, err := base64.StdEncoding.DecodeString(encodedToken)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.StdEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := keyManager.KeyByID(ctx, keyID)
+	if err != nil {
+		if errors.As(err, &secrets.ErrUnknownKey{}) {
+			return nil, errInvalidRestoreTokenSignature
+		}
+		return nil, status.Errorf(codes.Internal, "unable to load restore token key: %s", err)
+	}
+	if !secrets.Verify(key, decodedBytes, signature) {
+		return nil, errInvalidRestoreTokenSignature
+	}
+
+	decoded := &implv1.DecodedRestoreToken{}
+	if err := decoded.UnmarshalVT(decodedBytes); err != nil {
+		return nil, err
+	}
+
+	v1Token := decoded.GetV1()
+	if v1Token == nil || v1Token.DeletionId == "" {
+		return nil, fmt.Errorf("unknown restore token version")
+	}
+	return v1Token, nil
+}
+
+// RestoreRelationships recreates the relationships retained for the deletion of the restore
+// token, unless they have since been recreated, and then forgets them.
+func (es *experimentalServer) RestoreRelationships(ctx context.Context, req *experimentalv1.RestoreRelationshipsRequest) (*experimentalv1.RestoreRelationshipsResponse, error) {
+	if es.restoreWindow == 0 || es.restoreTokenKeys == nil || es.deletedRelationships == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "restoring deleted relationships is not enabled")
+	}
+
+	token, err := decodeRestoreToken(ctx, es.restoreTokenKeys, req.RestoreToken)
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return nil, err
+		}
+		return nil, status.Errorf(codes.InvalidArgument, "invalid restore token: %s", err)
+	}
+
+	if elapsed := time.Since(time.Unix(0, token.DeletedAtUnixNanos)); elapsed > es.restoreWindow {
+		return nil, status.Errorf(codes.FailedPrecondition, "the restore window of %s has elapsed since the deletion", es.restoreWindow)
+	}
+
+	retained, err := es.deletedRelationships.ReadDeletedRelationships(ctx, token.DeletionId)
+	if err != nil {
+		if errors.Is(err, dscommon.ErrDeletionNotFound) {
+			return nil, status.Errorf(codes.FailedPrecondition, "the deleted relationships have already been restored or purged")
+		}
+		return nil, rewriteError(ctx, err)
+	}
+
+	deleted := make([]*core.RelationTuple, 0, len(retained))
+	for _, marshalled := range retained {
+		tpl := &core.RelationTuple{}
+		if err := tpl.UnmarshalVT(marshalled); err != nil {
+			return nil, status.Errorf(codes.Internal, "unable to decode deleted relationship: %s", err)
+		}
+		deleted = append(deleted, tpl)
+	}
+
+	ds := datastoremw.MustFromContext(ctx)
+
+	var restoredCount uint64
+	revision, err := ds.ReadWriteTx(ctx, func(rwt datastore.ReadWriteTransaction) error {
+		restoredCount = 0
+		for start := 0; start < len(deleted); start += restoreBatchSize {
+			end := start + restoreBatchSize
+			if end > len(deleted) {
+				end = len(deleted)
+			}
+
+			current, err := readExistingRelationships(ctx, rwt, deleted[start:end])
+			if err != nil {
+				return err
+			}
+
+			creates := make([]*core.RelationTupleUpdate, 0, end-start)
+			for _, tpl := range deleted[start:end] {
+				if _, ok := current[tuple.StringWithoutCaveat(tpl)]; !ok {
+					creates = append(creates, tuple.Create(tpl))
+				}
+			}
+
+			if len(creates) == 0 {
+				continue
+			}
+			if err := rwt.WriteRelationships(ctx, creates); err != nil {
+				return err
+			}
+			restoredCount += uint64(len(creates))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, rewriteError(ctx, err)
+	}
+
+	// Restoring again would recreate nothing which was not recreated since, so failing to forget
+	// the relationships only leaves them to be purged at the end of their retention period.
+	if err := es.deletedRelationships.ForgetDeletedRelationships(ctx, token.DeletionId); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Str("deletion", token.DeletionId).Msg("unable to forget restored relationships")
+	}
+
+	return &experimentalv1.RestoreRelationshipsResponse{
+		RestoredAt:    zedtoken.MustNewFromRevision(revision),
+		RestoredCount: restoredCount,
+	}, nil
+}
+
+// readExistingRelationships returns those of the relationships which exist, keyed by their string
+// form without caveats.
+func readExistingRelationships(ctx context.Context, reader datastore.Reader, tuples []*core.RelationTuple) (map[string]struct{}, error) {
+	resourceIDsByType := make(map[string][]string)
+	for _, tpl := range tuples {
+		resourceType := tpl.ResourceAndRelation.Namespace
+		resourceIDsByType[resourceType] = append(resourceIDsByType[resourceType], tpl.ResourceAndRelation.ObjectId)
+	}
+
+	found := make(map[string]struct{})
+	for resourceType, resourceIDs := range resourceIDsByType {
+		iter, err := reader.QueryRelationships(ctx, datastore.RelationshipsFilter{
+			ResourceType:        resourceType,
+			OptionalResourceIds: resourceIDs,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for {
+			tpl, err := iter.Next()
+			if err != nil {
+				iter.Close()
+				return nil, err
+			}
+			if tpl == nil {
+				break
+			}
+
+			found[tuple.StringWithoutCaveat(tpl)] = struct{}{}
+		}
+		iter.Close()
+	}
+	return found, nil
+}
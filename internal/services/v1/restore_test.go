@@ -0,0 +1,187 @@
+package v1_test
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/authzed/grpcutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+
+	dscommon "github.com/authzed/spicedb/internal/datastore/common"
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	v1svc "github.com/authzed/spicedb/internal/services/v1"
+	tf "github.com/authzed/spicedb/internal/testfixtures"
+	"github.com/authzed/spicedb/internal/testserver"
+	"github.com/authzed/spicedb/pkg/datastore"
+	experimentalv1 "github.com/authzed/spicedb/pkg/proto/experimental/v1"
+	"github.com/authzed/spicedb/pkg/secrets"
+	"github.com/authzed/spicedb/pkg/tuple"
+	"github.com/authzed/spicedb/pkg/zedtoken"
+)
+
+func TestRestoreRelationships(t *testing.T) {
+	require := require.New(t)
+	conn, cleanup, _, revision := testserver.NewTestServerWithConfig(
+		require,
+		testTimedeltas[0],
+		memdb.DisableGC,
+		true,
+		testserver.ServerConfig{
+			MaxUpdatesPerWrite:        1000,
+			MaxPreconditionsCount:     1000,
+			RelationshipRestoreWindow: time.Hour,
+			RestoreTokenKeys:          []string{"restore=" + base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))},
+		},
+		tf.StandardDatastoreWithData,
+	)
+	t.Cleanup(cleanup)
+
+	client := v1.NewPermissionsServiceClient(conn)
+	experimentalClient := experimentalv1.NewExperimentalServiceClient(conn)
+
+	var header metadata.MD
+	deleted, err := client.DeleteRelationships(context.Background(), &v1.DeleteRelationshipsRequest{
+		RelationshipFilter: &v1.RelationshipFilter{
+			ResourceType:       "document",
+			OptionalResourceId: "masterplan",
+		},
+	}, grpc.Header(&header))
+	require.NoError(err)
+	require.Len(header.Get(v1svc.RestoreTokenHeader), 1)
+	restoreToken := header.Get(v1svc.RestoreTokenHeader)[0]
+
+	remaining := readAll(require, client, deleted.DeletedAt)
+	for rel := range remaining {
+		require.NotContains(rel, "document:masterplan#")
+	}
+
+	// Recreate one of the deleted relationships before restoring, which must be left as is.
+	_, err = client.WriteRelationships(context.Background(), &v1.WriteRelationshipsRequest{
+		Updates: []*v1.RelationshipUpdate{tuple.UpdateToRelationshipUpdate(tuple.Create(
+			tuple.MustParse("document:masterplan#owner@user:product_manager"),
+		))},
+	})
+	require.NoError(err)
+
+	resp, err := experimentalClient.RestoreRelationships(context.Background(), &experimentalv1.RestoreRelationshipsRequest{
+		RestoreToken: restoreToken,
+	})
+	require.NoError(err)
+	require.Equal(uint64(len(readAll(require, client, zedtoken.MustNewFromRevision(revision)))-len(remaining)-1), resp.RestoredCount)
+	require.Equal(readAll(require, client, zedtoken.MustNewFromRevision(revision)), readAll(require, client, resp.RestoredAt))
+
+	// The deleted relationships are no longer retained once restored.
+	_, err = experimentalClient.RestoreRelationships(context.Background(), &experimentalv1.RestoreRelationshipsRequest{
+		RestoreToken: restoreToken,
+	})
+	grpcutil.RequireStatus(t, codes.FailedPrecondition, err)
+
+	_, err = experimentalClient.RestoreRelationships(context.Background(), &experimentalv1.RestoreRelationshipsRequest{
+		RestoreToken: "invalid",
+	})
+	grpcutil.RequireStatus(t, codes.InvalidArgument, err)
+
+	// Tokens which are unsigned, or signed other than by the server, are rejected.
+	encodedToken, _, _ := strings.Cut(restoreToken, ".")
+	_, err = experimentalClient.RestoreRelationships(context.Background(), &experimentalv1.RestoreRelationshipsRequest{
+		RestoreToken: encodedToken,
+	})
+	grpcutil.RequireStatus(t, codes.InvalidArgument, err)
+
+	marshalled, err := base64.StdEncoding.DecodeString(encodedToken)
+	require.NoError(err)
+	forgedSignature := secrets.Sign(secrets.Key{ID: "restore", Material: []byte("fedcba9876543210fedcba9876543210")}, marshalled)
+	_, err = experimentalClient.RestoreRelationships(context.Background(), &experimentalv1.RestoreRelationshipsRequest{
+		RestoreToken: encodedToken + "." + base64.StdEncoding.EncodeToString(forgedSignature) + ".restore",
+	})
+	grpcutil.RequireStatus(t, codes.InvalidArgument, err)
+}
+
+func TestRestoreRelationshipsPurged(t *testing.T) {
+	require := require.New(t)
+	conn, cleanup, ds, _ := testserver.NewTestServerWithConfig(
+		require,
+		testTimedeltas[0],
+		memdb.DisableGC,
+		true,
+		testserver.ServerConfig{
+			MaxUpdatesPerWrite:        1000,
+			MaxPreconditionsCount:     1000,
+			RelationshipRestoreWindow: time.Hour,
+			RestoreTokenKeys:          []string{"restore=" + base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))},
+		},
+		tf.StandardDatastoreWithData,
+	)
+	t.Cleanup(cleanup)
+
+	client := v1.NewPermissionsServiceClient(conn)
+	experimentalClient := experimentalv1.NewExperimentalServiceClient(conn)
+
+	// Deletions which remove no relationships have nothing to restore.
+	var header metadata.MD
+	_, err := client.DeleteRelationships(context.Background(), &v1.DeleteRelationshipsRequest{
+		RelationshipFilter: &v1.RelationshipFilter{
+			ResourceType:       "document",
+			OptionalResourceId: "unknown",
+		},
+	}, grpc.Header(&header))
+	require.NoError(err)
+	require.Empty(header.Get(v1svc.RestoreTokenHeader))
+
+	_, err = client.DeleteRelationships(context.Background(), &v1.DeleteRelationshipsRequest{
+		RelationshipFilter: &v1.RelationshipFilter{
+			ResourceType:       "document",
+			OptionalResourceId: "masterplan",
+		},
+	}, grpc.Header(&header))
+	require.NoError(err)
+	require.Len(header.Get(v1svc.RestoreTokenHeader), 1)
+
+	store, ok := datastore.Unwrap(ds).(dscommon.DeletedRelationshipStore)
+	require.True(ok)
+
+	// Relationships retained for less than the retention period are kept.
+	purged, err := store.PurgeDeletedRelationships(context.Background(), time.Hour)
+	require.NoError(err)
+	require.Zero(purged)
+
+	purged, err = store.PurgeDeletedRelationships(context.Background(), 0)
+	require.NoError(err)
+	require.NotZero(purged)
+
+	_, err = experimentalClient.RestoreRelationships(context.Background(), &experimentalv1.RestoreRelationshipsRequest{
+		RestoreToken: header.Get(v1svc.RestoreTokenHeader)[0],
+	})
+	grpcutil.RequireStatus(t, codes.FailedPrecondition, err)
+}
+
+func TestRestoreRelationshipsDisabled(t *testing.T) {
+	require := require.New(t)
+	conn, cleanup, _, _ := testserver.NewTestServer(require, testTimedeltas[0], memdb.DisableGC, true, tf.StandardDatastoreWithData)
+	t.Cleanup(cleanup)
+
+	client := v1.NewPermissionsServiceClient(conn)
+	experimentalClient := experimentalv1.NewExperimentalServiceClient(conn)
+
+	var header metadata.MD
+	_, err := client.DeleteRelationships(context.Background(), &v1.DeleteRelationshipsRequest{
+		RelationshipFilter: &v1.RelationshipFilter{
+			ResourceType:       "document",
+			OptionalResourceId: "masterplan",
+		},
+	}, grpc.Header(&header))
+	require.NoError(err)
+	require.Empty(header.Get(v1svc.RestoreTokenHeader))
+
+	_, err = experimentalClient.RestoreRelationships(context.Background(), &experimentalv1.RestoreRelationshipsRequest{
+		RestoreToken: "invalid",
+	})
+	grpcutil.RequireStatus(t, codes.FailedPrecondition, err)
+}
@@ -0,0 +1,43 @@
+package v1
+
+import (
+	"context"
+	"strconv"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// MaximumResultSizeHeader is the request header in which callers of ExpandPermissionTree and
+// LookupResources can specify a maximum result size lower than that of the server.
+const MaximumResultSizeHeader = "io.spicedb.maxresultsize"
+
+// maximumResultSize returns the maximum result size for the request: the lower of the maximum
+// result size of the server and that found in the header of the request, if any. Zero means
+// the result size is unlimited.
+func (ps *permissionServer) maximumResultSize(ctx context.Context) (uint64, error) {
+	return requestMaximumResultSize(ctx, ps.config.MaximumResultSize)
+}
+
+func requestMaximumResultSize(ctx context.Context, maximum uint64) (uint64, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return maximum, nil
+	}
+
+	values := md.Get(MaximumResultSizeHeader)
+	if len(values) == 0 || values[0] == "" {
+		return maximum, nil
+	}
+
+	requested, err := strconv.ParseUint(values[0], 10, 64)
+	if err != nil || requested == 0 {
+		return 0, status.Errorf(codes.InvalidArgument, "invalid value for %s: must be a positive integer", MaximumResultSizeHeader)
+	}
+
+	if maximum == 0 || requested < maximum {
+		return requested, nil
+	}
+	return maximum, nil
+}
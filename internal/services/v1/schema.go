@@ -2,6 +2,7 @@ package v1
 
 import (
 	"context"
+	"sort"
 
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	grpcvalidate "github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/validator"
@@ -18,7 +19,6 @@ import (
 	dispatchv1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
 	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
 	"github.com/authzed/spicedb/pkg/schemadsl/generator"
-	"github.com/authzed/spicedb/pkg/schemadsl/input"
 )
 
 // NewSchemaServer creates a SchemaServiceServer instance.
@@ -63,6 +63,11 @@ func (ss *schemaServer) ReadSchema(ctx context.Context, in *v1.ReadSchemaRequest
 		return nil, status.Errorf(codes.NotFound, "No schema has been defined; please call WriteSchema to start")
 	}
 
+	// The definitions are ordered by name, as datastores list them in differing orders, so that
+	// the schema read is the same from every server.
+	sort.Slice(caveatDefs, func(i, j int) bool { return caveatDefs[i].Definition.Name < caveatDefs[j].Definition.Name })
+	sort.Slice(nsDefs, func(i, j int) bool { return nsDefs[i].Definition.Name < nsDefs[j].Definition.Name })
+
 	schemaDefinitions := make([]compiler.SchemaDefinition, 0, len(nsDefs)+len(caveatDefs))
 	for _, caveatDef := range caveatDefs {
 		schemaDefinitions = append(schemaDefinitions, caveatDef.Definition)
@@ -92,11 +97,7 @@ func (ss *schemaServer) WriteSchema(ctx context.Context, in *v1.WriteSchemaReque
 	ds := datastoremw.MustFromContext(ctx)
 
 	// Compile the schema into the namespace definitions.
-	emptyDefaultPrefix := ""
-	compiled, err := compiler.Compile(compiler.InputSchema{
-		Source:       input.Source("schema"),
-		SchemaString: in.GetSchema(),
-	}, &emptyDefaultPrefix)
+	compiled, err := compileSchemaText(in.GetSchema())
 	if err != nil {
 		return nil, rewriteError(ctx, err)
 	}
@@ -109,7 +110,7 @@ func (ss *schemaServer) WriteSchema(ctx context.Context, in *v1.WriteSchemaReque
 	}
 
 	// Update the schema.
-	_, err = ds.ReadWriteTx(ctx, func(rwt datastore.ReadWriteTransaction) error {
+	_, err = writeSchemaTx(ctx, ds, func(rwt datastore.ReadWriteTransaction) error {
 		applied, err := shared.ApplySchemaChanges(ctx, rwt, validated)
 		if err != nil {
 			return err
@@ -117,7 +118,9 @@ func (ss *schemaServer) WriteSchema(ctx context.Context, in *v1.WriteSchemaReque
 		usagemetrics.SetInContext(ctx, &dispatchv1.ResponseMeta{
 			DispatchCount: applied.TotalOperationCount,
 		})
-		return nil
+
+		_, err = recordSchemaVersion(ctx, rwt, in.GetSchema())
+		return err
 	})
 	if err != nil {
 		return nil, rewriteError(ctx, err)
@@ -0,0 +1,19 @@
+package v1
+
+import (
+	"context"
+
+	experimentalv1 "github.com/authzed/spicedb/pkg/proto/experimental/v1"
+	"github.com/authzed/spicedb/pkg/schemadsl/generator"
+)
+
+// FormatSchema returns the schema formatted canonically, without reading or writing the stored
+// schema.
+func (es *experimentalServer) FormatSchema(ctx context.Context, req *experimentalv1.FormatSchemaRequest) (*experimentalv1.FormatSchemaResponse, error) {
+	formatted, err := generator.FormatSchema(req.Schema, req.SortDefinitions)
+	if err != nil {
+		return nil, rewriteError(ctx, err)
+	}
+
+	return &experimentalv1.FormatSchemaResponse{FormattedSchema: formatted}, nil
+}
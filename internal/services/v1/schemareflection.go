@@ -0,0 +1,133 @@
+package v1
+
+import (
+	"context"
+	"sort"
+
+	"golang.org/x/exp/maps"
+
+	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
+	caveattypes "github.com/authzed/spicedb/pkg/caveats/types"
+	"github.com/authzed/spicedb/pkg/graph"
+	"github.com/authzed/spicedb/pkg/middleware/consistency"
+	nspkg "github.com/authzed/spicedb/pkg/namespace"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	experimentalv1 "github.com/authzed/spicedb/pkg/proto/experimental/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// ReflectSchema returns the definitions and caveats of the schema at the requested revision,
+// along with their doc comments.
+func (es *experimentalServer) ReflectSchema(ctx context.Context, req *experimentalv1.ReflectSchemaRequest) (*experimentalv1.ReflectSchemaResponse, error) {
+	atRevision, readAt := consistency.MustRevisionFromContext(ctx)
+	ds := datastoremw.MustFromContext(ctx).SnapshotReader(atRevision)
+
+	nsDefs, err := ds.ListAllNamespaces(ctx)
+	if err != nil {
+		return nil, rewriteError(ctx, err)
+	}
+
+	caveatDefs, err := ds.ListAllCaveats(ctx)
+	if err != nil {
+		return nil, rewriteError(ctx, err)
+	}
+
+	definitions := make([]*experimentalv1.ReflectionDefinition, 0, len(nsDefs))
+	for _, nsDef := range nsDefs {
+		definition, err := reflectDefinition(nsDef.Definition)
+		if err != nil {
+			return nil, rewriteError(ctx, err)
+		}
+		definitions = append(definitions, definition)
+	}
+	sort.Slice(definitions, func(i, j int) bool { return definitions[i].Name < definitions[j].Name })
+
+	caveats := make([]*experimentalv1.ReflectionCaveat, 0, len(caveatDefs))
+	for _, caveatDef := range caveatDefs {
+		caveat, err := reflectCaveat(caveatDef.Definition)
+		if err != nil {
+			return nil, rewriteError(ctx, err)
+		}
+		caveats = append(caveats, caveat)
+	}
+	sort.Slice(caveats, func(i, j int) bool { return caveats[i].Name < caveats[j].Name })
+
+	return &experimentalv1.ReflectSchemaResponse{
+		ReadAt:      readAt,
+		Definitions: definitions,
+		Caveats:     caveats,
+	}, nil
+}
+
+func reflectDefinition(nsDef *core.NamespaceDefinition) (*experimentalv1.ReflectionDefinition, error) {
+	definition := &experimentalv1.ReflectionDefinition{
+		Name:    nsDef.Name,
+		Comment: nspkg.GetDocumentation(nsDef.Metadata),
+	}
+
+	for _, relation := range nsDef.Relation {
+		// Permissions are the relations which are computed without their own relationships, as
+		// in the generated schema.
+		hasThis, err := graph.HasThis(relation.UsersetRewrite)
+		if err != nil {
+			return nil, err
+		}
+
+		comment := nspkg.GetDocumentation(relation.Metadata)
+		if relation.UsersetRewrite != nil && !hasThis {
+			definition.Permissions = append(definition.Permissions, &experimentalv1.ReflectionPermission{
+				Name:    relation.Name,
+				Comment: comment,
+				AliasOf: nspkg.GetAliasOf(relation),
+			})
+			continue
+		}
+
+		subjectTypes := make([]*experimentalv1.ReflectionSubjectType, 0, len(relation.GetTypeInformation().GetAllowedDirectRelations()))
+		for _, allowed := range relation.GetTypeInformation().GetAllowedDirectRelations() {
+			subjectRelation := allowed.GetRelation()
+			if subjectRelation == tuple.Ellipsis {
+				subjectRelation = ""
+			}
+
+			subjectTypes = append(subjectTypes, &experimentalv1.ReflectionSubjectType{
+				SubjectObjectType:       allowed.Namespace,
+				OptionalSubjectRelation: subjectRelation,
+				Wildcard:                allowed.GetPublicWildcard() != nil,
+				OptionalCaveatName:      allowed.GetRequiredCaveat().GetCaveatName(),
+			})
+		}
+
+		definition.Relations = append(definition.Relations, &experimentalv1.ReflectionRelation{
+			Name:         relation.Name,
+			Comment:      comment,
+			SubjectTypes: subjectTypes,
+		})
+	}
+
+	return definition, nil
+}
+
+func reflectCaveat(caveatDef *core.CaveatDefinition) (*experimentalv1.ReflectionCaveat, error) {
+	parameterNames := maps.Keys(caveatDef.ParameterTypes)
+	sort.Strings(parameterNames)
+
+	parameters := make([]*experimentalv1.ReflectionCaveatParameter, 0, len(parameterNames))
+	for _, name := range parameterNames {
+		decoded, err := caveattypes.DecodeParameterType(caveatDef.ParameterTypes[name])
+		if err != nil {
+			return nil, err
+		}
+
+		parameters = append(parameters, &experimentalv1.ReflectionCaveatParameter{
+			Name: name,
+			Type: decoded.String(),
+		})
+	}
+
+	return &experimentalv1.ReflectionCaveat{
+		Name:       caveatDef.Name,
+		Comment:    nspkg.GetDocumentation(caveatDef.Metadata),
+		Parameters: parameters,
+	}, nil
+}
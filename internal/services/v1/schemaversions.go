@@ -0,0 +1,298 @@
+package v1
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"time"
+
+	grpcauth "github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/authzed/spicedb/internal/caveats"
+	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
+	"github.com/authzed/spicedb/internal/middleware/usagemetrics"
+	"github.com/authzed/spicedb/internal/namespace"
+	"github.com/authzed/spicedb/internal/services/shared"
+	"github.com/authzed/spicedb/pkg/datastore"
+	"github.com/authzed/spicedb/pkg/middleware/consistency"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	dispatchv1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+	experimentalv1 "github.com/authzed/spicedb/pkg/proto/experimental/v1"
+	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
+	"github.com/authzed/spicedb/pkg/schemadsl/input"
+	"github.com/authzed/spicedb/pkg/zedtoken"
+)
+
+const (
+	// writerFingerprintLength is the number of hex characters of the digest of the caller's
+	// token by which the writers of schema versions are identified.
+	writerFingerprintLength = 16
+
+	// schemaWriteAttempts is the number of times a write of the schema is attempted whilst
+	// concurrent writes record the version it would record.
+	schemaWriteAttempts = 3
+)
+
+// schemaWriter identifies the caller by a fingerprint of its token, which identifies callers
+// with distinct tokens without recording the tokens themselves.
+func schemaWriter(ctx context.Context) string {
+	token, err := grpcauth.AuthFromMD(ctx, "bearer")
+	if err != nil || token == "" {
+		return ""
+	}
+
+	digest := sha256.Sum256([]byte(token))
+	return "sha256:" + hex.EncodeToString(digest[:])[:writerFingerprintLength]
+}
+
+// writeSchemaTx runs a transaction writing the schema, and recording its version, retrying it if
+// a concurrent write recorded the same version.
+func writeSchemaTx(ctx context.Context, ds datastore.Datastore, fn datastore.TxUserFunc) (datastore.Revision, error) {
+	for attempt := 1; ; attempt++ {
+		revision, err := ds.ReadWriteTx(ctx, fn)
+		if attempt < schemaWriteAttempts && errors.As(err, &datastore.ErrSchemaVersionExists{}) {
+			continue
+		}
+		return revision, err
+	}
+}
+
+// recordSchemaVersion records the schema text as the version following the most recent one,
+// returning its number.
+func recordSchemaVersion(ctx context.Context, rwt datastore.ReadWriteTransaction, schemaText string) (uint64, error) {
+	latest, err := rwt.LatestSchemaVersion(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	next := latest + 1
+
+	if err := rwt.WriteSchemaVersion(ctx, &core.SchemaVersion{
+		Version:    next,
+		SchemaText: schemaText,
+		Writer:     schemaWriter(ctx),
+		WrittenAt:  timestamppb.New(time.Now()),
+	}); err != nil {
+		return 0, err
+	}
+	return next, nil
+}
+
+// ListSchemaVersions returns the recorded versions of the schema.
+func (es *experimentalServer) ListSchemaVersions(ctx context.Context, req *experimentalv1.ListSchemaVersionsRequest) (*experimentalv1.ListSchemaVersionsResponse, error) {
+	atRevision, readAt := consistency.MustRevisionFromContext(ctx)
+	versions, err := datastoremw.MustFromContext(ctx).SnapshotReader(atRevision).ListSchemaVersions(ctx)
+	if err != nil {
+		return nil, rewriteError(ctx, err)
+	}
+
+	usagemetrics.SetInContext(ctx, &dispatchv1.ResponseMeta{
+		DispatchCount: 1,
+	})
+
+	converted := make([]*experimentalv1.SchemaVersion, 0, len(versions))
+	for _, version := range versions {
+		converted = append(converted, &experimentalv1.SchemaVersion{
+			Version:    version.Version,
+			SchemaText: version.SchemaText,
+			Writer:     version.Writer,
+			WrittenAt:  version.WrittenAt,
+		})
+	}
+
+	return &experimentalv1.ListSchemaVersionsResponse{
+		ReadAt:   readAt,
+		Versions: converted,
+	}, nil
+}
+
+// DiffSchemaVersions returns the changes made to each definition of the schema from one
+// recorded version to another.
+func (es *experimentalServer) DiffSchemaVersions(ctx context.Context, req *experimentalv1.DiffSchemaVersionsRequest) (*experimentalv1.DiffSchemaVersionsResponse, error) {
+	atRevision, readAt := consistency.MustRevisionFromContext(ctx)
+	versions, err := datastoremw.MustFromContext(ctx).SnapshotReader(atRevision).ListSchemaVersions(ctx)
+	if err != nil {
+		return nil, rewriteError(ctx, err)
+	}
+
+	from, err := compileSchemaVersion(versions, req.FromVersion)
+	if err != nil {
+		return nil, rewriteError(ctx, err)
+	}
+
+	to, err := compileSchemaVersion(versions, req.ToVersion)
+	if err != nil {
+		return nil, rewriteError(ctx, err)
+	}
+
+	deltas, err := diffCompiledSchemas(from, to)
+	if err != nil {
+		return nil, rewriteError(ctx, err)
+	}
+
+	usagemetrics.SetInContext(ctx, &dispatchv1.ResponseMeta{
+		DispatchCount: 1,
+	})
+
+	return &experimentalv1.DiffSchemaVersionsResponse{
+		ReadAt: readAt,
+		Deltas: deltas,
+	}, nil
+}
+
+// RollbackSchema writes the schema of a recorded version, subject to the same validation as
+// any other write of the schema, and records it as a new version.
+func (es *experimentalServer) RollbackSchema(ctx context.Context, req *experimentalv1.RollbackSchemaRequest) (*experimentalv1.RollbackSchemaResponse, error) {
+	if es.schemaRollbackDisabled {
+		return nil, status.Errorf(codes.FailedPrecondition, "writes to the schema are disabled")
+	}
+
+	ds := datastoremw.MustFromContext(ctx)
+	headRevision, err := ds.HeadRevision(ctx)
+	if err != nil {
+		return nil, rewriteError(ctx, err)
+	}
+
+	versions, err := ds.SnapshotReader(headRevision).ListSchemaVersions(ctx)
+	if err != nil {
+		return nil, rewriteError(ctx, err)
+	}
+
+	target, err := findSchemaVersion(versions, req.Version)
+	if err != nil {
+		return nil, rewriteError(ctx, err)
+	}
+
+	compiled, err := compileSchemaText(target.SchemaText)
+	if err != nil {
+		return nil, rewriteError(ctx, err)
+	}
+
+	validated, err := shared.ValidateSchemaChanges(ctx, compiled, es.additiveOnlySchema)
+	if err != nil {
+		return nil, rewriteError(ctx, err)
+	}
+
+	var version uint64
+	revision, err := writeSchemaTx(ctx, ds, func(rwt datastore.ReadWriteTransaction) error {
+		applied, err := shared.ApplySchemaChanges(ctx, rwt, validated)
+		if err != nil {
+			return err
+		}
+		usagemetrics.SetInContext(ctx, &dispatchv1.ResponseMeta{
+			DispatchCount: applied.TotalOperationCount,
+		})
+
+		version, err = recordSchemaVersion(ctx, rwt, target.SchemaText)
+		return err
+	})
+	if err != nil {
+		return nil, rewriteError(ctx, err)
+	}
+
+	return &experimentalv1.RollbackSchemaResponse{
+		WrittenAt: zedtoken.MustNewFromRevision(revision),
+		Version:   version,
+	}, nil
+}
+
+func findSchemaVersion(versions []*core.SchemaVersion, version uint64) (*core.SchemaVersion, error) {
+	for _, found := range versions {
+		if found.Version == version {
+			return found, nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "schema version %d not found", version)
+}
+
+func compileSchemaVersion(versions []*core.SchemaVersion, version uint64) (*compiler.CompiledSchema, error) {
+	found, err := findSchemaVersion(versions, version)
+	if err != nil {
+		return nil, err
+	}
+	return compileSchemaText(found.SchemaText)
+}
+
+func compileSchemaText(schemaText string) (*compiler.CompiledSchema, error) {
+	emptyDefaultPrefix := ""
+	return compiler.Compile(compiler.InputSchema{
+		Source:       input.Source("schema"),
+		SchemaString: schemaText,
+	}, &emptyDefaultPrefix)
+}
+
+// diffCompiledSchemas returns the deltas of each object definition and caveat between two
+// schemas, ordered by the name of the definition.
+func diffCompiledSchemas(from, to *compiler.CompiledSchema) ([]*experimentalv1.SchemaDelta, error) {
+	fromObjects := make(map[string]*core.NamespaceDefinition, len(from.ObjectDefinitions))
+	fromCaveats := make(map[string]*core.CaveatDefinition, len(from.CaveatDefinitions))
+	toObjects := make(map[string]*core.NamespaceDefinition, len(to.ObjectDefinitions))
+	toCaveats := make(map[string]*core.CaveatDefinition, len(to.CaveatDefinitions))
+
+	names := make(map[string]struct{})
+	for _, def := range from.ObjectDefinitions {
+		fromObjects[def.Name] = def
+		names[def.Name] = struct{}{}
+	}
+	for _, def := range to.ObjectDefinitions {
+		toObjects[def.Name] = def
+		names[def.Name] = struct{}{}
+	}
+	for _, def := range from.CaveatDefinitions {
+		fromCaveats[def.Name] = def
+		names[def.Name] = struct{}{}
+	}
+	for _, def := range to.CaveatDefinitions {
+		toCaveats[def.Name] = def
+		names[def.Name] = struct{}{}
+	}
+
+	sortedNames := make([]string, 0, len(names))
+	for name := range names {
+		sortedNames = append(sortedNames, name)
+	}
+	sort.Strings(sortedNames)
+
+	var deltas []*experimentalv1.SchemaDelta
+	for _, name := range sortedNames {
+		if fromObjects[name] != nil || toObjects[name] != nil {
+			diff, err := namespace.DiffNamespaces(fromObjects[name], toObjects[name])
+			if err != nil {
+				return nil, err
+			}
+
+			for _, delta := range diff.Deltas() {
+				schemaDelta := &experimentalv1.SchemaDelta{
+					DefinitionName: name,
+					Kind:           string(delta.Type),
+					MemberName:     delta.RelationName,
+				}
+				if delta.AllowedType != nil {
+					schemaDelta.AllowedType = namespace.SourceForAllowedRelation(delta.AllowedType)
+				}
+				deltas = append(deltas, schemaDelta)
+			}
+		}
+
+		if fromCaveats[name] != nil || toCaveats[name] != nil {
+			diff, err := caveats.DiffCaveats(fromCaveats[name], toCaveats[name])
+			if err != nil {
+				return nil, err
+			}
+
+			for _, delta := range diff.Deltas() {
+				deltas = append(deltas, &experimentalv1.SchemaDelta{
+					DefinitionName: name,
+					Kind:           string(delta.Type),
+					MemberName:     delta.ParameterName,
+				})
+			}
+		}
+	}
+	return deltas, nil
+}
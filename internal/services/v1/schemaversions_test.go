@@ -0,0 +1,145 @@
+package v1_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/authzed/grpcutil"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	tf "github.com/authzed/spicedb/internal/testfixtures"
+	"github.com/authzed/spicedb/internal/testserver"
+	experimentalv1 "github.com/authzed/spicedb/pkg/proto/experimental/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+func TestSchemaVersions(t *testing.T) {
+	require := require.New(t)
+	conn, cleanup, _, _ := testserver.NewTestServer(require, 0, memdb.DisableGC, false, tf.EmptyDatastore)
+	t.Cleanup(cleanup)
+
+	schemaClient := v1.NewSchemaServiceClient(conn)
+	permissionsClient := v1.NewPermissionsServiceClient(conn)
+	experimentalClient := experimentalv1.NewExperimentalServiceClient(conn)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer sometoken")
+
+	firstSchema := `definition user {}
+
+definition document {
+	relation viewer: user
+	permission view = viewer
+}`
+	_, err := schemaClient.WriteSchema(ctx, &v1.WriteSchemaRequest{Schema: firstSchema})
+	require.NoError(err)
+
+	secondSchema := `definition user {}
+
+definition document {
+	relation viewer: user
+	relation editor: user
+	permission view = viewer + editor
+}`
+	_, err = schemaClient.WriteSchema(ctx, &v1.WriteSchemaRequest{Schema: secondSchema})
+	require.NoError(err)
+
+	listed, err := experimentalClient.ListSchemaVersions(ctx, &experimentalv1.ListSchemaVersionsRequest{
+		Consistency: &v1.Consistency{Requirement: &v1.Consistency_FullyConsistent{FullyConsistent: true}},
+	})
+	require.NoError(err)
+	require.Len(listed.Versions, 2)
+	require.Equal(uint64(1), listed.Versions[0].Version)
+	require.Equal(firstSchema, listed.Versions[0].SchemaText)
+	require.Equal(uint64(2), listed.Versions[1].Version)
+	require.Equal(secondSchema, listed.Versions[1].SchemaText)
+	require.Regexp("^sha256:[0-9a-f]{16}$", listed.Versions[0].Writer)
+	require.NotContains(listed.Versions[0].Writer, "sometoken")
+	require.NotNil(listed.Versions[1].WrittenAt)
+
+	diff, err := experimentalClient.DiffSchemaVersions(ctx, &experimentalv1.DiffSchemaVersionsRequest{
+		Consistency: &v1.Consistency{Requirement: &v1.Consistency_FullyConsistent{FullyConsistent: true}},
+		FromVersion: 1,
+		ToVersion:   2,
+	})
+	require.NoError(err)
+	require.Len(diff.Deltas, 2)
+	require.Equal("document", diff.Deltas[0].DefinitionName)
+	require.Equal("added-relation", diff.Deltas[0].Kind)
+	require.Equal("editor", diff.Deltas[0].MemberName)
+	require.Equal("changed-permission-implementation", diff.Deltas[1].Kind)
+	require.Equal("view", diff.Deltas[1].MemberName)
+
+	_, err = experimentalClient.DiffSchemaVersions(ctx, &experimentalv1.DiffSchemaVersionsRequest{
+		FromVersion: 1,
+		ToVersion:   3,
+	})
+	grpcutil.RequireStatus(t, codes.NotFound, err)
+
+	// Rolling back is refused whilst relationships exist for the relation it removes.
+	editor := tuple.MustParse("document:firstdoc#editor@user:tom")
+	_, err = permissionsClient.WriteRelationships(ctx, &v1.WriteRelationshipsRequest{
+		Updates: []*v1.RelationshipUpdate{tuple.UpdateToRelationshipUpdate(tuple.Create(editor))},
+	})
+	require.NoError(err)
+
+	_, err = experimentalClient.RollbackSchema(ctx, &experimentalv1.RollbackSchemaRequest{Version: 1})
+	grpcutil.RequireStatus(t, codes.InvalidArgument, err)
+
+	_, err = permissionsClient.DeleteRelationships(ctx, &v1.DeleteRelationshipsRequest{
+		RelationshipFilter: &v1.RelationshipFilter{ResourceType: "document"},
+	})
+	require.NoError(err)
+
+	rolledBack, err := experimentalClient.RollbackSchema(ctx, &experimentalv1.RollbackSchemaRequest{Version: 1})
+	require.NoError(err)
+	require.Equal(uint64(3), rolledBack.Version)
+
+	read, err := schemaClient.ReadSchema(ctx, &v1.ReadSchemaRequest{})
+	require.NoError(err)
+	require.NotContains(read.SchemaText, "editor")
+
+	listed, err = experimentalClient.ListSchemaVersions(ctx, &experimentalv1.ListSchemaVersionsRequest{
+		Consistency: &v1.Consistency{Requirement: &v1.Consistency_AtLeastAsFresh{AtLeastAsFresh: rolledBack.WrittenAt}},
+	})
+	require.NoError(err)
+	require.Len(listed.Versions, 3)
+	require.Equal(firstSchema, listed.Versions[2].SchemaText)
+
+	_, err = experimentalClient.RollbackSchema(ctx, &experimentalv1.RollbackSchemaRequest{Version: 4})
+	grpcutil.RequireStatus(t, codes.NotFound, err)
+}
+
+func TestConcurrentSchemaWritesRecordConsecutiveVersions(t *testing.T) {
+	require := require.New(t)
+	conn, cleanup, _, _ := testserver.NewTestServer(require, 0, memdb.DisableGC, false, tf.EmptyDatastore)
+	t.Cleanup(cleanup)
+
+	schemaClient := v1.NewSchemaServiceClient(conn)
+	experimentalClient := experimentalv1.NewExperimentalServiceClient(conn)
+
+	const writers = 5
+	var g errgroup.Group
+	for i := 0; i < writers; i++ {
+		schema := fmt.Sprintf("definition user {}\n\ndefinition document%d {}", i)
+		g.Go(func() error {
+			_, err := schemaClient.WriteSchema(context.Background(), &v1.WriteSchemaRequest{Schema: schema})
+			return err
+		})
+	}
+	require.NoError(g.Wait())
+
+	listed, err := experimentalClient.ListSchemaVersions(context.Background(), &experimentalv1.ListSchemaVersionsRequest{
+		Consistency: &v1.Consistency{Requirement: &v1.Consistency_FullyConsistent{FullyConsistent: true}},
+	})
+	require.NoError(err)
+	require.Len(listed.Versions, writers)
+	for index, version := range listed.Versions {
+		require.Equal(uint64(index+1), version.Version)
+	}
+}
@@ -0,0 +1,128 @@
+package v1
+
+import (
+	"github.com/authzed/spicedb/internal/dispatch"
+	"github.com/authzed/spicedb/internal/graph"
+	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
+	"github.com/authzed/spicedb/internal/middleware/usagemetrics"
+	"github.com/authzed/spicedb/internal/namespace"
+	"github.com/authzed/spicedb/pkg/middleware/consistency"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	dispatchv1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+	experimentalv1 "github.com/authzed/spicedb/pkg/proto/experimental/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// pendingFragment is a subject set found in the leaves of a fragment, yet to be expanded.
+type pendingFragment struct {
+	parentID   uint32
+	subjectSet *core.ObjectAndRelation
+}
+
+// StreamExpandPermissionTree expands the permission, and then each of the subject sets found in
+// the leaves of its expansion, breadth first, sending each shallow expansion as it is
+// dispatched. Every subject set is expanded at most once, which also ends the expansion of
+// recursive subject sets.
+func (es *experimentalServer) StreamExpandPermissionTree(req *experimentalv1.StreamExpandPermissionTreeRequest, resp experimentalv1.ExperimentalService_StreamExpandPermissionTreeServer) error {
+	ctx := resp.Context()
+	atRevision, expandedAt := consistency.MustRevisionFromContext(ctx)
+	ds := datastoremw.MustFromContext(ctx).SnapshotReader(atRevision)
+
+	err := namespace.CheckNamespaceAndRelation(ctx, req.Resource.ObjectType, req.Permission, false, ds)
+	if err != nil {
+		return rewriteError(ctx, err)
+	}
+
+	maximumResultSize, err := requestMaximumResultSize(ctx, es.maximumResultSize)
+	if err != nil {
+		return rewriteError(ctx, err)
+	}
+
+	respMetadata := &dispatchv1.ResponseMeta{}
+	usagemetrics.SetInContext(ctx, respMetadata)
+
+	root := &core.ObjectAndRelation{
+		Namespace: req.Resource.ObjectType,
+		ObjectId:  req.Resource.ObjectId,
+		Relation:  req.Permission,
+	}
+
+	expanded := map[string]struct{}{tuple.StringONR(root): {}}
+	pending := []pendingFragment{{subjectSet: root}}
+
+	var fragmentID uint32
+	var resultSize uint64
+	for len(pending) > 0 {
+		next := pending[0]
+		pending = pending[1:]
+
+		expandResp, err := es.dispatch.DispatchExpand(ctx, &dispatchv1.DispatchExpandRequest{
+			Metadata: &dispatchv1.ResolverMeta{
+				AtRevision:     atRevision.String(),
+				DepthRemaining: es.maximumAPIDepth,
+			},
+			ResourceAndRelation: next.subjectSet,
+			ExpansionMode:       dispatchv1.DispatchExpandRequest_SHALLOW,
+			MaximumResultSize:   maximumResultSize,
+		})
+		if expandResp != nil {
+			dispatch.AddResponseMetadata(respMetadata, expandResp.Metadata)
+		}
+		if err != nil {
+			return rewriteError(ctx, err)
+		}
+
+		// The maximum result size applies to all of the fragments together, as it does to the
+		// single tree returned by ExpandPermissionTree.
+		resultSize += graph.ExpansionTreeSize(expandResp.TreeNode)
+		if maximumResultSize > 0 && resultSize > maximumResultSize {
+			return rewriteError(ctx, graph.NewResultTooLargeErr("expand", maximumResultSize))
+		}
+
+		fragmentID++
+		if err := resp.Send(&experimentalv1.StreamExpandPermissionTreeResponse{
+			ExpandedAt:       expandedAt,
+			FragmentId:       fragmentID,
+			ParentFragmentId: next.parentID,
+			Tree:             TranslateExpansionTree(expandResp.TreeNode),
+		}); err != nil {
+			return err
+		}
+
+		for _, subjectSet := range leafSubjectSets(expandResp.TreeNode) {
+			key := tuple.StringONR(subjectSet)
+			if _, ok := expanded[key]; ok {
+				continue
+			}
+			expanded[key] = struct{}{}
+			pending = append(pending, pendingFragment{parentID: fragmentID, subjectSet: subjectSet})
+		}
+	}
+
+	return nil
+}
+
+// leafSubjectSets returns the subject sets found in the leaves of the expansion tree, in the
+// order in which they are found.
+func leafSubjectSets(node *core.RelationTupleTreeNode) []*core.ObjectAndRelation {
+	switch typed := node.NodeType.(type) {
+	case *core.RelationTupleTreeNode_IntermediateNode:
+		var subjectSets []*core.ObjectAndRelation
+		for _, child := range typed.IntermediateNode.ChildNodes {
+			subjectSets = append(subjectSets, leafSubjectSets(child)...)
+		}
+		return subjectSets
+
+	case *core.RelationTupleTreeNode_LeafNode:
+		var subjectSets []*core.ObjectAndRelation
+		for _, subject := range typed.LeafNode.Subjects {
+			if subject.Subject.Relation != tuple.Ellipsis {
+				subjectSets = append(subjectSets, subject.Subject)
+			}
+		}
+		return subjectSets
+
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,50 @@
+package v1
+
+import (
+	"context"
+
+	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
+	"github.com/authzed/spicedb/internal/namespace"
+	"github.com/authzed/spicedb/pkg/middleware/consistency"
+	experimentalv1 "github.com/authzed/spicedb/pkg/proto/experimental/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// ReflectPermissionSubjectTypes returns the types of subjects which can possibly hold the
+// permission, as computed from the type system of the schema at the requested revision.
+func (es *experimentalServer) ReflectPermissionSubjectTypes(ctx context.Context, req *experimentalv1.ReflectPermissionSubjectTypesRequest) (*experimentalv1.ReflectPermissionSubjectTypesResponse, error) {
+	atRevision, readAt := consistency.MustRevisionFromContext(ctx)
+	ds := datastoremw.MustFromContext(ctx).SnapshotReader(atRevision)
+
+	_, ts, err := namespace.ReadNamespaceAndTypes(ctx, req.ResourceObjectType, ds)
+	if err != nil {
+		return nil, rewriteError(ctx, err)
+	}
+
+	possible, err := ts.PossibleSubjectTypes(ctx, req.Permission)
+	if err != nil {
+		return nil, rewriteError(ctx, err)
+	}
+
+	subjectTypes := make([]*experimentalv1.PermissionSubjectType, 0, len(possible))
+	for _, subjectType := range possible {
+		subjectRelation := subjectType.SubjectType.Relation
+		if subjectRelation == tuple.Ellipsis {
+			subjectRelation = ""
+		}
+
+		subjectTypes = append(subjectTypes, &experimentalv1.PermissionSubjectType{
+			SubjectObjectType:       subjectType.SubjectType.Namespace,
+			OptionalSubjectRelation: subjectRelation,
+			Concrete:                subjectType.Concrete,
+			Wildcard:                subjectType.Wildcard,
+			Caveats:                 subjectType.Caveats,
+			RequiresCaveat:          subjectType.RequiresCaveat,
+		})
+	}
+
+	return &experimentalv1.ReflectPermissionSubjectTypesResponse{
+		ReadAt:       readAt,
+		SubjectTypes: subjectTypes,
+	}, nil
+}
@@ -0,0 +1,70 @@
+package v1
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/authzed/spicedb/internal/tracestore"
+	"github.com/authzed/spicedb/pkg/middleware/requestid"
+	dispatch "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+)
+
+// recordTrace records the trace of a request with the trace recorder. The request is recorded
+// as given, so requests holding sensitive data should only be traced where the trace store is
+// suitably protected.
+func (ps *permissionServer) recordTrace(
+	ctx context.Context,
+	method string,
+	start time.Time,
+	req proto.Message,
+	debugInformation []byte,
+	responseMeta *dispatch.ResponseMeta,
+	err error,
+	extraMetadata map[string]string,
+) {
+	trace := tracestore.Trace{
+		RequestID:        traceRequestID(ctx),
+		Method:           "authzed.api.v1.PermissionsService/" + method,
+		RecordedAt:       start.UTC(),
+		Duration:         time.Since(start),
+		DebugInformation: debugInformation,
+		Metadata: map[string]string{
+			"dispatch_count":        strconv.FormatUint(uint64(responseMeta.GetDispatchCount()), 10),
+			"cached_dispatch_count": strconv.FormatUint(uint64(responseMeta.GetCachedDispatchCount()), 10),
+			"depth_required":        strconv.FormatUint(uint64(responseMeta.GetDepthRequired()), 10),
+		},
+	}
+	for key, value := range extraMetadata {
+		trace.Metadata[key] = value
+	}
+
+	if marshaled, merr := protojson.Marshal(req); merr == nil {
+		trace.Request = marshaled
+	}
+	if evaluations := responseMeta.GetDebugInfo().GetCaveatEvaluations(); len(evaluations) > 0 {
+		if marshaled, merr := MarshalCaveatEvaluations(evaluations); merr == nil {
+			trace.CaveatEvaluations = marshaled
+		}
+	}
+	if err != nil {
+		trace.Error = err.Error()
+	}
+
+	ps.config.TraceRecorder.Record(ctx, trace)
+}
+
+// traceRequestID returns the ID of the request, as set by the requestid middleware, or a new ID.
+func traceRequestID(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if requestIDs := md.Get(requestid.RequestIDMetadataKey); len(requestIDs) > 0 && requestIDs[0] != "" {
+			return requestIDs[0]
+		}
+	}
+	return uuid.NewString()
+}
@@ -1,11 +1,14 @@
 package v1
 
 import (
+	"context"
 	"errors"
+	"strconv"
 
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	grpcvalidate "github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/validator"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 
 	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
@@ -18,6 +21,12 @@ import (
 	"github.com/authzed/spicedb/pkg/zedtoken"
 )
 
+// WatchPerChangeHeader is the request header in which callers of Watch can ask for each change to
+// be sent in its own response, with a cursor positioned after the change within its revision. As
+// the changes of a revision are always emitted in the same order, resuming from such a cursor
+// emits exactly the changes which follow it.
+const WatchPerChangeHeader = "io.spicedb.watchperchange"
+
 type watchServer struct {
 	v1.UnimplementedWatchServiceServer
 	shared.WithStreamServiceSpecificInterceptor
@@ -42,40 +51,55 @@ func (ws *watchServer) Watch(req *v1.WatchRequest, stream v1.WatchService_WatchS
 		objectTypesMap[objectType] = struct{}{}
 	}
 
-	var afterRevision datastore.Revision
+	perChange, err := watchPerChange(ctx)
+	if err != nil {
+		return err
+	}
+
+	var position zedtoken.WatchPosition
 	if req.OptionalStartCursor != nil && req.OptionalStartCursor.Token != "" {
-		decodedRevision, err := zedtoken.DecodeRevision(req.OptionalStartCursor, ds)
+		decodedPosition, err := zedtoken.DecodeWatchPosition(req.OptionalStartCursor, ds)
 		if err != nil {
 			return status.Errorf(codes.InvalidArgument, "failed to decode start revision: %s", err)
 		}
 
-		afterRevision = decodedRevision
+		position = decodedPosition
 	} else {
-		var err error
-		afterRevision, err = ds.OptimizedRevision(ctx)
+		headRevision, err := ds.OptimizedRevision(ctx)
 		if err != nil {
 			return status.Errorf(codes.Unavailable, "failed to start watch: %s", err)
 		}
+
+		position = zedtoken.WatchPosition{AfterRevision: headRevision, Revision: headRevision}
 	}
 
 	usagemetrics.SetInContext(ctx, &dispatchv1.ResponseMeta{
 		DispatchCount: 1,
 	})
 
+	afterRevision := position.AfterRevision
 	updates, errchan := ds.Watch(ctx, afterRevision)
 	for {
 		select {
 		case update, ok := <-updates:
 			if ok {
-				filtered := filterUpdates(objectTypesMap, update.Changes)
-				if len(filtered) > 0 {
-					if err := stream.Send(&v1.WatchResponse{
-						Updates:        filtered,
-						ChangesThrough: zedtoken.MustNewFromRevision(update.Revision),
-					}); err != nil {
-						return status.Errorf(codes.Canceled, "watch canceled by user: %s", err)
+				// Changes are emitted by the datastores in a deterministic order within each
+				// revision, so resuming within a revision skips those already emitted.
+				var skipped uint32
+				if position.Sequence > 0 {
+					if update.Revision.Equal(position.Revision) {
+						skipped = position.Sequence
+						if int(skipped) > len(update.Changes) {
+							skipped = uint32(len(update.Changes))
+						}
 					}
+					position.Sequence = 0
 				}
+
+				if err := sendChanges(stream, objectTypesMap, update, afterRevision, skipped, perChange); err != nil {
+					return status.Errorf(codes.Canceled, "watch canceled by user: %s", err)
+				}
+				afterRevision = update.Revision
 			}
 		case err := <-errchan:
 			switch {
@@ -90,6 +114,75 @@ func (ws *watchServer) Watch(req *v1.WatchRequest, stream v1.WatchService_WatchS
 	}
 }
 
+// sendChanges sends the changes of the revision following those skipped. When sending each change
+// in its own response, the cursor of each response is positioned after the change within its
+// revision, except that of the last change of the revision, which is the revision itself.
+func sendChanges(
+	stream v1.WatchService_WatchServer,
+	objectTypes map[string]struct{},
+	update *datastore.RevisionChanges,
+	afterRevision datastore.Revision,
+	skipped uint32,
+	perChange bool,
+) error {
+	changes := update.Changes[skipped:]
+	if !perChange {
+		filtered := filterUpdates(objectTypes, changes)
+		if len(filtered) == 0 {
+			return nil
+		}
+
+		return stream.Send(&v1.WatchResponse{
+			Updates:        filtered,
+			ChangesThrough: zedtoken.MustNewFromRevision(update.Revision),
+		})
+	}
+
+	for index, change := range changes {
+		filtered := filterUpdates(objectTypes, []*core.RelationTupleUpdate{change})
+		if len(filtered) == 0 {
+			continue
+		}
+
+		sequence := skipped + uint32(index) + 1
+		changesThrough := zedtoken.MustNewFromRevision(update.Revision)
+		if int(sequence) < len(update.Changes) {
+			positioned, err := zedtoken.NewFromWatchPosition(update.Revision, afterRevision, sequence)
+			if err != nil {
+				return err
+			}
+			changesThrough = positioned
+		}
+
+		if err := stream.Send(&v1.WatchResponse{
+			Updates:        filtered,
+			ChangesThrough: changesThrough,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// watchPerChange returns whether the request asks for each change to be sent in its own response.
+func watchPerChange(ctx context.Context) (bool, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return false, nil
+	}
+
+	values := md.Get(WatchPerChangeHeader)
+	if len(values) == 0 || values[0] == "" {
+		return false, nil
+	}
+
+	perChange, err := strconv.ParseBool(values[0])
+	if err != nil {
+		return false, status.Errorf(codes.InvalidArgument, "invalid value for %s: must be a boolean", WatchPerChangeHeader)
+	}
+	return perChange, nil
+}
+
 func filterUpdates(objectTypes map[string]struct{}, candidates []*core.RelationTupleUpdate) []*v1.RelationshipUpdate {
 	updates := tuple.UpdatesToRelationshipUpdates(candidates)
 
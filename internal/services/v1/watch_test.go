@@ -14,9 +14,11 @@ import (
 	"github.com/authzed/grpcutil"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 
 	"github.com/authzed/spicedb/internal/datastore/memdb"
+	v1svc "github.com/authzed/spicedb/internal/services/v1"
 	"github.com/authzed/spicedb/internal/testfixtures"
 	"github.com/authzed/spicedb/internal/testserver"
 	"github.com/authzed/spicedb/pkg/tuple"
@@ -174,6 +176,81 @@ func TestWatch(t *testing.T) {
 	}
 }
 
+func TestWatchPerChange(t *testing.T) {
+	require := require.New(t)
+
+	conn, cleanup, ds, revision := testserver.NewTestServer(require, 0, memdb.DisableGC, true, testfixtures.StandardDatastoreWithData)
+	t.Cleanup(cleanup)
+	client := v1.NewWatchServiceClient(conn)
+
+	written, err := v1.NewPermissionsServiceClient(conn).WriteRelationships(context.Background(), &v1.WriteRelationshipsRequest{
+		Updates: []*v1.RelationshipUpdate{
+			update(v1.RelationshipUpdate_OPERATION_TOUCH, "folder", "folder2", "viewer", "user", "user1"),
+			update(v1.RelationshipUpdate_OPERATION_CREATE, "document", "document2", "viewer", "user", "user1"),
+			update(v1.RelationshipUpdate_OPERATION_DELETE, "folder", "auditors", "viewer", "user", "auditor"),
+			update(v1.RelationshipUpdate_OPERATION_CREATE, "document", "document1", "viewer", "user", "user1"),
+		},
+	})
+	require.NoError(err)
+
+	// Changes within a revision are always emitted in the same order.
+	expected := []*v1.RelationshipUpdate{
+		update(v1.RelationshipUpdate_OPERATION_TOUCH, "document", "document1", "viewer", "user", "user1"),
+		update(v1.RelationshipUpdate_OPERATION_TOUCH, "document", "document2", "viewer", "user", "user1"),
+		update(v1.RelationshipUpdate_OPERATION_DELETE, "folder", "auditors", "viewer", "user", "auditor"),
+		update(v1.RelationshipUpdate_OPERATION_TOUCH, "folder", "folder2", "viewer", "user", "user1"),
+	}
+
+	watch := func(cursor *v1.ZedToken, perChange bool, count int) []*v1.WatchResponse {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		if perChange {
+			ctx = metadata.AppendToOutgoingContext(ctx, v1svc.WatchPerChangeHeader, "true")
+		}
+
+		stream, err := client.Watch(ctx, &v1.WatchRequest{OptionalStartCursor: cursor})
+		require.NoError(err)
+
+		responses := make([]*v1.WatchResponse, 0, count)
+		for len(responses) < count {
+			resp, err := stream.Recv()
+			require.NoError(err)
+			responses = append(responses, resp)
+		}
+		return responses
+	}
+
+	responses := watch(zedtoken.MustNewFromRevision(revision), true, len(expected))
+	for index, resp := range responses {
+		require.Len(resp.Updates, 1)
+		require.Equal(tuple.MustRelString(expected[index].Relationship), tuple.MustRelString(resp.Updates[0].Relationship))
+		require.Equal(expected[index].Operation, resp.Updates[0].Operation)
+	}
+
+	// The cursor of the last change of the revision is that of the revision itself.
+	require.Equal(written.WrittenAt.Token, responses[len(responses)-1].ChangesThrough.Token)
+
+	// Positioned cursors remain usable for consistency.
+	position, err := zedtoken.DecodeRevision(responses[0].ChangesThrough, ds)
+	require.NoError(err)
+	writtenRevision, err := zedtoken.DecodeRevision(written.WrittenAt, ds)
+	require.NoError(err)
+	require.True(writtenRevision.Equal(position))
+
+	// Resuming from the cursor of a change emits exactly the changes which follow it.
+	for index, resp := range responses[:len(responses)-1] {
+		resumed := watch(resp.ChangesThrough, true, len(expected)-index-1)
+		for offset, resumedResp := range resumed {
+			require.Equal(tuple.MustRelString(expected[index+offset+1].Relationship), tuple.MustRelString(resumedResp.Updates[0].Relationship))
+		}
+	}
+
+	// Resuming without sending each change in its own response emits the remaining changes together.
+	resumed := watch(responses[1].ChangesThrough, false, 1)
+	require.Len(resumed[0].Updates, 2)
+	require.Equal(written.WrittenAt.Token, resumed[0].ChangesThrough.Token)
+}
+
 func sortUpdates(in []*v1.RelationshipUpdate) []*v1.RelationshipUpdate {
 	out := make([]*v1.RelationshipUpdate, 0, len(in))
 	out = append(out, in...)
@@ -0,0 +1,80 @@
+package v1
+
+import (
+	"context"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/authzed/spicedb/internal/datastore/proxy"
+	"github.com/authzed/spicedb/internal/middleware/usagemetrics"
+	"github.com/authzed/spicedb/internal/services/shared"
+	"github.com/authzed/spicedb/pkg/middleware/consistency"
+	dispatchv1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+	experimentalv1 "github.com/authzed/spicedb/pkg/proto/experimental/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// WhatIf evaluates each of the probes in the live world and in the hypothetical world of the
+// updates and candidate schema, at the same revision.
+func (es *experimentalServer) WhatIf(ctx context.Context, req *experimentalv1.WhatIfRequest) (*experimentalv1.WhatIfResponse, error) {
+	_, evaluatedAt := consistency.MustRevisionFromContext(ctx)
+
+	overlay := proxy.Overlay{Updates: tuple.UpdateFromRelationshipUpdates(req.Updates)}
+	if req.Schema != "" {
+		schema, err := shared.CompileProposedSchema(ctx, req.Schema)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid schema: %s", err)
+		}
+		overlay.Schema = schema
+	}
+
+	hypotheticalCtx, hypothetical, closer, err := es.withOverlay(ctx, overlay)
+	if err != nil {
+		return nil, rewriteError(ctx, err)
+	}
+	defer closer()
+
+	live := es.permissions.(*permissionServer)
+	resp := &experimentalv1.WhatIfResponse{
+		EvaluatedAt: evaluatedAt,
+		Results:     make([]*experimentalv1.WhatIfResult, 0, len(req.Probes)),
+	}
+	for _, probe := range req.Probes {
+		result := &experimentalv1.WhatIfResult{
+			Probe:        probe,
+			Live:         evaluateProbe(ctx, live, req.Consistency, probe),
+			Hypothetical: evaluateProbe(hypotheticalCtx, hypothetical, req.Consistency, probe),
+		}
+		result.Changed = !proto.Equal(result.Live, result.Hypothetical)
+		resp.Results = append(resp.Results, result)
+	}
+
+	// Each check sets its own metadata, which is replaced with that of the whole batch.
+	usagemetrics.SetInContext(ctx, &dispatchv1.ResponseMeta{
+		DispatchCount: uint32(2 * len(req.Probes)),
+	})
+	return resp, nil
+}
+
+// evaluateProbe checks the probe as would CheckPermission, returning its error as the outcome
+// if it fails.
+func evaluateProbe(ctx context.Context, permissions *permissionServer, consistency *v1.Consistency, probe *experimentalv1.WhatIfProbe) *experimentalv1.WhatIfOutcome {
+	checked, err := permissions.CheckPermission(ctx, &v1.CheckPermissionRequest{
+		Consistency: consistency,
+		Resource:    probe.Resource,
+		Permission:  probe.Permission,
+		Subject:     probe.Subject,
+		Context:     probe.Context,
+	})
+	if err != nil {
+		return &experimentalv1.WhatIfOutcome{Error: status.Convert(rewriteError(ctx, err)).Proto()}
+	}
+
+	return &experimentalv1.WhatIfOutcome{
+		Permissionship:    checked.Permissionship,
+		PartialCaveatInfo: checked.PartialCaveatInfo,
+	}
+}
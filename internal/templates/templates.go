@@ -0,0 +1,257 @@
+// Package templates implements named, parameterized permission checks which are registered
+// server-side and invoked by name, such as "can $user edit $document", so that which checks
+// services may perform can be governed centrally.
+//
+// Templates are defined in a YAML file:
+//
+//	templates:
+//	  - name: can-edit-document
+//	    resource: document:$document
+//	    permission: edit
+//	    subject: user:$user
+//	restricted_tokens:
+//	  - sha256: 5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8
+//	    templates: [can-edit-document]
+//
+// Parameters may only appear in object IDs. Requests made with a restricted token, identified
+// by the hex SHA-256 digest of the token, may only invoke the templates listed for it.
+package templates
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	yamlv3 "gopkg.in/yaml.v3"
+
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+var (
+	parameterRegex = regexp.MustCompile(`\$([a-zA-Z_][a-zA-Z0-9_]*)`)
+	nameRegex      = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9_.-]{0,127}$`)
+)
+
+// Template is a permission check whose object IDs are parameterized.
+type Template struct {
+	Name       string `yaml:"name"`
+	Resource   string `yaml:"resource"`
+	Permission string `yaml:"permission"`
+	Subject    string `yaml:"subject"`
+
+	parameters []string
+}
+
+// Parameters returns the names of the parameters of the template, sorted.
+func (t *Template) Parameters() []string {
+	return t.parameters
+}
+
+// RestrictedToken is a token which may only invoke the listed templates.
+type RestrictedToken struct {
+	SHA256    string   `yaml:"sha256"`
+	Templates []string `yaml:"templates"`
+}
+
+type file struct {
+	Templates        []*Template       `yaml:"templates"`
+	RestrictedTokens []RestrictedToken `yaml:"restricted_tokens"`
+}
+
+// Registry holds the templates which can be invoked, and the tokens restricted to them.
+type Registry struct {
+	templates        map[string]*Template
+	restrictedTokens map[string]map[string]struct{}
+}
+
+var current atomic.Pointer[Registry]
+
+// Set sets the process-wide registry of templates. A nil registry disables templates.
+func Set(r *Registry) {
+	current.Store(r)
+}
+
+// Current returns the process-wide registry of templates, which is nil if none is configured.
+func Current() *Registry {
+	return current.Load()
+}
+
+// Load reads and validates the templates defined in the file at the given path.
+func Load(path string) (*Registry, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read templates file: %w", err)
+	}
+	return Parse(contents)
+}
+
+// Parse parses and validates the templates defined in the given YAML.
+func Parse(contents []byte) (*Registry, error) {
+	var f file
+	if err := yamlv3.Unmarshal(contents, &f); err != nil {
+		return nil, fmt.Errorf("unable to parse templates file: %w", err)
+	}
+
+	r := &Registry{
+		templates:        make(map[string]*Template, len(f.Templates)),
+		restrictedTokens: make(map[string]map[string]struct{}, len(f.RestrictedTokens)),
+	}
+
+	for _, template := range f.Templates {
+		if !nameRegex.MatchString(template.Name) {
+			return nil, fmt.Errorf("invalid template name `%s`", template.Name)
+		}
+		if _, ok := r.templates[template.Name]; ok {
+			return nil, fmt.Errorf("duplicate template `%s`", template.Name)
+		}
+		if err := template.validate(); err != nil {
+			return nil, fmt.Errorf("invalid template `%s`: %w", template.Name, err)
+		}
+		r.templates[template.Name] = template
+	}
+
+	for index, restricted := range f.RestrictedTokens {
+		digest := strings.ToLower(restricted.SHA256)
+		if decoded, err := hex.DecodeString(digest); err != nil || len(decoded) != sha256.Size {
+			return nil, fmt.Errorf("invalid sha256 of restricted token #%d", index+1)
+		}
+
+		allowed := make(map[string]struct{}, len(restricted.Templates))
+		for _, name := range restricted.Templates {
+			if _, ok := r.templates[name]; !ok {
+				return nil, fmt.Errorf("unknown template `%s` for restricted token #%d", name, index+1)
+			}
+			allowed[name] = struct{}{}
+		}
+		r.restrictedTokens[digest] = allowed
+	}
+
+	return r, nil
+}
+
+func (t *Template) validate() error {
+	// Parameters are substituted only within object IDs, so that they cannot change which
+	// types and relations are checked.
+	resourceType, resourceID, ok := strings.Cut(t.Resource, ":")
+	if !ok || strings.Contains(resourceType, "$") {
+		return fmt.Errorf("resource must be of the form `type:id`")
+	}
+
+	subjectType, subjectRest, ok := strings.Cut(t.Subject, ":")
+	if !ok || strings.Contains(subjectType, "$") {
+		return fmt.Errorf("subject must be of the form `type:id` or `type:id#relation`")
+	}
+	subjectID, subjectRelation, _ := strings.Cut(subjectRest, "#")
+	if t.Permission == "" {
+		return fmt.Errorf("permission is required")
+	}
+	if strings.Contains(subjectRelation, "$") || strings.Contains(t.Permission, "$") {
+		return fmt.Errorf("parameters may only be used in object IDs")
+	}
+
+	parameters := make(map[string]struct{})
+	for _, id := range []string{resourceID, subjectID} {
+		for _, match := range parameterRegex.FindAllStringSubmatch(id, -1) {
+			parameters[match[1]] = struct{}{}
+		}
+	}
+
+	t.parameters = make([]string, 0, len(parameters))
+	for name := range parameters {
+		t.parameters = append(t.parameters, name)
+	}
+	sort.Strings(t.parameters)
+
+	// Check that the template expands into a valid request.
+	example := make(map[string]string, len(t.parameters))
+	for _, name := range t.parameters {
+		example[name] = "example"
+	}
+	_, err := t.expand(example)
+	return err
+}
+
+// Lookup returns the template with the given name, if any.
+func (r *Registry) Lookup(name string) (*Template, bool) {
+	if r == nil {
+		return nil, false
+	}
+	template, ok := r.templates[name]
+	return template, ok
+}
+
+// Restrictions returns whether the given token is restricted to invoking templates, and if so,
+// the names of the templates it may invoke.
+func (r *Registry) Restrictions(token string) (map[string]struct{}, bool) {
+	if r == nil || len(r.restrictedTokens) == 0 {
+		return nil, false
+	}
+
+	digest := sha256.Sum256([]byte(token))
+	allowed, ok := r.restrictedTokens[hex.EncodeToString(digest[:])]
+	return allowed, ok
+}
+
+// Expand returns the check request of the template for the given parameters, all of which must
+// be specified. The returned request has neither consistency nor caveat context.
+func (t *Template) Expand(parameters map[string]string) (*v1.CheckPermissionRequest, error) {
+	for _, name := range t.parameters {
+		if _, ok := parameters[name]; !ok {
+			return nil, fmt.Errorf("missing parameter `%s`", name)
+		}
+	}
+
+	for name := range parameters {
+		if idx := sort.SearchStrings(t.parameters, name); idx == len(t.parameters) || t.parameters[idx] != name {
+			return nil, fmt.Errorf("unknown parameter `%s`", name)
+		}
+	}
+
+	return t.expand(parameters)
+}
+
+func (t *Template) expand(parameters map[string]string) (*v1.CheckPermissionRequest, error) {
+	substitute := func(id string) string {
+		return parameterRegex.ReplaceAllStringFunc(id, func(match string) string {
+			return parameters[match[1:]]
+		})
+	}
+
+	resourceType, resourceID, _ := strings.Cut(t.Resource, ":")
+	resourceID = substitute(resourceID)
+	if err := tuple.ValidateResourceID(resourceID); err != nil {
+		return nil, err
+	}
+
+	subjectType, subjectRest, _ := strings.Cut(t.Subject, ":")
+	subjectID, subjectRelation, _ := strings.Cut(subjectRest, "#")
+	subjectID = substitute(subjectID)
+	if err := tuple.ValidateSubjectID(subjectID); err != nil {
+		return nil, err
+	}
+
+	req := &v1.CheckPermissionRequest{
+		Resource: &v1.ObjectReference{
+			ObjectType: resourceType,
+			ObjectId:   resourceID,
+		},
+		Permission: t.Permission,
+		Subject: &v1.SubjectReference{
+			Object: &v1.ObjectReference{
+				ObjectType: subjectType,
+				ObjectId:   subjectID,
+			},
+			OptionalRelation: subjectRelation,
+		},
+	}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
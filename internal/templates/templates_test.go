@@ -0,0 +1,185 @@
+package templates
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		contents      string
+		expectedError string
+	}{
+		{
+			"valid",
+			`
+templates:
+  - name: can-edit
+    resource: document:$doc
+    permission: edit
+    subject: user:$user
+  - name: team-member
+    resource: team:$org-$team
+    permission: member
+    subject: group:$group#member
+restricted_tokens:
+  - sha256: 5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8
+    templates: [can-edit]
+`,
+			"",
+		},
+		{
+			"parameter in type",
+			`
+templates:
+  - name: bad
+    resource: $type:$doc
+    permission: edit
+    subject: user:$user
+`,
+			"invalid template `bad`: resource must be of the form `type:id`",
+		},
+		{
+			"parameter in permission",
+			`
+templates:
+  - name: bad
+    resource: document:$doc
+    permission: $permission
+    subject: user:$user
+`,
+			"invalid template `bad`: parameters may only be used in object IDs",
+		},
+		{
+			"parameter in subject relation",
+			`
+templates:
+  - name: bad
+    resource: document:$doc
+    permission: edit
+    subject: group:$group#$relation
+`,
+			"invalid template `bad`: parameters may only be used in object IDs",
+		},
+		{
+			"invalid permission",
+			`
+templates:
+  - name: bad
+    resource: document:$doc
+    permission: Edit
+    subject: user:$user
+`,
+			"invalid template `bad`: invalid CheckPermissionRequest.Permission: value does not match regex pattern \"^([a-z][a-z0-9_]{1,62}[a-z0-9])?$\"",
+		},
+		{
+			"duplicate",
+			`
+templates:
+  - name: can-edit
+    resource: document:$doc
+    permission: edit
+    subject: user:$user
+  - name: can-edit
+    resource: document:$doc
+    permission: edit
+    subject: user:$user
+`,
+			"duplicate template `can-edit`",
+		},
+		{
+			"unknown template for token",
+			`
+restricted_tokens:
+  - sha256: 5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8
+    templates: [can-edit]
+`,
+			"unknown template `can-edit` for restricted token #1",
+		},
+		{
+			"invalid digest",
+			`
+restricted_tokens:
+  - sha256: password
+`,
+			"invalid sha256 of restricted token #1",
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse([]byte(tc.contents))
+			if tc.expectedError == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestExpand(t *testing.T) {
+	registry, err := Parse([]byte(`
+templates:
+  - name: team-member
+    resource: team:$org-$team
+    permission: member
+    subject: group:$group#member
+`))
+	require.NoError(t, err)
+
+	template, ok := registry.Lookup("team-member")
+	require.True(t, ok)
+	require.Equal(t, []string{"group", "org", "team"}, template.Parameters())
+
+	req, err := template.Expand(map[string]string{"org": "acme", "team": "eng", "group": "admins"})
+	require.NoError(t, err)
+	require.Equal(t, "team", req.Resource.ObjectType)
+	require.Equal(t, "acme-eng", req.Resource.ObjectId)
+	require.Equal(t, "member", req.Permission)
+	require.Equal(t, "group", req.Subject.Object.ObjectType)
+	require.Equal(t, "admins", req.Subject.Object.ObjectId)
+	require.Equal(t, "member", req.Subject.OptionalRelation)
+
+	_, err = template.Expand(map[string]string{"org": "acme", "team": "eng"})
+	require.EqualError(t, err, "missing parameter `group`")
+
+	_, err = template.Expand(map[string]string{"org": "acme", "team": "eng", "group": "admins", "other": "foo"})
+	require.EqualError(t, err, "unknown parameter `other`")
+
+	// Parameters cannot inject other parts of the request.
+	_, err = template.Expand(map[string]string{"org": "acme", "team": "eng", "group": "admins#owner"})
+	require.Error(t, err)
+
+	_, ok = registry.Lookup("unknown")
+	require.False(t, ok)
+}
+
+func TestRestrictions(t *testing.T) {
+	digest := sha256.Sum256([]byte("restricted"))
+	registry, err := Parse([]byte(`
+templates:
+  - name: can-edit
+    resource: document:$doc
+    permission: edit
+    subject: user:$user
+restricted_tokens:
+  - sha256: ` + hex.EncodeToString(digest[:]) + `
+    templates: [can-edit]
+`))
+	require.NoError(t, err)
+
+	allowed, restricted := registry.Restrictions("restricted")
+	require.True(t, restricted)
+	require.Contains(t, allowed, "can-edit")
+
+	_, restricted = registry.Restrictions("unrestricted")
+	require.False(t, restricted)
+
+	var none *Registry
+	_, restricted = none.Restrictions("restricted")
+	require.False(t, restricted)
+}
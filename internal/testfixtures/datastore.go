@@ -283,10 +283,15 @@ func (tc TupleChecker) VerifyIteratorCount(iter datastore.RelationshipIterator,
 	defer iter.Close()
 
 	foundCount := 0
-	for found := iter.Next(); found != nil; found = iter.Next() {
+	for {
+		found, err := iter.Next()
+		tc.Require.NoError(err)
+		if found == nil {
+			break
+		}
+
 		foundCount++
 	}
-	tc.Require.NoError(iter.Err())
 	tc.Require.Equal(count, foundCount)
 }
 
@@ -299,14 +304,18 @@ func (tc TupleChecker) VerifyIteratorResults(iter datastore.RelationshipIterator
 		toFind[tuple.MustString(tpl)] = struct{}{}
 	}
 
-	for found := iter.Next(); found != nil; found = iter.Next() {
-		tc.Require.NoError(iter.Err())
+	for {
+		found, err := iter.Next()
+		tc.Require.NoError(err)
+		if found == nil {
+			break
+		}
+
 		foundStr := tuple.MustString(found)
 		_, ok := toFind[foundStr]
 		tc.Require.True(ok, "found unexpected tuple %s in iterator", foundStr)
 		delete(toFind, foundStr)
 	}
-	tc.Require.NoError(iter.Err())
 
 	tc.Require.Zero(len(toFind), "did not find some expected tuples: %#v", toFind)
 }
@@ -0,0 +1,183 @@
+package testfixtures
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// GeneratedSchema is the schema of all generated datasets: resources nested under parent
+// resources, viewable and editable by users and by nested groups of users.
+const GeneratedSchema = `definition user {}
+
+caveat generated_caveat(allowed bool) {
+	allowed
+}
+
+definition group {
+	relation member: user | user with generated_caveat | group#member
+}
+
+definition resource {
+	relation parent: resource
+	relation viewer: user | user with generated_caveat | group#member
+	relation editor: user | group#member
+	permission edit = editor + parent->edit
+	permission view = viewer + edit + parent->view
+}`
+
+// GeneratorConfig configures a generated dataset. The same config always generates the same
+// dataset.
+type GeneratorConfig struct {
+	// Seed seeds the random choices made when generating.
+	Seed int64
+
+	// Depth is the number of levels of nested groups, and of nested resources.
+	Depth int
+
+	// FanOut is the number of children of each group and resource, the number of members of
+	// each group and the number of viewers of each resource.
+	FanOut int
+
+	// Users is the number of users.
+	Users int
+
+	// CaveatRatio is the fraction, between 0 and 1, of the relationships to users which are
+	// caveated. Half of the caveated relationships have the caveat's context, and half require
+	// it at evaluation.
+	CaveatRatio float64
+}
+
+// SmallGeneratorConfig generates a dataset small enough to be exhaustively checked.
+var SmallGeneratorConfig = GeneratorConfig{Seed: 1, Depth: 3, FanOut: 3, Users: 10, CaveatRatio: 0.2}
+
+// GeneratedDataset is a generated schema and relationships.
+type GeneratedDataset struct {
+	Schema        string
+	Relationships []*core.RelationTuple
+}
+
+// Generate generates the dataset for the config.
+func Generate(config GeneratorConfig) GeneratedDataset {
+	g := &generator{
+		config: config,
+		rng:    rand.New(rand.NewSource(config.Seed)),
+		seen:   make(map[string]struct{}),
+	}
+
+	groups := g.tree("group", func(parent, child string) *core.RelationTuple {
+		return tuple.MustParse(fmt.Sprintf("group:%s#member@group:%s#member", parent, child))
+	})
+	for _, group := range groups {
+		for i := 0; i < config.FanOut; i++ {
+			g.add(g.maybeCaveated(tuple.MustParse(fmt.Sprintf("group:%s#member@user:%s", group, g.user()))))
+		}
+	}
+
+	resources := g.tree("resource", func(parent, child string) *core.RelationTuple {
+		return tuple.MustParse(fmt.Sprintf("resource:%s#parent@resource:%s", child, parent))
+	})
+	for _, resource := range resources {
+		for i := 0; i < config.FanOut; i++ {
+			if g.rng.Intn(2) == 0 {
+				g.add(g.maybeCaveated(tuple.MustParse(fmt.Sprintf("resource:%s#viewer@user:%s", resource, g.user()))))
+			} else {
+				g.add(tuple.MustParse(fmt.Sprintf("resource:%s#viewer@group:%s#member", resource, groups[g.rng.Intn(len(groups))])))
+			}
+		}
+
+		if g.rng.Intn(2) == 0 {
+			g.add(tuple.MustParse(fmt.Sprintf("resource:%s#editor@user:%s", resource, g.user())))
+		} else {
+			g.add(tuple.MustParse(fmt.Sprintf("resource:%s#editor@group:%s#member", resource, groups[g.rng.Intn(len(groups))])))
+		}
+	}
+
+	return GeneratedDataset{
+		Schema:        GeneratedSchema,
+		Relationships: g.relationships,
+	}
+}
+
+// ValidationFile returns the contents of a validation file holding the dataset.
+func (gd GeneratedDataset) ValidationFile() string {
+	var sb strings.Builder
+	sb.WriteString("schema: |-\n")
+	for _, line := range strings.Split(gd.Schema, "\n") {
+		sb.WriteString("  " + line + "\n")
+	}
+
+	sb.WriteString("relationships: |-\n")
+	for _, rel := range gd.Relationships {
+		sb.WriteString("  " + tuple.MustString(rel) + "\n")
+	}
+	return sb.String()
+}
+
+// GeneratedDatastore returns a function which writes the dataset generated for the config to
+// a datastore, for use wherever the standard datasets are used.
+func GeneratedDatastore(config GeneratorConfig) func(datastore.Datastore, *require.Assertions) (datastore.Datastore, datastore.Revision) {
+	return func(ds datastore.Datastore, require *require.Assertions) (datastore.Datastore, datastore.Revision) {
+		generated := Generate(config)
+		return DatastoreFromSchemaAndTestRelationships(ds, generated.Schema, generated.Relationships, require)
+	}
+}
+
+type generator struct {
+	config        GeneratorConfig
+	rng           *rand.Rand
+	seen          map[string]struct{}
+	relationships []*core.RelationTuple
+}
+
+// tree generates the IDs of a tree of objects of the given type, of the configured depth and
+// fan-out, adding a relationship between each object and its parent.
+func (g *generator) tree(objectType string, relate func(parent, child string) *core.RelationTuple) []string {
+	ids := []string{objectType + "0"}
+	level := ids
+	for depth := 1; depth < g.config.Depth; depth++ {
+		var next []string
+		for _, parent := range level {
+			for i := 0; i < g.config.FanOut; i++ {
+				child := fmt.Sprintf("%s%d", objectType, len(ids))
+				ids = append(ids, child)
+				next = append(next, child)
+				g.add(relate(parent, child))
+			}
+		}
+		level = next
+	}
+	return ids
+}
+
+func (g *generator) user() string {
+	return fmt.Sprintf("user%d", g.rng.Intn(g.config.Users))
+}
+
+func (g *generator) maybeCaveated(tpl *core.RelationTuple) *core.RelationTuple {
+	if g.rng.Float64() >= g.config.CaveatRatio {
+		return tpl
+	}
+
+	if g.rng.Intn(2) == 0 {
+		return tuple.MustWithCaveat(tpl, "generated_caveat", map[string]any{"allowed": g.rng.Intn(2) == 0})
+	}
+	return tuple.MustWithCaveat(tpl, "generated_caveat")
+}
+
+// add adds the relationship, unless it has already been added, so that generated datasets can
+// be written with CREATE.
+func (g *generator) add(tpl *core.RelationTuple) {
+	key := tuple.StringWithoutCaveat(tpl)
+	if _, ok := g.seen[key]; ok {
+		return
+	}
+	g.seen[key] = struct{}{}
+	g.relationships = append(g.relationships, tpl)
+}
@@ -0,0 +1,45 @@
+package testfixtures
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+func TestGenerateIsDeterministic(t *testing.T) {
+	first := Generate(SmallGeneratorConfig)
+	second := Generate(SmallGeneratorConfig)
+	require.Equal(t, first.ValidationFile(), second.ValidationFile())
+
+	reseeded := SmallGeneratorConfig
+	reseeded.Seed = 2
+	require.NotEqual(t, first.ValidationFile(), Generate(reseeded).ValidationFile())
+}
+
+func TestGenerate(t *testing.T) {
+	config := GeneratorConfig{Seed: 1, Depth: 3, FanOut: 2, Users: 5, CaveatRatio: 1}
+	generated := Generate(config)
+
+	counts := make(map[string]int)
+	for _, rel := range generated.Relationships {
+		counts[rel.ResourceAndRelation.Namespace+"#"+rel.ResourceAndRelation.Relation]++
+
+		// Every relationship to a user, other than editors, is caveated.
+		if rel.Subject.Namespace == "user" && rel.ResourceAndRelation.Relation != "editor" {
+			require.NotNil(t, rel.Caveat, tuple.MustString(rel))
+		}
+	}
+
+	// Trees of depth 3 and fan-out 2 have 7 objects, 6 of which have parents.
+	require.Equal(t, 6, counts["resource#parent"])
+	require.Equal(t, 7, counts["resource#editor"])
+	require.LessOrEqual(t, counts["resource#viewer"], 7*2)
+	require.LessOrEqual(t, counts["group#member"], 6+7*2)
+
+	ds, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	require.NoError(t, err)
+	GeneratedDatastore(config)(ds, require.New(t))
+}
@@ -24,6 +24,10 @@ func NewValidatingDatastore(delegate datastore.Datastore) datastore.Datastore {
 	return validatingDatastore{Datastore: delegate}
 }
 
+func (vd validatingDatastore) Unwrap() datastore.Datastore {
+	return vd.Datastore
+}
+
 func (vd validatingDatastore) SnapshotReader(revision datastore.Revision) datastore.Reader {
 	return validatingSnapshotReader{vd.Datastore.SnapshotReader(revision)}
 }
@@ -124,6 +128,10 @@ func (vsr validatingSnapshotReader) ReverseQueryRelationships(ctx context.Contex
 		}
 	}
 
+	if queryOpts.ResIDPrefix != "" && queryOpts.ResRelation == nil {
+		return nil, errors.New("resource ID prefix on reverse query missing resource relation")
+	}
+
 	return vsr.delegate.ReverseQueryRelationships(ctx, subjectsFilter, opts...)
 }
 
@@ -169,6 +177,25 @@ func (vsr validatingSnapshotReader) ListAllCaveats(ctx context.Context) ([]datas
 	return read, err
 }
 
+func (vsr validatingSnapshotReader) ListSchemaVersions(ctx context.Context) ([]*core.SchemaVersion, error) {
+	read, err := vsr.delegate.ListSchemaVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, version := range read {
+		if err := version.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return read, nil
+}
+
+func (vsr validatingSnapshotReader) LatestSchemaVersion(ctx context.Context) (uint64, error) {
+	return vsr.delegate.LatestSchemaVersion(ctx)
+}
+
 type validatingReadWriteTransaction struct {
 	validatingSnapshotReader
 	delegate datastore.ReadWriteTransaction
@@ -223,6 +250,13 @@ func (vrwt validatingReadWriteTransaction) DeleteCaveats(ctx context.Context, na
 	return vrwt.delegate.DeleteCaveats(ctx, names)
 }
 
+func (vrwt validatingReadWriteTransaction) WriteSchemaVersion(ctx context.Context, version *core.SchemaVersion) error {
+	if err := version.Validate(); err != nil {
+		return err
+	}
+	return vrwt.delegate.WriteSchemaVersion(ctx, version)
+}
+
 // validateUpdatesToWrite performs basic validation on relationship updates going into datastores.
 func validateUpdatesToWrite(updates ...*core.RelationTupleUpdate) error {
 	for _, update := range updates {
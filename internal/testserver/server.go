@@ -23,6 +23,15 @@ import (
 type ServerConfig struct {
 	MaxUpdatesPerWrite    uint16
 	MaxPreconditionsCount uint16
+
+	StrictRelationshipValidation bool
+	RelationshipRestoreWindow    time.Duration
+	RestoreTokenKeys             []string
+	MaximumResultSize            uint64
+	PointInTimeCheckEnabled      bool
+	ArchiveDirectory             string
+	JobsEnabled                  bool
+	JobPollInterval              time.Duration
 }
 
 // NewTestServer creates a new test server, using defaults for the config.
@@ -58,6 +67,14 @@ func NewTestServerWithConfig(require *require.Assertions,
 		server.WithDispatchMaxDepth(50),
 		server.WithMaximumPreconditionCount(config.MaxPreconditionsCount),
 		server.WithMaximumUpdatesPerWrite(config.MaxUpdatesPerWrite),
+		server.WithStrictRelationshipValidation(config.StrictRelationshipValidation),
+		server.WithRelationshipRestoreWindow(config.RelationshipRestoreWindow),
+		server.SetRelationshipRestoreTokenKeys(config.RestoreTokenKeys),
+		server.WithMaximumResultSize(config.MaximumResultSize),
+		server.WithPointInTimeCheckEnabled(config.PointInTimeCheckEnabled),
+		server.WithArchiveDirectory(config.ArchiveDirectory),
+		server.WithJobsEnabled(config.JobsEnabled),
+		server.WithJobPollInterval(config.JobPollInterval),
 		server.WithGRPCServer(util.GRPCServerConfig{
 			Network: util.BufferedNetwork,
 			Enabled: true,
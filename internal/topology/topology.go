@@ -0,0 +1,119 @@
+// Package topology implements an endpoint describing the cluster of servers, and hints of their
+// load, for smart clients and sidecars balancing requests across the servers themselves rather
+// than relying upon DNS round-robin.
+package topology
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/authzed/spicedb/pkg/balancer"
+)
+
+// HalfWeightLoad is the number of requests in flight on a server at which the weight suggested
+// for it is half that of an idle server.
+const HalfWeightLoad = 100
+
+// maxWeight is the weight suggested for an idle server.
+const maxWeight = 100
+
+// Config configures the topology reported by a server.
+type Config struct {
+	// APIAddress is the address at which clients reach the API of this server, if known.
+	APIAddress string
+
+	// Serving returns whether this server is serving the API.
+	Serving func(ctx context.Context) bool
+
+	// Overloaded returns whether this server is shedding new requests, if it may do so.
+	Overloaded func() bool
+}
+
+// Topology is the topology of the cluster, as seen by a server.
+type Topology struct {
+	Node        Node      `json:"node"`
+	Peers       []Peer    `json:"peers"`
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+// Node describes the server reporting the topology, and its load.
+type Node struct {
+	ID               string `json:"id"`
+	APIAddress       string `json:"api_address,omitempty"`
+	Serving          bool   `json:"serving"`
+	Overloaded       bool   `json:"overloaded"`
+	InflightRequests int64  `json:"inflight_requests"`
+
+	// Weight is the relative weight, between 0 and 100, suggested for the server when balancing
+	// requests. It is 0 when the server should not be sent requests.
+	Weight uint32 `json:"weight"`
+}
+
+// Peer is a dispatch peer of the server, with the load it last reported to the server. Peers are
+// identified by their dispatch address; the host of a peer serves the API on its own address.
+type Peer struct {
+	Address string  `json:"address"`
+	Host    string  `json:"host"`
+	Load    *uint64 `json:"load,omitempty"`
+	Weight  uint32  `json:"weight"`
+}
+
+// Handler returns a handler serving the topology of the cluster as seen by this server, as JSON.
+func Handler(config Config) http.Handler {
+	nodeID, err := os.Hostname()
+	if err != nil {
+		nodeID = "unknown"
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := json.NewEncoder(w).Encode(Current(r.Context(), nodeID, config)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// Current returns the current topology of the cluster as seen by the server with the given ID.
+func Current(ctx context.Context, nodeID string, config Config) Topology {
+	node := Node{
+		ID:               nodeID,
+		APIAddress:       config.APIAddress,
+		Serving:          config.Serving == nil || config.Serving(ctx),
+		Overloaded:       config.Overloaded != nil && config.Overloaded(),
+		InflightRequests: balancer.InflightRequests(),
+	}
+	if node.Serving && !node.Overloaded && node.InflightRequests >= 0 {
+		node.Weight = weightForLoad(uint64(node.InflightRequests))
+	}
+
+	members := balancer.Members()
+	peers := make([]Peer, 0, len(members))
+	for _, member := range members {
+		peer := Peer{Address: member.Key, Host: member.Key, Weight: maxWeight}
+		if host, _, err := net.SplitHostPort(member.Key); err == nil {
+			peer.Host = host
+		}
+
+		// As for load-aware dispatch, peers which have not recently reported a load have not
+		// recently served any requests, and so are assumed to be idle.
+		if member.LoadReported {
+			load := member.Load
+			peer.Load = &load
+			peer.Weight = weightForLoad(load)
+		}
+		peers = append(peers, peer)
+	}
+
+	return Topology{Node: node, Peers: peers, GeneratedAt: time.Now().UTC()}
+}
+
+// weightForLoad returns the weight suggested for a server with the given number of requests in
+// flight, which halves from that of an idle server at HalfWeightLoad, and is never below 1.
+func weightForLoad(load uint64) uint32 {
+	return uint32(1 + (maxWeight-1)*HalfWeightLoad/(HalfWeightLoad+load))
+}
@@ -0,0 +1,56 @@
+package topology
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWeightForLoad(t *testing.T) {
+	require.Equal(t, uint32(100), weightForLoad(0))
+	require.Equal(t, uint32(50), weightForLoad(HalfWeightLoad))
+	require.Equal(t, uint32(1), weightForLoad(1_000_000))
+}
+
+func TestHandler(t *testing.T) {
+	serving := true
+	overloaded := false
+	handler := Handler(Config{
+		APIAddress: "10.0.0.1:50051",
+		Serving:    func(context.Context) bool { return serving },
+		Overloaded: func() bool { return overloaded },
+	})
+
+	read := func() Topology {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/topology", nil))
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+
+		var topology Topology
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &topology))
+		return topology
+	}
+
+	topology := read()
+	require.NotEmpty(t, topology.Node.ID)
+	require.Equal(t, "10.0.0.1:50051", topology.Node.APIAddress)
+	require.True(t, topology.Node.Serving)
+	require.Equal(t, uint32(100), topology.Node.Weight)
+	require.NotNil(t, topology.Peers)
+
+	overloaded = true
+	topology = read()
+	require.True(t, topology.Node.Overloaded)
+	require.Zero(t, topology.Node.Weight)
+
+	overloaded = false
+	serving = false
+	topology = read()
+	require.False(t, topology.Node.Serving)
+	require.Zero(t, topology.Node.Weight)
+}
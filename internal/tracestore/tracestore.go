@@ -0,0 +1,215 @@
+// Package tracestore persists the debug traces of sampled requests, keyed by the ID of the
+// request, so that surprising or slow decisions can be inspected after the fact.
+package tracestore
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var recordedCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "spicedb",
+	Subsystem: "tracestore",
+	Name:      "traces_total",
+	Help:      "The number of request traces recorded, by result.",
+}, []string{"result"})
+
+func init() {
+	prometheus.MustRegister(recordedCounter)
+}
+
+// ErrTraceNotFound is returned when no trace has been recorded for a request.
+var ErrTraceNotFound = errors.New("trace not found")
+
+// Trace is the debug trace of a single request.
+type Trace struct {
+	RequestID  string        `json:"request_id"`
+	Method     string        `json:"method"`
+	RecordedAt time.Time     `json:"recorded_at"`
+	Duration   time.Duration `json:"duration"`
+
+	// Request is the request, as JSON.
+	Request json.RawMessage `json:"request,omitempty"`
+
+	// DebugInformation is the debug information computed for the request, as the JSON of a
+	// v1.DebugInformation, if any.
+	DebugInformation json.RawMessage `json:"debug_information,omitempty"`
+
+	// CaveatEvaluations are the evaluations of caveat expressions performed for the request, as
+	// a JSON array of dispatch.v1.CaveatEvaluation, if any.
+	CaveatEvaluations json.RawMessage `json:"caveat_evaluations,omitempty"`
+
+	// Error is the error with which the request failed, if any.
+	Error string `json:"error,omitempty"`
+
+	// Metadata holds further details of the request, such as the number of dispatches.
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// Store records traces by the ID of their request.
+type Store interface {
+	// Put records the trace, replacing any recorded for the same request.
+	Put(ctx context.Context, trace Trace) error
+
+	// Get returns the trace recorded for the request, or ErrTraceNotFound.
+	Get(ctx context.Context, requestID string) (*Trace, error)
+}
+
+// Recorder samples requests to be traced and records their traces in a store.
+type Recorder struct {
+	store      Store
+	sampleRate float64
+}
+
+// NewRecorder creates a recorder which traces the given fraction of requests, in addition to
+// those for which debug information is explicitly requested.
+func NewRecorder(store Store, sampleRate float64) *Recorder {
+	return &Recorder{store: store, sampleRate: sampleRate}
+}
+
+// Sample returns whether a request should be traced. A nil recorder samples nothing.
+func (r *Recorder) Sample() bool {
+	if r == nil || r.sampleRate <= 0 {
+		return false
+	}
+	return r.sampleRate >= 1 || rand.Float64() < r.sampleRate
+}
+
+// Record records the trace. Failures are counted but otherwise ignored, so that recording a
+// trace never fails the request traced. A nil recorder records nothing.
+func (r *Recorder) Record(ctx context.Context, trace Trace) {
+	if r == nil {
+		return
+	}
+
+	if err := r.store.Put(ctx, trace); err != nil {
+		recordedCounter.WithLabelValues("error").Inc()
+		return
+	}
+	recordedCounter.WithLabelValues("success").Inc()
+}
+
+// Get returns the trace recorded for the request, or ErrTraceNotFound.
+func (r *Recorder) Get(ctx context.Context, requestID string) (*Trace, error) {
+	return r.store.Get(ctx, requestID)
+}
+
+// NewMemoryStore creates a store which holds the most recent traces in memory, up to the given
+// number.
+func NewMemoryStore(maxTraces int) Store {
+	return &memoryStore{
+		maxTraces: maxTraces,
+		order:     list.New(),
+		traces:    make(map[string]*list.Element, maxTraces),
+	}
+}
+
+type memoryStore struct {
+	sync.Mutex
+	maxTraces int
+	order     *list.List
+	traces    map[string]*list.Element
+}
+
+func (ms *memoryStore) Put(_ context.Context, trace Trace) error {
+	ms.Lock()
+	defer ms.Unlock()
+
+	if existing, ok := ms.traces[trace.RequestID]; ok {
+		ms.order.Remove(existing)
+	}
+	ms.traces[trace.RequestID] = ms.order.PushBack(trace)
+
+	for ms.order.Len() > ms.maxTraces {
+		oldest := ms.order.Front()
+		ms.order.Remove(oldest)
+		delete(ms.traces, oldest.Value.(Trace).RequestID)
+	}
+	return nil
+}
+
+func (ms *memoryStore) Get(_ context.Context, requestID string) (*Trace, error) {
+	ms.Lock()
+	defer ms.Unlock()
+
+	found, ok := ms.traces[requestID]
+	if !ok {
+		return nil, ErrTraceNotFound
+	}
+	trace := found.Value.(Trace)
+	return &trace, nil
+}
+
+// validRequestID matches request IDs which are safe to use as file names.
+var validRequestID = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,128}$`)
+
+// NewDirectoryStore creates a store which writes each trace as a JSON file to the directory,
+// which may be a volume shared by all of the servers of a deployment. Traces are never removed
+// from the directory, so it should be pruned externally.
+func NewDirectoryStore(path string) (Store, error) {
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return nil, fmt.Errorf("unable to create trace directory: %w", err)
+	}
+	return &directoryStore{path: path}, nil
+}
+
+type directoryStore struct {
+	path string
+}
+
+func (ds *directoryStore) filename(requestID string) (string, error) {
+	if !validRequestID.MatchString(requestID) || requestID == "." || requestID == ".." {
+		return "", fmt.Errorf("invalid request ID for trace: %q", requestID)
+	}
+	return filepath.Join(ds.path, requestID+".json"), nil
+}
+
+func (ds *directoryStore) Put(_ context.Context, trace Trace) error {
+	filename, err := ds.filename(trace.RequestID)
+	if err != nil {
+		return err
+	}
+
+	marshaled, err := json.Marshal(trace)
+	if err != nil {
+		return fmt.Errorf("unable to marshal trace: %w", err)
+	}
+
+	// Write to a temporary file first, so that a partially written trace is never read.
+	temp := filename + ".tmp"
+	if err := os.WriteFile(temp, marshaled, 0o600); err != nil {
+		return fmt.Errorf("unable to write trace: %w", err)
+	}
+	return os.Rename(temp, filename)
+}
+
+func (ds *directoryStore) Get(_ context.Context, requestID string) (*Trace, error) {
+	filename, err := ds.filename(requestID)
+	if err != nil {
+		return nil, ErrTraceNotFound
+	}
+
+	contents, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrTraceNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read trace: %w", err)
+	}
+
+	var trace Trace
+	if err := json.Unmarshal(contents, &trace); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal trace: %w", err)
+	}
+	return &trace, nil
+}
@@ -0,0 +1,66 @@
+package tracestore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStoreEvictsOldest(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(2)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, store.Put(ctx, Trace{RequestID: fmt.Sprintf("req%d", i)}))
+	}
+
+	_, err := store.Get(ctx, "req0")
+	require.ErrorIs(t, err, ErrTraceNotFound)
+
+	for _, requestID := range []string{"req1", "req2"} {
+		trace, err := store.Get(ctx, requestID)
+		require.NoError(t, err)
+		require.Equal(t, requestID, trace.RequestID)
+	}
+}
+
+func TestDirectoryStore(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewDirectoryStore(t.TempDir())
+	require.NoError(t, err)
+
+	trace := Trace{
+		RequestID:        "abc123",
+		Method:           "authzed.api.v1.PermissionsService/CheckPermission",
+		RecordedAt:       time.Now().UTC().Truncate(time.Millisecond),
+		Duration:         5 * time.Millisecond,
+		Request:          json.RawMessage(`{"permission":"view"}`),
+		DebugInformation: json.RawMessage(`{"check":{}}`),
+		Metadata:         map[string]string{"dispatch_count": "3"},
+	}
+	require.NoError(t, store.Put(ctx, trace))
+
+	found, err := store.Get(ctx, "abc123")
+	require.NoError(t, err)
+	require.Equal(t, trace, *found)
+
+	_, err = store.Get(ctx, "missing")
+	require.ErrorIs(t, err, ErrTraceNotFound)
+
+	_, err = store.Get(ctx, "../abc123")
+	require.ErrorIs(t, err, ErrTraceNotFound)
+	require.Error(t, store.Put(ctx, Trace{RequestID: "../escape"}))
+}
+
+func TestRecorderSampling(t *testing.T) {
+	var nilRecorder *Recorder
+	require.False(t, nilRecorder.Sample())
+	nilRecorder.Record(context.Background(), Trace{RequestID: "ignored"})
+
+	require.False(t, NewRecorder(NewMemoryStore(1), 0).Sample())
+	require.True(t, NewRecorder(NewMemoryStore(1), 1).Sample())
+}
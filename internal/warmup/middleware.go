@@ -0,0 +1,65 @@
+package warmup
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// replayedMetadataKey is the metadata key set on replayed requests, which are not recorded
+// again.
+const replayedMetadataKey = "x-spicedb-warmup"
+
+func isReplayed(ctx context.Context) bool {
+	md, ok := metadata.FromIncomingContext(ctx)
+	return ok && len(md.Get(replayedMetadataKey)) > 0
+}
+
+// UnaryServerInterceptor returns a new interceptor which records a sample of the check
+// requests served with the recorder. A nil recorder records nothing.
+func UnaryServerInterceptor(recorder *Recorder) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		resp, err := handler(ctx, req)
+		if message, ok := req.(proto.Message); ok && err == nil {
+			recorder.Record(ctx, info.FullMethod, message)
+		}
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns a new interceptor which records a sample of the lookup
+// requests served with the recorder. A nil recorder records nothing.
+func StreamServerInterceptor(recorder *Recorder) grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if recorder == nil {
+			return handler(srv, stream)
+		}
+
+		wrapped := &recordingStream{ServerStream: stream}
+		err := handler(srv, wrapped)
+		if wrapped.request != nil && err == nil {
+			recorder.Record(stream.Context(), info.FullMethod, wrapped.request)
+		}
+		return err
+	}
+}
+
+// recordingStream captures the first message received on a stream, which is the request of
+// server streaming methods.
+type recordingStream struct {
+	grpc.ServerStream
+	request proto.Message
+}
+
+func (s *recordingStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	if message, ok := m.(proto.Message); ok && s.request == nil {
+		s.request = message
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package warmup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	log "github.com/authzed/spicedb/internal/logging"
+)
+
+// minimizeLatency is the consistency at which requests are replayed: that used by most traffic,
+// whose results are cached at the revisions which subsequent requests will read.
+var minimizeLatency = &v1.Consistency{Requirement: &v1.Consistency_MinimizeLatency{MinimizeLatency: true}}
+
+// ReplayStats are the results of a replay.
+type ReplayStats struct {
+	Replayed int
+	Failed   int
+	Skipped  int
+}
+
+// Replay replays the requests on the connection, with the given number concurrently, until
+// all have been replayed or the context is cancelled. Requests are replayed at
+// minimize_latency consistency, whatever their recorded consistency, and their failures are
+// counted but otherwise ignored.
+func Replay(ctx context.Context, conn grpc.ClientConnInterface, requests []Request, concurrency int) ReplayStats {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	ctx = metadata.AppendToOutgoingContext(ctx, replayedMetadataKey, "true")
+
+	var mu sync.Mutex
+	var stats ReplayStats
+	count := func(result string) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch result {
+		case "success":
+			stats.Replayed++
+		case "error":
+			stats.Failed++
+		default:
+			stats.Skipped++
+		}
+		replayedCounter.WithLabelValues(result).Inc()
+	}
+
+	pending := make(chan Request)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for request := range pending {
+				if ctx.Err() != nil {
+					count("skipped")
+					continue
+				}
+
+				if err := replay(ctx, conn, request); err != nil {
+					log.Ctx(ctx).Debug().Err(err).Str("method", request.Method).Msg("failed to replay request for warmup")
+					count("error")
+					continue
+				}
+				count("success")
+			}
+		}()
+	}
+
+	for _, request := range requests {
+		pending <- request
+	}
+	close(pending)
+	wg.Wait()
+
+	return stats
+}
+
+func replay(ctx context.Context, conn grpc.ClientConnInterface, request Request) error {
+	method, ok := replayableMethods[request.Method]
+	if !ok {
+		return fmt.Errorf("unable to replay requests of method %s", request.Method)
+	}
+
+	req := method.newRequest()
+	if err := protojson.Unmarshal(request.Request, req); err != nil {
+		return fmt.Errorf("unable to unmarshal request: %w", err)
+	}
+
+	switch typed := req.(type) {
+	case *v1.CheckPermissionRequest:
+		typed.Consistency = minimizeLatency
+	case *v1.LookupResourcesRequest:
+		typed.Consistency = minimizeLatency
+	case *v1.LookupSubjectsRequest:
+		typed.Consistency = minimizeLatency
+	}
+
+	if !method.streaming {
+		return conn.Invoke(ctx, request.Method, req, method.newResponse())
+	}
+
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, request.Method)
+	if err != nil {
+		return err
+	}
+	if err := stream.SendMsg(req); err != nil {
+		return err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return err
+	}
+
+	for {
+		if err := stream.RecvMsg(method.newResponse()); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,264 @@
+// Package warmup records a sample of the check and lookup requests served, and replays them
+// when a server starts, so that its caches are populated before it is reported as serving and
+// added to the load balancer.
+package warmup
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	log "github.com/authzed/spicedb/internal/logging"
+)
+
+var (
+	recordedCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "spicedb",
+		Subsystem: "warmup",
+		Name:      "recorded_requests_total",
+		Help:      "The number of requests recorded for replay when warming up.",
+	})
+
+	replayedCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "spicedb",
+		Subsystem: "warmup",
+		Name:      "replayed_requests_total",
+		Help:      "The number of recorded requests replayed when warming up, by result.",
+	}, []string{"result"})
+)
+
+func init() {
+	prometheus.MustRegister(recordedCounter, replayedCounter)
+}
+
+// finalFlushTimeout is the time given to write the requests recorded when the recorder stops.
+const finalFlushTimeout = 10 * time.Second
+
+// replayableMethods are the methods whose requests are recorded, along with constructors of
+// their requests and responses, and whether they are server streaming.
+var replayableMethods = map[string]struct {
+	newRequest  func() proto.Message
+	newResponse func() proto.Message
+	streaming   bool
+}{
+	"/authzed.api.v1.PermissionsService/CheckPermission": {
+		newRequest:  func() proto.Message { return &v1.CheckPermissionRequest{} },
+		newResponse: func() proto.Message { return &v1.CheckPermissionResponse{} },
+	},
+	"/authzed.api.v1.PermissionsService/LookupResources": {
+		newRequest:  func() proto.Message { return &v1.LookupResourcesRequest{} },
+		newResponse: func() proto.Message { return &v1.LookupResourcesResponse{} },
+		streaming:   true,
+	},
+	"/authzed.api.v1.PermissionsService/LookupSubjects": {
+		newRequest:  func() proto.Message { return &v1.LookupSubjectsRequest{} },
+		newResponse: func() proto.Message { return &v1.LookupSubjectsResponse{} },
+		streaming:   true,
+	},
+}
+
+// Request is a recorded request.
+type Request struct {
+	// Method is the full name of the gRPC method called.
+	Method string `json:"method"`
+
+	// Request is the request, as protobuf JSON.
+	Request json.RawMessage `json:"request"`
+
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// Config configures the recording and replay of requests.
+type Config struct {
+	// Path is the file to which recorded requests are written, and from which they are replayed
+	// on startup. Requests are neither recorded nor replayed if it is empty.
+	Path string
+
+	// SampleRate is the fraction of check and lookup requests recorded.
+	SampleRate float64
+
+	// MaxRequests is the number of the most recent requests recorded which are kept.
+	MaxRequests int
+
+	// FlushInterval is the interval at which the requests recorded are written to the file.
+	FlushInterval time.Duration
+
+	// Concurrency is the number of requests replayed concurrently.
+	Concurrency int
+
+	// Timeout is the time given to replay the requests, after which the remainder are skipped.
+	Timeout time.Duration
+}
+
+// Recorder records a sample of the requests served, keeping the most recent in memory and
+// writing them to a file at intervals.
+type Recorder struct {
+	config Config
+
+	mu       sync.Mutex
+	requests []Request
+	dirty    bool
+}
+
+// NewRecorder creates a recorder which keeps the given requests, such as those read from its
+// file on startup, until replaced by those recorded. The recorder only writes the requests it
+// records once started.
+func NewRecorder(config Config, initial []Request) (*Recorder, error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("a path is required to record requests for warmup")
+	}
+
+	if config.SampleRate < 0 || config.SampleRate > 1 {
+		return nil, fmt.Errorf("warmup sample rate must be between 0 and 1")
+	}
+
+	if config.MaxRequests <= 0 {
+		return nil, fmt.Errorf("warmup max requests must be positive")
+	}
+
+	if config.FlushInterval <= 0 {
+		return nil, fmt.Errorf("warmup flush interval must be positive")
+	}
+
+	if len(initial) > config.MaxRequests {
+		initial = initial[len(initial)-config.MaxRequests:]
+	}
+
+	return &Recorder{
+		config:   config,
+		requests: append([]Request(nil), initial...),
+	}, nil
+}
+
+// Record records the request, if sampled. A nil recorder records nothing.
+func (r *Recorder) Record(ctx context.Context, method string, req proto.Message) {
+	if r == nil || isReplayed(ctx) {
+		return
+	}
+
+	if _, ok := replayableMethods[method]; !ok {
+		return
+	}
+
+	if r.config.SampleRate < 1 && rand.Float64() >= r.config.SampleRate {
+		return
+	}
+
+	marshaled, err := protojson.Marshal(req)
+	if err != nil {
+		log.Ctx(ctx).Debug().Err(err).Str("method", method).Msg("unable to marshal request for warmup")
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.requests = append(r.requests, Request{Method: method, Request: marshaled, RecordedAt: time.Now().UTC()})
+	if len(r.requests) > r.config.MaxRequests {
+		r.requests = append(r.requests[:0:0], r.requests[len(r.requests)-r.config.MaxRequests:]...)
+	}
+	r.dirty = true
+	recordedCounter.Inc()
+}
+
+// Start writes the requests recorded to the file at intervals until the context is cancelled,
+// at which point they are written a final time.
+func (r *Recorder) Start(ctx context.Context) error {
+	ticker := time.NewTicker(r.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), finalFlushTimeout)
+			defer cancel()
+			r.flush(flushCtx)
+			return nil
+
+		case <-ticker.C:
+			r.flush(ctx)
+		}
+	}
+}
+
+// flush writes the requests recorded to the file, if any have been recorded since the last
+// flush.
+func (r *Recorder) flush(ctx context.Context) {
+	r.mu.Lock()
+	if !r.dirty {
+		r.mu.Unlock()
+		return
+	}
+	requests := append([]Request(nil), r.requests...)
+	r.dirty = false
+	r.mu.Unlock()
+
+	if err := WriteRequests(r.config.Path, requests); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Str("path", r.config.Path).Msg("failed to write requests recorded for warmup")
+
+		r.mu.Lock()
+		r.dirty = true
+		r.mu.Unlock()
+	}
+}
+
+// WriteRequests writes the requests to the file as JSON lines.
+func WriteRequests(path string, requests []Request) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, request := range requests {
+		if err := encoder.Encode(request); err != nil {
+			return fmt.Errorf("unable to marshal request: %w", err)
+		}
+	}
+
+	// Write to a temporary file first, so that partially written requests are never replayed.
+	temp := path + ".tmp"
+	if err := os.WriteFile(temp, buf.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("unable to write requests: %w", err)
+	}
+	return os.Rename(temp, path)
+}
+
+// ReadRequests reads the requests written to the file. A file which does not exist holds no
+// requests.
+func ReadRequests(path string) ([]Request, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read requests: %w", err)
+	}
+	defer file.Close()
+
+	var requests []Request
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var request Request
+		if err := json.Unmarshal(scanner.Bytes(), &request); err != nil {
+			return nil, fmt.Errorf("unable to unmarshal request: %w", err)
+		}
+		requests = append(requests, request)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read requests: %w", err)
+	}
+	return requests, nil
+}
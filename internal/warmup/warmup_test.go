@@ -0,0 +1,145 @@
+package warmup_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	tf "github.com/authzed/spicedb/internal/testfixtures"
+	"github.com/authzed/spicedb/internal/testserver"
+	"github.com/authzed/spicedb/internal/warmup"
+)
+
+const (
+	checkMethod  = "/authzed.api.v1.PermissionsService/CheckPermission"
+	lookupMethod = "/authzed.api.v1.PermissionsService/LookupResources"
+)
+
+func checkRequest(resourceID string, permission string) *v1.CheckPermissionRequest {
+	return &v1.CheckPermissionRequest{
+		Resource:   &v1.ObjectReference{ObjectType: "document", ObjectId: resourceID},
+		Permission: permission,
+		Subject:    &v1.SubjectReference{Object: &v1.ObjectReference{ObjectType: "user", ObjectId: "eng_lead"}},
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	require := require.New(t)
+	path := filepath.Join(t.TempDir(), "warmup.jsonl")
+
+	initial, err := warmup.ReadRequests(path)
+	require.NoError(err)
+	require.Empty(initial)
+
+	recorder, err := warmup.NewRecorder(warmup.Config{
+		Path:          path,
+		SampleRate:    1,
+		MaxRequests:   2,
+		FlushInterval: time.Hour,
+	}, nil)
+	require.NoError(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- recorder.Start(ctx) }()
+
+	recorder.Record(ctx, checkMethod, checkRequest("masterplan", "view"))
+	recorder.Record(ctx, checkMethod, checkRequest("companyplan", "view"))
+	recorder.Record(ctx, checkMethod, checkRequest("healthplan", "view"))
+
+	// Requests of other methods, and those replayed, are not recorded.
+	recorder.Record(ctx, "/authzed.api.v1.PermissionsService/WriteRelationships", &v1.WriteRelationshipsRequest{})
+	replayedCtx := metadata.NewIncomingContext(ctx, metadata.Pairs("x-spicedb-warmup", "true"))
+	recorder.Record(replayedCtx, checkMethod, checkRequest("specialplan", "view"))
+
+	cancel()
+	require.NoError(<-done)
+
+	// Only the most recent requests are kept.
+	recorded, err := warmup.ReadRequests(path)
+	require.NoError(err)
+	require.Len(recorded, 2)
+	require.Equal(checkMethod, recorded[0].Method)
+	require.Contains(string(recorded[0].Request), "companyplan")
+	require.Contains(string(recorded[1].Request), "healthplan")
+
+	// Recorders started from the file keep its requests until replaced.
+	restarted, err := warmup.NewRecorder(warmup.Config{
+		Path:          path,
+		SampleRate:    1,
+		MaxRequests:   2,
+		FlushInterval: time.Hour,
+	}, recorded)
+	require.NoError(err)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() { done <- restarted.Start(ctx) }()
+	restarted.Record(ctx, checkMethod, checkRequest("specialplan", "view"))
+	cancel()
+	require.NoError(<-done)
+
+	recorded, err = warmup.ReadRequests(path)
+	require.NoError(err)
+	require.Len(recorded, 2)
+	require.Contains(string(recorded[0].Request), "healthplan")
+	require.Contains(string(recorded[1].Request), "specialplan")
+}
+
+func TestNewRecorderErrors(t *testing.T) {
+	for _, config := range []warmup.Config{
+		{SampleRate: 1, MaxRequests: 1, FlushInterval: time.Second},
+		{Path: "warmup.jsonl", SampleRate: 2, MaxRequests: 1, FlushInterval: time.Second},
+		{Path: "warmup.jsonl", SampleRate: 1, FlushInterval: time.Second},
+		{Path: "warmup.jsonl", SampleRate: 1, MaxRequests: 1},
+	} {
+		_, err := warmup.NewRecorder(config, nil)
+		require.Error(t, err)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	require := require.New(t)
+	conn, cleanup, _, _ := testserver.NewTestServer(require, 0, memdb.DisableGC, true, tf.StandardDatastoreWithData)
+	t.Cleanup(cleanup)
+
+	path := filepath.Join(t.TempDir(), "warmup.jsonl")
+	recorder, err := warmup.NewRecorder(warmup.Config{
+		Path:          path,
+		SampleRate:    1,
+		MaxRequests:   10,
+		FlushInterval: time.Hour,
+	}, nil)
+	require.NoError(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- recorder.Start(ctx) }()
+
+	recorder.Record(ctx, checkMethod, checkRequest("masterplan", "view"))
+	recorder.Record(ctx, checkMethod, checkRequest("masterplan", "unknown"))
+	recorder.Record(ctx, lookupMethod, &v1.LookupResourcesRequest{
+		ResourceObjectType: "document",
+		Permission:         "view",
+		Subject:            &v1.SubjectReference{Object: &v1.ObjectReference{ObjectType: "user", ObjectId: "eng_lead"}},
+	})
+	cancel()
+	require.NoError(<-done)
+
+	requests, err := warmup.ReadRequests(path)
+	require.NoError(err)
+	require.Len(requests, 3)
+
+	stats := warmup.Replay(context.Background(), conn, requests, 2)
+	require.Equal(warmup.ReplayStats{Replayed: 2, Failed: 1}, stats)
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	stats = warmup.Replay(canceled, conn, requests, 2)
+	require.Equal(warmup.ReplayStats{Skipped: 3}, stats)
+}
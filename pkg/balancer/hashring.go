@@ -1,6 +1,8 @@
 package balancer
 
 import (
+	"encoding/json"
+	"fmt"
 	"math/rand"
 	"sync"
 	"time"
@@ -8,6 +10,7 @@ import (
 	"google.golang.org/grpc/balancer"
 	"google.golang.org/grpc/balancer/base"
 	"google.golang.org/grpc/grpclog"
+	"google.golang.org/grpc/serviceconfig"
 
 	"github.com/authzed/spicedb/pkg/consistent"
 )
@@ -25,20 +28,104 @@ const (
 	// CtxKey is the key for the grpc request's context.Context which points to
 	// the key to hash for the request. The value it points to must be []byte
 	CtxKey ctxKey = "requestKey"
+
+	// PickObserverCtxKey is the key for the grpc request's context.Context which
+	// points to an optional PickObserver for the request.
+	PickObserverCtxKey ctxKey = "pickObserver"
 )
 
+// PickObserver observes the member of the hashring picked for a request.
+type PickObserver interface {
+	// Picked is invoked with the key of the member picked for the request. If
+	// it returns an error, which should be a status error, the request fails
+	// with it rather than being sent to the member.
+	Picked(memberKey string) error
+
+	// Done is invoked with the key of the member and the error of the request,
+	// if any, once it has completed.
+	Done(memberKey string, err error)
+}
+
+// PickFilter may be implemented by a PickObserver to restrict the members picked
+// for its request to those it accepts. If it accepts none of the members the
+// request may be sent to, one of them is picked as though there were no filter.
+type PickFilter interface {
+	// Accepts returns whether the member may be picked for the request.
+	Accepts(memberKey string) bool
+}
+
 var logger = grpclog.Component("consistenthashring")
 
 // NewConsistentHashringBuilder creates a new balancer.Builder that
 // will create a consistent hashring balancer with the given config.
 // Before making a connection, register it with grpc with:
 // `balancer.Register(consistent.NewConsistentHashringBuilder(hasher, factor, spread))`
+//
+// Connections whose service config is LoadAwareServiceConfig additionally route a
+// bounded fraction of requests away from overloaded members, based upon the load
+// they report via LoadTrailerKey.
 func NewConsistentHashringBuilder(hasher consistent.HasherFunc, replicationFactor uint16, spread uint8) balancer.Builder {
-	return base.NewBalancerBuilder(
-		BalancerName,
-		&consistentHashringPickerBuilder{hasher: hasher, replicationFactor: replicationFactor, spread: spread},
-		base.Config{HealthCheck: true},
-	)
+	return &consistentHashringBuilder{hasher: hasher, replicationFactor: replicationFactor, spread: spread}
+}
+
+type consistentHashringBuilder struct {
+	hasher            consistent.HasherFunc
+	replicationFactor uint16
+	spread            uint8
+}
+
+func (b *consistentHashringBuilder) Name() string {
+	return BalancerName
+}
+
+func (b *consistentHashringBuilder) Build(cc balancer.ClientConn, opts balancer.BuildOptions) balancer.Balancer {
+	// Each balancer has its own picker builder, as the load-aware config is set per connection.
+	pickerBuilder := &consistentHashringPickerBuilder{
+		hasher:            b.hasher,
+		replicationFactor: b.replicationFactor,
+		spread:            b.spread,
+	}
+	return &consistentHashringBalancer{
+		Balancer:      base.NewBalancerBuilder(BalancerName, pickerBuilder, base.Config{HealthCheck: true}).Build(cc, opts),
+		pickerBuilder: pickerBuilder,
+	}
+}
+
+func (b *consistentHashringBuilder) ParseConfig(js json.RawMessage) (serviceconfig.LoadBalancingConfig, error) {
+	config := &loadAwareBalancingConfig{}
+	if err := json.Unmarshal(js, &config.LoadAwareConfig); err != nil {
+		return nil, fmt.Errorf("invalid consistent hashring config: %w", err)
+	}
+	if err := config.LoadAwareConfig.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+type loadAwareBalancingConfig struct {
+	serviceconfig.LoadBalancingConfig
+	LoadAwareConfig
+}
+
+// consistentHashringBalancer is a base balancer which applies the load-aware config
+// of its connection, if any, to its picker builder.
+type consistentHashringBalancer struct {
+	balancer.Balancer
+	pickerBuilder *consistentHashringPickerBuilder
+}
+
+func (b *consistentHashringBalancer) UpdateClientConnState(state balancer.ClientConnState) error {
+	// Updates are not concurrent with each other, nor with the pickers being built.
+	if config, ok := state.BalancerConfig.(*loadAwareBalancingConfig); ok {
+		b.pickerBuilder.setLoadAware(config.LoadAwareConfig)
+	}
+	return b.Balancer.UpdateClientConnState(state)
+}
+
+func (b *consistentHashringBalancer) ExitIdle() {
+	if exitIdler, ok := b.Balancer.(balancer.ExitIdler); ok {
+		exitIdler.ExitIdle()
+	}
 }
 
 type subConnMember struct {
@@ -58,51 +145,133 @@ type consistentHashringPickerBuilder struct {
 	hasher            consistent.HasherFunc
 	replicationFactor uint16
 	spread            uint8
+
+	// loadAware and loads are only set for load-aware balancers. The loads are
+	// kept on the builder, so that they survive pickers being rebuilt.
+	loadAware *LoadAwareConfig
+	loads     *loadTracker
+}
+
+func (b *consistentHashringPickerBuilder) setLoadAware(config LoadAwareConfig) {
+	if config.MaxOffloadFraction == 0 {
+		b.loadAware, b.loads = nil, nil
+		return
+	}
+
+	b.loadAware = &config
+	if b.loads == nil {
+		b.loads = newLoadTracker(config.ReportTTL)
+	}
 }
 
 func (b *consistentHashringPickerBuilder) Build(info base.PickerBuildInfo) balancer.Picker {
 	logger.Infof("consistentHashringPicker: Build called with info: %v", info)
 	if len(info.ReadySCs) == 0 {
+		recordBuiltMembers(nil, b.loads)
 		return base.NewErrPicker(balancer.ErrNoSubConnAvailable)
 	}
 
 	hashring := consistent.MustNewHashring(b.hasher, b.replicationFactor)
+	keys := make([]string, 0, len(info.ReadySCs))
 	for sc, scInfo := range info.ReadySCs {
-		if err := hashring.Add(subConnMember{
+		member := subConnMember{
 			SubConn: sc,
 			key:     scInfo.Address.Addr + scInfo.Address.ServerName,
-		}); err != nil {
+		}
+		if err := hashring.Add(member); err != nil {
 			return base.NewErrPicker(err)
 		}
+		keys = append(keys, member.key)
 	}
+	recordBuiltMembers(keys, b.loads)
 	return &consistentHashringPicker{
-		hashring: hashring,
-		spread:   b.spread,
-		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+		hashring:    hashring,
+		memberCount: len(info.ReadySCs),
+		spread:      b.spread,
+		rand:        rand.New(rand.NewSource(time.Now().UnixNano())),
+		loadAware:   b.loadAware,
+		loads:       b.loads,
 	}
 }
 
 type consistentHashringPicker struct {
 	sync.Mutex
-	hashring *consistent.Hashring
-	spread   uint8
-	rand     *rand.Rand
+	hashring    *consistent.Hashring
+	memberCount int
+	spread      uint8
+	rand        *rand.Rand
+	loadAware   *LoadAwareConfig
+	loads       *loadTracker
 }
 
 func (p *consistentHashringPicker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
 	key := info.Ctx.Value(CtxKey).([]byte)
-	members, err := p.hashring.FindN(key, p.spread)
+	observer, _ := info.Ctx.Value(PickObserverCtxKey).(PickObserver)
+	filter, _ := observer.(PickFilter)
+
+	var result balancer.PickResult
+	var chosen subConnMember
+	var err error
+	if p.loadAware != nil {
+		result, chosen, err = p.pickLoadAware(key, filter)
+	} else {
+		result, chosen, err = p.pickMember(key, filter)
+	}
 	if err != nil {
 		return balancer.PickResult{}, err
 	}
 
+	if observer == nil {
+		return result, nil
+	}
+
+	if err := observer.Picked(chosen.key); err != nil {
+		return balancer.PickResult{}, err
+	}
+
+	done := result.Done
+	result.Done = func(info balancer.DoneInfo) {
+		if done != nil {
+			done(info)
+		}
+		observer.Done(chosen.key, info.Err)
+	}
+	return result, nil
+}
+
+func (p *consistentHashringPicker) pickMember(key []byte, filter PickFilter) (balancer.PickResult, subConnMember, error) {
+	members, err := p.hashring.FindN(key, p.spread)
+	if err != nil {
+		return balancer.PickResult{}, subConnMember{}, err
+	}
+	members, _ = filterMembers(members, filter)
+
 	// rand is not safe for concurrent use
 	p.Lock()
-	index := p.rand.Intn(int(p.spread))
+	index := p.rand.Intn(len(members))
 	p.Unlock()
 
 	chosen := members[index].(subConnMember)
 	return balancer.PickResult{
 		SubConn: chosen.SubConn,
-	}, nil
+	}, chosen, nil
+}
+
+// filterMembers returns the members accepted by the filter, and whether there
+// were any. If there were none, all of the members are returned.
+func filterMembers(members []consistent.Member, filter PickFilter) ([]consistent.Member, bool) {
+	if filter == nil {
+		return members, true
+	}
+
+	accepted := make([]consistent.Member, 0, len(members))
+	for _, member := range members {
+		if filter.Accepts(member.(subConnMember).key) {
+			accepted = append(accepted, member)
+		}
+	}
+	if len(accepted) == 0 {
+		return members, false
+	}
+	return accepted, true
 }
@@ -0,0 +1,195 @@
+package balancer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/metadata"
+)
+
+// LoadTrailerKey is the trailer in which servers report their current load (the
+// number of requests in flight) to load-aware balancers.
+const LoadTrailerKey = "io.spicedb.load"
+
+// LoadAwareConfig configures how a load-aware consistent hashring balancer routes
+// requests away from overloaded members.
+type LoadAwareConfig struct {
+	// OverloadThreshold is the reported load above which a member is considered
+	// overloaded.
+	OverloadThreshold uint64 `json:"overloadThreshold"`
+
+	// MaxOffloadFraction is the maximum fraction, between 0 and 1, of the requests
+	// for an overloaded member that will be routed to a less loaded replica of the
+	// key's range instead. 0 disables load-aware routing.
+	MaxOffloadFraction float64 `json:"maxOffloadFraction"`
+
+	// ReportTTL is how long a reported load is considered valid. Defaults to 10s.
+	ReportTTL time.Duration `json:"reportTTL"`
+}
+
+// Validate returns an error if the config is invalid.
+func (c LoadAwareConfig) Validate() error {
+	if c.MaxOffloadFraction < 0 || c.MaxOffloadFraction > 1 {
+		return fmt.Errorf("max offload fraction must be between 0 and 1, got %v", c.MaxOffloadFraction)
+	}
+	return nil
+}
+
+// LoadAwareServiceConfig returns a service config that sets the default balancer to
+// the consistent-hashring balancer, routing requests away from overloaded members
+// as configured.
+func LoadAwareServiceConfig(config LoadAwareConfig) (string, error) {
+	if err := config.Validate(); err != nil {
+		return "", err
+	}
+
+	balancerConfig, err := json.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`{"loadBalancingConfig":[{%q:%s}]}`, BalancerName, balancerConfig), nil
+}
+
+type loadReport struct {
+	load       uint64
+	reportedAt time.Time
+}
+
+type loadTracker struct {
+	sync.RWMutex
+	ttl     time.Duration
+	reports map[string]loadReport
+}
+
+func newLoadTracker(ttl time.Duration) *loadTracker {
+	if ttl <= 0 {
+		ttl = 10 * time.Second
+	}
+	return &loadTracker{ttl: ttl, reports: make(map[string]loadReport)}
+}
+
+// record records the load reported in the trailer for the member, if any.
+func (lt *loadTracker) record(memberKey string, trailer metadata.MD) {
+	values := trailer.Get(LoadTrailerKey)
+	if len(values) == 0 {
+		return
+	}
+
+	load, err := strconv.ParseUint(values[0], 10, 64)
+	if err != nil {
+		logger.Warningf("consistentHashringPicker: invalid load report %q: %v", values[0], err)
+		return
+	}
+
+	lt.Lock()
+	defer lt.Unlock()
+	lt.reports[memberKey] = loadReport{load: load, reportedAt: time.Now()}
+}
+
+// load returns the last load reported by the member, or false if none has been
+// reported within the TTL.
+func (lt *loadTracker) load(memberKey string) (uint64, bool) {
+	lt.RLock()
+	defer lt.RUnlock()
+
+	report, ok := lt.reports[memberKey]
+	if !ok || time.Since(report.reportedAt) > lt.ttl {
+		return 0, false
+	}
+	return report.load, true
+}
+
+func (p *consistentHashringPicker) pickLoadAware(key []byte, filter PickFilter) (balancer.PickResult, subConnMember, error) {
+	// Find an additional member beyond the spread, if one exists, to act as a
+	// secondary replica for the key's range.
+	num := p.spread
+	if int(num) < p.memberCount {
+		num++
+	}
+
+	members, err := p.hashring.FindN(key, num)
+	if err != nil {
+		return balancer.PickResult{}, subConnMember{}, err
+	}
+
+	// The secondary replica is only picked without offloading if the filter
+	// accepts it, but none of the members within the spread.
+	primaries, ok := filterMembers(members[:p.spread], filter)
+	if !ok && len(members) > int(p.spread) {
+		if secondary, ok := filterMembers(members[p.spread:], filter); ok {
+			primaries = secondary
+		}
+	}
+	candidates, _ := filterMembers(members, filter)
+
+	// rand is not safe for concurrent use
+	p.Lock()
+	index := p.rand.Intn(len(primaries))
+	offload := p.rand.Float64() < p.loadAware.MaxOffloadFraction
+	p.Unlock()
+
+	chosen := primaries[index].(subConnMember)
+	if primaryLoad, ok := p.loads.load(chosen.key); offload && ok && primaryLoad > p.loadAware.OverloadThreshold {
+		bestLoad := primaryLoad
+		for _, member := range candidates {
+			candidate := member.(subConnMember)
+			if candidate.key == chosen.key {
+				continue
+			}
+
+			// Members which have not recently reported a load have not recently
+			// served any requests, and are therefore assumed to be idle.
+			candidateLoad, _ := p.loads.load(candidate.key)
+			if candidateLoad < bestLoad {
+				chosen = candidate
+				bestLoad = candidateLoad
+			}
+		}
+	}
+
+	return balancer.PickResult{
+		SubConn: chosen.SubConn,
+		Done: func(info balancer.DoneInfo) {
+			p.loads.record(chosen.key, info.Trailer)
+		},
+	}, chosen, nil
+}
+
+var inflightRequests atomic.Int64
+
+// LoadReportingUnaryServerInterceptor returns a new unary server interceptor that
+// reports the number of requests in flight on this server via LoadTrailerKey.
+func LoadReportingUnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		inflightRequests.Add(1)
+		defer inflightRequests.Add(-1)
+
+		resp, err := handler(ctx, req)
+		_ = grpc.SetTrailer(ctx, loadTrailer())
+		return resp, err
+	}
+}
+
+// LoadReportingStreamServerInterceptor returns a new stream server interceptor that
+// reports the number of requests in flight on this server via LoadTrailerKey.
+func LoadReportingStreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		inflightRequests.Add(1)
+		defer inflightRequests.Add(-1)
+
+		err := handler(srv, stream)
+		stream.SetTrailer(loadTrailer())
+		return err
+	}
+}
+
+func loadTrailer() metadata.MD {
+	return metadata.Pairs(LoadTrailerKey, strconv.FormatInt(inflightRequests.Load(), 10))
+}
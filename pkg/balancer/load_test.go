@@ -0,0 +1,241 @@
+package balancer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/resolver"
+)
+
+type fakeSubConn struct {
+	balancer.SubConn
+	name string
+}
+
+func buildLoadAwarePicker(config LoadAwareConfig, names ...string) balancer.Picker {
+	builder := &consistentHashringPickerBuilder{
+		hasher:            xxhash.Sum64,
+		replicationFactor: 20,
+		spread:            1,
+		loadAware:         &config,
+		loads:             newLoadTracker(config.ReportTTL),
+	}
+
+	readySCs := make(map[balancer.SubConn]base.SubConnInfo, len(names))
+	for _, name := range names {
+		readySCs[&fakeSubConn{name: name}] = base.SubConnInfo{Address: resolver.Address{Addr: name}}
+	}
+	return builder.Build(base.PickerBuildInfo{ReadySCs: readySCs})
+}
+
+func pick(t *testing.T, picker balancer.Picker, key string, reportedLoad string) string {
+	result, err := picker.Pick(balancer.PickInfo{Ctx: context.WithValue(context.Background(), CtxKey, []byte(key))})
+	require.NoError(t, err)
+	result.Done(balancer.DoneInfo{Trailer: metadata.Pairs(LoadTrailerKey, reportedLoad)})
+	return result.SubConn.(*fakeSubConn).name
+}
+
+func TestLoadAwarePicker(t *testing.T) {
+	for _, tc := range []struct {
+		name               string
+		maxOffloadFraction float64
+		primaryLoad        string
+		expectOffload      bool
+	}{
+		{"primary not overloaded", 1, "5", false},
+		{"primary overloaded", 1, "100", true},
+		{"offloading disabled", 0, "100", false},
+		{"invalid load report", 1, "notanumber", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			picker := buildLoadAwarePicker(LoadAwareConfig{
+				OverloadThreshold:  10,
+				MaxOffloadFraction: tc.maxOffloadFraction,
+			}, "first", "second", "third")
+
+			primary := pick(t, picker, "somekey", tc.primaryLoad)
+			chosen := pick(t, picker, "somekey", "1")
+			if tc.expectOffload {
+				require.NotEqual(t, primary, chosen)
+			} else {
+				require.Equal(t, primary, chosen)
+			}
+		})
+	}
+}
+
+func TestLoadAwarePickerSingleMember(t *testing.T) {
+	picker := buildLoadAwarePicker(LoadAwareConfig{OverloadThreshold: 10, MaxOffloadFraction: 1}, "first")
+
+	require.Equal(t, "first", pick(t, picker, "somekey", "100"))
+	require.Equal(t, "first", pick(t, picker, "somekey", "100"))
+}
+
+type refusingObserver struct {
+	refused string
+	done    map[string]error
+}
+
+func (ro *refusingObserver) Picked(memberKey string) error {
+	if memberKey == ro.refused {
+		return errors.New("refused")
+	}
+	return nil
+}
+
+func (ro *refusingObserver) Done(memberKey string, err error) {
+	ro.done[memberKey] = err
+}
+
+func TestPickObserver(t *testing.T) {
+	picker := buildLoadAwarePicker(LoadAwareConfig{}, "first", "second")
+	observer := &refusingObserver{done: make(map[string]error)}
+
+	refusedOnce := false
+	for i := 0; i < 100; i++ {
+		ctx := context.WithValue(context.Background(), CtxKey, []byte(strconv.Itoa(i)))
+		ctx = context.WithValue(ctx, PickObserverCtxKey, PickObserver(observer))
+
+		observer.refused = "first"
+		result, err := picker.Pick(balancer.PickInfo{Ctx: ctx})
+		if err != nil {
+			refusedOnce = true
+			continue
+		}
+
+		name := result.SubConn.(*fakeSubConn).name
+		require.Equal(t, "second", name)
+		failure := errors.New("failed")
+		result.Done(balancer.DoneInfo{Err: failure})
+		require.Equal(t, failure, observer.done["second"])
+	}
+	require.True(t, refusedOnce)
+}
+
+type acceptingObserver struct {
+	accepted string
+}
+
+func (ao *acceptingObserver) Accepts(memberKey string) bool {
+	return memberKey == ao.accepted
+}
+
+func (ao *acceptingObserver) Picked(string) error { return nil }
+
+func (ao *acceptingObserver) Done(string, error) {}
+
+func TestPickFilter(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		loadAware *LoadAwareConfig
+		names     []string
+	}{
+		{"within spread", nil, []string{"first", "second"}},
+		{"load-aware with secondary", &LoadAwareConfig{OverloadThreshold: 10, MaxOffloadFraction: 1}, []string{"first", "second", "third"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			builder := &consistentHashringPickerBuilder{hasher: xxhash.Sum64, replicationFactor: 20, spread: 2}
+			if tc.loadAware != nil {
+				builder.setLoadAware(*tc.loadAware)
+			}
+
+			readySCs := make(map[balancer.SubConn]base.SubConnInfo, len(tc.names))
+			for _, name := range tc.names {
+				readySCs[&fakeSubConn{name: name}] = base.SubConnInfo{Address: resolver.Address{Addr: name}}
+			}
+			picker := builder.Build(base.PickerBuildInfo{ReadySCs: readySCs})
+
+			picked := make(map[string]struct{})
+			for i := 0; i < 100; i++ {
+				key := context.WithValue(context.Background(), CtxKey, []byte(strconv.Itoa(i)))
+
+				// Every member is a candidate for every key, so only the accepted member is picked.
+				result, err := picker.Pick(balancer.PickInfo{Ctx: context.WithValue(key, PickObserverCtxKey, PickObserver(&acceptingObserver{accepted: "second"}))})
+				require.NoError(t, err)
+				require.Equal(t, "second", result.SubConn.(*fakeSubConn).name)
+
+				// If no member is accepted, one is picked regardless.
+				result, err = picker.Pick(balancer.PickInfo{Ctx: context.WithValue(key, PickObserverCtxKey, PickObserver(&acceptingObserver{accepted: "unknown"}))})
+				require.NoError(t, err)
+				picked[result.SubConn.(*fakeSubConn).name] = struct{}{}
+			}
+			require.Greater(t, len(picked), 1)
+		})
+	}
+}
+
+func TestMembers(t *testing.T) {
+	picker := buildLoadAwarePicker(LoadAwareConfig{OverloadThreshold: 10, MaxOffloadFraction: 1}, "b", "a", "c")
+	pick(t, picker, "key", "7")
+
+	members := Members()
+	require.Len(t, members, 3)
+	require.Equal(t, []string{"a", "b", "c"}, []string{members[0].Key, members[1].Key, members[2].Key})
+
+	var reported []Member
+	for _, member := range members {
+		if member.LoadReported {
+			reported = append(reported, member)
+		}
+	}
+	require.Len(t, reported, 1)
+	require.Equal(t, uint64(7), reported[0].Load)
+}
+
+func TestLoadAwareServiceConfig(t *testing.T) {
+	builder := NewConsistentHashringBuilder(xxhash.Sum64, 20, 1)
+	balancer.Register(builder)
+
+	serviceConfig, err := LoadAwareServiceConfig(LoadAwareConfig{OverloadThreshold: 10, MaxOffloadFraction: 0.5})
+	require.NoError(t, err)
+
+	// The service config is accepted by grpc.
+	conn, err := grpc.Dial("passthrough:///localhost:0", grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithDefaultServiceConfig(serviceConfig))
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+
+	var parsed struct {
+		LoadBalancingConfig []map[string]json.RawMessage `json:"loadBalancingConfig"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(serviceConfig), &parsed))
+	require.Len(t, parsed.LoadBalancingConfig, 1)
+
+	config, err := builder.(balancer.ConfigParser).ParseConfig(parsed.LoadBalancingConfig[0][BalancerName])
+	require.NoError(t, err)
+	require.Equal(t, LoadAwareConfig{OverloadThreshold: 10, MaxOffloadFraction: 0.5}, config.(*loadAwareBalancingConfig).LoadAwareConfig)
+
+	_, err = builder.(balancer.ConfigParser).ParseConfig(json.RawMessage(`{"maxOffloadFraction":2}`))
+	require.Error(t, err)
+
+	_, err = LoadAwareServiceConfig(LoadAwareConfig{MaxOffloadFraction: -1})
+	require.Error(t, err)
+}
+
+func TestSetLoadAware(t *testing.T) {
+	builder := &consistentHashringPickerBuilder{hasher: xxhash.Sum64, replicationFactor: 20, spread: 1}
+
+	builder.setLoadAware(LoadAwareConfig{OverloadThreshold: 10, MaxOffloadFraction: 1})
+	require.NotNil(t, builder.loadAware)
+	loads := builder.loads
+	require.NotNil(t, loads)
+
+	// Loads survive the config being updated.
+	builder.setLoadAware(LoadAwareConfig{OverloadThreshold: 20, MaxOffloadFraction: 1})
+	require.Equal(t, uint64(20), builder.loadAware.OverloadThreshold)
+	require.Same(t, loads, builder.loads)
+
+	// A zero offload fraction disables load-aware routing.
+	builder.setLoadAware(LoadAwareConfig{OverloadThreshold: 20})
+	require.Nil(t, builder.loadAware)
+	require.Nil(t, builder.loads)
+}
@@ -0,0 +1,57 @@
+package balancer
+
+import (
+	"sort"
+	"sync"
+)
+
+// Member is a ready member of a consistent hashring.
+type Member struct {
+	// Key is the key of the member on the hashring: its address and server name.
+	Key string
+
+	// Load is the load last reported by the member, valid if LoadReported is true.
+	Load         uint64
+	LoadReported bool
+}
+
+// builtMembers are the members of the hashring most recently built by any consistent hashring
+// balancer, along with the loads tracked by its builder, if it is load-aware.
+var builtMembers struct {
+	sync.RWMutex
+	keys  []string
+	loads *loadTracker
+}
+
+func recordBuiltMembers(keys []string, loads *loadTracker) {
+	sort.Strings(keys)
+
+	builtMembers.Lock()
+	defer builtMembers.Unlock()
+	builtMembers.keys = keys
+	builtMembers.loads = loads
+}
+
+// Members returns the ready members of the hashring most recently built by a consistent hashring
+// balancer, such as that of the connections to dispatch peers, ordered by key. For load-aware
+// balancers, each member carries the load it last reported within the report TTL.
+func Members() []Member {
+	builtMembers.RLock()
+	defer builtMembers.RUnlock()
+
+	members := make([]Member, 0, len(builtMembers.keys))
+	for _, key := range builtMembers.keys {
+		member := Member{Key: key}
+		if builtMembers.loads != nil {
+			member.Load, member.LoadReported = builtMembers.loads.load(key)
+		}
+		members = append(members, member)
+	}
+	return members
+}
+
+// InflightRequests returns the number of requests in flight on this server, as reported via
+// LoadTrailerKey by the load reporting interceptors.
+func InflightRequests() int64 {
+	return inflightRequests.Load()
+}
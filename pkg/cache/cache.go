@@ -56,6 +56,9 @@ type Cache interface {
 	// Wait waits for the cache to process and apply updates.
 	Wait()
 
+	// SetMaxCost changes the capacity of the cache, evicting entries as needed if it shrinks.
+	SetMaxCost(cost int64)
+
 	// Close closes the cache's background workers (if any).
 	Close()
 
@@ -90,6 +93,7 @@ var _ Cache = (*noopCache)(nil)
 func (no *noopCache) Get(key any) (any, bool)             { return nil, false }
 func (no *noopCache) Set(key, entry any, cost int64) bool { return false }
 func (no *noopCache) Wait()                               {}
+func (no *noopCache) SetMaxCost(cost int64)               {}
 func (no *noopCache) Close()                              {}
 func (no *noopCache) GetMetrics() Metrics                 { return &noopMetrics{} }
 func (no *noopCache) MarshalZerologObject(e *zerolog.Event) {
@@ -66,6 +66,7 @@ func (w wrapped) Set(key, entry any, cost int64) bool {
 
 var _ Cache = (*wrapped)(nil)
 
+func (w wrapped) SetMaxCost(cost int64)                 { w.Cache.UpdateMaxCost(cost) }
 func (w wrapped) GetMetrics() Metrics                   { return w.Cache.Metrics }
 func (w wrapped) MarshalZerologObject(e *zerolog.Event) { e.EmbedObject(w.config) }
 
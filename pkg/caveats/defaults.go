@@ -0,0 +1,66 @@
+package caveats
+
+import (
+	"fmt"
+	"reflect"
+
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/authzed/spicedb/pkg/caveats/types"
+)
+
+var structValueType = reflect.TypeOf(&structpb.Value{})
+
+// EvaluateParameterDefault evaluates the expression given as the default value of a parameter
+// of the given type, returning the value in the form it would take if supplied in a context.
+// The expression cannot reference any parameters.
+func EvaluateParameterDefault(exprString string, paramType types.VariableType) (*structpb.Value, error) {
+	celEnv, err := NewEnvironment().asCelEnvironment()
+	if err != nil {
+		return nil, err
+	}
+
+	ast, issues := celEnv.Compile(exprString)
+	if issues != nil && issues.Err() != nil {
+		return nil, CompilationErrors{issues.Err(), issues}
+	}
+
+	prg, err := celEnv.Program(ast)
+	if err != nil {
+		return nil, err
+	}
+
+	val, _, err := prg.Eval(map[string]any{})
+	if err != nil {
+		return nil, EvaluationErr{err}
+	}
+
+	native, err := val.ConvertToNative(structValueType)
+	if err != nil {
+		return nil, fmt.Errorf("default value `%s` cannot be used in a context: %w", exprString, err)
+	}
+
+	value := native.(*structpb.Value)
+	if _, err := paramType.ConvertValue(value.AsInterface()); err != nil {
+		return nil, fmt.Errorf("default value `%s` is not a valid %s: %w", exprString, paramType.String(), err)
+	}
+
+	return value, nil
+}
+
+// WithParameterDefaults returns the given context with the defaults added for all parameters
+// not found within it.
+func WithParameterDefaults(contextMap map[string]any, defaults map[string]*structpb.Value) map[string]any {
+	if len(defaults) == 0 {
+		return contextMap
+	}
+
+	withDefaults := make(map[string]any, len(contextMap)+len(defaults))
+	for name, value := range defaults {
+		withDefaults[name] = value.AsInterface()
+	}
+	for name, value := range contextMap {
+		withDefaults[name] = value
+	}
+	return withDefaults
+}
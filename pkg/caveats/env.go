@@ -60,13 +60,14 @@ func (e *Environment) EncodedParametersTypes() map[string]*core.CaveatTypeRefere
 
 // asCelEnvironment converts the exported Environment into an internal CEL environment.
 func (e *Environment) asCelEnvironment() (*cel.Env, error) {
-	opts := make([]cel.EnvOption, 0, len(e.variables)+len(types.CustomTypes)+2)
+	opts := make([]cel.EnvOption, 0, len(e.variables)+len(types.CustomTypes)+len(builtinFunctions)+2)
 
 	// Add the custom types and functions.
 	for _, customTypeOpts := range types.CustomTypes {
 		opts = append(opts, customTypeOpts...)
 	}
 	opts = append(opts, types.CustomMethodsOnTypes...)
+	opts = append(opts, builtinFunctions...)
 
 	// Set options.
 	// DefaultUTCTimeZone: ensure all timestamps are evaluated at UTC
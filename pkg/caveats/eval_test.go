@@ -293,6 +293,46 @@ func TestEvaluateCaveat(t *testing.T) {
 			"",
 			noMissingVars,
 		},
+		{
+			"now before expiration",
+			MustEnvForVariables(map[string]types.VariableType{
+				"expiration": types.TimestampType,
+			}),
+			"now() < expiration",
+			map[string]any{
+				"expiration": time.Now().Add(time.Hour),
+			},
+			"",
+			true,
+			"",
+			noMissingVars,
+		},
+		{
+			"now after expiration",
+			MustEnvForVariables(map[string]types.VariableType{
+				"expiration": types.TimestampType,
+			}),
+			"now() < expiration",
+			map[string]any{
+				"expiration": time.Now().Add(-time.Hour),
+			},
+			"",
+			false,
+			"",
+			noMissingVars,
+		},
+		{
+			"now with missing expiration",
+			MustEnvForVariables(map[string]types.VariableType{
+				"expiration": types.TimestampType,
+			}),
+			"now() < expiration",
+			map[string]any{},
+			"",
+			false,
+			"now() < expiration",
+			[]string{"expiration"},
+		},
 	}
 
 	for _, tc := range tcs {
@@ -402,3 +442,32 @@ func TestEvalWithNesting(t *testing.T) {
 	require.False(t, result.Value())
 	require.False(t, result.IsPartial())
 }
+
+func TestIsTimeDependent(t *testing.T) {
+	env := MustEnvForVariables(map[string]types.VariableType{
+		"expiration": types.TimestampType,
+		"a":          types.IntType,
+	})
+
+	for _, tc := range []struct {
+		expr          string
+		timeDependent bool
+	}{
+		{"a == 42", false},
+		{"now() < expiration", true},
+		{"a == 42 || now() < expiration", true},
+		{"expiration > timestamp('2023-01-01T00:00:00Z')", false},
+	} {
+		t.Run(tc.expr, func(t *testing.T) {
+			compiled, err := compileCaveat(env, tc.expr)
+			require.NoError(t, err)
+
+			serialized, err := compiled.Serialize()
+			require.NoError(t, err)
+
+			timeDependent, err := IsTimeDependent(serialized)
+			require.NoError(t, err)
+			require.Equal(t, tc.timeDependent, timeDependent)
+		})
+	}
+}
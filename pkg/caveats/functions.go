@@ -0,0 +1,44 @@
+package caveats
+
+import (
+	"time"
+
+	"github.com/google/cel-go/cel"
+	celtypes "github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+
+	impl "github.com/authzed/spicedb/pkg/proto/impl/v1"
+)
+
+// nowOverloadID is the ID of the overload of now(), as recorded in checked expressions.
+const nowOverloadID = "now_timestamp"
+
+// builtinFunctions are the functions available to all caveat expressions, beyond those of CEL.
+var builtinFunctions = []cel.EnvOption{
+	// now() returns the time at which the caveat is evaluated.
+	cel.Function("now",
+		cel.Overload(nowOverloadID, []*cel.Type{}, cel.TimestampType,
+			cel.FunctionBinding(func(_ ...ref.Val) ref.Val {
+				return celtypes.Timestamp{Time: time.Now().UTC()}
+			}),
+		),
+	),
+}
+
+// IsTimeDependent returns whether the serialized caveat calls now(), in which case its result
+// for the same context changes over time, and must not be cached.
+func IsTimeDependent(serialized []byte) (bool, error) {
+	caveat := &impl.DecodedCaveat{}
+	if err := caveat.UnmarshalVT(serialized); err != nil {
+		return false, err
+	}
+
+	for _, reference := range caveat.GetCel().GetReferenceMap() {
+		for _, overloadID := range reference.OverloadId {
+			if overloadID == nowOverloadID {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
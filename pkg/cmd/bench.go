@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jzelinskie/cobrautil/v2"
+	"github.com/spf13/cobra"
+
+	"github.com/authzed/spicedb/internal/benchmark"
+	"github.com/authzed/spicedb/pkg/cmd/server"
+)
+
+func RegisterBenchFlags(cmd *cobra.Command) {
+	workloads := make([]string, 0, len(benchmark.Workloads))
+	for _, workload := range benchmark.Workloads {
+		workloads = append(workloads, string(workload))
+	}
+
+	cmd.Flags().String("workload", string(benchmark.DeepNesting), fmt.Sprintf(`shape of the generated schema and relationships ("%s")`, strings.Join(workloads, `", "`)))
+	cmd.Flags().Int("depth", 10, "nesting depth of the generated groups or folders")
+	cmd.Flags().Int("width", 50, "fan-out of the generated groups or folders, and number of resources")
+	cmd.Flags().Int64("seed", 0, "seed for the random placement of generated relationships")
+	cmd.Flags().Int("iterations", 1000, "number of requests to make for each operation")
+	cmd.Flags().Int("concurrency", 8, "number of requests to make in parallel")
+	cmd.Flags().Uint16("dispatch-concurrency-limit", 50, "maximum number of parallel goroutines to create for each request or subrequest")
+	cmd.Flags().Bool("json", false, "output the report as JSON")
+	cmd.Flags().String("baseline", "", "path of a JSON report to compare against, failing if any latency percentile regressed")
+	cmd.Flags().Float64("tolerance", 0.2, "fraction by which latency percentiles may exceed the baseline before being reported as regressions")
+}
+
+func NewBenchCommand(programName string) *cobra.Command {
+	return &cobra.Command{
+		Use:     "bench",
+		Short:   "benchmark checks and lookups against generated workloads",
+		Long:    "Generates a synthetic schema and relationships in an in-memory datastore, verifies the results of checks and lookups against it, and reports their latency percentiles.",
+		PreRunE: server.DefaultPreRunE(programName),
+		RunE:    benchCmdFunc,
+		Args:    cobra.ExactArgs(0),
+	}
+}
+
+func benchCmdFunc(cmd *cobra.Command, _ []string) error {
+	report, err := benchmark.Run(cmd.Context(), benchmark.Config{
+		Workload: benchmark.Workload(cobrautil.MustGetString(cmd, "workload")),
+		Params: benchmark.Params{
+			Depth: cobrautil.MustGetInt(cmd, "depth"),
+			Width: cobrautil.MustGetInt(cmd, "width"),
+			Seed:  cobrautil.MustGetInt64(cmd, "seed"),
+		},
+		Iterations:               cobrautil.MustGetInt(cmd, "iterations"),
+		Concurrency:              cobrautil.MustGetInt(cmd, "concurrency"),
+		DispatchConcurrencyLimit: cobrautil.MustGetUint16(cmd, "dispatch-concurrency-limit"),
+	})
+	if err != nil {
+		return err
+	}
+
+	if cobrautil.MustGetBool(cmd, "json") {
+		encoder := json.NewEncoder(cmd.OutOrStdout())
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	} else if err := report.WriteTable(cmd.OutOrStdout()); err != nil {
+		return err
+	}
+
+	baselinePath := cobrautil.MustGetString(cmd, "baseline")
+	if baselinePath == "" {
+		return nil
+	}
+
+	contents, err := os.ReadFile(baselinePath)
+	if err != nil {
+		return fmt.Errorf("failed to read baseline: %w", err)
+	}
+
+	var baseline benchmark.Report
+	if err := json.Unmarshal(contents, &baseline); err != nil {
+		return fmt.Errorf("failed to parse baseline: %w", err)
+	}
+
+	regressions, err := report.Regressions(&baseline, cobrautil.MustGetFloat64(cmd, "tolerance"))
+	if err != nil {
+		return err
+	}
+	if len(regressions) > 0 {
+		return fmt.Errorf("latency regressed against the baseline:\n%s", strings.Join(regressions, "\n"))
+	}
+	return nil
+}
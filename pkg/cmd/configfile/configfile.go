@@ -0,0 +1,160 @@
+// Package configfile sets the flags of commands from a YAML config file.
+//
+// Config files map flag names onto their values. Nested mappings are flattened by joining their
+// keys with dashes, so that
+//
+//	datastore:
+//	  engine: postgres
+//	  conn-uri: postgres://...
+//
+// sets --datastore-engine and --datastore-conn-uri. Lists set the values of slice flags, and of
+// map flags as `key=value` pairs.
+//
+// Flags set on the command line or in the environment take precedence over the config file, which
+// in turn takes precedence over the defaults of the flags.
+package configfile
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/jzelinskie/cobrautil/v2"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// FlagName is the name of the flag specifying the config file.
+const FlagName = "config"
+
+// setFromFileAnnotation annotates the flags whose values were set from the config file.
+const setFromFileAnnotation = "configfile_set"
+
+// Values are the values of the flags in a config file, by flag name. Scalars are single values.
+type Values map[string][]string
+
+// Load reads the values of the flags in the config file at the given path.
+func Load(path string) (Values, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read config file: %w", err)
+	}
+
+	var root map[string]any
+	if err := yamlv3.Unmarshal(contents, &root); err != nil {
+		return nil, fmt.Errorf("unable to parse config file: %w", err)
+	}
+
+	values := make(Values, len(root))
+	if err := flatten(values, "", root); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func flatten(values Values, prefix string, mapping map[string]any) error {
+	for key, value := range mapping {
+		name := key
+		if prefix != "" {
+			name = prefix + "-" + key
+		}
+
+		switch v := value.(type) {
+		case map[string]any:
+			if err := flatten(values, name, v); err != nil {
+				return err
+			}
+
+		case []any:
+			list := make([]string, 0, len(v))
+			for _, item := range v {
+				if _, ok := item.(map[string]any); ok {
+					return fmt.Errorf("invalid value for `%s` in config file: lists must contain only scalars", name)
+				}
+				list = append(list, fmt.Sprint(item))
+			}
+			values[name] = list
+
+		case nil:
+			values[name] = []string{""}
+
+		default:
+			values[name] = []string{fmt.Sprint(v)}
+		}
+	}
+	return nil
+}
+
+// Apply sets the flags which have not been set on the command line or in the environment to
+// their values in the config file. Values for unknown flags are an error.
+func Apply(flags *pflag.FlagSet, values Values) error {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		flag := flags.Lookup(name)
+		if flag == nil {
+			return fmt.Errorf("unknown flag `%s` in config file", name)
+		}
+
+		if flag.Changed || name == FlagName {
+			continue
+		}
+
+		if err := setFlag(flag, values[name]); err != nil {
+			return fmt.Errorf("invalid value for `%s` in config file: %w", name, err)
+		}
+		flag.Changed = true
+
+		if err := flags.SetAnnotation(name, setFromFileAnnotation, []string{"true"}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setFlag(flag *pflag.Flag, value []string) error {
+	if sliceValue, ok := flag.Value.(pflag.SliceValue); ok {
+		return sliceValue.Replace(value)
+	}
+	return flag.Value.Set(strings.Join(value, ","))
+}
+
+// Overrides returns the names of the flags set on the command line or in the environment, which
+// take precedence over the config file.
+func Overrides(flags *pflag.FlagSet) []string {
+	var overrides []string
+	flags.Visit(func(flag *pflag.Flag) {
+		if _, ok := flag.Annotations[setFromFileAnnotation]; !ok {
+			overrides = append(overrides, flag.Name)
+		}
+	})
+	return overrides
+}
+
+// PreRunE returns a Cobra run func that sets the flags of the command from the config file
+// specified by the config flag, if any. It must run after the flags have been synchronized with
+// the environment, so that the environment takes precedence.
+func PreRunE() cobrautil.CobraRunFunc {
+	return func(cmd *cobra.Command, args []string) error {
+		if cmd.Flags().Lookup(FlagName) == nil {
+			return nil
+		}
+
+		path := cobrautil.MustGetString(cmd, FlagName)
+		if path == "" {
+			return nil
+		}
+
+		values, err := Load(path)
+		if err != nil {
+			return err
+		}
+		return Apply(cmd.Flags(), values)
+	}
+}
@@ -0,0 +1,80 @@
+package configfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/require"
+)
+
+const testConfig = `
+log-level: debug
+datastore:
+  engine: postgres
+  conn-max-lifetime: 5m
+grpc-preshared-key:
+  - first
+  - second
+decision-log-labels:
+  - env=prod
+`
+
+func writeConfig(t *testing.T, contents string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0o600))
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	values, err := Load(writeConfig(t, testConfig))
+	require.NoError(t, err)
+	require.Equal(t, Values{
+		"log-level":                   {"debug"},
+		"datastore-engine":            {"postgres"},
+		"datastore-conn-max-lifetime": {"5m"},
+		"grpc-preshared-key":          {"first", "second"},
+		"decision-log-labels":         {"env=prod"},
+	}, values)
+
+	_, err = Load(writeConfig(t, "rules:\n  - name: nested\n"))
+	require.ErrorContains(t, err, "lists must contain only scalars")
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	require.ErrorContains(t, err, "unable to read config file")
+}
+
+func TestApply(t *testing.T) {
+	var (
+		logLevel    string
+		engine      string
+		maxLifetime time.Duration
+		keys        []string
+		labels      map[string]string
+	)
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.StringVar(&logLevel, "log-level", "info", "")
+	flags.StringVar(&engine, "datastore-engine", "memory", "")
+	flags.DurationVar(&maxLifetime, "datastore-conn-max-lifetime", time.Minute, "")
+	flags.StringSliceVar(&keys, "grpc-preshared-key", nil, "")
+	flags.StringToStringVar(&labels, "decision-log-labels", nil, "")
+
+	// Flags set on the command line take precedence over the config file.
+	require.NoError(t, flags.Parse([]string{"--log-level", "warn"}))
+
+	values, err := Load(writeConfig(t, testConfig))
+	require.NoError(t, err)
+	require.NoError(t, Apply(flags, values))
+
+	require.Equal(t, "warn", logLevel)
+	require.Equal(t, "postgres", engine)
+	require.Equal(t, 5*time.Minute, maxLifetime)
+	require.Equal(t, []string{"first", "second"}, keys)
+	require.Equal(t, map[string]string{"env": "prod"}, labels)
+	require.Equal(t, []string{"log-level"}, Overrides(flags))
+
+	require.ErrorContains(t, Apply(flags, Values{"unknown": {"value"}}), "unknown flag `unknown`")
+}
@@ -57,13 +57,7 @@ func NewGCDatastoreCommand(programName string, cfg *datastore.Config) *cobra.Com
 				return fmt.Errorf("failed to create datastore: %w", err)
 			}
 
-			for {
-				wds, ok := ds.(dspkg.UnwrappableDatastore)
-				if !ok {
-					break
-				}
-				ds = wds.Unwrap()
-			}
+			ds = dspkg.Unwrap(ds)
 
 			gc, ok := ds.(common.GarbageCollector)
 			if !ok {
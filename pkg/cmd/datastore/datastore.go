@@ -4,19 +4,23 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 
+	"github.com/authzed/spicedb/internal/datastore/common"
 	"github.com/authzed/spicedb/internal/datastore/crdb"
 	"github.com/authzed/spicedb/internal/datastore/memdb"
 	"github.com/authzed/spicedb/internal/datastore/mysql"
 	"github.com/authzed/spicedb/internal/datastore/postgres"
+	pgxcommon "github.com/authzed/spicedb/internal/datastore/postgres/common"
 	"github.com/authzed/spicedb/internal/datastore/proxy"
 	"github.com/authzed/spicedb/internal/datastore/spanner"
 	log "github.com/authzed/spicedb/internal/logging"
 	"github.com/authzed/spicedb/pkg/datastore"
+	"github.com/authzed/spicedb/pkg/secrets"
 	"github.com/authzed/spicedb/pkg/validationfile"
 )
 
@@ -46,16 +50,31 @@ type Config struct {
 	LegacyFuzzing        time.Duration
 	RevisionQuantization time.Duration
 
+	// Adaptive revision quantization
+	AdaptiveRevisionQuantization bool
+	MinRevisionQuantization      time.Duration
+	FullQuantizationWriteRate    float64
+
 	// Options
 	MaxIdleTime            time.Duration
 	MaxLifetime            time.Duration
 	MaxOpenConns           int
 	MinOpenConns           int
 	SplitQueryCount        uint16
+	QueryLogSampleRate     float64
+	IndexAdvisorEnabled    bool
+	CreateAdvisedIndexes   bool
 	ReadOnly               bool
 	EnableDatastoreMetrics bool
 	DisableStats           bool
 
+	// Connection authentication
+	ConnAuthMethod     string
+	ConnAWSRegion      string
+	ConnPasswordPath   string
+	ConnClientCertPath string
+	ConnClientKeyPath  string
+
 	// Bootstrap
 	BootstrapFiles        []string
 	BootstrapFileContents map[string][]byte
@@ -79,6 +98,13 @@ type Config struct {
 	GCInterval         time.Duration
 	GCMaxOperationTime time.Duration
 
+	// Postgres and MySQL
+	GCWorkers            uint16
+	GCMinBatchSize       uint64
+	GCMaxBatchSize       uint64
+	GCTargetBatchLatency time.Duration
+	GCLeaderElection     bool
+
 	// Spanner
 	SpannerCredentialsFile string
 	SpannerEmulatorHost    string
@@ -89,8 +115,19 @@ type Config struct {
 	// Internal
 	WatchBufferLength uint16
 
+	// Encryption
+	CaveatContextEncryptionKeys       []string
+	CaveatContextEncryptionKeyManager secrets.KeyManager
+
+	// Integrity
+	RelationshipIntegrityKeys       []string
+	RelationshipIntegrityKeyManager secrets.KeyManager
+
 	// Migrations
 	MigrationPhase string
+
+	// Testing
+	ChaosConfigFile string
 }
 
 // RegisterDatastoreFlags adds datastore flags to a cobra command.
@@ -115,11 +152,24 @@ func RegisterDatastoreFlagsWithPrefix(flagSet *pflag.FlagSet, prefix string, opt
 	flagSet.IntVar(&opts.MinOpenConns, flagName("datastore-conn-min-open"), defaults.MinOpenConns, "number of minimum concurrent connections open in a remote datastore's connection pool")
 	flagSet.DurationVar(&opts.MaxLifetime, flagName("datastore-conn-max-lifetime"), defaults.MaxLifetime, "maximum amount of time a connection can live in a remote datastore's connection pool")
 	flagSet.DurationVar(&opts.MaxIdleTime, flagName("datastore-conn-max-idletime"), defaults.MaxIdleTime, "maximum amount of time a connection can idle in a remote datastore's connection pool")
+	flagSet.StringVar(&opts.ConnAuthMethod, flagName("datastore-conn-auth-method"), defaults.ConnAuthMethod, fmt.Sprintf(`method by which connections to a remote datastore obtain their password (%s); iam tokens are refreshed automatically (postgres and cockroach drivers only)`, strings.Join(pgxcommon.AuthMethods, ", ")))
+	flagSet.StringVar(&opts.ConnAWSRegion, flagName("datastore-conn-aws-region"), defaults.ConnAWSRegion, "region of the database for aws iam authentication, defaulting to the region of the environment")
+	flagSet.StringVar(&opts.ConnPasswordPath, flagName("datastore-conn-password-path"), defaults.ConnPasswordPath, "path to a file containing the password of connections to a remote datastore, reloaded whenever it changes (postgres and cockroach drivers only)")
+	flagSet.StringVar(&opts.ConnClientCertPath, flagName("datastore-conn-client-cert-path"), defaults.ConnClientCertPath, "path to the client certificate presented to a remote datastore, reloaded whenever it changes (postgres and cockroach drivers only)")
+	flagSet.StringVar(&opts.ConnClientKeyPath, flagName("datastore-conn-client-key-path"), defaults.ConnClientKeyPath, "path to the key of the client certificate presented to a remote datastore")
 	flagSet.DurationVar(&opts.HealthCheckPeriod, flagName("datastore-conn-healthcheck-interval"), defaults.HealthCheckPeriod, "time between a remote datastore's connection pool health checks")
 	flagSet.DurationVar(&opts.GCWindow, flagName("datastore-gc-window"), defaults.GCWindow, "amount of time before revisions are garbage collected")
 	flagSet.DurationVar(&opts.GCInterval, flagName("datastore-gc-interval"), defaults.GCInterval, "amount of time between passes of garbage collection (postgres driver only)")
 	flagSet.DurationVar(&opts.GCMaxOperationTime, flagName("datastore-gc-max-operation-time"), defaults.GCMaxOperationTime, "maximum amount of time a garbage collection pass can operate before timing out (postgres driver only)")
+	flagSet.Uint16Var(&opts.GCWorkers, flagName("datastore-gc-workers"), defaults.GCWorkers, "number of ranges of each table garbage collected in parallel (postgres and mysql drivers only)")
+	flagSet.Uint64Var(&opts.GCMinBatchSize, flagName("datastore-gc-min-batch-size"), defaults.GCMinBatchSize, "minimum number of rows deleted by each garbage collection statement (postgres and mysql drivers only)")
+	flagSet.Uint64Var(&opts.GCMaxBatchSize, flagName("datastore-gc-max-batch-size"), defaults.GCMaxBatchSize, "maximum number of rows deleted by each garbage collection statement (postgres and mysql drivers only)")
+	flagSet.BoolVar(&opts.GCLeaderElection, flagName("datastore-gc-leader-election"), defaults.GCLeaderElection, "runs garbage collection on a single replica at a time, elected by a lease held in the datastore, rather than on every replica (postgres and mysql drivers only)")
+	flagSet.DurationVar(&opts.GCTargetBatchLatency, flagName("datastore-gc-target-batch-latency"), defaults.GCTargetBatchLatency, "latency of garbage collection statements above which batches shrink to reduce load on the datastore (postgres and mysql drivers only)")
 	flagSet.DurationVar(&opts.RevisionQuantization, flagName("datastore-revision-quantization-interval"), defaults.RevisionQuantization, "boundary interval to which to round the quantized revision")
+	flagSet.BoolVar(&opts.AdaptiveRevisionQuantization, flagName("datastore-revision-quantization-adaptive"), defaults.AdaptiveRevisionQuantization, "adapt the revision quantization interval to the rate of writes, between the minimum and --datastore-revision-quantization-interval (not supported by the memory driver)")
+	flagSet.DurationVar(&opts.MinRevisionQuantization, flagName("datastore-revision-quantization-minimum"), defaults.MinRevisionQuantization, "shortest revision quantization interval used when adapting it to the rate of writes")
+	flagSet.Float64Var(&opts.FullQuantizationWriteRate, flagName("datastore-revision-quantization-full-write-rate"), defaults.FullQuantizationWriteRate, "writes per second at and above which the full revision quantization interval is used when adapting it to the rate of writes")
 	flagSet.BoolVar(&opts.ReadOnly, flagName("datastore-readonly"), defaults.ReadOnly, "set the service to read-only mode")
 	flagSet.StringSliceVar(&opts.BootstrapFiles, flagName("datastore-bootstrap-files"), defaults.BootstrapFiles, "bootstrap data yaml files to load")
 	flagSet.BoolVar(&opts.BootstrapOverwrite, flagName("datastore-bootstrap-overwrite"), defaults.BootstrapOverwrite, "overwrite any existing data with bootstrap data")
@@ -132,6 +182,9 @@ func RegisterDatastoreFlagsWithPrefix(flagSet *pflag.FlagSet, prefix string, opt
 	// See crdb doc for info about follower reads and how it is configured: https://www.cockroachlabs.com/docs/stable/follower-reads.html
 	flagSet.DurationVar(&opts.FollowerReadDelay, flagName("datastore-follower-read-delay-duration"), 4_800*time.Millisecond, "amount of time to subtract from non-sync revision timestamps to ensure they are sufficiently in the past to enable follower reads (cockroach driver only)")
 	flagSet.Uint16Var(&opts.SplitQueryCount, flagName("datastore-query-userset-batch-size"), 1024, "number of usersets after which a relationship query will be split into multiple queries")
+	flagSet.Float64Var(&opts.QueryLogSampleRate, flagName("datastore-query-log-sample-rate"), 0, "fraction, between 0 and 1, of relationship queries to log with their normalized statement, parameter count, rows returned and duration (sql datastores only)")
+	flagSet.BoolVar(&opts.IndexAdvisorEnabled, flagName("datastore-index-advisor-enabled"), false, "record the columns filtered on by relationship queries, to suggest missing indexes via the admin API (postgres driver only)")
+	flagSet.BoolVar(&opts.CreateAdvisedIndexes, flagName("datastore-index-advisor-create-indexes"), false, "create the indexes suggested by the index advisor when suggestions are requested (postgres driver only)")
 	flagSet.IntVar(&opts.MaxRetries, flagName("datastore-max-tx-retries"), 10, "number of times a retriable transaction should be retried")
 	flagSet.StringVar(&opts.OverlapStrategy, flagName("datastore-tx-overlap-strategy"), "static", `strategy to generate transaction overlap keys ("prefix", "static", "insecure") (cockroach driver only)`)
 	flagSet.StringVar(&opts.OverlapKey, flagName("datastore-tx-overlap-key"), "key", "static key to touch when writing to ensure transactions overlap (only used if --datastore-tx-overlap-strategy=static is set; cockroach driver only)")
@@ -140,6 +193,8 @@ func RegisterDatastoreFlagsWithPrefix(flagSet *pflag.FlagSet, prefix string, opt
 	flagSet.StringVar(&opts.TablePrefix, flagName("datastore-mysql-table-prefix"), "", "prefix to add to the name of all SpiceDB database tables")
 	flagSet.StringVar(&opts.MigrationPhase, flagName("datastore-migration-phase"), "", "datastore-specific flag that should be used to signal to a datastore which phase of a multi-step migration it is in")
 	flagSet.Uint16Var(&opts.WatchBufferLength, flagName("datastore-watch-buffer-length"), 1024, "how many events the watch buffer should queue before forcefully disconnecting reader")
+	flagSet.StringSliceVar(&opts.CaveatContextEncryptionKeys, flagName("datastore-caveat-context-encryption-keys"), defaults.CaveatContextEncryptionKeys, `keys used to encrypt caveat context at rest, of the form "id=base64key"; the first key is used for new writes, the remainder only for reads`)
+	flagSet.StringSliceVar(&opts.RelationshipIntegrityKeys, flagName("datastore-relationship-integrity-keys"), defaults.RelationshipIntegrityKeys, `keys used to sign each relationship written, of the form "id=base64key", so that relationships modified other than by SpiceDB fail to be read; the first key is used for new writes, the remainder only for verification. relationships written before signing was enabled must be rewritten`)
 
 	// disabling stats is only for tests
 	flagSet.BoolVar(&opts.DisableStats, flagName("datastore-disable-stats"), false, "disable recording relationship counts to the stats table")
@@ -147,6 +202,12 @@ func RegisterDatastoreFlagsWithPrefix(flagSet *pflag.FlagSet, prefix string, opt
 		return fmt.Errorf("failed to mark flag as hidden: %w", err)
 	}
 
+	// fault injection is only for testing and staging environments
+	flagSet.StringVar(&opts.ChaosConfigFile, flagName("datastore-chaos-config"), "", "path to a yaml file configuring faults to inject into datastore operations; never set in production")
+	if err := flagSet.MarkHidden(flagName("datastore-chaos-config")); err != nil {
+		return fmt.Errorf("failed to mark flag as hidden: %w", err)
+	}
+
 	flagSet.DurationVar(&opts.LegacyFuzzing, flagName("datastore-revision-fuzzing-duration"), -1, "amount of time to advertize stale revisions")
 	if err := flagSet.MarkDeprecated(flagName("datastore-revision-fuzzing-duration"), "please use datastore-revision-quantization-interval instead"); err != nil {
 		return fmt.Errorf("failed to mark flag as deprecated: %w", err)
@@ -161,9 +222,12 @@ func DefaultDatastoreConfig() *Config {
 		GCWindow:                       24 * time.Hour,
 		LegacyFuzzing:                  -1,
 		RevisionQuantization:           5 * time.Second,
+		MinRevisionQuantization:        100 * time.Millisecond,
+		FullQuantizationWriteRate:      100,
 		MaxLifetime:                    30 * time.Minute,
 		MaxIdleTime:                    30 * time.Minute,
 		MaxOpenConns:                   20,
+		ConnAuthMethod:                 string(pgxcommon.AuthMethodPassword),
 		MinOpenConns:                   10,
 		SplitQueryCount:                1024,
 		ReadOnly:                       false,
@@ -173,10 +237,16 @@ func DefaultDatastoreConfig() *Config {
 		HealthCheckPeriod:              30 * time.Second,
 		GCInterval:                     3 * time.Minute,
 		GCMaxOperationTime:             1 * time.Minute,
+		GCWorkers:                      common.DefaultGCTuning.Workers,
+		GCMinBatchSize:                 common.DefaultGCTuning.MinBatchSize,
+		GCMaxBatchSize:                 common.DefaultGCTuning.MaxBatchSize,
+		GCTargetBatchLatency:           common.DefaultGCTuning.TargetBatchLatency,
 		WatchBufferLength:              1024,
 		EnableDatastoreMetrics:         true,
 		DisableStats:                   false,
 		BootstrapFiles:                 []string{},
+		CaveatContextEncryptionKeys:    []string{},
+		RelationshipIntegrityKeys:      []string{},
 		BootstrapTimeout:               10 * time.Second,
 		BootstrapOverwrite:             false,
 		RequestHedgingEnabled:          true,
@@ -214,6 +284,41 @@ func NewDatastore(ctx context.Context, options ...ConfigOption) (datastore.Datas
 		return nil, err
 	}
 
+	if len(opts.RelationshipIntegrityKeys) > 0 && opts.RelationshipIntegrityKeyManager == nil {
+		keys, err := secrets.ParseKeys(opts.RelationshipIntegrityKeys)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse relationship integrity keys: %w", err)
+		}
+
+		opts.RelationshipIntegrityKeyManager, err = secrets.NewStaticKeyManager(keys...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure relationship integrity: %w", err)
+		}
+	}
+
+	// Relationships are signed as stored, after the encryption of their caveat context.
+	if opts.RelationshipIntegrityKeyManager != nil {
+		log.Ctx(ctx).Info().Msg("relationship integrity signing enabled")
+		ds = proxy.NewRelationshipIntegrityProxy(ds, opts.RelationshipIntegrityKeyManager)
+	}
+
+	if len(opts.CaveatContextEncryptionKeys) > 0 && opts.CaveatContextEncryptionKeyManager == nil {
+		keys, err := secrets.ParseKeys(opts.CaveatContextEncryptionKeys)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse caveat context encryption keys: %w", err)
+		}
+
+		opts.CaveatContextEncryptionKeyManager, err = secrets.NewStaticKeyManager(keys...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure caveat context encryption: %w", err)
+		}
+	}
+
+	if opts.CaveatContextEncryptionKeyManager != nil {
+		log.Ctx(ctx).Info().Msg("caveat context encryption enabled")
+		ds = proxy.NewCaveatContextEncryptingProxy(ds, opts.CaveatContextEncryptionKeyManager)
+	}
+
 	if len(opts.BootstrapFiles) > 0 || len(opts.BootstrapFileContents) > 0 {
 		ctx, cancel := context.WithTimeout(ctx, opts.BootstrapTimeout)
 		defer cancel()
@@ -248,6 +353,16 @@ func NewDatastore(ctx context.Context, options ...ConfigOption) (datastore.Datas
 		}
 	}
 
+	if opts.ChaosConfigFile != "" {
+		injector, err := proxy.ReadChaosConfigFile(opts.ChaosConfigFile)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Ctx(ctx).Warn().Str("file", opts.ChaosConfigFile).Msg("injecting faults into datastore operations")
+		ds = proxy.NewChaosDatastoreProxy(ds, injector)
+	}
+
 	if opts.RequestHedgingEnabled {
 		log.Ctx(ctx).Info().
 			Stringer("initialSlowRequest", opts.RequestHedgingInitialSlowValue).
@@ -274,17 +389,31 @@ func NewDatastore(ctx context.Context, options ...ConfigOption) (datastore.Datas
 	return ds, nil
 }
 
+// gcTuning returns the tuning of the garbage collection of SQL datastores.
+func (c Config) gcTuning() common.GCTuning {
+	return common.GCTuning{
+		Workers:            c.GCWorkers,
+		MinBatchSize:       c.GCMinBatchSize,
+		MaxBatchSize:       c.GCMaxBatchSize,
+		TargetBatchLatency: c.GCTargetBatchLatency,
+	}
+}
+
 func newCRDBDatastore(opts Config) (datastore.Datastore, error) {
 	return crdb.NewCRDBDatastore(
 		opts.URI,
 		crdb.GCWindow(opts.GCWindow),
 		crdb.RevisionQuantization(opts.RevisionQuantization),
+		crdb.AdaptiveRevisionQuantization(opts.AdaptiveRevisionQuantization),
+		crdb.MinRevisionQuantization(opts.MinRevisionQuantization),
+		crdb.FullQuantizationWriteRate(opts.FullQuantizationWriteRate),
 		crdb.ConnMaxIdleTime(opts.MaxIdleTime),
 		crdb.ConnMaxLifetime(opts.MaxLifetime),
 		crdb.ConnHealthCheckInterval(opts.HealthCheckPeriod),
 		crdb.MaxOpenConns(opts.MaxOpenConns),
 		crdb.MinOpenConns(opts.MinOpenConns),
 		crdb.SplitAtUsersetCount(opts.SplitQueryCount),
+		crdb.QueryLogSampleRate(opts.QueryLogSampleRate),
 		crdb.FollowerReadDelay(opts.FollowerReadDelay),
 		crdb.MaxRetries(uint8(opts.MaxRetries)),
 		crdb.OverlapKey(opts.OverlapKey),
@@ -292,6 +421,10 @@ func newCRDBDatastore(opts Config) (datastore.Datastore, error) {
 		crdb.WatchBufferLength(opts.WatchBufferLength),
 		crdb.DisableStats(opts.DisableStats),
 		crdb.WithEnablePrometheusStats(opts.EnableDatastoreMetrics),
+		crdb.ConnAuthMethod(opts.ConnAuthMethod),
+		crdb.ConnAWSRegion(opts.ConnAWSRegion),
+		crdb.ConnPasswordPath(opts.ConnPasswordPath),
+		crdb.ConnClientCertificate(opts.ConnClientCertPath, opts.ConnClientKeyPath),
 	)
 }
 
@@ -300,19 +433,31 @@ func newPostgresDatastore(opts Config) (datastore.Datastore, error) {
 		postgres.GCWindow(opts.GCWindow),
 		postgres.GCEnabled(!opts.ReadOnly),
 		postgres.RevisionQuantization(opts.RevisionQuantization),
+		postgres.AdaptiveRevisionQuantization(opts.AdaptiveRevisionQuantization),
+		postgres.MinRevisionQuantization(opts.MinRevisionQuantization),
+		postgres.FullQuantizationWriteRate(opts.FullQuantizationWriteRate),
 		postgres.ConnMaxIdleTime(opts.MaxIdleTime),
 		postgres.ConnMaxLifetime(opts.MaxLifetime),
 		postgres.MaxOpenConns(opts.MaxOpenConns),
 		postgres.MinOpenConns(opts.MinOpenConns),
 		postgres.SplitAtUsersetCount(opts.SplitQueryCount),
+		postgres.QueryLogSampleRate(opts.QueryLogSampleRate),
+		postgres.IndexAdvisorEnabled(opts.IndexAdvisorEnabled),
+		postgres.CreateAdvisedIndexes(opts.CreateAdvisedIndexes),
 		postgres.HealthCheckPeriod(opts.HealthCheckPeriod),
 		postgres.GCInterval(opts.GCInterval),
 		postgres.GCMaxOperationTime(opts.GCMaxOperationTime),
+		postgres.GCTuning(opts.gcTuning()),
+		postgres.GCLeaderElection(opts.GCLeaderElection),
 		postgres.EnableTracing(),
 		postgres.WatchBufferLength(opts.WatchBufferLength),
 		postgres.WithEnablePrometheusStats(opts.EnableDatastoreMetrics),
 		postgres.MaxRetries(uint8(opts.MaxRetries)),
 		postgres.MigrationPhase(opts.MigrationPhase),
+		postgres.ConnAuthMethod(opts.ConnAuthMethod),
+		postgres.ConnAWSRegion(opts.ConnAWSRegion),
+		postgres.ConnPasswordPath(opts.ConnPasswordPath),
+		postgres.ConnClientCertificate(opts.ConnClientCertPath, opts.ConnClientKeyPath),
 	}
 	return postgres.NewPostgresDatastore(opts.URI, pgOpts...)
 }
@@ -324,9 +469,13 @@ func newSpannerDatastore(opts Config) (datastore.Datastore, error) {
 		spanner.GCInterval(opts.GCInterval),
 		spanner.GCWindow(opts.GCWindow),
 		spanner.GCEnabled(!opts.ReadOnly),
+		spanner.AdaptiveRevisionQuantization(opts.AdaptiveRevisionQuantization),
+		spanner.MinRevisionQuantization(opts.MinRevisionQuantization),
+		spanner.FullQuantizationWriteRate(opts.FullQuantizationWriteRate),
 		spanner.CredentialsFile(opts.SpannerCredentialsFile),
 		spanner.WatchBufferLength(opts.WatchBufferLength),
 		spanner.EmulatorHost(opts.SpannerEmulatorHost),
+		spanner.QueryLogSampleRate(opts.QueryLogSampleRate),
 	)
 }
 
@@ -337,16 +486,22 @@ func newMySQLDatastore(opts Config) (datastore.Datastore, error) {
 		mysql.GCInterval(opts.GCInterval),
 		mysql.GCEnabled(!opts.ReadOnly),
 		mysql.GCMaxOperationTime(opts.GCMaxOperationTime),
+		mysql.GCTuning(opts.gcTuning()),
+		mysql.GCLeaderElection(opts.GCLeaderElection),
 		mysql.ConnMaxIdleTime(opts.MaxIdleTime),
 		mysql.ConnMaxLifetime(opts.MaxLifetime),
 		mysql.MaxOpenConns(opts.MaxOpenConns),
 		mysql.RevisionQuantization(opts.RevisionQuantization),
+		mysql.AdaptiveRevisionQuantization(opts.AdaptiveRevisionQuantization),
+		mysql.MinRevisionQuantization(opts.MinRevisionQuantization),
+		mysql.FullQuantizationWriteRate(opts.FullQuantizationWriteRate),
 		mysql.TablePrefix(opts.TablePrefix),
 		mysql.WatchBufferLength(opts.WatchBufferLength),
 		mysql.WithEnablePrometheusStats(opts.EnableDatastoreMetrics),
 		mysql.MaxRetries(uint8(opts.MaxRetries)),
 		mysql.OverrideLockWaitTimeout(1),
 		mysql.SplitAtUsersetCount(opts.SplitQueryCount),
+		mysql.QueryLogSampleRate(opts.QueryLogSampleRate),
 	}
 	return mysql.NewMySQLDatastore(opts.URI, mysqlOpts...)
 }
@@ -1,7 +1,10 @@
 // Code generated by github.com/ecordell/optgen. DO NOT EDIT.
 package datastore
 
-import "time"
+import (
+	secrets "github.com/authzed/spicedb/pkg/secrets"
+	"time"
+)
 
 type ConfigOption func(c *Config)
 
@@ -22,14 +25,25 @@ func (c *Config) ToOption() ConfigOption {
 		to.GCWindow = c.GCWindow
 		to.LegacyFuzzing = c.LegacyFuzzing
 		to.RevisionQuantization = c.RevisionQuantization
+		to.AdaptiveRevisionQuantization = c.AdaptiveRevisionQuantization
+		to.MinRevisionQuantization = c.MinRevisionQuantization
+		to.FullQuantizationWriteRate = c.FullQuantizationWriteRate
 		to.MaxIdleTime = c.MaxIdleTime
 		to.MaxLifetime = c.MaxLifetime
 		to.MaxOpenConns = c.MaxOpenConns
 		to.MinOpenConns = c.MinOpenConns
 		to.SplitQueryCount = c.SplitQueryCount
+		to.QueryLogSampleRate = c.QueryLogSampleRate
+		to.IndexAdvisorEnabled = c.IndexAdvisorEnabled
+		to.CreateAdvisedIndexes = c.CreateAdvisedIndexes
 		to.ReadOnly = c.ReadOnly
 		to.EnableDatastoreMetrics = c.EnableDatastoreMetrics
 		to.DisableStats = c.DisableStats
+		to.ConnAuthMethod = c.ConnAuthMethod
+		to.ConnAWSRegion = c.ConnAWSRegion
+		to.ConnPasswordPath = c.ConnPasswordPath
+		to.ConnClientCertPath = c.ConnClientCertPath
+		to.ConnClientKeyPath = c.ConnClientKeyPath
 		to.BootstrapFiles = c.BootstrapFiles
 		to.BootstrapFileContents = c.BootstrapFileContents
 		to.BootstrapOverwrite = c.BootstrapOverwrite
@@ -45,11 +59,21 @@ func (c *Config) ToOption() ConfigOption {
 		to.HealthCheckPeriod = c.HealthCheckPeriod
 		to.GCInterval = c.GCInterval
 		to.GCMaxOperationTime = c.GCMaxOperationTime
+		to.GCWorkers = c.GCWorkers
+		to.GCMinBatchSize = c.GCMinBatchSize
+		to.GCMaxBatchSize = c.GCMaxBatchSize
+		to.GCTargetBatchLatency = c.GCTargetBatchLatency
+		to.GCLeaderElection = c.GCLeaderElection
 		to.SpannerCredentialsFile = c.SpannerCredentialsFile
 		to.SpannerEmulatorHost = c.SpannerEmulatorHost
 		to.TablePrefix = c.TablePrefix
 		to.WatchBufferLength = c.WatchBufferLength
+		to.CaveatContextEncryptionKeys = c.CaveatContextEncryptionKeys
+		to.CaveatContextEncryptionKeyManager = c.CaveatContextEncryptionKeyManager
+		to.RelationshipIntegrityKeys = c.RelationshipIntegrityKeys
+		to.RelationshipIntegrityKeyManager = c.RelationshipIntegrityKeyManager
 		to.MigrationPhase = c.MigrationPhase
+		to.ChaosConfigFile = c.ChaosConfigFile
 	}
 }
 
@@ -96,6 +120,27 @@ func WithRevisionQuantization(revisionQuantization time.Duration) ConfigOption {
 	}
 }
 
+// WithAdaptiveRevisionQuantization returns an option that can set AdaptiveRevisionQuantization on a Config
+func WithAdaptiveRevisionQuantization(adaptiveRevisionQuantization bool) ConfigOption {
+	return func(c *Config) {
+		c.AdaptiveRevisionQuantization = adaptiveRevisionQuantization
+	}
+}
+
+// WithMinRevisionQuantization returns an option that can set MinRevisionQuantization on a Config
+func WithMinRevisionQuantization(minRevisionQuantization time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.MinRevisionQuantization = minRevisionQuantization
+	}
+}
+
+// WithFullQuantizationWriteRate returns an option that can set FullQuantizationWriteRate on a Config
+func WithFullQuantizationWriteRate(fullQuantizationWriteRate float64) ConfigOption {
+	return func(c *Config) {
+		c.FullQuantizationWriteRate = fullQuantizationWriteRate
+	}
+}
+
 // WithMaxIdleTime returns an option that can set MaxIdleTime on a Config
 func WithMaxIdleTime(maxIdleTime time.Duration) ConfigOption {
 	return func(c *Config) {
@@ -131,6 +176,27 @@ func WithSplitQueryCount(splitQueryCount uint16) ConfigOption {
 	}
 }
 
+// WithQueryLogSampleRate returns an option that can set QueryLogSampleRate on a Config
+func WithQueryLogSampleRate(queryLogSampleRate float64) ConfigOption {
+	return func(c *Config) {
+		c.QueryLogSampleRate = queryLogSampleRate
+	}
+}
+
+// WithIndexAdvisorEnabled returns an option that can set IndexAdvisorEnabled on a Config
+func WithIndexAdvisorEnabled(indexAdvisorEnabled bool) ConfigOption {
+	return func(c *Config) {
+		c.IndexAdvisorEnabled = indexAdvisorEnabled
+	}
+}
+
+// WithCreateAdvisedIndexes returns an option that can set CreateAdvisedIndexes on a Config
+func WithCreateAdvisedIndexes(createAdvisedIndexes bool) ConfigOption {
+	return func(c *Config) {
+		c.CreateAdvisedIndexes = createAdvisedIndexes
+	}
+}
+
 // WithReadOnly returns an option that can set ReadOnly on a Config
 func WithReadOnly(readOnly bool) ConfigOption {
 	return func(c *Config) {
@@ -152,6 +218,41 @@ func WithDisableStats(disableStats bool) ConfigOption {
 	}
 }
 
+// WithConnAuthMethod returns an option that can set ConnAuthMethod on a Config
+func WithConnAuthMethod(connAuthMethod string) ConfigOption {
+	return func(c *Config) {
+		c.ConnAuthMethod = connAuthMethod
+	}
+}
+
+// WithConnAWSRegion returns an option that can set ConnAWSRegion on a Config
+func WithConnAWSRegion(connAWSRegion string) ConfigOption {
+	return func(c *Config) {
+		c.ConnAWSRegion = connAWSRegion
+	}
+}
+
+// WithConnPasswordPath returns an option that can set ConnPasswordPath on a Config
+func WithConnPasswordPath(connPasswordPath string) ConfigOption {
+	return func(c *Config) {
+		c.ConnPasswordPath = connPasswordPath
+	}
+}
+
+// WithConnClientCertPath returns an option that can set ConnClientCertPath on a Config
+func WithConnClientCertPath(connClientCertPath string) ConfigOption {
+	return func(c *Config) {
+		c.ConnClientCertPath = connClientCertPath
+	}
+}
+
+// WithConnClientKeyPath returns an option that can set ConnClientKeyPath on a Config
+func WithConnClientKeyPath(connClientKeyPath string) ConfigOption {
+	return func(c *Config) {
+		c.ConnClientKeyPath = connClientKeyPath
+	}
+}
+
 // WithBootstrapFiles returns an option that can append BootstrapFiless to Config.BootstrapFiles
 func WithBootstrapFiles(bootstrapFiles string) ConfigOption {
 	return func(c *Config) {
@@ -271,6 +372,41 @@ func WithGCMaxOperationTime(gCMaxOperationTime time.Duration) ConfigOption {
 	}
 }
 
+// WithGCWorkers returns an option that can set GCWorkers on a Config
+func WithGCWorkers(gCWorkers uint16) ConfigOption {
+	return func(c *Config) {
+		c.GCWorkers = gCWorkers
+	}
+}
+
+// WithGCMinBatchSize returns an option that can set GCMinBatchSize on a Config
+func WithGCMinBatchSize(gCMinBatchSize uint64) ConfigOption {
+	return func(c *Config) {
+		c.GCMinBatchSize = gCMinBatchSize
+	}
+}
+
+// WithGCMaxBatchSize returns an option that can set GCMaxBatchSize on a Config
+func WithGCMaxBatchSize(gCMaxBatchSize uint64) ConfigOption {
+	return func(c *Config) {
+		c.GCMaxBatchSize = gCMaxBatchSize
+	}
+}
+
+// WithGCTargetBatchLatency returns an option that can set GCTargetBatchLatency on a Config
+func WithGCTargetBatchLatency(gCTargetBatchLatency time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.GCTargetBatchLatency = gCTargetBatchLatency
+	}
+}
+
+// WithGCLeaderElection returns an option that can set GCLeaderElection on a Config
+func WithGCLeaderElection(gCLeaderElection bool) ConfigOption {
+	return func(c *Config) {
+		c.GCLeaderElection = gCLeaderElection
+	}
+}
+
 // WithSpannerCredentialsFile returns an option that can set SpannerCredentialsFile on a Config
 func WithSpannerCredentialsFile(spannerCredentialsFile string) ConfigOption {
 	return func(c *Config) {
@@ -299,9 +435,58 @@ func WithWatchBufferLength(watchBufferLength uint16) ConfigOption {
 	}
 }
 
+// WithCaveatContextEncryptionKeys returns an option that can append CaveatContextEncryptionKeyss to Config.CaveatContextEncryptionKeys
+func WithCaveatContextEncryptionKeys(caveatContextEncryptionKeys string) ConfigOption {
+	return func(c *Config) {
+		c.CaveatContextEncryptionKeys = append(c.CaveatContextEncryptionKeys, caveatContextEncryptionKeys)
+	}
+}
+
+// SetCaveatContextEncryptionKeys returns an option that can set CaveatContextEncryptionKeys on a Config
+func SetCaveatContextEncryptionKeys(caveatContextEncryptionKeys []string) ConfigOption {
+	return func(c *Config) {
+		c.CaveatContextEncryptionKeys = caveatContextEncryptionKeys
+	}
+}
+
+// WithCaveatContextEncryptionKeyManager returns an option that can set CaveatContextEncryptionKeyManager on a Config
+func WithCaveatContextEncryptionKeyManager(caveatContextEncryptionKeyManager secrets.KeyManager) ConfigOption {
+	return func(c *Config) {
+		c.CaveatContextEncryptionKeyManager = caveatContextEncryptionKeyManager
+	}
+}
+
+// WithRelationshipIntegrityKeys returns an option that can append RelationshipIntegrityKeyss to Config.RelationshipIntegrityKeys
+func WithRelationshipIntegrityKeys(relationshipIntegrityKeys string) ConfigOption {
+	return func(c *Config) {
+		c.RelationshipIntegrityKeys = append(c.RelationshipIntegrityKeys, relationshipIntegrityKeys)
+	}
+}
+
+// SetRelationshipIntegrityKeys returns an option that can set RelationshipIntegrityKeys on a Config
+func SetRelationshipIntegrityKeys(relationshipIntegrityKeys []string) ConfigOption {
+	return func(c *Config) {
+		c.RelationshipIntegrityKeys = relationshipIntegrityKeys
+	}
+}
+
+// WithRelationshipIntegrityKeyManager returns an option that can set RelationshipIntegrityKeyManager on a Config
+func WithRelationshipIntegrityKeyManager(relationshipIntegrityKeyManager secrets.KeyManager) ConfigOption {
+	return func(c *Config) {
+		c.RelationshipIntegrityKeyManager = relationshipIntegrityKeyManager
+	}
+}
+
 // WithMigrationPhase returns an option that can set MigrationPhase on a Config
 func WithMigrationPhase(migrationPhase string) ConfigOption {
 	return func(c *Config) {
 		c.MigrationPhase = migrationPhase
 	}
 }
+
+// WithChaosConfigFile returns an option that can set ChaosConfigFile on a Config
+func WithChaosConfigFile(chaosConfigFile string) ConfigOption {
+	return func(c *Config) {
+		c.ChaosConfigFile = chaosConfigFile
+	}
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/jzelinskie/cobrautil/v2"
+	"github.com/spf13/cobra"
+
+	"github.com/authzed/spicedb/pkg/cmd/server"
+	"github.com/authzed/spicedb/pkg/schemadsl/generator"
+)
+
+func RegisterFormatSchemaFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("sort-definitions", false, "order the definitions and caveats by name, rather than keeping the order in which they are written")
+	cmd.Flags().Bool("check", false, "rather than printing the formatted schemas, list those which are not formatted, failing if any are not")
+	cmd.Flags().Bool("write", false, "rather than printing the formatted schemas, write them back to their files")
+}
+
+func NewFormatSchemaCommand(programName string) *cobra.Command {
+	return &cobra.Command{
+		Use:     "format-schema <schema file>...",
+		Short:   "format schema files canonically",
+		Long:    "Formats schema files canonically, as ReadSchema returns the schema written, such as for enforcing the formatting of schemas in CI with --check. Comments which are not attached to a definition, relation or permission are dropped.",
+		PreRunE: server.DefaultPreRunE(programName),
+		RunE:    formatSchemaCmdFunc,
+		Args:    cobra.MinimumNArgs(1),
+	}
+}
+
+func formatSchemaCmdFunc(cmd *cobra.Command, args []string) error {
+	check := cobrautil.MustGetBool(cmd, "check")
+	write := cobrautil.MustGetBool(cmd, "write")
+	if check && write {
+		return fmt.Errorf("only one of --check and --write may be given")
+	}
+
+	unformatted := 0
+	for _, path := range args {
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		formatted, err := generator.FormatSchema(string(contents), cobrautil.MustGetBool(cmd, "sort-definitions"))
+		if err != nil {
+			return fmt.Errorf("failed to format %s: %w", path, err)
+		}
+		formattedContents := []byte(formatted + "\n")
+
+		switch {
+		case check:
+			if !bytes.Equal(contents, formattedContents) {
+				fmt.Fprintln(cmd.OutOrStdout(), path)
+				unformatted++
+			}
+
+		case write:
+			if bytes.Equal(contents, formattedContents) {
+				continue
+			}
+			if err := os.WriteFile(path, formattedContents, 0o644); err != nil {
+				return fmt.Errorf("failed to write %s: %w", path, err)
+			}
+
+		default:
+			if _, err := cmd.OutOrStdout().Write(formattedContents); err != nil {
+				return err
+			}
+		}
+	}
+
+	if unformatted > 0 {
+		return fmt.Errorf("%d schema files are not formatted", unformatted)
+	}
+	return nil
+}
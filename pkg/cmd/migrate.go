@@ -2,7 +2,11 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
 	"time"
 
 	"github.com/fatih/color"
@@ -26,6 +30,7 @@ func RegisterMigrateFlags(cmd *cobra.Command) {
 	cmd.Flags().String("datastore-spanner-emulator-host", "", "URI of spanner emulator instance used for development and testing (e.g. localhost:9010)")
 	cmd.Flags().String("datastore-mysql-table-prefix", "", "prefix to add to the name of all mysql database tables")
 	cmd.Flags().Uint64("migration-backfill-batch-size", 1000, "number of items to migrate per iteration of a datastore backfill")
+	cmd.Flags().Duration("migration-backfill-batch-delay", 0, "duration to wait between the iterations of the backfills of online migrations, to limit their load on the datastore")
 	cmd.Flags().Duration("migration-timeout", 1*time.Hour, "defines a timeout for the execution of the migration, set to 1 hour by default")
 }
 
@@ -45,6 +50,8 @@ func migrateRun(cmd *cobra.Command, args []string) error {
 	dbURL := cobrautil.MustGetStringExpanded(cmd, "datastore-conn-uri")
 	timeout := cobrautil.MustGetDuration(cmd, "migration-timeout")
 	migrationBatachSize := cobrautil.MustGetUint64(cmd, "migration-backfill-batch-size")
+	migrationBatchDelay := cobrautil.MustGetDuration(cmd, "migration-backfill-batch-delay")
+	ctx := context.WithValue(cmd.Context(), migrate.BackfillBatchDelay, migrationBatchDelay)
 
 	if datastoreEngine == "cockroachdb" {
 		log.Ctx(cmd.Context()).Info().Msg("migrating cockroachdb datastore")
@@ -54,7 +61,7 @@ func migrateRun(cmd *cobra.Command, args []string) error {
 		if err != nil {
 			return fmt.Errorf("unable to create migration driver for %s: %w", datastoreEngine, err)
 		}
-		return runMigration(cmd.Context(), migrationDriver, crdbmigrations.CRDBMigrations, args[0], timeout, migrationBatachSize)
+		return runMigration(ctx, migrationDriver, crdbmigrations.CRDBMigrations, args[0], timeout, migrationBatachSize)
 	} else if datastoreEngine == "postgres" {
 		log.Ctx(cmd.Context()).Info().Msg("migrating postgres datastore")
 
@@ -63,7 +70,7 @@ func migrateRun(cmd *cobra.Command, args []string) error {
 		if err != nil {
 			return fmt.Errorf("unable to create migration driver for %s: %w", datastoreEngine, err)
 		}
-		return runMigration(cmd.Context(), migrationDriver, migrations.DatabaseMigrations, args[0], timeout, migrationBatachSize)
+		return runMigration(ctx, migrationDriver, migrations.DatabaseMigrations, args[0], timeout, migrationBatachSize)
 	} else if datastoreEngine == "spanner" {
 		log.Ctx(cmd.Context()).Info().Msg("migrating spanner datastore")
 
@@ -77,7 +84,7 @@ func migrateRun(cmd *cobra.Command, args []string) error {
 		if err != nil {
 			return fmt.Errorf("unable to create migration driver for %s: %w", datastoreEngine, err)
 		}
-		return runMigration(cmd.Context(), migrationDriver, spannermigrations.SpannerMigrations, args[0], timeout, migrationBatachSize)
+		return runMigration(ctx, migrationDriver, spannermigrations.SpannerMigrations, args[0], timeout, migrationBatachSize)
 	} else if datastoreEngine == "mysql" {
 		log.Ctx(cmd.Context()).Info().Msg("migrating mysql datastore")
 
@@ -91,7 +98,7 @@ func migrateRun(cmd *cobra.Command, args []string) error {
 		if err != nil {
 			return fmt.Errorf("unable to create migration driver for %s: %w", datastoreEngine, err)
 		}
-		return runMigration(cmd.Context(), migrationDriver, mysqlmigrations.Manager, args[0], timeout, migrationBatachSize)
+		return runMigration(ctx, migrationDriver, mysqlmigrations.Manager, args[0], timeout, migrationBatachSize)
 	}
 
 	return fmt.Errorf("cannot migrate datastore engine type: %s", datastoreEngine)
@@ -119,6 +126,152 @@ func runMigration[D migrate.Driver[C, T], C any, T any](
 	return nil
 }
 
+// NewMigrateStatusCommand creates a command which reports the status of the migrations of the
+// datastore, including the progress of any online migration which has been started.
+func NewMigrateStatusCommand(programName string) *cobra.Command {
+	return &cobra.Command{
+		Use:     "status",
+		Short:   "report the status of datastore schema migrations",
+		PreRunE: server.DefaultPreRunE(programName),
+		RunE:    migrateStatusRun,
+		Args:    cobra.ExactArgs(0),
+	}
+}
+
+func migrateStatusRun(cmd *cobra.Command, _ []string) error {
+	datastoreEngine := cobrautil.MustGetStringExpanded(cmd, "datastore-engine")
+	dbURL := cobrautil.MustGetStringExpanded(cmd, "datastore-conn-uri")
+
+	switch datastoreEngine {
+	case "cockroachdb":
+		migrationDriver, err := crdbmigrations.NewCRDBDriver(dbURL)
+		if err != nil {
+			return fmt.Errorf("unable to create migration driver for %s: %w", datastoreEngine, err)
+		}
+		return printMigrationStatus(cmd.Context(), cmd.OutOrStdout(), migrationDriver, crdbmigrations.CRDBMigrations)
+
+	case "postgres":
+		migrationDriver, err := migrations.NewAlembicPostgresDriver(dbURL)
+		if err != nil {
+			return fmt.Errorf("unable to create migration driver for %s: %w", datastoreEngine, err)
+		}
+		return printMigrationStatus(cmd.Context(), cmd.OutOrStdout(), migrationDriver, migrations.DatabaseMigrations)
+
+	case "spanner":
+		credFile := cobrautil.MustGetStringExpanded(cmd, "datastore-spanner-credentials")
+		emulatorHost := cobrautil.MustGetStringExpanded(cmd, "datastore-spanner-emulator-host")
+		migrationDriver, err := spannermigrations.NewSpannerDriver(dbURL, credFile, emulatorHost)
+		if err != nil {
+			return fmt.Errorf("unable to create migration driver for %s: %w", datastoreEngine, err)
+		}
+		return printMigrationStatus(cmd.Context(), cmd.OutOrStdout(), migrationDriver, spannermigrations.SpannerMigrations)
+
+	case "mysql":
+		tablePrefix := cobrautil.MustGetStringExpanded(cmd, "datastore-mysql-table-prefix")
+		migrationDriver, err := mysqlmigrations.NewMySQLDriverFromDSN(dbURL, tablePrefix)
+		if err != nil {
+			return fmt.Errorf("unable to create migration driver for %s: %w", datastoreEngine, err)
+		}
+		return printMigrationStatus(cmd.Context(), cmd.OutOrStdout(), migrationDriver, mysqlmigrations.Manager)
+	}
+
+	return fmt.Errorf("cannot migrate datastore engine type: %s", datastoreEngine)
+}
+
+func printMigrationStatus[D migrate.Driver[C, T], C any, T any](ctx context.Context, w io.Writer, driver D, manager *migrate.Manager[D, C, T]) error {
+	status, err := manager.Status(ctx, driver)
+	if err != nil {
+		return fmt.Errorf("unable to load migration status: %w", err)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "version:\t%s\n", status.Version)
+	fmt.Fprintf(tw, "head:\t%s\n", status.HeadRevision)
+	for _, pending := range status.Pending {
+		fmt.Fprintf(tw, "pending:\t%s\n", pending)
+	}
+	if status.InProgress != nil {
+		fmt.Fprintf(tw, "in progress:\tphase %s, %d batches backfilled, updated %s\n",
+			status.InProgress.Phase, status.InProgress.Batches, status.InProgress.UpdatedAt.Format(time.RFC3339))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	return driver.Close(ctx)
+}
+
+func NewMigratePlanCommand(programName string) *cobra.Command {
+	return &cobra.Command{
+		Use:     "plan [revision]",
+		Short:   "report, as JSON, the datastore schema migrations which would be run, without running them",
+		Long:    "Reports the current and target revisions, the migrations which would be run and which of them are destructive, estimated backfill sizes, and whether the connected user has the privileges required to run them.",
+		PreRunE: server.DefaultPreRunE(programName),
+		RunE:    migratePlanRun,
+		Args:    cobra.MaximumNArgs(1),
+	}
+}
+
+func migratePlanRun(cmd *cobra.Command, args []string) error {
+	datastoreEngine := cobrautil.MustGetStringExpanded(cmd, "datastore-engine")
+	dbURL := cobrautil.MustGetStringExpanded(cmd, "datastore-conn-uri")
+
+	targetRevision := migrate.Head
+	if len(args) > 0 {
+		targetRevision = args[0]
+	}
+
+	switch datastoreEngine {
+	case "cockroachdb":
+		migrationDriver, err := crdbmigrations.NewCRDBDriver(dbURL)
+		if err != nil {
+			return fmt.Errorf("unable to create migration driver for %s: %w", datastoreEngine, err)
+		}
+		return printMigrationPlan(cmd.Context(), migrationDriver, crdbmigrations.CRDBMigrations, targetRevision)
+
+	case "postgres":
+		migrationDriver, err := migrations.NewAlembicPostgresDriver(dbURL)
+		if err != nil {
+			return fmt.Errorf("unable to create migration driver for %s: %w", datastoreEngine, err)
+		}
+		return printMigrationPlan(cmd.Context(), migrationDriver, migrations.DatabaseMigrations, targetRevision)
+
+	case "spanner":
+		credFile := cobrautil.MustGetStringExpanded(cmd, "datastore-spanner-credentials")
+		emulatorHost := cobrautil.MustGetStringExpanded(cmd, "datastore-spanner-emulator-host")
+		migrationDriver, err := spannermigrations.NewSpannerDriver(dbURL, credFile, emulatorHost)
+		if err != nil {
+			return fmt.Errorf("unable to create migration driver for %s: %w", datastoreEngine, err)
+		}
+		return printMigrationPlan(cmd.Context(), migrationDriver, spannermigrations.SpannerMigrations, targetRevision)
+
+	case "mysql":
+		tablePrefix := cobrautil.MustGetStringExpanded(cmd, "datastore-mysql-table-prefix")
+		migrationDriver, err := mysqlmigrations.NewMySQLDriverFromDSN(dbURL, tablePrefix)
+		if err != nil {
+			return fmt.Errorf("unable to create migration driver for %s: %w", datastoreEngine, err)
+		}
+		return printMigrationPlan(cmd.Context(), migrationDriver, mysqlmigrations.Manager, targetRevision)
+	}
+
+	return fmt.Errorf("cannot migrate datastore engine type: %s", datastoreEngine)
+}
+
+func printMigrationPlan[D migrate.Driver[C, T], C any, T any](ctx context.Context, driver D, manager *migrate.Manager[D, C, T], targetRevision string) error {
+	plan, err := manager.Plan(ctx, driver, targetRevision)
+	if err != nil {
+		return fmt.Errorf("unable to plan migrations: %w", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(plan); err != nil {
+		return fmt.Errorf("unable to encode migration plan: %w", err)
+	}
+
+	return driver.Close(ctx)
+}
+
 func RegisterHeadFlags(cmd *cobra.Command) {
 	cmd.Flags().String("datastore-engine", "postgres", fmt.Sprintf(`type of datastore to initialize (%s)`, datastore.EngineOptions()))
 }
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/authzed/grpcutil"
+	"github.com/jzelinskie/cobrautil/v2"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/authzed/spicedb/internal/middleware/capture"
+	"github.com/authzed/spicedb/pkg/cmd/server"
+)
+
+func RegisterReplayFlags(cmd *cobra.Command) {
+	cmd.Flags().String("endpoint", "localhost:50051", "address of the gRPC API of the cluster against which requests are replayed")
+	cmd.Flags().String("token", "", "preshared key with which requests are replayed")
+	cmd.Flags().Bool("insecure", false, "connect without TLS")
+	cmd.Flags().String("ca-path", "", "path of the certificate authority with which to verify the endpoint. defaults to the system certificates")
+	cmd.Flags().Int("concurrency", 8, "number of requests to replay in parallel")
+	cmd.Flags().Bool("json", false, "output the report, including every mismatch, as JSON")
+	cmd.Flags().Int("max-mismatches", 10, "number of mismatches to print after the table")
+}
+
+func NewReplayCommand(programName string) *cobra.Command {
+	return &cobra.Command{
+		Use:     "replay <capture file>...",
+		Short:   "replay captured requests against a cluster, comparing its responses",
+		Long:    "Replays the requests captured by a server with --capture-directory against a cluster, such as a staging cluster running a new version, and reports those whose status or responses differ from those captured, failing if any differ.",
+		PreRunE: server.DefaultPreRunE(programName),
+		RunE:    replayCmdFunc,
+		Args:    cobra.MinimumNArgs(1),
+	}
+}
+
+func replayCmdFunc(cmd *cobra.Command, args []string) error {
+	var exchanges []capture.Exchange
+	for _, path := range args {
+		read, err := capture.ReadExchanges(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		exchanges = append(exchanges, read...)
+	}
+
+	token := cobrautil.MustGetString(cmd, "token")
+	var opts []grpc.DialOption
+	switch {
+	case cobrautil.MustGetBool(cmd, "insecure"):
+		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()), grpcutil.WithInsecureBearerToken(token))
+	case cobrautil.MustGetString(cmd, "ca-path") != "":
+		opts = append(opts, grpcutil.WithCustomCerts(cobrautil.MustGetString(cmd, "ca-path"), grpcutil.VerifyCA), grpcutil.WithBearerToken(token))
+	default:
+		opts = append(opts, grpcutil.WithSystemCerts(grpcutil.VerifyCA), grpcutil.WithBearerToken(token))
+	}
+
+	conn, err := grpc.DialContext(cmd.Context(), cobrautil.MustGetString(cmd, "endpoint"), opts...)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close()
+
+	report := capture.Replay(cmd.Context(), conn, exchanges, cobrautil.MustGetInt(cmd, "concurrency"))
+
+	if cobrautil.MustGetBool(cmd, "json") {
+		encoder := json.NewEncoder(cmd.OutOrStdout())
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		if err := report.WriteTable(cmd.OutOrStdout()); err != nil {
+			return err
+		}
+
+		encoder := json.NewEncoder(cmd.OutOrStdout())
+		for i, mismatch := range report.Mismatches {
+			if i >= cobrautil.MustGetInt(cmd, "max-mismatches") {
+				fmt.Fprintf(cmd.OutOrStdout(), "... and %d more mismatches\n", len(report.Mismatches)-i)
+				break
+			}
+			if err := encoder.Encode(mismatch); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(report.Mismatches) > 0 {
+		return fmt.Errorf("%d replayed requests did not match those captured", len(report.Mismatches))
+	}
+	return nil
+}
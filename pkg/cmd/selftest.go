@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/authzed/grpcutil"
+	"github.com/jzelinskie/cobrautil/v2"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/authzed/spicedb/internal/selftest"
+	datastorecfg "github.com/authzed/spicedb/pkg/cmd/datastore"
+	"github.com/authzed/spicedb/pkg/cmd/server"
+)
+
+func RegisterSelfTestFlags(cmd *cobra.Command, config *server.Config) error {
+	if err := RegisterServeFlags(cmd, config); err != nil {
+		return err
+	}
+
+	cmd.Flags().Duration("selftest-check-timeout", 10*time.Second, "time after which each check fails")
+	cmd.Flags().Duration("selftest-max-clock-skew", 500*time.Millisecond, "maximum difference between the local clock and that of the datastore, for datastores whose revisions are timestamps")
+	cmd.Flags().Bool("json", false, "output the report as JSON")
+	return nil
+}
+
+func NewSelfTestCommand(programName string, config *server.Config) *cobra.Command {
+	return &cobra.Command{
+		Use:     "selftest",
+		Short:   "validate the configuration of the server against its environment",
+		Long:    "Validates the configuration of the server, given by the same flags as serve, against the environment in which it is deployed: that the datastore can be reached and is migrated, that TLS connections can be established under the TLS policy, that the TLS certificates and keys can be loaded and are valid, that the dispatch peers can be reached and that the local clock agrees with that of the datastore. Fails if any check fails.",
+		PreRunE: server.DefaultPreRunE(programName),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			checks, closeDatastore := selfTestChecks(cmd, config)
+			defer closeDatastore()
+
+			report := selftest.Run(cmd.Context(), checks, cobrautil.MustGetDuration(cmd, "selftest-check-timeout"))
+			if cobrautil.MustGetBool(cmd, "json") {
+				encoder := json.NewEncoder(cmd.OutOrStdout())
+				encoder.SetIndent("", "  ")
+				if err := encoder.Encode(report); err != nil {
+					return err
+				}
+			} else if err := report.WriteTable(cmd.OutOrStdout()); err != nil {
+				return err
+			}
+
+			if !report.Passed {
+				return errors.New("the self test failed")
+			}
+			return nil
+		},
+	}
+}
+
+// selfTestChecks returns the checks of the configuration, along with a function closing the
+// datastore they check. Checks of the datastore are skipped if it cannot be created.
+func selfTestChecks(cmd *cobra.Command, config *server.Config) ([]selftest.Check, func()) {
+	var checks []selftest.Check
+	closeDatastore := func() {}
+
+	ds, err := datastorecfg.NewDatastore(cmd.Context(), config.DatastoreConfig.ToOption())
+	if err != nil {
+		checks = append(checks,
+			selftest.Check{
+				Name: "datastore",
+				Run: func(_ context.Context) (selftest.Status, string) {
+					return selftest.StatusFailed, fmt.Sprintf("failed to create the datastore: %s", err)
+				},
+			},
+			selftest.Skipped("migration", "the datastore could not be created"),
+			selftest.Skipped("clock-skew", "the datastore could not be created"),
+		)
+	} else {
+		closeDatastore = func() { _ = ds.Close() }
+		checks = append(checks, selftest.Datastore(ds), selftest.Migration(ds))
+
+		switch config.DatastoreConfig.Engine {
+		case datastorecfg.CockroachEngine, datastorecfg.SpannerEngine:
+			checks = append(checks, selftest.ClockSkew(ds, cobrautil.MustGetDuration(cmd, "selftest-max-clock-skew")))
+		default:
+			checks = append(checks, selftest.Skipped("clock-skew", fmt.Sprintf("the %s datastore does not report the time of its clock", config.DatastoreConfig.Engine)))
+		}
+	}
+
+	checks = append(checks, selftest.TLSPolicy(config.TLSPolicy))
+	checks = append(checks, selftest.TLS("grpc", config.GRPCServer.TLSCertPath, config.GRPCServer.TLSKeyPath))
+	if config.DispatchServer.Enabled {
+		checks = append(checks, selftest.TLS("dispatch-cluster", config.DispatchServer.TLSCertPath, config.DispatchServer.TLSKeyPath))
+	}
+	if config.HTTPGateway.Enabled {
+		checks = append(checks, selftest.TLS("http", config.HTTPGateway.TLSCertPath, config.HTTPGateway.TLSKeyPath))
+	}
+	if config.MetricsAPI.Enabled {
+		checks = append(checks, selftest.TLS("metrics", config.MetricsAPI.TLSCertPath, config.MetricsAPI.TLSKeyPath))
+	}
+	if config.DashboardAPI.Enabled {
+		checks = append(checks, selftest.TLS("dashboard", config.DashboardAPI.TLSCertPath, config.DashboardAPI.TLSKeyPath))
+	}
+
+	dialOpt := grpc.WithTransportCredentials(insecure.NewCredentials())
+	if config.DispatchUpstreamCAPath != "" {
+		dialOpt = grpcutil.WithCustomCerts(config.DispatchUpstreamCAPath, grpcutil.VerifyCA)
+	}
+	return append(checks, selftest.DispatchPeer(config.DispatchUpstreamAddr, dialOpt)), closeDatastore
+}
@@ -3,14 +3,22 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 
+	dscommon "github.com/authzed/spicedb/internal/datastore/common"
+	"github.com/authzed/spicedb/internal/experiments"
+	"github.com/authzed/spicedb/internal/jobs"
+	"github.com/authzed/spicedb/internal/middleware/concurrencylimit"
+	"github.com/authzed/spicedb/internal/middleware/priority"
 	"github.com/authzed/spicedb/internal/telemetry"
+	"github.com/authzed/spicedb/pkg/cmd/configfile"
 	"github.com/authzed/spicedb/pkg/cmd/datastore"
 	"github.com/authzed/spicedb/pkg/cmd/server"
 	"github.com/authzed/spicedb/pkg/cmd/util"
+	"github.com/authzed/spicedb/pkg/redaction"
 )
 
 const PresharedKeyFlag = "grpc-preshared-key"
@@ -46,10 +54,23 @@ func RegisterServeFlags(cmd *cobra.Command, config *server.Config) error {
 	config.DispatchClusterMetricsEnabled = true
 	config.DispatchClientMetricsEnabled = true
 
+	cmd.Flags().StringVar(&config.ConfigFile, configfile.FlagName, "", "path to a yaml config file of flag values, which flags and the environment take precedence over. the log level, cache sizes and default request concurrency limit are reloaded whenever it changes")
+
+	// Flags for the TLS policy of every listener
+	cmd.Flags().StringVar(&config.TLSPolicy.MinVersion, "tls-min-version", "1.2", `minimum version of TLS negotiated by every listener served with TLS ("1.2", "1.3")`)
+	cmd.Flags().StringSliceVar(&config.TLSPolicy.CipherSuites, "tls-cipher-suites", nil, "TLS 1.2 cipher suites allowed by every listener served with TLS, such as TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256. defaults to the secure cipher suites of Go. the cipher suites of TLS 1.3 are not configurable")
+	cmd.Flags().BoolVar(&config.TLSPolicy.FIPSMode, "tls-fips-mode", false, "restricts every listener served with TLS to the cipher suites and curves approved under FIPS 140, verified by a TLS self test at startup. requires a FIPS build of SpiceDB, built with GOEXPERIMENT=boringcrypto: FIPS compliance comes from that build, not from this flag, which only restricts the configuration of TLS")
+
 	// Flags for the gRPC API server
 	util.RegisterGRPCServerFlags(cmd.Flags(), &config.GRPCServer, "grpc", "gRPC", ":50051", true)
 	cmd.Flags().StringSliceVar(&config.PresharedKey, PresharedKeyFlag, []string{}, "preshared key(s) to require for authenticated requests")
+	cmd.Flags().StringVar(&config.PresharedKeyFile, "grpc-preshared-key-file", "", fmt.Sprintf("path to a file of additional preshared keys to accept, one per line, reloaded whenever it changes so that keys can be rotated. --%s remains required, and is used for dispatch", PresharedKeyFlag))
+	cmd.Flags().StringSliceVar(&config.PresharedKeyPriorities, "grpc-preshared-key-priority", []string{}, fmt.Sprintf("maximum priority class (%s or %s) of the requests made with the preshared key at the same position in --%s. empty allows any priority", priority.Interactive, priority.Bulk, PresharedKeyFlag))
+	cmd.Flags().StringArrayVar(&config.PresharedKeyNamespaces, "grpc-preshared-key-namespaces", []string{}, fmt.Sprintf("comma-separated namespaces visible to the preshared key at the same position in --%s, each a definition name or a prefix such as tenant/*. requests referencing other namespaces are denied, and their relationships are filtered from responses. empty allows every namespace", PresharedKeyFlag))
+	cmd.Flags().StringSliceVar(&config.PresharedKeyDispatchPools, "grpc-preshared-key-dispatch-pool", []string{}, fmt.Sprintf("dispatch pool, as defined by --dispatch-pool-slots, to which the requests made with the preshared key at the same position in --%s are routed, taking precedence over --dispatch-pool-namespaces. empty routes by namespace", PresharedKeyFlag))
 	cmd.Flags().DurationVar(&config.ShutdownGracePeriod, "grpc-shutdown-grace-period", 0*time.Second, "amount of time after receiving sigint to continue serving")
+	cmd.Flags().StringToStringVar(&config.MethodTimeouts, "grpc-method-timeout", map[string]string{"CheckPermission": "10s", "LookupResources": "1m", "LookupSubjects": "1m"}, "timeout of the requests made without a deadline to each method, named alone, such as CheckPermission, or in full, such as /authzed.api.v1.PermissionsService/CheckPermission. methods without a timeout are not bounded")
+	cmd.Flags().StringToStringVar(&config.MethodMaximumTimeouts, "grpc-method-maximum-timeout", map[string]string{"CheckPermission": "1m", "LookupResources": "10m", "LookupSubjects": "10m"}, "maximum timeout of the requests made with a deadline to each method, named as in --grpc-method-timeout, to which later deadlines are shortened. methods without a maximum keep the deadline of the caller")
 	if err := cmd.MarkFlagRequired(PresharedKeyFlag); err != nil {
 		return fmt.Errorf("failed to mark flag as required: %w", err)
 	}
@@ -65,6 +86,7 @@ func RegisterServeFlags(cmd *cobra.Command, config *server.Config) error {
 		return fmt.Errorf("failed to mark flag as hidden: %w", err)
 	}
 	server.RegisterCacheFlags(cmd.Flags(), "ns-cache", &config.NamespaceCacheConfig, namespaceCacheDefaults)
+	cmd.Flags().DurationVar(&config.NamespaceCacheStaleWhileRevalidate, "ns-cache-stale-while-revalidate", 0, "maximum age of a cached definition served while it is reloaded at a newer revision, which delays observing schema changes made through other nodes by up to this amount. 0 disables serving stale definitions")
 
 	// Flags for parsing and validating schemas.
 	cmd.Flags().BoolVar(&config.SchemaPrefixesRequired, "schema-prefixes-required", false, "require prefixes on all object definitions in schemas")
@@ -92,12 +114,28 @@ func RegisterServeFlags(cmd *cobra.Command, config *server.Config) error {
 	util.RegisterGRPCServerFlags(cmd.Flags(), &config.DispatchServer, "dispatch-cluster", "dispatch", ":50053", false)
 	server.RegisterCacheFlags(cmd.Flags(), "dispatch-cache", &config.DispatchCacheConfig, dispatchCacheDefaults)
 	server.RegisterCacheFlags(cmd.Flags(), "dispatch-cluster-cache", &config.ClusterDispatchCacheConfig, dispatchClusterCacheDefaults)
+	cmd.Flags().StringVar(&config.DispatchCachePersistencePath, "dispatch-cache-persistence-path", "", "local path of a file to which the dispatch cache is persisted, so that it survives restarts. empty disables persistence")
+	cmd.Flags().DurationVar(&config.DispatchCachePersistenceInterval, "dispatch-cache-persistence-interval", 1*time.Minute, "interval at which the dispatch cache is persisted, in addition to on shutdown. 0 only persists on shutdown")
 
 	// Flags for configuring dispatch requests
 	cmd.Flags().Uint32Var(&config.DispatchMaxDepth, "dispatch-max-depth", 50, "maximum recursion depth for nested calls")
 	cmd.Flags().StringVar(&config.DispatchUpstreamAddr, "dispatch-upstream-addr", "", "upstream grpc address to dispatch to")
 	cmd.Flags().StringVar(&config.DispatchUpstreamCAPath, "dispatch-upstream-ca-path", "", "local path to the TLS CA used when connecting to the dispatch cluster")
 	cmd.Flags().DurationVar(&config.DispatchUpstreamTimeout, "dispatch-upstream-timeout", 60*time.Second, "maximum duration of a dispatch call an upstream cluster before it times out")
+	cmd.Flags().DurationVar(&config.DispatchDeadlineMargin, "dispatch-deadline-margin", 10*time.Millisecond, "duration subtracted from the time remaining to a request when dispatching it to the upstream cluster, allowing for the transit of the response, so that the upstream abandons the request before its caller does")
+	cmd.Flags().DurationVar(&config.DispatchHedgingDelay, "dispatch-hedging-delay", 0, "duration after which a check, expand or lookup dispatched to the upstream cluster which has not been answered is also computed locally, with the first result used. 0 disables hedging")
+	cmd.Flags().Uint32Var(&config.DispatchCircuitBreakerFailures, "dispatch-circuit-breaker-failures", 0, "number of consecutive failed or timed out dispatches to an upstream peer after which its requests are computed locally until it recovers. 0 disables circuit breaking")
+	cmd.Flags().DurationVar(&config.DispatchCircuitBreakerOpenTime, "dispatch-circuit-breaker-open-time", 10*time.Second, "duration for which the requests of a failing upstream peer are computed locally before it is probed for recovery")
+	cmd.Flags().Uint16Var(&config.DispatchUpstreamConnections, "dispatch-upstream-connections", 1, "number of connections to each upstream peer across which dispatches are spread, to avoid head-of-line blocking on a single connection")
+	cmd.Flags().DurationVar(&config.DispatchUpstreamKeepaliveTime, "dispatch-upstream-keepalive-time", 0, "interval between keepalive pings sent on idle connections to upstream peers; peers accept pings this often from each other. 0 disables keepalive pings")
+	cmd.Flags().DurationVar(&config.DispatchUpstreamKeepaliveTTL, "dispatch-upstream-keepalive-timeout", 20*time.Second, "duration to wait for a keepalive ping to be acknowledged by an upstream peer before closing the connection")
+	cmd.Flags().Uint32Var(&config.DispatchUpstreamMaxInflight, "dispatch-upstream-max-inflight-per-peer", 0, "maximum number of dispatches in flight to each upstream peer, beyond which they are computed locally. 0 is unlimited")
+	cmd.Flags().Uint64Var(&config.DispatchLoadAwareConfig.OverloadThreshold, "dispatch-overloaded-peer-threshold", 500, "number of dispatches in flight reported by an upstream peer above which it is considered overloaded, and a fraction of the requests for its keys are routed to other peers")
+	cmd.Flags().Float64Var(&config.DispatchLoadAwareConfig.MaxOffloadFraction, "dispatch-max-offload-fraction", 0.1, "maximum fraction, between 0 and 1, of the requests for the keys of an overloaded upstream peer which are routed to other peers. 0 disables offloading")
+	cmd.Flags().StringVar(&config.DispatchShadowUpstreamAddr, "dispatch-shadow-upstream-addr", "", "grpc address of the dispatch api of a shadow cluster, such as one running a new resolver implementation, to which a sample of the checks and lookups of api requests are also dispatched, reporting divergences from their results via metrics and logs without affecting responses. connects with --dispatch-upstream-ca-path, if set. empty disables shadow dispatching")
+	cmd.Flags().Float64Var(&config.DispatchShadowConfig.SampleRate, "dispatch-shadow-sample-rate", 0.01, "fraction of api dispatches also made to the shadow cluster")
+	cmd.Flags().DurationVar(&config.DispatchShadowConfig.Timeout, "dispatch-shadow-timeout", 10*time.Second, "time given to each dispatch made to the shadow cluster")
+	cmd.Flags().IntVar(&config.DispatchShadowConfig.MaxInflight, "dispatch-shadow-max-inflight", 100, "maximum number of dispatches in flight to the shadow cluster, beyond which sampled dispatches are not compared")
 
 	cmd.Flags().Uint16Var(&config.GlobalDispatchConcurrencyLimit, "dispatch-concurrency-limit", 50, "maximum number of parallel goroutines to create for each request or subrequest")
 
@@ -105,12 +143,52 @@ func RegisterServeFlags(cmd *cobra.Command, config *server.Config) error {
 	cmd.Flags().Uint16Var(&config.DispatchConcurrencyLimits.LookupResources, "dispatch-lookup-resources-concurrency-limit", 0, "maximum number of parallel goroutines to create for each lookup resources request or subrequest. defaults to --dispatch-concurrency-limit")
 	cmd.Flags().Uint16Var(&config.DispatchConcurrencyLimits.LookupSubjects, "dispatch-lookup-subjects-concurrency-limit", 0, "maximum number of parallel goroutines to create for each lookup subjects request or subrequest. defaults to --dispatch-concurrency-limit")
 	cmd.Flags().Uint16Var(&config.DispatchConcurrencyLimits.ReachableResources, "dispatch-reachable-resources-concurrency-limit", 0, "maximum number of parallel goroutines to create for each reachable resources request or subrequest. defaults to --dispatch-concurrency-limit")
+	cmd.Flags().Uint16Var(&config.DefaultRequestConcurrencyLimit, "dispatch-default-request-concurrency-limit", 0, fmt.Sprintf("maximum number of parallel goroutines to create for each subrequest of an API request that does not specify a limit via the %s header. defaults to no limit beyond the dispatch concurrency limits", concurrencylimit.RequestConcurrencyLimitHeader))
+	cmd.Flags().Uint16Var(&config.DispatchPrioritySlots, "dispatch-priority-slots", 0, fmt.Sprintf("maximum number of API dispatches to run concurrently, with waiting dispatches admitted by weighted fair queuing of their priority class, as determined by the token or the %s header. 0 disables scheduling", priority.RequestPriorityHeader))
+	cmd.Flags().StringToIntVar(&config.DispatchPriorityWeights, "dispatch-priority-weights", map[string]int{string(priority.Interactive): 4, string(priority.Bulk): 1}, "relative share of the dispatch priority slots given to each priority class when contended")
+	cmd.Flags().StringToIntVar(&config.DispatchPoolSlots, "dispatch-pool-slots", map[string]int{}, "dedicated pools of API dispatches, by name, each running up to the given number of dispatches concurrently independently of the other pools and of --dispatch-priority-slots, such that requests routed to one pool cannot delay those routed elsewhere")
+	cmd.Flags().StringToStringVar(&config.DispatchPoolNamespaces, "dispatch-pool-namespaces", map[string]string{}, "dispatch pool to which the dispatches of each namespace are routed, by namespace name or by prefix such as tenant/*. dispatches of other namespaces are scheduled by --dispatch-priority-slots, if set")
 
 	// Flags for configuring API behavior
 	cmd.Flags().BoolVar(&config.DisableV1SchemaAPI, "disable-v1-schema-api", false, "disables the V1 schema API")
 	cmd.Flags().BoolVar(&config.DisableVersionResponse, "disable-version-response", false, "disables version response support in the API")
 	cmd.Flags().Uint16Var(&config.MaximumUpdatesPerWrite, "write-relationships-max-updates-per-call", 1000, "maximum number of updates allowed for WriteRelationships calls")
 	cmd.Flags().Uint16Var(&config.MaximumPreconditionCount, "update-relationships-max-preconditions-per-call", 1000, "maximum number of preconditions allowed for WriteRelationships and DeleteRelationships calls")
+	cmd.Flags().Uint64Var(&config.MaximumResultSize, "max-result-size", 0, "maximum number of nodes and subjects returned by ExpandPermissionTree and of resources returned by LookupResources, beyond which calls fail rather than materializing the result; callers may lower it via the io.spicedb.maxresultsize header. 0 for no maximum")
+	cmd.Flags().Float64Var(&config.TraceSampleRate, "trace-sample-rate", 0, "fraction of CheckPermission and LookupResources requests whose debug traces are recorded, by request ID, for retrieval via the admin API. Traces are also recorded for requests asking for debug information when tracing is enabled. 0 records no sampled traces")
+	cmd.Flags().StringVar(&config.TraceStorePath, "trace-store-path", "", "directory in which recorded traces are written, which may be shared by all servers. If empty, the most recent traces are held in memory")
+
+	cmd.Flags().Uint32Var(&config.MaximumNestingDepth, "write-relationships-max-nesting-depth", 0, "maximum depth of the chains of nested relationships, such as of groups within groups, which WriteRelationships calls may create. Writes creating deeper chains, or cycles, are rejected. 0 for no maximum")
+	cmd.Flags().BoolVar(&config.StrictRelationshipValidation, "write-relationships-strict-validation", false, "validate every update in WriteRelationships calls against the schema, reporting all invalid updates and requiring referenced caveats to exist")
+	cmd.Flags().DurationVar(&config.RelationshipRestoreWindow, "delete-relationships-restore-window", 0, "period after a DeleteRelationships call during which the deleted relationships can be restored with the token returned in its response headers. deleted relationships are retained in the datastore, hidden from all reads, until they are restored or the period has passed. requires --delete-relationships-restore-token-keys. 0 disables restoring")
+	cmd.Flags().DurationVar(&config.RelationshipRestorePurgeInterval, "delete-relationships-purge-interval", 1*time.Minute, "interval between purges of the deleted relationships retained for longer than --delete-relationships-restore-window. 0 disables purging")
+	cmd.Flags().StringSliceVar(&config.RelationshipRestoreTokenKeys, "delete-relationships-restore-token-keys", nil, `keys used to sign the tokens restoring deleted relationships, of the form "id=base64key", which must be shared by every server; the first key is used to sign new tokens, the remainder only for verification`)
+	cmd.Flags().BoolVar(&config.AdminAPIEnabled, "admin-api-enabled", false, "enables the admin API, which exposes operations such as cleaning up orphaned relationships")
+	cmd.Flags().BoolVar(&config.PlaygroundAPIEnabled, "playground-api-enabled", false, "enables the developer API used by the playground to compile schemas, run validations and share them, for running a private playground")
+	cmd.Flags().StringVar(&config.PlaygroundShareStoreSalt, "playground-share-store-salt", "", "salt for hashing the references to schemas shared via the playground API, which are kept in memory")
+	cmd.Flags().IntVar(&config.PlaygroundShareStoreMaxBytes, "playground-share-store-max-bytes", 64*1024*1024, "maximum total size of the schemas shared via the playground API kept in memory, beyond which the oldest are evicted")
+	cmd.Flags().DurationVar(&config.OrphanScanInterval, "orphan-scan-interval", 0, "interval between background scans for relationships no longer valid under the schema, reported via metrics. 0 disables scanning")
+	cmd.Flags().DurationVar(&config.RelationshipExpirationInterval, "relationship-expiration-interval", 1*time.Minute, "interval between background deletions of the relationships of relations bounded in time with `within` whose bound has passed. 0 disables deletion")
+	cmd.Flags().DurationVar(&config.RelationUsageAnalysisInterval, "relation-usage-analysis-interval", 0, "interval between background analyses of the requests and relationships for each relation and permission, reported via metrics and the admin API. 0 disables analysis")
+	cmd.Flags().Uint64Var(&config.QuotaRelationshipsSoftLimit, "quota-relationships-soft-limit", 0, "number of relationships written by a token above which its writes are reported via metrics. 0 for no limit")
+	cmd.Flags().Uint64Var(&config.QuotaRelationshipsHardLimit, "quota-relationships-hard-limit", 0, "number of relationships written by a token above which its writes are denied. 0 for no limit")
+	cmd.Flags().Uint64Var(&config.QuotaNamespacesSoftLimit, "quota-namespaces-soft-limit", 0, "number of object definitions in a schema written by a token above which the write is reported via metrics. 0 for no limit")
+	cmd.Flags().Uint64Var(&config.QuotaNamespacesHardLimit, "quota-namespaces-hard-limit", 0, "number of object definitions in a schema written by a token above which the write is denied. 0 for no limit")
+	cmd.Flags().StringSliceVar(&config.MaterializedPermissions, "experimental-materialize-permission", []string{}, "permission to materialize in memory, as resource_type#permission@subject_type, for lookups of the resources of a subject via the experimental LookupMaterializedResources API; requires a datastore supporting watch")
+	cmd.Flags().BoolVar(&config.PointInTimeCheckEnabled, "experimental-point-in-time-check-enabled", false, "enables checking permissions at past times via the experimental CheckPermissionAtTime API, for compliance investigations; times preceding the datastore gc window are checked against the archive, if configured")
+	cmd.Flags().StringVar(&config.ArchiveDirectory, "experimental-archive-directory", "", "directory of the archive of relationships, retained beyond the datastore gc window, against which point-in-time checks of earlier times are made")
+	cmd.Flags().BoolVar(&config.ArchiveRecordingEnabled, "experimental-archive-recording-enabled", false, "records the relationships into the archive directory, as periodic snapshots followed by their changes; enable on a single server of the cluster, as each records the whole datastore. Requires a datastore supporting watch")
+	cmd.Flags().DurationVar(&config.ArchiveSnapshotInterval, "experimental-archive-snapshot-interval", 24*time.Hour, "interval between the snapshots of the relationships recorded into the archive, bounding the changes replayed to load a past time")
+	cmd.Flags().DurationVar(&config.SchemaDriftCheckInterval, "datastore-schema-drift-check-interval", 0, "interval between checks that the live schema of the datastore matches its migration revision, reported via metrics and the health service. 0 disables checking")
+	cmd.Flags().DurationVar(&config.StaleCheckMaximumStaleness, "datastore-outage-stale-check-window", 0, "period since the revision of the datastore was last read during which CheckPermission calls failing to read it, such as during a brief database outage, are served at that revision instead, from the caches of the server where they can be, and marked by the io.spicedb.respmeta.servedstale response header. calls requiring full consistency are never served stale. 0 disables serving stale checks")
+	cmd.Flags().DurationVar(&config.RelationCountInterval, "datastore-relation-count-interval", 0, "interval between counts of the live relationships of each namespace and relation, reported via metrics so that runaway growth can be detected. counting scans the relationships of the datastore. 0 disables counting")
+	cmd.Flags().Float64Var(&config.RelationshipGrowthAlertRate, "datastore-relationship-growth-alert-rate", 0, "rate of growth of the live relationships of a relation between two counts, in relationships per second, above which the growth is logged and counted by the spicedb_datastore_relation_relationships_growth_alerts_total metric. 0 disables the alerts")
+	cmd.Flags().BoolVar(&config.LeaderElectionEnabled, "datastore-leader-election-enabled", false, "runs the background tasks which need only run on a single server, such as relation counting, orphan scanning, LDAP sync and the revision heartbeat, on the server holding their lease in the datastore, rather than on every server. Requires a datastore supporting leases")
+	cmd.Flags().DurationVar(&config.LeaderLeaseDuration, "datastore-leader-lease-duration", dscommon.DefaultLeaseDuration, "duration of the lease held by the server running a background task, renewed while it runs; another server takes over the task once the lease of a server which stops expires")
+	cmd.Flags().BoolVar(&config.JobsEnabled, "jobs-enabled", true, "runs the jobs started via the experimental job APIs, such as bulk deletes of relationships. each job is run by a single server, which holds a lease on it in the datastore. Requires a datastore supporting jobs")
+	cmd.Flags().DurationVar(&config.JobLeaseDuration, "jobs-lease-duration", jobs.DefaultLeaseDuration, "duration of the lease held by a server on a job it runs, renewed while the job runs; the jobs of a server which stops are run again by another server once their lease expires")
+	cmd.Flags().DurationVar(&config.JobPollInterval, "jobs-poll-interval", jobs.DefaultPollInterval, "interval at which each server looks for pending jobs to run")
+	cmd.Flags().DurationVar(&config.RevisionHeartbeatInterval, "datastore-revision-heartbeat-interval", 0, "interval after which an empty transaction is written to advance the revision of an idle datastore, so that quantized revisions and Watch checkpoints keep advancing. 0 disables the heartbeat")
 
 	cmd.Flags().BoolVar(&config.V1SchemaAdditiveOnly, "testing-only-schema-additive-writes", false, "append new definitions to the existing schema, rather than overwriting it")
 	if err := cmd.Flags().MarkHidden("testing-only-schema-additive-writes"); err != nil {
@@ -120,11 +198,99 @@ func RegisterServeFlags(cmd *cobra.Command, config *server.Config) error {
 	// Flags for misc services
 	util.RegisterHTTPServerFlags(cmd.Flags(), &config.DashboardAPI, "dashboard", "dashboard", ":8080", true)
 	util.RegisterHTTPServerFlags(cmd.Flags(), &config.MetricsAPI, "metrics", "metrics", ":9090", true)
+	cmd.Flags().BoolVar(&config.TopologyEnabled, "topology-enabled", false, "serve the /topology endpoint on the metrics server, reporting the address of this server, its dispatch peers and hints of their load, for client-side load balancing. requires --topology-advertised-api-address")
+	cmd.Flags().StringVar(&config.TopologyAdvertisedAPIAddress, "topology-advertised-api-address", "", "address at which clients reach the gRPC API of this server, reported by the /topology endpoint of the metrics server")
+
+	// Flags for LDAP group reconciliation
+	cmd.Flags().DurationVar(&config.LDAPSyncInterval, "ldap-sync-interval", 0, "interval between reconciliations of the relations configured in the ldap mapping file to the groups in ldap. 0 disables reconciliation")
+	cmd.Flags().StringVar(&config.LDAPSyncMappingFile, "ldap-sync-mapping-file", "", "local path to the yaml file mapping ldap groups onto relations")
+	cmd.Flags().StringVar(&config.LDAPSyncURL, "ldap-sync-url", "", "url of the ldap server from which groups are read (e.g. ldaps://ldap.example.com)")
+	cmd.Flags().StringVar(&config.LDAPSyncBindDN, "ldap-sync-bind-dn", "", "dn to bind to the ldap server as. empty searches anonymously")
+	cmd.Flags().StringVar(&config.LDAPSyncBindPassword, "ldap-sync-bind-password", "", "password of the ldap bind dn")
+	cmd.Flags().BoolVar(&config.LDAPSyncDryRun, "ldap-sync-dry-run", false, "log the changes that ldap reconciliation would make, without making them")
+
+	// Flags for Envoy external authorization
+	util.RegisterGRPCServerFlags(cmd.Flags(), &config.ExtAuthzServer, "ext-authz", "envoy ext_authz", ":50055", false)
+	cmd.Flags().StringVar(&config.ExtAuthzMappingFile, "ext-authz-mapping-file", "", "local path to the yaml file mapping http requests authorized via envoy ext_authz onto permission checks")
+
+	// Flags for the Kubernetes authorization webhook
+	util.RegisterHTTPServerFlags(cmd.Flags(), &config.KubeAuthzServer, "kube-authz", "kubernetes authorization webhook", ":8445", false)
+	cmd.Flags().StringSliceVar(&config.KubeAuthzBearerToken, "kube-authz-bearer-token", []string{}, "bearer token(s) with which the kubernetes api server authenticates to the authorization webhook")
+	cmd.Flags().StringVar(&config.KubeAuthzNamespaceType, "kube-authz-namespace-type", "kubernetes/namespace", "object definition of kubernetes namespaces, on which permissions for namespaced resources are checked")
+	cmd.Flags().StringVar(&config.KubeAuthzClusterType, "kube-authz-cluster-type", "kubernetes/cluster", "object definition of the cluster, on which permissions for cluster-scoped resources are checked")
+	cmd.Flags().StringVar(&config.KubeAuthzClusterID, "kube-authz-cluster-id", "cluster", "object id of the cluster")
+	cmd.Flags().StringVar(&config.KubeAuthzUserType, "kube-authz-user-type", "kubernetes/user", "object definition of kubernetes users")
+	cmd.Flags().StringVar(&config.KubeAuthzGroupType, "kube-authz-group-type", "kubernetes/group", "object definition of kubernetes groups. empty does not check the groups of users")
+	cmd.Flags().StringVar(&config.KubeAuthzGroupRelation, "kube-authz-group-relation", "member", "relation of the group definition whose subjects are the members of the group")
+	cmd.Flags().BoolVar(&config.KubeAuthzDeny, "kube-authz-deny", false, "deny requests for which permission is not found, rather than leaving them to other authorizers")
+
+	// Flags for SCIM group sync
+	util.RegisterHTTPServerFlags(cmd.Flags(), &config.SCIMServer, "scim", "scim group sync", ":8444", false)
+	cmd.Flags().StringSliceVar(&config.SCIMBearerToken, "scim-bearer-token", []string{}, "bearer token(s) with which identity providers authenticate to the scim endpoint")
+	cmd.Flags().StringVar(&config.SCIMGroupType, "scim-group-type", "group", "object definition of the groups synced over scim")
+	cmd.Flags().StringVar(&config.SCIMMemberRelation, "scim-member-relation", "member", "relation of the group definition under which synced members are written")
+	cmd.Flags().StringVar(&config.SCIMUserType, "scim-user-type", "user", "object definition of the users synced over scim as group members")
+	cmd.Flags().BoolVar(&config.SCIMDryRun, "scim-dry-run", false, "log the relationship updates that scim requests would make, without making them")
+
+	// Flags for decision logs
+	cmd.Flags().BoolVar(&config.DecisionLogEnabled, "decision-log-enabled", false, "upload logs of the decisions of permission checks to object storage as gzipped json lines")
+	cmd.Flags().IntVar(&config.DecisionLogConfig.BatchSize, "decision-log-batch-size", 1000, "maximum number of decisions uploaded in a single object")
+	cmd.Flags().DurationVar(&config.DecisionLogConfig.FlushInterval, "decision-log-flush-interval", 10*time.Second, "interval at which decisions are uploaded when fewer than a full batch are buffered")
+	cmd.Flags().IntVar(&config.DecisionLogConfig.MaxBufferedEntries, "decision-log-max-buffered", 100_000, "maximum number of decisions buffered awaiting upload, beyond which decisions are dropped")
+	cmd.Flags().StringToStringVar(&config.DecisionLogConfig.Labels, "decision-log-labels", map[string]string{}, "labels added to every decision, such as the environment")
+	cmd.Flags().StringVar(&config.DecisionLogS3Bucket, "decision-log-s3-bucket", "", "s3 bucket to which decision logs are uploaded")
+	cmd.Flags().StringVar(&config.DecisionLogS3Prefix, "decision-log-s3-prefix", "decisions", "key prefix under which decision logs are uploaded")
+	cmd.Flags().StringVar(&config.DecisionLogS3Endpoint, "decision-log-s3-endpoint", "", "endpoint of an s3-compatible api to which decision logs are uploaded. defaults to aws s3")
+	cmd.Flags().StringVar(&config.DecisionLogS3Region, "decision-log-s3-region", "us-east-1", "region of the s3 bucket to which decision logs are uploaded")
+	cmd.Flags().StringVar(&config.DecisionLogS3AccessKey, "decision-log-s3-access-key", "", "s3 access key for uploading decision logs. defaults to the credentials of the environment")
+	cmd.Flags().StringVar(&config.DecisionLogS3SecretKey, "decision-log-s3-secret-key", "", "s3 secret key for uploading decision logs")
+
+	// Flags for warmup
+	cmd.Flags().StringVar(&config.WarmupConfig.Path, "warmup-path", "", "file to which a sample of CheckPermission, LookupResources and LookupSubjects requests is recorded, and from which they are replayed on startup to populate caches before the server is reported as serving. empty disables warmup")
+	cmd.Flags().Float64Var(&config.WarmupConfig.SampleRate, "warmup-sample-rate", 0.01, "fraction of requests recorded for warmup")
+	cmd.Flags().IntVar(&config.WarmupConfig.MaxRequests, "warmup-max-requests", 1000, "number of the most recently recorded requests kept for warmup")
+	cmd.Flags().DurationVar(&config.WarmupConfig.FlushInterval, "warmup-flush-interval", time.Minute, "interval at which requests recorded for warmup are written to the file")
+	cmd.Flags().IntVar(&config.WarmupConfig.Concurrency, "warmup-concurrency", 10, "number of recorded requests replayed concurrently on startup")
+	cmd.Flags().DurationVar(&config.WarmupConfig.Timeout, "warmup-timeout", time.Minute, "time given to replay recorded requests on startup, after which the server is reported as serving. 0 waits for all requests to be replayed")
+
+	// Flags for request capture
+	cmd.Flags().StringVar(&config.CaptureConfig.Directory, "capture-directory", "", "directory to which a sample of read requests is captured along with their responses, as json lines without request metadata or zedtokens, for replay against another cluster with the replay command. empty disables capture")
+	cmd.Flags().Float64Var(&config.CaptureConfig.SampleRate, "capture-sample-rate", 0.001, "fraction of requests captured")
+	cmd.Flags().IntVar(&config.CaptureConfig.MaxExchangesPerFile, "capture-max-exchanges-per-file", 10_000, "number of captured requests written to a file before another is started")
+	cmd.Flags().IntVar(&config.CaptureConfig.MaxFiles, "capture-max-files", 10, "number of the most recent capture files kept, beyond which the oldest are removed")
+	cmd.Flags().IntVar(&config.CaptureConfig.MaxResponses, "capture-max-responses", 1000, "number of responses captured of each streaming request")
+	cmd.Flags().IntVar(&config.CaptureConfig.MaxBufferedExchanges, "capture-max-buffered", 1000, "maximum number of captured requests awaiting writing, beyond which sampled requests are dropped")
+
+	// Flags for the validation webhook
+	cmd.Flags().StringVar(&config.ValidationWebhookConfig.URL, "validation-webhook-url", "", "url to which each WriteRelationships and WriteSchema request is POSTed as json for review before it is applied. the webhook responds with {\"allowed\": bool, \"reason\": string}. empty disables the webhook")
+	cmd.Flags().DurationVar(&config.ValidationWebhookConfig.Timeout, "validation-webhook-timeout", 5*time.Second, "time after which a review by the validation webhook fails")
+	cmd.Flags().BoolVar(&config.ValidationWebhookConfig.FailOpen, "validation-webhook-fail-open", false, "apply mutations whose review by the validation webhook fails, rather than rejecting them as unavailable")
+
+	// Flags for memory management
+	cmd.Flags().BoolVar(&config.MemoryManagerEnabled, "memory-manager-enabled", false, "tune the garbage collector to the memory limit and shed requests under memory pressure. has no effect without a configured or detected memory limit")
+	cmd.Flags().Uint64Var(&config.MemoryConfig.Limit, "memory-limit-bytes", 0, "memory limit in bytes to manage memory against. 0 uses the limit of the cgroup, if any")
+	cmd.Flags().Float64Var(&config.MemoryConfig.SoftLimitFraction, "memory-soft-limit-fraction", 0.9, "fraction of the memory limit at which the soft memory limit of the runtime is set, unless GOMEMLIMIT is set")
+	cmd.Flags().Float64Var(&config.MemoryConfig.BallastFraction, "memory-ballast-fraction", 0, "fraction of the memory limit to allocate as a ballast, reducing garbage collections while the heap is small. 0 disables the ballast")
+	cmd.Flags().Float64Var(&config.MemoryConfig.ShedThreshold, "memory-shed-threshold", 0.95, "fraction of the memory limit in use by the heap at which new requests are rejected. 0 disables load shedding")
+	cmd.Flags().IntVar(&config.MemoryConfig.MinimumGCPercent, "memory-minimum-gc-percent", 25, "GC percent to which the garbage collector is lowered as the heap approaches the shed threshold, unless GOGC is set")
+	cmd.Flags().DurationVar(&config.MemoryConfig.CheckInterval, "memory-check-interval", 1*time.Second, "interval at which heap usage is checked by the memory manager")
 
 	// Flags for telemetry
 	cmd.Flags().StringVar(&config.TelemetryEndpoint, "telemetry-endpoint", telemetry.DefaultEndpoint, "endpoint to which telemetry is reported, empty string to disable")
 	cmd.Flags().StringVar(&config.TelemetryCAOverridePath, "telemetry-ca-override-path", "", "TODO")
 	cmd.Flags().DurationVar(&config.TelemetryInterval, "telemetry-interval", telemetry.DefaultInterval, "approximate period between telemetry reports, minimum 1 minute")
+
+	// Flags for redaction
+	cmd.Flags().StringVar((*string)(&config.RedactionPolicy.ObjectIDs), "redact-object-ids", string(redaction.StrategyNone), fmt.Sprintf(`redaction applied to object IDs in logs and debug traces ("%s")`, strings.Join(redaction.Strategies, `", "`)))
+	cmd.Flags().StringVar((*string)(&config.RedactionPolicy.CaveatContext), "redact-caveat-context", string(redaction.StrategyNone), fmt.Sprintf(`redaction applied to caveat context values in logs and debug traces ("%s")`, strings.Join(redaction.Strategies, `", "`)))
+	cmd.Flags().StringVar(&config.RedactionPolicy.HashKey, "redact-hash-key", "", "secret used to key hashes when the hash redaction strategy is in use")
+
+	// Flags for experiments
+	cmd.Flags().StringSliceVar(&config.EnabledExperiments, "experiments-enabled", []string{}, fmt.Sprintf(`experimental behaviors to enable ("%s"). the state of every experiment is reported at /experiments on the metrics server`, strings.Join(experiments.Names(), `", "`)))
+	cmd.Flags().StringSliceVar(&config.DisabledExperiments, "experiments-disabled", []string{}, "experimental behaviors to disable, including those enabled by default")
+
+	// Flags for templates
+	cmd.Flags().StringVar(&config.TemplatesFile, "templates-file", "", "path to a YAML file of named permission check templates, invoked via CheckTemplate, and of the tokens restricted to them; reloaded when changed")
 	return nil
 }
 
@@ -135,6 +301,7 @@ func NewServeCommand(programName string, config *server.Config) *cobra.Command {
 		Long:    "A database that stores, computes, and validates application permissions",
 		PreRunE: server.DefaultPreRunE(programName),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			config.ConfigFileOverrides = configfile.Overrides(cmd.Flags())
 			server, err := config.Complete(cmd.Context())
 			if err != nil {
 				return err
@@ -49,18 +49,9 @@ func (cc *CacheConfig) Complete() (cache.Cache, error) {
 		return cache.NoopCache(), nil
 	}
 
-	var (
-		maxCost uint64
-		err     error
-	)
-
-	if strings.HasSuffix(cc.MaxCost, "%") {
-		maxCost, err = parsePercent(cc.MaxCost, freeMemory)
-	} else {
-		maxCost, err = humanize.ParseBytes(cc.MaxCost)
-	}
+	maxCost, err := parseMaxCost(cc.MaxCost)
 	if err != nil {
-		return nil, fmt.Errorf("error parsing cache max memory: `%s`: %w", cc.MaxCost, err)
+		return nil, err
 	}
 
 	if cc.Metrics {
@@ -78,6 +69,24 @@ func (cc *CacheConfig) Complete() (cache.Cache, error) {
 	})
 }
 
+// parseMaxCost parses a max cost in bytes or percent of available memory.
+func parseMaxCost(str string) (uint64, error) {
+	var (
+		maxCost uint64
+		err     error
+	)
+
+	if strings.HasSuffix(str, "%") {
+		maxCost, err = parsePercent(str, freeMemory)
+	} else {
+		maxCost, err = humanize.ParseBytes(str)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error parsing cache max memory: `%s`: %w", str, err)
+	}
+	return maxCost, nil
+}
+
 func parsePercent(str string, freeMem uint64) (uint64, error) {
 	percent := strings.TrimSuffix(str, "%")
 	parsedPercent, err := strconv.ParseUint(percent, 10, 64)
@@ -22,12 +22,28 @@ import (
 	"google.golang.org/grpc/codes"
 
 	"github.com/authzed/spicedb/internal/dispatch"
+	"github.com/authzed/spicedb/internal/experiments"
 	"github.com/authzed/spicedb/internal/logging"
+	"github.com/authzed/spicedb/internal/middleware/capture"
+	"github.com/authzed/spicedb/internal/middleware/concurrencylimit"
+	"github.com/authzed/spicedb/internal/middleware/decisionlog"
+	"github.com/authzed/spicedb/internal/middleware/loadshed"
+	"github.com/authzed/spicedb/internal/middleware/priority"
+	"github.com/authzed/spicedb/internal/middleware/quota"
+	"github.com/authzed/spicedb/internal/middleware/relationusage"
+	"github.com/authzed/spicedb/internal/middleware/requesttimeout"
+	"github.com/authzed/spicedb/internal/middleware/restrictedtokens"
+	"github.com/authzed/spicedb/internal/middleware/retryinfo"
 	consistencymw "github.com/authzed/spicedb/internal/middleware/consistency"
 	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
 	dispatchmw "github.com/authzed/spicedb/internal/middleware/dispatcher"
 	"github.com/authzed/spicedb/internal/middleware/serverversion"
 	"github.com/authzed/spicedb/internal/middleware/servicespecific"
+	"github.com/authzed/spicedb/internal/middleware/validationwebhook"
+	"github.com/authzed/spicedb/internal/middleware/visibility"
+	"github.com/authzed/spicedb/internal/warmup"
+	"github.com/authzed/spicedb/pkg/balancer"
+	"github.com/authzed/spicedb/pkg/cmd/configfile"
 	"github.com/authzed/spicedb/pkg/datastore"
 	logmw "github.com/authzed/spicedb/pkg/middleware/logging"
 	"github.com/authzed/spicedb/pkg/middleware/requestid"
@@ -52,11 +68,12 @@ func ServeExample(programName string) string {
 	)
 }
 
-// DefaultPreRunE sets up viper, zerolog, and OpenTelemetry flag handling for a
-// command.
+// DefaultPreRunE sets up viper, config file, zerolog, and OpenTelemetry flag
+// handling for a command.
 func DefaultPreRunE(programName string) cobrautil.CobraRunFunc {
 	return cobrautil.CommandStack(
 		cobrautil.SyncViperPreRunE(programName),
+		configfile.PreRunE(),
 		cobrazerolog.New(
 			cobrazerolog.WithTarget(func(logger zerolog.Logger) {
 				logging.SetGlobalLogger(logger)
@@ -70,7 +87,7 @@ func DefaultPreRunE(programName string) cobrautil.CobraRunFunc {
 }
 
 // MetricsHandler sets up an HTTP server that handles serving Prometheus
-// metrics and pprof endpoints.
+// metrics, pprof and experiment status endpoints.
 func MetricsHandler(telemetryRegistry *prometheus.Registry) http.Handler {
 	mux := http.NewServeMux()
 	mux.Handle("/metrics", promhttp.Handler())
@@ -82,6 +99,7 @@ func MetricsHandler(telemetryRegistry *prometheus.Registry) http.Handler {
 	if telemetryRegistry != nil {
 		mux.Handle("/telemetry", promhttp.HandlerFor(telemetryRegistry, promhttp.HandlerOpts{}))
 	}
+	mux.Handle("/experiments", experiments.Handler())
 	return mux
 }
 
@@ -101,22 +119,57 @@ var defaultGRPCLogOptions = []grpclog.Option{
 }
 
 const (
-	DefaultMiddlewareRequestID = "requestid"
-	DefaultMiddlewareLog       = "log"
-	DefaultMiddlewareGRPCLog   = "grpclog"
-	DefaultMiddlewareOTelGRPC  = "otelgrpc"
-	DefaultMiddlewareGRPCAuth  = "grpcauth"
-	DefaultMiddlewareGRPCProm  = "grpcprom"
+	DefaultMiddlewareRequestID         = "requestid"
+	DefaultMiddlewareLog               = "log"
+	DefaultMiddlewareGRPCLog           = "grpclog"
+	DefaultMiddlewareRequestTimeout    = "requesttimeout"
+	DefaultMiddlewareOTelGRPC          = "otelgrpc"
+	DefaultMiddlewareGRPCAuth          = "grpcauth"
+	DefaultMiddlewareRestrictedTokens  = "restrictedtokens"
+	DefaultMiddlewareVisibility        = "visibility"
+	DefaultMiddlewareGRPCProm          = "grpcprom"
+	DefaultMiddlewareRetryInfo         = "retryinfo"
+	DefaultMiddlewareLoadShed          = "loadshed"
+	DefaultMiddlewareValidationWebhook = "validationwebhook"
+	DefaultMiddlewareDecisionLog       = "decisionlog"
+	DefaultMiddlewareRelationUsage     = "relationusage"
+	DefaultMiddlewareQuota             = "quota"
+	DefaultMiddlewareWarmup            = "warmup"
+	DefaultMiddlewareCapture           = "capture"
 
 	DefaultInternalMiddlewareDispatch       = "dispatch"
 	DefaultInternalMiddlewareDatastore      = "datastore"
 	DefaultInternalMiddlewareConsistency    = "consistency"
 	DefaultInternalMiddlewareServerSpecific = "servicespecific"
 	DefaultInternalMiddlewareServerVersion  = "serverversion"
+	DefaultInternalMiddlewareConcurrency    = "concurrencylimit"
+	DefaultInternalMiddlewarePriority       = "priority"
 )
 
+// MiddlewareOption holds the dependencies of the default middleware chain used for the public
+// SpiceDB gRPC API.
+type MiddlewareOption struct {
+	Logger                         zerolog.Logger
+	AuthFunc                       grpcauth.AuthFunc
+	EnableVersionResponse          bool
+	Dispatcher                     dispatch.Dispatcher
+	Datastore                      datastore.Datastore
+	DefaultRequestConcurrencyLimit *concurrencylimit.DefaultLimit
+	TokenPriorities                map[string]priority.Priority
+	TokenAllowlists                visibility.TokenAllowlists
+	Shedder                        loadshed.Shedder
+	DecisionLogger                 *decisionlog.Logger
+	UsageTracker                   *relationusage.Tracker
+	QuotaTracker                   *quota.Tracker
+	WarmupRecorder                 *warmup.Recorder
+	CaptureRecorder                *capture.Recorder
+	ValidationWebhook              *validationwebhook.Webhook
+	RequestTimeouts                requesttimeout.Timeouts
+	StaleFallback                  *consistencymw.StaleFallback
+}
+
 // DefaultMiddleware generates the default middleware chain used for the public SpiceDB gRPC API
-func DefaultMiddleware(logger zerolog.Logger, authFunc grpcauth.AuthFunc, enableVersionResponse bool, dispatcher dispatch.Dispatcher, ds datastore.Datastore) (*MiddlewareChain, error) {
+func DefaultMiddleware(opts MiddlewareOption) (*MiddlewareChain, error) {
 	chain, err := NewMiddlewareChain([]ReferenceableMiddleware{
 		{
 			Name:                DefaultMiddlewareRequestID,
@@ -130,8 +183,13 @@ func DefaultMiddleware(logger zerolog.Logger, authFunc grpcauth.AuthFunc, enable
 		},
 		{
 			Name:                DefaultMiddlewareGRPCLog,
-			UnaryMiddleware:     grpclog.UnaryServerInterceptor(grpczerolog.InterceptorLogger(logger), defaultGRPCLogOptions...),
-			StreamingMiddleware: grpclog.StreamServerInterceptor(grpczerolog.InterceptorLogger(logger), defaultGRPCLogOptions...),
+			UnaryMiddleware:     grpclog.UnaryServerInterceptor(grpczerolog.InterceptorLogger(opts.Logger), defaultGRPCLogOptions...),
+			StreamingMiddleware: grpclog.StreamServerInterceptor(grpczerolog.InterceptorLogger(opts.Logger), defaultGRPCLogOptions...),
+		},
+		{
+			Name:                DefaultMiddlewareRequestTimeout,
+			UnaryMiddleware:     requesttimeout.UnaryServerInterceptor(opts.RequestTimeouts),
+			StreamingMiddleware: requesttimeout.StreamServerInterceptor(opts.RequestTimeouts),
 		},
 		{
 			Name:                DefaultMiddlewareOTelGRPC,
@@ -140,30 +198,80 @@ func DefaultMiddleware(logger zerolog.Logger, authFunc grpcauth.AuthFunc, enable
 		},
 		{
 			Name:                DefaultMiddlewareGRPCAuth,
-			UnaryMiddleware:     grpcauth.UnaryServerInterceptor(authFunc),
-			StreamingMiddleware: grpcauth.StreamServerInterceptor(authFunc),
+			UnaryMiddleware:     grpcauth.UnaryServerInterceptor(opts.AuthFunc),
+			StreamingMiddleware: grpcauth.StreamServerInterceptor(opts.AuthFunc),
+		},
+		{
+			Name:                DefaultMiddlewareRestrictedTokens,
+			UnaryMiddleware:     restrictedtokens.UnaryServerInterceptor(),
+			StreamingMiddleware: restrictedtokens.StreamServerInterceptor(),
+		},
+		{
+			Name:                DefaultMiddlewareVisibility,
+			UnaryMiddleware:     visibility.UnaryServerInterceptor(opts.TokenAllowlists),
+			StreamingMiddleware: visibility.StreamServerInterceptor(opts.TokenAllowlists),
 		},
 		{
 			Name:                DefaultMiddlewareGRPCProm,
 			UnaryMiddleware:     grpcprom.UnaryServerInterceptor,
 			StreamingMiddleware: grpcprom.StreamServerInterceptor,
 		},
+		{
+			Name:                DefaultMiddlewareRetryInfo,
+			UnaryMiddleware:     retryinfo.UnaryServerInterceptor(),
+			StreamingMiddleware: retryinfo.StreamServerInterceptor(),
+		},
+		{
+			Name:                DefaultMiddlewareLoadShed,
+			UnaryMiddleware:     loadshed.UnaryServerInterceptor(opts.Shedder),
+			StreamingMiddleware: loadshed.StreamServerInterceptor(opts.Shedder),
+		},
+		{
+			Name:                DefaultMiddlewareValidationWebhook,
+			UnaryMiddleware:     validationwebhook.UnaryServerInterceptor(opts.ValidationWebhook),
+			StreamingMiddleware: validationwebhook.StreamServerInterceptor(opts.ValidationWebhook),
+		},
+		{
+			Name:                DefaultMiddlewareDecisionLog,
+			UnaryMiddleware:     decisionlog.UnaryServerInterceptor(opts.DecisionLogger),
+			StreamingMiddleware: decisionlog.StreamServerInterceptor(opts.DecisionLogger),
+		},
+		{
+			Name:                DefaultMiddlewareRelationUsage,
+			UnaryMiddleware:     relationusage.UnaryServerInterceptor(opts.UsageTracker),
+			StreamingMiddleware: relationusage.StreamServerInterceptor(opts.UsageTracker),
+		},
+		{
+			Name:                DefaultMiddlewareQuota,
+			UnaryMiddleware:     quota.UnaryServerInterceptor(opts.QuotaTracker),
+			StreamingMiddleware: quota.StreamServerInterceptor(opts.QuotaTracker),
+		},
+		{
+			Name:                DefaultMiddlewareWarmup,
+			UnaryMiddleware:     warmup.UnaryServerInterceptor(opts.WarmupRecorder),
+			StreamingMiddleware: warmup.StreamServerInterceptor(opts.WarmupRecorder),
+		},
+		{
+			Name:                DefaultMiddlewareCapture,
+			UnaryMiddleware:     capture.UnaryServerInterceptor(opts.CaptureRecorder),
+			StreamingMiddleware: capture.StreamServerInterceptor(opts.CaptureRecorder),
+		},
 		{
 			Name:                DefaultInternalMiddlewareDispatch,
 			Internal:            true,
-			UnaryMiddleware:     dispatchmw.UnaryServerInterceptor(dispatcher),
-			StreamingMiddleware: dispatchmw.StreamServerInterceptor(dispatcher),
+			UnaryMiddleware:     dispatchmw.UnaryServerInterceptor(opts.Dispatcher),
+			StreamingMiddleware: dispatchmw.StreamServerInterceptor(opts.Dispatcher),
 		},
 		{
 			Name:                DefaultInternalMiddlewareDatastore,
 			Internal:            true,
-			UnaryMiddleware:     datastoremw.UnaryServerInterceptor(ds),
-			StreamingMiddleware: datastoremw.StreamServerInterceptor(ds),
+			UnaryMiddleware:     datastoremw.UnaryServerInterceptor(opts.Datastore),
+			StreamingMiddleware: datastoremw.StreamServerInterceptor(opts.Datastore),
 		},
 		{
 			Name:                DefaultInternalMiddlewareConsistency,
 			Internal:            true,
-			UnaryMiddleware:     consistencymw.UnaryServerInterceptor(),
+			UnaryMiddleware:     consistencymw.UnaryServerInterceptorWithStaleFallback(opts.StaleFallback),
 			StreamingMiddleware: consistencymw.StreamServerInterceptor(),
 		},
 		{
@@ -175,8 +283,20 @@ func DefaultMiddleware(logger zerolog.Logger, authFunc grpcauth.AuthFunc, enable
 		{
 			Name:                DefaultInternalMiddlewareServerVersion,
 			Internal:            true,
-			UnaryMiddleware:     serverversion.UnaryServerInterceptor(enableVersionResponse),
-			StreamingMiddleware: serverversion.StreamServerInterceptor(enableVersionResponse),
+			UnaryMiddleware:     serverversion.UnaryServerInterceptor(opts.EnableVersionResponse),
+			StreamingMiddleware: serverversion.StreamServerInterceptor(opts.EnableVersionResponse),
+		},
+		{
+			Name:                DefaultInternalMiddlewareConcurrency,
+			Internal:            true,
+			UnaryMiddleware:     concurrencylimit.UnaryServerInterceptor(opts.DefaultRequestConcurrencyLimit),
+			StreamingMiddleware: concurrencylimit.StreamServerInterceptor(opts.DefaultRequestConcurrencyLimit),
+		},
+		{
+			Name:                DefaultInternalMiddlewarePriority,
+			Internal:            true,
+			UnaryMiddleware:     priority.UnaryServerInterceptor(opts.TokenPriorities),
+			StreamingMiddleware: priority.StreamServerInterceptor(opts.TokenPriorities),
 		},
 	}...)
 	return &chain, err
@@ -185,22 +305,24 @@ func DefaultMiddleware(logger zerolog.Logger, authFunc grpcauth.AuthFunc, enable
 // DefaultDispatchMiddleware generates the default middleware chain used for the internal dispatch SpiceDB gRPC API
 func DefaultDispatchMiddleware(logger zerolog.Logger, authFunc grpcauth.AuthFunc, ds datastore.Datastore) ([]grpc.UnaryServerInterceptor, []grpc.StreamServerInterceptor) {
 	return []grpc.UnaryServerInterceptor{
-			requestid.UnaryServerInterceptor(requestid.GenerateIfMissing(true)),
-			logmw.UnaryServerInterceptor(logmw.ExtractMetadataField("x-request-id", "requestID")),
-			grpclog.UnaryServerInterceptor(grpczerolog.InterceptorLogger(logger), defaultGRPCLogOptions...),
-			otelgrpc.UnaryServerInterceptor(),
-			grpcauth.UnaryServerInterceptor(authFunc),
-			grpcprom.UnaryServerInterceptor,
-			datastoremw.UnaryServerInterceptor(ds),
-			servicespecific.UnaryServerInterceptor,
-		}, []grpc.StreamServerInterceptor{
-			requestid.StreamServerInterceptor(requestid.GenerateIfMissing(true)),
-			logmw.StreamServerInterceptor(logmw.ExtractMetadataField("x-request-id", "requestID")),
-			grpclog.StreamServerInterceptor(grpczerolog.InterceptorLogger(logger), defaultGRPCLogOptions...),
-			otelgrpc.StreamServerInterceptor(),
-			grpcauth.StreamServerInterceptor(authFunc),
-			grpcprom.StreamServerInterceptor,
-			datastoremw.StreamServerInterceptor(ds),
-			servicespecific.StreamServerInterceptor,
-		}
+		requestid.UnaryServerInterceptor(requestid.GenerateIfMissing(true)),
+		logmw.UnaryServerInterceptor(logmw.ExtractMetadataField("x-request-id", "requestID")),
+		grpclog.UnaryServerInterceptor(grpczerolog.InterceptorLogger(logger), defaultGRPCLogOptions...),
+		otelgrpc.UnaryServerInterceptor(),
+		grpcauth.UnaryServerInterceptor(authFunc),
+		grpcprom.UnaryServerInterceptor,
+		balancer.LoadReportingUnaryServerInterceptor(),
+		datastoremw.UnaryServerInterceptor(ds),
+		servicespecific.UnaryServerInterceptor,
+	}, []grpc.StreamServerInterceptor{
+		requestid.StreamServerInterceptor(requestid.GenerateIfMissing(true)),
+		logmw.StreamServerInterceptor(logmw.ExtractMetadataField("x-request-id", "requestID")),
+		grpclog.StreamServerInterceptor(grpczerolog.InterceptorLogger(logger), defaultGRPCLogOptions...),
+		otelgrpc.StreamServerInterceptor(),
+		grpcauth.StreamServerInterceptor(authFunc),
+		grpcprom.StreamServerInterceptor,
+		balancer.LoadReportingStreamServerInterceptor(),
+		datastoremw.StreamServerInterceptor(ds),
+		servicespecific.StreamServerInterceptor,
+	}
 }
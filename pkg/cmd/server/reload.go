@@ -0,0 +1,133 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog"
+
+	log "github.com/authzed/spicedb/internal/logging"
+	"github.com/authzed/spicedb/internal/middleware/concurrencylimit"
+	"github.com/authzed/spicedb/pkg/cache"
+	"github.com/authzed/spicedb/pkg/cmd/configfile"
+)
+
+// configReloader applies changes to the config file to the settings which can be changed while
+// serving. Changes to other settings are logged, and take effect on restart.
+type configReloader struct {
+	overrides map[string]struct{}
+	current   configfile.Values
+	setters   map[string]func(value string) error
+}
+
+func newConfigReloader(initial configfile.Values, overrides []string) *configReloader {
+	r := &configReloader{
+		overrides: make(map[string]struct{}, len(overrides)),
+		current:   initial,
+		setters:   make(map[string]func(string) error),
+	}
+	for _, name := range overrides {
+		r.overrides[name] = struct{}{}
+	}
+
+	// Log levels are changed through the global level, which requires that the logger itself
+	// logs at every level.
+	zerolog.SetGlobalLevel(log.Logger.GetLevel())
+	log.SetGlobalLogger(log.Logger.Level(zerolog.TraceLevel))
+	r.reloadable("log-level", func(value string) error {
+		level, err := zerolog.ParseLevel(strings.ToLower(value))
+		if err != nil {
+			return err
+		}
+		zerolog.SetGlobalLevel(level)
+		return nil
+	})
+	return r
+}
+
+// reloadable registers the function applying changes to the value of the flag with the given
+// name. It is a no-op on a nil reloader, used when there is no config file.
+func (r *configReloader) reloadable(flag string, set func(value string) error) {
+	if r == nil {
+		return
+	}
+	r.setters[flag] = set
+}
+
+// reloadableCache registers the max cost flag of the cache with the given flag prefix.
+func (r *configReloader) reloadableCache(flagPrefix string, c cache.Cache) {
+	r.reloadable(flagPrefix+"-max-cost", func(value string) error {
+		maxCost, err := parseMaxCost(value)
+		if err != nil {
+			return err
+		}
+		c.SetMaxCost(int64(maxCost))
+		return nil
+	})
+}
+
+// reloadableConcurrencyLimit registers the flag of the default request concurrency limit.
+func (r *configReloader) reloadableConcurrencyLimit(flag string, limit *concurrencylimit.DefaultLimit) {
+	r.reloadable(flag, func(value string) error {
+		parsed, err := strconv.ParseUint(value, 10, 16)
+		if err != nil {
+			return fmt.Errorf("invalid concurrency limit: %w", err)
+		}
+		limit.Set(uint16(parsed))
+		return nil
+	})
+}
+
+// apply applies the changes from the current values to the given values of the config file.
+func (r *configReloader) apply(values configfile.Values) {
+	names := make(map[string]struct{}, len(values))
+	for name := range values {
+		names[name] = struct{}{}
+	}
+	for name := range r.current {
+		names[name] = struct{}{}
+	}
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	next := make(configfile.Values, len(values))
+	for _, name := range sorted {
+		previous, hadPrevious := r.current[name]
+		value, hasValue := values[name]
+		if hasValue {
+			next[name] = value
+		}
+		if hasValue == hadPrevious && strings.Join(value, ",") == strings.Join(previous, ",") {
+			continue
+		}
+
+		if _, ok := r.overrides[name]; ok {
+			log.Info().Str("setting", name).Msg("ignoring change to config file setting overridden by a flag or the environment")
+			continue
+		}
+
+		set, ok := r.setters[name]
+		if !ok || !hasValue {
+			log.Warn().Str("setting", name).Msg("changed config file setting cannot be reloaded and takes effect on restart")
+			continue
+		}
+
+		if err := set(strings.Join(value, ",")); err != nil {
+			log.Warn().Err(err).Str("setting", name).Msg("failed to apply changed config file setting; continuing to use the previous value")
+			if hadPrevious {
+				next[name] = previous
+			} else {
+				delete(next, name)
+			}
+			continue
+		}
+		log.Info().Str("setting", name).Strs("value", value).Msg("applied changed config file setting")
+	}
+	r.current = next
+}
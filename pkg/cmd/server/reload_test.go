@@ -0,0 +1,49 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/middleware/concurrencylimit"
+	"github.com/authzed/spicedb/pkg/cmd/configfile"
+)
+
+func TestConfigReloader(t *testing.T) {
+	previousLevel := zerolog.GlobalLevel()
+	t.Cleanup(func() { zerolog.SetGlobalLevel(previousLevel) })
+
+	limit := concurrencylimit.NewDefaultLimit(10)
+	reloader := newConfigReloader(configfile.Values{
+		"dispatch-default-request-concurrency-limit": {"10"},
+		"ns-cache-max-cost":                          {"16MiB"},
+	}, []string{"ns-cache-max-cost"})
+	reloader.reloadableConcurrencyLimit("dispatch-default-request-concurrency-limit", limit)
+
+	reloader.apply(configfile.Values{
+		"dispatch-default-request-concurrency-limit": {"4"},
+		"ns-cache-max-cost":                          {"32MiB"},
+		"log-level":                                  {"debug"},
+	})
+	require.Equal(t, uint16(4), limit.Get())
+	require.Equal(t, zerolog.DebugLevel, zerolog.GlobalLevel())
+
+	// Invalid values leave the previous value in use, and are applied once corrected.
+	reloader.apply(configfile.Values{
+		"dispatch-default-request-concurrency-limit": {"many"},
+		"log-level": {"debug"},
+	})
+	require.Equal(t, uint16(4), limit.Get())
+	require.Equal(t, configfile.Values{
+		"dispatch-default-request-concurrency-limit": {"4"},
+		"log-level": {"debug"},
+	}, reloader.current)
+
+	reloader.apply(configfile.Values{
+		"dispatch-default-request-concurrency-limit": {"2"},
+		"log-level": {"warn"},
+	})
+	require.Equal(t, uint16(2), limit.Get())
+	require.Equal(t, zerolog.WarnLevel, zerolog.GlobalLevel())
+}
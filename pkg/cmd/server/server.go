@@ -2,15 +2,22 @@ package server
 
 import (
 	"context"
+	"crypto/x509"
 	"fmt"
 	"io"
+	"math"
 	"net"
 	"net/http"
+	"os"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	"github.com/authzed/grpcutil"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
 	grpc_auth "github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/auth"
 	grpcprom "github.com/grpc-ecosystem/go-grpc-prometheus"
 	"github.com/hashicorp/go-multierror"
@@ -19,35 +26,90 @@ import (
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
 
+	"github.com/authzed/spicedb/internal/archive"
 	"github.com/authzed/spicedb/internal/auth"
 	"github.com/authzed/spicedb/internal/dashboard"
+	dscommon "github.com/authzed/spicedb/internal/datastore/common"
 	"github.com/authzed/spicedb/internal/datastore/proxy"
 	"github.com/authzed/spicedb/internal/dispatch"
+	"github.com/authzed/spicedb/internal/dispatch/caching"
 	clusterdispatch "github.com/authzed/spicedb/internal/dispatch/cluster"
 	combineddispatch "github.com/authzed/spicedb/internal/dispatch/combined"
 	"github.com/authzed/spicedb/internal/dispatch/graph"
+	"github.com/authzed/spicedb/internal/dispatch/keys"
+	"github.com/authzed/spicedb/internal/dispatch/remote"
+	"github.com/authzed/spicedb/internal/dispatch/scheduler"
+	"github.com/authzed/spicedb/internal/dispatch/shadow"
+	"github.com/authzed/spicedb/internal/experiments"
+	"github.com/authzed/spicedb/internal/extauthz"
 	"github.com/authzed/spicedb/internal/gateway"
+	"github.com/authzed/spicedb/internal/hotreload"
+	"github.com/authzed/spicedb/internal/jobs"
+	"github.com/authzed/spicedb/internal/kubeauthz"
+	"github.com/authzed/spicedb/internal/ldapsync"
 	log "github.com/authzed/spicedb/internal/logging"
+	"github.com/authzed/spicedb/internal/materialize"
+	"github.com/authzed/spicedb/internal/memory"
+	"github.com/authzed/spicedb/internal/middleware/capture"
+	"github.com/authzed/spicedb/internal/middleware/concurrencylimit"
+	consistencymw "github.com/authzed/spicedb/internal/middleware/consistency"
+	"github.com/authzed/spicedb/internal/middleware/decisionlog"
+	"github.com/authzed/spicedb/internal/middleware/loadshed"
+	"github.com/authzed/spicedb/internal/middleware/priority"
+	"github.com/authzed/spicedb/internal/middleware/quota"
+	"github.com/authzed/spicedb/internal/middleware/relationusage"
+	"github.com/authzed/spicedb/internal/middleware/requesttimeout"
+	"github.com/authzed/spicedb/internal/middleware/validationwebhook"
+	"github.com/authzed/spicedb/internal/middleware/visibility"
+	"github.com/authzed/spicedb/internal/relationships"
+	"github.com/authzed/spicedb/internal/scim"
 	"github.com/authzed/spicedb/internal/services"
 	dispatchSvc "github.com/authzed/spicedb/internal/services/dispatch"
 	"github.com/authzed/spicedb/internal/services/health"
+	v0svc "github.com/authzed/spicedb/internal/services/v0"
 	v1svc "github.com/authzed/spicedb/internal/services/v1"
 	"github.com/authzed/spicedb/internal/telemetry"
+	"github.com/authzed/spicedb/internal/templates"
+	"github.com/authzed/spicedb/internal/topology"
+	"github.com/authzed/spicedb/internal/tracestore"
+	"github.com/authzed/spicedb/internal/warmup"
 	"github.com/authzed/spicedb/pkg/balancer"
+	"github.com/authzed/spicedb/pkg/cmd/configfile"
 	datastorecfg "github.com/authzed/spicedb/pkg/cmd/datastore"
 	"github.com/authzed/spicedb/pkg/cmd/util"
 	"github.com/authzed/spicedb/pkg/datastore"
+	dispatchv1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+	"github.com/authzed/spicedb/pkg/redaction"
+	"github.com/authzed/spicedb/pkg/releases"
+	"github.com/authzed/spicedb/pkg/secrets"
+	"github.com/authzed/spicedb/pkg/x509util"
 )
 
 //go:generate go run github.com/ecordell/optgen -output zz_generated.options.go . Config
 type Config struct {
+	// Config file
+	ConfigFile          string
+	ConfigFileOverrides []string
+
+	// TLS policy of every listener
+	TLSPolicy util.TLSPolicy
+
 	// API config
-	GRPCServer             util.GRPCServerConfig
-	GRPCAuthFunc           grpc_auth.AuthFunc
-	PresharedKey           []string
-	ShutdownGracePeriod    time.Duration
-	DisableVersionResponse bool
+	GRPCServer                util.GRPCServerConfig
+	GRPCAuthFunc              grpc_auth.AuthFunc
+	PresharedKey              []string
+	PresharedKeyFile          string
+	PresharedKeyPriorities    []string
+	PresharedKeyNamespaces    []string
+	PresharedKeyDispatchPools []string
+	ShutdownGracePeriod       time.Duration
+	DisableVersionResponse    bool
+	MethodTimeouts            map[string]string
+	MethodMaximumTimeouts     map[string]string
 
 	// GRPC Gateway config
 	HTTPGateway                    util.HTTPServerConfig
@@ -61,7 +123,8 @@ type Config struct {
 	Datastore       datastore.Datastore
 
 	// Namespace cache
-	NamespaceCacheConfig CacheConfig
+	NamespaceCacheConfig               CacheConfig
+	NamespaceCacheStaleWhileRevalidate time.Duration
 
 	// Schema options
 	SchemaPrefixesRequired bool
@@ -71,28 +134,170 @@ type Config struct {
 	DispatchMaxDepth               uint32
 	GlobalDispatchConcurrencyLimit uint16
 	DispatchConcurrencyLimits      graph.ConcurrencyLimits
+	DefaultRequestConcurrencyLimit uint16
+	DispatchPrioritySlots          uint16
+	DispatchPriorityWeights        map[string]int
+	DispatchPoolSlots              map[string]int
+	DispatchPoolNamespaces         map[string]string
 	DispatchUpstreamAddr           string
 	DispatchUpstreamCAPath         string
 	DispatchUpstreamTimeout        time.Duration
+	DispatchDeadlineMargin         time.Duration
+	DispatchHedgingDelay           time.Duration
+	DispatchCircuitBreakerFailures uint32
+	DispatchCircuitBreakerOpenTime time.Duration
+	DispatchUpstreamConnections    uint16
+	DispatchUpstreamKeepaliveTime  time.Duration
+	DispatchUpstreamKeepaliveTTL   time.Duration
+	DispatchUpstreamMaxInflight    uint32
+	DispatchLoadAwareConfig        balancer.LoadAwareConfig
+	DispatchShadowUpstreamAddr     string
+	DispatchShadowConfig           shadow.Config
 	DispatchClientMetricsEnabled   bool
 	DispatchClientMetricsPrefix    string
 	DispatchClusterMetricsEnabled  bool
 	DispatchClusterMetricsPrefix   string
 	Dispatcher                     dispatch.Dispatcher
 
-	DispatchCacheConfig        CacheConfig
-	ClusterDispatchCacheConfig CacheConfig
+	DispatchCacheConfig              CacheConfig
+	ClusterDispatchCacheConfig       CacheConfig
+	DispatchCachePersistencePath     string
+	DispatchCachePersistenceInterval time.Duration
 
 	// API Behavior
-	DisableV1SchemaAPI       bool
-	V1SchemaAdditiveOnly     bool
-	MaximumUpdatesPerWrite   uint16
-	MaximumPreconditionCount uint16
+	DisableV1SchemaAPI           bool
+	V1SchemaAdditiveOnly         bool
+	MaximumUpdatesPerWrite       uint16
+	MaximumPreconditionCount     uint16
+	StrictRelationshipValidation bool
+	AdminAPIEnabled              bool
+	RelationshipRestoreWindow    time.Duration
+	MaximumResultSize            uint64
+	MaximumNestingDepth          uint32
+	StaleCheckMaximumStaleness   time.Duration
+
+	// Keys with which the tokens restoring deleted relationships are signed
+	RelationshipRestoreTokenKeys       []string
+	RelationshipRestoreTokenKeyManager secrets.KeyManager
+
+	// Interval between purges of the deleted relationships retained past the restore window
+	RelationshipRestorePurgeInterval time.Duration
+
+	// Request tracing
+	TraceSampleRate float64
+	TraceStorePath  string
+
+	// Playground
+	PlaygroundAPIEnabled         bool
+	PlaygroundShareStoreSalt     string
+	PlaygroundShareStoreMaxBytes int
+
+	// Orphaned relationships
+	OrphanScanInterval time.Duration
+
+	// Expired relationships
+	RelationshipExpirationInterval time.Duration
+
+	// Relation usage analytics
+	RelationUsageAnalysisInterval time.Duration
+
+	// Per-token quotas
+	QuotaRelationshipsSoftLimit uint64
+	QuotaRelationshipsHardLimit uint64
+	QuotaNamespacesSoftLimit    uint64
+	QuotaNamespacesHardLimit    uint64
+
+	// Materialized permissions
+	MaterializedPermissions []string
+
+	// Point-in-time checks
+	PointInTimeCheckEnabled bool
+	ArchiveDirectory        string
+	ArchiveRecordingEnabled bool
+	ArchiveSnapshotInterval time.Duration
+
+	// Datastore schema drift detection
+	SchemaDriftCheckInterval time.Duration
+
+	// Datastore revision heartbeat
+	RevisionHeartbeatInterval time.Duration
+
+	// Datastore relation counts
+	RelationCountInterval       time.Duration
+	RelationshipGrowthAlertRate float64
+	RelationshipGrowthAlertFunc func(dscommon.RelationshipGrowth)
+
+	// Leader election
+	LeaderElectionEnabled bool
+	LeaderLeaseDuration   time.Duration
+
+	// Jobs
+	JobsEnabled      bool
+	JobLeaseDuration time.Duration
+	JobPollInterval  time.Duration
+
+	// LDAP group reconciliation
+	LDAPSyncInterval     time.Duration
+	LDAPSyncMappingFile  string
+	LDAPSyncURL          string
+	LDAPSyncBindDN       string
+	LDAPSyncBindPassword string
+	LDAPSyncDryRun       bool
+
+	// Envoy external authorization
+	ExtAuthzServer      util.GRPCServerConfig
+	ExtAuthzMappingFile string
+
+	// Kubernetes authorization webhook
+	KubeAuthzServer        util.HTTPServerConfig
+	KubeAuthzBearerToken   []string
+	KubeAuthzNamespaceType string
+	KubeAuthzClusterType   string
+	KubeAuthzClusterID     string
+	KubeAuthzUserType      string
+	KubeAuthzGroupType     string
+	KubeAuthzGroupRelation string
+	KubeAuthzDeny          bool
+
+	// SCIM group sync
+	SCIMServer         util.HTTPServerConfig
+	SCIMBearerToken    []string
+	SCIMGroupType      string
+	SCIMMemberRelation string
+	SCIMUserType       string
+	SCIMDryRun         bool
+
+	// Decision logs
+	DecisionLogEnabled     bool
+	DecisionLogConfig      decisionlog.Config
+	DecisionLogS3Bucket    string
+	DecisionLogS3Prefix    string
+	DecisionLogS3Endpoint  string
+	DecisionLogS3Region    string
+	DecisionLogS3AccessKey string
+	DecisionLogS3SecretKey string
+
+	// Warmup
+	WarmupConfig warmup.Config
+
+	// Request capture
+	CaptureConfig capture.Config
+
+	// Validation webhook
+	ValidationWebhookConfig validationwebhook.Config
+
+	// Memory management
+	MemoryManagerEnabled bool
+	MemoryConfig         memory.Config
 
 	// Additional Services
 	DashboardAPI util.HTTPServerConfig
 	MetricsAPI   util.HTTPServerConfig
 
+	// Topology reported to smart clients
+	TopologyEnabled              bool
+	TopologyAdvertisedAPIAddress string
+
 	// Middleware for grpc API
 	MiddlewareModification []MiddlewareModification
 
@@ -105,6 +310,16 @@ type Config struct {
 	TelemetryCAOverridePath  string
 	TelemetryEndpoint        string
 	TelemetryInterval        time.Duration
+
+	// Redaction
+	RedactionPolicy redaction.Policy
+
+	// Experiments
+	EnabledExperiments  []string
+	DisabledExperiments []string
+
+	// Templates
+	TemplatesFile string
 }
 
 type closeableStack struct {
@@ -159,6 +374,103 @@ func (c *Config) Complete(ctx context.Context) (RunnableServer, error) {
 		}
 	}()
 
+	if err := redaction.SetPolicy(c.RedactionPolicy); err != nil {
+		return nil, fmt.Errorf("invalid redaction policy: %w", err)
+	}
+
+	if err := experiments.Configure(c.EnabledExperiments, c.DisabledExperiments); err != nil {
+		return nil, fmt.Errorf("invalid experiments: %w", err)
+	}
+	log.Ctx(ctx).Info().Strs("enabled", experiments.Enabled()).Msg("configured experiments")
+
+	if !c.TLSPolicy.IsDefault() {
+		negotiated, err := c.TLSPolicy.SelfTest()
+		if err != nil {
+			return nil, fmt.Errorf("invalid TLS policy: %w", err)
+		}
+		log.Ctx(ctx).Info().
+			Str("min-version", c.TLSPolicy.MinVersion).
+			Strs("cipher-suites", c.TLSPolicy.CipherSuites).
+			Bool("fips-mode", c.TLSPolicy.FIPSMode).
+			Bool("fips-build", util.FIPSBuild).
+			Str("self-test", negotiated).
+			Msg("configured TLS policy")
+	}
+	for _, tlsPolicy := range []*util.TLSPolicy{
+		&c.GRPCServer.TLSPolicy,
+		&c.DispatchServer.TLSPolicy,
+		&c.HTTPGateway.TLSPolicy,
+		&c.DashboardAPI.TLSPolicy,
+		&c.MetricsAPI.TLSPolicy,
+		&c.ExtAuthzServer.TLSPolicy,
+		&c.KubeAuthzServer.TLSPolicy,
+		&c.SCIMServer.TLSPolicy,
+	} {
+		*tlsPolicy = c.TLSPolicy
+	}
+
+	var (
+		configFile *hotreload.File[configfile.Values]
+		reloader   *configReloader
+	)
+	if c.ConfigFile != "" {
+		configFile, err = hotreload.NewFile("config-file", func() (configfile.Values, error) {
+			return configfile.Load(c.ConfigFile)
+		}, c.ConfigFile)
+		if err != nil {
+			return nil, err
+		}
+		closeables.AddWithError(configFile.Close)
+
+		reloader = newConfigReloader(configFile.Load(), c.ConfigFileOverrides)
+	}
+
+	// Templates are reloaded whenever the file changes, so that the checks which services are
+	// allowed to perform can be governed without restart.
+	templates.Set(nil)
+	if c.TemplatesFile != "" {
+		templatesFile, err := hotreload.NewFile("templates", func() (*templates.Registry, error) {
+			return templates.Load(c.TemplatesFile)
+		}, c.TemplatesFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load templates file: %w", err)
+		}
+		closeables.AddWithError(templatesFile.Close)
+
+		templates.Set(templatesFile.Load())
+		templatesFile.Subscribe(templates.Set)
+	}
+
+	if c.RelationshipRestoreWindow > 0 {
+		// Restore tokens name the relationships to restore, and so must be signed to prevent
+		// clients from restoring relationships other than those they deleted.
+		if c.RelationshipRestoreTokenKeyManager == nil {
+			if len(c.RelationshipRestoreTokenKeys) == 0 {
+				return nil, fmt.Errorf("restoring deleted relationships requires keys with which to sign restore tokens")
+			}
+
+			keys, err := secrets.ParseKeys(c.RelationshipRestoreTokenKeys)
+			if err != nil {
+				return nil, fmt.Errorf("invalid relationship restore token keys: %w", err)
+			}
+			c.RelationshipRestoreTokenKeyManager, err = secrets.NewStaticKeyManager(keys...)
+			if err != nil {
+				return nil, fmt.Errorf("invalid relationship restore token keys: %w", err)
+			}
+		}
+	}
+
+	// Shared schemas are kept in memory, which must be bounded.
+	if c.PlaygroundAPIEnabled && c.PlaygroundShareStoreMaxBytes <= 0 {
+		return nil, fmt.Errorf("serving the playground API requires a maximum size for the schemas shared via it")
+	}
+
+	// The address on which the API listens is typically unspecified or behind a proxy, and so
+	// cannot be reported to clients in place of the address at which they reach it.
+	if c.TopologyEnabled && c.TopologyAdvertisedAPIAddress == "" {
+		return nil, fmt.Errorf("serving the topology endpoint requires the address at which clients reach the API to be advertised")
+	}
+
 	if len(c.PresharedKey) < 1 && c.GRPCAuthFunc == nil {
 		return nil, fmt.Errorf("a preshared key must be provided to authenticate API requests")
 	}
@@ -173,7 +485,19 @@ func (c *Config) Complete(ctx context.Context) (RunnableServer, error) {
 			log.Ctx(ctx).Trace().Int("preshared-key-"+strconv.Itoa(index+1)+"-length", len(presharedKey)).Msg("preshared key configured")
 		}
 
-		c.GRPCAuthFunc = auth.MustRequirePresharedKey(c.PresharedKey)
+		if c.PresharedKeyFile != "" {
+			keysFile, err := hotreload.NewFile("preshared-keys", func() ([]string, error) {
+				return readPresharedKeys(c.PresharedKeyFile)
+			}, c.PresharedKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load preshared key file: %w", err)
+			}
+			closeables.AddWithError(keysFile.Close)
+
+			c.GRPCAuthFunc = auth.MustRequireReloadablePresharedKey(c.PresharedKey, keysFile.Load)
+		} else {
+			c.GRPCAuthFunc = auth.MustRequirePresharedKey(c.PresharedKey)
+		}
 	} else {
 		log.Ctx(ctx).Trace().Msg("using preconfigured auth function")
 	}
@@ -193,8 +517,9 @@ func (c *Config) Complete(ctx context.Context) (RunnableServer, error) {
 		return nil, fmt.Errorf("failed to create namespace cache: %w", err)
 	}
 	log.Ctx(ctx).Info().EmbedObject(nscc).Msg("configured namespace cache")
+	reloader.reloadableCache("ns-cache", nscc)
 
-	ds = proxy.NewCachingDatastoreProxy(ds, nscc)
+	ds = proxy.NewCachingDatastoreProxy(ds, nscc, proxy.WithStaleWhileRevalidate(c.NamespaceCacheStaleWhileRevalidate))
 	ds = proxy.NewObservableDatastoreProxy(ds)
 	closeables.AddWithError(ds.Close)
 
@@ -208,12 +533,32 @@ func (c *Config) Complete(ctx context.Context) (RunnableServer, error) {
 		}
 		closeables.AddWithoutError(cc.Close)
 		log.Ctx(ctx).Info().EmbedObject(cc).Msg("configured dispatch cache")
+		reloader.reloadableCache("dispatch-cache", cc)
 
 		dispatchPresharedKey := ""
 		if len(c.PresharedKey) > 0 {
 			dispatchPresharedKey = c.PresharedKey[0]
 		}
 
+		// The upstream certificate authority is reloaded whenever it changes, so that it can be
+		// rotated without restart.
+		var upstreamCAPool func() *x509.CertPool
+		if c.DispatchUpstreamAddr != "" && c.DispatchUpstreamCAPath != "" {
+			caFile, err := hotreload.NewFile("dispatch-upstream-ca", func() (*x509.CertPool, error) {
+				return x509util.CustomCertPool(c.DispatchUpstreamCAPath)
+			}, c.DispatchUpstreamCAPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load dispatch upstream ca: %w", err)
+			}
+			closeables.AddWithError(caFile.Close)
+			upstreamCAPool = caFile.Load
+		}
+
+		serviceConfig, err := balancer.LoadAwareServiceConfig(c.DispatchLoadAwareConfig)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dispatch load-aware balancing config: %w", err)
+		}
+
 		specificConcurrencyLimits := c.DispatchConcurrencyLimits
 		concurrencyLimits := specificConcurrencyLimits.WithOverallDefaultLimit(c.GlobalDispatchConcurrencyLimit)
 		log.Ctx(ctx).Info().EmbedObject(concurrencyLimits).Msg("configured dispatch concurrency limits")
@@ -221,14 +566,39 @@ func (c *Config) Complete(ctx context.Context) (RunnableServer, error) {
 		dispatcher, err = combineddispatch.NewDispatcher(
 			combineddispatch.UpstreamAddr(c.DispatchUpstreamAddr),
 			combineddispatch.UpstreamCAPath(c.DispatchUpstreamCAPath),
+			combineddispatch.UpstreamCAPool(upstreamCAPool),
+			combineddispatch.DeadlineMargin(c.DispatchDeadlineMargin),
+			combineddispatch.HedgingDelay(c.DispatchHedgingDelay),
+			combineddispatch.CircuitBreaker(remote.CircuitBreakerConfig{
+				FailureThreshold: c.DispatchCircuitBreakerFailures,
+				OpenDuration:     c.DispatchCircuitBreakerOpenTime,
+			}),
+			combineddispatch.UpstreamConnections(c.DispatchUpstreamConnections),
+			combineddispatch.UpstreamKeepalive(keepalive.ClientParameters{
+				Time:                c.DispatchUpstreamKeepaliveTime,
+				Timeout:             c.DispatchUpstreamKeepaliveTTL,
+				PermitWithoutStream: true,
+			}),
+			combineddispatch.MaxInflightPerPeer(c.DispatchUpstreamMaxInflight),
 			combineddispatch.GrpcPresharedKey(dispatchPresharedKey),
 			combineddispatch.GrpcDialOpts(
 				grpc.WithUnaryInterceptor(otelgrpc.UnaryClientInterceptor()),
-				grpc.WithDefaultServiceConfig(balancer.BalancerServiceConfig),
+				grpc.WithDefaultServiceConfig(serviceConfig),
 			),
 			combineddispatch.MetricsEnabled(c.DispatchClientMetricsEnabled),
 			combineddispatch.PrometheusSubsystem(c.DispatchClientMetricsPrefix),
 			combineddispatch.Cache(cc),
+			combineddispatch.CachePersistence(caching.PersistenceConfig{
+				Path:     c.DispatchCachePersistencePath,
+				Interval: c.DispatchCachePersistenceInterval,
+				ValidateRevision: func(ctx context.Context, revision string) error {
+					rev, err := ds.RevisionFromString(revision)
+					if err != nil {
+						return err
+					}
+					return ds.CheckRevision(ctx, rev)
+				},
+			}),
 			combineddispatch.ConcurrencyLimits(concurrencyLimits),
 		)
 		if err != nil {
@@ -253,6 +623,7 @@ func (c *Config) Complete(ctx context.Context) (RunnableServer, error) {
 		}
 		log.Ctx(ctx).Info().EmbedObject(cdcc).Msg("configured cluster dispatch cache")
 		closeables.AddWithoutError(cdcc.Close)
+		reloader.reloadableCache("dispatch-cluster-cache", cdcc)
 
 		cachingClusterDispatch, err = clusterdispatch.NewClusterDispatcher(
 			dispatcher,
@@ -273,6 +644,8 @@ func (c *Config) Complete(ctx context.Context) (RunnableServer, error) {
 		},
 		grpc.ChainUnaryInterceptor(c.DispatchUnaryMiddleware...),
 		grpc.ChainStreamInterceptor(c.DispatchStreamingMiddleware...),
+		// Peers send keepalive pings as often as this node does, so they must be permitted.
+		grpc.KeepaliveEnforcementPolicy(dispatchKeepaliveEnforcement(c.DispatchUpstreamKeepaliveTime)),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create dispatch gRPC server: %w", err)
@@ -297,7 +670,348 @@ func (c *Config) Complete(ctx context.Context) (RunnableServer, error) {
 		watchServiceOption = services.WatchServiceDisabled
 	}
 
-	defaultMiddlewareChain, err := DefaultMiddleware(log.Logger, c.GRPCAuthFunc, !c.DisableVersionResponse, dispatcher, ds)
+	adminServiceOption := services.AdminServiceDisabled
+	if c.AdminAPIEnabled {
+		adminServiceOption = services.AdminServiceEnabled
+	}
+
+	// Tasks which need only run on one replica of the cluster are run by the replica holding their
+	// lease in the datastore, when leader election is enabled.
+	singleton := func(_ string, run func(context.Context) error) func(context.Context) error { return run }
+	if c.LeaderElectionEnabled {
+		leases, ok := datastore.Unwrap(ds).(dscommon.LeaseStore)
+		if !ok {
+			log.Ctx(ctx).Warn().Str("engine", c.DatastoreConfig.Engine).Msg("datastore does not support leases; background tasks will run on every replica")
+		} else {
+			if err := dscommon.RegisterLeaseMetrics(); err != nil {
+				log.Ctx(ctx).Warn().Err(err).Msg("unable to register lease metrics")
+			}
+
+			singleton = func(name string, run func(context.Context) error) func(context.Context) error {
+				return func(ctx context.Context) error {
+					return dscommon.RunWithLease(ctx, leases, name, c.LeaderLeaseDuration, run)
+				}
+			}
+		}
+	}
+
+	orphanScanner := func(ctx context.Context) error { return nil }
+	if c.OrphanScanInterval > 0 {
+		if err := relationships.RegisterOrphanMetrics(); err != nil {
+			log.Ctx(ctx).Warn().Err(err).Msg("unable to register orphaned relationship metrics")
+		}
+
+		orphanScanner = singleton("orphan-scanner", func(ctx context.Context) error {
+			return relationships.StartOrphanScanner(ctx, ds, c.OrphanScanInterval)
+		})
+	}
+
+	expiredRelationshipReaper := func(ctx context.Context) error { return nil }
+	if c.RelationshipExpirationInterval > 0 {
+		if err := relationships.RegisterExpirationMetrics(); err != nil {
+			log.Ctx(ctx).Warn().Err(err).Msg("unable to register expired relationship metrics")
+		}
+
+		expiredRelationshipReaper = singleton("expired-relationship-reaper", func(ctx context.Context) error {
+			return relationships.StartExpiredRelationshipReaper(ctx, ds, c.RelationshipExpirationInterval)
+		})
+	}
+
+	// Deleted relationships are retained in the datastore, hidden from reads, until they are
+	// restored or purged at the end of the restore window.
+	var deletedRelationships dscommon.DeletedRelationshipStore
+	deletedRelationshipPurger := func(ctx context.Context) error { return nil }
+	if c.RelationshipRestoreWindow > 0 {
+		store, ok := datastore.Unwrap(ds).(dscommon.DeletedRelationshipStore)
+		if !ok {
+			return nil, fmt.Errorf("restoring deleted relationships is not supported by the %s datastore", c.DatastoreConfig.Engine)
+		}
+		deletedRelationships = store
+
+		if c.RelationshipRestorePurgeInterval > 0 {
+			if err := relationships.RegisterPurgeMetrics(); err != nil {
+				log.Ctx(ctx).Warn().Err(err).Msg("unable to register deleted relationship purge metrics")
+			}
+
+			deletedRelationshipPurger = singleton("deleted-relationship-purger", func(ctx context.Context) error {
+				return relationships.StartDeletedRelationshipPurger(ctx, store, c.RelationshipRestoreWindow, c.RelationshipRestorePurgeInterval)
+			})
+		}
+	}
+
+	var usageTracker *relationusage.Tracker
+	usageAnalyzer := func(ctx context.Context) error { return nil }
+	if c.RelationUsageAnalysisInterval > 0 {
+		if err := relationusage.RegisterMetrics(); err != nil {
+			log.Ctx(ctx).Warn().Err(err).Msg("unable to register relation usage metrics")
+		}
+
+		usageTracker = relationusage.NewTracker()
+		usageAnalyzer = func(ctx context.Context) error {
+			return usageTracker.Start(ctx, ds, c.RelationUsageAnalysisInterval)
+		}
+	}
+
+	var quotaTracker *quota.Tracker
+	quotaLimits := quota.Limits{
+		quota.Relationships: {Soft: c.QuotaRelationshipsSoftLimit, Hard: c.QuotaRelationshipsHardLimit},
+		quota.Namespaces:    {Soft: c.QuotaNamespacesSoftLimit, Hard: c.QuotaNamespacesHardLimit},
+	}
+	for _, limit := range quotaLimits {
+		if limit.Soft > 0 || limit.Hard > 0 {
+			if err := quota.RegisterMetrics(); err != nil {
+				log.Ctx(ctx).Warn().Err(err).Msg("unable to register quota metrics")
+			}
+
+			quotaTracker = quota.NewTracker(quotaLimits)
+			break
+		}
+	}
+
+	var materializer *materialize.Materializer
+	permissionMaterializer := func(ctx context.Context) error { return nil }
+	if len(c.MaterializedPermissions) > 0 {
+		if !datastoreFeatures.Watch.Enabled {
+			return nil, fmt.Errorf("materialized permissions require a datastore supporting watch: %s", datastoreFeatures.Watch.Reason)
+		}
+
+		specs := make([]materialize.Spec, 0, len(c.MaterializedPermissions))
+		for _, permission := range c.MaterializedPermissions {
+			spec, err := materialize.ParseSpec(permission)
+			if err != nil {
+				return nil, err
+			}
+			specs = append(specs, spec)
+		}
+
+		if err := materialize.RegisterMetrics(); err != nil {
+			log.Ctx(ctx).Warn().Err(err).Msg("unable to register materialized permission metrics")
+		}
+
+		materializer = materialize.NewMaterializer(ds, dispatcher, specs, c.DispatchMaxDepth)
+		permissionMaterializer = materializer.Start
+	}
+
+	var relationshipArchive *archive.Archive
+	archiveRecorder := func(ctx context.Context) error { return nil }
+	if c.ArchiveRecordingEnabled {
+		if c.ArchiveDirectory == "" {
+			return nil, fmt.Errorf("recording an archive requires an archive directory")
+		}
+		if !datastoreFeatures.Watch.Enabled {
+			return nil, fmt.Errorf("recording an archive requires a datastore supporting watch: %s", datastoreFeatures.Watch.Reason)
+		}
+
+		recorder, err := archive.NewRecorder(ds, archive.Config{
+			Directory:        c.ArchiveDirectory,
+			SnapshotInterval: c.ArchiveSnapshotInterval,
+		})
+		if err != nil {
+			return nil, err
+		}
+		archiveRecorder = recorder.Start
+	}
+	if c.ArchiveDirectory != "" {
+		relationshipArchive = archive.NewArchive(c.ArchiveDirectory)
+	}
+
+	var jobManager *jobs.Manager
+	jobRunner := func(ctx context.Context) error { return nil }
+	if c.JobsEnabled {
+		store, ok := datastore.Unwrap(ds).(dscommon.JobStore)
+		if !ok {
+			log.Ctx(ctx).Warn().Str("engine", c.DatastoreConfig.Engine).Msg("datastore does not support jobs")
+		} else {
+			jobManager = jobs.NewManager(store, c.JobLeaseDuration, c.JobPollInterval)
+			jobManager.Register(jobs.KindDeleteRelationships, jobs.DeleteRelationships(ds))
+			jobRunner = jobManager.Run
+		}
+	}
+
+	ldapReconciler := func(ctx context.Context) error { return nil }
+	if c.LDAPSyncInterval > 0 {
+		mappingFile, err := ldapsync.ReadMappingFile(c.LDAPSyncMappingFile)
+		if err != nil {
+			return nil, err
+		}
+
+		source, err := ldapsync.NewLDAPSource(ldapsync.LDAPConfig{
+			URL:          c.LDAPSyncURL,
+			BindDN:       c.LDAPSyncBindDN,
+			BindPassword: c.LDAPSyncBindPassword,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if err := ldapsync.RegisterMetrics(); err != nil {
+			log.Ctx(ctx).Warn().Err(err).Msg("unable to register ldap sync metrics")
+		}
+
+		reconciler := ldapsync.NewReconciler(ds, source, mappingFile, c.LDAPSyncDryRun)
+		ldapReconciler = singleton("ldap-sync", func(ctx context.Context) error {
+			return reconciler.Start(ctx, c.LDAPSyncInterval)
+		})
+	}
+
+	var memoryShedder loadshed.Shedder
+	memoryManager := func(ctx context.Context) error { return nil }
+	if c.MemoryManagerEnabled {
+		manager, err := memory.NewManager(ctx, c.MemoryConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure memory manager: %w", err)
+		}
+		memoryShedder = manager
+		memoryManager = manager.Start
+	}
+
+	tokenPriorities, err := c.tokenPriorities()
+	if err != nil {
+		return nil, err
+	}
+
+	tokenAllowlists, err := c.tokenAllowlists()
+	if err != nil {
+		return nil, err
+	}
+
+	requestTimeouts, err := requesttimeout.Parse(c.MethodTimeouts, c.MethodMaximumTimeouts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request timeouts: %w", err)
+	}
+
+	var staleFallback *consistencymw.StaleFallback
+	if c.StaleCheckMaximumStaleness > 0 {
+		staleFallback = consistencymw.NewStaleFallback(c.StaleCheckMaximumStaleness)
+		log.Ctx(ctx).Info().Dur("window", c.StaleCheckMaximumStaleness).Msg("serving stale checks during datastore outages")
+	}
+
+	// Only the dispatches of API requests are scheduled by priority; those received from
+	// other nodes in the cluster have already been admitted by the node which received the
+	// request.
+	weights := make(map[priority.Priority]uint32, len(c.DispatchPriorityWeights))
+	for name, weight := range c.DispatchPriorityWeights {
+		p, err := priority.Parse(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dispatch priority weights: %w", err)
+		}
+		if weight <= 0 {
+			return nil, fmt.Errorf("invalid dispatch priority weights: weight of `%s` must be positive", name)
+		}
+		weights[p] = uint32(weight)
+	}
+
+	apiDispatcher := dispatcher
+	if c.DispatchPrioritySlots > 0 {
+		apiDispatcher, err = scheduler.NewDispatcher(dispatcher, c.DispatchPrioritySlots, weights)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create dispatch scheduler: %w", err)
+		}
+		log.Ctx(ctx).Info().Uint16("slots", c.DispatchPrioritySlots).Interface("weights", weights).Msg("scheduling API dispatches by priority")
+	}
+
+	// Dispatches routed to a dedicated pool are scheduled by that pool alone, while the others
+	// remain scheduled by priority, if configured.
+	if len(c.DispatchPoolSlots) > 0 {
+		slots := make(map[string]uint16, len(c.DispatchPoolSlots))
+		for name, poolSlots := range c.DispatchPoolSlots {
+			if poolSlots <= 0 || poolSlots > math.MaxUint16 {
+				return nil, fmt.Errorf("invalid dispatch pool slots: slots of `%s` must be between 1 and %d", name, math.MaxUint16)
+			}
+			slots[name] = uint16(poolSlots)
+		}
+
+		tokenPools, err := c.tokenDispatchPools()
+		if err != nil {
+			return nil, err
+		}
+
+		apiDispatcher, err = scheduler.NewPoolDispatcher(dispatcher, apiDispatcher, slots, scheduler.PoolRoutes{
+			Tokens:     tokenPools,
+			Namespaces: c.DispatchPoolNamespaces,
+		}, weights)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create dispatch pools: %w", err)
+		}
+		log.Ctx(ctx).Info().Interface("slots", slots).Interface("namespaces", c.DispatchPoolNamespaces).Int("tokens", len(tokenPools)).Msg("routing API dispatches to dedicated pools")
+	}
+
+	shadowDispatcher, shadowConn, err := c.shadowDispatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create shadow dispatcher: %w", err)
+	}
+	closeables.AddCloser(shadowConn)
+	if shadowDispatcher != nil {
+		apiDispatcher, err = shadow.NewDispatcher(apiDispatcher, shadowDispatcher, c.DispatchShadowConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create shadow dispatcher: %w", err)
+		}
+		log.Ctx(ctx).Info().Str("upstream", c.DispatchShadowUpstreamAddr).Float64("sample-rate", c.DispatchShadowConfig.SampleRate).Msg("comparing API dispatches against shadow dispatcher")
+	}
+
+	decisionLogger, err := c.decisionLogger()
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure decision logs: %w", err)
+	}
+
+	decisionLogUploader := func(ctx context.Context) error { return nil }
+	if decisionLogger != nil {
+		decisionLogUploader = decisionLogger.Start
+		log.Ctx(ctx).Info().Str("bucket", c.DecisionLogS3Bucket).Str("prefix", c.DecisionLogS3Prefix).Msg("uploading decision logs")
+	}
+
+	warmupRecorder, warmupRequests, err := c.warmupRecorder()
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure warmup: %w", err)
+	}
+
+	warmupRecorderWriter := func(ctx context.Context) error { return nil }
+	if warmupRecorder != nil {
+		warmupRecorderWriter = warmupRecorder.Start
+		log.Ctx(ctx).Info().Str("path", c.WarmupConfig.Path).Float64("sample-rate", c.WarmupConfig.SampleRate).Int("recorded", len(warmupRequests)).Msg("recording requests for warmup")
+	}
+
+	requestConcurrencyLimit := concurrencylimit.NewDefaultLimit(c.DefaultRequestConcurrencyLimit)
+	reloader.reloadableConcurrencyLimit("dispatch-default-request-concurrency-limit", requestConcurrencyLimit)
+
+	captureRecorder, err := c.captureRecorder()
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure request capture: %w", err)
+	}
+
+	captureWriter := func(ctx context.Context) error { return nil }
+	if captureRecorder != nil {
+		captureWriter = captureRecorder.Start
+		log.Ctx(ctx).Info().Str("directory", c.CaptureConfig.Directory).Float64("sample-rate", c.CaptureConfig.SampleRate).Msg("capturing requests")
+	}
+
+	validationWebhook, err := c.validationWebhook()
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure validation webhook: %w", err)
+	}
+	if validationWebhook != nil {
+		log.Ctx(ctx).Info().Str("url", c.ValidationWebhookConfig.URL).Bool("fail-open", c.ValidationWebhookConfig.FailOpen).Msg("validating mutations with webhook")
+	}
+
+	defaultMiddlewareChain, err := DefaultMiddleware(MiddlewareOption{
+		Logger:                         log.Logger,
+		AuthFunc:                       c.GRPCAuthFunc,
+		EnableVersionResponse:          !c.DisableVersionResponse,
+		Dispatcher:                     apiDispatcher,
+		Datastore:                      ds,
+		DefaultRequestConcurrencyLimit: requestConcurrencyLimit,
+		TokenPriorities:                tokenPriorities,
+		TokenAllowlists:                tokenAllowlists,
+		Shedder:                        memoryShedder,
+		DecisionLogger:                 decisionLogger,
+		UsageTracker:                   usageTracker,
+		QuotaTracker:                   quotaTracker,
+		WarmupRecorder:                 warmupRecorder,
+		CaptureRecorder:                captureRecorder,
+		ValidationWebhook:              validationWebhook,
+		RequestTimeouts:                requestTimeouts,
+		StaleFallback:                  staleFallback,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("error building default middleware: %w", err)
 	}
@@ -307,23 +1021,109 @@ func (c *Config) Complete(ctx context.Context) (RunnableServer, error) {
 		return nil, fmt.Errorf("error building Middlewares: %w", err)
 	}
 
+	// All reloadable settings have been registered.
+	if configFile != nil {
+		configFile.Subscribe(reloader.apply)
+		log.Ctx(ctx).Info().Str("path", c.ConfigFile).Msg("reloading settings whenever the config file changes")
+	}
+
+	var traceRecorder *tracestore.Recorder
+	if c.TraceSampleRate > 0 || c.TraceStorePath != "" {
+		traceStore := tracestore.NewMemoryStore(defaultMaxStoredTraces)
+		if c.TraceStorePath != "" {
+			traceStore, err = tracestore.NewDirectoryStore(c.TraceStorePath)
+			if err != nil {
+				return nil, fmt.Errorf("unable to create trace store: %w", err)
+			}
+		}
+		traceRecorder = tracestore.NewRecorder(traceStore, c.TraceSampleRate)
+		log.Ctx(ctx).Info().Float64("sample-rate", c.TraceSampleRate).Str("path", c.TraceStorePath).Msg("recording request traces")
+	}
+
 	permSysConfig := v1svc.PermissionsServerConfig{
 		MaxPreconditionsCount: c.MaximumPreconditionCount,
 		MaxUpdatesPerWrite:    c.MaximumUpdatesPerWrite,
 		MaximumAPIDepth:       c.DispatchMaxDepth,
+		MaximumResultSize:     c.MaximumResultSize,
+		TraceRecorder:         traceRecorder,
+		Materializer:          materializer,
+		Archive:               relationshipArchive,
+		Jobs:                  jobManager,
+
+		StrictRelationshipValidation: c.StrictRelationshipValidation,
+		RelationshipRestoreWindow:    c.RelationshipRestoreWindow,
+		RelationshipRestoreTokenKeys: c.RelationshipRestoreTokenKeyManager,
+		DeletedRelationships:         deletedRelationships,
+		MaximumNestingDepth:          c.MaximumNestingDepth,
+		PointInTimeCheckEnabled:      c.PointInTimeCheckEnabled,
 	}
 
 	healthManager := health.NewHealthManager(dispatcher, ds)
+
+	schemaDriftChecker := func(ctx context.Context) error { return nil }
+	if c.SchemaDriftCheckInterval > 0 {
+		checker, ok := datastore.Unwrap(ds).(dscommon.SchemaDriftChecker)
+		if !ok {
+			log.Ctx(ctx).Warn().Str("engine", c.DatastoreConfig.Engine).Msg("datastore does not support schema drift detection")
+		} else {
+			if err := dscommon.RegisterSchemaDriftMetrics(); err != nil {
+				log.Ctx(ctx).Warn().Err(err).Msg("unable to register schema drift metrics")
+			}
+
+			schemaDriftChecker = func(ctx context.Context) error {
+				return dscommon.StartSchemaDriftCheck(ctx, checker, c.SchemaDriftCheckInterval, func(drift []dscommon.SchemaDrift) {
+					healthManager.ReportSchemaDrift(len(drift) > 0)
+				})
+			}
+		}
+	}
+
+	revisionHeartbeat := func(ctx context.Context) error { return nil }
+	if c.RevisionHeartbeatInterval > 0 {
+		if c.DatastoreConfig.ReadOnly {
+			log.Ctx(ctx).Warn().Msg("revision heartbeat is disabled for read-only datastores")
+		} else {
+			if err := dscommon.RegisterRevisionHeartbeatMetrics(); err != nil {
+				log.Ctx(ctx).Warn().Err(err).Msg("unable to register revision heartbeat metrics")
+			}
+
+			revisionHeartbeat = singleton("revision-heartbeat", func(ctx context.Context) error {
+				return dscommon.StartRevisionHeartbeat(ctx, ds, c.RevisionHeartbeatInterval)
+			})
+		}
+	}
+
+	relationCounter := func(ctx context.Context) error { return nil }
+	if c.RelationCountInterval > 0 {
+		counter, ok := datastore.Unwrap(ds).(dscommon.RelationCounter)
+		if !ok {
+			log.Ctx(ctx).Warn().Str("engine", c.DatastoreConfig.Engine).Msg("datastore does not support counting relationships by relation")
+		} else {
+			if err := dscommon.RegisterRelationCountMetrics(); err != nil {
+				log.Ctx(ctx).Warn().Err(err).Msg("unable to register relation count metrics")
+			}
+
+			relationCounter = singleton("relation-counter", func(ctx context.Context) error {
+				return dscommon.StartRelationCounting(ctx, counter, c.RelationCountInterval, c.RelationshipGrowthAlertRate, c.RelationshipGrowthAlertFunc)
+			})
+		}
+	}
+
 	grpcServer, err := c.GRPCServer.Complete(zerolog.InfoLevel,
 		func(server *grpc.Server) {
 			services.RegisterGrpcServices(
 				server,
 				healthManager,
-				dispatcher,
+				apiDispatcher,
 				v1SchemaServiceOption,
 				watchServiceOption,
+				adminServiceOption,
 				permSysConfig,
 			)
+
+			if c.PlaygroundAPIEnabled {
+				services.RegisterDeveloperService(server, healthManager, v0svc.NewBoundedInMemoryShareStore(c.PlaygroundShareStoreSalt, c.PlaygroundShareStoreMaxBytes))
+			}
 		},
 	)
 	if err != nil {
@@ -331,6 +1131,12 @@ func (c *Config) Complete(ctx context.Context) (RunnableServer, error) {
 	}
 	closeables.AddWithoutError(grpcServer.GracefulStop)
 
+	warmupConn, err := c.initializeWarmup(ctx, grpcServer, healthManager, warmupRequests)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize warmup: %w", err)
+	}
+	closeables.AddCloser(warmupConn)
+
 	gatewayServer, gatewayCloser, err := c.initializeGateway(ctx)
 	if err != nil {
 		return nil, err
@@ -369,27 +1175,313 @@ func (c *Config) Complete(ctx context.Context) (RunnableServer, error) {
 		}
 	}
 
-	metricsServer, err := c.MetricsAPI.Complete(zerolog.InfoLevel, MetricsHandler(registry))
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/", MetricsHandler(registry))
+	if c.TopologyEnabled {
+		metricsMux.Handle("/topology", topology.Handler(topology.Config{
+			APIAddress: c.TopologyAdvertisedAPIAddress,
+			Serving: func(ctx context.Context) bool {
+				resp, err := healthManager.HealthSvc().Check(ctx, &healthpb.HealthCheckRequest{Service: v1.PermissionsService_ServiceDesc.ServiceName})
+				return err == nil && resp.Status == healthpb.HealthCheckResponse_SERVING
+			},
+			Overloaded: func() bool {
+				return memoryShedder != nil && memoryShedder.ShouldShed()
+			},
+		}))
+	}
+
+	metricsServer, err := c.MetricsAPI.Complete(zerolog.InfoLevel, metricsMux)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize metrics server: %w", err)
 	}
 	closeables.AddWithoutError(metricsServer.Close)
 
+	scimServer, err := c.initializeSCIM(ds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize scim server: %w", err)
+	}
+	closeables.AddWithoutError(scimServer.Close)
+
+	extAuthzServer, extAuthzConn, err := c.initializeExtAuthz(ctx, grpcServer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize ext_authz server: %w", err)
+	}
+	closeables.AddCloser(extAuthzConn)
+	closeables.AddWithoutError(extAuthzServer.GracefulStop)
+
+	kubeAuthzServer, kubeAuthzConn, err := c.initializeKubeAuthz(ctx, grpcServer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize kubernetes authorization webhook: %w", err)
+	}
+	closeables.AddCloser(kubeAuthzConn)
+	closeables.AddWithoutError(kubeAuthzServer.Close)
+
 	return &completedServerConfig{
 		gRPCServer:          grpcServer,
 		dispatchGRPCServer:  dispatchGrpcServer,
 		gatewayServer:       gatewayServer,
 		metricsServer:       metricsServer,
 		dashboardServer:     dashboardServer,
+		scimServer:          scimServer,
+		extAuthzServer:      extAuthzServer,
+		kubeAuthzServer:     kubeAuthzServer,
 		unaryMiddleware:     unaryMiddleware,
 		streamingMiddleware: streamingMiddleware,
 		presharedKeys:       c.PresharedKey,
 		telemetryReporter:   reporter,
 		healthManager:       healthManager,
+		orphanScanner:       orphanScanner,
+		expiredReaper:       expiredRelationshipReaper,
+		deletedPurger:       deletedRelationshipPurger,
+		usageAnalyzer:       usageAnalyzer,
+		materializer:        permissionMaterializer,
+		archiveRecorder:     archiveRecorder,
+		schemaDriftChecker:  schemaDriftChecker,
+		revisionHeartbeat:   revisionHeartbeat,
+		relationCounter:     relationCounter,
+		jobRunner:           jobRunner,
+		ldapReconciler:      ldapReconciler,
+		memoryManager:       memoryManager,
+		decisionLogUploader: decisionLogUploader,
+		warmupRecorder:      warmupRecorderWriter,
+		captureRecorder:     captureWriter,
 		closeFunc:           closeables.Close,
 	}, nil
 }
 
+// shadowDispatcher returns a dispatcher to the cluster at DispatchShadowUpstreamAddr, against
+// which a sample of the dispatches of API requests are compared, along with its connection, or
+// nil if shadow dispatching is disabled.
+func (c *Config) shadowDispatcher() (dispatch.Dispatcher, io.Closer, error) {
+	if c.DispatchShadowUpstreamAddr == "" {
+		return nil, nil, nil
+	}
+
+	if len(c.PresharedKey) == 0 {
+		return nil, nil, fmt.Errorf("a preshared key is required to dispatch to the shadow cluster")
+	}
+
+	opts := []grpc.DialOption{
+		grpc.WithUnaryInterceptor(otelgrpc.UnaryClientInterceptor()),
+		grpc.WithDefaultServiceConfig(balancer.BalancerServiceConfig),
+	}
+	if c.DispatchUpstreamCAPath != "" {
+		opts = append(opts, grpcutil.WithCustomCerts(c.DispatchUpstreamCAPath, grpcutil.VerifyCA), grpcutil.WithBearerToken(c.PresharedKey[0]))
+	} else {
+		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()), grpcutil.WithInsecureBearerToken(c.PresharedKey[0]))
+	}
+
+	conn, err := grpc.Dial(c.DispatchShadowUpstreamAddr, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return remote.NewClusterDispatcher(dispatchv1.NewDispatchServiceClient(conn), conn, remote.ClusterDispatcherConfig{
+		KeyHandler:             &keys.CanonicalKeyHandler{},
+		DispatchOverallTimeout: c.DispatchShadowConfig.Timeout,
+	}), conn, nil
+}
+
+// dispatchKeepaliveEnforcement returns the policy for keepalive pings from peers, which is the
+// default policy unless keepalive pings are sent to peers more often than it permits.
+func dispatchKeepaliveEnforcement(keepaliveTime time.Duration) keepalive.EnforcementPolicy {
+	const defaultMinTime = 5 * time.Minute
+	if keepaliveTime <= 0 || keepaliveTime >= defaultMinTime {
+		return keepalive.EnforcementPolicy{MinTime: defaultMinTime}
+	}
+	return keepalive.EnforcementPolicy{MinTime: keepaliveTime, PermitWithoutStream: true}
+}
+
+// decisionLogger returns the logger of the decisions of permission checks, or nil if decision
+// logs are disabled.
+func (c *Config) decisionLogger() (*decisionlog.Logger, error) {
+	if !c.DecisionLogEnabled {
+		return nil, nil
+	}
+
+	awsConfig := &aws.Config{Region: aws.String(c.DecisionLogS3Region)}
+	if c.DecisionLogS3Endpoint != "" {
+		awsConfig.Endpoint = aws.String(c.DecisionLogS3Endpoint)
+		awsConfig.S3ForcePathStyle = aws.Bool(true)
+	}
+	if c.DecisionLogS3AccessKey != "" {
+		awsConfig.Credentials = credentials.NewStaticCredentials(c.DecisionLogS3AccessKey, c.DecisionLogS3SecretKey, "")
+	}
+
+	uploader, err := decisionlog.NewS3Uploader(c.DecisionLogS3Bucket, c.DecisionLogS3Prefix, awsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	config := c.DecisionLogConfig
+	if _, ok := config.Labels["version"]; !ok {
+		if version, err := releases.CurrentVersion(); err == nil {
+			labels := map[string]string{"version": version}
+			for key, value := range config.Labels {
+				labels[key] = value
+			}
+			config.Labels = labels
+		}
+	}
+
+	return decisionlog.NewLogger(config, uploader)
+}
+
+// warmupRecorder returns the recorder of requests for warmup, or nil if warmup is disabled,
+// along with the requests recorded before the server started, which are replayed.
+func (c *Config) warmupRecorder() (*warmup.Recorder, []warmup.Request, error) {
+	if c.WarmupConfig.Path == "" {
+		return nil, nil, nil
+	}
+
+	requests, err := warmup.ReadRequests(c.WarmupConfig.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	recorder, err := warmup.NewRecorder(c.WarmupConfig, requests)
+	return recorder, requests, err
+}
+
+// captureRecorder returns the recorder capturing a sample of requests along with their
+// responses, or nil if capture is disabled.
+func (c *Config) captureRecorder() (*capture.Recorder, error) {
+	if c.CaptureConfig.Directory == "" {
+		return nil, nil
+	}
+	return capture.NewRecorder(c.CaptureConfig)
+}
+
+// validationWebhook returns the webhook reviewing mutations before they are applied, or nil if
+// no webhook is configured.
+func (c *Config) validationWebhook() (*validationwebhook.Webhook, error) {
+	if c.ValidationWebhookConfig.URL == "" {
+		return nil, nil
+	}
+	return validationwebhook.NewWebhook(c.ValidationWebhookConfig)
+}
+
+// initializeWarmup registers the replay of the requests recorded for warmup against the given
+// gRPC server, which delays reporting the server as serving until they have been replayed,
+// and returns its connection to the server.
+func (c *Config) initializeWarmup(ctx context.Context, grpcServer util.RunnableGRPCServer, healthManager health.Manager, requests []warmup.Request) (io.Closer, error) {
+	if len(requests) == 0 {
+		return nil, nil
+	}
+
+	conn, err := c.dialAPI(ctx, grpcServer)
+	if err != nil {
+		return nil, err
+	}
+
+	healthManager.RegisterWarmup(func(ctx context.Context) error {
+		if c.WarmupConfig.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, c.WarmupConfig.Timeout)
+			defer cancel()
+		}
+
+		start := time.Now()
+		stats := warmup.Replay(ctx, conn, requests, c.WarmupConfig.Concurrency)
+		log.Ctx(ctx).Info().Int("replayed", stats.Replayed).Int("failed", stats.Failed).Int("skipped", stats.Skipped).Dur("duration", time.Since(start)).Msg("warmed up by replaying recorded requests")
+		return nil
+	})
+	return conn, nil
+}
+
+// initializeSCIM returns the server of the SCIM endpoint through which identity providers sync
+// group memberships.
+func (c *Config) initializeSCIM(ds datastore.Datastore) (util.RunnableHTTPServer, error) {
+	if !c.SCIMServer.Enabled {
+		return c.SCIMServer.Complete(zerolog.InfoLevel, nil)
+	}
+
+	handler, err := scim.NewHandler(ds, scim.Config{
+		GroupType:      c.SCIMGroupType,
+		MemberRelation: c.SCIMMemberRelation,
+		UserType:       c.SCIMUserType,
+		DryRun:         c.SCIMDryRun,
+		BearerTokens:   c.SCIMBearerToken,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return c.SCIMServer.Complete(zerolog.InfoLevel, handler)
+}
+
+// dialAPI returns a connection to the given gRPC API server, authenticated with the first
+// preshared key, for services which check permissions on behalf of other systems.
+func (c *Config) dialAPI(ctx context.Context, grpcServer util.RunnableGRPCServer) (*grpc.ClientConn, error) {
+	if len(c.PresharedKey) == 0 {
+		return nil, fmt.Errorf("a preshared key is required to check permissions against the api")
+	}
+
+	var opts []grpc.DialOption
+	if grpcServer.Insecure() {
+		opts = append(opts, grpcutil.WithInsecureBearerToken(c.PresharedKey[0]))
+	} else {
+		opts = append(opts, grpcutil.WithBearerToken(c.PresharedKey[0]))
+	}
+
+	return grpcServer.DialContext(ctx, opts...)
+}
+
+// initializeExtAuthz returns the gRPC server of the Envoy external authorization service, which
+// checks permissions against the given gRPC server, along with its connection to the server.
+func (c *Config) initializeExtAuthz(ctx context.Context, grpcServer util.RunnableGRPCServer) (util.RunnableGRPCServer, io.Closer, error) {
+	if !c.ExtAuthzServer.Enabled {
+		server, err := c.ExtAuthzServer.Complete(zerolog.InfoLevel, nil)
+		return server, nil, err
+	}
+
+	mapping, err := extauthz.ReadMappingFile(c.ExtAuthzMappingFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conn, err := c.dialAPI(ctx, grpcServer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	server, err := c.ExtAuthzServer.Complete(zerolog.InfoLevel, func(server *grpc.Server) {
+		extauthz.RegisterGrpcServices(server, v1.NewPermissionsServiceClient(conn), mapping)
+	})
+	return server, conn, err
+}
+
+// initializeKubeAuthz returns the server of the Kubernetes authorization webhook, which checks
+// permissions against the given gRPC server, along with its connection to the server.
+func (c *Config) initializeKubeAuthz(ctx context.Context, grpcServer util.RunnableGRPCServer) (util.RunnableHTTPServer, io.Closer, error) {
+	if !c.KubeAuthzServer.Enabled {
+		server, err := c.KubeAuthzServer.Complete(zerolog.InfoLevel, nil)
+		return server, nil, err
+	}
+
+	conn, err := c.dialAPI(ctx, grpcServer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	handler, err := kubeauthz.NewHandler(v1.NewPermissionsServiceClient(conn), kubeauthz.Config{
+		NamespaceType: c.KubeAuthzNamespaceType,
+		ClusterType:   c.KubeAuthzClusterType,
+		ClusterID:     c.KubeAuthzClusterID,
+		UserType:      c.KubeAuthzUserType,
+		GroupType:     c.KubeAuthzGroupType,
+		GroupRelation: c.KubeAuthzGroupRelation,
+		Deny:          c.KubeAuthzDeny,
+		BearerTokens:  c.KubeAuthzBearerToken,
+	})
+	if err != nil {
+		return nil, conn, err
+	}
+
+	server, err := c.KubeAuthzServer.Complete(zerolog.InfoLevel, handler)
+	return server, conn, err
+}
+
 func (c *Config) buildMiddleware(defaultMiddleware *MiddlewareChain) ([]grpc.UnaryServerInterceptor, []grpc.StreamServerInterceptor, error) {
 	chain := MiddlewareChain{}
 	if defaultMiddleware != nil {
@@ -402,10 +1494,89 @@ func (c *Config) buildMiddleware(defaultMiddleware *MiddlewareChain) ([]grpc.Una
 	return unaryOutput, streamingOutput, nil
 }
 
+// tokenPriorities returns the maximum priority class of the requests made with each preshared
+// key, as configured by the priority at the same index in PresharedKeyPriorities.
+func (c *Config) tokenPriorities() (map[string]priority.Priority, error) {
+	if len(c.PresharedKeyPriorities) > len(c.PresharedKey) {
+		return nil, fmt.Errorf("%d preshared key priorities were provided for %d preshared keys", len(c.PresharedKeyPriorities), len(c.PresharedKey))
+	}
+
+	tokenPriorities := make(map[string]priority.Priority, len(c.PresharedKeyPriorities))
+	for index, name := range c.PresharedKeyPriorities {
+		if name == "" {
+			continue
+		}
+
+		p, err := priority.Parse(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid priority for preshared key #%d: %w", index+1, err)
+		}
+		tokenPriorities[c.PresharedKey[index]] = p
+	}
+	return tokenPriorities, nil
+}
+
+// tokenDispatchPools returns the dispatch pool to which the requests made with each preshared
+// key are routed, as configured by the pool at the same index in PresharedKeyDispatchPools.
+func (c *Config) tokenDispatchPools() (map[string]string, error) {
+	if len(c.PresharedKeyDispatchPools) > len(c.PresharedKey) {
+		return nil, fmt.Errorf("%d preshared key dispatch pools were provided for %d preshared keys", len(c.PresharedKeyDispatchPools), len(c.PresharedKey))
+	}
+
+	tokenPools := make(map[string]string, len(c.PresharedKeyDispatchPools))
+	for index, name := range c.PresharedKeyDispatchPools {
+		if name != "" {
+			tokenPools[c.PresharedKey[index]] = name
+		}
+	}
+	return tokenPools, nil
+}
+
+// tokenAllowlists returns the namespaces visible to each preshared key, as configured by the
+// comma-separated allowlist at the same index in PresharedKeyNamespaces.
+func (c *Config) tokenAllowlists() (visibility.TokenAllowlists, error) {
+	if len(c.PresharedKeyNamespaces) > len(c.PresharedKey) {
+		return nil, fmt.Errorf("%d preshared key namespace allowlists were provided for %d preshared keys", len(c.PresharedKeyNamespaces), len(c.PresharedKey))
+	}
+
+	tokenAllowlists := make(visibility.TokenAllowlists, len(c.PresharedKeyNamespaces))
+	for index, entries := range c.PresharedKeyNamespaces {
+		if entries == "" {
+			continue
+		}
+
+		allowlist, err := visibility.ParseAllowlist(strings.Split(entries, ","))
+		if err != nil {
+			return nil, fmt.Errorf("invalid namespace allowlist for preshared key #%d: %w", index+1, err)
+		}
+		tokenAllowlists[c.PresharedKey[index]] = allowlist
+	}
+	return tokenAllowlists, nil
+}
+
+// readPresharedKeys reads the preshared keys in the file at the given path, one per line.
+func readPresharedKeys(path string) ([]string, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var presharedKeys []string
+	for _, line := range strings.Split(string(contents), "\n") {
+		if presharedKey := strings.TrimSpace(line); presharedKey != "" {
+			presharedKeys = append(presharedKeys, presharedKey)
+		}
+	}
+	return presharedKeys, nil
+}
+
 // initializeGateway Configures the gateway to serve HTTP
 func (c *Config) initializeGateway(ctx context.Context) (util.RunnableHTTPServer, io.Closer, error) {
 	if len(c.HTTPGatewayUpstreamAddr) == 0 {
 		c.HTTPGatewayUpstreamAddr = c.GRPCServer.Address
+		if c.GRPCServer.IsUnixNetwork() {
+			c.HTTPGatewayUpstreamAddr = "unix:" + c.GRPCServer.Address
+		}
 	} else {
 		log.Ctx(ctx).Info().Str("upstream", c.HTTPGatewayUpstreamAddr).Msg("Overriding REST gateway upstream")
 	}
@@ -448,6 +1619,12 @@ func (c *Config) initializeGateway(ctx context.Context) (util.RunnableHTTPServer
 type RunnableServer interface {
 	Run(ctx context.Context) error
 	GRPCDialContext(ctx context.Context, opts ...grpc.DialOption) (*grpc.ClientConn, error)
+
+	// GRPCNetDialContext returns a low level connection to the gRPC API server, for use with
+	// grpc.WithContextDialer by clients dialing the server themselves, such as when it is served
+	// in memory on util.BufferedNetwork.
+	GRPCNetDialContext(ctx context.Context, s string) (net.Conn, error)
+
 	DispatchNetDialContext(ctx context.Context, s string) (net.Conn, error)
 }
 
@@ -455,13 +1632,31 @@ type RunnableServer interface {
 // but is assumed have already been validated via `Complete()` on Config.
 // It offers limited options for mutation before Run() starts the services.
 type completedServerConfig struct {
-	gRPCServer         util.RunnableGRPCServer
-	dispatchGRPCServer util.RunnableGRPCServer
-	gatewayServer      util.RunnableHTTPServer
-	metricsServer      util.RunnableHTTPServer
-	dashboardServer    util.RunnableHTTPServer
-	telemetryReporter  telemetry.Reporter
-	healthManager      health.Manager
+	gRPCServer          util.RunnableGRPCServer
+	dispatchGRPCServer  util.RunnableGRPCServer
+	gatewayServer       util.RunnableHTTPServer
+	metricsServer       util.RunnableHTTPServer
+	dashboardServer     util.RunnableHTTPServer
+	scimServer          util.RunnableHTTPServer
+	extAuthzServer      util.RunnableGRPCServer
+	kubeAuthzServer     util.RunnableHTTPServer
+	telemetryReporter   telemetry.Reporter
+	healthManager       health.Manager
+	orphanScanner       func(context.Context) error
+	expiredReaper       func(context.Context) error
+	deletedPurger       func(context.Context) error
+	usageAnalyzer       func(context.Context) error
+	materializer        func(context.Context) error
+	archiveRecorder     func(context.Context) error
+	schemaDriftChecker  func(context.Context) error
+	revisionHeartbeat   func(context.Context) error
+	relationCounter     func(context.Context) error
+	jobRunner           func(context.Context) error
+	ldapReconciler      func(context.Context) error
+	memoryManager       func(context.Context) error
+	decisionLogUploader func(context.Context) error
+	warmupRecorder      func(context.Context) error
+	captureRecorder     func(context.Context) error
 
 	unaryMiddleware     []grpc.UnaryServerInterceptor
 	streamingMiddleware []grpc.StreamServerInterceptor
@@ -481,6 +1676,10 @@ func (c *completedServerConfig) GRPCDialContext(ctx context.Context, opts ...grp
 	return c.gRPCServer.DialContext(ctx, opts...)
 }
 
+func (c *completedServerConfig) GRPCNetDialContext(ctx context.Context, s string) (net.Conn, error) {
+	return c.gRPCServer.NetDialContext(ctx, s)
+}
+
 func (c *completedServerConfig) DispatchNetDialContext(ctx context.Context, s string) (net.Conn, error) {
 	return c.dispatchGRPCServer.NetDialContext(ctx, s)
 }
@@ -502,7 +1701,25 @@ func (c *completedServerConfig) Run(ctx context.Context) error {
 	g.Go(c.gatewayServer.ListenAndServe)
 	g.Go(c.metricsServer.ListenAndServe)
 	g.Go(c.dashboardServer.ListenAndServe)
+	g.Go(c.scimServer.ListenAndServe)
+	g.Go(c.extAuthzServer.Listen(ctx))
+	g.Go(c.kubeAuthzServer.ListenAndServe)
 	g.Go(func() error { return c.telemetryReporter(ctx) })
+	g.Go(func() error { return c.orphanScanner(ctx) })
+	g.Go(func() error { return c.expiredReaper(ctx) })
+	g.Go(func() error { return c.deletedPurger(ctx) })
+	g.Go(func() error { return c.usageAnalyzer(ctx) })
+	g.Go(func() error { return c.materializer(ctx) })
+	g.Go(func() error { return c.archiveRecorder(ctx) })
+	g.Go(func() error { return c.schemaDriftChecker(ctx) })
+	g.Go(func() error { return c.revisionHeartbeat(ctx) })
+	g.Go(func() error { return c.relationCounter(ctx) })
+	g.Go(func() error { return c.jobRunner(ctx) })
+	g.Go(func() error { return c.ldapReconciler(ctx) })
+	g.Go(func() error { return c.memoryManager(ctx) })
+	g.Go(func() error { return c.decisionLogUploader(ctx) })
+	g.Go(func() error { return c.warmupRecorder(ctx) })
+	g.Go(func() error { return c.captureRecorder(ctx) })
 
 	g.Go(stopOnCancelWithErr(c.closeFunc))
 
@@ -514,6 +1731,10 @@ func (c *completedServerConfig) Run(ctx context.Context) error {
 	return nil
 }
 
+// defaultMaxStoredTraces is the number of the most recent traces held when traces are not
+// written to a directory.
+const defaultMaxStoredTraces = 1000
+
 var promOnce sync.Once
 
 // enableGRPCHistogram enables the standard time history for gRPC requests,
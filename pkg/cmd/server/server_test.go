@@ -3,14 +3,22 @@ package server
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
 	"time"
 
+	v0 "github.com/authzed/authzed-go/proto/authzed/api/v0"
+	"github.com/authzed/grpcutil"
+
 	"github.com/authzed/spicedb/internal/datastore/memdb"
 	"github.com/authzed/spicedb/internal/logging"
+	"github.com/authzed/spicedb/pkg/cmd/util"
 
 	"github.com/stretchr/testify/require"
 	"go.uber.org/goleak"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
 func TestServerGracefulTermination(t *testing.T) {
@@ -46,6 +54,57 @@ func TestServerGracefulTerminationOnError(t *testing.T) {
 	require.ErrorIs(t, err, context.Canceled)
 }
 
+func TestPlaygroundAPI(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		enabled := enabled
+		t.Run(fmt.Sprintf("enabled=%v", enabled), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			ds, err := memdb.NewMemdbDatastore(0, 1*time.Second, 10*time.Second)
+			require.NoError(t, err)
+
+			c := ConfigWithOptions(&Config{},
+				WithPresharedKey("psk"),
+				WithDatastore(ds),
+				WithPlaygroundAPIEnabled(enabled),
+				WithPlaygroundShareStoreMaxBytes(1024*1024),
+				WithGRPCServer(util.GRPCServerConfig{Network: util.BufferedNetwork, Enabled: true}),
+				WithHTTPGateway(util.HTTPServerConfig{Enabled: false}),
+				WithDashboardAPI(util.HTTPServerConfig{Enabled: false}),
+				WithMetricsAPI(util.HTTPServerConfig{Enabled: false}),
+				WithDispatchServer(util.GRPCServerConfig{Enabled: false}),
+			)
+			rs, err := c.Complete(ctx)
+			require.NoError(t, err)
+
+			go func() {
+				require.NoError(t, rs.Run(ctx))
+			}()
+
+			conn, err := rs.GRPCDialContext(ctx,
+				grpc.WithTransportCredentials(insecure.NewCredentials()),
+				grpcutil.WithInsecureBearerToken("psk"),
+			)
+			require.NoError(t, err)
+			t.Cleanup(func() { conn.Close() })
+
+			client := v0.NewDeveloperServiceClient(conn)
+			shared, err := client.Share(ctx, &v0.ShareRequest{Schema: "definition user {}"})
+			if !enabled {
+				grpcutil.RequireStatus(t, codes.Unimplemented, err)
+				return
+			}
+			require.NoError(t, err)
+
+			found, err := client.LookupShared(ctx, &v0.LookupShareRequest{ShareReference: shared.ShareReference})
+			require.NoError(t, err)
+			require.Equal(t, v0.LookupShareResponse_VALID_REFERENCE, found.Status)
+			require.Equal(t, "definition user {}", found.Schema)
+		})
+	}
+}
+
 func TestReplaceMiddleware(t *testing.T) {
 	c := Config{MiddlewareModification: []MiddlewareModification{
 		{
@@ -86,7 +145,7 @@ func TestModifyMiddleware(t *testing.T) {
 		},
 	}}
 
-	defaultMw, err := DefaultMiddleware(logging.Logger, nil, false, nil, nil)
+	defaultMw, err := DefaultMiddleware(MiddlewareOption{Logger: logging.Logger})
 	require.NoError(t, err)
 
 	unary, streaming, err := c.buildMiddleware(defaultMw)
@@ -100,3 +159,32 @@ func TestModifyMiddleware(t *testing.T) {
 	err = streaming[1](context.Background(), nil, nil, nil)
 	require.ErrorContains(t, err, "hi")
 }
+
+func TestRelationshipRestoreConfig(t *testing.T) {
+	ds, err := memdb.NewMemdbDatastore(0, 1*time.Second, 10*time.Second)
+	require.NoError(t, err)
+	t.Cleanup(func() { ds.Close() })
+
+	// Deleted relationships are retained apart from the history of the datastore, so the restore
+	// window may exceed its gc window.
+	c := ConfigWithOptions(&Config{}, WithPresharedKey("psk"), WithDatastore(ds), WithRelationshipRestoreWindow(2*time.Hour),
+		WithRelationshipRestoreTokenKeys("restore=MDEyMzQ1Njc4OWFiY2RlZg=="))
+	_, err = c.Complete(context.Background())
+	require.NoError(t, err)
+
+	// Restore tokens must be signed.
+	c = ConfigWithOptions(&Config{}, WithPresharedKey("psk"), WithDatastore(ds), WithRelationshipRestoreWindow(time.Minute))
+	_, err = c.Complete(context.Background())
+	require.ErrorContains(t, err, "requires keys")
+}
+
+func TestTopologyConfig(t *testing.T) {
+	ds, err := memdb.NewMemdbDatastore(0, 1*time.Second, 10*time.Second)
+	require.NoError(t, err)
+	t.Cleanup(func() { ds.Close() })
+
+	// The topology endpoint requires the address at which clients reach the API.
+	c := ConfigWithOptions(&Config{}, WithPresharedKey("psk"), WithDatastore(ds), WithTopologyEnabled(true))
+	_, err = c.Complete(context.Background())
+	require.ErrorContains(t, err, "requires the address at which clients reach the API")
+}
@@ -2,11 +2,21 @@
 package server
 
 import (
+	common "github.com/authzed/spicedb/internal/datastore/common"
 	dispatch "github.com/authzed/spicedb/internal/dispatch"
 	graph "github.com/authzed/spicedb/internal/dispatch/graph"
+	shadow "github.com/authzed/spicedb/internal/dispatch/shadow"
+	memory "github.com/authzed/spicedb/internal/memory"
+	capture "github.com/authzed/spicedb/internal/middleware/capture"
+	decisionlog "github.com/authzed/spicedb/internal/middleware/decisionlog"
+	validationwebhook "github.com/authzed/spicedb/internal/middleware/validationwebhook"
+	warmup "github.com/authzed/spicedb/internal/warmup"
+	balancer "github.com/authzed/spicedb/pkg/balancer"
 	datastore "github.com/authzed/spicedb/pkg/cmd/datastore"
 	util "github.com/authzed/spicedb/pkg/cmd/util"
 	datastore1 "github.com/authzed/spicedb/pkg/datastore"
+	redaction "github.com/authzed/spicedb/pkg/redaction"
+	secrets "github.com/authzed/spicedb/pkg/secrets"
 	auth "github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/auth"
 	grpc "google.golang.org/grpc"
 	"time"
@@ -26,11 +36,20 @@ func NewConfigWithOptions(opts ...ConfigOption) *Config {
 // ToOption returns a new ConfigOption that sets the values from the passed in Config
 func (c *Config) ToOption() ConfigOption {
 	return func(to *Config) {
+		to.ConfigFile = c.ConfigFile
+		to.ConfigFileOverrides = c.ConfigFileOverrides
+		to.TLSPolicy = c.TLSPolicy
 		to.GRPCServer = c.GRPCServer
 		to.GRPCAuthFunc = c.GRPCAuthFunc
 		to.PresharedKey = c.PresharedKey
+		to.PresharedKeyFile = c.PresharedKeyFile
+		to.PresharedKeyPriorities = c.PresharedKeyPriorities
+		to.PresharedKeyNamespaces = c.PresharedKeyNamespaces
+		to.PresharedKeyDispatchPools = c.PresharedKeyDispatchPools
 		to.ShutdownGracePeriod = c.ShutdownGracePeriod
 		to.DisableVersionResponse = c.DisableVersionResponse
+		to.MethodTimeouts = c.MethodTimeouts
+		to.MethodMaximumTimeouts = c.MethodMaximumTimeouts
 		to.HTTPGateway = c.HTTPGateway
 		to.HTTPGatewayUpstreamAddr = c.HTTPGatewayUpstreamAddr
 		to.HTTPGatewayUpstreamTLSCertPath = c.HTTPGatewayUpstreamTLSCertPath
@@ -39,14 +58,31 @@ func (c *Config) ToOption() ConfigOption {
 		to.DatastoreConfig = c.DatastoreConfig
 		to.Datastore = c.Datastore
 		to.NamespaceCacheConfig = c.NamespaceCacheConfig
+		to.NamespaceCacheStaleWhileRevalidate = c.NamespaceCacheStaleWhileRevalidate
 		to.SchemaPrefixesRequired = c.SchemaPrefixesRequired
 		to.DispatchServer = c.DispatchServer
 		to.DispatchMaxDepth = c.DispatchMaxDepth
 		to.GlobalDispatchConcurrencyLimit = c.GlobalDispatchConcurrencyLimit
 		to.DispatchConcurrencyLimits = c.DispatchConcurrencyLimits
+		to.DefaultRequestConcurrencyLimit = c.DefaultRequestConcurrencyLimit
+		to.DispatchPrioritySlots = c.DispatchPrioritySlots
+		to.DispatchPriorityWeights = c.DispatchPriorityWeights
+		to.DispatchPoolSlots = c.DispatchPoolSlots
+		to.DispatchPoolNamespaces = c.DispatchPoolNamespaces
 		to.DispatchUpstreamAddr = c.DispatchUpstreamAddr
 		to.DispatchUpstreamCAPath = c.DispatchUpstreamCAPath
 		to.DispatchUpstreamTimeout = c.DispatchUpstreamTimeout
+		to.DispatchDeadlineMargin = c.DispatchDeadlineMargin
+		to.DispatchHedgingDelay = c.DispatchHedgingDelay
+		to.DispatchCircuitBreakerFailures = c.DispatchCircuitBreakerFailures
+		to.DispatchCircuitBreakerOpenTime = c.DispatchCircuitBreakerOpenTime
+		to.DispatchUpstreamConnections = c.DispatchUpstreamConnections
+		to.DispatchUpstreamKeepaliveTime = c.DispatchUpstreamKeepaliveTime
+		to.DispatchUpstreamKeepaliveTTL = c.DispatchUpstreamKeepaliveTTL
+		to.DispatchUpstreamMaxInflight = c.DispatchUpstreamMaxInflight
+		to.DispatchLoadAwareConfig = c.DispatchLoadAwareConfig
+		to.DispatchShadowUpstreamAddr = c.DispatchShadowUpstreamAddr
+		to.DispatchShadowConfig = c.DispatchShadowConfig
 		to.DispatchClientMetricsEnabled = c.DispatchClientMetricsEnabled
 		to.DispatchClientMetricsPrefix = c.DispatchClientMetricsPrefix
 		to.DispatchClusterMetricsEnabled = c.DispatchClusterMetricsEnabled
@@ -54,12 +90,88 @@ func (c *Config) ToOption() ConfigOption {
 		to.Dispatcher = c.Dispatcher
 		to.DispatchCacheConfig = c.DispatchCacheConfig
 		to.ClusterDispatchCacheConfig = c.ClusterDispatchCacheConfig
+		to.DispatchCachePersistencePath = c.DispatchCachePersistencePath
+		to.DispatchCachePersistenceInterval = c.DispatchCachePersistenceInterval
 		to.DisableV1SchemaAPI = c.DisableV1SchemaAPI
 		to.V1SchemaAdditiveOnly = c.V1SchemaAdditiveOnly
 		to.MaximumUpdatesPerWrite = c.MaximumUpdatesPerWrite
 		to.MaximumPreconditionCount = c.MaximumPreconditionCount
+		to.StrictRelationshipValidation = c.StrictRelationshipValidation
+		to.AdminAPIEnabled = c.AdminAPIEnabled
+		to.RelationshipRestoreWindow = c.RelationshipRestoreWindow
+		to.RelationshipRestoreTokenKeys = c.RelationshipRestoreTokenKeys
+		to.RelationshipRestoreTokenKeyManager = c.RelationshipRestoreTokenKeyManager
+		to.RelationshipRestorePurgeInterval = c.RelationshipRestorePurgeInterval
+		to.MaximumResultSize = c.MaximumResultSize
+		to.MaximumNestingDepth = c.MaximumNestingDepth
+		to.StaleCheckMaximumStaleness = c.StaleCheckMaximumStaleness
+		to.TraceSampleRate = c.TraceSampleRate
+		to.TraceStorePath = c.TraceStorePath
+		to.PlaygroundAPIEnabled = c.PlaygroundAPIEnabled
+		to.PlaygroundShareStoreSalt = c.PlaygroundShareStoreSalt
+		to.PlaygroundShareStoreMaxBytes = c.PlaygroundShareStoreMaxBytes
+		to.OrphanScanInterval = c.OrphanScanInterval
+		to.RelationshipExpirationInterval = c.RelationshipExpirationInterval
+		to.RelationUsageAnalysisInterval = c.RelationUsageAnalysisInterval
+		to.QuotaRelationshipsSoftLimit = c.QuotaRelationshipsSoftLimit
+		to.QuotaRelationshipsHardLimit = c.QuotaRelationshipsHardLimit
+		to.QuotaNamespacesSoftLimit = c.QuotaNamespacesSoftLimit
+		to.QuotaNamespacesHardLimit = c.QuotaNamespacesHardLimit
+		to.MaterializedPermissions = c.MaterializedPermissions
+		to.PointInTimeCheckEnabled = c.PointInTimeCheckEnabled
+		to.ArchiveDirectory = c.ArchiveDirectory
+		to.ArchiveRecordingEnabled = c.ArchiveRecordingEnabled
+		to.ArchiveSnapshotInterval = c.ArchiveSnapshotInterval
+		to.SchemaDriftCheckInterval = c.SchemaDriftCheckInterval
+		to.RevisionHeartbeatInterval = c.RevisionHeartbeatInterval
+		to.RelationCountInterval = c.RelationCountInterval
+		to.RelationshipGrowthAlertRate = c.RelationshipGrowthAlertRate
+		to.RelationshipGrowthAlertFunc = c.RelationshipGrowthAlertFunc
+		to.LeaderElectionEnabled = c.LeaderElectionEnabled
+		to.LeaderLeaseDuration = c.LeaderLeaseDuration
+		to.JobsEnabled = c.JobsEnabled
+		to.JobLeaseDuration = c.JobLeaseDuration
+		to.JobPollInterval = c.JobPollInterval
+		to.LDAPSyncInterval = c.LDAPSyncInterval
+		to.LDAPSyncMappingFile = c.LDAPSyncMappingFile
+		to.LDAPSyncURL = c.LDAPSyncURL
+		to.LDAPSyncBindDN = c.LDAPSyncBindDN
+		to.LDAPSyncBindPassword = c.LDAPSyncBindPassword
+		to.LDAPSyncDryRun = c.LDAPSyncDryRun
+		to.ExtAuthzServer = c.ExtAuthzServer
+		to.ExtAuthzMappingFile = c.ExtAuthzMappingFile
+		to.KubeAuthzServer = c.KubeAuthzServer
+		to.KubeAuthzBearerToken = c.KubeAuthzBearerToken
+		to.KubeAuthzNamespaceType = c.KubeAuthzNamespaceType
+		to.KubeAuthzClusterType = c.KubeAuthzClusterType
+		to.KubeAuthzClusterID = c.KubeAuthzClusterID
+		to.KubeAuthzUserType = c.KubeAuthzUserType
+		to.KubeAuthzGroupType = c.KubeAuthzGroupType
+		to.KubeAuthzGroupRelation = c.KubeAuthzGroupRelation
+		to.KubeAuthzDeny = c.KubeAuthzDeny
+		to.SCIMServer = c.SCIMServer
+		to.SCIMBearerToken = c.SCIMBearerToken
+		to.SCIMGroupType = c.SCIMGroupType
+		to.SCIMMemberRelation = c.SCIMMemberRelation
+		to.SCIMUserType = c.SCIMUserType
+		to.SCIMDryRun = c.SCIMDryRun
+		to.DecisionLogEnabled = c.DecisionLogEnabled
+		to.DecisionLogConfig = c.DecisionLogConfig
+		to.DecisionLogS3Bucket = c.DecisionLogS3Bucket
+		to.DecisionLogS3Prefix = c.DecisionLogS3Prefix
+		to.DecisionLogS3Endpoint = c.DecisionLogS3Endpoint
+		to.DecisionLogS3Region = c.DecisionLogS3Region
+		to.DecisionLogS3AccessKey = c.DecisionLogS3AccessKey
+		to.DecisionLogS3SecretKey = c.DecisionLogS3SecretKey
+		to.WarmupConfig = c.WarmupConfig
+		to.CaptureConfig = c.CaptureConfig
+		to.ValidationWebhookConfig = c.ValidationWebhookConfig
+		to.MemoryManagerEnabled = c.MemoryManagerEnabled
+		to.MemoryConfig = c.MemoryConfig
 		to.DashboardAPI = c.DashboardAPI
 		to.MetricsAPI = c.MetricsAPI
+		to.TopologyEnabled = c.TopologyEnabled
+		to.TopologyAdvertisedAPIAddress = c.TopologyAdvertisedAPIAddress
 		to.MiddlewareModification = c.MiddlewareModification
 		to.DispatchUnaryMiddleware = c.DispatchUnaryMiddleware
 		to.DispatchStreamingMiddleware = c.DispatchStreamingMiddleware
@@ -67,6 +179,10 @@ func (c *Config) ToOption() ConfigOption {
 		to.TelemetryCAOverridePath = c.TelemetryCAOverridePath
 		to.TelemetryEndpoint = c.TelemetryEndpoint
 		to.TelemetryInterval = c.TelemetryInterval
+		to.RedactionPolicy = c.RedactionPolicy
+		to.EnabledExperiments = c.EnabledExperiments
+		to.DisabledExperiments = c.DisabledExperiments
+		to.TemplatesFile = c.TemplatesFile
 	}
 }
 
@@ -78,6 +194,34 @@ func ConfigWithOptions(c *Config, opts ...ConfigOption) *Config {
 	return c
 }
 
+// WithConfigFile returns an option that can set ConfigFile on a Config
+func WithConfigFile(configFile string) ConfigOption {
+	return func(c *Config) {
+		c.ConfigFile = configFile
+	}
+}
+
+// WithConfigFileOverrides returns an option that can append ConfigFileOverridess to Config.ConfigFileOverrides
+func WithConfigFileOverrides(configFileOverrides string) ConfigOption {
+	return func(c *Config) {
+		c.ConfigFileOverrides = append(c.ConfigFileOverrides, configFileOverrides)
+	}
+}
+
+// SetConfigFileOverrides returns an option that can set ConfigFileOverrides on a Config
+func SetConfigFileOverrides(configFileOverrides []string) ConfigOption {
+	return func(c *Config) {
+		c.ConfigFileOverrides = configFileOverrides
+	}
+}
+
+// WithTLSPolicy returns an option that can set TLSPolicy on a Config
+func WithTLSPolicy(tLSPolicy util.TLSPolicy) ConfigOption {
+	return func(c *Config) {
+		c.TLSPolicy = tLSPolicy
+	}
+}
+
 // WithGRPCServer returns an option that can set GRPCServer on a Config
 func WithGRPCServer(gRPCServer util.GRPCServerConfig) ConfigOption {
 	return func(c *Config) {
@@ -106,6 +250,55 @@ func SetPresharedKey(presharedKey []string) ConfigOption {
 	}
 }
 
+// WithPresharedKeyFile returns an option that can set PresharedKeyFile on a Config
+func WithPresharedKeyFile(presharedKeyFile string) ConfigOption {
+	return func(c *Config) {
+		c.PresharedKeyFile = presharedKeyFile
+	}
+}
+
+// WithPresharedKeyPriorities returns an option that can append PresharedKeyPrioritiess to Config.PresharedKeyPriorities
+func WithPresharedKeyPriorities(presharedKeyPriorities string) ConfigOption {
+	return func(c *Config) {
+		c.PresharedKeyPriorities = append(c.PresharedKeyPriorities, presharedKeyPriorities)
+	}
+}
+
+// SetPresharedKeyPriorities returns an option that can set PresharedKeyPriorities on a Config
+func SetPresharedKeyPriorities(presharedKeyPriorities []string) ConfigOption {
+	return func(c *Config) {
+		c.PresharedKeyPriorities = presharedKeyPriorities
+	}
+}
+
+// WithPresharedKeyNamespaces returns an option that can append PresharedKeyNamespacess to Config.PresharedKeyNamespaces
+func WithPresharedKeyNamespaces(presharedKeyNamespaces string) ConfigOption {
+	return func(c *Config) {
+		c.PresharedKeyNamespaces = append(c.PresharedKeyNamespaces, presharedKeyNamespaces)
+	}
+}
+
+// SetPresharedKeyNamespaces returns an option that can set PresharedKeyNamespaces on a Config
+func SetPresharedKeyNamespaces(presharedKeyNamespaces []string) ConfigOption {
+	return func(c *Config) {
+		c.PresharedKeyNamespaces = presharedKeyNamespaces
+	}
+}
+
+// WithPresharedKeyDispatchPools returns an option that can append PresharedKeyDispatchPoolss to Config.PresharedKeyDispatchPools
+func WithPresharedKeyDispatchPools(presharedKeyDispatchPools string) ConfigOption {
+	return func(c *Config) {
+		c.PresharedKeyDispatchPools = append(c.PresharedKeyDispatchPools, presharedKeyDispatchPools)
+	}
+}
+
+// SetPresharedKeyDispatchPools returns an option that can set PresharedKeyDispatchPools on a Config
+func SetPresharedKeyDispatchPools(presharedKeyDispatchPools []string) ConfigOption {
+	return func(c *Config) {
+		c.PresharedKeyDispatchPools = presharedKeyDispatchPools
+	}
+}
+
 // WithShutdownGracePeriod returns an option that can set ShutdownGracePeriod on a Config
 func WithShutdownGracePeriod(shutdownGracePeriod time.Duration) ConfigOption {
 	return func(c *Config) {
@@ -120,6 +313,34 @@ func WithDisableVersionResponse(disableVersionResponse bool) ConfigOption {
 	}
 }
 
+// WithMethodTimeouts returns an option that can append MethodTimeoutss to Config.MethodTimeouts
+func WithMethodTimeouts(key string, value string) ConfigOption {
+	return func(c *Config) {
+		c.MethodTimeouts[key] = value
+	}
+}
+
+// SetMethodTimeouts returns an option that can set MethodTimeouts on a Config
+func SetMethodTimeouts(methodTimeouts map[string]string) ConfigOption {
+	return func(c *Config) {
+		c.MethodTimeouts = methodTimeouts
+	}
+}
+
+// WithMethodMaximumTimeouts returns an option that can append MethodMaximumTimeoutss to Config.MethodMaximumTimeouts
+func WithMethodMaximumTimeouts(key string, value string) ConfigOption {
+	return func(c *Config) {
+		c.MethodMaximumTimeouts[key] = value
+	}
+}
+
+// SetMethodMaximumTimeouts returns an option that can set MethodMaximumTimeouts on a Config
+func SetMethodMaximumTimeouts(methodMaximumTimeouts map[string]string) ConfigOption {
+	return func(c *Config) {
+		c.MethodMaximumTimeouts = methodMaximumTimeouts
+	}
+}
+
 // WithHTTPGateway returns an option that can set HTTPGateway on a Config
 func WithHTTPGateway(hTTPGateway util.HTTPServerConfig) ConfigOption {
 	return func(c *Config) {
@@ -183,6 +404,13 @@ func WithNamespaceCacheConfig(namespaceCacheConfig CacheConfig) ConfigOption {
 	}
 }
 
+// WithNamespaceCacheStaleWhileRevalidate returns an option that can set NamespaceCacheStaleWhileRevalidate on a Config
+func WithNamespaceCacheStaleWhileRevalidate(namespaceCacheStaleWhileRevalidate time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.NamespaceCacheStaleWhileRevalidate = namespaceCacheStaleWhileRevalidate
+	}
+}
+
 // WithSchemaPrefixesRequired returns an option that can set SchemaPrefixesRequired on a Config
 func WithSchemaPrefixesRequired(schemaPrefixesRequired bool) ConfigOption {
 	return func(c *Config) {
@@ -218,6 +446,62 @@ func WithDispatchConcurrencyLimits(dispatchConcurrencyLimits graph.ConcurrencyLi
 	}
 }
 
+// WithDefaultRequestConcurrencyLimit returns an option that can set DefaultRequestConcurrencyLimit on a Config
+func WithDefaultRequestConcurrencyLimit(defaultRequestConcurrencyLimit uint16) ConfigOption {
+	return func(c *Config) {
+		c.DefaultRequestConcurrencyLimit = defaultRequestConcurrencyLimit
+	}
+}
+
+// WithDispatchPrioritySlots returns an option that can set DispatchPrioritySlots on a Config
+func WithDispatchPrioritySlots(dispatchPrioritySlots uint16) ConfigOption {
+	return func(c *Config) {
+		c.DispatchPrioritySlots = dispatchPrioritySlots
+	}
+}
+
+// WithDispatchPriorityWeights returns an option that can append DispatchPriorityWeightss to Config.DispatchPriorityWeights
+func WithDispatchPriorityWeights(key string, value int) ConfigOption {
+	return func(c *Config) {
+		c.DispatchPriorityWeights[key] = value
+	}
+}
+
+// SetDispatchPriorityWeights returns an option that can set DispatchPriorityWeights on a Config
+func SetDispatchPriorityWeights(dispatchPriorityWeights map[string]int) ConfigOption {
+	return func(c *Config) {
+		c.DispatchPriorityWeights = dispatchPriorityWeights
+	}
+}
+
+// WithDispatchPoolSlots returns an option that can append DispatchPoolSlotss to Config.DispatchPoolSlots
+func WithDispatchPoolSlots(key string, value int) ConfigOption {
+	return func(c *Config) {
+		c.DispatchPoolSlots[key] = value
+	}
+}
+
+// SetDispatchPoolSlots returns an option that can set DispatchPoolSlots on a Config
+func SetDispatchPoolSlots(dispatchPoolSlots map[string]int) ConfigOption {
+	return func(c *Config) {
+		c.DispatchPoolSlots = dispatchPoolSlots
+	}
+}
+
+// WithDispatchPoolNamespaces returns an option that can append DispatchPoolNamespacess to Config.DispatchPoolNamespaces
+func WithDispatchPoolNamespaces(key string, value string) ConfigOption {
+	return func(c *Config) {
+		c.DispatchPoolNamespaces[key] = value
+	}
+}
+
+// SetDispatchPoolNamespaces returns an option that can set DispatchPoolNamespaces on a Config
+func SetDispatchPoolNamespaces(dispatchPoolNamespaces map[string]string) ConfigOption {
+	return func(c *Config) {
+		c.DispatchPoolNamespaces = dispatchPoolNamespaces
+	}
+}
+
 // WithDispatchUpstreamAddr returns an option that can set DispatchUpstreamAddr on a Config
 func WithDispatchUpstreamAddr(dispatchUpstreamAddr string) ConfigOption {
 	return func(c *Config) {
@@ -239,6 +523,83 @@ func WithDispatchUpstreamTimeout(dispatchUpstreamTimeout time.Duration) ConfigOp
 	}
 }
 
+// WithDispatchDeadlineMargin returns an option that can set DispatchDeadlineMargin on a Config
+func WithDispatchDeadlineMargin(dispatchDeadlineMargin time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.DispatchDeadlineMargin = dispatchDeadlineMargin
+	}
+}
+
+// WithDispatchHedgingDelay returns an option that can set DispatchHedgingDelay on a Config
+func WithDispatchHedgingDelay(dispatchHedgingDelay time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.DispatchHedgingDelay = dispatchHedgingDelay
+	}
+}
+
+// WithDispatchCircuitBreakerFailures returns an option that can set DispatchCircuitBreakerFailures on a Config
+func WithDispatchCircuitBreakerFailures(dispatchCircuitBreakerFailures uint32) ConfigOption {
+	return func(c *Config) {
+		c.DispatchCircuitBreakerFailures = dispatchCircuitBreakerFailures
+	}
+}
+
+// WithDispatchCircuitBreakerOpenTime returns an option that can set DispatchCircuitBreakerOpenTime on a Config
+func WithDispatchCircuitBreakerOpenTime(dispatchCircuitBreakerOpenTime time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.DispatchCircuitBreakerOpenTime = dispatchCircuitBreakerOpenTime
+	}
+}
+
+// WithDispatchUpstreamConnections returns an option that can set DispatchUpstreamConnections on a Config
+func WithDispatchUpstreamConnections(dispatchUpstreamConnections uint16) ConfigOption {
+	return func(c *Config) {
+		c.DispatchUpstreamConnections = dispatchUpstreamConnections
+	}
+}
+
+// WithDispatchUpstreamKeepaliveTime returns an option that can set DispatchUpstreamKeepaliveTime on a Config
+func WithDispatchUpstreamKeepaliveTime(dispatchUpstreamKeepaliveTime time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.DispatchUpstreamKeepaliveTime = dispatchUpstreamKeepaliveTime
+	}
+}
+
+// WithDispatchUpstreamKeepaliveTTL returns an option that can set DispatchUpstreamKeepaliveTTL on a Config
+func WithDispatchUpstreamKeepaliveTTL(dispatchUpstreamKeepaliveTTL time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.DispatchUpstreamKeepaliveTTL = dispatchUpstreamKeepaliveTTL
+	}
+}
+
+// WithDispatchUpstreamMaxInflight returns an option that can set DispatchUpstreamMaxInflight on a Config
+func WithDispatchUpstreamMaxInflight(dispatchUpstreamMaxInflight uint32) ConfigOption {
+	return func(c *Config) {
+		c.DispatchUpstreamMaxInflight = dispatchUpstreamMaxInflight
+	}
+}
+
+// WithDispatchLoadAwareConfig returns an option that can set DispatchLoadAwareConfig on a Config
+func WithDispatchLoadAwareConfig(dispatchLoadAwareConfig balancer.LoadAwareConfig) ConfigOption {
+	return func(c *Config) {
+		c.DispatchLoadAwareConfig = dispatchLoadAwareConfig
+	}
+}
+
+// WithDispatchShadowUpstreamAddr returns an option that can set DispatchShadowUpstreamAddr on a Config
+func WithDispatchShadowUpstreamAddr(dispatchShadowUpstreamAddr string) ConfigOption {
+	return func(c *Config) {
+		c.DispatchShadowUpstreamAddr = dispatchShadowUpstreamAddr
+	}
+}
+
+// WithDispatchShadowConfig returns an option that can set DispatchShadowConfig on a Config
+func WithDispatchShadowConfig(dispatchShadowConfig shadow.Config) ConfigOption {
+	return func(c *Config) {
+		c.DispatchShadowConfig = dispatchShadowConfig
+	}
+}
+
 // WithDispatchClientMetricsEnabled returns an option that can set DispatchClientMetricsEnabled on a Config
 func WithDispatchClientMetricsEnabled(dispatchClientMetricsEnabled bool) ConfigOption {
 	return func(c *Config) {
@@ -288,6 +649,20 @@ func WithClusterDispatchCacheConfig(clusterDispatchCacheConfig CacheConfig) Conf
 	}
 }
 
+// WithDispatchCachePersistencePath returns an option that can set DispatchCachePersistencePath on a Config
+func WithDispatchCachePersistencePath(dispatchCachePersistencePath string) ConfigOption {
+	return func(c *Config) {
+		c.DispatchCachePersistencePath = dispatchCachePersistencePath
+	}
+}
+
+// WithDispatchCachePersistenceInterval returns an option that can set DispatchCachePersistenceInterval on a Config
+func WithDispatchCachePersistenceInterval(dispatchCachePersistenceInterval time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.DispatchCachePersistenceInterval = dispatchCachePersistenceInterval
+	}
+}
+
 // WithDisableV1SchemaAPI returns an option that can set DisableV1SchemaAPI on a Config
 func WithDisableV1SchemaAPI(disableV1SchemaAPI bool) ConfigOption {
 	return func(c *Config) {
@@ -316,6 +691,538 @@ func WithMaximumPreconditionCount(maximumPreconditionCount uint16) ConfigOption
 	}
 }
 
+// WithStrictRelationshipValidation returns an option that can set StrictRelationshipValidation on a Config
+func WithStrictRelationshipValidation(strictRelationshipValidation bool) ConfigOption {
+	return func(c *Config) {
+		c.StrictRelationshipValidation = strictRelationshipValidation
+	}
+}
+
+// WithAdminAPIEnabled returns an option that can set AdminAPIEnabled on a Config
+func WithAdminAPIEnabled(adminAPIEnabled bool) ConfigOption {
+	return func(c *Config) {
+		c.AdminAPIEnabled = adminAPIEnabled
+	}
+}
+
+// WithRelationshipRestoreWindow returns an option that can set RelationshipRestoreWindow on a Config
+func WithRelationshipRestoreWindow(relationshipRestoreWindow time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.RelationshipRestoreWindow = relationshipRestoreWindow
+	}
+}
+
+// WithRelationshipRestoreTokenKeys returns an option that can append RelationshipRestoreTokenKeyss to Config.RelationshipRestoreTokenKeys
+func WithRelationshipRestoreTokenKeys(relationshipRestoreTokenKeys string) ConfigOption {
+	return func(c *Config) {
+		c.RelationshipRestoreTokenKeys = append(c.RelationshipRestoreTokenKeys, relationshipRestoreTokenKeys)
+	}
+}
+
+// SetRelationshipRestoreTokenKeys returns an option that can set RelationshipRestoreTokenKeys on a Config
+func SetRelationshipRestoreTokenKeys(relationshipRestoreTokenKeys []string) ConfigOption {
+	return func(c *Config) {
+		c.RelationshipRestoreTokenKeys = relationshipRestoreTokenKeys
+	}
+}
+
+// WithRelationshipRestoreTokenKeyManager returns an option that can set RelationshipRestoreTokenKeyManager on a Config
+func WithRelationshipRestoreTokenKeyManager(relationshipRestoreTokenKeyManager secrets.KeyManager) ConfigOption {
+	return func(c *Config) {
+		c.RelationshipRestoreTokenKeyManager = relationshipRestoreTokenKeyManager
+	}
+}
+
+// WithRelationshipRestorePurgeInterval returns an option that can set RelationshipRestorePurgeInterval on a Config
+func WithRelationshipRestorePurgeInterval(relationshipRestorePurgeInterval time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.RelationshipRestorePurgeInterval = relationshipRestorePurgeInterval
+	}
+}
+
+// WithMaximumResultSize returns an option that can set MaximumResultSize on a Config
+func WithMaximumResultSize(maximumResultSize uint64) ConfigOption {
+	return func(c *Config) {
+		c.MaximumResultSize = maximumResultSize
+	}
+}
+
+// WithMaximumNestingDepth returns an option that can set MaximumNestingDepth on a Config
+func WithMaximumNestingDepth(maximumNestingDepth uint32) ConfigOption {
+	return func(c *Config) {
+		c.MaximumNestingDepth = maximumNestingDepth
+	}
+}
+
+// WithStaleCheckMaximumStaleness returns an option that can set StaleCheckMaximumStaleness on a Config
+func WithStaleCheckMaximumStaleness(staleCheckMaximumStaleness time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.StaleCheckMaximumStaleness = staleCheckMaximumStaleness
+	}
+}
+
+// WithTraceSampleRate returns an option that can set TraceSampleRate on a Config
+func WithTraceSampleRate(traceSampleRate float64) ConfigOption {
+	return func(c *Config) {
+		c.TraceSampleRate = traceSampleRate
+	}
+}
+
+// WithTraceStorePath returns an option that can set TraceStorePath on a Config
+func WithTraceStorePath(traceStorePath string) ConfigOption {
+	return func(c *Config) {
+		c.TraceStorePath = traceStorePath
+	}
+}
+
+// WithPlaygroundAPIEnabled returns an option that can set PlaygroundAPIEnabled on a Config
+func WithPlaygroundAPIEnabled(playgroundAPIEnabled bool) ConfigOption {
+	return func(c *Config) {
+		c.PlaygroundAPIEnabled = playgroundAPIEnabled
+	}
+}
+
+// WithPlaygroundShareStoreSalt returns an option that can set PlaygroundShareStoreSalt on a Config
+func WithPlaygroundShareStoreSalt(playgroundShareStoreSalt string) ConfigOption {
+	return func(c *Config) {
+		c.PlaygroundShareStoreSalt = playgroundShareStoreSalt
+	}
+}
+
+// WithPlaygroundShareStoreMaxBytes returns an option that can set PlaygroundShareStoreMaxBytes on a Config
+func WithPlaygroundShareStoreMaxBytes(playgroundShareStoreMaxBytes int) ConfigOption {
+	return func(c *Config) {
+		c.PlaygroundShareStoreMaxBytes = playgroundShareStoreMaxBytes
+	}
+}
+
+// WithOrphanScanInterval returns an option that can set OrphanScanInterval on a Config
+func WithOrphanScanInterval(orphanScanInterval time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.OrphanScanInterval = orphanScanInterval
+	}
+}
+
+// WithRelationshipExpirationInterval returns an option that can set RelationshipExpirationInterval on a Config
+func WithRelationshipExpirationInterval(relationshipExpirationInterval time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.RelationshipExpirationInterval = relationshipExpirationInterval
+	}
+}
+
+// WithRelationUsageAnalysisInterval returns an option that can set RelationUsageAnalysisInterval on a Config
+func WithRelationUsageAnalysisInterval(relationUsageAnalysisInterval time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.RelationUsageAnalysisInterval = relationUsageAnalysisInterval
+	}
+}
+
+// WithQuotaRelationshipsSoftLimit returns an option that can set QuotaRelationshipsSoftLimit on a Config
+func WithQuotaRelationshipsSoftLimit(quotaRelationshipsSoftLimit uint64) ConfigOption {
+	return func(c *Config) {
+		c.QuotaRelationshipsSoftLimit = quotaRelationshipsSoftLimit
+	}
+}
+
+// WithQuotaRelationshipsHardLimit returns an option that can set QuotaRelationshipsHardLimit on a Config
+func WithQuotaRelationshipsHardLimit(quotaRelationshipsHardLimit uint64) ConfigOption {
+	return func(c *Config) {
+		c.QuotaRelationshipsHardLimit = quotaRelationshipsHardLimit
+	}
+}
+
+// WithQuotaNamespacesSoftLimit returns an option that can set QuotaNamespacesSoftLimit on a Config
+func WithQuotaNamespacesSoftLimit(quotaNamespacesSoftLimit uint64) ConfigOption {
+	return func(c *Config) {
+		c.QuotaNamespacesSoftLimit = quotaNamespacesSoftLimit
+	}
+}
+
+// WithQuotaNamespacesHardLimit returns an option that can set QuotaNamespacesHardLimit on a Config
+func WithQuotaNamespacesHardLimit(quotaNamespacesHardLimit uint64) ConfigOption {
+	return func(c *Config) {
+		c.QuotaNamespacesHardLimit = quotaNamespacesHardLimit
+	}
+}
+
+// WithMaterializedPermissions returns an option that can append MaterializedPermissionss to Config.MaterializedPermissions
+func WithMaterializedPermissions(materializedPermissions string) ConfigOption {
+	return func(c *Config) {
+		c.MaterializedPermissions = append(c.MaterializedPermissions, materializedPermissions)
+	}
+}
+
+// SetMaterializedPermissions returns an option that can set MaterializedPermissions on a Config
+func SetMaterializedPermissions(materializedPermissions []string) ConfigOption {
+	return func(c *Config) {
+		c.MaterializedPermissions = materializedPermissions
+	}
+}
+
+// WithPointInTimeCheckEnabled returns an option that can set PointInTimeCheckEnabled on a Config
+func WithPointInTimeCheckEnabled(pointInTimeCheckEnabled bool) ConfigOption {
+	return func(c *Config) {
+		c.PointInTimeCheckEnabled = pointInTimeCheckEnabled
+	}
+}
+
+// WithArchiveDirectory returns an option that can set ArchiveDirectory on a Config
+func WithArchiveDirectory(archiveDirectory string) ConfigOption {
+	return func(c *Config) {
+		c.ArchiveDirectory = archiveDirectory
+	}
+}
+
+// WithArchiveRecordingEnabled returns an option that can set ArchiveRecordingEnabled on a Config
+func WithArchiveRecordingEnabled(archiveRecordingEnabled bool) ConfigOption {
+	return func(c *Config) {
+		c.ArchiveRecordingEnabled = archiveRecordingEnabled
+	}
+}
+
+// WithArchiveSnapshotInterval returns an option that can set ArchiveSnapshotInterval on a Config
+func WithArchiveSnapshotInterval(archiveSnapshotInterval time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.ArchiveSnapshotInterval = archiveSnapshotInterval
+	}
+}
+
+// WithSchemaDriftCheckInterval returns an option that can set SchemaDriftCheckInterval on a Config
+func WithSchemaDriftCheckInterval(schemaDriftCheckInterval time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.SchemaDriftCheckInterval = schemaDriftCheckInterval
+	}
+}
+
+// WithRevisionHeartbeatInterval returns an option that can set RevisionHeartbeatInterval on a Config
+func WithRevisionHeartbeatInterval(revisionHeartbeatInterval time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.RevisionHeartbeatInterval = revisionHeartbeatInterval
+	}
+}
+
+// WithRelationCountInterval returns an option that can set RelationCountInterval on a Config
+func WithRelationCountInterval(relationCountInterval time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.RelationCountInterval = relationCountInterval
+	}
+}
+
+// WithRelationshipGrowthAlertRate returns an option that can set RelationshipGrowthAlertRate on a Config
+func WithRelationshipGrowthAlertRate(relationshipGrowthAlertRate float64) ConfigOption {
+	return func(c *Config) {
+		c.RelationshipGrowthAlertRate = relationshipGrowthAlertRate
+	}
+}
+
+// WithRelationshipGrowthAlertFunc returns an option that can set RelationshipGrowthAlertFunc on a Config
+func WithRelationshipGrowthAlertFunc(relationshipGrowthAlertFunc func(common.RelationshipGrowth)) ConfigOption {
+	return func(c *Config) {
+		c.RelationshipGrowthAlertFunc = relationshipGrowthAlertFunc
+	}
+}
+
+// WithLeaderElectionEnabled returns an option that can set LeaderElectionEnabled on a Config
+func WithLeaderElectionEnabled(leaderElectionEnabled bool) ConfigOption {
+	return func(c *Config) {
+		c.LeaderElectionEnabled = leaderElectionEnabled
+	}
+}
+
+// WithLeaderLeaseDuration returns an option that can set LeaderLeaseDuration on a Config
+func WithLeaderLeaseDuration(leaderLeaseDuration time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.LeaderLeaseDuration = leaderLeaseDuration
+	}
+}
+
+// WithJobsEnabled returns an option that can set JobsEnabled on a Config
+func WithJobsEnabled(jobsEnabled bool) ConfigOption {
+	return func(c *Config) {
+		c.JobsEnabled = jobsEnabled
+	}
+}
+
+// WithJobLeaseDuration returns an option that can set JobLeaseDuration on a Config
+func WithJobLeaseDuration(jobLeaseDuration time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.JobLeaseDuration = jobLeaseDuration
+	}
+}
+
+// WithJobPollInterval returns an option that can set JobPollInterval on a Config
+func WithJobPollInterval(jobPollInterval time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.JobPollInterval = jobPollInterval
+	}
+}
+
+// WithLDAPSyncInterval returns an option that can set LDAPSyncInterval on a Config
+func WithLDAPSyncInterval(lDAPSyncInterval time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.LDAPSyncInterval = lDAPSyncInterval
+	}
+}
+
+// WithLDAPSyncMappingFile returns an option that can set LDAPSyncMappingFile on a Config
+func WithLDAPSyncMappingFile(lDAPSyncMappingFile string) ConfigOption {
+	return func(c *Config) {
+		c.LDAPSyncMappingFile = lDAPSyncMappingFile
+	}
+}
+
+// WithLDAPSyncURL returns an option that can set LDAPSyncURL on a Config
+func WithLDAPSyncURL(lDAPSyncURL string) ConfigOption {
+	return func(c *Config) {
+		c.LDAPSyncURL = lDAPSyncURL
+	}
+}
+
+// WithLDAPSyncBindDN returns an option that can set LDAPSyncBindDN on a Config
+func WithLDAPSyncBindDN(lDAPSyncBindDN string) ConfigOption {
+	return func(c *Config) {
+		c.LDAPSyncBindDN = lDAPSyncBindDN
+	}
+}
+
+// WithLDAPSyncBindPassword returns an option that can set LDAPSyncBindPassword on a Config
+func WithLDAPSyncBindPassword(lDAPSyncBindPassword string) ConfigOption {
+	return func(c *Config) {
+		c.LDAPSyncBindPassword = lDAPSyncBindPassword
+	}
+}
+
+// WithLDAPSyncDryRun returns an option that can set LDAPSyncDryRun on a Config
+func WithLDAPSyncDryRun(lDAPSyncDryRun bool) ConfigOption {
+	return func(c *Config) {
+		c.LDAPSyncDryRun = lDAPSyncDryRun
+	}
+}
+
+// WithExtAuthzServer returns an option that can set ExtAuthzServer on a Config
+func WithExtAuthzServer(extAuthzServer util.GRPCServerConfig) ConfigOption {
+	return func(c *Config) {
+		c.ExtAuthzServer = extAuthzServer
+	}
+}
+
+// WithExtAuthzMappingFile returns an option that can set ExtAuthzMappingFile on a Config
+func WithExtAuthzMappingFile(extAuthzMappingFile string) ConfigOption {
+	return func(c *Config) {
+		c.ExtAuthzMappingFile = extAuthzMappingFile
+	}
+}
+
+// WithKubeAuthzServer returns an option that can set KubeAuthzServer on a Config
+func WithKubeAuthzServer(kubeAuthzServer util.HTTPServerConfig) ConfigOption {
+	return func(c *Config) {
+		c.KubeAuthzServer = kubeAuthzServer
+	}
+}
+
+// WithKubeAuthzBearerToken returns an option that can append KubeAuthzBearerTokens to Config.KubeAuthzBearerToken
+func WithKubeAuthzBearerToken(kubeAuthzBearerToken string) ConfigOption {
+	return func(c *Config) {
+		c.KubeAuthzBearerToken = append(c.KubeAuthzBearerToken, kubeAuthzBearerToken)
+	}
+}
+
+// SetKubeAuthzBearerToken returns an option that can set KubeAuthzBearerToken on a Config
+func SetKubeAuthzBearerToken(kubeAuthzBearerToken []string) ConfigOption {
+	return func(c *Config) {
+		c.KubeAuthzBearerToken = kubeAuthzBearerToken
+	}
+}
+
+// WithKubeAuthzNamespaceType returns an option that can set KubeAuthzNamespaceType on a Config
+func WithKubeAuthzNamespaceType(kubeAuthzNamespaceType string) ConfigOption {
+	return func(c *Config) {
+		c.KubeAuthzNamespaceType = kubeAuthzNamespaceType
+	}
+}
+
+// WithKubeAuthzClusterType returns an option that can set KubeAuthzClusterType on a Config
+func WithKubeAuthzClusterType(kubeAuthzClusterType string) ConfigOption {
+	return func(c *Config) {
+		c.KubeAuthzClusterType = kubeAuthzClusterType
+	}
+}
+
+// WithKubeAuthzClusterID returns an option that can set KubeAuthzClusterID on a Config
+func WithKubeAuthzClusterID(kubeAuthzClusterID string) ConfigOption {
+	return func(c *Config) {
+		c.KubeAuthzClusterID = kubeAuthzClusterID
+	}
+}
+
+// WithKubeAuthzUserType returns an option that can set KubeAuthzUserType on a Config
+func WithKubeAuthzUserType(kubeAuthzUserType string) ConfigOption {
+	return func(c *Config) {
+		c.KubeAuthzUserType = kubeAuthzUserType
+	}
+}
+
+// WithKubeAuthzGroupType returns an option that can set KubeAuthzGroupType on a Config
+func WithKubeAuthzGroupType(kubeAuthzGroupType string) ConfigOption {
+	return func(c *Config) {
+		c.KubeAuthzGroupType = kubeAuthzGroupType
+	}
+}
+
+// WithKubeAuthzGroupRelation returns an option that can set KubeAuthzGroupRelation on a Config
+func WithKubeAuthzGroupRelation(kubeAuthzGroupRelation string) ConfigOption {
+	return func(c *Config) {
+		c.KubeAuthzGroupRelation = kubeAuthzGroupRelation
+	}
+}
+
+// WithKubeAuthzDeny returns an option that can set KubeAuthzDeny on a Config
+func WithKubeAuthzDeny(kubeAuthzDeny bool) ConfigOption {
+	return func(c *Config) {
+		c.KubeAuthzDeny = kubeAuthzDeny
+	}
+}
+
+// WithSCIMServer returns an option that can set SCIMServer on a Config
+func WithSCIMServer(sCIMServer util.HTTPServerConfig) ConfigOption {
+	return func(c *Config) {
+		c.SCIMServer = sCIMServer
+	}
+}
+
+// WithSCIMBearerToken returns an option that can append SCIMBearerTokens to Config.SCIMBearerToken
+func WithSCIMBearerToken(sCIMBearerToken string) ConfigOption {
+	return func(c *Config) {
+		c.SCIMBearerToken = append(c.SCIMBearerToken, sCIMBearerToken)
+	}
+}
+
+// SetSCIMBearerToken returns an option that can set SCIMBearerToken on a Config
+func SetSCIMBearerToken(sCIMBearerToken []string) ConfigOption {
+	return func(c *Config) {
+		c.SCIMBearerToken = sCIMBearerToken
+	}
+}
+
+// WithSCIMGroupType returns an option that can set SCIMGroupType on a Config
+func WithSCIMGroupType(sCIMGroupType string) ConfigOption {
+	return func(c *Config) {
+		c.SCIMGroupType = sCIMGroupType
+	}
+}
+
+// WithSCIMMemberRelation returns an option that can set SCIMMemberRelation on a Config
+func WithSCIMMemberRelation(sCIMMemberRelation string) ConfigOption {
+	return func(c *Config) {
+		c.SCIMMemberRelation = sCIMMemberRelation
+	}
+}
+
+// WithSCIMUserType returns an option that can set SCIMUserType on a Config
+func WithSCIMUserType(sCIMUserType string) ConfigOption {
+	return func(c *Config) {
+		c.SCIMUserType = sCIMUserType
+	}
+}
+
+// WithSCIMDryRun returns an option that can set SCIMDryRun on a Config
+func WithSCIMDryRun(sCIMDryRun bool) ConfigOption {
+	return func(c *Config) {
+		c.SCIMDryRun = sCIMDryRun
+	}
+}
+
+// WithDecisionLogEnabled returns an option that can set DecisionLogEnabled on a Config
+func WithDecisionLogEnabled(decisionLogEnabled bool) ConfigOption {
+	return func(c *Config) {
+		c.DecisionLogEnabled = decisionLogEnabled
+	}
+}
+
+// WithDecisionLogConfig returns an option that can set DecisionLogConfig on a Config
+func WithDecisionLogConfig(decisionLogConfig decisionlog.Config) ConfigOption {
+	return func(c *Config) {
+		c.DecisionLogConfig = decisionLogConfig
+	}
+}
+
+// WithDecisionLogS3Bucket returns an option that can set DecisionLogS3Bucket on a Config
+func WithDecisionLogS3Bucket(decisionLogS3Bucket string) ConfigOption {
+	return func(c *Config) {
+		c.DecisionLogS3Bucket = decisionLogS3Bucket
+	}
+}
+
+// WithDecisionLogS3Prefix returns an option that can set DecisionLogS3Prefix on a Config
+func WithDecisionLogS3Prefix(decisionLogS3Prefix string) ConfigOption {
+	return func(c *Config) {
+		c.DecisionLogS3Prefix = decisionLogS3Prefix
+	}
+}
+
+// WithDecisionLogS3Endpoint returns an option that can set DecisionLogS3Endpoint on a Config
+func WithDecisionLogS3Endpoint(decisionLogS3Endpoint string) ConfigOption {
+	return func(c *Config) {
+		c.DecisionLogS3Endpoint = decisionLogS3Endpoint
+	}
+}
+
+// WithDecisionLogS3Region returns an option that can set DecisionLogS3Region on a Config
+func WithDecisionLogS3Region(decisionLogS3Region string) ConfigOption {
+	return func(c *Config) {
+		c.DecisionLogS3Region = decisionLogS3Region
+	}
+}
+
+// WithDecisionLogS3AccessKey returns an option that can set DecisionLogS3AccessKey on a Config
+func WithDecisionLogS3AccessKey(decisionLogS3AccessKey string) ConfigOption {
+	return func(c *Config) {
+		c.DecisionLogS3AccessKey = decisionLogS3AccessKey
+	}
+}
+
+// WithDecisionLogS3SecretKey returns an option that can set DecisionLogS3SecretKey on a Config
+func WithDecisionLogS3SecretKey(decisionLogS3SecretKey string) ConfigOption {
+	return func(c *Config) {
+		c.DecisionLogS3SecretKey = decisionLogS3SecretKey
+	}
+}
+
+// WithWarmupConfig returns an option that can set WarmupConfig on a Config
+func WithWarmupConfig(warmupConfig warmup.Config) ConfigOption {
+	return func(c *Config) {
+		c.WarmupConfig = warmupConfig
+	}
+}
+
+// WithCaptureConfig returns an option that can set CaptureConfig on a Config
+func WithCaptureConfig(captureConfig capture.Config) ConfigOption {
+	return func(c *Config) {
+		c.CaptureConfig = captureConfig
+	}
+}
+
+// WithValidationWebhookConfig returns an option that can set ValidationWebhookConfig on a Config
+func WithValidationWebhookConfig(validationWebhookConfig validationwebhook.Config) ConfigOption {
+	return func(c *Config) {
+		c.ValidationWebhookConfig = validationWebhookConfig
+	}
+}
+
+// WithMemoryManagerEnabled returns an option that can set MemoryManagerEnabled on a Config
+func WithMemoryManagerEnabled(memoryManagerEnabled bool) ConfigOption {
+	return func(c *Config) {
+		c.MemoryManagerEnabled = memoryManagerEnabled
+	}
+}
+
+// WithMemoryConfig returns an option that can set MemoryConfig on a Config
+func WithMemoryConfig(memoryConfig memory.Config) ConfigOption {
+	return func(c *Config) {
+		c.MemoryConfig = memoryConfig
+	}
+}
+
 // WithDashboardAPI returns an option that can set DashboardAPI on a Config
 func WithDashboardAPI(dashboardAPI util.HTTPServerConfig) ConfigOption {
 	return func(c *Config) {
@@ -330,6 +1237,20 @@ func WithMetricsAPI(metricsAPI util.HTTPServerConfig) ConfigOption {
 	}
 }
 
+// WithTopologyEnabled returns an option that can set TopologyEnabled on a Config
+func WithTopologyEnabled(topologyEnabled bool) ConfigOption {
+	return func(c *Config) {
+		c.TopologyEnabled = topologyEnabled
+	}
+}
+
+// WithTopologyAdvertisedAPIAddress returns an option that can set TopologyAdvertisedAPIAddress on a Config
+func WithTopologyAdvertisedAPIAddress(topologyAdvertisedAPIAddress string) ConfigOption {
+	return func(c *Config) {
+		c.TopologyAdvertisedAPIAddress = topologyAdvertisedAPIAddress
+	}
+}
+
 // WithMiddlewareModification returns an option that can append MiddlewareModifications to Config.MiddlewareModification
 func WithMiddlewareModification(middlewareModification MiddlewareModification) ConfigOption {
 	return func(c *Config) {
@@ -399,3 +1320,45 @@ func WithTelemetryInterval(telemetryInterval time.Duration) ConfigOption {
 		c.TelemetryInterval = telemetryInterval
 	}
 }
+
+// WithRedactionPolicy returns an option that can set RedactionPolicy on a Config
+func WithRedactionPolicy(redactionPolicy redaction.Policy) ConfigOption {
+	return func(c *Config) {
+		c.RedactionPolicy = redactionPolicy
+	}
+}
+
+// WithEnabledExperiments returns an option that can append EnabledExperimentss to Config.EnabledExperiments
+func WithEnabledExperiments(enabledExperiments string) ConfigOption {
+	return func(c *Config) {
+		c.EnabledExperiments = append(c.EnabledExperiments, enabledExperiments)
+	}
+}
+
+// SetEnabledExperiments returns an option that can set EnabledExperiments on a Config
+func SetEnabledExperiments(enabledExperiments []string) ConfigOption {
+	return func(c *Config) {
+		c.EnabledExperiments = enabledExperiments
+	}
+}
+
+// WithDisabledExperiments returns an option that can append DisabledExperimentss to Config.DisabledExperiments
+func WithDisabledExperiments(disabledExperiments string) ConfigOption {
+	return func(c *Config) {
+		c.DisabledExperiments = append(c.DisabledExperiments, disabledExperiments)
+	}
+}
+
+// SetDisabledExperiments returns an option that can set DisabledExperiments on a Config
+func SetDisabledExperiments(disabledExperiments []string) ConfigOption {
+	return func(c *Config) {
+		c.DisabledExperiments = disabledExperiments
+	}
+}
+
+// WithTemplatesFile returns an option that can set TemplatesFile on a Config
+func WithTemplatesFile(templatesFile string) ConfigOption {
+	return func(c *Config) {
+		c.TemplatesFile = templatesFile
+	}
+}
@@ -61,6 +61,7 @@ func (c *Config) Complete() (RunnableTestServer, error) {
 			dispatcher,
 			services.V1SchemaServiceEnabled,
 			services.WatchServiceEnabled,
+			services.AdminServiceDisabled,
 			v1svc.PermissionsServerConfig{
 				MaxPreconditionsCount: c.MaximumPreconditionCount,
 				MaxUpdatesPerWrite:    c.MaximumUpdatesPerWrite,
@@ -0,0 +1,7 @@
+//go:build !boringcrypto
+
+package util
+
+// FIPSBuild is true if the binary was built with GOEXPERIMENT=boringcrypto, whose cryptography
+// is provided by a FIPS 140 validated module.
+const FIPSBuild = false
@@ -0,0 +1,10 @@
+//go:build boringcrypto
+
+package util
+
+// Restrict TLS to the settings approved under FIPS 140 across the whole binary.
+import _ "crypto/tls/fipsonly"
+
+// FIPSBuild is true if the binary was built with GOEXPERIMENT=boringcrypto, whose cryptography
+// is provided by a FIPS 140 validated module.
+const FIPSBuild = true
@@ -0,0 +1,60 @@
+package util
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+)
+
+// listenUnixWithMode listens on the unix socket at the address with the mode. The socket is
+// created in a private directory beside the address, where no one else can reach it until its
+// mode has been set, and is then moved to the address.
+func listenUnixWithMode(network, address string, mode os.FileMode) (net.Listener, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(address), ".sock")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a private directory for socket %s: %w", address, err)
+	}
+	defer os.RemoveAll(dir)
+
+	private := filepath.Join(dir, filepath.Base(address))
+	l, err := net.Listen(network, private)
+	if err != nil {
+		return nil, err
+	}
+
+	// The listener would remove the socket from where it was created, rather than from the address.
+	if ul, ok := l.(*net.UnixListener); ok {
+		ul.SetUnlinkOnClose(false)
+	}
+	movedListener := &movedUnixListener{Listener: l}
+
+	if err := os.Chmod(private, mode); err != nil {
+		movedListener.Close()
+		return nil, fmt.Errorf("failed to set the mode of socket %s: %w", address, err)
+	}
+	if err := os.Rename(private, address); err != nil {
+		movedListener.Close()
+		return nil, fmt.Errorf("failed to move socket %s into place: %w", address, err)
+	}
+
+	movedListener.address = address
+	return movedListener, nil
+}
+
+// movedUnixListener is a listener on a unix socket which has been moved to the address, from
+// which it is removed when the listener is closed.
+type movedUnixListener struct {
+	net.Listener
+	address string
+}
+
+func (l *movedUnixListener) Close() error {
+	err := l.Listener.Close()
+	if l.address != "" {
+		if removeErr := os.Remove(l.address); removeErr != nil && !os.IsNotExist(removeErr) && err == nil {
+			err = removeErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,219 @@
+package util
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// fipsCipherSuites are the TLS 1.2 cipher suites approved for use under FIPS 140.
+var fipsCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+}
+
+// fipsCurves are the key exchange curves approved for use under FIPS 140.
+var fipsCurves = []tls.CurveID{tls.CurveP256, tls.CurveP384}
+
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var tlsVersionNames = map[uint16]string{
+	tls.VersionTLS10: "TLS 1.0",
+	tls.VersionTLS11: "TLS 1.1",
+	tls.VersionTLS12: "TLS 1.2",
+	tls.VersionTLS13: "TLS 1.3",
+}
+
+func tlsVersionName(version uint16) string {
+	if name, ok := tlsVersionNames[version]; ok {
+		return name
+	}
+	return fmt.Sprintf("TLS version 0x%04X", version)
+}
+
+// TLSPolicy restricts the TLS negotiated by the listeners of the server. The zero policy
+// requires TLS 1.2 or later, with the default cipher suites of Go.
+type TLSPolicy struct {
+	// MinVersion is the minimum version of TLS, "1.2" or "1.3".
+	MinVersion string
+
+	// CipherSuites are the names of the TLS 1.2 cipher suites allowed, such as
+	// TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256. The cipher suites of TLS 1.3 are not configurable.
+	CipherSuites []string
+
+	// FIPSMode restricts TLS to the cipher suites and curves approved under FIPS 140, and
+	// requires a FIPS build. The mode only restricts the configuration of TLS: compliance comes
+	// from building SpiceDB with GOEXPERIMENT=boringcrypto, which provides the validated module.
+	FIPSMode bool
+}
+
+// IsDefault returns true if the policy places no restrictions beyond those of the zero policy.
+func (p TLSPolicy) IsDefault() bool {
+	return (p.MinVersion == "" || p.MinVersion == "1.2") && len(p.CipherSuites) == 0 && !p.FIPSMode
+}
+
+// Validate returns an error if the policy is invalid, or cannot be enforced by this build.
+func (p TLSPolicy) Validate() error {
+	if p.FIPSMode && !FIPSBuild {
+		return errors.New("FIPS mode requires a FIPS build of SpiceDB, built with GOEXPERIMENT=boringcrypto")
+	}
+	return p.Apply(&tls.Config{})
+}
+
+// Apply applies the policy to the TLS configuration.
+func (p TLSPolicy) Apply(config *tls.Config) error {
+	config.MinVersion = tls.VersionTLS12
+	if p.MinVersion != "" {
+		version, ok := tlsVersions[p.MinVersion]
+		if !ok {
+			return fmt.Errorf("unsupported minimum TLS version `%s`: must be one of 1.2 or 1.3", p.MinVersion)
+		}
+		config.MinVersion = version
+	}
+
+	if len(p.CipherSuites) > 0 {
+		suites, err := cipherSuitesByName(p.CipherSuites)
+		if err != nil {
+			return err
+		}
+		config.CipherSuites = suites
+	}
+
+	if p.FIPSMode {
+		if config.CipherSuites == nil {
+			config.CipherSuites = fipsCipherSuites
+		}
+		for _, suite := range config.CipherSuites {
+			if !slices.Contains(fipsCipherSuites, suite) {
+				return fmt.Errorf("cipher suite `%s` is not approved under FIPS 140", tls.CipherSuiteName(suite))
+			}
+		}
+		config.CurvePreferences = fipsCurves
+	}
+	return nil
+}
+
+func cipherSuitesByName(names []string) ([]uint16, error) {
+	byName := make(map[string]*tls.CipherSuite)
+	for _, suite := range tls.CipherSuites() {
+		byName[suite.Name] = suite
+	}
+
+	suites := make([]uint16, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		suite, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite `%s`", name)
+		}
+		if !slices.Contains(suite.SupportedVersions, tls.VersionTLS12) {
+			return nil, fmt.Errorf("cipher suite `%s` is a TLS 1.3 cipher suite, which cannot be configured", name)
+		}
+		suites = append(suites, suite.ID)
+	}
+	return suites, nil
+}
+
+// SelfTest verifies that TLS connections can be established under the policy, and negotiate a
+// version and cipher suite which it allows, by a handshake over an in-memory connection with an
+// ephemeral certificate. It returns a description of the connection negotiated.
+func (p TLSPolicy) SelfTest() (string, error) {
+	if err := p.Validate(); err != nil {
+		return "", err
+	}
+
+	cert, err := selfTestCertificate()
+	if err != nil {
+		return "", fmt.Errorf("unable to create the self test certificate: %w", err)
+	}
+
+	serverConfig := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if err := p.Apply(serverConfig); err != nil {
+		return "", err
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert.Leaf)
+	clientConfig := &tls.Config{RootCAs: pool, ServerName: "localhost"}
+	if err := p.Apply(clientConfig); err != nil {
+		return "", err
+	}
+
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	defer clientConn.Close()
+
+	deadline := time.Now().Add(10 * time.Second)
+	_ = serverConn.SetDeadline(deadline)
+	_ = clientConn.SetDeadline(deadline)
+
+	server := tls.Server(serverConn, serverConfig)
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.Handshake()
+	}()
+
+	client := tls.Client(clientConn, clientConfig)
+	if err := client.Handshake(); err != nil {
+		return "", fmt.Errorf("TLS handshake failed: %w", err)
+	}
+	if err := <-serverErr; err != nil {
+		return "", fmt.Errorf("TLS handshake failed: %w", err)
+	}
+
+	state := server.ConnectionState()
+	if state.Version < serverConfig.MinVersion {
+		return "", fmt.Errorf("negotiated %s, below the minimum version", tlsVersionName(state.Version))
+	}
+	if state.Version == tls.VersionTLS12 && serverConfig.CipherSuites != nil && !slices.Contains(serverConfig.CipherSuites, state.CipherSuite) {
+		return "", fmt.Errorf("negotiated cipher suite %s, which is not allowed", tls.CipherSuiteName(state.CipherSuite))
+	}
+	return fmt.Sprintf("negotiated %s with %s", tlsVersionName(state.Version), tls.CipherSuiteName(state.CipherSuite)), nil
+}
+
+func selfTestCertificate() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "spicedb-tls-self-test"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, nil
+}
@@ -0,0 +1,67 @@
+package util
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTLSPolicyApply(t *testing.T) {
+	config := &tls.Config{}
+	require.NoError(t, TLSPolicy{}.Apply(config))
+	require.Equal(t, uint16(tls.VersionTLS12), config.MinVersion)
+	require.Nil(t, config.CipherSuites)
+
+	config = &tls.Config{}
+	require.NoError(t, TLSPolicy{
+		MinVersion:   "1.3",
+		CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", " TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256"},
+	}.Apply(config))
+	require.Equal(t, uint16(tls.VersionTLS13), config.MinVersion)
+	require.Equal(t, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256}, config.CipherSuites)
+
+	config = &tls.Config{}
+	require.NoError(t, TLSPolicy{FIPSMode: true}.Apply(config))
+	require.Equal(t, fipsCipherSuites, config.CipherSuites)
+	require.Equal(t, fipsCurves, config.CurvePreferences)
+
+	for _, tc := range []struct {
+		name   string
+		policy TLSPolicy
+	}{
+		{"unsupported version", TLSPolicy{MinVersion: "1.1"}},
+		{"unknown cipher suite", TLSPolicy{CipherSuites: []string{"TLS_UNKNOWN"}}},
+		{"insecure cipher suite", TLSPolicy{CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}}},
+		{"TLS 1.3 cipher suite", TLSPolicy{CipherSuites: []string{"TLS_AES_128_GCM_SHA256"}}},
+		{"cipher suite not approved for FIPS", TLSPolicy{FIPSMode: true, CipherSuites: []string{"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256"}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Error(t, tc.policy.Apply(&tls.Config{}))
+		})
+	}
+}
+
+func TestTLSPolicyValidate(t *testing.T) {
+	require.NoError(t, TLSPolicy{MinVersion: "1.3"}.Validate())
+
+	err := TLSPolicy{FIPSMode: true}.Validate()
+	if FIPSBuild {
+		require.NoError(t, err)
+	} else {
+		require.ErrorContains(t, err, "requires a FIPS build")
+	}
+}
+
+func TestTLSPolicySelfTest(t *testing.T) {
+	negotiated, err := TLSPolicy{}.SelfTest()
+	require.NoError(t, err)
+	require.Contains(t, negotiated, "TLS 1.3")
+
+	negotiated, err = TLSPolicy{CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"}}.SelfTest()
+	require.NoError(t, err)
+	require.Contains(t, negotiated, "TLS 1.3")
+
+	_, err = TLSPolicy{MinVersion: "1.0"}.SelfTest()
+	require.Error(t, err)
+}
@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"os"
+	"strconv"
 	"time"
 
 	"github.com/jzelinskie/stringz"
@@ -30,6 +32,9 @@ import (
 	"github.com/authzed/spicedb/pkg/x509util"
 )
 
+// BufferedNetwork is the network of a gRPC server which listens in memory, rather than on a
+// network, such as for a server embedded in another process or in hermetic tests. It can only be
+// connected to via the DialContext and NetDialContext of the server.
 const BufferedNetwork string = "buffnet"
 
 type GRPCServerConfig struct {
@@ -43,6 +48,14 @@ type GRPCServerConfig struct {
 	ClientCAPath string
 	MaxWorkers   uint32
 
+	// UnixSocketMode is the file mode, in octal, set on the socket when serving on a unix
+	// network, such as 0660 to allow connections from the group of the server. Empty leaves the
+	// mode given by the umask.
+	UnixSocketMode string
+
+	// TLSPolicy restricts the TLS negotiated by the server, when it is served with TLS.
+	TLSPolicy TLSPolicy
+
 	flagPrefix string
 }
 
@@ -59,7 +72,8 @@ func RegisterGRPCServerFlags(flags *pflag.FlagSet, config *GRPCServerConfig, fla
 	config.flagPrefix = flagPrefix
 
 	flags.StringVar(&config.Address, flagPrefix+"-addr", defaultAddr, "address to listen on to serve "+serviceName)
-	flags.StringVar(&config.Network, flagPrefix+"-network", "tcp", "network type to serve "+serviceName+` ("tcp", "tcp4", "tcp6", "unix", "unixpacket")`)
+	flags.StringVar(&config.Network, flagPrefix+"-network", "tcp", "network type to serve "+serviceName+` ("tcp", "tcp4", "tcp6", "unix", "unixpacket"); for unix networks, the address is the path of the socket`)
+	flags.StringVar(&config.UnixSocketMode, flagPrefix+"-unix-socket-mode", "", "file mode, in octal, of the socket serving "+serviceName+" on a unix network, such as 0660. defaults to the mode given by the umask")
 	flags.StringVar(&config.TLSCertPath, flagPrefix+"-tls-cert-path", "", "local path to the TLS certificate used to serve "+serviceName)
 	flags.StringVar(&config.TLSKeyPath, flagPrefix+"-tls-key-path", "", "local path to the TLS key used to serve "+serviceName)
 	flags.DurationVar(&config.MaxConnAge, flagPrefix+"-max-conn-age", 30*time.Second, "how long a connection serving "+serviceName+" should be able to live")
@@ -144,13 +158,72 @@ func (c *GRPCServerConfig) listenerAndDialer() (net.Listener, DialFunc, NetDialF
 				return bl.DialContext(ctx)
 			}, nil
 	}
+
+	netDial := func(ctx context.Context, _ string) (net.Conn, error) {
+		var dialer net.Dialer
+		return dialer.DialContext(ctx, c.Network, c.Address)
+	}
+
+	if isUnixNetwork(c.Network) {
+		l, err := c.listenUnix()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return l, func(ctx context.Context, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+			opts = append(opts, grpc.WithContextDialer(netDial))
+			return grpc.DialContext(ctx, "passthrough:///"+c.Address, opts...)
+		}, netDial, nil
+	}
+
 	l, err := net.Listen(c.Network, c.Address)
 	if err != nil {
 		return nil, nil, nil, err
 	}
 	return l, func(ctx context.Context, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
 		return grpc.DialContext(ctx, c.Address, opts...)
-	}, nil, nil
+	}, netDial, nil
+}
+
+// listenUnix listens on the unix socket at the address, replacing any socket left behind by a
+// server which did not stop cleanly. The socket is removed when the listener is closed.
+func (c *GRPCServerConfig) listenUnix() (net.Listener, error) {
+	var mode os.FileMode
+	if c.UnixSocketMode != "" {
+		parsed, err := strconv.ParseUint(c.UnixSocketMode, 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid unix socket mode `%s`: %w", c.UnixSocketMode, err)
+		}
+		mode = os.FileMode(parsed)
+	}
+
+	if info, err := os.Stat(c.Address); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("cannot listen on %s: the file exists and is not a socket", c.Address)
+		}
+
+		// A socket which accepts connections is in use by another server.
+		if conn, err := net.DialTimeout(c.Network, c.Address, time.Second); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("cannot listen on %s: the socket is in use", c.Address)
+		}
+		if err := os.Remove(c.Address); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket %s: %w", c.Address, err)
+		}
+	}
+
+	if mode != 0 {
+		return listenUnixWithMode(c.Network, c.Address, mode)
+	}
+	return net.Listen(c.Network, c.Address)
+}
+
+// IsUnixNetwork returns true if the gRPC server is served on a unix socket.
+func (c *GRPCServerConfig) IsUnixNetwork() bool {
+	return isUnixNetwork(c.Network)
+}
+
+func isUnixNetwork(network string) bool {
+	return network == "unix" || network == "unixpacket"
 }
 
 func (c *GRPCServerConfig) tlsOpts() ([]grpc.ServerOption, *certwatcher.CertWatcher, error) {
@@ -162,11 +235,11 @@ func (c *GRPCServerConfig) tlsOpts() ([]grpc.ServerOption, *certwatcher.CertWatc
 		if err != nil {
 			return nil, nil, err
 		}
-		creds := credentials.NewTLS(&tls.Config{
-			GetCertificate: watcher.GetCertificate,
-			MinVersion:     tls.VersionTLS12,
-		})
-		return []grpc.ServerOption{grpc.Creds(creds)}, watcher, nil
+		tlsConfig := &tls.Config{GetCertificate: watcher.GetCertificate}
+		if err := c.TLSPolicy.Apply(tlsConfig); err != nil {
+			return nil, nil, err
+		}
+		return []grpc.ServerOption{grpc.Creds(credentials.NewTLS(tlsConfig))}, watcher, nil
 	default:
 		return nil, nil, nil
 	}
@@ -188,7 +261,11 @@ func (c *GRPCServerConfig) clientCreds() (credentials.TransportCredentials, erro
 			return nil, err
 		}
 
-		return credentials.NewTLS(&tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}), nil
+		tlsConfig := &tls.Config{RootCAs: pool}
+		if err := c.TLSPolicy.Apply(tlsConfig); err != nil {
+			return nil, err
+		}
+		return credentials.NewTLS(tlsConfig), nil
 	default:
 		return nil, nil
 	}
@@ -300,6 +377,9 @@ type HTTPServerConfig struct {
 	TLSKeyPath  string
 	Enabled     bool
 
+	// TLSPolicy restricts the TLS negotiated by the server, when it is served with TLS.
+	TLSPolicy TLSPolicy
+
 	flagPrefix string
 }
 
@@ -313,6 +393,7 @@ func (c *HTTPServerConfig) Complete(level zerolog.Level, handler http.Handler) (
 		ReadHeaderTimeout: 5 * time.Second,
 	}
 	var serveFunc func() error
+	stopWatching := func() {}
 	switch {
 	case c.TLSCertPath == "" && c.TLSKeyPath == "":
 		serveFunc = func() error {
@@ -330,14 +411,26 @@ func (c *HTTPServerConfig) Complete(level zerolog.Level, handler http.Handler) (
 			return nil, err
 		}
 
-		listener, err := tls.Listen("tcp", srv.Addr, &tls.Config{
-			GetCertificate: watcher.GetCertificate,
-			MinVersion:     tls.VersionTLS12,
-		})
+		tlsConfig := &tls.Config{GetCertificate: watcher.GetCertificate}
+		if err := c.TLSPolicy.Apply(tlsConfig); err != nil {
+			return nil, err
+		}
+
+		listener, err := tls.Listen("tcp", srv.Addr, tlsConfig)
 		if err != nil {
 			return nil, err
 		}
+
+		// The certificate is reloaded whenever it changes until the server is closed.
+		watchCtx, cancelWatch := context.WithCancel(context.Background())
+		stopWatching = cancelWatch
 		serveFunc = func() error {
+			go func() {
+				if err := watcher.Start(watchCtx); err != nil {
+					log.Error().Err(err).Str("service", c.flagPrefix).Msg("error watching tls certs")
+				}
+			}()
+
 			log.WithLevel(level).
 				Str("addr", srv.Addr).
 				Str("prefix", c.flagPrefix).
@@ -360,6 +453,7 @@ func (c *HTTPServerConfig) Complete(level zerolog.Level, handler http.Handler) (
 			return nil
 		},
 		closeFunc: func() {
+			stopWatching()
 			if err := srv.Close(); err != nil {
 				log.Error().Str("addr", srv.Addr).Str("service", c.flagPrefix).Err(err).Msg("error stopping http server")
 			}
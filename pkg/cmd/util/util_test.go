@@ -2,10 +2,17 @@ package util
 
 import (
 	"context"
+	"net"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 func TestDisabledGRPC(t *testing.T) {
@@ -22,3 +29,79 @@ func TestDisabledHTTP(t *testing.T) {
 	require.NoError(t, s.ListenAndServe())
 	s.Close()
 }
+
+func TestUnixSocketGRPC(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	socketDir := t.TempDir()
+	socketPath := filepath.Join(socketDir, "spicedb.sock")
+
+	// A socket left behind by a server which did not stop cleanly is replaced.
+	stale, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, stale.Close())
+
+	config := &GRPCServerConfig{Enabled: true, Network: "unix", Address: socketPath, UnixSocketMode: "0660"}
+	s, err := config.Complete(zerolog.InfoLevel, func(server *grpc.Server) {
+		healthpb.RegisterHealthServer(server, health.NewServer())
+	})
+	require.NoError(t, err)
+
+	info, err := os.Stat(socketPath)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o660), info.Mode().Perm())
+
+	// The private directory in which the socket was created is removed.
+	entries, err := os.ReadDir(socketDir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	go func() {
+		require.NoError(t, s.Listen(ctx)())
+	}()
+	t.Cleanup(s.GracefulStop)
+
+	conn, err := s.DialContext(ctx)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	require.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+
+	// A socket in use by a running server is not replaced.
+	_, err = (&GRPCServerConfig{Enabled: true, Network: "unix", Address: socketPath}).Complete(zerolog.InfoLevel, func(*grpc.Server) {})
+	require.ErrorContains(t, err, "in use")
+
+	// The socket is removed from the address once the server stops.
+	s.GracefulStop()
+	_, err = os.Stat(socketPath)
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestBufferedNetworkNetDial(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s, err := (&GRPCServerConfig{Enabled: true, Network: BufferedNetwork}).Complete(zerolog.InfoLevel, func(server *grpc.Server) {
+		healthpb.RegisterHealthServer(server, health.NewServer())
+	})
+	require.NoError(t, err)
+
+	go func() {
+		require.NoError(t, s.Listen(ctx)())
+	}()
+	t.Cleanup(s.GracefulStop)
+
+	conn, err := grpc.DialContext(ctx, BufferedNetwork,
+		grpc.WithContextDialer(s.NetDialContext),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+}
@@ -49,6 +49,33 @@ type RevisionChanges struct {
 	Changes  []*core.RelationTupleUpdate
 }
 
+// SortChanges sorts the changes into the deterministic order in which every datastore emits the
+// changes of a revision: by resource type, resource ID and relation, then by subject type, subject
+// ID and subject relation, and finally by operation.
+func (rc *RevisionChanges) SortChanges() {
+	sort.SliceStable(rc.Changes, func(i, j int) bool {
+		return compareChanges(rc.Changes[i], rc.Changes[j]) < 0
+	})
+}
+
+func compareChanges(lhs, rhs *core.RelationTupleUpdate) int {
+	lhsResource, rhsResource := lhs.Tuple.ResourceAndRelation, rhs.Tuple.ResourceAndRelation
+	lhsSubject, rhsSubject := lhs.Tuple.Subject, rhs.Tuple.Subject
+	for _, pair := range [][2]string{
+		{lhsResource.Namespace, rhsResource.Namespace},
+		{lhsResource.ObjectId, rhsResource.ObjectId},
+		{lhsResource.Relation, rhsResource.Relation},
+		{lhsSubject.Namespace, rhsSubject.Namespace},
+		{lhsSubject.ObjectId, rhsSubject.ObjectId},
+		{lhsSubject.Relation, rhsSubject.Relation},
+	} {
+		if c := strings.Compare(pair[0], pair[1]); c != 0 {
+			return c
+		}
+	}
+	return int(lhs.Operation) - int(rhs.Operation)
+}
+
 // RelationshipsFilter is a filter for relationships.
 type RelationshipsFilter struct {
 	// ResourceType is the namespace/type for the resources to be found.
@@ -67,6 +94,10 @@ type RelationshipsFilter struct {
 	// OptionalCaveatName is the filter to use for caveated relationships, filtering by a specific caveat name.
 	// If nil, all caveated and non-caveated relationships are allowed
 	OptionalCaveatName string
+
+	// OptionalLabels are the labels which relationships must have, each with the given value.
+	// If empty, relationships with any labels are allowed.
+	OptionalLabels map[string]string
 }
 
 // RelationshipsFilterFromPublicFilter constructs a datastore RelationshipsFilter from an API-defined RelationshipFilter.
@@ -216,6 +247,7 @@ type RevisionedNamespace = RevisionedDefinition[*core.NamespaceDefinition]
 // Reader is an interface for reading relationships from the datastore.
 type Reader interface {
 	CaveatReader
+	SchemaVersionReader
 
 	// QueryRelationships reads relationships, starting from the resource side.
 	QueryRelationships(
@@ -245,6 +277,7 @@ type Reader interface {
 type ReadWriteTransaction interface {
 	Reader
 	CaveatStorer
+	SchemaVersionStorer
 
 	// WriteRelationships takes a list of tuple mutations and applies them to the datastore.
 	WriteRelationships(ctx context.Context, mutations []*core.RelationTupleUpdate) error
@@ -316,6 +349,18 @@ type UnwrappableDatastore interface {
 	Unwrap() Datastore
 }
 
+// Unwrap returns the innermost datastore wrapped by the datastore, or the datastore itself if it
+// does not wrap another.
+func Unwrap(ds Datastore) Datastore {
+	for {
+		wds, ok := ds.(UnwrappableDatastore)
+		if !ok {
+			return ds
+		}
+		ds = wds.Unwrap()
+	}
+}
+
 // Feature represents a capability that a datastore can support, plus an
 // optional message explaining the feature is available (or not).
 type Feature struct {
@@ -355,11 +400,9 @@ type Stats struct {
 
 // RelationshipIterator is an iterator over matched tuples.
 type RelationshipIterator interface {
-	// Next returns the next tuple in the result set.
-	Next() *core.RelationTuple
-
-	// Err after receiving a nil response, the caller must check for an error.
-	Err() error
+	// Next returns the next tuple in the result set, or a nil tuple and nil error once the
+	// result set is exhausted. Once an error is returned, it is returned by every further call.
+	Next() (*core.RelationTuple, error)
 
 	// Close cancels the query and closes any open connections.
 	Close()
@@ -5,6 +5,9 @@ import (
 
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	"github.com/stretchr/testify/require"
+
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
 )
 
 func TestRelationshipsFilterFromPublicFilter(t *testing.T) {
@@ -103,3 +106,49 @@ func TestRelationshipsFilterFromPublicFilter(t *testing.T) {
 		})
 	}
 }
+
+func TestSortChanges(t *testing.T) {
+	changes := RevisionChanges{Changes: []*core.RelationTupleUpdate{
+		tuple.Touch(tuple.MustParse("document:first#viewer@user:tom")),
+		tuple.Delete(tuple.MustParse("document:first#editor@user:tom")),
+		tuple.Touch(tuple.MustParse("document:first#viewer@user:tom")),
+		tuple.Delete(tuple.MustParse("document:first#viewer@user:tom")),
+		tuple.Touch(tuple.MustParse("document:first#viewer@group:eng#member")),
+		tuple.Touch(tuple.MustParse("document:first-draft#viewer@user:fred")),
+		tuple.Touch(tuple.MustParse("folder:first#viewer@user:fred")),
+		tuple.Touch(tuple.MustParse("document:first#viewer@group:eng#...")),
+	}}
+	changes.SortChanges()
+
+	sorted := make([]string, 0, len(changes.Changes))
+	for _, change := range changes.Changes {
+		sorted = append(sorted, change.Operation.String()+" "+tuple.MustString(change.Tuple))
+	}
+	require.Equal(t, []string{
+		"DELETE document:first#editor@user:tom",
+		"TOUCH document:first#viewer@group:eng",
+		"TOUCH document:first#viewer@group:eng#member",
+		"TOUCH document:first#viewer@user:tom",
+		"TOUCH document:first#viewer@user:tom",
+		"DELETE document:first#viewer@user:tom",
+		"TOUCH document:first-draft#viewer@user:fred",
+		"TOUCH folder:first#viewer@user:fred",
+	}, sorted)
+}
+
+func TestSliceRelationshipIterator(t *testing.T) {
+	first := tuple.MustParse("document:first#viewer@user:tom")
+	second := tuple.MustParse("document:second#viewer@user:tom")
+	iter := NewSliceRelationshipIterator([]*core.RelationTuple{first, second})
+
+	for _, expected := range []*core.RelationTuple{first, second, nil, nil} {
+		found, err := iter.Next()
+		require.NoError(t, err)
+		require.Equal(t, expected, found)
+	}
+
+	iter.Close()
+	found, err := iter.Next()
+	require.ErrorIs(t, err, ErrClosedIterator)
+	require.Nil(t, found)
+}
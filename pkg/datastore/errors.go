@@ -54,6 +54,37 @@ type ErrWatchDisabled struct{ error }
 // read-only mode.
 type ErrReadOnly struct{ error }
 
+// ErrRelationshipIntegrity occurs when a relationship read from the datastore fails verification
+// of its integrity signature, indicating that it was written or modified other than by SpiceDB.
+type ErrRelationshipIntegrity struct {
+	error
+	relationship string
+	reason       string
+}
+
+// Relationship is the relationship which failed verification.
+func (err ErrRelationshipIntegrity) Relationship() string {
+	return err.relationship
+}
+
+// Reason is the reason the relationship failed verification.
+func (err ErrRelationshipIntegrity) Reason() string {
+	return err.reason
+}
+
+// MarshalZerologObject implements zerolog object marshalling.
+func (err ErrRelationshipIntegrity) MarshalZerologObject(e *zerolog.Event) {
+	e.Err(err.error).Str("relationship", err.relationship).Str("reason", err.reason)
+}
+
+// DetailsMetadata returns the metadata for details for this error.
+func (err ErrRelationshipIntegrity) DetailsMetadata() map[string]string {
+	return map[string]string{
+		"relationship": err.relationship,
+		"reason":       err.reason,
+	}
+}
+
 // InvalidRevisionReason is the reason the revision could not be used.
 type InvalidRevisionReason int
 
@@ -133,6 +164,15 @@ func NewReadonlyErr() error {
 	}
 }
 
+// NewRelationshipIntegrityErr constructs a new relationship integrity error.
+func NewRelationshipIntegrityErr(relationship, reason string) error {
+	return ErrRelationshipIntegrity{
+		error:        fmt.Errorf("integrity of relationship `%s` could not be verified: %s", relationship, reason),
+		relationship: relationship,
+		reason:       reason,
+	}
+}
+
 // NewInvalidRevisionErr constructs a new invalid revision error.
 func NewInvalidRevisionErr(revision Revision, reason InvalidRevisionReason) error {
 	switch reason {
@@ -152,6 +192,26 @@ func NewInvalidRevisionErr(revision Revision, reason InvalidRevisionReason) erro
 	}
 }
 
+// ErrSchemaVersionExists occurs when a version of the schema is recorded which has already been
+// recorded, such as by a concurrent write of the schema.
+type ErrSchemaVersionExists struct {
+	error
+	version uint64
+}
+
+// Version returns the number of the version which has already been recorded.
+func (err ErrSchemaVersionExists) Version() uint64 {
+	return err.version
+}
+
+// NewSchemaVersionExistsErr constructs a new schema version exists error.
+func NewSchemaVersionExistsErr(version uint64) error {
+	return ErrSchemaVersionExists{
+		error:   fmt.Errorf("schema version %d has already been recorded", version),
+		version: version,
+	}
+}
+
 // ErrCaveatNameNotFound is the error returned when a caveat is not found by its name
 type ErrCaveatNameNotFound struct {
 	error
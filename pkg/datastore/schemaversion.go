@@ -0,0 +1,27 @@
+package datastore
+
+import (
+	"context"
+
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+)
+
+// SchemaVersionReader offers read operations for the history of written schemas
+type SchemaVersionReader interface {
+	// ListSchemaVersions returns every recorded version of the schema, ordered by version.
+	ListSchemaVersions(ctx context.Context) ([]*core.SchemaVersion, error)
+
+	// LatestSchemaVersion returns the number of the most recently recorded version of the
+	// schema, or zero if no version has been recorded.
+	LatestSchemaVersion(ctx context.Context) (uint64, error)
+}
+
+// SchemaVersionStorer offers both read and write operations for the history of written schemas
+type SchemaVersionStorer interface {
+	SchemaVersionReader
+
+	// WriteSchemaVersion records a version of the schema. Versions are expected to be numbered
+	// consecutively, and writing a version which has already been recorded fails with
+	// ErrSchemaVersionExists.
+	WriteSchemaVersion(ctx context.Context, version *core.SchemaVersion) error
+}
@@ -360,10 +360,14 @@ func expectTupleChange(t *testing.T, ds datastore.Datastore, revBeforeWrite data
 
 func expectTuple(req *require.Assertions, iter datastore.RelationshipIterator, tpl *core.RelationTuple) {
 	defer iter.Close()
-	readTpl := iter.Next()
+	readTpl, err := iter.Next()
+	req.NoError(err)
 	foundDiff := cmp.Diff(tpl, readTpl, protocmp.Transform())
 	req.Empty(foundDiff)
-	req.Nil(iter.Next())
+
+	readTpl, err = iter.Next()
+	req.NoError(err)
+	req.Nil(readTpl)
 }
 
 func assertTupleCorrectlyStored(req *require.Assertions, ds datastore.Datastore, rev datastore.Revision, expected *core.RelationTuple) {
@@ -373,7 +377,8 @@ func assertTupleCorrectlyStored(req *require.Assertions, ds datastore.Datastore,
 	req.NoError(err)
 
 	defer iter.Close()
-	readTpl := iter.Next()
+	readTpl, err := iter.Next()
+	req.NoError(err)
 	foundDiff := cmp.Diff(expected, readTpl, protocmp.Transform())
 	req.Empty(foundDiff)
 }
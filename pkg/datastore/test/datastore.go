@@ -48,6 +48,11 @@ func AllExceptWatch(t *testing.T, tester DatastoreTester) {
 	t.Run("TestWriteDeleteWrite", func(t *testing.T) { WriteDeleteWriteTest(t, tester) })
 	t.Run("TestCreateAlreadyExisting", func(t *testing.T) { CreateAlreadyExistingTest(t, tester) })
 	t.Run("TestTouchAlreadyExisting", func(t *testing.T) { TouchAlreadyExistingTest(t, tester) })
+	t.Run("TestMixedWriteOperations", func(t *testing.T) { MixedWriteOperationsTest(t, tester) })
+	t.Run("TestGeneratedDataset", func(t *testing.T) { GeneratedDatasetTest(t, tester) })
+	t.Run("TestLabelledRelationships", func(t *testing.T) { LabelledRelationshipsTest(t, tester) })
+	t.Run("TestResourceIDPrefix", func(t *testing.T) { ResourceIDPrefixTest(t, tester) })
+	t.Run("TestIteratorContract", func(t *testing.T) { IteratorContractTest(t, tester) })
 	t.Run("TestUsersets", func(t *testing.T) { UsersetsTest(t, tester) })
 	t.Run("TestMultipleReadsInRWT", func(t *testing.T) { MultipleReadsInRWTTest(t, tester) })
 	t.Run("TestConcurrentWriteSerialization", func(t *testing.T) { ConcurrentWriteSerializationTest(t, tester) })
@@ -62,6 +67,8 @@ func AllExceptWatch(t *testing.T, tester DatastoreTester) {
 	t.Run("TestWriteCaveatedRelationship", func(t *testing.T) { WriteCaveatedRelationshipTest(t, tester) })
 	t.Run("TestCaveatedRelationshipFilter", func(t *testing.T) { CaveatedRelationshipFilterTest(t, tester) })
 	t.Run("TestCaveatSnapshotReads", func(t *testing.T) { CaveatSnapshotReadsTest(t, tester) })
+
+	t.Run("TestSchemaVersions", func(t *testing.T) { SchemaVersionsTest(t, tester) })
 }
 
 // All runs all generic datastore tests on a DatastoreTester.
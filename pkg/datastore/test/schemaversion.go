@@ -0,0 +1,81 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+)
+
+func SchemaVersionsTest(t *testing.T, tester DatastoreTester) {
+	require := require.New(t)
+
+	ds, err := tester.New(0, veryLargeGCWindow, 1)
+	require.NoError(err)
+
+	ctx := context.Background()
+
+	startRevision, err := ds.HeadRevision(ctx)
+	require.NoError(err)
+
+	versions, err := ds.SnapshotReader(startRevision).ListSchemaVersions(ctx)
+	require.NoError(err)
+	require.Empty(versions)
+
+	latest, err := ds.SnapshotReader(startRevision).LatestSchemaVersion(ctx)
+	require.NoError(err)
+	require.Zero(latest)
+
+	writeVersion := func(version *core.SchemaVersion) (datastore.Revision, error) {
+		return ds.ReadWriteTx(ctx, func(rwt datastore.ReadWriteTransaction) error {
+			return rwt.WriteSchemaVersion(ctx, version)
+		})
+	}
+
+	first := &core.SchemaVersion{
+		Version:    1,
+		SchemaText: "definition user {}",
+		Writer:     "first-writer",
+		WrittenAt:  timestamppb.New(time.Unix(1, 0)),
+	}
+	firstRevision, err := writeVersion(first)
+	require.NoError(err)
+
+	second := &core.SchemaVersion{
+		Version:    2,
+		SchemaText: "definition user {}\n\ndefinition document {}",
+		Writer:     "second-writer",
+		WrittenAt:  timestamppb.New(time.Unix(2, 0)),
+	}
+	secondRevision, err := writeVersion(second)
+	require.NoError(err)
+
+	// Versions are read as of the revision of the reader.
+	versions, err = ds.SnapshotReader(firstRevision).ListSchemaVersions(ctx)
+	require.NoError(err)
+	require.Len(versions, 1)
+	require.True(first.EqualVT(versions[0]))
+
+	versions, err = ds.SnapshotReader(secondRevision).ListSchemaVersions(ctx)
+	require.NoError(err)
+	require.Len(versions, 2)
+	require.True(first.EqualVT(versions[0]))
+	require.True(second.EqualVT(versions[1]))
+
+	latest, err = ds.SnapshotReader(firstRevision).LatestSchemaVersion(ctx)
+	require.NoError(err)
+	require.Equal(uint64(1), latest)
+
+	latest, err = ds.SnapshotReader(secondRevision).LatestSchemaVersion(ctx)
+	require.NoError(err)
+	require.Equal(uint64(2), latest)
+
+	// A version cannot be recorded twice.
+	_, err = writeVersion(second)
+	require.ErrorAs(err, &datastore.ErrSchemaVersionExists{})
+}
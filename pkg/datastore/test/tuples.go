@@ -595,6 +595,255 @@ func TouchAlreadyExistingTest(t *testing.T, tester DatastoreTester) {
 	require.NoError(err)
 }
 
+// MixedWriteOperationsTest tests that the mutations of a single write are applied as the
+// deletions followed by the creations and touches, regardless of their order, and that writing
+// the same relationship more than once fails.
+func MixedWriteOperationsTest(t *testing.T, tester DatastoreTester) {
+	require := require.New(t)
+
+	rawDS, err := tester.New(0, veryLargeGCWindow, 1)
+	require.NoError(err)
+
+	ds, _ := testfixtures.StandardDatastoreWithData(rawDS, require)
+	ctx := context.Background()
+
+	existing := makeTestTuple("foo", "tom")
+	_, err = common.WriteTuples(ctx, ds, core.RelationTupleUpdate_CREATE, existing)
+	require.NoError(err)
+
+	// The writes are made directly to the datastore, as the validating datastore rejects
+	// writes referring to the same relationship more than once.
+	write := func(mutations ...*core.RelationTupleUpdate) (datastore.Revision, error) {
+		return rawDS.ReadWriteTx(ctx, func(rwt datastore.ReadWriteTransaction) error {
+			return rwt.WriteRelationships(ctx, mutations)
+		})
+	}
+
+	exists := func(rev datastore.Revision, tpl *core.RelationTuple) bool {
+		iter, err := ds.SnapshotReader(rev).QueryRelationships(ctx, datastore.RelationshipsFilter{
+			ResourceType:             tpl.ResourceAndRelation.Namespace,
+			OptionalResourceIds:      []string{tpl.ResourceAndRelation.ObjectId},
+			OptionalResourceRelation: tpl.ResourceAndRelation.Relation,
+		})
+		require.NoError(err)
+		defer iter.Close()
+
+		found, err := iter.Next()
+		require.NoError(err)
+		return found != nil
+	}
+
+	// Deleting and then creating an existing relationship recreates it.
+	rev, err := write(tuple.Delete(existing), tuple.Create(existing))
+	require.NoError(err)
+	require.True(exists(rev, existing))
+
+	// The deletion is applied before the creation, even if given after it.
+	created := makeTestTuple("bar", "tom")
+	rev, err = write(tuple.Create(created), tuple.Delete(created))
+	require.NoError(err)
+	require.True(exists(rev, created))
+
+	// Creating a relationship which exists fails, even if it is also touched.
+	_, err = write(tuple.Touch(existing), tuple.Create(existing))
+	require.Error(err)
+
+	// Writing the same relationship more than once fails.
+	duplicated := makeTestTuple("baz", "tom")
+	_, err = write(tuple.Create(duplicated), tuple.Create(duplicated))
+	require.Error(err)
+
+	_, err = write(tuple.Touch(duplicated), tuple.Touch(duplicated))
+	require.Error(err)
+
+	rev, err = ds.HeadRevision(ctx)
+	require.NoError(err)
+	require.False(exists(rev, duplicated))
+}
+
+// GeneratedDatasetTest tests writing and reading back a generated dataset.
+func GeneratedDatasetTest(t *testing.T, tester DatastoreTester) {
+	require := require.New(t)
+
+	rawDS, err := tester.New(0, veryLargeGCWindow, 1)
+	require.NoError(err)
+
+	config := testfixtures.GeneratorConfig{Seed: 1, Depth: 4, FanOut: 4, Users: 50, CaveatRatio: 0.25}
+	ds, rev := testfixtures.GeneratedDatastore(config)(rawDS, require)
+	ctx := context.Background()
+
+	expected := make(map[string]struct{})
+	for _, rel := range testfixtures.Generate(config).Relationships {
+		expected[tuple.MustString(rel)] = struct{}{}
+	}
+
+	found := make(map[string]struct{}, len(expected))
+	for _, resourceType := range []string{"group", "resource"} {
+		iter, err := ds.SnapshotReader(rev).QueryRelationships(ctx, datastore.RelationshipsFilter{
+			ResourceType: resourceType,
+		})
+		require.NoError(err)
+
+		for {
+			tpl, err := iter.Next()
+			require.NoError(err)
+			if tpl == nil {
+				break
+			}
+
+			found[tuple.MustString(tpl)] = struct{}{}
+		}
+		iter.Close()
+	}
+
+	require.Equal(expected, found)
+}
+
+// LabelledRelationshipsTest tests whether or not the labels of relationships are stored, replaced
+// on touch and filterable for a particular datastore.
+func LabelledRelationshipsTest(t *testing.T, tester DatastoreTester) {
+	require := require.New(t)
+
+	rawDS, err := tester.New(0, veryLargeGCWindow, 1)
+	require.NoError(err)
+
+	ds, _ := testfixtures.StandardDatastoreWithData(rawDS, require)
+	ctx := context.Background()
+
+	synced := makeTestTuple("foo", "tom")
+	synced.Labels = map[string]string{"source": "scim-sync", "batch": "1"}
+
+	otherBatch := makeTestTuple("foo", "sarah")
+	otherBatch.Labels = map[string]string{"source": "scim-sync", "batch": "2"}
+
+	unlabelled := makeTestTuple("foo", "fred")
+
+	rev, err := common.WriteTuples(ctx, ds, core.RelationTupleUpdate_CREATE, synced, otherBatch, unlabelled)
+	require.NoError(err)
+
+	queryWithLabels := func(rev datastore.Revision, labels map[string]string) []*core.RelationTuple {
+		iter, err := ds.SnapshotReader(rev).QueryRelationships(ctx, datastore.RelationshipsFilter{
+			ResourceType:   testResourceNamespace,
+			OptionalLabels: labels,
+		})
+		require.NoError(err)
+		defer iter.Close()
+
+		var found []*core.RelationTuple
+		for {
+			tpl, err := iter.Next()
+			require.NoError(err)
+			if tpl == nil {
+				return found
+			}
+
+			found = append(found, tpl)
+		}
+	}
+
+	// The labels are read back.
+	found := queryWithLabels(rev, map[string]string{"source": "scim-sync", "batch": "1"})
+	require.Len(found, 1)
+	require.Equal(synced.Labels, found[0].Labels)
+
+	require.Len(queryWithLabels(rev, map[string]string{"source": "scim-sync"}), 2)
+	require.Len(queryWithLabels(rev, map[string]string{"source": "manual"}), 0)
+	require.Len(queryWithLabels(rev, nil), 3)
+
+	// Touching the relationship replaces its labels.
+	touched := makeTestTuple("foo", "tom")
+	touched.Labels = map[string]string{"source": "manual"}
+	rev, err = common.WriteTuples(ctx, ds, core.RelationTupleUpdate_TOUCH, touched)
+	require.NoError(err)
+
+	require.Len(queryWithLabels(rev, map[string]string{"source": "scim-sync"}), 1)
+
+	found = queryWithLabels(rev, map[string]string{"source": "manual"})
+	require.Len(found, 1)
+	require.Equal(touched.Labels, found[0].Labels)
+}
+
+// IteratorContractTest tests whether the relationship iterators of a particular datastore
+// signal exhaustion and closure as specified by datastore.RelationshipIterator.
+func IteratorContractTest(t *testing.T, tester DatastoreTester) {
+	require := require.New(t)
+
+	rawDS, err := tester.New(0, veryLargeGCWindow, 1)
+	require.NoError(err)
+
+	ds, rev := testfixtures.StandardDatastoreWithData(rawDS, require)
+	ctx := context.Background()
+
+	iter, err := ds.SnapshotReader(rev).QueryRelationships(ctx, datastore.RelationshipsFilter{
+		ResourceType: testfixtures.DocumentNS.Name,
+	})
+	require.NoError(err)
+
+	count := 0
+	for {
+		tpl, err := iter.Next()
+		require.NoError(err)
+		if tpl == nil {
+			break
+		}
+
+		count++
+	}
+	require.Positive(count)
+
+	// Once exhausted, the iterator remains so.
+	tpl, err := iter.Next()
+	require.NoError(err)
+	require.Nil(tpl)
+
+	// Once closed, the iterator fails.
+	iter.Close()
+	tpl, err = iter.Next()
+	require.ErrorIs(err, datastore.ErrClosedIterator)
+	require.Nil(tpl)
+}
+
+// ResourceIDPrefixTest tests limiting reverse queries to resources whose IDs have a prefix.
+func ResourceIDPrefixTest(t *testing.T, tester DatastoreTester) {
+	require := require.New(t)
+
+	rawDS, err := tester.New(0, veryLargeGCWindow, 1)
+	require.NoError(err)
+
+	ds, _ := testfixtures.StandardDatastoreWithSchema(rawDS, require)
+	ctx := context.Background()
+
+	inFolder := makeTestTuple("team_a/first", "tom")
+	alsoInFolder := makeTestTuple("team_a/second", "tom")
+	wildcardMatch := makeTestTuple("teamXa/third", "tom")
+	otherFolder := makeTestTuple("team_b/first", "tom")
+
+	rev, err := common.WriteTuples(ctx, ds, core.RelationTupleUpdate_CREATE, inFolder, alsoInFolder, wildcardMatch, otherFolder)
+	require.NoError(err)
+
+	tRequire := testfixtures.TupleChecker{Require: require, DS: ds}
+	reverseQueryWithPrefix := func(prefix string) datastore.RelationshipIterator {
+		iter, err := ds.SnapshotReader(rev).ReverseQueryRelationships(
+			ctx,
+			onrToSubjectsFilter(inFolder.Subject),
+			options.WithResRelation(&options.ResourceRelation{
+				Namespace: testResourceNamespace,
+				Relation:  testReaderRelation,
+			}),
+			options.WithResIDPrefix(prefix),
+		)
+		require.NoError(err)
+		return iter
+	}
+
+	// The underscore of the prefix must not act as a wildcard.
+	tRequire.VerifyIteratorResults(reverseQueryWithPrefix("team_a/"), inFolder, alsoInFolder)
+	tRequire.VerifyIteratorResults(reverseQueryWithPrefix("team_"), inFolder, alsoInFolder, otherFolder)
+	tRequire.VerifyIteratorResults(reverseQueryWithPrefix("team_a/first"), inFolder)
+	tRequire.VerifyIteratorResults(reverseQueryWithPrefix("team_c/"))
+	tRequire.VerifyIteratorResults(reverseQueryWithPrefix(""), inFolder, alsoInFolder, wildcardMatch, otherFolder)
+}
+
 // UsersetsTest tests whether or not the requirements for reading usersets hold
 // for a particular datastore.
 func UsersetsTest(t *testing.T, tester DatastoreTester) {
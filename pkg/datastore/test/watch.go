@@ -149,6 +149,10 @@ func verifyUpdates(
 			require.True(missingExpected.IsEmpty(), "expected changes missing: %s", missingExpected)
 			require.True(unexpected.IsEmpty(), "unexpected changes: %s", unexpected)
 
+			sorted := datastore.RevisionChanges{Changes: append([]*core.RelationTupleUpdate{}, change.Changes...)}
+			sorted.SortChanges()
+			require.Equal(sorted.Changes, change.Changes, "changes are not in order")
+
 			time.Sleep(1 * time.Millisecond)
 		case <-changeWait.C:
 			require.Fail("Timed out", "waiting for changes: %s", expected)
@@ -6,7 +6,8 @@ import (
 	core "github.com/authzed/spicedb/pkg/proto/core/v1"
 )
 
-var errClosedIterator = errors.New("unable to iterate: iterator closed")
+// ErrClosedIterator is returned by the Next method of an iterator which has been closed.
+var ErrClosedIterator = errors.New("unable to iterate: iterator closed")
 
 // NewSliceRelationshipIterator creates a datastore.TupleIterator instance from a materialized slice of tuples.
 func NewSliceRelationshipIterator(tuples []*core.RelationTuple) RelationshipIterator {
@@ -16,28 +17,21 @@ func NewSliceRelationshipIterator(tuples []*core.RelationTuple) RelationshipIter
 type sliceRelationshipIterator struct {
 	tuples []*core.RelationTuple
 	closed bool
-	err    error
 }
 
 // Next implements TupleIterator
-func (sti *sliceRelationshipIterator) Next() *core.RelationTuple {
+func (sti *sliceRelationshipIterator) Next() (*core.RelationTuple, error) {
 	if sti.closed {
-		sti.err = errClosedIterator
-		return nil
+		return nil, ErrClosedIterator
 	}
 
 	if len(sti.tuples) > 0 {
 		first := sti.tuples[0]
 		sti.tuples = sti.tuples[1:]
-		return first
+		return first, nil
 	}
 
-	return nil
-}
-
-// Err implements TupleIterator
-func (sti *sliceRelationshipIterator) Err() error {
-	return sti.err
+	return nil, nil
 }
 
 // Close implements TupleIterator
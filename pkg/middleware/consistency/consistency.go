@@ -26,3 +26,13 @@ func MustRevisionFromContext(ctx context.Context) (datastore.Revision, *v1.ZedTo
 
 	return rev, zedtoken.MustNewFromRevision(rev)
 }
+
+// EvaluatedAt is the key in the response header metadata holding the ZedToken of the exact
+// revision at which the request was evaluated.
+const EvaluatedAt = consistency.EvaluatedAt
+
+// SetEvaluatedRevisionHeader sets the ZedToken for the revision selected for the request in the
+// response header metadata.
+func SetEvaluatedRevisionHeader(ctx context.Context, revisionToken *v1.ZedToken) error {
+	return consistency.SetEvaluatedRevisionHeader(ctx, revisionToken)
+}
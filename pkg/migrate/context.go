@@ -8,4 +8,11 @@ const (
 	// BackfillBatchSize represents the number of items that should be backfilled in a
 	// single step of an incremental backfill, and should be of type uint64.
 	BackfillBatchSize MigrationVariable = iota
+
+	// BackfillBatchDelay represents the duration to wait between the batches of the backfills
+	// of online migrations, limiting their load on the datastore, and should be of type
+	// time.Duration.
+	BackfillBatchDelay
 )
+
+const defaultBackfillBatchSize uint64 = 1000
@@ -53,6 +53,9 @@ type migration[C any, T any] struct {
 	replaces string
 	up       MigrationFunc[C]
 	upTx     TxMigrationFunc[T]
+	phases   []OnlinePhase[C]
+
+	destructive bool
 }
 
 // Manager is used to manage a self-contained set of migrations. Standard usage
@@ -139,6 +142,12 @@ func (m *Manager[D, C, T]) Run(ctx context.Context, driver D, throughRevision st
 				}
 			}
 
+			if len(migrationToRun.phases) > 0 {
+				if err := runPhases(ctx, any(driver), driver.Conn(), migrationToRun.version, migrationToRun.phases); err != nil {
+					return fmt.Errorf("error executing online migration `%s`: %w", migrationToRun.version, err)
+				}
+			}
+
 			if err := driver.RunTx(ctx, func(ctx context.Context, tx T) error {
 				if migrationToRun.upTx != nil {
 					if err := migrationToRun.upTx(ctx, tx); err != nil {
@@ -2,6 +2,8 @@ package migrate
 
 import (
 	"context"
+	"errors"
+	"strconv"
 	"testing"
 	"time"
 
@@ -196,24 +198,161 @@ func TestManagerEnsureVersionIsWritten(t *testing.T) {
 var noMigrations = map[string]migration[fakeConnPool, fakeTx]{}
 
 var simpleMigrations = map[string]migration[fakeConnPool, fakeTx]{
-	"123": {"123", "", noNonatomicMigration, noTxMigration},
+	"123": {"123", "", noNonatomicMigration, noTxMigration, nil, false},
 }
 
 var singleHeadedChain = map[string]migration[fakeConnPool, fakeTx]{
-	"123": {"123", "", noNonatomicMigration, noTxMigration},
-	"456": {"456", "123", noNonatomicMigration, noTxMigration},
-	"789": {"789", "456", noNonatomicMigration, noTxMigration},
+	"123": {"123", "", noNonatomicMigration, noTxMigration, nil, false},
+	"456": {"456", "123", noNonatomicMigration, noTxMigration, nil, false},
+	"789": {"789", "456", noNonatomicMigration, noTxMigration, nil, false},
 }
 
 var multiHeadedChain = map[string]migration[fakeConnPool, fakeTx]{
-	"123":  {"123", "", noNonatomicMigration, noTxMigration},
-	"456":  {"456", "123", noNonatomicMigration, noTxMigration},
-	"789a": {"789a", "456", noNonatomicMigration, noTxMigration},
-	"789b": {"789b", "456", noNonatomicMigration, noTxMigration},
+	"123":  {"123", "", noNonatomicMigration, noTxMigration, nil, false},
+	"456":  {"456", "123", noNonatomicMigration, noTxMigration, nil, false},
+	"789a": {"789a", "456", noNonatomicMigration, noTxMigration, nil, false},
+	"789b": {"789b", "456", noNonatomicMigration, noTxMigration, nil, false},
 }
 
 var missingEarlyMigrations = map[string]migration[fakeConnPool, fakeTx]{
-	"456": {"456", "123", noNonatomicMigration, noTxMigration},
-	"789": {"789", "456", noNonatomicMigration, noTxMigration},
-	"10":  {"10", "789", noNonatomicMigration, noTxMigration},
+	"456": {"456", "123", noNonatomicMigration, noTxMigration, nil, false},
+	"789": {"789", "456", noNonatomicMigration, noTxMigration, nil, false},
+	"10":  {"10", "789", noNonatomicMigration, noTxMigration, nil, false},
+}
+
+type progressDriver struct {
+	fakeDriver
+	progress map[string]Progress
+}
+
+func (pd *progressDriver) RunTx(ctx context.Context, f TxMigrationFunc[fakeTx]) error {
+	return f(ctx, fakeTx{})
+}
+
+func (pd *progressDriver) ReadProgress(_ context.Context, version string) (*Progress, error) {
+	progress, ok := pd.progress[version]
+	if !ok {
+		return nil, nil
+	}
+	return &progress, nil
+}
+
+func (pd *progressDriver) WriteProgress(_ context.Context, version string, progress Progress) error {
+	pd.progress[version] = progress
+	return nil
+}
+
+func TestOnlineMigrationResumes(t *testing.T) {
+	req := require.New(t)
+	m := NewManager[Driver[fakeConnPool, fakeTx], fakeConnPool, fakeTx]()
+	req.NoError(m.Register("1", "", noNonatomicMigration, noTxMigration))
+
+	var ran []string
+	failAfterBatch := 2
+	batches := 0
+	req.NoError(m.RegisterOnline("2", "1",
+		OnlinePhase[fakeConnPool]{Name: "add-column", Up: func(ctx context.Context, conn fakeConnPool) error {
+			ran = append(ran, "add-column")
+			return nil
+		}},
+		OnlinePhase[fakeConnPool]{Name: "backfill", Backfill: func(ctx context.Context, conn fakeConnPool, cursor string, batchSize uint64) (string, bool, error) {
+			req.Equal(uint64(10), batchSize)
+			if batches == failAfterBatch {
+				failAfterBatch = -1
+				return "", false, errors.New("interrupted")
+			}
+			batches++
+			ran = append(ran, "backfill:"+cursor)
+			next := strconv.Itoa(batches)
+			return next, batches < 4, nil
+		}},
+		OnlinePhase[fakeConnPool]{Name: "drop-column", Up: func(ctx context.Context, conn fakeConnPool) error {
+			ran = append(ran, "drop-column")
+			return nil
+		}},
+	))
+
+	driver := &progressDriver{fakeDriver{currentVersion: "1"}, map[string]Progress{}}
+	ctx := context.WithValue(context.Background(), BackfillBatchSize, uint64(10))
+
+	err := m.Run(ctx, driver, Head, LiveRun)
+	req.ErrorContains(err, "interrupted")
+	req.Equal("1", driver.currentVersion)
+
+	status, err := m.Status(ctx, driver)
+	req.NoError(err)
+	req.Equal([]string{"2"}, status.Pending)
+	req.Equal(&Progress{Phase: "backfill", Cursor: "2", Batches: 2}, status.InProgress)
+
+	// The migration resumes from the batch after the last completed.
+	req.NoError(m.Run(ctx, driver, Head, LiveRun))
+	req.Equal("2", driver.currentVersion)
+	req.Equal([]string{"add-column", "backfill:", "backfill:1", "backfill:2", "backfill:3", "drop-column"}, ran)
+
+	status, err = m.Status(ctx, driver)
+	req.NoError(err)
+	req.Empty(status.Pending)
+	req.Nil(status.InProgress)
+}
+
+func TestOnlineMigrationRequiresProgressDriver(t *testing.T) {
+	req := require.New(t)
+	m := NewManager[Driver[fakeConnPool, fakeTx], fakeConnPool, fakeTx]()
+	noop := func(ctx context.Context, conn fakeConnPool) error { return nil }
+	req.NoError(m.RegisterOnline("1", "", OnlinePhase[fakeConnPool]{Name: "noop", Up: noop}))
+	req.ErrorContains(m.RegisterOnline("2", "1", OnlinePhase[fakeConnPool]{Name: "noop"}), "exactly one")
+	req.ErrorContains(m.RegisterOnline("2", "1"), "no phases")
+
+	err := m.Run(context.Background(), &fakeDriver{}, Head, LiveRun)
+	req.ErrorContains(err, "does not support online migrations")
+}
+
+func TestPlan(t *testing.T) {
+	req := require.New(t)
+	m := NewManager[Driver[fakeConnPool, fakeTx], fakeConnPool, fakeTx]()
+	req.NoError(m.Register("1", "", noNonatomicMigration, noTxMigration))
+	req.NoError(m.Register("2", "1", noNonatomicMigration, noTxMigration))
+	req.NoError(m.MarkDestructive("2"))
+	req.Error(m.MarkDestructive("unknown"))
+
+	noop := func(ctx context.Context, conn fakeConnPool) error { return nil }
+	req.NoError(m.RegisterOnline("3", "2",
+		OnlinePhase[fakeConnPool]{Name: "backfill", Backfill: func(ctx context.Context, conn fakeConnPool, cursor string, batchSize uint64) (string, bool, error) {
+			return "", false, nil
+		}, Estimate: func(ctx context.Context, conn fakeConnPool) (uint64, error) {
+			return 42, nil
+		}},
+		OnlinePhase[fakeConnPool]{Name: "drop", Up: noop, Destructive: true},
+	))
+
+	estimate := uint64(42)
+	driver := &progressDriver{fakeDriver{currentVersion: "1"}, map[string]Progress{}}
+
+	plan, err := m.Plan(context.Background(), driver, "2")
+	req.NoError(err)
+	req.Equal(&Plan{
+		CurrentRevision: "1",
+		TargetRevision:  "2",
+		Migrations:      []PlannedMigration{{Version: "2", Replaces: "1", Destructive: true}},
+		Destructive:     true,
+	}, plan)
+
+	plan, err = m.Plan(context.Background(), driver, Head)
+	req.NoError(err)
+	req.Equal("3", plan.TargetRevision)
+	req.Len(plan.Migrations, 2)
+	req.Equal(PlannedMigration{
+		Version:     "3",
+		Replaces:    "2",
+		Online:      true,
+		Destructive: true,
+		Phases: []PlannedPhase{
+			{Name: "backfill", Backfill: true, EstimatedRows: &estimate},
+			{Name: "drop", Destructive: true},
+		},
+	}, plan.Migrations[1])
+	req.Nil(plan.Permissions)
+
+	// Planning does not run any migrations.
+	req.Equal("1", driver.currentVersion)
 }
@@ -0,0 +1,230 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/authzed/spicedb/internal/logging"
+)
+
+// OnlinePhase is a single phase of an online migration, such as adding a column, backfilling
+// it, switching reads to it, or dropping what it replaces. Each phase must leave the datastore
+// usable by servers running either side of the migration, so that it can be applied without
+// downtime.
+type OnlinePhase[C any] struct {
+	// Name identifies the phase in the recorded progress of the migration, and so must not be
+	// changed once released.
+	Name string
+
+	// Up performs the phase in a single step. It must be idempotent, as it is rerun if the
+	// migration is interrupted before the phase has been recorded as complete.
+	Up MigrationFunc[C]
+
+	// Backfill, if set instead of Up, performs the phase in batches, until it reports that no
+	// work remains. The progress of the backfill is recorded after each batch, so that it
+	// resumes from the last batch completed if interrupted.
+	Backfill BackfillFunc[C]
+
+	// Estimate, if set, estimates the number of rows the backfill of the phase will process,
+	// for reporting when planning the migration.
+	Estimate EstimateFunc[C]
+
+	// Destructive marks a phase which removes what servers running an earlier version read.
+	Destructive bool
+}
+
+// BackfillFunc performs a single batch of a backfill, of at most the given size, starting
+// from the cursor, which is empty for the first batch. It returns the cursor from which the
+// next batch starts, and whether work remains.
+type BackfillFunc[C any] func(ctx context.Context, conn C, cursor string, batchSize uint64) (next string, more bool, err error)
+
+// Progress is the recorded progress of an online migration which has not yet completed.
+type Progress struct {
+	// Phase is the name of the phase in progress.
+	Phase string
+
+	// Cursor is the cursor from which the next batch of the backfill of the phase starts.
+	Cursor string
+
+	// Batches is the number of batches of the backfill of the phase completed.
+	Batches uint64
+
+	// UpdatedAt is when the progress was last recorded.
+	UpdatedAt time.Time
+}
+
+// ProgressDriver is implemented by drivers which record the progress of online migrations,
+// which can only be run with such drivers.
+type ProgressDriver interface {
+	// ReadProgress returns the recorded progress of the online migration to the version, or
+	// nil if none has been recorded.
+	ReadProgress(ctx context.Context, version string) (*Progress, error)
+
+	// WriteProgress records the progress of the online migration to the version.
+	WriteProgress(ctx context.Context, version string, progress Progress) error
+}
+
+// RegisterOnline is used to associate a single online migration with the migration engine. The
+// phases of the migration are run in order, after which the version is written.
+func (m *Manager[D, C, T]) RegisterOnline(version, replaces string, phases ...OnlinePhase[C]) error {
+	if len(phases) == 0 {
+		return fmt.Errorf("online migration %s has no phases", version)
+	}
+
+	names := make(map[string]struct{}, len(phases))
+	for _, phase := range phases {
+		if _, ok := names[phase.Name]; ok || phase.Name == "" {
+			return fmt.Errorf("online migration %s has an unnamed or duplicate phase: %q", version, phase.Name)
+		}
+		if (phase.Up == nil) == (phase.Backfill == nil) {
+			return fmt.Errorf("phase %s of online migration %s must have exactly one of Up or Backfill", phase.Name, version)
+		}
+		names[phase.Name] = struct{}{}
+	}
+
+	if err := m.Register(version, replaces, nil, nil); err != nil {
+		return err
+	}
+
+	registered := m.migrations[version]
+	registered.phases = phases
+	m.migrations[version] = registered
+	return nil
+}
+
+// runPhases runs the phases of an online migration, resuming from the recorded progress.
+func runPhases[C any](ctx context.Context, driver any, conn C, version string, phases []OnlinePhase[C]) error {
+	progressDriver, ok := driver.(ProgressDriver)
+	if !ok {
+		return fmt.Errorf("driver does not support online migrations, required by %s", version)
+	}
+
+	progress, err := progressDriver.ReadProgress(ctx, version)
+	if err != nil {
+		return fmt.Errorf("unable to read progress of online migration: %w", err)
+	}
+
+	start := 0
+	if progress != nil {
+		start = -1
+		for index, phase := range phases {
+			if phase.Name == progress.Phase {
+				start = index
+				break
+			}
+		}
+		if start < 0 {
+			return fmt.Errorf("recorded phase %s of online migration %s is unknown", progress.Phase, version)
+		}
+		log.Ctx(ctx).Info().Str("version", version).Str("phase", progress.Phase).Uint64("batches", progress.Batches).Msg("resuming online migration")
+	}
+
+	for index := start; index < len(phases); index++ {
+		phase := phases[index]
+		current := Progress{Phase: phase.Name}
+		if progress != nil && progress.Phase == phase.Name {
+			current = *progress
+		}
+
+		if err := progressDriver.WriteProgress(ctx, version, current); err != nil {
+			return fmt.Errorf("unable to record progress of online migration: %w", err)
+		}
+
+		log.Ctx(ctx).Info().Str("version", version).Str("phase", phase.Name).Msg("running online migration phase")
+		if phase.Up != nil {
+			if err := phase.Up(ctx, conn); err != nil {
+				return fmt.Errorf("error executing phase %s: %w", phase.Name, err)
+			}
+			continue
+		}
+
+		if err := runBackfill(ctx, progressDriver, conn, version, phase, current); err != nil {
+			return fmt.Errorf("error executing phase %s: %w", phase.Name, err)
+		}
+	}
+	return nil
+}
+
+func runBackfill[C any](ctx context.Context, progressDriver ProgressDriver, conn C, version string, phase OnlinePhase[C], progress Progress) error {
+	batchSize, _ := ctx.Value(BackfillBatchSize).(uint64)
+	if batchSize == 0 {
+		batchSize = defaultBackfillBatchSize
+	}
+	batchDelay, _ := ctx.Value(BackfillBatchDelay).(time.Duration)
+
+	for {
+		next, more, err := phase.Backfill(ctx, conn, progress.Cursor, batchSize)
+		if err != nil {
+			return err
+		}
+
+		progress.Cursor = next
+		progress.Batches++
+		if err := progressDriver.WriteProgress(ctx, version, progress); err != nil {
+			return fmt.Errorf("unable to record progress of online migration: %w", err)
+		}
+
+		if !more {
+			log.Ctx(ctx).Info().Str("version", version).Str("phase", phase.Name).Uint64("batches", progress.Batches).Msg("completed backfill")
+			return nil
+		}
+
+		if batchDelay > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(batchDelay):
+			}
+		}
+	}
+}
+
+// Status is the status of the migrations of a datastore.
+type Status struct {
+	// Version is the version to which the datastore has been migrated.
+	Version string
+
+	// HeadRevision is the latest version registered.
+	HeadRevision string
+
+	// Pending are the versions of the migrations yet to be run, in order.
+	Pending []string
+
+	// InProgress is the progress of the first pending migration, if it is an online migration
+	// which has been started.
+	InProgress *Progress
+}
+
+// Status returns the status of the migrations of the datastore.
+func (m *Manager[D, C, T]) Status(ctx context.Context, driver D) (*Status, error) {
+	version, err := driver.Version(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load version from driver: %w", err)
+	}
+
+	headRevision, err := m.HeadRevision()
+	if err != nil {
+		return nil, fmt.Errorf("unable to compute head revision: %w", err)
+	}
+
+	pending, err := collectMigrationsInRange(version, headRevision, m.migrations)
+	if err != nil {
+		return nil, fmt.Errorf("unable to compute migration list: %w", err)
+	}
+
+	status := &Status{Version: version, HeadRevision: headRevision}
+	for _, migration := range pending {
+		status.Pending = append(status.Pending, migration.version)
+	}
+
+	if len(pending) > 0 && len(pending[0].phases) > 0 {
+		if progressDriver, ok := any(driver).(ProgressDriver); ok {
+			status.InProgress, err = progressDriver.ReadProgress(ctx, pending[0].version)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read progress of online migration: %w", err)
+			}
+		}
+	}
+	return status, nil
+}
@@ -0,0 +1,129 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+)
+
+// EstimateFunc estimates the number of rows a backfill will process.
+type EstimateFunc[C any] func(ctx context.Context, conn C) (uint64, error)
+
+// Permission is a privilege required by the migrations, and whether the connected user has it.
+type Permission struct {
+	Name    string `json:"name"`
+	Granted bool   `json:"granted"`
+}
+
+// PreflightDriver is implemented by drivers which can check that the connected user has the
+// privileges required to run migrations.
+type PreflightDriver interface {
+	// CheckPermissions returns the privileges required to run migrations.
+	CheckPermissions(ctx context.Context) ([]Permission, error)
+}
+
+// PlannedPhase is a phase of an online migration which would be run.
+type PlannedPhase struct {
+	Name          string  `json:"name"`
+	Backfill      bool    `json:"backfill"`
+	Destructive   bool    `json:"destructive"`
+	EstimatedRows *uint64 `json:"estimatedRows,omitempty"`
+}
+
+// PlannedMigration is a migration which would be run.
+type PlannedMigration struct {
+	Version     string         `json:"version"`
+	Replaces    string         `json:"replaces"`
+	Online      bool           `json:"online"`
+	Destructive bool           `json:"destructive"`
+	Phases      []PlannedPhase `json:"phases,omitempty"`
+}
+
+// Plan describes the migrations which would be run to migrate a datastore to a revision,
+// without running them.
+type Plan struct {
+	CurrentRevision string             `json:"currentRevision"`
+	TargetRevision  string             `json:"targetRevision"`
+	Migrations      []PlannedMigration `json:"migrations"`
+	Destructive     bool               `json:"destructive"`
+
+	// Permissions are the privileges required to run the migrations, or nil if the driver
+	// cannot check them.
+	Permissions []Permission `json:"permissions,omitempty"`
+}
+
+// MarkDestructive marks a registered migration as destructive, such as one which drops columns
+// or tables that servers running an earlier version still read, so that it is reported as such
+// when planning.
+func (m *Manager[D, C, T]) MarkDestructive(version string) error {
+	registered, ok := m.migrations[version]
+	if !ok {
+		return fmt.Errorf("unknown migration: %s", version)
+	}
+	registered.destructive = true
+	m.migrations[version] = registered
+	return nil
+}
+
+// Plan computes the migrations which would be run to migrate the datastore through the
+// revision, estimating the size of their backfills and checking the privileges they require.
+func (m *Manager[D, C, T]) Plan(ctx context.Context, driver D, throughRevision string) (*Plan, error) {
+	currentVersion, err := driver.Version(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load version from driver: %w", err)
+	}
+
+	if throughRevision == Head {
+		throughRevision, err = m.HeadRevision()
+		if err != nil {
+			return nil, fmt.Errorf("unable to compute head revision: %w", err)
+		}
+	}
+
+	toRun, err := collectMigrationsInRange(currentVersion, throughRevision, m.migrations)
+	if err != nil {
+		return nil, fmt.Errorf("unable to compute migration list: %w", err)
+	}
+
+	plan := &Plan{
+		CurrentRevision: currentVersion,
+		TargetRevision:  throughRevision,
+		Migrations:      make([]PlannedMigration, 0, len(toRun)),
+	}
+	for _, migration := range toRun {
+		planned := PlannedMigration{
+			Version:     migration.version,
+			Replaces:    migration.replaces,
+			Online:      len(migration.phases) > 0,
+			Destructive: migration.destructive,
+		}
+
+		for _, phase := range migration.phases {
+			plannedPhase := PlannedPhase{
+				Name:        phase.Name,
+				Backfill:    phase.Backfill != nil,
+				Destructive: phase.Destructive,
+			}
+			if phase.Estimate != nil {
+				estimate, err := phase.Estimate(ctx, driver.Conn())
+				if err != nil {
+					return nil, fmt.Errorf("unable to estimate phase %s of migration %s: %w", phase.Name, migration.version, err)
+				}
+				plannedPhase.EstimatedRows = &estimate
+			}
+			planned.Destructive = planned.Destructive || phase.Destructive
+			planned.Phases = append(planned.Phases, plannedPhase)
+		}
+
+		plan.Destructive = plan.Destructive || planned.Destructive
+		plan.Migrations = append(plan.Migrations, planned)
+	}
+
+	if preflightDriver, ok := any(driver).(PreflightDriver); ok {
+		plan.Permissions, err = preflightDriver.CheckPermissions(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to check permissions: %w", err)
+		}
+	}
+
+	return plan, nil
+}
@@ -1,6 +1,8 @@
 package namespace
 
 import (
+	"google.golang.org/protobuf/types/known/anypb"
+
 	"github.com/authzed/spicedb/pkg/caveats"
 	core "github.com/authzed/spicedb/pkg/proto/core/v1"
 	iv1 "github.com/authzed/spicedb/pkg/proto/impl/v1"
@@ -66,6 +68,35 @@ func Relation(name string, rewrite *core.UsersetRewrite, allowedDirectRelations
 	return rel, nil
 }
 
+// MustAlias creates an alias of the relation or permission with the target name.
+func MustAlias(name string, target string) *core.Relation {
+	r, err := Alias(name, target)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// Alias creates an alias of the relation or permission with the target name, which is a
+// permission computing the target, marked as its alias.
+func Alias(name string, target string) (*core.Relation, error) {
+	rel := &core.Relation{
+		Name:           name,
+		UsersetRewrite: Union(ComputedUserset(target)),
+	}
+
+	encoded, err := anypb.New(&iv1.RelationMetadata{
+		Kind:    iv1.RelationMetadata_PERMISSION,
+		AliasOf: target,
+	})
+	if err != nil {
+		return nil, spiceerrors.MustBugf("failed to set alias: %s", err.Error())
+	}
+
+	rel.Metadata = &core.Metadata{MetadataMessage: []*anypb.Any{encoded}}
+	return rel, nil
+}
+
 // MustRelationWithComment creates a relation definition with an optional rewrite definition.
 func MustRelationWithComment(name string, comment string, rewrite *core.UsersetRewrite, allowedDirectRelations ...*core.AllowedRelation) *core.Relation {
 	rel := MustRelation(name, rewrite, allowedDirectRelations...)
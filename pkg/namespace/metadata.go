@@ -1,6 +1,8 @@
 package namespace
 
 import (
+	"strings"
+
 	"google.golang.org/protobuf/types/known/anypb"
 
 	core "github.com/authzed/spicedb/pkg/proto/core/v1"
@@ -54,6 +56,41 @@ func GetComments(metadata *core.Metadata) []string {
 	return comments
 }
 
+// GetDocumentation returns the text of the doc comments found within the given metadata message,
+// without the comment delimiters, with each comment on lines of its own.
+func GetDocumentation(metadata *core.Metadata) string {
+	var documentation []string
+	for _, comment := range GetComments(metadata) {
+		comment = strings.TrimSpace(comment)
+		if strings.HasPrefix(comment, "/*") {
+			comment = strings.TrimPrefix(strings.TrimPrefix(comment, "/*"), "*")
+			comment = strings.TrimSuffix(comment, "*/")
+		}
+
+		var lines []string
+		for _, line := range strings.Split(comment, "\n") {
+			line = strings.TrimSpace(line)
+			switch {
+			case strings.HasPrefix(line, "//"):
+				line = strings.TrimSpace(strings.TrimPrefix(line, "//"))
+			case strings.HasPrefix(line, "*"):
+				line = strings.TrimSpace(strings.TrimPrefix(line, "*"))
+			}
+			lines = append(lines, line)
+		}
+
+		// Remove the lines left blank by the delimiters of block comments.
+		for len(lines) > 0 && lines[0] == "" {
+			lines = lines[1:]
+		}
+		for len(lines) > 0 && lines[len(lines)-1] == "" {
+			lines = lines[:len(lines)-1]
+		}
+		documentation = append(documentation, lines...)
+	}
+	return strings.Join(documentation, "\n")
+}
+
 // AddComment adds a comment to the given metadata message.
 func AddComment(metadata *core.Metadata, comment string) (*core.Metadata, error) {
 	if metadata == nil {
@@ -89,6 +126,19 @@ func GetRelationKind(relation *core.Relation) iv1.RelationMetadata_RelationKind
 	return iv1.RelationMetadata_UNKNOWN_KIND
 }
 
+// GetAliasOf returns the name of the relation or permission of which the relation is an alias,
+// or empty if it is not an alias.
+func GetAliasOf(relation *core.Relation) string {
+	for _, msg := range relation.GetMetadata().GetMetadataMessage() {
+		var rm iv1.RelationMetadata
+		if err := msg.UnmarshalTo(&rm); err == nil {
+			return rm.AliasOf
+		}
+	}
+
+	return ""
+}
+
 // SetRelationKind sets the kind of relation.
 func SetRelationKind(relation *core.Relation, kind iv1.RelationMetadata_RelationKind) error {
 	metadata := relation.Metadata
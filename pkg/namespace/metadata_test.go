@@ -61,3 +61,19 @@ func TestMetadata(t *testing.T) {
 
 	require.Equal(iv1.RelationMetadata_PERMISSION, GetRelationKind(ns.Relation[0]))
 }
+
+func TestGetDocumentation(t *testing.T) {
+	metadata, err := AddComment(nil, "/**\n* user is a user\n*\n* of the system\n*/")
+	require.NoError(t, err)
+	require.Equal(t, "user is a user\n\nof the system", GetDocumentation(metadata))
+
+	metadata, err = AddComment(nil, "// first line")
+	require.NoError(t, err)
+	metadata, err = AddComment(metadata, "// second line")
+	require.NoError(t, err)
+	metadata, err = AddComment(metadata, "/* block */")
+	require.NoError(t, err)
+	require.Equal(t, "first line\nsecond line\nblock", GetDocumentation(metadata))
+
+	require.Equal(t, "", GetDocumentation(nil))
+}
@@ -3,6 +3,7 @@ package dispatchv1
 import (
 	"github.com/rs/zerolog"
 
+	"github.com/authzed/spicedb/pkg/redaction"
 	"github.com/authzed/spicedb/pkg/tuple"
 )
 
@@ -10,8 +11,8 @@ import (
 func (cr *DispatchCheckRequest) MarshalZerologObject(e *zerolog.Event) {
 	e.Object("metadata", cr.Metadata)
 	e.Str("resource-type", tuple.StringRR(cr.ResourceRelation))
-	e.Str("subject", tuple.StringONR(cr.Subject))
-	e.Array("resource-ids", strArray(cr.ResourceIds))
+	e.Str("subject", redaction.ONR(cr.Subject))
+	e.Array("resource-ids", strArray(redaction.ObjectIDs(cr.ResourceIds)))
 }
 
 // MarshalZerologObject implements zerolog object marshalling.
@@ -20,7 +21,7 @@ func (cr *DispatchCheckResponse) MarshalZerologObject(e *zerolog.Event) {
 
 	results := zerolog.Dict()
 	for resourceID, result := range cr.ResultsByResourceId {
-		results.Str(resourceID, ResourceCheckResult_Membership_name[int32(result.Membership)])
+		results.Str(redaction.ObjectID(resourceID), ResourceCheckResult_Membership_name[int32(result.Membership)])
 	}
 	e.Dict("results", results)
 }
@@ -28,7 +29,7 @@ func (cr *DispatchCheckResponse) MarshalZerologObject(e *zerolog.Event) {
 // MarshalZerologObject implements zerolog object marshalling.
 func (er *DispatchExpandRequest) MarshalZerologObject(e *zerolog.Event) {
 	e.Object("metadata", er.Metadata)
-	e.Str("expand", tuple.StringONR(er.ResourceAndRelation))
+	e.Str("expand", redaction.ONR(er.ResourceAndRelation))
 	e.Stringer("mode", er.ExpansionMode)
 }
 
@@ -41,8 +42,8 @@ func (cr *DispatchExpandResponse) MarshalZerologObject(e *zerolog.Event) {
 func (lr *DispatchLookupRequest) MarshalZerologObject(e *zerolog.Event) {
 	e.Object("metadata", lr.Metadata)
 	e.Str("object", tuple.StringRR(lr.ObjectRelation))
-	e.Str("subject", tuple.StringONR(lr.Subject))
-	e.Interface("context", lr.Context)
+	e.Str("subject", redaction.ONR(lr.Subject))
+	e.Interface("context", redaction.CaveatContext(lr.Context))
 	e.Uint32("limit", lr.Limit)
 }
 
@@ -51,7 +52,7 @@ func (lr *DispatchReachableResourcesRequest) MarshalZerologObject(e *zerolog.Eve
 	e.Object("metadata", lr.Metadata)
 	e.Str("resource-type", tuple.StringRR(lr.ResourceRelation))
 	e.Str("subject-type", tuple.StringRR(lr.SubjectRelation))
-	e.Array("subject-ids", strArray(lr.SubjectIds))
+	e.Array("subject-ids", strArray(redaction.ObjectIDs(lr.SubjectIds)))
 }
 
 // MarshalZerologObject implements zerolog object marshalling.
@@ -59,7 +60,7 @@ func (ls *DispatchLookupSubjectsRequest) MarshalZerologObject(e *zerolog.Event)
 	e.Object("metadata", ls.Metadata)
 	e.Str("resource-type", tuple.StringRR(ls.ResourceRelation))
 	e.Str("subject-type", tuple.StringRR(ls.SubjectRelation))
-	e.Array("resource-ids", strArray(ls.ResourceIds))
+	e.Array("resource-ids", strArray(redaction.ObjectIDs(ls.ResourceIds)))
 }
 
 type strArray []string
@@ -0,0 +1,174 @@
+// Package redaction implements a process-wide policy for hashing or masking
+// potentially sensitive values, such as object IDs and caveat context, before
+// they are emitted to logs and debug traces.
+package redaction
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"google.golang.org/protobuf/types/known/structpb"
+
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// Strategy is the way in which a value is redacted.
+type Strategy string
+
+const (
+	// StrategyNone leaves values unchanged.
+	StrategyNone Strategy = "none"
+
+	// StrategyHash replaces values with a truncated (optionally keyed) SHA-256
+	// hash, which allows for correlating values across entries without
+	// revealing them.
+	StrategyHash Strategy = "hash"
+
+	// StrategyMask replaces values with a fixed placeholder.
+	StrategyMask Strategy = "mask"
+)
+
+const (
+	maskedValue  = "<redacted>"
+	hashedPrefix = "sha256:"
+	hashedLength = 16
+)
+
+// Strategies is the list of supported redaction strategies.
+var Strategies = []string{string(StrategyNone), string(StrategyHash), string(StrategyMask)}
+
+// Policy defines which values are redacted and how.
+type Policy struct {
+	// ObjectIDs is the strategy applied to resource and subject object IDs.
+	ObjectIDs Strategy
+
+	// CaveatContext is the strategy applied to the values found in caveat
+	// contexts. Keys are left unchanged.
+	CaveatContext Strategy
+
+	// HashKey, if specified, is used to key the hash when StrategyHash is in use,
+	// which prevents recovering low-entropy values by brute force.
+	HashKey string
+}
+
+// Validate returns an error if the policy contains an unknown strategy.
+func (p Policy) Validate() error {
+	for name, strategy := range map[string]Strategy{
+		"object IDs":     p.ObjectIDs,
+		"caveat context": p.CaveatContext,
+	} {
+		switch strategy {
+		case "", StrategyNone, StrategyHash, StrategyMask:
+		default:
+			return fmt.Errorf("unknown redaction strategy `%s` for %s: must be one of %s", strategy, name, strings.Join(Strategies, ", "))
+		}
+	}
+	return nil
+}
+
+var current atomic.Pointer[Policy]
+
+// SetPolicy sets the process-wide redaction policy.
+func SetPolicy(p Policy) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	current.Store(&p)
+	return nil
+}
+
+// CurrentPolicy returns the process-wide redaction policy.
+func CurrentPolicy() Policy {
+	if p := current.Load(); p != nil {
+		return *p
+	}
+	return Policy{}
+}
+
+// ObjectID returns the object ID redacted according to the current policy.
+// The wildcard object ID is never redacted.
+func ObjectID(objectID string) string {
+	if objectID == tuple.PublicWildcard {
+		return objectID
+	}
+	p := CurrentPolicy()
+	return p.apply(p.ObjectIDs, objectID)
+}
+
+// ObjectIDs returns the object IDs redacted according to the current policy.
+func ObjectIDs(objectIDs []string) []string {
+	if !CurrentPolicy().redactsObjectIDs() {
+		return objectIDs
+	}
+
+	redacted := make([]string, 0, len(objectIDs))
+	for _, objectID := range objectIDs {
+		redacted = append(redacted, ObjectID(objectID))
+	}
+	return redacted
+}
+
+// ONR returns the string form of the object and relation, with its object ID
+// redacted according to the current policy.
+func ONR(onr *core.ObjectAndRelation) string {
+	if onr == nil || !CurrentPolicy().redactsObjectIDs() {
+		return tuple.StringONR(onr)
+	}
+
+	return tuple.StringONR(&core.ObjectAndRelation{
+		Namespace: onr.Namespace,
+		ObjectId:  ObjectID(onr.ObjectId),
+		Relation:  onr.Relation,
+	})
+}
+
+// CaveatContext returns a copy of the caveat context with all of its values
+// redacted according to the current policy.
+func CaveatContext(caveatContext *structpb.Struct) *structpb.Struct {
+	p := CurrentPolicy()
+	if caveatContext == nil || p.CaveatContext == "" || p.CaveatContext == StrategyNone {
+		return caveatContext
+	}
+
+	redacted := &structpb.Struct{Fields: make(map[string]*structpb.Value, len(caveatContext.Fields))}
+	for key, value := range caveatContext.Fields {
+		encoded, err := json.Marshal(value.AsInterface())
+		if err != nil {
+			encoded = []byte(value.String())
+		}
+		redacted.Fields[key] = structpb.NewStringValue(p.apply(p.CaveatContext, string(encoded)))
+	}
+	return redacted
+}
+
+func (p Policy) redactsObjectIDs() bool {
+	return p.ObjectIDs != "" && p.ObjectIDs != StrategyNone
+}
+
+func (p Policy) apply(strategy Strategy, value string) string {
+	switch strategy {
+	case StrategyHash:
+		var sum []byte
+		if p.HashKey != "" {
+			mac := hmac.New(sha256.New, []byte(p.HashKey))
+			mac.Write([]byte(value))
+			sum = mac.Sum(nil)
+		} else {
+			hashed := sha256.Sum256([]byte(value))
+			sum = hashed[:]
+		}
+		return hashedPrefix + hex.EncodeToString(sum)[:hashedLength]
+
+	case StrategyMask:
+		return maskedValue
+
+	default:
+		return value
+	}
+}
@@ -0,0 +1,69 @@
+package redaction
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+func withPolicy(t *testing.T, p Policy) {
+	require.NoError(t, SetPolicy(p))
+	t.Cleanup(func() {
+		require.NoError(t, SetPolicy(Policy{}))
+	})
+}
+
+func TestNoRedaction(t *testing.T) {
+	withPolicy(t, Policy{ObjectIDs: StrategyNone})
+
+	require.Equal(t, "tom", ObjectID("tom"))
+	require.Equal(t, []string{"a", "b"}, ObjectIDs([]string{"a", "b"}))
+	require.Equal(t, "user:tom", ONR(tuple.ParseSubjectONR("user:tom")))
+
+	caveatContext, err := structpb.NewStruct(map[string]any{"ip": "10.0.0.1"})
+	require.NoError(t, err)
+	require.Same(t, caveatContext, CaveatContext(caveatContext))
+}
+
+func TestHashRedaction(t *testing.T) {
+	withPolicy(t, Policy{ObjectIDs: StrategyHash, CaveatContext: StrategyHash})
+
+	hashed := ObjectID("tom")
+	require.NotEqual(t, "tom", hashed)
+	require.Equal(t, hashed, ObjectID("tom"))
+	require.NotEqual(t, hashed, ObjectID("fred"))
+	require.Len(t, hashed, len(hashedPrefix)+hashedLength)
+
+	require.Equal(t, tuple.PublicWildcard, ObjectID(tuple.PublicWildcard))
+	require.Equal(t, "document:"+ObjectID("firstdoc")+"#viewer", ONR(tuple.ParseONR("document:firstdoc#viewer")))
+
+	caveatContext, err := structpb.NewStruct(map[string]any{"ip": "10.0.0.1", "count": 42})
+	require.NoError(t, err)
+
+	redacted := CaveatContext(caveatContext)
+	require.Len(t, redacted.Fields, 2)
+	require.NotContains(t, redacted.Fields["ip"].GetStringValue(), "10.0.0.1")
+	require.Equal(t, "10.0.0.1", caveatContext.Fields["ip"].GetStringValue())
+
+	withPolicy(t, Policy{ObjectIDs: StrategyHash, HashKey: "somekey"})
+	require.NotEqual(t, hashed, ObjectID("tom"))
+}
+
+func TestMaskRedaction(t *testing.T) {
+	withPolicy(t, Policy{ObjectIDs: StrategyMask, CaveatContext: StrategyMask})
+
+	require.Equal(t, []string{maskedValue, maskedValue}, ObjectIDs([]string{"a", "b"}))
+
+	caveatContext, err := structpb.NewStruct(map[string]any{"ip": "10.0.0.1"})
+	require.NoError(t, err)
+	require.Equal(t, maskedValue, CaveatContext(caveatContext).Fields["ip"].GetStringValue())
+}
+
+func TestInvalidPolicy(t *testing.T) {
+	require.Error(t, SetPolicy(Policy{ObjectIDs: "scramble"}))
+	require.Error(t, SetPolicy(Policy{CaveatContext: "scramble"}))
+	require.Equal(t, Policy{}, CurrentPolicy())
+}
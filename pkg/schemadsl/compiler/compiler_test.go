@@ -5,6 +5,7 @@ import (
 
 	"github.com/stretchr/testify/require"
 	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/structpb"
 
 	"github.com/authzed/spicedb/pkg/caveats"
 	caveattypes "github.com/authzed/spicedb/pkg/caveats/types"
@@ -716,6 +717,280 @@ func TestCompile(t *testing.T) {
 					`someMap.isSubtreeOf(anotherMap)`),
 			},
 		},
+		{
+			"caveat with parameter defaults",
+			&someTenant,
+			`caveat foo(someParam int = 42, names list<string> = ["a", "b"], expiry duration = duration("1h")) {
+				someParam == 42 && "a" in names && expiry > duration("1m")
+			}`,
+			``,
+			[]SchemaDefinition{
+				withParameterDefaults(namespace.MustCaveatDefinition(caveats.MustEnvForVariables(
+					map[string]caveattypes.VariableType{
+						"someParam": caveattypes.IntType,
+						"names":     caveattypes.MustListType(caveattypes.StringType),
+						"expiry":    caveattypes.DurationType,
+					},
+				), "sometenant/foo", `someParam == 42 && "a" in names && expiry > duration("1m")`),
+					map[string]*structpb.Value{
+						"someParam": structpb.NewNumberValue(42),
+						"names": structpb.NewListValue(&structpb.ListValue{Values: []*structpb.Value{
+							structpb.NewStringValue("a"),
+							structpb.NewStringValue("b"),
+						}}),
+						"expiry": structpb.NewStringValue("3600s"),
+					}),
+			},
+		},
+		{
+			"caveat with context precedence",
+			&someTenant,
+			`caveat foo(someParam int) with precedence request_wins {
+				someParam == 42
+			}`,
+			``,
+			[]SchemaDefinition{
+				withContextPrecedence(namespace.MustCaveatDefinition(caveats.MustEnvForVariables(
+					map[string]caveattypes.VariableType{
+						"someParam": caveattypes.IntType,
+					},
+				), "sometenant/foo", "someParam == 42"), core.CaveatDefinition_REQUEST_WINS),
+			},
+		},
+		{
+			"caveat with unknown context precedence",
+			&someTenant,
+			`caveat foo(someParam int) with precedence whatever {
+				someParam == 42
+			}`,
+			"unknown context precedence `whatever` on caveat `foo`",
+			[]SchemaDefinition{},
+		},
+		{
+			"relation bounded in time",
+			&someTenant,
+			`definition user {}
+
+			definition document {
+				relation reader: user | user:*
+				permission view = reader.within(expiration)
+			}`,
+			``,
+			[]SchemaDefinition{
+				namespace.Namespace("sometenant/user"),
+				namespace.Namespace("sometenant/document",
+					namespace.MustRelation("reader", nil,
+						namespace.AllowedRelationWithCaveat("sometenant/user", "...", namespace.AllowedCaveat("sometenant/expiration")),
+						namespace.AllowedPublicNamespaceWithCaveat("sometenant/user", namespace.AllowedCaveat("sometenant/expiration")),
+					),
+					namespace.MustRelation("view",
+						namespace.Union(
+							namespace.ComputedUserset("reader"),
+						),
+					),
+				),
+				namespace.MustCaveatDefinition(caveats.MustEnvForVariables(
+					map[string]caveattypes.VariableType{
+						"expiration": caveattypes.TimestampType,
+					},
+				), "sometenant/expiration", "now() < expiration"),
+			},
+		},
+		{
+			"relation bounded in time by a defined caveat",
+			&someTenant,
+			`caveat expiration(expiration timestamp, grace duration) {
+				now() < expiration + grace
+			}
+
+			definition user {}
+
+			definition document {
+				relation reader: user | user with sometenant/expiration
+				permission view = reader.within(expiration) + reader.within(expiration)
+			}`,
+			``,
+			[]SchemaDefinition{
+				namespace.MustCaveatDefinition(caveats.MustEnvForVariables(
+					map[string]caveattypes.VariableType{
+						"expiration": caveattypes.TimestampType,
+						"grace":      caveattypes.DurationType,
+					},
+				), "sometenant/expiration", "now() < expiration + grace"),
+				namespace.Namespace("sometenant/user"),
+				namespace.Namespace("sometenant/document",
+					namespace.MustRelation("reader", nil,
+						namespace.AllowedRelationWithCaveat("sometenant/user", "...", namespace.AllowedCaveat("sometenant/expiration")),
+					),
+					namespace.MustRelation("view",
+						namespace.Union(
+							namespace.ComputedUserset("reader"),
+							namespace.ComputedUserset("reader"),
+						),
+					),
+				),
+			},
+		},
+		{
+			"relation bounded in time allowing another caveat",
+			&someTenant,
+			`caveat somecaveat(someparam int) {
+				someparam == 42
+			}
+
+			definition user {}
+
+			definition document {
+				relation reader: user | user with somecaveat
+				permission view = reader.within(expiration)
+			}`,
+			"`reader` is bounded by `within(sometenant/expiration)`, but allows relationships with caveat `somecaveat`",
+			[]SchemaDefinition{},
+		},
+		{
+			"relation bounded by two caveats",
+			&someTenant,
+			`definition user {}
+
+			definition document {
+				relation reader: user
+				permission view = reader.within(expiration)
+				permission edit = reader.within(deadline)
+			}`,
+			"`reader` is bounded by `within(sometenant/deadline)`, but allows relationships with caveat `sometenant/expiration`",
+			[]SchemaDefinition{},
+		},
+		{
+			"permission bounded in time",
+			&someTenant,
+			`definition document {
+				relation reader: document
+				permission edit = reader
+				permission view = edit.within(expiration)
+			}`,
+			"`within` can only be applied to relations, and `edit` is not a relation",
+			[]SchemaDefinition{},
+		},
+		{
+			"arrow bounded in time",
+			&someTenant,
+			`definition document {
+				relation parent: document
+				relation reader: document
+				permission view = parent.within(expiration)->reader
+			}`,
+			"`within` cannot be applied within an arrow",
+			[]SchemaDefinition{},
+		},
+		{
+			"definition with constraints",
+			&someTenant,
+			`definition document {
+				relation owner: user
+				relation approver: user
+				relation author: user
+
+				constraint owner at most 1
+				constraint approver excludes author
+			}`,
+			``,
+			[]SchemaDefinition{
+				withConstraints(namespace.Namespace("sometenant/document",
+					namespace.MustRelation("owner", nil,
+						namespace.AllowedRelation("sometenant/user", "..."),
+					),
+					namespace.MustRelation("approver", nil,
+						namespace.AllowedRelation("sometenant/user", "..."),
+					),
+					namespace.MustRelation("author", nil,
+						namespace.AllowedRelation("sometenant/user", "..."),
+					),
+				),
+					&core.RelationConstraint{
+						Relation: "owner",
+						Rule:     &core.RelationConstraint_MaximumRelationships{MaximumRelationships: 1},
+					},
+					&core.RelationConstraint{
+						Relation: "approver",
+						Rule:     &core.RelationConstraint_ExcludedRelation{ExcludedRelation: "author"},
+					},
+				),
+			},
+		},
+		{
+			"constraint with invalid maximum",
+			&someTenant,
+			`definition document {
+				relation owner: user
+				constraint owner at most 0
+			}`,
+			"invalid maximum `0` in constraint on relation `owner`",
+			[]SchemaDefinition{},
+		},
+		{
+			"constraint excluding its own relation",
+			&someTenant,
+			`definition document {
+				relation owner: user
+				constraint owner excludes owner
+			}`,
+			"relation `owner` cannot exclude itself",
+			[]SchemaDefinition{},
+		},
+		{
+			"definition with alias",
+			&someTenant,
+			`definition document {
+				relation viewer: user
+				alias reader = viewer
+			}`,
+			``,
+			[]SchemaDefinition{
+				namespace.Namespace("sometenant/document",
+					namespace.MustRelation("viewer", nil,
+						namespace.AllowedRelation("sometenant/user", "..."),
+					),
+					namespace.MustAlias("reader", "viewer"),
+				),
+			},
+		},
+		{
+			"alias without target",
+			&someTenant,
+			`definition document {
+				relation viewer: user
+				alias reader =
+			}`,
+			"Expected identifier",
+			[]SchemaDefinition{},
+		},
+		{
+			"caveat parameter default of the wrong type",
+			&someTenant,
+			`caveat foo(someParam int = "hello") {
+				someParam == 42
+			}`,
+			"invalid default for caveat parameter `someParam` on caveat `foo`",
+			[]SchemaDefinition{},
+		},
+		{
+			"caveat parameter default referencing a parameter",
+			&someTenant,
+			`caveat foo(someParam int, anotherParam int = someParam) {
+				someParam == anotherParam
+			}`,
+			"undeclared reference to 'someParam'",
+			[]SchemaDefinition{},
+		},
+		{
+			"caveat parameter missing default",
+			&someTenant,
+			`caveat foo(someParam int = ) {
+				someParam == 42
+			}`,
+			"parse error in `caveat parameter missing default`, line 1, column 28: Unexpected token at root level: TokenTypeRightParen",
+			[]SchemaDefinition{},
+		},
 	}
 
 	for _, test := range tests {
@@ -747,6 +1022,12 @@ func TestCompile(t *testing.T) {
 							testutil.RequireProtoEqual(t, expectedParam, foundParam, "mismatch type for parameter %s", expectedParamName)
 						}
 
+						require.Equal(expectedCaveatDef.ContextPrecedence, caveatDef.ContextPrecedence)
+						require.Equal(len(expectedCaveatDef.ParameterDefaults), len(caveatDef.ParameterDefaults))
+						for expectedParamName, expectedDefault := range expectedCaveatDef.ParameterDefaults {
+							testutil.RequireProtoEqual(t, expectedDefault, caveatDef.ParameterDefaults[expectedParamName], "mismatch default for parameter %s", expectedParamName)
+						}
+
 						expectedDecoded, err := caveats.DeserializeCaveat(expectedCaveatDef.SerializedExpression)
 						require.NoError(err)
 
@@ -769,6 +1050,41 @@ func TestCompile(t *testing.T) {
 	}
 }
 
+func TestWithinCaveatParameter(t *testing.T) {
+	compiled, err := Compile(InputSchema{
+		input.Source("schema"),
+		`caveat deadline(deadline timestamp, grace duration) {
+			now() < deadline + grace
+		}
+
+		caveat overridable(overridable timestamp) with precedence request_wins {
+			now() < overridable
+		}
+
+		definition user {}
+
+		definition document {
+			relation reader: user
+			relation editor: user with deadline
+			relation approver: user with overridable
+			permission view = reader.within(expiration)
+			permission edit = editor.within(deadline)
+			permission approve = approver.within(overridable)
+		}`,
+	}, &someTenant)
+	require.NoError(t, err)
+
+	parameters := make(map[string]string, len(compiled.CaveatDefinitions))
+	for _, caveatDef := range compiled.CaveatDefinitions {
+		if parameter, ok := WithinCaveatParameter(caveatDef); ok {
+			parameters[caveatDef.Name] = parameter
+		}
+	}
+
+	// Only the synthesized caveat bounds its relationships by their own timestamp alone.
+	require.Equal(t, map[string]string{"sometenant/expiration": "expiration"}, parameters)
+}
+
 func filterSourcePositions(m protoreflect.Message) {
 	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
 		if fd.Kind() == protoreflect.MessageKind {
@@ -794,3 +1110,18 @@ func filterSourcePositions(m protoreflect.Message) {
 		return true
 	})
 }
+
+func withParameterDefaults(def *core.CaveatDefinition, defaults map[string]*structpb.Value) *core.CaveatDefinition {
+	def.ParameterDefaults = defaults
+	return def
+}
+
+func withContextPrecedence(def *core.CaveatDefinition, precedence core.CaveatDefinition_ContextPrecedence) *core.CaveatDefinition {
+	def.ContextPrecedence = precedence
+	return def
+}
+
+func withConstraints(def *core.NamespaceDefinition, constraints ...*core.RelationConstraint) *core.NamespaceDefinition {
+	def.Constraints = constraints
+	return def
+}
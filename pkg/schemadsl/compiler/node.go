@@ -113,7 +113,7 @@ func (tn *dslNode) GetChildren() []*dslNode {
 
 func (tn *dslNode) FindAll(nodeType dslshape.NodeType) []*dslNode {
 	found := []*dslNode{}
-	if tn.nodeType == dslshape.NodeTypeError {
+	if tn.nodeType == nodeType {
 		found = append(found, tn)
 	}
 
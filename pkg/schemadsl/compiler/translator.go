@@ -3,6 +3,7 @@ package compiler
 import (
 	"bufio"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/authzed/spicedb/pkg/caveats"
@@ -10,6 +11,7 @@ import (
 	"github.com/authzed/spicedb/pkg/util"
 
 	"github.com/jzelinskie/stringz"
+	"google.golang.org/protobuf/types/known/structpb"
 
 	core "github.com/authzed/spicedb/pkg/proto/core/v1"
 
@@ -81,6 +83,16 @@ func translate(tctx translationContext, root *dslNode) (*CompiledSchema, error)
 		orderedDefinitions = append(orderedDefinitions, definition)
 	}
 
+	withinCaveats, err := translateWithinCaveats(tctx, root, names)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, def := range withinCaveats {
+		caveatDefinitions = append(caveatDefinitions, def)
+		orderedDefinitions = append(orderedDefinitions, def)
+	}
+
 	return &CompiledSchema{
 		CaveatDefinitions:  caveatDefinitions,
 		ObjectDefinitions:  objectDefinitions,
@@ -102,6 +114,7 @@ func translateCaveatDefinition(tctx translationContext, defNode *dslNode) (*core
 
 	env := caveats.NewEnvironment()
 	parameters := make(map[string]caveattypes.VariableType, len(paramNodes))
+	parameterDefaults := map[string]*structpb.Value{}
 	for _, paramNode := range paramNodes {
 		paramName, err := paramNode.GetString(dslshape.NodeCaveatParameterPredicateName)
 		if err != nil {
@@ -127,6 +140,20 @@ func translateCaveatDefinition(tctx translationContext, defNode *dslNode) (*core
 		if err != nil {
 			return nil, paramNode.ErrorWithSourcef(paramName, "invalid type for caveat parameter `%s` on caveat `%s`: %w", paramName, definitionName, err)
 		}
+
+		if paramNode.Has(dslshape.NodeCaveatParameterPredicateDefault) {
+			defaultExpression, err := paramNode.GetString(dslshape.NodeCaveatParameterPredicateDefault)
+			if err != nil {
+				return nil, paramNode.ErrorWithSourcef(paramName, "invalid default for parameter: %w", err)
+			}
+
+			defaultValue, err := caveats.EvaluateParameterDefault(defaultExpression, *translatedType)
+			if err != nil {
+				return nil, paramNode.ErrorWithSourcef(defaultExpression, "invalid default for caveat parameter `%s` on caveat `%s`: %w", paramName, definitionName, err)
+			}
+
+			parameterDefaults[paramName] = defaultValue
+		}
 	}
 
 	caveatPath, err := tctx.prefixedPath(definitionName)
@@ -165,6 +192,24 @@ func translateCaveatDefinition(tctx translationContext, defNode *dslNode) (*core
 		return nil, err
 	}
 
+	if len(parameterDefaults) > 0 {
+		def.ParameterDefaults = parameterDefaults
+	}
+
+	if defNode.Has(dslshape.NodeCaveatDefinitionPredicateContextPrecedence) {
+		policy, err := defNode.GetString(dslshape.NodeCaveatDefinitionPredicateContextPrecedence)
+		if err != nil {
+			return nil, defNode.ErrorWithSourcef(definitionName, "invalid context precedence: %w", err)
+		}
+
+		precedence, ok := core.CaveatDefinition_ContextPrecedence_value[strings.ToUpper(policy)]
+		if !ok || policy != strings.ToLower(policy) {
+			return nil, defNode.ErrorWithSourcef(policy, "unknown context precedence `%s` on caveat `%s`: expected `relationship_wins`, `request_wins` or `error_on_conflict`", policy, definitionName)
+		}
+
+		def.ContextPrecedence = core.CaveatDefinition_ContextPrecedence(precedence)
+	}
+
 	def.Metadata = addComments(def.Metadata, defNode)
 	def.SourcePosition = getSourcePosition(defNode, tctx.mapper)
 	return def, nil
@@ -201,11 +246,22 @@ func translateObjectDefinition(tctx translationContext, defNode *dslNode) (*core
 	}
 
 	relationsAndPermissions := []*core.Relation{}
+	var constraints []*core.RelationConstraint
 	for _, relationOrPermissionNode := range defNode.GetChildren() {
 		if relationOrPermissionNode.GetType() == dslshape.NodeTypeComment {
 			continue
 		}
 
+		if relationOrPermissionNode.GetType() == dslshape.NodeTypeConstraint {
+			constraint, err := translateConstraint(tctx, relationOrPermissionNode)
+			if err != nil {
+				return nil, err
+			}
+
+			constraints = append(constraints, constraint)
+			continue
+		}
+
 		relationOrPermission, err := translateRelationOrPermission(tctx, relationOrPermissionNode)
 		if err != nil {
 			return nil, err
@@ -214,6 +270,10 @@ func translateObjectDefinition(tctx translationContext, defNode *dslNode) (*core
 		relationsAndPermissions = append(relationsAndPermissions, relationOrPermission)
 	}
 
+	if err := applyWithinReferences(tctx, defNode, relationsAndPermissions); err != nil {
+		return nil, err
+	}
+
 	nspath, err := tctx.prefixedPath(definitionName)
 	if err != nil {
 		return nil, defNode.Errorf("%w", err)
@@ -222,6 +282,7 @@ func translateObjectDefinition(tctx translationContext, defNode *dslNode) (*core
 	if len(relationsAndPermissions) == 0 {
 		ns := namespace.Namespace(nspath)
 		ns.Metadata = addComments(ns.Metadata, defNode)
+		ns.Constraints = constraints
 
 		err = ns.Validate()
 		if err != nil {
@@ -234,6 +295,7 @@ func translateObjectDefinition(tctx translationContext, defNode *dslNode) (*core
 	ns := namespace.Namespace(nspath, relationsAndPermissions...)
 	ns.Metadata = addComments(ns.Metadata, defNode)
 	ns.SourcePosition = getSourcePosition(defNode, tctx.mapper)
+	ns.Constraints = constraints
 
 	err = ns.Validate()
 	if err != nil {
@@ -286,6 +348,45 @@ func normalizeComment(value string) string {
 	return strings.Join(lines, "\n")
 }
 
+func translateConstraint(tctx translationContext, constraintNode *dslNode) (*core.RelationConstraint, error) {
+	relationName, err := constraintNode.GetString(dslshape.NodeConstraintPredicateRelation)
+	if err != nil {
+		return nil, constraintNode.Errorf("invalid constraint relation name: %w", err)
+	}
+
+	constraint := &core.RelationConstraint{
+		Relation:       relationName,
+		SourcePosition: getSourcePosition(constraintNode, tctx.mapper),
+	}
+
+	if constraintNode.Has(dslshape.NodeConstraintPredicateMaximum) {
+		maximumStr, err := constraintNode.GetString(dslshape.NodeConstraintPredicateMaximum)
+		if err != nil {
+			return nil, constraintNode.Errorf("invalid constraint maximum: %w", err)
+		}
+
+		maximum, err := strconv.ParseUint(maximumStr, 10, 32)
+		if err != nil || maximum == 0 {
+			return nil, constraintNode.ErrorWithSourcef(maximumStr, "invalid maximum `%s` in constraint on relation `%s`: expected a positive number", maximumStr, relationName)
+		}
+
+		constraint.Rule = &core.RelationConstraint_MaximumRelationships{MaximumRelationships: uint32(maximum)}
+		return constraint, nil
+	}
+
+	excludedRelationName, err := constraintNode.GetString(dslshape.NodeConstraintPredicateExcludedRelation)
+	if err != nil {
+		return nil, constraintNode.Errorf("invalid constraint excluded relation name: %w", err)
+	}
+
+	if excludedRelationName == relationName {
+		return nil, constraintNode.ErrorWithSourcef(excludedRelationName, "relation `%s` cannot exclude itself", relationName)
+	}
+
+	constraint.Rule = &core.RelationConstraint_ExcludedRelation{ExcludedRelation: excludedRelationName}
+	return constraint, nil
+}
+
 func translateRelationOrPermission(tctx translationContext, relOrPermNode *dslNode) (*core.Relation, error) {
 	switch relOrPermNode.GetType() {
 	case dslshape.NodeTypeRelation:
@@ -306,6 +407,15 @@ func translateRelationOrPermission(tctx translationContext, relOrPermNode *dslNo
 		rel.SourcePosition = getSourcePosition(relOrPermNode, tctx.mapper)
 		return rel, err
 
+	case dslshape.NodeTypeAlias:
+		rel, err := translateAlias(relOrPermNode)
+		if err != nil {
+			return nil, err
+		}
+		rel.Metadata = addComments(rel.Metadata, relOrPermNode)
+		rel.SourcePosition = getSourcePosition(relOrPermNode, tctx.mapper)
+		return rel, err
+
 	default:
 		return nil, relOrPermNode.Errorf("unknown definition top-level node type %s", relOrPermNode.GetType())
 	}
@@ -340,6 +450,30 @@ func translateRelation(tctx translationContext, relationNode *dslNode) (*core.Re
 	return relation, nil
 }
 
+func translateAlias(aliasNode *dslNode) (*core.Relation, error) {
+	aliasName, err := aliasNode.GetString(dslshape.NodePredicateName)
+	if err != nil {
+		return nil, aliasNode.Errorf("invalid alias name: %w", err)
+	}
+
+	targetName, err := aliasNode.GetString(dslshape.NodeAliasPredicateTarget)
+	if err != nil {
+		return nil, aliasNode.Errorf("invalid alias target: %w", err)
+	}
+
+	alias, err := namespace.Alias(aliasName, targetName)
+	if err != nil {
+		return nil, err
+	}
+
+	err = alias.Validate()
+	if err != nil {
+		return nil, aliasNode.Errorf("error in alias %s: %w", aliasName, err)
+	}
+
+	return alias, nil
+}
+
 func translatePermission(tctx translationContext, permissionNode *dslNode) (*core.Relation, error) {
 	permissionName, err := permissionNode.GetString(dslshape.NodePredicateName)
 	if err != nil {
@@ -474,6 +608,10 @@ func translateExpressionOperationDirect(tctx translationContext, expressionOpNod
 			return nil, leftChild.Errorf("Nested arrows not yet supported")
 		}
 
+		if leftChild.Has(dslshape.NodeIdentiferPredicateWithinCaveat) || rightChild.Has(dslshape.NodeIdentiferPredicateWithinCaveat) {
+			return nil, expressionOpNode.Errorf("`within` cannot be applied within an arrow")
+		}
+
 		tuplesetRelation, err := leftChild.GetString(dslshape.NodeIdentiferPredicateValue)
 		if err != nil {
 			return nil, err
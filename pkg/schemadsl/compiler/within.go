@@ -0,0 +1,199 @@
+package compiler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/authzed/spicedb/pkg/caveats"
+	caveattypes "github.com/authzed/spicedb/pkg/caveats/types"
+	"github.com/authzed/spicedb/pkg/namespace"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/schemadsl/dslshape"
+	"github.com/authzed/spicedb/pkg/util"
+)
+
+// A relation referenced as `somerelation.within(somecaveat)` in a permission is bounded in time:
+// its relationships must be written with the caveat `somecaveat`, which grants access only until
+// the timestamp given as its `somecaveat` parameter. Unless defined in the schema, the caveat is
+// synthesized as:
+//
+//	caveat somecaveat(somecaveat timestamp) {
+//		now() < somecaveat
+//	}
+//
+// As the bound is placed on the relationships, it applies wherever the relation is used. Once
+// their bound has passed, the relationships of synthesized caveats are deleted by the server (see
+// WithinCaveatParameter).
+
+// applyWithinReferences requires the time-bounding caveat on each relation of the definition
+// referenced with `within`, replacing its uncaveated allowed types by caveated ones.
+func applyWithinReferences(tctx translationContext, defNode *dslNode, relationsAndPermissions []*core.Relation) error {
+	relations := make(map[string]*core.Relation, len(relationsAndPermissions))
+	for _, relation := range relationsAndPermissions {
+		relations[relation.Name] = relation
+	}
+
+	for _, identNode := range defNode.FindAll(dslshape.NodeTypeIdentifier) {
+		if !identNode.Has(dslshape.NodeIdentiferPredicateWithinCaveat) {
+			continue
+		}
+
+		relationName, err := identNode.GetString(dslshape.NodeIdentiferPredicateValue)
+		if err != nil {
+			return identNode.Errorf("invalid relation name: %w", err)
+		}
+
+		caveatName, err := withinCaveatPath(tctx, identNode)
+		if err != nil {
+			return err
+		}
+
+		relation, ok := relations[relationName]
+		if !ok || relation.UsersetRewrite != nil {
+			return identNode.ErrorWithSourcef(relationName, "`within` can only be applied to relations, and `%s` is not a relation", relationName)
+		}
+
+		bounded := make([]*core.AllowedRelation, 0, len(relation.TypeInformation.AllowedDirectRelations))
+		for _, allowedRelation := range relation.TypeInformation.AllowedDirectRelations {
+			if allowedRelation.RequiredCaveat != nil {
+				requiredCaveat, err := tctx.prefixedPath(allowedRelation.RequiredCaveat.CaveatName)
+				if err != nil || requiredCaveat != caveatName {
+					return identNode.ErrorWithSourcef(relationName, "`%s` is bounded by `within(%s)`, but allows relationships with caveat `%s`", relationName, caveatName, allowedRelation.RequiredCaveat.CaveatName)
+				}
+			}
+
+			if hasAllowedRelationWithCaveat(bounded, allowedRelation, caveatName) {
+				continue
+			}
+
+			allowedRelation = allowedRelation.CloneVT()
+			allowedRelation.RequiredCaveat = namespace.AllowedCaveat(caveatName)
+			bounded = append(bounded, allowedRelation)
+		}
+		relation.TypeInformation.AllowedDirectRelations = bounded
+	}
+
+	return nil
+}
+
+// translateWithinCaveats returns the time-bounding caveats referenced with `within` that are not
+// defined in the schema.
+func translateWithinCaveats(tctx translationContext, root *dslNode, definedNames *util.Set[string]) ([]*core.CaveatDefinition, error) {
+	caveatNames := util.NewSet[string]()
+	for _, identNode := range root.FindAll(dslshape.NodeTypeIdentifier) {
+		if !identNode.Has(dslshape.NodeIdentiferPredicateWithinCaveat) {
+			continue
+		}
+
+		caveatName, err := withinCaveatPath(tctx, identNode)
+		if err != nil {
+			return nil, err
+		}
+
+		if !definedNames.Has(caveatName) {
+			caveatNames.Add(caveatName)
+		}
+	}
+
+	sortedNames := caveatNames.AsSlice()
+	sort.Strings(sortedNames)
+
+	caveatDefs := make([]*core.CaveatDefinition, 0, len(sortedNames))
+	for _, caveatName := range sortedNames {
+		caveatDef, err := withinCaveat(caveatName)
+		if err != nil {
+			return nil, err
+		}
+		caveatDefs = append(caveatDefs, caveatDef)
+	}
+	return caveatDefs, nil
+}
+
+func withinCaveatPath(tctx translationContext, identNode *dslNode) (string, error) {
+	caveatName, err := identNode.GetString(dslshape.NodeIdentiferPredicateWithinCaveat)
+	if err != nil {
+		return "", identNode.Errorf("invalid caveat name: %w", err)
+	}
+
+	caveatPath, err := tctx.prefixedPath(caveatName)
+	if err != nil {
+		return "", identNode.Errorf("%w", err)
+	}
+
+	return caveatPath, nil
+}
+
+func withinCaveat(caveatPath string) (*core.CaveatDefinition, error) {
+	parameterName := caveatPath[strings.LastIndex(caveatPath, "/")+1:]
+
+	env := caveats.NewEnvironment()
+	if err := env.AddVariable(parameterName, caveattypes.TimestampType); err != nil {
+		return nil, err
+	}
+
+	compiled, err := caveats.CompileCaveatWithName(env, fmt.Sprintf("now() < %s", parameterName), caveatPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return namespace.CompiledCaveatDefinition(env, caveatPath, compiled)
+}
+
+func hasAllowedRelationWithCaveat(allowed []*core.AllowedRelation, allowedRelation *core.AllowedRelation, caveatName string) bool {
+	for _, existing := range allowed {
+		sameWildcard := (existing.GetPublicWildcard() != nil) == (allowedRelation.GetPublicWildcard() != nil)
+		if existing.Namespace == allowedRelation.Namespace &&
+			existing.GetRelation() == allowedRelation.GetRelation() &&
+			sameWildcard &&
+			existing.GetRequiredCaveat().GetCaveatName() == caveatName {
+			return true
+		}
+	}
+	return false
+}
+
+// WithinCaveatParameter returns the name of the timestamp parameter of the caveat, if the caveat
+// is one synthesized for `within`, and false otherwise. Once the timestamp stored on a relationship
+// with the caveat has passed, the relationship can never grant access again, as the relationship's
+// value of the parameter takes precedence over that of any request, and can therefore be deleted.
+func WithinCaveatParameter(caveatDef *core.CaveatDefinition) (string, bool) {
+	if caveatDef.ContextPrecedence == core.CaveatDefinition_REQUEST_WINS {
+		return "", false
+	}
+
+	synthesized, err := withinCaveat(caveatDef.Name)
+	if err != nil {
+		return "", false
+	}
+
+	// The serialized expressions are compared in their string form, as their encoding is not
+	// deterministic.
+	expected, err := caveatExprString(synthesized)
+	if err != nil {
+		return "", false
+	}
+	actual, err := caveatExprString(caveatDef)
+	if err != nil || actual != expected {
+		return "", false
+	}
+
+	if len(caveatDef.ParameterTypes) != len(synthesized.ParameterTypes) {
+		return "", false
+	}
+	for name, parameterType := range synthesized.ParameterTypes {
+		if !parameterType.EqualVT(caveatDef.ParameterTypes[name]) {
+			return "", false
+		}
+		return name, true
+	}
+	return "", false
+}
+
+func caveatExprString(caveatDef *core.CaveatDefinition) (string, error) {
+	compiled, err := caveats.DeserializeCaveat(caveatDef.SerializedExpression)
+	if err != nil {
+		return "", err
+	}
+	return compiled.ExprString()
+}
@@ -20,6 +20,8 @@ const (
 
 	NodeTypeRelation   // A relation
 	NodeTypePermission // A permission
+	NodeTypeConstraint // A constraint on the relationships of a relation
+	NodeTypeAlias      // An alias of a relation or permission
 
 	NodeTypeTypeReference         // A type reference
 	NodeTypeSpecificTypeReference // A reference to a specific type.
@@ -91,6 +93,9 @@ const (
 	// The link to the expression for the definition.
 	NodeCaveatDefinitionPredicateExpession = "caveat-definition-expression"
 
+	// The policy for merging relationship and request contexts for the definition, if any.
+	NodeCaveatDefinitionPredicateContextPrecedence = "caveat-context-precedence"
+
 	//
 	// NodeTypeCaveatExpession
 	//
@@ -108,6 +113,9 @@ const (
 	// The defined type of the caveat parameter.
 	NodeCaveatParameterPredicateType = "caveat-parameter-type"
 
+	// The expression of the default value of the caveat parameter, if any.
+	NodeCaveatParameterPredicateDefault = "caveat-parameter-default"
+
 	//
 	// NodeTypeCaveatTypeReference
 	//
@@ -119,7 +127,7 @@ const (
 	NodeCaveatTypeReferencePredicateChildTypes = "child-types"
 
 	//
-	// NodeTypeRelation + NodeTypePermission
+	// NodeTypeRelation + NodeTypePermission + NodeTypeAlias
 	//
 
 	// The name of the relation/permission
@@ -132,6 +140,26 @@ const (
 	// The allowed types for the relation.
 	NodeRelationPredicateAllowedTypes = "allowed-types"
 
+	//
+	// NodeTypeConstraint
+	//
+
+	// The name of the relation constrained.
+	NodeConstraintPredicateRelation = "constraint-relation"
+
+	// The maximum number of relationships of the relation, for an `at most` constraint.
+	NodeConstraintPredicateMaximum = "constraint-maximum"
+
+	// The name of the relation excluded, for an `excludes` constraint.
+	NodeConstraintPredicateExcludedRelation = "constraint-excluded-relation"
+
+	//
+	// NodeTypeAlias
+	//
+
+	// The name of the relation or permission aliased.
+	NodeAliasPredicateTarget = "alias-target"
+
 	//
 	// NodeTypeTypeReference
 	//
@@ -176,6 +204,9 @@ const (
 	// The value of the identifier.
 	NodeIdentiferPredicateValue = "identifier-value"
 
+	// The name of the caveat bounding the relationships of the identified relation in time, if any.
+	NodeIdentiferPredicateWithinCaveat = "identifier-within-caveat"
+
 	//
 	// NodeTypeUnionExpression + NodeTypeIntersectExpression + NodeTypeExclusionExpression + NodeTypeArrowExpression
 	//
@@ -17,21 +17,23 @@ func _() {
 	_ = x[NodeTypeCaveatExpession-6]
 	_ = x[NodeTypeRelation-7]
 	_ = x[NodeTypePermission-8]
-	_ = x[NodeTypeTypeReference-9]
-	_ = x[NodeTypeSpecificTypeReference-10]
-	_ = x[NodeTypeCaveatReference-11]
-	_ = x[NodeTypeUnionExpression-12]
-	_ = x[NodeTypeIntersectExpression-13]
-	_ = x[NodeTypeExclusionExpression-14]
-	_ = x[NodeTypeArrowExpression-15]
-	_ = x[NodeTypeIdentifier-16]
-	_ = x[NodeTypeNilExpression-17]
-	_ = x[NodeTypeCaveatTypeReference-18]
+	_ = x[NodeTypeConstraint-9]
+	_ = x[NodeTypeAlias-10]
+	_ = x[NodeTypeTypeReference-11]
+	_ = x[NodeTypeSpecificTypeReference-12]
+	_ = x[NodeTypeCaveatReference-13]
+	_ = x[NodeTypeUnionExpression-14]
+	_ = x[NodeTypeIntersectExpression-15]
+	_ = x[NodeTypeExclusionExpression-16]
+	_ = x[NodeTypeArrowExpression-17]
+	_ = x[NodeTypeIdentifier-18]
+	_ = x[NodeTypeNilExpression-19]
+	_ = x[NodeTypeCaveatTypeReference-20]
 }
 
-const _NodeType_name = "NodeTypeErrorNodeTypeFileNodeTypeCommentNodeTypeDefinitionNodeTypeCaveatDefinitionNodeTypeCaveatParameterNodeTypeCaveatExpessionNodeTypeRelationNodeTypePermissionNodeTypeTypeReferenceNodeTypeSpecificTypeReferenceNodeTypeCaveatReferenceNodeTypeUnionExpressionNodeTypeIntersectExpressionNodeTypeExclusionExpressionNodeTypeArrowExpressionNodeTypeIdentifierNodeTypeNilExpressionNodeTypeCaveatTypeReference"
+const _NodeType_name = "NodeTypeErrorNodeTypeFileNodeTypeCommentNodeTypeDefinitionNodeTypeCaveatDefinitionNodeTypeCaveatParameterNodeTypeCaveatExpessionNodeTypeRelationNodeTypePermissionNodeTypeConstraintNodeTypeAliasNodeTypeTypeReferenceNodeTypeSpecificTypeReferenceNodeTypeCaveatReferenceNodeTypeUnionExpressionNodeTypeIntersectExpressionNodeTypeExclusionExpressionNodeTypeArrowExpressionNodeTypeIdentifierNodeTypeNilExpressionNodeTypeCaveatTypeReference"
 
-var _NodeType_index = [...]uint16{0, 13, 25, 40, 58, 82, 105, 128, 144, 162, 183, 212, 235, 258, 285, 312, 335, 353, 374, 401}
+var _NodeType_index = [...]uint16{0, 13, 25, 40, 58, 82, 105, 128, 144, 162, 180, 193, 214, 243, 266, 289, 316, 343, 366, 384, 405, 432}
 
 func (i NodeType) String() string {
 	if i < 0 || i >= NodeType(len(_NodeType_index)-1) {
@@ -4,9 +4,11 @@ import (
 	"bufio"
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
 
 	"golang.org/x/exp/maps"
+	"google.golang.org/protobuf/types/known/structpb"
 
 	"github.com/authzed/spicedb/pkg/caveats"
 	caveattypes "github.com/authzed/spicedb/pkg/caveats/types"
@@ -14,6 +16,7 @@ import (
 	"github.com/authzed/spicedb/pkg/namespace"
 	core "github.com/authzed/spicedb/pkg/proto/core/v1"
 	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
+	"github.com/authzed/spicedb/pkg/schemadsl/input"
 	"github.com/authzed/spicedb/pkg/spiceerrors"
 )
 
@@ -55,6 +58,36 @@ func GenerateSchema(definitions []compiler.SchemaDefinition) (string, bool, erro
 	return strings.Join(generated, "\n\n"), result, nil
 }
 
+// FormatSchema formats the schema canonically, as generated from its compiled definitions, with
+// the definitions in the order in which they are written, or ordered by name if sortDefinitions
+// is true. Comments which are not attached to a definition, relation or permission are dropped.
+func FormatSchema(schemaText string, sortDefinitions bool) (string, error) {
+	emptyDefaultPrefix := ""
+	compiled, err := compiler.Compile(compiler.InputSchema{
+		Source:       input.Source("schema"),
+		SchemaString: schemaText,
+	}, &emptyDefaultPrefix)
+	if err != nil {
+		return "", err
+	}
+
+	definitions := compiled.OrderedDefinitions
+	if sortDefinitions {
+		sort.SliceStable(definitions, func(i, j int) bool {
+			return definitions[i].GetName() < definitions[j].GetName()
+		})
+	}
+
+	formatted, ok, err := GenerateSchema(definitions)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", fmt.Errorf("schema could not be formatted")
+	}
+	return formatted, nil
+}
+
 // GenerateCaveatSource generates a DSL view of the given caveat definition.
 func GenerateCaveatSource(caveat *core.CaveatDefinition) (string, bool, error) {
 	generator := &sourceGenerator{
@@ -111,10 +144,20 @@ func (sg *sourceGenerator) emitCaveat(caveat *core.CaveatDefinition) error {
 		sg.append(paramName)
 		sg.append(" ")
 		sg.append(decoded.String())
+
+		if defaultValue, ok := caveat.ParameterDefaults[paramName]; ok {
+			sg.append(" = ")
+			sg.append(celLiteral(defaultValue))
+		}
 	}
 
 	sg.append(")")
 
+	if caveat.ContextPrecedence != core.CaveatDefinition_RELATIONSHIP_WINS {
+		sg.append(" with precedence ")
+		sg.append(strings.ToLower(caveat.ContextPrecedence.String()))
+	}
+
 	sg.append(" {")
 	sg.appendLine()
 	sg.indent()
@@ -160,6 +203,13 @@ func (sg *sourceGenerator) emitNamespace(namespace *core.NamespaceDefinition) er
 		}
 	}
 
+	if len(namespace.Constraints) > 0 {
+		sg.appendLine()
+		for _, constraint := range namespace.Constraints {
+			sg.emitConstraint(constraint)
+		}
+	}
+
 	sg.dedent()
 	sg.append("}")
 	return nil
@@ -174,6 +224,15 @@ func (sg *sourceGenerator) emitRelation(relation *core.Relation) error {
 	isPermission := relation.UsersetRewrite != nil && !hasThis
 
 	sg.emitComments(relation.Metadata)
+	if aliasOf := namespace.GetAliasOf(relation); aliasOf != "" {
+		sg.append("alias ")
+		sg.append(relation.Name)
+		sg.append(" = ")
+		sg.append(aliasOf)
+		sg.appendLine()
+		return nil
+	}
+
 	if isPermission {
 		sg.append("permission ")
 	} else {
@@ -206,6 +265,24 @@ func (sg *sourceGenerator) emitRelation(relation *core.Relation) error {
 	return nil
 }
 
+func (sg *sourceGenerator) emitConstraint(constraint *core.RelationConstraint) {
+	sg.append("constraint ")
+	sg.append(constraint.Relation)
+
+	switch rule := constraint.Rule.(type) {
+	case *core.RelationConstraint_MaximumRelationships:
+		sg.append(" at most ")
+		sg.append(strconv.FormatUint(uint64(rule.MaximumRelationships), 10))
+	case *core.RelationConstraint_ExcludedRelation:
+		sg.append(" excludes ")
+		sg.append(rule.ExcludedRelation)
+	default:
+		sg.appendIssue("unknown constraint rule")
+	}
+
+	sg.appendLine()
+}
+
 func (sg *sourceGenerator) emitAllowedRelation(allowedRelation *core.AllowedRelation) {
 	sg.append(allowedRelation.Namespace)
 	if allowedRelation.GetRelation() != "" && allowedRelation.GetRelation() != Ellipsis {
@@ -340,3 +417,37 @@ func (sg *sourceGenerator) appendComment(comment string) {
 		sg.appendLine()
 	}
 }
+
+// celLiteral returns the CEL literal for a value found in a caveat context.
+func celLiteral(value *structpb.Value) string {
+	switch kind := value.Kind.(type) {
+	case *structpb.Value_BoolValue:
+		return strconv.FormatBool(kind.BoolValue)
+
+	case *structpb.Value_NumberValue:
+		return strconv.FormatFloat(kind.NumberValue, 'f', -1, 64)
+
+	case *structpb.Value_StringValue:
+		return strconv.Quote(kind.StringValue)
+
+	case *structpb.Value_ListValue:
+		elements := make([]string, 0, len(kind.ListValue.Values))
+		for _, element := range kind.ListValue.Values {
+			elements = append(elements, celLiteral(element))
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+
+	case *structpb.Value_StructValue:
+		keys := maps.Keys(kind.StructValue.Fields)
+		sort.Strings(keys)
+
+		entries := make([]string, 0, len(keys))
+		for _, key := range keys {
+			entries = append(entries, strconv.Quote(key)+": "+celLiteral(kind.StructValue.Fields[key]))
+		}
+		return "{" + strings.Join(entries, ", ") + "}"
+
+	default:
+		return "null"
+	}
+}
@@ -289,6 +289,59 @@ definition foos/test {
 }`,
 		},
 
+		{
+			"with alias",
+			`definition foos/test {
+				relation viewer: foos/user
+				// the former name of viewer
+				alias   reader =   viewer
+			}`,
+			`definition foos/test {
+	relation viewer: foos/user
+
+	// the former name of viewer
+	alias reader = viewer
+}`,
+		},
+		{
+			"caveat with parameter defaults",
+			`caveat foos/somecaveat(someParam int = 40 + 2, names list<string> = ['a', "b"], flags map<bool> = {'y': false, 'x': true}, expiry timestamp = timestamp("2023-01-01T00:00:00Z"), anotherParam bool) {
+				someParam == 42 && anotherParam && 'a' in names && flags['x'] && expiry > timestamp("2022-01-01T00:00:00Z")
+			}`,
+			`caveat foos/somecaveat(anotherParam bool, expiry timestamp = "2023-01-01T00:00:00Z", flags map<bool> = {"x": true, "y": false}, names list<string> = ["a", "b"], someParam int = 42) {
+	someParam == 42 && anotherParam && "a" in names && flags["x"] && expiry > timestamp("2022-01-01T00:00:00Z")
+}`,
+		},
+
+		{
+			"caveat with context precedence",
+			`caveat foos/somecaveat(someParam int) with precedence error_on_conflict {
+				someParam == 42
+			}`,
+			`caveat foos/somecaveat(someParam int) with precedence error_on_conflict {
+	someParam == 42
+}`,
+		},
+
+		{
+			"definition with constraints",
+			`definition foos/test {
+				relation owner: foos/user
+				relation approver: foos/user
+				relation author: foos/user
+				constraint owner at most 1
+				constraint approver excludes author
+			}`,
+			`definition foos/test {
+	relation owner: foos/user
+	relation approver: foos/user
+	relation author: foos/user
+
+	constraint owner at most 1
+	constraint approver excludes author
+}`,
+		},
+
 		{
 			"becomes single line comment",
 			`definition foos/test {
@@ -368,3 +421,50 @@ definition foos/document {
 		})
 	}
 }
+
+func TestFormatSchema(t *testing.T) {
+	schemaText := `definition document {
+		relation viewer: user;    permission view = viewer
+	}
+
+	// the user
+	definition user {}
+
+	caveat is_tuesday(day_of_week string) { day_of_week == 'tuesday' }`
+
+	formatted, err := FormatSchema(schemaText, false)
+	require.NoError(t, err)
+	require.Equal(t, `definition document {
+	relation viewer: user
+	permission view = viewer
+}
+
+// the user
+definition user {}
+
+caveat is_tuesday(day_of_week string) {
+	day_of_week == "tuesday"
+}`, formatted)
+
+	sorted, err := FormatSchema(schemaText, true)
+	require.NoError(t, err)
+	require.Equal(t, `definition document {
+	relation viewer: user
+	permission view = viewer
+}
+
+caveat is_tuesday(day_of_week string) {
+	day_of_week == "tuesday"
+}
+
+// the user
+definition user {}`, sorted)
+
+	// Formatting is idempotent.
+	reformatted, err := FormatSchema(sorted, true)
+	require.NoError(t, err)
+	require.Equal(t, sorted, reformatted)
+
+	_, err = FormatSchema("definition user {", false)
+	require.Error(t, err)
+}
@@ -71,6 +71,7 @@ Loop:
 
 // consumeCaveat attempts to consume a single caveat definition.
 // ```caveat somecaveat(param1 type, param2 type) { ... }```
+// ```caveat somecaveat(param1 type, param2 type) with precedence policy { ... }```
 func (p *sourceParser) consumeCaveat() AstNode {
 	defNode := p.startNode(dslshape.NodeTypeCaveatDefinition)
 	defer p.mustFinishNode()
@@ -109,6 +110,16 @@ func (p *sourceParser) consumeCaveat() AstNode {
 		return defNode
 	}
 
+	// with precedence policy
+	if p.tryConsumeKeyword("with") {
+		policy, ok := p.consumeCaveatContextPrecedence()
+		if !ok {
+			return defNode
+		}
+
+		defNode.MustDecorate(dslshape.NodeCaveatDefinitionPredicateContextPrecedence, policy)
+	}
+
 	// {
 	_, ok = p.consume(lexer.TokenTypeLeftBrace)
 	if !ok {
@@ -181,8 +192,24 @@ consumer:
 	return exprNode, true
 }
 
+// consumeCaveatContextPrecedence consumes the context precedence policy of a caveat.
+// ```precedence policyName```
+func (p *sourceParser) consumeCaveatContextPrecedence() (string, bool) {
+	keyword, ok := p.consumeIdentifier()
+	if !ok {
+		return "", false
+	}
+
+	if keyword != "precedence" {
+		p.emitErrorf("Expected precedence, found %s", keyword)
+		return "", false
+	}
+
+	return p.consumeIdentifier()
+}
+
 // consumeCaveatParameter attempts to consume a caveat parameter.
-// ```(paramName paramtype)```
+// ```(paramName paramtype)``` or ```(paramName paramtype = defaultValue)```
 func (p *sourceParser) consumeCaveatParameter() (AstNode, bool) {
 	paramNode := p.startNode(dslshape.NodeTypeCaveatParameter)
 	defer p.mustFinishNode()
@@ -194,9 +221,70 @@ func (p *sourceParser) consumeCaveatParameter() (AstNode, bool) {
 
 	paramNode.MustDecorate(dslshape.NodeCaveatParameterPredicateName, name)
 	paramNode.Connect(dslshape.NodeCaveatParameterPredicateType, p.consumeCaveatTypeReference())
+
+	// =
+	if _, ok := p.tryConsume(lexer.TokenTypeEquals); !ok {
+		return paramNode, true
+	}
+
+	defaultExpression, ok := p.consumeCaveatParameterDefault()
+	if !ok {
+		return paramNode, false
+	}
+
+	paramNode.MustDecorate(dslshape.NodeCaveatParameterPredicateDefault, defaultExpression)
 	return paramNode, true
 }
 
+// consumeCaveatParameterDefault consumes the CEL expression of the default value of a caveat
+// parameter, which ends at the next comma or close paren not nested within the expression.
+func (p *sourceParser) consumeCaveatParameterDefault() (string, bool) {
+	nestingDepth := 0
+	var startToken *commentedLexeme
+	var endToken *commentedLexeme
+consumer:
+	for {
+		currentToken := p.currentToken
+
+		switch currentToken.Kind {
+		case lexer.TokenTypeLeftParen, lexer.TokenTypeLeftBracket, lexer.TokenTypeLeftBrace:
+			nestingDepth++
+
+		case lexer.TokenTypeRightParen, lexer.TokenTypeRightBracket, lexer.TokenTypeRightBrace:
+			if nestingDepth == 0 {
+				break consumer
+			}
+
+			nestingDepth--
+
+		case lexer.TokenTypeComma:
+			if nestingDepth == 0 {
+				break consumer
+			}
+
+		case lexer.TokenTypeError:
+			break consumer
+
+		case lexer.TokenTypeEOF:
+			break consumer
+		}
+
+		if startToken == nil {
+			startToken = &currentToken
+		}
+
+		endToken = &currentToken
+		p.consumeToken()
+	}
+
+	if startToken == nil {
+		p.emitErrorf("missing default value for caveat parameter")
+		return "", false
+	}
+
+	return p.input[startToken.Position : int(endToken.Position)+len(endToken.Value)], true
+}
+
 // consumeCaveatTypeReference attempts to consume a caveat type reference.
 // ```typeName<childType>```
 func (p *sourceParser) consumeCaveatTypeReference() AstNode {
@@ -250,7 +338,7 @@ func (p *sourceParser) consumeDefinition() AstNode {
 		return defNode
 	}
 
-	// Relations and permissions.
+	// Relations, permissions, constraints and aliases.
 	for {
 		// }
 		if _, ok := p.tryConsume(lexer.TokenTypeRightBrace); ok {
@@ -259,12 +347,20 @@ func (p *sourceParser) consumeDefinition() AstNode {
 
 		// relation ...
 		// permission ...
+		// constraint ...
+		// alias ...
 		switch {
 		case p.isKeyword("relation"):
 			defNode.Connect(dslshape.NodePredicateChild, p.consumeRelation())
 
 		case p.isKeyword("permission"):
 			defNode.Connect(dslshape.NodePredicateChild, p.consumePermission())
+
+		case p.isIdentifier("constraint"):
+			defNode.Connect(dslshape.NodePredicateChild, p.consumeConstraint())
+
+		case p.isIdentifier("alias"):
+			defNode.Connect(dslshape.NodePredicateChild, p.consumeAlias())
 		}
 
 		ok := p.consumeStatementTerminator()
@@ -303,6 +399,98 @@ func (p *sourceParser) consumeRelation() AstNode {
 	return relNode
 }
 
+// consumeConstraint consumes a constraint on the relationships of a relation.
+// ```constraint owner at most 1```
+// ```constraint approver excludes author```
+func (p *sourceParser) consumeConstraint() AstNode {
+	constraintNode := p.startNode(dslshape.NodeTypeConstraint)
+	defer p.mustFinishNode()
+
+	// constraint ...
+	if _, ok := p.consumeIdentifier(); !ok {
+		return constraintNode
+	}
+
+	relationName, ok := p.consumeIdentifier()
+	if !ok {
+		return constraintNode
+	}
+
+	constraintNode.MustDecorate(dslshape.NodeConstraintPredicateRelation, relationName)
+
+	rule, ok := p.consumeIdentifier()
+	if !ok {
+		return constraintNode
+	}
+
+	switch rule {
+	case "at":
+		// at most 1
+		most, ok := p.consumeIdentifier()
+		if !ok {
+			return constraintNode
+		}
+
+		if most != "most" {
+			p.emitErrorf("Expected most, found %s", most)
+			return constraintNode
+		}
+
+		maximum, ok := p.consumeIdentifier()
+		if !ok {
+			return constraintNode
+		}
+
+		constraintNode.MustDecorate(dslshape.NodeConstraintPredicateMaximum, maximum)
+
+	case "excludes":
+		// excludes author
+		excludedRelationName, ok := p.consumeIdentifier()
+		if !ok {
+			return constraintNode
+		}
+
+		constraintNode.MustDecorate(dslshape.NodeConstraintPredicateExcludedRelation, excludedRelationName)
+
+	default:
+		p.emitErrorf("Expected at most or excludes, found %s", rule)
+	}
+
+	return constraintNode
+}
+
+// consumeAlias consumes an alias of a relation or permission.
+// ```alias reader = viewer```
+func (p *sourceParser) consumeAlias() AstNode {
+	aliasNode := p.startNode(dslshape.NodeTypeAlias)
+	defer p.mustFinishNode()
+
+	// alias ...
+	if _, ok := p.consumeIdentifier(); !ok {
+		return aliasNode
+	}
+
+	aliasName, ok := p.consumeIdentifier()
+	if !ok {
+		return aliasNode
+	}
+
+	aliasNode.MustDecorate(dslshape.NodePredicateName, aliasName)
+
+	// =
+	if _, ok := p.consume(lexer.TokenTypeEquals); !ok {
+		return aliasNode
+	}
+
+	targetName, ok := p.consumeIdentifier()
+	if !ok {
+		return aliasNode
+	}
+
+	aliasNode.MustDecorate(dslshape.NodeAliasPredicateTarget, targetName)
+	return aliasNode
+}
+
 // consumeTypeReference consumes a reference to a type or types of relations.
 // ```sometype | anothertype | anothertype:* ```
 func (p *sourceParser) consumeTypeReference() AstNode {
@@ -522,9 +710,10 @@ func (p *sourceParser) tryConsumeBaseExpression() (AstNode, bool) {
 }
 
 // tryConsumeIdentifierLiteral attempts to consume an identifier as a literal
-// expression.
+// expression, optionally bounded in time.
 //
 // ```foo```
+// ```foo.within(somecaveat)```
 func (p *sourceParser) tryConsumeIdentifierLiteral() (AstNode, bool) {
 	if !p.isToken(lexer.TokenTypeIdentifier) {
 		return nil, false
@@ -535,6 +724,35 @@ func (p *sourceParser) tryConsumeIdentifierLiteral() (AstNode, bool) {
 
 	identifier, _ := p.consumeIdentifier()
 	identNode.MustDecorate(dslshape.NodeIdentiferPredicateValue, identifier)
+
+	// .within(somecaveat)
+	if _, ok := p.tryConsume(lexer.TokenTypePeriod); ok {
+		functionName, ok := p.consumeIdentifier()
+		if !ok {
+			return identNode, true
+		}
+
+		if functionName != "within" {
+			p.emitErrorf("Expected within, found %s", functionName)
+			return identNode, true
+		}
+
+		if _, ok := p.consume(lexer.TokenTypeLeftParen); !ok {
+			return identNode, true
+		}
+
+		caveatName, ok := p.consumeIdentifier()
+		if !ok {
+			return identNode, true
+		}
+
+		if _, ok := p.consume(lexer.TokenTypeRightParen); !ok {
+			return identNode, true
+		}
+
+		identNode.MustDecorate(dslshape.NodeIdentiferPredicateWithinCaveat, caveatName)
+	}
+
 	return identNode, true
 }
 
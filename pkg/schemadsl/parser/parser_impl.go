@@ -168,6 +168,12 @@ func (p *sourceParser) isKeyword(keyword string) bool {
 	return p.isToken(lexer.TokenTypeKeyword) && p.currentToken.Value == keyword
 }
 
+// isIdentifier returns true if the current token is an identifier matching that given, such as
+// a word which is only meaningful in a particular position and so is not reserved as a keyword.
+func (p *sourceParser) isIdentifier(identifier string) bool {
+	return p.isToken(lexer.TokenTypeIdentifier) && p.currentToken.Value == identifier
+}
+
 // emitErrorf creates a new error node and attachs it as a child of the current
 // node.
 func (p *sourceParser) emitErrorf(format string, args ...interface{}) {
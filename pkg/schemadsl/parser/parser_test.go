@@ -113,6 +113,11 @@ func TestParser(t *testing.T) {
 		{"empty caveat test", "emptycaveat"},
 		{"unclosed caveat test", "unclosedcaveat"},
 		{"invalid caveat expr test", "invalidcaveatexpr"},
+		{"caveat parameter defaults test", "caveatdefaults"},
+		{"caveat context precedence test", "caveatprecedence"},
+		{"within test", "within"},
+		{"constraints test", "constraints"},
+		{"alias test", "alias"},
 	}
 
 	for _, test := range parserTests {
@@ -0,0 +1,155 @@
+package secrets
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Key is a symmetric key used for application-level encryption, identified by
+// an ID that is stored alongside any data encrypted with it.
+type Key struct {
+	// ID is the stable identifier of the key.
+	ID string
+
+	// Material is the raw key material. It must be 16, 24 or 32 bytes long,
+	// selecting AES-128, AES-192 or AES-256 respectively.
+	Material []byte
+}
+
+// KeyManager provides access to the keys used for application-level encryption.
+// Implementations can be backed by a KMS; keys that have been rotated out must
+// remain available via KeyByID for as long as data encrypted under them exists.
+type KeyManager interface {
+	// PrimaryKey returns the key that should be used to encrypt new data.
+	PrimaryKey(ctx context.Context) (Key, error)
+
+	// KeyByID returns the key with the given ID, to decrypt existing data.
+	KeyByID(ctx context.Context, id string) (Key, error)
+}
+
+// ErrUnknownKey is returned when a key with the requested ID is not known to
+// the key manager.
+type ErrUnknownKey struct {
+	error
+	keyID string
+}
+
+// KeyID returns the ID of the key that could not be found.
+func (err ErrUnknownKey) KeyID() string {
+	return err.keyID
+}
+
+// NewErrUnknownKey constructs a new unknown key error.
+func NewErrUnknownKey(keyID string) error {
+	return ErrUnknownKey{
+		error: fmt.Errorf("unknown encryption key `%s`", keyID),
+		keyID: keyID,
+	}
+}
+
+// NewStaticKeyManager returns a KeyManager over a fixed set of keys. The first
+// key is the primary key; the remainder are only used for decryption, which
+// allows for rotation by prepending a new key.
+func NewStaticKeyManager(keys ...Key) (KeyManager, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("at least one encryption key must be specified")
+	}
+
+	byID := make(map[string]Key, len(keys))
+	for _, key := range keys {
+		if key.ID == "" {
+			return nil, errors.New("encryption key IDs cannot be empty")
+		}
+		if _, ok := byID[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate encryption key ID `%s`", key.ID)
+		}
+		if _, err := aes.NewCipher(key.Material); err != nil {
+			return nil, fmt.Errorf("invalid encryption key `%s`: %w", key.ID, err)
+		}
+		byID[key.ID] = key
+	}
+
+	return &staticKeyManager{primary: keys[0], byID: byID}, nil
+}
+
+type staticKeyManager struct {
+	primary Key
+	byID    map[string]Key
+}
+
+func (skm *staticKeyManager) PrimaryKey(_ context.Context) (Key, error) {
+	return skm.primary, nil
+}
+
+func (skm *staticKeyManager) KeyByID(_ context.Context, id string) (Key, error) {
+	key, ok := skm.byID[id]
+	if !ok {
+		return Key{}, NewErrUnknownKey(id)
+	}
+	return key, nil
+}
+
+// ParseKeys parses keys of the form `id=base64-encoded-material`.
+func ParseKeys(specs []string) ([]Key, error) {
+	keys := make([]Key, 0, len(specs))
+	for _, spec := range specs {
+		id, encoded, ok := strings.Cut(spec, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid encryption key: expected `id=base64key`")
+		}
+
+		material, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid encryption key `%s`: %w", id, err)
+		}
+
+		keys = append(keys, Key{ID: id, Material: material})
+	}
+	return keys, nil
+}
+
+// Encrypt encrypts the plaintext under the key using AES-GCM. The additional
+// data is authenticated but not encrypted, and must be provided unchanged to
+// Decrypt. The returned ciphertext is prefixed with the random nonce.
+func Encrypt(key Key, plaintext []byte, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("unable to generate nonce: %w", err)
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// Decrypt decrypts ciphertext produced by Encrypt.
+func Decrypt(key Key, ciphertext []byte, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, sealed, additionalData)
+}
+
+func newAEAD(key Key) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key.Material)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key `%s`: %w", key.ID, err)
+	}
+	return cipher.NewGCM(block)
+}
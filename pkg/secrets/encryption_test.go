@@ -0,0 +1,72 @@
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	key := Key{ID: "k1", Material: []byte("0123456789abcdef0123456789abcdef")}
+
+	ciphertext, err := Encrypt(key, []byte("hello world"), []byte("aad"))
+	require.NoError(t, err)
+	require.NotContains(t, string(ciphertext), "hello world")
+
+	plaintext, err := Decrypt(key, ciphertext, []byte("aad"))
+	require.NoError(t, err)
+	require.Equal(t, "hello world", string(plaintext))
+
+	_, err = Decrypt(key, ciphertext, []byte("other aad"))
+	require.Error(t, err)
+
+	otherKey := Key{ID: "k2", Material: []byte("fedcba9876543210fedcba9876543210")}
+	_, err = Decrypt(otherKey, ciphertext, []byte("aad"))
+	require.Error(t, err)
+}
+
+func TestStaticKeyManager(t *testing.T) {
+	ctx := context.Background()
+
+	keys, err := ParseKeys([]string{
+		"new=" + base64.StdEncoding.EncodeToString([]byte("0123456789abcdef")),
+		"old=" + base64.StdEncoding.EncodeToString([]byte("fedcba9876543210")),
+	})
+	require.NoError(t, err)
+
+	km, err := NewStaticKeyManager(keys...)
+	require.NoError(t, err)
+
+	primary, err := km.PrimaryKey(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "new", primary.ID)
+
+	old, err := km.KeyByID(ctx, "old")
+	require.NoError(t, err)
+	require.Equal(t, []byte("fedcba9876543210"), old.Material)
+
+	_, err = km.KeyByID(ctx, "missing")
+	require.ErrorAs(t, err, &ErrUnknownKey{})
+}
+
+func TestInvalidKeys(t *testing.T) {
+	_, err := ParseKeys([]string{"missingseparator"})
+	require.Error(t, err)
+
+	_, err = ParseKeys([]string{"id=not base64!"})
+	require.Error(t, err)
+
+	_, err = NewStaticKeyManager()
+	require.Error(t, err)
+
+	_, err = NewStaticKeyManager(Key{ID: "short", Material: []byte("tooshort")})
+	require.Error(t, err)
+
+	_, err = NewStaticKeyManager(
+		Key{ID: "dup", Material: []byte("0123456789abcdef")},
+		Key{ID: "dup", Material: []byte("0123456789abcdef")},
+	)
+	require.Error(t, err)
+}
@@ -0,0 +1,20 @@
+package secrets
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+)
+
+// Sign returns the HMAC-SHA256 of the data under the key, with which the data can later be
+// verified as unmodified by a holder of the key.
+func Sign(key Key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key.Material)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// Verify returns whether the signature was produced by Sign for the data under the key. The
+// comparison is made in constant time.
+func Verify(key Key, data []byte, signature []byte) bool {
+	return hmac.Equal(Sign(key, data), signature)
+}
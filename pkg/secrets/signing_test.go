@@ -0,0 +1,19 @@
+package secrets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignVerify(t *testing.T) {
+	key := Key{ID: "k1", Material: []byte("0123456789abcdef0123456789abcdef")}
+
+	signature := Sign(key, []byte("hello world"))
+	require.Len(t, signature, 32)
+	require.True(t, Verify(key, []byte("hello world"), signature))
+	require.False(t, Verify(key, []byte("hello world!"), signature))
+
+	otherKey := Key{ID: "k2", Material: []byte("fedcba9876543210fedcba9876543210")}
+	require.False(t, Verify(otherKey, []byte("hello world"), signature))
+}
@@ -0,0 +1,81 @@
+package spiceerrors
+
+import (
+	"errors"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+)
+
+// Reasons for errors which are not (yet) defined by the V1 API. Like the reasons defined by the
+// V1 API, they are returned in the ErrorInfo of the gRPC status of an error and are stable, so
+// that clients can branch on them rather than on error messages.
+const (
+	// ReasonMaximumDepthExceeded indicates that a request required resolving a deeper or more
+	// recursive graph of relationships than the maximum dispatch depth allows.
+	ReasonMaximumDepthExceeded = "ERROR_REASON_MAXIMUM_DEPTH_EXCEEDED"
+
+	// ReasonRevisionStale indicates that the revision requested, such as that of a ZedToken, has
+	// fallen outside of the garbage collection window of the datastore.
+	ReasonRevisionStale = "ERROR_REASON_REVISION_STALE"
+
+	// ReasonInvalidRevision indicates that the revision requested is unknown to the datastore.
+	ReasonInvalidRevision = "ERROR_REASON_INVALID_REVISION"
+
+	// ReasonResultTooLarge indicates that the result of a request would exceed the maximum
+	// result size configured.
+	ReasonResultTooLarge = "ERROR_REASON_RESULT_TOO_LARGE"
+
+	// ReasonRequestCanceled indicates that the request was canceled before it completed.
+	ReasonRequestCanceled = "ERROR_REASON_REQUEST_CANCELED"
+
+	// ReasonDeadlineExceeded indicates that the deadline of the request passed before it
+	// completed.
+	ReasonDeadlineExceeded = "ERROR_REASON_DEADLINE_EXCEEDED"
+
+	// ReasonConstraintViolated indicates that a write would leave an object with relationships
+	// which violate a constraint of its definition.
+	ReasonConstraintViolated = "ERROR_REASON_CONSTRAINT_VIOLATED"
+
+	// ReasonMaximumNestingDepthExceeded indicates that a write would create a chain of nested
+	// relationships, such as of groups within groups, deeper than the maximum configured.
+	ReasonMaximumNestingDepthExceeded = "ERROR_REASON_MAXIMUM_NESTING_DEPTH_EXCEEDED"
+)
+
+// ForReasonName returns an ErrorInfo block for an error reason given by name, such as those
+// defined above.
+func ForReasonName(reason string, metadata map[string]string) *errdetails.ErrorInfo {
+	return &errdetails.ErrorInfo{
+		Reason:   reason,
+		Domain:   Domain,
+		Metadata: metadata,
+	}
+}
+
+// WithCodeAndReasonName returns a new error which wraps the existing error with a gRPC code and
+// a reason block for the reason given by name.
+func WithCodeAndReasonName(err error, code codes.Code, reason string) error {
+	status := WithCodeAndDetails(err, code, ForReasonName(reason, detailsMetadata(err)), ForDebug(err))
+	return errWithStatus{err, status}
+}
+
+// ForDebug returns a DebugInfo block holding the chain of errors wrapped by the error, outermost
+// first.
+func ForDebug(err error) *errdetails.DebugInfo {
+	var chain []string
+	for current := err; current != nil; current = errors.Unwrap(current) {
+		chain = append(chain, current.Error())
+	}
+	return &errdetails.DebugInfo{
+		StackEntries: chain,
+		Detail:       err.Error(),
+	}
+}
+
+func detailsMetadata(err error) map[string]string {
+	var hasMetadata HasMetadata
+	if ok := errors.As(err, &hasMetadata); ok {
+		return hasMetadata.DetailsMetadata()
+	}
+	return map[string]string{}
+}
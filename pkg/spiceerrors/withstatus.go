@@ -1,8 +1,6 @@
 package spiceerrors
 
 import (
-	"errors"
-
 	log "github.com/authzed/spicedb/internal/logging"
 
 	"google.golang.org/genproto/googleapis/rpc/errdetails"
@@ -29,25 +27,13 @@ func WithCodeAndDetails(err error, code codes.Code, details ...protoiface.Messag
 
 // ForReason returns an ErrorInfo block for a specific error reason as defined in the V1 API.
 func ForReason(reason v1.ErrorReason, metadata map[string]string) *errdetails.ErrorInfo {
-	return &errdetails.ErrorInfo{
-		Reason:   v1.ErrorReason_name[int32(reason)],
-		Domain:   Domain,
-		Metadata: metadata,
-	}
+	return ForReasonName(v1.ErrorReason_name[int32(reason)], metadata)
 }
 
 // WithCodeAndReason returns a new error which wraps the existing error with a gRPC code and
 // a reason block.
 func WithCodeAndReason(err error, code codes.Code, reason v1.ErrorReason) error {
-	metadata := map[string]string{}
-
-	var hasMetadata HasMetadata
-	if ok := errors.As(err, &hasMetadata); ok {
-		metadata = hasMetadata.DetailsMetadata()
-	}
-
-	status := WithCodeAndDetails(err, code, ForReason(reason, metadata))
-	return errWithStatus{err, status}
+	return WithCodeAndReasonName(err, code, v1.ErrorReason_name[int32(reason)])
 }
 
 type errWithStatus struct {
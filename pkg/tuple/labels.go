@@ -0,0 +1,77 @@
+package tuple
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	labelKeyExpr = "[a-z][a-z0-9_.-]{0,62}"
+
+	// MaxLabels is the maximum number of labels on a relationship.
+	MaxLabels = 16
+
+	// MaxLabelValueBytes is the maximum length of the value of a label.
+	MaxLabelValueBytes = 128
+)
+
+var labelKeyRegex = regexp.MustCompile(fmt.Sprintf("^%s$", labelKeyExpr))
+
+// IsValidLabelKey returns true if the given string is a valid key for a relationship label.
+func IsValidLabelKey(key string) bool {
+	return labelKeyRegex.MatchString(key)
+}
+
+// ParseLabels parses relationship labels given in the form `key=value,otherkey=othervalue`. An
+// empty string parses into no labels.
+func ParseLabels(labelsStr string) (map[string]string, error) {
+	labelsStr = strings.TrimSpace(labelsStr)
+	if labelsStr == "" {
+		return nil, nil
+	}
+
+	pairs := strings.Split(labelsStr, ",")
+	if len(pairs) > MaxLabels {
+		return nil, fmt.Errorf("found %d labels, more than the maximum of %d", len(pairs), MaxLabels)
+	}
+
+	labels := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("label `%s` must be of the form `key=value`", pair)
+		}
+
+		if !IsValidLabelKey(key) {
+			return nil, fmt.Errorf("label key `%s` must match %s", key, labelKeyExpr)
+		}
+
+		if len(value) > MaxLabelValueBytes {
+			return nil, fmt.Errorf("value of label `%s` is longer than %d bytes", key, MaxLabelValueBytes)
+		}
+
+		if _, ok := labels[key]; ok {
+			return nil, fmt.Errorf("label `%s` was specified more than once", key)
+		}
+
+		labels[key] = value
+	}
+	return labels, nil
+}
+
+// StringLabels converts relationship labels into the form parsed by ParseLabels, ordered by key.
+func StringLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+labels[key])
+	}
+	return strings.Join(pairs, ",")
+}
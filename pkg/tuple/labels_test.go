@@ -0,0 +1,44 @@
+package tuple
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLabels(t *testing.T) {
+	testCases := []struct {
+		labels        string
+		expected      map[string]string
+		expectedError string
+	}{
+		{"", nil, ""},
+		{"source=scim-sync", map[string]string{"source": "scim-sync"}, ""},
+		{" source=scim-sync, batch=1 ", map[string]string{"source": "scim-sync", "batch": "1"}, ""},
+		{"origin.team=a=b", map[string]string{"origin.team": "a=b"}, ""},
+		{"source=", map[string]string{"source": ""}, ""},
+		{"source", nil, "must be of the form `key=value`"},
+		{"Source=scim", nil, "label key `Source` must match"},
+		{"source=a,source=b", nil, "label `source` was specified more than once"},
+		{"source=" + strings.Repeat("a", MaxLabelValueBytes+1), nil, "longer than 128 bytes"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.labels, func(t *testing.T) {
+			labels, err := ParseLabels(tc.labels)
+			if tc.expectedError != "" {
+				require.ErrorContains(t, err, tc.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, labels)
+
+			reparsed, err := ParseLabels(StringLabels(labels))
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, reparsed)
+		})
+	}
+}
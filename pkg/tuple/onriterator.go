@@ -0,0 +1,245 @@
+package tuple
+
+import (
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+)
+
+// ONRIterator is an iterator over ObjectAndRelation's.
+type ONRIterator interface {
+	// Next returns the next ONR, or a nil ONR and nil error once the iterator is exhausted.
+	// Once an error is returned, it is returned by every further call.
+	Next() (*core.ObjectAndRelation, error)
+}
+
+// NewONRSliceIterator returns an iterator over the given ONRs.
+func NewONRSliceIterator(onrs ...*core.ObjectAndRelation) ONRIterator {
+	return &onrSliceIterator{onrs: onrs}
+}
+
+type onrSliceIterator struct {
+	onrs []*core.ObjectAndRelation
+}
+
+func (osi *onrSliceIterator) Next() (*core.ObjectAndRelation, error) {
+	if len(osi.onrs) == 0 {
+		return nil, nil
+	}
+
+	next := osi.onrs[0]
+	osi.onrs = osi.onrs[1:]
+	return next, nil
+}
+
+// Iterator returns an iterator over the ONRs found in the set at the time of the call.
+func (ons *ONRSet) Iterator() ONRIterator {
+	return NewONRSliceIterator(ons.AsSlice()...)
+}
+
+// CollectONRs drains the iterator into a new set.
+func CollectONRs(it ONRIterator) (*ONRSet, error) {
+	collected := NewONRSet()
+	for {
+		onr, err := it.Next()
+		if err != nil {
+			return nil, err
+		}
+		if onr == nil {
+			return collected, nil
+		}
+		collected.Add(onr)
+	}
+}
+
+// UnionONRs returns an iterator over the distinct ONRs found in any of the given iterators. Each
+// iterator is only read once those before it are exhausted.
+func UnionONRs(iters ...ONRIterator) ONRIterator {
+	return &onrUnion{iters: iters, seen: map[string]struct{}{}}
+}
+
+type onrUnion struct {
+	iters []ONRIterator
+	seen  map[string]struct{}
+	err   error
+}
+
+func (ou *onrUnion) Next() (*core.ObjectAndRelation, error) {
+	if ou.err != nil {
+		return nil, ou.err
+	}
+
+	for len(ou.iters) > 0 {
+		onr, err := ou.iters[0].Next()
+		if err != nil {
+			ou.err = err
+			return nil, err
+		}
+
+		if onr == nil {
+			ou.iters = ou.iters[1:]
+			continue
+		}
+
+		key := StringONR(onr)
+		if _, ok := ou.seen[key]; ok {
+			continue
+		}
+
+		ou.seen[key] = struct{}{}
+		return onr, nil
+	}
+
+	return nil, nil
+}
+
+// IntersectONRs returns an iterator over the distinct ONRs found in all of the given iterators.
+//
+// The iterators are read in turn, and an ONR is returned as soon as it has been seen in each of
+// them, rather than once all of them have been read. Once either side of an intersection is
+// exhausted, the other side is only read while the exhausted side found any ONRs that have not
+// yet been matched, so an empty branch stops the intersection without reading the others in full.
+func IntersectONRs(first ONRIterator, rest ...ONRIterator) ONRIterator {
+	intersected := first
+	for _, other := range rest {
+		intersected = &onrIntersection{
+			sides:    [2]ONRIterator{intersected, other},
+			pending:  [2]map[string]struct{}{{}, {}},
+			returned: map[string]struct{}{},
+		}
+	}
+	return intersected
+}
+
+type onrIntersection struct {
+	sides [2]ONRIterator
+
+	// pending holds the keys of the ONRs read from each side that have not yet been found on the
+	// other side.
+	pending [2]map[string]struct{}
+
+	// returned holds the keys of the ONRs already returned.
+	returned map[string]struct{}
+
+	exhausted [2]bool
+	current   int
+	err       error
+}
+
+func (oi *onrIntersection) Next() (*core.ObjectAndRelation, error) {
+	if oi.err != nil {
+		return nil, oi.err
+	}
+
+	for {
+		if oi.exhausted[0] && oi.exhausted[1] {
+			return nil, nil
+		}
+
+		side := oi.current
+		other := 1 - side
+		if oi.exhausted[side] {
+			side, other = other, side
+		} else if !oi.exhausted[other] {
+			oi.current = other
+		}
+
+		// Once the other side is exhausted, this side can only match its remaining pending ONRs.
+		if oi.exhausted[other] && len(oi.pending[other]) == 0 {
+			oi.exhausted[side] = true
+			oi.pending[side] = nil
+			return nil, nil
+		}
+
+		onr, err := oi.sides[side].Next()
+		if err != nil {
+			oi.err = err
+			return nil, err
+		}
+
+		if onr == nil {
+			oi.exhausted[side] = true
+			if oi.exhausted[other] {
+				return nil, nil
+			}
+
+			// Anything read from the other side that is not already matched never will be.
+			oi.pending[other] = map[string]struct{}{}
+			continue
+		}
+
+		key := StringONR(onr)
+		if _, ok := oi.returned[key]; ok {
+			continue
+		}
+
+		if _, ok := oi.pending[other][key]; ok {
+			delete(oi.pending[other], key)
+			oi.returned[key] = struct{}{}
+			return onr, nil
+		}
+
+		if oi.exhausted[other] {
+			continue
+		}
+
+		oi.pending[side][key] = struct{}{}
+	}
+}
+
+// SubtractONRs returns an iterator over the distinct ONRs found in the base iterator but not in
+// the excluded iterator. The excluded iterator is read in full before the first ONR is returned,
+// but is never read if the base iterator is empty.
+func SubtractONRs(base ONRIterator, excluded ONRIterator) ONRIterator {
+	return &onrSubtraction{base: base, excluded: excluded, returned: map[string]struct{}{}}
+}
+
+type onrSubtraction struct {
+	base         ONRIterator
+	excluded     ONRIterator
+	excludedKeys map[string]struct{}
+	returned     map[string]struct{}
+	err          error
+}
+
+func (os *onrSubtraction) Next() (*core.ObjectAndRelation, error) {
+	if os.err != nil {
+		return nil, os.err
+	}
+
+	for {
+		onr, err := os.base.Next()
+		if err != nil {
+			os.err = err
+			return nil, err
+		}
+		if onr == nil {
+			return nil, nil
+		}
+
+		if os.excludedKeys == nil {
+			excludedKeys := map[string]struct{}{}
+			for {
+				excludedONR, err := os.excluded.Next()
+				if err != nil {
+					os.err = err
+					return nil, err
+				}
+				if excludedONR == nil {
+					break
+				}
+				excludedKeys[StringONR(excludedONR)] = struct{}{}
+			}
+			os.excludedKeys = excludedKeys
+		}
+
+		key := StringONR(onr)
+		if _, ok := os.excludedKeys[key]; ok {
+			continue
+		}
+		if _, ok := os.returned[key]; ok {
+			continue
+		}
+
+		os.returned[key] = struct{}{}
+		return onr, nil
+	}
+}
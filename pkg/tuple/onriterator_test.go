@@ -0,0 +1,220 @@
+package tuple
+
+import (
+	"errors"
+	"sort"
+	"testing"
+
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+
+	"github.com/stretchr/testify/require"
+)
+
+// countingONRIterator counts the ONRs read from the wrapped iterator.
+type countingONRIterator struct {
+	ONRIterator
+	read int
+}
+
+func (coi *countingONRIterator) Next() (*core.ObjectAndRelation, error) {
+	onr, err := coi.ONRIterator.Next()
+	if onr != nil {
+		coi.read++
+	}
+	return onr, err
+}
+
+type failingONRIterator struct {
+	err error
+}
+
+func (foi failingONRIterator) Next() (*core.ObjectAndRelation, error) {
+	return nil, foi.err
+}
+
+func onrs(strs ...string) []*core.ObjectAndRelation {
+	parsed := make([]*core.ObjectAndRelation, 0, len(strs))
+	for _, str := range strs {
+		parsed = append(parsed, ParseONR(str))
+	}
+	return parsed
+}
+
+func iter(strs ...string) ONRIterator {
+	return NewONRSliceIterator(onrs(strs...)...)
+}
+
+func collectStrings(t *testing.T, it ONRIterator) []string {
+	found := []string{}
+	for {
+		onr, err := it.Next()
+		require.NoError(t, err)
+		if onr == nil {
+			break
+		}
+		found = append(found, StringONR(onr))
+	}
+
+	sort.Strings(found)
+	return found
+}
+
+func TestONRIteratorCombinators(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		iterator func() ONRIterator
+		expected []string
+	}{
+		{
+			"union",
+			func() ONRIterator {
+				return UnionONRs(iter("user:a#viewer", "user:b#viewer"), iter("user:b#viewer", "user:c#viewer"), iter())
+			},
+			[]string{"user:a#viewer", "user:b#viewer", "user:c#viewer"},
+		},
+		{
+			"union of nothing",
+			func() ONRIterator { return UnionONRs() },
+			[]string{},
+		},
+		{
+			"intersection",
+			func() ONRIterator {
+				return IntersectONRs(
+					iter("user:a#viewer", "user:b#viewer", "user:c#viewer", "user:d#viewer"),
+					iter("user:d#viewer", "user:c#viewer", "user:e#viewer"),
+				)
+			},
+			[]string{"user:c#viewer", "user:d#viewer"},
+		},
+		{
+			"intersection with duplicates",
+			func() ONRIterator {
+				return IntersectONRs(
+					iter("user:a#viewer", "user:a#viewer", "user:b#viewer"),
+					iter("user:a#viewer", "user:b#viewer", "user:a#viewer"),
+				)
+			},
+			[]string{"user:a#viewer", "user:b#viewer"},
+		},
+		{
+			"intersection of three",
+			func() ONRIterator {
+				return IntersectONRs(
+					iter("user:a#viewer", "user:b#viewer", "user:c#viewer"),
+					iter("user:b#viewer", "user:c#viewer"),
+					iter("user:c#viewer", "user:a#viewer"),
+				)
+			},
+			[]string{"user:c#viewer"},
+		},
+		{
+			"intersection of one",
+			func() ONRIterator { return IntersectONRs(iter("user:a#viewer")) },
+			[]string{"user:a#viewer"},
+		},
+		{
+			"disjoint intersection",
+			func() ONRIterator { return IntersectONRs(iter("user:a#viewer"), iter("user:b#viewer")) },
+			[]string{},
+		},
+		{
+			"subtraction",
+			func() ONRIterator {
+				return SubtractONRs(iter("user:a#viewer", "user:b#viewer", "user:a#viewer", "user:c#viewer"), iter("user:b#viewer"))
+			},
+			[]string{"user:a#viewer", "user:c#viewer"},
+		},
+		{
+			"subtraction of everything",
+			func() ONRIterator { return SubtractONRs(iter("user:a#viewer"), iter("user:a#viewer", "user:b#viewer")) },
+			[]string{},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, collectStrings(t, tc.iterator()))
+
+			// The iterators remain exhausted.
+			it := tc.iterator()
+			collectStrings(t, it)
+			onr, err := it.Next()
+			require.NoError(t, err)
+			require.Nil(t, onr)
+		})
+	}
+}
+
+func TestIntersectONRsIsLazy(t *testing.T) {
+	large := make([]*core.ObjectAndRelation, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		large = append(large, ObjectAndRelation("user", string(rune('a'+i%26))+string(rune('a'+i/26)), "viewer"))
+	}
+
+	// An empty side ends the intersection after a single read of the other side.
+	first := &countingONRIterator{ONRIterator: NewONRSliceIterator(large...)}
+	second := &countingONRIterator{ONRIterator: iter()}
+	require.Empty(t, collectStrings(t, IntersectONRs(first, second)))
+	require.Equal(t, 1, first.read)
+
+	// A match is returned before either side is read in full.
+	first = &countingONRIterator{ONRIterator: NewONRSliceIterator(large...)}
+	second = &countingONRIterator{ONRIterator: NewONRSliceIterator(large...)}
+	onr, err := IntersectONRs(first, second).Next()
+	require.NoError(t, err)
+	require.Equal(t, StringONR(large[0]), StringONR(onr))
+	require.Equal(t, 1, first.read)
+	require.Equal(t, 1, second.read)
+
+	// Once the smaller side is exhausted with nothing left to match, the larger one is not read
+	// further: the sides are read in turn, so the larger side is only read one more time.
+	first = &countingONRIterator{ONRIterator: NewONRSliceIterator(large...)}
+	second = &countingONRIterator{ONRIterator: NewONRSliceIterator(large[1], large[0])}
+	require.Equal(t, []string{StringONR(large[0]), StringONR(large[1])}, collectStrings(t, IntersectONRs(first, second)))
+	require.Equal(t, 3, first.read)
+}
+
+func TestSubtractONRsIsLazy(t *testing.T) {
+	excluded := &countingONRIterator{ONRIterator: iter("user:a#viewer")}
+	require.Empty(t, collectStrings(t, SubtractONRs(iter(), excluded)))
+	require.Zero(t, excluded.read)
+}
+
+func TestONRIteratorCombinatorErrors(t *testing.T) {
+	failure := errors.New("failed")
+
+	for _, tc := range []struct {
+		name     string
+		iterator ONRIterator
+	}{
+		{"union", UnionONRs(iter("user:a#viewer"), failingONRIterator{failure})},
+		{"intersection", IntersectONRs(iter("user:a#viewer", "user:b#viewer"), failingONRIterator{failure})},
+		{"subtraction base", SubtractONRs(failingONRIterator{failure}, iter())},
+		{"subtraction excluded", SubtractONRs(iter("user:a#viewer"), failingONRIterator{failure})},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var err error
+			for err == nil {
+				var onr *core.ObjectAndRelation
+				onr, err = tc.iterator.Next()
+				require.False(t, onr == nil && err == nil, "iterator exhausted without error")
+			}
+			require.ErrorIs(t, err, failure)
+
+			// The error is sticky.
+			_, err = tc.iterator.Next()
+			require.ErrorIs(t, err, failure)
+		})
+	}
+}
+
+func TestONRSetIteratorRoundTrip(t *testing.T) {
+	set := NewONRSet(onrs("user:a#viewer", "user:b#viewer", "team:c#member")...)
+	collected, err := CollectONRs(set.Iterator())
+	require.NoError(t, err)
+	require.ElementsMatch(t, set.AsSlice(), collected.AsSlice())
+
+	_, err = CollectONRs(failingONRIterator{errors.New("failed")})
+	require.Error(t, err)
+}
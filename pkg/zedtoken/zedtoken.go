@@ -50,6 +50,27 @@ func NewFromRevision(revision datastore.Revision) (*v1.ZedToken, error) {
 	return encoded, nil
 }
 
+// NewFromWatchPosition generates an encoded zedtoken positioned after the first sequence changes
+// of a revision emitted by Watch, where afterRevision is the revision after which Watch must resume
+// to emit the remaining changes of the revision.
+func NewFromWatchPosition(revision, afterRevision datastore.Revision, sequence uint32) (*v1.ZedToken, error) {
+	toEncode := &zedtoken.DecodedZedToken{
+		VersionOneof: &zedtoken.DecodedZedToken_V1{
+			V1: &zedtoken.DecodedZedToken_V1ZedToken{
+				Revision:           revision.String(),
+				WatchSequence:      sequence,
+				WatchAfterRevision: afterRevision.String(),
+			},
+		},
+	}
+	encoded, err := Encode(toEncode)
+	if err != nil {
+		return nil, fmt.Errorf(errEncodeError, err)
+	}
+
+	return encoded, nil
+}
+
 // Encode converts a decoded zedtoken to its opaque version.
 func Encode(decoded *zedtoken.DecodedZedToken) (*v1.ZedToken, error) {
 	marshalled, err := decoded.MarshalVT()
@@ -99,6 +120,49 @@ func DecodeRevision(encoded *v1.ZedToken, ds revisionDecoder) (datastore.Revisio
 	}
 }
 
+// WatchPosition is the position at which a Watch resumes.
+type WatchPosition struct {
+	// AfterRevision is the revision after which changes are watched.
+	AfterRevision datastore.Revision
+
+	// Revision is the revision of the changes in which the position lies.
+	Revision datastore.Revision
+
+	// Sequence is the number of changes of Revision which have already been emitted, and which
+	// must be skipped when resuming. It is zero when the position is not within a revision.
+	Sequence uint32
+}
+
+// DecodeWatchPosition converts and extracts the position at which a Watch resumes from a zedtoken
+// or legacy zookie. Tokens which are not positioned within a revision resume after their revision.
+func DecodeWatchPosition(encoded *v1.ZedToken, ds revisionDecoder) (WatchPosition, error) {
+	rev, err := DecodeRevision(encoded, ds)
+	if err != nil {
+		return WatchPosition{}, err
+	}
+
+	decoded, err := Decode(encoded)
+	if err != nil {
+		return WatchPosition{}, err
+	}
+
+	v1Token := decoded.GetV1()
+	if v1Token.GetWatchSequence() == 0 {
+		return WatchPosition{AfterRevision: rev, Revision: rev}, nil
+	}
+
+	afterRevision, err := ds.RevisionFromString(v1Token.WatchAfterRevision)
+	if err != nil {
+		return WatchPosition{}, fmt.Errorf(errDecodeError, err)
+	}
+
+	return WatchPosition{
+		AfterRevision: afterRevision,
+		Revision:      rev,
+		Sequence:      v1Token.WatchSequence,
+	}, nil
+}
+
 type revisionDecoder interface {
 	RevisionFromString(string) (datastore.Revision, error)
 }
@@ -40,6 +40,33 @@ func TestZedTokenEncode(t *testing.T) {
 	}
 }
 
+func TestWatchPositionEncode(t *testing.T) {
+	require := require.New(t)
+	before := revision.NewFromDecimal(decimal.NewFromInt(1))
+	rev := revision.NewFromDecimal(decimal.NewFromInt(2))
+
+	encoded, err := NewFromWatchPosition(rev, before, 3)
+	require.NoError(err)
+
+	// Positioned tokens remain usable as consistency tokens for their revision.
+	decoded, err := DecodeRevision(encoded, revision.DecimalDecoder{})
+	require.NoError(err)
+	require.True(rev.Equal(decoded))
+
+	position, err := DecodeWatchPosition(encoded, revision.DecimalDecoder{})
+	require.NoError(err)
+	require.True(before.Equal(position.AfterRevision))
+	require.True(rev.Equal(position.Revision))
+	require.Equal(uint32(3), position.Sequence)
+
+	// Tokens which are not positioned within a revision resume after their revision.
+	position, err = DecodeWatchPosition(MustNewFromRevision(rev), revision.DecimalDecoder{})
+	require.NoError(err)
+	require.True(rev.Equal(position.AfterRevision))
+	require.True(rev.Equal(position.Revision))
+	require.Zero(position.Sequence)
+}
+
 var decodeTests = []struct {
 	format           string
 	token            string
@@ -3,6 +3,7 @@ package main
 import (
 	"github.com/authzed/spicedb/tools/analyzers/closeafterusagecheck"
 	"github.com/authzed/spicedb/tools/analyzers/exprstatementcheck"
+	"github.com/authzed/spicedb/tools/analyzers/iteratorerrorcheck"
 	"github.com/authzed/spicedb/tools/analyzers/nilvaluecheck"
 	"github.com/authzed/spicedb/tools/analyzers/paniccheck"
 	"golang.org/x/tools/go/analysis/multichecker"
@@ -13,6 +14,7 @@ func main() {
 		nilvaluecheck.Analyzer(),
 		exprstatementcheck.Analyzer(),
 		closeafterusagecheck.Analyzer(),
+		iteratorerrorcheck.Analyzer(),
 		paniccheck.Analyzer(),
 	)
 }
@@ -0,0 +1,109 @@
+package iteratorerrorcheck
+
+import (
+	"flag"
+	"go/ast"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+func sliceMap(s []string, f func(value string) string) []string {
+	mapped := make([]string, 0, len(s))
+	for _, value := range s {
+		mapped = append(mapped, f(value))
+	}
+	return mapped
+}
+
+func Analyzer() *analysis.Analyzer {
+	flagSet := flag.NewFlagSet("iteratorerrorcheck", flag.ExitOnError)
+	iteratorTypes := flagSet.String(
+		"iterator-types",
+		"",
+		`semicolon delimited full paths of the iterator types whose Next() returns a value and an error, which must not be discarded`,
+	)
+	skip := flagSet.String("skip-pkg", "", "package(s) to skip for linting")
+
+	return &analysis.Analyzer{
+		Name: "iteratorerrorcheck",
+		Doc:  "reports calls to Next() of the specified iterator types whose error is discarded",
+		Run: func(pass *analysis.Pass) (any, error) {
+			// Check for a skipped package.
+			if len(*skip) > 0 {
+				skipped := sliceMap(strings.Split(*skip, ","), strings.TrimSpace)
+				for _, s := range skipped {
+					if strings.Contains(pass.Pkg.Path(), s) {
+						return nil, nil
+					}
+				}
+			}
+
+			typePaths := map[string]struct{}{}
+			for _, entry := range strings.Split(*iteratorTypes, ";") {
+				if len(entry) == 0 {
+					continue
+				}
+
+				typePaths[entry] = struct{}{}
+			}
+
+			isIteratorNext := func(expr ast.Expr) (string, bool) {
+				call, ok := expr.(*ast.CallExpr)
+				if !ok {
+					return "", false
+				}
+
+				selector, ok := call.Fun.(*ast.SelectorExpr)
+				if !ok || selector.Sel.Name != "Next" {
+					return "", false
+				}
+
+				foundType := pass.TypesInfo.TypeOf(selector.X)
+				if foundType == nil {
+					return "", false
+				}
+
+				typeString := foundType.String()
+				_, ok = typePaths[typeString]
+				return typeString, ok
+			}
+
+			inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+			nodeFilter := []ast.Node{
+				(*ast.ExprStmt)(nil),
+				(*ast.AssignStmt)(nil),
+			}
+
+			inspect.Preorder(nodeFilter, func(n ast.Node) {
+				switch s := n.(type) {
+				case *ast.ExprStmt:
+					if typeString, ok := isIteratorNext(s.X); ok {
+						pass.Reportf(s.Pos(), "In package %s: result and error of Next() on %s are discarded", pass.Pkg.Path(), typeString)
+					}
+
+				case *ast.AssignStmt:
+					if len(s.Rhs) != 1 || len(s.Lhs) != 2 {
+						return
+					}
+
+					typeString, ok := isIteratorNext(s.Rhs[0])
+					if !ok {
+						return
+					}
+
+					if ident, ok := s.Lhs[1].(*ast.Ident); ok && ident.Name == "_" {
+						pass.Reportf(s.Lhs[1].Pos(), "In package %s: error returned by Next() on %s is discarded", pass.Pkg.Path(), typeString)
+					}
+				}
+			})
+
+			return nil, nil
+		},
+		Requires: []*analysis.Analyzer{inspect.Analyzer},
+		Flags:    *flagSet,
+	}
+}
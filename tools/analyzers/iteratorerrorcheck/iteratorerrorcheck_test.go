@@ -0,0 +1,15 @@
+package iteratorerrorcheck
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analyzer := Analyzer()
+	analyzer.Flags.Set("iterator-types", "discardederror.SomeIterator")
+
+	testdata := analysistest.TestData()
+	analysistest.Run(t, testdata, analyzer, "discardederror")
+}
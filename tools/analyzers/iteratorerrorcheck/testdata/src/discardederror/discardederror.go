@@ -0,0 +1,59 @@
+package discardederror
+
+import (
+	"fmt"
+)
+
+type SomeIterator interface {
+	Next() (any, error)
+	Close()
+}
+
+type OtherIterator interface {
+	Next() (any, error)
+}
+
+func CheckedError(si SomeIterator) error {
+	for {
+		v, err := si.Next()
+		if err != nil {
+			return err
+		}
+		if v == nil {
+			return nil
+		}
+
+		fmt.Println(v)
+	}
+}
+
+func DiscardedError(si SomeIterator) {
+	for {
+		v, _ := si.Next() // want "error returned by Next\\(\\) on discardederror.SomeIterator is discarded"
+		if v == nil {
+			return
+		}
+
+		fmt.Println(v)
+	}
+}
+
+func DiscardedErrorOnAssignment(si SomeIterator) {
+	var v any
+	v, _ = si.Next() // want "error returned by Next\\(\\) on discardederror.SomeIterator is discarded"
+	fmt.Println(v)
+}
+
+func DiscardedResult(si SomeIterator) {
+	si.Next() // want "result and error of Next\\(\\) on discardederror.SomeIterator are discarded"
+}
+
+func SkippedValue(si SomeIterator) error {
+	_, err := si.Next()
+	return err
+}
+
+func OtherType(oi OtherIterator) {
+	v, _ := oi.Next()
+	fmt.Println(v)
+}